@@ -64,42 +64,123 @@ type Query struct {
 	NodeID int
 }
 
-// ParseConnectionString will parse a string to create a valid connection URL
+// ConnectionStringError is returned by ParseConnectionString for an invalid
+// connection string.
+type ConnectionStringError struct {
+	ConnectionString string
+	Reason           string
+}
+
+func (e *ConnectionStringError) Error() string {
+	return fmt.Sprintf("invalid connection string %q: %s", e.ConnectionString, e.Reason)
+}
+
+// ParseConnectionString will parse a string to create a valid connection URL.
+//
+// The string is "host[:port][/path]", with the https scheme if ssl is set and
+// http otherwise, DefaultHost if the host is empty and DefaultPort if no port
+// is given. It may also be a URL starting with http:// or https://, whose
+// scheme and port are kept. The host may be a bare IPv6 address, which then
+// cannot be followed by a port. The path, without its trailing slashes, is
+// the base of the endpoints of a server behind a reverse proxy.
 func ParseConnectionString(path string, ssl bool) (url.URL, error) {
-	var host string
-	var port int
+	invalid := func(format string, args ...interface{}) error {
+		return &ConnectionStringError{ConnectionString: path, Reason: fmt.Sprintf(format, args...)}
+	}
 
-	h, p, err := net.SplitHostPort(path)
-	if err != nil {
-		if path == "" {
-			host = DefaultHost
-		} else {
-			host = path
+	s := strings.TrimSpace(path)
+	scheme := "http"
+	if ssl {
+		scheme = "https"
+	}
+	if i := strings.Index(s, "://"); i >= 0 {
+		scheme, s = strings.ToLower(s[:i]), s[i+len("://"):]
+		if scheme != "http" && scheme != "https" {
+			return url.URL{}, invalid("unsupported protocol scheme %q", scheme)
+		}
+		if s == "" || s[0] == '/' {
+			return url.URL{}, invalid("no host")
 		}
-		// If they didn't specify a port, always use the default port
-		port = DefaultPort
 	} else {
-		host = h
-		port, err = strconv.Atoi(p)
-		if err != nil {
-			return url.URL{}, fmt.Errorf("invalid port number %q: %s\n", path, err)
+		hostPort, rest := s, ""
+		if i := strings.IndexByte(s, '/'); i >= 0 {
+			hostPort, rest = s[:i], s[i:]
 		}
+		host, port := hostPort, DefaultPort
+		if !isBareIPv6(hostPort) {
+			if h, p, err := net.SplitHostPort(hostPort); err == nil {
+				if port, err = strconv.Atoi(p); err != nil || port < 1 || port > 65535 {
+					return url.URL{}, invalid("invalid port number %q", p)
+				}
+				host = h
+			} else if strings.Count(hostPort, ":") > 0 && !strings.HasSuffix(hostPort, "]") {
+				return url.URL{}, invalid("%v", err)
+			}
+		}
+		host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+		if host == "" {
+			host = DefaultHost
+		}
+		if strings.Contains(host, ":") {
+			host = bracketIPv6(host)
+		}
+		if ssl && port != 443 || !ssl && port != 80 {
+			host += ":" + strconv.Itoa(port)
+		}
+		s = host + rest
 	}
 
-	u := url.URL{
-		Scheme: "http",
-		Host:   host,
+	hostPort, rest := s, ""
+	if i := strings.IndexByte(s, '/'); i >= 0 {
+		hostPort, rest = s[:i], s[i:]
 	}
-	if ssl {
-		u.Scheme = "https"
-		if port != 443 {
-			u.Host = net.JoinHostPort(host, strconv.Itoa(port))
+	if isBareIPv6(hostPort) {
+		hostPort = bracketIPv6(hostPort)
+	} else if i := strings.IndexByte(hostPort, ']'); strings.HasPrefix(hostPort, "[") && i > 0 {
+		hostPort = bracketIPv6(hostPort[1:i]) + hostPort[i+1:]
+	}
+	u, err := url.Parse(scheme + "://" + hostPort + rest)
+	if err != nil {
+		if ue, ok := err.(*url.Error); ok {
+			err = ue.Err
 		}
-	} else if port != 80 {
-		u.Host = net.JoinHostPort(host, strconv.Itoa(port))
+		return url.URL{}, invalid("%v", err)
 	}
+	switch {
+	case u.User != nil:
+		return url.URL{}, invalid("credentials are not allowed, use Config.Username and Config.Password")
+	case u.RawQuery != "" || u.Fragment != "":
+		return url.URL{}, invalid("a query or fragment is not allowed")
+	}
+	if p := u.Port(); p != "" {
+		if port, err := strconv.Atoi(p); err != nil || port < 1 || port > 65535 {
+			return url.URL{}, invalid("invalid port number %q", p)
+		}
+	}
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.RawPath = strings.TrimRight(u.RawPath, "/")
+	return *u, nil
+}
 
-	return u, nil
+// isBareIPv6 reports whether host is an IPv6 address, possibly with a zone,
+// that is not in brackets.
+func isBareIPv6(host string) bool {
+	if !strings.Contains(host, ":") || strings.HasPrefix(host, "[") {
+		return false
+	}
+	if i := strings.IndexByte(host, '%'); i >= 0 {
+		host = host[:i]
+	}
+	return net.ParseIP(host) != nil
+}
+
+// bracketIPv6 puts the IPv6 address host in brackets, escaping the percent
+// sign of its zone as URLs require.
+func bracketIPv6(host string) string {
+	if i := strings.IndexByte(host, '%'); i >= 0 && !strings.HasPrefix(host[i:], "%25") {
+		host = host[:i] + "%25" + host[i+1:]
+	}
+	return "[" + host + "]"
 }
 
 // Config is used to specify what server to connect to.
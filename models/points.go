@@ -1344,26 +1344,8 @@ func pointKey(measurement string, tags Tags, fields Fields, t time.Time) ([]byte
 	}
 
 	for key, value := range fields {
-		switch value := value.(type) {
-		case float64:
-			// Ensure the caller validates and handles invalid field values
-			if math.IsInf(value, 0) {
-				return nil, fmt.Errorf("+/-Inf is an unsupported value for field %s", key)
-			}
-			if math.IsNaN(value) {
-				return nil, fmt.Errorf("NaN is an unsupported value for field %s", key)
-			}
-		case float32:
-			// Ensure the caller validates and handles invalid field values
-			if math.IsInf(float64(value), 0) {
-				return nil, fmt.Errorf("+/-Inf is an unsupported value for field %s", key)
-			}
-			if math.IsNaN(float64(value)) {
-				return nil, fmt.Errorf("NaN is an unsupported value for field %s", key)
-			}
-		}
-		if len(key) == 0 {
-			return nil, fmt.Errorf("all fields must have non-empty names")
+		if err := checkField(key, value); err != nil {
+			return nil, err
 		}
 	}
 
@@ -1378,6 +1360,90 @@ func pointKey(measurement string, tags Tags, fields Fields, t time.Time) ([]byte
 	return key, nil
 }
 
+// checkField checks that a field has a name and a value that can be
+// represented.
+func checkField(key string, value interface{}) error {
+	switch value := value.(type) {
+	case float64:
+		// Ensure the caller validates and handles invalid field values
+		if math.IsInf(value, 0) {
+			return fmt.Errorf("+/-Inf is an unsupported value for field %s", key)
+		}
+		if math.IsNaN(value) {
+			return fmt.Errorf("NaN is an unsupported value for field %s", key)
+		}
+	case float32:
+		// Ensure the caller validates and handles invalid field values
+		if math.IsInf(float64(value), 0) {
+			return fmt.Errorf("+/-Inf is an unsupported value for field %s", key)
+		}
+		if math.IsNaN(float64(value)) {
+			return fmt.Errorf("NaN is an unsupported value for field %s", key)
+		}
+	}
+	if len(key) == 0 {
+		return fmt.Errorf("all fields must have non-empty names")
+	}
+	return nil
+}
+
+// NewPointFromSlices is like NewPoint, with the fields given as the parallel
+// slices fieldKeys and fieldValues instead of a map. tags must be sorted by
+// key and fieldKeys must be sorted, both without duplicates.
+func NewPointFromSlices(name string, tags Tags, fieldKeys []string, fieldValues []interface{}, t time.Time) (Point, error) {
+	if len(fieldKeys) != len(fieldValues) {
+		return nil, fmt.Errorf("%d field keys given for %d field values", len(fieldKeys), len(fieldValues))
+	}
+	if len(fieldKeys) == 0 {
+		return nil, ErrPointMustHaveAField
+	}
+
+	if !t.IsZero() {
+		if err := CheckTime(t); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := 1; i < len(tags); i++ {
+		if bytes.Compare(tags[i-1].Key, tags[i].Key) >= 0 {
+			return nil, fmt.Errorf("tag key %s is not sorted or duplicated", tags[i].Key)
+		}
+	}
+	for i, key := range fieldKeys {
+		if err := checkField(key, fieldValues[i]); err != nil {
+			return nil, err
+		}
+		if i > 0 && fieldKeys[i-1] >= key {
+			return nil, fmt.Errorf("field key %s is not sorted or duplicated", key)
+		}
+	}
+
+	key := MakeKey([]byte(name), tags)
+	var size int
+	for _, field := range fieldKeys {
+		sz := seriesKeySize(key, []byte(field))
+		if sz > MaxKeyLength {
+			return nil, fmt.Errorf("max key length exceeded: %v > %v", sz, MaxKeyLength)
+		}
+		// Room for the escaping and for most values.
+		size += len(field) + 24
+	}
+
+	fields := make([]byte, 0, size)
+	for i, field := range fieldKeys {
+		if i > 0 {
+			fields = append(fields, ',')
+		}
+		fields = appendField(fields, field, fieldValues[i])
+	}
+
+	return &point{
+		key:    key,
+		time:   t,
+		fields: fields,
+	}, nil
+}
+
 func seriesKeySize(key, field []byte) int {
 	// 4 is the length of the tsm1.fieldKeySeparator constant.  It's inlined here to avoid a circular
 	// dependency.
@@ -1478,6 +1544,83 @@ func (p *point) Round(d time.Duration) {
 	p.time = p.time.Round(d)
 }
 
+// RoundedPoint returns a point like p with the timestamp rounded to the given
+// duration, leaving p untouched. p itself is returned if rounding does not
+// change its timestamp, otherwise the returned point shares the encoded key
+// and fields of p.
+func RoundedPoint(p Point, d time.Duration) Point {
+	t := p.Time().Round(d)
+	if t.Equal(p.Time()) {
+		return p
+	}
+	return PointWithTime(p, t)
+}
+
+// AppendPrecisionString appends the result of p.PrecisionString(precision) to
+// buf and returns the result, without allocating a string for points parsed
+// or created by this package.
+func AppendPrecisionString(buf []byte, p Point, precision string) []byte {
+	pp, ok := p.(*point)
+	if !ok {
+		return append(buf, p.PrecisionString(precision)...)
+	}
+	buf = append(buf, pp.key...)
+	buf = append(buf, ' ')
+	buf = append(buf, pp.fields...)
+	if !pp.time.IsZero() {
+		buf = append(buf, ' ')
+		buf = strconv.AppendInt(buf, pp.UnixNano()/GetPrecisionMultiplier(precision), 10)
+	}
+	return buf
+}
+
+// PrecisionStringSize returns the length of p.PrecisionString(precision),
+// without allocating it.
+func PrecisionStringSize(p Point, precision string) int {
+	size := p.StringSize()
+	if p.Time().IsZero() {
+		return size
+	}
+	ns := p.UnixNano()
+	return size - intDigits(ns) + intDigits(ns/GetPrecisionMultiplier(precision))
+}
+
+// intDigits returns the length of the decimal representation of n.
+func intDigits(n int64) int {
+	digits := 1
+	if n < 0 {
+		digits++
+	}
+	for n > 9 || n < -9 {
+		digits++
+		n /= 10
+	}
+	return digits
+}
+
+// PointWithTime returns a point like p with the timestamp t, leaving p
+// untouched. The returned point shares the encoded key and fields of p.
+func PointWithTime(p Point, t time.Time) Point {
+	if pp, ok := p.(*point); ok {
+		return &point{
+			key:    pp.key,
+			time:   t,
+			fields: pp.fields,
+		}
+	}
+
+	// Other implementations can only be copied through their public API.
+	fields, err := p.Fields()
+	if err != nil {
+		return p
+	}
+	rp, err := NewPoint(string(p.Name()), p.Tags(), fields, t)
+	if err != nil {
+		return p
+	}
+	return rp
+}
+
 // Tags returns the tag set for the point.
 func (p *point) Tags() Tags {
 	if p.cachedTags != nil {
@@ -1975,6 +1975,29 @@ func TestNewPointWithoutField(t *testing.T) {
 	}
 }
 
+func TestNewPointFromSlices(t *testing.T) {
+	tags := models.NewTags(map[string]string{"host": "a", "region": "us west"})
+	pt, err := models.NewPointFromSlices("cpu", tags, []string{"count", "value"}, []interface{}{int64(3), 1.5}, time.Unix(1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := models.MustNewPoint("cpu", tags, models.Fields{"count": int64(3), "value": 1.5}, time.Unix(1, 0))
+	if pt.String() != exp.String() {
+		t.Errorf("NewPointFromSlices().String() mismatch.\ngot %v\nexp %v", pt.String(), exp.String())
+	}
+
+	if _, err := models.NewPointFromSlices("cpu", nil, []string{"value", "count"}, []interface{}{1.5, int64(3)}, time.Unix(1, 0)); err == nil {
+		t.Error("NewPointFromSlices() expected error for unsorted fields. got nil")
+	}
+	unsorted := models.Tags{models.NewTag([]byte("region"), []byte("b")), models.NewTag([]byte("host"), []byte("a"))}
+	if _, err := models.NewPointFromSlices("cpu", unsorted, []string{"value"}, []interface{}{1.5}, time.Unix(1, 0)); err == nil {
+		t.Error("NewPointFromSlices() expected error for unsorted tags. got nil")
+	}
+	if _, err := models.NewPointFromSlices("cpu", nil, nil, nil, time.Unix(1, 0)); err != models.ErrPointMustHaveAField {
+		t.Errorf("NewPointFromSlices() unexpected error.\ngot %v\nexp %v", err, models.ErrPointMustHaveAField)
+	}
+}
+
 func TestNewPointUnhandledType(t *testing.T) {
 	// nil value
 	pt := models.MustNewPoint("cpu", nil, models.Fields{"value": nil}, time.Unix(0, 0))
@@ -2129,6 +2152,47 @@ func TestRoundedString(t *testing.T) {
 	}
 }
 
+func TestRoundedPoint(t *testing.T) {
+	tm, _ := time.Parse(time.RFC3339Nano, "2000-01-01T12:34:56.789012345Z")
+	pt := models.MustNewPoint("cpu", models.NewTags(map[string]string{"host": "a"}), models.Fields{"value": int64(1)}, tm)
+
+	rp := models.RoundedPoint(pt, time.Second)
+	if got, exp := rp.String(), "cpu,host=a value=1i 946730097000000000"; got != exp {
+		t.Errorf("RoundedPoint() mismatch:\n actual:	%v\n exp:		%v", got, exp)
+	}
+	if got, exp := pt.String(), "cpu,host=a value=1i 946730096789012345"; got != exp {
+		t.Errorf("RoundedPoint() modified the original point:\n actual:	%v\n exp:		%v", got, exp)
+	}
+
+	// Mutating the copy must not affect the original.
+	rp.AddTag("region", "east")
+	if got, exp := pt.String(), "cpu,host=a value=1i 946730096789012345"; got != exp {
+		t.Errorf("mutating the rounded point modified the original:\n actual:	%v\n exp:		%v", got, exp)
+	}
+
+	if rp := models.RoundedPoint(pt, time.Nanosecond); rp != pt {
+		t.Errorf("RoundedPoint() copied a point that needs no rounding")
+	}
+}
+
+func TestAppendPrecisionString(t *testing.T) {
+	tm, _ := time.Parse(time.RFC3339Nano, "1960-01-01T12:34:56.789012345Z")
+	pt := models.MustNewPoint("cpu", models.NewTags(map[string]string{"host": "a"}), models.Fields{"value": int64(1)}, tm)
+	noTime := models.MustNewPoint("cpu", nil, models.Fields{"value": 1.5}, time.Time{})
+
+	for _, p := range []models.Point{pt, noTime} {
+		for _, precision := range []string{"", "n", "ns", "u", "ms", "s", "m", "h"} {
+			got := string(models.AppendPrecisionString([]byte("x"), p, precision))
+			if exp := "x" + p.PrecisionString(precision); got != exp {
+				t.Errorf("AppendPrecisionString(%q) mismatch:\n actual:	%v\n exp:		%v", precision, got, exp)
+			}
+			if got, exp := models.PrecisionStringSize(p, precision), len(p.PrecisionString(precision)); got != exp {
+				t.Errorf("PrecisionStringSize(%q) mismatch:\n actual:	%v\n exp:		%v", precision, got, exp)
+			}
+		}
+	}
+}
+
 func TestParsePointsStringWithExtraBuffer(t *testing.T) {
 	b := make([]byte, 70*5000)
 	buf := bytes.NewBuffer(b)
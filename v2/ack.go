@@ -0,0 +1,237 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// AckStatus is what is known of a point written by WriteAcked.
+type AckStatus int
+
+const (
+	// AckUnknown is the status of a point that may or may not have been
+	// stored, as when the request timed out or the server failed with a 5xx
+	// status.
+	AckUnknown AckStatus = iota
+
+	// AckAccepted is the status of a point the server stored.
+	AckAccepted
+
+	// AckDropped is the status of a point the server answered that it did
+	// not store, such as a line it could not parse or every point of a
+	// write rejected with a 4xx status.
+	AckDropped
+
+	// AckSent is the status of a point sent over a transport the server
+	// does not answer on, as UDP and TCP: it left the client, which is as
+	// much as can be known.
+	AckSent
+
+	// AckNotSent is the status of a point of a write that failed before
+	// anything was sent, as for an invalid batch, an open CircuitBreaker or
+	// a closed client.
+	AckNotSent
+
+	// AckSkipped is the status of the nil points of a batch, which writes
+	// skip.
+	AckSkipped
+)
+
+func (s AckStatus) String() string {
+	switch s {
+	case AckUnknown:
+		return "unknown"
+	case AckAccepted:
+		return "accepted"
+	case AckDropped:
+		return "dropped"
+	case AckSent:
+		return "sent"
+	case AckNotSent:
+		return "not sent"
+	case AckSkipped:
+		return "skipped"
+	}
+	return fmt.Sprintf("AckStatus(%d)", int(s))
+}
+
+// AckResult reports what became of each point of a write by WriteAcked.
+type AckResult struct {
+	// Status holds the status of each point, at the index of the point in
+	// the batch.
+	Status []AckStatus
+}
+
+// Count returns the number of points with status s.
+func (r AckResult) Count(s AckStatus) int {
+	var n int
+	for _, status := range r.Status {
+		if status == s {
+			n++
+		}
+	}
+	return n
+}
+
+// Indexes returns the indexes of the points with status s, in order.
+func (r AckResult) Indexes(s AckStatus) []int {
+	var indexes []int
+	for i, status := range r.Status {
+		if status == s {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// AckWriter is implemented by the HTTP, UDP and TCP clients, for pipelines
+// that acknowledge their input, such as by committing the offsets of a Kafka
+// consumer, only once it was written.
+type AckWriter interface {
+	// WriteAcked is like WriteContext, also returning the status of each
+	// point of bp. The HTTP client tells the points the server stored from
+	// those it dropped when it can: every point of a successful write is
+	// AckAccepted, and for a *PartialWriteError the lines the server
+	// reports it could not parse are mapped back to their points, which are
+	// AckDropped while the others are AckAccepted. Partial writes that do
+	// not name their lines, as for a field type conflict, leave the points
+	// AckUnknown, unless the server reported every point as dropped. The
+	// UDP and TCP clients report at best AckSent.
+	WriteAcked(ctx context.Context, bp BatchPoints) (AckResult, error)
+}
+
+// WriteAcked writes bp and reports the status of each of its points.
+func (c *client) WriteAcked(ctx context.Context, bp BatchPoints) (AckResult, error) {
+	var ws WriteStats
+	var lines lineIndex
+	err := c.writeContext(ctx, bp, nil, &ws, &lines)
+	return lines.result(bp, err), err
+}
+
+// WriteAcked writes bp and reports its points as AckSent once they were.
+func (uc *udpclient) WriteAcked(ctx context.Context, bp BatchPoints) (AckResult, error) {
+	ws, err := uc.WriteWithStats(ctx, bp)
+	return sentResult(bp, ws, err), err
+}
+
+// WriteAcked is like the UDP client's WriteAcked.
+func (uc *tcpclient) WriteAcked(ctx context.Context, bp BatchPoints) (AckResult, error) {
+	ws, err := uc.WriteWithStats(ctx, bp)
+	return sentResult(bp, ws, err), err
+}
+
+// sentResult returns the result of a write of bp over a transport without
+// acknowledgments, whose statistics are ws: points are AckSent if it
+// succeeded, AckNotSent if it failed before sending a payload and
+// AckUnknown otherwise, as it is not known which payloads got through.
+func sentResult(bp BatchPoints, ws WriteStats, err error) AckResult {
+	status := AckSent
+	switch {
+	case err != nil && ws.Flushes == 0:
+		status = AckNotSent
+	case err != nil:
+		status = AckUnknown
+	}
+	return newAckResult(bp, status)
+}
+
+// newAckResult returns the result of a write of bp whose points all have
+// status s, but for the nil ones.
+func newAckResult(bp BatchPoints, s AckStatus) AckResult {
+	points := bp.Points()
+	r := AckResult{Status: make([]AckStatus, len(points))}
+	for i, p := range points {
+		if p == nil {
+			r.Status[i] = AckSkipped
+		} else {
+			r.Status[i] = s
+		}
+	}
+	return r
+}
+
+// lineIndex maps the lines of a batch written over HTTP to the indexes of
+// their points, so that the lines a server reports in a partial write error
+// can be traced back to the points of the batch.
+type lineIndex struct {
+	// buf holds the lines, without their newlines; ends holds the end of
+	// each of them in buf, and points the index of its point.
+	buf    []byte
+	ends   []int
+	points []int
+
+	// sent is set once the body was sent.
+	sent bool
+}
+
+// add records line as the line of the point at index i.
+func (li *lineIndex) add(i int, line []byte) {
+	li.buf = append(li.buf, line...)
+	li.ends = append(li.ends, len(li.buf))
+	li.points = append(li.points, i)
+}
+
+// byLine returns the indexes of the points of each line.
+func (li *lineIndex) byLine() map[string][]int {
+	m := make(map[string][]int, len(li.ends))
+	start := 0
+	for j, end := range li.ends {
+		line := string(li.buf[start:end])
+		m[line] = append(m[line], li.points[j])
+		start = end
+	}
+	return m
+}
+
+// result returns the result of the write of bp, whose lines were recorded
+// in li, that failed with err.
+func (li *lineIndex) result(bp BatchPoints, err error) AckResult {
+	var er *ErrorResponse
+	switch {
+	case err == nil:
+		return newAckResult(bp, AckAccepted)
+	case !li.sent:
+		return newAckResult(bp, AckNotSent)
+	case !errors.As(err, &er):
+		return newAckResult(bp, AckUnknown)
+	}
+
+	var pe *PartialWriteError
+	if !errors.As(err, &pe) {
+		if er.StatusCode >= http.StatusBadRequest && er.StatusCode < http.StatusInternalServerError {
+			return newAckResult(bp, AckDropped)
+		}
+		return newAckResult(bp, AckUnknown)
+	}
+
+	failed := partialWriteLine.FindAllStringSubmatch(pe.Reason, -1)
+	if len(failed) == 0 {
+		if pe.droppedKnown && pe.Dropped == len(li.ends) {
+			return newAckResult(bp, AckDropped)
+		}
+		return newAckResult(bp, AckUnknown)
+	}
+
+	// The server reports every line it could not parse, and stores the
+	// others, which are accepted unless a reported line matches no point.
+	byLine := li.byLine()
+	rest := AckAccepted
+	r := newAckResult(bp, AckUnknown)
+	for _, m := range failed {
+		indexes, ok := byLine[m[1]]
+		if !ok {
+			rest = AckUnknown
+		}
+		for _, i := range indexes {
+			r.Status[i] = AckDropped
+		}
+	}
+	for i, s := range r.Status {
+		if s == AckUnknown {
+			r.Status[i] = rest
+		}
+	}
+	return r
+}
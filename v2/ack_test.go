@@ -0,0 +1,137 @@
+package client
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestClient_WriteAcked(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		status int
+		body   string
+		exp    []AckStatus
+	}{
+		{
+			name:   "written",
+			status: http.StatusNoContent,
+			exp:    []AckStatus{AckAccepted, AckAccepted, AckAccepted, AckAccepted},
+		},
+		{
+			name:   "unable to parse",
+			status: http.StatusBadRequest,
+			body:   `{"error":"partial write: unable to parse 'cpu,host=server01 value=1i 1': invalid field format\nunable to parse 'cpu,host=server01 value=3i 3': invalid field format dropped=0"}`,
+			exp:    []AckStatus{AckAccepted, AckDropped, AckAccepted, AckDropped},
+		},
+		{
+			name:   "unknown line",
+			status: http.StatusBadRequest,
+			body:   `{"error":"partial write: unable to parse 'cpu,host=server01 value=2i 2': invalid field format\nunable to parse 'mem value=': missing field value dropped=0"}`,
+			exp:    []AckStatus{AckUnknown, AckUnknown, AckDropped, AckUnknown},
+		},
+		{
+			name:   "field type conflict",
+			status: http.StatusBadRequest,
+			body:   `{"error":"partial write: field type conflict: input field \"value\" on measurement \"cpu\" is type integer, already exists as type float dropped=2"}`,
+			exp:    []AckStatus{AckUnknown, AckUnknown, AckUnknown, AckUnknown},
+		},
+		{
+			name:   "every point dropped",
+			status: http.StatusBadRequest,
+			body:   `{"error":"partial write: points beyond retention policy dropped=4"}`,
+			exp:    []AckStatus{AckDropped, AckDropped, AckDropped, AckDropped},
+		},
+		{
+			name:   "nothing parsed",
+			status: http.StatusBadRequest,
+			body:   `{"error":"unable to parse 'cpu,host=server01 value=0i 0': invalid field format"}`,
+			exp:    []AckStatus{AckDropped, AckDropped, AckDropped, AckDropped},
+		},
+		{
+			name:   "server error",
+			status: http.StatusInternalServerError,
+			body:   `{"error":"timeout"}`,
+			exp:    []AckStatus{AckUnknown, AckUnknown, AckUnknown, AckUnknown},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newErrorServer(tt.status, "", tt.body)
+			defer ts.Close()
+			c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+			defer c.Close()
+
+			result, err := c.(AckWriter).WriteAcked(context.Background(), newTestBatch(t, 4))
+			if (err == nil) != (tt.status == http.StatusNoContent) {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(result.Status, tt.exp) {
+				t.Errorf("unexpected status.  expected %v, actual %v", tt.exp, result.Status)
+			}
+		})
+	}
+}
+
+func TestClient_WriteAckedNotSent(t *testing.T) {
+	ts := newErrorServer(http.StatusNoContent, "", "")
+	defer ts.Close()
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, ValidatePoints: true})
+	defer c.Close()
+
+	// AddPoint skips nil points, which other implementations of
+	// BatchPoints can hold.
+	bp := newTestBatch(t, 2).(*batchpoints)
+	pt, _ := NewPoint("cpu", map[string]string{"time": "a"}, map[string]interface{}{"value": 1})
+	bp.points = append(bp.points, nil, pt)
+	result, err := c.(AckWriter).WriteAcked(context.Background(), bp)
+	if err == nil {
+		t.Fatal("expected the invalid point to fail the write")
+	}
+	exp := []AckStatus{AckNotSent, AckNotSent, AckSkipped, AckNotSent}
+	if !reflect.DeepEqual(result.Status, exp) {
+		t.Errorf("unexpected status.  expected %v, actual %v", exp, result.Status)
+	}
+	if n := result.Count(AckNotSent); n != 3 {
+		t.Errorf("unexpected count.  expected %v, actual %v", 3, n)
+	}
+	if indexes := result.Indexes(AckSkipped); !reflect.DeepEqual(indexes, []int{2}) {
+		t.Errorf("unexpected indexes.  expected %v, actual %v", []int{2}, indexes)
+	}
+}
+
+func TestUDPClient_WriteAcked(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	defer server.Close()
+	c, _ := NewUDPClient(UDPConfig{Addr: server.LocalAddr().String()})
+
+	result, err := c.(AckWriter).WriteAcked(context.Background(), newTestBatch(t, 2))
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if exp := []AckStatus{AckSent, AckSent}; !reflect.DeepEqual(result.Status, exp) {
+		t.Errorf("unexpected status.  expected %v, actual %v", exp, result.Status)
+	}
+
+	c.Close()
+	result, _ = c.(AckWriter).WriteAcked(context.Background(), newTestBatch(t, 1))
+	if exp := []AckStatus{AckNotSent}; !reflect.DeepEqual(result.Status, exp) {
+		t.Errorf("unexpected status after Close.  expected %v, actual %v", exp, result.Status)
+	}
+}
+
+func TestTCPClient_WriteAcked(t *testing.T) {
+	conn := &bufferConn{}
+	c := &tcpclient{conn: conn, payloadSize: TCPPayloadSize}
+	result, err := c.WriteAcked(context.Background(), newTestBatch(t, 2))
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if n := result.Count(AckSent); n != 2 {
+		t.Errorf("unexpected sent points.  expected %v, actual %v", 2, n)
+	}
+}
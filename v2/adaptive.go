@@ -0,0 +1,115 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+)
+
+// DefaultTargetLatency is the default latency of a write above which a
+// BatchingClient with AdaptiveBatching shrinks its batches.
+const DefaultTargetLatency = time.Second
+
+// AdaptiveStatsCollector is implemented by a StatsCollector that is told
+// about the batch size of a BatchingClient with AdaptiveBatching, when it is
+// set on the HTTP, UDP or TCP client the BatchingClient writes with.
+type AdaptiveStatsCollector interface {
+	// BatchSizeChanged is called with the number of points that triggers
+	// a write, when the BatchingClient is created and whenever it changes.
+	BatchSizeChanged(size int)
+}
+
+// aimd adjusts the batch size of a BatchingClient to the writes: a write that
+// is slower than target, timed out or was throttled halves it, and a full
+// batch written faster than target grows it by step, within min and max. It
+// belongs to the writing goroutine.
+type aimd struct {
+	min, max, step int
+	target         time.Duration
+	stats          AdaptiveStatsCollector
+}
+
+// validateAdaptive checks the fields of opts used by AdaptiveBatching.
+func validateAdaptive(opts BatchingOptions) error {
+	switch {
+	case opts.MinBatchSize < 0:
+		return &ConfigError{Field: "MinBatchSize", Reason: "must not be negative"}
+	case opts.MaxBatchSize < 0:
+		return &ConfigError{Field: "MaxBatchSize", Reason: "must not be negative"}
+	case opts.MaxBatchSize > 0 && opts.MinBatchSize > opts.MaxBatchSize:
+		return &ConfigError{Field: "MinBatchSize", Reason: "must not be larger than MaxBatchSize"}
+	case opts.TargetLatency < 0:
+		return &ConfigError{Field: "TargetLatency", Reason: "must not be negative"}
+	}
+	return nil
+}
+
+// newAIMD returns the controller of opts, which BatchSize, MaxBatchSize and
+// MinBatchSize have their defaults in by then, and the batch size to start
+// with.
+func newAIMD(opts BatchingOptions, stats AdaptiveStatsCollector) (*aimd, int) {
+	a := &aimd{
+		min:    opts.MinBatchSize,
+		max:    opts.MaxBatchSize,
+		step:   opts.MinBatchSize,
+		target: opts.TargetLatency,
+		stats:  stats,
+	}
+	if a.target == 0 {
+		a.target = DefaultTargetLatency
+	}
+	return a, a.clamp(opts.BatchSize)
+}
+
+func (a *aimd) clamp(size int) int {
+	switch {
+	case size < a.min:
+		return a.min
+	case size > a.max:
+		return a.max
+	}
+	return size
+}
+
+// observe returns the batch size following a write of points in size
+// batches that took latency and failed with err.
+func (a *aimd) observe(size, points int, latency time.Duration, err error) int {
+	switch {
+	case overloaded(err) || latency > a.target:
+		size = a.clamp(size / 2)
+	case err == nil && points >= size:
+		size = a.clamp(size + a.step)
+	}
+	return size
+}
+
+// overloaded reports whether err tells that the server is too busy for the
+// writes: a timeout, or a 429 or 503 status.
+func overloaded(err error) bool {
+	if err == nil {
+		return false
+	}
+	var er *ErrorResponse
+	if errors.As(err, &er) {
+		return er.StatusCode == http.StatusTooManyRequests || er.StatusCode == http.StatusServiceUnavailable
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// resize sets the batch size of bc with AdaptiveBatching and reports a
+// change.
+func (bc *BatchingClient) resize(size int) {
+	if size == bc.batchSize {
+		return
+	}
+	bc.batchSize = size
+	if bc.adaptive.stats != nil {
+		bc.adaptive.stats.BatchSizeChanged(size)
+	}
+}
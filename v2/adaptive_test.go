@@ -0,0 +1,142 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// simClient is a Client whose writes take a time that grows with the number
+// of points, on a clock of its own, and fail with a 503 status above a number
+// of points. It records the sizes of the batches and those reported to the
+// AdaptiveStatsCollector.
+type simClient struct {
+	batchRecorder
+
+	mu       sync.Mutex
+	now      time.Time
+	perPoint time.Duration
+	maxOK    int
+	targets  []int
+}
+
+func (c *simClient) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *simClient) After(d time.Duration) <-chan time.Time { return nil }
+
+func (c *simClient) Write(bp BatchPoints) error {
+	n := len(bp.Points())
+	c.mu.Lock()
+	c.now = c.now.Add(10*time.Millisecond + time.Duration(n)*c.perPoint)
+	c.mu.Unlock()
+	c.batchRecorder.Write(bp)
+	if n > c.maxOK {
+		return &ErrorResponse{StatusCode: http.StatusServiceUnavailable}
+	}
+	return nil
+}
+
+func (c *simClient) statsCollector() StatsCollector { return c }
+
+func (c *simClient) WriteDone(points, bytes int, dur time.Duration, err error) {}
+
+func (c *simClient) QueryDone(q string, dur time.Duration, err error) {}
+
+func (c *simClient) BatchSizeChanged(size int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.targets = append(c.targets, size)
+}
+
+func TestBatchingClient_AdaptiveBatching(t *testing.T) {
+	// A write takes 10ms and 1ms per point, so that batches of up to 990
+	// points take less than the target of a second. Batches of more than
+	// 3000 points are refused.
+	sim := &simClient{perPoint: time.Millisecond, maxOK: 3000}
+	bc, err := NewBatchingClient(sim, BatchingOptions{
+		BatchSize:        5000,
+		AdaptiveBatching: true,
+		MinBatchSize:     50,
+		TargetLatency:    time.Second,
+		Clock:            sim,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	defer bc.Close()
+
+	points := newTestPoints(200000)
+	bc.AddPoints(points[:100000])
+	bc.Flush()
+
+	// The refused batch halves the size, then the slow ones, before it
+	// grows back additively.
+	sim.mu.Lock()
+	targets := append([]int(nil), sim.targets...)
+	sim.mu.Unlock()
+	if len(targets) < 5 || targets[0] != 5000 || targets[1] != 2500 || targets[2] != 1250 || targets[3] != 625 || targets[4] != 675 {
+		t.Fatalf("unexpected batch sizes.  expected %v..., actual %v", []int{5000, 2500, 1250, 625, 675}, targets)
+	}
+
+	// Once the size found the target, the full batches stay between half
+	// the largest fast size and the first slow one.
+	sizes := sim.sizes()
+	if len(sizes) < 50 {
+		t.Fatalf("unexpected number of writes.  expected at least %v, actual %v", 50, len(sizes))
+	}
+	for i, n := range sizes[10 : len(sizes)-1] {
+		if n < 495 || n > 1040 {
+			t.Errorf("unexpected size of write %d.  expected between %v and %v, actual %v", i+10, 495, 1040, n)
+		}
+	}
+
+	// Flush writes the points held, fewer than the size, without growing
+	// it.
+	sim.mu.Lock()
+	before := len(sim.targets)
+	sim.mu.Unlock()
+	bc.AddPoints(points[100000:100010])
+	bc.Flush()
+	sizes = sim.sizes()
+	if n := sizes[len(sizes)-1]; n != 10 {
+		t.Errorf("unexpected size of the flushed batch.  expected %v, actual %v", 10, n)
+	}
+	sim.mu.Lock()
+	after := len(sim.targets)
+	sim.mu.Unlock()
+	if after != before {
+		t.Errorf("unexpected batch size changes.  expected %v, actual %v", before, after)
+	}
+}
+
+func TestOverloaded(t *testing.T) {
+	for _, tt := range []struct {
+		err error
+		exp bool
+	}{
+		{nil, false},
+		{&ErrorResponse{StatusCode: http.StatusServiceUnavailable}, true},
+		{&ThrottledError{ErrorResponse: ErrorResponse{StatusCode: http.StatusTooManyRequests}}, true},
+		{&ErrorResponse{StatusCode: http.StatusBadRequest}, false},
+		{&WriteTimeoutError{}, true},
+		{errors.New("boom"), false},
+	} {
+		if got := overloaded(tt.err); got != tt.exp {
+			t.Errorf("unexpected overloaded(%v).  expected %v, actual %v", tt.err, tt.exp, got)
+		}
+	}
+}
+
+func TestNewBatchingClient_InvalidAdaptive(t *testing.T) {
+	var ce *ConfigError
+	_, err := NewBatchingClient(&batchRecorder{}, BatchingOptions{AdaptiveBatching: true, MinBatchSize: 100, MaxBatchSize: 10})
+	if !errors.As(err, &ce) || ce.Field != "MinBatchSize" {
+		t.Errorf("unexpected error.  expected %T, actual %v", ce, err)
+	}
+}
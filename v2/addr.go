@@ -0,0 +1,128 @@
+package client
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// DefaultHTTPPort is the port of an HTTP address given without a scheme nor
+// a port, such as "localhost".
+const DefaultHTTPPort = 8086
+
+// parseHTTPAddr parses the Addr of an HTTP config, see HTTPConfig.Addr. An
+// address without a scheme gets https if secure is set and http otherwise,
+// and DefaultHTTPPort if it has no port. A bare IPv6 host is put in brackets,
+// and the trailing slashes of the path are removed. The errors are
+// *ConfigError of the Addr field.
+func parseHTTPAddr(addr string, secure bool) (*url.URL, error) {
+	invalid := func(format string, args ...interface{}) error {
+		return &ConfigError{Field: "Addr", Reason: fmt.Sprintf("%q: ", addr) + fmt.Sprintf(format, args...)}
+	}
+
+	s := strings.TrimSpace(addr)
+	if s == "" {
+		return nil, &ConfigError{Field: "Addr", Reason: "no address given"}
+	}
+	scheme := "http"
+	if secure {
+		scheme = "https"
+	}
+	if i := strings.Index(s, "://"); i >= 0 {
+		scheme, s = strings.ToLower(s[:i]), s[i+len("://"):]
+		switch scheme {
+		case "http", "https":
+		case "unix":
+			u, err := url.Parse(addr)
+			if err != nil {
+				return nil, invalid("%v", unwrapURLError(err))
+			}
+			return u, nil
+		default:
+			return nil, invalid("unsupported protocol scheme %q, the address must start with http://, https:// or unix://", scheme)
+		}
+	} else if host, rest := splitHostPath(s); !hasPort(host) {
+		host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+		if strings.Contains(host, ":") {
+			host = bracketIPv6(host)
+		}
+		s = host + ":" + strconv.Itoa(DefaultHTTPPort) + rest
+	}
+
+	host, rest := splitHostPath(s)
+	if isBareIPv6(host) {
+		host = bracketIPv6(host)
+	} else if i := strings.IndexByte(host, ']'); strings.HasPrefix(host, "[") && i > 0 {
+		host = bracketIPv6(host[1:i]) + host[i+1:]
+	}
+	u, err := url.Parse(scheme + "://" + host + rest)
+	if err != nil {
+		return nil, invalid("%v", unwrapURLError(err))
+	}
+	switch {
+	case u.Hostname() == "":
+		return nil, invalid("no host")
+	case u.User != nil:
+		return nil, invalid("credentials must be set with Username and Password, not in the address")
+	case u.RawQuery != "" || u.Fragment != "":
+		return nil, invalid("a query or fragment is not allowed")
+	}
+	if port := u.Port(); port != "" {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return nil, invalid("invalid port %s", port)
+		}
+	}
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.RawPath = strings.TrimRight(u.RawPath, "/")
+	return u, nil
+}
+
+// splitHostPath splits s, an address without a scheme, into its host and
+// port, and the path that follows.
+func splitHostPath(s string) (host, path string) {
+	if i := strings.IndexByte(s, '/'); i >= 0 {
+		return s[:i], s[i:]
+	}
+	return s, ""
+}
+
+// hasPort reports whether host, not a bare IPv6 address, ends with a port.
+func hasPort(host string) bool {
+	if isBareIPv6(host) {
+		return false
+	}
+	_, _, err := net.SplitHostPort(host)
+	return err == nil
+}
+
+// isBareIPv6 reports whether host is an IPv6 address, possibly with a zone,
+// that is not in brackets.
+func isBareIPv6(host string) bool {
+	if !strings.Contains(host, ":") || strings.HasPrefix(host, "[") {
+		return false
+	}
+	if i := strings.IndexByte(host, '%'); i >= 0 {
+		host = host[:i]
+	}
+	return net.ParseIP(host) != nil
+}
+
+// bracketIPv6 puts the IPv6 address host in brackets, escaping the percent
+// sign of its zone as URLs require.
+func bracketIPv6(host string) string {
+	if i := strings.IndexByte(host, '%'); i >= 0 && !strings.HasPrefix(host[i:], "%25") {
+		host = host[:i] + "%25" + host[i+1:]
+	}
+	return "[" + host + "]"
+}
+
+// unwrapURLError returns the cause of an error of url.Parse, which repeats
+// the URL.
+func unwrapURLError(err error) error {
+	if ue, ok := err.(*url.Error); ok {
+		return ue.Err
+	}
+	return err
+}
@@ -0,0 +1,272 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// InfiniteDuration is a retention policy duration that keeps data forever.
+const InfiniteDuration time.Duration = -1
+
+// RetentionPolicySpec describes a retention policy.
+type RetentionPolicySpec struct {
+	// Name of the retention policy.
+	Name string
+
+	// Duration is how long data is kept. Zero or InfiniteDuration keep
+	// data forever when creating a policy, zero leaves it unchanged when
+	// altering one.
+	Duration time.Duration
+
+	// ShardGroupDuration is the time range covered by a shard group. Zero
+	// lets the server choose when creating and leaves it unchanged when
+	// altering.
+	ShardGroupDuration time.Duration
+
+	// ReplicaN is the number of copies of the data in a cluster. Zero means
+	// 1 when creating and leaves it unchanged when altering.
+	ReplicaN int
+
+	// Default makes the retention policy the default of its database.
+	Default bool
+}
+
+// CreateDatabase creates the database name, with rp as its default
+// retention policy if rp is not nil. Creating a database that already exists
+// with the same retention policy succeeds.
+func CreateDatabase(ctx context.Context, c Client, name string, rp *RetentionPolicySpec) error {
+	if name == "" {
+		return errors.New("database name is required")
+	}
+	stmt := "CREATE DATABASE " + QuoteIdent(name)
+	if rp != nil {
+		clauses, err := rp.clauses(true)
+		if err != nil {
+			return err
+		}
+		stmt += " WITH" + clauses
+		if rp.Name != "" {
+			stmt += " NAME " + QuoteIdent(rp.Name)
+		}
+	}
+	return execAdmin(ctx, c, stmt, "")
+}
+
+// DropDatabase drops the database name and all of its data.
+func DropDatabase(ctx context.Context, c Client, name string) error {
+	if name == "" {
+		return errors.New("database name is required")
+	}
+	return execAdmin(ctx, c, "DROP DATABASE "+QuoteIdent(name), "")
+}
+
+// CreateRetentionPolicy creates the retention policy spec on the database db.
+// It fails with an error matching ErrRetentionPolicyExists if a different
+// policy of the same name exists.
+func CreateRetentionPolicy(ctx context.Context, c Client, db string, spec RetentionPolicySpec) error {
+	if db == "" || spec.Name == "" {
+		return errors.New("database and retention policy names are required")
+	}
+	clauses, err := spec.clauses(true)
+	if err != nil {
+		return err
+	}
+	stmt := "CREATE RETENTION POLICY " + QuoteIdent(spec.Name) + " ON " + QuoteIdent(db) + clauses
+	if spec.Default {
+		stmt += " DEFAULT"
+	}
+	return execAdmin(ctx, c, stmt, db)
+}
+
+// AlterRetentionPolicy changes the non-zero settings of spec on the existing
+// retention policy spec.Name of the database db.
+func AlterRetentionPolicy(ctx context.Context, c Client, db string, spec RetentionPolicySpec) error {
+	if db == "" || spec.Name == "" {
+		return errors.New("database and retention policy names are required")
+	}
+	clauses, err := spec.clauses(false)
+	if err != nil {
+		return err
+	}
+	if spec.Default {
+		clauses += " DEFAULT"
+	}
+	if clauses == "" {
+		return errors.New("nothing to alter in retention policy " + spec.Name)
+	}
+	stmt := "ALTER RETENTION POLICY " + QuoteIdent(spec.Name) + " ON " + QuoteIdent(db) + clauses
+	return execAdmin(ctx, c, stmt, db)
+}
+
+// DropRetentionPolicy drops the retention policy name of the database db and
+// all of its data.
+func DropRetentionPolicy(ctx context.Context, c Client, db, name string) error {
+	if db == "" || name == "" {
+		return errors.New("database and retention policy names are required")
+	}
+	return execAdmin(ctx, c, "DROP RETENTION POLICY "+QuoteIdent(name)+" ON "+QuoteIdent(db), db)
+}
+
+// IgnoreExists returns nil if err reports that a database, retention policy,
+// continuous query, subscription or user already exists, and err otherwise.
+func IgnoreExists(err error) error {
+	if errors.Is(err, ErrDatabaseExists) || errors.Is(err, ErrRetentionPolicyExists) ||
+		errors.Is(err, ErrContinuousQueryExists) || errors.Is(err, ErrSubscriptionExists) ||
+		errors.Is(err, ErrUserExists) {
+		return nil
+	}
+	return err
+}
+
+// clauses returns the DURATION, REPLICATION and SHARD DURATION clauses of
+// spec. When creating, DURATION and REPLICATION are always included.
+func (spec *RetentionPolicySpec) clauses(create bool) (string, error) {
+	var b strings.Builder
+	if spec.Duration != 0 || create {
+		d, err := formatDuration(spec.Duration)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(" DURATION " + d)
+	}
+	if spec.ReplicaN < 0 {
+		return "", fmt.Errorf("invalid replication factor %d", spec.ReplicaN)
+	}
+	if spec.ReplicaN > 0 || create {
+		n := spec.ReplicaN
+		if n == 0 {
+			n = 1
+		}
+		b.WriteString(" REPLICATION " + strconv.Itoa(n))
+	}
+	if spec.ShardGroupDuration != 0 {
+		if spec.ShardGroupDuration < 0 {
+			return "", fmt.Errorf("invalid shard group duration %v", spec.ShardGroupDuration)
+		}
+		d, err := formatDuration(spec.ShardGroupDuration)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(" SHARD DURATION " + d)
+	}
+	return b.String(), nil
+}
+
+// execAdmin runs an administrative statement and returns its error.
+func execAdmin(ctx context.Context, c Client, stmt, db string) error {
+	_, err := queryStatement(ctx, c, db, stmt)
+	return err
+}
+
+// queryStatement runs a single statement and returns its response, or its
+// error as a *StatementError unless the server answered with an error
+// status.
+func queryStatement(ctx context.Context, c Client, db, stmt string) (*Response, error) {
+	resp, err := queryContext(ctx, c, NewQuery(stmt, db, ""))
+	if err != nil {
+		return nil, err
+	}
+	if err := resp.Error(); err != nil {
+		var er *ErrorResponse
+		if errors.As(err, &er) {
+			return nil, err
+		}
+		var se *StatementError
+		if errors.As(err, &se) {
+			return nil, &StatementError{Statement: stmt, Message: se.Message}
+		}
+		return nil, &StatementError{Statement: stmt, Message: err.Error()}
+	}
+	return resp, nil
+}
+
+// queryContext runs q with c, bound to ctx if c is a ContextClient.
+func queryContext(ctx context.Context, c Client, q Query) (*Response, error) {
+	if cc, ok := c.(ContextClient); ok {
+		return cc.QueryContext(ctx, q)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return c.Query(q)
+}
+
+// durationUnits are the InfluxQL duration units, largest first.
+var durationUnits = []struct {
+	unit time.Duration
+	name string
+}{
+	{7 * 24 * time.Hour, "w"},
+	{24 * time.Hour, "d"},
+	{time.Hour, "h"},
+	{time.Minute, "m"},
+	{time.Second, "s"},
+	{time.Millisecond, "ms"},
+	{time.Microsecond, "u"},
+}
+
+// formatDuration formats d as an InfluxQL duration literal in the largest
+// unit that represents it exactly, or INF for zero and InfiniteDuration.
+func formatDuration(d time.Duration) (string, error) {
+	if d == 0 || d == InfiniteDuration {
+		return "INF", nil
+	}
+	if d < 0 {
+		return "", fmt.Errorf("invalid duration %v", d)
+	}
+	for _, u := range durationUnits {
+		if d%u.unit == 0 {
+			return strconv.FormatInt(int64(d/u.unit), 10) + u.name, nil
+		}
+	}
+	return "", fmt.Errorf("duration %v is not a whole number of microseconds", d)
+}
+
+// parseDuration parses an InfluxQL duration literal, such as 1h30m or 1w.
+func parseDuration(s string) (time.Duration, error) {
+	if strings.EqualFold(s, "INF") {
+		return InfiniteDuration, nil
+	}
+	if s == "" {
+		return 0, errors.New("empty duration")
+	}
+	var d time.Duration
+	rest := s
+	for rest != "" {
+		i := 0
+		for i < len(rest) && rest[i] >= '0' && rest[i] <= '9' {
+			i++
+		}
+		j := i
+		for j < len(rest) && (rest[j] < '0' || rest[j] > '9') {
+			j++
+		}
+		n, err := strconv.ParseInt(rest[:i], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		unit := time.Duration(0)
+		switch rest[i:j] {
+		case "ns":
+			unit = time.Nanosecond
+		case "u", "µ":
+			unit = time.Microsecond
+		default:
+			for _, u := range durationUnits {
+				if u.name == rest[i:j] {
+					unit = u.unit
+				}
+			}
+		}
+		if unit == 0 {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		d += time.Duration(n) * unit
+		rest = rest[j:]
+	}
+	return d, nil
+}
@@ -0,0 +1,151 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newStatementServer records the statements it receives and answers each of
+// them with the error returned by fail, if any.
+func newStatementServer(t *testing.T, fail func(q string) string) (*httptest.Server, *[]string) {
+	var statements []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.FormValue("q")
+		statements = append(statements, q)
+		var resp Response
+		result := Result{}
+		if fail != nil {
+			result.Err = fail(q)
+		}
+		resp.Results = append(resp.Results, result)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(resp)
+	}))
+	return ts, &statements
+}
+
+func TestAdmin_Statements(t *testing.T) {
+	ts, statements := newStatementServer(t, nil)
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	ctx := context.Background()
+	steps := []struct {
+		run func() error
+		exp string
+	}{
+		{
+			run: func() error { return CreateDatabase(ctx, c, "db0", nil) },
+			exp: `CREATE DATABASE "db0"`,
+		},
+		{
+			run: func() error {
+				return CreateDatabase(ctx, c, `my "db"`, &RetentionPolicySpec{Name: "one_week", Duration: 7 * 24 * time.Hour, ShardGroupDuration: 24 * time.Hour})
+			},
+			exp: `CREATE DATABASE "my \"db\"" WITH DURATION 1w REPLICATION 1 SHARD DURATION 1d NAME "one_week"`,
+		},
+		{
+			run: func() error {
+				return CreateRetentionPolicy(ctx, c, "db0", RetentionPolicySpec{Name: `back\slash`, Duration: InfiniteDuration, ReplicaN: 2, Default: true})
+			},
+			exp: `CREATE RETENTION POLICY "back\\slash" ON "db0" DURATION INF REPLICATION 2 DEFAULT`,
+		},
+		{
+			run: func() error {
+				return AlterRetentionPolicy(ctx, c, "db0", RetentionPolicySpec{Name: "rp", Duration: 90 * time.Minute})
+			},
+			exp: `ALTER RETENTION POLICY "rp" ON "db0" DURATION 90m`,
+		},
+		{
+			run: func() error {
+				return AlterRetentionPolicy(ctx, c, "db0", RetentionPolicySpec{Name: "rp", Default: true})
+			},
+			exp: `ALTER RETENTION POLICY "rp" ON "db0" DEFAULT`,
+		},
+		{
+			run: func() error { return DropRetentionPolicy(ctx, c, "db0", "rp") },
+			exp: `DROP RETENTION POLICY "rp" ON "db0"`,
+		},
+		{
+			run: func() error { return DropDatabase(ctx, c, "db0") },
+			exp: `DROP DATABASE "db0"`,
+		},
+	}
+	for i, step := range steps {
+		if err := step.run(); err != nil {
+			t.Fatalf("%d: unexpected error: %v", i, err)
+		}
+		if got := (*statements)[i]; got != step.exp {
+			t.Errorf("%d: unexpected statement.\nexpected %s\nactual   %s", i, step.exp, got)
+		}
+	}
+}
+
+func TestAdmin_RetentionPolicyExists(t *testing.T) {
+	ts, _ := newStatementServer(t, func(string) string { return "retention policy already exists" })
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	err := CreateRetentionPolicy(context.Background(), c, "db0", RetentionPolicySpec{Name: "rp", Duration: time.Hour})
+	if !errors.Is(err, ErrRetentionPolicyExists) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var se *StatementError
+	if !errors.As(err, &se) || se.Statement != `CREATE RETENTION POLICY "rp" ON "db0" DURATION 1h REPLICATION 1` {
+		t.Errorf("unexpected statement error: %+v", se)
+	}
+	if IgnoreExists(err) != nil {
+		t.Error("expected IgnoreExists to ignore the error")
+	}
+	other := errors.New("boom")
+	if IgnoreExists(other) != other {
+		t.Error("expected IgnoreExists to keep other errors")
+	}
+}
+
+func TestAdmin_Invalid(t *testing.T) {
+	c, _ := NewHTTPClient(HTTPConfig{Addr: "http://localhost:1"})
+	defer c.Close()
+
+	ctx := context.Background()
+	if err := CreateDatabase(ctx, c, "", nil); err == nil {
+		t.Error("expected an error for an empty database name")
+	}
+	if err := AlterRetentionPolicy(ctx, c, "db0", RetentionPolicySpec{Name: "rp"}); err == nil {
+		t.Error("expected an error for an empty alteration")
+	}
+	if err := CreateRetentionPolicy(ctx, c, "db0", RetentionPolicySpec{Name: "rp", Duration: time.Nanosecond}); err == nil {
+		t.Error("expected an error for a sub-microsecond duration")
+	}
+	if err := CreateRetentionPolicy(ctx, c, "db0", RetentionPolicySpec{Name: "rp", ReplicaN: -1}); err == nil {
+		t.Error("expected an error for a negative replication factor")
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	for _, tt := range []struct {
+		d   time.Duration
+		exp string
+	}{
+		{0, "INF"},
+		{InfiniteDuration, "INF"},
+		{14 * 24 * time.Hour, "2w"},
+		{36 * time.Hour, "36h"},
+		{1500 * time.Millisecond, "1500ms"},
+		{time.Microsecond, "1u"},
+	} {
+		if got, err := formatDuration(tt.d); err != nil || got != tt.exp {
+			t.Errorf("formatDuration(%v) = %q, %v; expected %q", tt.d, got, err, tt.exp)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// AggregateSpec describes a query of QueryAggregate: an aggregate of a field
+// per window of time.
+type AggregateSpec struct {
+	// Database, RetentionPolicy and Measurement are the data to aggregate.
+	// The retention policy is optional.
+	Database        string
+	RetentionPolicy string
+	Measurement     string
+
+	// Field is the key of the field aggregated.
+	Field string
+
+	// Aggregate is the InfluxQL function applied to the field of every
+	// window, such as mean, sum, max, min, count or percentile.
+	Aggregate string
+
+	// Percentile is the argument of the percentile function, from 0
+	// excluded to 100. It is only used, and required, by percentile.
+	Percentile float64
+
+	// Window is the duration of the windows, and Offset, if set, shifts
+	// them from their default alignment on the Unix epoch.
+	Window time.Duration
+	Offset time.Duration
+
+	// Fill is how windows without data are filled, and FillValue the value
+	// of FillValue. The windows of FillNull have a NaN value.
+	Fill      FillMode
+	FillValue float64
+
+	// TagFilters restricts the points aggregated to those having these tag
+	// values.
+	TagFilters map[string]string
+
+	// GroupBy are the keys of the tags to group by besides time, making a
+	// series per tag set.
+	GroupBy []string
+
+	// Start and End bound the time range aggregated, End excluded.
+	Start, End time.Time
+}
+
+// QueryAggregate queries the aggregate of spec and returns a series per tag
+// set of spec.GroupBy, keyed as by Response.TimeSeries, such as "cpu" or
+// "cpu,host=a". The time of each value is the start of its window, so the
+// first window, and the last, may start before Start and end after End when
+// those are not aligned on the windows, and only aggregate the points from
+// Start to End. A time range without data returns no series.
+func QueryAggregate(ctx context.Context, c Client, spec AggregateSpec) (map[string]TimeSeries, error) {
+	q, err := spec.query()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := queryContext(ctx, c, q)
+	if err != nil {
+		return nil, err
+	}
+	return TimeSeriesDecoder{Precision: q.Epoch, NullAsNaN: true}.TimeSeries(resp, spec.Aggregate)
+}
+
+// query checks spec and returns its query.
+func (spec *AggregateSpec) query() (Query, error) {
+	switch {
+	case spec.Database == "":
+		return Query{}, &ConfigError{Field: "Database", Reason: "no database given"}
+	case spec.Measurement == "":
+		return Query{}, &ConfigError{Field: "Measurement", Reason: "no measurement given"}
+	case spec.Field == "":
+		return Query{}, &ConfigError{Field: "Field", Reason: "no field given"}
+	case spec.Aggregate == "":
+		return Query{}, &ConfigError{Field: "Aggregate", Reason: "no aggregate given"}
+	case spec.Window <= 0:
+		return Query{}, &ConfigError{Field: "Window", Reason: "must be positive"}
+	case spec.Start.IsZero() || spec.End.IsZero():
+		return Query{}, &ConfigError{Field: "Start", Reason: "no time range given"}
+	case !spec.End.After(spec.Start):
+		return Query{}, &ConfigError{Field: "End", Reason: "not after Start"}
+	}
+
+	call := Call(spec.Aggregate, spec.Field)
+	if spec.Aggregate == "percentile" {
+		if spec.Percentile <= 0 || spec.Percentile > 100 {
+			return Query{}, &ConfigError{Field: "Percentile", Reason: fmt.Sprintf("%v is not in (0, 100]", spec.Percentile)}
+		}
+		call = Call(spec.Aggregate, spec.Field, spec.Percentile)
+	}
+
+	conds := []Cond{Time().Lt(spec.End)}
+	keys := make([]string, 0, len(spec.TagFilters))
+	for k := range spec.TagFilters {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		conds = append(conds, Tag(k).Eq(spec.TagFilters[k]))
+	}
+
+	interval := Time(spec.Window)
+	if spec.Offset != 0 {
+		interval = Time(spec.Window, spec.Offset)
+	}
+	dimensions := []interface{}{interval}
+	for _, k := range spec.GroupBy {
+		dimensions = append(dimensions, k)
+	}
+
+	q, err := Select(call.As(spec.Aggregate)).
+		From(spec.Database, spec.RetentionPolicy, spec.Measurement).
+		Where(Time().Gte(spec.Start).And(conds...)).
+		GroupBy(dimensions...).
+		Fill(spec.Fill, spec.FillValue).
+		Build()
+	if err != nil {
+		return Query{}, err
+	}
+	q.Epoch = "ns"
+	return q, nil
+}
@@ -0,0 +1,113 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+func TestQueryAggregate(t *testing.T) {
+	start, end := time.Unix(90, 0), time.Unix(240, 0)
+	const stmt = `SELECT mean("usage") AS "mean" FROM "telegraf".."cpu" WHERE (time >= '1970-01-01T00:01:30Z' AND time < '1970-01-01T00:04:00Z' AND "dc"::tag = 'eu' AND "role"::tag = 'web\'s') GROUP BY time(1m, 30s), "host" fill(null)`
+	ts, statements := newShowServer(t, map[string][]models.Row{
+		stmt: {
+			{Name: "cpu", Tags: map[string]string{"host": "a"}, Columns: []string{"time", "mean"}, Values: [][]interface{}{{90e9, 1.5}, {150e9, nil}, {210e9, 3}}},
+			{Name: "cpu", Tags: map[string]string{"host": "b"}, Columns: []string{"time", "mean"}, Values: [][]interface{}{{90e9, 2}, {150e9, 4}, {210e9, nil}}},
+		},
+	})
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	spec := AggregateSpec{
+		Database:    "telegraf",
+		Measurement: "cpu",
+		Field:       "usage",
+		Aggregate:   "mean",
+		Window:      time.Minute,
+		Offset:      30 * time.Second,
+		TagFilters:  map[string]string{"role": "web's", "dc": "eu"},
+		GroupBy:     []string{"host"},
+		Start:       start,
+		End:         end,
+	}
+	series, err := QueryAggregate(context.Background(), c, spec)
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if len(*statements) != 1 || (*statements)[0] != stmt {
+		t.Fatalf("unexpected statements.\nexpected %s\nactual   %q", stmt, *statements)
+	}
+	if len(series) != 2 {
+		t.Fatalf("unexpected number of series.  expected %v, actual %v", 2, len(series))
+	}
+	a := series["cpu,host=a"]
+	if len(a.Values) != 3 || a.Values[0] != 1.5 || !math.IsNaN(a.Values[1]) || !a.Times[2].Equal(time.Unix(210, 0)) {
+		t.Errorf("unexpected series a: %+v", a)
+	}
+	if b := series["cpu,host=b"]; len(b.Values) != 3 || b.Values[1] != 4 || b.Tags["host"] != "b" {
+		t.Errorf("unexpected series b: %+v", b)
+	}
+
+	// A time range without data has no series.
+	spec.GroupBy, spec.TagFilters = nil, nil
+	if series, err := QueryAggregate(context.Background(), c, spec); err != nil || len(series) != 0 {
+		t.Errorf("unexpected empty result: %v, %v", series, err)
+	}
+}
+
+func TestAggregateSpec_Query(t *testing.T) {
+	start, end := time.Unix(0, 0), time.Unix(3600, 0)
+	for _, tt := range []struct {
+		spec AggregateSpec
+		exp  string
+	}{
+		{
+			AggregateSpec{Database: "db", RetentionPolicy: "rp", Measurement: "http", Field: "latency", Aggregate: "percentile", Percentile: 99.9, Window: time.Hour, Fill: FillNone, Start: start, End: end},
+			`SELECT percentile("latency", 99.9) AS "percentile" FROM "db"."rp"."http" WHERE (time >= '1970-01-01T00:00:00Z' AND time < '1970-01-01T01:00:00Z') GROUP BY time(1h) fill(none)`,
+		},
+		{
+			AggregateSpec{Database: "db", Measurement: "http", Field: "requests", Aggregate: "count", Window: 5 * time.Minute, Fill: FillValue, Start: start, End: end},
+			`SELECT count("requests") AS "count" FROM "db".."http" WHERE (time >= '1970-01-01T00:00:00Z' AND time < '1970-01-01T01:00:00Z') GROUP BY time(5m) fill(0.0)`,
+		},
+	} {
+		q, err := tt.spec.query()
+		if err != nil {
+			t.Errorf("unexpected error.  expected %v, actual %v", nil, err)
+			continue
+		}
+		if q.Command != tt.exp || q.Database != "db" || q.Epoch != "ns" {
+			t.Errorf("unexpected query.\nexpected %s\nactual   %s (%s, %s)", tt.exp, q.Command, q.Database, q.Epoch)
+		}
+	}
+
+	valid := AggregateSpec{Database: "db", Measurement: "m", Field: "v", Aggregate: "max", Window: time.Minute, Start: start, End: end}
+	for _, tt := range []struct {
+		field string
+		spec  func(s *AggregateSpec)
+	}{
+		{"Database", func(s *AggregateSpec) { s.Database = "" }},
+		{"Field", func(s *AggregateSpec) { s.Field = "" }},
+		{"Window", func(s *AggregateSpec) { s.Window = 0 }},
+		{"Start", func(s *AggregateSpec) { s.Start = time.Time{} }},
+		{"End", func(s *AggregateSpec) { s.End = s.Start }},
+		{"Percentile", func(s *AggregateSpec) { s.Aggregate = "percentile" }},
+		{"Percentile", func(s *AggregateSpec) { s.Aggregate, s.Percentile = "percentile", 101 }},
+	} {
+		spec := valid
+		tt.spec(&spec)
+		var ce *ConfigError
+		if _, err := spec.query(); !errors.As(err, &ce) || ce.Field != tt.field {
+			t.Errorf("unexpected error.  expected a ConfigError for %s, actual %v", tt.field, err)
+		}
+	}
+	valid.Aggregate = "mean("
+	if _, err := valid.query(); err == nil {
+		t.Error("expected an error for an invalid aggregate")
+	}
+}
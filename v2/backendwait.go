@@ -0,0 +1,103 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultBackendProbeInterval is the default pause after the first failed
+// Ping of a BackendWait.
+const DefaultBackendProbeInterval = 500 * time.Millisecond
+
+// ErrBackendNotReady is passed to BatchingOptions.OnError, wrapping the error
+// of the Ping, when a BatchingClient with a BackendWait starts holding its
+// writes because the server does not answer.
+var ErrBackendNotReady = errors.New("backend not ready, holding writes")
+
+// BackendWait makes a BatchingClient wait for the server to answer a Ping
+// before it writes, so that the points added while the server is not
+// reachable yet, as on startup, are held rather than fail one write after
+// the other. The wait starts again after a write failed because the circuit
+// breaker of the wrapped client opened, and then also lasts until the
+// breaker lets a request through.
+//
+// While the BatchingClient waits no point is written: the points added are
+// held up to its BufferSize, and those that do not fit are handled by its
+// Overflow policy. Flush waits too, while Close ends the wait and writes the
+// points held. A wait whose first Ping fails is reported to OnError once,
+// with ErrBackendNotReady and no points.
+type BackendWait struct {
+	// MaxWait is the longest time a wait lasts, after which the points
+	// are written, and their writes fail, as without a BackendWait. It
+	// must be set.
+	MaxWait time.Duration
+
+	// ProbeInterval is the pause after the first failed Ping, doubled
+	// after every failed one. Defaults to DefaultBackendProbeInterval.
+	ProbeInterval time.Duration
+}
+
+func (bw *BackendWait) validate() error {
+	if bw.MaxWait <= 0 {
+		return &ConfigError{Field: "WaitForBackend.MaxWait", Reason: "must be positive"}
+	}
+	if bw.ProbeInterval < 0 {
+		return &ConfigError{Field: "WaitForBackend.ProbeInterval", Reason: "must not be negative"}
+	}
+	return nil
+}
+
+// awaitBackend waits until the wrapped client is ready, see backendReady,
+// for up to the MaxWait of bc.wait, unless bc is closed first.
+func (bc *BatchingClient) awaitBackend() {
+	deadline := bc.clock.Now().Add(bc.wait.MaxWait)
+	interval := bc.wait.ProbeInterval
+	if interval == 0 {
+		interval = DefaultBackendProbeInterval
+	}
+	for reported := false; ; reported = true {
+		err := bc.backendReady()
+		if err == nil {
+			return
+		}
+		if !reported {
+			bc.report(fmt.Errorf("%w: %v", ErrBackendNotReady, err), nil)
+		}
+		remaining := deadline.Sub(bc.clock.Now())
+		if remaining <= 0 {
+			return
+		}
+		if interval > remaining {
+			interval = remaining
+		}
+		select {
+		case <-bc.clock.After(interval):
+		case <-bc.closing:
+			return
+		}
+		interval *= 2
+	}
+}
+
+// backendReady returns the error of a Ping of the wrapped client, or
+// ErrCircuitOpen if its circuit breaker is open.
+func (bc *BatchingClient) backendReady() error {
+	if _, _, err := bc.c.Ping(0); err != nil {
+		return err
+	}
+	if cs, ok := bc.c.(CircuitStater); ok && cs.CircuitState() == CircuitOpen {
+		return ErrCircuitOpen
+	}
+	return nil
+}
+
+// circuitOpened reports whether the write that failed with err opened the
+// circuit breaker of the wrapped client, or was rejected by it.
+func (bc *BatchingClient) circuitOpened(err error) bool {
+	if errors.Is(err, ErrCircuitOpen) {
+		return true
+	}
+	cs, ok := bc.c.(CircuitStater)
+	return ok && cs.CircuitState() == CircuitOpen
+}
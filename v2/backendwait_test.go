@@ -0,0 +1,204 @@
+package client
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// pingClient is a batchRecorder whose pings fail with pingErr, with a
+// circuit breaker in state.
+type pingClient struct {
+	batchRecorder
+	pmu     sync.Mutex
+	pingErr error
+	pings   int
+	state   CircuitState
+}
+
+func (c *pingClient) Ping(timeout time.Duration) (time.Duration, string, error) {
+	c.pmu.Lock()
+	defer c.pmu.Unlock()
+	c.pings++
+	return 0, "", c.pingErr
+}
+
+func (c *pingClient) CircuitState() CircuitState {
+	c.pmu.Lock()
+	defer c.pmu.Unlock()
+	return c.state
+}
+
+func (c *pingClient) set(pingErr error, state CircuitState) {
+	c.pmu.Lock()
+	defer c.pmu.Unlock()
+	c.pingErr, c.state = pingErr, state
+}
+
+func (c *pingClient) written() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var n int
+	for _, batch := range c.batches {
+		n += len(batch)
+	}
+	return n
+}
+
+// errorRecorder records the errors reported to OnError.
+type errorRecorder struct {
+	mu   sync.Mutex
+	errs []error
+}
+
+func (r *errorRecorder) onError(err error, points []*Point) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errs = append(r.errs, err)
+}
+
+func (r *errorRecorder) count(target error) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var n int
+	for _, err := range r.errs {
+		if errors.Is(err, target) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestBatchingClient_WaitForBackend(t *testing.T) {
+	errDown := errors.New("connection refused")
+	c := &pingClient{pingErr: errDown}
+	errs := &errorRecorder{}
+	bc, err := NewBatchingClient(c, BatchingOptions{
+		FlushInterval:  5 * time.Millisecond,
+		OnError:        errs.onError,
+		WaitForBackend: &BackendWait{MaxWait: time.Minute, ProbeInterval: time.Millisecond},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	defer bc.Close()
+
+	bc.AddPoints(newTestPoints(3))
+	time.Sleep(50 * time.Millisecond)
+	if n := c.written(); n != 0 {
+		t.Fatalf("unexpected points written before the backend is ready.  expected %v, actual %v", 0, n)
+	}
+	bc.AddPoints(newTestPoints(2))
+
+	c.set(nil, CircuitClosed)
+	deadline := time.Now().Add(5 * time.Second)
+	for c.written() < 5 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if n := c.written(); n != 5 {
+		t.Errorf("unexpected points written.  expected %v, actual %v", 5, n)
+	}
+	if n := errs.count(ErrBackendNotReady); n != 1 || len(errs.errs) != 1 || !errors.Is(errs.errs[0], ErrBackendNotReady) {
+		t.Errorf("unexpected errors.  expected a single %v, actual %v", ErrBackendNotReady, errs.errs)
+	}
+}
+
+func TestBatchingClient_WaitForBackendMaxWait(t *testing.T) {
+	c := &pingClient{pingErr: errors.New("connection refused")}
+	c.err = errors.New("write failed")
+	errs := &errorRecorder{}
+	bc, _ := NewBatchingClient(c, BatchingOptions{
+		OnError:        errs.onError,
+		WaitForBackend: &BackendWait{MaxWait: 30 * time.Millisecond, ProbeInterval: time.Millisecond},
+	})
+	defer bc.Close()
+
+	bc.AddPoints(newTestPoints(2))
+	start := time.Now()
+	bc.Flush()
+	if d := time.Since(start); d < 30*time.Millisecond {
+		t.Errorf("unexpected wait.  expected at least %v, actual %v", 30*time.Millisecond, d)
+	}
+	// The pings back off.
+	if c.pings > 10 {
+		t.Errorf("unexpected pings.  expected fewer than %v, actual %v", 10, c.pings)
+	}
+	// After the wait writes fail as usual, without waiting again.
+	bc.AddPoints(newTestPoints(2))
+	bc.Flush()
+	if n := c.written(); n != 4 {
+		t.Errorf("unexpected points written.  expected %v, actual %v", 4, n)
+	}
+	if a, b := errs.count(ErrBackendNotReady), errs.count(c.err); a != 1 || b != 2 {
+		t.Errorf("unexpected errors.  expected a wait and %v failed writes, actual %v", 2, errs.errs)
+	}
+}
+
+func TestBatchingClient_WaitForBackendCircuitOpen(t *testing.T) {
+	c := &pingClient{}
+	c.err = ErrCircuitOpen
+	errs := &errorRecorder{}
+	bc, _ := NewBatchingClient(c, BatchingOptions{
+		OnError:        errs.onError,
+		WaitForBackend: &BackendWait{MaxWait: time.Minute, ProbeInterval: time.Millisecond},
+	})
+	defer bc.Close()
+
+	// The server answers, and the first write opens the breaker.
+	bc.AddPoints(newTestPoints(1))
+	bc.Flush()
+	c.mu.Lock()
+	c.err = nil
+	c.mu.Unlock()
+	c.set(nil, CircuitOpen)
+
+	bc.AddPoints(newTestPoints(1))
+	flushed := make(chan struct{})
+	go func() {
+		bc.Flush()
+		close(flushed)
+	}()
+	select {
+	case <-flushed:
+		t.Fatalf("unexpected write while the circuit breaker is open")
+	case <-time.After(30 * time.Millisecond):
+	}
+	c.set(nil, CircuitHalfOpen)
+	<-flushed
+	if n := c.written(); n != 2 {
+		t.Errorf("unexpected points written.  expected %v, actual %v", 2, n)
+	}
+	if a, b := errs.count(ErrBackendNotReady), errs.count(ErrCircuitOpen); a != 1 || b != 1 {
+		t.Errorf("unexpected errors.  expected a wait after the failed write, actual %v", errs.errs)
+	}
+}
+
+func TestBatchingClient_WaitForBackendClose(t *testing.T) {
+	c := &pingClient{pingErr: errors.New("connection refused")}
+	bc, _ := NewBatchingClient(c, BatchingOptions{
+		WaitForBackend: &BackendWait{MaxWait: time.Minute},
+	})
+	bc.AddPoints(newTestPoints(2))
+	go bc.Flush()
+	time.Sleep(10 * time.Millisecond)
+
+	closed := make(chan struct{})
+	go func() {
+		bc.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("unexpected Close waiting for the backend")
+	}
+	if n := c.written(); n != 2 {
+		t.Errorf("unexpected points written.  expected %v, actual %v", 2, n)
+	}
+
+	var ce *ConfigError
+	if _, err := NewBatchingClient(c, BatchingOptions{WaitForBackend: &BackendWait{}}); !errors.As(err, &ce) {
+		t.Errorf("unexpected error.  expected a *ConfigError, actual %v", err)
+	}
+}
@@ -0,0 +1,377 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultBackfillWindowIntervals is the default number of GROUP BY
+	// intervals of a window of BackfillInto.
+	DefaultBackfillWindowIntervals = 1000
+
+	// DefaultBackfillRetries is the default number of retries of a window
+	// of BackfillInto that failed with a transient error.
+	DefaultBackfillRetries = 3
+)
+
+// BackfillSpec describes a backfill of downsampled data by BackfillInto.
+type BackfillSpec struct {
+	// Database, RetentionPolicy and Measurement are the data to downsample.
+	// The retention policy is optional.
+	Database        string
+	RetentionPolicy string
+	Measurement     string
+
+	// TargetDatabase, TargetRetentionPolicy and TargetMeasurement are where
+	// the downsampled data is written. The database defaults to Database and
+	// the measurement to Measurement. The retention policy is optional.
+	TargetDatabase        string
+	TargetRetentionPolicy string
+	TargetMeasurement     string
+
+	// Aggregate is the InfluxQL selected into the target, such as
+	// `mean("value") AS "value"`. It is not quoted.
+	Aggregate string
+
+	// Where, if set, restricts the points downsampled, in addition to the
+	// time range.
+	Where *Cond
+
+	// Interval is the interval of GROUP BY time. Start and End are rounded
+	// down and up to it, as the server aligns the intervals to the Unix
+	// epoch.
+	Interval time.Duration
+
+	// GroupBy are the keys of the tags to group by, besides time. It
+	// defaults to "*", which keeps every tag.
+	GroupBy []string
+
+	// Start and End bound the time range to backfill, End excluded.
+	Start, End time.Time
+
+	// Window is the time range of each SELECT INTO, a multiple of Interval
+	// so that no interval is split across windows. It defaults to
+	// DefaultBackfillWindowIntervals intervals.
+	Window time.Duration
+
+	// Concurrency is the number of windows run at once, defaults to 1.
+	Concurrency int
+
+	// Retries is the number of times a window that failed with a transient
+	// error, such as a 5xx status or a timeout, is run again, defaults to
+	// DefaultBackfillRetries. A negative value disables retries.
+	Retries int
+
+	// RetryInterval is the delay before the first retry of a window,
+	// doubled for every retry. It defaults to DefaultRetryInterval.
+	RetryInterval time.Duration
+
+	// Timeout, if set, bounds the query of every window, see Query.Timeout.
+	Timeout time.Duration
+
+	// OnWindow, if set, is called for every window once it is done, with
+	// the number of windows done so far and the total. The calls are not
+	// concurrent, but come in the order the windows finish.
+	OnWindow func(w BackfillWindow, done, total int)
+}
+
+// BackfillWindow is the outcome of a window of BackfillInto.
+type BackfillWindow struct {
+	// Start and End bound the window, End excluded.
+	Start, End time.Time
+
+	// Rows is the number of points the server reported written.
+	Rows int64
+
+	// Attempts is the number of queries made for the window.
+	Attempts int
+
+	// Duration is the time spent on the window, retries included.
+	Duration time.Duration
+
+	// Err is the error of the last attempt, if it failed.
+	Err error
+}
+
+// BackfillReport is the outcome of BackfillInto.
+type BackfillReport struct {
+	// Windows are the windows in time order. Windows not run because ctx
+	// was done have Attempts of 0.
+	Windows []BackfillWindow
+
+	// Rows is the number of points written by all windows.
+	Rows int64
+
+	// Failed is the number of windows that failed.
+	Failed int
+}
+
+// BackfillError is returned by BackfillInto when some windows failed.
+type BackfillError struct {
+	// Failed is the number of windows that failed, and Windows the total.
+	Failed, Windows int
+
+	// Err is the error of the first window that failed.
+	Err error
+}
+
+func (e *BackfillError) Error() string {
+	return fmt.Sprintf("%d of %d backfill windows failed, first: %v", e.Failed, e.Windows, e.Err)
+}
+
+func (e *BackfillError) Unwrap() error { return e.Err }
+
+// BackfillInto downsamples data with one SELECT ... INTO ... GROUP BY time
+// query per window of spec, so that a backfill of months of data neither
+// times out nor exhausts the memory of the server. The windows are aligned
+// to the GROUP BY interval. A window that fails is retried if the error is
+// transient, and the other windows go on regardless; the error is then a
+// *BackfillError, and the report has the outcome of every window.
+func BackfillInto(ctx context.Context, c Client, spec BackfillSpec) (BackfillReport, error) {
+	windows, err := spec.windows()
+	if err != nil {
+		return BackfillReport{}, err
+	}
+	concurrency := spec.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	report := BackfillReport{Windows: windows}
+	indices := make(chan int)
+	var mu sync.Mutex
+	done := 0
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency && i < len(windows); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				w := spec.run(ctx, c, windows[i].Start, windows[i].End)
+
+				mu.Lock()
+				report.Windows[i] = w
+				done++
+				if spec.OnWindow != nil {
+					spec.OnWindow(w, done, len(windows))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := range windows {
+		if ctx.Err() != nil {
+			break
+		}
+		select {
+		case indices <- i:
+		case <-ctx.Done():
+		}
+	}
+	close(indices)
+	wg.Wait()
+
+	var first error
+	for _, w := range report.Windows {
+		report.Rows += w.Rows
+		if w.Err != nil {
+			report.Failed++
+			if first == nil {
+				first = w.Err
+			}
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return report, err
+	}
+	if report.Failed > 0 {
+		return report, &BackfillError{Failed: report.Failed, Windows: len(windows), Err: first}
+	}
+	return report, nil
+}
+
+// windows checks spec and returns its windows, not run yet.
+func (spec *BackfillSpec) windows() ([]BackfillWindow, error) {
+	switch {
+	case spec.Database == "":
+		return nil, &ConfigError{Field: "Database", Reason: "no database given"}
+	case spec.Measurement == "":
+		return nil, &ConfigError{Field: "Measurement", Reason: "no measurement given"}
+	case spec.Aggregate == "":
+		return nil, &ConfigError{Field: "Aggregate", Reason: "no aggregate given"}
+	case spec.Interval <= 0:
+		return nil, &ConfigError{Field: "Interval", Reason: "must be positive"}
+	case spec.Window < 0 || spec.Window%spec.Interval != 0:
+		return nil, &ConfigError{Field: "Window", Reason: fmt.Sprintf("%v is not a multiple of the interval %v", spec.Window, spec.Interval)}
+	case !spec.End.After(spec.Start):
+		return nil, &ConfigError{Field: "End", Reason: "not after Start"}
+	}
+	if err := Time(spec.Interval).err; err != nil {
+		return nil, &ConfigError{Field: "Interval", Reason: err.Error()}
+	}
+	if spec.Where != nil && spec.Where.err != nil {
+		return nil, spec.Where.err
+	}
+
+	window := spec.Window
+	if window == 0 {
+		window = DefaultBackfillWindowIntervals * spec.Interval
+	}
+	start := alignTime(spec.Start, spec.Interval, false)
+	end := alignTime(spec.End, spec.Interval, true)
+
+	var windows []BackfillWindow
+	for t := start; t.Before(end); t = t.Add(window) {
+		w := BackfillWindow{Start: t, End: t.Add(window)}
+		if w.End.After(end) {
+			w.End = end
+		}
+		windows = append(windows, w)
+	}
+	return windows, nil
+}
+
+// alignTime rounds t down, or up, to a multiple of interval since the Unix
+// epoch.
+func alignTime(t time.Time, interval time.Duration, up bool) time.Time {
+	ns, d := t.UnixNano(), int64(interval)
+	aligned := ns - ns%d
+	if ns%d < 0 {
+		aligned -= d
+	}
+	if up && aligned != ns {
+		aligned += d
+	}
+	return time.Unix(0, aligned).UTC()
+}
+
+// statement returns the SELECT INTO statement of the window from start to
+// end.
+func (spec *BackfillSpec) statement(start, end time.Time) string {
+	targetDB, target := spec.TargetDatabase, spec.TargetMeasurement
+	if targetDB == "" {
+		targetDB = spec.Database
+	}
+	if target == "" {
+		target = spec.Measurement
+	}
+	where := Time().Gte(start).And(Time().Lt(end))
+	if spec.Where != nil {
+		where = where.And(*spec.Where)
+	}
+	groupBy := []string{Time(spec.Interval).s}
+	if len(spec.GroupBy) == 0 {
+		groupBy = append(groupBy, "*")
+	}
+	for _, key := range spec.GroupBy {
+		groupBy = append(groupBy, QuoteIdent(key))
+	}
+	return fmt.Sprintf("SELECT %s INTO %s FROM %s WHERE %s GROUP BY %s",
+		spec.Aggregate,
+		qualifySource(targetDB, spec.TargetRetentionPolicy, QuoteIdent(target)),
+		qualifySource(spec.Database, spec.RetentionPolicy, QuoteIdent(spec.Measurement)),
+		where.s,
+		strings.Join(groupBy, ", "))
+}
+
+// run runs the window from start to end, retrying it after transient
+// failures.
+func (spec *BackfillSpec) run(ctx context.Context, c Client, start, end time.Time) BackfillWindow {
+	w := BackfillWindow{Start: start, End: end}
+	began := time.Now()
+
+	retries := spec.Retries
+	if retries == 0 {
+		retries = DefaultBackfillRetries
+	}
+	delay := spec.RetryInterval
+	if delay <= 0 {
+		delay = DefaultRetryInterval
+	}
+
+	q := NewQuery(spec.statement(start, end), spec.Database, "")
+	q.Timeout = spec.Timeout
+	for {
+		w.Attempts++
+		w.Rows, w.Err = selectInto(ctx, c, q)
+		if w.Err == nil || w.Attempts > retries || !isTransient(w.Err) || ctx.Err() != nil {
+			w.Duration = time.Since(began)
+			return w
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			w.Err = ctx.Err()
+			w.Duration = time.Since(began)
+			return w
+		}
+		if delay *= 2; delay > DefaultMaxRetryInterval {
+			delay = DefaultMaxRetryInterval
+		}
+	}
+}
+
+// selectInto runs the SELECT INTO query q and returns the number of points it
+// wrote.
+func selectInto(ctx context.Context, c Client, q Query) (int64, error) {
+	resp, err := queryContext(ctx, c, q)
+	if err != nil {
+		return 0, err
+	}
+	if err := resp.Error(); err != nil {
+		if _, ok := err.(*ErrorResponse); ok {
+			return 0, err
+		}
+		var se *StatementError
+		if errors.As(err, &se) {
+			return 0, &StatementError{Statement: q.Command, Message: se.Message}
+		}
+		return 0, &StatementError{Statement: q.Command, Message: err.Error()}
+	}
+	for _, result := range resp.Results {
+		for _, row := range result.Series {
+			for i, col := range row.Columns {
+				if col != "written" || len(row.Values) == 0 || len(row.Values[0]) <= i {
+					continue
+				}
+				switch v := row.Values[0][i].(type) {
+				case json.Number:
+					return v.Int64()
+				case float64:
+					return int64(v), nil
+				case int64:
+					return v, nil
+				}
+			}
+		}
+	}
+	return 0, nil
+}
+
+// isTransient reports whether a window that failed with err may succeed when
+// run again.
+func isTransient(err error) bool {
+	var er *ErrorResponse
+	if errors.As(err, &er) {
+		return er.StatusCode >= 500
+	}
+	var qte *QueryTimeoutError
+	if errors.As(err, &qte) {
+		return true
+	}
+	var se *StatementError
+	if errors.As(err, &se) {
+		msg := strings.ToLower(se.Message)
+		return strings.Contains(msg, "timeout") || strings.Contains(msg, "interrupted")
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
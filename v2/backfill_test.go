@@ -0,0 +1,217 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// backfillServer answers SELECT INTO queries with the number of points
+// written returned by answer, for the statements it records.
+type backfillServer struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	statements []string
+}
+
+func newBackfillServer(t *testing.T, answer func(n int, stmt string) (status int, body string)) *backfillServer {
+	s := &backfillServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		stmt := r.FormValue("q")
+		s.statements = append(s.statements, stmt)
+		n := len(s.statements)
+		s.mu.Unlock()
+
+		status, body := answer(n, stmt)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Influxdb-Version", "1.8.10")
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func writtenBody(n int) string {
+	return fmt.Sprintf(`{"results":[{"statement_id":0,"series":[{"name":"result","columns":["time","written"],"values":[[0,%d]]}]}]}`, n)
+}
+
+func TestBackfillInto(t *testing.T) {
+	ts := newBackfillServer(t, func(int, string) (int, string) { return http.StatusOK, writtenBody(10) })
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	where := Tag("host").Eq("a")
+	var progress []int
+	report, err := BackfillInto(context.Background(), c, BackfillSpec{
+		Database:              "telegraf",
+		RetentionPolicy:       "raw",
+		Measurement:           "cpu",
+		TargetRetentionPolicy: "1y",
+		TargetMeasurement:     "cpu_1h",
+		Aggregate:             `mean("usage") AS "usage"`,
+		Where:                 &where,
+		Interval:              time.Hour,
+		Start:                 time.Date(2023, 1, 1, 0, 30, 0, 0, time.UTC),
+		End:                   time.Date(2023, 1, 2, 1, 15, 0, 0, time.UTC),
+		Window:                12 * time.Hour,
+		OnWindow: func(w BackfillWindow, done, total int) {
+			if total != 3 {
+				t.Errorf("unexpected total.  expected %v, actual %v", 3, total)
+			}
+			progress = append(progress, done)
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+
+	exp := []string{
+		`SELECT mean("usage") AS "usage" INTO "telegraf"."1y"."cpu_1h" FROM "telegraf"."raw"."cpu" WHERE ((time >= '2023-01-01T00:00:00Z' AND time < '2023-01-01T12:00:00Z') AND "host"::tag = 'a') GROUP BY time(1h), *`,
+		`SELECT mean("usage") AS "usage" INTO "telegraf"."1y"."cpu_1h" FROM "telegraf"."raw"."cpu" WHERE ((time >= '2023-01-01T12:00:00Z' AND time < '2023-01-02T00:00:00Z') AND "host"::tag = 'a') GROUP BY time(1h), *`,
+		`SELECT mean("usage") AS "usage" INTO "telegraf"."1y"."cpu_1h" FROM "telegraf"."raw"."cpu" WHERE ((time >= '2023-01-02T00:00:00Z' AND time < '2023-01-02T02:00:00Z') AND "host"::tag = 'a') GROUP BY time(1h), *`,
+	}
+	if strings.Join(ts.statements, "\n") != strings.Join(exp, "\n") {
+		t.Errorf("unexpected statements.\nexpected %s\nactual   %s", strings.Join(exp, "\n"), strings.Join(ts.statements, "\n"))
+	}
+	if report.Rows != 30 || report.Failed != 0 || len(report.Windows) != 3 || report.Windows[2].Rows != 10 || report.Windows[2].Attempts != 1 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	if fmt.Sprint(progress) != "[1 2 3]" {
+		t.Errorf("unexpected progress.  expected %v, actual %v", "[1 2 3]", progress)
+	}
+}
+
+func TestBackfillInto_Retries(t *testing.T) {
+	var mu sync.Mutex
+	attempts := make(map[string]int)
+	ts := newBackfillServer(t, func(_ int, stmt string) (int, string) {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts[stmt]++
+		switch {
+		case strings.Contains(stmt, "'2023-01-01T01:00:00Z' AND") && attempts[stmt] == 1:
+			return http.StatusServiceUnavailable, `{"error":"overloaded"}`
+		case strings.Contains(stmt, "'2023-01-01T02:00:00Z' AND"):
+			return http.StatusOK, `{"results":[{"statement_id":0,"error":"field type conflict"}]}`
+		}
+		return http.StatusOK, writtenBody(1)
+	})
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	report, err := BackfillInto(context.Background(), c, BackfillSpec{
+		Database:      "db",
+		Measurement:   "cpu",
+		Aggregate:     "max(*)",
+		GroupBy:       []string{"host"},
+		Interval:      time.Minute,
+		Window:        time.Hour,
+		Start:         time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+		End:           time.Date(2023, 1, 1, 4, 0, 0, 0, time.UTC),
+		Concurrency:   3,
+		RetryInterval: time.Millisecond,
+	})
+	var be *BackfillError
+	if !errors.As(err, &be) || be.Failed != 1 || be.Windows != 4 || !errors.Is(err, ErrFieldTypeConflict) {
+		t.Fatalf("unexpected error.  expected %T, actual %v", be, err)
+	}
+	if report.Rows != 3 || report.Failed != 1 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	for i, exp := range []int{1, 2, 1, 1} {
+		if w := report.Windows[i]; w.Attempts != exp {
+			t.Errorf("unexpected attempts of window %d.  expected %v, actual %v", i, exp, w.Attempts)
+		}
+	}
+	if w := report.Windows[2]; w.Err == nil || w.Rows != 0 {
+		t.Errorf("unexpected window: %+v", w)
+	}
+	if !strings.Contains(ts.statements[0], ` INTO "db".."cpu" FROM "db".."cpu" `) || !strings.HasSuffix(ts.statements[0], ` GROUP BY time(1m), "host"`) {
+		t.Errorf("unexpected statement: %s", ts.statements[0])
+	}
+}
+
+func TestBackfillInto_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ts := newBackfillServer(t, func(n int, _ string) (int, string) {
+		if n == 2 {
+			cancel()
+		}
+		return http.StatusOK, writtenBody(1)
+	})
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	report, err := BackfillInto(ctx, c, BackfillSpec{
+		Database:    "db",
+		Measurement: "cpu",
+		Aggregate:   "max(*)",
+		Interval:    time.Minute,
+		Window:      time.Minute,
+		Start:       time.Unix(0, 0),
+		End:         time.Unix(600, 0),
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("unexpected error.  expected %v, actual %v", context.Canceled, err)
+	}
+	if len(report.Windows) != 10 || report.Windows[9].Attempts != 0 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+}
+
+func TestBackfillInto_InvalidSpec(t *testing.T) {
+	valid := BackfillSpec{
+		Database:    "db",
+		Measurement: "cpu",
+		Aggregate:   "max(*)",
+		Interval:    time.Hour,
+		Start:       time.Unix(0, 0),
+		End:         time.Unix(3600, 0),
+	}
+	for _, tt := range []struct {
+		field  string
+		modify func(*BackfillSpec)
+	}{
+		{field: "Database", modify: func(s *BackfillSpec) { s.Database = "" }},
+		{field: "Aggregate", modify: func(s *BackfillSpec) { s.Aggregate = "" }},
+		{field: "Interval", modify: func(s *BackfillSpec) { s.Interval = 0 }},
+		{field: "Interval", modify: func(s *BackfillSpec) { s.Interval = time.Nanosecond }},
+		{field: "Window", modify: func(s *BackfillSpec) { s.Window = 90 * time.Minute }},
+		{field: "End", modify: func(s *BackfillSpec) { s.End = s.Start }},
+	} {
+		spec := valid
+		tt.modify(&spec)
+		_, err := BackfillInto(context.Background(), nil, spec)
+		var ce *ConfigError
+		if !errors.As(err, &ce) || ce.Field != tt.field {
+			t.Errorf("unexpected error.  expected %v, actual %v", tt.field, err)
+		}
+	}
+}
+
+func TestAlignTime(t *testing.T) {
+	for _, tt := range []struct {
+		t        time.Time
+		up       bool
+		expected time.Time
+	}{
+		{t: time.Unix(90, 0), expected: time.Unix(60, 0)},
+		{t: time.Unix(90, 0), up: true, expected: time.Unix(120, 0)},
+		{t: time.Unix(120, 0), up: true, expected: time.Unix(120, 0)},
+		{t: time.Unix(-90, 0), expected: time.Unix(-120, 0)},
+		{t: time.Unix(-90, 0), up: true, expected: time.Unix(-60, 0)},
+	} {
+		if got := alignTime(tt.t, time.Minute, tt.up); !got.Equal(tt.expected) {
+			t.Errorf("unexpected time for %v.  expected %v, actual %v", tt.t.Unix(), tt.expected.Unix(), got.Unix())
+		}
+	}
+}
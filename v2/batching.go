@@ -0,0 +1,509 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultBatchSize is the default number of points per batch written by a
+	// BatchingClient.
+	DefaultBatchSize = 5000
+
+	// DefaultFlushInterval is the default interval at which a BatchingClient
+	// writes the points it holds.
+	DefaultFlushInterval = time.Second
+)
+
+// ErrBufferFull is passed to BatchingOptions.OnError with the points dropped
+// because the buffer of a BatchingClient was full.
+var ErrBufferFull = errors.New("batching buffer is full, points dropped")
+
+// ErrBatchingClientClosed is passed to BatchingOptions.OnError with the points
+// added after the BatchingClient was closed. It matches ErrClientClosed.
+var ErrBatchingClientClosed = fmt.Errorf("batching %w", ErrClientClosed)
+
+// OverflowPolicy decides what a BatchingClient does with new points while its
+// buffer is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock makes AddPoint wait until there is room in the buffer.
+	OverflowBlock OverflowPolicy = iota
+
+	// OverflowDropOldest discards the oldest buffered point to make room.
+	OverflowDropOldest
+
+	// OverflowDropNewest discards the point being added.
+	OverflowDropNewest
+)
+
+// BatchingOptions is the config data needed to create a BatchingClient.
+type BatchingOptions struct {
+	// BatchPointsConfig is used for every batch written, it sets the
+	// database, retention policy, precision and write consistency.
+	BatchPointsConfig BatchPointsConfig
+
+	// BatchSize is the number of points that triggers a write, defaults to
+	// DefaultBatchSize.
+	BatchSize int
+
+	// FlushInterval is the longest time a point waits before being written,
+	// defaults to DefaultFlushInterval.
+	FlushInterval time.Duration
+
+	// AdaptiveBatching makes the BatchingClient adjust the number of points
+	// that triggers a write to the writes of the wrapped client, starting
+	// from BatchSize: a write slower than TargetLatency, timed out or
+	// answered with a 429 or 503 status halves it, and a full batch written
+	// faster grows it by MinBatchSize. The size is reported to an
+	// AdaptiveStatsCollector set on the wrapped client. Flush and Close
+	// write every point held whatever the size.
+	AdaptiveBatching bool
+
+	// MinBatchSize and MaxBatchSize bound the batch size of
+	// AdaptiveBatching, they default to a tenth of BatchSize and to
+	// BatchSize.
+	MinBatchSize int
+	MaxBatchSize int
+
+	// TargetLatency is the latency of the writes AdaptiveBatching keeps the
+	// batches below, defaults to DefaultTargetLatency.
+	TargetLatency time.Duration
+
+	// BufferSize is the number of points held while a write is in progress,
+	// defaults to BatchSize, or to MaxBatchSize with AdaptiveBatching. With
+	// Priorities, it is the budget in points of the queue.
+	BufferSize int
+
+	// Priorities, if set, makes the BatchingClient queue the points by the
+	// Priority they are added with, see PriorityOptions.
+	Priorities *PriorityOptions
+
+	// Overflow is what happens to new points while the buffer is full,
+	// defaults to OverflowBlock.
+	Overflow OverflowPolicy
+
+	// OnError, if set, is called with the points of every failed write,
+	// with the points dropped by the Overflow policy and, without points,
+	// by WaitForBackend. It is called from the writing goroutine or from
+	// AddPoint and must not block for long.
+	OnError func(err error, points []*Point)
+
+	// TimestampCheck, if set, checks the timestamps of the points of every
+	// batch before it is written. The points out of range are reported to
+	// OnError with a *TimestampError, after its Action was applied.
+	TimestampCheck *TimestampCheck
+
+	// WaitForBackend, if set, makes the first write wait for the wrapped
+	// client to answer a Ping, and the writes after its circuit breaker
+	// opened, see BackendWait.
+	WaitForBackend *BackendWait
+
+	// Clock, if set, is the clock of FlushInterval, WaitForBackend, and of
+	// the TimestampCheck and the AssignTimeOnAdd of BatchPointsConfig
+	// unless they set their own. Defaults to the system clock.
+	Clock Clock
+}
+
+// QueueStatsCollector is implemented by a StatsCollector that is told how
+// many points the BatchingClients wrapping its client hold in their buffer.
+type QueueStatsCollector interface {
+	// QueueDepthChanged is called with the number of points added to the
+	// buffer of a BatchingClient, or, negative, of those taken out of it
+	// to be written or dropped. The points AddPoint waits to add are
+	// counted as in the buffer.
+	QueueDepthChanged(delta int)
+}
+
+// BatchingClient accumulates points in the background and writes them with
+// the wrapped Client in batches. BatchingClient is safe for concurrent use by
+// multiple goroutines.
+type BatchingClient struct {
+	c    Client
+	conf BatchPointsConfig
+
+	batchSize     int
+	flushInterval time.Duration
+	adaptive      *aimd
+	overflow      OverflowPolicy
+	onError       func(error, []*Point)
+	timeCheck     *TimestampCheck
+	clock         Clock
+
+	// wait is WaitForBackend, and waiting whether the next write waits
+	// for the wrapped client. waiting belongs to the writing goroutine.
+	wait    *BackendWait
+	waiting bool
+
+	// prio is the queue of the points with Priorities, in place of points.
+	prio      *priorityQueue
+	prioStats PriorityStatsCollector
+
+	queueStats QueueStatsCollector
+
+	// mu guards closed. AddPoint holds a read lock while it sends to points
+	// so that no point is left behind once Close starts draining.
+	mu     sync.RWMutex
+	closed bool
+
+	points  chan *Point
+	flushes chan chan struct{}
+	closing chan struct{}
+	done    chan struct{}
+}
+
+// NewBatchingClient returns a BatchingClient that writes with c. Closing the
+// BatchingClient does not close c.
+func NewBatchingClient(c Client, opts BatchingOptions) (*BatchingClient, error) {
+	if _, err := NewBatchPoints(opts.BatchPointsConfig); err != nil {
+		return nil, err
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = DefaultFlushInterval
+	}
+	if opts.AdaptiveBatching {
+		if err := validateAdaptive(opts); err != nil {
+			return nil, err
+		}
+		if opts.MaxBatchSize == 0 {
+			opts.MaxBatchSize = opts.BatchSize
+			if opts.MinBatchSize > opts.MaxBatchSize {
+				opts.MaxBatchSize = opts.MinBatchSize
+			}
+		}
+		if opts.MinBatchSize == 0 {
+			opts.MinBatchSize = opts.MaxBatchSize / 10
+			if opts.MinBatchSize == 0 {
+				opts.MinBatchSize = 1
+			}
+		}
+	}
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = opts.BatchSize
+		if opts.AdaptiveBatching {
+			opts.BufferSize = opts.MaxBatchSize
+		}
+	}
+	switch opts.Overflow {
+	case OverflowBlock, OverflowDropOldest, OverflowDropNewest:
+	default:
+		return nil, errors.New("unknown overflow policy")
+	}
+	clock := clockOrSystem(opts.Clock)
+	if opts.TimestampCheck != nil {
+		if err := opts.TimestampCheck.validate(); err != nil {
+			return nil, err
+		}
+		if opts.TimestampCheck.Now == nil {
+			tc := *opts.TimestampCheck
+			tc.Now = clock.Now
+			opts.TimestampCheck = &tc
+		}
+	}
+	if opts.BatchPointsConfig.AssignTimeOnAdd && opts.BatchPointsConfig.Clock == nil {
+		opts.BatchPointsConfig.Clock = clock
+	}
+	if opts.WaitForBackend != nil {
+		if err := opts.WaitForBackend.validate(); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Priorities != nil {
+		if err := opts.Priorities.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	bc := &BatchingClient{
+		c:             c,
+		conf:          opts.BatchPointsConfig,
+		batchSize:     opts.BatchSize,
+		flushInterval: opts.FlushInterval,
+		overflow:      opts.Overflow,
+		onError:       opts.OnError,
+		timeCheck:     opts.TimestampCheck,
+		clock:         clock,
+		wait:          opts.WaitForBackend,
+		waiting:       opts.WaitForBackend != nil,
+		points:        make(chan *Point, opts.BufferSize),
+		flushes:       make(chan chan struct{}),
+		closing:       make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	if sc, ok := c.(statsClient); ok {
+		bc.queueStats, _ = sc.statsCollector().(QueueStatsCollector)
+	}
+	if opts.Priorities != nil {
+		bc.prio = newPriorityQueue(opts.BufferSize, *opts.Priorities)
+		bc.points = nil
+		if sc, ok := c.(statsClient); ok {
+			bc.prioStats, _ = sc.statsCollector().(PriorityStatsCollector)
+		}
+	}
+	if opts.AdaptiveBatching {
+		var stats AdaptiveStatsCollector
+		if sc, ok := c.(statsClient); ok {
+			stats, _ = sc.statsCollector().(AdaptiveStatsCollector)
+		}
+		bc.adaptive, bc.batchSize = newAIMD(opts, stats)
+		if stats != nil {
+			stats.BatchSizeChanged(bc.batchSize)
+		}
+	}
+	go bc.run()
+	return bc, nil
+}
+
+// AddPoint queues p to be written. With the AssignTimeOnAdd of the
+// BatchPointsConfig a point without a timestamp is given the time it was
+// added at. With Priorities, p is queued as PriorityNormal.
+func (bc *BatchingClient) AddPoint(p *Point) {
+	bc.AddPointWithPriority(p, PriorityNormal)
+}
+
+// AddPointWithPriority queues p to be written with the class prio, see
+// PriorityOptions. Without Priorities, it is the same as AddPoint.
+func (bc *BatchingClient) AddPointWithPriority(p *Point, prio Priority) {
+	if bc.conf.AssignTimeOnAdd {
+		p = assignTime(p, bc.conf.Clock)
+	}
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	if bc.closed {
+		bc.report(ErrBatchingClientClosed, []*Point{p})
+		return
+	}
+
+	bc.queued(1)
+	if bc.prio != nil {
+		for i, points := range bc.prio.push(p, prio) {
+			if len(points) == 0 {
+				continue
+			}
+			bc.queued(-len(points))
+			if bc.prioStats != nil {
+				bc.prioStats.PointsEvicted(PriorityHigh-Priority(i), len(points))
+			}
+			bc.report(ErrBufferFull, points)
+		}
+		return
+	}
+
+	switch bc.overflow {
+	case OverflowBlock:
+		bc.points <- p
+	case OverflowDropNewest:
+		select {
+		case bc.points <- p:
+		default:
+			bc.queued(-1)
+			bc.report(ErrBufferFull, []*Point{p})
+		}
+	case OverflowDropOldest:
+		for {
+			select {
+			case bc.points <- p:
+				return
+			default:
+			}
+			select {
+			case old := <-bc.points:
+				bc.queued(-1)
+				bc.report(ErrBufferFull, []*Point{old})
+			default:
+			}
+		}
+	}
+}
+
+// AddPoints queues ps to be written.
+func (bc *BatchingClient) AddPoints(ps []*Point) {
+	for _, p := range ps {
+		bc.AddPoint(p)
+	}
+}
+
+// Flush writes every point added before the call and waits for the writes to
+// complete. Write errors are reported to OnError.
+func (bc *BatchingClient) Flush() {
+	ch := make(chan struct{})
+	select {
+	case bc.flushes <- ch:
+		<-ch
+	case <-bc.done:
+	}
+}
+
+// Close writes the remaining points and stops the background writer. Points
+// added after Close are reported to OnError with ErrBatchingClientClosed.
+func (bc *BatchingClient) Close() error {
+	bc.mu.Lock()
+	if !bc.closed {
+		bc.closed = true
+		close(bc.closing)
+	}
+	bc.mu.Unlock()
+
+	<-bc.done
+	return nil
+}
+
+// run is the background writer. It owns the batch being accumulated.
+func (bc *BatchingClient) run() {
+	defer close(bc.done)
+
+	tick := bc.clock.After(bc.flushInterval)
+
+	batch := make([]*Point, 0, bc.batchSize)
+	add := func(p *Point) {
+		batch = append(batch, p)
+		if len(batch) >= bc.batchSize {
+			bc.write(batch)
+			batch = make([]*Point, 0, bc.batchSize)
+		}
+	}
+	drain := func() {
+		for n := len(bc.points); n > 0; n-- {
+			bc.queued(-1)
+			add(<-bc.points)
+		}
+		if len(batch) > 0 {
+			bc.write(batch)
+			batch = make([]*Point, 0, bc.batchSize)
+		}
+		if bc.prio != nil {
+			bc.writeQueued()
+		}
+	}
+
+	var ready <-chan struct{}
+	if bc.prio != nil {
+		ready = bc.prio.ready
+	}
+
+	for {
+		select {
+		case p := <-bc.points:
+			bc.queued(-1)
+			add(p)
+		case <-ready:
+			for bc.prio.len() >= bc.batchSize {
+				limit := bc.prio.lens()
+				bc.writeTaken(bc.prio.take(bc.batchSize, &limit))
+			}
+		case <-tick:
+			if len(batch) > 0 {
+				bc.write(batch)
+				batch = make([]*Point, 0, bc.batchSize)
+			}
+			if bc.prio != nil {
+				bc.writeQueued()
+			}
+			tick = bc.clock.After(bc.flushInterval)
+		case ch := <-bc.flushes:
+			drain()
+			close(ch)
+		case <-bc.closing:
+			// Close holds no lock by now and every AddPoint that got
+			// in before it has returned, so the buffer is final.
+			if bc.prio != nil {
+				for {
+					points := bc.prio.takeOrdered(bc.batchSize)
+					if len(points) == 0 {
+						break
+					}
+					bc.writeTaken(points)
+				}
+			}
+			drain()
+			return
+		}
+	}
+}
+
+// writeQueued writes the points queued with Priorities by the time it is
+// called, in batches taken in weighted turns.
+func (bc *BatchingClient) writeQueued() {
+	limit := bc.prio.lens()
+	for {
+		points := bc.prio.take(bc.batchSize, &limit)
+		if len(points) == 0 {
+			return
+		}
+		bc.writeTaken(points)
+	}
+}
+
+// writeTaken writes the points taken out of the priority queue.
+func (bc *BatchingClient) writeTaken(points []*Point) {
+	bc.queued(-len(points))
+	bc.write(points)
+}
+
+// queued reports n points added to the buffer, or taken out of it if n is
+// negative, to the QueueStatsCollector.
+func (bc *BatchingClient) queued(n int) {
+	if bc.queueStats != nil {
+		bc.queueStats.QueueDepthChanged(n)
+	}
+}
+
+// write sends points with the wrapped client and reports a failure. They
+// are split into as many batches as the MaxBytes of the config requires. A
+// point that fits none is reported with ErrPointExceedsBatch, and one that
+// AddPoint rejects otherwise, as with a *LimitError, with its error.
+func (bc *BatchingClient) write(points []*Point) {
+	if bc.timeCheck != nil {
+		var bad []*Point
+		var err error
+		points, bad, err = bc.timeCheck.apply(points, bc.conf.Precision)
+		if err != nil {
+			bc.report(err, bad)
+		}
+	}
+	for len(points) > 0 {
+		if bc.waiting {
+			bc.waiting = false
+			bc.awaitBackend()
+		}
+		bp, _ := NewBatchPoints(bc.conf)
+		var n int
+		var err error
+		for ; n < len(points); n++ {
+			if err = bp.AddPoint(points[n]); err != nil {
+				break
+			}
+		}
+		if n > 0 {
+			start := bc.clock.Now()
+			werr := bc.c.Write(bp)
+			if bc.adaptive != nil {
+				bc.resize(bc.adaptive.observe(bc.batchSize, n, bc.clock.Now().Sub(start), werr))
+			}
+			if werr != nil {
+				bc.report(werr, points[:n])
+				if bc.wait != nil && bc.circuitOpened(werr) {
+					bc.waiting = true
+				}
+			}
+		}
+		if err != nil && err != ErrBatchFull {
+			bc.report(err, points[n:n+1])
+			n++
+		}
+		points = points[n:]
+	}
+}
+
+func (bc *BatchingClient) report(err error, points []*Point) {
+	if bc.onError != nil {
+		bc.onError(err, points)
+	}
+}
@@ -0,0 +1,309 @@
+package client
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// batchRecorder is a Client that records the batches written to it.
+type batchRecorder struct {
+	mu      sync.Mutex
+	batches [][]*Point
+	gate    chan struct{}
+	err     error
+}
+
+func (r *batchRecorder) Ping(timeout time.Duration) (time.Duration, string, error) {
+	return 0, "", nil
+}
+
+func (r *batchRecorder) Write(bp BatchPoints) error {
+	if r.gate != nil {
+		<-r.gate
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.batches = append(r.batches, bp.Points())
+	return r.err
+}
+
+func (r *batchRecorder) Query(q Query) (*Response, error) { return nil, nil }
+
+func (r *batchRecorder) QueryAsChunk(q Query) (*ChunkedResponse, error) { return nil, nil }
+
+func (r *batchRecorder) Close() error { return nil }
+
+func (r *batchRecorder) sizes() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var n []int
+	for _, b := range r.batches {
+		n = append(n, len(b))
+	}
+	return n
+}
+
+func newTestPoints(n int) []*Point {
+	points := make([]*Point, n)
+	for i := range points {
+		points[i], _ = NewPoint("cpu", nil, map[string]interface{}{"value": float64(i)}, time.Unix(int64(i), 0))
+	}
+	return points
+}
+
+func TestBatchingClient_BatchSize(t *testing.T) {
+	var r batchRecorder
+	bc, err := NewBatchingClient(&r, BatchingOptions{BatchSize: 3, FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	bc.AddPoints(newTestPoints(7))
+	bc.Flush()
+
+	if got, exp := r.sizes(), []int{3, 3, 1}; !equalInts(got, exp) {
+		t.Errorf("unexpected batch sizes: got %v, exp %v", got, exp)
+	}
+	bc.Close()
+}
+
+func TestBatchingClient_MaxBytes(t *testing.T) {
+	var r batchRecorder
+	var reported []error
+	p, _ := NewPoint("cpu", nil, map[string]interface{}{"value": 1.0}, time.Unix(1, 0))
+	size := len(p.String()) + 1
+	large, _ := NewPoint("cpu", nil, map[string]interface{}{"value": strings.Repeat("x", 2*size)}, time.Unix(1, 0))
+	points := []*Point{p, p, p, p, large, p, p, p}
+
+	bc, err := NewBatchingClient(&r, BatchingOptions{
+		BatchPointsConfig: BatchPointsConfig{MaxBytes: 2 * size},
+		BatchSize:         10,
+		FlushInterval:     time.Hour,
+		OnError:           func(err error, points []*Point) { reported = append(reported, err) },
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	bc.AddPoints(points)
+	bc.Flush()
+
+	if got, exp := r.sizes(), []int{2, 2, 2, 1}; !equalInts(got, exp) {
+		t.Errorf("unexpected batch sizes: got %v, exp %v", got, exp)
+	}
+	if len(reported) != 1 || reported[0] != ErrPointExceedsBatch {
+		t.Errorf("unexpected errors reported: %v", reported)
+	}
+	bc.Close()
+}
+
+func TestBatchingClient_PointOverLimits(t *testing.T) {
+	var r batchRecorder
+	var reported []*Point
+	var lerr *LimitError
+	points := newTestPoints(3)
+	large, _ := NewPoint("large", nil, map[string]interface{}{"value": strings.Repeat("x", 70000)}, time.Unix(1, 0))
+	points = append(points[:1], append([]*Point{large}, points[1:]...)...)
+
+	bc, _ := NewBatchingClient(&r, BatchingOptions{
+		BatchSize:     10,
+		FlushInterval: time.Hour,
+		OnError: func(err error, points []*Point) {
+			if !errors.As(err, &lerr) {
+				t.Errorf("unexpected error.  expected a *LimitError, actual %v", err)
+			}
+			reported = append(reported, points...)
+		},
+	})
+	defer bc.Close()
+	bc.AddPoints(points)
+
+	done := make(chan struct{})
+	go func() {
+		bc.Flush()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Flush did not return")
+	}
+	if len(reported) != 1 || reported[0] != large {
+		t.Errorf("unexpected points reported: %v", reported)
+	}
+	if got, exp := r.sizes(), []int{1, 2}; !equalInts(got, exp) {
+		t.Errorf("unexpected batch sizes: got %v, exp %v", got, exp)
+	}
+}
+
+func TestBatchingClient_FlushInterval(t *testing.T) {
+	var r batchRecorder
+	bc, _ := NewBatchingClient(&r, BatchingOptions{BatchSize: 100, FlushInterval: 10 * time.Millisecond})
+	defer bc.Close()
+
+	bc.AddPoints(newTestPoints(2))
+
+	deadline := time.Now().Add(time.Second)
+	for len(r.sizes()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("points were not flushed after FlushInterval")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got, exp := r.sizes(), []int{2}; !equalInts(got, exp) {
+		t.Errorf("unexpected batch sizes: got %v, exp %v", got, exp)
+	}
+}
+
+func TestBatchingClient_CloseDrains(t *testing.T) {
+	var r batchRecorder
+	var dropped []*Point
+	bc, _ := NewBatchingClient(&r, BatchingOptions{
+		BatchSize:     10,
+		FlushInterval: time.Hour,
+		OnError: func(err error, points []*Point) {
+			if err != ErrBatchingClientClosed {
+				t.Errorf("unexpected error: %v", err)
+			}
+			dropped = append(dropped, points...)
+		},
+	})
+
+	bc.AddPoints(newTestPoints(5))
+	if err := bc.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, exp := r.sizes(), []int{5}; !equalInts(got, exp) {
+		t.Errorf("unexpected batch sizes: got %v, exp %v", got, exp)
+	}
+
+	bc.AddPoints(newTestPoints(1))
+	bc.Flush()
+	if len(dropped) != 1 {
+		t.Errorf("expected the point added after Close to be reported, got %d", len(dropped))
+	}
+}
+
+func TestBatchingClient_OnError(t *testing.T) {
+	r := batchRecorder{err: errors.New("write failed")}
+	var mu sync.Mutex
+	var failed []*Point
+	bc, _ := NewBatchingClient(&r, BatchingOptions{
+		BatchSize:     2,
+		FlushInterval: time.Hour,
+		OnError: func(err error, points []*Point) {
+			mu.Lock()
+			defer mu.Unlock()
+			if err != r.err {
+				t.Errorf("unexpected error: %v", err)
+			}
+			failed = append(failed, points...)
+		},
+	})
+	defer bc.Close()
+
+	bc.AddPoints(newTestPoints(3))
+	bc.Flush()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(failed) != 3 {
+		t.Errorf("unexpected number of failed points: got %d, exp %d", len(failed), 3)
+	}
+}
+
+func TestBatchingClient_Overflow(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		policy  OverflowPolicy
+		written int64
+		dropped int64
+	}{
+		{name: "drop oldest", policy: OverflowDropOldest, written: 2, dropped: 1},
+		{name: "drop newest", policy: OverflowDropNewest, written: 1, dropped: 2},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := batchRecorder{gate: make(chan struct{})}
+			var dropped []*Point
+			bc, _ := NewBatchingClient(&r, BatchingOptions{
+				BatchSize:     1,
+				BufferSize:    1,
+				FlushInterval: time.Hour,
+				Overflow:      tt.policy,
+				OnError: func(err error, points []*Point) {
+					if err != ErrBufferFull {
+						t.Errorf("unexpected error: %v", err)
+					}
+					dropped = append(dropped, points...)
+				},
+			})
+
+			points := newTestPoints(3)
+			bc.AddPoint(points[0])
+			// Wait for the writer to block on the first point.
+			for len(bc.points) != 0 {
+				time.Sleep(time.Millisecond)
+			}
+			bc.AddPoint(points[1])
+			bc.AddPoint(points[2])
+			close(r.gate)
+			bc.Close()
+
+			if len(dropped) != 1 {
+				t.Fatalf("unexpected number of dropped points: got %d, exp %d", len(dropped), 1)
+			}
+			if v := dropped[0].pt.Time().Unix(); v != tt.dropped {
+				t.Errorf("dropped the wrong point: got %v, exp %v", v, tt.dropped)
+			}
+			if got := r.sizes(); len(got) != 2 || r.batches[1][0].pt.Time().Unix() != tt.written {
+				t.Errorf("wrote the wrong points: %v", r.batches)
+			}
+		})
+	}
+}
+
+func TestBatchingClient_OverflowBlock(t *testing.T) {
+	r := batchRecorder{gate: make(chan struct{})}
+	bc, _ := NewBatchingClient(&r, BatchingOptions{BatchSize: 1, BufferSize: 1, FlushInterval: time.Hour})
+
+	points := newTestPoints(3)
+	added := make(chan struct{})
+	go func() {
+		bc.AddPoints(points)
+		close(added)
+	}()
+
+	select {
+	case <-added:
+		t.Fatal("AddPoints returned while the buffer was full")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(r.gate)
+	<-added
+	bc.Close()
+	if got, exp := r.sizes(), []int{1, 1, 1}; !equalInts(got, exp) {
+		t.Errorf("unexpected batch sizes: got %v, exp %v", got, exp)
+	}
+}
+
+func TestNewBatchingClient_InvalidPrecision(t *testing.T) {
+	var r batchRecorder
+	if _, err := NewBatchingClient(&r, BatchingOptions{BatchPointsConfig: BatchPointsConfig{Precision: "bad"}}); err == nil {
+		t.Error("expected an error for an invalid precision")
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,28 @@
+package client
+
+import "sync"
+
+// batchPool recycles the batches of GetBatchPoints.
+var batchPool = sync.Pool{New: func() interface{} { return new(batchpoints) }}
+
+// GetBatchPoints is like NewBatchPoints, but reuses a batch released with
+// PutBatchPoints if there is one, along with the memory allocated for its
+// points.
+func GetBatchPoints(conf BatchPointsConfig) (BatchPoints, error) {
+	bp := batchPool.Get().(*batchpoints)
+	if err := bp.configure(conf); err != nil {
+		batchPool.Put(bp)
+		return nil, err
+	}
+	return bp, nil
+}
+
+// PutBatchPoints releases bp for reuse by GetBatchPoints. Neither bp nor a
+// slice returned by its Points method may be used after the call. Batches
+// that were not created by NewBatchPoints or GetBatchPoints are ignored.
+func PutBatchPoints(bp BatchPoints) {
+	if b, ok := bp.(*batchpoints); ok {
+		b.Reset()
+		batchPool.Put(b)
+	}
+}
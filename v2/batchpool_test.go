@@ -0,0 +1,78 @@
+package client
+
+import "testing"
+
+func TestBatchPoints_Reset(t *testing.T) {
+	bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0", Precision: "s"})
+	pt, _ := NewPoint("cpu", nil, map[string]interface{}{"value": 1})
+	bp.AddPoints([]*Point{pt, pt, pt})
+	capacity := cap(bp.Points())
+
+	bp.Reset()
+	if n := len(bp.Points()); n != 0 {
+		t.Errorf("unexpected number of points.  expected %v, actual %v", 0, n)
+	}
+	if c := cap(bp.Points()); c != capacity {
+		t.Errorf("unexpected capacity.  expected %v, actual %v", capacity, c)
+	}
+	if bp.Database() != "db0" || bp.Precision() != "s" {
+		t.Errorf("unexpected settings: database %q, precision %q", bp.Database(), bp.Precision())
+	}
+}
+
+func TestGetBatchPoints(t *testing.T) {
+	bp, err := GetBatchPoints(BatchPointsConfig{Database: "db0", Precision: "s"})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	pt, _ := NewPoint("cpu", nil, map[string]interface{}{"value": 1})
+	bp.AddPoint(pt)
+	PutBatchPoints(bp)
+
+	bp, err = GetBatchPoints(BatchPointsConfig{Database: "db1"})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if len(bp.Points()) != 0 || bp.Database() != "db1" || bp.Precision() != "ns" || bp.RetentionPolicy() != "" {
+		t.Errorf("unexpected batch: %d points, database %q, precision %q", len(bp.Points()), bp.Database(), bp.Precision())
+	}
+
+	if _, err := GetBatchPoints(BatchPointsConfig{Precision: "bad"}); err == nil {
+		t.Error("expected an error for an invalid precision")
+	}
+}
+
+func BenchmarkBatchPoints_New(b *testing.B) {
+	cl := &tcpclient{conn: discardConn{}, payloadSize: TCPPayloadSize}
+	points := newTestPoints(5000)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bp, _ := NewBatchPoints(BatchPointsConfig{})
+		for _, pt := range points {
+			bp.AddPoint(pt)
+		}
+		if err := cl.Write(bp); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkBatchPoints_Pooled(b *testing.B) {
+	cl := &tcpclient{conn: discardConn{}, payloadSize: TCPPayloadSize}
+	points := newTestPoints(5000)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bp, _ := GetBatchPoints(BatchPointsConfig{})
+		for _, pt := range points {
+			bp.AddPoint(pt)
+		}
+		if err := cl.Write(bp); err != nil {
+			b.Fatal(err)
+		}
+		PutBatchPoints(bp)
+	}
+}
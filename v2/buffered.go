@@ -0,0 +1,424 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+const (
+	// DefaultMaxDiskUsage is the default size of the writes a BufferedClient
+	// keeps on disk.
+	DefaultMaxDiskUsage = 100 << 20
+
+	// DefaultSegmentSize is the default size of the segment files of a
+	// BufferedClient.
+	DefaultSegmentSize = 4 << 20
+
+	// DefaultReplayInterval is the default interval at which a
+	// BufferedClient tries to replay the writes it holds.
+	DefaultReplayInterval = 10 * time.Second
+)
+
+// ErrBufferEvicted is passed to BufferOptions.OnError when buffered writes
+// were discarded to stay within MaxDiskUsage.
+var ErrBufferEvicted = errors.New("buffered writes evicted")
+
+// BufferOptions is the config data needed to create a BufferedClient.
+type BufferOptions struct {
+	// Dir is the directory the failed writes are kept in, created if it
+	// does not exist. It must not be shared with another BufferedClient.
+	Dir string
+
+	// MaxDiskUsage is the size in bytes the buffered writes may take on
+	// disk, defaults to DefaultMaxDiskUsage. Once it is exceeded the oldest
+	// segments are discarded.
+	MaxDiskUsage int64
+
+	// SegmentSize is the size in bytes of a segment file, defaults to
+	// DefaultSegmentSize. Writes are evicted and replayed a whole segment
+	// at a time.
+	SegmentSize int64
+
+	// ReplayInterval is how often the wrapped client is pinged while writes
+	// are buffered, defaults to DefaultReplayInterval. The buffer is
+	// replayed as soon as a ping succeeds.
+	ReplayInterval time.Duration
+
+	// OnError, if set, is called with the errors of the background replay
+	// that cause writes to be dropped: a write the server rejected, a
+	// partially written record found in a segment, or ErrBufferEvicted. It
+	// is called from the replaying goroutine or from Write and must not
+	// block for long.
+	OnError func(err error)
+}
+
+// BufferedClient is a Client that keeps the writes that failed because the
+// server could not be reached, or answered with a 5xx or 429 status, in
+// segment files on disk, and replays them in order once the server answers
+// pings again. The files survive a restart: a BufferedClient opened on the
+// same directory replays the writes left there.
+//
+// Delivery is at least once. A write replayed when the process stopped, or
+// whose response was lost, is sent again, so points may be written twice;
+// InfluxDB stores a point written twice with the same series and timestamp
+// once. Points without a timestamp get the time they were buffered at. The
+// WriteJournal of the wrapped client, kept in the directory, skips the writes
+// replayed that were acknowledged before.
+//
+// BufferedClient is safe for concurrent use by multiple goroutines.
+type BufferedClient struct {
+	c              Client
+	q              *diskQueue
+	replayInterval time.Duration
+	onError        func(error)
+
+	// replayMu serializes the replays of the background goroutine and of
+	// Drain.
+	replayMu sync.Mutex
+
+	// ctx is canceled by Close, to abort a replay in progress.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// NewBufferedClient returns a BufferedClient that writes with c. Closing the
+// BufferedClient does not close c.
+func NewBufferedClient(c Client, opts BufferOptions) (*BufferedClient, error) {
+	if opts.Dir == "" {
+		return nil, &ConfigError{Field: "Dir", Reason: "no directory given"}
+	}
+	if opts.MaxDiskUsage <= 0 {
+		opts.MaxDiskUsage = DefaultMaxDiskUsage
+	}
+	if opts.SegmentSize <= 0 {
+		opts.SegmentSize = DefaultSegmentSize
+	}
+	if opts.ReplayInterval <= 0 {
+		opts.ReplayInterval = DefaultReplayInterval
+	}
+
+	q, err := openDiskQueue(opts.Dir, opts.MaxDiskUsage, opts.SegmentSize)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	bc := &BufferedClient{
+		c:              c,
+		q:              q,
+		replayInterval: opts.ReplayInterval,
+		onError:        opts.OnError,
+		ctx:            ctx,
+		cancel:         cancel,
+		done:           make(chan struct{}),
+	}
+	go bc.run()
+	return bc, nil
+}
+
+// Ping checks the status of the wrapped client.
+func (bc *BufferedClient) Ping(timeout time.Duration) (time.Duration, string, error) {
+	return bc.c.Ping(timeout)
+}
+
+// Write writes bp with the wrapped client. If that fails because the server
+// could not be reached, bp is buffered on disk and Write returns nil. Other
+// errors, such as points the server rejected, are returned as is.
+func (bc *BufferedClient) Write(bp BatchPoints) error {
+	return bc.WriteContext(context.Background(), bp)
+}
+
+// WriteContext is like Write, but the write is bound to ctx. A write canceled
+// through ctx is not buffered.
+func (bc *BufferedClient) WriteContext(ctx context.Context, bp BatchPoints) error {
+	err := bc.write(ctx, bp)
+	if err == nil || ctx.Err() != nil || breakerOutcome(err) != outcomeFailure {
+		return err
+	}
+	if spillErr := bc.spill(bp); spillErr != nil {
+		return errors.Join(err, spillErr)
+	}
+	return nil
+}
+
+// Query sends q with the wrapped client.
+func (bc *BufferedClient) Query(q Query) (*Response, error) {
+	return bc.c.Query(q)
+}
+
+// QueryContext sends q with the wrapped client, bound to ctx if it supports
+// it.
+func (bc *BufferedClient) QueryContext(ctx context.Context, q Query) (*Response, error) {
+	if cc, ok := bc.c.(ContextClient); ok {
+		return cc.QueryContext(ctx, q)
+	}
+	return bc.c.Query(q)
+}
+
+// QueryAsChunk sends q with the wrapped client.
+func (bc *BufferedClient) QueryAsChunk(q Query) (*ChunkedResponse, error) {
+	return bc.c.QueryAsChunk(q)
+}
+
+// QueryAsChunkContext sends q with the wrapped client, bound to ctx if it
+// supports it.
+func (bc *BufferedClient) QueryAsChunkContext(ctx context.Context, q Query) (*ChunkedResponse, error) {
+	if cc, ok := bc.c.(ContextClient); ok {
+		return cc.QueryAsChunkContext(ctx, q)
+	}
+	return bc.c.QueryAsChunk(q)
+}
+
+// Buffered returns the size in bytes of the writes waiting on disk.
+func (bc *BufferedClient) Buffered() int64 {
+	return bc.q.bytes()
+}
+
+// Drain replays the buffered writes until there are none left or ctx is done,
+// retrying every ReplayInterval while the server cannot be reached. Close
+// aborts it too. The writes not replayed stay on disk.
+func (bc *BufferedClient) Drain(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-bc.ctx.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	for {
+		err := bc.replay(ctx)
+		if err == nil {
+			return nil
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+
+		t := time.NewTimer(bc.replayInterval)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// Close stops the background replay, aborting a write it is sending if the
+// wrapped client is a ContextClient. The
+// buffered writes stay on disk, to be replayed by the next BufferedClient
+// opened on the directory.
+func (bc *BufferedClient) Close() error {
+	bc.closeOnce.Do(func() {
+		bc.cancel()
+		<-bc.done
+		bc.q.close()
+	})
+	return nil
+}
+
+// run replays the buffered writes when the wrapped client answers pings.
+func (bc *BufferedClient) run() {
+	defer close(bc.done)
+
+	ticker := time.NewTicker(bc.replayInterval)
+	defer ticker.Stop()
+	// Writes left by a previous process are replayed right away.
+	for {
+		if bc.q.bytes() > 0 {
+			if _, _, err := bc.c.Ping(0); err == nil {
+				bc.replay(bc.ctx)
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-bc.ctx.Done():
+			return
+		}
+	}
+}
+
+// replay writes the buffered writes in order, removing every segment once it
+// was written. It stops at the first write that fails because the server
+// could not be reached, and returns its error.
+func (bc *BufferedClient) replay(ctx context.Context) error {
+	bc.replayMu.Lock()
+	defer bc.replayMu.Unlock()
+
+	for {
+		seg := bc.q.oldest()
+		if seg == nil {
+			return nil
+		}
+		data, err := os.ReadFile(seg.path)
+		if os.IsNotExist(err) {
+			// Evicted while being replayed.
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		for seg.offset < len(data) {
+			// Drain may still be going on once the client is closed.
+			if err := bc.ctx.Err(); err != nil {
+				return err
+			}
+			payload, n, err := readRecord(data[seg.offset:])
+			if err != nil {
+				bc.report(fmt.Errorf("buffered segment %s: dropping a partially written record of %d bytes", seg.path, len(data)-seg.offset))
+				break
+			}
+			if err := bc.replayRecord(ctx, payload); err != nil {
+				return err
+			}
+			seg.offset += n
+		}
+		if err := bc.q.remove(seg); err != nil {
+			return err
+		}
+	}
+}
+
+// replayRecord writes the buffered write of payload. It returns an error only
+// if the write should be tried again later.
+func (bc *BufferedClient) replayRecord(ctx context.Context, payload []byte) error {
+	e, err := decodeBufferedWrite(payload)
+	if err != nil {
+		bc.report(err)
+		return nil
+	}
+	points, err := ParsePoints(e.lines, time.Now(), e.precision)
+	if err != nil {
+		bc.report(fmt.Errorf("buffered write: %v", err))
+		return nil
+	}
+
+	bp, err := NewBatchPoints(BatchPointsConfig{
+		Database:         e.database,
+		RetentionPolicy:  e.retentionPolicy,
+		Precision:        e.precision,
+		WriteConsistency: e.consistency,
+	})
+	if err == nil {
+		// The points were accepted by the batch buffered.
+		err = addAccepted(bp, points)
+	}
+	if err != nil {
+		bc.report(fmt.Errorf("buffered write: %v", err))
+		return nil
+	}
+
+	err = bc.write(ctx, bp)
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() != nil || breakerOutcome(err) == outcomeFailure {
+		return err
+	}
+	bc.report(err)
+	return nil
+}
+
+// write writes bp with the wrapped client, bound to ctx if it supports it.
+func (bc *BufferedClient) write(ctx context.Context, bp BatchPoints) error {
+	if cc, ok := bc.c.(ContextClient); ok {
+		return cc.WriteContext(ctx, bp)
+	}
+	return bc.c.Write(bp)
+}
+
+// spill appends bp to the buffer.
+func (bc *BufferedClient) spill(bp BatchPoints) error {
+	var lines []byte
+	now := time.Now()
+	sorter := newFieldSorter(bp)
+	for _, p := range bp.Points() {
+		if p == nil {
+			continue
+		}
+		pt := p.pt
+		if pt.Time().IsZero() {
+			pt = models.PointWithTime(pt, now)
+		}
+		start := len(lines)
+		lines = models.AppendPrecisionString(lines, pt, bp.Precision())
+		if sorter != nil {
+			sorter.sortLine(lines[start:], len(pt.Key()), true)
+		}
+		lines = append(lines, '\n')
+	}
+
+	e := bufferedWrite{
+		database:        bp.Database(),
+		retentionPolicy: bp.RetentionPolicy(),
+		precision:       bp.Precision(),
+		consistency:     bp.WriteConsistency(),
+		lines:           lines,
+	}
+	evicted, err := bc.q.append(e.encode())
+	if evicted > 0 {
+		bc.report(fmt.Errorf("%w: %d segments removed to stay within the maximum disk usage", ErrBufferEvicted, evicted))
+	}
+	return err
+}
+
+func (bc *BufferedClient) report(err error) {
+	if bc.onError != nil {
+		bc.onError(err)
+	}
+}
+
+// bufferedWrite is the payload of a record of a BufferedClient: the settings
+// of the batch, each a uvarint length followed by the string, then the line
+// protocol of its points.
+type bufferedWrite struct {
+	database        string
+	retentionPolicy string
+	precision       string
+	consistency     string
+	lines           []byte
+}
+
+func (e bufferedWrite) encode() []byte {
+	var b []byte
+	for _, s := range []string{e.database, e.retentionPolicy, e.precision, e.consistency} {
+		b = binary.AppendUvarint(b, uint64(len(s)))
+		b = append(b, s...)
+	}
+	return append(b, e.lines...)
+}
+
+func decodeBufferedWrite(b []byte) (bufferedWrite, error) {
+	r := bytes.NewReader(b)
+	var fields [4]string
+	for i := range fields {
+		n, err := binary.ReadUvarint(r)
+		if err != nil || n > uint64(r.Len()) {
+			return bufferedWrite{}, fmt.Errorf("invalid buffered write: %v", io.ErrUnexpectedEOF)
+		}
+		s := make([]byte, n)
+		r.Read(s)
+		fields[i] = string(s)
+	}
+	return bufferedWrite{
+		database:        fields[0],
+		retentionPolicy: fields[1],
+		precision:       fields[2],
+		consistency:     fields[3],
+		lines:           b[len(b)-r.Len():],
+	}, nil
+}
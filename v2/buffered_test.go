@@ -0,0 +1,326 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flakyClient is a Client whose writes fail while it is down.
+type flakyClient struct {
+	mu     sync.Mutex
+	down   bool
+	err    error
+	points []string
+
+	// block, if set, makes a write wait once that many points were written,
+	// until its context is done.
+	block int
+	// blocked is closed when a write blocks.
+	blocked chan struct{}
+}
+
+var errUnreachable = errors.New("dial tcp: connection refused")
+
+func (c *flakyClient) setDown(down bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.down = down
+}
+
+func (c *flakyClient) written() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.points...)
+}
+
+func (c *flakyClient) Ping(timeout time.Duration) (time.Duration, string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.down {
+		return 0, "", errUnreachable
+	}
+	return 0, "", nil
+}
+
+func (c *flakyClient) Write(bp BatchPoints) error {
+	return c.WriteContext(context.Background(), bp)
+}
+
+func (c *flakyClient) WriteContext(ctx context.Context, bp BatchPoints) error {
+	c.mu.Lock()
+	if c.down {
+		c.mu.Unlock()
+		return errUnreachable
+	}
+	if c.err != nil {
+		c.mu.Unlock()
+		return c.err
+	}
+	if c.block > 0 && len(c.points) >= c.block {
+		close(c.blocked)
+		c.block = 0
+		c.mu.Unlock()
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	defer c.mu.Unlock()
+	for _, p := range bp.Points() {
+		c.points = append(c.points, p.String())
+	}
+	return nil
+}
+
+func (c *flakyClient) Query(q Query) (*Response, error) { return &Response{}, nil }
+
+func (c *flakyClient) QueryContext(ctx context.Context, q Query) (*Response, error) {
+	return &Response{}, nil
+}
+
+func (c *flakyClient) QueryAsChunk(q Query) (*ChunkedResponse, error) {
+	return nil, ErrQueryNotSupported
+}
+
+func (c *flakyClient) QueryAsChunkContext(ctx context.Context, q Query) (*ChunkedResponse, error) {
+	return nil, ErrQueryNotSupported
+}
+
+func (c *flakyClient) Close() error { return nil }
+
+// writeNumbered writes n batches of one point each, numbered from first.
+func writeNumbered(t *testing.T, c Client, first, n int) []string {
+	t.Helper()
+	var exp []string
+	for i := first; i < first+n; i++ {
+		bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0", Precision: "s"})
+		pt, _ := NewPoint("cpu", map[string]string{"host": "a"}, map[string]interface{}{"n": i}, time.Unix(int64(i), 0))
+		bp.AddPoint(pt)
+		if err := c.Write(bp); err != nil {
+			t.Fatalf("unexpected error for write %d.  expected %v, actual %v", i, nil, err)
+		}
+		exp = append(exp, pt.String())
+	}
+	return exp
+}
+
+// distinct returns the sorted distinct values of s.
+func distinct(s []string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, v := range s {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestBufferedClient_Replay(t *testing.T) {
+	inner := &flakyClient{down: true}
+	bc, err := NewBufferedClient(inner, BufferOptions{Dir: t.TempDir(), SegmentSize: 256, ReplayInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	defer bc.Close()
+
+	exp := writeNumbered(t, bc, 0, 20)
+	if bc.Buffered() == 0 {
+		t.Fatal("expected the failed writes to be buffered")
+	}
+
+	inner.setDown(false)
+	if err := bc.Drain(context.Background()); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if got := inner.written(); fmt.Sprint(got) != fmt.Sprint(exp) {
+		t.Errorf("unexpected points, in order.  expected %v, actual %v", exp, got)
+	}
+	if n := bc.Buffered(); n != 0 {
+		t.Errorf("unexpected buffered size.  expected %v, actual %v", 0, n)
+	}
+}
+
+func TestBufferedClient_ReplayWithoutLimits(t *testing.T) {
+	inner := &flakyClient{down: true}
+	bc, _ := NewBufferedClient(inner, BufferOptions{Dir: t.TempDir(), ReplayInterval: time.Hour})
+	defer bc.Close()
+
+	// The batch accepts a string over the default limit, and so do the
+	// batches replaying it.
+	bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0", MaxStringFieldLength: -1})
+	long := mustPoint(t, "cpu", nil, map[string]interface{}{"s": strings.Repeat("x", 70000)}, time.Unix(1, 0))
+	bp.AddPoints([]*Point{long, mustPoint(t, "cpu", nil, map[string]interface{}{"n": int64(2)}, time.Unix(2, 0))})
+	if err := bc.Write(bp); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+
+	inner.setDown(false)
+	if err := bc.Drain(context.Background()); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if got := inner.written(); len(got) != 2 || got[0] != long.String() {
+		t.Errorf("unexpected points.  expected %d, actual %d", 2, len(got))
+	}
+}
+
+func TestBufferedClient_RejectedWrite(t *testing.T) {
+	inner := &flakyClient{err: &ErrorResponse{StatusCode: 400, Message: "unable to parse"}}
+	bc, _ := NewBufferedClient(inner, BufferOptions{Dir: t.TempDir(), ReplayInterval: time.Hour})
+	defer bc.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0"})
+	bp.AddPoints(newTestPoints(1))
+	if err := bc.Write(bp); err != inner.err {
+		t.Errorf("unexpected error.  expected %v, actual %v", inner.err, err)
+	}
+
+	// Neither is a write canceled by the caller.
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	inner.setDown(true)
+	if err := bc.WriteContext(canceled, bp); err != errUnreachable {
+		t.Errorf("unexpected error.  expected %v, actual %v", errUnreachable, err)
+	}
+	if n := bc.Buffered(); n != 0 {
+		t.Errorf("unexpected buffered size.  expected %v, actual %v", 0, n)
+	}
+}
+
+func TestBufferedClient_RestartMidReplay(t *testing.T) {
+	dir := t.TempDir()
+	inner := &flakyClient{down: true}
+	bc, _ := NewBufferedClient(inner, BufferOptions{Dir: dir, SegmentSize: 512, ReplayInterval: time.Hour})
+	exp := writeNumbered(t, bc, 0, 50)
+
+	// Stop the process while it replays the buffer.
+	inner.mu.Lock()
+	inner.down, inner.block, inner.blocked = false, 17, make(chan struct{})
+	inner.mu.Unlock()
+	drained := make(chan error, 1)
+	go func() { drained <- bc.Drain(context.Background()) }()
+	<-inner.blocked
+	bc.Close()
+	if err := <-drained; err == nil {
+		t.Fatal("expected the replay to be aborted")
+	}
+
+	// Leave a record half written as well.
+	segments, _ := filepath.Glob(filepath.Join(dir, "*"+segmentExt))
+	last := segments[len(segments)-1]
+	f, _ := os.OpenFile(last, os.O_WRONLY|os.O_APPEND, 0)
+	f.Write([]byte{0, 0, 1, 0, 0xde, 0xad, 'c', 'p'})
+	f.Close()
+
+	var errs []error
+	inner2 := &flakyClient{}
+	bc, err := NewBufferedClient(inner2, BufferOptions{Dir: dir, ReplayInterval: time.Hour, OnError: func(err error) { errs = append(errs, err) }})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	defer bc.Close()
+	if err := bc.Drain(context.Background()); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+
+	// Every point arrived at least once, and nothing else did.
+	got := distinct(append(inner.written(), inner2.written()...))
+	sort.Strings(exp)
+	if fmt.Sprint(got) != fmt.Sprint(exp) {
+		t.Errorf("unexpected points.  expected %v, actual %v", exp, got)
+	}
+	if len(errs) != 1 {
+		t.Errorf("expected the partial record to be reported, got %v", errs)
+	}
+	if remaining, _ := filepath.Glob(filepath.Join(dir, "*"+segmentExt)); len(remaining) != 0 {
+		t.Errorf("unexpected segments left: %v", remaining)
+	}
+}
+
+func TestBufferedClient_Eviction(t *testing.T) {
+	var evicted int
+	inner := &flakyClient{down: true}
+	bc, _ := NewBufferedClient(inner, BufferOptions{
+		Dir:            t.TempDir(),
+		MaxDiskUsage:   1024,
+		SegmentSize:    256,
+		ReplayInterval: time.Hour,
+		OnError: func(err error) {
+			if errors.Is(err, ErrBufferEvicted) {
+				evicted++
+			}
+		},
+	})
+	defer bc.Close()
+
+	exp := writeNumbered(t, bc, 0, 100)
+	if evicted == 0 {
+		t.Fatal("expected segments to be evicted")
+	}
+	if n := bc.Buffered(); n > 1024 {
+		t.Errorf("unexpected buffered size.  expected at most %v, actual %v", 1024, n)
+	}
+
+	inner.setDown(false)
+	if err := bc.Drain(context.Background()); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	// The newest points were kept.
+	got := inner.written()
+	if len(got) == 0 || len(got) >= len(exp) || got[len(got)-1] != exp[len(exp)-1] {
+		t.Errorf("unexpected points: %v", got)
+	}
+}
+
+func TestBufferedClient_BackgroundReplay(t *testing.T) {
+	dir := t.TempDir()
+	inner := &flakyClient{down: true}
+	bc, _ := NewBufferedClient(inner, BufferOptions{Dir: dir, ReplayInterval: time.Hour})
+	exp := writeNumbered(t, bc, 0, 3)
+	bc.Close()
+
+	// The writes left on disk are replayed as soon as the server answers.
+	inner.setDown(false)
+	bc, _ = NewBufferedClient(inner, BufferOptions{Dir: dir, ReplayInterval: 10 * time.Millisecond})
+	defer bc.Close()
+	deadline := time.Now().Add(5 * time.Second)
+	for bc.Buffered() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := inner.written(); fmt.Sprint(got) != fmt.Sprint(exp) {
+		t.Errorf("unexpected points.  expected %v, actual %v", exp, got)
+	}
+}
+
+func TestReadRecord(t *testing.T) {
+	q, _ := openDiskQueue(t.TempDir(), 1<<20, 1<<20)
+	q.append([]byte("first"))
+	q.append([]byte("second"))
+	q.close()
+	data, _ := os.ReadFile(q.segments[0].path)
+
+	payload, n, err := readRecord(data)
+	if err != nil || string(payload) != "first" {
+		t.Fatalf("unexpected record: %q, %v", payload, err)
+	}
+	// Every truncation of the second record is detected.
+	for i := n + 1; i < len(data); i++ {
+		if _, _, err := readRecord(data[n:i]); err != io.ErrUnexpectedEOF {
+			t.Errorf("unexpected error for %d bytes.  expected %v, actual %v", i-n, io.ErrUnexpectedEOF, err)
+		}
+	}
+	// As is a corrupted byte.
+	data[len(data)-1] ^= 0xff
+	if _, _, err := readRecord(data[n:]); err != io.ErrUnexpectedEOF {
+		t.Errorf("unexpected error.  expected %v, actual %v", io.ErrUnexpectedEOF, err)
+	}
+}
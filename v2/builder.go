@@ -0,0 +1,152 @@
+package client
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+// PointBuilder builds a Point from its tags and fields added one at a time,
+// without the maps NewPoint takes. Tags and fields are kept sorted as they
+// are added. A PointBuilder can be reused for another point after Reset,
+// which keeps the memory allocated for the tags and fields.
+//
+//	pt, err := client.NewPointBuilder("cpu").
+//		Tag("host", host).
+//		Field("usage", 42.1).
+//		Time(t).
+//		Build()
+type PointBuilder struct {
+	opts PointOptions
+
+	name   string
+	tags   models.Tags
+	keys   []string
+	values []interface{}
+	time   time.Time
+
+	// skipped are the non-finite fields dropped with NonFiniteSkip.
+	skipped []string
+
+	// err is the first error of Tag or Field, returned by Build.
+	err error
+}
+
+// NewPointBuilder returns a PointBuilder for a point of measurement name. Field
+// values are converted like those of NewPoint.
+func NewPointBuilder(name string) *PointBuilder {
+	return PointOptions{}.NewPointBuilder(name)
+}
+
+// NewPointBuilder is like the NewPointBuilder function, with the field values
+// converted as set by o.
+func (o PointOptions) NewPointBuilder(name string) *PointBuilder {
+	return &PointBuilder{opts: o, name: name}
+}
+
+// Measurement sets the measurement of the point.
+func (b *PointBuilder) Measurement(name string) *PointBuilder {
+	b.name = name
+	return b
+}
+
+// Tag adds a tag to the point. Adding a tag key twice makes Build fail.
+func (b *PointBuilder) Tag(key, value string) *PointBuilder {
+	i := sort.Search(len(b.tags), func(i int) bool { return string(b.tags[i].Key) >= key })
+	if i < len(b.tags) && string(b.tags[i].Key) == key {
+		b.fail(fmt.Errorf("duplicate tag key %q", key))
+		return b
+	}
+
+	// Reuse the memory of a tag dropped by Reset, if there is one.
+	n := len(b.tags)
+	if n < cap(b.tags) {
+		b.tags = b.tags[:n+1]
+	} else {
+		b.tags = append(b.tags, models.Tag{})
+	}
+	spare := b.tags[n]
+	copy(b.tags[i+1:], b.tags[i:n])
+	b.tags[i] = models.Tag{Key: append(spare.Key[:0], key...), Value: append(spare.Value[:0], value...)}
+	return b
+}
+
+// Field adds a field to the point, replacing the value of a field of the
+// same key added before.
+func (b *PointBuilder) Field(key string, value interface{}) *PointBuilder {
+	if f, ok := floatValue(value); ok && (math.IsNaN(f) || math.IsInf(f, 0)) {
+		switch b.opts.NonFinite {
+		case NonFiniteSkip:
+			b.skipped = append(b.skipped, key)
+			return b
+		case NonFiniteReplace:
+			value = b.opts.Replacement
+		}
+	} else if v, ok, err := b.opts.convertField(key, value); err != nil {
+		b.fail(err)
+		return b
+	} else if ok {
+		value = v
+	}
+
+	i := sort.SearchStrings(b.keys, key)
+	if i < len(b.keys) && b.keys[i] == key {
+		b.values[i] = value
+		return b
+	}
+	b.keys = append(b.keys, "")
+	copy(b.keys[i+1:], b.keys[i:])
+	b.keys[i] = key
+	b.values = append(b.values, nil)
+	copy(b.values[i+1:], b.values[i:])
+	b.values[i] = value
+	return b
+}
+
+// Time sets the timestamp of the point. Without one, the server assigns its
+// local time upon reception, as for NewPoint.
+func (b *PointBuilder) Time(t time.Time) *PointBuilder {
+	b.time = t
+	return b
+}
+
+// Build returns the point, or the first error of the tags and fields added.
+// The builder can go on to build more points with the same tags and fields.
+func (b *PointBuilder) Build() (*Point, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	if len(b.keys) == 0 && len(b.skipped) > 0 {
+		skipped := append([]string(nil), b.skipped...)
+		sort.Strings(skipped)
+		return nil, &SkippedFieldsError{Measurement: b.name, Fields: skipped}
+	}
+	pt, err := models.NewPointFromSlices(b.name, b.tags, b.keys, b.values, b.time)
+	if err != nil {
+		return nil, err
+	}
+	return &Point{pt: pt}, nil
+}
+
+// Reset removes the tags, fields and timestamp, and the error, so that the
+// builder can build another point of the same measurement.
+func (b *PointBuilder) Reset() {
+	b.tags = b.tags[:0]
+	for i := range b.values {
+		b.values[i] = nil
+	}
+	b.keys = b.keys[:0]
+	b.values = b.values[:0]
+	b.skipped = b.skipped[:0]
+	b.time = time.Time{}
+	b.err = nil
+}
+
+func (b *PointBuilder) fail(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
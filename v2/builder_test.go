@@ -0,0 +1,112 @@
+package client
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestPointBuilder(t *testing.T) {
+	b := NewPointBuilder("cpu")
+	pt, err := b.Tag("region", "us west").Tag("host", "a").
+		Field("value", 1.5).Field("count", uint32(3)).Field("value", 2.5).
+		Time(time.Unix(1, 0)).
+		Build()
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if got, exp := pt.String(), `cpu,host=a,region=us\ west count=3u,value=2.5 1000000000`; got != exp {
+		t.Errorf("unexpected line.  expected %q, actual %q", exp, got)
+	}
+
+	// The point keeps its tags after the builder is reused.
+	b.Reset()
+	b.Tag("zone", "b").Tag("host", "c").Field("value", 1.0)
+	if _, err := b.Build(); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if got := pt.Tags(); got["host"] != "a" || got["region"] != "us west" || len(got) != 2 {
+		t.Errorf("unexpected tags of the first point: %v", got)
+	}
+
+	want, _ := NewPoint("mem", map[string]string{"host": "c", "zone": "b"}, map[string]interface{}{"value": 1.0})
+	pt, err = b.Measurement("mem").Build()
+	if err != nil || pt.String() != want.String() {
+		t.Errorf("unexpected point.  expected %v, actual %v, %v", want, pt, err)
+	}
+}
+
+func TestPointBuilder_Errors(t *testing.T) {
+	if _, err := NewPointBuilder("cpu").Tag("host", "a").Tag("host", "b").Field("value", 1).Build(); err == nil || err.Error() != `duplicate tag key "host"` {
+		t.Errorf("unexpected error for a duplicate tag: %v", err)
+	}
+	if _, err := NewPointBuilder("cpu").Tag("host", "a").Build(); err == nil {
+		t.Error("expected an error for a point without fields")
+	}
+	if _, err := NewPointBuilder("cpu").Field("value", math.NaN()).Build(); err == nil {
+		t.Error("expected an error for a NaN field")
+	}
+
+	var se *SkippedFieldsError
+	b := PointOptions{NonFinite: NonFiniteSkip}.NewPointBuilder("cpu")
+	if _, err := b.Field("value", math.Inf(1)).Build(); !errors.As(err, &se) || se.Measurement != "cpu" {
+		t.Errorf("unexpected error.  expected %T, actual %v", se, err)
+	}
+	b.Reset()
+	if pt, err := b.Field("value", math.Inf(1)).Field("ok", 1.0).Build(); err != nil || pt.String() != "cpu ok=1" {
+		t.Errorf("unexpected point: %v, %v", pt, err)
+	}
+}
+
+func benchmarkPointTags() ([]string, []string) {
+	var keys, values []string
+	for i := 0; i < 6; i++ {
+		keys = append(keys, "tag"+strconv.Itoa(i))
+		values = append(values, "value"+strconv.Itoa(i))
+	}
+	return keys, values
+}
+
+func BenchmarkNewPoint_6Tags10Fields(b *testing.B) {
+	tagKeys, tagValues := benchmarkPointTags()
+	now := time.Now()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		tags := make(map[string]string, len(tagKeys))
+		for j, k := range tagKeys {
+			tags[k] = tagValues[j]
+		}
+		fields := make(map[string]interface{}, 10)
+		for j := 0; j < 10; j++ {
+			fields[fieldNames[j]] = float64(j)
+		}
+		if _, err := NewPoint("cpu", tags, fields, now); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPointBuilder_6Tags10Fields(b *testing.B) {
+	tagKeys, tagValues := benchmarkPointTags()
+	now := time.Now()
+	builder := NewPointBuilder("cpu")
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		builder.Reset()
+		for j, k := range tagKeys {
+			builder.Tag(k, tagValues[j])
+		}
+		for j := 0; j < 10; j++ {
+			builder.Field(fieldNames[j], float64(j))
+		}
+		if _, err := builder.Time(now).Build(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+var fieldNames = []string{"f0", "f1", "f2", "f3", "f4", "f5", "f6", "f7", "f8", "f9"}
@@ -0,0 +1,334 @@
+package client
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+const (
+	// DefaultCacheTTL is the default time a CachingClient keeps a response.
+	DefaultCacheTTL = 10 * time.Second
+
+	// DefaultCacheSize is the default number of responses a CachingClient
+	// keeps.
+	DefaultCacheSize = 1000
+)
+
+// CacheOptions is the config data needed to create a CachingClient.
+type CacheOptions struct {
+	// TTL is how long a response is served from the cache, defaults to
+	// DefaultCacheTTL.
+	TTL time.Duration
+
+	// MaxEntries is the number of responses kept, the least recently used
+	// ones being evicted first. Defaults to DefaultCacheSize.
+	MaxEntries int
+
+	// Clock, if set, is the clock the TTL is measured with, defaults to the
+	// system clock.
+	Clock Clock
+}
+
+// CacheStats are the counters of a CachingClient.
+type CacheStats struct {
+	// Hits is the number of queries answered from the cache, Misses the
+	// number sent to the server, and Shared the number that waited for the
+	// same query already being sent rather than sending it again.
+	Hits   int64
+	Misses int64
+	Shared int64
+
+	// Inflight is the number of queries being sent to the server.
+	Inflight int
+
+	// Entries is the number of responses cached.
+	Entries int
+}
+
+// CacheStatsCollector is implemented by a StatsCollector that is told about
+// the queries of a CachingClient, when it is set on the HTTP client the
+// CachingClient queries with.
+type CacheStatsCollector interface {
+	// QueryCached is called for every query the CachingClient can cache,
+	// with a hit if it was answered from the cache, and shared if it
+	// waited for the same query already being sent.
+	QueryCached(hit, shared bool)
+}
+
+// CachingClient is a Client that caches the responses of the read-only
+// queries it sends, see NewCachingClient. It is safe for concurrent use.
+type CachingClient struct {
+	c     Client
+	ttl   time.Duration
+	max   int
+	clock Clock
+	stats CacheStatsCollector
+
+	mu       sync.Mutex
+	lru      *list.List
+	entries  map[string]*list.Element
+	inflight map[string]*cacheCall
+	counts   CacheStats
+}
+
+// cacheEntry is a cached response.
+type cacheEntry struct {
+	key     string
+	resp    *Response
+	expires time.Time
+}
+
+// cacheCall is a query being sent, which the same queries wait for.
+type cacheCall struct {
+	done chan struct{}
+	resp *Response
+	err  error
+}
+
+// NewCachingClient returns a CachingClient that queries with c and keeps the
+// successful responses of the queries for opts.TTL, so that the same queries
+// sent meanwhile, such as by the panels of dashboards refreshing together,
+// are answered without asking the server. The queries are the same if their
+// command, database, retention policy, epoch, parameters, headers and the
+// options that change their response are. A query sent while the same one is
+// waiting for its response waits for that response too, so that the server
+// gets it once.
+//
+// It is a read cache only: the queries that are not read-only, such as
+// DROP or SELECT INTO, are sent with c as they are and invalidate nothing,
+// so that a response may be out of date by up to TTL after a write. Chunked
+// queries are not cached either. Every caller gets a copy of the response,
+// which it may change. Writes and pings are sent with c, and closing the
+// CachingClient does not close c.
+func NewCachingClient(c Client, opts CacheOptions) (*CachingClient, error) {
+	if opts.TTL < 0 {
+		return nil, &ConfigError{Field: "TTL", Reason: "must not be negative"}
+	}
+	if opts.TTL == 0 {
+		opts.TTL = DefaultCacheTTL
+	}
+	if opts.MaxEntries < 0 {
+		return nil, &ConfigError{Field: "MaxEntries", Reason: "must not be negative"}
+	}
+	if opts.MaxEntries == 0 {
+		opts.MaxEntries = DefaultCacheSize
+	}
+	cc := &CachingClient{
+		c:        c,
+		ttl:      opts.TTL,
+		max:      opts.MaxEntries,
+		clock:    clockOrSystem(opts.Clock),
+		lru:      list.New(),
+		entries:  make(map[string]*list.Element),
+		inflight: make(map[string]*cacheCall),
+	}
+	if sc, ok := c.(statsClient); ok {
+		cc.stats, _ = sc.statsCollector().(CacheStatsCollector)
+	}
+	return cc, nil
+}
+
+// Stats returns the counters of the cache.
+func (cc *CachingClient) Stats() CacheStats {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	stats := cc.counts
+	stats.Inflight = len(cc.inflight)
+	stats.Entries = cc.lru.Len()
+	return stats
+}
+
+// Query is QueryContext without a context.
+func (cc *CachingClient) Query(q Query) (*Response, error) {
+	return cc.QueryContext(context.Background(), q)
+}
+
+// QueryContext returns the cached response of q, or sends q with the wrapped
+// client, bound to ctx if it supports it.
+func (cc *CachingClient) QueryContext(ctx context.Context, q Query) (*Response, error) {
+	if q.Chunked || !readOnly(q.Command) {
+		return queryContext(ctx, cc.c, q)
+	}
+	key, err := cacheKey(q)
+	if err != nil {
+		return queryContext(ctx, cc.c, q)
+	}
+
+	for {
+		cc.mu.Lock()
+		if e, ok := cc.entries[key]; ok {
+			entry := e.Value.(*cacheEntry)
+			if cc.clock.Now().Before(entry.expires) {
+				cc.lru.MoveToFront(e)
+				cc.counts.Hits++
+				cc.mu.Unlock()
+				cc.report(true, false)
+				return copyResponse(entry.resp), nil
+			}
+			cc.lru.Remove(e)
+			delete(cc.entries, key)
+		}
+		if call, ok := cc.inflight[key]; ok {
+			cc.counts.Shared++
+			cc.mu.Unlock()
+			cc.report(false, true)
+			select {
+			case <-call.done:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			// The query is sent again if it failed by the context of the
+			// caller that sent it.
+			if isContextError(call.err) && ctx.Err() == nil {
+				continue
+			}
+			if call.err != nil {
+				return nil, call.err
+			}
+			return copyResponse(call.resp), nil
+		}
+		call := &cacheCall{done: make(chan struct{})}
+		cc.inflight[key] = call
+		cc.counts.Misses++
+		cc.mu.Unlock()
+		cc.report(false, false)
+
+		call.resp, call.err = queryContext(ctx, cc.c, q)
+		if call.err == nil && call.resp != nil && call.resp.Error() != nil {
+			call.err = call.resp.Error()
+		}
+		cc.mu.Lock()
+		delete(cc.inflight, key)
+		if call.err == nil {
+			cc.add(key, call.resp)
+		}
+		cc.mu.Unlock()
+		close(call.done)
+		if call.err != nil {
+			return call.resp, call.err
+		}
+		return copyResponse(call.resp), nil
+	}
+}
+
+// add caches resp under key. cc.mu must be held.
+func (cc *CachingClient) add(key string, resp *Response) {
+	cc.entries[key] = cc.lru.PushFront(&cacheEntry{key: key, resp: resp, expires: cc.clock.Now().Add(cc.ttl)})
+	for cc.lru.Len() > cc.max {
+		oldest := cc.lru.Back()
+		cc.lru.Remove(oldest)
+		delete(cc.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+func (cc *CachingClient) report(hit, shared bool) {
+	if cc.stats != nil {
+		cc.stats.QueryCached(hit, shared)
+	}
+}
+
+// cacheKey returns the key of the response of q.
+func cacheKey(q Query) (string, error) {
+	b, err := json.Marshal(struct {
+		Command, Database, RetentionPolicy, Precision, Epoch string
+		Parameters                                           map[string]interface{}
+		Headers                                              map[string]string
+		Method                                               QueryMethod
+		FailOnPartial                                        bool
+		MaxRows, MaxSeries                                   int
+	}{q.Command, q.Database, q.RetentionPolicy, q.Precision, q.Epoch, q.Parameters, q.Headers, q.Method, q.FailOnPartial, q.MaxRows, q.MaxSeries})
+	return string(b), err
+}
+
+// isContextError reports whether err is that of a context done.
+func isContextError(err error) bool {
+	return err == context.Canceled || err == context.DeadlineExceeded
+}
+
+// copyResponse returns a copy of resp that shares nothing with it but the
+// values of its rows, which are not changed in place.
+func copyResponse(resp *Response) *Response {
+	if resp == nil {
+		return nil
+	}
+	cp := *resp
+	cp.Header = resp.Header.Clone()
+	cp.Results = make([]Result, len(resp.Results))
+	for i, r := range resp.Results {
+		cr := r
+		if r.Series != nil {
+			cr.Series = make([]models.Row, len(r.Series))
+			for j, row := range r.Series {
+				cr.Series[j] = copyRow(row)
+			}
+		}
+		if r.Messages != nil {
+			cr.Messages = make([]*Message, len(r.Messages))
+			for j, m := range r.Messages {
+				mc := *m
+				cr.Messages[j] = &mc
+			}
+		}
+		cp.Results[i] = cr
+	}
+	return &cp
+}
+
+// copyRow returns a copy of row.
+func copyRow(row models.Row) models.Row {
+	cp := row
+	if row.Tags != nil {
+		cp.Tags = make(map[string]string, len(row.Tags))
+		for k, v := range row.Tags {
+			cp.Tags[k] = v
+		}
+	}
+	cp.Columns = append([]string(nil), row.Columns...)
+	if row.Values != nil {
+		cp.Values = make([][]interface{}, len(row.Values))
+		for i, values := range row.Values {
+			cp.Values[i] = append([]interface{}(nil), values...)
+		}
+	}
+	return cp
+}
+
+// Ping pings with the wrapped client.
+func (cc *CachingClient) Ping(timeout time.Duration) (time.Duration, string, error) {
+	return cc.c.Ping(timeout)
+}
+
+// Write writes bp with the wrapped client.
+func (cc *CachingClient) Write(bp BatchPoints) error {
+	return cc.c.Write(bp)
+}
+
+// WriteContext writes bp with the wrapped client, bound to ctx if it supports
+// it.
+func (cc *CachingClient) WriteContext(ctx context.Context, bp BatchPoints) error {
+	return writeContext(ctx, cc.c, bp)
+}
+
+// QueryAsChunk sends q with the wrapped client, uncached.
+func (cc *CachingClient) QueryAsChunk(q Query) (*ChunkedResponse, error) {
+	return cc.c.QueryAsChunk(q)
+}
+
+// QueryAsChunkContext sends q with the wrapped client, uncached, bound to ctx
+// if it supports it.
+func (cc *CachingClient) QueryAsChunkContext(ctx context.Context, q Query) (*ChunkedResponse, error) {
+	if c, ok := cc.c.(ContextClient); ok {
+		return c.QueryAsChunkContext(ctx, q)
+	}
+	return cc.c.QueryAsChunk(q)
+}
+
+// Close does nothing, the wrapped client is left open.
+func (cc *CachingClient) Close() error {
+	return nil
+}
@@ -0,0 +1,220 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+// queryCounter answers every query with a series of its count, after gate is
+// closed if it is set.
+type queryCounter struct {
+	*batchRecorder
+	mu      sync.Mutex
+	queries []string
+	gate    chan struct{}
+	err     error
+}
+
+func (c *queryCounter) Query(q Query) (*Response, error) {
+	if c.gate != nil {
+		<-c.gate
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.queries = append(c.queries, q.Command)
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &Response{Results: []Result{{Series: []models.Row{{
+		Name:    "cpu",
+		Columns: []string{"time", "count"},
+		Values:  [][]interface{}{{int64(0), len(c.queries)}},
+	}}}}}, nil
+}
+
+func (c *queryCounter) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.queries)
+}
+
+func newTestCachingClient(t *testing.T, c Client, opts CacheOptions) *CachingClient {
+	t.Helper()
+	cc, err := NewCachingClient(c, opts)
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	return cc
+}
+
+func TestCachingClient_TTL(t *testing.T) {
+	qc := &queryCounter{batchRecorder: &batchRecorder{}}
+	clock := &replayClock{now: time.Unix(1700000000, 0)}
+	cc := newTestCachingClient(t, qc, CacheOptions{TTL: time.Minute, Clock: clock})
+
+	q := NewQuery("SELECT count(value) FROM cpu", "db0", "")
+	first, _ := cc.Query(q)
+	clock.now = clock.now.Add(59 * time.Second)
+	second, _ := cc.Query(q)
+	if qc.count() != 1 {
+		t.Fatalf("unexpected queries sent.  expected %v, actual %v", 1, qc.count())
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("unexpected cached response.  expected %v, actual %v", first, second)
+	}
+
+	// Other databases and epochs are other queries.
+	cc.Query(NewQuery("SELECT count(value) FROM cpu", "db1", ""))
+	cc.Query(NewQuery("SELECT count(value) FROM cpu", "db0", "s"))
+	if qc.count() != 3 {
+		t.Fatalf("unexpected queries sent.  expected %v, actual %v", 3, qc.count())
+	}
+
+	clock.now = clock.now.Add(time.Second)
+	third, _ := cc.Query(q)
+	if qc.count() != 4 {
+		t.Fatalf("unexpected queries sent after the TTL.  expected %v, actual %v", 4, qc.count())
+	}
+	if v := third.Results[0].Series[0].Values[0][1]; v != 4 {
+		t.Errorf("unexpected value.  expected %v, actual %v", 4, v)
+	}
+
+	exp := CacheStats{Hits: 1, Misses: 4, Entries: 3}
+	if stats := cc.Stats(); stats != exp {
+		t.Errorf("unexpected stats.  expected %+v, actual %+v", exp, stats)
+	}
+}
+
+func TestCachingClient_LRU(t *testing.T) {
+	qc := &queryCounter{batchRecorder: &batchRecorder{}}
+	cc := newTestCachingClient(t, qc, CacheOptions{MaxEntries: 2})
+
+	a := NewQuery("SELECT * FROM a", "db0", "")
+	b := NewQuery("SELECT * FROM b", "db0", "")
+	c := NewQuery("SELECT * FROM c", "db0", "")
+	cc.Query(a)
+	cc.Query(b)
+	cc.Query(a)
+	cc.Query(c) // evicts b, the least recently used
+	cc.Query(a)
+	if qc.count() != 3 {
+		t.Fatalf("unexpected queries sent.  expected %v, actual %v", 3, qc.count())
+	}
+	cc.Query(b)
+	if qc.count() != 4 {
+		t.Fatalf("unexpected queries sent for the evicted query.  expected %v, actual %v", 4, qc.count())
+	}
+	if n := cc.Stats().Entries; n != 2 {
+		t.Errorf("unexpected entries.  expected %v, actual %v", 2, n)
+	}
+}
+
+func TestCachingClient_Singleflight(t *testing.T) {
+	gate := make(chan struct{})
+	qc := &queryCounter{batchRecorder: &batchRecorder{}, gate: gate}
+	cc := newTestCachingClient(t, qc, CacheOptions{})
+
+	const n = 8
+	q := NewQuery("SELECT * FROM cpu", "db0", "")
+	resps := make([]*Response, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resps[i], _ = cc.Query(q)
+		}(i)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for stats := cc.Stats(); stats.Shared < n-1 || stats.Inflight != 1; stats = cc.Stats() {
+		if time.Now().After(deadline) {
+			t.Fatalf("unexpected stats.  expected %v shared queries, actual %+v", n-1, stats)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(gate)
+	wg.Wait()
+
+	if qc.count() != 1 {
+		t.Fatalf("unexpected queries sent.  expected %v, actual %v", 1, qc.count())
+	}
+	for i, resp := range resps {
+		if resp == nil || len(resp.Results) != 1 {
+			t.Fatalf("unexpected response %d.  expected a result, actual %v", i, resp)
+		}
+	}
+	resps[0].Results[0].Series[0].Values[0][1] = "changed"
+	if v := resps[1].Results[0].Series[0].Values[0][1]; v != 1 {
+		t.Errorf("unexpected shared value.  expected %v, actual %v", 1, v)
+	}
+}
+
+func TestCachingClient_Copy(t *testing.T) {
+	qc := &queryCounter{batchRecorder: &batchRecorder{}}
+	cc := newTestCachingClient(t, qc, CacheOptions{})
+
+	q := NewQuery("SELECT * FROM cpu", "db0", "")
+	resp, _ := cc.Query(q)
+	resp.Results[0].Series[0].Values[0][1] = "changed"
+	resp.Results[0].Series[0].Columns[0] = "changed"
+	resp.Results[0].Series = nil
+
+	cached, _ := cc.Query(q)
+	row := cached.Results[0].Series[0]
+	if row.Columns[0] != "time" || row.Values[0][1] != 1 {
+		t.Errorf("unexpected cached row.  expected the server's, actual %v", row)
+	}
+}
+
+func TestCachingClient_Bypass(t *testing.T) {
+	qc := &queryCounter{batchRecorder: &batchRecorder{}}
+	cc := newTestCachingClient(t, qc, CacheOptions{})
+
+	drop := NewQuery("DROP MEASUREMENT cpu", "db0", "")
+	cc.Query(drop)
+	cc.Query(drop)
+	if qc.count() != 2 {
+		t.Errorf("unexpected queries sent.  expected %v, actual %v", 2, qc.count())
+	}
+
+	chunked := NewQuery("SELECT * FROM cpu", "db0", "")
+	chunked.Chunked = true
+	cc.Query(chunked)
+	cc.Query(chunked)
+	if qc.count() != 4 {
+		t.Errorf("unexpected queries sent.  expected %v, actual %v", 4, qc.count())
+	}
+	if stats := cc.Stats(); stats != (CacheStats{}) {
+		t.Errorf("unexpected stats.  expected %+v, actual %+v", CacheStats{}, stats)
+	}
+}
+
+func TestCachingClient_Errors(t *testing.T) {
+	qc := &queryCounter{batchRecorder: &batchRecorder{}, err: errors.New("unavailable")}
+	cc := newTestCachingClient(t, qc, CacheOptions{})
+
+	q := NewQuery("SELECT * FROM cpu", "db0", "")
+	if _, err := cc.Query(q); err == nil {
+		t.Fatal("expected an error")
+	}
+	qc.err = nil
+	if _, err := cc.QueryContext(context.Background(), q); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if qc.count() != 2 {
+		t.Errorf("unexpected queries sent after an error.  expected %v, actual %v", 2, qc.count())
+	}
+
+	for _, opts := range []CacheOptions{{TTL: -time.Second}, {MaxEntries: -1}} {
+		var ce *ConfigError
+		if _, err := NewCachingClient(qc, opts); !errors.As(err, &ce) {
+			t.Errorf("unexpected error.  expected a *ConfigError, actual %v", err)
+		}
+	}
+}
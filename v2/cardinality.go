@@ -0,0 +1,198 @@
+package client
+
+import (
+	"sync"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+// CardinalityAction is what a CardinalityLimiter does with a point that has a
+// new value for a tag key over the limit.
+type CardinalityAction int
+
+const (
+	// CardinalityDropTag removes the tag from the point and adds its value
+	// as a string field of the same name, unless the point already has such
+	// a field, in which case the value is dropped.
+	CardinalityDropTag CardinalityAction = iota
+
+	// CardinalityRejectPoint drops the point.
+	CardinalityRejectPoint
+
+	// CardinalityKeep keeps the point as is, leaving it to OnExceeded to
+	// report the tag.
+	CardinalityKeep
+)
+
+// CardinalityLimiterConfig is the config data needed to create a
+// CardinalityLimiter.
+type CardinalityLimiterConfig struct {
+	// MaxValues is the number of distinct values of a tag key that are let
+	// through, it must be set.
+	MaxValues int
+
+	// Action is what happens to a point with a new value of a tag key that
+	// already has MaxValues values, defaults to CardinalityDropTag.
+	Action CardinalityAction
+
+	// OnExceeded, if set, is called with such a point, before Action is
+	// applied, and the key and value of the tag. It is called from AddPoint
+	// and must not block for long.
+	OnExceeded func(p *Point, key, value string)
+}
+
+// CardinalityLimiter guards against an explosion of the series cardinality,
+// such as from a request ID put in a tag, by tracking the distinct values of
+// every tag key of the points added to a batch, see
+// BatchPointsConfig.CardinalityLimiter. The values seen before a key reached
+// the limit keep being let through. Only hashes of the values are kept, at
+// most MaxValues of them per key. CardinalityLimiter is safe for concurrent
+// use by multiple goroutines, and can be shared by batches.
+type CardinalityLimiter struct {
+	maxValues  int
+	action     CardinalityAction
+	onExceeded func(*Point, string, string)
+
+	mu   sync.Mutex
+	keys map[string]map[uint64]struct{}
+}
+
+// NewCardinalityLimiter returns a CardinalityLimiter based on the given
+// config.
+func NewCardinalityLimiter(conf CardinalityLimiterConfig) (*CardinalityLimiter, error) {
+	if conf.MaxValues <= 0 {
+		return nil, &ConfigError{Field: "MaxValues", Reason: "must be positive"}
+	}
+	switch conf.Action {
+	case CardinalityDropTag, CardinalityRejectPoint, CardinalityKeep:
+	default:
+		return nil, &ConfigError{Field: "Action", Reason: "unknown cardinality action"}
+	}
+	return &CardinalityLimiter{
+		maxValues:  conf.MaxValues,
+		action:     conf.Action,
+		onExceeded: conf.OnExceeded,
+		keys:       make(map[string]map[uint64]struct{}),
+	}, nil
+}
+
+// Values returns the number of distinct values of the tag key that were let
+// through since the limiter was created or reset.
+func (l *CardinalityLimiter) Values(key string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.keys[key])
+}
+
+// Reset forgets the values seen, starting a new tracking window.
+func (l *CardinalityLimiter) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.keys = make(map[string]map[uint64]struct{})
+}
+
+// tagValue is a value of a tag key recorded by a CardinalityLimiter.
+type tagValue struct {
+	key  string
+	hash uint64
+}
+
+// allow reports whether the value of the tag key is one seen before or fits
+// within the limit, and records it if it is new, appending it to added.
+// l.mu must be held.
+func (l *CardinalityLimiter) allow(key, value []byte, added *[]tagValue) bool {
+	values, ok := l.keys[string(key)]
+	if !ok {
+		values = make(map[uint64]struct{})
+		l.keys[string(key)] = values
+	}
+	h := hashTagValue(value)
+	if _, ok := values[h]; ok {
+		return true
+	}
+	if len(values) >= l.maxValues {
+		return false
+	}
+	values[h] = struct{}{}
+	*added = append(*added, tagValue{key: string(key), hash: h})
+	return true
+}
+
+// forget removes the values added by apply for a point that was not added
+// to its batch after all.
+func (l *CardinalityLimiter) forget(added []tagValue) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, v := range added {
+		delete(l.keys[v.key], v.hash)
+	}
+}
+
+// apply returns p as changed by the action for the tags over the limit, or
+// nil if the point is rejected, along with the values it recorded.
+func (l *CardinalityLimiter) apply(p *Point) (*Point, []tagValue) {
+	if p == nil {
+		return nil, nil
+	}
+	// The tags are walked in the key of the point, and only parsed for
+	// those over the limit.
+	var over []int
+	var added []tagValue
+	i := 0
+	l.mu.Lock()
+	p.pt.ForEachTag(func(k, v []byte) bool {
+		if !l.allow(k, v, &added) {
+			over = append(over, i)
+		}
+		i++
+		return true
+	})
+	l.mu.Unlock()
+	if len(over) == 0 {
+		return p, added
+	}
+	tags := p.pt.Tags()
+
+	if l.onExceeded != nil {
+		for _, i := range over {
+			l.onExceeded(p, string(tags[i].Key), string(tags[i].Value))
+		}
+	}
+	switch l.action {
+	case CardinalityRejectPoint:
+		return nil, added
+	case CardinalityKeep:
+		return p, added
+	}
+
+	fields, err := p.pt.Fields()
+	if err != nil {
+		return p, added
+	}
+	kept := make(models.Tags, 0, len(tags)-len(over))
+	for i, t := range tags {
+		if len(over) > 0 && over[0] == i {
+			over = over[1:]
+			if _, ok := fields[string(t.Key)]; !ok {
+				fields[string(t.Key)] = string(t.Value)
+			}
+			continue
+		}
+		kept = append(kept, t)
+	}
+	pt, err := models.NewPoint(string(p.pt.Name()), kept, fields, p.pt.Time())
+	if err != nil {
+		return p, added
+	}
+	return &Point{pt: pt}, added
+}
+
+// hashTagValue returns the 64-bit FNV-1a hash of v.
+func hashTagValue(v []byte) uint64 {
+	h := uint64(14695981039346656037)
+	for _, c := range v {
+		h ^= uint64(c)
+		h *= 1099511628211
+	}
+	return h
+}
@@ -0,0 +1,215 @@
+package client
+
+import (
+	"errors"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestLimiter(t *testing.T, conf CardinalityLimiterConfig) *CardinalityLimiter {
+	t.Helper()
+	l, err := NewCardinalityLimiter(conf)
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	return l
+}
+
+func TestCardinalityLimiter_DropTag(t *testing.T) {
+	var exceeded []string
+	l := newTestLimiter(t, CardinalityLimiterConfig{
+		MaxValues: 2,
+		OnExceeded: func(p *Point, key, value string) {
+			exceeded = append(exceeded, key+"="+value)
+		},
+	})
+	bp, _ := NewBatchPoints(BatchPointsConfig{CardinalityLimiter: l})
+	for _, id := range []string{"a", "b", "c", "a", "d"} {
+		bp.AddPoint(mustPoint(t, "req", map[string]string{"host": "h", "id": id}, map[string]interface{}{"v": int64(1)}, time.Unix(0, 1)))
+	}
+	bp.AddPoints([]*Point{
+		mustPoint(t, "req", map[string]string{"id": "e"}, map[string]interface{}{"id": "taken"}, time.Unix(0, 2)),
+		nil,
+	})
+
+	var got []string
+	for _, p := range bp.Points() {
+		got = append(got, p.String())
+	}
+	exp := []string{
+		`req,host=h,id=a v=1i 1`,
+		`req,host=h,id=b v=1i 1`,
+		`req,host=h id="c",v=1i 1`,
+		`req,host=h,id=a v=1i 1`,
+		`req,host=h id="d",v=1i 1`,
+		`req id="taken" 2`,
+	}
+	if len(got) != len(exp) {
+		t.Fatalf("unexpected points.  expected %q, actual %q", exp, got)
+	}
+	for i := range exp {
+		if got[i] != exp[i] {
+			t.Errorf("unexpected point %d.  expected %v, actual %v", i, exp[i], got[i])
+		}
+	}
+	if len(exceeded) != 3 || exceeded[0] != "id=c" || exceeded[2] != "id=e" {
+		t.Errorf("unexpected exceeded tags: %q", exceeded)
+	}
+	if n := l.Values("id"); n != 2 {
+		t.Errorf("unexpected value count.  expected %v, actual %v", 2, n)
+	}
+
+	// A new window lets new values through again.
+	l.Reset()
+	bp.Reset()
+	bp.AddPoint(mustPoint(t, "req", map[string]string{"id": "z"}, map[string]interface{}{"v": int64(1)}, time.Unix(0, 1)))
+	if s := bp.Points()[0].String(); s != `req,id=z v=1i 1` {
+		t.Errorf("unexpected point.  expected %v, actual %v", `req,id=z v=1i 1`, s)
+	}
+}
+
+func TestCardinalityLimiter_Actions(t *testing.T) {
+	for _, tt := range []struct {
+		action CardinalityAction
+		exp    int
+	}{
+		{action: CardinalityRejectPoint, exp: 3},
+		{action: CardinalityKeep, exp: 10},
+	} {
+		calls := 0
+		l := newTestLimiter(t, CardinalityLimiterConfig{
+			MaxValues:  3,
+			Action:     tt.action,
+			OnExceeded: func(*Point, string, string) { calls++ },
+		})
+		bp, _ := NewBatchPoints(BatchPointsConfig{CardinalityLimiter: l})
+		for i := 0; i < 10; i++ {
+			bp.AddPoint(mustPoint(t, "req", map[string]string{"id": strconv.Itoa(i)}, map[string]interface{}{"v": int64(i)}, time.Unix(0, 1)))
+		}
+		if n := len(bp.Points()); n != tt.exp {
+			t.Errorf("unexpected point count for %d.  expected %v, actual %v", tt.action, tt.exp, n)
+		}
+		if calls != 7 {
+			t.Errorf("unexpected OnExceeded calls for %d.  expected %v, actual %v", tt.action, 7, calls)
+		}
+	}
+}
+
+func TestCardinalityLimiter_BoundedMemory(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode")
+	}
+	const maxValues = 1000
+	l := newTestLimiter(t, CardinalityLimiterConfig{MaxValues: maxValues, Action: CardinalityRejectPoint})
+	bp, _ := NewBatchPoints(BatchPointsConfig{CardinalityLimiter: l})
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	for i := 0; i < 1000000; i++ {
+		p, err := NewPoint("req", map[string]string{"id": "0e3c2a8a-" + strconv.Itoa(i)}, map[string]interface{}{"v": i})
+		if err != nil {
+			t.Fatal(err)
+		}
+		bp.AddPoint(p)
+	}
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+
+	if n := len(bp.Points()); n != maxValues {
+		t.Errorf("unexpected point count.  expected %v, actual %v", maxValues, n)
+	}
+	if n := l.Values("id"); n != maxValues {
+		t.Errorf("unexpected value count.  expected %v, actual %v", maxValues, n)
+	}
+	// The points kept and the hashes of their values are well under a
+	// megabyte, whereas a million values would take tens of megabytes.
+	if grown := int64(after.HeapAlloc) - int64(before.HeapAlloc); grown > 4<<20 {
+		t.Errorf("unexpected heap growth of %d bytes", grown)
+	}
+	runtime.KeepAlive(bp)
+}
+
+func TestCardinalityLimiter_Concurrent(t *testing.T) {
+	l := newTestLimiter(t, CardinalityLimiterConfig{MaxValues: 50, Action: CardinalityRejectPoint})
+	bp, _ := NewSafeBatchPoints(BatchPointsConfig{CardinalityLimiter: l})
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				bp.AddPoint(mustPoint(t, "req", map[string]string{"id": strconv.Itoa(g*100 + i)}, map[string]interface{}{"v": int64(i)}))
+			}
+		}(g)
+	}
+	wg.Wait()
+	if n := len(bp.Points()); n != 50 {
+		t.Errorf("unexpected point count.  expected %v, actual %v", 50, n)
+	}
+}
+
+func TestCardinalityLimiter_BatchFull(t *testing.T) {
+	l := newTestLimiter(t, CardinalityLimiterConfig{MaxValues: 2, Action: CardinalityRejectPoint})
+	point := func(host string) *Point {
+		return mustPoint(t, "cpu", map[string]string{"host": host}, map[string]interface{}{"v": int64(1)}, time.Unix(1, 0))
+	}
+	size := len(point("a").String()) + 1
+	bp, _ := NewBatchPoints(BatchPointsConfig{CardinalityLimiter: l, MaxBytes: size})
+
+	bp.AddPoint(point("a"))
+	if err := bp.AddPoint(point("b")); err != ErrBatchFull {
+		t.Fatalf("unexpected error.  expected %v, actual %v", ErrBatchFull, err)
+	}
+	// The point rejected as the batch is full does not count.
+	if n := l.Values("host"); n != 1 {
+		t.Errorf("unexpected values once the batch is full.  expected %v, actual %v", 1, n)
+	}
+
+	// It is added to the next batch, as would be a value of its own.
+	next, _ := NewBatchPoints(BatchPointsConfig{CardinalityLimiter: l, MaxBytes: 2 * size})
+	next.AddPoints([]*Point{point("b"), point("c")})
+	if n := len(next.Points()); n != 1 || next.Points()[0].Tags()["host"] != "b" {
+		t.Errorf("unexpected points of the next batch: %v", next.Points())
+	}
+}
+
+func TestCardinalityLimiter_RejectedPoint(t *testing.T) {
+	l := newTestLimiter(t, CardinalityLimiterConfig{MaxValues: 2, Action: CardinalityRejectPoint})
+	point := func(host, region string) *Point {
+		return mustPoint(t, "cpu", map[string]string{"host": host, "region": region}, map[string]interface{}{"v": int64(1)}, time.Unix(1, 0))
+	}
+	bp, _ := NewBatchPoints(BatchPointsConfig{CardinalityLimiter: l})
+	bp.AddPoints([]*Point{point("a", "eu"), point("b", "eu")})
+	// The host is over the limit, the region of the rejected point is not
+	// counted.
+	bp.AddPoint(point("c", "us"))
+	if n := l.Values("region"); n != 1 {
+		t.Errorf("unexpected values of region.  expected %v, actual %v", 1, n)
+	}
+	// So a new region remains within the limit.
+	bp.AddPoint(point("a", "ap"))
+	if n := len(bp.Points()); n != 3 {
+		t.Errorf("unexpected points.  expected %v, actual %v", 3, n)
+	}
+}
+
+func TestNewCardinalityLimiter_Invalid(t *testing.T) {
+	for _, tt := range []struct {
+		conf  CardinalityLimiterConfig
+		field string
+	}{
+		{conf: CardinalityLimiterConfig{}, field: "MaxValues"},
+		{conf: CardinalityLimiterConfig{MaxValues: 1, Action: 42}, field: "Action"},
+	} {
+		_, err := NewCardinalityLimiter(tt.conf)
+		var ce *ConfigError
+		if !errors.As(err, &ce) || ce.Field != tt.field {
+			t.Errorf("unexpected error.  expected %v, actual %v", tt.field, err)
+		}
+	}
+}
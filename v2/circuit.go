@@ -0,0 +1,291 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultFailureThreshold is the number of consecutive failures that
+	// opens a circuit breaker when CircuitBreaker.FailureThreshold is 0.
+	DefaultFailureThreshold = 5
+
+	// DefaultOpenDuration is how long a circuit breaker stays open when
+	// CircuitBreaker.OpenDuration is 0.
+	DefaultOpenDuration = 30 * time.Second
+)
+
+// ErrCircuitOpen is returned by the writes and queries of an HTTP client
+// whose circuit breaker is open, without a request being sent.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// CircuitBreaker configures the circuit breaker of an HTTP client. After
+// FailureThreshold consecutive writes or queries failed because the server
+// could not be reached or answered with a 5xx or 429 status, the breaker
+// opens and writes and queries fail with ErrCircuitOpen. Once OpenDuration
+// passed, HalfOpenProbes requests are let through: the breaker closes again
+// if they all succeed, and opens for another OpenDuration as soon as one
+// fails. A retried write counts once. Pings are never blocked, so that health
+// checks still reach the server.
+type CircuitBreaker struct {
+	// FailureThreshold, defaults to DefaultFailureThreshold.
+	FailureThreshold int
+
+	// OpenDuration, defaults to DefaultOpenDuration.
+	OpenDuration time.Duration
+
+	// HalfOpenProbes, defaults to 1.
+	HalfOpenProbes int
+}
+
+// CircuitState is the state of a circuit breaker.
+type CircuitState int
+
+const (
+	// CircuitClosed lets every request through.
+	CircuitClosed CircuitState = iota
+
+	// CircuitOpen fails every request with ErrCircuitOpen.
+	CircuitOpen
+
+	// CircuitHalfOpen lets the probe requests through.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// CircuitStatsCollector is implemented by a StatsCollector that is told about
+// the state changes of the circuit breaker of the HTTP client it is set on.
+type CircuitStatsCollector interface {
+	// CircuitStateChanged is called when the breaker goes from one state
+	// to another.
+	CircuitStateChanged(from, to CircuitState)
+}
+
+// CircuitStater is implemented by the HTTP client.
+type CircuitStater interface {
+	// CircuitState returns the state of the client's circuit breaker, which
+	// is always CircuitClosed without a CircuitBreaker configured.
+	CircuitState() CircuitState
+}
+
+// CircuitState returns the state of the client's circuit breaker.
+func (c *client) CircuitState() CircuitState {
+	return c.breaker.currentState()
+}
+
+// breaker implements a CircuitBreaker. A nil *breaker lets every request
+// through.
+type breaker struct {
+	threshold    int
+	openDuration time.Duration
+	probes       int
+
+	// notify, if set, is called after every state change, without mu held.
+	notify func(from, to CircuitState)
+
+	// now is replaced by tests.
+	now func() time.Time
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+
+	// inFlight and succeeded count the probes of the half-open state.
+	inFlight  int
+	succeeded int
+}
+
+// newBreaker returns the breaker of conf on clock, or nil if conf is nil.
+func newBreaker(conf *CircuitBreaker, stats StatsCollector, clock Clock) (*breaker, error) {
+	if conf == nil {
+		return nil, nil
+	}
+	switch {
+	case conf.FailureThreshold < 0:
+		return nil, &ConfigError{Field: "CircuitBreaker", Reason: "negative failure threshold"}
+	case conf.OpenDuration < 0:
+		return nil, &ConfigError{Field: "CircuitBreaker", Reason: "negative open duration"}
+	case conf.HalfOpenProbes < 0:
+		return nil, &ConfigError{Field: "CircuitBreaker", Reason: "negative number of half-open probes"}
+	}
+
+	b := &breaker{
+		threshold:    conf.FailureThreshold,
+		openDuration: conf.OpenDuration,
+		probes:       conf.HalfOpenProbes,
+		now:          clock.Now,
+	}
+	if b.threshold == 0 {
+		b.threshold = DefaultFailureThreshold
+	}
+	if b.openDuration == 0 {
+		b.openDuration = DefaultOpenDuration
+	}
+	if b.probes == 0 {
+		b.probes = 1
+	}
+	if cs, ok := stats.(CircuitStatsCollector); ok {
+		b.notify = cs.CircuitStateChanged
+	}
+	return b, nil
+}
+
+func (b *breaker) currentState() CircuitState {
+	if b == nil {
+		return CircuitClosed
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == CircuitOpen && b.now().Sub(b.openedAt) >= b.openDuration {
+		// No probe was let through yet, but the next request will be.
+		return CircuitHalfOpen
+	}
+	return b.state
+}
+
+// allow returns ErrCircuitOpen if a request may not be sent. Otherwise the
+// outcome of the request must be reported with done, passing it probe.
+func (b *breaker) allow() (probe bool, err error) {
+	if b == nil {
+		return false, nil
+	}
+	b.mu.Lock()
+	from := b.state
+	if b.state == CircuitOpen {
+		if b.now().Sub(b.openedAt) < b.openDuration {
+			b.mu.Unlock()
+			return false, ErrCircuitOpen
+		}
+		b.state, b.inFlight, b.succeeded = CircuitHalfOpen, 0, 0
+	}
+	if b.state == CircuitClosed {
+		b.mu.Unlock()
+		return false, nil
+	}
+	probe = b.inFlight+b.succeeded < b.probes
+	if probe {
+		b.inFlight++
+	}
+	to := b.state
+	b.mu.Unlock()
+
+	b.changed(from, to)
+	if !probe {
+		return false, ErrCircuitOpen
+	}
+	return true, nil
+}
+
+// done reports the outcome of a request allowed by allow.
+func (b *breaker) done(probe bool, err error) {
+	if b == nil {
+		return
+	}
+	result := breakerOutcome(err)
+
+	b.mu.Lock()
+	from := b.state
+	switch {
+	case b.state == CircuitClosed && !probe:
+		switch result {
+		case outcomeSuccess:
+			b.failures = 0
+		case outcomeFailure:
+			b.failures++
+			if b.failures >= b.threshold {
+				b.open()
+			}
+		}
+	case b.state == CircuitHalfOpen && probe:
+		b.inFlight--
+		switch result {
+		case outcomeSuccess:
+			b.succeeded++
+			if b.succeeded >= b.probes {
+				b.state, b.failures = CircuitClosed, 0
+			}
+		case outcomeFailure:
+			b.open()
+		}
+	}
+	// Other requests ended after the state they were sent in, and cannot
+	// tell anything about the current one.
+	to := b.state
+	b.mu.Unlock()
+	b.changed(from, to)
+}
+
+// open opens the breaker. It must be called with mu held.
+func (b *breaker) open() {
+	b.state, b.openedAt, b.failures = CircuitOpen, b.now(), 0
+}
+
+func (b *breaker) changed(from, to CircuitState) {
+	if from != to && b.notify != nil {
+		b.notify(from, to)
+	}
+}
+
+type outcome int
+
+const (
+	outcomeSuccess outcome = iota
+	outcomeFailure
+
+	// outcomeNeutral is a request that tells nothing about the health of
+	// the server, such as one canceled by the caller.
+	outcomeNeutral
+)
+
+// breakerOutcome classifies the error of a write or query. Errors reported by
+// a server that is otherwise working, such as a 400 status or a partial
+// write, are not failures.
+func breakerOutcome(err error) outcome {
+	if err == nil {
+		return outcomeSuccess
+	}
+	if errors.Is(err, context.Canceled) {
+		return outcomeNeutral
+	}
+	var er *ErrorResponse
+	if errors.As(err, &er) {
+		if er.StatusCode >= http.StatusInternalServerError || er.StatusCode == http.StatusTooManyRequests {
+			return outcomeFailure
+		}
+		return outcomeSuccess
+	}
+	var (
+		pe *PartialWriteError
+		se *StatementError
+		re *RedirectError
+		pr *PartialResultError
+	)
+	if errors.As(err, &pe) || errors.As(err, &se) || errors.As(err, &re) || errors.As(err, &pr) {
+		return outcomeSuccess
+	}
+	// Errors found before the request was sent.
+	var (
+		ve *ValidationError
+		le *LineError
+		ce *ConfigError
+	)
+	if errors.As(err, &ve) || errors.As(err, &le) || errors.As(err, &ce) {
+		return outcomeNeutral
+	}
+	return outcomeFailure
+}
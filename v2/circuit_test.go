@@ -0,0 +1,184 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// circuitStats records the state changes of a circuit breaker.
+type circuitStats struct {
+	recordingStats
+	changes []string
+}
+
+func (s *circuitStats) CircuitStateChanged(from, to CircuitState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.changes = append(s.changes, fmt.Sprintf("%v->%v", from, to))
+}
+
+func TestClient_CircuitBreaker(t *testing.T) {
+	var status, requests, pings int32
+	atomic.StoreInt32(&status, http.StatusServiceUnavailable)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ping" {
+			atomic.AddInt32(&pings, 1)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(int(atomic.LoadInt32(&status)))
+		_ = json.NewEncoder(w).Encode(Response{})
+	}))
+	defer ts.Close()
+
+	stats := &circuitStats{}
+	c, err := NewHTTPClient(HTTPConfig{
+		Addr:           ts.URL,
+		Stats:          stats,
+		CircuitBreaker: &CircuitBreaker{FailureThreshold: 3, OpenDuration: time.Minute},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	defer c.Close()
+	now := time.Unix(0, 0)
+	c.(*client).breaker.now = func() time.Time { return now }
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0"})
+	bp.AddPoints(newTestPoints(1))
+	for i := 0; i < 2; i++ {
+		if err := c.Write(bp); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("unexpected error for write %d: %v", i, err)
+		}
+	}
+	if _, err := c.Query(Query{Command: "SHOW DATABASES"}); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("unexpected error for the query: %v", err)
+	}
+	if state := c.(CircuitStater).CircuitState(); state != CircuitOpen {
+		t.Fatalf("unexpected state.  expected %v, actual %v", CircuitOpen, state)
+	}
+
+	// The open breaker fails without a request, but lets pings through.
+	if err := c.Write(bp); err != ErrCircuitOpen {
+		t.Errorf("unexpected error.  expected %v, actual %v", ErrCircuitOpen, err)
+	}
+	if _, err := c.Query(Query{Command: "SHOW DATABASES"}); err != ErrCircuitOpen {
+		t.Errorf("unexpected error.  expected %v, actual %v", ErrCircuitOpen, err)
+	}
+	if _, err := c.QueryAsChunk(Query{Command: "SHOW DATABASES"}); err != ErrCircuitOpen {
+		t.Errorf("unexpected error.  expected %v, actual %v", ErrCircuitOpen, err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 3 {
+		t.Errorf("unexpected number of requests.  expected %v, actual %v", 3, n)
+	}
+	if _, _, err := c.Ping(0); err != nil || atomic.LoadInt32(&pings) != 1 {
+		t.Errorf("unexpected ping: %v", err)
+	}
+
+	// A failed probe opens the breaker again.
+	now = now.Add(time.Minute)
+	if state := c.(CircuitStater).CircuitState(); state != CircuitHalfOpen {
+		t.Fatalf("unexpected state.  expected %v, actual %v", CircuitHalfOpen, state)
+	}
+	if err := c.Write(bp); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("unexpected error for the probe: %v", err)
+	}
+	if err := c.Write(bp); err != ErrCircuitOpen {
+		t.Errorf("unexpected error.  expected %v, actual %v", ErrCircuitOpen, err)
+	}
+
+	// A successful one closes it.
+	atomic.StoreInt32(&status, http.StatusNoContent)
+	now = now.Add(time.Minute)
+	if err := c.Write(bp); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if state := c.(CircuitStater).CircuitState(); state != CircuitClosed {
+		t.Errorf("unexpected state.  expected %v, actual %v", CircuitClosed, state)
+	}
+
+	exp := []string{"closed->open", "open->half-open", "half-open->open", "open->half-open", "half-open->closed"}
+	if fmt.Sprint(stats.changes) != fmt.Sprint(exp) {
+		t.Errorf("unexpected state changes.  expected %v, actual %v", exp, stats.changes)
+	}
+}
+
+func TestClient_CircuitBreakerClientErrors(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Influxdb-Error", "unable to parse")
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, CircuitBreaker: &CircuitBreaker{FailureThreshold: 1}})
+	defer c.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0"})
+	bp.AddPoints(newTestPoints(1))
+	for i := 0; i < 3; i++ {
+		if err := c.Write(bp); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("unexpected error for write %d: %v", i, err)
+		}
+	}
+	if state := c.(CircuitStater).CircuitState(); state != CircuitClosed {
+		t.Errorf("unexpected state.  expected %v, actual %v", CircuitClosed, state)
+	}
+}
+
+func TestBreaker_HalfOpenProbes(t *testing.T) {
+	b, _ := newBreaker(&CircuitBreaker{FailureThreshold: 1, HalfOpenProbes: 2}, nil, systemClock{})
+	now := time.Unix(0, 0)
+	b.now = func() time.Time { return now }
+	errDown := errors.New("connection refused")
+
+	probe, _ := b.allow()
+	b.done(probe, errDown)
+	now = now.Add(DefaultOpenDuration)
+
+	first, err1 := b.allow()
+	second, err2 := b.allow()
+	if !first || !second || err1 != nil || err2 != nil {
+		t.Fatalf("unexpected probes: %v, %v, %v, %v", first, second, err1, err2)
+	}
+	if _, err := b.allow(); err != ErrCircuitOpen {
+		t.Errorf("unexpected error for a third request.  expected %v, actual %v", ErrCircuitOpen, err)
+	}
+	b.done(first, nil)
+	if state := b.currentState(); state != CircuitHalfOpen {
+		t.Errorf("unexpected state after one probe.  expected %v, actual %v", CircuitHalfOpen, state)
+	}
+	b.done(second, nil)
+	if state := b.currentState(); state != CircuitClosed {
+		t.Errorf("unexpected state after two probes.  expected %v, actual %v", CircuitClosed, state)
+	}
+}
+
+func TestBreakerOutcome(t *testing.T) {
+	for _, tt := range []struct {
+		err error
+		exp outcome
+	}{
+		{nil, outcomeSuccess},
+		{errors.New("connection refused"), outcomeFailure},
+		{&ErrorResponse{StatusCode: http.StatusServiceUnavailable}, outcomeFailure},
+		{&ErrorResponse{StatusCode: http.StatusTooManyRequests}, outcomeFailure},
+		{&RetryError{Err: &ErrorResponse{StatusCode: http.StatusInternalServerError}}, outcomeFailure},
+		{&ErrorResponse{StatusCode: http.StatusBadRequest}, outcomeSuccess},
+		{&PartialWriteError{ErrorResponse: ErrorResponse{StatusCode: http.StatusBadRequest}}, outcomeSuccess},
+		{context.DeadlineExceeded, outcomeFailure},
+		{context.Canceled, outcomeNeutral},
+	} {
+		if got := breakerOutcome(tt.err); got != tt.exp {
+			t.Errorf("unexpected outcome for %v.  expected %v, actual %v", tt.err, tt.exp, got)
+		}
+	}
+}
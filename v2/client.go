@@ -4,18 +4,23 @@ package client // import "github.com/influxdata/influxdb1-client/v2"
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
 	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"io/ioutil"
+	"math"
 	"mime"
 	"net/http"
 	"net/url"
 	"path"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/influxdata/influxdb1-client/models"
@@ -31,34 +36,288 @@ const (
 // HTTPConfig is the config data needed to create an HTTP Client.
 type HTTPConfig struct {
 	// Addr should be of the form "http://host:port"
-	// or "http://[ipv6-host%zone]:port".
+	// or "http://[ipv6-host%zone]:port", or "unix:///path/to/influxd.sock"
+	// to connect over a unix domain socket. A path, such as in
+	// "https://gateway.example/influx", is the base of the endpoints of a
+	// server behind a reverse proxy. Without a scheme the address is
+	// "host[:port][/path]", with https if TLSConfig is set and
+	// DefaultHTTPPort if no port is given, and the host may be a bare IPv6
+	// address. An invalid address is a *ConfigError.
 	Addr string
 
+	// WriteAddr and QueryAddr, if set, are the addresses of the /write
+	// endpoint and of the others, /query and /ping among them, in place of
+	// Addr, as when writes go to an ingest proxy and queries to a read
+	// replica. They are of the form of Addr, unix sockets excepted, and
+	// Addr can be left empty if both are set.
+	WriteAddr string
+	QueryAddr string
+
+	// WriteEndpoint and QueryEndpoint override the TLS and auth settings
+	// of the requests to WriteAddr and QueryAddr, see EndpointConfig.
+	WriteEndpoint EndpointConfig
+	QueryEndpoint EndpointConfig
+
+	// PingBothEndpoints makes Ping probe the write endpoint as well as the
+	// query one, when they differ, and report the slower of the two or the
+	// first error.
+	PingBothEndpoints bool
+
+	// UnixSocketHost is the Host header sent over a unix domain socket,
+	// defaults to DefaultUnixSocketHost.
+	UnixSocketHost string
+
 	// Username is the influxdb username, optional.
 	Username string
 
 	// Password is the influxdb password, optional.
 	Password string
 
-	// UserAgent is the http User Agent, defaults to "InfluxDBClient".
+	// AuthToken is sent as "Authorization: Token <AuthToken>" instead of
+	// basic auth, optional. It is either "username:password" for InfluxDB
+	// 1.8 or a token for the 2.x compatibility API, and cannot be combined
+	// with Username and Password.
+	AuthToken string
+
+	// AuthViaParams sends Username and Password as the u and p parameters
+	// of the URL instead of with basic auth, for proxies that drop the
+	// Authorization header. The password is redacted from the URLs of the
+	// errors returned. It cannot be combined with AuthToken.
+	AuthViaParams bool
+
+	// CredentialsProvider, if set, returns the credentials of the requests
+	// in place of Username, Password and AuthToken, which must be empty,
+	// for credentials that rotate while the client is in use. They are
+	// cached for CredentialsTTL. A request the server rejects with 401
+	// Unauthorized has the provider called again right away and, if the
+	// credentials changed, is sent once more with them before the error is
+	// returned; a streamed write, whose body cannot be sent again, is not.
+	CredentialsProvider CredentialsProvider
+
+	// CredentialsTTL is how long the credentials of CredentialsProvider are
+	// cached, defaults to DefaultCredentialsTTL.
+	CredentialsTTL time.Duration
+
+	// UserAgent is the http User Agent, defaults to
+	// "influxdb1-client/<Version> (go/<go version>)".
 	UserAgent string
 
+	// ClientID, if set, is sent as the X-Client-Id header of every request,
+	// so that the services sharing a UserAgent can be told apart in the
+	// logs of the server.
+	ClientID string
+
+	// Headers are sent with every request, such as the header a gateway
+	// selects the tenant with. Query.Headers and WriteWithHeaders add to
+	// them per request. Authorization, Content-Length, and Content-Encoding
+	// with gzip encoded writes, are set by the client and cannot be.
+	Headers map[string]string
+
 	// Timeout for influxdb writes, defaults to no timeout.
 	Timeout time.Duration
 
+	// ChunkReadTimeout makes NextResponse of a response of QueryAsChunk
+	// fail with a *ChunkReadTimeoutError and close the response when the
+	// server sends nothing for that long, as when it hangs with the
+	// connection open. Query.ChunkReadTimeout overrides it. Defaults to no
+	// timeout.
+	ChunkReadTimeout time.Duration
+
 	// InsecureSkipVerify gets passed to the http client, if true, it will
-	// skip https certificate verification. Defaults to false.
+	// skip https certificate verification. Defaults to false. Ignored when
+	// Transport is set.
 	InsecureSkipVerify bool
 
 	// TLSConfig allows the user to set their own TLS config for the HTTP
-	// Client. If set, this option overrides InsecureSkipVerify.
+	// Client. If set, this option overrides InsecureSkipVerify. Ignored when
+	// Transport is set. It can be replaced by ReloadTLS, see TLSReloader.
 	TLSConfig *tls.Config
 
+	// CACertPEM, or the file at CACertFile, holds the PEM certificates of
+	// the authorities the server certificate is verified with, in place of
+	// the system roots. PinnedCertSHA256 are the SHA-256 hashes of public
+	// keys, the SubjectPublicKeyInfo of a certificate, in hex or base64: the
+	// certificate of the server, or of an authority of its verified chain,
+	// must have one of them, on top of the verification of the chain, which
+	// InsecureSkipVerify skips. ClientCertPEM and ClientKeyPEM are the
+	// PEM certificate and key the client authenticates with. These build
+	// the TLS config, and cannot be combined with TLSConfig. A malformed
+	// value is a *ConfigError.
+	CACertPEM        []byte
+	CACertFile       string
+	PinnedCertSHA256 []string
+	ClientCertPEM    []byte
+	ClientKeyPEM     []byte
+
 	// Proxy configures the Proxy function on the HTTP client.
 	Proxy func(req *http.Request) (*url.URL, error)
 
+	// Transport, if set, is used as is to send every request. In that case
+	// InsecureSkipVerify, TLSConfig, Proxy and MaxIdleConnsPerHost are
+	// ignored and must be configured on the Transport itself.
+	Transport http.RoundTripper
+
+	// DisableKeepAlives sends every request with "Connection: close" and
+	// opens a new connection for each, so that a load balancer spreading
+	// connections over several nodes spreads the requests too.
+	DisableKeepAlives bool
+
+	// ConnectionRecycleInterval, if set, closes the idle connections every
+	// interval, so that the requests that follow open new ones, spread over
+	// the nodes behind a load balancer, while keeping them alive between
+	// requests sent close to each other. Connections in use are left open.
+	ConnectionRecycleInterval time.Duration
+
+	// MaxIdleConnsPerHost is the number of idle connections kept open to
+	// the server, defaults to http.DefaultMaxIdleConnsPerHost, 2. Writing
+	// from more goroutines, as WriteParallel does, needs more for their
+	// connections to be reused.
+	MaxIdleConnsPerHost int
+
 	// WriteEncoding specifies the encoding of write request
 	WriteEncoding ContentEncoding
+
+	// WriteCompressionLevel is the gzip compression level used when
+	// WriteEncoding is GzipEncoding, defaults to gzip.DefaultCompression.
+	WriteCompressionLevel int
+
+	// ResponseFormat is the format query results are requested in, defaults
+	// to JSONFormat.
+	ResponseFormat ResponseFormat
+
+	// UintEncoding is how unsigned integer fields are written, defaults to
+	// UintUnsigned.
+	UintEncoding UintEncoding
+
+	// NumberDecoding selects the type of the numeric values of query
+	// results, chunked or not, defaults to JSONNumberDecoding.
+	NumberDecoding NumberDecoding
+
+	// AcceptGzip asks for gzip compressed query responses with the
+	// Accept-Encoding header, for large results over slow links. A query
+	// response the server compressed is decompressed either way, including
+	// when the header is set through Headers or Query.Headers.
+	AcceptGzip bool
+
+	// StrictResponses makes a query fail when the server answers with a
+	// content type other than JSON, or MessagePack if ResponseFormat asks
+	// for it, or with a JSON response holding neither results nor an error,
+	// as a page or an empty object sent by a proxy. The keys of a JSON
+	// response, and of its results, unknown to Response are reported to
+	// Logger instead of being ignored silently.
+	StrictResponses bool
+
+	// UseV2CompatWrite sends writes to the /api/v2/write compatibility
+	// endpoint of InfluxDB 1.8 and later instead of /write. Write
+	// consistency is not supported there and only the ns, us, ms and s
+	// precisions are accepted.
+	UseV2CompatWrite bool
+
+	// Bucket is the bucket written to with UseV2CompatWrite. It defaults to
+	// "database/retention-policy" of each BatchPoints, or to the database
+	// alone if no retention policy is set.
+	Bucket string
+
+	// Org is the organization written to with UseV2CompatWrite, defaults to
+	// "-" which InfluxDB 1.8 ignores.
+	Org string
+
+	// MaxRetries is the number of times a write is retried after a network
+	// error, a 429 or a 5xx response. Other 4xx responses are never retried.
+	// Defaults to 0, which disables retries.
+	MaxRetries int
+
+	// RetryInterval is the delay before the first retry, doubled on every
+	// following attempt, defaults to DefaultRetryInterval. The delay a 429
+	// or 5xx response asks for takes precedence, see ThrottledError.Delay.
+	RetryInterval time.Duration
+
+	// MaxRetryInterval caps the delay between retries, including the one a
+	// response asks for, defaults to DefaultMaxRetryInterval.
+	MaxRetryInterval time.Duration
+
+	// SplitTooLarge makes a write rejected with 413 Payload Too Large, as
+	// by a proxy limiting the size of the requests, be cut in halves that
+	// are written in turn, those rejected again being cut in turn down to
+	// MinSplitSize points, which defaults to 1. The points of the
+	// fragments that still fail make a *WriteError, holding ErrPointTooLarge
+	// for a point too large on its own. See SplitStatsCollector.
+	SplitTooLarge bool
+	MinSplitSize  int
+
+	// Stats, if set, is told about every write attempt and query.
+	Stats StatsCollector
+
+	// Logger, if set, is told about retried writes and failures to read a
+	// chunked response. Nothing is logged by default.
+	Logger Logger
+
+	// ValidateRawWrites makes WriteRaw parse the line protocol it is given
+	// and reject the body if a line is invalid, instead of leaving that to
+	// the server.
+	ValidateRawWrites bool
+
+	// MaxRedirects is the number of redirects, such as the 307 of a relay
+	// sending writes to another node, followed for a request. Defaults to
+	// DefaultMaxRedirects; a negative value disables following redirects.
+	// Redirects back to a location already visited or from https to http
+	// fail with a *RedirectError.
+	MaxRedirects int
+
+	// RateLimit, if set, limits the points and bytes written per second.
+	// Streamed writes are not limited, as their size is only known once
+	// they are sent.
+	RateLimit *RateLimit
+
+	// CircuitBreaker, if set, makes writes and queries fail right away
+	// with ErrCircuitOpen while the server keeps failing. A Stats that
+	// implements CircuitStatsCollector is told about its state changes.
+	CircuitBreaker *CircuitBreaker
+
+	// Clock, if set, is the clock of the delays between retries, of the
+	// RateLimit and of the CircuitBreaker, defaults to the system clock.
+	Clock Clock
+
+	// ValidatePoints makes Write validate the points of a batch with
+	// BatchPoints.Validate and reject the batch if one is invalid. It is off
+	// by default, as it parses the fields of every point again.
+	ValidatePoints bool
+
+	// Tracer, if set, starts a span around every write, query and ping.
+	Tracer Tracer
+
+	// DrainTimeout is how long Close waits for the requests in progress to
+	// finish, a request of QueryAsChunk or QueryStream lasting until its
+	// response is closed. Defaults to DefaultDrainTimeout.
+	DrainTimeout time.Duration
+
+	// WriteJournal, if set, remembers the writes the server acknowledged,
+	// which are skipped when written again, and those that timed out, which
+	// are retried or not as it is configured. Closing the client does not
+	// close it.
+	WriteJournal *WriteJournal
+
+	// DumpRequest, if set, is called with every request once it was sent,
+	// or failed to be, for debugging what the server got: the exact URL
+	// with its parameters, the headers and the first DumpBodySize bytes of
+	// the body, as sent, thus compressed with WriteEncoding. The password of
+	// AuthViaParams and the credentials of the Authorization headers are
+	// redacted. DumpResponse, if set, is called with the status, headers
+	// and first DumpBodySize bytes of every response, as received, once its
+	// body was read to the end or closed. Bodies are only kept when these
+	// are set. They are called by the goroutines sending the requests and
+	// reading the responses.
+	DumpRequest  func(method, url string, headers http.Header, body []byte)
+	DumpResponse func(status int, headers http.Header, body []byte)
+
+	// DumpBodySize is the number of bytes of the bodies that are dumped,
+	// defaults to DefaultDumpBodySize.
+	DumpBodySize int
+
+	// MaxErrorBodySize is the number of bytes of the body of a response
+	// kept in the Body of its ErrorResponse, defaults to
+	// DefaultMaxErrorBodySize.
+	MaxErrorBodySize int
 }
 
 // BatchPointsConfig is the config data needed to create an instance of the BatchPoints struct.
@@ -72,10 +331,96 @@ type BatchPointsConfig struct {
 	// RetentionPolicy is the retention policy of the points.
 	RetentionPolicy string
 
-	// Write consistency is the number of servers required to confirm write.
+	// Write consistency is the number of servers required to confirm write,
+	// one of the Consistency constants in any case, or empty to leave it to
+	// the server.
 	WriteConsistency string
+
+	// SortOnWrite makes Points return the points sorted by series and
+	// time, so that they are written in that order, see BatchPoints.Sort.
+	SortOnWrite bool
+
+	// SortFields makes the writes of the batch, and WriteTo, serialize the
+	// fields of every point sorted by key, so that the same point is always
+	// written as the same bytes. The points created by this package already
+	// have their fields sorted; those parsed from line protocol, as by
+	// ParsePoints, keep the order of the line. The points are not modified.
+	SortFields bool
+
+	// CardinalityLimiter, if set, is applied to the points added to the
+	// batch, see CardinalityLimiter. Batches can share it, as those of a
+	// BatchingClient do.
+	CardinalityLimiter *CardinalityLimiter `json:"-"`
+
+	// TagSanitizer, if set, cleans the tags of the points added to the
+	// batch, before the CardinalityLimiter sees them, see TagSanitizer.
+	// The points added to a BatchingClient are sanitized as they are
+	// written, by those of its batches.
+	TagSanitizer *TagSanitizer `json:"-"`
+
+	// AssignTimeOnAdd makes AddPoint add the points without a timestamp
+	// with the current time of Clock, rather than leaving it to the server
+	// to assign one when it stores them, so that the writes of a point to
+	// several servers have the same time. The points given are not
+	// modified.
+	AssignTimeOnAdd bool
+
+	// Clock, if set, is the clock of AssignTimeOnAdd, defaults to the
+	// system clock.
+	Clock Clock `json:"-"`
+
+	// Strict makes the writes of the batch fail before anything is sent if
+	// a nil point was added to it, or if it has no points, see
+	// BatchPoints.Err. Otherwise nil points are dropped as they are added,
+	// and the writes of an empty batch send nothing and succeed.
+	Strict bool
+
+	// MaxBytes, if set, limits the size of the batch, see BatchPoints.Size.
+	// AddPoint returns ErrBatchFull instead of adding a point that would
+	// take the batch over it, so that the batch can be written and a new
+	// one started.
+	MaxBytes int
+
+	// MaxFieldsPerPoint, if positive, is the largest number of fields of
+	// a point AddPoint accepts. The server has no such limit.
+	MaxFieldsPerPoint int
+
+	// MaxStringFieldLength is the length in bytes of the longest string
+	// field value AddPoint accepts, MaxKeyLength that of the longest series
+	// key of a field, its measurement, tags and field key as the server
+	// stores them. They default to the limits of InfluxDB 1.8,
+	// MaxStringFieldLength and DefaultMaxKeyLength, and a negative value
+	// removes the limit. AddPoint returns a *LimitError for a point over a
+	// limit.
+	MaxStringFieldLength int
+	MaxKeyLength         int
+
+	// TruncateStrings makes AddPoint cut the string values longer than
+	// MaxStringFieldLength to it, ending them with TruncationMarker, rather
+	// than rejecting the point. TruncationMarker defaults to
+	// DefaultTruncationMarker. The points given are not modified.
+	TruncateStrings  bool
+	TruncationMarker string
 }
 
+var (
+	// ErrNilPoint is returned for a write of a Strict batch to which a nil
+	// point was added.
+	ErrNilPoint = errors.New("nil point added to batch")
+
+	// ErrEmptyBatch is returned for a write of a Strict batch without
+	// points.
+	ErrEmptyBatch = errors.New("batch has no points")
+
+	// ErrBatchFull is returned by AddPoint for a point that does not fit
+	// the MaxBytes of a batch that has points.
+	ErrBatchFull = errors.New("batch is full")
+
+	// ErrPointExceedsBatch is returned by AddPoint for a point larger than
+	// the MaxBytes of the batch on its own, which no batch can hold.
+	ErrPointExceedsBatch = errors.New("point is larger than the batch size limit")
+)
+
 // Client is a client interface for writing & querying the database.
 type Client interface {
 	// Ping checks that status of cluster, and will always return 0 time and no
@@ -90,27 +435,84 @@ type Client interface {
 	Query(q Query) (*Response, error)
 
 	// QueryAsChunk makes an InfluxDB Query on the database. This will fail if using
-	// the UDP client.
+	// the UDP client. The results are sent in chunks of q.ChunkSize points,
+	// or of the server's default of 10,000 points if it is zero.
 	QueryAsChunk(q Query) (*ChunkedResponse, error)
 
-	// Close releases any resources a Client may be using.
+	// Close releases any resources a Client may be using, once the writes
+	// and queries in progress finished. Those that follow fail with
+	// ErrClientClosed.
 	Close() error
 }
 
+// ContextClient is a Client whose requests can be bounded by a context.Context.
+// All of the clients returned by this package implement ContextClient.
+type ContextClient interface {
+	Client
+
+	// WriteContext is like Write, but returns ctx.Err() once ctx is done.
+	WriteContext(ctx context.Context, bp BatchPoints) error
+
+	// QueryContext is like Query, but returns ctx.Err() once ctx is done.
+	QueryContext(ctx context.Context, q Query) (*Response, error)
+
+	// QueryAsChunkContext is like QueryAsChunk. The context applies to the
+	// whole lifetime of the returned ChunkedResponse.
+	QueryAsChunkContext(ctx context.Context, q Query) (*ChunkedResponse, error)
+}
+
 // NewHTTPClient returns a new Client from the provided config.
 // Client is safe for concurrent use by multiple goroutines.
 func NewHTTPClient(conf HTTPConfig) (Client, error) {
 	if conf.UserAgent == "" {
-		conf.UserAgent = "InfluxDBClient"
+		conf.UserAgent = defaultUserAgent()
 	}
 
-	u, err := url.Parse(conf.Addr)
+	tlsConfig, err := conf.tlsHelpers().tlsConfig(conf.TLSConfig)
 	if err != nil {
 		return nil, err
-	} else if u.Scheme != "http" && u.Scheme != "https" {
-		m := fmt.Sprintf("Unsupported protocol scheme: %s, your address"+
-			" must start with http:// or https://", u.Scheme)
-		return nil, errors.New(m)
+	}
+	conf.TLSConfig = tlsConfig
+
+	// Addr is not needed when both endpoints have their own address.
+	var u *url.URL
+	if conf.Addr != "" || conf.WriteAddr == "" || conf.QueryAddr == "" {
+		if u, err = parseHTTPAddr(conf.Addr, conf.TLSConfig != nil); err != nil {
+			return nil, err
+		}
+	}
+
+	if conf.AuthToken != "" && (conf.Username != "" || conf.Password != "") {
+		return nil, errors.New("AuthToken cannot be used together with Username and Password")
+	}
+	if conf.AuthViaParams && conf.AuthToken != "" {
+		return nil, &ConfigError{Field: "AuthViaParams", Reason: "cannot be used together with AuthToken"}
+	}
+	if conf.CredentialsProvider != nil && (conf.Username != "" || conf.Password != "" || conf.AuthToken != "") {
+		return nil, &ConfigError{Field: "CredentialsProvider", Reason: "cannot be used together with Username, Password or AuthToken"}
+	}
+	if conf.CredentialsTTL < 0 {
+		return nil, &ConfigError{Field: "CredentialsTTL", Reason: fmt.Sprintf("%v is negative", conf.CredentialsTTL)}
+	}
+
+	if conf.Org == "" {
+		conf.Org = "-"
+	}
+
+	var socketPath string
+	if u != nil && u.Scheme == "unix" {
+		if conf.Transport != nil {
+			return nil, errors.New("unix socket addresses cannot be used with a custom Transport")
+		}
+		socketPath = u.Path
+		if socketPath == "" {
+			return nil, fmt.Errorf("missing socket path in address %s", conf.Addr)
+		}
+		host := conf.UnixSocketHost
+		if host == "" {
+			host = DefaultUnixSocketHost
+		}
+		u = &url.URL{Scheme: "http", Host: host}
 	}
 
 	switch conf.WriteEncoding {
@@ -119,104 +521,389 @@ func NewHTTPClient(conf HTTPConfig) (Client, error) {
 		return nil, fmt.Errorf("unsupported encoding %s", conf.WriteEncoding)
 	}
 
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: conf.InsecureSkipVerify,
-		},
-		Proxy: conf.Proxy,
-	}
-	if conf.TLSConfig != nil {
-		tr.TLSClientConfig = conf.TLSConfig
-	}
-	return &client{
-		url:       *u,
-		username:  conf.Username,
-		password:  conf.Password,
-		useragent: conf.UserAgent,
-		httpClient: &http.Client{
-			Timeout:   conf.Timeout,
-			Transport: tr,
-		},
-		transport: tr,
-		encoding:  conf.WriteEncoding,
-	}, nil
-}
-
-// Ping will check to see if the server is up with an optional timeout on waiting for leader.
-// Ping returns how long the request took, the version of the server it connected to, and an error if one occurred.
-func (c *client) Ping(timeout time.Duration) (time.Duration, string, error) {
-	now := time.Now()
+	switch conf.ResponseFormat {
+	case JSONFormat, MsgpackFormat, AutoFormat:
+	default:
+		return nil, fmt.Errorf("unsupported response format %s", conf.ResponseFormat)
+	}
 
-	u := c.url
-	u.Path = path.Join(u.Path, "ping")
+	switch conf.UintEncoding {
+	case UintUnsigned, UintSigned, UintAuto:
+	default:
+		return nil, &ConfigError{Field: "UintEncoding", Reason: fmt.Sprintf("unsupported encoding %d", conf.UintEncoding)}
+	}
 
-	req, err := http.NewRequest("GET", u.String(), nil)
-	if err != nil {
-		return 0, "", err
+	switch conf.NumberDecoding {
+	case JSONNumberDecoding, Float64Decoding, Int64Decoding:
+	default:
+		return nil, &ConfigError{Field: "NumberDecoding", Reason: fmt.Sprintf("unsupported number decoding %d", conf.NumberDecoding)}
 	}
 
-	req.Header.Set("User-Agent", c.useragent)
+	compressionLevel := conf.WriteCompressionLevel
+	if compressionLevel == 0 {
+		compressionLevel = gzip.DefaultCompression
+	}
+	if compressionLevel < gzip.HuffmanOnly || compressionLevel > gzip.BestCompression {
+		return nil, fmt.Errorf("invalid compression level %d", conf.WriteCompressionLevel)
+	}
 
-	if c.username != "" {
-		req.SetBasicAuth(c.username, c.password)
+	if conf.MaxRetries < 0 {
+		return nil, fmt.Errorf("invalid max retries %d", conf.MaxRetries)
+	}
+	if conf.MinSplitSize < 0 {
+		return nil, &ConfigError{Field: "MinSplitSize", Reason: fmt.Sprintf("%d is negative", conf.MinSplitSize)}
+	}
+	if conf.MinSplitSize == 0 {
+		conf.MinSplitSize = 1
+	}
+	if conf.ChunkReadTimeout < 0 {
+		return nil, &ConfigError{Field: "ChunkReadTimeout", Reason: fmt.Sprintf("%v is negative", conf.ChunkReadTimeout)}
+	}
+	if conf.DrainTimeout < 0 {
+		return nil, &ConfigError{Field: "DrainTimeout", Reason: fmt.Sprintf("%v is negative", conf.DrainTimeout)}
+	}
+	if conf.ConnectionRecycleInterval < 0 {
+		return nil, &ConfigError{Field: "ConnectionRecycleInterval", Reason: fmt.Sprintf("%v is negative", conf.ConnectionRecycleInterval)}
+	}
+	if conf.DumpBodySize < 0 {
+		return nil, &ConfigError{Field: "DumpBodySize", Reason: fmt.Sprintf("%d is negative", conf.DumpBodySize)}
+	}
+	if conf.MaxErrorBodySize < 0 {
+		return nil, &ConfigError{Field: "MaxErrorBodySize", Reason: fmt.Sprintf("%d is negative", conf.MaxErrorBodySize)}
+	}
+	if conf.MaxIdleConnsPerHost < 0 {
+		return nil, &ConfigError{Field: "MaxIdleConnsPerHost", Reason: fmt.Sprintf("%d is negative", conf.MaxIdleConnsPerHost)}
 	}
 
-	if timeout > 0 {
-		params := req.URL.Query()
-		params.Set("wait_for_leader", fmt.Sprintf("%.0fs", timeout.Seconds()))
-		req.URL.RawQuery = params.Encode()
+	if err := checkHeaders(conf.Headers, conf.WriteEncoding); err != nil {
+		return nil, &ConfigError{Field: "Headers", Reason: err.Error()}
 	}
 
-	resp, err := c.httpClient.Do(req)
+	clock := clockOrSystem(conf.Clock)
+	limiter, err := newRateLimiter(conf.RateLimit, clock)
 	if err != nil {
-		return 0, "", err
+		return nil, err
 	}
-	defer resp.Body.Close()
-
-	body, err := ioutil.ReadAll(resp.Body)
+	breaker, err := newBreaker(conf.CircuitBreaker, conf.Stats, clock)
 	if err != nil {
-		return 0, "", err
+		return nil, err
+	}
+	if conf.RetryInterval == 0 {
+		conf.RetryInterval = DefaultRetryInterval
+	}
+	if conf.MaxRetryInterval == 0 {
+		conf.MaxRetryInterval = DefaultMaxRetryInterval
+	}
+	if conf.MaxErrorBodySize == 0 {
+		conf.MaxErrorBodySize = DefaultMaxErrorBodySize
 	}
 
-	if resp.StatusCode != http.StatusNoContent {
-		var err = errors.New(string(body))
-		return 0, "", err
+	tr := conf.Transport
+	var tlsTransport *reloadableTransport
+	if tr == nil {
+		tlsTransport = newHTTPTransport(conf, conf.TLSConfig, socketPath)
+		tr = tlsTransport
+	}
+	writeEP, writeTransport, err := newEndpoint(conf, "WriteAddr", conf.WriteAddr, conf.WriteEndpoint, u, tr, socketPath)
+	if err != nil {
+		return nil, err
+	}
+	queryEP, queryTransport, err := newEndpoint(conf, "QueryAddr", conf.QueryAddr, conf.QueryEndpoint, u, tr, socketPath)
+	if err != nil {
+		return nil, err
+	}
+	var endpointTransports []*reloadableTransport
+	for _, t := range []*reloadableTransport{writeTransport, queryTransport} {
+		if t != nil {
+			endpointTransports = append(endpointTransports, t)
+		}
 	}
+	c := &client{
+		writeEP:            writeEP,
+		queryEP:            queryEP,
+		pingBothEndpoints:  conf.PingBothEndpoints,
+		username:           conf.Username,
+		password:           conf.Password,
+		authToken:          conf.AuthToken,
+		authViaParams:      conf.AuthViaParams,
+		useragent:          conf.UserAgent,
+		clientID:           conf.ClientID,
+		headers:            conf.Headers,
+		transport:          tr,
+		endpointTransports: endpointTransports,
+		tlsTransport:       tlsTransport,
+		keepAlivesOff:      conf.DisableKeepAlives,
+		encoding:           conf.WriteEncoding,
+		format:             conf.ResponseFormat,
+		numbers:            conf.NumberDecoding,
+		uints:              conf.UintEncoding,
+		acceptGzip:         conf.AcceptGzip,
+		strict:             conf.StrictResponses,
+		v2Write:            conf.UseV2CompatWrite,
+		bucketName:         conf.Bucket,
+		org:                conf.Org,
+		maxRetries:         conf.MaxRetries,
+		retryInterval:      conf.RetryInterval,
+		maxRetryInterval:   conf.MaxRetryInterval,
+		splitTooLarge:      conf.SplitTooLarge,
+		minSplit:           conf.MinSplitSize,
+		stats:              conf.Stats,
+		tracer:             conf.Tracer,
+		logger:             conf.Logger,
+		validateRaw:        conf.ValidateRawWrites,
+		validatePoints:     conf.ValidatePoints,
+		chunkReadTimeout:   conf.ChunkReadTimeout,
+		limiter:            limiter,
+		breaker:            breaker,
+		drain:              drainer{timeout: conf.DrainTimeout},
+		journal:            conf.WriteJournal,
+		dumper:             newDumper(conf),
+		maxErrorBody:       conf.MaxErrorBodySize,
+		clock:              clock,
+	}
+	if conf.CredentialsProvider != nil {
+		ttl := conf.CredentialsTTL
+		if ttl == 0 {
+			ttl = DefaultCredentialsTTL
+		}
+		c.credentials = &credentialsCache{provider: conf.CredentialsProvider, ttl: ttl, clock: clock}
+	}
+	c.gzipWriters.New = func() interface{} {
+		w, _ := gzip.NewWriterLevel(nil, compressionLevel)
+		return w
+	}
+	if conf.ConnectionRecycleInterval > 0 {
+		c.recycling = make(chan struct{})
+		c.recycled = make(chan struct{})
+		go c.recycleLoop(conf.ConnectionRecycleInterval)
+	}
+	return c, nil
+}
+
+// Ping will check to see if the server is up with an optional timeout on waiting for leader.
+// Ping returns how long the request took, the version of the server it connected to, and an error if one occurred.
+// A timeout, if set, also bounds the request in place of HTTPConfig.Timeout.
+func (c *client) Ping(timeout time.Duration) (time.Duration, string, error) {
+	res, err := c.PingContext(context.Background(), timeout)
+	return res.Latency, res.Version, err
+}
+
+// RequestIDClient is implemented by the HTTP client.
+type RequestIDClient interface {
+	// LastRequestID returns the request ID the server assigned to the last
+	// write that got a response, for finding it in the server's logs.
+	LastRequestID() string
+}
+
+// LastRequestID returns the request ID of the last write response.
+func (c *client) LastRequestID() string {
+	id, _ := c.lastRequestID.Load().(string)
+	return id
+}
 
-	version := resp.Header.Get("X-Influxdb-Version")
-	return time.Since(now), version, nil
+// requestID returns the ID InfluxDB assigned to the request of resp.
+func requestID(resp *http.Response) string {
+	if id := resp.Header.Get("X-Request-Id"); id != "" {
+		return id
+	}
+	return resp.Header.Get("Request-Id")
 }
 
-// Close releases the client's resources.
+// Close makes the writes, queries and pings that follow fail with
+// ErrClientClosed, waits up to the DrainTimeout for those in progress to
+// finish and closes the idle connections. A write waiting to be retried fails
+// with ErrClientClosed. The connections of the requests still in progress by
+// then are closed once the last of them finished.
 func (c *client) Close() error {
-	c.transport.CloseIdleConnections()
-	return nil
+	return c.drain.close(func() error {
+		if c.recycling != nil {
+			close(c.recycling)
+			<-c.recycled
+		}
+		c.closeIdleConnections()
+		return nil
+	})
 }
 
 // client is safe for concurrent use as the fields are all read-only
 // once the client is instantiated.
 type client struct {
 	// N.B - if url.UserInfo is accessed in future modifications to the
-	// methods on client, you will need to synchronize access to url.
-	url        url.URL
-	username   string
-	password   string
-	useragent  string
-	httpClient *http.Client
-	transport  *http.Transport
-	encoding   ContentEncoding
+	// methods on client, you will need to synchronize access to the url of
+	// the endpoints.
+	//
+	// writeEP is where the writes are sent, and queryEP the other
+	// requests.
+	writeEP endpoint
+	queryEP endpoint
+
+	// pingBothEndpoints is HTTPConfig.PingBothEndpoints.
+	pingBothEndpoints bool
+
+	username      string
+	password      string
+	authToken     string
+	authViaParams bool
+	useragent     string
+	clientID      string
+	headers       map[string]string
+	transport     http.RoundTripper
+
+	// endpointTransports are the transports of the endpoints with a TLS
+	// config of their own.
+	endpointTransports []*reloadableTransport
+
+	// credentials, if set, caches the credentials of the
+	// CredentialsProvider, used in place of username, password and
+	// authToken.
+	credentials *credentialsCache
+
+	// tlsTransport is the transport, unless HTTPConfig.Transport was set.
+	tlsTransport *reloadableTransport
+
+	// keepAlivesOff closes the connection of every request.
+	keepAlivesOff bool
+
+	// recycling, if set, stops the recycleLoop on Close, which closes
+	// recycled once it returned.
+	recycling chan struct{}
+	recycled  chan struct{}
+
+	encoding ContentEncoding
+	format   ResponseFormat
+	numbers  NumberDecoding
+	uints    UintEncoding
+
+	// info, guarded by infoMu, is the ServerInfo of the last probe.
+	infoMu sync.Mutex
+	info   *ServerInfo
+
+	// acceptGzip sets Accept-Encoding on query requests.
+	acceptGzip bool
+
+	// strict checks the content type and keys of query responses.
+	strict bool
+
+	v2Write    bool
+	bucketName string
+	org        string
+
+	maxRetries       int
+	retryInterval    time.Duration
+	maxRetryInterval time.Duration
+
+	// splitTooLarge is HTTPConfig.SplitTooLarge, and minSplit
+	// HTTPConfig.MinSplitSize.
+	splitTooLarge bool
+	minSplit      int
+
+	stats       StatsCollector
+	tracer      Tracer
+	logger      Logger
+	validateRaw bool
+
+	validatePoints   bool
+	chunkReadTimeout time.Duration
+	limiter          *rateLimiter
+	breaker          *breaker
+
+	// drain tracks the requests in progress, from sending them to closing
+	// their response bodies.
+	drain drainer
+
+	// journal, if set, journals the writes.
+	journal *WriteJournal
+
+	// dumper, if set, dumps the requests and responses.
+	dumper *dumper
+
+	// maxErrorBody is HTTPConfig.MaxErrorBodySize.
+	maxErrorBody int
+
+	// clock is HTTPConfig.Clock, or the system clock.
+	clock Clock
+
+	// lastRequestID holds the request ID of the last write response.
+	lastRequestID atomic.Value
+
+	// gzipWriters pools the *gzip.Writer used for compressing writes.
+	gzipWriters sync.Pool
 }
 
 // BatchPoints is an interface into a batched grouping of points to write into
 // InfluxDB together. BatchPoints is NOT thread-safe, you must create a separate
-// batch for each goroutine.
+// batch for each goroutine, or use NewSafeBatchPoints.
 type BatchPoints interface {
-	// AddPoint adds the given point to the Batch of points.
-	AddPoint(p *Point)
-	// AddPoints adds the given points to the Batch of points.
-	AddPoints(ps []*Point)
+	// AddPoint adds the given point to the Batch of points. A nil point is
+	// dropped, and fails the writes of a Strict Batch, see Err. It returns
+	// ErrBatchFull, or ErrPointExceedsBatch, without adding the point if it
+	// would take the Batch over its MaxBytes.
+	AddPoint(p *Point) error
+	// AddPoints adds the given points to the Batch of points, dropping the
+	// nil ones like AddPoint. It stops at the first point that does not
+	// fit, returning the error of AddPoint; those before it are added.
+	AddPoints(ps []*Point) error
+	// Size returns the number of bytes of the points of the Batch as line
+	// protocol in its precision, newlines included: the size of the body
+	// of an uncompressed HTTP write. It is kept up to date as points are
+	// added, so it is cheap to call, but does not see the points changed
+	// after they were added.
+	Size() int
 	// Points lists the points in the Batch.
 	Points() []*Point
+	// Reset removes the points from the Batch, keeping its settings and the
+	// memory allocated for the points. A slice returned by Points before
+	// Reset must not be used after it, as it is reused for new points.
+	Reset()
+	// Validate checks every point of the Batch, see Point.Validate. The
+	// Index of a returned *ValidationError is the index of the point.
+	Validate() error
+	// Err returns the error the writes of a Strict Batch fail with before
+	// anything is sent: ErrNilPoint if a nil point was added since the
+	// Batch was created or Reset, or ErrEmptyBatch if it has no points. It
+	// returns nil for a Batch that is not Strict.
+	Err() error
+	// Dedup removes the points the server would overwrite: those of the
+	// same series and time, in the precision of the Batch, as a later
+	// point. When the duplicates carry different fields they are merged,
+	// the later value of a field winning, into a point that takes the place
+	// of the first one. Points without a time count as duplicates, as the
+	// server gives them all the same time. The Batch is left as is if the
+	// fields of a point cannot be parsed.
+	Dedup() (DedupStats, error)
+	// Sort orders the points of the Batch by series key then time, which
+	// the server ingests faster. The sort is stable, points of the same
+	// series and time keep their order, and points without a time come
+	// after the others of their series. The slices given to AddPoints are
+	// left as is, but not one returned by Points before.
+	Sort()
+	// WriteTo writes the points of the Batch to w as the line protocol the
+	// HTTP client sends, one point per line with its time in the precision
+	// of the Batch, and returns the number of bytes written.
+	WriteTo(w io.Writer) (int64, error)
+	// TruncateTimes truncates the timestamps of the points of the Batch to
+	// its precision, toward zero as its HTTP writes do, so that they are
+	// written the same by every client. The points whose timestamp changes
+	// are replaced in the Batch by copies, those given to AddPoint are left
+	// as is.
+	TruncateTimes()
+	// CheckTimestamps returns the points of the Batch whose timestamp, in
+	// the precision of the Batch, is more than maxPast before now or
+	// maxFuture after it, such as those of a host with a skewed clock, a
+	// limit being ignored if it is not positive. Points without a
+	// timestamp, whose time the server assigns, are never returned.
+	CheckTimestamps(now time.Time, maxPast, maxFuture time.Duration) []TimestampViolation
+	// Split cuts the Batch into batches of at most maxPoints points and
+	// maxBytes bytes, see Size, a limit being ignored if it is not
+	// positive. The batches have the settings of the Batch and its points
+	// in order, and the Batch is left as is. A point larger than maxBytes
+	// on its own is put in a batch of its own, which is reported by an
+	// *OversizedPointsError returned along with the batches.
+	Split(maxPoints, maxBytes int) ([]BatchPoints, error)
+	// Filter returns a batch with the settings of the Batch and those of
+	// its points that m matches, in order, which it shares with the Batch.
+	// The Batch is left as is.
+	Filter(m *TagMatcher) BatchPoints
 
 	// Precision returns the currently set precision of this Batch.
 	Precision() string
@@ -241,18 +928,10 @@ type BatchPoints interface {
 
 // NewBatchPoints returns a BatchPoints interface based on the given config.
 func NewBatchPoints(conf BatchPointsConfig) (BatchPoints, error) {
-	if conf.Precision == "" {
-		conf.Precision = "ns"
-	}
-	if _, err := time.ParseDuration("1" + conf.Precision); err != nil {
+	bp := &batchpoints{}
+	if err := bp.configure(conf); err != nil {
 		return nil, err
 	}
-	bp := &batchpoints{
-		database:         conf.Database,
-		precision:        conf.Precision,
-		retentionPolicy:  conf.RetentionPolicy,
-		writeConsistency: conf.WriteConsistency,
-	}
 	return bp, nil
 }
 
@@ -262,20 +941,168 @@ type batchpoints struct {
 	precision        string
 	retentionPolicy  string
 	writeConsistency string
+
+	// sortOnWrite is BatchPointsConfig.SortOnWrite, and sorted whether the
+	// points were sorted since the last one was added.
+	sortOnWrite bool
+	sorted      bool
+
+	// sortFields is BatchPointsConfig.SortFields.
+	sortFields bool
+
+	limiter   *CardinalityLimiter
+	sanitizer *TagSanitizer
+
+	// clock is the Clock of the config if AssignTimeOnAdd is set, nil
+	// otherwise.
+	clock Clock
+
+	// strict is BatchPointsConfig.Strict, and nilPoint whether a nil point
+	// was added since the last Reset.
+	strict   bool
+	nilPoint bool
+
+	// size is the result of Size, and maxBytes BatchPointsConfig.MaxBytes.
+	size     int
+	maxBytes int
+
+	limits pointLimits
+}
+
+// configure applies the settings of conf to bp.
+func (bp *batchpoints) configure(conf BatchPointsConfig) error {
+	if conf.Precision == "" {
+		conf.Precision = "ns"
+	}
+	if _, err := time.ParseDuration("1" + conf.Precision); err != nil {
+		return err
+	}
+	consistency, err := ParseConsistency(conf.WriteConsistency)
+	if err != nil {
+		return err
+	}
+	if conf.MaxBytes < 0 {
+		return &ConfigError{Field: "MaxBytes", Reason: fmt.Sprintf("%d is negative", conf.MaxBytes)}
+	}
+	limits, err := newPointLimits(conf)
+	if err != nil {
+		return err
+	}
+	bp.database = conf.Database
+	bp.precision = conf.Precision
+	bp.retentionPolicy = conf.RetentionPolicy
+	bp.writeConsistency = string(consistency)
+	bp.sortOnWrite = conf.SortOnWrite
+	bp.sorted = false
+	bp.sortFields = conf.SortFields
+	bp.limiter = conf.CardinalityLimiter
+	bp.sanitizer = conf.TagSanitizer
+	bp.clock = nil
+	if conf.AssignTimeOnAdd {
+		bp.clock = clockOrSystem(conf.Clock)
+	}
+	bp.strict = conf.Strict
+	bp.nilPoint = false
+	bp.maxBytes = conf.MaxBytes
+	bp.limits = limits
+	return nil
 }
 
-func (bp *batchpoints) AddPoint(p *Point) {
+func (bp *batchpoints) AddPoint(p *Point) error {
+	if p == nil {
+		bp.nilPoint = bp.strict
+		return nil
+	}
+	if bp.clock != nil {
+		p = assignTime(p, bp.clock)
+	}
+	if bp.sanitizer != nil {
+		p = bp.sanitizer.apply(p)
+	}
+	p, err := bp.limits.apply(p)
+	if err != nil {
+		return err
+	}
+	var added []tagValue
+	if bp.limiter != nil {
+		if p, added = bp.limiter.apply(p); p == nil {
+			// Nor are the series of a point rejected.
+			bp.limiter.forget(added)
+			return nil
+		}
+	}
+	size := models.PrecisionStringSize(p.pt, bp.precision) + 1
+	if bp.maxBytes > 0 && bp.size+size > bp.maxBytes {
+		// The point is not added, nor are its series.
+		if len(added) > 0 {
+			bp.limiter.forget(added)
+		}
+		if len(bp.points) == 0 {
+			return ErrPointExceedsBatch
+		}
+		return ErrBatchFull
+	}
 	bp.points = append(bp.points, p)
+	bp.size += size
+	bp.sorted = false
+	return nil
+}
+
+func (bp *batchpoints) AddPoints(ps []*Point) error {
+	for _, p := range ps {
+		if err := bp.AddPoint(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (bp *batchpoints) Size() int {
+	return bp.size
+}
+
+// pointsSize computes the result of Size from the points.
+func (bp *batchpoints) pointsSize() int {
+	var size int
+	for _, p := range bp.points {
+		size += models.PrecisionStringSize(p.pt, bp.precision) + 1
+	}
+	return size
 }
 
-func (bp *batchpoints) AddPoints(ps []*Point) {
-	bp.points = append(bp.points, ps...)
+func (bp *batchpoints) Err() error {
+	switch {
+	case !bp.strict:
+		return nil
+	case bp.nilPoint:
+		return ErrNilPoint
+	case len(bp.points) == 0:
+		return ErrEmptyBatch
+	}
+	return nil
 }
 
 func (bp *batchpoints) Points() []*Point {
+	if bp.sortOnWrite && !bp.sorted {
+		bp.Sort()
+	}
 	return bp.points
 }
 
+func (bp *batchpoints) Validate() error {
+	return validatePoints(bp.points)
+}
+
+func (bp *batchpoints) Reset() {
+	// Drop the references to the points so that they can be collected.
+	for i := range bp.points {
+		bp.points[i] = nil
+	}
+	bp.points = bp.points[:0]
+	bp.nilPoint = false
+	bp.size = 0
+}
+
 func (bp *batchpoints) Precision() string {
 	return bp.precision
 }
@@ -297,6 +1124,7 @@ func (bp *batchpoints) SetPrecision(p string) error {
 		return err
 	}
 	bp.precision = p
+	bp.size = bp.pointsSize()
 	return nil
 }
 
@@ -321,17 +1149,49 @@ type Point struct {
 // given, then data is sent to the database without a timestamp, in which case
 // the server will assign local time upon reception. NOTE: it is recommended to
 // send data with a timestamp.
+//
+// Unsigned integer fields are written as unsigned integers, time.Duration
+// fields as integer nanoseconds, and json.Number fields as integers or
+// floats. NaN and infinite floats are an error, see PointOptions, as is a
+// timestamp outside of MinPlausibleNanoTime and MaxPlausibleNanoTime.
 func NewPoint(
 	name string,
 	tags map[string]string,
 	fields map[string]interface{},
 	t ...time.Time,
+) (*Point, error) {
+	return PointOptions{}.NewPoint(name, tags, fields, t...)
+}
+
+// NewPoint is like the NewPoint function, with the field values converted
+// as set by o.
+func (o PointOptions) NewPoint(
+	name string,
+	tags map[string]string,
+	fields map[string]interface{},
+	t ...time.Time,
 ) (*Point, error) {
 	var T time.Time
 	if len(t) > 0 {
 		T = t[0]
 	}
+	if o.Precision != "" {
+		if err := checkPrecision(o.Precision); err != nil {
+			return nil, err
+		}
+		T = truncateTime(T, o.Precision)
+	}
+	if err := o.checkTime(name, T); err != nil {
+		return nil, err
+	}
 
+	fields, err := o.convertFields(fields)
+	if err != nil {
+		if se, ok := err.(*SkippedFieldsError); ok {
+			se.Measurement = name
+		}
+		return nil, err
+	}
 	pt, err := models.NewPoint(name, models.NewTags(tags), fields, T)
 	if err != nil {
 		return nil, err
@@ -362,6 +1222,27 @@ func (p *Point) Tags() map[string]string {
 	return p.pt.Tags().Map()
 }
 
+// Key returns the series key of the point, its measurement name and tags
+// sorted by key and escaped as in line protocol, such as "cpu,host=a,dc=eu".
+// It is not copied: it must not be modified, and is only valid until the
+// point is, as by AddTag.
+func (p *Point) Key() []byte {
+	return p.pt.Key()
+}
+
+// HashID returns the 64-bit FNV-1a hash of the series key of the point.
+func (p *Point) HashID() uint64 {
+	return p.pt.HashID()
+}
+
+// ForEachTag calls fn with the key and value of every tag of the point, in
+// the order of their keys, until it returns false, without building the map
+// of Tags. Like the key of the point, k and v must not be modified nor kept.
+// Only tags holding escaped characters are copied, to unescape them.
+func (p *Point) ForEachTag(fn func(k, v []byte) bool) {
+	p.pt.ForEachTag(fn)
+}
+
 // Time return the timestamp for the point.
 func (p *Point) Time() time.Time {
 	return p.pt.Time()
@@ -382,27 +1263,127 @@ func NewPointFrom(pt models.Point) *Point {
 	return &Point{pt: pt}
 }
 
+// Write takes a BatchPoints object and writes all Points to InfluxDB.
 func (c *client) Write(bp BatchPoints) error {
+	return c.WriteContext(context.Background(), bp)
+}
+
+// WriteContext is like Write, but the request is bound to ctx.
+func (c *client) WriteContext(ctx context.Context, bp BatchPoints) error {
+	var ws WriteStats
+	return c.writeSplitting(ctx, bp, &ws)
+}
+
+// WriteWithStats is like WriteContext, returning the statistics of the write.
+func (c *client) WriteWithStats(ctx context.Context, bp BatchPoints) (WriteStats, error) {
+	var ws WriteStats
+	err := c.writeSplitting(ctx, bp, &ws)
+	return ws, err
+}
+
+// writeContext writes bp, sending headers with the request, and records the
+// statistics of the write in ws. The lines of the points are recorded in
+// lines, if not nil.
+func (c *client) writeContext(ctx context.Context, bp BatchPoints, headers map[string]string, ws *WriteStats, lines *lineIndex) error {
+	if ok, err := checkBatch(bp); !ok {
+		return err
+	}
+	if c.validatePoints {
+		if err := bp.Validate(); err != nil {
+			return err
+		}
+	}
+	if c.v2Write {
+		if _, err := v2Precision(bp.Precision()); err != nil {
+			return err
+		}
+	}
+
+	sorter := newFieldSorter(bp)
+	signed := c.signedUints(ctx)
+	return c.writeEncoded(ctx, bp, headers, ws, lines, func(w io.Writer) (int, error) {
+		var points int
+		var line []byte
+		for i, p := range bp.Points() {
+			if p == nil {
+				continue
+			}
+			points++
+			pt := p.pt
+			if signed {
+				var err error
+				if pt, err = signedUintPoint(pt); err != nil {
+					return 0, err
+				}
+			}
+			line = models.AppendPrecisionString(line[:0], pt, bp.Precision())
+			if sorter != nil {
+				sorter.sortLine(line, len(p.pt.Key()), !p.pt.Time().IsZero())
+			}
+			if lines != nil {
+				lines.add(i, line)
+			}
+			line = append(line, '\n')
+			if _, err := w.Write(line); err != nil {
+				return 0, err
+			}
+		}
+		return points, nil
+	})
+}
+
+// checkBatch reports whether a write of bp has points to send, and if not
+// the error it fails with, see BatchPoints.Err.
+func checkBatch(bp BatchPoints) (bool, error) {
+	if err := bp.Err(); err != nil {
+		return false, err
+	}
+	for _, p := range bp.Points() {
+		if p != nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// writeEncoded writes the body encode writes to w to the database of bp,
+// compressed as configured, and records the statistics of the write in ws.
+// encode returns the number of points it wrote. lines, if not nil, records
+// whether the body was sent.
+func (c *client) writeEncoded(ctx context.Context, bp BatchPoints, headers map[string]string, ws *WriteStats, lines *lineIndex, encode func(w io.Writer) (int, error)) (err error) {
+	ctx, end := c.startSpan(ctx, SpanWrite)
+	if end != nil {
+		defer func() { end(err, writeSpanAttrs(bp, ws)) }()
+	}
+	// SetWriteConsistency takes any string, so it is checked here rather
+	// than left for the server to reject.
+	if _, err := ParseConsistency(bp.WriteConsistency()); err != nil {
+		return err
+	}
+
 	var b bytes.Buffer
+	start := time.Now()
 
 	var w io.Writer
 	if c.encoding == GzipEncoding {
-		w = gzip.NewWriter(&b)
+		gw := c.gzipWriters.Get().(*gzip.Writer)
+		defer c.gzipWriters.Put(gw)
+		gw.Reset(&b)
+		w = gw
 	} else {
 		w = &b
 	}
 
-	for _, p := range bp.Points() {
-		if p == nil {
-			continue
-		}
-		if _, err := io.WriteString(w, p.pt.PrecisionString(bp.Precision())); err != nil {
-			return err
-		}
-
-		if _, err := w.Write([]byte{'\n'}); err != nil {
-			return err
-		}
+	// The journal hashes the body before it is compressed.
+	enc := w
+	var h hash.Hash
+	if c.journal != nil {
+		h = c.journal.newHash(bp)
+		enc = io.MultiWriter(w, h)
+	}
+	points, err := encode(enc)
+	if err != nil {
+		return err
 	}
 
 	// gzip writer should be closed to flush data into underlying buffer
@@ -411,11 +1392,81 @@ func (c *client) Write(bp BatchPoints) error {
 			return err
 		}
 	}
+	ws.PointCount, ws.ByteCount, ws.SerializeDuration = points, b.Len(), time.Since(start)
+
+	var key journalKey
+	if h != nil {
+		key = c.journal.key(h)
+		if c.journal.skip(key) {
+			logf(c.logger, "influxdb: skipping a write of %d points journaled before", points)
+			return nil
+		}
+	}
+
+	if err := c.limiter.wait(ctx, points, b.Len()); err != nil {
+		return err
+	}
+	probe, err := c.breaker.allow()
+	if err != nil {
+		return err
+	}
+
+	retryStats, _ := c.stats.(RetryStatsCollector)
+	attempts := 0
+	var last error
+	err = c.retry(ctx, func() error {
+		if attempts++; attempts > 1 {
+			ws.Retries++
+			if retryStats != nil {
+				retryStats.WriteRetried(points, last)
+			}
+		}
+		if lines != nil {
+			lines.sent = true
+		}
+		start := time.Now()
+		ws.StatusCode = 0
+		err := c.write(ctx, bp, headers, bytes.NewReader(b.Bytes()), ws)
+		ws.NetworkDuration += time.Since(start)
+		if c.stats != nil {
+			c.stats.WriteDone(points, b.Len(), time.Since(start), err)
+		}
+		last = err
+		if err != nil && h != nil {
+			var journalErr error
+			if err, journalErr = c.journal.attempted(key, err); journalErr != nil {
+				logf(c.logger, "influxdb: %v", journalErr)
+			}
+		}
+		return err
+	})
+	c.breaker.done(probe, err)
+	if err == nil && h != nil {
+		if journalErr := c.journal.acknowledge(key); journalErr != nil {
+			logf(c.logger, "influxdb: %v", journalErr)
+		}
+	}
+
+	var pe *PartialWriteError
+	if errors.As(err, &pe) {
+		pe.setTotal(points)
+	}
+	return err
+}
 
-	u := c.url
-	u.Path = path.Join(u.Path, "write")
+// write sends a single write request with the already encoded body and
+// headers. The status of the response is recorded in ws, if not nil.
+func (c *client) write(ctx context.Context, bp BatchPoints, headers map[string]string, body io.Reader, ws *WriteStats) error {
+	u := c.writeEP.url
+	if c.v2Write {
+		u.Path = path.Join(u.Path, "api/v2/write")
+	} else {
+		u.Path = path.Join(u.Path, "write")
+	}
 
-	req, err := http.NewRequest("POST", u.String(), &b)
+	// The body of a bytes.Reader, as that of every write but a streamed
+	// one, gets a GetBody so that 307 and 308 redirects send it again.
+	req, err := http.NewRequestWithContext(ctx, "POST", u.String(), body)
 	if err != nil {
 		return err
 	}
@@ -423,31 +1474,68 @@ func (c *client) Write(bp BatchPoints) error {
 		req.Header.Set("Content-Encoding", string(c.encoding))
 	}
 	req.Header.Set("Content-Type", "")
-	req.Header.Set("User-Agent", c.useragent)
-	if c.username != "" {
-		req.SetBasicAuth(c.username, c.password)
-	}
-
+	c.setHeaders(req, headers)
 	params := req.URL.Query()
-	params.Set("db", bp.Database())
-	params.Set("rp", bp.RetentionPolicy())
-	params.Set("precision", bp.Precision())
-	params.Set("consistency", bp.WriteConsistency())
+	if c.v2Write {
+		precision, _ := v2Precision(bp.Precision())
+		params.Set("bucket", c.bucket(bp))
+		params.Set("org", c.org)
+		params.Set("precision", precision)
+	} else {
+		params.Set("db", bp.Database())
+		params.Set("rp", bp.RetentionPolicy())
+		params.Set("precision", bp.Precision())
+		// The consistency was checked by writeEncoded, and is only
+		// normalized here.
+		if consistency, _ := ParseConsistency(bp.WriteConsistency()); consistency != "" {
+			params.Set("consistency", string(consistency))
+		}
+	}
 	req.URL.RawQuery = params.Encode()
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(&c.writeEP, req)
 	if err != nil {
+		var re *RedirectError
+		if ctx.Err() == nil && !errors.As(err, &re) && err != ErrClientClosed {
+			err = &retryableError{err: err}
+		}
 		return err
 	}
 	defer resp.Body.Close()
+	c.lastRequestID.Store(requestID(resp))
+	if ws != nil {
+		ws.StatusCode = resp.StatusCode
+	}
 
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
+	if err := unfollowedRedirect(resp); err != nil {
 		return err
 	}
 
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return contextError(ctx, err)
+	}
+
+	if resp.StatusCode == http.StatusUnsupportedMediaType && c.encoding != DefaultEncoding {
+		return fmt.Errorf("server does not accept %s encoded writes: %s", c.encoding, strings.TrimSpace(string(respBody)))
+	}
+
 	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
-		var err = errors.New(string(body))
+		err := c.errorBody(newErrorResponseBody(resp, respBody), respBody)
+		if ce, ok := err.(*ConsistencyError); ok {
+			ce.Required = params.Get("consistency")
+		}
+		if IsRetryable(err) {
+			// The dates of the hints are measured by the clock the
+			// retries wait on.
+			now := c.clock.Now()
+			retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"), now)
+			if te, ok := err.(*ThrottledError); ok {
+				te.RetryAfter = retryAfter
+				retryAfter = te.delay(now)
+			}
+			err = &retryableError{err: err, retryAfter: retryAfter}
+		}
 		return err
 	}
 
@@ -463,6 +1551,155 @@ type Query struct {
 	Chunked         bool
 	ChunkSize       int
 	Parameters      map[string]interface{}
+
+	// Timeout bounds this query, including the reading of every chunk of a
+	// chunked response, in place of HTTPConfig.Timeout. Zero means the
+	// client's timeout applies.
+	Timeout time.Duration
+
+	// ChunkReadTimeout, if set, overrides HTTPConfig.ChunkReadTimeout for
+	// this query.
+	ChunkReadTimeout time.Duration
+
+	// AutoKillAfter bounds this query as Timeout does, the shorter one
+	// applying if both are set. Once it expires, the client kills the query
+	// on the server before returning the *QueryTimeoutError, so that the
+	// server does not keep running a query nobody waits for. The query is
+	// looked up with SHOW QUERIES, as the one of Database whose text is
+	// Command, or Command but for its spacing, running for the time closest
+	// to AutoKillAfter. The server reports the text of the query as it
+	// formats it, which can differ from Command, as with bound parameters,
+	// in which case nothing is killed; and another client running the same
+	// query at the same time could get its query killed instead.
+	AutoKillAfter time.Duration
+
+	// Method selects the HTTP method of the query. The zero value,
+	// QueryMethodAuto, sends statements that modify data as POST.
+	Method QueryMethod
+
+	// Epoch makes the server return times as integers in the given unit,
+	// one of "ns", "u", "ms", "s", "m" or "h", in place of Precision. When
+	// both are empty, times are returned as RFC3339 strings.
+	Epoch string
+
+	// Headers are sent with the query, on top of HTTPConfig.Headers and
+	// overriding them. The headers set by the client cannot be overridden,
+	// see HTTPConfig.Headers.
+	Headers map[string]string
+
+	// FailOnPartial makes the query fail with a *PartialResultError, along
+	// with the response read, when the server truncated the results of a
+	// statement. With QueryAsChunk the error is returned by the
+	// NextResponse call that reaches the end of the stream.
+	FailOnPartial bool
+
+	// ResyncOnDecodeError makes a chunked JSON response skip what it cannot
+	// decode, as the garbage a proxy inserted between chunks, up to the next
+	// line starting a chunk, with `{"results"`, instead of failing. The
+	// parts skipped are reported by ChunkedResponse.DecodeErrors, and to the
+	// Logger. The chunks skipped along with them are lost.
+	ResyncOnDecodeError bool
+
+	// MaxRows and MaxSeries, if set, bound the rows and the series of the
+	// response. A command made of a single SELECT statement without INTO or
+	// subqueries gets its LIMIT lowered to MaxRows and its SLIMIT to
+	// MaxSeries, or added if it has none, so that the server stops early;
+	// LIMIT bounds the rows of every series, so the response may still have
+	// more rows in all, as with GROUP BY *. Past either limit, reading the
+	// response is aborted and the query fails with a *TooManyRowsError,
+	// matched by ErrTooManyRows. A response that is not chunked is only
+	// checked once decoded: set Chunked to stop reading it early.
+	MaxRows   int
+	MaxSeries int
+
+	// MaxResponseBytes, if set, bounds the size of the response, once
+	// decompressed. Past it, reading the response is aborted and its
+	// connection closed, so that the server stops sending, and the query
+	// fails with a *ResponseTooLargeError, matched by ErrResponseTooLarge:
+	// from Query before the response is decoded in full, from the
+	// NextResponse call of QueryAsChunk that reads past the limit, or from
+	// the Err of a QueryStream. See ChunkedResponse.BytesRead for the size
+	// read so far.
+	MaxResponseBytes int64
+}
+
+// timeout returns the duration the query is bounded by, the shorter of
+// Timeout and AutoKillAfter, or 0.
+func (q Query) timeout() time.Duration {
+	if q.AutoKillAfter > 0 && (q.Timeout <= 0 || q.AutoKillAfter < q.Timeout) {
+		return q.AutoKillAfter
+	}
+	return q.Timeout
+}
+
+// epoch returns the epoch parameter of q.
+func (q Query) epoch() string {
+	if q.Epoch != "" {
+		return q.Epoch
+	}
+	return q.Precision
+}
+
+// responsePrecision returns the precision of the times in the response to a
+// query with the given epoch parameter, or "" for RFC3339 times.
+func responsePrecision(epoch string) string {
+	switch epoch {
+	case "", "u", "ms", "s", "m", "h":
+		return epoch
+	}
+	// The server returns nanoseconds for any other epoch.
+	return "ns"
+}
+
+// QueryTimeoutError is returned when a query runs longer than its Timeout or
+// AutoKillAfter. It wraps context.DeadlineExceeded.
+type QueryTimeoutError struct {
+	Timeout time.Duration
+
+	// Killed reports whether the query was killed on the server after its
+	// AutoKillAfter, and KillErr is the error looking it up or killing it
+	// failed with.
+	Killed  bool
+	KillErr error
+}
+
+func (e *QueryTimeoutError) Error() string {
+	msg := fmt.Sprintf("query timed out after %v", e.Timeout)
+	switch {
+	case e.KillErr != nil:
+		msg += fmt.Sprintf(", killing it failed: %v", e.KillErr)
+	case e.Killed:
+		msg += ", killed"
+	}
+	return msg
+}
+
+func (e *QueryTimeoutError) Unwrap() error { return context.DeadlineExceeded }
+
+// withQueryTimeout bounds ctx by the timeout of q, if any. The returned
+// function releases the context and maps an error caused by the timeout to a
+// *QueryTimeoutError, killing the query first if its AutoKillAfter expired.
+func (c *client) withQueryTimeout(ctx context.Context, q Query) (context.Context, func(err error) error) {
+	timeout := q.timeout()
+	if timeout <= 0 {
+		return ctx, func(err error) error { return err }
+	}
+	qctx, cancel := context.WithTimeout(ctx, timeout)
+	var once sync.Once
+	var killed bool
+	var killErr error
+	return qctx, func(err error) error {
+		cancel()
+		if err != nil && ctx.Err() == nil && qctx.Err() == context.DeadlineExceeded {
+			if timeout == q.AutoKillAfter {
+				// The release of a chunked response can be called
+				// once per failed read.
+				once.Do(func() { killed, killErr = killQuery(ctx, c, q, timeout) })
+			}
+			return &QueryTimeoutError{Timeout: timeout, Killed: killed, KillErr: killErr}
+		}
+		return err
+	}
 }
 
 // Params is a type alias to the query parameters.
@@ -494,7 +1731,8 @@ func NewQueryWithRP(command, database, retentionPolicy, precision string) Query
 
 // NewQueryWithParameters returns a query object.
 // The database and precision arguments can be empty strings if they are not needed for the query.
-// parameters is a map of the parameter names used in the command to their values.
+// parameters is a map of the parameter names used in the command to their values,
+// which must be of type string, float64, int64 or bool.
 func NewQueryWithParameters(command, database, precision string, parameters map[string]interface{}) Query {
 	return Query{
 		Command:    command,
@@ -508,39 +1746,222 @@ func NewQueryWithParameters(command, database, precision string, parameters map[
 type Response struct {
 	Results []Result
 	Err     string `json:"error,omitempty"`
+
+	// Header holds the headers of the HTTP response, such as
+	// X-Influxdb-Version and X-Request-Id. It is nil for responses that did
+	// not come from the HTTP client.
+	Header http.Header `json:"-"`
+
+	// err is the typed error for Err, set when the server answered with an
+	// unexpected status code.
+	err error
+
+	// precision is the precision of the times of the response, used by
+	// Scan and TimeSeries.
+	precision string
+
+	// command is the command of the query, used by ResultFor and Errors.
+	command string
 }
 
-// Error returns the first error from any statement.
+// Error returns the error of the query, or the errors of its statements.
 // It returns nil if no errors occurred on any statements.
+// An error sent along with an unexpected status code is an *ErrorResponse,
+// or one of the types wrapping it. The error of a statement is a
+// *StatementError, its text prefixed with the index of the statement, as in
+// "statement 1: database not found: db0"; those of several statements are
+// joined. Messages of the server that are not errors, such as warnings, are
+// returned by Messages.
 func (r *Response) Error() error {
 	if r.Err != "" {
+		if r.err != nil {
+			return r.err
+		}
 		return errors.New(r.Err)
 	}
+	var errs []error
 	for _, result := range r.Results {
 		if result.Err != "" {
-			return errors.New(result.Err)
+			errs = append(errs, fmt.Errorf("statement %d: %w", result.StatementId,
+				&StatementError{Statement: r.statement(result.StatementId), Message: result.Err}))
 		}
 	}
-	return nil
+	if len(errs) == 1 {
+		return errs[0]
+	}
+	return errors.Join(errs...)
+}
+
+// TimeSeries holds the times and values of one column of a series.
+type TimeSeries struct {
+	Name   string
+	Tags   map[string]string
+	Times  []time.Time
+	Values []float64
+}
+
+// TimeSeriesDecoder extracts TimeSeries from a Response.
+type TimeSeriesDecoder struct {
+	// Precision is the epoch precision the query was made with, used to
+	// decode times returned as numbers. Defaults to the Epoch or Precision
+	// of the query the response is for.
+	Precision string
+
+	// NullAsNaN keeps null values as NaN. They are skipped otherwise.
+	NullAsNaN bool
+}
+
+// TimeSeries returns the values of the column field of every series of r,
+// see TimeSeriesDecoder.TimeSeries. Null values are skipped.
+func (r *Response) TimeSeries(field string) (map[string]TimeSeries, error) {
+	return TimeSeriesDecoder{}.TimeSeries(r, field)
+}
+
+// TimeSeries returns the values of the column field of every series of resp,
+// keyed by the name and tags of the series, as in "cpu,host=a". The values of
+// a series found in several results, such as the chunks of a chunked query,
+// are appended in order. It returns the first error of the response, if any.
+func (d TimeSeriesDecoder) TimeSeries(resp *Response, field string) (map[string]TimeSeries, error) {
+	if err := resp.Error(); err != nil {
+		return nil, err
+	}
+	precision := d.Precision
+	if precision == "" {
+		precision = resp.precision
+	}
+	unit, err := epochUnit(precision)
+	if err != nil {
+		return nil, err
+	}
+
+	series := make(map[string]TimeSeries)
+	for _, result := range resp.Results {
+		for _, row := range result.Series {
+			key := row.Name + string(models.NewTags(row.Tags).HashKey())
+			ts, ok := series[key]
+			if !ok {
+				ts = TimeSeries{Name: row.Name, Tags: row.Tags}
+			}
+			if ts, err = d.appendRow(ts, row, field, unit); err != nil {
+				return nil, err
+			}
+			series[key] = ts
+		}
+	}
+	return series, nil
+}
+
+// appendRow appends the times and values of the column field of row to ts.
+func (d TimeSeriesDecoder) appendRow(ts TimeSeries, row models.Row, field string, unit time.Duration) (TimeSeries, error) {
+	timeIndex, fieldIndex := -1, -1
+	for i, name := range row.Columns {
+		switch name {
+		case "time":
+			timeIndex = i
+		case field:
+			fieldIndex = i
+		}
+	}
+	if timeIndex < 0 {
+		return ts, fmt.Errorf("series %q has no time column", row.Name)
+	}
+	if fieldIndex < 0 {
+		return ts, fmt.Errorf("series %q has no column %q", row.Name, field)
+	}
+
+	for _, values := range row.Values {
+		if timeIndex >= len(values) || fieldIndex >= len(values) {
+			continue
+		}
+		var v float64
+		if values[fieldIndex] == nil {
+			if !d.NullAsNaN {
+				continue
+			}
+			v = math.NaN()
+		} else {
+			n, ok := numberString(values[fieldIndex])
+			if !ok {
+				return ts, fmt.Errorf("column %q: cannot decode %T into float64", field, values[fieldIndex])
+			}
+			var err error
+			if v, err = strconv.ParseFloat(n, 64); err != nil {
+				return ts, fmt.Errorf("column %q: %v", field, err)
+			}
+		}
+		t, err := decodeTime(values[timeIndex], unit)
+		if err != nil {
+			return ts, fmt.Errorf("column %q: %v", "time", err)
+		}
+		ts.Times = append(ts.Times, t)
+		ts.Values = append(ts.Values, v)
+	}
+	return ts, nil
 }
 
 // Message represents a user message.
 type Message struct {
-	Level string
-	Text  string
+	Level string `json:"level"`
+	Text  string `json:"text"`
 }
 
 // Result represents a resultset returned from a single statement.
 type Result struct {
-	StatementId int `json:"statement_id"`
-	Series      []models.Row
-	Messages    []*Message
-	Err         string `json:"error,omitempty"`
+	// StatementId is the index of the statement of the query the result is
+	// for, counted from 0, which matches the results of a multi-statement
+	// or chunked query with their statements. See Response.ResultFor.
+	StatementId int          `json:"statement_id"`
+	Series      []models.Row `json:"series,omitempty"`
+	Messages    []*Message   `json:"messages,omitempty"`
+	Err         string       `json:"error,omitempty"`
+
+	// Partial is set by the server on every result of a chunked response
+	// but the last one of a statement, and on the last one when a limit
+	// such as max-row-limit truncated the output. Query.FailOnPartial
+	// turns the latter into an error.
+	Partial bool `json:"partial,omitempty"`
 }
 
 // Query sends a command to the server and returns the Response.
 func (c *client) Query(q Query) (*Response, error) {
-	req, err := c.createDefaultRequest(q)
+	return c.QueryContext(context.Background(), q)
+}
+
+// QueryContext sends a command to the server bound to ctx and returns the Response.
+func (c *client) QueryContext(ctx context.Context, q Query) (*Response, error) {
+	q.Command = q.limitCommand()
+	start := time.Now()
+	ctx, end := c.startSpan(ctx, SpanQuery)
+	ctx, release := c.withQueryTimeout(ctx, q)
+	resp, err := c.query(ctx, q)
+	err = release(err)
+	qerr := err
+	if err == nil && resp != nil {
+		qerr = resp.Error()
+	}
+	c.queryDone(q, start, qerr)
+	if end != nil {
+		status := spanStatus(err, http.StatusOK)
+		if resp != nil {
+			// The server answered, with 200 unless the error says otherwise.
+			status = spanStatus(resp.err, http.StatusOK)
+		}
+		end(qerr, querySpanAttrs(q, status))
+	}
+	return resp, err
+}
+
+// queryDone reports a query that started at start and failed with err to the
+// client's StatsCollector, if any. It returns err.
+func (c *client) queryDone(q Query, start time.Time, err error) error {
+	if c.stats != nil {
+		c.stats.QueryDone(q.Command, time.Since(start), err)
+	}
+	return err
+}
+
+func (c *client) query(ctx context.Context, q Query) (*Response, error) {
+	req, err := c.createDefaultRequest(ctx, q)
 	if err != nil {
 		return nil, err
 	}
@@ -552,19 +1973,49 @@ func (c *client) Query(q Query) (*Response, error) {
 		}
 		req.URL.RawQuery = params.Encode()
 	}
-	resp, err := c.httpClient.Do(req)
+
+	probe, err := c.breaker.allow()
+	if err != nil {
+		return nil, err
+	}
+	response, err := c.sendQuery(req, q)
+	if err == nil {
+		// A 5xx status with an error in the body is a failure too.
+		c.breaker.done(probe, response.err)
+	} else {
+		c.breaker.done(probe, err)
+	}
+	return response, err
+}
+
+// sendQuery sends the request of q and decodes the response.
+func (c *client) sendQuery(req *http.Request, q Query) (*Response, error) {
+	ctx := req.Context()
+	resp, err := c.doQuery(req, q)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if err := checkResponse(resp); err != nil {
+	if err := c.checkResponse(resp, c.format); err != nil {
 		return nil, err
 	}
+	size := newSizeReader(resp.Body, q.MaxResponseBytes)
+	resp.Body = size
+	// The body of a response with an error status is kept for its
+	// ErrorResponse.
+	var errBody []byte
+	if resp.StatusCode != http.StatusOK {
+		if errBody, err = ioutil.ReadAll(resp.Body); err != nil {
+			return nil, contextError(ctx, err)
+		}
+		resp.Body = ioutil.NopCloser(bytes.NewReader(errBody))
+	}
 
 	var response Response
 	if q.Chunked {
-		cr := NewChunkedResponse(resp.Body)
+		cr := c.newChunkedResponse(resp, 0)
+		cr.guard = newRowGuard(q)
 		for {
 			r, err := cr.NextResponse()
 			if err != nil {
@@ -572,7 +2023,7 @@ func (c *client) Query(q Query) (*Response, error) {
 					break
 				}
 				// If we got an error while decoding the response, send that back.
-				return nil, err
+				return nil, contextError(ctx, err)
 			}
 
 			if r == nil {
@@ -586,33 +2037,96 @@ func (c *client) Query(q Query) (*Response, error) {
 			}
 		}
 	} else {
-		dec := json.NewDecoder(resp.Body)
-		dec.UseNumber()
-		decErr := dec.Decode(&response)
+		var decErr error
+		if isMsgpack(resp) {
+			decErr = newMsgpackDecoder(resp.Body).Decode(&response)
+		} else {
+			dec := json.NewDecoder(resp.Body)
+			dec.UseNumber()
+			var strict *strictDecoder
+			if c.strict {
+				strict = newStrictDecoder(c.logger)
+			}
+			if decErr, err = decodeJSONResponse(dec, &response, strict); err != nil {
+				return nil, err
+			}
+		}
 
 		// ignore this error if we got an invalid status code
 		if decErr != nil && decErr.Error() == "EOF" && resp.StatusCode != http.StatusOK {
 			decErr = nil
 		}
+		if size.err != nil {
+			return nil, size.err
+		}
 		// If we got a valid decode error, send that back
 		if decErr != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
 			return nil, fmt.Errorf("unable to decode json: received status code %d err: %s", resp.StatusCode, decErr)
 		}
+		if g := newRowGuard(q); g != nil {
+			if err := g.add(response.Results); err != nil {
+				return nil, err
+			}
+		}
 	}
+	convertNumbers(response.Results, c.numbers)
 
-	// If we don't have an error in our json response, and didn't get statusOK
-	// then send back an error
-	if resp.StatusCode != http.StatusOK && response.Error() == nil {
-		return &response, fmt.Errorf("received status code %d from server", resp.StatusCode)
+	if resp.StatusCode != http.StatusOK {
+		if response.Err != "" {
+			response.err = c.errorBody(newErrorResponse(resp, response.Err), errBody)
+		}
+		// If we don't have an error in our json response, and didn't get
+		// statusOK then send back an error
+		if response.Error() == nil {
+			response.Header = resp.Header
+			response.precision = responsePrecision(q.epoch())
+			response.command = q.Command
+			return &response, c.errorBody(newErrorResponse(resp, ""), errBody)
+		}
+	} else if response.Err != "" {
+		// The error of a chunk, which the server sends with a 200 status.
+		response.err = c.errorBody(newErrorResponse(resp, response.Err), encodedError(response.Err))
+	}
+	response.Header = resp.Header
+	response.precision = responsePrecision(q.epoch())
+	response.command = q.Command
+	if q.FailOnPartial {
+		partial := make(partialTracker)
+		partial.add(response.Results)
+		if err := partial.err(); err != nil {
+			return &response, err
+		}
 	}
 	return &response, nil
 }
 
 // QueryAsChunk sends a command to the server and returns the Response.
 func (c *client) QueryAsChunk(q Query) (*ChunkedResponse, error) {
-	req, err := c.createDefaultRequest(q)
+	return c.QueryAsChunkContext(context.Background(), q)
+}
+
+// QueryAsChunkContext sends a command to the server bound to ctx and returns
+// the Response. Canceling ctx aborts any read blocked in NextResponse.
+func (c *client) QueryAsChunkContext(ctx context.Context, q Query) (*ChunkedResponse, error) {
+	q.Command = q.limitCommand()
+	start := time.Now()
+	ctx, end := c.startSpan(ctx, SpanQueryChunked)
+	ctx, cancel := context.WithCancel(ctx)
+	ctx, release := c.withQueryTimeout(ctx, q)
+	fail := func(err error) error {
+		err = release(err)
+		cancel()
+		if end != nil {
+			end(err, querySpanAttrs(q, spanStatus(err, 0)))
+		}
+		return c.queryDone(q, start, err)
+	}
+	req, err := c.createDefaultRequest(ctx, q)
 	if err != nil {
-		return nil, err
+		return nil, fail(err)
 	}
 	params := req.URL.Query()
 	params.Set("chunked", "true")
@@ -620,76 +2134,203 @@ func (c *client) QueryAsChunk(q Query) (*ChunkedResponse, error) {
 		params.Set("chunk_size", strconv.Itoa(q.ChunkSize))
 	}
 	req.URL.RawQuery = params.Encode()
-	resp, err := c.httpClient.Do(req)
+
+	probe, err := c.breaker.allow()
 	if err != nil {
-		return nil, err
+		return nil, fail(err)
+	}
+	resp, err := c.doQuery(req, q)
+	if err == nil {
+		err = c.checkResponse(resp, c.format)
+		if err != nil {
+			resp.Body.Close()
+		}
+	}
+	c.breaker.done(probe, err)
+	if err != nil {
+		return nil, fail(err)
+	}
+	timeout := q.ChunkReadTimeout
+	if timeout <= 0 {
+		timeout = c.chunkReadTimeout
+	}
+	var idle *idleReader
+	if timeout > 0 {
+		idle = &idleReader{ReadCloser: resp.Body, timeout: timeout, abort: cancel}
+		resp.Body = idle
+	}
+	cr := c.newChunkedResponse(resp, q.MaxResponseBytes)
+	cr.resp = resp
+	cr.maxErrorBody = c.maxErrorBody
+	cr.ctx = ctx
+	cr.idle = idle
+	cr.precision = responsePrecision(q.epoch())
+	cr.Header = resp.Header
+	cr.release = release
+	cr.cancel = cancel
+	cr.logger = c.logger
+	cr.numbers = c.numbers
+	if q.FailOnPartial {
+		cr.partial = make(partialTracker)
 	}
+	cr.resyncOnError = q.ResyncOnDecodeError
+	cr.guard = newRowGuard(q)
+	if c.stats != nil || end != nil {
+		status := resp.StatusCode
+		cr.done = func(err error) {
+			if c.stats != nil {
+				c.stats.QueryDone(q.Command, time.Since(start), err)
+			}
+			if end != nil {
+				end(err, querySpanAttrs(q, status))
+			}
+		}
+	}
+	return cr, nil
+}
 
-	if err := checkResponse(resp); err != nil {
-		return nil, err
+// isMsgpack reports whether resp carries a MessagePack body.
+func isMsgpack(resp *http.Response) bool {
+	cType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return cType == msgpackContentType
+}
+
+// newChunkedResponse returns a ChunkedResponse decoding resp in the format the
+// server answered with, of up to limit bytes if it is positive.
+func (c *client) newChunkedResponse(resp *http.Response, limit int64) *ChunkedResponse {
+	size := newSizeReader(resp.Body, limit)
+	if isMsgpack(resp) {
+		return &ChunkedResponse{
+			duplex:  &duplexReader{r: size, w: ioutil.Discard},
+			msgpack: newMsgpackDecoder(size),
+			size:    size,
+		}
 	}
-	return NewChunkedResponse(resp.Body), nil
+	cr := newJSONChunkedResponse(size)
+	if c.strict {
+		cr.strict = newStrictDecoder(c.logger)
+	}
+	return cr
+}
+
+// checkResponse checks resp as checkResponse does, and as
+// checkStrictResponse does with StrictResponses for a query in format.
+func (c *client) checkResponse(resp *http.Response, format ResponseFormat) error {
+	if err := checkResponse(resp, c.maxErrorBody); err != nil {
+		return err
+	}
+	if c.strict {
+		return checkStrictResponse(resp, format)
+	}
+	return nil
 }
 
-func checkResponse(resp *http.Response) error {
+// checkResponse returns the error of resp if it is not a response of the
+// server, as for a proxy in front of it. An error status makes it an
+// ErrorResponse holding the first maxBody bytes of the body.
+func checkResponse(resp *http.Response, maxBody int) error {
+	if err := unfollowedRedirect(resp); err != nil {
+		return err
+	}
+
 	// If we lack a X-Influxdb-Version header, then we didn't get a response from influxdb
 	// but instead some other service. If the error code is also a 500+ code, then some
 	// downstream loadbalancer/proxy/etc had an issue and we should report that.
 	if resp.Header.Get("X-Influxdb-Version") == "" && resp.StatusCode >= http.StatusInternalServerError {
 		body, err := ioutil.ReadAll(resp.Body)
 		if err != nil || len(body) == 0 {
-			return fmt.Errorf("received status code %d from downstream server", resp.StatusCode)
+			return newUnexpectedResponse(resp, nil, maxBody, "received status code %d from downstream server", resp.StatusCode)
 		}
 
-		return fmt.Errorf("received status code %d from downstream server, with response body: %q", resp.StatusCode, body)
+		return newUnexpectedResponse(resp, body, maxBody, "received status code %d from downstream server, with response body: %q", resp.StatusCode, body)
 	}
 
 	// If we get an unexpected content type, then it is also not from influx direct and therefore
 	// we want to know what we received and what status code was returned for debugging purposes.
-	if cType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); cType != "application/json" {
+	if cType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); cType != jsonContentType && cType != msgpackContentType {
 		// Read up to 1kb of the body to help identify downstream errors and limit the impact of things
 		// like downstream serving a large file
 		body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		// A gateway rate limiting requests answers in a format of its own.
+		if resp.StatusCode == http.StatusTooManyRequests {
+			return setErrorBody(newErrorResponseBody(resp, body), body, maxBody)
+		}
 		if err != nil || len(body) == 0 {
-			return fmt.Errorf("expected json response, got empty body, with status: %v", resp.StatusCode)
+			return newUnexpectedResponse(resp, nil, maxBody, "expected json response, got empty body, with status: %v", resp.StatusCode)
 		}
 
-		return fmt.Errorf("expected json response, got %q, with status: %v and response body: %q", cType, resp.StatusCode, body)
+		return newUnexpectedResponse(resp, body, maxBody, "expected json response, got %q, with status: %v and response body: %q", cType, resp.StatusCode, body)
 	}
 	return nil
 }
 
-func (c *client) createDefaultRequest(q Query) (*http.Request, error) {
-	u := c.url
+func (c *client) createDefaultRequest(ctx context.Context, q Query) (*http.Request, error) {
+	u := c.queryEP.url
 	u.Path = path.Join(u.Path, "query")
 
+	switch q.Epoch {
+	case "", "ns", "u", "ms", "s", "m", "h":
+	default:
+		return nil, fmt.Errorf("invalid epoch %q: must be one of ns, u, ms, s, m or h", q.Epoch)
+	}
+	if q.ChunkSize < 0 {
+		return nil, fmt.Errorf("invalid chunk size %d: must be positive", q.ChunkSize)
+	}
+	if err := validateParameters(q.Parameters); err != nil {
+		return nil, err
+	}
+	if err := checkHeaders(q.Headers, c.encoding); err != nil {
+		return nil, err
+	}
 	jsonParameters, err := json.Marshal(q.Parameters)
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", u.String(), nil)
+	form := url.Values{}
+	form.Set("q", q.Command)
+	form.Set("params", string(jsonParameters))
+	encoded := form.Encode()
+
+	// A long command and its parameters are sent in the body, leaving the
+	// other parameters in the URL.
+	method := q.method(len(encoded))
+	var body io.Reader
+	if method == "POST" && len(encoded) > MaxQueryURLLength {
+		body = strings.NewReader(encoded)
+	}
+
+	// A strings.Reader body gets a GetBody, see write.
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("Content-Type", "")
-	req.Header.Set("User-Agent", c.useragent)
-
-	if c.username != "" {
-		req.SetBasicAuth(c.username, c.password)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else if method == "POST" {
+		req.Header.Set("Content-Type", "")
+	}
+	if c.queryFormat(ctx) == MsgpackFormat {
+		req.Header.Set("Accept", msgpackContentType)
+	}
+	c.setHeaders(req, q.Headers)
+	if c.acceptGzip {
+		req.Header.Set("Accept-Encoding", "gzip")
 	}
 
 	params := req.URL.Query()
-	params.Set("q", q.Command)
+	if body == nil {
+		params.Set("q", q.Command)
+		params.Set("params", string(jsonParameters))
+	}
 	params.Set("db", q.Database)
 	if q.RetentionPolicy != "" {
 		params.Set("rp", q.RetentionPolicy)
 	}
-	params.Set("params", string(jsonParameters))
 
-	if q.Precision != "" {
-		params.Set("epoch", q.Precision)
+	if epoch := q.epoch(); epoch != "" {
+		params.Set("epoch", epoch)
 	}
 	req.URL.RawQuery = params.Encode()
 
@@ -697,11 +2338,135 @@ func (c *client) createDefaultRequest(q Query) (*http.Request, error) {
 
 }
 
+// validateParameters checks that every bound parameter has a type the server
+// can bind. Values are sent as JSON and escaped by the server, never by the client.
+func validateParameters(parameters map[string]interface{}) error {
+	for name, v := range parameters {
+		switch v.(type) {
+		case string, float64, int64, bool:
+		default:
+			return fmt.Errorf("unsupported type %T for bound parameter %q: must be string, float64, int64 or bool", v, name)
+		}
+	}
+	return nil
+}
+
+// redactURL returns the URL s with the value of its p parameter, the password
+// of AuthViaParams, replaced.
+func redactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil {
+		return s
+	}
+	params := u.Query()
+	if _, ok := params["p"]; !ok {
+		return s
+	}
+	params.Set("p", "xxxxx")
+	u.RawQuery = params.Encode()
+	return u.String()
+}
+
+// doQuery sends the request of q. A query with its own Timeout or
+// AutoKillAfter is not bound by the client's timeout.
+func (c *client) doQuery(req *http.Request, q Query) (*http.Response, error) {
+	hc := c.queryEP.httpClient
+	if q.timeout() > 0 {
+		hc = c.queryEP.untimedClient
+	}
+	resp, err := c.doWith(&c.queryEP, hc, req)
+	if err != nil {
+		return nil, err
+	}
+	decompressBody(resp)
+	return resp, nil
+}
+
+// do sends req to ep and, if the request's context ended before a response
+// was received, reports the context error instead of the transport error.
+func (c *client) do(ep *endpoint, req *http.Request) (*http.Response, error) {
+	return c.doWith(ep, ep.httpClient, req)
+}
+
+// doWith adds the credentials of ep to req and sends it with hc. A request
+// rejected with 401 Unauthorized is sent once more if the
+// CredentialsProvider returns new credentials.
+func (c *client) doWith(ep *endpoint, hc *http.Client, req *http.Request) (*http.Response, error) {
+	creds, err := c.auth(req.Context(), ep)
+	if err != nil {
+		return nil, err
+	}
+	c.setAuth(req, creds)
+	resp, err := c.send(hc, req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || ep.creds != nil {
+		return resp, err
+	}
+	if retry, ok := c.reauthRequest(req, resp, creds); ok {
+		return c.send(hc, retry)
+	}
+	return resp, nil
+}
+
+// send sends req with hc.
+func (c *client) send(hc *http.Client, req *http.Request) (*http.Response, error) {
+	if err := c.drain.begin(); err != nil {
+		return nil, err
+	}
+	var dumped func()
+	if c.dumper != nil {
+		dumped = c.dumper.teeRequest(req)
+	}
+	resp, err := hc.Do(req)
+	if dumped != nil {
+		dumped()
+	}
+	if err != nil {
+		c.drain.end()
+		if ue, ok := err.(*url.Error); ok {
+			ue.URL = redactURL(ue.URL)
+		}
+		return nil, contextError(req.Context(), err)
+	}
+	resp.Body = &drainBody{ReadCloser: resp.Body, end: c.drain.end}
+	if c.dumper != nil {
+		c.dumper.teeResponse(resp)
+	}
+	return resp, nil
+}
+
+// contextError returns ctx.Err() if err is non-nil and ctx is done, and err
+// otherwise.
+func contextError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if ctxErr := ctxError(ctx); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+// ctxError is like ctx.Err, but also reports a deadline that has passed
+// before the context's timer fired. I/O deadlines taken from ctx can expire
+// first.
+func ctxError(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if d, ok := ctx.Deadline(); ok && !time.Now().Before(d) {
+		return context.DeadlineExceeded
+	}
+	return nil
+}
+
 // duplexReader reads responses and writes it to another writer while
 // satisfying the reader interface.
 type duplexReader struct {
 	r io.ReadCloser
 	w io.Writer
+
+	// err is the error of the last read.
+	err error
 }
 
 func (r *duplexReader) Read(p []byte) (n int, err error) {
@@ -709,6 +2474,7 @@ func (r *duplexReader) Read(p []byte) (n int, err error) {
 	if err == nil {
 		r.w.Write(p[:n])
 	}
+	r.err = err
 	return n, err
 }
 
@@ -720,9 +2486,79 @@ func (r *duplexReader) Close() error {
 // ChunkedResponse represents a response from the server that
 // uses chunking to stream the output.
 type ChunkedResponse struct {
+	// Header holds the headers of the HTTP response, available before the
+	// first chunk is read. It is nil if the ChunkedResponse was created by
+	// NewChunkedResponse.
+	Header http.Header
+
 	dec    *json.Decoder
 	duplex *duplexReader
 	buf    bytes.Buffer
+
+	// msgpack, if set, decodes the stream instead of dec.
+	msgpack *msgpackDecoder
+
+	// precision is the precision of the times of the response, used by
+	// Scan.
+	precision string
+
+	// resp, if set, is the response of the chunked query, whose errors are
+	// ErrorResponses with bodies of up to maxErrorBody bytes.
+	resp         *http.Response
+	maxErrorBody int
+
+	// release, if set, releases the query's context on Close and maps
+	// errors caused by the query's timeout.
+	release func(err error) error
+
+	// cancel, if set, cancels the request on Close.
+	cancel context.CancelFunc
+
+	// ctx, if set, is the context of the request, reported instead of the
+	// error of a read it interrupted.
+	ctx context.Context
+
+	// idle, if set, is the body of the response with its ChunkReadTimeout.
+	idle *idleReader
+
+	// done, if set, is called once with the outcome of the query when the
+	// stream ends or is closed. queryErr is the first error of a response
+	// read so far, and messages the messages of the responses read so far.
+	// Close and Messages may be called while NextResponse reads, so they
+	// are guarded by mu.
+	mu       sync.Mutex
+	done     func(err error)
+	queryErr error
+	messages []Message
+
+	// partial, if set, tracks the truncated statements of a query with
+	// FailOnPartial.
+	partial partialTracker
+
+	numbers NumberDecoding
+
+	// strict, if set, decodes the JSON responses of a query with
+	// StrictResponses.
+	strict *strictDecoder
+
+	// resyncOnError skips what cannot be decoded, see
+	// Query.ResyncOnDecodeError. src, if set, is the reader dec reads from
+	// in place of duplex, offset the position in the stream it started
+	// reading at, and decodeErrors, guarded by mu, the parts skipped.
+	resyncOnError bool
+	src           io.Reader
+	offset        int64
+	decodeErrors  []ChunkDecodeError
+
+	// guard, if set, aborts the response once it has too many rows, with
+	// the error kept in guardErr for the reads that follow.
+	guard    *rowGuard
+	guardErr error
+
+	// size is the stream, counting the bytes read.
+	size *sizeReader
+
+	logger Logger
 }
 
 // NewChunkedResponse reads a stream and produces responses from the stream.
@@ -731,8 +2567,14 @@ func NewChunkedResponse(r io.Reader) *ChunkedResponse {
 	if !ok {
 		rc = ioutil.NopCloser(r)
 	}
-	resp := &ChunkedResponse{}
-	resp.duplex = &duplexReader{r: rc, w: &resp.buf}
+	return newJSONChunkedResponse(newSizeReader(rc, 0))
+}
+
+// newJSONChunkedResponse returns a ChunkedResponse decoding the JSON
+// responses of size.
+func newJSONChunkedResponse(size *sizeReader) *ChunkedResponse {
+	resp := &ChunkedResponse{size: size}
+	resp.duplex = &duplexReader{r: size, w: &resp.buf}
 	resp.dec = json.NewDecoder(resp.duplex)
 	resp.dec.UseNumber()
 	return resp
@@ -740,11 +2582,128 @@ func NewChunkedResponse(r io.Reader) *ChunkedResponse {
 
 // NextResponse reads the next line of the stream and returns a response.
 func (r *ChunkedResponse) NextResponse() (*Response, error) {
-	var response Response
-	if err := r.dec.Decode(&response); err != nil {
+	return r.next(nil)
+}
+
+// NextResponseInto is like NextResponse, but decodes the next response into
+// resp instead of a new one, reusing the memory of the results, series and
+// values of the response resp held, so that reading a long stream does not
+// allocate them anew for every chunk. The previous response is overwritten,
+// along with the series and rows taken from it: only the last response
+// decoded into resp may be used, and its empty slices may not be nil. Memory
+// is only reused for JSON responses.
+func (r *ChunkedResponse) NextResponseInto(resp *Response) error {
+	_, err := r.next(resp)
+	return err
+}
+
+// next reads the next response, into the given one if not nil.
+func (r *ChunkedResponse) next(into *Response) (*Response, error) {
+	if r.guardErr != nil {
+		return nil, r.guardErr
+	}
+	resp, err := r.nextResponse(into)
+	if err != nil && err != io.EOF {
+		switch {
+		case r.idle != nil && r.idle.timedOut():
+			err = &ChunkReadTimeoutError{Timeout: r.idle.timeout}
+			r.duplex.Close()
+		case r.ctx != nil:
+			err = contextError(r.ctx, err)
+		}
+		if r.release != nil {
+			err = r.release(err)
+		}
+	}
+	if resp != nil && resp.Err != "" && r.resp != nil {
+		resp.err = setErrorBody(newErrorResponse(r.resp, resp.Err), encodedError(resp.Err), r.maxErrorBody)
+	}
+	if resp != nil {
+		resp.Header = r.Header
+		resp.precision = r.precision
+		convertNumbers(resp.Results, r.numbers)
+		r.mu.Lock()
+		if r.queryErr == nil {
+			r.queryErr = resp.Error()
+		}
+		r.messages = appendMessages(r.messages, resp.Results)
+		r.mu.Unlock()
+		if r.partial != nil {
+			r.partial.add(resp.Results)
+		}
+		if r.guard != nil {
+			if gerr := r.guard.add(resp.Results); gerr != nil {
+				// The rest of the response is not read.
+				logf(r.logger, "influxdb: aborting chunked response: %v", gerr)
+				r.guardErr = gerr
+				r.finish(gerr)
+				r.Close()
+				return nil, gerr
+			}
+		}
+	}
+	if err == io.EOF && r.partial != nil {
+		if perr := r.partial.err(); perr != nil {
+			r.partial = nil
+			r.finish(perr)
+			return nil, perr
+		}
+	}
+	if err == io.EOF {
+		r.finish(nil)
+	} else if err != nil {
+		logf(r.logger, "influxdb: reading chunked response failed: %v", err)
+		r.finish(err)
+	}
+	return resp, err
+}
+
+// finish calls done with err, or the first error of a response read if err
+// is nil, unless it was called before.
+func (r *ChunkedResponse) finish(err error) {
+	r.mu.Lock()
+	done := r.done
+	r.done = nil
+	if err == nil {
+		err = r.queryErr
+	}
+	r.mu.Unlock()
+	if done != nil {
+		done(err)
+	}
+}
+
+func (r *ChunkedResponse) nextResponse(into *Response) (*Response, error) {
+	response := into
+	if response == nil {
+		response = &Response{}
+	} else {
+		response.reuse()
+	}
+	if r.msgpack != nil {
+		if err := r.msgpack.Decode(response); err != nil {
+			return nil, err
+		}
+		return response, nil
+	}
+	err, strictErr := decodeJSONResponse(r.dec, response, r.strict)
+	if strictErr != nil {
+		return nil, strictErr
+	}
+	if err != nil {
 		if err == io.EOF {
 			return nil, err
 		}
+		if r.duplex.err != nil && r.duplex.err != io.EOF {
+			// Reading failed, as when the request was canceled.
+			return nil, r.duplex.err
+		}
+		if r.resyncOnError {
+			if err := r.resync(err); err != nil {
+				return nil, err
+			}
+			return r.nextResponse(into)
+		}
 		// A decoding error happened. This probably means the server crashed
 		// and sent a last-ditch error message to us. Ensure we have read the
 		// entirety of the connection to get any remaining error text.
@@ -753,10 +2712,39 @@ func (r *ChunkedResponse) NextResponse() (*Response, error) {
 	}
 
 	r.buf.Reset()
-	return &response, nil
+	return response, nil
 }
 
-// Close closes the response.
+// reuse empties resp for a response to be decoded into it, keeping the
+// memory of its results, series and values. encoding/json decodes the
+// elements of a slice into those past its length, so the ones of the previous
+// response are emptied down to their capacity.
+func (resp *Response) reuse() {
+	results := resp.Results[:cap(resp.Results)]
+	for i := range results {
+		series := results[i].Series[:cap(results[i].Series)]
+		for j := range series {
+			values := series[j].Values[:cap(series[j].Values)]
+			for k := range values {
+				values[k] = values[k][:0]
+			}
+			series[j] = models.Row{Columns: series[j].Columns[:0], Values: series[j].Values[:0]}
+		}
+		results[i] = Result{Series: results[i].Series[:0]}
+	}
+	*resp = Response{Results: resp.Results[:0]}
+}
+
+// Close closes the response. For a response of QueryAsChunk it cancels the
+// request, so that the rest of the results is not read from the server.
 func (r *ChunkedResponse) Close() error {
-	return r.duplex.Close()
+	if r.cancel != nil {
+		r.cancel()
+	}
+	err := r.duplex.Close()
+	if r.release != nil {
+		r.release(nil)
+	}
+	r.finish(nil)
+	return err
 }
@@ -1,18 +1,29 @@
 package client
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"math"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"path"
 	"reflect"
+	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
 )
 
 func TestUDPClient_Query(t *testing.T) {
@@ -108,7 +119,7 @@ func TestUDPClient_Split(t *testing.T) {
 	var cl udpclient
 
 	cl.conn = &logger
-	cl.payloadSize = 1 // force one field per point
+	cl.payloadSize = 20 // force one field per point
 
 	fields := map[string]interface{}{"a": 1, "b": 2, "c": 3, "d": 4}
 
@@ -127,6 +138,253 @@ func TestUDPClient_Split(t *testing.T) {
 	}
 }
 
+func TestUDPClient_WriteError(t *testing.T) {
+	w := &failingWriter{n: 2}
+	cl := &udpclient{conn: w, payloadSize: 20} // two points per datagram
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	p, _ := NewPoint("cpu", nil, map[string]interface{}{"a": 1}, time.Time{})
+	for i := 0; i < 9; i++ {
+		bp.AddPoint(p)
+	}
+
+	// Only the failed datagrams' points are dropped.
+	err := cl.Write(bp)
+	var writeErr *WriteError
+	if !errors.As(err, &writeErr) {
+		t.Fatalf("unexpected error.  expected %T, actual %v", writeErr, err)
+	}
+	if writeErr.PointsWritten != 2 || writeErr.PointsDropped != 7 || len(writeErr.Errs) != 4 {
+		t.Errorf("unexpected result.  expected 2 written, 7 dropped and 4 errors, actual %d, %d and %d", writeErr.PointsWritten, writeErr.PointsDropped, len(writeErr.Errs))
+	}
+}
+
+func TestUDPClient_WriteErrorSplit(t *testing.T) {
+	w := &failingWriter{n: 2}
+	cl := &udpclient{conn: w, payloadSize: 20} // force one field per datagram
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	p1, _ := NewPoint("cpu", nil, map[string]interface{}{"a": 1, "b": 2}, time.Unix(1, 0))
+	p2, _ := NewPoint("cpu", nil, map[string]interface{}{"a": 1}, time.Unix(1, 0))
+	bp.AddPoints([]*Point{p1, p2})
+
+	// The second half of p1 fails, so p1 counts as dropped even though its
+	// first field was sent.
+	err := cl.Write(bp)
+	var writeErr *WriteError
+	if !errors.As(err, &writeErr) {
+		t.Fatalf("unexpected error.  expected %T, actual %v", writeErr, err)
+	}
+	if writeErr.PointsWritten != 0 || writeErr.PointsDropped != 2 {
+		t.Errorf("unexpected result.  expected 0 written and 2 dropped, actual %d and %d", writeErr.PointsWritten, writeErr.PointsDropped)
+	}
+}
+
+func TestUDPClient_SplitZeroTime(t *testing.T) {
+	var logger writeLogger
+	cl := &udpclient{conn: &logger, payloadSize: 64}
+
+	fields := map[string]interface{}{}
+	for _, k := range []string{"a", "b", "c", "d"} {
+		fields[k] = strings.Repeat(k, 20)
+	}
+	p, _ := NewPoint("cpu", nil, fields, time.Time{})
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	bp.AddPoint(p)
+
+	if err := cl.Write(bp); err != nil {
+		t.Fatalf("Unexpected error during Write: %v", err)
+	}
+	if len(logger.writes) < 2 {
+		t.Fatalf("expected the point to be split, got %d writes", len(logger.writes))
+	}
+
+	// Every part carries the same timestamp, so the server stores one point.
+	var ts string
+	for _, w := range logger.writes {
+		if len(w) > 64 {
+			t.Errorf("payload of %d bytes exceeds the payload size", len(w))
+		}
+		pts, err := models.ParsePoints(w)
+		if err != nil || len(pts) != 1 {
+			t.Fatalf("unexpected payload %q: %v", w, err)
+		}
+		if pts[0].Time().IsZero() {
+			t.Fatalf("expected a timestamp in %q", w)
+		}
+		if got := pts[0].Time().String(); ts == "" {
+			ts = got
+		} else if got != ts {
+			t.Errorf("unexpected timestamp.  expected %v, actual %v", ts, got)
+		}
+	}
+}
+
+func TestUDPClient_PointTooLarge(t *testing.T) {
+	var logger writeLogger
+	cl := &udpclient{conn: &logger, payloadSize: 10}
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	big, _ := NewPoint("cpu", nil, map[string]interface{}{"msg": strings.Repeat("x", 4096)}, time.Unix(1, 0))
+	small, _ := NewPoint("m", nil, map[string]interface{}{"v": 1}, time.Time{})
+	bp.AddPoints([]*Point{small, big, small})
+
+	err := cl.Write(bp)
+	var writeErr *WriteError
+	if !errors.As(err, &writeErr) {
+		t.Fatalf("unexpected error.  expected %T, actual %v", writeErr, err)
+	}
+	if writeErr.PointsWritten != 2 || writeErr.PointsDropped != 1 {
+		t.Errorf("unexpected result.  expected 2 written and 1 dropped, actual %d and %d", writeErr.PointsWritten, writeErr.PointsDropped)
+	}
+	var tooLarge *PointTooLargeError
+	if !errors.As(err, &tooLarge) {
+		t.Fatalf("unexpected error.  expected %T, actual %v", tooLarge, err)
+	}
+	if tooLarge.Measurement != "cpu" || tooLarge.Field != "msg" || tooLarge.PayloadSize != 10 {
+		t.Errorf("unexpected error: %+v", tooLarge)
+	}
+	for _, w := range logger.writes {
+		if len(w) > 10 {
+			t.Errorf("payload of %d bytes exceeds the payload size", len(w))
+		}
+	}
+}
+
+func TestUDPClient_PointTooLargeMidBatch(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	defer server.Close()
+	c, err := NewUDPClient(UDPConfig{Addr: server.LocalAddr().String(), PayloadSize: 512})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	defer c.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	small, _ := NewPoint("m", nil, map[string]interface{}{"v": 1}, time.Unix(1, 0))
+	big, _ := NewPoint("logs", nil, map[string]interface{}{"msg": strings.Repeat("x", 2048)}, time.Unix(1, 0))
+	bp.AddPoints([]*Point{small, small, big, small})
+
+	err = c.Write(bp)
+	var tooLarge *PointTooLargeError
+	if !errors.As(err, &tooLarge) {
+		t.Fatalf("unexpected error.  expected %T, actual %v", tooLarge, err)
+	}
+	if tooLarge.Measurement != "logs" || tooLarge.Field != "msg" || tooLarge.PayloadSize != 512 {
+		t.Errorf("unexpected error: %+v", tooLarge)
+	}
+
+	var received string
+	buf := make([]byte, 4096)
+	for strings.Count(received, "\n") < 3 {
+		server.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := server.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("unexpected error receiving the datagrams of %q.  expected %v, actual %v", received, nil, err)
+		}
+		if n > 512 {
+			t.Errorf("datagram of %d bytes exceeds the payload size", n)
+		}
+		received += string(buf[:n])
+	}
+	if exp := strings.Repeat("m v=1i 1000000000\n", 3); received != exp {
+		t.Errorf("unexpected datagrams.  expected %q, actual %q", exp, received)
+	}
+	// Nothing else, as the large point, is sent.
+	server.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	if n, _, err := server.ReadFrom(buf); err == nil {
+		t.Errorf("unexpected datagram of %d bytes", n)
+	}
+}
+
+func TestTCPClient_PointTooLargeDoesNotStop(t *testing.T) {
+	w := &bufferConn{}
+	cl := &tcpclient{conn: w, payloadSize: 10}
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	small, _ := NewPoint("m", nil, map[string]interface{}{"v": 1}, time.Time{})
+	big, _ := NewPoint("cpu", nil, map[string]interface{}{"a": strings.Repeat("x", 2048), "b": strings.Repeat("y", 2048)}, time.Time{})
+	bp.AddPoints([]*Point{big, small})
+
+	err := cl.Write(bp)
+	var writeErr *WriteError
+	if !errors.As(err, &writeErr) {
+		t.Fatalf("unexpected error.  expected %T, actual %v", writeErr, err)
+	}
+	if writeErr.PointsWritten != 1 || writeErr.PointsDropped != 1 {
+		t.Errorf("unexpected result.  expected 1 written and 1 dropped, actual %d and %d", writeErr.PointsWritten, writeErr.PointsDropped)
+	}
+	if exp := "m v=1i\n"; w.String() != exp {
+		t.Errorf("unexpected data.  expected %q, actual %q", exp, w.String())
+	}
+}
+
+func TestTCPClient_PointTooLarge(t *testing.T) {
+	conn := &payloadConn{}
+	cl := &tcpclient{conn: conn, payloadSize: 512}
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	small, _ := NewPoint("m", nil, map[string]interface{}{"v": 1}, time.Unix(1, 0))
+	big, _ := NewPoint("logs", nil, map[string]interface{}{"msg": strings.Repeat("x", 2048)}, time.Unix(1, 0))
+	bp.AddPoints([]*Point{small, small, big, small})
+
+	err := cl.Write(bp)
+	var writeErr *WriteError
+	if !errors.As(err, &writeErr) {
+		t.Fatalf("unexpected error.  expected %T, actual %v", writeErr, err)
+	}
+	if writeErr.PointsWritten != 3 || writeErr.PointsDropped != 1 || len(writeErr.Errs) != 1 {
+		t.Errorf("unexpected result.  expected 3 written and 1 dropped, actual %+v", writeErr)
+	}
+	var tooLarge *PointTooLargeError
+	if !errors.As(err, &tooLarge) {
+		t.Fatalf("unexpected error.  expected %T, actual %v", tooLarge, err)
+	}
+	if tooLarge.Measurement != "logs" || tooLarge.Field != "msg" || tooLarge.Size <= 2048 || tooLarge.PayloadSize != 512 {
+		t.Errorf("unexpected error: %+v", tooLarge)
+	}
+	exp := []string{"m v=1i 1000000000\nm v=1i 1000000000\n", "m v=1i 1000000000\n"}
+	if !reflect.DeepEqual(conn.payloads, exp) {
+		t.Errorf("unexpected payloads.  expected %q, actual %q", exp, conn.payloads)
+	}
+}
+
+func TestUDPClient_WriteDoesNotRoundPoints(t *testing.T) {
+	var logger writeLogger
+	cl := &udpclient{conn: &logger, payloadSize: UDPPayloadSize}
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{Precision: "s"})
+	p, _ := NewPoint("cpu", nil, map[string]interface{}{"value": 1.0}, time.Unix(1, 123456789))
+	bp.AddPoint(p)
+
+	if err := cl.Write(bp); err != nil {
+		t.Fatalf("Unexpected error during Write: %v", err)
+	}
+
+	// Writing the same batch again with a finer precision must still see the
+	// original timestamp.
+	bp.SetPrecision("ns")
+	if err := cl.Write(bp); err != nil {
+		t.Fatalf("Unexpected error during Write: %v", err)
+	}
+
+	exp := []string{"cpu value=1 1000000000\n", "cpu value=1 1123456789\n"}
+	if len(logger.writes) != len(exp) {
+		t.Fatalf("Mismatched write count: got %v, exp %v", len(logger.writes), len(exp))
+	}
+	for i := range exp {
+		if got := string(logger.writes[i]); got != exp[i] {
+			t.Errorf("unexpected payload %d.  expected %q, actual %q", i, exp[i], got)
+		}
+	}
+	if got, exp := p.UnixNano(), int64(1123456789); got != exp {
+		t.Errorf("point was modified.  expected %d, actual %d", exp, got)
+	}
+}
+
 type writeLogger struct {
 	writes [][]byte
 }
@@ -484,6 +742,75 @@ func TestClient_BoundParameters(t *testing.T) {
 	}
 }
 
+func TestClient_BoundParametersEscaping(t *testing.T) {
+	var commands, parameters []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		commands = append(commands, r.FormValue("q"))
+		parameters = append(parameters, r.FormValue("params"))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(Response{})
+	}))
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	const command = `SELECT * FROM cpu WHERE host = $host AND value > $min AND up = $up`
+	expectedParameters := map[string]interface{}{
+		"host": `o'brien\'s "box"`,
+		"min":  int64(10),
+		"up":   true,
+	}
+	query := NewQueryWithParameters(command, "db0", "", expectedParameters)
+
+	if _, err := c.Query(query); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	resp, err := c.QueryAsChunk(query)
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	resp.Close()
+
+	for i := range commands {
+		// The command is sent as is, values are bound by the server.
+		if commands[i] != command {
+			t.Errorf("unexpected command. expected %q, actual %q", command, commands[i])
+		}
+		var actual map[string]interface{}
+		d := json.NewDecoder(strings.NewReader(parameters[i]))
+		d.UseNumber()
+		if err := d.Decode(&actual); err != nil {
+			t.Fatalf("unexpected error. expected %v, actual %v", nil, err)
+		}
+		if actual["host"] != expectedParameters["host"] {
+			t.Errorf("unexpected host. expected %q, actual %q", expectedParameters["host"], actual["host"])
+		}
+		if actual["min"] != json.Number("10") || actual["up"] != true {
+			t.Errorf("unexpected parameters: %v", actual)
+		}
+	}
+	if len(commands) != 2 {
+		t.Errorf("unexpected number of requests. expected %d, actual %d", 2, len(commands))
+	}
+}
+
+func TestClient_BoundParametersUnsupportedType(t *testing.T) {
+	c, _ := NewHTTPClient(HTTPConfig{Addr: "http://localhost:8086"})
+	defer c.Close()
+
+	query := NewQueryWithParameters("SELECT * FROM cpu WHERE v > $min", "db0", "", map[string]interface{}{"min": []int{1}})
+	exp := `unsupported type []int for bound parameter "min": must be string, float64, int64 or bool`
+	if _, err := c.Query(query); err == nil || err.Error() != exp {
+		t.Errorf("unexpected error. expected %v, actual %v", exp, err)
+	}
+	if _, err := c.QueryAsChunk(query); err == nil || err.Error() != exp {
+		t.Errorf("unexpected error. expected %v, actual %v", exp, err)
+	}
+}
+
 func TestClient_BasicAuth(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		u, p, ok := r.BasicAuth()
@@ -515,6 +842,112 @@ func TestClient_BasicAuth(t *testing.T) {
 	}
 }
 
+func TestClient_AuthToken(t *testing.T) {
+	var paths []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if got, exp := r.Header.Get("Authorization"), "Token user:pass"; got != exp {
+			t.Errorf("unexpected Authorization header.  expected %q, actual %q", exp, got)
+		}
+		if r.URL.Path == "/query" {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{}`))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	c, err := NewHTTPClient(HTTPConfig{Addr: ts.URL, AuthToken: "user:pass"})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	defer c.Close()
+
+	if _, _, err := c.Ping(0); err != nil {
+		t.Errorf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	bp.AddPoints(newTestPoints(1))
+	if err := c.Write(bp); err != nil {
+		t.Errorf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if _, err := c.Query(Query{}); err != nil {
+		t.Errorf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if exp := []string{"/ping", "/write", "/query"}; !reflect.DeepEqual(paths, exp) {
+		t.Errorf("unexpected requests.  expected %v, actual %v", exp, paths)
+	}
+}
+
+func TestClient_AuthTokenWithBasicAuth(t *testing.T) {
+	_, err := NewHTTPClient(HTTPConfig{Addr: "http://localhost:8086", AuthToken: "token", Username: "user"})
+	if exp := "AuthToken cannot be used together with Username and Password"; err == nil || err.Error() != exp {
+		t.Errorf("unexpected error.  expected %v, actual %v", exp, err)
+	}
+}
+
+func TestClient_AuthViaParams(t *testing.T) {
+	const password = "p&ss w=rd?"
+	var requests []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); auth != "" {
+			t.Errorf("unexpected Authorization header for %s: %q", r.URL.Path, auth)
+		}
+		requests = append(requests, r.URL.Path+" "+r.URL.Query().Get("u")+" "+r.URL.Query().Get("p"))
+		switch r.URL.Path {
+		case "/query":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"results":[{}]}`))
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer ts.Close()
+
+	c, err := NewHTTPClient(HTTPConfig{Addr: ts.URL, Username: "user", Password: password, AuthViaParams: true})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	defer c.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0"})
+	bp.AddPoints(newTestPoints(1))
+	if err := c.Write(bp); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if _, err := c.Query(Query{Command: "SHOW DATABASES"}); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if _, _, err := c.Ping(0); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	exp := []string{"/write user " + password, "/query user " + password, "/ping user " + password}
+	if fmt.Sprint(requests) != fmt.Sprint(exp) {
+		t.Errorf("unexpected requests.  expected %q, actual %q", exp, requests)
+	}
+
+	// The password is left out of the errors.
+	ts.Close()
+	for _, err := range []error{c.Write(bp), func() error { _, err := c.Query(Query{Command: "SHOW DATABASES"}); return err }()} {
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+		if msg := err.Error(); strings.Contains(msg, url.QueryEscape(password)) || strings.Contains(msg, password) || !strings.Contains(msg, "p=xxxxx") {
+			t.Errorf("unexpected error: %v", msg)
+		}
+	}
+}
+
+func TestClient_AuthViaParamsWithAuthToken(t *testing.T) {
+	_, err := NewHTTPClient(HTTPConfig{Addr: "http://localhost:8086", AuthToken: "token", AuthViaParams: true})
+	var ce *ConfigError
+	if !errors.As(err, &ce) || ce.Field != "AuthViaParams" {
+		t.Errorf("unexpected error.  expected %T, actual %v", ce, err)
+	}
+}
+
 func TestClient_Ping(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var data Response
@@ -558,6 +991,7 @@ func TestClient_Concurrent_Use(t *testing.T) {
 			errC <- fmt.Errorf("got error %v", err)
 			return
 		}
+		bp.AddPoints(newTestPoints(1))
 
 		for i := 0; i < n; i++ {
 			if err = c.Write(bp); err != nil {
@@ -643,76 +1077,338 @@ func TestClient_Write(t *testing.T) {
 	}
 }
 
-func TestClient_UserAgent(t *testing.T) {
-	receivedUserAgent := ""
+func TestClient_WriteContextCanceled(t *testing.T) {
+	done := make(chan struct{})
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		receivedUserAgent = r.UserAgent()
+		select {
+		case <-done:
+		case <-r.Context().Done():
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+	defer close(done)
 
-		var data Response
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	bp.AddPoints(newTestPoints(1))
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	err := c.(ContextClient).WriteContext(ctx, bp)
+	if err != context.Canceled {
+		t.Errorf("unexpected error.  expected %v, actual %v", context.Canceled, err)
+	}
+}
+
+func TestClient_QueryContextDeadline(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("q") == "slow" {
+			<-r.Context().Done()
+		}
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
-		_ = json.NewEncoder(w).Encode(data)
+		w.Write([]byte(`{}`))
 	}))
 	defer ts.Close()
 
-	_, err := http.Get(ts.URL)
-	if err != nil {
-		t.Errorf("unexpected error.  expected %v, actual %v", nil, err)
-	}
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
 
-	tests := []struct {
-		name      string
-		userAgent string
-		expected  string
-	}{
-		{
-			name:      "Empty user agent",
-			userAgent: "",
-			expected:  "InfluxDBClient",
-		},
-		{
-			name:      "Custom user agent",
-			userAgent: "Test Influx Client",
-			expected:  "Test Influx Client",
-		},
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
 
-	for _, test := range tests {
+	_, err := c.(ContextClient).QueryContext(ctx, Query{Command: "slow"})
+	if err != context.DeadlineExceeded {
+		t.Errorf("unexpected error.  expected %v, actual %v", context.DeadlineExceeded, err)
+	}
 
-		config := HTTPConfig{Addr: ts.URL, UserAgent: test.userAgent}
-		c, _ := NewHTTPClient(config)
-		defer c.Close()
+	// The client remains usable for subsequent requests.
+	if _, err := c.Query(Query{}); err != nil {
+		t.Errorf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+}
 
-		receivedUserAgent = ""
-		query := Query{}
-		_, err = c.Query(query)
-		if err != nil {
-			t.Errorf("unexpected error.  expected %v, actual %v", nil, err)
-		}
-		if !strings.HasPrefix(receivedUserAgent, test.expected) {
-			t.Errorf("Unexpected user agent. expected %v, actual %v", test.expected, receivedUserAgent)
+func TestClient_WriteGzip(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, exp := r.Header.Get("Content-Encoding"), "gzip"; got != exp {
+			t.Errorf("unexpected Content-Encoding: %s != %s", got, exp)
 		}
-
-		receivedUserAgent = ""
-		bp, _ := NewBatchPoints(BatchPointsConfig{})
-		err = c.Write(bp)
+		gr, err := gzip.NewReader(r.Body)
 		if err != nil {
-			t.Errorf("unexpected error.  expected %v, actual %v", nil, err)
+			t.Fatalf("unexpected error: %s", err)
 		}
-		if !strings.HasPrefix(receivedUserAgent, test.expected) {
-			t.Errorf("Unexpected user agent. expected %v, actual %v", test.expected, receivedUserAgent)
+		in, _ := ioutil.ReadAll(gr)
+		if have, want := string(in), "cpu value=1 0\ncpu value=2 0\n"; have != want {
+			t.Errorf("unexpected write protocol: %q != %q", have, want)
 		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
 
-		receivedUserAgent = ""
-		_, err := c.Query(query)
-		if err != nil {
-			t.Errorf("unexpected error.  expected %v, actual %v", nil, err)
-		}
-		if receivedUserAgent != test.expected {
-			t.Errorf("Unexpected user agent. expected %v, actual %v", test.expected, receivedUserAgent)
-		}
+	c, err := NewHTTPClient(HTTPConfig{Addr: ts.URL, WriteEncoding: GzipEncoding, WriteCompressionLevel: gzip.BestSpeed})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
 	}
-}
+	defer c.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	p1, _ := NewPoint("cpu", nil, map[string]interface{}{"value": 1.0}, time.Unix(0, 0))
+	p2, _ := NewPoint("cpu", nil, map[string]interface{}{"value": 2.0}, time.Unix(0, 0))
+	bp.AddPoints([]*Point{p1, p2})
+
+	// Pooled writers must be reset between writes.
+	for i := 0; i < 3; i++ {
+		if err := c.Write(bp); err != nil {
+			t.Errorf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+	}
+}
+
+func TestClient_WriteGzipUnsupported(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		w.Write([]byte("unsupported encoding"))
+	}))
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, WriteEncoding: GzipEncoding})
+	defer c.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	bp.AddPoints(newTestPoints(1))
+	err := c.Write(bp)
+	if exp := "server does not accept gzip encoded writes: unsupported encoding"; err == nil || err.Error() != exp {
+		t.Errorf("unexpected error.  expected %v, actual %v", exp, err)
+	}
+}
+
+func TestClient_InvalidCompressionLevel(t *testing.T) {
+	_, err := NewHTTPClient(HTTPConfig{Addr: "http://localhost:8086", WriteEncoding: GzipEncoding, WriteCompressionLevel: 42})
+	if err == nil {
+		t.Error("expected an error for an invalid compression level")
+	}
+}
+
+func benchmarkClientWrite(b *testing.B, encoding ContentEncoding) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, WriteEncoding: encoding})
+	defer c.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	for i := 0; i < 1000; i++ {
+		p, _ := NewPoint("cpu", map[string]string{"host": "server01"}, map[string]interface{}{"value": float64(i)}, time.Unix(int64(i), 0))
+		bp.AddPoint(p)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := c.Write(bp); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkClient_Write(b *testing.B)     { benchmarkClientWrite(b, DefaultEncoding) }
+func BenchmarkClient_WriteGzip(b *testing.B) { benchmarkClientWrite(b, GzipEncoding) }
+
+func TestClient_QueryTimeout(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("q") == "slow" {
+			<-r.Context().Done()
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, Timeout: 50 * time.Millisecond})
+	defer c.Close()
+
+	_, err := c.Query(Query{Command: "slow", Timeout: 20 * time.Millisecond})
+	var te *QueryTimeoutError
+	if !errors.As(err, &te) || te.Timeout != 20*time.Millisecond {
+		t.Fatalf("unexpected error.  expected %T, actual %v", te, err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the error to wrap %v", context.DeadlineExceeded)
+	}
+
+	// A query timeout longer than the client's lifts the client's limit.
+	if _, err := c.Query(Query{Command: "fast", Timeout: time.Second}); err != nil {
+		t.Errorf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if _, err := c.Query(Query{Command: "fast"}); err == nil {
+		t.Error("expected the client timeout to apply without a query timeout")
+	}
+}
+
+func TestClient_QueryAsChunkTimeout(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results":[{"statement_id":0}]}` + "\n"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	resp, err := c.QueryAsChunk(Query{Command: "SELECT * FROM cpu", Timeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	defer resp.Close()
+
+	if _, err := resp.NextResponse(); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	// The timeout covers reading chunks after the headers arrived.
+	_, err = resp.NextResponse()
+	var te *QueryTimeoutError
+	if !errors.As(err, &te) {
+		t.Errorf("unexpected error.  expected %T, actual %v", te, err)
+	}
+}
+
+// recordingTransport records the paths of the requests it forwards.
+type recordingTransport struct {
+	mu    sync.Mutex
+	paths []string
+}
+
+func (rt *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.mu.Lock()
+	rt.paths = append(rt.paths, req.URL.Path)
+	rt.mu.Unlock()
+	req.Header.Set("X-Trace-Id", "trace")
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestClient_Transport(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("X-Trace-Id"); got != "trace" {
+			t.Errorf("unexpected trace header: %q", got)
+		}
+		switch r.URL.Path {
+		case "/write":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer ts.Close()
+
+	rt := &recordingTransport{}
+	// TLS settings must not replace the caller's transport.
+	c, err := NewHTTPClient(HTTPConfig{Addr: ts.URL, Transport: rt, InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	defer c.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	bp.AddPoints(newTestPoints(1))
+	if err := c.Write(bp); err != nil {
+		t.Errorf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if _, err := c.Query(Query{}); err != nil {
+		t.Errorf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	resp, err := c.QueryAsChunk(Query{Timeout: time.Second})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	resp.Close()
+
+	if exp := []string{"/write", "/query", "/query"}; !reflect.DeepEqual(rt.paths, exp) {
+		t.Errorf("unexpected requests.  expected %v, actual %v", exp, rt.paths)
+	}
+}
+
+func TestClient_UserAgent(t *testing.T) {
+	type identity struct{ userAgent, clientID string }
+	var received []identity
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, identity{r.UserAgent(), r.Header.Get("X-Client-Id")})
+		switch r.URL.Path {
+		case "/query":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			_ = json.NewEncoder(w).Encode(Response{})
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name      string
+		userAgent string
+		clientID  string
+		expected  string
+	}{
+		{
+			name:      "Empty user agent",
+			userAgent: "",
+			expected:  fmt.Sprintf("influxdb1-client/%s (go/%s)", Version, strings.TrimPrefix(runtime.Version(), "go")),
+		},
+		{
+			name:      "Custom user agent",
+			userAgent: "Test Influx Client",
+			clientID:  "ingest-7",
+			expected:  "Test Influx Client",
+		},
+	}
+
+	for _, test := range tests {
+		config := HTTPConfig{Addr: ts.URL, UserAgent: test.userAgent, ClientID: test.clientID}
+		c, _ := NewHTTPClient(config)
+		defer c.Close()
+
+		received = nil
+		if _, err := c.Query(Query{}); err != nil {
+			t.Errorf("%s: unexpected query error.  expected %v, actual %v", test.name, nil, err)
+		}
+		if resp, err := c.QueryAsChunk(Query{}); err != nil {
+			t.Errorf("%s: unexpected chunked query error.  expected %v, actual %v", test.name, nil, err)
+		} else {
+			resp.Close()
+		}
+		bp, _ := NewBatchPoints(BatchPointsConfig{})
+		bp.AddPoints(newTestPoints(1))
+		if err := c.Write(bp); err != nil {
+			t.Errorf("%s: unexpected write error.  expected %v, actual %v", test.name, nil, err)
+		}
+		if _, _, err := c.Ping(0); err != nil {
+			t.Errorf("%s: unexpected ping error.  expected %v, actual %v", test.name, nil, err)
+		}
+
+		exp := identity{test.expected, test.clientID}
+		if len(received) != 4 {
+			t.Fatalf("%s: unexpected requests.  expected %v, actual %v", test.name, 4, len(received))
+		}
+		for i, got := range received {
+			if got != exp {
+				t.Errorf("%s: unexpected identity of request %d. expected %v, actual %v", test.name, i, exp, got)
+			}
+		}
+	}
+}
 
 func TestClient_PointString(t *testing.T) {
 	const shortForm = "2006-Jan-02"
@@ -772,6 +1468,90 @@ func TestClient_PointTags(t *testing.T) {
 	}
 }
 
+// newTenTagPoint returns a point with 10 tags.
+func newTenTagPoint() *Point {
+	tags := make(map[string]string)
+	for i := 0; i < 10; i++ {
+		tags[fmt.Sprintf("tag%d", i)] = fmt.Sprintf("value%d", i)
+	}
+	p, _ := NewPoint("cpu", tags, map[string]interface{}{"value": 1.0}, time.Unix(1, 0))
+	return p
+}
+
+func TestClient_PointKey(t *testing.T) {
+	p, _ := NewPoint("cpu usage", map[string]string{"region": "eu", "host": "a,b"}, map[string]interface{}{"v": 1.0})
+	if exp := `cpu\ usage,host=a\,b,region=eu`; string(p.Key()) != exp {
+		t.Errorf("unexpected key.  expected %v, actual %v", exp, string(p.Key()))
+	}
+	h := models.NewInlineFNV64a()
+	h.Write(p.Key())
+	if p.HashID() != h.Sum64() {
+		t.Errorf("unexpected hash.  expected %v, actual %v", h.Sum64(), p.HashID())
+	}
+
+	var tags []string
+	p.ForEachTag(func(k, v []byte) bool {
+		tags = append(tags, string(k)+"="+string(v))
+		return true
+	})
+	if exp := []string{"host=a,b", "region=eu"}; !reflect.DeepEqual(tags, exp) {
+		t.Errorf("unexpected tags.  expected %v, actual %v", exp, tags)
+	}
+	tags = nil
+	p.ForEachTag(func(k, v []byte) bool {
+		tags = append(tags, string(k))
+		return false
+	})
+	if len(tags) != 1 {
+		t.Errorf("unexpected number of tags walked.  expected %v, actual %v", 1, len(tags))
+	}
+
+	p = newTenTagPoint()
+	var n int
+	count := func(k, v []byte) bool {
+		n++
+		return true
+	}
+	if allocs := testing.AllocsPerRun(100, func() {
+		p.Key()
+		p.HashID()
+		p.ForEachTag(count)
+	}); allocs != 0 {
+		t.Errorf("unexpected allocations.  expected %v, actual %v", 0, allocs)
+	}
+}
+
+func BenchmarkPoint_Key(b *testing.B) {
+	p := newTenTagPoint()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if len(p.Key()) == 0 {
+			b.Fatal("empty key")
+		}
+	}
+}
+
+func BenchmarkPoint_HashID(b *testing.B) {
+	p := newTenTagPoint()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		p.HashID()
+	}
+}
+
+func BenchmarkPoint_ForEachTag(b *testing.B) {
+	p := newTenTagPoint()
+	var n int
+	count := func(k, v []byte) bool {
+		n++
+		return true
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		p.ForEachTag(count)
+	}
+}
+
 func TestClient_PointUnixNano(t *testing.T) {
 	const shortForm = "2006-Jan-02"
 	time1, _ := time.Parse(shortForm, "2013-Feb-03")
@@ -814,12 +1594,162 @@ func TestBatchPoints_PrecisionError(t *testing.T) {
 	}
 }
 
+func TestBatchPoints_NilPoints(t *testing.T) {
+	p := newTestPoints(1)[0]
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	bp.AddPoint(nil)
+	bp.AddPoints([]*Point{p, nil})
+	if len(bp.Points()) != 1 || bp.Points()[0] != p {
+		t.Errorf("unexpected points.  expected %v, actual %v", []*Point{p}, bp.Points())
+	}
+	if err := bp.Err(); err != nil {
+		t.Errorf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+
+	bp, _ = NewSafeBatchPoints(BatchPointsConfig{Strict: true})
+	if err := bp.Err(); err != ErrEmptyBatch {
+		t.Errorf("unexpected error.  expected %v, actual %v", ErrEmptyBatch, err)
+	}
+	bp.AddPoints([]*Point{p, nil})
+	if err := bp.Err(); err != ErrNilPoint {
+		t.Errorf("unexpected error.  expected %v, actual %v", ErrNilPoint, err)
+	}
+	bp.Reset()
+	bp.AddPoint(p)
+	if err := bp.Err(); err != nil {
+		t.Errorf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+}
+
+func TestBatchPoints_Size(t *testing.T) {
+	var body []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	tags := map[string]string{"host": "server 1", "region": "us-west"}
+	points := newTestPoints(3)
+	for _, tm := range []time.Time{{}, time.Unix(-1, -999), time.Unix(1e9, 123456789)} {
+		p, _ := NewPoint("disk usage", tags, map[string]interface{}{"used": int64(42), "path": `/var/"log"`}, tm)
+		points = append(points, p)
+	}
+
+	for _, precision := range []string{"ns", "us", "ms", "s", "m", "h"} {
+		bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0", Precision: precision})
+		bp.AddPoints(points)
+		if err := c.Write(bp); err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+		if bp.Size() != len(body) {
+			t.Errorf("unexpected size in precision %s.  expected %v, actual %v", precision, len(body), bp.Size())
+		}
+	}
+
+	// The size follows the precision, and the points removed.
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	bp.AddPoints(append(points, points[0]))
+	ns := bp.Size()
+	bp.SetPrecision("s")
+	if bp.Size() >= ns {
+		t.Errorf("unexpected size in seconds.  expected less than %v, actual %v", ns, bp.Size())
+	}
+	var buf bytes.Buffer
+	bp.Dedup()
+	bp.WriteTo(&buf)
+	if bp.Size() != buf.Len() {
+		t.Errorf("unexpected size after Dedup.  expected %v, actual %v", buf.Len(), bp.Size())
+	}
+	bp.Reset()
+	if bp.Size() != 0 {
+		t.Errorf("unexpected size after Reset.  expected %v, actual %v", 0, bp.Size())
+	}
+}
+
+func TestBatchPoints_MaxBytes(t *testing.T) {
+	p, _ := NewPoint("cpu", nil, map[string]interface{}{"value": 1.0}, time.Unix(1, 0))
+	points := []*Point{p, p, p, p, p}
+	size := len(p.String()) + 1
+
+	for _, newBatch := range []func(BatchPointsConfig) (BatchPoints, error){
+		NewBatchPoints,
+		func(conf BatchPointsConfig) (BatchPoints, error) { return NewSafeBatchPoints(conf) },
+	} {
+		bp, _ := newBatch(BatchPointsConfig{MaxBytes: 3*size + 1})
+		if err := bp.AddPoints(points); err != ErrBatchFull {
+			t.Errorf("unexpected error.  expected %v, actual %v", ErrBatchFull, err)
+		}
+		if len(bp.Points()) != 3 || bp.Size() != 3*size {
+			t.Errorf("unexpected batch.  expected %v points of %v bytes, actual %v of %v", 3, 3*size, len(bp.Points()), bp.Size())
+		}
+		if err := bp.AddPoint(nil); err != nil {
+			t.Errorf("unexpected error for a nil point.  expected %v, actual %v", nil, err)
+		}
+
+		bp.Reset()
+		if err := bp.AddPoints(points[:3]); err != nil {
+			t.Errorf("unexpected error after Reset.  expected %v, actual %v", nil, err)
+		}
+	}
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{MaxBytes: size - 1})
+	if err := bp.AddPoint(points[0]); err != ErrPointExceedsBatch {
+		t.Errorf("unexpected error.  expected %v, actual %v", ErrPointExceedsBatch, err)
+	}
+
+	var ce *ConfigError
+	if _, err := NewBatchPoints(BatchPointsConfig{MaxBytes: -1}); !errors.As(err, &ce) || ce.Field != "MaxBytes" {
+		t.Errorf("unexpected error.  expected a ConfigError for MaxBytes, actual %v", err)
+	}
+}
+
+func TestClient_WriteEmptyBatch(t *testing.T) {
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	hc, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer hc.Close()
+	// Anything sent by the UDP and TCP clients fails.
+	clients := map[string]Client{
+		"http": hc,
+		"udp":  &udpclient{conn: brokenConn{}, payloadSize: UDPPayloadSize},
+		"tcp":  newTCPTestClient(brokenConn{}, ""),
+	}
+
+	strictWithNil, _ := NewBatchPoints(BatchPointsConfig{Strict: true})
+	strictWithNil.AddPoints([]*Point{newTestPoints(1)[0], nil})
+	for name, c := range clients {
+		empty, _ := NewBatchPoints(BatchPointsConfig{})
+		empty.AddPoint(nil)
+		if err := c.Write(empty); err != nil {
+			t.Errorf("%s: unexpected error.  expected %v, actual %v", name, nil, err)
+		}
+		strict, _ := NewBatchPoints(BatchPointsConfig{Strict: true})
+		if err := c.Write(strict); err != ErrEmptyBatch {
+			t.Errorf("%s: unexpected error.  expected %v, actual %v", name, ErrEmptyBatch, err)
+		}
+		if err := c.Write(strictWithNil); err != ErrNilPoint {
+			t.Errorf("%s: unexpected error.  expected %v, actual %v", name, ErrNilPoint, err)
+		}
+	}
+	if n := atomic.LoadInt32(&requests); n != 0 {
+		t.Errorf("unexpected requests.  expected %v, actual %v", 0, n)
+	}
+}
+
 func TestBatchPoints_SettersGetters(t *testing.T) {
 	bp, _ := NewBatchPoints(BatchPointsConfig{
 		Precision:        "ns",
 		Database:         "db",
 		RetentionPolicy:  "rp",
-		WriteConsistency: "wc",
+		WriteConsistency: "one",
 	})
 	if bp.Precision() != "ns" {
 		t.Errorf("Expected: %s, got %s", bp.Precision(), "ns")
@@ -830,13 +1760,13 @@ func TestBatchPoints_SettersGetters(t *testing.T) {
 	if bp.RetentionPolicy() != "rp" {
 		t.Errorf("Expected: %s, got %s", bp.RetentionPolicy(), "rp")
 	}
-	if bp.WriteConsistency() != "wc" {
-		t.Errorf("Expected: %s, got %s", bp.WriteConsistency(), "wc")
+	if bp.WriteConsistency() != "one" {
+		t.Errorf("Expected: %s, got %s", bp.WriteConsistency(), "one")
 	}
 
 	bp.SetDatabase("db2")
 	bp.SetRetentionPolicy("rp2")
-	bp.SetWriteConsistency("wc2")
+	bp.SetWriteConsistency("all")
 	err := bp.SetPrecision("s")
 	if err != nil {
 		t.Errorf("Did not expect error: %s", err.Error())
@@ -851,8 +1781,8 @@ func TestBatchPoints_SettersGetters(t *testing.T) {
 	if bp.RetentionPolicy() != "rp2" {
 		t.Errorf("Expected: %s, got %s", bp.RetentionPolicy(), "rp2")
 	}
-	if bp.WriteConsistency() != "wc2" {
-		t.Errorf("Expected: %s, got %s", bp.WriteConsistency(), "wc2")
+	if bp.WriteConsistency() != "all" {
+		t.Errorf("Expected: %s, got %s", bp.WriteConsistency(), "all")
 	}
 }
 
@@ -938,6 +1868,73 @@ func TestClient_QueryAsChunk(t *testing.T) {
 	}
 }
 
+func TestClient_QueryAsChunkSize(t *testing.T) {
+	canceled := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, exp := r.URL.Query().Get("chunk_size"), "2"; got != exp {
+			t.Errorf("unexpected chunk_size parameter.  expected %q, actual %q", exp, got)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Influxdb-Version", "1.8.10")
+		w.WriteHeader(http.StatusOK)
+		enc := json.NewEncoder(w)
+		for i := 0; i < 3; i++ {
+			_ = enc.Encode(Response{Results: []Result{{StatementId: i}}})
+		}
+		w.(http.Flusher).Flush()
+
+		// Stream until the client cancels the request.
+		for {
+			select {
+			case <-r.Context().Done():
+				close(canceled)
+				return
+			case <-time.After(10 * time.Millisecond):
+				_ = enc.Encode(Response{Results: []Result{{}}})
+				w.(http.Flusher).Flush()
+			}
+		}
+	}))
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	cr, err := c.QueryAsChunk(Query{Command: "SELECT * FROM cpu", ChunkSize: 2})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	for i := 0; i < 3; i++ {
+		resp, err := cr.NextResponse()
+		if err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+		if got := resp.Results[0].StatementId; got != i {
+			t.Errorf("unexpected statement id.  expected %v, actual %v", i, got)
+		}
+	}
+	cr.Close()
+
+	select {
+	case <-canceled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected Close to cancel the request")
+	}
+	if _, err := cr.NextResponse(); err == nil {
+		t.Error("expected an error reading a closed response")
+	}
+}
+
+func TestClient_QueryAsChunkNegativeSize(t *testing.T) {
+	c, _ := NewHTTPClient(HTTPConfig{Addr: "http://localhost:8086"})
+	defer c.Close()
+
+	exp := "invalid chunk size -1: must be positive"
+	if _, err := c.QueryAsChunk(Query{ChunkSize: -1}); err == nil || err.Error() != exp {
+		t.Errorf("unexpected error.  expected %v, actual %v", exp, err)
+	}
+}
+
 func TestClient_ReadStatementId(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		data := Response{
@@ -981,3 +1978,283 @@ func TestClient_ReadStatementId(t *testing.T) {
 		t.Fatalf("expected statement_id = 1, got %d", r.Results[0].StatementId)
 	}
 }
+
+func newHeaderServer(status int, body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Influxdb-Version", "1.8.10")
+		w.Header().Set("X-Request-Id", "req-"+r.URL.Path)
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+}
+
+func TestClient_QueryHeader(t *testing.T) {
+	ts := newHeaderServer(http.StatusOK, `{"results":[{}]}`)
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	resp, err := c.Query(Query{Command: "SHOW DATABASES"})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if got := resp.Header.Get("X-Influxdb-Version"); got != "1.8.10" {
+		t.Errorf("unexpected version header.  expected %q, actual %q", "1.8.10", got)
+	}
+}
+
+func TestClient_QueryAsChunkHeader(t *testing.T) {
+	ts := newHeaderServer(http.StatusOK, `{"results":[{}]}`+"\n")
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	cr, err := c.QueryAsChunk(Query{Command: "SHOW DATABASES"})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	defer cr.Close()
+
+	if got := cr.Header.Get("X-Request-Id"); got != "req-/query" {
+		t.Errorf("unexpected request ID before streaming.  expected %q, actual %q", "req-/query", got)
+	}
+	resp, err := cr.NextResponse()
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if got := resp.Header.Get("X-Influxdb-Version"); got != "1.8.10" {
+		t.Errorf("unexpected version header.  expected %q, actual %q", "1.8.10", got)
+	}
+}
+
+func TestClient_WriteRequestID(t *testing.T) {
+	ts := newHeaderServer(http.StatusNoContent, "")
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	bp.AddPoints(newTestPoints(1))
+	if err := c.Write(bp); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if got := c.(RequestIDClient).LastRequestID(); got != "req-/write" {
+		t.Errorf("unexpected request ID.  expected %q, actual %q", "req-/write", got)
+	}
+}
+
+func TestClient_WriteErrorRequestID(t *testing.T) {
+	ts := newHeaderServer(http.StatusBadRequest, `{"error":"database not found: \"db0\""}`)
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	bp.AddPoints(newTestPoints(1))
+	var er *ErrorResponse
+	if err := c.Write(bp); !errors.As(err, &er) {
+		t.Fatalf("unexpected error.  expected %T, actual %v", er, err)
+	}
+	if er.RequestID != "req-/write" {
+		t.Errorf("unexpected request ID.  expected %q, actual %q", "req-/write", er.RequestID)
+	}
+}
+
+func TestResponse_TimeSeries(t *testing.T) {
+	body := `{"results":[` +
+		`{"statement_id":0,"series":[` +
+		`{"name":"cpu","tags":{"host":"a"},"columns":["time","value"],"values":[[1,0.5],[2,null],[3,2]]},` +
+		`{"name":"cpu","tags":{"host":"b"},"columns":["time","value"],"values":[[1,7]]}]},` +
+		`{"statement_id":1,"series":[` +
+		`{"name":"cpu","tags":{"host":"a"},"columns":["time","value"],"values":[[4,3]]}]}]}`
+	var epoch string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		epoch = r.FormValue("epoch")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(body))
+	}))
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	resp, err := c.Query(Query{Command: "SELECT value FROM cpu GROUP BY host", Precision: "s"})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if epoch != "s" {
+		t.Fatalf("unexpected epoch parameter: %q", epoch)
+	}
+
+	series, err := resp.TimeSeries("value")
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	a := series["cpu,host=a"]
+	if exp := []float64{0.5, 2, 3}; !reflect.DeepEqual(a.Values, exp) {
+		t.Errorf("unexpected values.  expected %v, actual %v", exp, a.Values)
+	}
+	if exp := []time.Time{time.Unix(1, 0).UTC(), time.Unix(3, 0).UTC(), time.Unix(4, 0).UTC()}; !reflect.DeepEqual(a.Times, exp) {
+		t.Errorf("unexpected times.  expected %v, actual %v", exp, a.Times)
+	}
+	if b := series["cpu,host=b"]; b.Name != "cpu" || b.Tags["host"] != "b" || len(b.Values) != 1 {
+		t.Errorf("unexpected series: %+v", b)
+	}
+
+	series, err = TimeSeriesDecoder{NullAsNaN: true}.TimeSeries(resp, "value")
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if v := series["cpu,host=a"].Values; len(v) != 4 || !math.IsNaN(v[1]) {
+		t.Errorf("expected a NaN for the null value, got %v", v)
+	}
+}
+
+func TestResponse_TimeSeriesRFC3339(t *testing.T) {
+	var resp Response
+	dec := json.NewDecoder(strings.NewReader(`{"results":[{"series":[{"name":"cpu","columns":["time","value","host"],"values":[["2020-01-02T03:04:05.5Z",1,"a"]]}]}]}`))
+	dec.UseNumber()
+	if err := dec.Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+
+	series, err := resp.TimeSeries("value")
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	exp := time.Date(2020, 1, 2, 3, 4, 5, 5e8, time.UTC)
+	if s := series["cpu"]; len(s.Times) != 1 || !s.Times[0].Equal(exp) || s.Values[0] != 1 {
+		t.Errorf("unexpected series: %+v", s)
+	}
+
+	if _, err := resp.TimeSeries("host"); err == nil || !strings.Contains(err.Error(), `column "host"`) {
+		t.Errorf("unexpected error for a string column: %v", err)
+	}
+	if _, err := resp.TimeSeries("missing"); err == nil || !strings.Contains(err.Error(), `"missing"`) {
+		t.Errorf("unexpected error for a missing column: %v", err)
+	}
+}
+
+func TestChunkedResponse_TimeSeries(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results":[{"series":[{"name":"cpu","columns":["time","value"],"values":[[1500,1]]}]}]}` + "\n"))
+	}))
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	cr, err := c.QueryAsChunk(Query{Command: "SELECT value FROM cpu", Precision: "ms"})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	defer cr.Close()
+
+	resp, err := cr.NextResponse()
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	series, err := resp.TimeSeries("value")
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if exp := time.Unix(1, 5e8).UTC(); !reflect.DeepEqual(series["cpu"].Times, []time.Time{exp}) {
+		t.Errorf("unexpected times.  expected %v, actual %v", exp, series["cpu"].Times)
+	}
+}
+
+func TestChunkedResponse_NextResponseInto(t *testing.T) {
+	body := `{"results":[{"statement_id":0,"series":[{"name":"cpu","tags":{"host":"a"},"columns":["time","value","idle"],"values":[[1,1,true],[2,2,false],[3,3,true]]},{"name":"cpu","tags":{"host":"b"},"columns":["time","value","idle"],"values":[[1,4,true]]}],"partial":true}]}
+{"results":[{"statement_id":0,"series":[{"name":"mem","columns":["time","free"],"values":[[4,5]]}]}]}
+{"results":[{"statement_id":0,"messages":[{"level":"warning","text":"deprecated"}]},{"statement_id":1,"error":"not found"}]}
+`
+	var exp []*Response
+	r := NewChunkedResponse(strings.NewReader(body))
+	for {
+		resp, err := r.NextResponse()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+		exp = append(exp, resp)
+	}
+
+	var resp Response
+	r = NewChunkedResponse(strings.NewReader(body))
+	for i := 0; ; i++ {
+		err := r.NextResponseInto(&resp)
+		if err == io.EOF {
+			if i != len(exp) {
+				t.Errorf("unexpected number of responses.  expected %v, actual %v", len(exp), i)
+			}
+			break
+		} else if err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+		// The slices emptied for reuse are not nil.
+		for j := range resp.Results {
+			if len(resp.Results[j].Series) == 0 {
+				resp.Results[j].Series = nil
+			}
+		}
+		if i < len(exp) && !reflect.DeepEqual(&resp, exp[i]) {
+			t.Errorf("unexpected response %d.\nexpected %+v\nactual   %+v", i, exp[i], &resp)
+		}
+	}
+}
+
+// newChunkedBody returns a chunked response body of n chunks of rows rows.
+func newChunkedBody(n, rows int) []byte {
+	var chunk bytes.Buffer
+	chunk.WriteString(`{"results":[{"statement_id":0,"series":[{"name":"cpu","columns":["time","value"],"values":[`)
+	for i := 0; i < rows; i++ {
+		if i > 0 {
+			chunk.WriteByte(',')
+		}
+		fmt.Fprintf(&chunk, "[%d,%d.5]", i, i)
+	}
+	chunk.WriteString("]}],\"partial\":true}]}\n")
+	return bytes.Repeat(chunk.Bytes(), n)
+}
+
+func BenchmarkChunkedResponse_NextResponse(b *testing.B) {
+	body := newChunkedBody(1000, 10000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r := NewChunkedResponse(bytes.NewReader(body))
+		for {
+			if _, err := r.NextResponse(); err == io.EOF {
+				break
+			} else if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkChunkedResponse_NextResponseInto(b *testing.B) {
+	body := newChunkedBody(1000, 10000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var resp Response
+		r := NewChunkedResponse(bytes.NewReader(body))
+		for {
+			if err := r.NextResponseInto(&resp); err == io.EOF {
+				break
+			} else if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,284 @@
+// Package clienttest provides an in-memory implementation of client.Client
+// for testing code that writes to or queries InfluxDB.
+package clienttest // import "github.com/influxdata/influxdb1-client/v2/clienttest"
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"regexp"
+	"sync"
+	"time"
+
+	client "github.com/influxdata/influxdb1-client/v2"
+)
+
+// Client records the points written to it and answers queries with the
+// responses registered for them. It implements client.ContextClient and is
+// safe for concurrent use. The zero value is ready to use.
+type Client struct {
+	// Latency delays every call, as the round trip to a server would.
+	Latency time.Duration
+
+	mu        sync.Mutex
+	batches   []client.BatchPoints
+	attempts  int
+	queries   []client.Query
+	pings     int
+	writeErrs []error
+	pingErrs  []error
+	responses []response
+	closed    bool
+}
+
+// response is a canned answer to the queries matching command or re.
+type response struct {
+	command string
+	re      *regexp.Regexp
+	resp    *client.Response
+	err     error
+}
+
+func (r response) matches(command string) bool {
+	if r.re != nil {
+		return r.re.MatchString(command)
+	}
+	return r.command == command
+}
+
+var _ client.ContextClient = (*Client)(nil)
+
+// OnQuery makes queries whose command is exactly command return resp and
+// err. Responses registered later take precedence.
+func (c *Client) OnQuery(command string, resp *client.Response, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.responses = append(c.responses, response{command: command, resp: resp, err: err})
+}
+
+// OnQueryMatch makes queries whose command matches re return resp and err.
+// Responses registered later take precedence.
+func (c *Client) OnQueryMatch(re *regexp.Regexp, resp *client.Response, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.responses = append(c.responses, response{re: re, resp: resp, err: err})
+}
+
+// FailWrites makes the next len(errs) writes fail with errs, in order. The
+// points of a failed write are not recorded.
+func (c *Client) FailWrites(errs ...error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writeErrs = append(c.writeErrs, errs...)
+}
+
+// FailPings makes the next len(errs) pings fail with errs, in order.
+func (c *Client) FailPings(errs ...error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pingErrs = append(c.pingErrs, errs...)
+}
+
+// Batches returns copies of the batches written so far.
+func (c *Client) Batches() []client.BatchPoints {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]client.BatchPoints(nil), c.batches...)
+}
+
+// WrittenPoints returns the points of every batch written so far, in order.
+func (c *Client) WrittenPoints() []*client.Point {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var points []*client.Point
+	for _, bp := range c.batches {
+		points = append(points, bp.Points()...)
+	}
+	return points
+}
+
+// WriteAttempts returns the number of calls to Write, including the failed
+// ones.
+func (c *Client) WriteAttempts() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.attempts
+}
+
+// Queries returns the queries made so far, in order.
+func (c *Client) Queries() []client.Query {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]client.Query(nil), c.queries...)
+}
+
+// Pings returns the number of calls to Ping.
+func (c *Client) Pings() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.pings
+}
+
+// Reset forgets the recorded writes, queries and pings, and the pending
+// failures. Registered responses are kept.
+func (c *Client) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.batches, c.attempts, c.queries, c.pings = nil, 0, nil, 0
+	c.writeErrs, c.pingErrs = nil, nil
+}
+
+// Ping returns the Latency and the version "clienttest".
+func (c *Client) Ping(timeout time.Duration) (time.Duration, string, error) {
+	if err := c.wait(context.Background()); err != nil {
+		return 0, "", err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pings++
+	if err := shift(&c.pingErrs); err != nil {
+		return 0, "", err
+	}
+	return c.Latency, "clienttest", nil
+}
+
+// Write records a copy of bp.
+func (c *Client) Write(bp client.BatchPoints) error {
+	return c.WriteContext(context.Background(), bp)
+}
+
+// WriteContext records a copy of bp, unless ctx is done first.
+func (c *Client) WriteContext(ctx context.Context, bp client.BatchPoints) error {
+	if err := c.wait(ctx); err != nil {
+		return err
+	}
+	cp, err := copyBatch(bp)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.attempts++
+	if err := shift(&c.writeErrs); err != nil {
+		return err
+	}
+	c.batches = append(c.batches, cp)
+	return nil
+}
+
+// Query returns the response registered for q, or an empty response if
+// there is none.
+func (c *Client) Query(q client.Query) (*client.Response, error) {
+	return c.QueryContext(context.Background(), q)
+}
+
+// QueryContext is like Query, unless ctx is done first.
+func (c *Client) QueryContext(ctx context.Context, q client.Query) (*client.Response, error) {
+	if err := c.wait(ctx); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.queries = append(c.queries, q)
+	for i := len(c.responses) - 1; i >= 0; i-- {
+		if r := c.responses[i]; r.matches(q.Command) {
+			return r.resp, r.err
+		}
+	}
+	return &client.Response{}, nil
+}
+
+// QueryAsChunk returns the response registered for q as a single chunk.
+func (c *Client) QueryAsChunk(q client.Query) (*client.ChunkedResponse, error) {
+	return c.QueryAsChunkContext(context.Background(), q)
+}
+
+// QueryAsChunkContext is like QueryAsChunk, unless ctx is done first.
+func (c *Client) QueryAsChunkContext(ctx context.Context, q client.Query) (*client.ChunkedResponse, error) {
+	resp, err := c.QueryContext(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if resp != nil {
+		if err := json.NewEncoder(&buf).Encode(resp); err != nil {
+			return nil, err
+		}
+	}
+	return client.NewChunkedResponse(&buf), nil
+}
+
+// Close marks the client closed, see Closed.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return nil
+}
+
+// Closed reports whether Close was called.
+func (c *Client) Closed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
+
+// wait sleeps for the Latency, or until ctx is done.
+func (c *Client) wait(ctx context.Context) error {
+	if c.Latency <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(c.Latency)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// shift removes and returns the first error of errs, if any.
+func shift(errs *[]error) error {
+	if len(*errs) == 0 {
+		return nil
+	}
+	err := (*errs)[0]
+	*errs = (*errs)[1:]
+	return err
+}
+
+// copyBatch returns a copy of bp that later changes to bp do not affect.
+func copyBatch(bp client.BatchPoints) (client.BatchPoints, error) {
+	// The points were accepted by bp, so the copy has its limits.
+	conf := client.NewBatchPointsDocument(bp).Config
+	cp, err := client.NewBatchPoints(client.BatchPointsConfig{
+		Precision:            bp.Precision(),
+		Database:             bp.Database(),
+		RetentionPolicy:      bp.RetentionPolicy(),
+		WriteConsistency:     bp.WriteConsistency(),
+		MaxFieldsPerPoint:    conf.MaxFieldsPerPoint,
+		MaxStringFieldLength: conf.MaxStringFieldLength,
+		MaxKeyLength:         conf.MaxKeyLength,
+		TruncateStrings:      conf.TruncateStrings,
+		TruncationMarker:     conf.TruncationMarker,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range bp.Points() {
+		fields, err := p.Fields()
+		if err != nil {
+			return nil, err
+		}
+		pt, err := client.NewPoint(p.Name(), p.Tags(), fields, p.Time())
+		if err != nil {
+			return nil, err
+		}
+		if err := cp.AddPoint(pt); err != nil {
+			return nil, err
+		}
+	}
+	return cp, nil
+}
@@ -0,0 +1,117 @@
+package clienttest
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
+	client "github.com/influxdata/influxdb1-client/v2"
+)
+
+func TestClient_Write(t *testing.T) {
+	var c Client
+	bp, _ := client.NewBatchPoints(client.BatchPointsConfig{Database: "db0", Precision: "s"})
+	pt, _ := client.NewPoint("cpu", map[string]string{"host": "a"}, map[string]interface{}{"value": 1.0}, time.Unix(1, 0))
+	bp.AddPoint(pt)
+
+	if err := c.Write(bp); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	// Later changes to the batch are not recorded.
+	bp.SetDatabase("db1")
+	bp.AddPoint(pt)
+
+	batches := c.Batches()
+	if len(batches) != 1 || batches[0].Database() != "db0" || batches[0].Precision() != "s" {
+		t.Fatalf("unexpected batches: %v", batches)
+	}
+	points := c.WrittenPoints()
+	if len(points) != 1 || points[0].String() != pt.String() {
+		t.Errorf("unexpected points: %v", points)
+	}
+}
+
+func TestClient_FailWrites(t *testing.T) {
+	var c Client
+	errBoom := errors.New("boom")
+	c.FailWrites(errBoom, errBoom)
+
+	bp, _ := client.NewBatchPoints(client.BatchPointsConfig{})
+	for i, exp := range []error{errBoom, errBoom, nil} {
+		if err := c.Write(bp); err != exp {
+			t.Errorf("unexpected error for write %d.  expected %v, actual %v", i, exp, err)
+		}
+	}
+	if got := c.WriteAttempts(); got != 3 {
+		t.Errorf("unexpected number of attempts.  expected %v, actual %v", 3, got)
+	}
+	if got := len(c.Batches()); got != 1 {
+		t.Errorf("unexpected number of batches.  expected %v, actual %v", 1, got)
+	}
+}
+
+func TestClient_Query(t *testing.T) {
+	var c Client
+	databases := &client.Response{Results: []client.Result{{Series: []models.Row{{Name: "databases", Columns: []string{"name"}, Values: [][]interface{}{{"db0"}}}}}}}
+	errBoom := errors.New("boom")
+	c.OnQueryMatch(regexp.MustCompile(`^SELECT`), nil, errBoom)
+	c.OnQuery("SHOW DATABASES", databases, nil)
+
+	if resp, err := c.Query(client.Query{Command: "SHOW DATABASES"}); err != nil || resp != databases {
+		t.Errorf("unexpected response: %v, %v", resp, err)
+	}
+	if _, err := c.Query(client.Query{Command: "SELECT * FROM cpu"}); err != errBoom {
+		t.Errorf("unexpected error.  expected %v, actual %v", errBoom, err)
+	}
+	if resp, err := c.Query(client.Query{Command: "SHOW MEASUREMENTS"}); err != nil || len(resp.Results) != 0 {
+		t.Errorf("unexpected response: %v, %v", resp, err)
+	}
+
+	cr, err := c.QueryAsChunk(client.Query{Command: "SHOW DATABASES"})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	defer cr.Close()
+	resp, err := cr.NextResponse()
+	if err != nil || len(resp.Results) != 1 || resp.Results[0].Series[0].Values[0][0] != "db0" {
+		t.Errorf("unexpected chunk: %v, %v", resp, err)
+	}
+
+	if got := len(c.Queries()); got != 4 {
+		t.Errorf("unexpected number of queries.  expected %v, actual %v", 4, got)
+	}
+}
+
+func TestClient_Ping(t *testing.T) {
+	c := Client{Latency: 10 * time.Millisecond}
+	errDown := errors.New("down")
+	c.FailPings(errDown)
+
+	if _, _, err := c.Ping(0); err != errDown {
+		t.Errorf("unexpected error.  expected %v, actual %v", errDown, err)
+	}
+	rtt, version, err := c.Ping(0)
+	if err != nil || rtt != c.Latency || version != "clienttest" {
+		t.Errorf("unexpected ping: %v, %q, %v", rtt, version, err)
+	}
+	if got := c.Pings(); got != 2 {
+		t.Errorf("unexpected number of pings.  expected %v, actual %v", 2, got)
+	}
+}
+
+func TestClient_LatencyContext(t *testing.T) {
+	c := Client{Latency: time.Minute}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	bp, _ := client.NewBatchPoints(client.BatchPointsConfig{})
+	if err := c.WriteContext(ctx, bp); err != context.DeadlineExceeded {
+		t.Errorf("unexpected error.  expected %v, actual %v", context.DeadlineExceeded, err)
+	}
+	if got := c.WriteAttempts(); got != 0 {
+		t.Errorf("unexpected number of attempts.  expected %v, actual %v", 0, got)
+	}
+}
@@ -0,0 +1,97 @@
+package clienttest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	client "github.com/influxdata/influxdb1-client/v2"
+)
+
+// FakeClock is a client.Clock whose time only moves when Advance is called,
+// so that the time-dependent features of the client can be tested without
+// sleeps. It is safe for concurrent use. The zero value starts at the zero
+// time; use NewFakeClock to start at another.
+type FakeClock struct {
+	mu      sync.Mutex
+	changed *sync.Cond
+	now     time.Time
+	waiters []waiter
+}
+
+// waiter is a channel returned by After, which receives the time once the
+// clock reaches at.
+type waiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+var _ client.Clock = (*FakeClock)(nil)
+
+// NewFakeClock returns a FakeClock starting at now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the time of the clock.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After returns a channel that receives the time of the clock once Advance
+// moved it by d. It receives it right away if d is not positive.
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, waiter{at: c.now.Add(d), ch: ch})
+	c.cond().Broadcast()
+	return ch
+}
+
+// Advance moves the clock by d, and fires the channels of After that are
+// due, in the order of their times.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	sort.SliceStable(c.waiters, func(i, j int) bool { return c.waiters[i].at.Before(c.waiters[j].at) })
+	n := 0
+	for ; n < len(c.waiters) && !c.waiters[n].at.After(c.now); n++ {
+		c.waiters[n].ch <- c.now
+	}
+	c.waiters = append(c.waiters[:0], c.waiters[n:]...)
+	c.cond().Broadcast()
+}
+
+// Waiters returns the number of channels of After waiting for the clock.
+func (c *FakeClock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+// BlockUntil waits until n channels of After are waiting for the clock, so
+// that a test advances it once the code under test waits.
+func (c *FakeClock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.waiters) < n {
+		c.cond().Wait()
+	}
+}
+
+// cond returns the condition signaled when the waiters change. c.mu must be
+// held.
+func (c *FakeClock) cond() *sync.Cond {
+	if c.changed == nil {
+		c.changed = sync.NewCond(&c.mu)
+	}
+	return c.changed
+}
@@ -0,0 +1,163 @@
+package clienttest
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	client "github.com/influxdata/influxdb1-client/v2"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Unix(1000, 0)
+	c := NewFakeClock(start)
+	select {
+	case now := <-c.After(0):
+		if !now.Equal(start) {
+			t.Errorf("unexpected time.  expected %v, actual %v", start, now)
+		}
+	default:
+		t.Errorf("unexpected wait for a zero duration")
+	}
+
+	late, early := c.After(2*time.Second), c.After(time.Second)
+	c.BlockUntil(2)
+	c.Advance(1500 * time.Millisecond)
+	select {
+	case <-late:
+		t.Errorf("unexpected channel fired before its time")
+	case now := <-early:
+		if exp := start.Add(1500 * time.Millisecond); !now.Equal(exp) {
+			t.Errorf("unexpected time.  expected %v, actual %v", exp, now)
+		}
+	}
+	if n := c.Waiters(); n != 1 {
+		t.Errorf("unexpected waiters.  expected %v, actual %v", 1, n)
+	}
+	c.Advance(time.Second)
+	<-late
+	if exp := start.Add(2500 * time.Millisecond); !c.Now().Equal(exp) {
+		t.Errorf("unexpected time.  expected %v, actual %v", exp, c.Now())
+	}
+}
+
+func newPoint(t *testing.T, ts ...time.Time) *client.Point {
+	t.Helper()
+	p, err := client.NewPoint("cpu", nil, map[string]interface{}{"value": 1.0}, ts...)
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	return p
+}
+
+func TestFakeClock_BatchingClient(t *testing.T) {
+	var c Client
+	clock := NewFakeClock(time.Unix(1000, 0))
+	bc, _ := client.NewBatchingClient(&c, client.BatchingOptions{
+		BatchPointsConfig: client.BatchPointsConfig{AssignTimeOnAdd: true},
+		FlushInterval:     10 * time.Second,
+		Clock:             clock,
+	})
+	defer bc.Close()
+
+	p := newPoint(t)
+	bc.AddPoint(p)
+	clock.BlockUntil(1)
+	clock.Advance(5 * time.Second)
+	if n := len(c.Batches()); n != 0 {
+		t.Fatalf("unexpected batches before the flush interval.  expected %v, actual %v", 0, n)
+	}
+	clock.Advance(5 * time.Second)
+	// The writer waits for the next flush once it wrote the batch.
+	clock.BlockUntil(1)
+	points := c.WrittenPoints()
+	if len(points) != 1 || !points[0].Time().Equal(time.Unix(1000, 0)) {
+		t.Fatalf("unexpected points written: %v", points)
+	}
+	if !p.Time().IsZero() {
+		t.Errorf("unexpected time set on the point added: %v", p.Time())
+	}
+}
+
+func TestFakeClock_AssignTimeOnAdd(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1000, 0))
+	bp, _ := client.NewBatchPoints(client.BatchPointsConfig{AssignTimeOnAdd: true, Clock: clock})
+	bp.AddPoint(newPoint(t))
+	clock.Advance(time.Second)
+	bp.AddPoints([]*client.Point{newPoint(t), newPoint(t, time.Unix(5, 0))})
+
+	exp := []time.Time{time.Unix(1000, 0), time.Unix(1001, 0), time.Unix(5, 0)}
+	for i, p := range bp.Points() {
+		if !p.Time().Equal(exp[i]) {
+			t.Errorf("unexpected time of point %d.  expected %v, actual %v", i, exp[i], p.Time())
+		}
+	}
+
+	bp, _ = client.NewBatchPoints(client.BatchPointsConfig{Clock: clock})
+	bp.AddPoint(newPoint(t))
+	if tm := bp.Points()[0].Time(); !tm.IsZero() {
+		t.Errorf("unexpected time assigned without AssignTimeOnAdd: %v", tm)
+	}
+}
+
+func TestFakeClock_Retry(t *testing.T) {
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+	clock := NewFakeClock(time.Unix(1000, 0))
+	c, _ := client.NewHTTPClient(client.HTTPConfig{Addr: ts.URL, MaxRetries: 1, RetryInterval: time.Hour, Clock: clock})
+	defer c.Close()
+
+	bp, _ := client.NewBatchPoints(client.BatchPointsConfig{Database: "db0"})
+	bp.AddPoint(newPoint(t, time.Unix(1, 0)))
+	done := make(chan error)
+	go func() { done <- c.Write(bp) }()
+
+	// The retry waits for the clock, at most an hour.
+	clock.BlockUntil(1)
+	if n := atomic.LoadInt32(&requests); n != 1 {
+		t.Errorf("unexpected requests before the retry.  expected %v, actual %v", 1, n)
+	}
+	clock.Advance(time.Hour)
+	if err := <-done; err != nil {
+		t.Errorf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("unexpected requests.  expected %v, actual %v", 2, n)
+	}
+}
+
+func TestFakeClock_CircuitBreaker(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+	clock := NewFakeClock(time.Unix(1000, 0))
+	c, _ := client.NewHTTPClient(client.HTTPConfig{
+		Addr:           ts.URL,
+		CircuitBreaker: &client.CircuitBreaker{FailureThreshold: 1, OpenDuration: time.Minute},
+		Clock:          clock,
+	})
+	defer c.Close()
+
+	bp, _ := client.NewBatchPoints(client.BatchPointsConfig{Database: "db0"})
+	bp.AddPoint(newPoint(t, time.Unix(1, 0)))
+	c.Write(bp)
+	if err := c.Write(bp); !errors.Is(err, client.ErrCircuitOpen) {
+		t.Fatalf("unexpected error.  expected %v, actual %v", client.ErrCircuitOpen, err)
+	}
+	clock.Advance(time.Minute)
+	// The breaker lets a probe through once the open duration passed.
+	if err := c.Write(bp); errors.Is(err, client.ErrCircuitOpen) {
+		t.Errorf("unexpected error.  expected the error of the server, actual %v", err)
+	}
+}
@@ -0,0 +1,271 @@
+package clienttest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	client "github.com/influxdata/influxdb1-client/v2"
+)
+
+const (
+	// DefaultMinPollInterval and DefaultMaxPollInterval are the default
+	// bounds of the delay between the queries of WaitForPoints.
+	DefaultMinPollInterval = 10 * time.Millisecond
+	DefaultMaxPollInterval = 500 * time.Millisecond
+)
+
+// VerifySpec is the points WaitForPoints waits for.
+type VerifySpec struct {
+	// Measurement is the measurement of the points, and RetentionPolicy
+	// their retention policy, defaulting to that of the database.
+	Measurement     string
+	RetentionPolicy string
+
+	// Start and End, if set, bound the times of the points counted, both
+	// included.
+	Start, End time.Time
+
+	// Count, if positive, is the number of points expected in the range.
+	// The points of a series are counted by their most written field, as
+	// the server counts the values of each field.
+	Count int
+
+	// Times are timestamps at which a point is expected, and TagSets tags
+	// of which a point is expected, each one matching the series that have
+	// at least those tags.
+	Times   []time.Time
+	TagSets []map[string]string
+
+	// MinPollInterval is the delay before the second query, doubled for
+	// every query after it up to MaxPollInterval. They default to
+	// DefaultMinPollInterval and DefaultMaxPollInterval.
+	MinPollInterval time.Duration
+	MaxPollInterval time.Duration
+}
+
+// WaitError is returned by WaitForPoints when ctx is done before the points
+// are visible. It holds what the last query observed.
+type WaitError struct {
+	// Measurement is that of the VerifySpec, and Count the number of its
+	// points last observed, -1 if no query succeeded.
+	Measurement string
+	Count       int
+
+	// Expected is the Count of the VerifySpec.
+	Expected int
+
+	// MissingTimes and MissingTagSets are the Times and TagSets of the
+	// VerifySpec that had no point.
+	MissingTimes   []time.Time
+	MissingTagSets []map[string]string
+
+	// QueryErr is the error of the last query, if it failed.
+	QueryErr error
+
+	// Err is the error of the context.
+	Err error
+}
+
+func (e *WaitError) Error() string {
+	var parts []string
+	if e.Count >= 0 && e.Expected > 0 {
+		parts = append(parts, fmt.Sprintf("observed %d points, expected %d", e.Count, e.Expected))
+	}
+	if len(e.MissingTimes) > 0 {
+		parts = append(parts, fmt.Sprintf("no point at %v", e.MissingTimes))
+	}
+	if len(e.MissingTagSets) > 0 {
+		parts = append(parts, fmt.Sprintf("no point with tags %v", e.MissingTagSets))
+	}
+	if e.QueryErr != nil {
+		parts = append(parts, fmt.Sprintf("query failed: %v", e.QueryErr))
+	}
+	return fmt.Sprintf("waiting for points of %q: %s: %v", e.Measurement, strings.Join(parts, ", "), e.Err)
+}
+
+func (e *WaitError) Unwrap() error { return e.Err }
+
+// WaitForPoints queries the points of verify in db with c until they are all
+// visible, as the points just written may not be yet, or until ctx is done,
+// which returns a *WaitError. The points are counted with COUNT queries, one
+// for the range and the tag sets and one per expected time, with a growing
+// delay between the tries. It is meant for the tests that write to a server
+// and query what they wrote, in place of sleeps.
+func WaitForPoints(ctx context.Context, c client.Client, db string, verify VerifySpec) error {
+	if verify.Measurement == "" {
+		return &client.ConfigError{Field: "Measurement", Reason: "must be set"}
+	}
+	if verify.MinPollInterval <= 0 {
+		verify.MinPollInterval = DefaultMinPollInterval
+	}
+	if verify.MaxPollInterval <= 0 {
+		verify.MaxPollInterval = DefaultMaxPollInterval
+	}
+	if verify.MaxPollInterval < verify.MinPollInterval {
+		verify.MaxPollInterval = verify.MinPollInterval
+	}
+
+	delay := verify.MinPollInterval
+	// last is the last observation whose query succeeded, if any, with the
+	// error of a query that failed since, unless ctx was done.
+	var last *WaitError
+	for {
+		werr := observe(ctx, c, db, verify)
+		if werr == nil {
+			return nil
+		}
+		switch {
+		case werr.QueryErr == nil || last == nil:
+			last = werr
+		case ctx.Err() == nil:
+			last.QueryErr = werr.QueryErr
+		}
+		if err := ctx.Err(); err != nil {
+			last.Err = err
+			return last
+		}
+		t := time.NewTimer(delay)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			last.Err = ctx.Err()
+			return last
+		}
+		if delay *= 2; delay > verify.MaxPollInterval {
+			delay = verify.MaxPollInterval
+		}
+	}
+}
+
+// observe queries the points of verify once, returning what is missing or
+// nil if nothing is.
+func observe(ctx context.Context, c client.Client, db string, verify VerifySpec) *WaitError {
+	werr := &WaitError{Measurement: verify.Measurement, Count: -1, Expected: verify.Count}
+	rows, err := count(ctx, c, db, verify, timeRange(verify.Start, verify.End))
+	if err != nil {
+		werr.QueryErr = err
+		return werr
+	}
+	werr.Count = 0
+	for _, r := range rows {
+		werr.Count += r.n
+	}
+	for _, tags := range verify.TagSets {
+		var n int
+		for _, r := range rows {
+			if hasTags(r.tags, tags) {
+				n += r.n
+			}
+		}
+		if n == 0 {
+			werr.MissingTagSets = append(werr.MissingTagSets, tags)
+		}
+	}
+	for _, t := range verify.Times {
+		rows, err := count(ctx, c, db, verify, fmt.Sprintf(" WHERE time = %d", t.UnixNano()))
+		if err != nil {
+			werr.QueryErr = err
+			return werr
+		}
+		if len(rows) == 0 {
+			werr.MissingTimes = append(werr.MissingTimes, t)
+		}
+	}
+	if (verify.Count > 0 && werr.Count != verify.Count) || len(werr.MissingTagSets) > 0 || len(werr.MissingTimes) > 0 {
+		return werr
+	}
+	return nil
+}
+
+// countRow is the number of points of a series and its tags.
+type countRow struct {
+	tags map[string]string
+	n    int
+}
+
+// count returns the number of points of the series of the measurement of
+// verify matching where, omitting those without points.
+func count(ctx context.Context, c client.Client, db string, verify VerifySpec, where string) ([]countRow, error) {
+	q := client.NewQueryWithRP(fmt.Sprintf("SELECT COUNT(*) FROM %s%s GROUP BY *",
+		client.QuoteIdent(verify.Measurement), where), db, verify.RetentionPolicy, "")
+	var resp *client.Response
+	var err error
+	if cc, ok := c.(client.ContextClient); ok {
+		resp, err = cc.QueryContext(ctx, q)
+	} else {
+		resp, err = c.Query(q)
+	}
+	if err == nil {
+		err = resp.Error()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []countRow
+	for _, result := range resp.Results {
+		for _, row := range result.Series {
+			r := countRow{tags: row.Tags}
+			for _, values := range row.Values {
+				for i, v := range values {
+					if row.Columns[i] == "time" {
+						continue
+					}
+					if n := number(v); n > r.n {
+						r.n = n
+					}
+				}
+			}
+			if r.n > 0 {
+				rows = append(rows, r)
+			}
+		}
+	}
+	return rows, nil
+}
+
+// timeRange returns the WHERE clause of the times from start to end, empty
+// if neither is set.
+func timeRange(start, end time.Time) string {
+	var conds []string
+	if !start.IsZero() {
+		conds = append(conds, fmt.Sprintf("time >= %d", start.UnixNano()))
+	}
+	if !end.IsZero() {
+		conds = append(conds, fmt.Sprintf("time <= %d", end.UnixNano()))
+	}
+	if len(conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conds, " AND ")
+}
+
+// hasTags reports whether tags has every tag of want.
+func hasTags(tags, want map[string]string) bool {
+	for k, v := range want {
+		if tags[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// number returns the integer value of a count decoded from a response.
+func number(v interface{}) int {
+	switch v := v.(type) {
+	case json.Number:
+		n, _ := v.Int64()
+		return int(n)
+	case float64:
+		return int(v)
+	case int64:
+		return int(v)
+	case uint64:
+		return int(v)
+	}
+	return 0
+}
@@ -0,0 +1,146 @@
+package clienttest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
+	client "github.com/influxdata/influxdb1-client/v2"
+)
+
+// eventualClient answers the COUNT queries with one more point of host b
+// each time, up to max, as points become visible on a server, and with
+// points at the times of visible.
+type eventualClient struct {
+	Client
+
+	mu      sync.Mutex
+	n, max  int
+	visible map[string]bool
+}
+
+func (c *eventualClient) QueryContext(ctx context.Context, q client.Query) (*client.Response, error) {
+	c.Client.QueryContext(ctx, q)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if i := strings.Index(q.Command, "time = "); i >= 0 {
+		if !c.visible[strings.Fields(q.Command[i+len("time = "):])[0]] {
+			return &client.Response{Results: []client.Result{{}}}, nil
+		}
+		return countResponse(map[string]int{"a": 1}), nil
+	}
+	if c.n < c.max {
+		c.n++
+	}
+	return countResponse(map[string]int{"a": 2, "b": c.n}), nil
+}
+
+// interruptedClient is an eventualClient whose queries past the first after
+// ones block until their context is done.
+type interruptedClient struct {
+	*eventualClient
+	after int
+}
+
+func (c *interruptedClient) QueryContext(ctx context.Context, q client.Query) (*client.Response, error) {
+	c.mu.Lock()
+	c.after--
+	blocked := c.after < 0
+	c.mu.Unlock()
+	if blocked {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return c.eventualClient.QueryContext(ctx, q)
+}
+
+// countResponse returns the response of a COUNT(*) grouped by host, with the
+// counts of two fields.
+func countResponse(counts map[string]int) *client.Response {
+	var rows []models.Row
+	for host, n := range counts {
+		rows = append(rows, models.Row{
+			Name:    "cpu",
+			Tags:    map[string]string{"host": host},
+			Columns: []string{"time", "count_value", "count_other"},
+			Values:  [][]interface{}{{json.Number("0"), json.Number(strconv.Itoa(n)), json.Number("1")}},
+		})
+	}
+	return &client.Response{Results: []client.Result{{Series: rows}}}
+}
+
+func TestWaitForPoints(t *testing.T) {
+	c := &eventualClient{max: 3, visible: map[string]bool{"1000000000": true}}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := WaitForPoints(ctx, c, "db0", VerifySpec{
+		Measurement:     "cpu",
+		Start:           time.Unix(0, 0),
+		Count:           5,
+		Times:           []time.Time{time.Unix(1, 0)},
+		TagSets:         []map[string]string{{"host": "b"}},
+		MinPollInterval: time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	queries := c.Queries()
+	// Three polls of the range, each followed by the query of the time.
+	if len(queries) != 6 {
+		t.Fatalf("unexpected number of queries.  expected %v, actual %v", 6, len(queries))
+	}
+	if exp := `SELECT COUNT(*) FROM "cpu" WHERE time >= 0 GROUP BY *`; queries[0].Command != exp || queries[0].Database != "db0" {
+		t.Errorf("unexpected query.  expected %q, actual %q", exp, queries[0].Command)
+	}
+}
+
+func TestWaitForPoints_Timeout(t *testing.T) {
+	c := &eventualClient{max: 1, visible: map[string]bool{}}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err := WaitForPoints(ctx, c, "db0", VerifySpec{
+		Measurement:     "cpu",
+		Count:           5,
+		Times:           []time.Time{time.Unix(2, 0)},
+		TagSets:         []map[string]string{{"host": "c"}},
+		MinPollInterval: time.Millisecond,
+	})
+	var werr *WaitError
+	if !errors.As(err, &werr) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("unexpected error.  expected %T, actual %v", werr, err)
+	}
+	if werr.Count != 3 || werr.Expected != 5 || len(werr.MissingTimes) != 1 || len(werr.MissingTagSets) != 1 {
+		t.Errorf("unexpected error: %+v", werr)
+	}
+	if !strings.Contains(err.Error(), "observed 3 points, expected 5") {
+		t.Errorf("unexpected message: %v", err)
+	}
+
+	// A query interrupted by the end of ctx leaves the last observation.
+	c = &eventualClient{max: 3, visible: map[string]bool{}}
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = WaitForPoints(ctx, &interruptedClient{eventualClient: c, after: 3}, "db0", VerifySpec{Measurement: "cpu", Count: 6, MinPollInterval: time.Millisecond})
+	if !errors.As(err, &werr) || !errors.Is(err, context.DeadlineExceeded) || werr.Count != 5 || werr.QueryErr != nil {
+		t.Errorf("unexpected error: %+v", err)
+	}
+
+	// A failing query is retried, and reported.
+	var failing Client
+	errBoom := errors.New("boom")
+	failing.OnQuery(`SELECT COUNT(*) FROM "cpu" GROUP BY *`, nil, errBoom)
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = WaitForPoints(ctx, &failing, "db0", VerifySpec{Measurement: "cpu", Count: 1, MinPollInterval: time.Millisecond})
+	if !errors.As(err, &werr) || werr.QueryErr != errBoom || werr.Count != -1 || len(failing.Queries()) < 2 {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
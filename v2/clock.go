@@ -0,0 +1,58 @@
+package client
+
+import (
+	"context"
+	"time"
+)
+
+// Clock tells the time and waits for the features of this package that depend
+// on it: the flushes of a BatchingClient, the delays between retries, the
+// budget of a RateLimit and the open state of a CircuitBreaker. It defaults
+// to the system clock; tests can set a clock they advance themselves, as
+// clienttest.FakeClock, so that they need no sleeps.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// After returns a channel that receives the current time once d has
+	// passed, as time.After does.
+	After(d time.Duration) <-chan time.Time
+}
+
+// systemClock is the Clock of the time package.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// clockOrSystem returns c, or the system clock if c is nil.
+func clockOrSystem(c Clock) Clock {
+	if c == nil {
+		return systemClock{}
+	}
+	return c
+}
+
+// sleepClock waits for d on clock, or until ctx is done.
+func sleepClock(ctx context.Context, clock Clock, d time.Duration) error {
+	if _, ok := clock.(systemClock); ok {
+		return sleepContext(ctx, d)
+	}
+	select {
+	case <-clock.After(d):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// assignTime returns p, or a copy of it with the time of clock if it has no
+// time, see BatchPointsConfig.AssignTimeOnAdd.
+func assignTime(p *Point, clock Clock) *Point {
+	if p == nil || !p.pt.Time().IsZero() {
+		return p
+	}
+	p = p.Clone()
+	p.SetTime(clock.Now())
+	return p
+}
@@ -0,0 +1,149 @@
+package client
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// DefaultDrainTimeout is how long Close waits for the writes and queries in
+// progress if the DrainTimeout of the config is not set.
+const DefaultDrainTimeout = 5 * time.Second
+
+// ErrClientClosed is returned by the writes, queries and pings of a client
+// once Close was called.
+var ErrClientClosed = errors.New("client is closed")
+
+// drainer tracks the operations in progress on a client, so that Close can
+// refuse new ones and wait for those in progress before releasing the
+// resources they use. The zero value is ready for use.
+type drainer struct {
+	timeout time.Duration
+
+	mu     sync.Mutex
+	closed bool
+	active int
+
+	// waiting is set while close waits for idle, which is closed when the
+	// last operation ends. release is left to that operation once close
+	// stopped waiting.
+	waiting bool
+	idle    chan struct{}
+	release func() error
+}
+
+// begin starts an operation, or returns ErrClientClosed once the client is
+// closing. Every successful begin must be followed by an end.
+func (d *drainer) begin() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return ErrClientClosed
+	}
+	d.active++
+	return nil
+}
+
+// end ends an operation. The last operation to end after the drain timeout
+// of close expired releases the resources of the client.
+func (d *drainer) end() {
+	d.mu.Lock()
+	d.active--
+	if !d.closed || d.active > 0 {
+		d.mu.Unlock()
+		return
+	}
+	if d.waiting {
+		close(d.idle)
+		d.mu.Unlock()
+		return
+	}
+	release := d.release
+	d.release = nil
+	d.mu.Unlock()
+	if release != nil {
+		release()
+	}
+}
+
+// close refuses new operations, waits for those in progress to end for up to
+// the drain timeout and then calls release, whose error it returns. If some
+// are still in progress by then, release is left to the last of them and
+// close returns nil. Later calls return nil without waiting.
+func (d *drainer) close(release func() error) error {
+	d.mu.Lock()
+	if d.closed {
+		d.mu.Unlock()
+		return nil
+	}
+	d.closed = true
+	if d.active == 0 {
+		d.mu.Unlock()
+		return release()
+	}
+	d.waiting = true
+	d.idle = make(chan struct{})
+	d.release = release
+	idle := d.idle
+	d.mu.Unlock()
+
+	timeout := d.timeout
+	if timeout == 0 {
+		timeout = DefaultDrainTimeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-idle:
+	case <-timer.C:
+	}
+
+	d.mu.Lock()
+	d.waiting = false
+	if d.active > 0 {
+		d.mu.Unlock()
+		return nil
+	}
+	d.release = nil
+	d.mu.Unlock()
+	return release()
+}
+
+// drainBody is the body of a response, ending the operation of its request
+// when it is read to the end or closed.
+type drainBody struct {
+	io.ReadCloser
+	once sync.Once
+	end  func()
+}
+
+func (b *drainBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.once.Do(b.end)
+	}
+	return n, err
+}
+
+func (b *drainBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.end)
+	return err
+}
+
+// recycleLoop closes the idle connections of the client every interval, so
+// that the requests that follow open new ones, until the client is closed.
+func (c *client) recycleLoop(interval time.Duration) {
+	defer close(c.recycled)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.recycling:
+			return
+		case <-ticker.C:
+		}
+		c.closeIdleConnections()
+	}
+}
@@ -0,0 +1,276 @@
+package client
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// hammerClose writes with c from several goroutines while closing it, and
+// checks that every write either succeeded or failed with ErrClientClosed.
+func hammerClose(t *testing.T, c Client) {
+	t.Helper()
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0"})
+			bp.AddPoints(newTestPoints(10))
+			for {
+				if err := c.Write(bp); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	if err := c.Close(); err != nil {
+		t.Errorf("unexpected error closing.  expected %v, actual %v", nil, err)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if !errors.Is(err, ErrClientClosed) {
+			t.Errorf("unexpected write error.  expected %v, actual %v", ErrClientClosed, err)
+		}
+	}
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0"})
+	bp.AddPoints(newTestPoints(1))
+	if err := c.Write(bp); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("unexpected error after closing.  expected %v, actual %v", ErrClientClosed, err)
+	}
+	if err := c.Close(); err != nil {
+		t.Errorf("unexpected error closing again.  expected %v, actual %v", nil, err)
+	}
+}
+
+func TestClose_HTTP(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(ioutil.Discard, r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	hammerClose(t, c)
+
+	if _, err := c.Query(Query{Command: "SHOW DATABASES"}); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("unexpected query error.  expected %v, actual %v", ErrClientClosed, err)
+	}
+	if _, _, err := c.Ping(0); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("unexpected ping error.  expected %v, actual %v", ErrClientClosed, err)
+	}
+}
+
+func TestClose_TCP(t *testing.T) {
+	for _, size := range []int{1, 3} {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		go func() {
+			for {
+				conn, err := l.Accept()
+				if err != nil {
+					return
+				}
+				go io.Copy(ioutil.Discard, conn)
+			}
+		}()
+
+		c, err := NewTCPClient(TCPConfig{Addr: l.Addr().String(), PoolSize: size})
+		if err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+		hammerClose(t, c)
+		if err := c.(RawBytesWriter).WriteRawBytes([]byte("cpu value=1\n")); !errors.Is(err, ErrClientClosed) {
+			t.Errorf("unexpected raw write error.  expected %v, actual %v", ErrClientClosed, err)
+		}
+		l.Close()
+	}
+}
+
+func TestClose_UDP(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	addr := conn.LocalAddr().String()
+
+	for _, conf := range []UDPConfig{{Addr: addr}, {Addrs: []string{addr, addr}}} {
+		c, err := NewUDPClient(conf)
+		if err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+		hammerClose(t, c)
+	}
+}
+
+func TestClose_Drain(t *testing.T) {
+	release := make(chan struct{})
+	received := make(chan struct{}, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	write := func(c Client) <-chan error {
+		done := make(chan error, 1)
+		go func() {
+			bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0"})
+			bp.AddPoints(newTestPoints(1))
+			done <- c.Write(bp)
+		}()
+		<-received
+		return done
+	}
+
+	// Close waits for the write in progress.
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	done := write(c)
+	closed := make(chan error, 1)
+	go func() { closed <- c.Close() }()
+	select {
+	case <-closed:
+		t.Fatal("Close returned before the write in progress finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+	release <- struct{}{}
+	if err := <-done; err != nil {
+		t.Errorf("unexpected write error.  expected %v, actual %v", nil, err)
+	}
+	if err := <-closed; err != nil {
+		t.Errorf("unexpected error closing.  expected %v, actual %v", nil, err)
+	}
+
+	// It gives up once the drain timeout expired.
+	c, _ = NewHTTPClient(HTTPConfig{Addr: ts.URL, DrainTimeout: 20 * time.Millisecond})
+	done = write(c)
+	start := time.Now()
+	if err := c.Close(); err != nil {
+		t.Errorf("unexpected error closing.  expected %v, actual %v", nil, err)
+	}
+	if d := time.Since(start); d < 20*time.Millisecond || d > time.Second {
+		t.Errorf("unexpected time closing.  expected %v, actual %v", 20*time.Millisecond, d)
+	}
+	release <- struct{}{}
+	if err := <-done; err != nil {
+		t.Errorf("unexpected write error.  expected %v, actual %v", nil, err)
+	}
+}
+
+func TestClose_DrainTimeoutConfig(t *testing.T) {
+	var ce *ConfigError
+	if _, err := NewHTTPClient(HTTPConfig{Addr: "http://localhost:8086", DrainTimeout: -1}); !errors.As(err, &ce) || ce.Field != "DrainTimeout" {
+		t.Errorf("unexpected HTTP error.  expected DrainTimeout, actual %v", err)
+	}
+	if _, err := NewTCPClient(TCPConfig{Addr: "localhost:8094", DrainTimeout: -1}); !errors.As(err, &ce) || ce.Field != "DrainTimeout" {
+		t.Errorf("unexpected TCP error.  expected DrainTimeout, actual %v", err)
+	}
+	if _, err := NewUDPClient(UDPConfig{Addr: "localhost:8089", DrainTimeout: -1}); !errors.As(err, &ce) || ce.Field != "DrainTimeout" {
+		t.Errorf("unexpected UDP error.  expected DrainTimeout, actual %v", err)
+	}
+}
+
+// newAddrServer returns a server answering writes that records the remote
+// address and Connection header of every request.
+func newAddrServer() (*httptest.Server, func() ([]string, []bool)) {
+	var mu sync.Mutex
+	var addrs []string
+	var closes []bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		addrs = append(addrs, r.RemoteAddr)
+		closes = append(closes, r.Close)
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	return ts, func() ([]string, []bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), addrs...), append([]bool(nil), closes...)
+	}
+}
+
+func countDistinct(s []string) int {
+	seen := make(map[string]bool)
+	for _, v := range s {
+		seen[v] = true
+	}
+	return len(seen)
+}
+
+func TestClient_DisableKeepAlives(t *testing.T) {
+	for _, disable := range []bool{false, true} {
+		ts, requests := newAddrServer()
+		c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, DisableKeepAlives: disable})
+		bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0"})
+		bp.AddPoints(newTestPoints(1))
+		for i := 0; i < 3; i++ {
+			if err := c.Write(bp); err != nil {
+				t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+			}
+		}
+		c.Close()
+		ts.Close()
+
+		addrs, closes := requests()
+		exp := 1
+		if disable {
+			exp = 3
+		}
+		if n := countDistinct(addrs); n != exp {
+			t.Errorf("unexpected connections with DisableKeepAlives %v.  expected %v, actual %v (%v)", disable, exp, n, addrs)
+		}
+		for _, closed := range closes {
+			if closed != disable {
+				t.Errorf("unexpected Connection: close with DisableKeepAlives %v: %v", disable, closes)
+				break
+			}
+		}
+	}
+}
+
+func TestClient_ConnectionRecycleInterval(t *testing.T) {
+	ts, requests := newAddrServer()
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, ConnectionRecycleInterval: 20 * time.Millisecond})
+	bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0"})
+	bp.AddPoints(newTestPoints(1))
+	for i := 0; i < 2; i++ {
+		if err := c.Write(bp); err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if addrs, _ := requests(); countDistinct(addrs) != 2 {
+		t.Errorf("unexpected connections.  expected %v, actual %v", 2, addrs)
+	}
+
+	for _, conf := range []HTTPConfig{
+		{Addr: ts.URL, ConnectionRecycleInterval: -time.Second},
+		{Addr: ts.URL, MaxIdleConnsPerHost: -1},
+	} {
+		var ce *ConfigError
+		if _, err := NewHTTPClient(conf); !errors.As(err, &ce) {
+			t.Errorf("unexpected error.  expected a *ConfigError, actual %v", err)
+		}
+	}
+}
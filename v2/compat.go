@@ -0,0 +1,28 @@
+package client
+
+import "fmt"
+
+// v2Precision maps the precision of a BatchPoints to the one accepted by the
+// /api/v2/write endpoint.
+func v2Precision(precision string) (string, error) {
+	switch precision {
+	case "", "n", "ns":
+		return "ns", nil
+	case "u", "us", "µ", "µs":
+		return "us", nil
+	case "ms", "s":
+		return precision, nil
+	}
+	return "", fmt.Errorf("precision %q is not supported by the v2 write endpoint, use ns, us, ms or s", precision)
+}
+
+// bucket returns the bucket the points of bp are written to.
+func (c *client) bucket(bp BatchPoints) string {
+	if c.bucketName != "" {
+		return c.bucketName
+	}
+	if rp := bp.RetentionPolicy(); rp != "" {
+		return bp.Database() + "/" + rp
+	}
+	return bp.Database()
+}
@@ -0,0 +1,136 @@
+package client
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestClient_WriteV2Compat(t *testing.T) {
+	var paths []string
+	var queries []url.Values
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		queries = append(queries, r.URL.Query())
+		if got, exp := r.Header.Get("Authorization"), "Token my-token"; got != exp {
+			t.Errorf("unexpected Authorization header.  expected %q, actual %q", exp, got)
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		if got, exp := string(body), "cpu value=1 1000000\n"; got != exp {
+			t.Errorf("unexpected body.  expected %q, actual %q", exp, got)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, AuthToken: "my-token", UseV2CompatWrite: true})
+	defer c.Close()
+
+	p, _ := NewPoint("cpu", nil, map[string]interface{}{"value": 1.0}, time.Unix(1000, 0))
+	for _, conf := range []BatchPointsConfig{
+		{Database: "db0", RetentionPolicy: "autogen", Precision: "ms"},
+		{Database: "db0", Precision: "ms"},
+	} {
+		bp, _ := NewBatchPoints(conf)
+		bp.AddPoint(p)
+		if err := c.Write(bp); err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+	}
+
+	for i, exp := range []string{"db0/autogen", "db0"} {
+		if paths[i] != "/api/v2/write" {
+			t.Errorf("unexpected path.  expected %q, actual %q", "/api/v2/write", paths[i])
+		}
+		q := queries[i]
+		if q.Get("bucket") != exp || q.Get("org") != "-" || q.Get("precision") != "ms" {
+			t.Errorf("unexpected query parameters: %v", q)
+		}
+		if q.Get("db") != "" || q.Get("consistency") != "" {
+			t.Errorf("unexpected v1 query parameters: %v", q)
+		}
+	}
+}
+
+func TestClient_WriteV2CompatBucket(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("bucket"); got != "my-bucket" {
+			t.Errorf("unexpected bucket: %q", got)
+		}
+		if got := r.URL.Query().Get("org"); got != "my-org" {
+			t.Errorf("unexpected org: %q", got)
+		}
+		if got := r.URL.Query().Get("precision"); got != "us" {
+			t.Errorf("unexpected precision: %q", got)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, UseV2CompatWrite: true, Bucket: "my-bucket", Org: "my-org"})
+	defer c.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0", Precision: "us"})
+	bp.AddPoints(newTestPoints(1))
+	if err := c.Write(bp); err != nil {
+		t.Errorf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+}
+
+func TestClient_WriteV2CompatPrecision(t *testing.T) {
+	c, _ := NewHTTPClient(HTTPConfig{Addr: "http://localhost:1", UseV2CompatWrite: true})
+	defer c.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{Precision: "h"})
+	bp.AddPoints(newTestPoints(1))
+	err := c.Write(bp)
+	if exp := `precision "h" is not supported by the v2 write endpoint, use ns, us, ms or s`; err == nil || err.Error() != exp {
+		t.Errorf("unexpected error.  expected %v, actual %v", exp, err)
+	}
+}
+
+func TestClient_WriteV2CompatError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write([]byte(`{"code":"unprocessable entity","message":"failure writing points to database: partial write: field type conflict: input field \"value\" on measurement \"cpu\" is type string, already exists as type float dropped=1"}`))
+	}))
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, UseV2CompatWrite: true})
+	defer c.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0"})
+	bp.AddPoints(newTestPoints(1))
+	err := c.Write(bp)
+
+	var pe *PartialWriteError
+	if !errors.As(err, &pe) {
+		t.Fatalf("unexpected error.  expected %T, actual %v", pe, err)
+	}
+	if pe.Code != "unprocessable entity" || pe.Dropped != 1 || pe.StatusCode != http.StatusUnprocessableEntity {
+		t.Errorf("unexpected partial write error: %+v", pe)
+	}
+	if !errors.Is(err, ErrFieldTypeConflict) {
+		t.Error("expected the error to match ErrFieldTypeConflict")
+	}
+}
+
+func TestParseErrorBody(t *testing.T) {
+	for _, tt := range []struct {
+		body, code, message string
+	}{
+		{body: `{"error":"database not found"}`, message: "database not found"},
+		{body: `{"code":"invalid","message":"unable to parse"}`, code: "invalid", message: "unable to parse"},
+		{body: "Bad Request\n", message: "Bad Request"},
+	} {
+		code, message := parseErrorBody([]byte(tt.body))
+		if code != tt.code || message != tt.message {
+			t.Errorf("%s: unexpected result %q, %q", tt.body, code, message)
+		}
+	}
+}
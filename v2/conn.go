@@ -0,0 +1,90 @@
+package client
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// aLongTimeAgo is a non-zero time in the past, used to unblock pending
+// operations on a connection immediately.
+var aLongTimeAgo = time.Unix(1, 0)
+
+// writeDeadliner is implemented by connections supporting write deadlines,
+// such as net.Conn.
+type writeDeadliner interface {
+	SetWriteDeadline(t time.Time) error
+}
+
+// bindWriteContext ties the write deadline of conn to ctx until the returned
+// function is called. The returned function clears the deadline again so the
+// connection remains usable, and translates err into ctx.Err() when the
+// failure was caused by ctx ending.
+func bindWriteContext(ctx context.Context, conn io.Writer) func(err error) error {
+	wd, ok := conn.(writeDeadliner)
+	if !ok || ctx.Done() == nil {
+		return func(err error) error {
+			return err
+		}
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		wd.SetWriteDeadline(deadline)
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		select {
+		case <-ctx.Done():
+			wd.SetWriteDeadline(aLongTimeAgo)
+		case <-stop:
+		}
+	}()
+
+	return func(err error) error {
+		close(stop)
+		<-done
+		wd.SetWriteDeadline(time.Time{})
+		return contextError(ctx, err)
+	}
+}
+
+// setWriteDeadline sets the write deadline of conn to timeout from now,
+// bounded by the deadline of ctx. It is a no-op for a zero timeout.
+func setWriteDeadline(ctx context.Context, conn io.Writer, timeout time.Duration) {
+	wd, ok := conn.(writeDeadliner)
+	if !ok || timeout <= 0 {
+		return
+	}
+
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	wd.SetWriteDeadline(deadline)
+
+	// Don't undo the deadline set by bindWriteContext when ctx ended
+	// concurrently.
+	if ctx.Err() != nil {
+		wd.SetWriteDeadline(aLongTimeAgo)
+	}
+}
+
+// writeFull writes all of b to w, continuing after short writes. It returns
+// the number of bytes written.
+func writeFull(w io.Writer, b []byte) (int, error) {
+	var written int
+	for written < len(b) {
+		n, err := w.Write(b[written:])
+		written += n
+		if n == 0 && err == nil {
+			err = io.ErrShortWrite
+		}
+		if err != nil && (n == 0 || err != io.ErrShortWrite) {
+			return written, err
+		}
+	}
+	return written, nil
+}
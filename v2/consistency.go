@@ -0,0 +1,96 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Consistency is the write consistency of a batch: the number of the owners
+// of a shard of an InfluxDB Enterprise cluster that must confirm a write for
+// it to succeed. Single node servers ignore it.
+type Consistency string
+
+const (
+	// ConsistencyAny succeeds once any node accepted the write, even if it
+	// is only queued there for the owners of the shard.
+	ConsistencyAny Consistency = "any"
+
+	// ConsistencyOne succeeds once one owner of the shard wrote the points.
+	ConsistencyOne Consistency = "one"
+
+	// ConsistencyQuorum succeeds once a majority of the owners wrote the
+	// points.
+	ConsistencyQuorum Consistency = "quorum"
+
+	// ConsistencyAll succeeds once every owner wrote the points.
+	ConsistencyAll Consistency = "all"
+)
+
+// ErrInvalidConsistency is returned for a write consistency that is not one
+// of the Consistency constants.
+var ErrInvalidConsistency = errors.New("invalid write consistency")
+
+// ParseConsistency returns the Consistency named by s, in any case. An empty
+// s returns an empty Consistency, which leaves the consistency to the server.
+func ParseConsistency(s string) (Consistency, error) {
+	switch c := Consistency(strings.ToLower(s)); c {
+	case "", ConsistencyAny, ConsistencyOne, ConsistencyQuorum, ConsistencyAll:
+		return c, nil
+	}
+	return "", fmt.Errorf("%w %q, expected any, one, quorum or all", ErrInvalidConsistency, s)
+}
+
+// ConsistencyError is returned by a write to an InfluxDB Enterprise cluster
+// that some owners of the shards stored, but not enough of them to meet the
+// write consistency, as the server answers with a 5xx status and a bare
+// "partial write", or a "partial write: timeout" when the rest of the owners
+// did not answer in time. Writes are idempotent, so it is retryable unlike a
+// *PartialWriteError: the owners that stored the points store them again.
+type ConsistencyError struct {
+	ErrorResponse
+
+	// Required is the consistency the write asked for, empty if it left it
+	// to the server.
+	Required string
+
+	// Achieved is the consistency the server reported to have reached, if
+	// it did.
+	Achieved string
+}
+
+func (e *ConsistencyError) Unwrap() error { return &e.ErrorResponse }
+
+// HintedHandoffError is returned by a write to an InfluxDB Enterprise cluster
+// rejected because the hinted handoff queue of an owner of the shards is not
+// empty, as the cluster answers to writes with consistency all while a node
+// catches up. It is retryable once the queue drained.
+type HintedHandoffError struct {
+	ErrorResponse
+}
+
+func (e *HintedHandoffError) Unwrap() error { return &e.ErrorResponse }
+
+// consistencyAchieved finds the consistency a cluster reports reaching in
+// the message of a consistency failure, such as "achieved: one".
+var consistencyAchieved = regexp.MustCompile(`achieved[ =:]+"?(\w+)`)
+
+// clusterError returns the *ConsistencyError or *HintedHandoffError er is
+// about, or nil.
+func clusterError(er ErrorResponse) error {
+	switch {
+	case strings.Contains(er.Message, "hinted handoff queue not empty"):
+		return &HintedHandoffError{ErrorResponse: er}
+	case er.StatusCode < 500:
+		return nil
+	case strings.Contains(er.Message, "partial write") && !strings.Contains(er.Message, " dropped="),
+		strings.Contains(er.Message, "consistency level not met"):
+		ce := &ConsistencyError{ErrorResponse: er}
+		if m := consistencyAchieved.FindStringSubmatch(er.Message); m != nil {
+			ce.Achieved = strings.ToLower(m[1])
+		}
+		return ce
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestConsistency(t *testing.T) {
+	var requests []url.Values
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Query())
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	for _, tt := range []struct {
+		value string
+		exp   string // the parameter sent, "-" if it is omitted
+		err   bool
+	}{
+		{value: "", exp: "-"},
+		{value: "any", exp: "any"},
+		{value: "one", exp: "one"},
+		{value: "Quorum", exp: "quorum"},
+		{value: "ALL", exp: "all"},
+		{value: "qourum", err: true},
+		{value: "two", err: true},
+		{value: " one", err: true},
+	} {
+		// A batch is configured with the value or given it afterwards.
+		for _, set := range []bool{false, true} {
+			requests = nil
+			conf := BatchPointsConfig{Database: "db0"}
+			if !set {
+				conf.WriteConsistency = tt.value
+			}
+			bp, err := NewBatchPoints(conf)
+			if !set && tt.err {
+				if !errors.Is(err, ErrInvalidConsistency) {
+					t.Errorf("unexpected error for %q.  expected %v, actual %v", tt.value, ErrInvalidConsistency, err)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatalf("unexpected error for %q.  expected %v, actual %v", tt.value, nil, err)
+			}
+			if set {
+				bp.SetWriteConsistency(tt.value)
+			}
+			bp.AddPoints(newTestPoints(1))
+
+			err = c.Write(bp)
+			if tt.err {
+				if !errors.Is(err, ErrInvalidConsistency) || len(requests) != 0 {
+					t.Errorf("unexpected write of %q.  expected %v, actual %v and %d requests", tt.value, ErrInvalidConsistency, err, len(requests))
+				}
+				continue
+			}
+			if err != nil || len(requests) != 1 {
+				t.Fatalf("unexpected write of %q: %v, %d requests", tt.value, err, len(requests))
+			}
+			got := "-"
+			if params := requests[0]; params.Has("consistency") {
+				got = params.Get("consistency")
+			}
+			if got != tt.exp {
+				t.Errorf("unexpected consistency parameter for %q.  expected %v, actual %v", tt.value, tt.exp, got)
+			}
+		}
+	}
+}
+
+func TestConsistency_WriteOptions(t *testing.T) {
+	bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0"})
+	if _, err := (WriteOptions{WriteConsistency: "qourum"}).apply(bp); !errors.Is(err, ErrInvalidConsistency) {
+		t.Errorf("unexpected error.  expected %v, actual %v", ErrInvalidConsistency, err)
+	}
+	if c, err := ParseConsistency("Any"); c != ConsistencyAny || err != nil {
+		t.Errorf("unexpected consistency.  expected %v, actual %v, %v", ConsistencyAny, c, err)
+	}
+}
+
+func TestClient_WriteClusterErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		code      int
+		body      string
+		check     func(err error) bool
+		retryable bool
+	}{
+		{
+			name: "consistency not met",
+			code: http.StatusInternalServerError,
+			body: `{"error":"partial write"}`,
+			check: func(err error) bool {
+				var ce *ConsistencyError
+				return errors.As(err, &ce) && ce.Required == "all" && ce.Achieved == ""
+			},
+			retryable: true,
+		},
+		{
+			name:      "timeout with partial success",
+			code:      http.StatusInternalServerError,
+			body:      `{"error":"partial write: timeout"}`,
+			check:     func(err error) bool { var ce *ConsistencyError; return errors.As(err, &ce) && ce.Required == "all" },
+			retryable: true,
+		},
+		{
+			name:      "achieved consistency reported",
+			code:      http.StatusInternalServerError,
+			body:      `{"error":"write failed: consistency level not met: required: all, achieved: ONE"}`,
+			check:     func(err error) bool { var ce *ConsistencyError; return errors.As(err, &ce) && ce.Achieved == "one" },
+			retryable: true,
+		},
+		{
+			name: "hinted handoff",
+			code: http.StatusServiceUnavailable,
+			body: `{"error":"write failed: hinted handoff queue not empty"}`,
+			check: func(err error) bool {
+				var he *HintedHandoffError
+				return errors.As(err, &he) && he.StatusCode == http.StatusServiceUnavailable
+			},
+			retryable: true,
+		},
+		{
+			name:      "partial hinted handoff",
+			code:      http.StatusInternalServerError,
+			body:      `{"error":"partial write: hinted handoff queue not empty"}`,
+			check:     func(err error) bool { var he *HintedHandoffError; return errors.As(err, &he) },
+			retryable: true,
+		},
+		{
+			name: "write failed",
+			code: http.StatusInternalServerError,
+			body: `{"error":"write failed: timeout"}`,
+			check: func(err error) bool {
+				var er *ErrorResponse
+				return errors.As(err, &er) && er.Message == "write failed: timeout"
+			},
+			retryable: true,
+		},
+		{
+			name:  "points rejected",
+			code:  http.StatusBadRequest,
+			body:  `{"error":"partial write: points beyond retention policy dropped=1"}`,
+			check: func(err error) bool { var pe *PartialWriteError; return errors.As(err, &pe) },
+		},
+		{
+			name:  "points rejected by a cluster",
+			code:  http.StatusInternalServerError,
+			body:  `{"error":"partial write: field type conflict: input field \"value\" on measurement \"cpu\" is type integer, already exists as type float dropped=1"}`,
+			check: func(err error) bool { var pe *PartialWriteError; return errors.As(err, &pe) },
+		},
+		{
+			name:  "bare partial write of a single node",
+			code:  http.StatusBadRequest,
+			body:  `{"error":"partial write"}`,
+			check: func(err error) bool { var pe *PartialWriteError; return errors.As(err, &pe) },
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(tt.code)
+				w.Write([]byte(tt.body))
+			}))
+			defer ts.Close()
+
+			c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, MaxRetries: 1, RetryInterval: time.Millisecond})
+			defer c.Close()
+
+			bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0", WriteConsistency: "all"})
+			bp.AddPoints(newTestPoints(1))
+			err := c.Write(bp)
+			if !tt.check(err) {
+				t.Errorf("unexpected error: %#v", err)
+			}
+			if IsRetryable(err) != tt.retryable {
+				t.Errorf("unexpected IsRetryable.  expected %v, actual %v", tt.retryable, !tt.retryable)
+			}
+			if exp := map[bool]int{false: 1, true: 2}[tt.retryable]; calls != exp {
+				t.Errorf("unexpected number of requests.  expected %v, actual %v", exp, calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,333 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MeasurementBackreference is the measurement of the INTO clause of a query
+// that writes every series into the measurement it was selected from.
+const MeasurementBackreference = ":MEASUREMENT"
+
+// CQSpec describes a continuous query.
+type CQSpec struct {
+	// Name of the continuous query.
+	Name string
+
+	// Select is the SELECT statement run by the continuous query, with a
+	// GROUP BY time clause, such as the Command of a query built by
+	// SelectBuilder. An INTO clause for IntoDatabase, IntoRetentionPolicy
+	// and IntoMeasurement is inserted before its FROM clause, unless it has
+	// one already.
+	Select string
+
+	// IntoDatabase, IntoRetentionPolicy and IntoMeasurement are where the
+	// results are written. The database and retention policy are optional,
+	// and the measurement may be MeasurementBackreference.
+	IntoDatabase        string
+	IntoRetentionPolicy string
+	IntoMeasurement     string
+
+	// Every and For are the RESAMPLE options: how often the query runs and
+	// the time range it covers. Zero leaves them to the GROUP BY interval.
+	Every time.Duration
+	For   time.Duration
+}
+
+// CQInfo is a continuous query returned by ShowContinuousQueries.
+type CQInfo struct {
+	// Database is the database the continuous query runs on.
+	Database string
+
+	// CQSpec is the continuous query parsed from Query. Its Select has no
+	// INTO clause, which is given by the Into fields instead.
+	CQSpec
+
+	// Query is the CREATE CONTINUOUS QUERY statement returned by the server.
+	Query string
+}
+
+// CreateContinuousQuery creates the continuous query spec on the database
+// db. It fails with an error matching ErrContinuousQueryExists if a
+// different query of the same name exists.
+func CreateContinuousQuery(ctx context.Context, c Client, db string, spec CQSpec) error {
+	if db == "" || spec.Name == "" {
+		return errors.New("database and continuous query names are required")
+	}
+	sel, err := spec.selectInto()
+	if err != nil {
+		return err
+	}
+	stmt := "CREATE CONTINUOUS QUERY " + QuoteIdent(spec.Name) + " ON " + QuoteIdent(db)
+	if spec.Every < 0 || spec.For < 0 {
+		return fmt.Errorf("invalid resample durations EVERY %v FOR %v", spec.Every, spec.For)
+	}
+	if spec.Every > 0 || spec.For > 0 {
+		stmt += " RESAMPLE"
+		for _, opt := range []struct {
+			name string
+			d    time.Duration
+		}{{"EVERY", spec.Every}, {"FOR", spec.For}} {
+			if opt.d == 0 {
+				continue
+			}
+			d, err := formatDuration(opt.d)
+			if err != nil {
+				return err
+			}
+			stmt += " " + opt.name + " " + d
+		}
+	}
+	stmt += " BEGIN " + sel + " END"
+	return execAdmin(ctx, c, stmt, db)
+}
+
+// DropContinuousQuery drops the continuous query name of the database db.
+// The data it wrote is kept.
+func DropContinuousQuery(ctx context.Context, c Client, db, name string) error {
+	if db == "" || name == "" {
+		return errors.New("database and continuous query names are required")
+	}
+	return execAdmin(ctx, c, "DROP CONTINUOUS QUERY "+QuoteIdent(name)+" ON "+QuoteIdent(db), db)
+}
+
+// ShowContinuousQueries returns the continuous queries of the database db,
+// or of every database if db is empty.
+func ShowContinuousQueries(ctx context.Context, c Client, db string) ([]CQInfo, error) {
+	resp, err := queryStatement(ctx, c, db, "SHOW CONTINUOUS QUERIES")
+	if err != nil {
+		return nil, err
+	}
+
+	var infos []CQInfo
+	for _, result := range resp.Results {
+		for _, row := range result.Series {
+			if db != "" && row.Name != db {
+				continue
+			}
+			for _, values := range row.Values {
+				info := CQInfo{Database: row.Name}
+				for i, col := range row.Columns {
+					if i >= len(values) {
+						break
+					}
+					switch col {
+					case "name":
+						info.Name, _ = values[i].(string)
+					case "query":
+						info.Query, _ = values[i].(string)
+					}
+				}
+				spec, err := parseContinuousQuery(info.Query)
+				if err != nil {
+					return nil, fmt.Errorf("continuous query %s of %s: %v", info.Name, info.Database, err)
+				}
+				info.CQSpec = spec
+				infos = append(infos, info)
+			}
+		}
+	}
+	return infos, nil
+}
+
+// selectInto returns the SELECT statement of spec with its INTO clause.
+func (spec *CQSpec) selectInto() (string, error) {
+	sel := strings.TrimSpace(spec.Select)
+	if sel == "" {
+		return "", errors.New("no select statement given")
+	}
+	if findKeyword(sel, "INTO") >= 0 {
+		if spec.IntoDatabase != "" || spec.IntoRetentionPolicy != "" || spec.IntoMeasurement != "" {
+			return "", errors.New("the select statement has an INTO clause already")
+		}
+		return sel, nil
+	}
+	if spec.IntoMeasurement == "" {
+		return "", errors.New("no measurement to select into")
+	}
+	from := findKeyword(sel, "FROM")
+	if from < 0 {
+		return "", errors.New("no FROM clause in the select statement")
+	}
+	into := QuoteIdent(spec.IntoMeasurement)
+	if spec.IntoMeasurement == MeasurementBackreference {
+		into = MeasurementBackreference
+	}
+	if spec.IntoDatabase != "" || spec.IntoRetentionPolicy != "" {
+		into = qualifySource(spec.IntoDatabase, spec.IntoRetentionPolicy, into)
+	}
+	return sel[:from] + "INTO " + into + " " + sel[from:], nil
+}
+
+// parseContinuousQuery parses a CREATE CONTINUOUS QUERY statement as returned
+// by SHOW CONTINUOUS QUERIES.
+func parseContinuousQuery(stmt string) (CQSpec, error) {
+	var spec CQSpec
+	p := &stmtScanner{s: stmt}
+	if !p.keywords("CREATE", "CONTINUOUS", "QUERY") {
+		return spec, fmt.Errorf("not a CREATE CONTINUOUS QUERY statement: %s", stmt)
+	}
+	var ok bool
+	if spec.Name, ok = p.ident(); !ok || !p.keywords("ON") {
+		return spec, fmt.Errorf("invalid continuous query name in %s", stmt)
+	}
+	if _, ok := p.ident(); !ok {
+		return spec, fmt.Errorf("invalid database name in %s", stmt)
+	}
+	if p.keywords("RESAMPLE") {
+		for _, opt := range []struct {
+			name string
+			d    *time.Duration
+		}{{"EVERY", &spec.Every}, {"FOR", &spec.For}} {
+			if !p.keywords(opt.name) {
+				continue
+			}
+			d, err := parseDuration(p.word())
+			if err != nil {
+				return spec, fmt.Errorf("invalid %s duration in %s: %v", opt.name, stmt, err)
+			}
+			*opt.d = d
+		}
+	}
+	if !p.keywords("BEGIN") {
+		return spec, fmt.Errorf("no BEGIN in %s", stmt)
+	}
+	body := strings.TrimSpace(p.s[p.i:])
+	if len(body) < 3 || !strings.EqualFold(body[len(body)-3:], "END") {
+		return spec, fmt.Errorf("no END in %s", stmt)
+	}
+	sel := strings.TrimSpace(body[:len(body)-3])
+
+	into := findKeyword(sel, "INTO")
+	if into < 0 {
+		return spec, fmt.Errorf("no INTO clause in %s", stmt)
+	}
+	p = &stmtScanner{s: sel, i: into + len("INTO")}
+	var parts []string
+	for {
+		p.skipSpace()
+		var part string
+		switch {
+		case strings.HasPrefix(p.s[p.i:], MeasurementBackreference):
+			part = MeasurementBackreference
+			p.i += len(part)
+		case p.i < len(p.s) && p.s[p.i] == '.':
+		default:
+			if part, ok = p.ident(); !ok {
+				return spec, fmt.Errorf("invalid INTO clause in %s", stmt)
+			}
+		}
+		parts = append(parts, part)
+		if p.i >= len(p.s) || p.s[p.i] != '.' {
+			break
+		}
+		p.i++
+	}
+	switch len(parts) {
+	case 3:
+		spec.IntoDatabase = parts[0]
+		fallthrough
+	case 2:
+		spec.IntoRetentionPolicy = parts[len(parts)-2]
+		fallthrough
+	case 1:
+		spec.IntoMeasurement = parts[len(parts)-1]
+	default:
+		return spec, fmt.Errorf("invalid INTO clause in %s", stmt)
+	}
+	spec.Select = strings.TrimRight(sel[:into], " ") + " " + strings.TrimLeft(sel[p.i:], " ")
+	return spec, nil
+}
+
+// stmtScanner reads the keywords and identifiers of an InfluxQL statement.
+type stmtScanner struct {
+	s string
+	i int
+}
+
+func (p *stmtScanner) skipSpace() {
+	for p.i < len(p.s) && isSpace(p.s[p.i]) {
+		p.i++
+	}
+}
+
+// keywords consumes the keywords kws, case insensitively, and reports whether
+// they were all there. Nothing is consumed otherwise.
+func (p *stmtScanner) keywords(kws ...string) bool {
+	i := p.i
+	for _, kw := range kws {
+		p.skipSpace()
+		if w := p.word(); !strings.EqualFold(w, kw) {
+			p.i = i
+			return false
+		}
+	}
+	return true
+}
+
+// word consumes the characters up to the next space.
+func (p *stmtScanner) word() string {
+	p.skipSpace()
+	start := p.i
+	for p.i < len(p.s) && !isSpace(p.s[p.i]) {
+		p.i++
+	}
+	return p.s[start:p.i]
+}
+
+// ident consumes a double quoted or bare identifier.
+func (p *stmtScanner) ident() (string, bool) {
+	p.skipSpace()
+	if p.i < len(p.s) && p.s[p.i] == '"' {
+		var b strings.Builder
+		for i := p.i + 1; i < len(p.s); i++ {
+			switch ch := p.s[i]; {
+			case ch == '"':
+				p.i = i + 1
+				return b.String(), true
+			case ch == '\\' && i+1 < len(p.s):
+				i++
+				if p.s[i] == 'n' {
+					b.WriteByte('\n')
+				} else {
+					b.WriteByte(p.s[i])
+				}
+			default:
+				b.WriteByte(ch)
+			}
+		}
+		return "", false
+	}
+	start := p.i
+	for p.i < len(p.s) && isWordByte(p.s[p.i]) {
+		p.i++
+	}
+	return p.s[start:p.i], p.i > start
+}
+
+// findKeyword returns the index of the first occurrence of the keyword kw in
+// stmt outside of quoted identifiers and strings, or -1.
+func findKeyword(stmt, kw string) int {
+	for i := 0; i < len(stmt); i++ {
+		switch ch := stmt[i]; ch {
+		case '"', '\'':
+			for i++; i < len(stmt) && stmt[i] != ch; i++ {
+				if stmt[i] == '\\' {
+					i++
+				}
+			}
+		default:
+			if (i == 0 || !isWordByte(stmt[i-1])) && len(stmt)-i >= len(kw) &&
+				strings.EqualFold(stmt[i:i+len(kw)], kw) &&
+				(i+len(kw) == len(stmt) || !isWordByte(stmt[i+len(kw)])) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func isSpace(ch byte) bool { return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r' }
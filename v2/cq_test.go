@@ -0,0 +1,237 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+func TestContinuousQuery_Statements(t *testing.T) {
+	ts, statements := newStatementServer(t, nil)
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	q, err := Select(Call("mean", "value").As("value")).
+		From("telegraf", "autogen", "cpu").
+		GroupBy(Time(30*time.Minute), "*").
+		Build()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx := context.Background()
+	steps := []struct {
+		run func() error
+		exp string
+	}{
+		{
+			run: func() error {
+				return CreateContinuousQuery(ctx, c, "telegraf", CQSpec{
+					Name:                "cq_30m",
+					Select:              q.Command,
+					IntoRetentionPolicy: "1y",
+					IntoMeasurement:     "cpu_30m",
+					Every:               time.Hour,
+					For:                 2 * time.Hour,
+				})
+			},
+			exp: `CREATE CONTINUOUS QUERY "cq_30m" ON "telegraf" RESAMPLE EVERY 1h FOR 2h BEGIN SELECT mean("value") AS "value" INTO "1y"."cpu_30m" FROM "telegraf"."autogen"."cpu" GROUP BY time(30m), * END`,
+		},
+		{
+			run: func() error {
+				return CreateContinuousQuery(ctx, c, "db0", CQSpec{
+					Name:            "all",
+					Select:          `SELECT max(*) FROM /.*/ GROUP BY time(1d)`,
+					IntoDatabase:    "db1",
+					IntoMeasurement: MeasurementBackreference,
+					For:             48 * time.Hour,
+				})
+			},
+			exp: `CREATE CONTINUOUS QUERY "all" ON "db0" RESAMPLE FOR 2d BEGIN SELECT max(*) INTO "db1"..:MEASUREMENT FROM /.*/ GROUP BY time(1d) END`,
+		},
+		{
+			run: func() error {
+				return CreateContinuousQuery(ctx, c, "db0", CQSpec{
+					Name:   "raw",
+					Select: `SELECT count("from") INTO "counts" FROM "m" GROUP BY time(1m)`,
+				})
+			},
+			exp: `CREATE CONTINUOUS QUERY "raw" ON "db0" BEGIN SELECT count("from") INTO "counts" FROM "m" GROUP BY time(1m) END`,
+		},
+		{
+			run: func() error { return DropContinuousQuery(ctx, c, "telegraf", "cq_30m") },
+			exp: `DROP CONTINUOUS QUERY "cq_30m" ON "telegraf"`,
+		},
+	}
+	for i, step := range steps {
+		if err := step.run(); err != nil {
+			t.Fatalf("%d: unexpected error: %v", i, err)
+		}
+		if got := (*statements)[i]; got != step.exp {
+			t.Errorf("%d: unexpected statement.\nexpected %s\nactual   %s", i, step.exp, got)
+		}
+	}
+}
+
+func TestShowContinuousQueries(t *testing.T) {
+	const (
+		cq0 = `CREATE CONTINUOUS QUERY cq_30m ON telegraf RESAMPLE EVERY 1h FOR 2h BEGIN SELECT mean(value) AS value INTO telegraf."1y".cpu_30m FROM telegraf.autogen.cpu GROUP BY time(30m), * END`
+		cq1 = `CREATE CONTINUOUS QUERY "my cq" ON telegraf BEGIN SELECT max(*) INTO telegraf.autogen.:MEASUREMENT FROM telegraf.autogen./.*/ WHERE "into" = 'INTO' GROUP BY time(1d) END`
+		cq2 = `CREATE CONTINUOUS QUERY other ON db1 BEGIN SELECT count(v) INTO counts FROM m GROUP BY time(1m) END`
+	)
+	ts, statements := newShowServer(t, map[string][]models.Row{
+		"SHOW CONTINUOUS QUERIES": {
+			{Name: "_internal", Columns: []string{"name", "query"}},
+			{Name: "telegraf", Columns: []string{"name", "query"}, Values: [][]interface{}{{"cq_30m", cq0}, {"my cq", cq1}}},
+			{Name: "db1", Columns: []string{"name", "query"}, Values: [][]interface{}{{"other", cq2}}},
+		},
+	})
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	infos, err := ShowContinuousQueries(context.Background(), c, "telegraf")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	exp := []CQInfo{
+		{
+			Database: "telegraf",
+			CQSpec: CQSpec{
+				Name:                "cq_30m",
+				Select:              `SELECT mean(value) AS value FROM telegraf.autogen.cpu GROUP BY time(30m), *`,
+				IntoDatabase:        "telegraf",
+				IntoRetentionPolicy: "1y",
+				IntoMeasurement:     "cpu_30m",
+				Every:               time.Hour,
+				For:                 2 * time.Hour,
+			},
+			Query: cq0,
+		},
+		{
+			Database: "telegraf",
+			CQSpec: CQSpec{
+				Name:                "my cq",
+				Select:              `SELECT max(*) FROM telegraf.autogen./.*/ WHERE "into" = 'INTO' GROUP BY time(1d)`,
+				IntoDatabase:        "telegraf",
+				IntoRetentionPolicy: "autogen",
+				IntoMeasurement:     MeasurementBackreference,
+			},
+			Query: cq1,
+		},
+	}
+	if !reflect.DeepEqual(infos, exp) {
+		t.Errorf("unexpected continuous queries.\nexpected %+v\nactual   %+v", exp, infos)
+	}
+	if got := (*statements)[0]; got != "SHOW CONTINUOUS QUERIES" {
+		t.Errorf("unexpected statement: %s", got)
+	}
+
+	infos, err = ShowContinuousQueries(context.Background(), c, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(infos) != 3 || infos[2].Database != "db1" || infos[2].IntoMeasurement != "counts" || infos[2].IntoDatabase != "" {
+		t.Errorf("unexpected continuous queries: %+v", infos)
+	}
+}
+
+func TestParseContinuousQuery_RoundTrip(t *testing.T) {
+	spec := CQSpec{
+		Name:                `we"ird`,
+		Select:              `SELECT last("v") FROM "db"."rp"."m" GROUP BY time(90m)`,
+		IntoDatabase:        "db",
+		IntoRetentionPolicy: "long term",
+		IntoMeasurement:     "m.last",
+		Every:               90 * time.Minute,
+	}
+	sel, err := spec.selectInto()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	parsed, err := parseContinuousQuery(`CREATE CONTINUOUS QUERY "we\"ird" ON "db" RESAMPLE EVERY 90m BEGIN ` + sel + ` END`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(parsed, spec) {
+		t.Errorf("unexpected spec.\nexpected %+v\nactual   %+v", spec, parsed)
+	}
+}
+
+func TestContinuousQuery_Invalid(t *testing.T) {
+	ts, _ := newStatementServer(t, func(string) string { return "continuous query already exists" })
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	ctx := context.Background()
+	valid := CQSpec{Name: "cq", Select: "SELECT mean(v) FROM m GROUP BY time(1h)", IntoMeasurement: "m_1h"}
+	err := CreateContinuousQuery(ctx, c, "db0", valid)
+	if !errors.Is(err, ErrContinuousQueryExists) || IgnoreExists(err) != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		db     string
+		modify func(*CQSpec)
+	}{
+		{name: "no database", modify: func(*CQSpec) {}},
+		{name: "no name", db: "db0", modify: func(s *CQSpec) { s.Name = "" }},
+		{name: "no select", db: "db0", modify: func(s *CQSpec) { s.Select = "" }},
+		{name: "no into", db: "db0", modify: func(s *CQSpec) { s.IntoMeasurement = "" }},
+		{name: "two intos", db: "db0", modify: func(s *CQSpec) { s.Select = "SELECT mean(v) INTO x FROM m GROUP BY time(1h)" }},
+		{name: "no from", db: "db0", modify: func(s *CQSpec) { s.Select = "SELECT 1" }},
+		{name: "negative every", db: "db0", modify: func(s *CQSpec) { s.Every = -time.Hour }},
+		{name: "sub-microsecond for", db: "db0", modify: func(s *CQSpec) { s.For = time.Nanosecond }},
+	} {
+		spec := valid
+		tt.modify(&spec)
+		if err := CreateContinuousQuery(ctx, c, tt.db, spec); err == nil || errors.Is(err, ErrContinuousQueryExists) {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+		}
+	}
+
+	for _, stmt := range []string{
+		"SELECT 1",
+		"CREATE CONTINUOUS QUERY cq ON db BEGIN SELECT mean(v) FROM m GROUP BY time(1h) END",
+		"CREATE CONTINUOUS QUERY cq ON db RESAMPLE EVERY 1x BEGIN SELECT mean(v) INTO n FROM m GROUP BY time(1h) END",
+		"CREATE CONTINUOUS QUERY cq ON db BEGIN SELECT mean(v) INTO n FROM m GROUP BY time(1h)",
+	} {
+		if _, err := parseContinuousQuery(stmt); err == nil {
+			t.Errorf("unexpected error for %s.  expected an error, actual %v", stmt, err)
+		}
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	for _, tt := range []struct {
+		s   string
+		exp time.Duration
+	}{
+		{"1w", 7 * 24 * time.Hour},
+		{"90m", 90 * time.Minute},
+		{"1h30m", 90 * time.Minute},
+		{"10u", 10 * time.Microsecond},
+		{"10µ", 10 * time.Microsecond},
+		{"5ns", 5},
+		{"INF", InfiniteDuration},
+	} {
+		if d, err := parseDuration(tt.s); err != nil || d != tt.exp {
+			t.Errorf("unexpected duration for %s.  expected %v, actual %v (%v)", tt.s, tt.exp, d, err)
+		}
+	}
+	for _, s := range []string{"", "h", "1", "1y"} {
+		if _, err := parseDuration(s); err == nil {
+			t.Errorf("unexpected error for %q.  expected an error, actual %v", s, err)
+		}
+	}
+}
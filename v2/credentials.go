@@ -0,0 +1,144 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultCredentialsTTL is how long the credentials returned by a
+// CredentialsProvider are used before it is called again.
+const DefaultCredentialsTTL = time.Minute
+
+// CredentialsProvider returns the credentials of the requests of an HTTP
+// client, such as from a secret store that rotates them. A token is sent as
+// an AuthToken is, and takes precedence over the username and password,
+// which are sent as Username and Password are, along with AuthViaParams.
+// Empty credentials send none.
+type CredentialsProvider func(ctx context.Context) (username, password, token string, err error)
+
+// credentials are the credentials of a request.
+type credentials struct {
+	username, password, token string
+}
+
+// credentialsCache caches the credentials of a CredentialsProvider for its
+// TTL.
+type credentialsCache struct {
+	provider CredentialsProvider
+	ttl      time.Duration
+	clock    Clock
+
+	// mu is held while the provider is called, so that concurrent requests
+	// wait for the credentials of one call.
+	mu      sync.Mutex
+	creds   credentials
+	expires time.Time
+	valid   bool
+}
+
+// get returns the cached credentials, calling the provider if they expired.
+func (cc *credentialsCache) get(ctx context.Context) (credentials, error) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if cc.valid && cc.clock.Now().Before(cc.expires) {
+		return cc.creds, nil
+	}
+	return cc.fetch(ctx)
+}
+
+// refresh returns new credentials after the server rejected used. The
+// provider is only called again if no other request did since used were
+// got.
+func (cc *credentialsCache) refresh(ctx context.Context, used credentials) (credentials, error) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	if cc.valid && cc.creds != used && cc.clock.Now().Before(cc.expires) {
+		return cc.creds, nil
+	}
+	return cc.fetch(ctx)
+}
+
+// fetch calls the provider and caches its credentials. cc.mu must be held.
+func (cc *credentialsCache) fetch(ctx context.Context) (credentials, error) {
+	var creds credentials
+	var err error
+	creds.username, creds.password, creds.token, err = cc.provider(ctx)
+	if err != nil {
+		cc.valid = false
+		return credentials{}, fmt.Errorf("credentials provider failed: %w", err)
+	}
+	cc.creds, cc.expires, cc.valid = creds, cc.clock.Now().Add(cc.ttl), true
+	return creds, nil
+}
+
+// auth returns the credentials of the next request to ep.
+func (c *client) auth(ctx context.Context, ep *endpoint) (credentials, error) {
+	if ep.creds != nil {
+		return *ep.creds, nil
+	}
+	if c.credentials == nil {
+		return credentials{username: c.username, password: c.password, token: c.authToken}, nil
+	}
+	return c.credentials.get(ctx)
+}
+
+// setAuth adds creds to req.
+func (c *client) setAuth(req *http.Request, creds credentials) {
+	switch {
+	case creds.token != "":
+		req.Header.Set("Authorization", "Token "+creds.token)
+	case creds.username == "":
+	case c.authViaParams:
+		params := req.URL.Query()
+		params.Set("u", creds.username)
+		params.Set("p", creds.password)
+		req.URL.RawQuery = params.Encode()
+	default:
+		req.SetBasicAuth(creds.username, creds.password)
+	}
+}
+
+// reauthRequest returns a copy of req, which the server answered resp with
+// 401 Unauthorized, with the credentials of the CredentialsProvider
+// refreshed, if they changed and the body of req can be sent again. resp is
+// closed if so.
+func (c *client) reauthRequest(req *http.Request, resp *http.Response, used credentials) (*http.Request, bool) {
+	if c.credentials == nil || req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return nil, false
+	}
+	creds, err := c.credentials.refresh(req.Context(), used)
+	if err != nil {
+		logf(c.logger, "influxdb: %v", err)
+		return nil, false
+	}
+	if creds == used {
+		return nil, false
+	}
+
+	retry := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, false
+		}
+		retry.Body = body
+	}
+	retry.Header.Del("Authorization")
+	params := retry.URL.Query()
+	if _, ok := params["p"]; ok {
+		params.Del("u")
+		params.Del("p")
+		retry.URL.RawQuery = params.Encode()
+	}
+	c.setAuth(retry, creds)
+
+	// Reading the rest of the body lets the connection be reused.
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 4<<10))
+	resp.Body.Close()
+	return retry, true
+}
@@ -0,0 +1,148 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// rotatingCredentials are credentials a test rotates, which the server
+// accepts only once rotated and the provider returns.
+type rotatingCredentials struct {
+	mu       sync.Mutex
+	password string
+	calls    int
+}
+
+func (rc *rotatingCredentials) get() string {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.password
+}
+
+func (rc *rotatingCredentials) rotate(password string) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.password = password
+}
+
+func (rc *rotatingCredentials) provider(ctx context.Context) (string, string, string, error) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.calls++
+	return "user", rc.password, "", nil
+}
+
+func TestClient_CredentialsProvider(t *testing.T) {
+	creds := &rotatingCredentials{password: "old"}
+	var bodies []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if u, p, _ := r.BasicAuth(); u != "user" || p != creds.get() {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"authorization failed"}`))
+			return
+		}
+		bodies = append(bodies, string(body))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	c, err := NewHTTPClient(HTTPConfig{Addr: ts.URL, CredentialsProvider: creds.provider})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	defer c.Close()
+
+	for i := 0; i < 2; i++ {
+		if err := c.Write(newTestBatch(t, 1)); err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+	}
+	if creds.calls != 1 {
+		t.Errorf("unexpected provider calls.  expected %v, actual %v", 1, creds.calls)
+	}
+
+	// The cached credentials are rejected once rotated, which has the
+	// provider called again and the write sent again, body included.
+	creds.rotate("new")
+	if err := c.Write(newTestBatch(t, 1)); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if creds.calls != 2 {
+		t.Errorf("unexpected provider calls.  expected %v, actual %v", 2, creds.calls)
+	}
+	if len(bodies) != 3 || bodies[2] != "cpu,host=server01 value=0i 0\n" {
+		t.Errorf("unexpected bodies: %q", bodies)
+	}
+	if _, _, err := c.Ping(0); err != nil {
+		t.Errorf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+}
+
+func TestClient_CredentialsProviderRejected(t *testing.T) {
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":"authorization failed"}`))
+	}))
+	defer ts.Close()
+
+	// Credentials still rejected after a refresh are not sent again.
+	creds := &rotatingCredentials{password: "old"}
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, CredentialsProvider: creds.provider})
+	defer c.Close()
+	var ae *AuthorizationError
+	if err := c.Write(newTestBatch(t, 1)); !errors.As(err, &ae) {
+		t.Errorf("unexpected error.  expected %T, actual %v", ae, err)
+	}
+	if requests != 1 || creds.calls != 2 {
+		t.Errorf("unexpected requests and provider calls.  expected 1 and 2, actual %v and %v", requests, creds.calls)
+	}
+
+	// A streamed body cannot be sent again.
+	requests = 0
+	calls := 0
+	rotating := func(ctx context.Context) (string, string, string, error) {
+		calls++
+		return "", "", strings.Repeat("t", calls), nil
+	}
+	c, _ = NewHTTPClient(HTTPConfig{Addr: ts.URL, CredentialsProvider: rotating})
+	defer c.Close()
+	sc := c.(*client)
+	if err := sc.WriteStream(context.Background(), "db0", "", "", strings.NewReader("cpu value=1\n")); !errors.As(err, &ae) {
+		t.Errorf("unexpected error.  expected %T, actual %v", ae, err)
+	}
+	if requests != 1 {
+		t.Errorf("unexpected requests.  expected %v, actual %v", 1, requests)
+	}
+}
+
+func TestClient_CredentialsProviderError(t *testing.T) {
+	ts := newErrorServer(http.StatusNoContent, "", "")
+	defer ts.Close()
+	errVault := errors.New("vault sealed")
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, CredentialsProvider: func(ctx context.Context) (string, string, string, error) {
+		return "", "", "", errVault
+	}})
+	defer c.Close()
+	if err := c.Write(newTestBatch(t, 1)); !errors.Is(err, errVault) {
+		t.Errorf("unexpected error.  expected %v, actual %v", errVault, err)
+	}
+
+	for _, conf := range []HTTPConfig{
+		{Addr: ts.URL, Username: "user", CredentialsProvider: (&rotatingCredentials{}).provider},
+		{Addr: ts.URL, CredentialsTTL: -1},
+	} {
+		var ce *ConfigError
+		if _, err := NewHTTPClient(conf); !errors.As(err, &ce) {
+			t.Errorf("unexpected error.  expected %T, actual %v", ce, err)
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Decoder maps series onto structs. Columns and tags are matched to fields by
+// their `influx:"name"` struct tag, untagged fields and fields tagged "-" are
+// left alone.
+//
+// Numbers decode into int, uint and float fields, strings and tag values into
+// string fields, and the time column into time.Time fields. A null value sets
+// a pointer field to nil and leaves any other field at its zero value.
+type Decoder struct {
+	// Precision is the epoch precision the query was made with, used to
+	// decode times returned as numbers. Defaults to the Epoch or Precision
+	// of the query a response is for, and to "ns" for a single series.
+	Precision string
+
+	// Strict makes decoding fail on a column that has no matching field.
+	// Unknown columns are skipped otherwise.
+	Strict bool
+}
+
+// DecodeSeries appends the values of row to dest, which must be a pointer to
+// a slice of structs or of pointers to structs.
+func DecodeSeries(row models.Row, dest interface{}) error {
+	return Decoder{}.DecodeSeries(row, dest)
+}
+
+// Scan appends the values of every series of r to dest, see DecodeSeries.
+// It returns the first error of the response, if any.
+func (r *Response) Scan(dest interface{}) error {
+	return Decoder{}.DecodeResponse(r, dest)
+}
+
+// Scan reads the next chunk of the stream and decodes its series into dest,
+// see DecodeSeries. Unlike DecodeSeries, the slice is truncated first so it
+// can be reused across chunks. Scan returns io.EOF at the end of the stream.
+func (r *ChunkedResponse) Scan(dest interface{}) error {
+	resp, err := r.NextResponse()
+	if err != nil {
+		return err
+	}
+	if resp == nil {
+		return io.EOF
+	}
+	slice, _, err := sliceOf(dest)
+	if err != nil {
+		return err
+	}
+	slice.SetLen(0)
+	return Decoder{Precision: r.precision}.DecodeResponse(resp, dest)
+}
+
+// DecodeResponse appends the values of every series of resp to dest.
+func (d Decoder) DecodeResponse(resp *Response, dest interface{}) error {
+	if err := resp.Error(); err != nil {
+		return err
+	}
+	if d.Precision == "" {
+		d.Precision = resp.precision
+	}
+	for _, result := range resp.Results {
+		for _, row := range result.Series {
+			if err := d.DecodeSeries(row, dest); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// DecodeSeries appends the values of row to dest, which must be a pointer to
+// a slice of structs or of pointers to structs.
+func (d Decoder) DecodeSeries(row models.Row, dest interface{}) error {
+	slice, elem, err := sliceOf(dest)
+	if err != nil {
+		return err
+	}
+	structType := elem
+	if elem.Kind() == reflect.Ptr {
+		structType = elem.Elem()
+	}
+	fields := structFields(structType)
+
+	unit, err := epochUnit(d.Precision)
+	if err != nil {
+		return err
+	}
+
+	columns := make([][]int, len(row.Columns))
+	for i, name := range row.Columns {
+		index, ok := fields[name]
+		if !ok && d.Strict {
+			return fmt.Errorf("column %q has no matching field in %s", name, structType)
+		}
+		columns[i] = index
+	}
+
+	for _, values := range row.Values {
+		v := reflect.New(structType).Elem()
+		for name, value := range row.Tags {
+			if index, ok := fields[name]; ok {
+				if err := setField(v.FieldByIndex(index), value, unit); err != nil {
+					return fmt.Errorf("tag %q: %v", name, err)
+				}
+			}
+		}
+		for i, value := range values {
+			if i >= len(columns) || columns[i] == nil {
+				continue
+			}
+			if err := setField(v.FieldByIndex(columns[i]), value, unit); err != nil {
+				return fmt.Errorf("column %q: %v", row.Columns[i], err)
+			}
+		}
+		if elem.Kind() == reflect.Ptr {
+			v = v.Addr()
+		}
+		slice.Set(reflect.Append(slice, v))
+	}
+	return nil
+}
+
+// sliceOf returns the slice dest points to and its element type.
+func sliceOf(dest interface{}) (reflect.Value, reflect.Type, error) {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Slice {
+		return reflect.Value{}, nil, fmt.Errorf("decode destination must be a non-nil pointer to a slice, got %T", dest)
+	}
+	elem := v.Elem().Type().Elem()
+	if elem.Kind() == reflect.Ptr {
+		if elem.Elem().Kind() != reflect.Struct {
+			return reflect.Value{}, nil, fmt.Errorf("decode destination must be a slice of structs, got %T", dest)
+		}
+	} else if elem.Kind() != reflect.Struct {
+		return reflect.Value{}, nil, fmt.Errorf("decode destination must be a slice of structs, got %T", dest)
+	}
+	return v.Elem(), elem, nil
+}
+
+// structFields maps influx tag names to the index of their field in t.
+func structFields(t reflect.Type) map[string][]int {
+	fields := make(map[string][]int)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := f.Tag.Get("influx")
+		if name == "" || name == "-" || f.PkgPath != "" {
+			continue
+		}
+		fields[name] = f.Index
+	}
+	return fields
+}
+
+// epochUnit returns the duration of one unit of the given epoch precision.
+func epochUnit(precision string) (time.Duration, error) {
+	switch precision {
+	case "", "n", "ns":
+		return time.Nanosecond, nil
+	case "u", "us", "µ", "µs":
+		return time.Microsecond, nil
+	case "ms":
+		return time.Millisecond, nil
+	case "s":
+		return time.Second, nil
+	case "m":
+		return time.Minute, nil
+	case "h":
+		return time.Hour, nil
+	}
+	return 0, fmt.Errorf("unknown precision %q", precision)
+}
+
+// setField stores a decoded JSON value into f.
+func setField(f reflect.Value, value interface{}, unit time.Duration) error {
+	if value == nil {
+		f.Set(reflect.Zero(f.Type()))
+		return nil
+	}
+	if f.Kind() == reflect.Ptr {
+		p := reflect.New(f.Type().Elem())
+		if err := setField(p.Elem(), value, unit); err != nil {
+			return err
+		}
+		f.Set(p)
+		return nil
+	}
+
+	if f.Type() == timeType {
+		t, err := decodeTime(value, unit)
+		if err != nil {
+			return err
+		}
+		f.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	switch f.Kind() {
+	case reflect.Interface:
+		f.Set(reflect.ValueOf(value))
+		return nil
+	case reflect.String:
+		if s, ok := value.(string); ok {
+			f.SetString(s)
+			return nil
+		}
+	case reflect.Bool:
+		if b, ok := value.(bool); ok {
+			f.SetBool(b)
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n, ok := numberString(value); ok {
+			i, err := strconv.ParseInt(n, 10, f.Type().Bits())
+			if err != nil {
+				return err
+			}
+			f.SetInt(i)
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n, ok := numberString(value); ok {
+			u, err := strconv.ParseUint(n, 10, f.Type().Bits())
+			if err != nil {
+				return err
+			}
+			f.SetUint(u)
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		if n, ok := numberString(value); ok {
+			v, err := strconv.ParseFloat(n, f.Type().Bits())
+			if err != nil {
+				return err
+			}
+			f.SetFloat(v)
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot decode %T into %s", value, f.Type())
+}
+
+// numberString returns the textual form of a numeric JSON value.
+func numberString(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case json.Number:
+		return string(v), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case uint64:
+		return strconv.FormatUint(v, 10), true
+	}
+	return "", false
+}
+
+// decodeTime parses an RFC3339 time or an epoch in the given unit.
+func decodeTime(value interface{}, unit time.Duration) (time.Time, error) {
+	switch v := value.(type) {
+	case string:
+		return time.Parse(time.RFC3339Nano, v)
+	case time.Time:
+		return v, nil
+	}
+	n, ok := numberString(value)
+	if !ok {
+		return time.Time{}, fmt.Errorf("cannot decode %T into time.Time", value)
+	}
+	i, err := strconv.ParseInt(n, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, i*int64(unit)).UTC(), nil
+}
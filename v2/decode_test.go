@@ -0,0 +1,233 @@
+package client
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+type cpuSample struct {
+	Time   time.Time `influx:"time"`
+	Host   string    `influx:"host"`
+	Value  float64   `influx:"value"`
+	Count  int64     `influx:"count"`
+	Cores  uint8     `influx:"cores"`
+	Idle   *float64  `influx:"idle"`
+	Up     bool      `influx:"up"`
+	Ignore string
+}
+
+func TestDecodeSeries(t *testing.T) {
+	row := models.Row{
+		Name:    "cpu",
+		Tags:    map[string]string{"host": "server01"},
+		Columns: []string{"time", "value", "count", "cores", "idle", "up", "extra"},
+		Values: [][]interface{}{
+			{"2019-01-02T03:04:05.000000006Z", json.Number("1.5"), json.Number("42"), json.Number("8"), json.Number("97.5"), true, "x"},
+			{"2019-01-02T03:04:06Z", json.Number("2"), json.Number("43"), json.Number("8"), nil, false, "y"},
+		},
+	}
+
+	var samples []cpuSample
+	if err := DecodeSeries(row, &samples); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(samples) != 2 {
+		t.Fatalf("unexpected number of samples: got %d, exp %d", len(samples), 2)
+	}
+
+	s := samples[0]
+	if exp := time.Date(2019, 1, 2, 3, 4, 5, 6, time.UTC); !s.Time.Equal(exp) {
+		t.Errorf("unexpected time: got %v, exp %v", s.Time, exp)
+	}
+	if s.Host != "server01" || s.Value != 1.5 || s.Count != 42 || s.Cores != 8 || !s.Up {
+		t.Errorf("unexpected sample: %+v", s)
+	}
+	if s.Idle == nil || *s.Idle != 97.5 {
+		t.Errorf("unexpected idle: %v", s.Idle)
+	}
+	if samples[1].Idle != nil {
+		t.Errorf("expected a nil idle for a null value, got %v", *samples[1].Idle)
+	}
+}
+
+func TestDecodeSeries_Pointers(t *testing.T) {
+	row := models.Row{Columns: []string{"value"}, Values: [][]interface{}{{json.Number("1")}}}
+
+	var samples []*cpuSample
+	if err := DecodeSeries(row, &samples); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(samples) != 1 || samples[0].Value != 1 {
+		t.Errorf("unexpected samples: %v", samples)
+	}
+}
+
+func TestDecoder_Strict(t *testing.T) {
+	row := models.Row{Columns: []string{"value", "extra"}, Values: [][]interface{}{{json.Number("1"), "x"}}}
+
+	var samples []cpuSample
+	err := Decoder{Strict: true}.DecodeSeries(row, &samples)
+	if err == nil || !strings.Contains(err.Error(), `column "extra"`) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestDecoder_EpochPrecision(t *testing.T) {
+	row := models.Row{Columns: []string{"time"}, Values: [][]interface{}{{json.Number("1546398245")}}}
+
+	var samples []cpuSample
+	if err := (Decoder{Precision: "s"}).DecodeSeries(row, &samples); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if exp := time.Unix(1546398245, 0); !samples[0].Time.Equal(exp) {
+		t.Errorf("unexpected time: got %v, exp %v", samples[0].Time, exp)
+	}
+}
+
+func TestDecodeSeries_Errors(t *testing.T) {
+	row := models.Row{Columns: []string{"cores"}, Values: [][]interface{}{{json.Number("300")}}}
+
+	var samples []cpuSample
+	if err := DecodeSeries(row, &samples); err == nil {
+		t.Error("expected an overflow error")
+	}
+	if err := DecodeSeries(models.Row{Columns: []string{"host"}, Values: [][]interface{}{{true}}}, &samples); err == nil {
+		t.Error("expected a type mismatch error")
+	}
+	if err := DecodeSeries(row, samples); err == nil {
+		t.Error("expected an error for a non-pointer destination")
+	}
+	var ints []int
+	if err := DecodeSeries(row, &ints); err == nil {
+		t.Error("expected an error for a slice of non-structs")
+	}
+}
+
+func TestResponse_Scan(t *testing.T) {
+	resp := &Response{Results: []Result{{Series: []models.Row{
+		{Columns: []string{"value"}, Values: [][]interface{}{{json.Number("1")}}},
+		{Columns: []string{"value"}, Values: [][]interface{}{{json.Number("2")}}},
+	}}}}
+
+	var samples []cpuSample
+	if err := resp.Scan(&samples); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(samples) != 2 || samples[1].Value != 2 {
+		t.Errorf("unexpected samples: %v", samples)
+	}
+
+	resp.Results[0].Err = "boom"
+	if err := resp.Scan(&samples); err == nil || err.Error() != "statement 0: boom" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestChunkedResponse_Scan(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.FormValue("epoch"); got != "ms" {
+			t.Errorf("unexpected epoch: %q", got)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		enc := json.NewEncoder(w)
+		for i := 0; i < 3; i++ {
+			enc.Encode(Response{Results: []Result{{Series: []models.Row{{
+				Columns: []string{"time", "value"},
+				Values:  [][]interface{}{{int64(i * 1000), float64(i)}, {int64(i*1000 + 1), float64(i)}},
+			}}}}})
+		}
+	}))
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	cr, err := c.QueryAsChunk(Query{Command: "SELECT * FROM cpu", Precision: "ms", Chunked: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer cr.Close()
+
+	var samples []cpuSample
+	var chunks int
+	for {
+		err := cr.Scan(&samples)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(samples) != 2 {
+			t.Fatalf("unexpected number of samples in chunk %d: %d", chunks, len(samples))
+		}
+		if exp := time.Unix(int64(chunks), 0); !samples[0].Time.Equal(exp) {
+			t.Errorf("unexpected time: got %v, exp %v", samples[0].Time, exp)
+		}
+		chunks++
+	}
+	if chunks != 3 {
+		t.Errorf("unexpected number of chunks: got %d, exp %d", chunks, 3)
+	}
+}
+
+func TestResponse_ScanEpoch(t *testing.T) {
+	var epochs []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		epochs = append(epochs, r.FormValue("epoch"))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results":[{"series":[{"name":"cpu","columns":["time","value"],"values":[[90,1]]}]}]}`))
+	}))
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	for _, tt := range []struct {
+		query Query
+		epoch string
+		exp   time.Time
+	}{
+		{Query{Epoch: "s"}, "s", time.Unix(90, 0)},
+		{Query{Epoch: "m", Precision: "s"}, "m", time.Unix(90*60, 0)},
+		{Query{Precision: "ms"}, "ms", time.Unix(0, 90e6)},
+		{Query{Epoch: "ns"}, "ns", time.Unix(0, 90)},
+	} {
+		epochs = nil
+		resp, err := c.Query(tt.query)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(epochs) != 1 || epochs[0] != tt.epoch {
+			t.Errorf("unexpected epoch parameter.  expected %q, actual %q", tt.epoch, epochs)
+		}
+		var samples []cpuSample
+		if err := resp.Scan(&samples); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(samples) != 1 || !samples[0].Time.Equal(tt.exp) {
+			t.Errorf("unexpected samples for epoch %q: %+v", tt.epoch, samples)
+		}
+	}
+}
+
+func TestClient_QueryInvalidEpoch(t *testing.T) {
+	c, _ := NewHTTPClient(HTTPConfig{Addr: "http://localhost:8086"})
+	defer c.Close()
+
+	exp := `invalid epoch "us": must be one of ns, u, ms, s, m or h`
+	if _, err := c.Query(Query{Epoch: "us"}); err == nil || err.Error() != exp {
+		t.Errorf("unexpected error.  expected %v, actual %v", exp, err)
+	}
+	if _, err := c.QueryAsChunk(Query{Epoch: "us"}); err == nil || err.Error() != exp {
+		t.Errorf("unexpected error.  expected %v, actual %v", exp, err)
+	}
+}
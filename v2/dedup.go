@@ -0,0 +1,120 @@
+package client
+
+import (
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+// DedupStats counts the points BatchPoints.Dedup removed.
+type DedupStats struct {
+	// Dropped is the number of points removed from the batch, as the
+	// server would have overwritten them with a later point.
+	Dropped int
+
+	// Merged is the number of points left whose fields were merged from
+	// duplicates carrying different fields.
+	Merged int
+}
+
+// dedupKey identifies the points the server stores as one: those of a series
+// at the same time, in the precision of the batch.
+type dedupKey struct {
+	series string
+	time   int64
+	noTime bool
+}
+
+// dedupPoints removes the duplicates from points in place, see
+// BatchPoints.Dedup, and returns the points left. Nil points are dropped.
+func dedupPoints(points []*Point, precision string) ([]*Point, DedupStats, error) {
+	var stats DedupStats
+	multiplier := models.GetPrecisionMultiplier(precision)
+
+	// merged holds the fields of the points that were merged so far, so
+	// that they are only parsed once.
+	var merged map[int]models.Fields
+	index := make(map[dedupKey]int, len(points))
+	out := make([]*Point, 0, len(points))
+	for _, p := range points {
+		if p == nil {
+			continue
+		}
+		key := dedupKey{series: string(p.pt.Key())}
+		if t := p.pt.Time(); t.IsZero() {
+			// The server gives every point without a time the same one.
+			key.noTime = true
+		} else {
+			key.time = p.pt.UnixNano() / multiplier
+		}
+
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(out)
+			out = append(out, p)
+			continue
+		}
+		stats.Dropped++
+
+		earlier, ok := merged[i]
+		if !ok {
+			var err error
+			if earlier, err = out[i].pt.Fields(); err != nil {
+				return nil, DedupStats{}, err
+			}
+		}
+		later, err := p.pt.Fields()
+		if err != nil {
+			return nil, DedupStats{}, err
+		}
+		if !ok && coversFields(later, earlier) {
+			// Nothing of the earlier point is left.
+			out[i] = p
+			continue
+		}
+		for k, v := range later {
+			earlier[k] = v
+		}
+		pt, err := models.NewPoint(string(p.pt.Name()), p.pt.Tags(), earlier, p.pt.Time())
+		if err != nil {
+			return nil, DedupStats{}, err
+		}
+		if merged == nil {
+			merged = make(map[int]models.Fields)
+			stats.Merged++
+		} else if _, ok := merged[i]; !ok {
+			stats.Merged++
+		}
+		merged[i] = earlier
+		out[i] = NewPointFrom(pt)
+	}
+	return out, stats, nil
+}
+
+// coversFields reports whether every field of b is in a.
+func coversFields(a, b models.Fields) bool {
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (bp *batchpoints) Dedup() (DedupStats, error) {
+	points, stats, err := dedupPoints(bp.points, bp.precision)
+	if err != nil {
+		return stats, err
+	}
+	n := copy(bp.points, points)
+	for i := n; i < len(bp.points); i++ {
+		bp.points[i] = nil
+	}
+	bp.points = bp.points[:n]
+	bp.size = bp.pointsSize()
+	return stats, nil
+}
+
+func (s *safeBatchPoints) Dedup() (DedupStats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bp.Dedup()
+}
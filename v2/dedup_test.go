@@ -0,0 +1,105 @@
+package client
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func mustPoint(t testing.TB, name string, tags map[string]string, fields map[string]interface{}, ts ...time.Time) *Point {
+	t.Helper()
+	pt, err := NewPoint(name, tags, fields, ts...)
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	return pt
+}
+
+func TestBatchPoints_Dedup(t *testing.T) {
+	t0 := time.Unix(100, 0)
+	bp, _ := NewBatchPoints(BatchPointsConfig{Precision: "s"})
+	bp.AddPoints([]*Point{
+		mustPoint(t, "cpu", map[string]string{"host": "a", "dc": "x"}, map[string]interface{}{"idle": 1.0, "user": 2.0}, t0),
+		mustPoint(t, "cpu", map[string]string{"host": "b"}, map[string]interface{}{"idle": 5.0}, t0),
+		// The same series, with the tags in another order, and the same
+		// second.
+		mustPoint(t, "cpu", map[string]string{"dc": "x", "host": "a"}, map[string]interface{}{"idle": 3.0}, t0.Add(500*time.Millisecond)),
+		mustPoint(t, "cpu", map[string]string{"host": "a", "dc": "x"}, map[string]interface{}{"system": 4.0}, t0),
+		// Another second.
+		mustPoint(t, "cpu", map[string]string{"host": "a", "dc": "x"}, map[string]interface{}{"idle": 9.0}, t0.Add(time.Second)),
+		// A full overwrite.
+		mustPoint(t, "cpu", map[string]string{"host": "b"}, map[string]interface{}{"idle": 6.0}, t0),
+		nil,
+		// Tag values needing escaping are compared escaped.
+		mustPoint(t, "m e", map[string]string{"k": "a,b"}, map[string]interface{}{"v": 1}, t0),
+		mustPoint(t, "m e", map[string]string{"k": "a\\,b"}, map[string]interface{}{"v": 2}, t0),
+		mustPoint(t, "m e", map[string]string{"k": "a,b"}, map[string]interface{}{"v": 3}, t0),
+	})
+
+	stats, err := bp.Dedup()
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if exp := (DedupStats{Dropped: 4, Merged: 1}); stats != exp {
+		t.Errorf("unexpected stats.  expected %+v, actual %+v", exp, stats)
+	}
+
+	var got []string
+	for _, p := range bp.Points() {
+		got = append(got, p.PrecisionString("s"))
+	}
+	exp := []string{
+		"cpu,dc=x,host=a idle=3,system=4,user=2 100",
+		"cpu,host=b idle=6 100",
+		"cpu,dc=x,host=a idle=9 101",
+		`m\ e,k=a\,b v=3i 100`,
+		`m\ e,k=a\\,b v=2i 100`,
+	}
+	if fmt.Sprintf("%q", got) != fmt.Sprintf("%q", exp) {
+		t.Errorf("unexpected points.\nexpected %q\nactual   %q", exp, got)
+	}
+}
+
+func TestBatchPoints_DedupNoTime(t *testing.T) {
+	bp, _ := NewSafeBatchPoints(BatchPointsConfig{})
+	bp.AddPoint(mustPoint(t, "cpu", nil, map[string]interface{}{"a": 1.0}))
+	bp.AddPoint(mustPoint(t, "cpu", nil, map[string]interface{}{"b": 2.0}))
+	bp.AddPoint(mustPoint(t, "cpu", nil, map[string]interface{}{"c": 3.0}, time.Unix(1, 0)))
+
+	stats, err := bp.Dedup()
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if exp := (DedupStats{Dropped: 1, Merged: 1}); stats != exp {
+		t.Errorf("unexpected stats.  expected %+v, actual %+v", exp, stats)
+	}
+	if points := bp.Points(); len(points) != 2 || points[0].String() != "cpu a=1,b=2" {
+		t.Errorf("unexpected points: %v", points)
+	}
+}
+
+func BenchmarkBatchPoints_Dedup(b *testing.B) {
+	// 100k points, a fifth of them duplicates of another with other fields.
+	const n = 100000
+	points := make([]*Point, 0, n)
+	t0 := time.Unix(1600000000, 0)
+	for i := 0; len(points) < n; i++ {
+		tags := map[string]string{"host": fmt.Sprintf("host-%d", i%100), "region": "eu"}
+		ts := t0.Add(time.Duration(i/100) * time.Second)
+		points = append(points, mustPoint(b, "cpu", tags, map[string]interface{}{"idle": float64(i), "user": 1.0}, ts))
+		if i%4 == 0 {
+			points = append(points, mustPoint(b, "cpu", tags, map[string]interface{}{"idle": float64(i), "system": 2.0}, ts))
+		}
+	}
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{Precision: "s"})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bp.Reset()
+		bp.AddPoints(points)
+		if _, err := bp.Dedup(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
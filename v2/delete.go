@@ -0,0 +1,139 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrUnboundedDelete is returned for a delete or drop that would match every
+// point or series, unless AllowFullDelete is set.
+var ErrUnboundedDelete = errors.New("delete matches all data, set AllowFullDelete to allow it")
+
+// DeleteCondition selects the points deleted by DeleteMeasurementData. At
+// least one of Start, End and Tags must be set, unless AllowFullDelete is.
+type DeleteCondition struct {
+	// Start and End bound the time range of the points deleted, End
+	// excluded. Either may be zero for an open range.
+	Start, End time.Time
+
+	// Tags restricts the delete to the series with these tag values.
+	Tags map[string]string
+
+	// AllowFullDelete allows a condition with no bound, which deletes
+	// every point of the measurement.
+	AllowFullDelete bool
+}
+
+// SeriesMatcher selects the series dropped by DropSeries. Tags must be set,
+// unless AllowFullDelete is.
+type SeriesMatcher struct {
+	// Measurement restricts the drop to the series of a measurement. Every
+	// measurement of the database is matched if it is empty.
+	Measurement string
+
+	// Tags restricts the drop to the series with these tag values.
+	Tags map[string]string
+
+	// AllowFullDelete allows a matcher with no tags, which drops every
+	// series of Measurement, or of the database.
+	AllowFullDelete bool
+}
+
+// DeleteMeasurementData deletes the points of measurement in the database db
+// that match cond. The series are kept in the index, see DropSeries. To
+// review the statement run without running it, see DeleteCondition.Statement.
+func DeleteMeasurementData(ctx context.Context, c Client, db, measurement string, cond DeleteCondition) error {
+	if db == "" {
+		return errors.New("database name is required")
+	}
+	stmt, err := cond.Statement(measurement)
+	if err != nil {
+		return err
+	}
+	return execAdmin(ctx, c, stmt, db)
+}
+
+// DropSeries drops the series of the database db that match m, with all of
+// their points. To review the statement run without running it, see
+// SeriesMatcher.Statement.
+func DropSeries(ctx context.Context, c Client, db string, m SeriesMatcher) error {
+	if db == "" {
+		return errors.New("database name is required")
+	}
+	stmt, err := m.Statement()
+	if err != nil {
+		return err
+	}
+	return execAdmin(ctx, c, stmt, db)
+}
+
+// Statement returns the DELETE statement DeleteMeasurementData runs for cond
+// and measurement, without running it.
+func (cond DeleteCondition) Statement(measurement string) (string, error) {
+	if measurement == "" {
+		return "", errors.New("measurement name is required")
+	}
+	if !cond.Start.IsZero() && !cond.End.IsZero() && !cond.End.After(cond.Start) {
+		return "", errors.New("delete time range ends before it starts")
+	}
+	where, err := tagConditions(cond.Tags)
+	if err != nil {
+		return "", err
+	}
+	if !cond.Start.IsZero() {
+		s, _ := literal(cond.Start)
+		where = append(where, "time >= "+s)
+	}
+	if !cond.End.IsZero() {
+		s, _ := literal(cond.End)
+		where = append(where, "time < "+s)
+	}
+	if len(where) == 0 && !cond.AllowFullDelete {
+		return "", ErrUnboundedDelete
+	}
+	return "DELETE FROM " + QuoteIdent(measurement) + whereClause(where), nil
+}
+
+// Statement returns the DROP SERIES statement DropSeries runs for m, without
+// running it.
+func (m SeriesMatcher) Statement() (string, error) {
+	where, err := tagConditions(m.Tags)
+	if err != nil {
+		return "", err
+	}
+	if len(where) == 0 && !m.AllowFullDelete {
+		return "", ErrUnboundedDelete
+	}
+	stmt := "DROP SERIES"
+	if m.Measurement != "" {
+		stmt += " FROM " + QuoteIdent(m.Measurement)
+	}
+	return stmt + whereClause(where), nil
+}
+
+// tagConditions returns the conditions matching tags, ordered by tag key.
+func tagConditions(tags map[string]string) ([]string, error) {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		if k == "" {
+			return nil, errors.New("empty tag key")
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	conds := make([]string, len(keys))
+	for i, k := range keys {
+		conds[i] = QuoteIdent(k) + " = " + QuoteString(tags[k])
+	}
+	return conds, nil
+}
+
+func whereClause(conds []string) string {
+	if len(conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conds, " AND ")
+}
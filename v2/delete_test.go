@@ -0,0 +1,138 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDeleteCondition_Statement(t *testing.T) {
+	start := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+	for _, tt := range []struct {
+		name        string
+		measurement string
+		cond        DeleteCondition
+		exp         string
+		err         error
+	}{
+		{
+			name:        "older than",
+			measurement: "cpu",
+			cond:        DeleteCondition{End: end},
+			exp:         `DELETE FROM "cpu" WHERE time < '2023-01-02T00:00:00Z'`,
+		},
+		{
+			name:        "range and tags",
+			measurement: `c"pu`,
+			cond:        DeleteCondition{Start: start, End: end, Tags: map[string]string{"region": "eu", "host": `it's\a`}},
+			exp:         `DELETE FROM "c\"pu" WHERE "host" = 'it\'s\\a' AND "region" = 'eu' AND time >= '2023-01-01T00:00:00Z' AND time < '2023-01-02T00:00:00Z'`,
+		},
+		{
+			name:        "unbounded",
+			measurement: "cpu",
+			err:         ErrUnboundedDelete,
+		},
+		{
+			name:        "full delete",
+			measurement: "cpu",
+			cond:        DeleteCondition{AllowFullDelete: true},
+			exp:         `DELETE FROM "cpu"`,
+		},
+	} {
+		stmt, err := tt.cond.Statement(tt.measurement)
+		if err != tt.err {
+			t.Errorf("%s: unexpected error.  expected %v, actual %v", tt.name, tt.err, err)
+		}
+		if stmt != tt.exp {
+			t.Errorf("%s: unexpected statement.\nexpected %s\nactual   %s", tt.name, tt.exp, stmt)
+		}
+	}
+
+	for _, cond := range []DeleteCondition{
+		{Start: end, End: start},
+		{Tags: map[string]string{"": "a"}},
+	} {
+		if _, err := cond.Statement("cpu"); err == nil {
+			t.Errorf("unexpected error for %+v.  expected an error, actual %v", cond, err)
+		}
+	}
+	if _, err := (DeleteCondition{End: end}).Statement(""); err == nil {
+		t.Error("expected an error for an empty measurement name")
+	}
+}
+
+func TestSeriesMatcher_Statement(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		matcher SeriesMatcher
+		exp     string
+		err     error
+	}{
+		{
+			name:    "tags",
+			matcher: SeriesMatcher{Measurement: "cpu", Tags: map[string]string{"host": "web-1"}},
+			exp:     `DROP SERIES FROM "cpu" WHERE "host" = 'web-1'`,
+		},
+		{
+			name:    "every measurement",
+			matcher: SeriesMatcher{Tags: map[string]string{"host": "web-1"}},
+			exp:     `DROP SERIES WHERE "host" = 'web-1'`,
+		},
+		{
+			name:    "unbounded",
+			matcher: SeriesMatcher{Measurement: "cpu"},
+			err:     ErrUnboundedDelete,
+		},
+		{
+			name:    "full delete",
+			matcher: SeriesMatcher{Measurement: "cpu", AllowFullDelete: true},
+			exp:     `DROP SERIES FROM "cpu"`,
+		},
+	} {
+		stmt, err := tt.matcher.Statement()
+		if err != tt.err {
+			t.Errorf("%s: unexpected error.  expected %v, actual %v", tt.name, tt.err, err)
+		}
+		if stmt != tt.exp {
+			t.Errorf("%s: unexpected statement.\nexpected %s\nactual   %s", tt.name, tt.exp, stmt)
+		}
+	}
+}
+
+func TestDeleteMeasurementDataAndDropSeries(t *testing.T) {
+	ts, statements := newStatementServer(t, nil)
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+	ctx := context.Background()
+
+	if err := DeleteMeasurementData(ctx, c, "db0", "cpu", DeleteCondition{Tags: map[string]string{"host": "a"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := DropSeries(ctx, c, "db0", SeriesMatcher{Measurement: "cpu", Tags: map[string]string{"host": "a"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	exp := []string{`DELETE FROM "cpu" WHERE "host" = 'a'`, `DROP SERIES FROM "cpu" WHERE "host" = 'a'`}
+	for i, stmt := range exp {
+		if (*statements)[i] != stmt {
+			t.Errorf("unexpected statement.\nexpected %s\nactual   %s", stmt, (*statements)[i])
+		}
+	}
+
+	// Unbounded deletes never reach the server.
+	if err := DeleteMeasurementData(ctx, c, "db0", "cpu", DeleteCondition{}); !errors.Is(err, ErrUnboundedDelete) {
+		t.Errorf("unexpected error.  expected %v, actual %v", ErrUnboundedDelete, err)
+	}
+	if err := DropSeries(ctx, c, "db0", SeriesMatcher{}); !errors.Is(err, ErrUnboundedDelete) {
+		t.Errorf("unexpected error.  expected %v, actual %v", ErrUnboundedDelete, err)
+	}
+	if err := DropSeries(ctx, c, "", SeriesMatcher{Tags: map[string]string{"host": "a"}}); err == nil {
+		t.Error("expected an error for an empty database name")
+	}
+	if len(*statements) != 2 {
+		t.Errorf("unexpected statements: %q", *statements)
+	}
+}
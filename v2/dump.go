@@ -0,0 +1,129 @@
+package client
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// DefaultDumpBodySize is the number of bytes of the bodies given to the
+// DumpRequest and DumpResponse hooks of an HTTPConfig whose DumpBodySize is
+// not set.
+const DefaultDumpBodySize = 64 << 10
+
+// redactedHeaders are the headers whose credentials are redacted from dumps.
+var redactedHeaders = []string{"Authorization", "Proxy-Authorization"}
+
+// dumper calls the dump hooks of an HTTPConfig.
+type dumper struct {
+	request  func(method, url string, headers http.Header, body []byte)
+	response func(status int, headers http.Header, body []byte)
+	max      int
+}
+
+// newDumper returns the dumper of conf, nil if it has no hook.
+func newDumper(conf HTTPConfig) *dumper {
+	if conf.DumpRequest == nil && conf.DumpResponse == nil {
+		return nil
+	}
+	max := conf.DumpBodySize
+	if max == 0 {
+		max = DefaultDumpBodySize
+	}
+	return &dumper{request: conf.DumpRequest, response: conf.DumpResponse, max: max}
+}
+
+// teeRequest makes the body of req be kept as it is sent, and returns the
+// function dumping req once it was.
+func (d *dumper) teeRequest(req *http.Request) func() {
+	if d.request == nil {
+		return func() {}
+	}
+	var body *teeBody
+	if req.Body != nil && req.Body != http.NoBody {
+		// An http.NoBody replaced with another body would be sent with
+		// chunked encoding.
+		body = &teeBody{ReadCloser: req.Body, max: d.max}
+		req.Body = body
+	}
+	return func() {
+		var b []byte
+		if body != nil {
+			b = body.bytes()
+		}
+		u := *req.URL
+		u.User = nil
+		d.request(req.Method, redactURL(u.String()), redactHeaders(req.Header), b)
+	}
+}
+
+// teeResponse makes the body of resp be kept as it is read, and dumped when
+// it is read to the end or closed.
+func (d *dumper) teeResponse(resp *http.Response) {
+	if d.response == nil {
+		return
+	}
+	body := &teeBody{ReadCloser: resp.Body, max: d.max}
+	body.done = func() { d.response(resp.StatusCode, redactHeaders(resp.Header), body.bytes()) }
+	resp.Body = body
+}
+
+// redactHeaders returns a copy of h with the credentials of its
+// Authorization headers replaced, keeping their scheme.
+func redactHeaders(h http.Header) http.Header {
+	h = h.Clone()
+	for _, key := range redactedHeaders {
+		for i, v := range h[key] {
+			if scheme, _, ok := strings.Cut(v, " "); ok {
+				h[key][i] = scheme + " xxxxx"
+			} else {
+				h[key][i] = "xxxxx"
+			}
+		}
+	}
+	return h
+}
+
+// teeBody keeps the first max bytes read from a body, calling done, if set,
+// once when the body is read to the end or closed.
+type teeBody struct {
+	io.ReadCloser
+	max  int
+	done func()
+
+	mu   sync.Mutex
+	kept []byte
+	once sync.Once
+}
+
+func (b *teeBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.mu.Lock()
+	if room := b.max - len(b.kept); room > 0 {
+		if room > n {
+			room = n
+		}
+		b.kept = append(b.kept, p[:room]...)
+	}
+	b.mu.Unlock()
+	if err == io.EOF && b.done != nil {
+		b.once.Do(b.done)
+	}
+	return n, err
+}
+
+func (b *teeBody) Close() error {
+	err := b.ReadCloser.Close()
+	if b.done != nil {
+		b.once.Do(b.done)
+	}
+	return err
+}
+
+// bytes returns a copy of the bytes kept so far.
+func (b *teeBody) bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]byte(nil), b.kept...)
+}
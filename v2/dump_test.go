@@ -0,0 +1,140 @@
+package client
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestHTTPClient_Dump(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/query" {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"results":[{"statement_id":0}]}`))
+			return
+		}
+		w.Header().Set("X-Influxdb-Error", "partial write")
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer ts.Close()
+
+	type dump struct {
+		method, url string
+		status      int
+		headers     http.Header
+		body        string
+	}
+	var mu sync.Mutex
+	var dumps []dump
+	c, err := NewHTTPClient(HTTPConfig{
+		Addr:          ts.URL,
+		Username:      "user",
+		Password:      "secret",
+		AuthViaParams: true,
+		DumpBodySize:  20,
+		DumpRequest: func(method, url string, headers http.Header, body []byte) {
+			mu.Lock()
+			dumps = append(dumps, dump{method: method, url: url, headers: headers, body: string(body)})
+			mu.Unlock()
+		},
+		DumpResponse: func(status int, headers http.Header, body []byte) {
+			mu.Lock()
+			dumps = append(dumps, dump{status: status, headers: headers, body: string(body)})
+			mu.Unlock()
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	defer c.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0"})
+	bp.AddPoints(newTestPoints(3))
+	if err := c.Write(bp); err == nil {
+		t.Fatalf("unexpected error.  expected the write to fail, actual %v", err)
+	}
+	if _, err := c.Query(Query{Command: "SHOW DATABASES"}); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(dumps) != 4 {
+		t.Fatalf("unexpected dumps.  expected %v, actual %+v", 4, dumps)
+	}
+	req := dumps[0]
+	if req.method != "POST" || !strings.Contains(req.url, "/write?") || !strings.Contains(req.url, "p=xxxxx") || strings.Contains(req.url, "secret") {
+		t.Errorf("unexpected request dumped: %s %s", req.method, req.url)
+	}
+	if exp := "cpu value=0 0\ncpu va"; req.body != exp {
+		t.Errorf("unexpected request body.  expected %q, actual %q", exp, req.body)
+	}
+	if resp := dumps[1]; resp.status != http.StatusBadRequest || resp.headers.Get("X-Influxdb-Error") != "partial write" {
+		t.Errorf("unexpected response dumped: %+v", resp)
+	}
+	if req, resp := dumps[2], dumps[3]; req.method != "GET" || req.body != "" || resp.body != `{"results":[{"statem` {
+		t.Errorf("unexpected query dumped: %+v, %+v", req, resp)
+	}
+
+	var ce *ConfigError
+	if _, err := NewHTTPClient(HTTPConfig{Addr: ts.URL, DumpBodySize: -1}); !errors.As(err, &ce) {
+		t.Errorf("unexpected error.  expected a *ConfigError, actual %v", err)
+	}
+}
+
+func TestRedactHeaders(t *testing.T) {
+	h := http.Header{"Authorization": {"Token abc"}, "Proxy-Authorization": {"abc"}, "User-Agent": {"ua"}}
+	redacted := redactHeaders(h)
+	if exp := (http.Header{"Authorization": {"Token xxxxx"}, "Proxy-Authorization": {"xxxxx"}, "User-Agent": {"ua"}}); !reflect.DeepEqual(redacted, exp) {
+		t.Errorf("unexpected headers.  expected %v, actual %v", exp, redacted)
+	}
+	if h.Get("Authorization") != "Token abc" {
+		t.Errorf("unexpected header modified: %v", h)
+	}
+}
+
+func TestTCPClient_DumpPayload(t *testing.T) {
+	var dumped []string
+	uc := newTCPTestClient(discardConn{}, "")
+	uc.payloadSize = MinPayloadSize
+	uc.dumpPayload = func(b []byte) { dumped = append(dumped, string(b)) }
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	bp.AddPoints(newTestPoints(100))
+	if err := uc.Write(bp); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if len(dumped) < 2 || !strings.HasPrefix(dumped[0], "cpu value=0 0\n") {
+		t.Errorf("unexpected payloads dumped: %q", dumped)
+	}
+}
+
+func TestUDPClient_DumpPayload(t *testing.T) {
+	l, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	for _, conf := range []UDPConfig{{Addr: l.LocalAddr().String()}, {Addrs: []string{l.LocalAddr().String()}}} {
+		var dumped []string
+		conf.DumpPayload = func(b []byte) { dumped = append(dumped, string(b)) }
+		c, err := NewUDPClient(conf)
+		if err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+		bp, _ := NewBatchPoints(BatchPointsConfig{})
+		bp.AddPoints(newTestPoints(2))
+		if err := c.Write(bp); err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+		c.Close()
+		if exp := []string{"cpu value=0 0\ncpu value=1 1000000000\n"}; strings.Join(dumped, "|") != strings.Join(exp, "|") {
+			t.Errorf("unexpected datagrams dumped.  expected %q, actual %q", exp, dumped)
+		}
+	}
+}
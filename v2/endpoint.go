@@ -0,0 +1,146 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// EndpointConfig overrides settings of HTTPConfig for the requests to one of
+// its endpoints, see HTTPConfig.WriteEndpoint. The settings left empty are
+// those of the HTTPConfig.
+type EndpointConfig struct {
+	// TLSConfig, if set, is the TLS config of the connections to the
+	// endpoint in place of HTTPConfig.TLSConfig. The endpoint then has its
+	// own connections, which ReloadTLS does not change, and cannot be used
+	// with HTTPConfig.Transport.
+	TLSConfig *tls.Config
+
+	// Username, Password and AuthToken, if one of them is set, are the
+	// credentials of the endpoint in place of those of the HTTPConfig or of
+	// its CredentialsProvider. AuthToken cannot be combined with Username
+	// and Password.
+	Username  string
+	Password  string
+	AuthToken string
+}
+
+// endpoint is where the client sends a kind of request, and how.
+type endpoint struct {
+	url url.URL
+
+	// httpClient sends the requests, and untimedClient shares its
+	// transport without its timeout, for queries that carry their own.
+	httpClient    *http.Client
+	untimedClient *http.Client
+
+	// creds, if set, are the credentials of the endpoint in place of
+	// those of the client.
+	creds *credentials
+}
+
+// newEndpoint returns the endpoint at addr, the field of conf it is from, or
+// at base if addr is empty, with the overrides of ec. tr is the transport of
+// the client, used unless ec has a TLS config, in which case the transport
+// of the endpoint is returned along with it.
+func newEndpoint(conf HTTPConfig, field, addr string, ec EndpointConfig, base *url.URL, tr http.RoundTripper, socketPath string) (endpoint, *reloadableTransport, error) {
+	u := base
+	if addr != "" {
+		var err error
+		if u, err = parseHTTPAddr(addr, conf.TLSConfig != nil || ec.TLSConfig != nil); err != nil {
+			if ce, ok := err.(*ConfigError); ok {
+				ce.Field = field
+			}
+			return endpoint{}, nil, err
+		}
+		if u.Scheme == "unix" || socketPath != "" {
+			return endpoint{}, nil, &ConfigError{Field: field, Reason: "unix socket addresses are only supported by Addr"}
+		}
+	}
+
+	overrides := field[:len(field)-len("Addr")] + "Endpoint"
+	var creds *credentials
+	if ec.Username != "" || ec.Password != "" || ec.AuthToken != "" {
+		if ec.AuthToken != "" && (ec.Username != "" || ec.Password != "") {
+			return endpoint{}, nil, &ConfigError{Field: overrides, Reason: "AuthToken cannot be used together with Username and Password"}
+		}
+		if ec.AuthToken != "" && conf.AuthViaParams {
+			return endpoint{}, nil, &ConfigError{Field: overrides, Reason: "AuthToken cannot be used together with AuthViaParams"}
+		}
+		creds = &credentials{username: ec.Username, password: ec.Password, token: ec.AuthToken}
+	}
+
+	var own *reloadableTransport
+	if ec.TLSConfig != nil {
+		if conf.Transport != nil {
+			return endpoint{}, nil, &ConfigError{Field: overrides, Reason: "TLSConfig cannot be used with a custom Transport"}
+		}
+		own = newHTTPTransport(conf, ec.TLSConfig, socketPath)
+		tr = own
+	}
+	return endpoint{
+		url: *u,
+		httpClient: &http.Client{
+			Timeout:       conf.Timeout,
+			Transport:     tr,
+			CheckRedirect: checkRedirect(conf.MaxRedirects),
+		},
+		untimedClient: &http.Client{
+			Transport:     tr,
+			CheckRedirect: checkRedirect(conf.MaxRedirects),
+		},
+		creds: creds,
+	}, own, nil
+}
+
+// newHTTPTransport returns the transport of the settings of conf, with the
+// given TLS config if not nil, over the unix socket at socketPath if set.
+func newHTTPTransport(conf HTTPConfig, tlsConfig *tls.Config, socketPath string) *reloadableTransport {
+	t := &http.Transport{
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: conf.InsecureSkipVerify,
+		},
+		Proxy:               conf.Proxy,
+		DisableKeepAlives:   conf.DisableKeepAlives,
+		MaxIdleConnsPerHost: conf.MaxIdleConnsPerHost,
+	}
+	if tlsConfig != nil {
+		t.TLSClientConfig = tlsConfig
+	}
+	if socketPath != "" {
+		t.Proxy = nil
+		t.DialContext = dialUnixSocket(socketPath)
+	}
+	return newReloadableTransport(t)
+}
+
+// closeIdleConnections closes the idle connections of the transports of the
+// client.
+func (c *client) closeIdleConnections() {
+	if t, ok := c.transport.(interface{ CloseIdleConnections() }); ok {
+		t.CloseIdleConnections()
+	}
+	for _, t := range c.endpointTransports {
+		t.CloseIdleConnections()
+	}
+}
+
+// pingBoth pings the query endpoint and, if it is another one, the write
+// endpoint, returning the slower result or the first error.
+func (c *client) pingBoth(ctx context.Context, timeout time.Duration) (PingResult, error) {
+	res, err := c.ping(ctx, &c.queryEP, timeout)
+	if err != nil || c.writeEP.url == c.queryEP.url {
+		return res, err
+	}
+	wres, err := c.ping(ctx, &c.writeEP, timeout)
+	if err != nil {
+		return PingResult{}, fmt.Errorf("write endpoint: %w", err)
+	}
+	if wres.Latency > res.Latency {
+		return wres, nil
+	}
+	return res, nil
+}
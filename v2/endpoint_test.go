@@ -0,0 +1,133 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// endpointServer records the paths and Authorization headers of the
+// requests it receives, and answers them after delay.
+type endpointServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []string
+	delay    time.Duration
+}
+
+func newEndpointServer(version string, delay time.Duration) *endpointServer {
+	s := &endpointServer{delay: delay}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, r.URL.Path+" "+r.Header.Get("Authorization"))
+		s.mu.Unlock()
+		time.Sleep(s.delay)
+		w.Header().Set("X-Influxdb-Version", version)
+		if r.URL.Path == "/query" {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"results":[{"statement_id":0}]}`))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	return s
+}
+
+func (s *endpointServer) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+func TestClient_SplitEndpoints(t *testing.T) {
+	ingest := newEndpointServer("ingest", 0)
+	defer ingest.Close()
+	replica := newEndpointServer("replica", 0)
+	defer replica.Close()
+
+	c, err := NewHTTPClient(HTTPConfig{
+		WriteAddr:     ingest.URL,
+		QueryAddr:     replica.URL,
+		AuthToken:     "shared",
+		WriteEndpoint: EndpointConfig{AuthToken: "ingest"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	defer c.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db"})
+	pt, _ := NewPoint("cpu", nil, map[string]interface{}{"value": 1})
+	bp.AddPoint(pt)
+	if err := c.Write(bp); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if _, err := c.Query(NewQuery("SELECT * FROM cpu", "db", "")); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	_, version, err := c.Ping(0)
+	if err != nil || version != "replica" {
+		t.Errorf("unexpected ping.  expected %v, actual %v, %v", "replica", version, err)
+	}
+
+	if exp, got := []string{"/write Token ingest"}, ingest.received(); strings.Join(exp, ",") != strings.Join(got, ",") {
+		t.Errorf("unexpected requests of the write endpoint.  expected %v, actual %v", exp, got)
+	}
+	if exp, got := []string{"/query Token shared", "/ping Token shared"}, replica.received(); strings.Join(exp, ",") != strings.Join(got, ",") {
+		t.Errorf("unexpected requests of the query endpoint.  expected %v, actual %v", exp, got)
+	}
+}
+
+func TestClient_PingBothEndpoints(t *testing.T) {
+	ingest := newEndpointServer("ingest", 50*time.Millisecond)
+	defer ingest.Close()
+	replica := newEndpointServer("replica", 0)
+	defer replica.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{WriteAddr: ingest.URL, QueryAddr: replica.URL, PingBothEndpoints: true})
+	defer c.Close()
+	res, err := c.(HealthClient).PingContext(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if res.Version != "ingest" || res.Latency < 50*time.Millisecond {
+		t.Errorf("unexpected result.  expected the slower write endpoint, actual %+v", res)
+	}
+
+	// A write endpoint down fails the ping.
+	ingest.Close()
+	if _, err := c.(HealthClient).PingContext(context.Background(), 0); err == nil || !strings.Contains(err.Error(), "write endpoint") {
+		t.Errorf("unexpected error.  expected a write endpoint error, actual %v", err)
+	}
+}
+
+func TestNewHTTPClient_SplitEndpointsInvalid(t *testing.T) {
+	for _, tt := range []struct {
+		conf  HTTPConfig
+		field string
+	}{
+		{HTTPConfig{Addr: "http://localhost:8086", WriteAddr: "ftp://ingest"}, "WriteAddr"},
+		{HTTPConfig{Addr: "http://localhost:8086", QueryAddr: "unix:///var/run/influxdb.sock"}, "QueryAddr"},
+		{HTTPConfig{Addr: "unix:///var/run/influxdb.sock", WriteAddr: "http://ingest"}, "WriteAddr"},
+		{HTTPConfig{WriteAddr: "http://ingest"}, "Addr"},
+		{HTTPConfig{Addr: "http://localhost:8086", WriteEndpoint: EndpointConfig{Username: "u", AuthToken: "t"}}, "WriteEndpoint"},
+		{HTTPConfig{
+			Addr:          "http://localhost:8086",
+			Transport:     http.DefaultTransport,
+			QueryEndpoint: EndpointConfig{TLSConfig: &tls.Config{}},
+		}, "QueryEndpoint"},
+	} {
+		_, err := NewHTTPClient(tt.conf)
+		var ce *ConfigError
+		if !errors.As(err, &ce) || ce.Field != tt.field {
+			t.Errorf("unexpected error for %+v.  expected a %s *ConfigError, actual %v", tt.conf, tt.field, err)
+		}
+	}
+}
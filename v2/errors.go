@@ -0,0 +1,354 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrQueryNotSupported is matched by the error the UDP and TCP clients return
+// from their Query methods.
+var ErrQueryNotSupported = errors.New("querying is not supported")
+
+// Errors matched by an *ErrorResponse or a *StatementError whose message
+// reports the condition.
+var (
+	ErrDatabaseNotFound            = errors.New("database not found")
+	ErrFieldTypeConflict           = errors.New("field type conflict")
+	ErrPointsBeyondRetentionPolicy = errors.New("points beyond retention policy")
+	ErrDatabaseExists              = errors.New("database already exists")
+	ErrRetentionPolicyExists       = errors.New("retention policy already exists")
+	ErrRetentionPolicyNotFound     = errors.New("retention policy not found")
+	ErrMaxValuesPerTagExceeded     = errors.New("max-values-per-tag limit exceeded")
+	ErrContinuousQueryExists       = errors.New("continuous query already exists")
+	ErrContinuousQueryNotFound     = errors.New("continuous query not found")
+	ErrSubscriptionExists          = errors.New("subscription already exists")
+	ErrSubscriptionNotFound        = errors.New("subscription not found")
+	ErrUserExists                  = errors.New("user already exists")
+	ErrUserNotFound                = errors.New("user not found")
+)
+
+// matchesMessage reports whether message reports the condition target.
+func matchesMessage(message string, target error) bool {
+	switch target {
+	case ErrDatabaseNotFound, ErrFieldTypeConflict, ErrPointsBeyondRetentionPolicy,
+		ErrDatabaseExists, ErrRetentionPolicyExists, ErrRetentionPolicyNotFound,
+		ErrMaxValuesPerTagExceeded, ErrContinuousQueryExists, ErrContinuousQueryNotFound,
+		ErrSubscriptionExists, ErrSubscriptionNotFound, ErrUserExists, ErrUserNotFound:
+		return strings.Contains(message, target.Error())
+	}
+	return false
+}
+
+// ErrorResponse is an error reported by the server through the status code of
+// a response.
+type ErrorResponse struct {
+	StatusCode int
+
+	// Code is the error code sent by InfluxDB 2.x compatible endpoints,
+	// such as "invalid" or "unauthorized". It is empty otherwise.
+	Code string
+
+	// Message is the error sent by the server, taken from the
+	// X-Influxdb-Error header or from the body of the response.
+	Message string
+
+	// RequestID is the ID the server assigned to the request, if any.
+	RequestID string
+
+	// Body is the start of the body of the response, up to the
+	// HTTPConfig.MaxErrorBodySize of the client. The error of a chunk,
+	// whose body is not kept, has the chunk as the server encodes it.
+	Body []byte
+}
+
+// ServerError is the name ErrorResponse goes by for the callers looking for
+// the status code, body and request ID of a failed request:
+//
+//	var se *client.ServerError
+//	if errors.As(err, &se) {
+//		log.Printf("status %d, request %s: %s", se.StatusCode, se.RequestID, se.Body)
+//	}
+type ServerError = ErrorResponse
+
+// DefaultMaxErrorBodySize is the default number of bytes of the body of a
+// response kept in its ErrorResponse.
+const DefaultMaxErrorBodySize = 4 << 10
+
+func (e *ErrorResponse) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("received status code %d from server", e.StatusCode)
+	}
+	return e.Message
+}
+
+// Is reports whether the message of e matches one of the Err variables of
+// this package, such as ErrDatabaseNotFound.
+func (e *ErrorResponse) Is(target error) bool { return matchesMessage(e.Message, target) }
+
+// StatementError is the error of a single statement of a query.
+type StatementError struct {
+	Statement string
+	Message   string
+}
+
+func (e *StatementError) Error() string { return e.Message }
+
+// Is reports whether the message of e matches one of the Err variables of
+// this package, such as ErrRetentionPolicyExists.
+func (e *StatementError) Is(target error) bool { return matchesMessage(e.Message, target) }
+
+// PartialWriteError is returned when the server rejected some of the points
+// of a write and stored the others. Such a write should not be retried, as
+// that would write the stored points again.
+type PartialWriteError struct {
+	ErrorResponse
+
+	// Dropped is the number of points rejected, if the server reported it.
+	Dropped int
+
+	// Written is the number of points of the batch that were stored, or -1
+	// if the server did not report how many were dropped.
+	Written int
+
+	// Reason is why the points were rejected.
+	Reason string
+
+	// Measurement is the measurement of the rejected points, if the server
+	// named it, as it does for field type conflicts and for exceeding the
+	// max-values-per-tag limit.
+	Measurement string
+
+	// Line is the first line of the batch the server could not parse, if
+	// any.
+	Line string
+
+	droppedKnown bool
+}
+
+func (e *PartialWriteError) Unwrap() error { return &e.ErrorResponse }
+
+// setTotal sets Written from the number of points in the batch, if the
+// number of dropped points is known.
+func (e *PartialWriteError) setTotal(points int) {
+	if e.droppedKnown && points >= e.Dropped {
+		e.Written = points - e.Dropped
+	}
+}
+
+// Patterns for the details InfluxDB 1.x includes in partial write errors,
+// such as `input field "value" on measurement "cpu" is type ...`,
+// `max-values-per-tag limit exceeded (100001/100000): measurement="cpu" ...`
+// and `unable to parse 'cpu value=': missing field value`.
+var (
+	partialWriteMeasurement = regexp.MustCompile(`on measurement "([^"]*)"|measurement="([^"]*)"`)
+	partialWriteLine        = regexp.MustCompile(`unable to parse '(.*?)': `)
+)
+
+// AuthorizationError is returned when the server rejected the credentials of
+// the client.
+type AuthorizationError struct {
+	ErrorResponse
+}
+
+func (e *AuthorizationError) Unwrap() error { return &e.ErrorResponse }
+
+// ThrottledError is returned when the server, or a gateway in front of it,
+// answered 429 Too Many Requests. It carries the hints of the response for
+// callers that schedule their own retries.
+type ThrottledError struct {
+	ErrorResponse
+
+	// RetryAfter is the delay the Retry-After header asked to wait before
+	// the next request, or 0 if there was none.
+	RetryAfter time.Duration
+
+	// Remaining is the number of requests left in the current rate limit
+	// window, from the X-RateLimit-Remaining header, or -1 if there was
+	// none.
+	Remaining int
+
+	// Reset is when the rate limit window resets, from the
+	// X-RateLimit-Reset header given in Unix seconds or in seconds from
+	// now, or the zero time if there was none.
+	Reset time.Time
+}
+
+func (e *ThrottledError) Unwrap() error { return &e.ErrorResponse }
+
+// Delay returns how long to wait before the next request: RetryAfter if the
+// server sent it, else the time left until Reset, or 0 without either hint.
+func (e *ThrottledError) Delay() time.Duration {
+	return e.delay(time.Now())
+}
+
+// delay is Delay measured from now.
+func (e *ThrottledError) delay(now time.Time) time.Duration {
+	if e.RetryAfter > 0 || e.Reset.IsZero() {
+		return e.RetryAfter
+	}
+	if d := e.Reset.Sub(now); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// ConfigError is returned by NewTCPClient and NewUDPClient when a field of
+// the config is invalid, as opposed to the address being unreachable.
+type ConfigError struct {
+	// Field is the name of the invalid field, such as "PayloadSize".
+	Field string
+
+	// Reason describes what is wrong with it.
+	Reason string
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Field, e.Reason)
+}
+
+// validatePayloadSize checks the PayloadSize of a TCP or UDP config. Zero
+// stands for the default.
+func validatePayloadSize(size, max int) error {
+	switch {
+	case size < 0:
+		return &ConfigError{Field: "PayloadSize", Reason: fmt.Sprintf("%d is negative", size)}
+	case size > 0 && size < MinPayloadSize:
+		return &ConfigError{Field: "PayloadSize", Reason: fmt.Sprintf("%d is less than the minimum of %d bytes", size, MinPayloadSize)}
+	case max > 0 && size > max:
+		return &ConfigError{Field: "PayloadSize", Reason: fmt.Sprintf("%d exceeds the maximum of %d bytes", size, max)}
+	}
+	return nil
+}
+
+// queryNotSupportedError is returned by clients that can only write.
+type queryNotSupportedError string
+
+func (e queryNotSupportedError) Error() string {
+	return "Querying via " + string(e) + " is not supported"
+}
+
+func (e queryNotSupportedError) Is(target error) bool { return target == ErrQueryNotSupported }
+
+// newErrorResponse returns the typed error for a response with an unexpected
+// status code. message is the error found in the body, if any; the
+// X-Influxdb-Error header takes precedence over it.
+func newErrorResponse(resp *http.Response, message string) error {
+	return newCodedErrorResponse(resp, "", message)
+}
+
+// newErrorResponseBody is like newErrorResponse, taking the error from the
+// body of the response.
+func newErrorResponseBody(resp *http.Response, body []byte) error {
+	code, message := parseErrorBody(body)
+	return newCodedErrorResponse(resp, code, message)
+}
+
+func newCodedErrorResponse(resp *http.Response, code, message string) error {
+	if h := resp.Header.Get("X-Influxdb-Error"); h != "" {
+		message = h
+	}
+	er := ErrorResponse{StatusCode: resp.StatusCode, Code: code, Message: message, RequestID: requestID(resp)}
+	if err := clusterError(er); err != nil {
+		return err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return &AuthorizationError{ErrorResponse: er}
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return &ThrottledError{
+			ErrorResponse: er,
+			RetryAfter:    parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+			Remaining:     parseRateLimitRemaining(resp.Header.Get("X-RateLimit-Remaining")),
+			Reset:         parseRateLimitReset(resp.Header.Get("X-RateLimit-Reset"), time.Now()),
+		}
+	case strings.Contains(message, "partial write"):
+		pe := &PartialWriteError{ErrorResponse: er, Written: -1}
+		reason := message[strings.Index(message, "partial write")+len("partial write"):]
+		reason = strings.TrimPrefix(reason, ":")
+		if i := strings.LastIndex(reason, " dropped="); i >= 0 {
+			if n, err := strconv.Atoi(strings.TrimSpace(reason[i+len(" dropped="):])); err == nil {
+				pe.Dropped = n
+				pe.droppedKnown = true
+				reason = reason[:i]
+			}
+		}
+		pe.Reason = strings.TrimSpace(reason)
+		if m := partialWriteMeasurement.FindStringSubmatch(pe.Reason); m != nil {
+			pe.Measurement = m[1] + m[2]
+		}
+		if m := partialWriteLine.FindStringSubmatch(pe.Reason); m != nil {
+			pe.Line = m[1]
+		}
+		return pe
+	}
+	return &er
+}
+
+// errorBody sets the Body of the ErrorResponse err is or wraps, if any, to
+// the start of body, and returns err.
+func (c *client) errorBody(err error, body []byte) error {
+	return setErrorBody(err, body, c.maxErrorBody)
+}
+
+// setErrorBody sets the Body of the ErrorResponse err is or wraps, if any, to
+// the first max bytes of body, and returns err.
+func setErrorBody(err error, body []byte, max int) error {
+	var er *ErrorResponse
+	if errors.As(err, &er) && len(body) > 0 {
+		if len(body) > max {
+			body = body[:max]
+		}
+		er.Body = append([]byte(nil), body...)
+	}
+	return err
+}
+
+// encodedError returns the JSON response the server sends for the error msg,
+// such as a chunk that ends a chunked response.
+func encodedError(msg string) []byte {
+	b, _ := json.Marshal(&Response{Err: msg})
+	return b
+}
+
+// newUnexpectedResponse returns the error of a response that does not come
+// from the server, described by format and args: an ErrorResponse with the
+// first maxBody bytes of body if its status reports an error, as that of a
+// proxy, and a plain error otherwise.
+func newUnexpectedResponse(resp *http.Response, body []byte, maxBody int, format string, args ...interface{}) error {
+	message := fmt.Sprintf(format, args...)
+	if resp.StatusCode < http.StatusBadRequest {
+		return errors.New(message)
+	}
+	er := &ErrorResponse{StatusCode: resp.StatusCode, Message: message, RequestID: requestID(resp)}
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return setErrorBody(&AuthorizationError{ErrorResponse: *er}, body, maxBody)
+	}
+	return setErrorBody(er, body, maxBody)
+}
+
+// parseErrorBody extracts the error from the body of a response, which is
+// either a JSON object with an "error" field, a JSON object with "code" and
+// "message" fields as sent by the 2.x API, or plain text.
+func parseErrorBody(body []byte) (code, message string) {
+	var v struct {
+		Err     string `json:"error"`
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &v) == nil {
+		if v.Err != "" {
+			return "", v.Err
+		}
+		if v.Message != "" || v.Code != "" {
+			return v.Code, v.Message
+		}
+	}
+	return "", strings.TrimSpace(string(body))
+}
@@ -0,0 +1,465 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newErrorServer(code int, header, body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if header != "" {
+			w.Header().Set("X-Influxdb-Error", header)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Influxdb-Version", "1.8.10")
+		w.WriteHeader(code)
+		w.Write([]byte(body))
+	}))
+}
+
+func TestClient_WritePartialWriteError(t *testing.T) {
+	ts := newErrorServer(http.StatusBadRequest, "",
+		`{"error":"partial write: field type conflict: input field \"value\" on measurement \"cpu\" is type integer, already exists as type float dropped=2"}`)
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	bp.AddPoints(newTestPoints(1))
+	err := c.Write(bp)
+
+	var pe *PartialWriteError
+	if !errors.As(err, &pe) {
+		t.Fatalf("unexpected error.  expected %T, actual %v", pe, err)
+	}
+	if pe.Dropped != 2 || pe.StatusCode != http.StatusBadRequest {
+		t.Errorf("unexpected partial write error: %+v", pe)
+	}
+	if exp := `field type conflict: input field "value" on measurement "cpu" is type integer, already exists as type float`; pe.Reason != exp {
+		t.Errorf("unexpected reason.  expected %q, actual %q", exp, pe.Reason)
+	}
+	if !errors.Is(err, ErrFieldTypeConflict) {
+		t.Error("expected the error to match ErrFieldTypeConflict")
+	}
+	var er *ErrorResponse
+	if !errors.As(err, &er) || er.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected the error to unwrap to an *ErrorResponse, got %v", err)
+	}
+}
+
+func TestClient_WriteBeyondRetentionPolicy(t *testing.T) {
+	ts := newErrorServer(http.StatusBadRequest, "", `{"error":"partial write: points beyond retention policy dropped=1"}`)
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	bp.AddPoints(newTestPoints(1))
+	err := c.Write(bp)
+	if !errors.Is(err, ErrPointsBeyondRetentionPolicy) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if exp := "partial write: points beyond retention policy dropped=1"; err.Error() != exp {
+		t.Errorf("unexpected error message.  expected %q, actual %q", exp, err.Error())
+	}
+}
+
+func TestClient_WriteDatabaseNotFound(t *testing.T) {
+	ts := newErrorServer(http.StatusNotFound, `database not found: "db0"`, `{"error":"database not found: \"db0\""}`)
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0"})
+	bp.AddPoints(newTestPoints(1))
+	err := c.Write(bp)
+	if !errors.Is(err, ErrDatabaseNotFound) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	var er *ErrorResponse
+	if !errors.As(err, &er) || er.StatusCode != http.StatusNotFound || er.Message != `database not found: "db0"` {
+		t.Errorf("unexpected error response: %+v", er)
+	}
+}
+
+func TestClient_AuthorizationError(t *testing.T) {
+	ts := newErrorServer(http.StatusUnauthorized, "authorization failed", `{"error":"authorization failed"}`)
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, Username: "u", Password: "bad"})
+	defer c.Close()
+
+	var ae *AuthorizationError
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	bp.AddPoints(newTestPoints(1))
+	if err := c.Write(bp); !errors.As(err, &ae) {
+		t.Errorf("unexpected write error.  expected %T, actual %v", ae, err)
+	}
+	if _, _, err := c.Ping(0); !errors.As(err, &ae) {
+		t.Errorf("unexpected ping error.  expected %T, actual %v", ae, err)
+	}
+
+	resp, err := c.Query(Query{Command: "SHOW DATABASES"})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if err := resp.Error(); !errors.As(err, &ae) || err.Error() != "authorization failed" {
+		t.Errorf("unexpected response error.  expected %T, actual %v", ae, err)
+	}
+}
+
+func TestClient_ThrottledError(t *testing.T) {
+	retryAfter := "7"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A gateway, answering in plain text.
+		w.Header().Set("Retry-After", retryAfter)
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte("slow down"))
+	}))
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	bp.AddPoints(newTestPoints(1))
+	var te *ThrottledError
+	if err := c.Write(bp); !errors.As(err, &te) {
+		t.Fatalf("unexpected write error.  expected %T, actual %v", te, err)
+	}
+	if te.StatusCode != http.StatusTooManyRequests || te.Message != "slow down" || te.RetryAfter != 7*time.Second || te.Remaining != 0 {
+		t.Errorf("unexpected throttled error: %+v", te)
+	}
+	if d := time.Until(te.Reset); d <= 25*time.Second || d > 30*time.Second {
+		t.Errorf("unexpected reset in %v", d)
+	}
+
+	retryAfter = time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if _, err := c.Query(Query{Command: "SHOW DATABASES"}); !errors.As(err, &te) {
+		t.Fatalf("unexpected query error.  expected %T, actual %v", te, err)
+	}
+	if te.RetryAfter <= 58*time.Second || te.RetryAfter > time.Minute || te.Delay() != te.RetryAfter {
+		t.Errorf("unexpected delay for HTTP date: %v", te.RetryAfter)
+	}
+	var er *ErrorResponse
+	if !errors.As(te, &er) || breakerOutcome(te) != outcomeFailure {
+		t.Errorf("expected the error to unwrap to a failed *ErrorResponse, got %v", te)
+	}
+}
+
+func TestClient_QueryAsChunkErrorResponse(t *testing.T) {
+	ts := newErrorServer(http.StatusBadRequest, "", `{"error":"error parsing query: found EOF"}`)
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	cr, err := c.QueryAsChunk(Query{Command: "SELECT"})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	defer cr.Close()
+
+	resp, err := cr.NextResponse()
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	var er *ErrorResponse
+	if err := resp.Error(); !errors.As(err, &er) || er.StatusCode != http.StatusBadRequest {
+		t.Errorf("unexpected response error: %v", err)
+	}
+}
+
+func TestClient_QueryStatusError(t *testing.T) {
+	ts := newErrorServer(http.StatusServiceUnavailable, "", `{}`)
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	_, err := c.Query(Query{})
+	var er *ErrorResponse
+	if !errors.As(err, &er) || er.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if exp := "received status code 503 from server"; err.Error() != exp {
+		t.Errorf("unexpected error message.  expected %q, actual %q", exp, err.Error())
+	}
+}
+
+func TestQueryNotSupported(t *testing.T) {
+	udp, err := NewUDPClient(UDPConfig{Addr: "localhost:8089"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer udp.Close()
+
+	_, err = udp.Query(Query{})
+	if !errors.Is(err, ErrQueryNotSupported) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if exp := "Querying via UDP is not supported"; err.Error() != exp {
+		t.Errorf("unexpected error message.  expected %q, actual %q", exp, err.Error())
+	}
+	if _, err := (&tcpclient{}).QueryAsChunk(Query{}); !errors.Is(err, ErrQueryNotSupported) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestClient_WritePartialWriteDetails(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		body string
+		exp  PartialWriteError
+		is   error
+	}{
+		{
+			name: "field type conflict",
+			body: `{"error":"partial write: field type conflict: input field \"value\" on measurement \"cpu\" is type integer, already exists as type float dropped=1"}`,
+			exp:  PartialWriteError{Dropped: 1, Written: 2, Measurement: "cpu"},
+			is:   ErrFieldTypeConflict,
+		},
+		{
+			name: "beyond retention policy",
+			body: `{"error":"partial write: points beyond retention policy dropped=3"}`,
+			exp:  PartialWriteError{Dropped: 3, Written: 0},
+			is:   ErrPointsBeyondRetentionPolicy,
+		},
+		{
+			name: "max values per tag",
+			body: `{"error":"partial write: max-values-per-tag limit exceeded (100000/100000): measurement=\"cpu\" tag=\"host\" value=\"server42\" dropped=2"}`,
+			exp:  PartialWriteError{Dropped: 2, Written: 1, Measurement: "cpu"},
+			is:   ErrMaxValuesPerTagExceeded,
+		},
+		{
+			name: "unable to parse",
+			body: `{"error":"partial write: unable to parse 'cpu,host=a value=': missing field value dropped=1"}`,
+			exp:  PartialWriteError{Dropped: 1, Written: 2, Line: "cpu,host=a value="},
+		},
+		{
+			name: "dropped not reported",
+			body: `{"error":"partial write: points beyond retention policy"}`,
+			exp:  PartialWriteError{Written: -1},
+			is:   ErrPointsBeyondRetentionPolicy,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newErrorServer(http.StatusBadRequest, "", tt.body)
+			defer ts.Close()
+
+			c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+			defer c.Close()
+
+			err := c.Write(newTestBatch(t, 3))
+
+			var pe *PartialWriteError
+			if !errors.As(err, &pe) {
+				t.Fatalf("unexpected error.  expected %T, actual %v", pe, err)
+			}
+			if pe.Dropped != tt.exp.Dropped || pe.Written != tt.exp.Written || pe.Measurement != tt.exp.Measurement || pe.Line != tt.exp.Line {
+				t.Errorf("unexpected partial write error: %+v", pe)
+			}
+			if tt.is != nil && !errors.Is(err, tt.is) {
+				t.Errorf("expected the error to match %v", tt.is)
+			}
+		})
+	}
+}
+
+func TestClient_WritePartialWriteNotRetried(t *testing.T) {
+	var calls int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"error":"partial write: points beyond retention policy dropped=1"}`))
+	}))
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, MaxRetries: 3})
+	defer c.Close()
+
+	var pe *PartialWriteError
+	if err := c.Write(newTestBatch(t, 2)); !errors.As(err, &pe) {
+		t.Fatalf("unexpected error.  expected %T, actual %v", pe, err)
+	}
+	if calls != 1 {
+		t.Errorf("unexpected number of requests.  expected %v, actual %v", 1, calls)
+	}
+}
+
+func TestNewClients_ConfigError(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		new   func() (Client, error)
+		field string
+	}{
+		{"TCP empty address", func() (Client, error) { return NewTCPClient(TCPConfig{}) }, "Addr"},
+		{"TCP negative payload size", func() (Client, error) {
+			return NewTCPClient(TCPConfig{Addr: "localhost:8089", PayloadSize: -1})
+		}, "PayloadSize"},
+		{"TCP payload size below minimum", func() (Client, error) {
+			return NewTCPClient(TCPConfig{Addr: "localhost:8089", PayloadSize: MinPayloadSize - 1})
+		}, "PayloadSize"},
+		{"TCP negative pool size", func() (Client, error) {
+			return NewTCPClient(TCPConfig{Addr: "localhost:8089", PoolSize: -1})
+		}, "PoolSize"},
+		{"UDP empty address", func() (Client, error) { return NewUDPClient(UDPConfig{}) }, "Addr"},
+		{"UDP payload size below minimum", func() (Client, error) {
+			return NewUDPClient(UDPConfig{Addr: "localhost:8089", PayloadSize: 10})
+		}, "PayloadSize"},
+		{"UDP payload size above maximum", func() (Client, error) {
+			return NewUDPClient(UDPConfig{Addr: "localhost:8089", PayloadSize: MaxUDPPayloadSize + 1})
+		}, "PayloadSize"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := tt.new()
+			if c != nil {
+				c.Close()
+			}
+			var ce *ConfigError
+			if !errors.As(err, &ce) {
+				t.Fatalf("unexpected error.  expected %T, actual %v", ce, err)
+			}
+			if ce.Field != tt.field {
+				t.Errorf("unexpected field.  expected %v, actual %v", tt.field, ce.Field)
+			}
+		})
+	}
+
+	c, err := NewUDPClient(UDPConfig{Addr: "localhost:8089", PayloadSize: MaxUDPPayloadSize})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	c.Close()
+}
+
+func TestClient_ServerError(t *testing.T) {
+	const (
+		jsonBody  = `{"error":"boom"}`
+		plainBody = "<html>oops</html>"
+	)
+	for _, status := range []int{400, 401, 404, 413, 500} {
+		for _, withJSON := range []bool{true, false} {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Request-Id", "req-1")
+				body := plainBody
+				if withJSON {
+					w.Header().Set("Content-Type", "application/json")
+					w.Header().Set("X-Influxdb-Version", "1.8.10")
+					body = jsonBody
+				} else {
+					w.Header().Set("Content-Type", "text/html")
+				}
+				w.WriteHeader(status)
+				w.Write([]byte(body + "\n"))
+			}))
+			c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+
+			bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0"})
+			bp.AddPoints(newTestPoints(1))
+			_, _, pingErr := c.Ping(0)
+			resp, queryErr := c.Query(NewQuery("SELECT * FROM cpu", "db0", ""))
+			if queryErr == nil {
+				queryErr = resp.Error()
+			}
+			chunkErr := func() error {
+				cr, err := c.QueryAsChunk(NewQuery("SELECT * FROM cpu", "db0", ""))
+				if err != nil {
+					return err
+				}
+				defer cr.Close()
+				resp, err := cr.NextResponse()
+				if err != nil {
+					return err
+				}
+				return resp.Error()
+			}()
+			for _, tt := range []struct {
+				endpoint string
+				err      error
+			}{
+				{"Write", c.Write(bp)},
+				{"Ping", pingErr},
+				{"Query", queryErr},
+				{"QueryAsChunk", chunkErr},
+			} {
+				var se *ServerError
+				if !errors.As(tt.err, &se) {
+					t.Errorf("unexpected error of %s with status %d, json %v.  expected a *ServerError, actual %v", tt.endpoint, status, withJSON, tt.err)
+					continue
+				}
+				if se.StatusCode != status || se.RequestID != "req-1" {
+					t.Errorf("unexpected error of %s with status %d, json %v: %+v", tt.endpoint, status, withJSON, se)
+				}
+				if withJSON {
+					if tt.err.Error() != "boom" || string(bytes.TrimSpace(se.Body)) != jsonBody {
+						t.Errorf("unexpected error of %s with status %d.  expected %q with body %s, actual %q with body %s", tt.endpoint, status, "boom", jsonBody, tt.err, se.Body)
+					}
+				} else if !strings.Contains(tt.err.Error(), "oops") || string(bytes.TrimSpace(se.Body)) != plainBody {
+					t.Errorf("unexpected error of %s with status %d.  expected the body %s, actual %q with body %s", tt.endpoint, status, plainBody, tt.err, se.Body)
+				}
+				var ae *AuthorizationError
+				if errors.As(tt.err, &ae) != (status == http.StatusUnauthorized) {
+					t.Errorf("unexpected error of %s with status %d, json %v: %T", tt.endpoint, status, withJSON, tt.err)
+				}
+			}
+			c.Close()
+			ts.Close()
+		}
+	}
+}
+
+func TestClient_ServerErrorChunk(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Influxdb-Version", "1.8.10")
+		w.Header().Set("X-Request-Id", "req-2")
+		w.Write([]byte(`{"results":[{"statement_id":0,"series":[{"name":"cpu","columns":["time","value"],"values":[[0,1]]}],"partial":true}]}` + "\n"))
+		w.Write([]byte(`{"error":"max-select-point limit exceeded: (10/5)"}` + "\n"))
+	}))
+	defer ts.Close()
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, MaxErrorBodySize: 16})
+	defer c.Close()
+
+	// The error of a chunk sent after the 200 status.
+	cr, err := c.QueryAsChunk(NewQuery("SELECT * FROM cpu", "db0", ""))
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	defer cr.Close()
+	var chunkErr error
+	for chunkErr == nil {
+		resp, err := cr.NextResponse()
+		if err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+		chunkErr = resp.Error()
+	}
+	resp, queryErr := c.Query(Query{Command: "SELECT * FROM cpu", Database: "db0", Chunked: true})
+	if queryErr == nil {
+		queryErr = resp.Error()
+	}
+	for _, err := range []error{chunkErr, queryErr} {
+		var se *ServerError
+		if !errors.As(err, &se) || se.StatusCode != http.StatusOK || se.RequestID != "req-2" {
+			t.Fatalf("unexpected error.  expected a *ServerError with status %d, actual %v", http.StatusOK, err)
+		}
+		// The body is capped to MaxErrorBodySize.
+		if exp := `{"error":"max-se`; string(se.Body) != exp {
+			t.Errorf("unexpected body.  expected %s, actual %s", exp, se.Body)
+		}
+		if exp := "max-select-point limit exceeded: (10/5)"; err.Error() != exp {
+			t.Errorf("unexpected error.  expected %v, actual %v", exp, err)
+		}
+	}
+}
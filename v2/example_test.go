@@ -1,8 +1,13 @@
 package client_test
 
 import (
+	"context"
+	"encoding/json"
+	"expvar"
 	"fmt"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"time"
 
@@ -283,3 +288,116 @@ func ExampleClient_queryWithParams() {
 		fmt.Println(response.Results)
 	}
 }
+
+// otelTracer mirrors the part of go.opentelemetry.io/otel/trace.Tracer that
+// the adapter below uses, so that the example builds without depending on
+// OpenTelemetry. With it, use trace.Tracer instead.
+type otelTracer interface {
+	Start(ctx context.Context, name string) (context.Context, otelSpan)
+}
+
+// otelSpan mirrors the part of trace.Span the adapter uses. With
+// OpenTelemetry, SetAttributes takes attribute.KeyValue values, such as
+// attribute.String(k, v) and attribute.Int(k, n), and the status is set with
+// span.SetStatus(codes.Error, err.Error()).
+type otelSpan interface {
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+	End()
+}
+
+// tracerAdapter bridges a client.Tracer to an OpenTelemetry tracer.
+type tracerAdapter struct {
+	tracer otelTracer
+}
+
+func (a tracerAdapter) StartSpan(ctx context.Context, op string) (context.Context, func(error, map[string]interface{})) {
+	ctx, span := a.tracer.Start(ctx, op)
+	return ctx, func(err error, attrs map[string]interface{}) {
+		for k, v := range attrs {
+			span.SetAttribute(k, v)
+		}
+		if err != nil {
+			span.RecordError(err)
+		}
+		span.End()
+	}
+}
+
+// printTracer prints the spans it ends, in place of an OpenTelemetry tracer.
+type printTracer struct{}
+
+type printSpan struct {
+	name  string
+	attrs map[string]interface{}
+}
+
+func (printTracer) Start(ctx context.Context, name string) (context.Context, otelSpan) {
+	return ctx, &printSpan{name: name, attrs: make(map[string]interface{})}
+}
+
+func (s *printSpan) SetAttribute(key string, value interface{}) { s.attrs[key] = value }
+func (s *printSpan) RecordError(err error)                      { s.attrs["error"] = err.Error() }
+func (s *printSpan) End()                                       { fmt.Println(s.name, s.attrs) }
+
+// Trace the writes, queries and pings of a client. Wrapping the Transport with
+// otelhttp.NewTransport also propagates the spans to the server.
+func ExampleTracer() {
+	c, err := client.NewHTTPClient(client.HTTPConfig{
+		Addr:   "http://localhost:8086",
+		Tracer: tracerAdapter{tracer: printTracer{}},
+	})
+	if err != nil {
+		fmt.Println("Error creating InfluxDB Client: ", err.Error())
+	}
+	defer c.Close()
+
+	q := client.NewQuery("SELECT count(value) FROM shapes", "square_holes", "ns")
+	if response, err := c.(client.ContextClient).QueryContext(context.Background(), q); err == nil && response.Error() == nil {
+		fmt.Println(response.Results)
+	}
+}
+
+// Publish the metrics of a client with expvar, served as JSON on /debug/vars
+// by the default HTTP mux.
+func ExampleExpvarCollector() {
+	// A server accepting every write, in place of InfluxDB.
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	metrics := client.NewExpvarCollector("influxdb_example")
+	labels := client.MetricLabels{Address: ts.URL, Database: "square_holes"}
+	c, err := client.NewHTTPClient(client.HTTPConfig{Addr: ts.URL, Stats: metrics.Client(labels)})
+	if err != nil {
+		fmt.Println("Error creating InfluxDB Client: ", err.Error())
+	}
+	defer c.Close()
+
+	scrape := func() (written float64) {
+		rec := httptest.NewRecorder()
+		expvar.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/debug/vars", nil))
+		var vars struct {
+			Metrics struct {
+				PointsWritten map[string]float64 `json:"points_written_total"`
+			} `json:"influxdb_example"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &vars)
+		return vars.Metrics.PointsWritten[labels.String()]
+	}
+	fmt.Println("before:", scrape())
+
+	bp, _ := client.NewBatchPoints(client.BatchPointsConfig{Database: "square_holes"})
+	for i := 0; i < 2; i++ {
+		pt, _ := client.NewPoint("shapes", nil, map[string]interface{}{"value": i}, time.Unix(int64(i), 0))
+		bp.AddPoint(pt)
+	}
+	if err := c.Write(bp); err != nil {
+		fmt.Println("Error: ", err.Error())
+	}
+	fmt.Println("after:", scrape())
+	// Output:
+	// before: 0
+	// after: 2
+}
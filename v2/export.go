@@ -0,0 +1,66 @@
+package client
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+// writeLines writes points to w in the given precision, with their fields
+// sorted by sorter if not nil, see BatchPoints.WriteTo.
+func writeLines(w io.Writer, points []*Point, precision string, sorter *fieldSorter) (int64, error) {
+	var written int64
+	var line []byte
+	for _, p := range points {
+		if p == nil {
+			continue
+		}
+		line = models.AppendPrecisionString(line[:0], p.pt, precision)
+		if sorter != nil {
+			sorter.sortLine(line, len(p.pt.Key()), !p.pt.Time().IsZero())
+		}
+		line = append(line, '\n')
+		n, err := w.Write(line)
+		written += int64(n)
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+func (bp *batchpoints) WriteTo(w io.Writer) (int64, error) {
+	var sorter *fieldSorter
+	if bp.sortFields {
+		sorter = &fieldSorter{}
+	}
+	return writeLines(w, bp.Points(), bp.precision, sorter)
+}
+
+func (s *safeBatchPoints) WriteTo(w io.Writer) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bp.WriteTo(w)
+}
+
+// WriteImport writes bp to w in the format read by `influx -import`: a DML
+// section with the database and retention policy of bp as its context,
+// followed by the points, see BatchPoints.WriteTo. The times are in the
+// precision of bp, which the import must be given with -precision. It returns
+// the number of bytes written.
+func WriteImport(w io.Writer, bp BatchPoints) (int64, error) {
+	header := "# DML\n"
+	if db := bp.Database(); db != "" {
+		header += fmt.Sprintf("# CONTEXT-DATABASE: %s\n", db)
+	}
+	if rp := bp.RetentionPolicy(); rp != "" {
+		header += fmt.Sprintf("# CONTEXT-RETENTION-POLICY: %s\n", rp)
+	}
+	n, err := io.WriteString(w, header)
+	if err != nil {
+		return int64(n), err
+	}
+	m, err := bp.WriteTo(w)
+	return int64(n) + m, err
+}
@@ -0,0 +1,116 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+func newExportBatch(t *testing.T, precision string) BatchPoints {
+	bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0", RetentionPolicy: "rp0", Precision: precision})
+	t0 := time.Unix(1500000000, 123456789)
+	bp.AddPoints([]*Point{
+		mustPoint(t, "cpu", map[string]string{"host": "a b", "dc": "x,y"}, map[string]interface{}{"idle": 1.5, "busy": int64(3)}, t0),
+		nil,
+		mustPoint(t, "mem", nil, map[string]interface{}{"text": `say "hi"`, "ok": true}, t0.Add(time.Hour)),
+		mustPoint(t, "disk", nil, map[string]interface{}{"free": int64(42)}),
+	})
+	return bp
+}
+
+func TestBatchPoints_WriteTo(t *testing.T) {
+	var body []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	for _, precision := range []string{"ns", "ms", "s", "h"} {
+		bp := newExportBatch(t, precision)
+		var buf bytes.Buffer
+		n, err := bp.WriteTo(&buf)
+		if err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+		if n != int64(buf.Len()) {
+			t.Errorf("unexpected number of bytes.  expected %v, actual %v", buf.Len(), n)
+		}
+
+		// The body is the one the HTTP client sends.
+		if err := c.Write(bp); err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+		if buf.String() != string(body) {
+			t.Errorf("unexpected lines for %s.\nexpected %q\nactual   %q", precision, body, buf.String())
+		}
+
+		// And parses back into the points of the batch.
+		points, err := models.ParsePointsWithPrecision(buf.Bytes(), time.Now(), precision)
+		if err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+		var exp []*Point
+		for _, p := range bp.Points() {
+			if p != nil {
+				exp = append(exp, p)
+			}
+		}
+		if len(points) != len(exp) {
+			t.Fatalf("unexpected number of points.  expected %v, actual %v", len(exp), len(points))
+		}
+		for i, p := range points {
+			if exp[i].Time().IsZero() {
+				// The parser gives the point the time it was given.
+				p = models.PointWithTime(p, time.Time{})
+			}
+			if got, want := p.PrecisionString(precision), exp[i].PrecisionString(precision); got != want {
+				t.Errorf("unexpected point %d.  expected %v, actual %v", i, want, got)
+			}
+		}
+	}
+}
+
+type limitedWriter struct{ n int }
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if w.n < len(p) {
+		n := w.n
+		w.n = 0
+		return n, errors.New("disk full")
+	}
+	w.n -= len(p)
+	return len(p), nil
+}
+
+func TestWriteImport(t *testing.T) {
+	bp := newExportBatch(t, "s")
+	var buf bytes.Buffer
+	n, err := WriteImport(&buf, bp)
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("unexpected number of bytes.  expected %v, actual %v", buf.Len(), n)
+	}
+	var lines bytes.Buffer
+	bp.WriteTo(&lines)
+	if exp := "# DML\n# CONTEXT-DATABASE: db0\n# CONTEXT-RETENTION-POLICY: rp0\n" + lines.String(); buf.String() != exp {
+		t.Errorf("unexpected import file.\nexpected %q\nactual   %q", exp, buf.String())
+	}
+
+	// The bytes written before a failure are counted.
+	w := &limitedWriter{n: 70}
+	n, err = WriteImport(w, bp)
+	if err == nil || n != 70 {
+		t.Errorf("unexpected result.  expected %v bytes and an error, actual %v, %v", 70, n, err)
+	}
+}
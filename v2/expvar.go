@@ -0,0 +1,165 @@
+package client
+
+import (
+	"expvar"
+	"sync"
+	"time"
+)
+
+// MetricLabels tell apart the clients reporting to the same ExpvarCollector,
+// or promcollector.Collector.
+type MetricLabels struct {
+	// Address is the address of the server, such as http://localhost:8086.
+	Address string
+
+	// Database is the database the client writes to.
+	Database string
+}
+
+// String returns the labels as the key of their metrics in an
+// ExpvarCollector, as in address=http://localhost:8086,database=db0.
+func (l MetricLabels) String() string {
+	return "address=" + l.Address + ",database=" + l.Database
+}
+
+var (
+	expvarMu         sync.Mutex
+	expvarCollectors = make(map[string]*ExpvarCollector)
+)
+
+// ExpvarCollector publishes the metrics of clients with the expvar package,
+// which serves them as JSON on /debug/vars. They are an expvar.Map named
+// after its prefix, holding a map per metric of the values by MetricLabels:
+//
+//	"influxdb": {
+//		"points_written_total": {"address=http://localhost:8086,database=db0": 1200},
+//		"query_duration_seconds": {"address=http://localhost:8086,database=db0": {"count": 3, "sum": 0.042}},
+//		...
+//	}
+//
+// The metrics are the counters points_written_total, bytes_written_total,
+// write_errors_total and retries_total, the summary query_duration_seconds,
+// and the gauges queue_depth, for the BatchingClients wrapping the clients,
+// and circuit_state, the CircuitState of their breaker. Points and bytes
+// are counted once written.
+//
+// ExpvarCollector is a StatsCollector reporting with empty labels, and Client
+// returns the one of a client. It is safe for concurrent use.
+type ExpvarCollector struct {
+	vars *expvar.Map
+
+	mu      sync.Mutex
+	clients map[MetricLabels]*expvarClient
+}
+
+// NewExpvarCollector returns the ExpvarCollector publishing its metrics
+// under prefix. As the names of expvar are global, the collectors of the
+// same prefix are the same one.
+func NewExpvarCollector(prefix string) *ExpvarCollector {
+	expvarMu.Lock()
+	defer expvarMu.Unlock()
+	if ec, ok := expvarCollectors[prefix]; ok {
+		return ec
+	}
+	ec := &ExpvarCollector{vars: expvar.NewMap(prefix), clients: make(map[MetricLabels]*expvarClient)}
+	expvarCollectors[prefix] = ec
+	return ec
+}
+
+// Client returns the StatsCollector of a client with labels, to be set as
+// the Stats of its config. It counts along with the other clients of the
+// same labels.
+func (ec *ExpvarCollector) Client(labels MetricLabels) StatsCollector {
+	return ec.client(labels)
+}
+
+func (ec *ExpvarCollector) client(labels MetricLabels) *expvarClient {
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+	if c, ok := ec.clients[labels]; ok {
+		return c
+	}
+	key := labels.String()
+	query := new(expvar.Map).Init()
+	c := &expvarClient{
+		pointsWritten: ec.metric("points_written_total", key, new(expvar.Int)),
+		bytesWritten:  ec.metric("bytes_written_total", key, new(expvar.Int)),
+		writeErrors:   ec.metric("write_errors_total", key, new(expvar.Int)),
+		retries:       ec.metric("retries_total", key, new(expvar.Int)),
+		queueDepth:    ec.metric("queue_depth", key, new(expvar.Int)),
+		circuitState:  ec.metric("circuit_state", key, new(expvar.Int)),
+		queryCount:    new(expvar.Int),
+		querySum:      new(expvar.Float),
+	}
+	query.Set("count", c.queryCount)
+	query.Set("sum", c.querySum)
+	ec.metricMap("query_duration_seconds").Set(key, query)
+	ec.clients[labels] = c
+	return c
+}
+
+// metric publishes v as the value of the metric name for the labels key.
+func (ec *ExpvarCollector) metric(name, key string, v *expvar.Int) *expvar.Int {
+	ec.metricMap(name).Set(key, v)
+	return v
+}
+
+func (ec *ExpvarCollector) metricMap(name string) *expvar.Map {
+	if m, ok := ec.vars.Get(name).(*expvar.Map); ok {
+		return m
+	}
+	m := new(expvar.Map).Init()
+	ec.vars.Set(name, m)
+	return m
+}
+
+func (ec *ExpvarCollector) WriteDone(points, bytes int, dur time.Duration, err error) {
+	ec.client(MetricLabels{}).WriteDone(points, bytes, dur, err)
+}
+
+func (ec *ExpvarCollector) QueryDone(q string, dur time.Duration, err error) {
+	ec.client(MetricLabels{}).QueryDone(q, dur, err)
+}
+
+func (ec *ExpvarCollector) WriteRetried(points int, err error) {
+	ec.client(MetricLabels{}).WriteRetried(points, err)
+}
+
+func (ec *ExpvarCollector) QueueDepthChanged(delta int) {
+	ec.client(MetricLabels{}).QueueDepthChanged(delta)
+}
+
+func (ec *ExpvarCollector) CircuitStateChanged(from, to CircuitState) {
+	ec.client(MetricLabels{}).CircuitStateChanged(from, to)
+}
+
+// expvarClient is the StatsCollector of the clients of some labels in an
+// ExpvarCollector.
+type expvarClient struct {
+	pointsWritten, bytesWritten, writeErrors, retries *expvar.Int
+	queueDepth, circuitState                          *expvar.Int
+	queryCount                                        *expvar.Int
+	querySum                                          *expvar.Float
+}
+
+func (c *expvarClient) WriteDone(points, bytes int, dur time.Duration, err error) {
+	if err != nil {
+		c.writeErrors.Add(1)
+		return
+	}
+	c.pointsWritten.Add(int64(points))
+	c.bytesWritten.Add(int64(bytes))
+}
+
+func (c *expvarClient) QueryDone(q string, dur time.Duration, err error) {
+	c.queryCount.Add(1)
+	c.querySum.Add(dur.Seconds())
+}
+
+func (c *expvarClient) WriteRetried(points int, err error) { c.retries.Add(1) }
+
+func (c *expvarClient) QueueDepthChanged(delta int) { c.queueDepth.Add(int64(delta)) }
+
+func (c *expvarClient) CircuitStateChanged(from, to CircuitState) {
+	c.circuitState.Set(int64(to))
+}
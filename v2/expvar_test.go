@@ -0,0 +1,176 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// expvarMetric returns the value of the metric of labels published under
+// prefix, nil if there is none.
+func expvarMetric(t *testing.T, prefix, metric string, labels MetricLabels) interface{} {
+	t.Helper()
+	var vars map[string]map[string]interface{}
+	if err := json.Unmarshal([]byte(expvar.Get(prefix).String()), &vars); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	return vars[metric][labels.String()]
+}
+
+func TestExpvarCollector_Client(t *testing.T) {
+	var writes int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/write":
+			if atomic.AddInt32(&writes, 1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case "/query":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"results":[{"statement_id":0}]}`))
+		}
+	}))
+	defer ts.Close()
+
+	ec := NewExpvarCollector("influxdb_test_client")
+	labels := MetricLabels{Address: ts.URL, Database: "db0"}
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, MaxRetries: 1, RetryInterval: time.Millisecond, Stats: ec.Client(labels)})
+	defer c.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0"})
+	bp.AddPoints(newTestPoints(2))
+	if err := c.Write(bp); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if _, err := c.Query(NewQuery("SHOW DATABASES", "", "")); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+
+	for _, tt := range []struct {
+		metric string
+		exp    interface{}
+	}{
+		{"points_written_total", 2.0},
+		{"bytes_written_total", float64(bp.Size())},
+		{"write_errors_total", 1.0},
+		{"retries_total", 1.0},
+		{"circuit_state", 0.0},
+	} {
+		if v := expvarMetric(t, "influxdb_test_client", tt.metric, labels); v != tt.exp {
+			t.Errorf("unexpected %s.  expected %v, actual %v", tt.metric, tt.exp, v)
+		}
+	}
+	q, _ := expvarMetric(t, "influxdb_test_client", "query_duration_seconds", labels).(map[string]interface{})
+	if q["count"] != 1.0 || q["sum"].(float64) <= 0 {
+		t.Errorf("unexpected query_duration_seconds: %v", q)
+	}
+	if NewExpvarCollector("influxdb_test_client") != ec {
+		t.Error("expected the collector of the same prefix")
+	}
+}
+
+func TestExpvarCollector_Concurrent(t *testing.T) {
+	ec := NewExpvarCollector("influxdb_test_concurrent")
+	labels := []MetricLabels{{Address: "http://a:8086", Database: "db0"}, {Address: "http://b:8086", Database: "db0"}}
+	// The collector is that of the process, so the counts are taken as
+	// what the test adds to them, for -count.
+	count := func(metric string, l MetricLabels) float64 {
+		v, _ := expvarMetric(t, "influxdb_test_concurrent", metric, l).(float64)
+		return v
+	}
+	before := map[string]float64{}
+	for _, l := range append(labels, MetricLabels{}) {
+		before["points_written_total"+l.String()] = count("points_written_total", l)
+		before["write_errors_total"+l.String()] = count("write_errors_total", l)
+	}
+	added := func(metric string, l MetricLabels) float64 {
+		return count(metric, l) - before[metric+l.String()]
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// The clients of the same labels count together.
+			stats := ec.Client(labels[i%2])
+			for j := 0; j < 100; j++ {
+				stats.WriteDone(3, 10, time.Millisecond, nil)
+				stats.WriteDone(3, 10, time.Millisecond, errors.New("timeout"))
+				stats.QueryDone("SELECT 1", time.Millisecond, nil)
+				ec.WriteDone(1, 1, time.Millisecond, nil)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for _, l := range labels {
+		if v := added("points_written_total", l); v != 1200 {
+			t.Errorf("%v: unexpected points_written_total.  expected %v, actual %v", l, 1200, v)
+		}
+		if v := added("write_errors_total", l); v != 400 {
+			t.Errorf("%v: unexpected write_errors_total.  expected %v, actual %v", l, 400, v)
+		}
+	}
+	if v := added("points_written_total", MetricLabels{}); v != 800 {
+		t.Errorf("unexpected points_written_total without labels.  expected %v, actual %v", 800, v)
+	}
+}
+
+// statsRecorder is a batchRecorder reporting to stats, for the clients
+// wrapping it.
+type statsRecorder struct {
+	batchRecorder
+	stats StatsCollector
+}
+
+func (r *statsRecorder) statsCollector() StatsCollector { return r.stats }
+
+func TestExpvarCollector_QueueDepth(t *testing.T) {
+	ec := NewExpvarCollector("influxdb_test_queue")
+	r := &statsRecorder{batchRecorder: batchRecorder{gate: make(chan struct{})}, stats: ec}
+	bc, _ := NewBatchingClient(r, BatchingOptions{BatchSize: 1, BufferSize: 2, FlushInterval: time.Hour, Overflow: OverflowDropNewest})
+
+	depth := func() interface{} { return expvarMetric(t, "influxdb_test_queue", "queue_depth", MetricLabels{}) }
+	points := newTestPoints(4)
+	bc.AddPoint(points[0])
+	// Wait for the writer to block on the first point.
+	for depth() != 0.0 {
+		time.Sleep(time.Millisecond)
+	}
+	bc.AddPoints(points[1:])
+	if v := depth(); v != 2.0 {
+		t.Errorf("unexpected queue_depth of a full buffer.  expected %v, actual %v", 2, v)
+	}
+	close(r.gate)
+	bc.Close()
+	if v := depth(); v != 0.0 {
+		t.Errorf("unexpected queue_depth once closed.  expected %v, actual %v", 0, v)
+	}
+}
+
+func TestExpvarCollector_CircuitState(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	ec := NewExpvarCollector("influxdb_test_circuit")
+	labels := MetricLabels{Address: ts.URL}
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, CircuitBreaker: &CircuitBreaker{FailureThreshold: 1}, Stats: ec.Client(labels)})
+	defer c.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0"})
+	bp.AddPoints(newTestPoints(1))
+	c.Write(bp)
+	if v := expvarMetric(t, "influxdb_test_circuit", "circuit_state", labels); v != float64(CircuitOpen) {
+		t.Errorf("unexpected circuit_state.  expected %v, actual %v", int(CircuitOpen), v)
+	}
+}
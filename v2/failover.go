@@ -0,0 +1,408 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultHealthCheckInterval is the default interval at which a
+// FailoverClient pings the nodes that failed.
+const DefaultHealthCheckInterval = 10 * time.Second
+
+// FailoverStrategy decides which node of a FailoverClient serves a request.
+type FailoverStrategy int
+
+const (
+	// FailoverOrdered sends every request to the first healthy node, in the
+	// order of the configs.
+	FailoverOrdered FailoverStrategy = iota
+
+	// FailoverRoundRobin spreads requests across the healthy nodes.
+	FailoverRoundRobin
+)
+
+// FailoverOptions is the config data needed to create a FailoverClient.
+type FailoverOptions struct {
+	// Strategy picks the node for each request, defaults to FailoverOrdered.
+	Strategy FailoverStrategy
+
+	// HealthCheckInterval is how often failed nodes are pinged to see if
+	// they recovered, defaults to DefaultHealthCheckInterval.
+	HealthCheckInterval time.Duration
+
+	// RetryWriteOnTimeout lets a write move to the next node after a
+	// network error that happened once the request was sent, such as a
+	// timeout reading the response. The first node may have stored the
+	// points already, so this can duplicate them. By default writes only
+	// fail over when a node could not be connected to or answered with a
+	// 5xx status.
+	RetryWriteOnTimeout bool
+
+	// HedgeDelay enables hedged queries: a read-only query still running
+	// on the first node after HedgeDelay is sent to the next node as well,
+	// and the first answer is returned while the other query is canceled.
+	// A delay around the 95th percentile of the query latency keeps the
+	// extra load low. Queries that may modify data, such as SELECT INTO,
+	// and chunked queries are never hedged. Zero, the default, disables
+	// hedging.
+	HedgeDelay time.Duration
+}
+
+// HedgeStats counts the hedged queries of a FailoverClient.
+type HedgeStats struct {
+	// Fired is the number of queries sent to a second node because the
+	// first one was slower than HedgeDelay.
+	Fired uint64
+
+	// Won is the number of those queries the second node answered first.
+	Won uint64
+}
+
+// FailoverClient is a Client that sends each request to one of several
+// InfluxDB nodes and moves to the next one when a node is unreachable or
+// answers with a 5xx status. FailoverClient is safe for concurrent use by
+// multiple goroutines.
+type FailoverClient struct {
+	nodes        []*failoverNode
+	strategy     FailoverStrategy
+	retryTimeout bool
+	hedgeDelay   time.Duration
+
+	next uint32
+
+	hedgesFired uint64
+	hedgesWon   uint64
+
+	mu       sync.Mutex
+	lastAddr string
+
+	closing   chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+type failoverNode struct {
+	addr    string
+	client  ContextClient
+	healthy int32
+}
+
+func (n *failoverNode) isHealthy() bool { return atomic.LoadInt32(&n.healthy) == 1 }
+
+func (n *failoverNode) setHealthy(healthy bool) {
+	var v int32
+	if healthy {
+		v = 1
+	}
+	atomic.StoreInt32(&n.healthy, v)
+}
+
+// NewFailoverClient returns a FailoverClient over one HTTP client per config.
+func NewFailoverClient(configs []HTTPConfig, opts FailoverOptions) (*FailoverClient, error) {
+	if len(configs) == 0 {
+		return nil, errors.New("at least one config is required")
+	}
+	switch opts.Strategy {
+	case FailoverOrdered, FailoverRoundRobin:
+	default:
+		return nil, errors.New("unknown failover strategy")
+	}
+	if opts.HealthCheckInterval <= 0 {
+		opts.HealthCheckInterval = DefaultHealthCheckInterval
+	}
+
+	fc := &FailoverClient{
+		strategy:     opts.Strategy,
+		retryTimeout: opts.RetryWriteOnTimeout,
+		hedgeDelay:   opts.HedgeDelay,
+		closing:      make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+	for _, conf := range configs {
+		c, err := NewHTTPClient(conf)
+		if err != nil {
+			for _, n := range fc.nodes {
+				n.client.Close()
+			}
+			return nil, fmt.Errorf("%s: %v", conf.Addr, err)
+		}
+		fc.nodes = append(fc.nodes, &failoverNode{addr: conf.Addr, client: c.(ContextClient), healthy: 1})
+	}
+
+	go fc.healthCheck(opts.HealthCheckInterval)
+	return fc, nil
+}
+
+// LastAddr returns the address of the node that served the last request, or
+// that failed last if every node failed.
+func (fc *FailoverClient) LastAddr() string {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	return fc.lastAddr
+}
+
+// Ping pings the node that would serve the next request.
+func (fc *FailoverClient) Ping(timeout time.Duration) (time.Duration, string, error) {
+	var rtt time.Duration
+	var version string
+	err := fc.do(context.Background(), false, func(c ContextClient) error {
+		var err error
+		rtt, version, err = c.Ping(timeout)
+		return err
+	})
+	return rtt, version, err
+}
+
+// Write writes bp to the first node that accepts it.
+func (fc *FailoverClient) Write(bp BatchPoints) error {
+	return fc.WriteContext(context.Background(), bp)
+}
+
+// WriteContext is like Write, bound to ctx.
+func (fc *FailoverClient) WriteContext(ctx context.Context, bp BatchPoints) error {
+	return fc.do(ctx, true, func(c ContextClient) error {
+		return c.WriteContext(ctx, bp)
+	})
+}
+
+// Query runs q on the first node that answers.
+func (fc *FailoverClient) Query(q Query) (*Response, error) {
+	return fc.QueryContext(context.Background(), q)
+}
+
+// QueryContext is like Query, bound to ctx.
+func (fc *FailoverClient) QueryContext(ctx context.Context, q Query) (*Response, error) {
+	if fc.hedgeDelay > 0 && len(fc.nodes) > 1 && readOnly(q.Command) {
+		return fc.hedgedQuery(ctx, q)
+	}
+	var resp *Response
+	err := fc.do(ctx, false, func(c ContextClient) error {
+		var err error
+		resp, err = c.QueryContext(ctx, q)
+		return err
+	})
+	return resp, err
+}
+
+// QueryAsChunk runs q on the first node that answers. Once the response
+// started streaming, errors are not retried on another node.
+func (fc *FailoverClient) QueryAsChunk(q Query) (*ChunkedResponse, error) {
+	return fc.QueryAsChunkContext(context.Background(), q)
+}
+
+// QueryAsChunkContext is like QueryAsChunk, bound to ctx.
+func (fc *FailoverClient) QueryAsChunkContext(ctx context.Context, q Query) (*ChunkedResponse, error) {
+	var resp *ChunkedResponse
+	err := fc.do(ctx, false, func(c ContextClient) error {
+		var err error
+		resp, err = c.QueryAsChunkContext(ctx, q)
+		return err
+	})
+	return resp, err
+}
+
+// HedgeStats returns the counts of hedged queries so far.
+func (fc *FailoverClient) HedgeStats() HedgeStats {
+	return HedgeStats{
+		Fired: atomic.LoadUint64(&fc.hedgesFired),
+		Won:   atomic.LoadUint64(&fc.hedgesWon),
+	}
+}
+
+// Close stops the health checks and closes the client of every node.
+func (fc *FailoverClient) Close() error {
+	fc.closeOnce.Do(func() { close(fc.closing) })
+	<-fc.done
+
+	var err error
+	for _, n := range fc.nodes {
+		if cerr := n.client.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// do calls fn with the client of each node in turn until one succeeds or
+// fails with an error that must not be retried elsewhere.
+func (fc *FailoverClient) do(ctx context.Context, write bool, fn func(c ContextClient) error) error {
+	var err error
+	for _, n := range fc.order() {
+		err = fn(n.client)
+
+		fc.mu.Lock()
+		fc.lastAddr = n.addr
+		fc.mu.Unlock()
+
+		if err == nil {
+			n.setHealthy(true)
+			return nil
+		}
+		if ctx.Err() != nil || !fc.shouldFailover(err, write) {
+			return err
+		}
+		n.setHealthy(false)
+		err = fmt.Errorf("%s: %w", n.addr, err)
+	}
+	return err
+}
+
+// hedgedQuery runs q on the first node in order, and on the second one as
+// well if the first did not answer within the hedge delay or failed with an
+// error that may be retried elsewhere. The first answer wins and the other
+// query is canceled.
+func (fc *FailoverClient) hedgedQuery(ctx context.Context, q Query) (*Response, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		node  *failoverNode
+		resp  *Response
+		err   error
+		hedge bool
+	}
+	results := make(chan result, 2)
+	run := func(n *failoverNode, hedge bool) {
+		go func() {
+			resp, err := n.client.QueryContext(ctx, q)
+			results <- result{node: n, resp: resp, err: err, hedge: hedge}
+		}()
+	}
+
+	nodes := fc.order()
+	run(nodes[0], false)
+	timer := time.NewTimer(fc.hedgeDelay)
+	defer timer.Stop()
+
+	var (
+		second  bool
+		pending = 1
+		err     error
+	)
+	for {
+		select {
+		case <-timer.C:
+			if !second {
+				second = true
+				pending++
+				atomic.AddUint64(&fc.hedgesFired, 1)
+				run(nodes[1], true)
+			}
+			continue
+		case r := <-results:
+			pending--
+
+			fc.mu.Lock()
+			fc.lastAddr = r.node.addr
+			fc.mu.Unlock()
+
+			if r.err == nil || ctx.Err() != nil || !fc.shouldFailover(r.err, false) {
+				if r.err == nil {
+					r.node.setHealthy(true)
+				}
+				if r.hedge {
+					atomic.AddUint64(&fc.hedgesWon, 1)
+				}
+				return r.resp, r.err
+			}
+			r.node.setHealthy(false)
+			err = fmt.Errorf("%s: %w", r.node.addr, r.err)
+		}
+
+		if !second {
+			// The first node failed before the hedge delay, fail over
+			// right away.
+			second = true
+			pending++
+			run(nodes[1], false)
+		}
+		if pending == 0 {
+			return nil, err
+		}
+	}
+}
+
+// order returns the nodes in the order they should be tried: healthy nodes
+// first, according to the strategy, then the others as a last resort.
+func (fc *FailoverClient) order() []*failoverNode {
+	nodes := fc.nodes
+	if fc.strategy == FailoverRoundRobin {
+		start := int(atomic.AddUint32(&fc.next, 1)-1) % len(nodes)
+		nodes = append(append([]*failoverNode(nil), nodes[start:]...), nodes[:start]...)
+	}
+
+	ordered := make([]*failoverNode, 0, len(nodes))
+	for _, n := range nodes {
+		if n.isHealthy() {
+			ordered = append(ordered, n)
+		}
+	}
+	for _, n := range nodes {
+		if !n.isHealthy() {
+			ordered = append(ordered, n)
+		}
+	}
+	return ordered
+}
+
+// shouldFailover reports whether a request that failed with err may be sent
+// to another node.
+func (fc *FailoverClient) shouldFailover(err error, write bool) bool {
+	return retryElsewhere(err, write, fc.retryTimeout)
+}
+
+// retryElsewhere reports whether a request that failed with err may be sent
+// to another server: it could not connect or the server failed, with a 5xx
+// status. A write that timed out once sent may be, with retryTimeout set,
+// at the risk of duplicating its points.
+func retryElsewhere(err error, write, retryTimeout bool) bool {
+	var qte *QueryTimeoutError
+	if errors.As(err, &qte) {
+		return false
+	}
+
+	var er *ErrorResponse
+	if errors.As(err, &er) {
+		return er.StatusCode >= 500
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		// Nothing was sent, there is no risk of duplicating a write.
+		return true
+	}
+
+	var netErr net.Error
+	if !errors.As(err, &netErr) {
+		return false
+	}
+	return !write || retryTimeout
+}
+
+// healthCheck pings the unhealthy nodes every interval until Close.
+func (fc *FailoverClient) healthCheck(interval time.Duration) {
+	defer close(fc.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-fc.closing:
+			return
+		case <-ticker.C:
+		}
+		for _, n := range fc.nodes {
+			if n.isHealthy() {
+				continue
+			}
+			if _, _, err := n.client.Ping(0); err == nil {
+				n.setHealthy(true)
+			}
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newFailoverNode returns a server answering every request with the status
+// code held by code, and the number of requests it served.
+func newFailoverNode(code *int32) (*httptest.Server, *int32) {
+	var hits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		c := int(atomic.LoadInt32(code))
+		if r.URL.Path == "/query" && c == http.StatusNoContent {
+			c = http.StatusOK
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(c)
+		if c == http.StatusOK {
+			w.Write([]byte(`{}`))
+		}
+	}))
+	return ts, &hits
+}
+
+func TestFailoverClient_UnreachableNode(t *testing.T) {
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	code := int32(http.StatusNoContent)
+	up, hits := newFailoverNode(&code)
+	defer up.Close()
+
+	fc, err := NewFailoverClient([]HTTPConfig{{Addr: down.URL}, {Addr: up.URL}}, FailoverOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer fc.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	bp.AddPoints(newTestPoints(1))
+	if err := fc.Write(bp); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := fc.LastAddr(); got != up.URL {
+		t.Errorf("unexpected last address.  expected %v, actual %v", up.URL, got)
+	}
+	if _, err := fc.Query(Query{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := atomic.LoadInt32(hits); got != 2 {
+		t.Errorf("unexpected number of requests.  expected %d, actual %d", 2, got)
+	}
+}
+
+func TestFailoverClient_ServerError(t *testing.T) {
+	bad := int32(http.StatusServiceUnavailable)
+	primary, primaryHits := newFailoverNode(&bad)
+	defer primary.Close()
+	ok := int32(http.StatusNoContent)
+	secondary, secondaryHits := newFailoverNode(&ok)
+	defer secondary.Close()
+
+	fc, _ := NewFailoverClient([]HTTPConfig{{Addr: primary.URL}, {Addr: secondary.URL}}, FailoverOptions{HealthCheckInterval: 10 * time.Millisecond})
+	defer fc.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	bp.AddPoints(newTestPoints(1))
+	if err := fc.Write(bp); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// The primary is skipped while unhealthy.
+	if err := fc.Write(bp); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := atomic.LoadInt32(secondaryHits); got != 2 {
+		t.Errorf("unexpected number of secondary requests.  expected %d, actual %d", 2, got)
+	}
+
+	// Once the primary recovers the health check promotes it back.
+	atomic.StoreInt32(&bad, http.StatusNoContent)
+	deadline := time.Now().Add(time.Second)
+	for {
+		if err := fc.Write(bp); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if fc.LastAddr() == primary.URL {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("primary was not promoted back")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if atomic.LoadInt32(primaryHits) < 2 {
+		t.Error("expected the primary to be pinged and written to")
+	}
+}
+
+func TestFailoverClient_ClientError(t *testing.T) {
+	bad := int32(http.StatusBadRequest)
+	primary, _ := newFailoverNode(&bad)
+	defer primary.Close()
+	ok := int32(http.StatusNoContent)
+	secondary, secondaryHits := newFailoverNode(&ok)
+	defer secondary.Close()
+
+	fc, _ := NewFailoverClient([]HTTPConfig{{Addr: primary.URL}, {Addr: secondary.URL}}, FailoverOptions{})
+	defer fc.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	bp.AddPoints(newTestPoints(1))
+	var er *ErrorResponse
+	if err := fc.Write(bp); !errors.As(err, &er) || er.StatusCode != http.StatusBadRequest {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := atomic.LoadInt32(secondaryHits); got != 0 {
+		t.Errorf("expected no failover on a client error, got %d requests", got)
+	}
+}
+
+func TestFailoverClient_WriteTimeout(t *testing.T) {
+	var slowHits int32
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&slowHits, 1)
+		time.Sleep(100 * time.Millisecond)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer slow.Close()
+	ok := int32(http.StatusNoContent)
+	fast, fastHits := newFailoverNode(&ok)
+	defer fast.Close()
+
+	configs := []HTTPConfig{{Addr: slow.URL, Timeout: 20 * time.Millisecond}, {Addr: fast.URL}}
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	bp.AddPoints(newTestPoints(1))
+
+	fc, _ := NewFailoverClient(configs, FailoverOptions{})
+	if err := fc.Write(bp); err == nil {
+		t.Error("expected the write timeout to be returned")
+	}
+	if got := atomic.LoadInt32(fastHits); got != 0 {
+		t.Errorf("expected the write not to be sent again, got %d requests", got)
+	}
+	fc.Close()
+
+	fc, _ = NewFailoverClient(configs, FailoverOptions{RetryWriteOnTimeout: true})
+	defer fc.Close()
+	if err := fc.Write(bp); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if got := atomic.LoadInt32(fastHits); got != 1 {
+		t.Errorf("expected the write to be sent again, got %d requests", got)
+	}
+}
+
+func TestFailoverClient_RoundRobin(t *testing.T) {
+	ok := int32(http.StatusNoContent)
+	a, aHits := newFailoverNode(&ok)
+	defer a.Close()
+	b, bHits := newFailoverNode(&ok)
+	defer b.Close()
+
+	fc, _ := NewFailoverClient([]HTTPConfig{{Addr: a.URL}, {Addr: b.URL}}, FailoverOptions{Strategy: FailoverRoundRobin})
+	defer fc.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	bp.AddPoints(newTestPoints(1))
+	for i := 0; i < 4; i++ {
+		if err := fc.Write(bp); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if atomic.LoadInt32(aHits) != 2 || atomic.LoadInt32(bHits) != 2 {
+		t.Errorf("unexpected distribution: %d/%d", atomic.LoadInt32(aHits), atomic.LoadInt32(bHits))
+	}
+}
+
+func TestFailoverClient_AllDown(t *testing.T) {
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	fc, _ := NewFailoverClient([]HTTPConfig{{Addr: down.URL}, {Addr: down.URL}}, FailoverOptions{})
+	defer fc.Close()
+
+	if _, _, err := fc.Ping(0); err == nil {
+		t.Error("expected an error when every node is down")
+	}
+	if _, err := NewFailoverClient(nil, FailoverOptions{}); err == nil {
+		t.Error("expected an error without configs")
+	}
+}
+
+// newHedgeNode returns a server answering queries after delay, and a channel
+// receiving the commands whose request was canceled by the client.
+func newHedgeNode(delay time.Duration) (*httptest.Server, chan string) {
+	canceled := make(chan string, 10)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			canceled <- r.FormValue("q")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{}`))
+	}))
+	return ts, canceled
+}
+
+func TestFailoverClient_HedgedQuery(t *testing.T) {
+	slow, canceled := newHedgeNode(time.Minute)
+	defer slow.Close()
+	fast, _ := newHedgeNode(0)
+	defer fast.Close()
+
+	fc, _ := NewFailoverClient([]HTTPConfig{{Addr: slow.URL}, {Addr: fast.URL}}, FailoverOptions{HedgeDelay: 10 * time.Millisecond})
+	defer fc.Close()
+
+	if _, err := fc.Query(Query{Command: "SELECT * FROM cpu"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := fc.LastAddr(); got != fast.URL {
+		t.Errorf("unexpected last address.  expected %v, actual %v", fast.URL, got)
+	}
+	select {
+	case q := <-canceled:
+		if q != "SELECT * FROM cpu" {
+			t.Errorf("unexpected canceled query: %q", q)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("expected the slow query to be canceled")
+	}
+	if stats := fc.HedgeStats(); stats != (HedgeStats{Fired: 1, Won: 1}) {
+		t.Errorf("unexpected hedge stats.  expected %+v, actual %+v", HedgeStats{Fired: 1, Won: 1}, stats)
+	}
+}
+
+func TestFailoverClient_HedgedQueryFastPrimary(t *testing.T) {
+	primary, _ := newHedgeNode(0)
+	defer primary.Close()
+	code := int32(http.StatusNoContent)
+	secondary, hits := newFailoverNode(&code)
+	defer secondary.Close()
+
+	fc, _ := NewFailoverClient([]HTTPConfig{{Addr: primary.URL}, {Addr: secondary.URL}}, FailoverOptions{HedgeDelay: time.Minute})
+	defer fc.Close()
+
+	if _, err := fc.Query(Query{Command: "SHOW DATABASES"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := atomic.LoadInt32(hits); got != 0 {
+		t.Errorf("unexpected number of hedged requests.  expected %d, actual %d", 0, got)
+	}
+	if stats := fc.HedgeStats(); stats != (HedgeStats{}) {
+		t.Errorf("unexpected hedge stats.  expected %+v, actual %+v", HedgeStats{}, stats)
+	}
+}
+
+func TestFailoverClient_HedgedQueryFailover(t *testing.T) {
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	up, _ := newHedgeNode(0)
+	defer up.Close()
+
+	fc, _ := NewFailoverClient([]HTTPConfig{{Addr: down.URL}, {Addr: up.URL}}, FailoverOptions{HedgeDelay: time.Minute})
+	defer fc.Close()
+
+	// An unreachable primary fails over without waiting for the hedge delay.
+	start := time.Now()
+	if _, err := fc.Query(Query{Command: "SELECT * FROM cpu"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d := time.Since(start); d > 30*time.Second {
+		t.Errorf("unexpected query duration: %v", d)
+	}
+	if stats := fc.HedgeStats(); stats != (HedgeStats{}) {
+		t.Errorf("unexpected hedge stats.  expected %+v, actual %+v", HedgeStats{}, stats)
+	}
+}
+
+func TestFailoverClient_HedgedQueryMutating(t *testing.T) {
+	primary, _ := newHedgeNode(50 * time.Millisecond)
+	defer primary.Close()
+	code := int32(http.StatusNoContent)
+	secondary, hits := newFailoverNode(&code)
+	defer secondary.Close()
+
+	fc, _ := NewFailoverClient([]HTTPConfig{{Addr: primary.URL}, {Addr: secondary.URL}}, FailoverOptions{HedgeDelay: time.Millisecond})
+	defer fc.Close()
+
+	for _, command := range []string{
+		"CREATE DATABASE db0",
+		"DROP MEASUREMENT cpu",
+		"SELECT * INTO cpu_copy FROM cpu",
+		"SELECT * FROM cpu; DELETE FROM cpu",
+	} {
+		if _, err := fc.Query(Query{Command: command}); err != nil {
+			t.Fatalf("unexpected error for %q: %v", command, err)
+		}
+	}
+	if got := atomic.LoadInt32(hits); got != 0 {
+		t.Errorf("unexpected number of hedged requests.  expected %d, actual %d", 0, got)
+	}
+	if stats := fc.HedgeStats(); stats.Fired != 0 {
+		t.Errorf("unexpected hedges fired.  expected %d, actual %d", 0, stats.Fired)
+	}
+}
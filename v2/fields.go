@@ -0,0 +1,170 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// NonFinitePolicy sets what NewPoint does with NaN and infinite float
+// fields, which the line protocol cannot represent.
+type NonFinitePolicy int
+
+const (
+	// NonFiniteError makes NewPoint fail.
+	NonFiniteError NonFinitePolicy = iota
+
+	// NonFiniteSkip drops the field. A point left without fields is not
+	// created, see SkippedFieldsError.
+	NonFiniteSkip
+
+	// NonFiniteReplace writes PointOptions.Replacement in place of the
+	// value.
+	NonFiniteReplace
+)
+
+// PointOptions sets how NewPoint converts field values to the types of the
+// line protocol.
+type PointOptions struct {
+	// SignedIntegers writes unsigned integer values as signed integers, for
+	// servers without unsigned integer support. A value above math.MaxInt64
+	// is an error then.
+	SignedIntegers bool
+
+	// NonFinite is the policy for NaN and infinite float values.
+	NonFinite NonFinitePolicy
+
+	// Replacement is the value written for NaN and infinite float values
+	// with the NonFiniteReplace policy.
+	Replacement float64
+
+	// AllowAnyTime accepts timestamps outside of MinPlausibleNanoTime and
+	// MaxPlausibleNanoTime, for historical data. Timestamps the server
+	// cannot store are rejected still.
+	AllowAnyTime bool
+
+	// Precision, if set, truncates the timestamps of the points created to
+	// that precision, such as "s", as BatchPoints.TruncateTimes does. The
+	// point is then written with the same timestamp by every client.
+	Precision string
+}
+
+// SkippedFieldsError is returned by NewPoint with the NonFiniteSkip policy
+// for a point whose fields were all dropped. No point is created, and adding
+// the other points to the batch can go on.
+type SkippedFieldsError struct {
+	Measurement string
+
+	// Fields are the names of the dropped fields.
+	Fields []string
+}
+
+func (e *SkippedFieldsError) Error() string {
+	return fmt.Sprintf("point %s skipped: no finite value in fields %s", e.Measurement, strings.Join(e.Fields, ", "))
+}
+
+// convertFields returns fields with the values of types the models package
+// does not encode as intended converted, and non-finite values handled as
+// set by o.NonFinite. fields is copied before the first change.
+func (o PointOptions) convertFields(fields map[string]interface{}) (map[string]interface{}, error) {
+	converted := fields
+	var copied bool
+	update := func(key string, v interface{}, drop bool) {
+		if !copied {
+			converted = make(map[string]interface{}, len(fields))
+			for k, v := range fields {
+				converted[k] = v
+			}
+			copied = true
+		}
+		if drop {
+			delete(converted, key)
+		} else {
+			converted[key] = v
+		}
+	}
+
+	var skipped []string
+	for key, value := range fields {
+		if f, ok := floatValue(value); ok && (math.IsNaN(f) || math.IsInf(f, 0)) {
+			switch o.NonFinite {
+			case NonFiniteSkip:
+				skipped = append(skipped, key)
+				update(key, nil, true)
+			case NonFiniteReplace:
+				update(key, o.Replacement, false)
+			}
+			continue
+		}
+
+		v, ok, err := o.convertField(key, value)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			update(key, v, false)
+		}
+	}
+
+	if len(skipped) > 0 && len(converted) == 0 {
+		sort.Strings(skipped)
+		return nil, &SkippedFieldsError{Fields: skipped}
+	}
+	return converted, nil
+}
+
+// floatValue returns the value of a float field.
+func floatValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// convertField returns value converted to a type that is encoded as
+// intended, and whether it had to be converted.
+func (o PointOptions) convertField(key string, value interface{}) (interface{}, bool, error) {
+	var u uint64
+	switch v := value.(type) {
+	case time.Duration:
+		return int64(v), true, nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, true, nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid number %q for field %s", v, key)
+		}
+		return f, true, nil
+	case uint:
+		u = uint64(v)
+	case uint8:
+		u = uint64(v)
+	case uint16:
+		u = uint64(v)
+	case uint32:
+		u = uint64(v)
+	case uint64:
+		if !o.SignedIntegers {
+			return nil, false, nil
+		}
+		u = v
+	default:
+		return nil, false, nil
+	}
+
+	if !o.SignedIntegers {
+		return u, true, nil
+	}
+	if u > math.MaxInt64 {
+		return nil, false, fmt.Errorf("value %d of field %s overflows a signed integer", u, key)
+	}
+	return int64(u), true, nil
+}
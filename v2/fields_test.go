@@ -0,0 +1,132 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+func TestNewPoint_FieldTypes(t *testing.T) {
+	models.EnableUintSupport()
+
+	for _, tt := range []struct {
+		name   string
+		opts   PointOptions
+		value  interface{}
+		line   string
+		parsed interface{}
+	}{
+		{"uint", PointOptions{}, uint(7), "m v=7u", uint64(7)},
+		{"uint8", PointOptions{}, uint8(7), "m v=7u", uint64(7)},
+		{"uint16", PointOptions{}, uint16(7), "m v=7u", uint64(7)},
+		{"uint32", PointOptions{}, uint32(7), "m v=7u", uint64(7)},
+		{"max uint64", PointOptions{}, uint64(math.MaxUint64), "m v=18446744073709551615u", uint64(math.MaxUint64)},
+		{"signed uint32", PointOptions{SignedIntegers: true}, uint32(7), "m v=7i", int64(7)},
+		{"signed uint64", PointOptions{SignedIntegers: true}, uint64(math.MaxInt64), "m v=9223372036854775807i", int64(math.MaxInt64)},
+		{"duration", PointOptions{}, 1500 * time.Millisecond, "m v=1500000000i", int64(1500000000)},
+		{"float32", PointOptions{}, float32(0.5), "m v=0.5", 0.5},
+		{"json integer", PointOptions{}, json.Number("42"), "m v=42i", int64(42)},
+		{"json float", PointOptions{}, json.Number("4.2"), "m v=4.2", 4.2},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			pt, err := tt.opts.NewPoint("m", nil, map[string]interface{}{"v": tt.value})
+			if err != nil {
+				t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+			}
+			if got := pt.String(); got != tt.line {
+				t.Errorf("unexpected line.  expected %q, actual %q", tt.line, got)
+			}
+
+			points, err := models.ParsePointsString(pt.String())
+			if err != nil {
+				t.Fatalf("unexpected parse error: %v", err)
+			}
+			fields, err := points[0].Fields()
+			if err != nil {
+				t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+			}
+			if !reflect.DeepEqual(fields["v"], tt.parsed) {
+				t.Errorf("unexpected parsed value.  expected %#v, actual %#v", tt.parsed, fields["v"])
+			}
+		})
+	}
+}
+
+func TestNewPoint_FieldTypeErrors(t *testing.T) {
+	opts := PointOptions{SignedIntegers: true}
+	if _, err := opts.NewPoint("m", nil, map[string]interface{}{"v": uint64(math.MaxInt64) + 1}); err == nil {
+		t.Error("expected an overflow error")
+	}
+	if _, err := NewPoint("m", nil, map[string]interface{}{"v": json.Number("x")}); err == nil {
+		t.Error("expected an error for an invalid number")
+	}
+}
+
+func TestNewPoint_FieldsNotModified(t *testing.T) {
+	fields := map[string]interface{}{"d": time.Second, "f": 1.0}
+	if _, err := NewPoint("m", nil, fields); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if _, ok := fields["d"].(time.Duration); !ok {
+		t.Errorf("unexpected change of the fields: %#v", fields)
+	}
+}
+
+func TestNewPoint_NonFinite(t *testing.T) {
+	fields := func(v float64) map[string]interface{} {
+		return map[string]interface{}{"bad": v, "good": 1.0}
+	}
+	for _, v := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		if _, err := NewPoint("m", nil, fields(v)); err == nil {
+			t.Errorf("expected an error for %v", v)
+		}
+
+		pt, err := PointOptions{NonFinite: NonFiniteSkip}.NewPoint("m", nil, fields(v))
+		if err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+		if got, exp := pt.String(), "m good=1"; got != exp {
+			t.Errorf("unexpected line for %v.  expected %q, actual %q", v, exp, got)
+		}
+
+		pt, err = PointOptions{NonFinite: NonFiniteReplace, Replacement: -1}.NewPoint("m", nil, fields(v))
+		if err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+		if got, exp := pt.String(), "m bad=-1,good=1"; got != exp {
+			t.Errorf("unexpected line for %v.  expected %q, actual %q", v, exp, got)
+		}
+	}
+
+	if pt, err := (PointOptions{NonFinite: NonFiniteReplace}).NewPoint("m", nil, map[string]interface{}{"v": float32(math.NaN())}); err != nil || pt.String() != "m v=0" {
+		t.Errorf("unexpected float32 replacement: %v, %v", pt, err)
+	}
+}
+
+func TestNewPoint_NonFiniteOnlyField(t *testing.T) {
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	opts := PointOptions{NonFinite: NonFiniteSkip}
+	var skipped int
+	for _, v := range []float64{1, math.NaN(), 2} {
+		pt, err := opts.NewPoint("cpu", nil, map[string]interface{}{"value": v})
+		var se *SkippedFieldsError
+		if errors.As(err, &se) {
+			if se.Measurement != "cpu" || !reflect.DeepEqual(se.Fields, []string{"value"}) {
+				t.Errorf("unexpected skipped fields error: %+v", se)
+			}
+			skipped++
+			continue
+		} else if err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+		bp.AddPoint(pt)
+	}
+	if skipped != 1 || len(bp.Points()) != 2 {
+		t.Errorf("unexpected result: %d skipped, %d points", skipped, len(bp.Points()))
+	}
+}
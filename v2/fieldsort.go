@@ -0,0 +1,137 @@
+package client
+
+import (
+	"bytes"
+	"sort"
+)
+
+// fieldSorter sorts the fields of serialized points by key, for
+// BatchPointsConfig.SortFields. Its buffers are reused from one point to the
+// next, so that sorting does not allocate once they have grown. It is not
+// safe for concurrent use.
+type fieldSorter struct {
+	spans   []fieldSpan
+	scratch []byte
+}
+
+// fieldSpan is the position of a field in scratch, and keyEnd that of the
+// '=' ending its key.
+type fieldSpan struct {
+	start, keyEnd, end int
+}
+
+// sortLine sorts the fields of line, the line protocol of a point without its
+// newline, whose series key is keyLen bytes long, in place.
+func (s *fieldSorter) sortLine(line []byte, keyLen int, hasTime bool) {
+	fields := line[keyLen+1:]
+	if hasTime {
+		// The time is the last part of the line, and has no space.
+		fields = fields[:bytes.LastIndexByte(fields, ' ')]
+	}
+	s.sortFields(fields)
+}
+
+// sortFields sorts the comma-separated fields of a point in place.
+func (s *fieldSorter) sortFields(fields []byte) {
+	s.spans = s.spans[:0]
+	sorted := true
+	for i := 0; i < len(fields); {
+		span := fieldSpan{start: i}
+		// An escaped '=' or ',' is part of the key.
+		for i < len(fields) && fields[i] != '=' {
+			if fields[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		if i >= len(fields) {
+			// A malformed point, left as it is.
+			return
+		}
+		span.keyEnd = i
+		i++
+		if i < len(fields) && fields[i] == '"' {
+			for i++; i < len(fields) && fields[i] != '"'; i++ {
+				if fields[i] == '\\' {
+					i++
+				}
+			}
+			i++
+		}
+		for i < len(fields) && fields[i] != ',' {
+			i++
+		}
+		if i > len(fields) {
+			// A malformed point, left as it is.
+			return
+		}
+		span.end = i
+		i++
+		if n := len(s.spans); n > 0 && compareFieldKeys(fields[s.spans[n-1].start:s.spans[n-1].keyEnd], fields[span.start:span.keyEnd]) > 0 {
+			sorted = false
+		}
+		s.spans = append(s.spans, span)
+	}
+	if sorted {
+		return
+	}
+
+	s.scratch = append(s.scratch[:0], fields...)
+	sort.Sort(s)
+	n := 0
+	for i, span := range s.spans {
+		if i > 0 {
+			fields[n] = ','
+			n++
+		}
+		n += copy(fields[n:], s.scratch[span.start:span.end])
+	}
+}
+
+func (s *fieldSorter) Len() int      { return len(s.spans) }
+func (s *fieldSorter) Swap(i, j int) { s.spans[i], s.spans[j] = s.spans[j], s.spans[i] }
+func (s *fieldSorter) Less(i, j int) bool {
+	a, b := s.spans[i], s.spans[j]
+	return compareFieldKeys(s.scratch[a.start:a.keyEnd], s.scratch[b.start:b.keyEnd]) < 0
+}
+
+// compareFieldKeys compares the escaped field keys a and b as they compare
+// unescaped, which is the order of the fields of the points created by this
+// package.
+func compareFieldKeys(a, b []byte) int {
+	if bytes.IndexByte(a, '\\') < 0 && bytes.IndexByte(b, '\\') < 0 {
+		return bytes.Compare(a, b)
+	}
+	for {
+		if len(a) == 0 || len(b) == 0 {
+			return len(a) - len(b)
+		}
+		ca, na := unescapedByte(a)
+		cb, nb := unescapedByte(b)
+		if ca != cb {
+			return int(ca) - int(cb)
+		}
+		a, b = a[na:], b[nb:]
+	}
+}
+
+// unescapedByte returns the first byte of the escaped field key b, and the
+// number of bytes it takes in b.
+func unescapedByte(b []byte) (byte, int) {
+	if b[0] == '\\' && len(b) > 1 {
+		switch b[1] {
+		case ',', '=', ' ':
+			return b[1], 2
+		}
+	}
+	return b[0], 1
+}
+
+// newFieldSorter returns the sorter of the fields of the points of bp, nil if
+// they are not sorted.
+func newFieldSorter(bp BatchPoints) *fieldSorter {
+	if batchPointsConfig(bp).SortFields {
+		return &fieldSorter{}
+	}
+	return nil
+}
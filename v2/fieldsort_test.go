@@ -0,0 +1,146 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFieldSorter(t *testing.T) {
+	tests := []struct {
+		fields string
+		exp    string
+	}{
+		{fields: `a=1`, exp: `a=1`},
+		{fields: `a=1,b=2i`, exp: `a=1,b=2i`},
+		{fields: `c=3,b=2,a=1`, exp: `a=1,b=2,c=3`},
+		{fields: `z="x, y=\"1\", w",a=true`, exp: `a=true,z="x, y=\"1\", w"`},
+		{fields: `b\,c=1,b=2,b\ a=3`, exp: `b=2,b\ a=3,b\,c=1`},
+		{fields: `s="a\\",a=1`, exp: `a=1,s="a\\"`},
+		// Malformed fields are left as they are.
+		{fields: `b=1,a`, exp: `b=1,a`},
+		{fields: `b="1,a=2`, exp: `b="1,a=2`},
+	}
+
+	var s fieldSorter
+	for _, test := range tests {
+		b := []byte(test.fields)
+		s.sortFields(b)
+		if string(b) != test.exp {
+			t.Errorf("unexpected fields for %s.  expected %s, actual %s", test.fields, test.exp, b)
+		}
+	}
+}
+
+// unsortedBatch returns a batch with sortFields set of points parsed from
+// line protocol with their fields out of order.
+func unsortedBatch(t testing.TB, sortFields bool) BatchPoints {
+	points, err := ParsePointsString("cpu,host=a b=2i,a=\"x y\",c=3 1000000000\nmem c=1,b=\"1 2\" 2000000000\n", time.Time{}, "ns")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bp, _ := NewBatchPoints(BatchPointsConfig{Precision: "s", SortFields: sortFields})
+	bp.AddPoints(points)
+	return bp
+}
+
+const sortedLines = "cpu,host=a a=\"x y\",b=2i,c=3 1\nmem b=\"1 2\",c=1 2\n"
+
+func TestBatchPoints_SortFields(t *testing.T) {
+	bp := unsortedBatch(t, true)
+	var buf bytes.Buffer
+	if _, err := bp.WriteTo(&buf); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if buf.String() != sortedLines {
+		t.Errorf("unexpected lines.\nexpected %q\nactual   %q", sortedLines, buf.String())
+	}
+	// The points are left as they were parsed.
+	if exp, actual := "mem c=1,b=\"1 2\" 2000000000", bp.Points()[1].String(); actual != exp {
+		t.Errorf("unexpected point.  expected %s, actual %s", exp, actual)
+	}
+	buf.Reset()
+	unsortedBatch(t, false).WriteTo(&buf)
+	if buf.String() == sortedLines {
+		t.Errorf("unexpected lines sorted without SortFields: %q", buf.String())
+	}
+
+	b, _ := AppendBinary(nil, bp)
+	if decoded, _ := FromBinary(b); !batchPointsConfig(decoded).SortFields {
+		t.Errorf("unexpected SortFields lost by the binary encoding")
+	}
+}
+
+func TestClient_WriteSortFields(t *testing.T) {
+	var body string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		body = string(b)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+	if err := c.Write(unsortedBatch(t, true)); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if body != sortedLines {
+		t.Errorf("unexpected body.\nexpected %q\nactual   %q", sortedLines, body)
+	}
+
+	var payloads []string
+	bp := unsortedBatch(t, true)
+	err := writePayloads(context.Background(), bp, "", &payloadBuffers{}, TCPPayloadSize, true, func(b []byte) error {
+		payloads = append(payloads, string(b))
+		return nil
+	})
+	// The payloads are in nanoseconds, rounded to the precision.
+	exp := strings.NewReplacer(" 1\n", " 1000000000\n", " 2\n", " 2000000000\n").Replace(sortedLines)
+	if err != nil || strings.Join(payloads, "") != exp {
+		t.Errorf("unexpected payloads.\nexpected %q\nactual   %q, %v", exp, payloads, err)
+	}
+}
+
+// newWideBatch returns a batch of points of 20 fields, parsed in reverse
+// order.
+func newWideBatch(b *testing.B, sortFields bool) BatchPoints {
+	var lines strings.Builder
+	for i := 0; i < 100; i++ {
+		lines.WriteString("cpu,host=server01 ")
+		for f := 19; f >= 0; f-- {
+			fmt.Fprintf(&lines, "field%02d=%d", f, i*f)
+			if f > 0 {
+				lines.WriteByte(',')
+			}
+		}
+		fmt.Fprintf(&lines, " %d\n", i)
+	}
+	points, err := ParsePointsString(lines.String(), time.Time{}, "ns")
+	if err != nil {
+		b.Fatal(err)
+	}
+	bp, _ := NewBatchPoints(BatchPointsConfig{SortFields: sortFields})
+	bp.AddPoints(points)
+	return bp
+}
+
+func BenchmarkBatchPoints_SortFields(b *testing.B) {
+	for _, sortFields := range []bool{false, true} {
+		b.Run(fmt.Sprintf("sort=%v", sortFields), func(b *testing.B) {
+			bp := newWideBatch(b, sortFields)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				bp.WriteTo(io.Discard)
+			}
+		})
+	}
+}
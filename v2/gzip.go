@@ -0,0 +1,53 @@
+package client
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// decompressBody replaces the body of resp with its decompressed content if
+// the server gzip compressed it, as the transport only does so itself when it
+// set Accept-Encoding.
+func decompressBody(resp *http.Response) {
+	if resp.Uncompressed || !strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		return
+	}
+	resp.Body = &gzipBody{body: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+}
+
+// gzipBody decompresses the body of a response. The gzip header is only read
+// on the first Read, so that a chunked response whose first chunk is not sent
+// yet does not block before its read timeout applies.
+type gzipBody struct {
+	body io.ReadCloser
+	zr   *gzip.Reader
+	err  error
+}
+
+func (b *gzipBody) Read(p []byte) (int, error) {
+	if b.zr == nil && b.err == nil {
+		b.zr, b.err = gzip.NewReader(b.body)
+	}
+	if b.err != nil {
+		return 0, b.err
+	}
+	return b.zr.Read(p)
+}
+
+// Close closes the gzip reader and the body.
+func (b *gzipBody) Close() error {
+	var err error
+	if b.zr != nil {
+		err = b.zr.Close()
+	}
+	if cerr := b.body.Close(); cerr != nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,150 @@
+package client
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+// bodyCloseTransport is a transport that records whether the bodies of its
+// responses were closed.
+type bodyCloseTransport struct {
+	rt http.RoundTripper
+
+	mu     sync.Mutex
+	bodies []*closeBody
+}
+
+type closeBody struct {
+	io.ReadCloser
+	closed bool
+}
+
+func (b *closeBody) Close() error {
+	b.closed = true
+	return b.ReadCloser.Close()
+}
+
+func (r *bodyCloseTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := r.rt.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body := &closeBody{ReadCloser: resp.Body}
+	resp.Body = body
+	r.mu.Lock()
+	r.bodies = append(r.bodies, body)
+	r.mu.Unlock()
+	return resp, nil
+}
+
+// newGzipServer returns a server answering every query with a chunk per
+// series of cpu, gzip compressed if the request accepts it, along with the
+// Accept-Encoding headers of the requests.
+func newGzipServer(t *testing.T) (*httptest.Server, *[]string) {
+	var accepted []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accepted = append(accepted, r.Header.Get("Accept-Encoding"))
+		w.Header().Set("Content-Type", "application/json")
+		var out io.Writer = w
+		if r.Header.Get("Accept-Encoding") == "gzip" {
+			w.Header().Set("Content-Encoding", "gzip")
+			gw := gzip.NewWriter(w)
+			defer gw.Close()
+			out = gw
+		}
+		w.WriteHeader(http.StatusOK)
+		enc := json.NewEncoder(out)
+		for i := 0; i < 2; i++ {
+			enc.Encode(Response{Results: []Result{{
+				Series:  []models.Row{newRows("cpu", 1)},
+				Partial: i == 0,
+			}}})
+			if gw, ok := out.(*gzip.Writer); ok {
+				gw.Flush()
+			}
+		}
+	}))
+	return ts, &accepted
+}
+
+func TestClient_AcceptGzip(t *testing.T) {
+	ts, accepted := newGzipServer(t)
+	defer ts.Close()
+
+	tr := &bodyCloseTransport{rt: &http.Transport{DisableCompression: true}}
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, AcceptGzip: true, Transport: tr})
+	defer c.Close()
+
+	cr, err := c.QueryAsChunk(Query{Command: "SELECT * FROM cpu", Database: "db0"})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if enc := cr.Header.Get("Content-Encoding"); enc != "" {
+		t.Errorf("unexpected Content-Encoding.  expected none, actual %s", enc)
+	}
+	var n int
+	for {
+		resp, err := cr.NextResponse()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+		if len(resp.Results) != 1 || len(resp.Results[0].Series) != 1 {
+			t.Fatalf("unexpected response: %+v", resp)
+		}
+		n++
+	}
+	if n != 2 {
+		t.Errorf("unexpected number of chunks.  expected %v, actual %v", 2, n)
+	}
+	if err := cr.Close(); err != nil {
+		t.Errorf("unexpected error closing.  expected %v, actual %v", nil, err)
+	}
+
+	resp, err := c.Query(Query{Command: "SELECT * FROM cpu", Database: "db0", Chunked: true})
+	if err != nil || len(resp.Results) != 2 {
+		t.Fatalf("unexpected query result: %v, %v", resp, err)
+	}
+
+	if exp := []string{"gzip", "gzip"}; len(*accepted) != 2 || (*accepted)[0] != exp[0] || (*accepted)[1] != exp[1] {
+		t.Errorf("unexpected Accept-Encoding.  expected %q, actual %q", exp, *accepted)
+	}
+	if len(tr.bodies) != 2 {
+		t.Fatalf("unexpected number of responses.  expected %v, actual %v", 2, len(tr.bodies))
+	}
+	for i, b := range tr.bodies {
+		if !b.closed {
+			t.Errorf("expected the body of response %d to be closed", i)
+		}
+	}
+}
+
+func TestClient_AcceptGzipOff(t *testing.T) {
+	ts, accepted := newGzipServer(t)
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, Transport: &http.Transport{DisableCompression: true}})
+	defer c.Close()
+	if _, err := c.Query(Query{Command: "SELECT * FROM cpu", Database: "db0", Chunked: true}); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+
+	// The header set by the caller gets a compressed response, which is
+	// decompressed too.
+	resp, err := c.Query(Query{Command: "SELECT * FROM cpu", Database: "db0", Chunked: true, Headers: map[string]string{"Accept-Encoding": "gzip"}})
+	if err != nil || len(resp.Results) != 2 {
+		t.Fatalf("unexpected query result: %v, %v", resp, err)
+	}
+	if exp := []string{"", "gzip"}; len(*accepted) != 2 || (*accepted)[0] != exp[0] || (*accepted)[1] != exp[1] {
+		t.Errorf("unexpected Accept-Encoding.  expected %q, actual %q", exp, *accepted)
+	}
+}
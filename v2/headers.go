@@ -0,0 +1,79 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime"
+	"strings"
+)
+
+// Version is the version of the client, sent in its default User-Agent.
+const Version = "2.0.0"
+
+// defaultUserAgent returns the User-Agent of a client without
+// HTTPConfig.UserAgent.
+func defaultUserAgent() string {
+	return fmt.Sprintf("influxdb1-client/%s (go/%s)", Version, strings.TrimPrefix(runtime.Version(), "go"))
+}
+
+// ReservedHeaderError is returned when headers passed to a write or query
+// would override one the client sets itself: Authorization, Content-Length,
+// and Content-Encoding when writes are gzip encoded.
+type ReservedHeaderError struct {
+	Header string
+}
+
+func (e *ReservedHeaderError) Error() string {
+	return fmt.Sprintf("header %s is set by the client and cannot be overridden", e.Header)
+}
+
+// HeaderWriter is implemented by the HTTP client.
+type HeaderWriter interface {
+	// WriteWithHeaders is like WriteContext, but sends headers with the
+	// request, on top of HTTPConfig.Headers and overriding them.
+	WriteWithHeaders(ctx context.Context, bp BatchPoints, headers map[string]string) error
+}
+
+// WriteWithHeaders is like WriteContext, sending headers with the request.
+func (c *client) WriteWithHeaders(ctx context.Context, bp BatchPoints, headers map[string]string) error {
+	if err := checkHeaders(headers, c.encoding); err != nil {
+		return err
+	}
+	var ws WriteStats
+	return c.writeContext(ctx, bp, headers, &ws, nil)
+}
+
+// checkHeaders returns a *ReservedHeaderError if headers holds a header set
+// by a client with the given write encoding.
+func checkHeaders(headers map[string]string, encoding ContentEncoding) error {
+	for name := range headers {
+		switch key := http.CanonicalHeaderKey(name); key {
+		case "Authorization", "Content-Length":
+			return &ReservedHeaderError{Header: key}
+		case "Content-Encoding":
+			if encoding != DefaultEncoding {
+				return &ReservedHeaderError{Header: key}
+			}
+		}
+	}
+	return nil
+}
+
+// setHeaders sets the User-Agent and X-Client-Id headers of the client on
+// req, then the headers of the client's config, then those of the request
+// itself. With DisableKeepAlives the request is sent with "Connection: close",
+// through a custom Transport too.
+func (c *client) setHeaders(req *http.Request, headers map[string]string) {
+	req.Close = c.keepAlivesOff
+	req.Header.Set("User-Agent", c.useragent)
+	if c.clientID != "" {
+		req.Header.Set("X-Client-Id", c.clientID)
+	}
+	for name, value := range c.headers {
+		req.Header.Set(name, value)
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+}
@@ -0,0 +1,114 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestClient_Headers(t *testing.T) {
+	var mu sync.Mutex
+	seen := make(map[string]http.Header)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.URL.Path
+		if r.URL.Query().Get("chunked") == "true" {
+			key += "?chunked"
+		}
+		mu.Lock()
+		seen[key] = r.Header.Clone()
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/query":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"results":[{}]}`))
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer ts.Close()
+
+	c, err := NewHTTPClient(HTTPConfig{
+		Addr:     ts.URL,
+		Username: "user",
+		Password: "pass",
+		Headers:  map[string]string{"X-Scope-OrgID": "tenant-1", "X-Extra": "config"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	defer c.Close()
+
+	if _, _, err := c.Ping(0); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0"})
+	bp.AddPoints(newTestPoints(1))
+	if err := c.(HeaderWriter).WriteWithHeaders(context.Background(), bp, map[string]string{"x-extra": "write"}); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if _, err := c.Query(Query{Command: "SHOW DATABASES", Headers: map[string]string{"X-Extra": "query"}}); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	cr, err := c.QueryAsChunk(Query{Command: "SHOW DATABASES", Headers: map[string]string{"X-Extra": "chunked"}})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	cr.Close()
+
+	for key, extra := range map[string]string{
+		"/ping":          "config",
+		"/write":         "write",
+		"/query":         "query",
+		"/query?chunked": "chunked",
+	} {
+		h, ok := seen[key]
+		if !ok {
+			t.Errorf("no request to %s", key)
+			continue
+		}
+		if got := h.Get("X-Scope-OrgID"); got != "tenant-1" {
+			t.Errorf("unexpected X-Scope-OrgID for %s.  expected %v, actual %v", key, "tenant-1", got)
+		}
+		if got := h.Get("X-Extra"); got != extra {
+			t.Errorf("unexpected X-Extra for %s.  expected %v, actual %v", key, extra, got)
+		}
+		if _, _, ok := (&http.Request{Header: h}).BasicAuth(); !ok {
+			t.Errorf("expected basic auth for %s", key)
+		}
+	}
+}
+
+func TestClient_ReservedHeaders(t *testing.T) {
+	for _, conf := range []HTTPConfig{
+		{Addr: "http://localhost", Headers: map[string]string{"authorization": "Token x"}},
+		{Addr: "http://localhost", Headers: map[string]string{"Content-Length": "0"}},
+		{Addr: "http://localhost", Headers: map[string]string{"Content-Encoding": "br"}, WriteEncoding: GzipEncoding},
+	} {
+		var ce *ConfigError
+		if _, err := NewHTTPClient(conf); !errors.As(err, &ce) || ce.Field != "Headers" {
+			t.Errorf("unexpected error for %v.  expected a *ConfigError, actual %v", conf.Headers, err)
+		}
+	}
+	// Without gzip the client does not set Content-Encoding.
+	if _, err := NewHTTPClient(HTTPConfig{Addr: "http://localhost", Headers: map[string]string{"Content-Encoding": "identity"}}); err != nil {
+		t.Errorf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: "http://localhost"})
+	defer c.Close()
+	bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0"})
+	var he *ReservedHeaderError
+	err := c.(HeaderWriter).WriteWithHeaders(context.Background(), bp, map[string]string{"Authorization": "Token x"})
+	if !errors.As(err, &he) || he.Header != "Authorization" {
+		t.Errorf("unexpected error.  expected a *ReservedHeaderError, actual %v", err)
+	}
+	if _, err := c.Query(Query{Command: "SHOW DATABASES", Headers: map[string]string{"content-length": "1"}}); !errors.As(err, &he) || he.Header != "Content-Length" {
+		t.Errorf("unexpected error.  expected a *ReservedHeaderError, actual %v", err)
+	}
+	if _, err := c.QueryAsChunk(Query{Command: "SHOW DATABASES", Headers: map[string]string{"Authorization": "Token x"}}); !errors.As(err, &he) {
+		t.Errorf("unexpected error.  expected a *ReservedHeaderError, actual %v", err)
+	}
+}
@@ -0,0 +1,207 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"time"
+)
+
+// ErrNotSupported is matched by the error of a request to an endpoint the
+// server does not have.
+var ErrNotSupported = errors.New("not supported by the server")
+
+// EndpointNotSupportedError is returned for a request to an endpoint that the
+// server answered with a 404 status, as servers older than the endpoint do.
+type EndpointNotSupportedError struct {
+	// Endpoint is the path of the endpoint, such as "/health".
+	Endpoint string
+
+	// Version is the version of the server, if it sent one.
+	Version string
+}
+
+func (e *EndpointNotSupportedError) Error() string {
+	if e.Version == "" {
+		return fmt.Sprintf("%s is not supported by the server", e.Endpoint)
+	}
+	return fmt.Sprintf("%s is not supported by the server, version %s", e.Endpoint, e.Version)
+}
+
+func (e *EndpointNotSupportedError) Is(target error) bool { return target == ErrNotSupported }
+
+// PingResult is the outcome of a successful ping.
+type PingResult struct {
+	// Latency is how long the request took.
+	Latency time.Duration
+
+	// Version is the version of the server.
+	Version string
+
+	// Build is the build type of the server, such as "OSS", or "" if the
+	// server did not tell.
+	Build string
+}
+
+// HealthInfo is the health of a server, as reported by its /health endpoint.
+type HealthInfo struct {
+	// Name is the name of the service, "influxdb".
+	Name string
+
+	// Status is "pass" if the server is ready for writes and queries, and
+	// "fail" otherwise.
+	Status string
+
+	// Message describes the status.
+	Message string
+
+	// Version is the version of the server.
+	Version string
+
+	// Latency is how long the request took.
+	Latency time.Duration
+}
+
+// HealthClient is implemented by the HTTP client.
+type HealthClient interface {
+	// PingContext is like Ping, but bound to ctx, and also returns the
+	// build type of the server.
+	PingContext(ctx context.Context, timeout time.Duration) (PingResult, error)
+
+	// Health returns the health of the server, a failing one included. It
+	// returns an error matching ErrNotSupported for a server older than
+	// InfluxDB 1.8, which has no /health endpoint.
+	Health(ctx context.Context) (HealthInfo, error)
+}
+
+// PingContext pings the server. A timeout, if set, bounds the request in place
+// of HTTPConfig.Timeout and is how long the server waits for a leader.
+func (c *client) PingContext(ctx context.Context, timeout time.Duration) (res PingResult, err error) {
+	ctx, end := c.startSpan(ctx, SpanPing)
+	if end != nil {
+		defer func() {
+			attrs := make(map[string]interface{})
+			if status := spanStatus(err, http.StatusNoContent); status != 0 {
+				attrs[SpanAttrStatusCode] = status
+			}
+			end(err, attrs)
+		}()
+	}
+	if c.pingBothEndpoints {
+		return c.pingBoth(ctx, timeout)
+	}
+	return c.ping(ctx, &c.queryEP, timeout)
+}
+
+// ping pings the server of ep.
+func (c *client) ping(ctx context.Context, ep *endpoint, timeout time.Duration) (PingResult, error) {
+	now := time.Now()
+
+	u := ep.url
+	u.Path = path.Join(u.Path, "ping")
+
+	hc := ep.httpClient
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+		hc = ep.untimedClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return PingResult{}, err
+	}
+
+	c.setHeaders(req, nil)
+
+	if timeout > 0 {
+		params := req.URL.Query()
+		params.Set("wait_for_leader", fmt.Sprintf("%.0fs", timeout.Seconds()))
+		req.URL.RawQuery = params.Encode()
+	}
+
+	resp, err := c.doWith(ep, hc, req)
+	if err != nil {
+		return PingResult{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return PingResult{}, contextError(ctx, err)
+	}
+
+	if resp.StatusCode != http.StatusNoContent {
+		return PingResult{}, c.errorBody(newErrorResponseBody(resp, body), body)
+	}
+
+	return PingResult{
+		Latency: time.Since(now),
+		Version: resp.Header.Get("X-Influxdb-Version"),
+		Build:   resp.Header.Get("X-Influxdb-Build"),
+	}, nil
+}
+
+// Health returns the health of the server. A server the ServerInfo found
+// too old for the endpoint is not asked.
+func (c *client) Health(ctx context.Context) (HealthInfo, error) {
+	if info, ok := c.cachedServerInfo(); ok && !info.Development && !info.SupportsHealthEndpoint {
+		return HealthInfo{}, &EndpointNotSupportedError{Endpoint: "/health", Version: info.Version}
+	}
+	return c.health(ctx)
+}
+
+func (c *client) health(ctx context.Context) (HealthInfo, error) {
+	now := time.Now()
+
+	u := c.queryEP.url
+	u.Path = path.Join(u.Path, "health")
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return HealthInfo{}, err
+	}
+	c.setHeaders(req, nil)
+
+	resp, err := c.do(&c.queryEP, req)
+	if err != nil {
+		return HealthInfo{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return HealthInfo{}, contextError(ctx, err)
+	}
+	version := resp.Header.Get("X-Influxdb-Version")
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusServiceUnavailable:
+	case http.StatusNotFound:
+		return HealthInfo{}, &EndpointNotSupportedError{Endpoint: "/health", Version: version}
+	default:
+		return HealthInfo{}, c.errorBody(newErrorResponseBody(resp, body), body)
+	}
+
+	var info HealthInfo
+	err = json.Unmarshal(body, &info)
+	if err == nil && info.Status == "" {
+		err = errors.New("missing status")
+	}
+	if err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return HealthInfo{}, c.errorBody(newErrorResponseBody(resp, body), body)
+		}
+		return HealthInfo{}, fmt.Errorf("unable to decode health: received status code %d err: %s", resp.StatusCode, err)
+	}
+	if info.Version == "" {
+		info.Version = version
+	}
+	info.Latency = time.Since(now)
+	return info, nil
+}
@@ -0,0 +1,110 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClient_Health(t *testing.T) {
+	var status int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			t.Errorf("unexpected path.  expected %v, actual %v", "/health", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Influxdb-Version", "1.8.10")
+		w.WriteHeader(status)
+		switch status {
+		case http.StatusOK:
+			w.Write([]byte(`{"name":"influxdb","message":"ready for queries and writes","status":"pass","checks":[],"version":"1.8.10"}`))
+		case http.StatusServiceUnavailable:
+			w.Write([]byte(`{"name":"influxdb","message":"not ready","status":"fail","checks":[]}`))
+		}
+	}))
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+	hc := c.(HealthClient)
+
+	status = http.StatusOK
+	info, err := hc.Health(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if info.Name != "influxdb" || info.Status != "pass" || info.Message != "ready for queries and writes" || info.Version != "1.8.10" || info.Latency <= 0 {
+		t.Errorf("unexpected health: %+v", info)
+	}
+
+	// A failing server is reported as such, with the version of the header.
+	status = http.StatusServiceUnavailable
+	info, err = hc.Health(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if info.Status != "fail" || info.Message != "not ready" || info.Version != "1.8.10" {
+		t.Errorf("unexpected health: %+v", info)
+	}
+
+	status = http.StatusNotFound
+	_, err = hc.Health(context.Background())
+	var ne *EndpointNotSupportedError
+	if !errors.Is(err, ErrNotSupported) || !errors.As(err, &ne) || ne.Endpoint != "/health" || ne.Version != "1.8.10" {
+		t.Errorf("unexpected error.  expected %v, actual %v", ErrNotSupported, err)
+	}
+}
+
+func TestClient_PingContext(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("wait_for_leader") == "2s" {
+			time.Sleep(100 * time.Millisecond)
+		}
+		w.Header().Set("X-Influxdb-Version", "1.8.10")
+		w.Header().Set("X-Influxdb-Build", "OSS")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, Timeout: 50 * time.Millisecond})
+	defer c.Close()
+
+	res, err := c.(HealthClient).PingContext(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if res.Version != "1.8.10" || res.Build != "OSS" {
+		t.Errorf("unexpected ping result: %+v", res)
+	}
+
+	// The timeout of a ping lifts the client's.
+	if _, _, err := c.Ping(2 * time.Second); err != nil {
+		t.Errorf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+}
+
+func TestClient_PingTimeout(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	start := time.Now()
+	_, _, err := c.Ping(100 * time.Millisecond)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("unexpected error.  expected %v, actual %v", context.DeadlineExceeded, err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("unexpected ping duration: %v", elapsed)
+	}
+}
@@ -0,0 +1,51 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// ChunkReadTimeoutError is returned by ChunkedResponse.NextResponse when the
+// server sent nothing for the ChunkReadTimeout of the query. It wraps
+// context.DeadlineExceeded.
+type ChunkReadTimeoutError struct {
+	Timeout time.Duration
+}
+
+func (e *ChunkReadTimeoutError) Error() string {
+	return fmt.Sprintf("no data received from the chunked response for %v", e.Timeout)
+}
+
+func (e *ChunkReadTimeoutError) Unwrap() error { return context.DeadlineExceeded }
+
+// idleReader is the body of a chunked response that calls abort, canceling
+// the request, when a read gets no data for timeout. The time spent between
+// reads, while the caller handles a response, does not count.
+type idleReader struct {
+	io.ReadCloser
+	timeout time.Duration
+	abort   func()
+
+	expired int32
+}
+
+func (r *idleReader) Read(p []byte) (int, error) {
+	t := time.AfterFunc(r.timeout, func() {
+		atomic.StoreInt32(&r.expired, 1)
+		r.abort()
+	})
+	n, err := r.ReadCloser.Read(p)
+	t.Stop()
+	if err != nil && r.timedOut() {
+		err = &ChunkReadTimeoutError{Timeout: r.timeout}
+	}
+	return n, err
+}
+
+// timedOut reports whether a read timed out.
+func (r *idleReader) timedOut() bool {
+	return atomic.LoadInt32(&r.expired) == 1
+}
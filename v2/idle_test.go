@@ -0,0 +1,159 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newStallingServer returns a server sending n chunks every interval, then
+// nothing until the client goes away, which closes gone.
+func newStallingServer(n int, interval time.Duration) (*httptest.Server, chan struct{}) {
+	gone := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		enc := json.NewEncoder(w)
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				time.Sleep(interval)
+			}
+			_ = enc.Encode(Response{Results: []Result{{StatementId: i}}})
+			w.(http.Flusher).Flush()
+		}
+		<-r.Context().Done()
+		close(gone)
+	}))
+	return ts, gone
+}
+
+func TestChunkedResponse_ReadTimeout(t *testing.T) {
+	ts, gone := newStallingServer(1, 0)
+	defer ts.Close()
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, ChunkReadTimeout: time.Hour})
+	defer c.Close()
+
+	cr, err := c.QueryAsChunk(Query{Command: "SELECT * FROM cpu", ChunkReadTimeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	defer cr.Close()
+	if _, err := cr.NextResponse(); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+
+	start := time.Now()
+	_, err = cr.NextResponse()
+	var te *ChunkReadTimeoutError
+	if !errors.As(err, &te) || te.Timeout != 50*time.Millisecond || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("unexpected error.  expected a *ChunkReadTimeoutError, actual %v", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("unexpected wait for the timeout: %v", d)
+	}
+	select {
+	case <-gone:
+	case <-time.After(5 * time.Second):
+		t.Error("expected the request to be canceled")
+	}
+}
+
+func TestChunkedResponse_ReadTimeoutIdle(t *testing.T) {
+	// The stream takes longer than the timeout, but is never idle for that
+	// long.
+	ts, _ := newStallingServer(6, 20*time.Millisecond)
+	defer ts.Close()
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, ChunkReadTimeout: 500 * time.Millisecond})
+	defer c.Close()
+
+	cr, err := c.QueryAsChunk(Query{Command: "SELECT * FROM cpu"})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	defer cr.Close()
+	for i := 0; i < 6; i++ {
+		if _, err := cr.NextResponse(); err != nil {
+			t.Fatalf("unexpected error for chunk %d.  expected %v, actual %v", i, nil, err)
+		}
+		// Handling a chunk is not idling.
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if _, err := NewHTTPClient(HTTPConfig{Addr: ts.URL, ChunkReadTimeout: -time.Second}); err == nil {
+		t.Error("expected an error for a negative ChunkReadTimeout")
+	}
+}
+
+func TestChunkedResponse_Cancel(t *testing.T) {
+	ts, gone := newStallingServer(1, 0)
+	defer ts.Close()
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cr, err := c.(ContextClient).QueryAsChunkContext(ctx, Query{Command: "SELECT * FROM cpu"})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	defer cr.Close()
+	if _, err := cr.NextResponse(); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if _, err := cr.NextResponse(); err != context.Canceled {
+		t.Errorf("unexpected error.  expected %v, actual %v", context.Canceled, err)
+	}
+	select {
+	case <-gone:
+	case <-time.After(5 * time.Second):
+		t.Error("expected the request to be canceled")
+	}
+}
+
+func TestChunkedResponse_CloseWhileReading(t *testing.T) {
+	ts, gone := newStallingServer(1, 0)
+	defer ts.Close()
+	stats := &recordingStats{}
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, Stats: stats})
+	defer c.Close()
+
+	cr, err := c.QueryAsChunk(Query{Command: "SELECT * FROM cpu"})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if _, err := cr.NextResponse(); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+
+	read := make(chan error, 1)
+	go func() {
+		_, err := cr.NextResponse()
+		read <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cr.Close()
+	select {
+	case err := <-read:
+		if err == nil {
+			t.Error("expected the read to fail")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected Close to abort the read")
+	}
+	select {
+	case <-gone:
+	case <-time.After(5 * time.Second):
+		t.Error("expected the request to be canceled")
+	}
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	if n := len(stats.queries); n != 1 {
+		t.Errorf("unexpected number of queries reported.  expected %v, actual %v", 1, n)
+	}
+}
@@ -0,0 +1,367 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+// DefaultImportBatchSize is the default number of points of the batches
+// written by ImportFile.
+const DefaultImportBatchSize = 5000
+
+// ImportErrorMode sets how ImportFile handles invalid lines and failed writes.
+type ImportErrorMode int
+
+const (
+	// ImportFailFast stops the import at the first invalid line or failed
+	// write. The writes already sent are waited for.
+	ImportFailFast ImportErrorMode = iota
+
+	// ImportContinueOnError skips the invalid lines and the batches whose
+	// write failed, and goes on with the rest of the file.
+	ImportContinueOnError
+)
+
+// ImportOptions sets how ImportFile restores a file.
+type ImportOptions struct {
+	// Database and RetentionPolicy, if set, are written to in place of the
+	// context of the DML sections of the file.
+	Database        string
+	RetentionPolicy string
+
+	// Precision is the precision of the timestamps, defaults to "ns" as
+	// written by influx_inspect export.
+	Precision string
+
+	// DDL makes ImportFile run the statements of the DDL sections, which
+	// create the databases and retention policies of the export. They are
+	// skipped otherwise.
+	DDL bool
+
+	// BatchSize is the number of points per write, defaults to
+	// DefaultImportBatchSize.
+	BatchSize int
+
+	// Concurrency is the number of writes sent at once, defaults to 1.
+	Concurrency int
+
+	// ErrorMode defaults to ImportFailFast.
+	ErrorMode ImportErrorMode
+}
+
+// ImportReport is the outcome of ImportFile.
+type ImportReport struct {
+	// LinesRead is the number of lines read, comments and blank lines
+	// included.
+	LinesRead int
+
+	// PointsWritten is the number of points written.
+	PointsWritten int
+
+	// LinesSkipped is the number of invalid lines skipped with
+	// ImportContinueOnError.
+	LinesSkipped int
+
+	// PointsFailed is the number of points of the batches whose write
+	// failed.
+	PointsFailed int
+
+	// Duration is how long the import took.
+	Duration time.Duration
+}
+
+// ImportError is returned by ImportFile with ImportContinueOnError when the
+// writes of some batches failed. The other batches were written.
+type ImportError struct {
+	// Batches is the number of batches whose write failed.
+	Batches int
+
+	// Err is the error of the first of them.
+	Err error
+}
+
+func (e *ImportError) Error() string {
+	return fmt.Sprintf("%d batches failed to import: %v", e.Batches, e.Err)
+}
+
+func (e *ImportError) Unwrap() error { return e.Err }
+
+// ImportFile restores the data of a file written by `influx_inspect export`,
+// or by WriteImport, read from r and decompressed if it is gzipped. The
+// points of the DML sections are written with c to the database and
+// retention policy given by their "# CONTEXT-DATABASE:" and
+// "# CONTEXT-RETENTION-POLICY:" comments, unless opts sets them. The file is
+// read as it is written, so that its size does not matter. Lines before the
+// first section are taken as DML, so plain line protocol can be imported too.
+//
+// With ImportFailFast the error of an invalid line is a *LineError, whose
+// Index and Offset are those of the line in the decompressed file.
+func ImportFile(ctx context.Context, c Client, r io.Reader, opts ImportOptions) (ImportReport, error) {
+	start := time.Now()
+	im, err := newImporter(ctx, c, opts, start)
+	if err != nil {
+		return ImportReport{}, err
+	}
+	err = im.read(r)
+	report, werr := im.wait()
+	report.Duration = time.Since(start)
+	switch {
+	case ctx.Err() != nil:
+		return report, ctx.Err()
+	case err != nil:
+		return report, err
+	}
+	return report, werr
+}
+
+// importer holds the state of an ImportFile.
+type importer struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	c      Client
+	opts   ImportOptions
+	start  time.Time
+
+	// section is the section of the file being read, and db and rp the
+	// context of its points.
+	section string
+	db, rp  string
+	batch   BatchPoints
+
+	batches chan BatchPoints
+	wg      sync.WaitGroup
+
+	mu     sync.Mutex
+	report ImportReport
+	failed int
+	first  error
+}
+
+func newImporter(ctx context.Context, c Client, opts ImportOptions, start time.Time) (*importer, error) {
+	if opts.Precision == "" {
+		opts.Precision = "ns"
+	}
+	if _, err := time.ParseDuration("1" + opts.Precision); err != nil {
+		return nil, &ConfigError{Field: "Precision", Reason: err.Error()}
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultImportBatchSize
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 1
+	}
+
+	im := &importer{c: c, opts: opts, start: start, section: "# DML", batches: make(chan BatchPoints)}
+	im.ctx, im.cancel = context.WithCancel(ctx)
+	for i := 0; i < opts.Concurrency; i++ {
+		im.wg.Add(1)
+		go im.writer()
+	}
+	return im, nil
+}
+
+// read reads the lines of r, decompressing it if it is gzipped.
+func (im *importer) read(r io.Reader) error {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); isGzip(magic) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		br = bufio.NewReader(gz)
+	}
+
+	offset := 0
+	for {
+		if err := im.ctx.Err(); err != nil {
+			return nil
+		}
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			if lerr := im.line(line, im.report.LinesRead, offset); lerr != nil {
+				return lerr
+			}
+			im.report.LinesRead++
+			offset += len(line)
+		}
+		if err == io.EOF {
+			return im.flush()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// line handles the line of the file with the given index and offset.
+func (im *importer) line(line []byte, index, offset int) error {
+	text := strings.TrimSpace(string(trimNewline(line)))
+	switch {
+	case text == "":
+		return nil
+	case text == "# DDL" || text == "# DML":
+		im.section = text
+		return nil
+	case strings.HasPrefix(text, "#"):
+		im.context(text)
+		return nil
+	case im.section == "# DDL":
+		if !im.opts.DDL {
+			return nil
+		}
+		return execAdmin(im.ctx, im.c, text, "")
+	}
+
+	db, rp := im.destination()
+	if db == "" {
+		return &ConfigError{Field: "Database", Reason: fmt.Sprintf("line %d has no database context", index)}
+	}
+	if im.batch != nil && (im.batch.Database() != db || im.batch.RetentionPolicy() != rp) {
+		if err := im.flush(); err != nil {
+			return err
+		}
+	}
+
+	var invalid *LineError
+	parseLines(line, im.start, im.opts.Precision, func(pt models.Point, le *LineError) bool {
+		if le != nil {
+			le.Index, le.Offset = index, offset
+			invalid = le
+			return false
+		}
+		if im.batch == nil {
+			im.batch, _ = NewBatchPoints(BatchPointsConfig{Database: db, RetentionPolicy: rp, Precision: im.opts.Precision})
+		}
+		if err := im.batch.AddPoint(NewPointFrom(pt)); err != nil {
+			// A point over the limits of the batch is an invalid line.
+			invalid = &LineError{Index: index, Offset: offset, Line: string(trimNewline(line)), Err: err}
+			return false
+		}
+		return true
+	})
+	if invalid != nil {
+		if im.opts.ErrorMode == ImportFailFast {
+			return invalid
+		}
+		im.mu.Lock()
+		im.report.LinesSkipped++
+		im.mu.Unlock()
+	}
+	if im.batch != nil && len(im.batch.Points()) >= im.opts.BatchSize {
+		return im.flush()
+	}
+	return nil
+}
+
+// context applies a comment setting the context of the DML section.
+func (im *importer) context(comment string) {
+	if v, ok := cutComment(comment, "CONTEXT-DATABASE:"); ok {
+		// A new database starts at its default retention policy.
+		im.db, im.rp = v, ""
+	} else if v, ok := cutComment(comment, "CONTEXT-RETENTION-POLICY:"); ok {
+		im.rp = v
+	}
+}
+
+// cutComment returns the value of a "# KEY: value" comment.
+func cutComment(comment, key string) (string, bool) {
+	s := strings.TrimSpace(strings.TrimPrefix(comment, "#"))
+	if !strings.HasPrefix(s, key) {
+		return "", false
+	}
+	return strings.TrimSpace(s[len(key):]), true
+}
+
+// destination returns the database and retention policy the points read are
+// written to.
+func (im *importer) destination() (string, string) {
+	db := im.db
+	if im.opts.Database != "" {
+		db = im.opts.Database
+	}
+	rp := im.rp
+	if im.opts.RetentionPolicy != "" {
+		rp = im.opts.RetentionPolicy
+	}
+	return db, rp
+}
+
+// flush hands the current batch to the writers.
+func (im *importer) flush() error {
+	bp := im.batch
+	if bp == nil {
+		return nil
+	}
+	im.batch = nil
+	select {
+	case im.batches <- bp:
+	case <-im.ctx.Done():
+	}
+	return nil
+}
+
+// writer writes the batches read until there are none left.
+func (im *importer) writer() {
+	defer im.wg.Done()
+	for bp := range im.batches {
+		if im.ctx.Err() != nil {
+			// The import was stopped, the batches left are dropped.
+			continue
+		}
+		var err error
+		if cc, ok := im.c.(ContextClient); ok {
+			err = cc.WriteContext(im.ctx, bp)
+		} else {
+			err = im.c.Write(bp)
+		}
+
+		n := len(bp.Points())
+		im.mu.Lock()
+		if err == nil {
+			im.report.PointsWritten += n
+		} else {
+			im.report.PointsFailed += n
+			im.failed++
+			if im.first == nil {
+				im.first = err
+			}
+			if im.opts.ErrorMode == ImportFailFast {
+				im.cancel()
+			}
+		}
+		im.mu.Unlock()
+	}
+}
+
+// wait waits for the writes sent and returns the report along with the
+// error of the failed writes, if any.
+func (im *importer) wait() (ImportReport, error) {
+	close(im.batches)
+	im.wg.Wait()
+	im.cancel()
+
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	switch {
+	case im.failed == 0:
+		return im.report, nil
+	case im.opts.ErrorMode == ImportFailFast:
+		return im.report, im.first
+	}
+	return im.report, &ImportError{Batches: im.failed, Err: im.first}
+}
+
+// isGzip reports whether b starts like a gzip stream.
+func isGzip(b []byte) bool {
+	return bytes.HasPrefix(b, []byte{0x1f, 0x8b})
+}
@@ -0,0 +1,194 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// importRecorder is a Client that records the statements and the batches
+// written to it by ImportFile.
+type importRecorder struct {
+	mu sync.Mutex
+	destinationRecorder
+	statements []string
+}
+
+func (r *importRecorder) Write(bp BatchPoints) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.destinationRecorder.Write(bp)
+}
+
+func (r *importRecorder) Query(q Query) (*Response, error) {
+	r.statements = append(r.statements, q.Command)
+	return &Response{Results: []Result{{}}}, nil
+}
+
+const testExport = `# INFLUXDB EXPORT: 1677-09-21T00:12:43Z - 2262-04-11T23:47:16Z
+# DDL
+CREATE DATABASE db0 WITH NAME autogen
+CREATE RETENTION POLICY week ON db0 DURATION 1w REPLICATION 1
+# DML
+# CONTEXT-DATABASE:db0
+# CONTEXT-RETENTION-POLICY:autogen
+# writing tsm data
+cpu v=1 1
+cpu v=2 2
+
+cpu v=3 3
+# CONTEXT-RETENTION-POLICY:week
+mem v=4 4
+`
+
+func TestImportFile(t *testing.T) {
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write([]byte(testExport))
+	w.Close()
+
+	for _, tt := range []struct {
+		name  string
+		input []byte
+	}{
+		{name: "plain", input: []byte(testExport)},
+		{name: "gzip", input: gz.Bytes()},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &importRecorder{}
+			report, err := ImportFile(context.Background(), r, bytes.NewReader(tt.input), ImportOptions{BatchSize: 2, DDL: true})
+			if err != nil {
+				t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+			}
+			exp := []string{
+				"db0.autogen ns : cpu v=1 1 cpu v=2 2",
+				"db0.autogen ns : cpu v=3 3",
+				"db0.week ns : mem v=4 4",
+			}
+			if !reflect.DeepEqual(r.writes, exp) {
+				t.Errorf("unexpected writes.\nexpected %q\nactual   %q", exp, r.writes)
+			}
+			stmts := []string{"CREATE DATABASE db0 WITH NAME autogen", "CREATE RETENTION POLICY week ON db0 DURATION 1w REPLICATION 1"}
+			if !reflect.DeepEqual(r.statements, stmts) {
+				t.Errorf("unexpected statements.  expected %q, actual %q", stmts, r.statements)
+			}
+			if report.LinesRead != 14 || report.PointsWritten != 4 || report.LinesSkipped != 0 || report.PointsFailed != 0 {
+				t.Errorf("unexpected report: %+v", report)
+			}
+		})
+	}
+}
+
+func TestImportFile_Options(t *testing.T) {
+	r := &importRecorder{}
+	input := "cpu v=1 1\nmem v=2 2\ndisk v=3 3\ncpu v=4 4"
+	report, err := ImportFile(context.Background(), r, strings.NewReader(input), ImportOptions{
+		Database:    "restored",
+		Precision:   "s",
+		BatchSize:   1,
+		Concurrency: 3,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	sort.Strings(r.writes)
+	exp := []string{"restored. s : cpu v=1 1", "restored. s : cpu v=4 4", "restored. s : disk v=3 3", "restored. s : mem v=2 2"}
+	if !reflect.DeepEqual(r.writes, exp) {
+		t.Errorf("unexpected writes.\nexpected %q\nactual   %q", exp, r.writes)
+	}
+	if report.LinesRead != 4 || report.PointsWritten != 4 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+
+	r = &importRecorder{}
+	input = "# DDL\nCREATE DATABASE db0\n# DML\n# CONTEXT-DATABASE: db0\ncpu v=1 1\n"
+	if _, err := ImportFile(context.Background(), r, strings.NewReader(input), ImportOptions{RetentionPolicy: "rp0"}); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if exp := []string{"db0.rp0 ns : cpu v=1 1"}; !reflect.DeepEqual(r.writes, exp) || len(r.statements) != 0 {
+		t.Errorf("unexpected import: %q, statements %q", r.writes, r.statements)
+	}
+
+	var ce *ConfigError
+	if _, err := ImportFile(context.Background(), r, strings.NewReader("cpu v=1 1\n"), ImportOptions{}); !errors.As(err, &ce) || ce.Field != "Database" {
+		t.Errorf("unexpected error.  expected a *ConfigError for Database, actual %v", err)
+	}
+}
+
+func TestImportFile_Errors(t *testing.T) {
+	input := "# CONTEXT-DATABASE: db0\ncpu v=1 1\ncpu v=\nmem v=2 2\n"
+
+	r := &importRecorder{}
+	report, err := ImportFile(context.Background(), r, strings.NewReader(input), ImportOptions{})
+	var le *LineError
+	if !errors.As(err, &le) || le.Index != 2 || le.Offset != 34 {
+		t.Fatalf("unexpected error.  expected a *LineError of line 2, actual %v", err)
+	}
+	if len(r.writes) != 0 || report.PointsWritten != 0 {
+		t.Errorf("unexpected writes after an invalid line: %q", r.writes)
+	}
+
+	r = &importRecorder{}
+	report, err = ImportFile(context.Background(), r, strings.NewReader(input), ImportOptions{ErrorMode: ImportContinueOnError})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if report.LinesSkipped != 1 || report.PointsWritten != 2 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+
+	input = "# CONTEXT-DATABASE: db0\ncpu v=1 1\n# CONTEXT-DATABASE: db1\ncpu v=2 2\n# CONTEXT-DATABASE: db2\ncpu v=3 3\n"
+	r = &importRecorder{}
+	r.fail = "db1"
+	report, err = ImportFile(context.Background(), r, strings.NewReader(input), ImportOptions{ErrorMode: ImportContinueOnError})
+	var ie *ImportError
+	if !errors.As(err, &ie) || ie.Batches != 1 || !errors.Is(err, errDestination) {
+		t.Fatalf("unexpected error.  expected an *ImportError, actual %v", err)
+	}
+	if report.PointsWritten != 2 || report.PointsFailed != 1 || len(r.writes) != 3 {
+		t.Errorf("unexpected report: %+v, writes %q", report, r.writes)
+	}
+
+	r = &importRecorder{}
+	r.fail = "db1"
+	report, err = ImportFile(context.Background(), r, strings.NewReader(input), ImportOptions{})
+	if err != errDestination {
+		t.Fatalf("unexpected error.  expected %v, actual %v", errDestination, err)
+	}
+	if report.PointsWritten != 1 || report.PointsFailed != 1 || len(r.writes) != 2 {
+		t.Errorf("unexpected report: %+v, writes %q", report, r.writes)
+	}
+}
+
+func TestImportFile_PointOverLimits(t *testing.T) {
+	// The second point has a string over MaxStringFieldLength.
+	input := "# CONTEXT-DATABASE: db0\ncpu v=1 1\ncpu v=\"" + strings.Repeat("x", 70000) + "\" 2\nmem v=3 3\n"
+
+	report, err := ImportFile(context.Background(), &importRecorder{}, strings.NewReader(input), ImportOptions{})
+	var le *LineError
+	var lerr *LimitError
+	if !errors.As(err, &le) || le.Index != 2 || !errors.As(err, &lerr) {
+		t.Fatalf("unexpected error.  expected a *LineError of line 2 over a limit, actual %v", err)
+	}
+	if report.PointsWritten != 0 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+
+	r := &importRecorder{}
+	report, err = ImportFile(context.Background(), r, strings.NewReader(input), ImportOptions{ErrorMode: ImportContinueOnError})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if report.LinesSkipped != 1 || report.PointsWritten != 2 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	if exp := []string{"db0. ns : cpu v=1 1 mem v=3 3"}; !reflect.DeepEqual(r.writes, exp) {
+		t.Errorf("unexpected writes.\nexpected %q\nactual   %q", exp, r.writes)
+	}
+}
@@ -0,0 +1,1247 @@
+package influxtest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+// maxBuckets bounds the intervals of a GROUP BY time() query, as the
+// max-select-buckets setting of InfluxDB does.
+const maxBuckets = 100000
+
+// result is the result of a statement in a response.
+type result struct {
+	StatementID int          `json:"statement_id"`
+	Series      []models.Row `json:"series,omitempty"`
+	Err         string       `json:"error,omitempty"`
+}
+
+// queryContext is what the statements of a query run with.
+type queryContext struct {
+	db, rp, epoch string
+}
+
+// statement is a statement s supports, which exec runs with s.mu held.
+type statement interface {
+	exec(s *Server, qc queryContext) ([]models.Row, error)
+}
+
+func (s *Server) serveQuery(w http.ResponseWriter, r *http.Request) {
+	q := r.FormValue("q")
+	if strings.TrimSpace(q) == "" {
+		httpError(w, http.StatusBadRequest, `missing required parameter "q"`)
+		return
+	}
+	var params map[string]interface{}
+	if raw := r.FormValue("params"); raw != "" {
+		dec := json.NewDecoder(strings.NewReader(raw))
+		dec.UseNumber()
+		if err := dec.Decode(&params); err != nil {
+			httpError(w, http.StatusBadRequest, "error parsing query parameters: "+err.Error())
+			return
+		}
+	}
+	stmts, err := parseQuery(q, params, s.now())
+	if err != nil {
+		httpError(w, http.StatusBadRequest, "error parsing query: "+err.Error())
+		return
+	}
+
+	qc := queryContext{db: r.FormValue("db"), rp: r.FormValue("rp"), epoch: r.FormValue("epoch")}
+	results := make([]result, 0, len(stmts))
+	s.mu.Lock()
+	for i, stmt := range stmts {
+		rows, err := stmt.exec(s, qc)
+		res := result{StatementID: i, Series: rows}
+		if err != nil {
+			res.Err = err.Error()
+		}
+		results = append(results, res)
+		// As InfluxDB, the statements after a failed one are not run.
+		if err != nil {
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Results []result `json:"results"`
+	}{results})
+}
+
+// createDatabase is CREATE DATABASE, whose options are ignored.
+type createDatabase struct{ name string }
+
+func (st createDatabase) exec(s *Server, qc queryContext) ([]models.Row, error) {
+	if s.dbs[st.name] == nil {
+		s.dbs[st.name] = newDatabase()
+	}
+	return nil, nil
+}
+
+// dropDatabase is DROP DATABASE.
+type dropDatabase struct{ name string }
+
+func (st dropDatabase) exec(s *Server, qc queryContext) ([]models.Row, error) {
+	delete(s.dbs, st.name)
+	return nil, nil
+}
+
+// createRetentionPolicy is CREATE RETENTION POLICY, whose options are
+// ignored: the points are kept until the database is dropped.
+type createRetentionPolicy struct{ name, db string }
+
+func (st createRetentionPolicy) exec(s *Server, qc queryContext) ([]models.Row, error) {
+	d := s.dbs[st.db]
+	if d == nil {
+		return nil, fmt.Errorf("database not found: %s", st.db)
+	}
+	if d.rps[st.name] == nil {
+		d.rps[st.name] = make(map[string]*series)
+	}
+	return nil, nil
+}
+
+// showDatabases is SHOW DATABASES.
+type showDatabases struct{}
+
+func (showDatabases) exec(s *Server, qc queryContext) ([]models.Row, error) {
+	row := models.Row{Name: "databases", Columns: []string{"name"}}
+	names := make([]string, 0, len(s.dbs))
+	for name := range s.dbs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		row.Values = append(row.Values, []interface{}{name})
+	}
+	return []models.Row{row}, nil
+}
+
+// selectField is a field of a SELECT: a field or tag, or * for all of
+// them, or a function of a field or of all of them.
+type selectField struct {
+	fn, arg, alias string
+}
+
+// tagCond is a condition on a tag of a WHERE clause.
+type tagCond struct {
+	key, value string
+	not        bool
+}
+
+// fillMode is the fill() of a GROUP BY time() query.
+type fillMode struct {
+	kind  string // "null", "none", "previous" or "value"
+	value interface{}
+}
+
+// selectStatement is a SELECT.
+type selectStatement struct {
+	fields          []selectField
+	db, rp, measure string
+
+	// start and end are the bounds of the times, which they include.
+	start, end int64
+	tags       []tagCond
+
+	interval  int64
+	groupTags []string
+	groupAll  bool
+	fill      fillMode
+}
+
+// storedPoint is a point read by a SELECT.
+type storedPoint struct {
+	t      int64
+	fields models.Fields
+	tags   map[string]string
+}
+
+// group are the points of the series of a SELECT with the same tags of its
+// GROUP BY.
+type group struct {
+	key    string
+	tags   map[string]string
+	points []storedPoint
+}
+
+// selectors are the functions selecting a point, whose time a query without
+// GROUP BY time() returns.
+var selectors = map[string]bool{"first": true, "last": true, "min": true, "max": true}
+
+// functions are the functions supported in a SELECT.
+var functions = map[string]bool{"count": true, "sum": true, "mean": true, "first": true, "last": true, "min": true, "max": true}
+
+func (st *selectStatement) exec(s *Server, qc queryContext) ([]models.Row, error) {
+	db := st.db
+	if db == "" {
+		db = qc.db
+	}
+	if db == "" {
+		return nil, errors.New("database name required")
+	}
+	d := s.dbs[db]
+	if d == nil {
+		return nil, fmt.Errorf("database not found: %s", db)
+	}
+	rp := st.rp
+	if rp == "" {
+		rp = qc.rp
+	}
+	if rp == "" {
+		rp = DefaultRetentionPolicy
+	}
+	if d.rps[rp] == nil {
+		return nil, fmt.Errorf("retention policy not found: %s", rp)
+	}
+
+	var fieldKeys []string
+	for k := range d.fieldTypes[st.measure] {
+		fieldKeys = append(fieldKeys, k)
+	}
+	sort.Strings(fieldKeys)
+
+	tagKeys := make(map[string]bool)
+	groups := make(map[string]*group)
+	var keys []string
+	for _, ser := range sortedSeries(d.rps[rp]) {
+		if ser.name != st.measure {
+			continue
+		}
+		tags := ser.tags.Map()
+		if !st.matches(tags) {
+			continue
+		}
+		for k := range tags {
+			tagKeys[k] = true
+		}
+		gtags := st.groupBy(tags)
+		key := tagsKey(gtags)
+		g := groups[key]
+		if g == nil {
+			g = &group{key: key, tags: gtags}
+			groups[key] = g
+			keys = append(keys, key)
+		}
+		for _, t := range ser.times() {
+			if t >= st.start && t <= st.end {
+				g.points = append(g.points, storedPoint{t: t, fields: ser.points[t], tags: tags})
+			}
+		}
+	}
+	sort.Strings(keys)
+	list := make([]*group, 0, len(keys))
+	for _, key := range keys {
+		g := groups[key]
+		if len(g.points) == 0 {
+			continue
+		}
+		sort.SliceStable(g.points, func(i, j int) bool { return g.points[i].t < g.points[j].t })
+		list = append(list, g)
+	}
+
+	if st.fields[0].fn == "" {
+		return st.raw(list, fieldKeys, tagKeys, qc), nil
+	}
+	return st.aggregate(list, fieldKeys, qc)
+}
+
+// matches reports whether the tags of a series meet the conditions.
+func (st *selectStatement) matches(tags map[string]string) bool {
+	for _, c := range st.tags {
+		if (tags[c.key] == c.value) == c.not {
+			return false
+		}
+	}
+	return true
+}
+
+// groupBy returns the tags of the GROUP BY of a series with tags.
+func (st *selectStatement) groupBy(tags map[string]string) map[string]string {
+	if st.groupAll {
+		return tags
+	}
+	if len(st.groupTags) == 0 {
+		return nil
+	}
+	gtags := make(map[string]string, len(st.groupTags))
+	for _, k := range st.groupTags {
+		gtags[k] = tags[k]
+	}
+	return gtags
+}
+
+// tagsKey returns the key of a group with tags.
+func tagsKey(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteByte(0)
+		b.WriteString(tags[k])
+		b.WriteByte(0)
+	}
+	return b.String()
+}
+
+// raw returns the rows of a SELECT of fields and tags, those of the points
+// with at least one of the fields.
+func (st *selectStatement) raw(groups []*group, fieldKeys []string, tagKeys map[string]bool, qc queryContext) []models.Row {
+	isField := make(map[string]bool, len(fieldKeys))
+	for _, k := range fieldKeys {
+		isField[k] = true
+	}
+	var names, columns []string
+	for _, f := range st.fields {
+		if f.arg != "*" {
+			names = append(names, f.arg)
+			columns = append(columns, f.alias)
+			continue
+		}
+		all := append([]string(nil), fieldKeys...)
+		for k := range tagKeys {
+			if !st.grouped(k) && !isField[k] {
+				all = append(all, k)
+			}
+		}
+		sort.Strings(all)
+		names = append(names, all...)
+		columns = append(columns, all...)
+	}
+	for i, c := range columns {
+		if c == "" {
+			columns[i] = names[i]
+		}
+	}
+
+	var rows []models.Row
+	for _, g := range groups {
+		row := models.Row{Name: st.measure, Tags: nilIfEmpty(g.tags), Columns: append([]string{"time"}, columns...)}
+		for _, p := range g.points {
+			values := []interface{}{formatTime(p.t, qc.epoch)}
+			found := false
+			for _, name := range names {
+				if v, ok := p.fields[name]; ok {
+					values = append(values, v)
+					found = true
+				} else if v, ok := p.tags[name]; ok {
+					values = append(values, v)
+				} else {
+					values = append(values, nil)
+				}
+			}
+			if found {
+				row.Values = append(row.Values, values)
+			}
+		}
+		if len(row.Values) > 0 {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// grouped reports whether the tag key is of the GROUP BY.
+func (st *selectStatement) grouped(key string) bool {
+	if st.groupAll {
+		return true
+	}
+	for _, k := range st.groupTags {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// aggregateColumn is a function of a field in the columns of a SELECT.
+type aggregateColumn struct {
+	fn, field, name string
+}
+
+// aggregate returns the rows of a SELECT of functions, a row per interval
+// of its GROUP BY time(), or one if it has none.
+func (st *selectStatement) aggregate(groups []*group, fieldKeys []string, qc queryContext) ([]models.Row, error) {
+	var cols []aggregateColumn
+	for _, f := range st.fields {
+		if f.arg != "*" {
+			name := f.alias
+			if name == "" {
+				name = f.fn
+			}
+			cols = append(cols, aggregateColumn{fn: f.fn, field: f.arg, name: name})
+			continue
+		}
+		for _, k := range fieldKeys {
+			cols = append(cols, aggregateColumn{fn: f.fn, field: k, name: f.fn + "_" + k})
+		}
+	}
+	if len(cols) == 0 || len(groups) == 0 {
+		return nil, nil
+	}
+	columns := []string{"time"}
+	for _, c := range cols {
+		columns = append(columns, c.name)
+	}
+
+	// The intervals are those of the time range, or of the points if it
+	// has no bounds.
+	lo, hi := st.start, st.end
+	if st.interval > 0 {
+		if lo == math.MinInt64 || hi == math.MaxInt64 {
+			first, last := int64(math.MaxInt64), int64(math.MinInt64)
+			for _, g := range groups {
+				if t := g.points[0].t; t < first {
+					first = t
+				}
+				if t := g.points[len(g.points)-1].t; t > last {
+					last = t
+				}
+			}
+			if lo == math.MinInt64 {
+				lo = first
+			}
+			if hi == math.MaxInt64 {
+				hi = last
+			}
+		}
+		lo = floorDiv(lo, st.interval) * st.interval
+		if hi < lo {
+			return nil, nil
+		}
+		if (hi-lo)/st.interval >= maxBuckets {
+			return nil, fmt.Errorf("max-select-buckets limit exceeded: (%d/%d)", (hi-lo)/st.interval+1, maxBuckets)
+		}
+	}
+
+	var rows []models.Row
+	for _, g := range groups {
+		row := models.Row{Name: st.measure, Tags: nilIfEmpty(g.tags), Columns: columns}
+		if st.interval == 0 {
+			t := lo
+			if lo == math.MinInt64 {
+				t = 0
+			}
+			values := []interface{}{nil}
+			for _, c := range cols {
+				v, vt := aggregate(c.fn, g.points, c.field)
+				if len(cols) == 1 && selectors[c.fn] && v != nil {
+					t = vt
+				}
+				values = append(values, v)
+			}
+			values[0] = formatTime(t, qc.epoch)
+			row.Values = append(row.Values, values)
+			rows = append(rows, row)
+			continue
+		}
+
+		i := 0
+		var previous []interface{}
+		for t := lo; t <= hi; t += st.interval {
+			j := i
+			for j < len(g.points) && g.points[j].t < t+st.interval {
+				j++
+			}
+			points := g.points[i:j]
+			i = j
+			if len(points) == 0 && st.fill.kind == "none" {
+				continue
+			}
+			values := []interface{}{formatTime(t, qc.epoch)}
+			for k, c := range cols {
+				v, _ := aggregate(c.fn, points, c.field)
+				if v == nil {
+					switch st.fill.kind {
+					case "value":
+						v = st.fill.value
+					case "previous":
+						if previous != nil {
+							v = previous[k+1]
+						}
+					}
+				}
+				values = append(values, v)
+			}
+			previous = values
+			row.Values = append(row.Values, values)
+		}
+		if len(row.Values) > 0 {
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+// aggregate returns the value of the function fn of field over points,
+// sorted by time, and the time of the point it selected, if any. It is nil
+// if no point has a value for it, but for count which is then 0.
+func aggregate(fn string, points []storedPoint, field string) (interface{}, int64) {
+	type value struct {
+		v interface{}
+		t int64
+	}
+	var values []value
+	for _, p := range points {
+		if v, ok := p.fields[field]; ok {
+			values = append(values, value{v, p.t})
+		}
+	}
+
+	switch fn {
+	case "count":
+		return int64(len(values)), 0
+	case "first":
+		if len(values) > 0 {
+			return values[0].v, values[0].t
+		}
+	case "last":
+		if len(values) > 0 {
+			return values[len(values)-1].v, values[len(values)-1].t
+		}
+	case "min", "max":
+		var best *value
+		var bestF float64
+		for i := range values {
+			f, ok := toFloat(values[i].v)
+			if ok && (best == nil || (fn == "min" && f < bestF) || (fn == "max" && f > bestF)) {
+				best, bestF = &values[i], f
+			}
+		}
+		if best != nil {
+			return best.v, best.t
+		}
+	case "sum", "mean":
+		var (
+			n           int
+			f           float64
+			i           int64
+			u           uint64
+			ints, uints = true, true
+		)
+		for _, v := range values {
+			x, ok := toFloat(v.v)
+			if !ok {
+				continue
+			}
+			n++
+			f += x
+			switch v := v.v.(type) {
+			case int64:
+				i += v
+				uints = false
+			case uint64:
+				u += v
+				ints = false
+			default:
+				ints, uints = false, false
+			}
+		}
+		switch {
+		case n == 0:
+		case fn == "mean":
+			return f / float64(n), 0
+		case ints:
+			return i, 0
+		case uints:
+			return u, 0
+		default:
+			return f, 0
+		}
+	}
+	return nil, 0
+}
+
+// toFloat returns the numeric field value v as a float64.
+func toFloat(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// floorDiv returns a/b rounded down.
+func floorDiv(a, b int64) int64 {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
+
+func nilIfEmpty(tags map[string]string) map[string]string {
+	if len(tags) == 0 {
+		return nil
+	}
+	return tags
+}
+
+// formatTime returns the time t, in ns, as a response with epoch has it.
+func formatTime(t int64, epoch string) interface{} {
+	switch epoch {
+	case "n", "ns":
+		return t
+	case "u", "µ":
+		return t / int64(time.Microsecond)
+	case "ms":
+		return t / int64(time.Millisecond)
+	case "s":
+		return t / int64(time.Second)
+	case "m":
+		return t / int64(time.Minute)
+	case "h":
+		return t / int64(time.Hour)
+	}
+	return time.Unix(0, t).UTC().Format(time.RFC3339Nano)
+}
+
+// tokenKind is the kind of a token of a query.
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokDuration
+	tokParam
+	tokOp
+)
+
+// token is a token of a query. s is the identifier, the string, the number,
+// the operator or the name of the parameter, and d the duration in ns.
+type token struct {
+	kind   tokenKind
+	s      string
+	quoted bool
+	d      int64
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "EOF"
+	case tokString:
+		return strconv.Quote(t.s)
+	case tokParam:
+		return "$" + t.s
+	}
+	return t.s
+}
+
+// durationUnits are the units of the duration literals.
+var durationUnits = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"u":  time.Microsecond,
+	"µ":  time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+	"d":  24 * time.Hour,
+	"w":  7 * 24 * time.Hour,
+}
+
+// lex splits q into tokens.
+func lex(q string) ([]token, error) {
+	var toks []token
+	rs := []rune(q)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '-' && i+1 < len(rs) && rs[i+1] == '-':
+			for i < len(rs) && rs[i] != '\n' {
+				i++
+			}
+		case isIdentStart(r):
+			j := i
+			for j < len(rs) && isIdentChar(rs[j]) {
+				j++
+			}
+			toks = append(toks, token{kind: tokIdent, s: string(rs[i:j])})
+			i = j
+		case r == '$':
+			j := i + 1
+			for j < len(rs) && isIdentChar(rs[j]) {
+				j++
+			}
+			if j == i+1 {
+				return nil, errors.New("bound parameter without a name")
+			}
+			toks = append(toks, token{kind: tokParam, s: string(rs[i+1 : j])})
+			i = j
+		case r == '"' || r == '\'':
+			s, n, err := lexQuoted(rs[i:])
+			if err != nil {
+				return nil, err
+			}
+			if r == '"' {
+				toks = append(toks, token{kind: tokIdent, s: s, quoted: true})
+			} else {
+				toks = append(toks, token{kind: tokString, s: s})
+			}
+			i += n
+		case r >= '0' && r <= '9':
+			j := i
+			for j < len(rs) && (rs[j] >= '0' && rs[j] <= '9' || rs[j] == '.') {
+				j++
+			}
+			num := string(rs[i:j])
+			k := j
+			for k < len(rs) && (unicode.IsLetter(rs[k])) {
+				k++
+			}
+			if k == j {
+				toks = append(toks, token{kind: tokNumber, s: num})
+				i = j
+				continue
+			}
+			unit, ok := durationUnits[string(rs[j:k])]
+			n, err := strconv.ParseInt(num, 10, 64)
+			if !ok || err != nil {
+				return nil, fmt.Errorf("invalid duration %s", string(rs[i:k]))
+			}
+			toks = append(toks, token{kind: tokDuration, s: string(rs[i:k]), d: n * int64(unit)})
+			i = k
+		default:
+			op := string(r)
+			if i+1 < len(rs) {
+				switch two := string(rs[i : i+2]); two {
+				case "!=", "<>", "<=", ">=", "=~", "!~":
+					op = two
+				}
+			}
+			if !strings.Contains("(),;*.=<>+-!~", string(r)) {
+				return nil, fmt.Errorf("unexpected %q", r)
+			}
+			toks = append(toks, token{kind: tokOp, s: op})
+			i += len([]rune(op))
+		}
+	}
+	return toks, nil
+}
+
+func isIdentStart(r rune) bool { return r == '_' || unicode.IsLetter(r) }
+
+func isIdentChar(r rune) bool { return isIdentStart(r) || unicode.IsDigit(r) }
+
+// lexQuoted returns the string quoted at the start of rs, unescaped, and the
+// number of runes it spans.
+func lexQuoted(rs []rune) (string, int, error) {
+	quote := rs[0]
+	var b strings.Builder
+	for i := 1; i < len(rs); i++ {
+		switch rs[i] {
+		case '\\':
+			if i+1 < len(rs) {
+				i++
+				switch rs[i] {
+				case 'n':
+					b.WriteRune('\n')
+				default:
+					b.WriteRune(rs[i])
+				}
+			}
+		case quote:
+			return b.String(), i + 1, nil
+		default:
+			b.WriteRune(rs[i])
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated %c", quote)
+}
+
+// bind replaces the bound parameters of toks with their values in params.
+func bind(toks []token, params map[string]interface{}) error {
+	for i, t := range toks {
+		if t.kind != tokParam {
+			continue
+		}
+		v, ok := params[t.s]
+		if !ok {
+			return fmt.Errorf("missing parameter: %s", t.s)
+		}
+		switch v := v.(type) {
+		case string:
+			toks[i] = token{kind: tokString, s: v}
+		case json.Number:
+			toks[i] = token{kind: tokNumber, s: v.String()}
+		case bool:
+			toks[i] = token{kind: tokIdent, s: strconv.FormatBool(v)}
+		default:
+			return fmt.Errorf("unsupported value of parameter %s: %v", t.s, v)
+		}
+	}
+	return nil
+}
+
+// parseQuery returns the statements of q, whose now() is now.
+func parseQuery(q string, params map[string]interface{}, now time.Time) ([]statement, error) {
+	toks, err := lex(q)
+	if err != nil {
+		return nil, err
+	}
+	if err := bind(toks, params); err != nil {
+		return nil, err
+	}
+	var stmts []statement
+	for len(toks) > 0 {
+		end := 0
+		for end < len(toks) && !(toks[end].kind == tokOp && toks[end].s == ";") {
+			end++
+		}
+		if end > 0 {
+			p := &parser{toks: toks[:end], now: now}
+			stmt, err := p.statement()
+			if err != nil {
+				return nil, err
+			}
+			stmts = append(stmts, stmt)
+		}
+		if end < len(toks) {
+			end++
+		}
+		toks = toks[end:]
+	}
+	if len(stmts) == 0 {
+		return nil, errors.New("no statement")
+	}
+	return stmts, nil
+}
+
+// parser parses the tokens of a statement.
+type parser struct {
+	toks []token
+	pos  int
+	now  time.Time
+}
+
+func (p *parser) peek() token {
+	if p.pos < len(p.toks) {
+		return p.toks[p.pos]
+	}
+	return token{kind: tokEOF}
+}
+
+func (p *parser) next() token {
+	t := p.peek()
+	if p.pos < len(p.toks) {
+		p.pos++
+	}
+	return t
+}
+
+func isKeyword(t token, kw string) bool {
+	return t.kind == tokIdent && !t.quoted && strings.EqualFold(t.s, kw)
+}
+
+// accept consumes the keyword kw if it is next.
+func (p *parser) accept(kw string) bool {
+	if isKeyword(p.peek(), kw) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(kw string) error {
+	if !p.accept(kw) {
+		return fmt.Errorf("found %s, expected %s", p.peek(), kw)
+	}
+	return nil
+}
+
+// acceptOp consumes the operator op if it is next.
+func (p *parser) acceptOp(op string) bool {
+	if t := p.peek(); t.kind == tokOp && t.s == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectOp(op string) error {
+	if !p.acceptOp(op) {
+		return fmt.Errorf("found %s, expected %s", p.peek(), op)
+	}
+	return nil
+}
+
+func (p *parser) ident() (string, error) {
+	t := p.next()
+	if t.kind != tokIdent {
+		return "", fmt.Errorf("found %s, expected identifier", t)
+	}
+	return t.s, nil
+}
+
+func (p *parser) end() error {
+	if t := p.peek(); t.kind != tokEOF {
+		return fmt.Errorf("found %s, expected ;", t)
+	}
+	return nil
+}
+
+// statement parses a statement, of which the options influxtest ignores are
+// skipped.
+func (p *parser) statement() (statement, error) {
+	first := p.peek()
+	switch {
+	case p.accept("SELECT"):
+		return p.selectStatement()
+	case p.accept("CREATE"):
+		if p.accept("DATABASE") {
+			name, err := p.ident()
+			p.pos = len(p.toks)
+			return createDatabase{name}, err
+		}
+		if p.accept("RETENTION") {
+			if err := p.expect("POLICY"); err != nil {
+				return nil, err
+			}
+			name, err := p.ident()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("ON"); err != nil {
+				return nil, err
+			}
+			db, err := p.ident()
+			return createRetentionPolicy{name: name, db: db}, err
+		}
+	case p.accept("DROP"):
+		if p.accept("DATABASE") {
+			name, err := p.ident()
+			if err == nil {
+				err = p.end()
+			}
+			return dropDatabase{name}, err
+		}
+	case p.accept("SHOW"):
+		if p.accept("DATABASES") {
+			return showDatabases{}, p.end()
+		}
+	}
+	return nil, fmt.Errorf("statement not supported by influxtest, starting with %s %s", first, p.peek())
+}
+
+func (p *parser) selectStatement() (*selectStatement, error) {
+	st := &selectStatement{start: math.MinInt64, end: math.MaxInt64, fill: fillMode{kind: "null"}}
+	for {
+		f, err := p.selectField()
+		if err != nil {
+			return nil, err
+		}
+		st.fields = append(st.fields, f)
+		if !p.acceptOp(",") {
+			break
+		}
+	}
+	aggregates := 0
+	for _, f := range st.fields {
+		if f.fn != "" {
+			aggregates++
+		}
+	}
+	if aggregates > 0 && aggregates < len(st.fields) {
+		return nil, errors.New("mixing aggregate and non-aggregate queries is not supported")
+	}
+
+	if err := p.expect("FROM"); err != nil {
+		return nil, err
+	}
+	var parts []string
+	for {
+		part, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, part)
+		if !p.acceptOp(".") {
+			break
+		}
+	}
+	switch len(parts) {
+	case 1:
+		st.measure = parts[0]
+	case 2:
+		st.rp, st.measure = parts[0], parts[1]
+	case 3:
+		st.db, st.rp, st.measure = parts[0], parts[1], parts[2]
+	default:
+		return nil, fmt.Errorf("invalid measurement %s", strings.Join(parts, "."))
+	}
+
+	if p.accept("WHERE") {
+		if err := p.where(st); err != nil {
+			return nil, err
+		}
+	}
+	if p.accept("GROUP") {
+		if err := p.expect("BY"); err != nil {
+			return nil, err
+		}
+		if err := p.groupBy(st); err != nil {
+			return nil, err
+		}
+	}
+	if p.accept("fill") {
+		if err := p.fill(st); err != nil {
+			return nil, err
+		}
+	}
+	if st.interval > 0 && aggregates == 0 {
+		return nil, errors.New("GROUP BY requires at least one aggregate function")
+	}
+	return st, p.end()
+}
+
+func (p *parser) selectField() (selectField, error) {
+	var f selectField
+	if p.acceptOp("*") {
+		f.arg = "*"
+		return f, nil
+	}
+	t := p.next()
+	if t.kind != tokIdent {
+		return f, fmt.Errorf("found %s, expected field", t)
+	}
+	if !t.quoted && p.acceptOp("(") {
+		f.fn = strings.ToLower(t.s)
+		if !functions[f.fn] {
+			return f, fmt.Errorf("function %s not supported by influxtest", t.s)
+		}
+		if p.acceptOp("*") {
+			f.arg = "*"
+		} else {
+			arg, err := p.ident()
+			if err != nil {
+				return f, err
+			}
+			f.arg = arg
+		}
+		if err := p.expectOp(")"); err != nil {
+			return f, err
+		}
+	} else {
+		f.arg = t.s
+	}
+	if p.accept("AS") {
+		alias, err := p.ident()
+		if err != nil {
+			return f, err
+		}
+		f.alias = alias
+	}
+	return f, nil
+}
+
+// where parses conditions on time and tags joined by AND.
+func (p *parser) where(st *selectStatement) error {
+	for {
+		key, err := p.ident()
+		if err != nil {
+			return err
+		}
+		op := p.next()
+		if op.kind != tokOp {
+			return fmt.Errorf("found %s, expected operator", op)
+		}
+		if strings.EqualFold(key, "time") {
+			t, err := p.timeExpr()
+			if err != nil {
+				return err
+			}
+			switch op.s {
+			case ">":
+				t++
+				fallthrough
+			case ">=":
+				if t > st.start {
+					st.start = t
+				}
+			case "<":
+				t--
+				fallthrough
+			case "<=":
+				if t < st.end {
+					st.end = t
+				}
+			case "=":
+				if t > st.start {
+					st.start = t
+				}
+				if t < st.end {
+					st.end = t
+				}
+			default:
+				return fmt.Errorf("invalid operator %s on time", op.s)
+			}
+		} else {
+			v := p.next()
+			if v.kind != tokString {
+				return fmt.Errorf("found %s, expected string: influxtest supports conditions on time and tags only", v)
+			}
+			switch op.s {
+			case "=":
+				st.tags = append(st.tags, tagCond{key: key, value: v.s})
+			case "!=", "<>":
+				st.tags = append(st.tags, tagCond{key: key, value: v.s, not: true})
+			default:
+				return fmt.Errorf("operator %s on tags not supported by influxtest", op.s)
+			}
+		}
+		if p.accept("OR") {
+			return errors.New("OR not supported by influxtest")
+		}
+		if !p.accept("AND") {
+			return nil
+		}
+	}
+}
+
+// timeLayouts are the layouts of the time strings of a WHERE clause.
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999", "2006-01-02"}
+
+// timeExpr parses a time, in ns, as a duration since the epoch, RFC3339 or
+// now(), plus or minus durations.
+func (p *parser) timeExpr() (int64, error) {
+	var t int64
+	neg := p.acceptOp("-")
+	tok := p.next()
+	switch {
+	case isKeyword(tok, "now") && !neg:
+		if err := p.expectOp("("); err != nil {
+			return 0, err
+		}
+		if err := p.expectOp(")"); err != nil {
+			return 0, err
+		}
+		t = p.now.UnixNano()
+	case tok.kind == tokNumber:
+		n, err := strconv.ParseInt(tok.s, 10, 64)
+		if err != nil {
+			f, ferr := strconv.ParseFloat(tok.s, 64)
+			if ferr != nil {
+				return 0, err
+			}
+			n = int64(f)
+		}
+		t = n
+		if neg {
+			t = -n
+		}
+	case tok.kind == tokDuration:
+		t = tok.d
+		if neg {
+			t = -t
+		}
+	case tok.kind == tokString && !neg:
+		var parsed time.Time
+		var err error
+		for _, layout := range timeLayouts {
+			if parsed, err = time.Parse(layout, tok.s); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			return 0, fmt.Errorf("invalid time %s", tok)
+		}
+		t = parsed.UnixNano()
+	default:
+		return 0, fmt.Errorf("found %s, expected time", tok)
+	}
+	for {
+		sign := int64(1)
+		switch {
+		case p.acceptOp("+"):
+		case p.acceptOp("-"):
+			sign = -1
+		default:
+			return t, nil
+		}
+		d := p.next()
+		if d.kind != tokDuration {
+			return 0, fmt.Errorf("found %s, expected duration", d)
+		}
+		t += sign * d.d
+	}
+}
+
+func (p *parser) groupBy(st *selectStatement) error {
+	for {
+		switch t := p.peek(); {
+		case p.acceptOp("*"):
+			st.groupAll = true
+		case isKeyword(t, "time") && p.pos+1 < len(p.toks) && p.toks[p.pos+1].s == "(":
+			p.pos += 2
+			d := p.next()
+			if d.kind != tokDuration || d.d <= 0 {
+				return fmt.Errorf("found %s, expected duration", d)
+			}
+			st.interval = d.d
+			if err := p.expectOp(")"); err != nil {
+				return err
+			}
+		default:
+			key, err := p.ident()
+			if err != nil {
+				return err
+			}
+			st.groupTags = append(st.groupTags, key)
+		}
+		if !p.acceptOp(",") {
+			return nil
+		}
+	}
+}
+
+func (p *parser) fill(st *selectStatement) error {
+	if err := p.expectOp("("); err != nil {
+		return err
+	}
+	neg := p.acceptOp("-")
+	switch t := p.next(); {
+	case !neg && (isKeyword(t, "null") || isKeyword(t, "none") || isKeyword(t, "previous")):
+		st.fill.kind = strings.ToLower(t.s)
+	case t.kind == tokNumber:
+		s := t.s
+		if neg {
+			s = "-" + s
+		}
+		st.fill.kind = "value"
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			st.fill.value = n
+		} else if f, err := strconv.ParseFloat(s, 64); err == nil {
+			st.fill.value = f
+		} else {
+			return err
+		}
+	default:
+		return fmt.Errorf("found %s, expected fill option", t)
+	}
+	return p.expectOp(")")
+}
@@ -0,0 +1,221 @@
+package influxtest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	client "github.com/influxdata/influxdb1-client/v2"
+	"github.com/influxdata/influxdb1-client/v2/clienttest"
+)
+
+// newQueryServer returns a server whose db0 has cpu points of hosts a and b
+// every 10s from 0s to 50s, of value the second.
+func newQueryServer(t *testing.T) (*Server, client.Client) {
+	t.Helper()
+	s := NewServer(Options{Databases: []string{"db0"}})
+	t.Cleanup(s.Close)
+	c := newTestClient(t, s, client.HTTPConfig{})
+	var lines []string
+	for i := 0; i < 60; i += 10 {
+		lines = append(lines, fmt.Sprintf("cpu,host=a value=%d %d", i, i), fmt.Sprintf("cpu,host=b value=%d,idle=1i %d", i+1, i))
+	}
+	if err := writeLines(c, "db0", "s", lines...); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	return s, c
+}
+
+// query returns the string of the values of the rows of the statements of
+// command, with the tags of every row, and the error of the first failed
+// statement.
+func query(t *testing.T, c client.Client, command string) (string, error) {
+	t.Helper()
+	q := client.NewQuery(command, "db0", "s")
+	resp, err := c.Query(q)
+	if err != nil {
+		return "", err
+	}
+	var rows []string
+	for _, r := range resp.Results {
+		for _, row := range r.Series {
+			var b strings.Builder
+			if len(row.Tags) > 0 {
+				fmt.Fprintf(&b, "%v ", row.Tags)
+			}
+			fmt.Fprintf(&b, "%v %v", row.Columns, row.Values)
+			rows = append(rows, b.String())
+		}
+	}
+	return strings.Join(rows, "; "), resp.Error()
+}
+
+func TestServer_QuerySelect(t *testing.T) {
+	_, c := newQueryServer(t)
+	for _, tt := range []struct {
+		command, exp string
+	}{
+		{
+			command: "SELECT * FROM cpu WHERE time >= 10s AND time < 30s",
+			exp:     "[time host idle value] [[10 a <nil> 10] [10 b 1 11] [20 a <nil> 20] [20 b 1 21]]",
+		},
+		{
+			command: `SELECT "value" FROM "db0"."autogen"."cpu" WHERE host = 'b' AND time <= 10000000000`,
+			exp:     "[time value] [[0 1] [10 11]]",
+		},
+		{
+			command: "SELECT idle AS i FROM cpu WHERE time = 20s",
+			exp:     "[time i] [[20 1]]",
+		},
+		{
+			command: "SELECT value FROM cpu WHERE host != 'b' AND time > 30s GROUP BY host",
+			exp:     "map[host:a] [time value] [[40 40] [50 50]]",
+		},
+		{
+			command: "SELECT count(value), sum(value), mean(value) FROM cpu",
+			exp:     "[time count sum mean] [[0 12 306 25.5]]",
+		},
+		{
+			command: "SELECT max(value) FROM cpu WHERE host = 'a'",
+			exp:     "[time max] [[50 50]]",
+		},
+		{
+			command: "SELECT count(*) FROM cpu WHERE time >= 20s GROUP BY *",
+			exp:     "map[host:a] [time count_idle count_value] [[20 0 4]]; map[host:b] [time count_idle count_value] [[20 4 4]]",
+		},
+		{
+			command: "SELECT mean(value) FROM cpu WHERE host = 'a' AND time >= 0s AND time < 60s GROUP BY time(20s)",
+			exp:     "[time mean] [[0 5] [20 25] [40 45]]",
+		},
+		{
+			command: "SELECT last(value) FROM cpu WHERE host = 'a' AND time >= 40s AND time < 80s GROUP BY time(10s) fill(previous)",
+			exp:     "[time last] [[40 40] [50 50] [60 50] [70 50]]",
+		},
+		{
+			command: "SELECT count(value) FROM cpu WHERE time >= 50s AND time < 70s GROUP BY time(10s), host fill(none)",
+			exp:     "map[host:a] [time count] [[50 1]]; map[host:b] [time count] [[50 1]]",
+		},
+		{
+			command: "SELECT first(value) FROM cpu WHERE time >= 50s AND time < 70s GROUP BY time(10s) fill(-1)",
+			exp:     "[time first] [[50 50] [60 -1]]",
+		},
+		{
+			command: "SELECT * FROM cpu WHERE time > 1h",
+			exp:     "",
+		},
+		{
+			command: "CREATE DATABASE db1; SHOW DATABASES; DROP DATABASE db1",
+			exp:     "[name] [[db0] [db1]]",
+		},
+	} {
+		rows, err := query(t, c, tt.command)
+		if err != nil {
+			t.Errorf("%s: unexpected error.  expected %v, actual %v", tt.command, nil, err)
+		}
+		if rows != tt.exp {
+			t.Errorf("%s: unexpected rows.\nexpected %v\nactual   %v", tt.command, tt.exp, rows)
+		}
+	}
+}
+
+func TestServer_QueryTimes(t *testing.T) {
+	now := time.Unix(40, 0)
+	s := NewServer(Options{Databases: []string{"db0"}, Now: func() time.Time { return now }})
+	defer s.Close()
+	c := newTestClient(t, s, client.HTTPConfig{})
+	if err := writeLines(c, "db0", "s", "cpu value=1 10", "cpu value=2 30", "cpu value=3"); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+
+	for _, tt := range []struct {
+		command, epoch string
+		params         map[string]interface{}
+		exp            interface{}
+	}{
+		{command: "SELECT value FROM cpu WHERE time > now() - 15s", epoch: "s", exp: json.Number("30")},
+		{command: "SELECT value FROM cpu WHERE time >= '1970-01-01T00:00:30Z'", epoch: "ms", exp: json.Number("30000")},
+		{command: "SELECT value FROM cpu WHERE time >= $start", params: map[string]interface{}{"start": 30 * int64(time.Second)}, exp: "1970-01-01T00:00:30Z"},
+	} {
+		q := client.NewQuery(tt.command, "db0", tt.epoch)
+		q.Parameters = tt.params
+		resp, err := c.Query(q)
+		if err == nil {
+			err = resp.Error()
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error.  expected %v, actual %v", tt.command, nil, err)
+		}
+		values := resp.Results[0].Series[0].Values
+		if len(values) != 2 || values[0][0] != tt.exp {
+			t.Errorf("%s: unexpected values.  expected %v first of 2, actual %v", tt.command, tt.exp, values)
+		}
+	}
+}
+
+func TestServer_QueryErrors(t *testing.T) {
+	_, c := newQueryServer(t)
+	for _, tt := range []struct {
+		command, err string
+	}{
+		{"DELETE FROM cpu", "not supported"},
+		{"SELECT value, count(value) FROM cpu", "mixing aggregate"},
+		{"SELECT value FROM cpu GROUP BY time(1m)", "aggregate function"},
+		{"SELECT value FROM cpu WHERE value > 1", "conditions on time and tags"},
+		{"SELECT value FROM cpu WHERE host = 'a' OR host = 'b'", "OR"},
+		{"SELECT mode(value) FROM cpu", "mode"},
+		{"SELECT value FROM db9.autogen.cpu", "database not found: db9"},
+		{"SELECT value FROM rp9.cpu", "retention policy not found: rp9"},
+		{"SELECT count(value) FROM cpu WHERE time >= 0s AND time < 1000h GROUP BY time(1s)", "max-select-buckets"},
+	} {
+		if _, err := query(t, c, tt.command); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: unexpected error.  expected %q, actual %v", tt.command, tt.err, err)
+		}
+	}
+}
+
+func TestServer_RetentionPolicy(t *testing.T) {
+	s, c := newQueryServer(t)
+	if _, err := query(t, c, "CREATE RETENTION POLICY short ON db0 DURATION 1h REPLICATION 1"); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	bp, _ := client.NewBatchPoints(client.BatchPointsConfig{Database: "db0", RetentionPolicy: "short"})
+	p, _ := client.NewPoint("mem", nil, map[string]interface{}{"used": 1.5}, time.Unix(0, 5))
+	bp.AddPoint(p)
+	if err := c.Write(bp); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+
+	exp := []string{"mem used=1.5 5"}
+	if lines := pointStrings(s, "db0", "short"); !reflect.DeepEqual(lines, exp) {
+		t.Errorf("unexpected points.  expected %v, actual %v", exp, lines)
+	}
+	if rows, _ := query(t, c, "SELECT * FROM mem"); rows != "" {
+		t.Errorf("unexpected rows of the default retention policy: %v", rows)
+	}
+	if rows, _ := query(t, c, "SELECT * FROM short.mem"); rows != "[time used] [[0 1.5]]" {
+		t.Errorf("unexpected rows of short: %v", rows)
+	}
+}
+
+func TestServer_WaitForPoints(t *testing.T) {
+	s, c := newQueryServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := clienttest.WaitForPoints(ctx, c, "db0", clienttest.VerifySpec{
+		Measurement: "cpu",
+		Count:       12,
+		Times:       []time.Time{time.Unix(50, 0)},
+		TagSets:     []map[string]string{{"host": "a"}, {"host": "b"}},
+	})
+	if err != nil {
+		t.Errorf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if n := len(s.Points("db0", "")); n != 12 {
+		t.Errorf("unexpected points.  expected %v, actual %v", 12, n)
+	}
+}
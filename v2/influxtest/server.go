@@ -0,0 +1,476 @@
+// Package influxtest provides an in-memory server emulating enough of the
+// InfluxDB 1.x HTTP API to run integration tests of code using the client
+// without a running InfluxDB.
+//
+// The server answers /ping, stores the points written to /write, and
+// answers the queries to /query it supports: CREATE DATABASE, DROP
+// DATABASE, CREATE RETENTION POLICY, SHOW DATABASES, and SELECT of fields,
+// tags or the functions COUNT, SUM, MEAN, MIN, MAX, FIRST and LAST, with a
+// WHERE clause of conditions on time and tags joined by AND, and GROUP BY
+// time(), tags or *, with fill(). Any other statement fails to parse. Faults
+// such as latency and error responses can be injected per endpoint, see
+// Server.Inject.
+package influxtest // import "github.com/influxdata/influxdb1-client/v2/influxtest"
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+// DefaultVersion is the version a Server reports by default.
+const DefaultVersion = "1.8.10"
+
+// DefaultRetentionPolicy is the retention policy of the databases, which the
+// writes and queries without one use.
+const DefaultRetentionPolicy = "autogen"
+
+// The endpoints of a Server, to inject faults into.
+const (
+	PingEndpoint  = "/ping"
+	WriteEndpoint = "/write"
+	QueryEndpoint = "/query"
+)
+
+// Options is the config data needed to create a Server.
+type Options struct {
+	// Databases are created when the server starts.
+	Databases []string
+
+	// Version is the version of InfluxDB reported in the
+	// X-Influxdb-Version header, defaults to DefaultVersion.
+	Version string
+
+	// Now, if set, returns the time of the points written without one and
+	// of now() in queries, defaults to time.Now.
+	Now func() time.Time
+}
+
+// Fault is what a Server does to a request to an endpoint in place of, or
+// before, answering it, see Server.Inject.
+type Fault struct {
+	// Latency delays the request.
+	Latency time.Duration
+
+	// StatusCode, if set, is the status the request is answered with, with
+	// an error of Message, or the text of the status if Message is empty.
+	// The request is otherwise not served, so a write stores nothing.
+	StatusCode int
+	Message    string
+
+	// Drop closes the connection of the request without answering it.
+	Drop bool
+
+	// Count is the number of requests the fault applies to, every request
+	// until ClearFaults if 0.
+	Count int
+}
+
+// Server is an in-memory InfluxDB listening on a local HTTP address. It is
+// safe for concurrent use.
+type Server struct {
+	// URL is the base URL of the server, of the form http://ipaddr:port,
+	// to use as client.HTTPConfig.Addr.
+	URL string
+
+	ts      *httptest.Server
+	version string
+	now     func() time.Time
+
+	mu       sync.Mutex
+	dbs      map[string]*database
+	faults   map[string][]*Fault
+	requests map[string]int
+	nextID   int
+}
+
+// database is the data of a database.
+type database struct {
+	rps map[string]map[string]*series
+
+	// fieldTypes are the types of the fields of the measurements, which the
+	// later writes must match.
+	fieldTypes map[string]map[string]string
+}
+
+// series are the points of a series, by time.
+type series struct {
+	key    string
+	name   string
+	tags   models.Tags
+	points map[int64]models.Fields
+}
+
+func newDatabase() *database {
+	return &database{
+		rps:        map[string]map[string]*series{DefaultRetentionPolicy: {}},
+		fieldTypes: make(map[string]map[string]string),
+	}
+}
+
+// NewServer starts and returns a Server, which must be closed once done.
+func NewServer(opts Options) *Server {
+	s := &Server{
+		version:  opts.Version,
+		now:      opts.Now,
+		dbs:      make(map[string]*database),
+		faults:   make(map[string][]*Fault),
+		requests: make(map[string]int),
+	}
+	if s.version == "" {
+		s.version = DefaultVersion
+	}
+	if s.now == nil {
+		s.now = time.Now
+	}
+	for _, db := range opts.Databases {
+		s.dbs[db] = newDatabase()
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(PingEndpoint, s.serve(PingEndpoint, s.servePing))
+	mux.HandleFunc(WriteEndpoint, s.serve(WriteEndpoint, s.serveWrite))
+	mux.HandleFunc(QueryEndpoint, s.serve(QueryEndpoint, s.serveQuery))
+	s.ts = httptest.NewServer(mux)
+	s.URL = s.ts.URL
+	return s
+}
+
+// Close shuts the server down, waiting for the requests being served.
+func (s *Server) Close() {
+	s.ts.Close()
+}
+
+// Inject makes the requests to endpoint, one of PingEndpoint, WriteEndpoint
+// and QueryEndpoint, suffer f. The faults injected into an endpoint apply in
+// turn, each to its Count of requests.
+func (s *Server) Inject(endpoint string, f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults[endpoint] = append(s.faults[endpoint], &f)
+}
+
+// ClearFaults removes the faults injected into every endpoint.
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = make(map[string][]*Fault)
+}
+
+// Requests returns the number of requests received by endpoint, including
+// those that suffered a fault.
+func (s *Server) Requests(endpoint string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[endpoint]
+}
+
+// CreateDatabase creates the database name, if it does not exist.
+func (s *Server) CreateDatabase(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dbs[name] == nil {
+		s.dbs[name] = newDatabase()
+	}
+}
+
+// Databases returns the names of the databases, sorted.
+func (s *Server) Databases() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.dbs))
+	for name := range s.dbs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Points returns the points stored in the retention policy rp of db, or its
+// default one if rp is empty, sorted by series key and time. A point
+// written several times for the same series and time holds the fields of
+// every write, the latest value of each.
+func (s *Server) Points(db, rp string) []models.Point {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if rp == "" {
+		rp = DefaultRetentionPolicy
+	}
+	d := s.dbs[db]
+	if d == nil {
+		return nil
+	}
+	var points []models.Point
+	for _, ser := range sortedSeries(d.rps[rp]) {
+		for _, t := range ser.times() {
+			p, err := models.NewPoint(ser.name, ser.tags, ser.points[t], time.Unix(0, t))
+			if err == nil {
+				points = append(points, p)
+			}
+		}
+	}
+	return points
+}
+
+// sortedSeries returns the series of rp sorted by key.
+func sortedSeries(rp map[string]*series) []*series {
+	list := make([]*series, 0, len(rp))
+	for _, ser := range rp {
+		list = append(list, ser)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].key < list[j].key })
+	return list
+}
+
+// times returns the times of the points of the series, sorted.
+func (ser *series) times() []int64 {
+	times := make([]int64, 0, len(ser.points))
+	for t := range ser.points {
+		times = append(times, t)
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	return times
+}
+
+// serve returns the handler of endpoint, which counts the requests and
+// applies the faults injected before serving them with h.
+func (s *Server) serve(endpoint string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests[endpoint]++
+		s.nextID++
+		id := s.nextID
+		f := s.takeFault(endpoint)
+		s.mu.Unlock()
+
+		w.Header().Set("X-Influxdb-Version", s.version)
+		w.Header().Set("X-Influxdb-Build", "OSS")
+		w.Header().Set("Request-Id", strconv.Itoa(id))
+		w.Header().Set("X-Request-Id", strconv.Itoa(id))
+		if f.Latency > 0 {
+			select {
+			case <-time.After(f.Latency):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		switch {
+		case f.Drop:
+			if hj, ok := w.(http.Hijacker); ok {
+				if conn, _, err := hj.Hijack(); err == nil {
+					conn.Close()
+					return
+				}
+			}
+			panic(http.ErrAbortHandler)
+		case f.StatusCode != 0:
+			msg := f.Message
+			if msg == "" {
+				msg = http.StatusText(f.StatusCode)
+			}
+			httpError(w, f.StatusCode, msg)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// takeFault returns the fault the next request to endpoint suffers, the zero
+// Fault if none. s.mu must be held.
+func (s *Server) takeFault(endpoint string) Fault {
+	faults := s.faults[endpoint]
+	if len(faults) == 0 {
+		return Fault{}
+	}
+	f := faults[0]
+	if f.Count > 0 {
+		if f.Count--; f.Count == 0 {
+			s.faults[endpoint] = faults[1:]
+		}
+	}
+	return *f
+}
+
+// httpError answers with status and the JSON error msg, as InfluxDB does.
+func httpError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Influxdb-Error", msg)
+	w.WriteHeader(status)
+	b, _ := json.Marshal(struct {
+		Err string `json:"error"`
+	}{msg})
+	w.Write(b)
+}
+
+func (s *Server) servePing(w http.ResponseWriter, r *http.Request) {
+	if r.FormValue("verbose") == "true" {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"version":%q}`, s.version)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) serveWrite(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		httpError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	db := r.URL.Query().Get("db")
+	if db == "" {
+		httpError(w, http.StatusBadRequest, "database is required")
+		return
+	}
+
+	var body io.Reader = r.Body
+	switch enc := r.Header.Get("Content-Encoding"); enc {
+	case "", "identity":
+	case "gzip":
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			httpError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		defer gz.Close()
+		body = gz
+	default:
+		httpError(w, http.StatusUnsupportedMediaType, fmt.Sprintf("unsupported content encoding: %s", enc))
+		return
+	}
+	buf, err := ioutil.ReadAll(body)
+	if err != nil {
+		httpError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	precision := r.URL.Query().Get("precision")
+	switch precision {
+	case "", "n", "ns", "u", "ms", "s", "m", "h":
+	default:
+		httpError(w, http.StatusBadRequest, fmt.Sprintf("invalid precision %q (use n, u, ms, s, m or h)", precision))
+		return
+	}
+	points, parseErr := models.ParsePointsWithPrecision(buf, s.now().UTC(), precision)
+	if parseErr != nil && len(points) == 0 {
+		httpError(w, http.StatusBadRequest, parseErr.Error())
+		return
+	}
+
+	s.mu.Lock()
+	d := s.dbs[db]
+	if d == nil {
+		s.mu.Unlock()
+		httpError(w, http.StatusNotFound, fmt.Sprintf("database not found: %q", db))
+		return
+	}
+	rp := r.URL.Query().Get("rp")
+	if rp == "" {
+		rp = DefaultRetentionPolicy
+	}
+	if d.rps[rp] == nil {
+		s.mu.Unlock()
+		httpError(w, http.StatusBadRequest, fmt.Sprintf("retention policy not found: %s", rp))
+		return
+	}
+	conflict, dropped := d.write(rp, points)
+	s.mu.Unlock()
+
+	switch {
+	case conflict != "":
+		httpError(w, http.StatusBadRequest, fmt.Sprintf("partial write: %s dropped=%d", conflict, dropped))
+	case parseErr != nil:
+		httpError(w, http.StatusBadRequest, "partial write: "+parseErr.Error())
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// write stores points in rp, but those with a field of another type than
+// that of the field already written, of which it returns the first conflict
+// and the number.
+func (d *database) write(rp string, points []models.Point) (conflict string, dropped int) {
+	for _, p := range points {
+		fields, err := p.Fields()
+		if err != nil {
+			continue
+		}
+		name := string(p.Name())
+		types := d.fieldTypes[name]
+		if types == nil {
+			types = make(map[string]string)
+			d.fieldTypes[name] = types
+		}
+		if c := typeConflict(name, types, fields); c != "" {
+			if conflict == "" {
+				conflict = c
+			}
+			dropped++
+			continue
+		}
+		for k, v := range fields {
+			types[k] = fieldType(v)
+		}
+
+		key := string(p.Key())
+		ser := d.rps[rp][key]
+		if ser == nil {
+			ser = &series{key: key, name: name, tags: p.Tags().Clone(), points: make(map[int64]models.Fields)}
+			d.rps[rp][key] = ser
+		}
+		t := p.Time().UnixNano()
+		stored := ser.points[t]
+		if stored == nil {
+			stored = make(models.Fields, len(fields))
+			ser.points[t] = stored
+		}
+		for k, v := range fields {
+			stored[k] = v
+		}
+	}
+	return conflict, dropped
+}
+
+// typeConflict returns the error of the first field of fields, sorted, of
+// another type than in types, empty if there is none.
+func typeConflict(name string, types map[string]string, fields models.Fields) string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		typ := fieldType(fields[k])
+		if existing, ok := types[k]; ok && existing != typ {
+			return fmt.Sprintf("field type conflict: input field %q on measurement %q is type %s, already exists as type %s", k, name, typ, existing)
+		}
+	}
+	return ""
+}
+
+// fieldType returns the name InfluxDB gives the type of the field value v.
+func fieldType(v interface{}) string {
+	switch v.(type) {
+	case float64:
+		return "float"
+	case int64:
+		return "integer"
+	case uint64:
+		return "unsigned"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	}
+	return strings.ToLower(fmt.Sprintf("%T", v))
+}
@@ -0,0 +1,147 @@
+package influxtest
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
+	client "github.com/influxdata/influxdb1-client/v2"
+)
+
+func newTestClient(t *testing.T, s *Server, conf client.HTTPConfig) client.Client {
+	t.Helper()
+	conf.Addr = s.URL
+	c, err := client.NewHTTPClient(conf)
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// writeLines writes the points of lines to db with precision.
+func writeLines(c client.Client, db, precision string, lines ...string) error {
+	bp, _ := client.NewBatchPoints(client.BatchPointsConfig{Database: db, Precision: precision})
+	for _, line := range lines {
+		points, err := models.ParsePointsWithPrecision([]byte(line), time.Now(), precision)
+		if err != nil {
+			return err
+		}
+		for _, p := range points {
+			bp.AddPoint(client.NewPointFrom(p))
+		}
+	}
+	return c.Write(bp)
+}
+
+func pointStrings(s *Server, db, rp string) []string {
+	var lines []string
+	for _, p := range s.Points(db, rp) {
+		lines = append(lines, p.String())
+	}
+	return lines
+}
+
+func TestServer_Ping(t *testing.T) {
+	s := NewServer(Options{Version: "1.7.0"})
+	defer s.Close()
+	c := newTestClient(t, s, client.HTTPConfig{})
+
+	_, version, err := c.Ping(0)
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if version != "1.7.0" {
+		t.Errorf("unexpected version.  expected %v, actual %v", "1.7.0", version)
+	}
+	if n := s.Requests(PingEndpoint); n != 1 {
+		t.Errorf("unexpected requests.  expected %v, actual %v", 1, n)
+	}
+}
+
+func TestServer_Write(t *testing.T) {
+	s := NewServer(Options{Databases: []string{"db0"}})
+	defer s.Close()
+
+	for _, conf := range []client.HTTPConfig{{}, {WriteEncoding: client.GzipEncoding}} {
+		c := newTestClient(t, s, conf)
+		if err := writeLines(c, "db0", "s", "cpu,host=b value=2 1", "cpu,host=a value=1 1", "cpu,host=a idle=3i 1"); err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+	}
+	exp := []string{
+		"cpu,host=a idle=3i,value=1 1000000000",
+		"cpu,host=b value=2 1000000000",
+	}
+	if lines := pointStrings(s, "db0", ""); !reflect.DeepEqual(lines, exp) {
+		t.Errorf("unexpected points.  expected %v, actual %v", exp, lines)
+	}
+
+	c := newTestClient(t, s, client.HTTPConfig{})
+	err := writeLines(c, "db1", "", "cpu value=1")
+	var er *client.ErrorResponse
+	if !errors.As(err, &er) || er.StatusCode != 404 {
+		t.Errorf("unexpected error.  expected a 404 *ErrorResponse, actual %v", err)
+	}
+}
+
+func TestServer_WriteTypeConflict(t *testing.T) {
+	s := NewServer(Options{Databases: []string{"db0"}})
+	defer s.Close()
+	c := newTestClient(t, s, client.HTTPConfig{})
+
+	if err := writeLines(c, "db0", "", "cpu value=1 1"); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	err := writeLines(c, "db0", "", `cpu value="high" 2`, "cpu value=3i 3", "cpu value=4 4")
+	var pe *client.PartialWriteError
+	if !errors.As(err, &pe) {
+		t.Fatalf("unexpected error.  expected a *PartialWriteError, actual %v", err)
+	}
+	if pe.Dropped != 2 || pe.Measurement != "cpu" {
+		t.Errorf("unexpected partial write.  expected %v dropped of %v, actual %v of %v", 2, "cpu", pe.Dropped, pe.Measurement)
+	}
+	exp := []string{"cpu value=1 1", "cpu value=4 4"}
+	if lines := pointStrings(s, "db0", ""); !reflect.DeepEqual(lines, exp) {
+		t.Errorf("unexpected points.  expected %v, actual %v", exp, lines)
+	}
+}
+
+func TestServer_Inject(t *testing.T) {
+	s := NewServer(Options{Databases: []string{"db0"}})
+	defer s.Close()
+	c := newTestClient(t, s, client.HTTPConfig{Timeout: time.Second})
+
+	s.Inject(WriteEndpoint, Fault{StatusCode: 503, Message: "overloaded", Count: 1})
+	s.Inject(WriteEndpoint, Fault{Drop: true, Count: 1})
+	var er *client.ErrorResponse
+	if err := writeLines(c, "db0", "", "cpu value=1 1"); !errors.As(err, &er) || er.StatusCode != 503 || er.Message != "overloaded" {
+		t.Errorf("unexpected error.  expected a 503 *ErrorResponse, actual %v", err)
+	}
+	if err := writeLines(c, "db0", "", "cpu value=1 1"); err == nil {
+		t.Error("expected an error of the dropped connection")
+	}
+	if err := writeLines(c, "db0", "", "cpu value=1 1"); err != nil {
+		t.Errorf("unexpected error once the faults are spent.  expected %v, actual %v", nil, err)
+	}
+	if n := len(s.Points("db0", "")); n != 1 {
+		t.Errorf("unexpected points.  expected %v, actual %v", 1, n)
+	}
+
+	s.Inject(PingEndpoint, Fault{Latency: 50 * time.Millisecond})
+	for i := 0; i < 2; i++ {
+		latency, _, err := c.Ping(0)
+		if err != nil || latency < 50*time.Millisecond {
+			t.Errorf("unexpected ping.  expected a latency of at least %v, actual %v (%v)", 50*time.Millisecond, latency, err)
+		}
+	}
+	s.ClearFaults()
+	if latency, _, _ := c.Ping(0); latency >= 50*time.Millisecond {
+		t.Errorf("unexpected latency after ClearFaults: %v", latency)
+	}
+	if n := s.Requests(WriteEndpoint); n != 3 {
+		t.Errorf("unexpected write requests.  expected %v, actual %v", 3, n)
+	}
+}
@@ -0,0 +1,144 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// WriteFunc writes a batch of points, as the WriteContext of a client does.
+type WriteFunc func(ctx context.Context, bp BatchPoints) error
+
+// WriteInterceptor returns a WriteFunc that wraps next, such as to change the
+// batch before calling next or to look at the error it returns. It can also
+// skip next to drop the batch.
+type WriteInterceptor func(next WriteFunc) WriteFunc
+
+// WrapClient returns a Client that writes with c through the interceptors,
+// the first one being called first. Queries and pings are sent with c as
+// they are, and closing the returned Client closes c.
+//
+// The interceptors see the batches c is given. To see the batches of a
+// RoutingClient or a BatchingClient once they are split or flushed, wrap the
+// client they write with instead, such as with
+// NewRoutingClient(WrapClient(c, interceptors...), route).
+func WrapClient(c Client, interceptors ...WriteInterceptor) Client {
+	write := func(ctx context.Context, bp BatchPoints) error {
+		if cc, ok := c.(ContextClient); ok {
+			return cc.WriteContext(ctx, bp)
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return c.Write(bp)
+	}
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		write = interceptors[i](write)
+	}
+	return &interceptedClient{c: c, write: write}
+}
+
+// interceptedClient is the Client returned by WrapClient.
+type interceptedClient struct {
+	c     Client
+	write WriteFunc
+}
+
+func (ic *interceptedClient) Ping(timeout time.Duration) (time.Duration, string, error) {
+	return ic.c.Ping(timeout)
+}
+
+func (ic *interceptedClient) Write(bp BatchPoints) error {
+	return ic.write(context.Background(), bp)
+}
+
+func (ic *interceptedClient) WriteContext(ctx context.Context, bp BatchPoints) error {
+	return ic.write(ctx, bp)
+}
+
+func (ic *interceptedClient) Query(q Query) (*Response, error) {
+	return ic.c.Query(q)
+}
+
+func (ic *interceptedClient) QueryContext(ctx context.Context, q Query) (*Response, error) {
+	return queryContext(ctx, ic.c, q)
+}
+
+func (ic *interceptedClient) QueryAsChunk(q Query) (*ChunkedResponse, error) {
+	return ic.c.QueryAsChunk(q)
+}
+
+func (ic *interceptedClient) QueryAsChunkContext(ctx context.Context, q Query) (*ChunkedResponse, error) {
+	if cc, ok := ic.c.(ContextClient); ok {
+		return cc.QueryAsChunkContext(ctx, q)
+	}
+	return ic.c.QueryAsChunk(q)
+}
+
+func (ic *interceptedClient) Close() error {
+	return ic.c.Close()
+}
+
+// PointMapError is returned by the WriteFunc of a PointMapper when its
+// function failed for a point. Nothing of the batch was written.
+type PointMapError struct {
+	// Index is the index of the point in its batch, and Point the point.
+	Index int
+	Point *Point
+
+	Err error
+}
+
+func (e *PointMapError) Error() string {
+	return fmt.Sprintf("mapping point %d (%s): %v", e.Index, e.Point.Name(), e.Err)
+}
+
+func (e *PointMapError) Unwrap() error { return e.Err }
+
+// PointMapper returns a WriteInterceptor that calls fn for every point of a
+// batch and writes the points it returns instead, in a new batch with the
+// settings of the batch given, which is left as is. A nil point drops the
+// point, and the batch is not written if every point was dropped. An error
+// aborts the write with a *PointMapError. fn may return p itself, changed
+// with its mutators such as AddTag, but that changes the point of the batch
+// given too.
+func PointMapper(fn func(p *Point) (*Point, error)) WriteInterceptor {
+	return func(next WriteFunc) WriteFunc {
+		return func(ctx context.Context, bp BatchPoints) error {
+			if err := bp.Err(); err != nil {
+				return err
+			}
+			points := bp.Points()
+			mapped := make([]*Point, 0, len(points))
+			for i, p := range points {
+				if p == nil {
+					continue
+				}
+				q, err := fn(p)
+				if err != nil {
+					return &PointMapError{Index: i, Point: p, Err: err}
+				}
+				if q != nil {
+					mapped = append(mapped, q)
+				}
+			}
+			if len(mapped) == 0 && len(points) > 0 {
+				return nil
+			}
+
+			conf := batchPointsConfig(bp)
+			// The points were already counted by the limiter of bp, and the
+			// size of the points mapped is not checked again.
+			conf.CardinalityLimiter = nil
+			conf.MaxBytes = 0
+			nbp, err := NewBatchPoints(conf)
+			if err != nil {
+				return err
+			}
+			if err := nbp.AddPoints(mapped); err != nil {
+				return err
+			}
+			return next(ctx, nbp)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWrapClient(t *testing.T) {
+	var calls []string
+	trace := func(name string) WriteInterceptor {
+		return func(next WriteFunc) WriteFunc {
+			return func(ctx context.Context, bp BatchPoints) error {
+				calls = append(calls, name+" before")
+				err := next(ctx, bp)
+				calls = append(calls, name+" after")
+				return err
+			}
+		}
+	}
+	r := &batchRecorder{}
+	c := WrapClient(r, trace("a"), trace("b"))
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0"})
+	bp.AddPoints(newTestPoints(2))
+	if err := c.Write(bp); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if exp := []string{"a before", "b before", "b after", "a after"}; !reflect.DeepEqual(calls, exp) {
+		t.Errorf("unexpected calls.  expected %q, actual %q", exp, calls)
+	}
+	if len(r.batches) != 1 || len(r.batches[0]) != 2 {
+		t.Errorf("unexpected batches: %v", r.batches)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.(ContextClient).WriteContext(ctx, bp); err != context.Canceled {
+		t.Errorf("unexpected error.  expected %v, actual %v", context.Canceled, err)
+	}
+}
+
+func TestPointMapper(t *testing.T) {
+	errBad := errors.New("bad point")
+	mapper := PointMapper(func(p *Point) (*Point, error) {
+		switch p.Name() {
+		case "debug":
+			return nil, nil
+		case "bad":
+			return nil, errBad
+		}
+		tags := p.Tags()
+		tags["env"] = "prod"
+		delete(tags, "secret")
+		fields, _ := p.Fields()
+		return NewPoint(p.Name(), tags, fields, p.Time())
+	})
+	r := &batchRecorder{}
+	c := WrapClient(r, mapper)
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0", Precision: "s"})
+	p0, _ := NewPoint("cpu", map[string]string{"host": "a", "secret": "x"}, map[string]interface{}{"value": 1.0}, time.Unix(1, 0))
+	p1, _ := NewPoint("debug", nil, map[string]interface{}{"value": 2.0}, time.Unix(2, 0))
+	bp.AddPoints([]*Point{p0, p1})
+	if err := c.Write(bp); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if len(r.batches) != 1 || len(r.batches[0]) != 1 {
+		t.Fatalf("unexpected batches: %v", r.batches)
+	}
+	if s, exp := r.batches[0][0].String(), "cpu,env=prod,host=a value=1 1000000000"; s != exp {
+		t.Errorf("unexpected point.  expected %s, actual %s", exp, s)
+	}
+	if bp.Points()[0] != p0 || len(bp.Points()) != 2 {
+		t.Error("expected the batch to be left as is")
+	}
+
+	// A batch whose points were all dropped is not written.
+	bp, _ = NewBatchPoints(BatchPointsConfig{Database: "db0"})
+	bp.AddPoint(p1)
+	if err := c.Write(bp); err != nil || len(r.batches) != 1 {
+		t.Errorf("unexpected write of dropped points: %v, %d batches", err, len(r.batches))
+	}
+
+	// An error aborts the whole write.
+	p2, _ := NewPoint("bad", nil, map[string]interface{}{"value": 3.0}, time.Unix(3, 0))
+	bp.AddPoints([]*Point{p0, p2})
+	err := c.Write(bp)
+	var pme *PointMapError
+	if !errors.As(err, &pme) || pme.Index != 2 || pme.Point != p2 || !errors.Is(err, errBad) {
+		t.Errorf("unexpected error.  expected a *PointMapError for point 2, actual %v", err)
+	}
+	if len(r.batches) != 1 {
+		t.Errorf("unexpected number of batches.  expected %v, actual %v", 1, len(r.batches))
+	}
+}
+
+func TestWrapClient_Routing(t *testing.T) {
+	seen := make(map[string]int)
+	count := func(next WriteFunc) WriteFunc {
+		return func(ctx context.Context, bp BatchPoints) error {
+			seen[bp.Database()] += len(bp.Points())
+			return next(ctx, bp)
+		}
+	}
+	r := &batchRecorder{}
+	c := NewRoutingClient(WrapClient(r, count), func(p *Point) (string, string) {
+		if p.UnixNano()%2e9 == 0 {
+			return "even", ""
+		}
+		return "", ""
+	})
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0"})
+	bp.AddPoints(newTestPoints(5))
+	if err := c.Write(bp); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if exp := map[string]int{"even": 3, "db0": 2}; !reflect.DeepEqual(seen, exp) {
+		t.Errorf("unexpected batches seen.  expected %v, actual %v", exp, seen)
+	}
+}
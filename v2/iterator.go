@@ -0,0 +1,149 @@
+package client
+
+import (
+	"errors"
+	"io"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+// RowIterator walks the rows of a ChunkedResponse one at a time, reading
+// chunks only as needed so that memory use does not depend on the size of the
+// result. A series split across chunks is presented as a single series.
+//
+//	it := NewRowIterator(resp)
+//	defer it.Close()
+//	for it.Next() {
+//		fmt.Println(it.Tags(), it.Row())
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type RowIterator struct {
+	cr *ChunkedResponse
+
+	// results and series are what is left of the current chunk.
+	results []Result
+	series  []models.Row
+
+	statement int
+	row       models.Row
+	index     int
+	newSeries bool
+
+	err    error
+	closed bool
+}
+
+// NewRowIterator returns a RowIterator reading from cr. The iterator owns cr
+// and closes it once iteration ends.
+func NewRowIterator(cr *ChunkedResponse) *RowIterator {
+	return &RowIterator{cr: cr, index: -1}
+}
+
+// Next advances to the next row and reports whether there is one. It returns
+// false at the end of the response or on error, see Err.
+func (it *RowIterator) Next() bool {
+	if it.closed {
+		return false
+	}
+	it.index++
+	it.newSeries = false
+	for it.index >= len(it.row.Values) {
+		if !it.nextSeries() {
+			it.Close()
+			return false
+		}
+	}
+	return true
+}
+
+// nextSeries moves to the next series, reading a new chunk if needed.
+func (it *RowIterator) nextSeries() bool {
+	for len(it.series) == 0 {
+		if len(it.results) == 0 {
+			resp, err := it.cr.NextResponse()
+			if err != nil {
+				if err != io.EOF {
+					it.err = err
+				}
+				return false
+			}
+			if resp == nil {
+				return false
+			}
+			if resp.Err != "" {
+				it.err = errors.New(resp.Err)
+				return false
+			}
+			it.results = resp.Results
+			continue
+		}
+		result := it.results[0]
+		it.results = it.results[1:]
+		if result.Err != "" {
+			it.err = errors.New(result.Err)
+			return false
+		}
+		if result.StatementId != it.statement {
+			// Never merge series of different statements.
+			it.row = models.Row{}
+			it.statement = result.StatementId
+		}
+		it.series = result.Series
+	}
+
+	next := it.series[0]
+	it.series = it.series[1:]
+	it.newSeries = !(it.row.Partial && it.row.SameSeries(&next))
+	it.row = next
+	it.index = 0
+	return true
+}
+
+// NewSeries reports whether the current row is the first of its series.
+func (it *RowIterator) NewSeries() bool { return it.newSeries }
+
+// StatementID returns the id of the statement the current row belongs to.
+func (it *RowIterator) StatementID() int { return it.statement }
+
+// Name returns the measurement name of the current series.
+func (it *RowIterator) Name() string { return it.row.Name }
+
+// Tags returns the tags of the current series.
+func (it *RowIterator) Tags() map[string]string { return it.row.Tags }
+
+// Columns returns the column names of the current series.
+func (it *RowIterator) Columns() []string { return it.row.Columns }
+
+// Row returns the values of the current row.
+func (it *RowIterator) Row() []interface{} {
+	if it.index < 0 || it.index >= len(it.row.Values) {
+		return nil
+	}
+	return it.row.Values[it.index]
+}
+
+// Value returns the i-th value of the current row, or nil if there is none.
+func (it *RowIterator) Value(i int) interface{} {
+	row := it.Row()
+	if i < 0 || i >= len(row) {
+		return nil
+	}
+	return row[i]
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *RowIterator) Err() error { return it.err }
+
+// Close releases the underlying response. It is safe to call more than once
+// and to call before the iteration is complete.
+func (it *RowIterator) Close() error {
+	if it.closed {
+		return nil
+	}
+	it.closed = true
+	it.row = models.Row{}
+	it.series, it.results = nil, nil
+	return it.cr.Close()
+}
@@ -0,0 +1,132 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+// closeRecorder records whether the wrapped reader was closed.
+type closeRecorder struct {
+	*strings.Reader
+	closed bool
+}
+
+func (r *closeRecorder) Close() error {
+	r.closed = true
+	return nil
+}
+
+func newChunkedStream(t *testing.T, chunks ...Response) *closeRecorder {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, c := range chunks {
+		if err := enc.Encode(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &closeRecorder{Reader: strings.NewReader(buf.String())}
+}
+
+func TestRowIterator_MergesPartialSeries(t *testing.T) {
+	host := map[string]string{"host": "a"}
+	body := newChunkedStream(t,
+		Response{Results: []Result{{Series: []models.Row{
+			{Name: "cpu", Tags: host, Columns: []string{"time", "value"}, Values: [][]interface{}{{1, 1}, {2, 2}}, Partial: true},
+		}}}},
+		Response{Results: []Result{{Series: []models.Row{
+			{Name: "cpu", Tags: host, Columns: []string{"time", "value"}, Values: [][]interface{}{{3, 3}}},
+			{Name: "cpu", Tags: map[string]string{"host": "b"}, Columns: []string{"time", "value"}, Values: [][]interface{}{{4, 4}}},
+		}}}},
+	)
+
+	it := NewRowIterator(NewChunkedResponse(body))
+	defer it.Close()
+
+	var rows, series int
+	for it.Next() {
+		if it.NewSeries() {
+			series++
+		}
+		if got, exp := it.Value(1), json.Number(strconv.Itoa(rows+1)); got != exp {
+			t.Errorf("unexpected value: got %v, exp %v", got, exp)
+		}
+		rows++
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rows != 4 || series != 2 {
+		t.Errorf("unexpected rows/series: got %d/%d, exp %d/%d", rows, series, 4, 2)
+	}
+	if !body.closed {
+		t.Error("expected the response to be closed at the end of iteration")
+	}
+}
+
+func TestRowIterator_Error(t *testing.T) {
+	body := newChunkedStream(t,
+		Response{Results: []Result{{Series: []models.Row{{Name: "cpu", Columns: []string{"value"}, Values: [][]interface{}{{1}}}}}}},
+		Response{Results: []Result{{Err: "query interrupted"}}},
+		Response{Results: []Result{{Series: []models.Row{{Name: "cpu", Columns: []string{"value"}, Values: [][]interface{}{{2}}}}}}},
+	)
+
+	it := NewRowIterator(NewChunkedResponse(body))
+	var rows int
+	for it.Next() {
+		rows++
+	}
+	if rows != 1 {
+		t.Errorf("unexpected number of rows: got %d, exp %d", rows, 1)
+	}
+	if err := it.Err(); err == nil || err.Error() != "query interrupted" {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if !body.closed {
+		t.Error("expected the response to be closed after an error")
+	}
+}
+
+func TestRowIterator_Close(t *testing.T) {
+	body := newChunkedStream(t,
+		Response{Results: []Result{{Series: []models.Row{{Name: "cpu", Columns: []string{"value"}, Values: [][]interface{}{{1}, {2}}}}}}},
+	)
+
+	it := NewRowIterator(NewChunkedResponse(body))
+	if !it.Next() {
+		t.Fatal("expected a row")
+	}
+	if got, exp := it.Columns(), []string{"value"}; len(got) != 1 || got[0] != exp[0] {
+		t.Errorf("unexpected columns: %v", got)
+	}
+	if err := it.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !body.closed {
+		t.Error("expected Close to release the response")
+	}
+	if it.Next() {
+		t.Error("expected no rows after Close")
+	}
+	if it.Row() != nil || it.Value(0) != nil {
+		t.Error("expected no values after Close")
+	}
+	if err := it.Close(); err != nil {
+		t.Errorf("unexpected error closing twice: %v", err)
+	}
+}
+
+func TestRowIterator_DecodeError(t *testing.T) {
+	body := &closeRecorder{Reader: strings.NewReader("{\"results\":[{\"series\":[{\"values\":[[1]]}]}]}\nnot json\n")}
+
+	it := NewRowIterator(NewChunkedResponse(body))
+	for it.Next() {
+	}
+	if it.Err() == nil {
+		t.Error("expected a decode error")
+	}
+}
@@ -0,0 +1,358 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+// JoinMatch is how JoinResponses pairs the times of the two series.
+type JoinMatch int
+
+const (
+	// JoinExact pairs the values at the same time.
+	JoinExact JoinMatch = iota
+
+	// JoinNearest pairs each value of the first series with the value of
+	// the second series nearest in time, if it is within the Tolerance.
+	// On a tie the earlier value is taken.
+	JoinNearest
+
+	// JoinBucketed pairs the values whose times are in the same window of
+	// Tolerance, aligned on the Unix epoch. The joined times are the
+	// starts of the windows.
+	JoinBucketed
+)
+
+// JoinType is which values JoinResponses keeps when they have no match.
+type JoinType int
+
+const (
+	// JoinInner keeps the pairs only.
+	JoinInner JoinType = iota
+
+	// JoinLeft also keeps the values of the first series without a match,
+	// with NaN for the second one.
+	JoinLeft
+
+	// JoinOuter keeps the values of either series without a match, with
+	// NaN for the other one.
+	JoinOuter
+)
+
+// JoinOptions sets how JoinResponses joins two series.
+type JoinOptions struct {
+	// FieldA and FieldB are the columns of the first and second responses
+	// joined. They default to the one column of the series besides time.
+	FieldA, FieldB string
+
+	// Match defaults to JoinExact, and Type to JoinInner.
+	Match JoinMatch
+	Type  JoinType
+
+	// Tolerance is the largest distance in time of the values JoinNearest
+	// pairs, and the window of JoinBucketed, which requires it.
+	Tolerance time.Duration
+
+	// Precision is the epoch precision the queries were made with, see
+	// TimeSeriesDecoder.Precision.
+	Precision string
+}
+
+// JoinedSeries are the series of two responses joined by JoinResponses,
+// one per tag set, sorted by their tags.
+type JoinedSeries struct {
+	Sets []JoinedSet
+}
+
+// JoinedSet is the join of the series of a tag set: the joined times, in
+// order, and the values of the first and second responses at each of them,
+// NaN for none.
+type JoinedSet struct {
+	// Names are the names of the series of the first and second responses.
+	Names [2]string
+	Tags  map[string]string
+
+	Times  []time.Time
+	Values [2][]float64
+}
+
+// TimeSeries returns the column i of s, 0 for the first response and 1 for
+// the second one, as a TimeSeries holding NaN for the missing values.
+func (s JoinedSet) TimeSeries(i int) TimeSeries {
+	return TimeSeries{Name: s.Names[i], Tags: s.Tags, Times: s.Times, Values: s.Values[i]}
+}
+
+// QueryJoin sends the queries qa and qb with c and joins their responses, see
+// JoinResponses.
+func QueryJoin(ctx context.Context, c Client, qa, qb Query, opts JoinOptions) (*JoinedSeries, error) {
+	a, err := queryContext(ctx, c, qa)
+	if err != nil {
+		return nil, err
+	}
+	b, err := queryContext(ctx, c, qb)
+	if err != nil {
+		return nil, err
+	}
+	return JoinResponses(a, b, opts)
+}
+
+// JoinResponses joins the single field series of a and b by time, as InfluxQL
+// cannot join measurements. Responses grouped by tags are joined by tag set,
+// and must be grouped by the same tags: a tag set of one response that the
+// other does not have is joined as with no values on that side. The series of
+// a response not grouped by tags is joined with every tag set of the other
+// one. A time found several times on a side gives as many rows as the pairs
+// it makes.
+func JoinResponses(a, b *Response, opts JoinOptions) (*JoinedSeries, error) {
+	switch {
+	case opts.Tolerance < 0:
+		return nil, &ConfigError{Field: "Tolerance", Reason: "must not be negative"}
+	case opts.Match == JoinBucketed && opts.Tolerance == 0:
+		return nil, &ConfigError{Field: "Tolerance", Reason: "is required by JoinBucketed"}
+	case opts.Match < JoinExact || opts.Match > JoinBucketed:
+		return nil, &ConfigError{Field: "Match", Reason: fmt.Sprintf("unknown match %d", opts.Match)}
+	case opts.Type < JoinInner || opts.Type > JoinOuter:
+		return nil, &ConfigError{Field: "Type", Reason: fmt.Sprintf("unknown join type %d", opts.Type)}
+	}
+	as, err := joinInput(a, opts.FieldA, opts.Precision)
+	if err != nil {
+		return nil, fmt.Errorf("first response: %w", err)
+	}
+	bs, err := joinInput(b, opts.FieldB, opts.Precision)
+	if err != nil {
+		return nil, fmt.Errorf("second response: %w", err)
+	}
+	switch ka, kb := tagKeys(as), tagKeys(bs); {
+	case ka != nil && kb != nil && !reflect.DeepEqual(ka, kb):
+		return nil, fmt.Errorf("responses grouped by different tags: %v and %v", ka, kb)
+	case ka == nil && kb != nil:
+		as = broadcast(as, bs)
+	case ka != nil && kb == nil:
+		bs = broadcast(bs, as)
+	}
+
+	var keys []string
+	for k := range as {
+		keys = append(keys, k)
+	}
+	if opts.Type == JoinOuter {
+		for k := range bs {
+			if _, ok := as[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+
+	joined := &JoinedSeries{}
+	for _, k := range keys {
+		sa, oka := as[k]
+		sb, okb := bs[k]
+		if !okb && opts.Type == JoinInner {
+			continue
+		}
+		set := joinSet(sa, sb, opts)
+		if len(set.Times) == 0 {
+			continue
+		}
+		set.Names = [2]string{sa.Name, sb.Name}
+		set.Tags = sa.Tags
+		if !oka || len(set.Tags) == 0 {
+			set.Tags = sb.Tags
+		}
+		joined.Sets = append(joined.Sets, set)
+	}
+	return joined, nil
+}
+
+// joinInput returns the series of the column field of resp keyed by their tag
+// set. An empty field is the one column of the series besides time.
+func joinInput(resp *Response, field, precision string) (map[string]TimeSeries, error) {
+	if field == "" {
+		var err error
+		if field, err = singleField(resp); err != nil {
+			return nil, err
+		}
+	}
+	series, err := TimeSeriesDecoder{Precision: precision}.TimeSeries(resp, field)
+	if err != nil {
+		return nil, err
+	}
+	bySet := make(map[string]TimeSeries, len(series))
+	for _, ts := range series {
+		k := string(models.NewTags(ts.Tags).HashKey())
+		if other, ok := bySet[k]; ok {
+			return nil, fmt.Errorf("series %s and %s have the same tags", other.Name, ts.Name)
+		}
+		bySet[k] = ts
+	}
+	return bySet, nil
+}
+
+// singleField returns the column of the series of resp besides time, which
+// must be the same one for all of them.
+func singleField(resp *Response) (string, error) {
+	var field string
+	for _, result := range resp.Results {
+		for _, row := range result.Series {
+			var others []string
+			for _, c := range row.Columns {
+				if c != "time" {
+					others = append(others, c)
+				}
+			}
+			if len(others) != 1 {
+				return "", fmt.Errorf("series %q has columns %v, a field is required", row.Name, others)
+			}
+			if field != "" && others[0] != field {
+				return "", fmt.Errorf("series have the columns %q and %q, a field is required", field, others[0])
+			}
+			field = others[0]
+		}
+	}
+	return field, nil
+}
+
+// broadcast returns the series without tags of one keyed by every tag set of
+// other.
+func broadcast(one, other map[string]TimeSeries) map[string]TimeSeries {
+	ts, ok := one[""]
+	if !ok {
+		return one
+	}
+	all := make(map[string]TimeSeries, len(other))
+	for k := range other {
+		all[k] = ts
+	}
+	return all
+}
+
+// tagKeys returns the sorted keys of the tags of the series, nil if there are
+// none.
+func tagKeys(series map[string]TimeSeries) []string {
+	for _, ts := range series {
+		if len(ts.Tags) == 0 {
+			return nil
+		}
+		keys := make([]string, 0, len(ts.Tags))
+		for k := range ts.Tags {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		return keys
+	}
+	return nil
+}
+
+// joinRow is a time of a join and the values of both series at it.
+type joinRow struct {
+	t    time.Time
+	a, b float64
+}
+
+// joinSet joins the values of a and b.
+func joinSet(a, b TimeSeries, opts JoinOptions) JoinedSet {
+	nan := math.NaN()
+	matched := make([]bool, len(b.Times))
+	key := func(t time.Time) time.Time { return t }
+	if opts.Match == JoinBucketed {
+		key = func(t time.Time) time.Time { return bucketStart(t, opts.Tolerance) }
+	}
+
+	var rows []joinRow
+	switch opts.Match {
+	case JoinNearest:
+		order := timeOrder(b.Times)
+		for i, t := range a.Times {
+			if j := nearestTime(b.Times, order, t, opts.Tolerance); j >= 0 {
+				rows = append(rows, joinRow{t, a.Values[i], b.Values[j]})
+				matched[j] = true
+			} else if opts.Type != JoinInner {
+				rows = append(rows, joinRow{t, a.Values[i], nan})
+			}
+		}
+	default:
+		byTime := make(map[int64][]int, len(b.Times))
+		for j, t := range b.Times {
+			k := key(t).UnixNano()
+			byTime[k] = append(byTime[k], j)
+		}
+		for i, t := range a.Times {
+			k := key(t)
+			js := byTime[k.UnixNano()]
+			if len(js) == 0 && opts.Type != JoinInner {
+				rows = append(rows, joinRow{k, a.Values[i], nan})
+			}
+			for _, j := range js {
+				rows = append(rows, joinRow{k, a.Values[i], b.Values[j]})
+				matched[j] = true
+			}
+		}
+	}
+	if opts.Type == JoinOuter {
+		for j, ok := range matched {
+			if !ok {
+				rows = append(rows, joinRow{key(b.Times[j]), nan, b.Values[j]})
+			}
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].t.Before(rows[j].t) })
+
+	set := JoinedSet{Times: make([]time.Time, len(rows))}
+	set.Values[0], set.Values[1] = make([]float64, len(rows)), make([]float64, len(rows))
+	for i, r := range rows {
+		set.Times[i], set.Values[0][i], set.Values[1][i] = r.t, r.a, r.b
+	}
+	return set
+}
+
+// bucketStart returns the start of the window of width d, aligned on the Unix
+// epoch, that t is in.
+func bucketStart(t time.Time, d time.Duration) time.Time {
+	ns, w := t.UnixNano(), int64(d)
+	start := ns - ns%w
+	if ns%w < 0 {
+		start -= w
+	}
+	return time.Unix(0, start).In(t.Location())
+}
+
+// timeOrder returns the indexes of times sorted by time.
+func timeOrder(times []time.Time) []int {
+	order := make([]int, len(times))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return times[order[i]].Before(times[order[j]]) })
+	return order
+}
+
+// nearestTime returns the index of the time of times nearest t within
+// tolerance, the earlier one on a tie, or -1 if there is none. order is the
+// order of times, see timeOrder.
+func nearestTime(times []time.Time, order []int, t time.Time, tolerance time.Duration) int {
+	n := sort.Search(len(order), func(i int) bool { return !times[order[i]].Before(t) })
+	best, dist := -1, tolerance
+	if n > 0 {
+		// The first of the values at the time before t.
+		p := n - 1
+		for p > 0 && times[order[p-1]].Equal(times[order[n-1]]) {
+			p--
+		}
+		if d := t.Sub(times[order[p]]); d <= dist {
+			best, dist = order[p], d
+		}
+	}
+	if n < len(order) {
+		if d := times[order[n]].Sub(t); d < dist || (d == dist && best < 0) {
+			best = order[n]
+		}
+	}
+	return best
+}
@@ -0,0 +1,161 @@
+package client
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// joinResponse decodes the JSON of a query response, with times in seconds.
+func joinResponse(t *testing.T, body string) *Response {
+	t.Helper()
+	var resp Response
+	dec := json.NewDecoder(strings.NewReader(body))
+	dec.UseNumber()
+	if err := dec.Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	return &resp
+}
+
+// joinRows returns the times in seconds and the values of set, with -1 for
+// NaN, as rows.
+func joinRows(set JoinedSet) [][3]float64 {
+	rows := make([][3]float64, len(set.Times))
+	for i, tm := range set.Times {
+		rows[i][0] = float64(tm.Unix())
+		for j := 0; j < 2; j++ {
+			rows[i][j+1] = set.Values[j][i]
+			if math.IsNaN(rows[i][j+1]) {
+				rows[i][j+1] = -1
+			}
+		}
+	}
+	return rows
+}
+
+func TestJoinResponses(t *testing.T) {
+	cpu := joinResponse(t, `{"results":[{"series":[{"name":"cpu","columns":["time","usage"],"values":[[10,1],[20,2],[20,3],[30,4]]}]}]}`)
+	mem := joinResponse(t, `{"results":[{"series":[{"name":"mem","columns":["time","used"],"values":[[10,100],[20,200],[41,400]]}]}]}`)
+
+	for _, tt := range []struct {
+		name string
+		opts JoinOptions
+		exp  [][3]float64
+	}{
+		{
+			// The duplicate time of cpu pairs twice with the one of mem.
+			name: "inner",
+			exp:  [][3]float64{{10, 1, 100}, {20, 2, 200}, {20, 3, 200}},
+		},
+		{
+			name: "left",
+			opts: JoinOptions{Type: JoinLeft},
+			exp:  [][3]float64{{10, 1, 100}, {20, 2, 200}, {20, 3, 200}, {30, 4, -1}},
+		},
+		{
+			name: "outer",
+			opts: JoinOptions{Type: JoinOuter},
+			exp:  [][3]float64{{10, 1, 100}, {20, 2, 200}, {20, 3, 200}, {30, 4, -1}, {41, -1, 400}},
+		},
+		{
+			name: "nearest",
+			opts: JoinOptions{Match: JoinNearest, Tolerance: 10 * 1e9, Type: JoinOuter},
+			exp:  [][3]float64{{10, 1, 100}, {20, 2, 200}, {20, 3, 200}, {30, 4, 200}, {41, -1, 400}},
+		},
+		{
+			name: "bucketed",
+			opts: JoinOptions{Match: JoinBucketed, Tolerance: 20 * 1e9},
+			exp:  [][3]float64{{0, 1, 100}, {20, 2, 200}, {20, 3, 200}, {20, 4, 200}},
+		},
+	} {
+		tt.opts.Precision = "s"
+		joined, err := JoinResponses(cpu, mem, tt.opts)
+		if err != nil {
+			t.Fatalf("%s: unexpected error.  expected %v, actual %v", tt.name, nil, err)
+		}
+		if len(joined.Sets) != 1 {
+			t.Fatalf("%s: unexpected sets.  expected %v, actual %v", tt.name, 1, len(joined.Sets))
+		}
+		if got := joinRows(joined.Sets[0]); !reflect.DeepEqual(tt.exp, got) {
+			t.Errorf("%s: unexpected rows.  expected %v, actual %v", tt.name, tt.exp, got)
+		}
+	}
+
+	col := mustJoin(t, cpu, mem, JoinOptions{Precision: "s"}).Sets[0].TimeSeries(1)
+	if col.Name != "mem" || !reflect.DeepEqual(col.Values, []float64{100, 200, 200}) {
+		t.Errorf("unexpected column: %+v", col)
+	}
+}
+
+func mustJoin(t *testing.T, a, b *Response, opts JoinOptions) *JoinedSeries {
+	t.Helper()
+	joined, err := JoinResponses(a, b, opts)
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	return joined
+}
+
+func TestJoinResponses_Disjoint(t *testing.T) {
+	a := joinResponse(t, `{"results":[{"series":[{"name":"a","columns":["time","v"],"values":[[1,1],[2,2]]}]}]}`)
+	b := joinResponse(t, `{"results":[{"series":[{"name":"b","columns":["time","v"],"values":[[100,3]]}]}]}`)
+
+	if joined := mustJoin(t, a, b, JoinOptions{Precision: "s"}); len(joined.Sets) != 0 {
+		t.Errorf("unexpected inner join of disjoint ranges: %+v", joined.Sets)
+	}
+	exp := [][3]float64{{1, 1, -1}, {2, 2, -1}, {100, -1, 3}}
+	if got := joinRows(mustJoin(t, a, b, JoinOptions{Precision: "s", Type: JoinOuter}).Sets[0]); !reflect.DeepEqual(exp, got) {
+		t.Errorf("unexpected rows.  expected %v, actual %v", exp, got)
+	}
+}
+
+func TestJoinResponses_TagSets(t *testing.T) {
+	a := joinResponse(t, `{"results":[{"series":[`+
+		`{"name":"cpu","tags":{"host":"a"},"columns":["time","v"],"values":[[1,1]]},`+
+		`{"name":"cpu","tags":{"host":"b"},"columns":["time","v"],"values":[[1,2]]}]}]}`)
+	b := joinResponse(t, `{"results":[{"series":[`+
+		`{"name":"mem","tags":{"host":"b"},"columns":["time","v"],"values":[[1,20]]},`+
+		`{"name":"mem","tags":{"host":"c"},"columns":["time","v"],"values":[[1,30]]}]}]}`)
+
+	// Only host b is on both sides.
+	joined := mustJoin(t, a, b, JoinOptions{Precision: "s"})
+	if len(joined.Sets) != 1 || joined.Sets[0].Tags["host"] != "b" || joined.Sets[0].Values[1][0] != 20 {
+		t.Errorf("unexpected inner join: %+v", joined.Sets)
+	}
+	joined = mustJoin(t, a, b, JoinOptions{Precision: "s", Type: JoinOuter})
+	var hosts []string
+	for _, s := range joined.Sets {
+		hosts = append(hosts, s.Tags["host"])
+	}
+	if exp := []string{"a", "b", "c"}; !reflect.DeepEqual(exp, hosts) {
+		t.Errorf("unexpected tag sets of the outer join.  expected %v, actual %v", exp, hosts)
+	}
+
+	// A series without tags is joined with every tag set.
+	total := joinResponse(t, `{"results":[{"series":[{"name":"total","columns":["time","v"],"values":[[1,100]]}]}]}`)
+	if joined := mustJoin(t, a, total, JoinOptions{Precision: "s"}); len(joined.Sets) != 2 || joined.Sets[1].Values[1][0] != 100 {
+		t.Errorf("unexpected join with an ungrouped series: %+v", joined.Sets)
+	}
+
+	// Responses grouped by other tags do not join.
+	region := joinResponse(t, `{"results":[{"series":[{"name":"mem","tags":{"region":"eu"},"columns":["time","v"],"values":[[1,1]]}]}]}`)
+	if _, err := JoinResponses(a, region, JoinOptions{Precision: "s"}); err == nil || !strings.Contains(err.Error(), "different tags") {
+		t.Errorf("unexpected error.  expected different tags, actual %v", err)
+	}
+}
+
+func TestJoinResponses_Invalid(t *testing.T) {
+	wide := joinResponse(t, `{"results":[{"series":[{"name":"cpu","columns":["time","a","b"],"values":[[1,1,2]]}]}]}`)
+	if _, err := JoinResponses(wide, wide, JoinOptions{}); err == nil {
+		t.Error("expected an error for series of several fields without a field")
+	}
+	if joined := mustJoin(t, wide, wide, JoinOptions{FieldA: "a", FieldB: "b", Precision: "s"}); joined.Sets[0].Values[1][0] != 2 {
+		t.Errorf("unexpected join of the fields: %+v", joined.Sets)
+	}
+	if _, err := JoinResponses(wide, wide, JoinOptions{Match: JoinBucketed}); err == nil {
+		t.Error("expected an error for JoinBucketed without a Tolerance")
+	}
+}
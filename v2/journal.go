@@ -0,0 +1,335 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultWriteJournalSize is the number of writes a WriteJournal remembers if
+// the Size of its config is not set.
+const DefaultWriteJournalSize = 1024
+
+// UnknownWritePolicy is what a WriteJournal does with a write whose outcome is
+// unknown, one that timed out after its body was sent: the server may have
+// stored its points or not.
+type UnknownWritePolicy int
+
+const (
+	// ResendUnknownWrites sends the write again, when it is retried or
+	// written again, at the risk of storing its points twice.
+	ResendUnknownWrites UnknownWritePolicy = iota
+
+	// SkipUnknownWrites does not retry the write, which fails with the
+	// error of its timeout, and skips it when it is written again, at the
+	// risk of losing its points.
+	SkipUnknownWrites
+)
+
+// WriteJournalConfig is the config data needed to create a WriteJournal.
+type WriteJournalConfig struct {
+	// Size is the number of writes remembered, the oldest being forgotten,
+	// defaults to DefaultWriteJournalSize.
+	Size int
+
+	// Path, if set, is the file the journal is kept in, so that it survives
+	// a restart, created if it does not exist. It may be a file in the Dir
+	// of a BufferedClient, whose writes replayed after a restart are then
+	// not written twice.
+	Path string
+
+	// OnUnknown is what is done with a write whose outcome is unknown,
+	// defaults to ResendUnknownWrites.
+	OnUnknown UnknownWritePolicy
+}
+
+// WriteJournalStats are the counters of a WriteJournal.
+type WriteJournalStats struct {
+	// Acknowledged is the number of writes the server acknowledged.
+	Acknowledged uint64
+
+	// Skipped is the number of writes skipped as they were acknowledged
+	// before.
+	Skipped uint64
+
+	// SkippedUnknown is the number of writes, and of retries, skipped as
+	// their outcome was unknown, with SkipUnknownWrites.
+	SkippedUnknown uint64
+}
+
+// WriteJournal remembers the writes the server acknowledged, by a hash of
+// their points and settings, so that a write sent again, as when a
+// BufferedClient replays a write whose response was lost or an application
+// retries a batch, is skipped instead of storing its points twice, which
+// matters for points that are summed. Set it as the WriteJournal of an
+// HTTPConfig, where it also tells which retries may write the points twice.
+// Only the writes encoded by the client are journaled, not those of
+// WriteStream.
+//
+// This does not make writes transactional: a write is only recognized if its
+// body is the same, and the server still stores the points of a write that
+// partially failed.
+//
+// A WriteJournal is safe for concurrent use and may be shared by clients.
+type WriteJournal struct {
+	size      int
+	onUnknown UnknownWritePolicy
+
+	mu      sync.Mutex
+	entries map[journalKey]journalState
+	// order holds the keys of entries, from the oldest.
+	order   []journalKey
+	file    *os.File
+	path    string
+	records int
+
+	acknowledged   uint64
+	skipped        uint64
+	skippedUnknown uint64
+}
+
+// journalKey is the hash of a write.
+type journalKey [16]byte
+
+type journalState byte
+
+const (
+	journalAcknowledged journalState = iota + 1
+	journalUnknown
+)
+
+// journalRecordSize is the size of a record of a journal file: the key of a
+// write followed by its state.
+const journalRecordSize = len(journalKey{}) + 1
+
+// NewWriteJournal returns a WriteJournal, reading the writes remembered in
+// the file of conf if it exists.
+func NewWriteJournal(conf WriteJournalConfig) (*WriteJournal, error) {
+	if conf.Size < 0 {
+		return nil, &ConfigError{Field: "Size", Reason: fmt.Sprintf("%d is negative", conf.Size)}
+	}
+	if conf.Size == 0 {
+		conf.Size = DefaultWriteJournalSize
+	}
+	switch conf.OnUnknown {
+	case ResendUnknownWrites, SkipUnknownWrites:
+	default:
+		return nil, &ConfigError{Field: "OnUnknown", Reason: fmt.Sprintf("unknown policy %d", conf.OnUnknown)}
+	}
+
+	j := &WriteJournal{
+		size:      conf.Size,
+		onUnknown: conf.OnUnknown,
+		entries:   make(map[journalKey]journalState),
+		path:      conf.Path,
+	}
+	if conf.Path == "" {
+		return j, nil
+	}
+	if err := j.load(); err != nil {
+		return nil, err
+	}
+	// The file is compacted right away, dropping what was forgotten.
+	if err := j.compact(); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// Stats returns the counters of j.
+func (j *WriteJournal) Stats() WriteJournalStats {
+	return WriteJournalStats{
+		Acknowledged:   atomic.LoadUint64(&j.acknowledged),
+		Skipped:        atomic.LoadUint64(&j.skipped),
+		SkippedUnknown: atomic.LoadUint64(&j.skippedUnknown),
+	}
+}
+
+// Len returns the number of writes j remembers.
+func (j *WriteJournal) Len() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return len(j.order)
+}
+
+// Close closes the file of j. The writes of a client using j fail to keep
+// the journal in its file afterwards.
+func (j *WriteJournal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.file == nil {
+		return nil
+	}
+	err := j.file.Close()
+	j.file = nil
+	return err
+}
+
+// newHash returns the hash a write is journaled by, already fed with the
+// settings of bp, for its body to be written to.
+func (j *WriteJournal) newHash(bp BatchPoints) hash.Hash {
+	h := sha256.New()
+	for _, s := range []string{bp.Database(), bp.RetentionPolicy(), bp.Precision(), bp.WriteConsistency()} {
+		fmt.Fprintf(h, "%d:%s", len(s), s)
+	}
+	return h
+}
+
+// key returns the key of the write whose hash is h.
+func (j *WriteJournal) key(h hash.Hash) journalKey {
+	var key journalKey
+	copy(key[:], h.Sum(nil))
+	return key
+}
+
+// skip reports whether the write of key is not to be sent: if it was
+// acknowledged, or if its outcome is unknown with SkipUnknownWrites.
+func (j *WriteJournal) skip(key journalKey) bool {
+	j.mu.Lock()
+	state := j.entries[key]
+	j.mu.Unlock()
+	switch {
+	case state == journalAcknowledged:
+		atomic.AddUint64(&j.skipped, 1)
+		return true
+	case state == journalUnknown && j.onUnknown == SkipUnknownWrites:
+		atomic.AddUint64(&j.skippedUnknown, 1)
+		return true
+	}
+	return false
+}
+
+// attempted records the outcome of an attempt to send the write of key that
+// failed with err, and returns the error to retry it with, or to give up
+// with when its outcome is unknown and it is not to be sent again.
+func (j *WriteJournal) attempted(key journalKey, err error) (error, error) {
+	if !unknownWriteOutcome(err) {
+		return err, nil
+	}
+	journalErr := j.set(key, journalUnknown)
+	if j.onUnknown == SkipUnknownWrites {
+		atomic.AddUint64(&j.skippedUnknown, 1)
+		var re *retryableError
+		if errors.As(err, &re) {
+			err = re.err
+		}
+	}
+	return err, journalErr
+}
+
+// acknowledge records that the server acknowledged the write of key.
+func (j *WriteJournal) acknowledge(key journalKey) error {
+	atomic.AddUint64(&j.acknowledged, 1)
+	return j.set(key, journalAcknowledged)
+}
+
+// set sets the state of the write of key, forgetting the oldest write if j
+// is full, and appends it to the file.
+func (j *WriteJournal) set(key journalKey, state journalState) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.put(key, state)
+	if j.path == "" {
+		return nil
+	}
+	if j.file == nil {
+		return fmt.Errorf("write journal %s: %w", j.path, os.ErrClosed)
+	}
+	if _, err := j.file.Write(append(key[:], byte(state))); err != nil {
+		return fmt.Errorf("write journal %s: %w", j.path, err)
+	}
+	// Once the file holds twice the records remembered, it is rewritten
+	// without those forgotten.
+	if j.records++; j.records > 2*j.size {
+		return j.compactLocked()
+	}
+	return nil
+}
+
+// put sets the state of key in memory. j.mu must be held.
+func (j *WriteJournal) put(key journalKey, state journalState) {
+	if _, ok := j.entries[key]; !ok {
+		if len(j.order) == j.size {
+			delete(j.entries, j.order[0])
+			j.order = j.order[1:]
+		}
+		j.order = append(j.order, key)
+	}
+	j.entries[key] = state
+}
+
+// load reads the records of the file of j, a partial last record, left by a
+// crash, being ignored.
+func (j *WriteJournal) load() error {
+	f, err := os.Open(j.path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var record [journalRecordSize]byte
+	for {
+		if _, err := io.ReadFull(r, record[:]); err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		var key journalKey
+		copy(key[:], record[:])
+		switch state := journalState(record[len(key)]); state {
+		case journalAcknowledged, journalUnknown:
+			j.put(key, state)
+		default:
+			return fmt.Errorf("write journal %s: invalid record", j.path)
+		}
+	}
+}
+
+// compact rewrites the file of j with the writes it remembers.
+func (j *WriteJournal) compact() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.compactLocked()
+}
+
+func (j *WriteJournal) compactLocked() error {
+	tmp := j.path + ".tmp"
+	b := make([]byte, 0, len(j.order)*journalRecordSize)
+	for _, key := range j.order {
+		b = append(append(b, key[:]...), byte(j.entries[key]))
+	}
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, j.path); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(j.path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if j.file != nil {
+		j.file.Close()
+	}
+	j.file, j.records = f, len(j.order)
+	return nil
+}
+
+// unknownWriteOutcome reports whether a write that failed with err may have
+// been stored by the server: whether it timed out, possibly after its body
+// was sent and before the response came.
+func unknownWriteOutcome(err error) bool {
+	var ne net.Error
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &ne) && ne.Timeout())
+}
@@ -0,0 +1,141 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newJournalServer returns a server answering writes, the first slow ones
+// taking longer than the client's timeout, and the number of writes it got.
+func newJournalServer(slow int32) (*httptest.Server, *int32) {
+	var writes int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if n := atomic.AddInt32(&writes, 1); n <= slow {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	return ts, &writes
+}
+
+func newJournalBatch(value int) BatchPoints {
+	bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0"})
+	p, _ := NewPoint("cpu", nil, map[string]interface{}{"value": value}, time.Unix(1, 0))
+	bp.AddPoint(p)
+	return bp
+}
+
+func TestWriteJournal(t *testing.T) {
+	ts, writes := newJournalServer(0)
+	defer ts.Close()
+
+	j, _ := NewWriteJournal(WriteJournalConfig{Size: 2})
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, WriteJournal: j, WriteEncoding: GzipEncoding})
+	defer c.Close()
+
+	for _, v := range []int{1, 1, 2, 3, 1, 3} {
+		if err := c.Write(newJournalBatch(v)); err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+	}
+	// The write of 1 was forgotten once 2 and 3 were written.
+	if n := atomic.LoadInt32(writes); n != 4 {
+		t.Errorf("unexpected writes sent.  expected %v, actual %v", 4, n)
+	}
+	if exp, stats := (WriteJournalStats{Acknowledged: 4, Skipped: 2}), j.Stats(); stats != exp {
+		t.Errorf("unexpected stats.  expected %+v, actual %+v", exp, stats)
+	}
+	if j.Len() != 2 {
+		t.Errorf("unexpected writes remembered.  expected %v, actual %v", 2, j.Len())
+	}
+
+	bp := newJournalBatch(3)
+	bp.SetRetentionPolicy("rp0")
+	if err := c.Write(bp); err != nil || atomic.LoadInt32(writes) != 5 {
+		t.Errorf("expected the write to another retention policy to be sent, got %v", err)
+	}
+
+	var ce *ConfigError
+	if _, err := NewWriteJournal(WriteJournalConfig{OnUnknown: 2}); !errors.As(err, &ce) {
+		t.Errorf("unexpected error.  expected a *ConfigError, actual %v", err)
+	}
+}
+
+func TestWriteJournal_Unknown(t *testing.T) {
+	for _, policy := range []UnknownWritePolicy{ResendUnknownWrites, SkipUnknownWrites} {
+		ts, writes := newJournalServer(1)
+		j, _ := NewWriteJournal(WriteJournalConfig{OnUnknown: policy})
+		c, _ := NewHTTPClient(HTTPConfig{
+			Addr:          ts.URL,
+			Timeout:       50 * time.Millisecond,
+			MaxRetries:    2,
+			RetryInterval: time.Millisecond,
+			WriteJournal:  j,
+		})
+
+		err := c.Write(newJournalBatch(1))
+		again := c.Write(newJournalBatch(1))
+		c.Close()
+		ts.Close()
+
+		switch policy {
+		case ResendUnknownWrites:
+			if err != nil || again != nil {
+				t.Errorf("unexpected errors.  expected %v, actual %v and %v", nil, err, again)
+			}
+			if exp, stats := (WriteJournalStats{Acknowledged: 1, Skipped: 1}), j.Stats(); stats != exp {
+				t.Errorf("unexpected stats.  expected %+v, actual %+v", exp, stats)
+			}
+			if n := atomic.LoadInt32(writes); n != 2 {
+				t.Errorf("unexpected writes sent.  expected %v, actual %v", 2, n)
+			}
+		case SkipUnknownWrites:
+			if !unknownWriteOutcome(err) || again != nil {
+				t.Errorf("unexpected errors.  expected a timeout then %v, actual %v and %v", nil, err, again)
+			}
+			if exp, stats := (WriteJournalStats{SkippedUnknown: 2}), j.Stats(); stats != exp {
+				t.Errorf("unexpected stats.  expected %+v, actual %+v", exp, stats)
+			}
+			if n := atomic.LoadInt32(writes); n != 1 {
+				t.Errorf("unexpected writes sent.  expected %v, actual %v", 1, n)
+			}
+		}
+	}
+}
+
+func TestWriteJournal_Path(t *testing.T) {
+	ts, writes := newJournalServer(0)
+	defer ts.Close()
+	path := filepath.Join(t.TempDir(), "journal")
+
+	for i := 0; i < 2; i++ {
+		j, err := NewWriteJournal(WriteJournalConfig{Path: path, Size: 3})
+		if err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+		c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, WriteJournal: j})
+		for k := 0; k < 10; k++ {
+			// The second run starts with the writes the first remembered.
+			v := k
+			if i == 1 {
+				v = 9 - k
+			}
+			if err := c.Write(newJournalBatch(v)); err != nil {
+				t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+			}
+		}
+		c.Close()
+		if err := j.Close(); err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+	}
+	// The last 3 writes were remembered from the first run.
+	if n := atomic.LoadInt32(writes); n != 17 {
+		t.Errorf("unexpected writes sent.  expected %v, actual %v", 17, n)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build go1.23
+// +build go1.23
+
+package client
+
+import (
+	"net"
+	"time"
+)
+
+// setKeepAlive configures the keep-alive probes of the connections opened by
+// d. The interval between probes needs net.KeepAliveConfig.
+func setKeepAlive(d *net.Dialer, idle, interval time.Duration) {
+	d.KeepAlive = idle
+	if interval != 0 && idle >= 0 {
+		d.KeepAliveConfig = net.KeepAliveConfig{Enable: true, Idle: idle, Interval: interval, Count: -1}
+	}
+}
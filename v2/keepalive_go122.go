@@ -0,0 +1,15 @@
+//go:build !go1.23
+// +build !go1.23
+
+package client
+
+import (
+	"net"
+	"time"
+)
+
+// setKeepAlive configures the keep-alive probes of the connections opened by
+// d. The interval between probes cannot be set before Go 1.23.
+func setKeepAlive(d *net.Dialer, idle, interval time.Duration) {
+	d.KeepAlive = idle
+}
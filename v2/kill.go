@@ -0,0 +1,118 @@
+package client
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// autoKillTimeout bounds the lookup and kill of a query that ran past its
+// Query.AutoKillAfter.
+const autoKillTimeout = 5 * time.Second
+
+// RunningQuery is a query running on the server, as returned by SHOW QUERIES.
+type RunningQuery struct {
+	ID       uint64
+	Query    string
+	Database string
+
+	// Duration is how long the query had been running.
+	Duration time.Duration
+
+	// Status is the status of the query, such as "running", if the server
+	// reports it.
+	Status string
+
+	// Host is the node running the query on a cluster, empty otherwise.
+	Host string
+}
+
+// ShowQueries returns the queries running on the server.
+func ShowQueries(ctx context.Context, c Client) ([]RunningQuery, error) {
+	resp, err := queryStatement(ctx, c, "", "SHOW QUERIES")
+	if err != nil {
+		return nil, err
+	}
+
+	var queries []RunningQuery
+	err = eachRow(resp, func(get func(column string) interface{}) error {
+		var rq RunningQuery
+		if n, ok := numberString(get("qid")); ok {
+			rq.ID, _ = strconv.ParseUint(n, 10, 64)
+		}
+		rq.Query, _ = get("query").(string)
+		rq.Database, _ = get("database").(string)
+		rq.Status, _ = get("status").(string)
+		rq.Host, _ = get("host").(string)
+		var err error
+		if rq.Duration, err = parseShowDuration(get("duration")); err != nil {
+			return err
+		}
+		queries = append(queries, rq)
+		return nil
+	})
+	return queries, err
+}
+
+// KillQuery stops the query of ID qid. On a cluster, host is the node
+// running the query, see RunningQuery.Host, and is empty otherwise.
+func KillQuery(ctx context.Context, c Client, qid uint64, host string) error {
+	stmt := "KILL QUERY " + strconv.FormatUint(qid, 10)
+	if host != "" {
+		stmt += " ON " + QuoteIdent(host)
+	}
+	_, err := queryStatement(ctx, c, "", stmt)
+	return err
+}
+
+// killQuery looks up q among the running queries and kills it, once it ran
+// for elapsed past its AutoKillAfter. It reports whether a query was found.
+func killQuery(ctx context.Context, c Client, q Query, elapsed time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, autoKillTimeout)
+	defer cancel()
+	running, err := ShowQueries(ctx, c)
+	if err != nil {
+		return false, err
+	}
+	rq, ok := matchRunningQuery(running, q, elapsed)
+	if !ok {
+		return false, nil
+	}
+	return true, KillQuery(ctx, c, rq.ID, rq.Host)
+}
+
+// matchRunningQuery returns the running query that is most likely q, which
+// has been running for elapsed: one of the same database with the same
+// command, or the same command but for its spacing if none has exactly the
+// same, that has been running for the time closest to elapsed.
+func matchRunningQuery(running []RunningQuery, q Query, elapsed time.Duration) (RunningQuery, bool) {
+	for _, same := range []func(string) bool{
+		func(s string) bool { return s == q.Command },
+		func(s string) bool {
+			return strings.Join(strings.Fields(s), " ") == strings.Join(strings.Fields(q.Command), " ")
+		},
+	} {
+		var best RunningQuery
+		var found bool
+		for _, rq := range running {
+			if rq.Database != q.Database || !same(rq.Query) {
+				continue
+			}
+			if !found || absDuration(rq.Duration-elapsed) < absDuration(best.Duration-elapsed) {
+				best, found = rq, true
+			}
+		}
+		if found {
+			return best, true
+		}
+	}
+	return RunningQuery{}, false
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,140 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+func TestShowQueries(t *testing.T) {
+	ts, _ := newShowServer(t, map[string][]models.Row{
+		"SHOW QUERIES": {{
+			Columns: []string{"qid", "query", "database", "duration", "status"},
+			Values: [][]interface{}{
+				{36, "SELECT mean(value) FROM cpu", "telegraf", "1m5s", "running"},
+				{37, "SHOW QUERIES", "", "55µs", "running"},
+			},
+		}},
+	})
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	queries, err := ShowQueries(context.Background(), c)
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	exp := []RunningQuery{
+		{ID: 36, Query: "SELECT mean(value) FROM cpu", Database: "telegraf", Duration: 65 * time.Second, Status: "running"},
+		{ID: 37, Query: "SHOW QUERIES", Duration: 55 * time.Microsecond, Status: "running"},
+	}
+	if !reflect.DeepEqual(queries, exp) {
+		t.Errorf("unexpected queries.\nexpected %+v\nactual   %+v", exp, queries)
+	}
+}
+
+func TestKillQuery(t *testing.T) {
+	ts, statements := newShowServer(t, nil)
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	if err := KillQuery(context.Background(), c, 36, ""); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if err := KillQuery(context.Background(), c, 37, "node-1:8088"); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if exp := []string{"KILL QUERY 36", `KILL QUERY 37 ON "node-1:8088"`}; !reflect.DeepEqual(*statements, exp) {
+		t.Errorf("unexpected statements.  expected %q, actual %q", exp, *statements)
+	}
+}
+
+func TestMatchRunningQuery(t *testing.T) {
+	running := []RunningQuery{
+		{ID: 1, Query: "SELECT * FROM cpu", Database: "db1", Duration: time.Second},
+		{ID: 2, Query: "SELECT  *  FROM cpu", Database: "db0", Duration: time.Second},
+		{ID: 3, Query: "SELECT * FROM cpu", Database: "db0", Duration: time.Minute},
+		{ID: 4, Query: "SELECT * FROM cpu", Database: "db0", Duration: 2 * time.Second},
+		{ID: 5, Query: "SELECT * FROM mem", Database: "db0", Duration: time.Second},
+	}
+	for _, tt := range []struct {
+		command string
+		db      string
+		exp     uint64
+	}{
+		{"SELECT * FROM cpu", "db0", 4},
+		{"SELECT * FROM cpu", "db1", 1},
+		{"SELECT *\n\tFROM mem", "db0", 5},
+		{"SELECT * FROM disk", "db0", 0},
+		{"SELECT * FROM mem", "db1", 0},
+	} {
+		rq, ok := matchRunningQuery(running, Query{Command: tt.command, Database: tt.db}, time.Second)
+		if rq.ID != tt.exp || ok != (tt.exp != 0) {
+			t.Errorf("unexpected match of %q on %s.  expected %v, actual %v (%v)", tt.command, tt.db, tt.exp, rq.ID, ok)
+		}
+	}
+}
+
+func TestQuery_AutoKillAfter(t *testing.T) {
+	const command = "SELECT count(*) FROM huge"
+	var mu sync.Mutex
+	var killed []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var resp Response
+		switch q := r.FormValue("q"); q {
+		case command:
+			<-r.Context().Done()
+			return
+		case "SHOW QUERIES":
+			resp.Results = []Result{{Series: []models.Row{{
+				Columns: []string{"qid", "query", "database", "duration"},
+				Values:  [][]interface{}{{41, command, "db0", "60ms"}, {42, "SHOW QUERIES", "", "10µs"}},
+			}}}}
+		default:
+			mu.Lock()
+			killed = append(killed, q)
+			mu.Unlock()
+			resp.Results = []Result{{}}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, Timeout: time.Minute})
+	defer c.Close()
+
+	q := Query{Command: command, Database: "db0", AutoKillAfter: 50 * time.Millisecond}
+	_, err := c.Query(q)
+	var qte *QueryTimeoutError
+	if !errors.As(err, &qte) || !qte.Killed || qte.KillErr != nil || qte.Timeout != q.AutoKillAfter || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("unexpected error.  expected a killed *QueryTimeoutError, actual %v", err)
+	}
+	_, err = c.QueryAsChunk(q)
+	if !errors.As(err, &qte) || !qte.Killed {
+		t.Errorf("unexpected chunked error.  expected a killed *QueryTimeoutError, actual %v", err)
+	}
+
+	// A shorter Timeout applies without killing the query.
+	q.Timeout = 20 * time.Millisecond
+	if _, err := c.Query(q); !errors.As(err, &qte) || qte.Killed || qte.Timeout != q.Timeout {
+		t.Errorf("unexpected error.  expected a *QueryTimeoutError, actual %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if exp := []string{"KILL QUERY 41", "KILL QUERY 41"}; !reflect.DeepEqual(killed, exp) {
+		t.Errorf("unexpected kills.  expected %q, actual %q", exp, killed)
+	}
+}
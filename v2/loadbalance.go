@@ -0,0 +1,432 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultProbeInterval is the default interval at which a
+	// LoadBalancedClient pings its backends.
+	DefaultProbeInterval = 5 * time.Second
+
+	// DefaultSlowPing is the default ping latency above which the health
+	// score of a backend goes down.
+	DefaultSlowPing = 250 * time.Millisecond
+
+	// DefaultUnhealthyErrorRate is the default error rate at which a
+	// backend stops being sent writes.
+	DefaultUnhealthyErrorRate = 0.5
+)
+
+// lbSmoothing is the weight of the latest outcome in the moving averages of
+// the error rate and ping latency of a backend.
+const lbSmoothing = 0.3
+
+// BackendSpec is a backend of a LoadBalancedClient.
+type BackendSpec struct {
+	HTTPConfig HTTPConfig
+
+	// Weight is the share of the writes sent to the backend, relative to
+	// the weights of the others. Zero sends it none but when every other
+	// backend is unhealthy. Defaults to 1 if no backend has a weight.
+	Weight int
+}
+
+// LoadBalancerOptions is the config data of a LoadBalancedClient, beyond its
+// backends.
+type LoadBalancerOptions struct {
+	// ProbeInterval is how often every backend is pinged, defaults to
+	// DefaultProbeInterval.
+	ProbeInterval time.Duration
+
+	// SlowPing is the ping latency above which the health score of a backend
+	// goes down in proportion, defaults to DefaultSlowPing.
+	SlowPing time.Duration
+
+	// UnhealthyErrorRate is the moving average of the rate of failed
+	// requests and pings at which a backend is unhealthy, defaults to
+	// DefaultUnhealthyErrorRate. An unhealthy backend gets no requests
+	// while another one is healthy, until it answers a ping.
+	UnhealthyErrorRate float64
+
+	// RetryWriteOnTimeout lets a write move to another backend after a
+	// timeout once it was sent, as for a FailoverClient.
+	RetryWriteOnTimeout bool
+
+	// Clock, if set, is the clock of the probes. Defaults to the system
+	// clock.
+	Clock Clock
+}
+
+// BackendStatus is the state of a backend of a LoadBalancedClient.
+type BackendStatus struct {
+	Addr string
+
+	// Weight is the configured weight, and EffectiveWeight the one writes
+	// are spread by: Weight scaled by Health, or zero while unhealthy.
+	Weight          int
+	EffectiveWeight float64
+
+	// Health is the score of the backend, from 0 to 1: one minus its
+	// ErrorRate, lowered further by pings slower than SlowPing.
+	Health    float64
+	ErrorRate float64
+	Latency   time.Duration
+	Healthy   bool
+}
+
+// LoadBalancedClient is a Client that spreads the writes across several
+// InfluxDB backends in proportion to their weights, and sends the queries to
+// the healthiest of them. A request that fails as a FailoverClient would
+// fail over moves to another backend. LoadBalancedClient is safe for
+// concurrent use by multiple goroutines.
+type LoadBalancedClient struct {
+	backends     []*lbBackend
+	slowPing     time.Duration
+	unhealthy    float64
+	retryTimeout bool
+	clock        Clock
+
+	randMu sync.Mutex
+	rand   *rand.Rand
+
+	closing   chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+type lbBackend struct {
+	addr   string
+	client ContextClient
+
+	mu      sync.Mutex
+	weight  int
+	errRate float64
+	latency time.Duration
+	healthy bool
+}
+
+// NewLoadBalancedClient returns a LoadBalancedClient over one HTTP client
+// per backend.
+func NewLoadBalancedClient(backends []BackendSpec, opts LoadBalancerOptions) (*LoadBalancedClient, error) {
+	if len(backends) == 0 {
+		return nil, errors.New("at least one backend is required")
+	}
+	weighted := false
+	for _, b := range backends {
+		if b.Weight < 0 {
+			return nil, &ConfigError{Field: "Weight", Reason: fmt.Sprintf("must not be negative for %s", b.HTTPConfig.Addr)}
+		}
+		weighted = weighted || b.Weight > 0
+	}
+	if opts.UnhealthyErrorRate < 0 || opts.UnhealthyErrorRate > 1 {
+		return nil, &ConfigError{Field: "UnhealthyErrorRate", Reason: "must be between 0 and 1"}
+	}
+	if opts.ProbeInterval <= 0 {
+		opts.ProbeInterval = DefaultProbeInterval
+	}
+	if opts.SlowPing <= 0 {
+		opts.SlowPing = DefaultSlowPing
+	}
+	if opts.UnhealthyErrorRate == 0 {
+		opts.UnhealthyErrorRate = DefaultUnhealthyErrorRate
+	}
+
+	lb := &LoadBalancedClient{
+		slowPing:     opts.SlowPing,
+		unhealthy:    opts.UnhealthyErrorRate,
+		retryTimeout: opts.RetryWriteOnTimeout,
+		clock:        clockOrSystem(opts.Clock),
+		rand:         rand.New(rand.NewSource(time.Now().UnixNano())),
+		closing:      make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+	for _, b := range backends {
+		c, err := NewHTTPClient(b.HTTPConfig)
+		if err != nil {
+			for _, b := range lb.backends {
+				b.client.Close()
+			}
+			return nil, fmt.Errorf("%s: %v", b.HTTPConfig.Addr, err)
+		}
+		weight := b.Weight
+		if !weighted {
+			weight = 1
+		}
+		lb.backends = append(lb.backends, &lbBackend{addr: b.HTTPConfig.Addr, client: c.(ContextClient), weight: weight, healthy: true})
+	}
+
+	go lb.probe(opts.ProbeInterval)
+	return lb, nil
+}
+
+// Backends returns the status of every backend, in the order they were
+// given.
+func (lb *LoadBalancedClient) Backends() []BackendStatus {
+	statuses := make([]BackendStatus, len(lb.backends))
+	for i, b := range lb.backends {
+		statuses[i] = lb.status(b)
+	}
+	return statuses
+}
+
+// SetWeight changes the weight of the backend addr, such as to drain it
+// with 0.
+func (lb *LoadBalancedClient) SetWeight(addr string, weight int) error {
+	if weight < 0 {
+		return &ConfigError{Field: "Weight", Reason: "must not be negative"}
+	}
+	for _, b := range lb.backends {
+		if b.addr == addr {
+			b.mu.Lock()
+			b.weight = weight
+			b.mu.Unlock()
+			return nil
+		}
+	}
+	return fmt.Errorf("no backend %s", addr)
+}
+
+// Ping pings the healthiest backend.
+func (lb *LoadBalancedClient) Ping(timeout time.Duration) (time.Duration, string, error) {
+	var rtt time.Duration
+	var version string
+	err := lb.do(context.Background(), false, lb.byHealth(), func(c ContextClient) error {
+		var err error
+		rtt, version, err = c.Ping(timeout)
+		return err
+	})
+	return rtt, version, err
+}
+
+// Write writes bp to a backend picked at random by weight.
+func (lb *LoadBalancedClient) Write(bp BatchPoints) error {
+	return lb.WriteContext(context.Background(), bp)
+}
+
+// WriteContext is like Write, bound to ctx.
+func (lb *LoadBalancedClient) WriteContext(ctx context.Context, bp BatchPoints) error {
+	return lb.do(ctx, true, lb.byWeight(), func(c ContextClient) error {
+		return c.WriteContext(ctx, bp)
+	})
+}
+
+// Query runs q on the healthiest backend.
+func (lb *LoadBalancedClient) Query(q Query) (*Response, error) {
+	return lb.QueryContext(context.Background(), q)
+}
+
+// QueryContext is like Query, bound to ctx.
+func (lb *LoadBalancedClient) QueryContext(ctx context.Context, q Query) (*Response, error) {
+	var resp *Response
+	err := lb.do(ctx, false, lb.byHealth(), func(c ContextClient) error {
+		var err error
+		resp, err = c.QueryContext(ctx, q)
+		return err
+	})
+	return resp, err
+}
+
+// QueryAsChunk runs q on the healthiest backend. Once the response started
+// streaming, errors are not retried on another backend.
+func (lb *LoadBalancedClient) QueryAsChunk(q Query) (*ChunkedResponse, error) {
+	return lb.QueryAsChunkContext(context.Background(), q)
+}
+
+// QueryAsChunkContext is like QueryAsChunk, bound to ctx.
+func (lb *LoadBalancedClient) QueryAsChunkContext(ctx context.Context, q Query) (*ChunkedResponse, error) {
+	var resp *ChunkedResponse
+	err := lb.do(ctx, false, lb.byHealth(), func(c ContextClient) error {
+		var err error
+		resp, err = c.QueryAsChunkContext(ctx, q)
+		return err
+	})
+	return resp, err
+}
+
+// Close stops the probes and closes the client of every backend.
+func (lb *LoadBalancedClient) Close() error {
+	lb.closeOnce.Do(func() { close(lb.closing) })
+	<-lb.done
+
+	var err error
+	for _, b := range lb.backends {
+		if cerr := b.client.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// do calls fn with the client of each backend in turn until one succeeds or
+// fails with an error that must not be retried elsewhere, and records the
+// outcome in the health of the backends.
+func (lb *LoadBalancedClient) do(ctx context.Context, write bool, backends []*lbBackend, fn func(c ContextClient) error) error {
+	var err error
+	for _, b := range backends {
+		err = fn(b.client)
+		if err == nil {
+			lb.observe(b, false)
+			return nil
+		}
+		if ctx.Err() != nil || !retryElsewhere(err, write, lb.retryTimeout) {
+			// The backend answered, it is not to blame for the error.
+			lb.observe(b, false)
+			return err
+		}
+		lb.observe(b, true)
+		err = fmt.Errorf("%s: %w", b.addr, err)
+	}
+	return err
+}
+
+// observe adds the outcome of a request or ping to the error rate of b.
+func (lb *LoadBalancedClient) observe(b *lbBackend, failed bool) {
+	var x float64
+	if failed {
+		x = 1
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.errRate += lbSmoothing * (x - b.errRate)
+	if b.errRate >= lb.unhealthy {
+		b.healthy = false
+	}
+}
+
+// status returns the status of b.
+func (lb *LoadBalancedClient) status(b *lbBackend) BackendStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	s := BackendStatus{
+		Addr:      b.addr,
+		Weight:    b.weight,
+		Health:    1 - b.errRate,
+		ErrorRate: b.errRate,
+		Latency:   b.latency,
+		Healthy:   b.healthy,
+	}
+	if b.latency > lb.slowPing {
+		s.Health *= float64(lb.slowPing) / float64(b.latency)
+	}
+	if s.Healthy {
+		s.EffectiveWeight = float64(b.weight) * s.Health
+	}
+	return s
+}
+
+// byWeight returns the backends in the order a write tries them: the healthy
+// ones in a random order by effective weight, then those without weight,
+// then the unhealthy ones as a last resort.
+func (lb *LoadBalancedClient) byWeight() []*lbBackend {
+	type candidate struct {
+		b      *lbBackend
+		weight float64
+	}
+	var weighted []candidate
+	var rest, unhealthy []*lbBackend
+	var total float64
+	for _, b := range lb.backends {
+		s := lb.status(b)
+		switch {
+		case !s.Healthy:
+			unhealthy = append(unhealthy, b)
+		case s.EffectiveWeight > 0:
+			weighted = append(weighted, candidate{b, s.EffectiveWeight})
+			total += s.EffectiveWeight
+		default:
+			rest = append(rest, b)
+		}
+	}
+
+	ordered := make([]*lbBackend, 0, len(lb.backends))
+	for len(weighted) > 0 {
+		lb.randMu.Lock()
+		r := lb.rand.Float64() * total
+		lb.randMu.Unlock()
+		i := 0
+		for ; i < len(weighted)-1 && r >= weighted[i].weight; i++ {
+			r -= weighted[i].weight
+		}
+		ordered = append(ordered, weighted[i].b)
+		total -= weighted[i].weight
+		weighted = append(weighted[:i], weighted[i+1:]...)
+	}
+	ordered = append(ordered, rest...)
+	return append(ordered, unhealthy...)
+}
+
+// byHealth returns the backends from the healthiest to the least healthy,
+// the unhealthy ones last, and by weight for the same health.
+func (lb *LoadBalancedClient) byHealth() []*lbBackend {
+	statuses := make([]BackendStatus, len(lb.backends))
+	ordered := make([]*lbBackend, len(lb.backends))
+	for i, b := range lb.backends {
+		statuses[i] = lb.status(b)
+		ordered[i] = b
+	}
+	sort.Stable(byHealth{statuses, ordered})
+	return ordered
+}
+
+type byHealth struct {
+	statuses []BackendStatus
+	backends []*lbBackend
+}
+
+func (s byHealth) Len() int { return len(s.statuses) }
+
+func (s byHealth) Less(i, j int) bool {
+	a, b := s.statuses[i], s.statuses[j]
+	if a.Healthy != b.Healthy {
+		return a.Healthy
+	}
+	if a.Health != b.Health {
+		return a.Health > b.Health
+	}
+	return a.Weight > b.Weight
+}
+
+func (s byHealth) Swap(i, j int) {
+	s.statuses[i], s.statuses[j] = s.statuses[j], s.statuses[i]
+	s.backends[i], s.backends[j] = s.backends[j], s.backends[i]
+}
+
+// probe pings every backend every interval until Close. A backend that
+// answers is healthy again, with a clean error rate.
+func (lb *LoadBalancedClient) probe(interval time.Duration) {
+	defer close(lb.done)
+
+	for {
+		select {
+		case <-lb.closing:
+			return
+		case <-lb.clock.After(interval):
+		}
+		for _, b := range lb.backends {
+			rtt, _, err := b.client.Ping(interval)
+			if err != nil {
+				lb.observe(b, true)
+				continue
+			}
+			b.mu.Lock()
+			if b.latency == 0 {
+				b.latency = rtt
+			} else {
+				b.latency += time.Duration(lbSmoothing * float64(rtt-b.latency))
+			}
+			if !b.healthy {
+				b.healthy = true
+				b.errRate = 0
+			}
+			b.mu.Unlock()
+			lb.observe(b, false)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// lbServer is a backend answering with the status code held by code, which
+// counts the writes and queries it served.
+type lbServer struct {
+	*httptest.Server
+	code    int32
+	writes  int32
+	queries int32
+}
+
+func newLBServer() *lbServer {
+	s := &lbServer{code: http.StatusNoContent}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		code := int(atomic.LoadInt32(&s.code))
+		switch r.URL.Path {
+		case "/write":
+			atomic.AddInt32(&s.writes, 1)
+		case "/query":
+			atomic.AddInt32(&s.queries, 1)
+			if code == http.StatusNoContent {
+				code = http.StatusOK
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		if code == http.StatusOK {
+			w.Write([]byte(`{}`))
+		}
+	}))
+	return s
+}
+
+func (s *lbServer) counts() (writes, queries int) {
+	return int(atomic.LoadInt32(&s.writes)), int(atomic.LoadInt32(&s.queries))
+}
+
+func TestLoadBalancedClient(t *testing.T) {
+	big, small := newLBServer(), newLBServer()
+	defer big.Close()
+	defer small.Close()
+
+	lb, err := NewLoadBalancedClient([]BackendSpec{
+		{HTTPConfig: HTTPConfig{Addr: big.URL}, Weight: 3},
+		{HTTPConfig: HTTPConfig{Addr: small.URL}, Weight: 1},
+	}, LoadBalancerOptions{ProbeInterval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	defer lb.Close()
+
+	bp := newTestBatch(t, 1)
+	write := func(n int) {
+		t.Helper()
+		for i := 0; i < n; i++ {
+			if err := lb.Write(bp); err != nil {
+				t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+			}
+		}
+	}
+
+	// The writes are spread by weight, the queries go to the heaviest of
+	// the healthy backends.
+	write(400)
+	if n, _ := big.counts(); n < 240 || n > 360 {
+		t.Errorf("unexpected share of the writes of the big backend.  expected about %v, actual %v", 300, n)
+	}
+	if _, err := lb.Query(Query{Command: "SHOW DATABASES"}); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if _, n := big.counts(); n != 1 {
+		t.Errorf("unexpected queries of the big backend.  expected %v, actual %v", 1, n)
+	}
+
+	// A failing backend is taken out once its error rate is high enough,
+	// without failing the writes.
+	atomic.StoreInt32(&big.code, http.StatusServiceUnavailable)
+	write(50)
+	if s := lb.Backends()[0]; s.Healthy || s.EffectiveWeight != 0 {
+		t.Fatalf("unexpected status of the failing backend: %+v", s)
+	}
+	before, _ := big.counts()
+	write(50)
+	lb.Query(Query{Command: "SHOW DATABASES"})
+	if n, _ := big.counts(); n != before {
+		t.Errorf("unexpected writes to the unhealthy backend.  expected %v, actual %v", before, n)
+	}
+	if _, n := small.counts(); n != 1 {
+		t.Errorf("unexpected queries of the small backend.  expected %v, actual %v", 1, n)
+	}
+
+	// It is back once it answers a probe.
+	atomic.StoreInt32(&big.code, http.StatusNoContent)
+	deadline := time.Now().Add(5 * time.Second)
+	for !lb.Backends()[0].Healthy {
+		if time.Now().After(deadline) {
+			t.Fatal("the backend did not recover")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	write(200)
+	if n, _ := big.counts(); n-before < 100 {
+		t.Errorf("unexpected share of the writes of the recovered backend.  expected about %v, actual %v", 150, n-before)
+	}
+
+	// A backend without weight gets no writes.
+	if err := lb.SetWeight(big.URL, 0); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	before, _ = big.counts()
+	write(20)
+	if n, _ := big.counts(); n != before {
+		t.Errorf("unexpected writes to the drained backend.  expected %v, actual %v", before, n)
+	}
+}
+
+func TestNewLoadBalancedClient_Invalid(t *testing.T) {
+	if _, err := NewLoadBalancedClient(nil, LoadBalancerOptions{}); err == nil {
+		t.Error("expected an error without backends")
+	}
+	var ce *ConfigError
+	_, err := NewLoadBalancedClient([]BackendSpec{{HTTPConfig: HTTPConfig{Addr: "http://localhost:8086"}, Weight: -1}}, LoadBalancerOptions{})
+	if !errors.As(err, &ce) || ce.Field != "Weight" {
+		t.Errorf("unexpected error.  expected %T, actual %v", ce, err)
+	}
+}
@@ -0,0 +1,15 @@
+package client
+
+// Logger receives messages about events the client handles on its own, such
+// as a retried write or a re-established TCP connection. A *log.Logger
+// satisfies it, other loggers are adapted with a Printf method.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// logf formats a message for l, if it is set.
+func logf(l Logger, format string, v ...interface{}) {
+	if l != nil {
+		l.Printf(format, v...)
+	}
+}
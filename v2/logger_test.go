@@ -0,0 +1,86 @@
+package client
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// bufferLogger collects log lines for inspection.
+type bufferLogger struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+	l   *log.Logger
+}
+
+func newBufferLogger() *bufferLogger {
+	bl := &bufferLogger{}
+	bl.l = log.New(&bl.buf, "", 0)
+	return bl
+}
+
+func (bl *bufferLogger) Printf(format string, v ...interface{}) {
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+	bl.l.Printf(format, v...)
+}
+
+func (bl *bufferLogger) String() string {
+	bl.mu.Lock()
+	defer bl.mu.Unlock()
+	return bl.buf.String()
+}
+
+func TestClient_LogsRetries(t *testing.T) {
+	ts, _ := newRetryTestServer(t, http.StatusServiceUnavailable)
+	defer ts.Close()
+
+	logger := newBufferLogger()
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, MaxRetries: 1, RetryInterval: time.Millisecond, Logger: logger})
+	defer c.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	bp.AddPoints(newTestPoints(1))
+	if err := c.Write(bp); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := logger.String(); !strings.Contains(got, "write attempt 1 failed, retrying in") {
+		t.Errorf("unexpected log output: %q", got)
+	}
+}
+
+func TestTCPClient_LogsReconnect(t *testing.T) {
+	l := newSinkListener(t)
+	defer l.Close()
+
+	logger := newBufferLogger()
+	cl := newTCPTestClient(brokenConn{}, l.Addr().String())
+	cl.logger = logger
+	defer cl.Close()
+
+	if err := cl.Write(newTestBatch(t, 1)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := logger.String()
+	for _, exp := range []string{"writing to " + l.Addr().String() + " failed", "reconnected to " + l.Addr().String()} {
+		if !strings.Contains(got, exp) {
+			t.Errorf("expected log output to contain %q, got %q", exp, got)
+		}
+	}
+}
+
+func TestUDPClient_LogsSendErrors(t *testing.T) {
+	logger := newBufferLogger()
+	cl := &udpclient{conn: brokenConn{}, payloadSize: UDPPayloadSize, logger: logger}
+
+	if err := cl.Write(newTestBatch(t, 1)); err == nil {
+		t.Fatal("expected an error")
+	}
+	if got := logger.String(); !strings.Contains(got, "sending UDP datagram failed") {
+		t.Errorf("unexpected log output: %q", got)
+	}
+}
@@ -0,0 +1,54 @@
+package client
+
+// MessageLevelWarning is the level of the messages of the server that warn
+// about a query, such as the use of a deprecated feature.
+const MessageLevelWarning = "warning"
+
+// Messages returns the messages the server sent along with the results of
+// every statement, in order, such as deprecation warnings. They are not
+// errors, see Error.
+func (r *Response) Messages() []Message {
+	return appendMessages(nil, r.Results)
+}
+
+// HasWarnings reports whether the server sent a message of level
+// MessageLevelWarning with the results.
+func (r *Response) HasWarnings() bool {
+	return hasWarnings(r.Messages())
+}
+
+// Messages returns the messages of the responses read so far, in order, see
+// Response.Messages.
+func (r *ChunkedResponse) Messages() []Message {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Message(nil), r.messages...)
+}
+
+// HasWarnings reports whether one of the responses read so far has warnings,
+// see Response.HasWarnings.
+func (r *ChunkedResponse) HasWarnings() bool {
+	return hasWarnings(r.Messages())
+}
+
+// appendMessages appends the messages of results to messages. They are
+// copied, as the results of a response may be reused.
+func appendMessages(messages []Message, results []Result) []Message {
+	for _, result := range results {
+		for _, m := range result.Messages {
+			if m != nil {
+				messages = append(messages, *m)
+			}
+		}
+	}
+	return messages
+}
+
+func hasWarnings(messages []Message) bool {
+	for _, m := range messages {
+		if m.Level == MessageLevelWarning {
+			return true
+		}
+	}
+	return false
+}
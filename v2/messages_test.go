@@ -0,0 +1,64 @@
+package client
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResponse_Messages(t *testing.T) {
+	resp := &Response{Results: []Result{
+		{StatementId: 0, Messages: []*Message{{Level: "info", Text: "query returned no results"}}},
+		{StatementId: 1, Err: "database not found: db0"},
+		{StatementId: 2, Messages: []*Message{{Level: MessageLevelWarning, Text: "deprecated"}}, Err: "boom"},
+	}}
+
+	exp := []Message{{Level: "info", Text: "query returned no results"}, {Level: MessageLevelWarning, Text: "deprecated"}}
+	if messages := resp.Messages(); !reflect.DeepEqual(messages, exp) {
+		t.Errorf("unexpected messages.  expected %v, actual %v", exp, messages)
+	}
+	if !resp.HasWarnings() {
+		t.Error("expected the response to have warnings")
+	}
+
+	err := resp.Error()
+	if exp := "statement 1: database not found: db0\nstatement 2: boom"; err == nil || err.Error() != exp {
+		t.Errorf("unexpected error.  expected %q, actual %v", exp, err)
+	}
+	var se *StatementError
+	if !errors.Is(err, ErrDatabaseNotFound) || !errors.As(err, &se) || se.Message != "database not found: db0" {
+		t.Errorf("unexpected error.  expected a *StatementError matching ErrDatabaseNotFound, actual %v", err)
+	}
+
+	resp.Results = resp.Results[:1]
+	if err := resp.Error(); err != nil {
+		t.Errorf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if resp.HasWarnings() {
+		t.Error("expected the response to have no warnings")
+	}
+}
+
+func TestChunkedResponse_Messages(t *testing.T) {
+	body := `{"results":[{"statement_id":0,"messages":[{"level":"warning","text":"deprecated"}],"partial":true}]}
+{"results":[{"statement_id":0,"messages":[{"level":"info","text":"done"}]}]}
+`
+	r := NewChunkedResponse(strings.NewReader(body))
+	var resp Response
+	for {
+		if err := r.NextResponseInto(&resp); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+	}
+	exp := []Message{{Level: MessageLevelWarning, Text: "deprecated"}, {Level: "info", Text: "done"}}
+	if messages := r.Messages(); !reflect.DeepEqual(messages, exp) {
+		t.Errorf("unexpected messages.  expected %v, actual %v", exp, messages)
+	}
+	if !r.HasWarnings() || resp.HasWarnings() {
+		t.Errorf("unexpected warnings.  expected them on the stream only")
+	}
+}
@@ -0,0 +1,115 @@
+package client
+
+import (
+	"strings"
+	"unicode"
+)
+
+// QueryMethod is the HTTP method a Query is sent with.
+type QueryMethod string
+
+const (
+	// QueryMethodAuto sends read-only statements as GET and any other
+	// statement as POST. A command too long for a URL is always sent as POST.
+	QueryMethodAuto QueryMethod = ""
+
+	// QueryMethodGet sends the query as GET, whatever its statements and
+	// length. The server rejects statements that modify data this way.
+	QueryMethodGet QueryMethod = "GET"
+
+	// QueryMethodPost sends the query as POST.
+	QueryMethodPost QueryMethod = "POST"
+)
+
+// MaxQueryURLLength is the length of the encoded query parameters above which
+// a query is sent as POST with the command in a form-encoded body, as longer
+// URLs are rejected by many proxies.
+const MaxQueryURLLength = 2048
+
+// method returns the HTTP method to send q with, given the length of its
+// encoded parameters.
+func (q Query) method(paramsLength int) string {
+	switch q.Method {
+	case QueryMethodGet:
+		return "GET"
+	case QueryMethodPost:
+		return "POST"
+	}
+	if paramsLength > MaxQueryURLLength || !readOnly(q.Command) {
+		return "POST"
+	}
+	return "GET"
+}
+
+// readOnly reports whether every statement of command starts with SELECT,
+// SHOW or EXPLAIN and none selects INTO a measurement. Quoted strings,
+// identifiers and comments are skipped. A statement that cannot be
+// recognized is not read-only.
+func readOnly(command string) bool {
+	first := true
+	for s := command; len(s) > 0; {
+		switch c := s[0]; {
+		case c == ';':
+			first = true
+			s = s[1:]
+		case strings.HasPrefix(s, "--"):
+			if n := strings.IndexByte(s, '\n'); n >= 0 {
+				s = s[n+1:]
+			} else {
+				s = ""
+			}
+		case strings.HasPrefix(s, "/*"):
+			if n := strings.Index(s[2:], "*/"); n >= 0 {
+				s = s[n+4:]
+			} else {
+				s = ""
+			}
+		case isWordByte(c):
+			n := 1
+			for n < len(s) && isWordByte(s[n]) {
+				n++
+			}
+			word := strings.ToUpper(s[:n])
+			if first {
+				if word != "SELECT" && word != "SHOW" && word != "EXPLAIN" {
+					return false
+				}
+				first = false
+			} else if word == "INTO" {
+				return false
+			}
+			s = s[n:]
+		case c == '\'' || c == '"':
+			if first {
+				return false
+			}
+			s = s[quotedLen(s):]
+		case unicode.IsSpace(rune(c)):
+			s = s[1:]
+		default:
+			if first {
+				return false
+			}
+			s = s[1:]
+		}
+	}
+	return true
+}
+
+// quotedLen returns the length of the quoted string or identifier s starts
+// with, including its quotes.
+func quotedLen(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case s[0]:
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
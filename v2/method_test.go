@@ -0,0 +1,132 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReadOnly(t *testing.T) {
+	for _, tt := range []struct {
+		command string
+		exp     bool
+	}{
+		{"", true},
+		{"SELECT value FROM cpu", true},
+		{"  select value from cpu", true},
+		{"SHOW DATABASES", true},
+		{"EXPLAIN SELECT value FROM cpu", true},
+		{"SELECT value INTO cpu_copy FROM cpu", false},
+		{"SELECT * INTO \"db1\".\"autogen\".:MEASUREMENT FROM /.*/ GROUP BY *", false},
+		{"SELECT value FROM cpu WHERE host = 'into'", true},
+		{"SELECT \"into\" FROM cpu", true},
+		{"SELECT value FROM cpu WHERE host = 'it\\'s into'", true},
+		{"SELECT value FROM cpu; SHOW MEASUREMENTS", true},
+		{"SELECT value FROM cpu; DROP SERIES FROM cpu", false},
+		{"SHOW DATABASES;CREATE DATABASE db0;", false},
+		{"DELETE FROM cpu WHERE time < now() - 1d", false},
+		{"DROP MEASUREMENT cpu", false},
+		{"CREATE DATABASE db0", false},
+		{"ALTER RETENTION POLICY rp0 ON db0 DURATION 1d", false},
+		{"GRANT ALL TO admin", false},
+		{"REVOKE ALL FROM admin", false},
+		{"KILL QUERY 36", false},
+		{"-- comment\nSELECT value FROM cpu", true},
+		{"/* DROP */ SELECT value FROM cpu", true},
+		{"SELECT value FROM cpu -- INTO\n", true},
+		{"(SELECT value FROM cpu)", false},
+	} {
+		if got := readOnly(tt.command); got != tt.exp {
+			t.Errorf("unexpected result for %q.  expected %v, actual %v", tt.command, tt.exp, got)
+		}
+	}
+}
+
+func TestClient_QueryMethod(t *testing.T) {
+	type request struct {
+		method, contentType, q, db, urlQ string
+	}
+	var got request
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = request{
+			method:      r.Method,
+			contentType: r.Header.Get("Content-Type"),
+			q:           r.FormValue("q"),
+			db:          r.FormValue("db"),
+			urlQ:        r.URL.Query().Get("q"),
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(Response{})
+	}))
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	longWhere := "SELECT value FROM cpu WHERE host = '" + strings.Repeat("x", 8192) + "'"
+	for _, tt := range []struct {
+		name   string
+		query  Query
+		method string
+		inBody bool
+	}{
+		{"select", Query{Command: "SELECT value FROM cpu"}, "GET", false},
+		{"select into", Query{Command: "SELECT value INTO cpu_copy FROM cpu"}, "POST", false},
+		{"multiple statements", Query{Command: "SHOW DATABASES; CREATE DATABASE db0"}, "POST", false},
+		{"long where clause", Query{Command: longWhere}, "POST", true},
+		{"forced get", Query{Command: "SELECT value INTO cpu_copy FROM cpu", Method: QueryMethodGet}, "GET", false},
+		{"forced post", Query{Command: "SELECT value FROM cpu", Method: QueryMethodPost}, "POST", false},
+		{"long forced get", Query{Command: longWhere, Method: QueryMethodGet}, "GET", false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.query.Database = "db0"
+			if _, err := c.Query(tt.query); err != nil {
+				t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+			}
+			if got.method != tt.method {
+				t.Errorf("unexpected method.  expected %v, actual %v", tt.method, got.method)
+			}
+			if got.q != tt.query.Command || got.db != "db0" {
+				t.Errorf("unexpected parameters.  q: %.40q, db: %q", got.q, got.db)
+			}
+			if tt.inBody {
+				if exp := "application/x-www-form-urlencoded"; got.contentType != exp {
+					t.Errorf("unexpected content type.  expected %q, actual %q", exp, got.contentType)
+				}
+				if got.urlQ != "" {
+					t.Error("expected the command to be left out of the URL")
+				}
+			} else if got.urlQ != tt.query.Command {
+				t.Error("expected the command in the URL")
+			}
+		})
+	}
+}
+
+func TestClient_QueryAsChunkLongCommand(t *testing.T) {
+	var method, q, chunked string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, q, chunked = r.Method, r.FormValue("q"), r.FormValue("chunked")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(Response{})
+	}))
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	command := "SELECT value FROM cpu WHERE host = '" + strings.Repeat("x", 8192) + "'"
+	cr, err := c.QueryAsChunk(Query{Command: command})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	cr.Close()
+
+	if method != "POST" || q != command || chunked != "true" {
+		t.Errorf("unexpected request.  method: %v, chunked: %q, command length: %v", method, chunked, len(q))
+	}
+}
@@ -0,0 +1,349 @@
+package client
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+// ResponseFormat is the format query results are requested in.
+type ResponseFormat string
+
+const (
+	// JSONFormat requests query results as JSON, the default.
+	JSONFormat ResponseFormat = ""
+
+	// MsgpackFormat requests query results as MessagePack, supported by
+	// InfluxDB 1.8 and later. Integers decode as int64 and floats as float64
+	// instead of json.Number, and times returned without an epoch decode as
+	// time.Time.
+	MsgpackFormat ResponseFormat = "msgpack"
+
+	// AutoFormat requests query results as MessagePack from servers whose
+	// ServerInfo has SupportsMsgpack, and as JSON from the others and from
+	// a server that could not tell its version.
+	AutoFormat ResponseFormat = "auto"
+)
+
+const (
+	jsonContentType    = "application/json"
+	msgpackContentType = "application/x-msgpack"
+)
+
+// msgpackDecoder reads a stream of MessagePack encoded responses.
+type msgpackDecoder struct {
+	r   *bufio.Reader
+	buf []byte
+}
+
+func newMsgpackDecoder(r io.Reader) *msgpackDecoder {
+	return &msgpackDecoder{r: bufio.NewReader(r)}
+}
+
+// Decode reads the next response of the stream. It returns io.EOF if the
+// stream ended before a new response.
+func (d *msgpackDecoder) Decode(resp *Response) error {
+	if _, err := d.r.Peek(1); err != nil {
+		return err
+	}
+	v, err := d.value()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return err
+	}
+
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("msgpack: expected a response map, got %T", v)
+	}
+	*resp = Response{}
+	if s, ok := m["error"].(string); ok {
+		resp.Err = s
+	}
+	results, _ := m["results"].([]interface{})
+	for _, r := range results {
+		rm, ok := r.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("msgpack: expected a result map, got %T", r)
+		}
+		result, err := decodeMsgpackResult(rm)
+		if err != nil {
+			return err
+		}
+		resp.Results = append(resp.Results, result)
+	}
+	return nil
+}
+
+func decodeMsgpackResult(m map[string]interface{}) (Result, error) {
+	var result Result
+	if id, ok := m["statement_id"].(int64); ok {
+		result.StatementId = int(id)
+	}
+	if s, ok := m["error"].(string); ok {
+		result.Err = s
+	}
+	result.Partial, _ = m["partial"].(bool)
+	messages, _ := m["messages"].([]interface{})
+	for _, msg := range messages {
+		mm, _ := msg.(map[string]interface{})
+		level, _ := mm["level"].(string)
+		text, _ := mm["text"].(string)
+		result.Messages = append(result.Messages, &Message{Level: level, Text: text})
+	}
+	series, _ := m["series"].([]interface{})
+	for _, s := range series {
+		sm, ok := s.(map[string]interface{})
+		if !ok {
+			return result, fmt.Errorf("msgpack: expected a series map, got %T", s)
+		}
+		row, err := decodeMsgpackRow(sm)
+		if err != nil {
+			return result, err
+		}
+		result.Series = append(result.Series, row)
+	}
+	return result, nil
+}
+
+func decodeMsgpackRow(m map[string]interface{}) (models.Row, error) {
+	var row models.Row
+	row.Name, _ = m["name"].(string)
+	row.Partial, _ = m["partial"].(bool)
+	if tags, ok := m["tags"].(map[string]interface{}); ok {
+		row.Tags = make(map[string]string, len(tags))
+		for k, v := range tags {
+			row.Tags[k], _ = v.(string)
+		}
+	}
+	columns, _ := m["columns"].([]interface{})
+	for _, c := range columns {
+		name, ok := c.(string)
+		if !ok {
+			return row, fmt.Errorf("msgpack: expected a column name, got %T", c)
+		}
+		row.Columns = append(row.Columns, name)
+	}
+	values, _ := m["values"].([]interface{})
+	if len(values) > 0 {
+		row.Values = make([][]interface{}, len(values))
+	}
+	for i, v := range values {
+		vs, ok := v.([]interface{})
+		if !ok {
+			return row, fmt.Errorf("msgpack: expected an array of values, got %T", v)
+		}
+		row.Values[i] = vs
+	}
+	return row, nil
+}
+
+// value decodes a single MessagePack value. Integers decode as int64, or
+// uint64 when they do not fit, floats as float64 and map keys as strings.
+func (d *msgpackDecoder) value() (interface{}, error) {
+	c, err := d.r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xf0 == 0x80:
+		return d.mapValue(int(c & 0x0f))
+	case c&0xf0 == 0x90:
+		return d.arrayValue(int(c & 0x0f))
+	case c&0xe0 == 0xa0:
+		return d.str(int(c & 0x1f))
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.uint(1 << (c - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		b, err := d.bytes(int(n))
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), b...), nil
+	case 0xc7, 0xc8, 0xc9:
+		n, err := d.uint(1 << (c - 0xc7))
+		if err != nil {
+			return nil, err
+		}
+		return d.ext(int(n))
+	case 0xca:
+		n, err := d.uint(4)
+		return float64(math.Float32frombits(uint32(n))), err
+	case 0xcb:
+		n, err := d.uint(8)
+		return math.Float64frombits(n), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		n, err := d.uint(1 << (c - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		if n > math.MaxInt64 {
+			return n, nil
+		}
+		return int64(n), nil
+	case 0xd0:
+		n, err := d.uint(1)
+		return int64(int8(n)), err
+	case 0xd1:
+		n, err := d.uint(2)
+		return int64(int16(n)), err
+	case 0xd2:
+		n, err := d.uint(4)
+		return int64(int32(n)), err
+	case 0xd3:
+		n, err := d.uint(8)
+		return int64(n), err
+	case 0xd4, 0xd5, 0xd6, 0xd7, 0xd8:
+		return d.ext(1 << (c - 0xd4))
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.uint(1 << (c - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return d.str(int(n))
+	case 0xdc, 0xdd:
+		n, err := d.uint(2 << (c - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return d.arrayValue(int(n))
+	case 0xde, 0xdf:
+		n, err := d.uint(2 << (c - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return d.mapValue(int(n))
+	}
+	return nil, fmt.Errorf("msgpack: invalid type byte 0x%02x", c)
+}
+
+// bytes reads the next n bytes. The result is only valid until the next read.
+func (d *msgpackDecoder) bytes(n int) ([]byte, error) {
+	if n < 0 {
+		return nil, errors.New("msgpack: invalid length")
+	}
+	if cap(d.buf) < n {
+		d.buf = make([]byte, n)
+	}
+	b := d.buf[:n]
+	if _, err := io.ReadFull(d.r, b); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return b, nil
+}
+
+// uint reads a big endian unsigned integer of the given size in bytes.
+func (d *msgpackDecoder) uint(size int) (uint64, error) {
+	b, err := d.bytes(size)
+	if err != nil {
+		return 0, err
+	}
+	switch size {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	default:
+		return binary.BigEndian.Uint64(b), nil
+	}
+}
+
+func (d *msgpackDecoder) str(n int) (interface{}, error) {
+	b, err := d.bytes(n)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (d *msgpackDecoder) arrayValue(n int) (interface{}, error) {
+	a := make([]interface{}, n)
+	for i := range a {
+		v, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		a[i] = v
+	}
+	return a, nil
+}
+
+func (d *msgpackDecoder) mapValue(n int) (interface{}, error) {
+	m := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		k, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := k.(string)
+		if !ok {
+			return nil, fmt.Errorf("msgpack: unsupported map key type %T", k)
+		}
+		v, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		m[key] = v
+	}
+	return m, nil
+}
+
+// ext decodes an extension value of n bytes. Times are the only extensions
+// sent by InfluxDB, either as the msgpack timestamp type (-1) or as the type
+// 5 used by github.com/tinylib/msgp.
+func (d *msgpackDecoder) ext(n int) (interface{}, error) {
+	typ, err := d.r.ReadByte()
+	if err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b, err := d.bytes(n)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case int8(typ) == -1 && n == 4:
+		return time.Unix(int64(binary.BigEndian.Uint32(b)), 0).UTC(), nil
+	case int8(typ) == -1 && n == 8:
+		v := binary.BigEndian.Uint64(b)
+		return time.Unix(int64(v&0x3ffffffff), int64(v>>34)).UTC(), nil
+	case int8(typ) == -1 && n == 12:
+		nsec := binary.BigEndian.Uint32(b[:4])
+		sec := int64(binary.BigEndian.Uint64(b[4:]))
+		return time.Unix(sec, int64(nsec)).UTC(), nil
+	case typ == 5 && n == 12:
+		sec := int64(binary.BigEndian.Uint64(b[:8]))
+		nsec := int32(binary.BigEndian.Uint32(b[8:]))
+		return time.Unix(sec, int64(nsec)).UTC(), nil
+	}
+	return nil, fmt.Errorf("msgpack: unsupported extension type %d of %d bytes", int8(typ), n)
+}
@@ -0,0 +1,312 @@
+package client
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+// msgpackWriter encodes responses the way InfluxDB 1.8 does.
+type msgpackWriter struct {
+	bytes.Buffer
+}
+
+func (w *msgpackWriter) header(fix, base byte, n int) {
+	switch {
+	case n < 16:
+		w.WriteByte(fix | byte(n))
+	case n <= math.MaxUint16:
+		w.WriteByte(base)
+		binary.Write(w, binary.BigEndian, uint16(n))
+	default:
+		w.WriteByte(base + 1)
+		binary.Write(w, binary.BigEndian, uint32(n))
+	}
+}
+
+func (w *msgpackWriter) str(s string) {
+	if len(s) < 32 {
+		w.WriteByte(0xa0 | byte(len(s)))
+	} else {
+		w.WriteByte(0xd9)
+		w.WriteByte(byte(len(s)))
+	}
+	w.WriteString(s)
+}
+
+func (w *msgpackWriter) value(v interface{}) {
+	switch v := v.(type) {
+	case nil:
+		w.WriteByte(0xc0)
+	case bool:
+		if v {
+			w.WriteByte(0xc3)
+		} else {
+			w.WriteByte(0xc2)
+		}
+	case int64:
+		w.WriteByte(0xd3)
+		binary.Write(w, binary.BigEndian, v)
+	case float64:
+		w.WriteByte(0xcb)
+		binary.Write(w, binary.BigEndian, math.Float64bits(v))
+	case string:
+		w.str(v)
+	case time.Time:
+		w.Write([]byte{0xc7, 12, 5})
+		binary.Write(w, binary.BigEndian, v.Unix())
+		binary.Write(w, binary.BigEndian, int32(v.Nanosecond()))
+	}
+}
+
+func (w *msgpackWriter) response(resp Response) {
+	w.header(0x80, 0xde, 1)
+	if resp.Err != "" {
+		w.str("error")
+		w.str(resp.Err)
+		return
+	}
+	w.str("results")
+	w.header(0x90, 0xdc, len(resp.Results))
+	for _, result := range resp.Results {
+		if result.Err != "" {
+			w.header(0x80, 0xde, 2)
+			w.str("statement_id")
+			w.value(int64(result.StatementId))
+			w.str("error")
+			w.str(result.Err)
+			continue
+		}
+		if result.Partial {
+			w.header(0x80, 0xde, 3)
+			w.str("partial")
+			w.value(true)
+		} else {
+			w.header(0x80, 0xde, 2)
+		}
+		w.str("statement_id")
+		w.value(int64(result.StatementId))
+		w.str("series")
+		w.header(0x90, 0xdc, len(result.Series))
+		for _, row := range result.Series {
+			n := 3
+			if len(row.Tags) > 0 {
+				n++
+			}
+			if row.Partial {
+				n++
+			}
+			w.header(0x80, 0xde, n)
+			w.str("name")
+			w.str(row.Name)
+			if len(row.Tags) > 0 {
+				w.str("tags")
+				w.header(0x80, 0xde, len(row.Tags))
+				keys := make([]string, 0, len(row.Tags))
+				for k := range row.Tags {
+					keys = append(keys, k)
+				}
+				sort.Strings(keys)
+				for _, k := range keys {
+					w.str(k)
+					w.str(row.Tags[k])
+				}
+			}
+			w.str("columns")
+			w.header(0x90, 0xdc, len(row.Columns))
+			for _, c := range row.Columns {
+				w.str(c)
+			}
+			w.str("values")
+			w.header(0x90, 0xdc, len(row.Values))
+			for _, values := range row.Values {
+				w.header(0x90, 0xdc, len(values))
+				for _, v := range values {
+					w.value(v)
+				}
+			}
+			if row.Partial {
+				w.str("partial")
+				w.value(true)
+			}
+		}
+	}
+}
+
+func TestMsgpackDecoder_Values(t *testing.T) {
+	for _, tt := range []struct {
+		in  []byte
+		exp interface{}
+	}{
+		{in: []byte{0x05}, exp: int64(5)},
+		{in: []byte{0xff}, exp: int64(-1)},
+		{in: []byte{0xcc, 0xff}, exp: int64(255)},
+		{in: []byte{0xcd, 0x01, 0x00}, exp: int64(256)},
+		{in: []byte{0xcf, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, exp: uint64(math.MaxUint64)},
+		{in: []byte{0xd0, 0x80}, exp: int64(-128)},
+		{in: []byte{0xd1, 0xff, 0x00}, exp: int64(-256)},
+		{in: []byte{0xd2, 0xff, 0xff, 0xff, 0xfe}, exp: int64(-2)},
+		{in: []byte{0xca, 0x3f, 0xc0, 0x00, 0x00}, exp: float64(1.5)},
+		{in: []byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}, exp: float64(1.5)},
+		{in: []byte{0xc0}, exp: nil},
+		{in: []byte{0xc3}, exp: true},
+		{in: []byte{0xa2, 'h', 'i'}, exp: "hi"},
+		{in: []byte{0xd9, 0x02, 'h', 'i'}, exp: "hi"},
+		{in: []byte{0xc4, 0x01, 0x07}, exp: []byte{7}},
+		{in: []byte{0x92, 0x01, 0xc2}, exp: []interface{}{int64(1), false}},
+		{in: []byte{0x81, 0xa1, 'k', 0x01}, exp: map[string]interface{}{"k": int64(1)}},
+		{in: []byte{0xd6, 0xff, 0x00, 0x00, 0x00, 0x02}, exp: time.Unix(2, 0).UTC()},
+		{in: []byte{0xc7, 12, 5, 0, 0, 0, 0, 0, 0, 0, 2, 0, 0, 0, 3}, exp: time.Unix(2, 3).UTC()},
+	} {
+		d := newMsgpackDecoder(bytes.NewReader(tt.in))
+		v, err := d.value()
+		if err != nil {
+			t.Errorf("%x: unexpected error: %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(v, tt.exp) {
+			t.Errorf("%x: got %#v, exp %#v", tt.in, v, tt.exp)
+		}
+	}
+}
+
+func TestMsgpackDecoder_Truncated(t *testing.T) {
+	var w msgpackWriter
+	w.response(Response{Results: []Result{{Series: []models.Row{{Name: "cpu", Columns: []string{"value"}, Values: [][]interface{}{{int64(1)}}}}}}})
+	b := w.Bytes()
+
+	var resp Response
+	if err := newMsgpackDecoder(bytes.NewReader(b[:len(b)-1])).Decode(&resp); err != io.ErrUnexpectedEOF {
+		t.Errorf("unexpected error: got %v, exp %v", err, io.ErrUnexpectedEOF)
+	}
+	if err := newMsgpackDecoder(bytes.NewReader(nil)).Decode(&resp); err != io.EOF {
+		t.Errorf("unexpected error: got %v, exp %v", err, io.EOF)
+	}
+}
+
+func newMsgpackServer(t *testing.T, chunks ...Response) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Accept"); got != "application/x-msgpack" {
+			t.Errorf("unexpected Accept header: %q", got)
+		}
+		var mw msgpackWriter
+		for _, c := range chunks {
+			mw.response(c)
+		}
+		w.Header().Set("Content-Type", "application/x-msgpack")
+		w.Header().Set("X-Influxdb-Version", "1.8.10")
+		w.WriteHeader(http.StatusOK)
+		w.Write(mw.Bytes())
+	}))
+}
+
+func TestClient_QueryMsgpack(t *testing.T) {
+	ts0 := time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)
+	ts := newMsgpackServer(t, Response{Results: []Result{{StatementId: 0, Series: []models.Row{{
+		Name:    "cpu",
+		Tags:    map[string]string{"host": "a"},
+		Columns: []string{"time", "count", "value", "up", "note"},
+		Values:  [][]interface{}{{ts0, int64(3), 1.5, true, nil}},
+	}}}}})
+	defer ts.Close()
+
+	c, err := NewHTTPClient(HTTPConfig{Addr: ts.URL, ResponseFormat: MsgpackFormat})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer c.Close()
+
+	resp, err := c.Query(Query{Command: "SELECT * FROM cpu"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	row := resp.Results[0].Series[0]
+	if row.Name != "cpu" || row.Tags["host"] != "a" || len(row.Columns) != 5 {
+		t.Errorf("unexpected row: %+v", row)
+	}
+	if exp := []interface{}{ts0, int64(3), 1.5, true, nil}; !reflect.DeepEqual(row.Values[0], exp) {
+		t.Errorf("unexpected values: got %#v, exp %#v", row.Values[0], exp)
+	}
+}
+
+func TestClient_QueryAsChunkMsgpack(t *testing.T) {
+	ts := newMsgpackServer(t,
+		Response{Results: []Result{{Series: []models.Row{{Name: "cpu", Columns: []string{"time", "value"}, Values: [][]interface{}{{int64(1), 1.0}}, Partial: true}}}}},
+		Response{Results: []Result{{Series: []models.Row{{Name: "cpu", Columns: []string{"time", "value"}, Values: [][]interface{}{{int64(2), 2.0}}}}}}},
+		Response{Results: []Result{{Err: "boom"}}},
+	)
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, ResponseFormat: MsgpackFormat})
+	defer c.Close()
+
+	cr, err := c.QueryAsChunk(Query{Command: "SELECT * FROM cpu", Precision: "s", Chunked: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	it := NewRowIterator(cr)
+	defer it.Close()
+	var rows []int64
+	for it.Next() {
+		rows = append(rows, it.Value(0).(int64))
+	}
+	if !reflect.DeepEqual(rows, []int64{1, 2}) {
+		t.Errorf("unexpected rows: %v", rows)
+	}
+	if err := it.Err(); err == nil || err.Error() != "boom" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestNewHTTPClient_InvalidResponseFormat(t *testing.T) {
+	if _, err := NewHTTPClient(HTTPConfig{Addr: "http://localhost:8086", ResponseFormat: "xml"}); err == nil {
+		t.Error("expected an error for an unsupported response format")
+	}
+}
+
+func benchmarkResponse() Response {
+	row := models.Row{Name: "cpu", Tags: map[string]string{"host": "a"}, Columns: []string{"time", "value", "count"}}
+	for i := 0; i < 1000; i++ {
+		row.Values = append(row.Values, []interface{}{int64(i), float64(i) / 3, int64(i)})
+	}
+	return Response{Results: []Result{{Series: []models.Row{row}}}}
+}
+
+func BenchmarkDecode_JSON(b *testing.B) {
+	body, _ := json.Marshal(benchmarkResponse())
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var resp Response
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		if err := dec.Decode(&resp); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecode_Msgpack(b *testing.B) {
+	var w msgpackWriter
+	w.response(benchmarkResponse())
+	body := w.Bytes()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var resp Response
+		if err := newMsgpackDecoder(bytes.NewReader(body)).Decode(&resp); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package client
+
+import (
+	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+// AddTag sets the tag key of the point to value, replacing the value it had.
+// It returns a *ValidationError, leaving the point as it was, for a key or
+// value the server would reject.
+//
+// The mutators of a Point are not safe for concurrent use with the other
+// methods of the point, and a point whose series changed after it was added
+// to a batch sorted on write is not sorted again.
+func (p *Point) AddTag(key, value string) error {
+	if err := checkKey(key, "tag"); err != nil {
+		return err
+	}
+	switch {
+	case value == "":
+		return &ValidationError{Index: -1, Key: key, Reason: "tag value is empty"}
+	case !models.ValidKeyToken(value):
+		return &ValidationError{Index: -1, Key: key, Reason: "tag value contains an unprintable character"}
+	}
+	// The tags of a parsed point refer to the buffer it was parsed from.
+	tags := p.pt.Tags().Clone()
+	tags.SetString(key, value)
+	p.pt.SetTags(tags)
+	return nil
+}
+
+// RemoveTag removes the tag key from the point, if it has it.
+func (p *Point) RemoveTag(key string) {
+	tags := p.pt.Tags()
+	if tags.Get([]byte(key)) == nil {
+		return
+	}
+	kept := make(models.Tags, 0, len(tags)-1)
+	for _, tag := range tags {
+		if string(tag.Key) != key {
+			kept = append(kept, tag.Clone())
+		}
+	}
+	p.pt.SetTags(kept)
+}
+
+// AddField sets the field key of the point to value, replacing the value it
+// had. The value is converted as by NewPoint. It returns an error, leaving the
+// point as it was, for a key the server would reject, a nil value and a value
+// NewPoint rejects.
+func (p *Point) AddField(key string, value interface{}) error {
+	if err := checkKey(key, "field"); err != nil {
+		return err
+	}
+	if value == nil {
+		return &ValidationError{Index: -1, Key: key, Reason: "field value is nil"}
+	}
+	converted, err := PointOptions{}.convertFields(map[string]interface{}{key: value})
+	if err != nil {
+		return err
+	}
+
+	current, err := p.pt.Fields()
+	if err != nil {
+		return err
+	}
+	// The fields of a point are cached by it, and must not be changed in
+	// place.
+	fields := make(models.Fields, len(current)+1)
+	for k, v := range current {
+		fields[k] = v
+	}
+	fields[key] = converted[key]
+
+	pt, err := models.NewPoint(string(p.pt.Name()), p.pt.Tags(), fields, p.pt.Time())
+	if err != nil {
+		return err
+	}
+	p.pt = pt
+	return nil
+}
+
+// SetTime sets the timestamp of the point. The zero time leaves it to the
+// server to assign one.
+func (p *Point) SetTime(t time.Time) {
+	p.pt.SetTime(t)
+}
+
+// Clone returns a copy of the point that shares no memory with it, so that
+// the mutators of either leave the other as it is.
+func (p *Point) Clone() *Point {
+	// The binary form holds the key and fields of the point as they are,
+	// unlike the maps of Tags and Fields that would be encoded again.
+	if b, err := p.pt.MarshalBinary(); err == nil {
+		if pt, err := models.NewPointFromBytes(b); err == nil {
+			return &Point{pt: pt}
+		}
+	}
+	// Only a point without fields fails, which NewPoint does not create
+	// and the server rejects.
+	return &Point{pt: p.pt}
+}
+
+// WithName returns a copy of the point in the measurement name, with the tags,
+// fields and timestamp of the point. It returns a *ValidationError for a
+// name the server would reject.
+func (p *Point) WithName(name string) (*Point, error) {
+	switch {
+	case name == "":
+		return nil, &ValidationError{Index: -1, Reason: "missing measurement"}
+	case !models.ValidKeyToken(name):
+		return nil, &ValidationError{Index: -1, Key: name, Reason: "measurement contains an unprintable character"}
+	}
+	c := p.Clone()
+	c.pt.SetName(name)
+	return c, nil
+}
+
+// FilterFields returns a copy of the point with only the fields whose key
+// keep returns true for, of the same types, such as integers. It returns a
+// *ValidationError if no field is kept.
+func (p *Point) FilterFields(keep func(key string) bool) (*Point, error) {
+	fields, err := p.pt.Fields()
+	if err != nil {
+		return nil, err
+	}
+	kept := make(models.Fields, len(fields))
+	for k, v := range fields {
+		if keep(k) {
+			kept[k] = v
+		}
+	}
+	if len(kept) == 0 {
+		return nil, &ValidationError{Index: -1, Reason: "missing fields"}
+	}
+	pt, err := models.NewPoint(string(p.pt.Name()), p.pt.Tags().Clone(), kept, p.pt.Time())
+	if err != nil {
+		return nil, err
+	}
+	return &Point{pt: pt}, nil
+}
+
+// checkKey returns a *ValidationError for a tag or field key the server
+// rejects.
+func checkKey(key, kind string) *ValidationError {
+	switch {
+	case key == "":
+		return &ValidationError{Index: -1, Reason: kind + " key is empty"}
+	case key == "time":
+		return &ValidationError{Index: -1, Key: key, Reason: kind + " key is reserved"}
+	case !models.ValidKeyToken(key):
+		return &ValidationError{Index: -1, Key: key, Reason: kind + " key contains an unprintable character"}
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package client
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+func TestPoint_Mutators(t *testing.T) {
+	p := mustPoint(t, "cpu", map[string]string{"region": "eu", "host": "a"}, map[string]interface{}{"v": 1.5}, time.Unix(1, 0))
+
+	if err := p.AddTag("dc", "fra 1"); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if err := p.AddTag("host", "b"); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	p.RemoveTag("region")
+	p.RemoveTag("missing")
+	if err := p.AddField("processed", uint8(3)); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if err := p.AddField("v", "a \"quoted\" value"); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	p.SetTime(time.Unix(2, 0))
+
+	exp := `cpu,dc=fra\ 1,host=b processed=3u,v="a \"quoted\" value" 2000000000`
+	if p.String() != exp {
+		t.Errorf("unexpected point.\nexpected %s\nactual   %s", exp, p.String())
+	}
+	// The point must read back as it is written.
+	parsed, err := models.ParsePointsString(p.String())
+	if err != nil || len(parsed) != 1 || parsed[0].String() != exp {
+		t.Errorf("unexpected parse of %s: %v, %v", p.String(), parsed, err)
+	}
+}
+
+func TestPoint_MutatorsParsed(t *testing.T) {
+	buf := []byte("cpu,host=a v=1 1\n")
+	pts, err := models.ParsePoints(buf)
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	p := NewPointFrom(pts[0])
+	if err := p.AddTag("zone", "z1"); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	// The series of the point no longer depends on the buffer it was parsed
+	// from.
+	copy(buf, "xxxxxxxxxx")
+	if exp := "cpu,host=a,zone=z1 v=1 1"; p.String() != exp {
+		t.Errorf("unexpected point.\nexpected %s\nactual   %s", exp, p.String())
+	}
+}
+
+func TestPoint_MutatorsInvalid(t *testing.T) {
+	p := mustPoint(t, "cpu", map[string]string{"host": "a"}, map[string]interface{}{"v": 1.0}, time.Unix(1, 0))
+	exp := p.String()
+
+	for _, tt := range []struct {
+		name string
+		err  error
+	}{
+		{name: "empty tag key", err: p.AddTag("", "x")},
+		{name: "reserved tag key", err: p.AddTag("time", "x")},
+		{name: "empty tag value", err: p.AddTag("dc", "")},
+		{name: "unprintable tag value", err: p.AddTag("dc", "a\x00b")},
+		{name: "empty field key", err: p.AddField("", 1)},
+		{name: "reserved field key", err: p.AddField("time", 1)},
+		{name: "unprintable field key", err: p.AddField("a\nb", 1)},
+		{name: "nil field", err: p.AddField("x", nil)},
+	} {
+		var ve *ValidationError
+		if !errors.As(tt.err, &ve) {
+			t.Errorf("%s: unexpected error.  expected a *ValidationError, actual %v", tt.name, tt.err)
+		}
+	}
+	if err := p.AddField("x", math.NaN()); err == nil {
+		t.Error("expected an error for a NaN field")
+	}
+	if p.String() != exp {
+		t.Errorf("unexpected point after invalid mutations.\nexpected %s\nactual   %s", exp, p.String())
+	}
+}
+
+func TestPoint_Clone(t *testing.T) {
+	buf := []byte(`cpu,host=a,zone=z1 count=3i,n=7u,ok=true,ratio=0.5,s="x" 1` + "\n")
+	pts, err := models.ParsePoints(buf)
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	p := NewPointFrom(pts[0])
+	const exp = `cpu,host=a,zone=z1 count=3i,n=7u,ok=true,ratio=0.5,s="x" 1`
+
+	c := p.Clone()
+	if err := c.AddTag("host", "b"); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	c.SetTime(time.Unix(0, 2))
+	if p.String() != exp {
+		t.Errorf("unexpected original point.\nexpected %s\nactual   %s", exp, p.String())
+	}
+
+	// The copy does not depend on the buffer the point was parsed from.
+	c = p.Clone()
+	copy(buf, "xxxxxxxxxxxxxxxxxxxxxxxxxxxxxx")
+	if c.String() != exp {
+		t.Errorf("unexpected copy.\nexpected %s\nactual   %s", exp, c.String())
+	}
+
+	renamed, err := c.WithName("cpu 5m")
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if s, exp := renamed.String(), `cpu\ 5m,host=a,zone=z1 count=3i,n=7u,ok=true,ratio=0.5,s="x" 1`; s != exp {
+		t.Errorf("unexpected renamed point.\nexpected %s\nactual   %s", exp, s)
+	}
+	if c.String() != exp {
+		t.Errorf("unexpected copy after WithName.\nexpected %s\nactual   %s", exp, c.String())
+	}
+	if _, err := c.WithName(""); err == nil {
+		t.Error("expected an error for an empty measurement")
+	}
+
+	filtered, err := renamed.FilterFields(func(k string) bool { return k == "count" || k == "n" })
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if s, exp := filtered.String(), `cpu\ 5m,host=a,zone=z1 count=3i,n=7u 1`; s != exp {
+		t.Errorf("unexpected filtered point.\nexpected %s\nactual   %s", exp, s)
+	}
+	var ve *ValidationError
+	if _, err := renamed.FilterFields(func(string) bool { return false }); !errors.As(err, &ve) {
+		t.Errorf("unexpected error.  expected a *ValidationError, actual %v", err)
+	}
+}
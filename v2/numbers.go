@@ -0,0 +1,81 @@
+package client
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// NumberDecoding selects how the HTTP client decodes the numeric values of
+// query results.
+type NumberDecoding int
+
+const (
+	// JSONNumberDecoding leaves the numbers of JSON results as
+	// json.Number, the default. MsgpackFormat results keep their int64 and
+	// float64 values.
+	JSONNumberDecoding NumberDecoding = iota
+
+	// Float64Decoding decodes every number as a float64, but the epoch
+	// times of the time column, which are decoded as int64 so that they
+	// keep their precision.
+	Float64Decoding
+
+	// Int64Decoding decodes the numbers that are integers as int64 and the
+	// others as float64. JSON does not tell a float with an integral value
+	// from an integer, so such a float decodes as int64 too, unlike with
+	// MsgpackFormat.
+	Int64Decoding
+)
+
+// convertNumbers converts the numbers of the values of results as selected by
+// mode.
+func convertNumbers(results []Result, mode NumberDecoding) {
+	if mode == JSONNumberDecoding {
+		return
+	}
+	for _, result := range results {
+		for _, row := range result.Series {
+			timeColumn := -1
+			for i, col := range row.Columns {
+				if col == "time" {
+					timeColumn = i
+					break
+				}
+			}
+			for _, values := range row.Values {
+				for i, v := range values {
+					m := mode
+					if i == timeColumn {
+						m = Int64Decoding
+					}
+					values[i] = convertNumber(v, m)
+				}
+			}
+		}
+	}
+}
+
+// convertNumber returns the number v as selected by mode. Other values, and
+// numbers that do not fit the type, are returned as is.
+func convertNumber(v interface{}, mode NumberDecoding) interface{} {
+	switch n := v.(type) {
+	case json.Number:
+		if mode == Int64Decoding {
+			if i, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+				return i
+			}
+		}
+		if f, err := strconv.ParseFloat(string(n), 64); err == nil {
+			return f
+		}
+	case int64:
+		if mode == Float64Decoding {
+			return float64(n)
+		}
+	case uint64:
+		if mode == Float64Decoding {
+			return float64(n)
+		}
+	}
+	return v
+}
@@ -0,0 +1,119 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+)
+
+const mixedNumbersBody = `{"results":[{"statement_id":0,"series":[{"name":"cpu","columns":["time","i","f","b","s","n"],"values":[[1500000000000000001,3,2.5,true,"x",null],[1500000000000000002,-7,1e300,false,"",null],[1500000000000000003,123456789012345678901,2,true,"7",null]]}]}]}` + "\n"
+
+func TestClient_QueryNumberDecoding(t *testing.T) {
+	ts := newCannedServer(mixedNumbersBody)
+	defer ts.Close()
+
+	for _, tt := range []struct {
+		decoding NumberDecoding
+		exp      string
+	}{
+		{
+			decoding: JSONNumberDecoding,
+			exp:      "[[1500000000000000001 json.Number 3 json.Number 2.5 json.Number true bool x string <nil> <nil>] [1500000000000000002 json.Number -7 json.Number 1e300 json.Number false bool  string <nil> <nil>] [1500000000000000003 json.Number 123456789012345678901 json.Number 2 json.Number true bool 7 string <nil> <nil>]]",
+		},
+		{
+			decoding: Float64Decoding,
+			exp:      "[[1500000000000000001 int64 3 float64 2.5 float64 true bool x string <nil> <nil>] [1500000000000000002 int64 -7 float64 1e+300 float64 false bool  string <nil> <nil>] [1500000000000000003 int64 1.2345678901234568e+20 float64 2 float64 true bool 7 string <nil> <nil>]]",
+		},
+		{
+			decoding: Int64Decoding,
+			exp:      "[[1500000000000000001 int64 3 int64 2.5 float64 true bool x string <nil> <nil>] [1500000000000000002 int64 -7 int64 1e+300 float64 false bool  string <nil> <nil>] [1500000000000000003 int64 1.2345678901234568e+20 float64 2 int64 true bool 7 string <nil> <nil>]]",
+		},
+	} {
+		c, err := NewHTTPClient(HTTPConfig{Addr: ts.URL, NumberDecoding: tt.decoding})
+		if err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+		defer c.Close()
+
+		for _, chunked := range []bool{false, true} {
+			resp, err := c.Query(Query{Command: "SELECT * FROM cpu", Epoch: "ns", Chunked: chunked})
+			if err != nil {
+				t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+			}
+			if got := typedValues(resp.Results[0].Series[0].Values); got != tt.exp {
+				t.Errorf("unexpected values for %d, chunked %v.\nexpected %s\nactual   %s", tt.decoding, chunked, tt.exp, got)
+			}
+		}
+
+		// As are the responses of a chunked query.
+		cr, err := c.QueryAsChunk(Query{Command: "SELECT * FROM cpu", Epoch: "ns"})
+		if err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+		resp, err := cr.NextResponse()
+		if err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+		if got := typedValues(resp.Results[0].Series[0].Values); got != tt.exp {
+			t.Errorf("unexpected chunked values for %d.\nexpected %s\nactual   %s", tt.decoding, tt.exp, got)
+		}
+		if _, err := cr.NextResponse(); err != io.EOF {
+			t.Errorf("unexpected error.  expected %v, actual %v", io.EOF, err)
+		}
+		cr.Close()
+	}
+}
+
+// typedValues formats values along with their types.
+func typedValues(values [][]interface{}) string {
+	var rows [][]string
+	for _, row := range values {
+		var typed []string
+		for _, v := range row {
+			if _, ok := v.(json.Number); ok {
+				typed = append(typed, fmt.Sprint(v), "json.Number")
+				continue
+			}
+			typed = append(typed, fmt.Sprint(v), fmt.Sprintf("%T", v))
+		}
+		rows = append(rows, typed)
+	}
+	return fmt.Sprint(rows)
+}
+
+func TestClient_QueryNumberDecodingScan(t *testing.T) {
+	ts := newCannedServer(mixedNumbersBody)
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, NumberDecoding: Float64Decoding})
+	defer c.Close()
+
+	resp, err := c.Query(Query{Command: "SELECT * FROM cpu", Epoch: "ns"})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	var rows []struct {
+		Time time.Time `influx:"time"`
+		I    int64     `influx:"i"`
+		F    float64   `influx:"f"`
+		N    *float64  `influx:"n"`
+	}
+	resp.Results[0].Series[0].Values = resp.Results[0].Series[0].Values[:2]
+	if err := resp.Scan(&rows); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if len(rows) != 2 || rows[0].Time.UnixNano() != 1500000000000000001 || rows[1].I != -7 || rows[0].F != 2.5 || rows[0].N != nil {
+		t.Errorf("unexpected rows: %+v", rows)
+	}
+}
+
+func TestNewHTTPClient_InvalidNumberDecoding(t *testing.T) {
+	_, err := NewHTTPClient(HTTPConfig{Addr: "http://localhost:8086", NumberDecoding: 42})
+	var ce *ConfigError
+	if !errors.As(err, &ce) || ce.Field != "NumberDecoding" {
+		t.Errorf("unexpected error.  expected %T, actual %v", ce, err)
+	}
+}
@@ -0,0 +1,167 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// WriteOptions override the settings of a BatchPoints for a single write.
+// Empty fields keep the value of the BatchPoints.
+type WriteOptions struct {
+	Database         string
+	RetentionPolicy  string
+	WriteConsistency string
+	Precision        string
+}
+
+// OptionsWriter is implemented by all of the clients returned by this
+// package. The TCP and UDP clients only support overriding the Precision.
+type OptionsWriter interface {
+	// WriteWithOptions is like WriteContext, with the settings of bp
+	// overridden by opts.
+	WriteWithOptions(ctx context.Context, bp BatchPoints, opts WriteOptions) error
+}
+
+// UnsupportedOptionError is returned by WriteWithOptions when an option is
+// given that the transport of the client cannot apply.
+type UnsupportedOptionError struct {
+	Transport string
+	Option    string
+}
+
+func (e *UnsupportedOptionError) Error() string {
+	return fmt.Sprintf("write option %s is not supported over %s", e.Option, e.Transport)
+}
+
+// apply returns bp with the options applied.
+func (o WriteOptions) apply(bp BatchPoints) (BatchPoints, error) {
+	if o == (WriteOptions{}) {
+		return bp, nil
+	}
+	if o.Precision != "" {
+		if _, err := time.ParseDuration("1" + o.Precision); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := ParseConsistency(o.WriteConsistency); err != nil {
+		return nil, err
+	}
+	return &overriddenBatchPoints{BatchPoints: bp, opts: o}, nil
+}
+
+// checkTransport returns an *UnsupportedOptionError for the first option
+// set that a client writing over transport ignores.
+func (o WriteOptions) checkTransport(transport string) error {
+	for _, opt := range []struct {
+		name, value string
+	}{
+		{"Database", o.Database},
+		{"RetentionPolicy", o.RetentionPolicy},
+		{"WriteConsistency", o.WriteConsistency},
+	} {
+		if opt.value != "" {
+			return &UnsupportedOptionError{Transport: transport, Option: opt.name}
+		}
+	}
+	return nil
+}
+
+// overriddenBatchPoints reports the settings of opts in place of those of
+// the BatchPoints it wraps.
+type overriddenBatchPoints struct {
+	BatchPoints
+	opts WriteOptions
+}
+
+func (bp *overriddenBatchPoints) Database() string {
+	return override(bp.opts.Database, bp.BatchPoints.Database())
+}
+
+func (bp *overriddenBatchPoints) RetentionPolicy() string {
+	return override(bp.opts.RetentionPolicy, bp.BatchPoints.RetentionPolicy())
+}
+
+func (bp *overriddenBatchPoints) WriteConsistency() string {
+	return override(bp.opts.WriteConsistency, bp.BatchPoints.WriteConsistency())
+}
+
+func (bp *overriddenBatchPoints) Precision() string {
+	return override(bp.opts.Precision, bp.BatchPoints.Precision())
+}
+
+func override(option, value string) string {
+	if option != "" {
+		return option
+	}
+	return value
+}
+
+// WriteWithOptions writes bp with the settings overridden by opts.
+func (c *client) WriteWithOptions(ctx context.Context, bp BatchPoints, opts WriteOptions) error {
+	bp, err := opts.apply(bp)
+	if err != nil {
+		return err
+	}
+	return c.WriteContext(ctx, bp)
+}
+
+// WriteWithOptions writes bp with the settings overridden by opts, which
+// must not name a database, retention policy or consistency.
+func (uc *tcpclient) WriteWithOptions(ctx context.Context, bp BatchPoints, opts WriteOptions) error {
+	if err := opts.checkTransport("TCP"); err != nil {
+		return err
+	}
+	bp, err := opts.apply(bp)
+	if err != nil {
+		return err
+	}
+	return uc.WriteContext(ctx, bp)
+}
+
+// WriteWithOptions is like the TCP client's WriteWithOptions.
+func (p *tcppool) WriteWithOptions(ctx context.Context, bp BatchPoints, opts WriteOptions) error {
+	if err := opts.checkTransport("TCP"); err != nil {
+		return err
+	}
+	bp, err := opts.apply(bp)
+	if err != nil {
+		return err
+	}
+	return p.WriteContext(ctx, bp)
+}
+
+// WriteWithOptions writes bp with the settings overridden by opts, which
+// must not name a database, retention policy or consistency.
+func (uc *udpclient) WriteWithOptions(ctx context.Context, bp BatchPoints, opts WriteOptions) error {
+	if err := opts.checkTransport("UDP"); err != nil {
+		return err
+	}
+	bp, err := opts.apply(bp)
+	if err != nil {
+		return err
+	}
+	return uc.WriteContext(ctx, bp)
+}
+
+// WriteWithOptions is like the UDP client's WriteWithOptions.
+func (p *udppool) WriteWithOptions(ctx context.Context, bp BatchPoints, opts WriteOptions) error {
+	if err := opts.checkTransport("UDP"); err != nil {
+		return err
+	}
+	bp, err := opts.apply(bp)
+	if err != nil {
+		return err
+	}
+	return p.WriteContext(ctx, bp)
+}
+
+// WriteWithOptions writes bp with the settings overridden by opts to the
+// first node that accepts it.
+func (fc *FailoverClient) WriteWithOptions(ctx context.Context, bp BatchPoints, opts WriteOptions) error {
+	bp, err := opts.apply(bp)
+	if err != nil {
+		return err
+	}
+	return fc.WriteContext(ctx, bp)
+}
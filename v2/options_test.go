@@ -0,0 +1,84 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestClient_WriteWithOptions(t *testing.T) {
+	var params url.Values
+	var body string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params = r.URL.Query()
+		b, _ := ioutil.ReadAll(r.Body)
+		body = string(b)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0", RetentionPolicy: "rp0", WriteConsistency: "one", Precision: "ns"})
+	pt, _ := NewPoint("cpu", nil, map[string]interface{}{"value": 1}, time.Unix(2, 0))
+	bp.AddPoint(pt)
+
+	opts := WriteOptions{Database: "tenant1", WriteConsistency: "all", Precision: "s"}
+	if err := c.(OptionsWriter).WriteWithOptions(context.Background(), bp, opts); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	for k, exp := range map[string]string{"db": "tenant1", "rp": "rp0", "consistency": "all", "precision": "s"} {
+		if got := params.Get(k); got != exp {
+			t.Errorf("unexpected %s.  expected %q, actual %q", k, exp, got)
+		}
+	}
+	if exp := "cpu value=1i 2\n"; body != exp {
+		t.Errorf("unexpected body.  expected %q, actual %q", exp, body)
+	}
+	if bp.Database() != "db0" || bp.Precision() != "ns" {
+		t.Errorf("expected the batch to be left untouched, got %q and %q", bp.Database(), bp.Precision())
+	}
+}
+
+func TestClient_WriteWithOptionsInvalidPrecision(t *testing.T) {
+	c, _ := NewHTTPClient(HTTPConfig{Addr: "http://localhost:8086"})
+	defer c.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	if err := c.(OptionsWriter).WriteWithOptions(context.Background(), bp, WriteOptions{Precision: "x"}); err == nil {
+		t.Error("expected an error for an invalid precision")
+	}
+}
+
+func TestUDPClient_WriteWithOptionsUnsupported(t *testing.T) {
+	var logger writeLogger
+	cl := &udpclient{conn: &logger, payloadSize: UDPPayloadSize}
+
+	err := cl.WriteWithOptions(context.Background(), newTestBatch(t, 1), WriteOptions{RetentionPolicy: "rp0"})
+	var ue *UnsupportedOptionError
+	if !errors.As(err, &ue) || ue.Transport != "UDP" || ue.Option != "RetentionPolicy" {
+		t.Fatalf("unexpected error.  expected %T, actual %v", ue, err)
+	}
+	if len(logger.writes) != 0 {
+		t.Errorf("unexpected writes: %q", logger.writes)
+	}
+
+	if err := cl.WriteWithOptions(context.Background(), newTestBatch(t, 1), WriteOptions{Precision: "s"}); err != nil {
+		t.Errorf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+}
+
+func TestTCPClient_WriteWithOptionsUnsupported(t *testing.T) {
+	cl := &tcpclient{conn: &bufferConn{}, payloadSize: TCPPayloadSize}
+
+	var ue *UnsupportedOptionError
+	if err := cl.WriteWithOptions(context.Background(), newTestBatch(t, 1), WriteOptions{Database: "db0"}); !errors.As(err, &ue) {
+		t.Fatalf("unexpected error.  expected %T, actual %v", ue, err)
+	}
+}
@@ -0,0 +1,242 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ErrMultiStatementQuery is returned by QueryPaged and QueryPagedSeries for a
+// query of more than one statement, whose pages could not be told apart.
+var ErrMultiStatementQuery = errors.New("cannot paginate a query of more than one statement")
+
+// QueryPaged runs the single statement of q page by page, with a LIMIT of
+// pageSize and an OFFSET moving by pageSize, and calls fn with the response
+// of every page until fn returns false or an error, or a page is the last.
+// A page is the last when no series has pageSize rows, as the LIMIT of a
+// statement grouped by tags applies to each series. A LIMIT and OFFSET of
+// the statement bound the rows paginated over. The responses are not used
+// after fn returns.
+func QueryPaged(ctx context.Context, c Client, q Query, pageSize int, fn func(*Response) (bool, error)) error {
+	return queryPaged(ctx, c, q, pageSize, false, fn)
+}
+
+// QueryPagedSeries is like QueryPaged, paginating over the series of a
+// statement grouped by tags with SLIMIT and SOFFSET. A page is the last when
+// it has less than pageSize series.
+func QueryPagedSeries(ctx context.Context, c Client, q Query, pageSize int, fn func(*Response) (bool, error)) error {
+	return queryPaged(ctx, c, q, pageSize, true, fn)
+}
+
+func queryPaged(ctx context.Context, c Client, q Query, pageSize int, series bool, fn func(*Response) (bool, error)) error {
+	if pageSize <= 0 {
+		return fmt.Errorf("invalid page size %d", pageSize)
+	}
+	statements := q.Statements()
+	switch {
+	case len(statements) == 0:
+		return errors.New("cannot paginate a query without statements")
+	case len(statements) > 1:
+		return ErrMultiStatementQuery
+	}
+	stmt, err := parsePaging(statements[0])
+	if err != nil {
+		return err
+	}
+
+	limit, offset := &stmt.limit, &stmt.offset
+	if series {
+		limit, offset = &stmt.slimit, &stmt.soffset
+	}
+	max, start := *limit, *offset
+	if start < 0 {
+		start = 0
+	}
+	for done := 0; max < 0 || done < max; done += pageSize {
+		n := pageSize
+		if max >= 0 && max-done < n {
+			n = max - done
+		}
+		*limit, *offset = n, start+done
+		q.Command = stmt.String()
+		resp, err := queryContext(ctx, c, q)
+		if err != nil {
+			return err
+		}
+		if err := resp.Error(); err != nil {
+			return err
+		}
+		more, err := fn(resp)
+		if err != nil || !more || pageLen(resp, series) < n {
+			return err
+		}
+	}
+	return nil
+}
+
+// pageLen returns the number of series of the response of a page, or the
+// largest number of rows of one of its series.
+func pageLen(resp *Response, series bool) int {
+	var n int
+	for _, r := range resp.Results {
+		if series {
+			n += len(r.Series)
+			continue
+		}
+		for _, s := range r.Series {
+			if len(s.Values) > n {
+				n = len(s.Values)
+			}
+		}
+	}
+	return n
+}
+
+// pagedStatement is a statement without its trailing LIMIT, OFFSET, SLIMIT,
+// SOFFSET and tz clauses. The values of the missing clauses are -1.
+type pagedStatement struct {
+	base                           string
+	limit, offset, slimit, soffset int
+	tz                             string
+}
+
+// String returns the statement with its clauses, in the order InfluxQL
+// requires.
+func (s *pagedStatement) String() string {
+	var b strings.Builder
+	b.WriteString(s.base)
+	for _, clause := range []struct {
+		keyword string
+		value   int
+	}{{"LIMIT", s.limit}, {"OFFSET", s.offset}, {"SLIMIT", s.slimit}, {"SOFFSET", s.soffset}} {
+		if clause.value >= 0 {
+			b.WriteString(" " + clause.keyword + " " + strconv.Itoa(clause.value))
+		}
+	}
+	if s.tz != "" {
+		b.WriteString(" " + s.tz)
+	}
+	return b.String()
+}
+
+// parsePaging splits the trailing clauses off stmt. Those inside
+// parentheses, such as of a subquery, are part of the base statement, as are
+// quoted strings, identifiers and comments. The comments ending the base
+// statement are dropped, so that the clauses are not appended to them.
+func parsePaging(stmt string) (*pagedStatement, error) {
+	p := &pagedStatement{limit: -1, offset: -1, slimit: -1, soffset: -1}
+	unexpected := func(token string) error {
+		return fmt.Errorf("cannot paginate %q: unexpected %q after LIMIT, OFFSET, SLIMIT or SOFFSET", stmt, token)
+	}
+	// base is the end of the base statement, without the comments that
+	// follow it, and tail whether the trailing clauses started.
+	base, tail := 0, false
+	depth := 0
+	for i := 0; i < len(stmt); {
+		s := stmt[i:]
+		switch c := s[0]; {
+		case strings.HasPrefix(s, "--"):
+			if n := strings.IndexByte(s, '\n'); n >= 0 {
+				i += n + 1
+			} else {
+				i = len(stmt)
+			}
+			continue
+		case strings.HasPrefix(s, "/*"):
+			if n := strings.Index(s[2:], "*/"); n >= 0 {
+				i += n + 4
+			} else {
+				i = len(stmt)
+			}
+			continue
+		case unicode.IsSpace(rune(c)):
+			i++
+			continue
+		case isWordByte(c) && depth == 0:
+			n := 1
+			for n < len(s) && isWordByte(s[n]) {
+				n++
+			}
+			word := strings.ToUpper(s[:n])
+			if end, ok := p.clause(stmt, i, word, i+n); ok {
+				tail = true
+				i = end
+				continue
+			}
+			switch {
+			case word == "LIMIT" || word == "OFFSET" || word == "SLIMIT" || word == "SOFFSET":
+				return nil, fmt.Errorf("cannot paginate %q: %s is not followed by an integer", stmt, word)
+			case tail:
+				return nil, unexpected(s[:n])
+			}
+			i += n
+		case tail:
+			return nil, unexpected(s[:1])
+		case c == '\'' || c == '"':
+			i += quotedLen(s)
+		case c == '(':
+			depth++
+			i++
+		case c == ')':
+			depth--
+			i++
+		default:
+			i++
+		}
+		base = i
+	}
+	p.base = stmt[:base]
+	return p, nil
+}
+
+// clause records the clause of stmt starting with word at start, whose end
+// is at end, and returns the end of the clause. It returns false if word
+// does not start one of the trailing clauses.
+func (p *pagedStatement) clause(stmt string, start int, word string, end int) (int, bool) {
+	rest := strings.TrimLeftFunc(stmt[end:], unicode.IsSpace)
+	skipped := len(stmt) - end - len(rest)
+	if word == "TZ" {
+		// tz('Europe/Paris')
+		if !strings.HasPrefix(rest, "(") {
+			return 0, false
+		}
+		arg := strings.TrimLeftFunc(rest[1:], unicode.IsSpace)
+		if !strings.HasPrefix(arg, "'") {
+			return 0, false
+		}
+		after := strings.TrimLeftFunc(arg[quotedLen(arg):], unicode.IsSpace)
+		if !strings.HasPrefix(after, ")") {
+			return 0, false
+		}
+		end = len(stmt) - len(after) + 1
+		p.tz = stmt[start:end]
+		return end, true
+	}
+
+	var value *int
+	switch word {
+	case "LIMIT":
+		value = &p.limit
+	case "OFFSET":
+		value = &p.offset
+	case "SLIMIT":
+		value = &p.slimit
+	case "SOFFSET":
+		value = &p.soffset
+	default:
+		return 0, false
+	}
+	n := 0
+	for n < len(rest) && rest[n] >= '0' && rest[n] <= '9' {
+		n++
+	}
+	v, err := strconv.Atoi(rest[:n])
+	if n == 0 || err != nil {
+		return 0, false
+	}
+	*value = v
+	return end + skipped + n, true
+}
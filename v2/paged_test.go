@@ -0,0 +1,149 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+// newRows returns a series with n rows.
+func newRows(name string, n int) models.Row {
+	row := models.Row{Name: name, Columns: []string{"time", "value"}}
+	for i := 0; i < n; i++ {
+		row.Values = append(row.Values, []interface{}{i, i})
+	}
+	return row
+}
+
+func TestQueryPaged(t *testing.T) {
+	for _, tt := range []struct {
+		name, command string
+		pages         map[string][]models.Row
+		exp           []string
+	}{
+		{
+			name:    "rows",
+			command: `SELECT * FROM "cpu"`,
+			pages: map[string][]models.Row{
+				`SELECT * FROM "cpu" LIMIT 2 OFFSET 0`: {newRows("cpu", 2)},
+				`SELECT * FROM "cpu" LIMIT 2 OFFSET 2`: {newRows("cpu", 2)},
+				`SELECT * FROM "cpu" LIMIT 2 OFFSET 4`: {newRows("cpu", 1)},
+			},
+			exp: []string{`SELECT * FROM "cpu" LIMIT 2 OFFSET 0`, `SELECT * FROM "cpu" LIMIT 2 OFFSET 2`, `SELECT * FROM "cpu" LIMIT 2 OFFSET 4`},
+		},
+		{
+			name:    "exact pages",
+			command: `SELECT * FROM "cpu";`,
+			pages: map[string][]models.Row{
+				`SELECT * FROM "cpu" LIMIT 2 OFFSET 0`: {newRows("cpu", 2)},
+			},
+			exp: []string{`SELECT * FROM "cpu" LIMIT 2 OFFSET 0`, `SELECT * FROM "cpu" LIMIT 2 OFFSET 2`},
+		},
+		{
+			name:    "limit of the statement",
+			command: `SELECT * FROM "cpu" WHERE "host" = 'limit 1' GROUP BY * limit 3 offset 10 SLIMIT 5 tz('Europe/Paris')`,
+			pages: map[string][]models.Row{
+				`SELECT * FROM "cpu" WHERE "host" = 'limit 1' GROUP BY * LIMIT 2 OFFSET 10 SLIMIT 5 tz('Europe/Paris')`: {newRows("cpu", 1), newRows("cpu", 2)},
+				`SELECT * FROM "cpu" WHERE "host" = 'limit 1' GROUP BY * LIMIT 1 OFFSET 12 SLIMIT 5 tz('Europe/Paris')`: {newRows("cpu", 1)},
+			},
+			exp: []string{
+				`SELECT * FROM "cpu" WHERE "host" = 'limit 1' GROUP BY * LIMIT 2 OFFSET 10 SLIMIT 5 tz('Europe/Paris')`,
+				`SELECT * FROM "cpu" WHERE "host" = 'limit 1' GROUP BY * LIMIT 1 OFFSET 12 SLIMIT 5 tz('Europe/Paris')`,
+			},
+		},
+		{
+			name:    "subquery",
+			command: `SELECT max("mean") FROM (SELECT mean("value") FROM "cpu" GROUP BY time(1m) LIMIT 100) GROUP BY time(1h) -- hourly`,
+			exp:     []string{`SELECT max("mean") FROM (SELECT mean("value") FROM "cpu" GROUP BY time(1m) LIMIT 100) GROUP BY time(1h) LIMIT 2 OFFSET 0`},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ts, statements := newShowServer(t, tt.pages)
+			defer ts.Close()
+			c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+			defer c.Close()
+
+			var pages int
+			err := QueryPaged(context.Background(), c, NewQuery(tt.command, "db0", ""), 2, func(resp *Response) (bool, error) {
+				pages++
+				return true, nil
+			})
+			if err != nil {
+				t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+			}
+			if !reflect.DeepEqual(*statements, tt.exp) {
+				t.Errorf("unexpected statements.\nexpected %q\nactual   %q", tt.exp, *statements)
+			}
+			if pages != len(tt.exp) {
+				t.Errorf("unexpected number of pages.  expected %v, actual %v", len(tt.exp), pages)
+			}
+		})
+	}
+}
+
+func TestQueryPagedSeries(t *testing.T) {
+	ts, statements := newShowServer(t, map[string][]models.Row{
+		`SELECT last(*) FROM "cpu" GROUP BY * LIMIT 1 SLIMIT 2 SOFFSET 0`: {newRows("cpu", 1), newRows("cpu", 1)},
+		`SELECT last(*) FROM "cpu" GROUP BY * LIMIT 1 SLIMIT 2 SOFFSET 2`: {newRows("cpu", 1)},
+	})
+	defer ts.Close()
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	var series int
+	err := QueryPagedSeries(context.Background(), c, NewQuery(`SELECT last(*) FROM "cpu" GROUP BY * LIMIT 1`, "db0", ""), 2, func(resp *Response) (bool, error) {
+		series += len(resp.Results[0].Series)
+		return true, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if len(*statements) != 2 || series != 3 {
+		t.Errorf("unexpected pages: %q with %d series", *statements, series)
+	}
+}
+
+func TestQueryPaged_Stop(t *testing.T) {
+	ts, statements := newShowServer(t, map[string][]models.Row{
+		`SELECT * FROM "cpu" LIMIT 2 OFFSET 0`: {newRows("cpu", 2)},
+	})
+	defer ts.Close()
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+	q := NewQuery(`SELECT * FROM "cpu"`, "db0", "")
+
+	if err := QueryPaged(context.Background(), c, q, 2, func(*Response) (bool, error) { return false, nil }); err != nil || len(*statements) != 1 {
+		t.Errorf("unexpected stop: %v after %q", err, *statements)
+	}
+	errStop := errors.New("stop")
+	if err := QueryPaged(context.Background(), c, q, 2, func(*Response) (bool, error) { return true, errStop }); err != errStop {
+		t.Errorf("unexpected error.  expected %v, actual %v", errStop, err)
+	}
+}
+
+func TestQueryPaged_Invalid(t *testing.T) {
+	fn := func(*Response) (bool, error) { t.Error("unexpected page"); return false, nil }
+	if err := QueryPaged(context.Background(), nil, NewQuery(`SELECT * FROM "a"; SELECT * FROM "b"`, "db0", ""), 10, fn); err != ErrMultiStatementQuery {
+		t.Errorf("unexpected error.  expected %v, actual %v", ErrMultiStatementQuery, err)
+	}
+	for _, tt := range []struct {
+		command, reason string
+	}{
+		{`SELECT * FROM "cpu" LIMIT $limit`, "not followed by an integer"},
+		{`SELECT * FROM "cpu" LIMIT 10 WHERE "host" = 'a'`, `unexpected "WHERE"`},
+		{`SELECT * FROM "cpu" LIMIT 10 "x"`, `unexpected "\""`},
+		{` -- nothing`, "without statements"},
+	} {
+		err := QueryPaged(context.Background(), nil, NewQuery(tt.command, "db0", ""), 10, fn)
+		if err == nil || !strings.Contains(err.Error(), tt.reason) {
+			t.Errorf("unexpected error for %s.  expected %q, actual %v", tt.command, tt.reason, err)
+		}
+	}
+	if err := QueryPaged(context.Background(), nil, NewQuery(`SELECT * FROM "cpu"`, "db0", ""), 0, fn); err == nil {
+		t.Error("expected an error for a page size of 0")
+	}
+}
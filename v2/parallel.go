@@ -0,0 +1,206 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// ParallelWriteOptions sets how WriteParallel cuts a batch into shards and
+// writes them.
+type ParallelWriteOptions struct {
+	// Shards is the number of shards the batch is cut into, and ShardBytes
+	// the size, see BatchPoints.Size, a shard is cut at. One of them must
+	// be set; ShardBytes wins if both are.
+	Shards     int
+	ShardBytes int
+
+	// Concurrency is the number of shards written at the same time,
+	// defaults to the number of shards or runtime.NumCPU, the smaller one.
+	Concurrency int
+
+	// BySeries puts the points of a series in the same shard, chosen by a
+	// hash of their series key, so that they are written in the order of
+	// the batch. By default the shards are consecutive runs of points of
+	// the batch, which splits a series across shards that are written in
+	// no particular order. The shards of BySeries are sized by the number
+	// of shards alone, ShardBytes giving the number that makes them of that
+	// size on average.
+	BySeries bool
+}
+
+// Shard is a part of a batch written by WriteParallel, and the outcome of its
+// write.
+type Shard struct {
+	// Index is the index of the shard, from 0.
+	Index int
+
+	// Batch holds the points of the shard, with the settings of the batch
+	// given to WriteParallel, to retry the write of a failed shard.
+	Batch BatchPoints
+
+	// Err is the error the write of the shard failed with, or nil.
+	Err error
+}
+
+// ParallelWriteError is returned by WriteParallel when the write of some of
+// the shards failed. The points of the others were written.
+type ParallelWriteError struct {
+	// Shards holds every shard of the batch.
+	Shards []Shard
+}
+
+// Failed returns the shards whose write failed.
+func (e *ParallelWriteError) Failed() []Shard {
+	var failed []Shard
+	for _, s := range e.Shards {
+		if s.Err != nil {
+			failed = append(failed, s)
+		}
+	}
+	return failed
+}
+
+func (e *ParallelWriteError) Error() string {
+	failed := e.Failed()
+	msgs := make([]string, len(failed))
+	for i, s := range failed {
+		msgs[i] = fmt.Sprintf("shard %d (%d points): %v", s.Index, len(s.Batch.Points()), s.Err)
+	}
+	return fmt.Sprintf("write failed for %d of %d shards: %s", len(failed), len(e.Shards), strings.Join(msgs, "; "))
+}
+
+// Unwrap returns the errors of the shards whose write failed.
+func (e *ParallelWriteError) Unwrap() []error {
+	var errs []error
+	for _, s := range e.Failed() {
+		errs = append(errs, s.Err)
+	}
+	return errs
+}
+
+// WriteParallel cuts bp into shards as set by opts and writes them with c
+// concurrently, bound to ctx if c supports it, so that a large batch is
+// encoded and sent on several connections at once. Each shard is a write of
+// its own, retried on its own by a client with retries. If some of the
+// writes fail, the error is a *ParallelWriteError holding the shards, so that
+// only the failed ones can be written again. bp is left as is.
+func WriteParallel(ctx context.Context, c Client, bp BatchPoints, opts ParallelWriteOptions) error {
+	if err := bp.Err(); err != nil {
+		return err
+	}
+	switch {
+	case opts.Shards < 0:
+		return &ConfigError{Field: "Shards", Reason: "must not be negative"}
+	case opts.ShardBytes < 0:
+		return &ConfigError{Field: "ShardBytes", Reason: "must not be negative"}
+	case opts.Shards == 0 && opts.ShardBytes == 0:
+		return &ConfigError{Field: "Shards", Reason: "neither Shards nor ShardBytes is set"}
+	case opts.Concurrency < 0:
+		return &ConfigError{Field: "Concurrency", Reason: "must not be negative"}
+	}
+
+	batches, err := shardBatch(bp, opts)
+	if err != nil {
+		return err
+	}
+	concurrency := opts.Concurrency
+	if concurrency == 0 {
+		concurrency = runtime.NumCPU()
+	}
+	if concurrency > len(batches) {
+		concurrency = len(batches)
+	}
+
+	shards := make([]Shard, len(batches))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				shards[i].Err = writeContext(ctx, c, batches[i])
+			}
+		}()
+	}
+	for i, b := range batches {
+		shards[i] = Shard{Index: i, Batch: b}
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	for _, s := range shards {
+		if s.Err != nil {
+			return &ParallelWriteError{Shards: shards}
+		}
+	}
+	return nil
+}
+
+// shardBatch cuts bp into the shards of opts.
+func shardBatch(bp BatchPoints, opts ParallelWriteOptions) ([]BatchPoints, error) {
+	n := len(bp.Points())
+	if !opts.BySeries {
+		if opts.ShardBytes > 0 {
+			batches, err := bp.Split(0, opts.ShardBytes)
+			// A point larger than a shard is in a shard of its own.
+			if errors.Is(err, ErrPointExceedsBatch) {
+				err = nil
+			}
+			return batches, err
+		}
+		return bp.Split((n+opts.Shards-1)/opts.Shards, 0)
+	}
+
+	shards := opts.Shards
+	if opts.ShardBytes > 0 {
+		shards = (bp.Size() + opts.ShardBytes - 1) / opts.ShardBytes
+	}
+	if shards < 1 {
+		shards = 1
+	}
+	conf := batchPointsConfig(bp)
+	// The points were already counted by the limiter of bp, and the shards
+	// are not bound by its MaxBytes.
+	conf.CardinalityLimiter = nil
+	conf.MaxBytes = 0
+	buckets := make([][]*Point, shards)
+	for _, p := range bp.Points() {
+		if p == nil {
+			continue
+		}
+		i := hashTagValue(p.pt.Key()) % uint64(shards)
+		buckets[i] = append(buckets[i], p)
+	}
+	var batches []BatchPoints
+	for _, points := range buckets {
+		if len(points) == 0 {
+			continue
+		}
+		b, err := NewBatchPoints(conf)
+		if err != nil {
+			return nil, err
+		}
+		if err := b.AddPoints(points); err != nil {
+			return nil, err
+		}
+		batches = append(batches, b)
+	}
+	return batches, nil
+}
+
+// writeContext writes bp with c, bound to ctx if c is a ContextClient.
+func writeContext(ctx context.Context, c Client, bp BatchPoints) error {
+	if cc, ok := c.(ContextClient); ok {
+		return cc.WriteContext(ctx, bp)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return c.Write(bp)
+}
@@ -0,0 +1,134 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteParallel(t *testing.T) {
+	r := &batchRecorder{}
+	bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0"})
+	bp.AddPoints(newTestPoints(10))
+
+	if err := WriteParallel(context.Background(), r, bp, ParallelWriteOptions{Shards: 3, Concurrency: 2}); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	var sizes []int
+	seen := make(map[string]bool)
+	for _, b := range r.batches {
+		sizes = append(sizes, len(b))
+		for _, p := range b {
+			seen[p.String()] = true
+		}
+	}
+	if len(sizes) != 3 || len(seen) != 10 {
+		t.Errorf("unexpected shards.  expected 3 shards of 10 points, actual %v of %d points", sizes, len(seen))
+	}
+	if len(bp.Points()) != 10 {
+		t.Errorf("unexpected points left in the batch.  expected %v, actual %v", 10, len(bp.Points()))
+	}
+
+	var ce *ConfigError
+	for _, opts := range []ParallelWriteOptions{{}, {Shards: -1}, {Shards: 2, Concurrency: -1}} {
+		if err := WriteParallel(context.Background(), r, bp, opts); !errors.As(err, &ce) {
+			t.Errorf("unexpected error for %+v.  expected a *ConfigError, actual %v", opts, err)
+		}
+	}
+}
+
+func TestWriteParallel_BySeries(t *testing.T) {
+	r := &batchRecorder{}
+	bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0"})
+	for i := 0; i < 40; i++ {
+		p, _ := NewPoint("cpu", map[string]string{"host": fmt.Sprint("h", i%8)}, map[string]interface{}{"value": i}, time.Unix(int64(i), 0))
+		bp.AddPoint(p)
+	}
+
+	if err := WriteParallel(context.Background(), r, bp, ParallelWriteOptions{Shards: 4, BySeries: true}); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	shardOf := make(map[string]int)
+	n := 0
+	for i, b := range r.batches {
+		last := make(map[string]int64)
+		for _, p := range b {
+			n++
+			host := p.Tags()["host"]
+			if s, ok := shardOf[host]; ok && s != i {
+				t.Errorf("series of %s written in shards %d and %d", host, s, i)
+			}
+			shardOf[host] = i
+			if ts := p.UnixNano(); ts < last[host] {
+				t.Errorf("series of %s written out of order", host)
+			} else {
+				last[host] = ts
+			}
+		}
+	}
+	if n != 40 {
+		t.Errorf("unexpected points written.  expected %v, actual %v", 40, n)
+	}
+}
+
+func TestWriteParallel_FailedShards(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), "value=5") {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0"})
+	bp.AddPoints(newTestPoints(8))
+	err := WriteParallel(context.Background(), c, bp, ParallelWriteOptions{Shards: 4})
+	var pe *ParallelWriteError
+	if !errors.As(err, &pe) || len(pe.Shards) != 4 {
+		t.Fatalf("unexpected error.  expected a *ParallelWriteError of 4 shards, actual %v", err)
+	}
+	failed := pe.Failed()
+	if len(failed) != 1 || failed[0].Index != 2 || len(failed[0].Batch.Points()) != 2 {
+		t.Fatalf("unexpected failed shards.  expected shard 2, actual %+v", failed)
+	}
+	if errs := pe.Unwrap(); len(errs) != 1 || errs[0] != failed[0].Err {
+		t.Errorf("unexpected wrapped errors.  expected %v, actual %v", failed[0].Err, errs)
+	}
+}
+
+func BenchmarkWriteParallel(b *testing.B) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := io.Copy(io.Discard, r.Body)
+		// The time the server takes to store the points.
+		time.Sleep(time.Duration(n/10) * time.Microsecond)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0"})
+	bp.AddPoints(newTestPoints(50000))
+
+	for _, shards := range []int{1, 8} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if err := WriteParallel(context.Background(), c, bp, ParallelWriteOptions{Shards: shards, Concurrency: shards}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package client
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+// ParseOptions sets how ParsePoints handles invalid lines.
+type ParseOptions struct {
+	// SkipInvalid makes ParsePoints go on after an invalid line and return
+	// the points of the valid lines along with a *ParseError. The first
+	// invalid line is returned as a *LineError otherwise.
+	SkipInvalid bool
+}
+
+// ParseError is returned by ParsePoints with SkipInvalid set for a buffer
+// with invalid lines.
+type ParseError struct {
+	// Lines are the invalid lines, in order.
+	Lines []*LineError
+}
+
+func (e *ParseError) Error() string {
+	if len(e.Lines) == 1 {
+		return e.Lines[0].Error()
+	}
+	return fmt.Sprintf("%d invalid lines, the first one: %v", len(e.Lines), e.Lines[0])
+}
+
+// ParsePoints parses the line protocol in buf into points. Points without a
+// timestamp get defaultTime, and timestamps are in the given precision, as
+// in BatchPointsConfig. Blank lines and comments are skipped.
+//
+// It returns a *LineError for the first invalid line. The points refer to
+// buf, which must not be modified afterwards.
+func ParsePoints(buf []byte, defaultTime time.Time, precision string) ([]*Point, error) {
+	return ParseOptions{}.ParsePoints(buf, defaultTime, precision)
+}
+
+// ParsePointsString is like ParsePoints for line protocol held in a string.
+func ParsePointsString(s string, defaultTime time.Time, precision string) ([]*Point, error) {
+	return ParseOptions{}.ParsePoints([]byte(s), defaultTime, precision)
+}
+
+// ParsePoints is like the ParsePoints function, with invalid lines handled
+// as set by o.
+func (o ParseOptions) ParsePoints(buf []byte, defaultTime time.Time, precision string) ([]*Point, error) {
+	if precision != "" {
+		if _, err := time.ParseDuration("1" + precision); err != nil {
+			return nil, err
+		}
+	}
+
+	var points []*Point
+	var invalid []*LineError
+	parseLines(buf, defaultTime, precision, func(pt models.Point, le *LineError) bool {
+		if le == nil {
+			points = append(points, NewPointFrom(pt))
+			return true
+		}
+		invalid = append(invalid, le)
+		return o.SkipInvalid
+	})
+
+	switch {
+	case len(invalid) == 0:
+		return points, nil
+	case !o.SkipInvalid:
+		return nil, invalid[0]
+	}
+	return points, &ParseError{Lines: invalid}
+}
+
+// parseLines parses every line of b and hands each point, or the error of an
+// invalid line, to fn, until it returns false. precision is the precision of
+// the timestamps, as in BatchPointsConfig.
+func parseLines(b []byte, defaultTime time.Time, precision string, fn func(models.Point, *LineError) bool) {
+	switch precision {
+	case "", "ns":
+		precision = "n"
+	case "us", "µs":
+		precision = "u"
+	}
+
+	for i, offset := 0, 0; offset < len(b); i++ {
+		n := lineEnd(b[offset:])
+		line := b[offset : offset+n]
+		points, err := models.ParsePointsWithPrecision(line, defaultTime, precision)
+		if err != nil {
+			if !fn(nil, &LineError{Index: i, Offset: offset, Line: string(trimNewline(line)), Err: err}) {
+				return
+			}
+		}
+		for _, pt := range points {
+			if !fn(pt, nil) {
+				return
+			}
+		}
+		offset += n
+	}
+}
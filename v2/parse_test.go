@@ -0,0 +1,70 @@
+package client
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePoints_RoundTrip(t *testing.T) {
+	const input = "cpu,host=a,region=us\\ west value=1.5,count=3i 1000\n" +
+		"mem,host=a free=42i,label=\"a \\\"quoted\\\"\nvalue\" 2000\n" +
+		"disk used=0.25 3000\n"
+
+	points, err := ParsePointsString(input, time.Time{}, "s")
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	bp, _ := NewBatchPoints(BatchPointsConfig{Precision: "s"})
+	bp.AddPoints(points)
+
+	var b strings.Builder
+	for _, p := range bp.Points() {
+		b.WriteString(p.PrecisionString(bp.Precision()))
+		b.WriteByte('\n')
+	}
+	if got := b.String(); got != input {
+		t.Errorf("unexpected round trip.  expected %q, actual %q", input, got)
+	}
+}
+
+func TestParsePoints_DefaultTime(t *testing.T) {
+	now := time.Unix(10, 0)
+	points, err := ParsePoints([]byte("# comment\n\ncpu value=1\n"), now, "")
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if len(points) != 1 || !points[0].Time().Equal(now) {
+		t.Errorf("unexpected points: %v", points)
+	}
+}
+
+func TestParsePoints_Invalid(t *testing.T) {
+	const input = "cpu value=1\ncpu value=\ncpu value=2\nbad\n"
+
+	_, err := ParsePointsString(input, time.Time{}, "")
+	var le *LineError
+	if !errors.As(err, &le) {
+		t.Fatalf("unexpected error.  expected %T, actual %v", le, err)
+	}
+	if le.Index != 1 || le.Offset != 12 || le.Line != "cpu value=" {
+		t.Errorf("unexpected line error: %+v", le)
+	}
+
+	points, err := ParseOptions{SkipInvalid: true}.ParsePoints([]byte(input), time.Time{}, "")
+	var pe *ParseError
+	if !errors.As(err, &pe) {
+		t.Fatalf("unexpected error.  expected %T, actual %v", pe, err)
+	}
+	if len(points) != 2 {
+		t.Errorf("unexpected number of points.  expected %v, actual %v", 2, len(points))
+	}
+	if len(pe.Lines) != 2 || pe.Lines[0].Offset != 12 || pe.Lines[1].Index != 3 || pe.Lines[1].Offset != 35 {
+		t.Errorf("unexpected invalid lines: %+v", pe.Lines)
+	}
+
+	if _, err := ParsePointsString(input, time.Time{}, "x"); err == nil {
+		t.Error("expected an error for an invalid precision")
+	}
+}
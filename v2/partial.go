@@ -0,0 +1,56 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrPartialResult is matched by the *PartialResultError of a query with
+// FailOnPartial whose results were truncated by the server.
+var ErrPartialResult = errors.New("result truncated by the server")
+
+// PartialResultError is returned by a query with FailOnPartial when the
+// server marked the last result of a statement as partial, as it does when a
+// limit such as max-row-limit cut the output short.
+type PartialResultError struct {
+	// StatementID is the index of the first truncated statement.
+	StatementID int
+}
+
+func (e *PartialResultError) Error() string {
+	return fmt.Sprintf("%v: statement %d", ErrPartialResult, e.StatementID)
+}
+
+// Is reports whether target is ErrPartialResult.
+func (e *PartialResultError) Is(target error) bool { return target == ErrPartialResult }
+
+// partialTracker records, for every statement, whether its last result read
+// so far was partial. The results of a chunked query are partial until the
+// last one of each statement, so only a statement still partial once the
+// whole response was read was truncated.
+type partialTracker map[int]bool
+
+func (t partialTracker) add(results []Result) {
+	for _, r := range results {
+		partial := r.Partial
+		if n := len(r.Series); n > 0 && r.Series[n-1].Partial {
+			partial = true
+		}
+		t[r.StatementId] = partial
+	}
+}
+
+// err returns a *PartialResultError for the first truncated statement, or
+// nil.
+func (t partialTracker) err() error {
+	id := -1
+	for i, partial := range t {
+		if partial && (id < 0 || i < id) {
+			id = i
+		}
+	}
+	if id < 0 {
+		return nil
+	}
+	return &PartialResultError{StatementID: id}
+}
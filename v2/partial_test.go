@@ -0,0 +1,169 @@
+package client
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+// newCannedServer returns a server answering every query with body.
+func newCannedServer(body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+}
+
+func TestClient_QueryPartial(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		body    string
+		chunked bool
+		partial bool
+	}{
+		{
+			name:    "result",
+			body:    `{"results":[{"statement_id":0},{"statement_id":1,"series":[{"name":"cpu","columns":["v"],"values":[[1]]}],"partial":true}]}`,
+			partial: true,
+		},
+		{
+			name:    "series",
+			body:    `{"results":[{"statement_id":0,"series":[{"name":"cpu","columns":["v"],"values":[[1]],"partial":true}]}]}`,
+			partial: true,
+		},
+		{
+			name: "complete",
+			body: `{"results":[{"statement_id":0,"series":[{"name":"cpu","columns":["v"],"values":[[1]]}]}]}`,
+		},
+		{
+			name: "chunks",
+			body: `{"results":[{"statement_id":0,"series":[{"name":"cpu","columns":["v"],"values":[[1]],"partial":true}],"partial":true}]}
+{"results":[{"statement_id":0,"series":[{"name":"cpu","columns":["v"],"values":[[2]]}]}]}
+`,
+			chunked: true,
+		},
+		{
+			name: "truncated chunks",
+			body: `{"results":[{"statement_id":0,"series":[{"name":"cpu","columns":["v"],"values":[[1]],"partial":true}],"partial":true}]}
+{"results":[{"statement_id":0,"series":[{"name":"cpu","columns":["v"],"values":[[2]]}],"partial":true}]}
+`,
+			chunked: true,
+			partial: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newCannedServer(tt.body)
+			defer ts.Close()
+			c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+			defer c.Close()
+
+			// The flag is kept by default.
+			resp, err := c.Query(Query{Command: "SELECT * FROM cpu", Chunked: tt.chunked})
+			if err != nil {
+				t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+			}
+			last := resp.Results[len(resp.Results)-1]
+			if got := last.Partial || last.Series[len(last.Series)-1].Partial; got != tt.partial {
+				t.Errorf("unexpected partial flag.  expected %v, actual %v", tt.partial, got)
+			}
+
+			resp, err = c.Query(Query{Command: "SELECT * FROM cpu", Chunked: tt.chunked, FailOnPartial: true})
+			checkPartialError(t, err, tt.partial)
+			if resp == nil {
+				t.Fatal("expected the response to be returned")
+			}
+		})
+	}
+}
+
+func TestClient_QueryAsChunkPartial(t *testing.T) {
+	for _, tt := range []struct {
+		body    string
+		partial bool
+	}{
+		{
+			body: `{"results":[{"statement_id":0,"series":[{"name":"cpu","columns":["v"],"values":[[1]],"partial":true}],"partial":true}]}
+{"results":[{"statement_id":0,"series":[{"name":"cpu","columns":["v"],"values":[[2]]}]}]}
+`,
+		},
+		{
+			body: `{"results":[{"statement_id":0,"series":[{"name":"cpu","columns":["v"],"values":[[1]],"partial":true}],"partial":true}]}
+`,
+			partial: true,
+		},
+	} {
+		ts := newCannedServer(tt.body)
+		c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+
+		for _, strict := range []bool{false, true} {
+			cr, err := c.QueryAsChunk(Query{Command: "SELECT * FROM cpu", FailOnPartial: strict})
+			if err != nil {
+				t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+			}
+			var chunks int
+			for {
+				resp, err := cr.NextResponse()
+				if err != nil {
+					if strict {
+						checkPartialError(t, ignoreEOF(err), tt.partial)
+					} else if err != io.EOF {
+						t.Errorf("unexpected error.  expected %v, actual %v", io.EOF, err)
+					}
+					break
+				}
+				if chunks == 0 && !resp.Results[0].Partial {
+					t.Error("expected the first chunk to be partial")
+				}
+				chunks++
+			}
+			cr.Close()
+			if chunks != strings.Count(tt.body, "\n") {
+				t.Errorf("unexpected number of chunks.  expected %v, actual %v", strings.Count(tt.body, "\n"), chunks)
+			}
+		}
+		c.Close()
+		ts.Close()
+	}
+}
+
+func TestClient_QueryPartialMsgpack(t *testing.T) {
+	ts := newMsgpackServer(t, Response{Results: []Result{{
+		Series:  []models.Row{{Name: "cpu", Columns: []string{"v"}, Values: [][]interface{}{{int64(1)}}, Partial: true}},
+		Partial: true,
+	}}})
+	defer ts.Close()
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, ResponseFormat: MsgpackFormat})
+	defer c.Close()
+
+	resp, err := c.Query(Query{Command: "SELECT * FROM cpu", FailOnPartial: true})
+	checkPartialError(t, err, true)
+	if resp == nil || !resp.Results[0].Partial || !resp.Results[0].Series[0].Partial {
+		t.Errorf("unexpected response: %+v", resp)
+	}
+}
+
+func checkPartialError(t *testing.T, err error, partial bool) {
+	t.Helper()
+	if !partial {
+		if err != nil {
+			t.Errorf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+		return
+	}
+	var pe *PartialResultError
+	if !errors.As(err, &pe) || !errors.Is(err, ErrPartialResult) {
+		t.Errorf("unexpected error.  expected a *PartialResultError, actual %v", err)
+	}
+}
+
+func ignoreEOF(err error) error {
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
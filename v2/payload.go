@@ -0,0 +1,358 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+// WriteError is returned by the TCP and UDP clients when some of the payloads
+// of a batch could not be sent, and by the HTTP client when some fragments of
+// a write split with HTTPConfig.SplitTooLarge failed. Points that were split
+// across payloads are counted as dropped if any of their parts failed.
+type WriteError struct {
+	// PointsWritten is the number of points that were sent.
+	PointsWritten int
+
+	// PointsDropped is the number of points that were not sent, either
+	// because their payload failed or because the write was aborted.
+	PointsDropped int
+
+	// Errs holds the error of every payload that failed, in order.
+	Errs []error
+}
+
+func (e *WriteError) Error() string {
+	total := e.PointsWritten + e.PointsDropped
+	if len(e.Errs) == 1 {
+		return fmt.Sprintf("%d of %d points dropped: %v", e.PointsDropped, total, e.Errs[0])
+	}
+	return fmt.Sprintf("%d of %d points dropped: %v (and %d more errors)", e.PointsDropped, total, e.Errs[0], len(e.Errs)-1)
+}
+
+// Unwrap returns the payload errors.
+func (e *WriteError) Unwrap() []error { return e.Errs }
+
+// payloadBuffers pools the buffers payloads are serialized into, so that
+// writes do not allocate one each. The zero value is ready to use.
+type payloadBuffers struct {
+	pool sync.Pool
+}
+
+// get returns an empty buffer of at least size bytes capacity.
+func (p *payloadBuffers) get(size int) *[]byte {
+	if b, ok := p.pool.Get().(*[]byte); ok && cap(*b) >= size {
+		*b = (*b)[:0]
+		return b
+	}
+	b := make([]byte, 0, size)
+	return &b
+}
+
+// put returns b to the pool, unless a large point grew it well beyond size.
+func (p *payloadBuffers) put(b *[]byte, size int) {
+	if cap(*b) > 2*size {
+		return
+	}
+	p.pool.Put(b)
+}
+
+// precisionDuration returns the duration of one unit of precision, as
+// time.ParseDuration("1" + precision) does, without parsing the common ones.
+func precisionDuration(precision string) time.Duration {
+	switch precision {
+	case "", "ns":
+		return time.Nanosecond
+	case "us", "u", "µs":
+		return time.Microsecond
+	case "ms":
+		return time.Millisecond
+	case "s":
+		return time.Second
+	case "m":
+		return time.Minute
+	case "h":
+		return time.Hour
+	}
+	d, _ := time.ParseDuration("1" + precision)
+	return d
+}
+
+// expectedPrecision returns the precision the timestamps of the payloads are
+// written in for the ExpectedPrecision of a TCPConfig or UDPConfig, in the
+// form models.AppendPrecisionString takes.
+func expectedPrecision(precision string) (string, error) {
+	switch precision {
+	case "", "n", "ns":
+		return "", nil
+	case "u", "us", "µ", "µs":
+		return "u", nil
+	case "ms", "s", "m", "h":
+		return precision, nil
+	}
+	return "", &ConfigError{Field: "ExpectedPrecision", Reason: fmt.Sprintf("%q is not one of ns, u, ms, s, m or h", precision)}
+}
+
+// payloadRounding returns the duration the points of a batch in precision
+// are rounded to in payloads whose timestamps are in timePrecision: that of
+// the coarser of the two.
+func payloadRounding(precision, timePrecision string) time.Duration {
+	d := precisionDuration(precision)
+	if td := precisionDuration(timePrecision); td > d {
+		return td
+	}
+	return d
+}
+
+// PointTooLargeError is returned by the TCP and UDP clients, as one of the
+// errors of a WriteError, for a point that cannot be sent because one of its
+// fields does not fit a payload on its own. The point is not sent, the other
+// points of the write are.
+type PointTooLargeError struct {
+	Measurement string
+	Field       string
+
+	// Size is the size of the point with only that field, and PayloadSize
+	// the configured limit.
+	Size        int
+	PayloadSize int
+}
+
+func (e *PointTooLargeError) Error() string {
+	return fmt.Sprintf("point of measurement %q does not fit a payload of %d bytes: field %q alone needs %d bytes",
+		e.Measurement, e.PayloadSize, e.Field, e.Size)
+}
+
+// checkPointParts returns a *PointTooLargeError if one of the parts pt was
+// split into does not fit payloadSize, including its newline.
+func checkPointParts(pt models.Point, parts []models.Point, payloadSize int) error {
+	for _, sp := range parts {
+		if size := sp.StringSize() + 1; size > payloadSize {
+			var field string
+			if it := sp.FieldIterator(); it.Next() {
+				field = string(it.FieldKey())
+			}
+			return &PointTooLargeError{
+				Measurement: string(pt.Name()),
+				Field:       field,
+				Size:        size,
+				PayloadSize: payloadSize,
+			}
+		}
+	}
+	return nil
+}
+
+// writePayloads serializes the points of bp into payloads of at most
+// payloadSize bytes, with timestamps in timePrecision, nanoseconds if empty,
+// and hands each of them to flush. The buffer passed to flush is taken from
+// bufs and reused afterwards. With stopOnError set no more payloads are
+// flushed after the first failure.
+func writePayloads(ctx context.Context, bp BatchPoints, timePrecision string, bufs *payloadBuffers, payloadSize int, stopOnError bool, flush func([]byte) error) error {
+	return writePointPayloads(ctx, len(bp.Points()), batchPointAt(bp), bp.Precision(), timePrecision, newFieldSorter(bp), bufs, payloadSize, stopOnError, flush)
+}
+
+// batchPointAt returns a function returning the point of bp at an index, nil
+// for a nil point.
+func batchPointAt(bp BatchPoints) func(i int) models.Point {
+	points := bp.Points()
+	return func(i int) models.Point {
+		if points[i] == nil {
+			return nil
+		}
+		return points[i].pt
+	}
+}
+
+// writeModelsPayloads is like writePayloads for points in the given precision.
+func writeModelsPayloads(ctx context.Context, points []models.Point, precision, timePrecision string, bufs *payloadBuffers, payloadSize int, stopOnError bool, flush func([]byte) error) error {
+	at := func(i int) models.Point { return points[i] }
+	return writePointPayloads(ctx, len(points), at, precision, timePrecision, nil, bufs, payloadSize, stopOnError, flush)
+}
+
+// writePointPayloads implements writePayloads for the n points returned by
+// at, in the given precision, with their fields sorted by sorter if not nil.
+func writePointPayloads(ctx context.Context, n int, at func(i int) models.Point, precision, timePrecision string, sorter *fieldSorter, bufs *payloadBuffers, payloadSize int, stopOnError bool, flush func([]byte) error) error {
+	var buf = bufs.get(payloadSize)
+	var b = *buf // it will grow as needed
+	defer func() {
+		*buf = b
+		bufs.put(buf, payloadSize)
+	}()
+	var d = payloadRounding(precision, timePrecision)
+
+	var errs []error
+	var dropped int
+
+	// tooLarge is the number of points dropped for not fitting a payload.
+	var tooLarge int
+
+	// first and last are the indices of the points with data in b, and
+	// lastDropped the index of the last point known to be dropped.
+	var first, last, lastDropped = -1, -1, -1
+
+	// stopped is the index of the first point that was not sent once writing
+	// stopped because of stopOnError.
+	var stopped = -1
+
+	var flushBuffer = func() {
+		if stopped >= 0 {
+			return
+		}
+		if err := flush(b); err != nil {
+			errs = append(errs, err)
+			if first <= lastDropped {
+				first = lastDropped + 1
+			}
+			dropped += last - first + 1
+			lastDropped = last
+			if stopOnError {
+				stopped = first
+			}
+		}
+		b = b[:0]
+		first = -1
+	}
+
+	var checkBuffer = func(n int) {
+		if len(b) > 0 && len(b)+n > payloadSize {
+			flushBuffer()
+		}
+	}
+
+	var appendPoint = func(i int, pt models.Point) {
+		if first < 0 {
+			first = i
+		}
+		last = i
+		start := len(b)
+		b = models.AppendPrecisionString(b, pt, timePrecision)
+		if sorter != nil {
+			sorter.sortLine(b[start:], len(pt.Key()), !pt.Time().IsZero())
+		}
+		b = append(b, '\n')
+	}
+
+	for i := 0; i < n; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if stopped >= 0 {
+			break
+		}
+		p := at(i)
+		if p == nil {
+			continue
+		}
+
+		// Round into a copy, the points belong to the caller.
+		pt := models.RoundedPoint(p, d)
+		pointSize := models.PrecisionStringSize(pt, timePrecision) + 1 // include newline in size
+
+		checkBuffer(pointSize)
+
+		if pointSize <= payloadSize {
+			appendPoint(i, pt)
+			continue
+		}
+
+		// The parts of a point without a timestamp would each be stored at
+		// the time the server receives them, so give them a common one.
+		if pt.Time().IsZero() {
+			pt = models.PointWithTime(pt, time.Now().Round(d))
+		}
+
+		parts := pt.Split(payloadSize - 1) // account for newline character
+		if err := checkPointParts(pt, parts, payloadSize); err != nil {
+			// Keep the point out of the payloads around it, so that the
+			// dropped points counted for a payload are the ones in it.
+			if len(b) > 0 {
+				flushBuffer()
+			}
+			if stopped >= 0 {
+				break
+			}
+			errs = append(errs, err)
+			dropped++
+			tooLarge++
+			continue
+		}
+		for _, sp := range parts {
+			checkBuffer(sp.StringSize() + 1)
+			appendPoint(i, sp)
+		}
+	}
+
+	if len(b) > 0 {
+		flushBuffer()
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	if stopped >= 0 {
+		dropped = n - stopped + tooLarge
+	}
+	return &WriteError{
+		PointsWritten: n - dropped,
+		PointsDropped: dropped,
+		Errs:          errs,
+	}
+}
+
+// countPayloads returns the number of payloads writePointPayloads sends for
+// the n points returned by at, without serializing them, along with the
+// number of points at the start that fit in max of them.
+func countPayloads(n int, at func(i int) models.Point, precision, timePrecision string, payloadSize, max int) (count, fit int) {
+	var d = payloadRounding(precision, timePrecision)
+
+	// size is the size of the payload being filled.
+	var size int
+	var flush = func() {
+		if size > 0 {
+			count++
+			size = 0
+		}
+	}
+	var add = func(n int) {
+		if size > 0 && size+n > payloadSize {
+			flush()
+		}
+		size += n
+	}
+
+	for i := 0; i < n; i++ {
+		if p := at(i); p != nil {
+			pt := models.RoundedPoint(p, d)
+			if pointSize := models.PrecisionStringSize(pt, timePrecision) + 1; pointSize <= payloadSize {
+				add(pointSize)
+			} else {
+				if pt.Time().IsZero() {
+					pt = models.PointWithTime(pt, time.Now().Round(d))
+				}
+				parts := pt.Split(payloadSize - 1)
+				if checkPointParts(pt, parts, payloadSize) != nil {
+					// The point is dropped, after the payload before it is
+					// sent.
+					flush()
+				} else {
+					for _, sp := range parts {
+						add(sp.StringSize() + 1)
+					}
+				}
+			}
+		}
+		used := count
+		if size > 0 {
+			used++
+		}
+		if used <= max {
+			fit = i + 1
+		}
+	}
+	flush()
+	return count, fit
+}
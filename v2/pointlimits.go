@@ -0,0 +1,168 @@
+package client
+
+import (
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+// DefaultMaxKeyLength is the default BatchPointsConfig.MaxKeyLength, the
+// largest series key of InfluxDB 1.8.
+const DefaultMaxKeyLength = models.MaxKeyLength
+
+// DefaultTruncationMarker is the default BatchPointsConfig.TruncationMarker.
+const DefaultTruncationMarker = "..."
+
+// LimitError is returned by AddPoint for a point over a limit of its batch,
+// see BatchPointsConfig.MaxFieldsPerPoint.
+type LimitError struct {
+	// Limit is the limit exceeded: "fields per point", "string field
+	// length" or "key length".
+	Limit string
+
+	// Key is the measurement of a point with too many fields, or the field
+	// key whose value or series key is too long.
+	Key string
+
+	// Size is the number of fields or bytes of the point, and Max the
+	// limit.
+	Size int
+	Max  int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s of %q is %d, exceeds the maximum of %d", e.Limit, e.Key, e.Size, e.Max)
+}
+
+// pointLimits are the limits of BatchPointsConfig, as configured.
+type pointLimits struct {
+	maxFields       int
+	maxStringLength int
+	maxKeyLength    int
+	truncate        bool
+	marker          string
+}
+
+// newPointLimits returns the limits of conf.
+func newPointLimits(conf BatchPointsConfig) (pointLimits, error) {
+	l := pointLimits{
+		maxFields:       conf.MaxFieldsPerPoint,
+		maxStringLength: conf.MaxStringFieldLength,
+		maxKeyLength:    conf.MaxKeyLength,
+		truncate:        conf.TruncateStrings,
+		marker:          conf.TruncationMarker,
+	}
+	if l.truncate && l.stringLength() > 0 && len(l.truncationMarker()) >= l.stringLength() {
+		return l, &ConfigError{Field: "TruncationMarker", Reason: "must be shorter than MaxStringFieldLength"}
+	}
+	return l, nil
+}
+
+// stringLength and keyLength return the limits in effect, 0 for none.
+func (l pointLimits) stringLength() int {
+	return effectiveLimit(l.maxStringLength, MaxStringFieldLength)
+}
+
+func (l pointLimits) keyLength() int {
+	return effectiveLimit(l.maxKeyLength, DefaultMaxKeyLength)
+}
+
+func (l pointLimits) truncationMarker() string {
+	if l.marker == "" {
+		return DefaultTruncationMarker
+	}
+	return l.marker
+}
+
+// effectiveLimit returns def for a zero limit and 0, no limit, for a
+// negative one.
+func effectiveLimit(limit, def int) int {
+	switch {
+	case limit == 0:
+		return def
+	case limit < 0:
+		return 0
+	}
+	return limit
+}
+
+// apply checks p against the limits, returning p with its long strings
+// truncated if the limits truncate them.
+func (l pointLimits) apply(p *Point) (*Point, error) {
+	maxString, maxKey := l.stringLength(), l.keyLength()
+	keySize := len(p.pt.Key())
+	var fields int
+	var long []string
+	it := p.pt.FieldIterator()
+	for it.Next() {
+		fields++
+		key := it.FieldKey()
+		if maxKey > 0 && keySize+4+len(key) > maxKey {
+			// 4 is the length of the separator of the series key and
+			// the field key in the storage of the server.
+			return nil, &LimitError{Limit: "key length", Key: string(key), Size: keySize + 4 + len(key), Max: maxKey}
+		}
+		if maxString > 0 && it.Type() == models.String {
+			if n := len(it.StringValue()); n > maxString {
+				if !l.truncate {
+					return nil, &LimitError{Limit: "string field length", Key: string(key), Size: n, Max: maxString}
+				}
+				long = append(long, string(key))
+			}
+		}
+	}
+	if l.maxFields > 0 && fields > l.maxFields {
+		return nil, &LimitError{Limit: "fields per point", Key: string(p.pt.Name()), Size: fields, Max: l.maxFields}
+	}
+	if len(long) == 0 {
+		return p, nil
+	}
+
+	cached, err := p.pt.Fields()
+	if err != nil {
+		return nil, err
+	}
+	// The fields are those cached by the point, which must not change.
+	values := make(models.Fields, len(cached))
+	for k, v := range cached {
+		values[k] = v
+	}
+	for _, k := range long {
+		values[k] = truncateString(values[k].(string), maxString, l.truncationMarker())
+	}
+	pt, err := models.NewPoint(string(p.pt.Name()), p.pt.Tags(), values, p.pt.Time())
+	if err != nil {
+		return nil, err
+	}
+	return &Point{pt: pt}, nil
+}
+
+// truncateString cuts s to at most max bytes ending with marker, without
+// splitting a UTF-8 character.
+func truncateString(s string, max int, marker string) string {
+	n := max - len(marker)
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n] + marker
+}
+
+// configure sets the limits in conf.
+// addAccepted adds points, which a batch accepted once already, to bp of
+// NewBatchPoints without checking them against its limits again.
+func addAccepted(bp BatchPoints, points []*Point) error {
+	b := bp.(*batchpoints)
+	limits := b.limits
+	b.limits = pointLimits{maxStringLength: -1, maxKeyLength: -1}
+	defer func() { b.limits = limits }()
+	return b.AddPoints(points)
+}
+
+func (l pointLimits) configure(conf *BatchPointsConfig) {
+	conf.MaxFieldsPerPoint = l.maxFields
+	conf.MaxStringFieldLength = l.maxStringLength
+	conf.MaxKeyLength = l.maxKeyLength
+	conf.TruncateStrings = l.truncate
+	conf.TruncationMarker = l.marker
+}
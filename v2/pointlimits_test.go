@@ -0,0 +1,113 @@
+package client
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestBatchPoints_Limits(t *testing.T) {
+	bp, _ := NewBatchPoints(BatchPointsConfig{MaxFieldsPerPoint: 2, MaxStringFieldLength: 4, MaxKeyLength: 16})
+
+	for _, tt := range []struct {
+		name        string
+		measurement string
+		fields      map[string]interface{}
+		err         *LimitError
+	}{
+		{name: "at the limits", measurement: "cpu", fields: map[string]interface{}{"a": "abcd", "b": 1}},
+		{
+			name:        "one field over",
+			measurement: "cpu",
+			fields:      map[string]interface{}{"a": 1, "b": 2, "c": 3},
+			err:         &LimitError{Limit: "fields per point", Key: "cpu", Size: 3, Max: 2},
+		},
+		{
+			name:        "one byte over",
+			measurement: "cpu",
+			fields:      map[string]interface{}{"a": "abcde"},
+			err:         &LimitError{Limit: "string field length", Key: "a", Size: 5, Max: 4},
+		},
+		// The series key of a field is "cpu8char#!~#a", 16 bytes at the
+		// limit.
+		{name: "key at the limit", measurement: "cpu8char", fields: map[string]interface{}{"abcd": 1}},
+		{
+			name:        "key one byte over",
+			measurement: "cpu8char",
+			fields:      map[string]interface{}{"abcde": 1},
+			err:         &LimitError{Limit: "key length", Key: "abcde", Size: 17, Max: 16},
+		},
+	} {
+		pt, _ := NewPoint(tt.measurement, nil, tt.fields)
+		err := bp.AddPoint(pt)
+		if tt.err == nil {
+			if err != nil {
+				t.Errorf("%s: unexpected error.  expected %v, actual %v", tt.name, nil, err)
+			}
+			continue
+		}
+		var le *LimitError
+		if !errors.As(err, &le) || *le != *tt.err {
+			t.Errorf("%s: unexpected error.  expected %v, actual %v", tt.name, tt.err, err)
+		}
+	}
+	if n := len(bp.Points()); n != 2 {
+		t.Errorf("unexpected points.  expected %v, actual %v", 2, n)
+	}
+}
+
+func TestBatchPoints_LimitsDefaults(t *testing.T) {
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	long := strings.Repeat("x", MaxStringFieldLength)
+	pt, _ := NewPoint("cpu", nil, map[string]interface{}{"value": long})
+	if err := bp.AddPoint(pt); err != nil {
+		t.Errorf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	pt, _ = NewPoint("cpu", nil, map[string]interface{}{"value": long + "x"})
+	if err := bp.AddPoint(pt); err == nil {
+		t.Error("expected an error for a string over the default limit")
+	}
+
+	// A negative limit removes it.
+	bp, _ = NewBatchPoints(BatchPointsConfig{MaxStringFieldLength: -1})
+	if err := bp.AddPoint(pt); err != nil {
+		t.Errorf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+}
+
+func TestBatchPoints_TruncateStrings(t *testing.T) {
+	bp, _ := NewBatchPoints(BatchPointsConfig{MaxStringFieldLength: 8, TruncateStrings: true})
+
+	// "é" takes two bytes: a cut after 5 bytes would split the third one.
+	s := "ééééé"
+	pt, _ := NewPoint("cpu", map[string]string{"host": "a"}, map[string]interface{}{"msg": s, "value": 1})
+	if err := bp.AddPoint(pt); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	fields, _ := bp.Points()[0].Fields()
+	got := fields["msg"].(string)
+	if exp := "éé..."; got != exp {
+		t.Errorf("unexpected value.  expected %q, actual %q", exp, got)
+	}
+	if !utf8.ValidString(got) {
+		t.Errorf("unexpected invalid UTF-8: %q", got)
+	}
+	if fields["value"] != int64(1) || bp.Points()[0].Tags()["host"] != "a" {
+		t.Errorf("unexpected point: %v", bp.Points()[0])
+	}
+	if fields, _ := pt.Fields(); fields["msg"] != s {
+		t.Errorf("unexpected change of the point given: %v", pt)
+	}
+
+	// A value at the limit is kept as is.
+	bp, _ = NewBatchPoints(BatchPointsConfig{MaxStringFieldLength: 10, TruncateStrings: true, TruncationMarker: "~"})
+	bp.AddPoint(pt)
+	if fields, _ := bp.Points()[0].Fields(); fields["msg"] != s {
+		t.Errorf("unexpected value.  expected %q, actual %q", s, fields["msg"])
+	}
+
+	if _, err := NewBatchPoints(BatchPointsConfig{MaxStringFieldLength: 3, TruncateStrings: true}); err == nil {
+		t.Error("expected an error for a marker as long as the limit")
+	}
+}
@@ -0,0 +1,120 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+// PrecisionCollisionError is returned by ConvertPrecision, along with the
+// converted batch, when points of the same series with distinct timestamps
+// have the same timestamp in the target precision. The server keeps one
+// point per series and timestamp, so the fields of the later points overwrite
+// those of the earlier ones.
+type PrecisionCollisionError struct {
+	// Precision is the target precision.
+	Precision string
+
+	// Collisions is the number of points having the timestamp of an earlier
+	// point of their series in the target precision.
+	Collisions int
+}
+
+func (e *PrecisionCollisionError) Error() string {
+	return fmt.Sprintf("%d points collide with other points of their series in precision %q", e.Collisions, e.Precision)
+}
+
+// ConvertPrecision returns a copy of bp in the precision target, with the
+// timestamps of the points truncated to it as the server would store them.
+// bp is left as it is; the points of the copy share their keys and fields
+// with those of bp.
+//
+// An invalid target is an error. A conversion that gives points of a series
+// the same timestamp returns the converted batch with a
+// *PrecisionCollisionError counting them, for the caller to decide whether
+// to write it.
+func ConvertPrecision(bp BatchPoints, target string) (BatchPoints, error) {
+	if err := checkPrecision(target); err != nil {
+		return nil, err
+	}
+	var out BatchPoints
+	var collisions int
+	switch b := bp.(type) {
+	case *batchpoints:
+		out, collisions = b.convert(target)
+	case *safeBatchPoints:
+		b.mu.Lock()
+		child, n := b.bp.convert(target)
+		b.mu.Unlock()
+		out, collisions = &safeBatchPoints{bp: child}, n
+	default:
+		var points []*Point
+		points, collisions = convertPoints(bp.Points(), target)
+		conf := batchPointsConfig(bp)
+		conf.Precision = target
+		nbp, err := NewBatchPoints(conf)
+		if err != nil {
+			return nil, err
+		}
+		if err := nbp.AddPoints(points); err != nil {
+			return nil, err
+		}
+		out = nbp
+	}
+	if collisions > 0 {
+		return out, &PrecisionCollisionError{Precision: target, Collisions: collisions}
+	}
+	return out, nil
+}
+
+// convert returns a batch with the settings of bp holding its points
+// converted to precision, and the number of collisions of convertPoints.
+func (bp *batchpoints) convert(precision string) (*batchpoints, int) {
+	points, collisions := convertPoints(bp.Points(), precision)
+	child := bp.child(points, 0)
+	child.precision = precision
+	child.size = child.pointsSize()
+	return child, collisions
+}
+
+// seriesTime is a series key and a timestamp in nanoseconds.
+type seriesTime struct {
+	key string
+	ns  int64
+}
+
+// convertPoints truncates the timestamps of points to precision, and counts
+// the points that get the timestamp of an earlier point of their series
+// while their own is distinct from those before them. Points that were
+// duplicates already are not counted.
+func convertPoints(points []*Point, precision string) ([]*Point, int) {
+	converted := make([]*Point, len(points))
+	before := make(map[seriesTime]bool, len(points))
+	after := make(map[seriesTime]bool, len(points))
+	var collisions int
+	for i, p := range points {
+		if p == nil {
+			continue
+		}
+		t := p.pt.Time()
+		tt := truncateTime(t, precision)
+		// The point is copied even when its timestamp is unchanged, so that
+		// setting it on either batch leaves the other as it is.
+		converted[i] = &Point{pt: models.PointWithTime(p.pt, tt)}
+		if t.IsZero() {
+			continue
+		}
+		key := string(p.pt.Key())
+		orig := seriesTime{key: key, ns: t.UnixNano()}
+		if before[orig] {
+			continue
+		}
+		before[orig] = true
+		k := seriesTime{key: key, ns: tt.UnixNano()}
+		if after[k] {
+			collisions++
+		}
+		after[k] = true
+	}
+	return converted, collisions
+}
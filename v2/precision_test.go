@@ -0,0 +1,132 @@
+package client
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// convertBatch returns a batch in precision of a cpu point at each of times.
+func convertBatch(t *testing.T, precision string, times ...time.Time) BatchPoints {
+	t.Helper()
+	bp, err := NewBatchPoints(BatchPointsConfig{Database: "db", Precision: precision})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	addTimes(t, bp, times...)
+	return bp
+}
+
+// addTimes adds to bp a cpu point at each of times.
+func addTimes(t *testing.T, bp BatchPoints, times ...time.Time) {
+	t.Helper()
+	for i, tm := range times {
+		pt, err := NewPoint("cpu", map[string]string{"host": "a"}, map[string]interface{}{"value": i}, tm)
+		if err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+		bp.AddPoint(pt)
+	}
+}
+
+// batchLines returns the points of bp as lines in its precision.
+func batchLines(bp BatchPoints) string {
+	var s string
+	for _, p := range bp.Points() {
+		s += p.PrecisionString(bp.Precision()) + "\n"
+	}
+	return s
+}
+
+// pointTimes returns the timestamps of the points of bp.
+func pointTimes(bp BatchPoints) []time.Time {
+	var times []time.Time
+	for _, p := range bp.Points() {
+		times = append(times, p.Time())
+	}
+	return times
+}
+
+func TestConvertPrecision_SecondsToMilliseconds(t *testing.T) {
+	t0 := time.Unix(1600000000, 0)
+	bp := convertBatch(t, "s", t0, t0.Add(time.Second))
+
+	out, err := ConvertPrecision(bp, "ms")
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if out.Precision() != "ms" || out.Database() != "db" {
+		t.Errorf("unexpected batch.  expected %v %v, actual %v %v", "ms", "db", out.Precision(), out.Database())
+	}
+	if exp, got := pointTimes(bp), pointTimes(out); !reflect.DeepEqual(exp, got) {
+		t.Errorf("unexpected times.  expected %v, actual %v", exp, got)
+	}
+	exp := "cpu,host=a value=0i 1600000000000\ncpu,host=a value=1i 1600000001000\n"
+	if got := batchLines(out); got != exp {
+		t.Errorf("unexpected lines.  expected %q, actual %q", exp, got)
+	}
+}
+
+func TestConvertPrecision_NanosecondsToSeconds(t *testing.T) {
+	t0 := time.Unix(1600000000, 0)
+	bp := convertBatch(t, "ns", t0.Add(999*time.Millisecond), t0.Add(time.Second+1))
+	before := pointTimes(bp)
+
+	out, err := ConvertPrecision(bp, "s")
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	exp := []time.Time{t0, t0.Add(time.Second)}
+	if got := pointTimes(out); !reflect.DeepEqual(exp, got) {
+		t.Errorf("unexpected times.  expected %v, actual %v", exp, got)
+	}
+	if got := out.Size(); got != len(batchLines(out)) {
+		t.Errorf("unexpected size.  expected %v, actual %v", len(batchLines(out)), got)
+	}
+
+	// The source batch is left as it was.
+	if bp.Precision() != "ns" {
+		t.Errorf("unexpected source precision.  expected %q, actual %q", "ns", bp.Precision())
+	}
+	if got := pointTimes(bp); !reflect.DeepEqual(before, got) {
+		t.Errorf("unexpected source times.  expected %v, actual %v", before, got)
+	}
+	out.Points()[0].SetTime(t0.Add(time.Hour))
+	if got := pointTimes(bp); !reflect.DeepEqual(before, got) {
+		t.Errorf("unexpected source times after setting the copy.  expected %v, actual %v", before, got)
+	}
+}
+
+func TestConvertPrecision_Collisions(t *testing.T) {
+	t0 := time.Unix(1600000000, 0)
+	// Three distinct timestamps in the same second, one duplicate of the
+	// first and one in the next second.
+	bp, err := NewSafeBatchPoints(BatchPointsConfig{Database: "db"})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	addTimes(t, bp, t0, t0.Add(time.Millisecond), t0.Add(2*time.Millisecond), t0, t0.Add(time.Second))
+
+	out, err := ConvertPrecision(bp, "s")
+	var ce *PrecisionCollisionError
+	if !errors.As(err, &ce) {
+		t.Fatalf("unexpected error.  expected %T, actual %v", ce, err)
+	}
+	if ce.Collisions != 2 || ce.Precision != "s" {
+		t.Errorf("unexpected error.  expected %v collisions in %q, actual %+v", 2, "s", ce)
+	}
+	if out == nil || len(out.Points()) != 5 {
+		t.Fatalf("unexpected batch.  expected %v points, actual %v", 5, out)
+	}
+	if _, ok := out.(*safeBatchPoints); !ok {
+		t.Errorf("unexpected batch.  expected %T, actual %T", bp, out)
+	}
+}
+
+func TestConvertPrecision_InvalidTarget(t *testing.T) {
+	bp := convertBatch(t, "s", time.Unix(1600000000, 0))
+	if out, err := ConvertPrecision(bp, "days"); err == nil || out != nil {
+		t.Errorf("unexpected result.  expected an error, actual %v, %v", out, err)
+	}
+}
@@ -0,0 +1,267 @@
+package client
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Priority is the class of a point queued by a BatchingClient with
+// Priorities, which decides which points are evicted first when the queue is
+// over budget and which are written first.
+type Priority int
+
+const (
+	// PriorityLow points are evicted first and written last.
+	PriorityLow Priority = -1
+
+	// PriorityNormal is the class of the points queued with AddPoint.
+	PriorityNormal Priority = 0
+
+	// PriorityHigh points are evicted last and written first.
+	PriorityHigh Priority = 1
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	}
+	return fmt.Sprintf("Priority(%d)", int(p))
+}
+
+// class returns the index of p in the classes of a priorityQueue, 0 for
+// PriorityHigh, or -1 if p is not a priority.
+func (p Priority) class() int {
+	if p < PriorityLow || p > PriorityHigh {
+		return -1
+	}
+	return int(PriorityHigh - p)
+}
+
+// Default weights of the classes of PriorityOptions.
+const (
+	DefaultHighWeight   = 4
+	DefaultNormalWeight = 2
+	DefaultLowWeight    = 1
+)
+
+// PriorityOptions makes a BatchingClient queue its points by Priority, in
+// place of its buffer. The queued points of every class share the budget of
+// the BufferSize points and MaxBytes, and while the queue is over budget the
+// oldest Low points are evicted, then the Normal ones, then the High ones; a
+// point is never evicted for one of a lower class, which is dropped instead.
+// The evicted points are reported to OnError with ErrBufferFull, and to the
+// PriorityStatsCollector of the wrapped client. The Overflow policy does not
+// apply: adding a point never blocks.
+//
+// Every batch is taken from the classes in turns, up to their weight of
+// points each, starting with High, so that High points are written first
+// but the lower classes are not starved while High points keep coming.
+// Close writes the points left strictly High first.
+type PriorityOptions struct {
+	// MaxBytes, if positive, is the budget of the queue in bytes of line
+	// protocol, along with BufferSize points.
+	MaxBytes int
+
+	// HighWeight, NormalWeight and LowWeight are the points taken from each
+	// class in a turn, default to DefaultHighWeight, DefaultNormalWeight and
+	// DefaultLowWeight.
+	HighWeight   int
+	NormalWeight int
+	LowWeight    int
+}
+
+func (o *PriorityOptions) validate() error {
+	if o.MaxBytes < 0 {
+		return &ConfigError{Field: "Priorities.MaxBytes", Reason: "must not be negative"}
+	}
+	for _, w := range []struct {
+		field string
+		value int
+	}{
+		{"Priorities.HighWeight", o.HighWeight},
+		{"Priorities.NormalWeight", o.NormalWeight},
+		{"Priorities.LowWeight", o.LowWeight},
+	} {
+		if w.value < 0 {
+			return &ConfigError{Field: w.field, Reason: "must not be negative"}
+		}
+	}
+	return nil
+}
+
+// PriorityStatsCollector is implemented by a StatsCollector that is told
+// about the points a BatchingClient with Priorities evicted, when it is set
+// on the HTTP, UDP or TCP client the BatchingClient writes with.
+type PriorityStatsCollector interface {
+	// PointsEvicted is called with the number of points of class prio
+	// evicted to keep the queue within its budget.
+	PointsEvicted(prio Priority, points int)
+}
+
+// queuedPoint is a point of a priorityQueue along with its size.
+type queuedPoint struct {
+	p    *Point
+	size int
+}
+
+// priorityQueue holds the points of a BatchingClient with Priorities, in a
+// FIFO per class, High first.
+type priorityQueue struct {
+	maxPoints int
+	maxBytes  int
+	weights   [3]int
+
+	mu      sync.Mutex
+	classes [3][]queuedPoint
+	points  int
+	bytes   int
+	// sizes are the bytes queued in every class.
+	sizes [3]int
+
+	// ready is signaled when points are queued.
+	ready chan struct{}
+}
+
+func newPriorityQueue(maxPoints int, opts PriorityOptions) *priorityQueue {
+	weights := [3]int{opts.HighWeight, opts.NormalWeight, opts.LowWeight}
+	for i, def := range [3]int{DefaultHighWeight, DefaultNormalWeight, DefaultLowWeight} {
+		if weights[i] == 0 {
+			weights[i] = def
+		}
+	}
+	return &priorityQueue{
+		maxPoints: maxPoints,
+		maxBytes:  opts.MaxBytes,
+		weights:   weights,
+		ready:     make(chan struct{}, 1),
+	}
+}
+
+// push queues p with prio, evicting points to keep the queue within its
+// budget, and returns the points evicted by class, including p if it was
+// not queued.
+func (q *priorityQueue) push(p *Point, prio Priority) (evicted [3][]*Point) {
+	c := prio.class()
+	if c < 0 {
+		c = PriorityNormal.class()
+	}
+	qp := queuedPoint{p: p, size: p.pt.StringSize() + 1}
+
+	q.mu.Lock()
+	// p is dropped if evicting every point of its class and the lower
+	// ones would not make room for it.
+	points, bytes := q.points, q.bytes
+	for i := c; i < len(q.classes); i++ {
+		points -= len(q.classes[i])
+		bytes -= q.sizes[i]
+	}
+	if points+1 > q.maxPoints || q.maxBytes > 0 && bytes+qp.size > q.maxBytes {
+		q.mu.Unlock()
+		evicted[c] = []*Point{p}
+		return evicted
+	}
+	for q.over(qp.size) {
+		low := len(q.classes) - 1
+		for len(q.classes[low]) == 0 {
+			low--
+		}
+		evicted[low] = q.pop(evicted[low], low, 1)
+	}
+	q.classes[c] = append(q.classes[c], qp)
+	q.points++
+	q.bytes += qp.size
+	q.sizes[c] += qp.size
+	q.mu.Unlock()
+
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+	return evicted
+}
+
+// over reports whether adding a point of size would put the queue over
+// budget. q.mu must be held.
+func (q *priorityQueue) over(size int) bool {
+	return q.points+1 > q.maxPoints || q.maxBytes > 0 && q.bytes+size > q.maxBytes
+}
+
+// len returns the number of points queued.
+func (q *priorityQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.points
+}
+
+// lens returns the number of points queued in every class.
+func (q *priorityQueue) lens() [3]int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var n [3]int
+	for i := range q.classes {
+		n[i] = len(q.classes[i])
+	}
+	return n
+}
+
+// take removes up to n points, and at most limit[i] points of class i, in
+// weighted turns starting with High, and decrements limit by the points
+// taken.
+func (q *priorityQueue) take(n int, limit *[3]int) []*Point {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var points []*Point
+	for len(points) < n {
+		taken := len(points)
+		for i := range q.classes {
+			k := q.weights[i]
+			if k > limit[i] {
+				k = limit[i]
+			}
+			if k > n-len(points) {
+				k = n - len(points)
+			}
+			before := len(points)
+			points = q.pop(points, i, k)
+			limit[i] -= len(points) - before
+		}
+		if len(points) == taken {
+			break
+		}
+	}
+	return points
+}
+
+// takeOrdered removes up to n points, strictly High first.
+func (q *priorityQueue) takeOrdered(n int) []*Point {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var points []*Point
+	for i := range q.classes {
+		points = q.pop(points, i, n-len(points))
+	}
+	return points
+}
+
+// pop appends up to k points of class i to points. q.mu must be held.
+func (q *priorityQueue) pop(points []*Point, i, k int) []*Point {
+	if k > len(q.classes[i]) {
+		k = len(q.classes[i])
+	}
+	for _, qp := range q.classes[i][:k] {
+		points = append(points, qp.p)
+		q.points--
+		q.bytes -= qp.size
+		q.sizes[i] -= qp.size
+	}
+	for j := range q.classes[i][:k] {
+		q.classes[i][j] = queuedPoint{}
+	}
+	q.classes[i] = q.classes[i][k:]
+	return points
+}
@@ -0,0 +1,167 @@
+package client
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// priorityStats records the points evicted along with the writes.
+type priorityStats struct {
+	recordingStats
+	mu      sync.Mutex
+	evicted map[Priority]int
+}
+
+func (s *priorityStats) PointsEvicted(prio Priority, points int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.evicted == nil {
+		s.evicted = make(map[Priority]int)
+	}
+	s.evicted[prio] += points
+}
+
+// priorityPoint returns a point of the measurement name with the value v.
+func priorityPoint(name string, v int) *Point {
+	p, _ := NewPoint(name, nil, map[string]interface{}{"v": v}, time.Unix(1, 0))
+	return p
+}
+
+func pointNames(points []*Point) string {
+	var names []string
+	for _, p := range points {
+		names = append(names, p.Name())
+	}
+	return strings.Join(names, " ")
+}
+
+func TestBatchingClient_PriorityEviction(t *testing.T) {
+	var mu sync.Mutex
+	var written []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+			written = append(written, strings.Fields(line)[0])
+		}
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+	stats := &priorityStats{}
+	hc, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, Stats: stats})
+	defer hc.Close()
+
+	var evicted []*Point
+	bc, err := NewBatchingClient(hc, BatchingOptions{
+		BatchSize:     100,
+		BufferSize:    4,
+		FlushInterval: time.Hour,
+		Priorities:    &PriorityOptions{},
+		OnError: func(err error, points []*Point) {
+			if err != ErrBufferFull {
+				t.Errorf("unexpected error.  expected %v, actual %v", ErrBufferFull, err)
+			}
+			evicted = append(evicted, points...)
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+
+	bc.AddPointWithPriority(priorityPoint("l0", 0), PriorityLow)
+	bc.AddPointWithPriority(priorityPoint("l1", 1), PriorityLow)
+	bc.AddPoint(priorityPoint("n0", 2))
+	bc.AddPoint(priorityPoint("n1", 3))
+	// The queue is full: the Low points go first, then the oldest Normal
+	// one, and a Low point is not queued in place of a Normal one.
+	bc.AddPointWithPriority(priorityPoint("h0", 4), PriorityHigh)
+	bc.AddPointWithPriority(priorityPoint("h1", 5), PriorityHigh)
+	bc.AddPointWithPriority(priorityPoint("n2", 6), PriorityNormal)
+	bc.AddPointWithPriority(priorityPoint("l2", 7), PriorityLow)
+	bc.AddPointWithPriority(priorityPoint("h2", 8), PriorityHigh)
+	bc.AddPointWithPriority(priorityPoint("h3", 9), PriorityHigh)
+	bc.AddPointWithPriority(priorityPoint("h4", 10), PriorityHigh)
+
+	if exp := "l0 l1 n0 l2 n1 n2 h0"; pointNames(evicted) != exp {
+		t.Errorf("unexpected evicted points.  expected %v, actual %v", exp, pointNames(evicted))
+	}
+	if exp := map[Priority]int{PriorityLow: 3, PriorityNormal: 3, PriorityHigh: 1}; !reflect.DeepEqual(stats.evicted, exp) {
+		t.Errorf("unexpected evictions.  expected %v, actual %v", exp, stats.evicted)
+	}
+
+	bc.Close()
+	if exp := []string{"h1", "h2", "h3", "h4"}; !reflect.DeepEqual(written, exp) {
+		t.Errorf("unexpected points written.  expected %v, actual %v", exp, written)
+	}
+}
+
+func TestBatchingClient_PriorityMaxBytes(t *testing.T) {
+	var r batchRecorder
+	var evicted []*Point
+	size := priorityPoint("h0", 0).pt.StringSize() + 1
+	bc, _ := NewBatchingClient(&r, BatchingOptions{
+		BatchSize:     100,
+		FlushInterval: time.Hour,
+		Priorities:    &PriorityOptions{MaxBytes: 2 * size},
+		OnError:       func(err error, points []*Point) { evicted = append(evicted, points...) },
+	})
+	bc.AddPointWithPriority(priorityPoint("h0", 0), PriorityHigh)
+	bc.AddPoint(priorityPoint("n0", 1))
+	bc.AddPointWithPriority(priorityPoint("h1", 2), PriorityHigh)
+	bc.AddPointWithPriority(priorityPoint("h2", 3), PriorityHigh)
+	bc.Close()
+	if pointNames(evicted) != "n0 h0" || len(r.batches) != 1 || pointNames(r.batches[0]) != "h1 h2" {
+		t.Errorf("unexpected evicted points %v and batches %v", pointNames(evicted), r.batches)
+	}
+}
+
+func TestPriorityQueue_Weights(t *testing.T) {
+	q := newPriorityQueue(100, PriorityOptions{})
+	for i := 0; i < 20; i++ {
+		q.push(priorityPoint("h", i), PriorityHigh)
+	}
+	for i := 0; i < 5; i++ {
+		q.push(priorityPoint("n", i), PriorityNormal)
+		q.push(priorityPoint("l", i), PriorityLow)
+	}
+
+	// Every batch takes from every class in turns, so that the Low points
+	// are written along with the High ones rather than after them.
+	limit := q.lens()
+	var batches []string
+	for {
+		points := q.take(7, &limit)
+		if len(points) == 0 {
+			break
+		}
+		batches = append(batches, pointNames(points))
+	}
+	exp := []string{
+		"h h h h n n l",
+		"h h h h n n l",
+		"h h h h n l h",
+		"h h h h l h h",
+		"h l",
+	}
+	if !reflect.DeepEqual(batches, exp) {
+		t.Errorf("unexpected batches.\nexpected %q\nactual   %q", exp, batches)
+	}
+}
+
+func TestNewBatchingClient_InvalidPriorities(t *testing.T) {
+	var r batchRecorder
+	for _, opts := range []PriorityOptions{{MaxBytes: -1}, {LowWeight: -1}} {
+		var ce *ConfigError
+		if _, err := NewBatchingClient(&r, BatchingOptions{Priorities: &opts}); !errors.As(err, &ce) {
+			t.Errorf("unexpected error for %+v.  expected %T, actual %v", opts, ce, err)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+// Package promcollector exposes the metrics of InfluxDB clients as
+// Prometheus metrics, the same ones client.ExpvarCollector publishes with the
+// expvar package. It is kept apart from package client so that only the
+// programs importing it depend on client_golang.
+package promcollector // import "github.com/influxdata/influxdb1-client/v2/promcollector"
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	client "github.com/influxdata/influxdb1-client/v2"
+)
+
+// labelNames are the labels of every metric, from the MetricLabels of the
+// clients.
+var labelNames = []string{"address", "database"}
+
+// Collector is a prometheus.Collector of the metrics of the clients whose
+// config has the StatsCollector returned by Client:
+//
+//   - points_written_total, bytes_written_total, write_errors_total and
+//     retries_total, counters of the writes;
+//   - query_duration_seconds, a summary of the queries;
+//   - queue_depth, a gauge of the points held by the BatchingClients
+//     wrapping the clients;
+//   - circuit_state, a gauge of the client.CircuitState of their breaker.
+//
+// Every metric is labeled address and database. Collector is itself a
+// client.StatsCollector reporting with empty labels. It is safe for
+// concurrent use.
+type Collector struct {
+	pointsWritten *prometheus.CounterVec
+	bytesWritten  *prometheus.CounterVec
+	writeErrors   *prometheus.CounterVec
+	retries       *prometheus.CounterVec
+	queryDuration *prometheus.SummaryVec
+	queueDepth    *prometheus.GaugeVec
+	circuitState  *prometheus.GaugeVec
+}
+
+// New returns a Collector whose metric names are prefixed with namespace,
+// as in influxdb_points_written_total, unless it is empty.
+func New(namespace string) *Collector {
+	counter := func(name, help string) *prometheus.CounterVec {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{Namespace: namespace, Name: name, Help: help}, labelNames)
+	}
+	gauge := func(name, help string) *prometheus.GaugeVec {
+		return prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: namespace, Name: name, Help: help}, labelNames)
+	}
+	return &Collector{
+		pointsWritten: counter("points_written_total", "Points written to InfluxDB."),
+		bytesWritten:  counter("bytes_written_total", "Bytes of the payloads written to InfluxDB, after compression."),
+		writeErrors:   counter("write_errors_total", "Attempts to write to InfluxDB that failed."),
+		retries:       counter("retries_total", "Writes to InfluxDB sent again after a failure."),
+		queryDuration: prometheus.NewSummaryVec(prometheus.SummaryOpts{
+			Namespace:  namespace,
+			Name:       "query_duration_seconds",
+			Help:       "Duration of the queries to InfluxDB.",
+			Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
+		}, labelNames),
+		queueDepth:   gauge("queue_depth", "Points held by the batching clients before being written."),
+		circuitState: gauge("circuit_state", "State of the circuit breaker: 0 closed, 1 open, 2 half-open."),
+	}
+}
+
+func (c *Collector) collectors() []prometheus.Collector {
+	return []prometheus.Collector{c.pointsWritten, c.bytesWritten, c.writeErrors, c.retries, c.queryDuration, c.queueDepth, c.circuitState}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, m := range c.collectors() {
+		m.Describe(ch)
+	}
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	for _, m := range c.collectors() {
+		m.Collect(ch)
+	}
+}
+
+// Client returns the StatsCollector of a client with labels, to be set as
+// the Stats of its config. It counts along with the other clients of the
+// same labels.
+func (c *Collector) Client(labels client.MetricLabels) client.StatsCollector {
+	return c.client(labels)
+}
+
+func (c *Collector) client(labels client.MetricLabels) *clientStats {
+	values := []string{labels.Address, labels.Database}
+	return &clientStats{
+		pointsWritten: c.pointsWritten.WithLabelValues(values...),
+		bytesWritten:  c.bytesWritten.WithLabelValues(values...),
+		writeErrors:   c.writeErrors.WithLabelValues(values...),
+		retries:       c.retries.WithLabelValues(values...),
+		queryDuration: c.queryDuration.WithLabelValues(values...),
+		queueDepth:    c.queueDepth.WithLabelValues(values...),
+		circuitState:  c.circuitState.WithLabelValues(values...),
+	}
+}
+
+func (c *Collector) WriteDone(points, bytes int, dur time.Duration, err error) {
+	c.client(client.MetricLabels{}).WriteDone(points, bytes, dur, err)
+}
+
+func (c *Collector) QueryDone(q string, dur time.Duration, err error) {
+	c.client(client.MetricLabels{}).QueryDone(q, dur, err)
+}
+
+func (c *Collector) WriteRetried(points int, err error) {
+	c.client(client.MetricLabels{}).WriteRetried(points, err)
+}
+
+func (c *Collector) QueueDepthChanged(delta int) {
+	c.client(client.MetricLabels{}).QueueDepthChanged(delta)
+}
+
+func (c *Collector) CircuitStateChanged(from, to client.CircuitState) {
+	c.client(client.MetricLabels{}).CircuitStateChanged(from, to)
+}
+
+// clientStats is the StatsCollector of the clients of some labels in a
+// Collector.
+type clientStats struct {
+	pointsWritten, bytesWritten, writeErrors, retries prometheus.Counter
+	queryDuration                                     prometheus.Observer
+	queueDepth, circuitState                          prometheus.Gauge
+}
+
+func (s *clientStats) WriteDone(points, bytes int, dur time.Duration, err error) {
+	if err != nil {
+		s.writeErrors.Inc()
+		return
+	}
+	s.pointsWritten.Add(float64(points))
+	s.bytesWritten.Add(float64(bytes))
+}
+
+func (s *clientStats) QueryDone(q string, dur time.Duration, err error) {
+	s.queryDuration.Observe(dur.Seconds())
+}
+
+func (s *clientStats) WriteRetried(points int, err error) { s.retries.Inc() }
+
+func (s *clientStats) QueueDepthChanged(delta int) { s.queueDepth.Add(float64(delta)) }
+
+func (s *clientStats) CircuitStateChanged(from, to client.CircuitState) {
+	s.circuitState.Set(float64(to))
+}
@@ -0,0 +1,150 @@
+package promcollector
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	client "github.com/influxdata/influxdb1-client/v2"
+)
+
+// metric returns the metric of the family name with the address and
+// database labels of labels, nil if there is none.
+func metric(t *testing.T, reg *prometheus.Registry, name string, labels client.MetricLabels) *dto.Metric {
+	t.Helper()
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	for _, mf := range mfs {
+		if mf.GetName() != name {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			values := map[string]string{}
+			for _, lp := range m.GetLabel() {
+				values[lp.GetName()] = lp.GetValue()
+			}
+			if values["address"] == labels.Address && values["database"] == labels.Database {
+				return m
+			}
+		}
+	}
+	return nil
+}
+
+func TestCollector(t *testing.T) {
+	var writes int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/write":
+			if atomic.AddInt32(&writes, 1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case "/query":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"results":[{"statement_id":0}]}`))
+		}
+	}))
+	defer ts.Close()
+
+	collector := New("influxdb")
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(collector)
+
+	labels := client.MetricLabels{Address: ts.URL, Database: "db0"}
+	c, _ := client.NewHTTPClient(client.HTTPConfig{Addr: ts.URL, MaxRetries: 1, RetryInterval: time.Millisecond, Stats: collector.Client(labels)})
+	defer c.Close()
+
+	bp, _ := client.NewBatchPoints(client.BatchPointsConfig{Database: "db0"})
+	for i := 0; i < 3; i++ {
+		p, _ := client.NewPoint("cpu", nil, map[string]interface{}{"value": i}, time.Unix(int64(i), 0))
+		bp.AddPoint(p)
+	}
+	if err := c.Write(bp); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if _, err := c.Query(client.NewQuery("SHOW DATABASES", "", "")); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+
+	for _, tt := range []struct {
+		name string
+		exp  float64
+	}{
+		{"influxdb_points_written_total", 3},
+		{"influxdb_bytes_written_total", float64(bp.Size())},
+		{"influxdb_write_errors_total", 1},
+		{"influxdb_retries_total", 1},
+	} {
+		m := metric(t, reg, tt.name, labels)
+		if v := m.GetCounter().GetValue(); v != tt.exp {
+			t.Errorf("unexpected %s.  expected %v, actual %v", tt.name, tt.exp, v)
+		}
+	}
+	if s := metric(t, reg, "influxdb_query_duration_seconds", labels).GetSummary(); s.GetSampleCount() != 1 || s.GetSampleSum() <= 0 {
+		t.Errorf("unexpected query_duration_seconds: %v", s)
+	}
+}
+
+func TestCollector_Gauges(t *testing.T) {
+	collector := New("")
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(collector)
+
+	labels := client.MetricLabels{Address: "http://a:8086"}
+	stats := collector.Client(labels)
+	stats.(client.QueueStatsCollector).QueueDepthChanged(5)
+	stats.(client.QueueStatsCollector).QueueDepthChanged(-2)
+	stats.(client.CircuitStatsCollector).CircuitStateChanged(client.CircuitClosed, client.CircuitOpen)
+
+	if v := metric(t, reg, "queue_depth", labels).GetGauge().GetValue(); v != 3 {
+		t.Errorf("unexpected queue_depth.  expected %v, actual %v", 3, v)
+	}
+	if v := metric(t, reg, "circuit_state", labels).GetGauge().GetValue(); v != float64(client.CircuitOpen) {
+		t.Errorf("unexpected circuit_state.  expected %v, actual %v", float64(client.CircuitOpen), v)
+	}
+}
+
+func TestCollector_Concurrent(t *testing.T) {
+	collector := New("influxdb")
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(collector)
+
+	labels := []client.MetricLabels{{Address: "http://a:8086", Database: "db0"}, {Address: "http://b:8086", Database: "db1"}}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			stats := collector.Client(labels[i%2])
+			for j := 0; j < 100; j++ {
+				stats.WriteDone(2, 10, time.Millisecond, nil)
+				stats.WriteDone(2, 10, time.Millisecond, errors.New("timeout"))
+				collector.WriteDone(1, 1, time.Millisecond, nil)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for _, l := range labels {
+		if v := metric(t, reg, "influxdb_points_written_total", l).GetCounter().GetValue(); v != 800 {
+			t.Errorf("%v: unexpected points_written_total.  expected %v, actual %v", l, 800, v)
+		}
+		if v := metric(t, reg, "influxdb_write_errors_total", l).GetCounter().GetValue(); v != 400 {
+			t.Errorf("%v: unexpected write_errors_total.  expected %v, actual %v", l, 400, v)
+		}
+	}
+	if v := metric(t, reg, "influxdb_points_written_total", client.MetricLabels{}).GetCounter().GetValue(); v != 800 {
+		t.Errorf("unexpected points_written_total without labels.  expected %v, actual %v", 800, v)
+	}
+}
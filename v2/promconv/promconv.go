@@ -0,0 +1,211 @@
+// Package promconv converts Prometheus metrics to points of the InfluxDB
+// client, so that the metrics of a client_golang registry can be written to
+// InfluxDB 1.x. It is kept apart from package client so that only the
+// programs importing it depend on client_golang.
+package promconv // import "github.com/influxdata/influxdb1-client/v2/promconv"
+
+import (
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	client "github.com/influxdata/influxdb1-client/v2"
+)
+
+// Layout is how histograms and summaries are converted.
+type Layout int
+
+const (
+	// Fields converts a histogram or summary to a single point, with a sum
+	// and a count field and a field per bucket or quantile, named after its
+	// upper bound or quantile.
+	Fields Layout = iota
+
+	// Points converts a histogram or summary to a point per bucket or
+	// quantile, tagged le or quantile with its upper bound or quantile and
+	// with a single value field, along with a point holding the sum and
+	// count fields.
+	Points
+)
+
+// Options sets how metrics are converted.
+type Options struct {
+	// Namespace, if set, is joined with an underscore before the names of
+	// the metrics to make the names of the measurements.
+	Namespace string
+
+	// Layout defaults to Fields.
+	Layout Layout
+
+	// Label, if set, returns the tag key of a label, or "" to drop the
+	// label. The names of the labels are used as they are otherwise.
+	Label func(name string) string
+
+	// Time is the time of the metrics without a timestamp, defaults to the
+	// time of the conversion.
+	Time time.Time
+}
+
+// Gather gathers the metrics of g and converts them. The metrics gathered are
+// converted even if g returns an error, which is returned along with them.
+func Gather(g prometheus.Gatherer, opts Options) ([]*client.Point, error) {
+	mfs, err := g.Gather()
+	pts, cerr := Convert(mfs, opts)
+	if err != nil {
+		return pts, err
+	}
+	return pts, cerr
+}
+
+// Convert converts the metrics of mfs to points, in their order. Counters,
+// gauges and untyped metrics become points with a single value field, and
+// histograms and summaries are converted as set by opts.Layout. The labels of
+// a metric become the tags of its points, dropping those with an empty value.
+//
+// Values InfluxDB cannot store, such as the NaN quantiles of a summary
+// without observations, are left out, as are the points left without a field.
+func Convert(mfs []*dto.MetricFamily, opts Options) ([]*client.Point, error) {
+	c := converter{opts: opts, now: opts.Time}
+	if c.now.IsZero() {
+		c.now = time.Now()
+	}
+	for _, mf := range mfs {
+		name := mf.GetName()
+		if opts.Namespace != "" {
+			name = opts.Namespace + "_" + name
+		}
+		for _, m := range mf.GetMetric() {
+			if err := c.metric(name, mf.GetType(), m); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return c.points, nil
+}
+
+// converter accumulates the points of a conversion.
+type converter struct {
+	opts   Options
+	now    time.Time
+	points []*client.Point
+}
+
+// metric converts a metric of the measurement name.
+func (c *converter) metric(name string, typ dto.MetricType, m *dto.Metric) error {
+	t := c.now
+	if ms := m.GetTimestampMs(); ms != 0 {
+		t = time.Unix(0, ms*int64(time.Millisecond))
+	}
+	tags := c.tags(m)
+
+	switch typ {
+	case dto.MetricType_COUNTER:
+		return c.point(name, tags, "", "", t, "value", m.GetCounter().GetValue())
+	case dto.MetricType_GAUGE:
+		return c.point(name, tags, "", "", t, "value", m.GetGauge().GetValue())
+	case dto.MetricType_UNTYPED:
+		return c.point(name, tags, "", "", t, "value", m.GetUntyped().GetValue())
+	case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
+		h := m.GetHistogram()
+		var bounds []float64
+		var counts []float64
+		for _, b := range h.GetBucket() {
+			bounds = append(bounds, b.GetUpperBound())
+			counts = append(counts, float64(b.GetCumulativeCount()))
+		}
+		// The +Inf bucket, which holds every observation, is implied.
+		if n := len(bounds); n == 0 || !math.IsInf(bounds[n-1], 1) {
+			bounds = append(bounds, math.Inf(1))
+			counts = append(counts, float64(h.GetSampleCount()))
+		}
+		return c.distribution(name, tags, t, "le", bounds, counts, h.GetSampleSum(), h.GetSampleCount())
+	case dto.MetricType_SUMMARY:
+		s := m.GetSummary()
+		var quantiles []float64
+		var values []float64
+		for _, q := range s.GetQuantile() {
+			quantiles = append(quantiles, q.GetQuantile())
+			values = append(values, q.GetValue())
+		}
+		return c.distribution(name, tags, t, "quantile", quantiles, values, s.GetSampleSum(), s.GetSampleCount())
+	}
+	return nil
+}
+
+// distribution converts a histogram or a summary, whose buckets or quantiles
+// are keyed by bounds and tagged with the tag key.
+func (c *converter) distribution(name string, tags map[string]string, t time.Time, key string, bounds, values []float64, sum float64, count uint64) error {
+	if c.opts.Layout == Points {
+		for i, b := range bounds {
+			if err := c.point(name, tags, key, formatBound(b), t, "value", values[i]); err != nil {
+				return err
+			}
+		}
+		return c.point(name, tags, "", "", t, "sum", sum, "count", float64(count))
+	}
+
+	kv := []interface{}{"sum", sum, "count", float64(count)}
+	for i, b := range bounds {
+		kv = append(kv, formatBound(b), values[i])
+	}
+	return c.point(name, tags, "", "", t, kv...)
+}
+
+// point adds a point of the measurement name, with tags and the tag key set
+// to value if key is not empty, and the fields of kv, pairs of a key and a
+// float64 value.
+func (c *converter) point(name string, tags map[string]string, key, value string, t time.Time, kv ...interface{}) error {
+	fields := make(map[string]interface{}, len(kv)/2)
+	for i := 0; i < len(kv); i += 2 {
+		v := kv[i+1].(float64)
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			continue
+		}
+		fields[kv[i].(string)] = v
+	}
+	if len(fields) == 0 {
+		return nil
+	}
+	if key != "" {
+		extended := make(map[string]string, len(tags)+1)
+		for k, v := range tags {
+			extended[k] = v
+		}
+		extended[key] = value
+		tags = extended
+	}
+	pt, err := client.NewPoint(name, tags, fields, t)
+	if err != nil {
+		return err
+	}
+	c.points = append(c.points, pt)
+	return nil
+}
+
+// tags returns the tags of the labels of m.
+func (c *converter) tags(m *dto.Metric) map[string]string {
+	tags := make(map[string]string, len(m.GetLabel()))
+	for _, lp := range m.GetLabel() {
+		k, v := lp.GetName(), lp.GetValue()
+		if c.opts.Label != nil {
+			k = c.opts.Label(k)
+		}
+		if k == "" || v == "" {
+			continue
+		}
+		tags[k] = v
+	}
+	return tags
+}
+
+// formatBound formats a bucket upper bound or a quantile the way Prometheus
+// does in its le and quantile labels.
+func formatBound(b float64) string {
+	if math.IsInf(b, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(b, 'g', -1, 64)
+}
@@ -0,0 +1,134 @@
+package promconv
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	client "github.com/influxdata/influxdb1-client/v2"
+)
+
+var testTime = time.Unix(1, 0)
+
+func newTestRegistry() *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+
+	requests := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "requests_total", Help: "Requests."}, []string{"code", "path"})
+	requests.WithLabelValues("200", "/write").Add(3)
+	requests.WithLabelValues("500", "").Inc()
+
+	temperature := prometheus.NewGauge(prometheus.GaugeOpts{Name: "temperature", Help: "Temperature.", ConstLabels: prometheus.Labels{"room": "lab"}})
+	temperature.Set(21.5)
+
+	latency := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "latency_seconds", Help: "Latency.", Buckets: []float64{0.1, 1}})
+	for _, v := range []float64{0.05, 0.5, 2} {
+		latency.Observe(v)
+	}
+
+	size := prometheus.NewSummary(prometheus.SummaryOpts{Name: "size_bytes", Help: "Size.", Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01}})
+
+	up := prometheus.NewUntypedFunc(prometheus.UntypedOpts{Name: "up", Help: "Up."}, func() float64 { return 1 })
+
+	reg.MustRegister(requests, temperature, latency, size, up)
+	return reg
+}
+
+func lines(pts []*client.Point) []string {
+	var s []string
+	for _, p := range pts {
+		s = append(s, p.String())
+	}
+	sort.Strings(s)
+	return s
+}
+
+func TestGather(t *testing.T) {
+	pts, err := Gather(newTestRegistry(), Options{Time: testTime})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	exp := []string{
+		"latency_seconds +Inf=3,0.1=1,1=2,count=3,sum=2.55 1000000000",
+		"requests_total,code=200,path=/write value=3 1000000000",
+		"requests_total,code=500 value=1 1000000000",
+		"size_bytes count=0,sum=0 1000000000",
+		"temperature,room=lab value=21.5 1000000000",
+		"up value=1 1000000000",
+	}
+	if got := lines(pts); !reflect.DeepEqual(got, exp) {
+		t.Errorf("unexpected points.\nexpected %q\nactual   %q", exp, got)
+	}
+}
+
+func TestGather_Points(t *testing.T) {
+	reg := newTestRegistry()
+	size := prometheus.NewSummary(prometheus.SummaryOpts{Name: "observed_bytes", Help: "Size.", Objectives: map[float64]float64{0.5: 0.05}})
+	for _, v := range []float64{10, 20, 30} {
+		size.Observe(v)
+	}
+	reg.MustRegister(size)
+
+	label := func(name string) string {
+		if name == "path" {
+			return ""
+		}
+		return "prom_" + name
+	}
+	pts, err := Gather(reg, Options{Namespace: "app", Layout: Points, Label: label, Time: testTime})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	exp := []string{
+		"app_latency_seconds count=3,sum=2.55 1000000000",
+		"app_latency_seconds,le=+Inf value=3 1000000000",
+		"app_latency_seconds,le=0.1 value=1 1000000000",
+		"app_latency_seconds,le=1 value=2 1000000000",
+		"app_observed_bytes count=3,sum=60 1000000000",
+		"app_observed_bytes,quantile=0.5 value=20 1000000000",
+		"app_requests_total,prom_code=200 value=3 1000000000",
+		"app_requests_total,prom_code=500 value=1 1000000000",
+		"app_size_bytes count=0,sum=0 1000000000",
+		"app_temperature,prom_room=lab value=21.5 1000000000",
+		"app_up value=1 1000000000",
+	}
+	if got := lines(pts); !reflect.DeepEqual(got, exp) {
+		t.Errorf("unexpected points.\nexpected %q\nactual   %q", exp, got)
+	}
+}
+
+func TestConvert_Timestamp(t *testing.T) {
+	name, v, ms := "mem", 2.0, int64(1500)
+	mfs := []*dto.MetricFamily{{
+		Name:   &name,
+		Type:   dto.MetricType_GAUGE.Enum(),
+		Metric: []*dto.Metric{{Gauge: &dto.Gauge{Value: &v}, TimestampMs: &ms}},
+	}}
+	pts, err := Convert(mfs, Options{})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if len(pts) != 1 || !pts[0].Time().Equal(time.Unix(1, 5e8)) {
+		t.Errorf("unexpected points: %v", pts)
+	}
+}
+
+func TestGather_Error(t *testing.T) {
+	errGather := errors.New("collector failed")
+	g := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		mfs, _ := newTestRegistry().Gather()
+		return mfs, errGather
+	})
+	pts, err := Gather(g, Options{})
+	if err != errGather {
+		t.Errorf("unexpected error.  expected %v, actual %v", errGather, err)
+	}
+	if len(pts) != 6 || !strings.HasPrefix(pts[0].Name(), "latency_seconds") {
+		t.Errorf("unexpected points: %v", pts)
+	}
+}
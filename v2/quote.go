@@ -0,0 +1,55 @@
+package client
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+var identReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// QuoteIdent quotes s as an InfluxQL identifier, such as a database,
+// measurement, tag or field name, for queries written by hand. Backslashes,
+// double quotes and newlines are escaped with a backslash, as the InfluxQL
+// scanner expects: it does not take a doubled quote for one.
+func QuoteIdent(s string) string {
+	return `"` + identReplacer.Replace(s) + `"`
+}
+
+var stringReplacer = strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\n", `\n`)
+
+// QuoteString quotes s as an InfluxQL string literal, such as a tag value
+// or a password. Backslashes, single quotes and newlines are escaped with a
+// backslash.
+func QuoteString(s string) string {
+	return `'` + stringReplacer.Replace(s) + `'`
+}
+
+// FormatTime formats t as an InfluxQL time literal: an RFC3339 string with
+// nanoseconds in UTC, single quoted, as in time > '2021-01-01T00:00:00Z'.
+func FormatTime(t time.Time) string {
+	return QuoteString(t.UTC().Format(time.RFC3339Nano))
+}
+
+// FormatDuration formats d as an InfluxQL duration literal in the largest
+// unit that represents it exactly, such as 90m or 1500ns, with a minus sign
+// if it is negative. Unlike the durations of a retention policy no value is
+// taken for INF.
+func FormatDuration(d time.Duration) string {
+	if d < 0 {
+		if d == -d {
+			// The smallest duration has no positive counterpart.
+			return "-" + strconv.FormatUint(uint64(d), 10) + "ns"
+		}
+		return "-" + FormatDuration(-d)
+	}
+	if d == 0 {
+		return "0s"
+	}
+	for _, u := range durationUnits {
+		if d%u.unit == 0 {
+			return strconv.FormatInt(int64(d/u.unit), 10) + u.name
+		}
+	}
+	return strconv.FormatInt(int64(d), 10) + "ns"
+}
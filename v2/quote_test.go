@@ -0,0 +1,78 @@
+package client
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestQuote(t *testing.T) {
+	for _, s := range []string{`a"b`, `a'b`, `\`, `\"`, "a\nb", "ünïcødé ✓", `"; DROP DATABASE db0; --`} {
+		if got := unquote(t, QuoteIdent(s)); got != s {
+			t.Errorf("unexpected identifier.  expected %q, actual %q", s, got)
+		}
+		if got := unquote(t, QuoteString(s)); got != s {
+			t.Errorf("unexpected string.  expected %q, actual %q", s, got)
+		}
+		q := NewQuery("SELECT "+QuoteIdent(s)+" FROM cpu WHERE host = "+QuoteString(s)+"; SHOW DATABASES", "", "")
+		if n := len(q.Statements()); n != 2 {
+			t.Errorf("unexpected number of statements for %q.  expected %v, actual %v", s, 2, n)
+		}
+	}
+	if exp := `"a\"b\\c"`; QuoteIdent(`a"b\c`) != exp {
+		t.Errorf("unexpected identifier.  expected %s, actual %s", exp, QuoteIdent(`a"b\c`))
+	}
+}
+
+func TestFormatTime(t *testing.T) {
+	ts := time.Date(2021, 3, 4, 5, 6, 7, 890, time.FixedZone("CET", 3600))
+	s := FormatTime(ts)
+	if exp := `'2021-03-04T04:06:07.00000089Z'`; s != exp {
+		t.Errorf("unexpected time.  expected %s, actual %s", exp, s)
+	}
+	if parsed, err := time.Parse(time.RFC3339Nano, unquote(t, s)); err != nil || !parsed.Equal(ts) {
+		t.Errorf("unexpected parsed time.  expected %v, actual %v (%v)", ts, parsed, err)
+	}
+}
+
+func TestFormatDurationLiteral(t *testing.T) {
+	for _, tt := range []struct {
+		d   time.Duration
+		exp string
+	}{
+		{0, "0s"},
+		{90 * time.Minute, "90m"},
+		{2 * time.Hour, "2h"},
+		{14 * 24 * time.Hour, "2w"},
+		{1500 * time.Microsecond, "1500u"},
+		{1500 * time.Nanosecond, "1500ns"},
+		{-time.Second, "-1s"},
+		{math.MinInt64, "-9223372036854775808ns"},
+	} {
+		s := FormatDuration(tt.d)
+		if s != tt.exp {
+			t.Errorf("unexpected duration for %v.  expected %v, actual %v", tt.d, tt.exp, s)
+		}
+		if tt.d <= 0 {
+			continue
+		}
+		if d, err := parseDuration(s); err != nil || d != tt.d {
+			t.Errorf("unexpected parsed duration for %s.  expected %v, actual %v (%v)", s, tt.d, d, err)
+		}
+	}
+}
+
+func FuzzQuoteIdent(f *testing.F) {
+	for _, s := range []string{"", "a", `"`, `\`, "\n", `a\"b`, `\\"`, "x;y", "ü"} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		if got := unquote(t, QuoteIdent(s)); got != s {
+			t.Errorf("unexpected identifier.  expected %q, actual %q", s, got)
+		}
+		q := NewQuery("SHOW TAG KEYS FROM "+QuoteIdent(s), "", "")
+		if n := len(q.Statements()); n != 1 {
+			t.Errorf("unexpected number of statements for %q.  expected %v, actual %v", s, 1, n)
+		}
+	})
+}
@@ -0,0 +1,197 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is matched by the *RateLimitError a write returns when its
+// RateLimit is exhausted and NonBlocking is set.
+var ErrRateLimited = errors.New("write rate limit exceeded")
+
+// RateLimitError is returned by a write that would exceed the RateLimit of a
+// NonBlocking client.
+type RateLimitError struct {
+	// Wait is how long to wait before the same write can be sent.
+	Wait time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%v: retry in %v", ErrRateLimited, e.Wait)
+}
+
+// Is reports whether target is ErrRateLimited.
+func (e *RateLimitError) Is(target error) bool { return target == ErrRateLimited }
+
+// RateLimit is a budget of points and bytes a client may write per second,
+// enforced with a token bucket for each. The budget is taken once for every
+// request or payload sent, before its first attempt, so that retries of a
+// write are not counted again.
+type RateLimit struct {
+	// PointsPerSecond is the number of points written per second. Zero
+	// means the number of points is not limited.
+	PointsPerSecond float64
+
+	// PointBurst is the number of points that can be written at once after
+	// a pause, defaults to PointsPerSecond.
+	PointBurst int
+
+	// BytesPerSecond is the number of bytes written per second, as sent
+	// after compression. Zero means the number of bytes is not limited.
+	BytesPerSecond float64
+
+	// ByteBurst is the number of bytes that can be written at once after a
+	// pause, defaults to BytesPerSecond.
+	ByteBurst int
+
+	// NonBlocking makes a write that exceeds the budget fail with a
+	// *RateLimitError right away, instead of waiting until the budget
+	// allows it.
+	NonBlocking bool
+}
+
+// rateLimiter enforces a RateLimit. A nil *rateLimiter allows every write.
+type rateLimiter struct {
+	nonBlocking bool
+
+	// now and sleep are replaced by tests.
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+
+	mu     sync.Mutex
+	points tokenBucket
+	bytes  tokenBucket
+}
+
+// tokenBucket holds up to burst tokens refilled at rate per second. Tokens
+// go negative when a write larger than the tokens left is let through, which
+// delays the writes after it.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter returns the limiter of conf on clock, or nil if conf is nil.
+// A nil clock is the system clock.
+func newRateLimiter(conf *RateLimit, clock Clock) (*rateLimiter, error) {
+	if conf == nil {
+		return nil, nil
+	}
+	switch {
+	case conf.PointsPerSecond < 0:
+		return nil, &ConfigError{Field: "RateLimit", Reason: fmt.Sprintf("%v points per second is negative", conf.PointsPerSecond)}
+	case conf.BytesPerSecond < 0:
+		return nil, &ConfigError{Field: "RateLimit", Reason: fmt.Sprintf("%v bytes per second is negative", conf.BytesPerSecond)}
+	case conf.PointBurst < 0:
+		return nil, &ConfigError{Field: "RateLimit", Reason: fmt.Sprintf("point burst %d is negative", conf.PointBurst)}
+	case conf.ByteBurst < 0:
+		return nil, &ConfigError{Field: "RateLimit", Reason: fmt.Sprintf("byte burst %d is negative", conf.ByteBurst)}
+	}
+
+	clock = clockOrSystem(clock)
+	l := &rateLimiter{
+		nonBlocking: conf.NonBlocking,
+		now:         clock.Now,
+		sleep: func(ctx context.Context, d time.Duration) error {
+			return sleepClock(ctx, clock, d)
+		},
+	}
+	now := l.now()
+	l.points = newTokenBucket(conf.PointsPerSecond, conf.PointBurst, now)
+	l.bytes = newTokenBucket(conf.BytesPerSecond, conf.ByteBurst, now)
+	return l, nil
+}
+
+func newTokenBucket(rate float64, burst int, now time.Time) tokenBucket {
+	b := tokenBucket{rate: rate, burst: float64(burst), last: now}
+	if burst == 0 {
+		b.burst = rate
+	}
+	b.tokens = b.burst
+	return b
+}
+
+// wait takes points and bytes from the budget, waiting until the budget
+// allows them. It returns a *RateLimitError instead of waiting for a
+// non-blocking limiter, and context.DeadlineExceeded without waiting if the
+// deadline of ctx would pass first. The budget is left untouched when wait
+// fails.
+func (l *rateLimiter) wait(ctx context.Context, points, bytes int) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := l.now()
+	d := l.points.delay(now, float64(points))
+	if bd := l.bytes.delay(now, float64(bytes)); bd > d {
+		d = bd
+	}
+	if d > 0 {
+		if l.nonBlocking {
+			l.mu.Unlock()
+			return &RateLimitError{Wait: d}
+		}
+		if deadline, ok := ctx.Deadline(); ok && deadline.Before(now.Add(d)) {
+			l.mu.Unlock()
+			return context.DeadlineExceeded
+		}
+	}
+	l.points.take(float64(points))
+	l.bytes.take(float64(bytes))
+	l.mu.Unlock()
+
+	if d <= 0 {
+		return nil
+	}
+	if err := l.sleep(ctx, d); err != nil {
+		l.mu.Lock()
+		l.points.take(-float64(points))
+		l.bytes.take(-float64(bytes))
+		l.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// delay refills b up to now and returns how long until n tokens are
+// available.
+func (b *tokenBucket) delay(now time.Time, n float64) time.Duration {
+	if b.rate == 0 {
+		return 0
+	}
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+	}
+	if b.tokens >= n {
+		return 0
+	}
+	return time.Duration((n - b.tokens) / b.rate * float64(time.Second))
+}
+
+func (b *tokenBucket) take(n float64) {
+	if b.rate != 0 {
+		b.tokens -= n
+	}
+}
+
+// sleepContext waits for d, or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
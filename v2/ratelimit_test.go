@@ -0,0 +1,146 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeClock makes l sleep by advancing a clock of its own.
+func fakeClock(l *rateLimiter) *time.Time {
+	now := time.Unix(0, 0)
+	l.now = func() time.Time { return now }
+	l.sleep = func(ctx context.Context, d time.Duration) error {
+		now = now.Add(d)
+		return nil
+	}
+	l.points.last, l.bytes.last = now, now
+	return &now
+}
+
+func TestClient_RateLimit(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	c, err := NewHTTPClient(HTTPConfig{Addr: ts.URL, RateLimit: &RateLimit{PointsPerSecond: 1000, PointBurst: 100}})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	defer c.Close()
+	now := fakeClock(c.(*client).limiter)
+	start := *now
+
+	// A burst of 5000 points flushed 100 at a time.
+	points := newTestPoints(100)
+	for i := 0; i < 50; i++ {
+		bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0"})
+		bp.AddPoints(points)
+		if err := c.Write(bp); err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+	}
+	if elapsed := now.Sub(start); elapsed < 4800*time.Millisecond || elapsed > 5*time.Second {
+		t.Errorf("unexpected duration of the burst.  expected ~5s, actual %v", elapsed)
+	}
+}
+
+func TestClient_RateLimitNonBlocking(t *testing.T) {
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Fail the first attempt, so that the write is retried.
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{
+		Addr:          ts.URL,
+		MaxRetries:    1,
+		RetryInterval: time.Millisecond,
+		RateLimit:     &RateLimit{PointsPerSecond: 100, NonBlocking: true},
+	})
+	defer c.Close()
+	fakeClock(c.(*client).limiter)
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0"})
+	bp.AddPoints(newTestPoints(60))
+	if err := c.Write(bp); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Fatalf("unexpected number of requests.  expected %v, actual %v", 2, n)
+	}
+
+	// The retry did not take from the budget again, so 40 points are left.
+	bp, _ = NewBatchPoints(BatchPointsConfig{Database: "db0"})
+	bp.AddPoints(newTestPoints(40))
+	if err := c.Write(bp); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+
+	err := c.Write(bp)
+	var re *RateLimitError
+	if !errors.As(err, &re) || !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("unexpected error.  expected %T, actual %v", re, err)
+	}
+	if exp := 400 * time.Millisecond; re.Wait != exp {
+		t.Errorf("unexpected wait.  expected %v, actual %v", exp, re.Wait)
+	}
+}
+
+func TestRateLimiter_Bytes(t *testing.T) {
+	l, _ := newRateLimiter(&RateLimit{BytesPerSecond: 1000, ByteBurst: 500}, nil)
+	now := fakeClock(l)
+	start := *now
+
+	for i := 0; i < 4; i++ {
+		if err := l.wait(context.Background(), 1, 500); err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+	}
+	if exp := 1500 * time.Millisecond; now.Sub(start) != exp {
+		t.Errorf("unexpected duration.  expected %v, actual %v", exp, now.Sub(start))
+	}
+}
+
+func TestRateLimiter_Deadline(t *testing.T) {
+	l, _ := newRateLimiter(&RateLimit{PointsPerSecond: 10}, nil)
+	fakeClock(l)
+	ctx, cancel := context.WithDeadline(context.Background(), time.Unix(0, 0).Add(time.Second))
+	defer cancel()
+
+	if err := l.wait(ctx, 10, 0); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if err := l.wait(ctx, 20, 0); err != context.DeadlineExceeded {
+		t.Fatalf("unexpected error.  expected %v, actual %v", context.DeadlineExceeded, err)
+	}
+	// The failed wait left the budget untouched.
+	if err := l.wait(ctx, 5, 0); err != nil {
+		t.Errorf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+}
+
+func TestNewRateLimiter_Invalid(t *testing.T) {
+	for _, conf := range []RateLimit{
+		{PointsPerSecond: -1},
+		{BytesPerSecond: -1},
+		{PointsPerSecond: 1, PointBurst: -1},
+		{BytesPerSecond: 1, ByteBurst: -1},
+	} {
+		_, err := NewHTTPClient(HTTPConfig{Addr: "http://localhost:8086", RateLimit: &conf})
+		var ce *ConfigError
+		if !errors.As(err, &ce) || ce.Field != "RateLimit" {
+			t.Errorf("unexpected error for %+v: %v", conf, err)
+		}
+	}
+}
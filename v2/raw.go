@@ -0,0 +1,426 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+// RawWriter is implemented by the HTTP client. It writes line protocol that
+// is already serialized, without parsing it into Points first.
+type RawWriter interface {
+	// WriteRaw writes the line protocol read from body to the database db
+	// and retention policy rp, with timestamps in the given precision.
+	// The body is compressed and retried like the one of a Write.
+	WriteRaw(ctx context.Context, db, rp, precision string, body io.Reader) error
+}
+
+// StreamWriter is implemented by the HTTP client. It writes line protocol of
+// any size with bounded memory.
+type StreamWriter interface {
+	// WriteStream is like WriteRaw, but sends the body as it is read from r
+	// instead of reading all of it first. The write is not retried, as the
+	// body cannot be sent again.
+	WriteStream(ctx context.Context, db, rp, precision string, r io.Reader) error
+}
+
+// RawBytesWriter is implemented by the TCP and UDP clients. It writes line
+// protocol that is already serialized, without parsing it into Points first.
+type RawBytesWriter interface {
+	// WriteRawBytes sends b split at line boundaries into payloads of at
+	// most the configured PayloadSize. A line longer than that is sent in a
+	// payload of its own.
+	WriteRawBytes(b []byte) error
+}
+
+// LineError is returned for a body of line protocol with an invalid line
+// when raw writes are validated.
+type LineError struct {
+	// Index is the index of the line within the body, counting from 0.
+	Index int
+
+	// Offset is the offset in bytes of the line within the body.
+	Offset int
+
+	// Line is the invalid line, without its newline.
+	Line string
+
+	Err error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("invalid line %d %q: %v", e.Index, e.Line, e.Err)
+}
+
+func (e *LineError) Unwrap() error { return e.Err }
+
+// WriteRaw writes the line protocol read from body.
+func (c *client) WriteRaw(ctx context.Context, db, rp, precision string, body io.Reader) error {
+	bp, err := NewBatchPoints(BatchPointsConfig{Database: db, RetentionPolicy: rp, Precision: precision})
+	if err != nil {
+		return err
+	}
+	if c.v2Write {
+		if _, err := v2Precision(bp.Precision()); err != nil {
+			return err
+		}
+	}
+
+	b, err := ioutil.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	if c.validateRaw {
+		if err := validateLines(b, bp.Precision()); err != nil {
+			return err
+		}
+	}
+
+	var ws WriteStats
+	return c.writeEncoded(ctx, bp, nil, &ws, nil, func(w io.Writer) (int, error) {
+		if _, err := w.Write(terminateLines(b)); err != nil {
+			return 0, err
+		}
+		return countLines(b), nil
+	})
+}
+
+// WriteStream writes the line protocol read from r while reading it.
+func (c *client) WriteStream(ctx context.Context, db, rp, precision string, r io.Reader) error {
+	bp, err := NewBatchPoints(BatchPointsConfig{Database: db, RetentionPolicy: rp, Precision: precision})
+	if err != nil {
+		return err
+	}
+	if c.v2Write {
+		if _, err := v2Precision(bp.Precision()); err != nil {
+			return err
+		}
+	}
+
+	// The counters are only read once the encoder returned.
+	var start = time.Now()
+	var src = &lineCounter{r: r}
+	var sent int
+
+	pr, pw := io.Pipe()
+	encoded := make(chan error, 1)
+	go func() {
+		err := c.encodeStream(writerFunc(func(p []byte) (int, error) {
+			n, err := pw.Write(p)
+			sent += n
+			return n, err
+		}), src)
+		pw.CloseWithError(err)
+		encoded <- err
+	}()
+
+	var ws WriteStats
+	ctx, end := c.startSpan(ctx, SpanWrite)
+	probe, err := c.breaker.allow()
+	if err != nil {
+		if end != nil {
+			end(err, writeSpanAttrs(bp, &ws))
+		}
+		return err
+	}
+	err = c.write(ctx, bp, nil, pr, &ws)
+	c.breaker.done(probe, err)
+	// Unblock the encoder if the request ended before the body was read.
+	pr.CloseWithError(io.ErrClosedPipe)
+	encErr := <-encoded
+
+	var re *retryableError
+	if errors.As(err, &re) {
+		err = re.err
+	}
+	if encErr != nil && encErr != io.ErrClosedPipe {
+		err = errors.Join(encErr, err)
+	}
+	if c.stats != nil {
+		c.stats.WriteDone(src.lines(), sent, time.Since(start), err)
+	}
+	if end != nil {
+		ws.PointCount, ws.ByteCount = src.lines(), sent
+		end(err, writeSpanAttrs(bp, &ws))
+	}
+	return err
+}
+
+// encodeStream copies r to w, compressed as configured.
+func (c *client) encodeStream(w io.Writer, r io.Reader) error {
+	if c.encoding != GzipEncoding {
+		_, err := io.Copy(w, r)
+		return err
+	}
+
+	gw := c.gzipWriters.Get().(*gzip.Writer)
+	defer c.gzipWriters.Put(gw)
+	gw.Reset(w)
+	if _, err := io.Copy(gw, r); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// lineCounter counts the lines read from r.
+type lineCounter struct {
+	r        io.Reader
+	newlines int
+	last     byte
+}
+
+func (r *lineCounter) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.newlines += bytes.Count(p[:n], []byte{'\n'})
+		r.last = p[n-1]
+	}
+	return n, err
+}
+
+// lines returns the number of lines read, including a last one without a
+// newline.
+func (r *lineCounter) lines() int {
+	if r.last != 0 && r.last != '\n' {
+		return r.newlines + 1
+	}
+	return r.newlines
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
+
+// WriteRawBytes sends the line protocol b over the connection.
+func (uc *tcpclient) WriteRawBytes(b []byte) (err error) {
+	if err := uc.drain.begin(); err != nil {
+		return err
+	}
+	defer uc.drain.end()
+	if uc.validateRaw {
+		if err := validateLines(b, ""); err != nil {
+			return err
+		}
+	}
+	var sent int
+	if uc.stats != nil {
+		defer writeDone(uc.stats, countLines(b), time.Now(), &sent, &err)
+	}
+
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+
+	var reconnected bool
+	return writeRawPayloads(b, uc.payloadSize, true, func(p []byte) error {
+		if err := uc.limiter.wait(context.Background(), countLines(p), len(p)); err != nil {
+			return err
+		}
+		sent += len(p)
+		return uc.flush(context.Background(), p, &reconnected)
+	})
+}
+
+// WriteRawBytes sends the line protocol b over the connections of the pool.
+func (p *tcppool) WriteRawBytes(b []byte) (err error) {
+	if err := p.drain.begin(); err != nil {
+		return err
+	}
+	defer p.drain.end()
+	if p.validateRaw {
+		if err := validateLines(b, ""); err != nil {
+			return err
+		}
+	}
+	var sent int
+	if p.stats != nil {
+		defer writeDone(p.stats, countLines(b), time.Now(), &sent, &err)
+	}
+
+	return writeRawPayloads(b, p.payloadSize, true, func(b []byte) error {
+		if err := p.limiter.wait(context.Background(), countLines(b), len(b)); err != nil {
+			return err
+		}
+		sent += len(b)
+		uc := p.checkout()
+		defer uc.mu.Unlock()
+
+		var reconnected bool
+		return uc.flush(context.Background(), b, &reconnected)
+	})
+}
+
+// WriteRawBytes sends the line protocol b in datagrams.
+func (uc *udpclient) WriteRawBytes(b []byte) (err error) {
+	if err := uc.drain.begin(); err != nil {
+		return err
+	}
+	defer uc.drain.end()
+	if uc.validateRaw {
+		if err := validateLines(b, ""); err != nil {
+			return err
+		}
+	}
+	var sent int
+	if uc.stats != nil {
+		defer writeDone(uc.stats, countLines(b), time.Now(), &sent, &err)
+	}
+
+	var prev error
+	err = writeRawPayloads(b, uc.payloadSize, false, func(b []byte) error {
+		if err := uc.limiter.wait(context.Background(), countLines(b), len(b)); err != nil {
+			return err
+		}
+		sent += len(b)
+		return uc.send(context.Background(), b, &prev)
+	})
+	return joinPrevious(prev, err)
+}
+
+// WriteRawBytes sends the line protocol b in datagrams to the addresses of
+// the pool in turn.
+func (p *udppool) WriteRawBytes(b []byte) (err error) {
+	if err := p.drain.begin(); err != nil {
+		return err
+	}
+	defer p.drain.end()
+	if p.validateRaw {
+		if err := validateLines(b, ""); err != nil {
+			return err
+		}
+	}
+	var sent int
+	if p.stats != nil {
+		defer writeDone(p.stats, countLines(b), time.Now(), &sent, &err)
+	}
+
+	var prev error
+	releases := make([]func(error) error, len(p.endpoints))
+	err = writeRawPayloads(b, p.payloadSize, false, func(b []byte) error {
+		if err := p.limiter.wait(context.Background(), countLines(b), len(b)); err != nil {
+			return err
+		}
+		sent += len(b)
+		return p.send(context.Background(), -1, b, &prev, releases)
+	})
+	return joinPrevious(prev, err)
+}
+
+// writeRawPayloads splits b at line boundaries into payloads of at most
+// payloadSize bytes and hands each of them to flush, like writePayloads does
+// for points. With stopOnError set no more payloads are flushed after the
+// first failure.
+func writeRawPayloads(b []byte, payloadSize int, stopOnError bool, flush func([]byte) error) error {
+	b = terminateLines(b)
+
+	var errs []error
+	var written, dropped int
+	for len(b) > 0 {
+		var n int
+		for n < len(b) {
+			end := n + lineEnd(b[n:])
+			if n > 0 && end > payloadSize {
+				break
+			}
+			n = end
+		}
+
+		payload := b[:n]
+		b = b[n:]
+		if err := flush(payload); err != nil {
+			errs = append(errs, err)
+			dropped += countLines(payload)
+			if stopOnError {
+				dropped += countLines(b)
+				break
+			}
+			continue
+		}
+		written += countLines(payload)
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return &WriteError{
+		PointsWritten: written,
+		PointsDropped: dropped,
+		Errs:          errs,
+	}
+}
+
+// validateLines parses every line of b and returns a *LineError for the
+// first one that is not valid line protocol. precision is the precision of
+// the timestamps, as in BatchPointsConfig.
+func validateLines(b []byte, precision string) error {
+	var first *LineError
+	parseLines(b, time.Now(), precision, func(_ models.Point, le *LineError) bool {
+		first = le
+		return le == nil
+	})
+	if first != nil {
+		return first
+	}
+	return nil
+}
+
+// countLines returns the number of points in the line protocol b, skipping
+// blank lines and comments.
+func countLines(b []byte) int {
+	var count int
+	for len(b) > 0 {
+		n := lineEnd(b)
+		if line := bytes.TrimLeft(trimNewline(b[:n]), " \t\r"); len(line) > 0 && line[0] != '#' {
+			count++
+		}
+		b = b[n:]
+	}
+	return count
+}
+
+// lineEnd returns the length of the first line of b including its newline.
+// Like the parser of the models package, it does not end a line at a newline
+// within a quoted string field value.
+func lineEnd(b []byte) int {
+	var quoted, fields bool
+	var equals, commas int
+	for i := 0; i < len(b); i++ {
+		switch c := b[i]; {
+		case c == '\\' && i+2 < len(b):
+			i++
+		case c == ' ':
+			fields = true
+		case fields && !quoted && c == '=':
+			equals++
+		case fields && !quoted && c == ',':
+			commas++
+		case fields && c == '"' && equals > commas:
+			quoted = !quoted
+		case c == '\n' && !quoted:
+			return i + 1
+		}
+	}
+	return len(b)
+}
+
+// terminateLines returns b ending with a newline, copying it if one has to
+// be added.
+func terminateLines(b []byte) []byte {
+	if len(b) == 0 || b[len(b)-1] == '\n' {
+		return b
+	}
+	return append(b[:len(b):len(b)], '\n')
+}
+
+func trimNewline(b []byte) []byte {
+	if len(b) > 0 && b[len(b)-1] == '\n' {
+		return b[:len(b)-1]
+	}
+	return b
+}
@@ -0,0 +1,224 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// payloadConn records every write to it separately.
+type payloadConn struct {
+	payloads []string
+}
+
+func (c *payloadConn) Write(b []byte) (int, error) {
+	c.payloads = append(c.payloads, string(b))
+	return len(b), nil
+}
+
+func (c *payloadConn) Close() error { return nil }
+
+func TestClient_WriteRaw(t *testing.T) {
+	var body, query, encoding string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding = r.Header.Get("Content-Encoding")
+		query = r.URL.RawQuery
+		rd := r.Body
+		if encoding == "gzip" {
+			rd, _ = gzip.NewReader(r.Body)
+		}
+		b, _ := ioutil.ReadAll(rd)
+		body = string(b)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	for _, enc := range []ContentEncoding{DefaultEncoding, GzipEncoding} {
+		c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, WriteEncoding: enc})
+		defer c.Close()
+
+		lines := "cpu value=1 1\ncpu value=2 2"
+		if err := c.(RawWriter).WriteRaw(context.Background(), "db0", "rp0", "s", strings.NewReader(lines)); err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+		if exp := lines + "\n"; body != exp {
+			t.Errorf("unexpected body.  expected %q, actual %q", exp, body)
+		}
+		if encoding != string(enc) {
+			t.Errorf("unexpected encoding.  expected %q, actual %q", enc, encoding)
+		}
+		for _, param := range []string{"db=db0", "rp=rp0", "precision=s"} {
+			if !strings.Contains(query, param) {
+				t.Errorf("expected query %q to contain %q", query, param)
+			}
+		}
+	}
+}
+
+func TestClient_WriteRawValidates(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("unexpected request for an invalid body")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, ValidateRawWrites: true})
+	defer c.Close()
+
+	body := "# comment\ncpu value=1\ncpu value=\ncpu value=3\n"
+	err := c.(RawWriter).WriteRaw(context.Background(), "db0", "", "", strings.NewReader(body))
+
+	var le *LineError
+	if !errors.As(err, &le) {
+		t.Fatalf("unexpected error.  expected %T, actual %v", le, err)
+	}
+	if le.Index != 2 || le.Line != "cpu value=" {
+		t.Errorf("unexpected line error: %+v", le)
+	}
+}
+
+func TestTCPClient_WriteRawBytesSplitsLines(t *testing.T) {
+	conn := &payloadConn{}
+	cl := &tcpclient{conn: conn, payloadSize: 30}
+
+	lines := "cpu value=1 1\ncpu value=2 2\ncpu value=3 3\ncpu,host=a-long-host-name value=4 4"
+	if err := cl.WriteRawBytes([]byte(lines)); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+
+	exp := []string{"cpu value=1 1\ncpu value=2 2\n", "cpu value=3 3\n", "cpu,host=a-long-host-name value=4 4\n"}
+	if strings.Join(conn.payloads, "|") != strings.Join(exp, "|") {
+		t.Errorf("unexpected payloads.  expected %q, actual %q", exp, conn.payloads)
+	}
+}
+
+func TestUDPClient_WriteRawBytesError(t *testing.T) {
+	cl := &udpclient{conn: brokenConn{}, payloadSize: 14}
+
+	err := cl.WriteRawBytes([]byte("cpu value=1 1\ncpu value=2 2\n"))
+	var we *WriteError
+	if !errors.As(err, &we) {
+		t.Fatalf("unexpected error.  expected %T, actual %v", we, err)
+	}
+	if we.PointsDropped != 2 || len(we.Errs) != 2 {
+		t.Errorf("unexpected write error: %+v", we)
+	}
+}
+
+func TestLineEnd(t *testing.T) {
+	for _, tt := range []struct {
+		in  string
+		exp int
+	}{
+		{"cpu value=1\ncpu value=2\n", 12},
+		{"cpu value=1", 11},
+		{"cpu msg=\"a\nb\" 1\ncpu value=2\n", 16},
+		{"cpu\\ x msg=\"a\\\"\nb\"\ncpu value=2\n", 19},
+	} {
+		if got := lineEnd([]byte(tt.in)); got != tt.exp {
+			t.Errorf("lineEnd(%q): expected %v, actual %v", tt.in, tt.exp, got)
+		}
+	}
+}
+
+// lineReader produces n lines of line protocol without holding them in
+// memory.
+type lineReader struct {
+	n   int
+	buf []byte
+	err error
+}
+
+func (r *lineReader) Read(p []byte) (int, error) {
+	if len(r.buf) == 0 {
+		if r.n == 0 {
+			if r.err != nil {
+				return 0, r.err
+			}
+			return 0, io.EOF
+		}
+		r.n--
+		r.buf = []byte("cpu,host=server01 value=1\n")
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func TestClient_WriteStream(t *testing.T) {
+	for _, enc := range []ContentEncoding{DefaultEncoding, GzipEncoding} {
+		var lines int
+		var chunked bool
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			chunked = len(r.TransferEncoding) > 0 && r.TransferEncoding[0] == "chunked"
+			rd := r.Body
+			if r.Header.Get("Content-Encoding") == "gzip" {
+				rd, _ = gzip.NewReader(r.Body)
+			}
+			b, _ := ioutil.ReadAll(rd)
+			lines = bytes.Count(b, []byte("\n"))
+			w.WriteHeader(http.StatusNoContent)
+		}))
+
+		stats := &recordingStats{}
+		c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, WriteEncoding: enc, Stats: stats})
+		if err := c.(StreamWriter).WriteStream(context.Background(), "db0", "", "", &lineReader{n: 100000}); err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+		if lines != 100000 || !chunked {
+			t.Errorf("unexpected request: %d lines, chunked %v", lines, chunked)
+		}
+		if len(stats.writes) != 1 || stats.writes[0].points != 100000 || stats.writes[0].bytes == 0 {
+			t.Errorf("unexpected writes reported: %+v", stats.writes)
+		}
+		c.Close()
+		ts.Close()
+	}
+}
+
+func TestClient_WriteStreamSourceError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	errSource := errors.New("source failed")
+	err := c.(StreamWriter).WriteStream(context.Background(), "db0", "", "", &lineReader{n: 10, err: errSource})
+	if !errors.Is(err, errSource) {
+		t.Errorf("unexpected error.  expected %v, actual %v", errSource, err)
+	}
+}
+
+func TestClient_WriteStreamEarlyResponse(t *testing.T) {
+	ts := newErrorServer(http.StatusBadRequest, "", `{"error":"database not found: \"db0\""}`)
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		// The server answers without reading the body, which never ends.
+		done <- c.(StreamWriter).WriteStream(context.Background(), "db0", "", "", &lineReader{n: -1})
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrDatabaseNotFound) {
+			t.Errorf("unexpected error.  expected %v, actual %v", ErrDatabaseNotFound, err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("WriteStream did not return after the server responded")
+	}
+}
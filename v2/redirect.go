@@ -0,0 +1,73 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// DefaultMaxRedirects is the number of redirects followed for a request when
+// HTTPConfig.MaxRedirects is 0.
+const DefaultMaxRedirects = 10
+
+// RedirectError is returned when a redirect sent by the server is not
+// followed: it leads back to a location already visited, it goes from https
+// to http, the request was redirected too many times, or its body cannot be
+// sent again.
+type RedirectError struct {
+	// URL is the location the server redirected to.
+	URL string
+
+	Reason string
+}
+
+func (e *RedirectError) Error() string {
+	return fmt.Sprintf("not following redirect to %s: %s", e.URL, e.Reason)
+}
+
+// checkRedirect returns the CheckRedirect function of an http.Client that
+// follows at most max redirects, DefaultMaxRedirects if max is 0, and none if
+// max is negative.
+func checkRedirect(max int) func(req *http.Request, via []*http.Request) error {
+	if max == 0 {
+		max = DefaultMaxRedirects
+	}
+	return func(req *http.Request, via []*http.Request) error {
+		if max < 0 {
+			return http.ErrUseLastResponse
+		}
+		location := redactURL(req.URL.String())
+		if len(via) > max {
+			return &RedirectError{URL: location, Reason: fmt.Sprintf("stopped after %d redirects", max)}
+		}
+		for _, prev := range via {
+			if redactURL(prev.URL.String()) == location {
+				return &RedirectError{URL: location, Reason: "redirect loop"}
+			}
+			if prev.URL.Scheme == "https" && req.URL.Scheme == "http" {
+				return &RedirectError{URL: location, Reason: "downgrade from https to http"}
+			}
+		}
+		return nil
+	}
+}
+
+// unfollowedRedirect returns a *RedirectError if resp is a redirect the
+// http.Client did not follow, because following redirects is disabled or the
+// body of the request, as that of a streamed write, could not be sent again.
+func unfollowedRedirect(resp *http.Response) error {
+	switch resp.StatusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		return nil
+	}
+	location, err := resp.Location()
+	if err != nil {
+		return nil
+	}
+	reason := "following redirects is disabled"
+	if req := resp.Request; req != nil && req.GetBody == nil && req.Body != nil && req.Body != http.NoBody {
+		reason = "the request body cannot be sent again"
+	}
+	return &RedirectError{URL: redactURL(location.String()), Reason: reason}
+}
@@ -0,0 +1,198 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// redirectTo returns a server redirecting every request to target with code.
+func redirectTo(target string, code int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target+r.URL.RequestURI(), code)
+	}))
+}
+
+func TestClient_WriteRedirect(t *testing.T) {
+	for _, code := range []int{http.StatusTemporaryRedirect, http.StatusPermanentRedirect} {
+		var body, db, encoding string
+		final := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, _ := ioutil.ReadAll(r.Body)
+			body, db, encoding = string(b), r.URL.Query().Get("db"), r.Header.Get("Content-Encoding")
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		relay := redirectTo(final.URL, code)
+
+		c, _ := NewHTTPClient(HTTPConfig{Addr: relay.URL})
+		bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0"})
+		points := newTestPoints(100)
+		bp.AddPoints(points)
+		if err := c.Write(bp); err != nil {
+			t.Fatalf("unexpected error for status %d.  expected %v, actual %v", code, nil, err)
+		}
+		if lines := strings.Count(body, "\n"); lines != len(points) || db != "db0" || encoding != "" {
+			t.Errorf("unexpected request for status %d.  lines: %v, db: %q, encoding: %q", code, lines, db, encoding)
+		}
+
+		c.Close()
+		relay.Close()
+		final.Close()
+	}
+}
+
+func TestClient_WriteRedirectGzip(t *testing.T) {
+	var encoding string
+	final := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding = r.Header.Get("Content-Encoding")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer final.Close()
+	relay := redirectTo(final.URL, http.StatusTemporaryRedirect)
+	defer relay.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: relay.URL, WriteEncoding: GzipEncoding})
+	defer c.Close()
+	bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0"})
+	bp.AddPoints(newTestPoints(1))
+	if err := c.Write(bp); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if encoding != "gzip" {
+		t.Errorf("unexpected encoding.  expected %q, actual %q", "gzip", encoding)
+	}
+}
+
+func TestClient_QueryRedirect(t *testing.T) {
+	var method, q string
+	final := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, q = r.Method, r.FormValue("q")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(Response{})
+	}))
+	defer final.Close()
+	relay := redirectTo(final.URL, http.StatusTemporaryRedirect)
+	defer relay.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: relay.URL})
+	defer c.Close()
+
+	command := "SELECT value FROM cpu WHERE host = '" + strings.Repeat("x", 8192) + "'"
+	if _, err := c.Query(Query{Command: command}); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if method != "POST" || q != command {
+		t.Errorf("unexpected request.  method: %v, command length: %v", method, len(q))
+	}
+}
+
+func TestClient_RedirectLoop(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Bounce between two paths.
+		target := "/a"
+		if strings.HasPrefix(r.URL.Path, "/a") {
+			target = "/b"
+		}
+		http.Redirect(w, r, ts.URL+target, http.StatusTemporaryRedirect)
+	}))
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, MaxRetries: 2, RetryInterval: time.Millisecond})
+	defer c.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0"})
+	bp.AddPoints(newTestPoints(1))
+	err := c.Write(bp)
+	var re *RedirectError
+	if !errors.As(err, &re) {
+		t.Fatalf("unexpected error.  expected %T, actual %v", re, err)
+	}
+	if re.Reason != "redirect loop" || !strings.HasSuffix(re.URL, "/a") {
+		t.Errorf("unexpected redirect error: %v", re)
+	}
+}
+
+func TestClient_RedirectDowngrade(t *testing.T) {
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("unexpected request over http")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer plain.Close()
+	secure := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, plain.URL+r.URL.RequestURI(), http.StatusTemporaryRedirect)
+	}))
+	defer secure.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: secure.URL, TLSConfig: &tls.Config{InsecureSkipVerify: true}})
+	defer c.Close()
+
+	_, err := c.Query(Query{Command: "SHOW DATABASES"})
+	var re *RedirectError
+	if !errors.As(err, &re) {
+		t.Fatalf("unexpected error.  expected %T, actual %v", re, err)
+	}
+	if exp := "downgrade from https to http"; re.Reason != exp {
+		t.Errorf("unexpected reason.  expected %q, actual %q", exp, re.Reason)
+	}
+}
+
+func TestClient_MaxRedirects(t *testing.T) {
+	var hops int
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hops++
+		http.Redirect(w, r, ts.URL+"/query/"+strings.Repeat("x", hops), http.StatusTemporaryRedirect)
+	}))
+	defer ts.Close()
+
+	for _, tt := range []struct {
+		max, hops int
+		reason    string
+	}{
+		{max: 2, hops: 3, reason: "stopped after 2 redirects"},
+		{max: 0, hops: DefaultMaxRedirects + 1, reason: "stopped after 10 redirects"},
+		{max: -1, hops: 1, reason: "following redirects is disabled"},
+	} {
+		hops = 0
+		c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, MaxRedirects: tt.max})
+		_, err := c.Query(Query{Command: "SHOW DATABASES"})
+		c.Close()
+
+		var re *RedirectError
+		if !errors.As(err, &re) {
+			t.Fatalf("unexpected error for %d redirects.  expected %T, actual %v", tt.max, re, err)
+		}
+		if re.Reason != tt.reason || hops != tt.hops {
+			t.Errorf("unexpected result for %d redirects.  reason: %q, requests: %v", tt.max, re.Reason, hops)
+		}
+	}
+}
+
+func TestClient_WriteStreamRedirect(t *testing.T) {
+	final := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("unexpected request to the final server")
+	}))
+	defer final.Close()
+	relay := redirectTo(final.URL, http.StatusTemporaryRedirect)
+	defer relay.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: relay.URL})
+	defer c.Close()
+
+	err := c.(StreamWriter).WriteStream(context.Background(), "db0", "", "", strings.NewReader("cpu value=1\n"))
+	var re *RedirectError
+	if !errors.As(err, &re) {
+		t.Fatalf("unexpected error.  expected %T, actual %v", re, err)
+	}
+	if exp := "the request body cannot be sent again"; re.Reason != exp {
+		t.Errorf("unexpected reason.  expected %q, actual %q", exp, re.Reason)
+	}
+}
@@ -0,0 +1,305 @@
+package client
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+// DefaultReplayWindow is the default span of the timestamps of the points
+// Replay writes in one batch.
+const DefaultReplayWindow = 100 * time.Millisecond
+
+// ReplayOptions sets how Replay writes a capture.
+type ReplayOptions struct {
+	// Database and RetentionPolicy are written to. Database is required.
+	Database        string
+	RetentionPolicy string
+
+	// Precision is the precision of the timestamps, defaults to "ns".
+	Precision string
+
+	// Speed is the rate of the replay as a multiple of the original one,
+	// such as 10 for ten times faster or 0.5 for half as fast, defaults to
+	// 1.
+	Speed float64
+
+	// AsFastAsPossible writes the batches one after the other without
+	// waiting, in place of Speed.
+	AsFastAsPossible bool
+
+	// Window is the span of the timestamps of a batch: a point that comes
+	// Window or more after the first point of the batch starts the next
+	// one. Defaults to DefaultReplayWindow.
+	Window time.Duration
+
+	// BatchSize is the largest number of points per write, defaults to
+	// DefaultImportBatchSize.
+	BatchSize int
+
+	// ShiftToNow moves the timestamps so that the first point lands at the
+	// start of the replay and the others keep their offset from it, for
+	// the data to be in the present rather than at the time of the
+	// capture.
+	ShiftToNow bool
+
+	// ErrorMode defaults to ImportFailFast, which stops the replay at the
+	// first invalid line or failed write.
+	ErrorMode ImportErrorMode
+
+	// Clock, if set, is the clock the replay is paced with, defaults to
+	// the system clock.
+	Clock Clock
+}
+
+// ReplayReport is the outcome of Replay.
+type ReplayReport struct {
+	// PointsSent is the number of points written, and Batches the number
+	// of writes that succeeded.
+	PointsSent int
+	Batches    int
+
+	// LinesSkipped is the number of invalid lines skipped with
+	// ImportContinueOnError.
+	LinesSkipped int
+
+	// WriteErrors is the number of writes that failed, and PointsFailed
+	// the number of points they held.
+	WriteErrors  int
+	PointsFailed int
+
+	// Duration is how long the replay took.
+	Duration time.Duration
+}
+
+// ReplayError is returned by Replay with ImportContinueOnError when some
+// writes failed. The other batches were written.
+type ReplayError struct {
+	// Batches is the number of batches whose write failed.
+	Batches int
+
+	// Err is the error of the first of them.
+	Err error
+}
+
+func (e *ReplayError) Error() string {
+	return fmt.Sprintf("%d batches failed to replay: %v", e.Batches, e.Err)
+}
+
+func (e *ReplayError) Unwrap() error { return e.Err }
+
+// Replay writes with c the line protocol read from r, decompressed if it is
+// gzipped, at the pace of its timestamps: the batches are written as far
+// apart in time as their first points are, divided by Speed, counting from
+// the first point of the file. A batch later than its time, as after a slow
+// write, is written at once and the ones after it keep to the original
+// timeline. Points out of order are written with the batch being gathered.
+// Comments and blank lines are skipped, and r is read as it is written, so
+// that its size does not matter.
+//
+// With ImportFailFast the error of an invalid line is a *LineError, whose
+// Index and Offset are those of the line in the decompressed file.
+func Replay(ctx context.Context, c Client, r io.Reader, opts ReplayOptions) (ReplayReport, error) {
+	rp, err := newReplayer(ctx, c, opts)
+	if err != nil {
+		return ReplayReport{}, err
+	}
+	err = rp.read(r)
+	rp.report.Duration = rp.clock.Now().Sub(rp.start)
+	switch {
+	case ctx.Err() != nil:
+		return rp.report, ctx.Err()
+	case err != nil:
+		return rp.report, err
+	case rp.first != nil:
+		return rp.report, &ReplayError{Batches: rp.report.WriteErrors, Err: rp.first}
+	}
+	return rp.report, nil
+}
+
+// replayer holds the state of a Replay.
+type replayer struct {
+	ctx   context.Context
+	c     Client
+	opts  ReplayOptions
+	clock Clock
+
+	// start is when the replay started, origin the timestamp of the first
+	// point, and started whether it was read.
+	start   time.Time
+	origin  time.Time
+	started bool
+
+	// batch holds the points gathered since the one at batchTime.
+	batch     BatchPoints
+	batchTime time.Time
+
+	report ReplayReport
+	first  error
+}
+
+func newReplayer(ctx context.Context, c Client, opts ReplayOptions) (*replayer, error) {
+	if opts.Database == "" {
+		return nil, &ConfigError{Field: "Database", Reason: "must be set"}
+	}
+	if opts.Precision == "" {
+		opts.Precision = "ns"
+	}
+	if _, err := time.ParseDuration("1" + opts.Precision); err != nil {
+		return nil, &ConfigError{Field: "Precision", Reason: err.Error()}
+	}
+	switch {
+	case opts.Speed < 0:
+		return nil, &ConfigError{Field: "Speed", Reason: "must not be negative"}
+	case opts.Speed == 0:
+		opts.Speed = 1
+	}
+	if opts.Window < 0 {
+		return nil, &ConfigError{Field: "Window", Reason: "must not be negative"}
+	}
+	if opts.Window == 0 {
+		opts.Window = DefaultReplayWindow
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultImportBatchSize
+	}
+	clock := clockOrSystem(opts.Clock)
+	return &replayer{ctx: ctx, c: c, opts: opts, clock: clock, start: clock.Now()}, nil
+}
+
+// read replays the lines of r, decompressing it if it is gzipped.
+func (rp *replayer) read(r io.Reader) error {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); isGzip(magic) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		br = bufio.NewReader(gz)
+	}
+
+	for index, offset := 0, 0; ; index++ {
+		if rp.ctx.Err() != nil {
+			return nil
+		}
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			if lerr := rp.line(line, index, offset); lerr != nil {
+				return lerr
+			}
+			offset += len(line)
+		}
+		if err == io.EOF {
+			return rp.flush()
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// line gathers the points of the line of the file with the given index and
+// offset, writing the batch it ends.
+func (rp *replayer) line(line []byte, index, offset int) error {
+	var invalid *LineError
+	var err error
+	parseLines(line, rp.start, rp.opts.Precision, func(pt models.Point, le *LineError) bool {
+		if le != nil {
+			le.Index, le.Offset = index, offset
+			invalid = le
+			return false
+		}
+		err = rp.add(pt)
+		if le, ok := err.(*LineError); ok {
+			le.Index, le.Offset, le.Line = index, offset, string(trimNewline(line))
+			invalid, err = le, nil
+		}
+		return err == nil && invalid == nil
+	})
+	if err != nil {
+		return err
+	}
+	if invalid != nil {
+		if rp.opts.ErrorMode == ImportFailFast {
+			return invalid
+		}
+		rp.report.LinesSkipped++
+	}
+	return nil
+}
+
+// add adds pt to the batch, after writing the batch if pt is out of its
+// window or it is full.
+func (rp *replayer) add(pt models.Point) error {
+	t := pt.Time()
+	if !rp.started {
+		rp.origin, rp.started = t, true
+	}
+	if rp.batch != nil && (t.Sub(rp.batchTime) >= rp.opts.Window || len(rp.batch.Points()) >= rp.opts.BatchSize) {
+		if err := rp.flush(); err != nil {
+			return err
+		}
+	}
+	if rp.batch == nil {
+		rp.batch, _ = NewBatchPoints(BatchPointsConfig{
+			Database:        rp.opts.Database,
+			RetentionPolicy: rp.opts.RetentionPolicy,
+			Precision:       rp.opts.Precision,
+		})
+		rp.batchTime = t
+	}
+	if rp.opts.ShiftToNow {
+		pt = models.PointWithTime(pt, t.Add(rp.start.Sub(rp.origin)))
+	}
+	if err := rp.batch.AddPoint(NewPointFrom(pt)); err != nil {
+		// A point over the limits of the batch is an invalid line, whose
+		// position line fills in.
+		return &LineError{Err: err}
+	}
+	return nil
+}
+
+// flush waits for the time of the batch and writes it.
+func (rp *replayer) flush() error {
+	bp := rp.batch
+	if bp == nil {
+		return nil
+	}
+	rp.batch = nil
+	if !rp.opts.AsFastAsPossible {
+		at := rp.start.Add(time.Duration(float64(rp.batchTime.Sub(rp.origin)) / rp.opts.Speed))
+		if d := at.Sub(rp.clock.Now()); d > 0 {
+			if err := sleepClock(rp.ctx, rp.clock, d); err != nil {
+				return nil
+			}
+		}
+	}
+
+	var err error
+	if cc, ok := rp.c.(ContextClient); ok {
+		err = cc.WriteContext(rp.ctx, bp)
+	} else {
+		err = rp.c.Write(bp)
+	}
+	n := len(bp.Points())
+	if err == nil {
+		rp.report.PointsSent += n
+		rp.report.Batches++
+		return nil
+	}
+	rp.report.PointsFailed += n
+	rp.report.WriteErrors++
+	if rp.first == nil {
+		rp.first = err
+	}
+	if rp.opts.ErrorMode == ImportFailFast {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// replayClock is a Clock whose time moves by the delays it is waited for,
+// which it records.
+type replayClock struct {
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (c *replayClock) Now() time.Time { return c.now }
+
+func (c *replayClock) After(d time.Duration) <-chan time.Time {
+	c.sleeps = append(c.sleeps, d)
+	c.now = c.now.Add(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.now
+	return ch
+}
+
+// replayCapture has points at 0s, 1s, 1.05s and 3s, in milliseconds.
+const replayCapture = `# captured
+cpu value=1 1600000000000
+cpu value=2 1600000001000
+
+cpu value=3 1600000001050
+cpu value=4 1600000003000
+`
+
+func TestReplay(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	clock := &replayClock{now: start}
+	rec := &batchRecorder{}
+	report, err := Replay(context.Background(), rec, strings.NewReader(replayCapture), ReplayOptions{
+		Database:   "db",
+		Precision:  "ms",
+		Speed:      2,
+		ShiftToNow: true,
+		Clock:      clock,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+
+	// The points 50ms apart share a batch, and the batches are twice as
+	// close as in the capture.
+	if exp, got := []int{1, 2, 1}, rec.sizes(); !reflect.DeepEqual(exp, got) {
+		t.Errorf("unexpected batches.  expected %v, actual %v", exp, got)
+	}
+	if exp := []time.Duration{500 * time.Millisecond, time.Second}; !reflect.DeepEqual(exp, clock.sleeps) {
+		t.Errorf("unexpected sleeps.  expected %v, actual %v", exp, clock.sleeps)
+	}
+	exp := ReplayReport{PointsSent: 4, Batches: 3, Duration: 1500 * time.Millisecond}
+	if report != exp {
+		t.Errorf("unexpected report.  expected %+v, actual %+v", exp, report)
+	}
+
+	// The timestamps were moved to the start of the replay.
+	var times []int64
+	for _, b := range rec.batches {
+		for _, p := range b {
+			times = append(times, p.Time().Sub(start).Milliseconds())
+		}
+	}
+	if exp := []int64{0, 1000, 1050, 3000}; !reflect.DeepEqual(exp, times) {
+		t.Errorf("unexpected offsets of the times in ms.  expected %v, actual %v", exp, times)
+	}
+}
+
+func TestReplay_AsFastAsPossible(t *testing.T) {
+	clock := &replayClock{now: time.Unix(1700000000, 0)}
+	rec := &batchRecorder{}
+	_, err := Replay(context.Background(), rec, strings.NewReader(replayCapture), ReplayOptions{
+		Database:         "db",
+		Precision:        "ms",
+		AsFastAsPossible: true,
+		BatchSize:        1,
+		Clock:            clock,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if exp, got := []int{1, 1, 1, 1}, rec.sizes(); !reflect.DeepEqual(exp, got) {
+		t.Errorf("unexpected batches.  expected %v, actual %v", exp, got)
+	}
+	if len(clock.sleeps) != 0 {
+		t.Errorf("unexpected sleeps.  expected none, actual %v", clock.sleeps)
+	}
+	if got := rec.batches[0][0].Time(); !got.Equal(time.Unix(1600000000, 0)) {
+		t.Errorf("unexpected time.  expected %v, actual %v", time.Unix(1600000000, 0), got)
+	}
+}
+
+func TestReplay_Errors(t *testing.T) {
+	capture := replayCapture + "not line protocol\n"
+	boom := errors.New("boom")
+
+	// Fail fast stops at the first failed write.
+	rec := &batchRecorder{err: boom}
+	report, err := Replay(context.Background(), rec, strings.NewReader(capture), ReplayOptions{
+		Database: "db", Precision: "ms", AsFastAsPossible: true,
+	})
+	if err != boom || report.WriteErrors != 1 || report.PointsFailed != 1 || len(rec.sizes()) != 1 {
+		t.Errorf("unexpected result.  expected %v after one write, actual %v, %+v", boom, err, report)
+	}
+
+	// Continuing counts the failed writes and the invalid lines.
+	rec = &batchRecorder{err: boom}
+	report, err = Replay(context.Background(), rec, strings.NewReader(capture), ReplayOptions{
+		Database: "db", Precision: "ms", AsFastAsPossible: true, ErrorMode: ImportContinueOnError,
+	})
+	var re *ReplayError
+	if !errors.As(err, &re) || re.Batches != 3 || !errors.Is(err, boom) {
+		t.Errorf("unexpected error.  expected %T, actual %v", re, err)
+	}
+	if report.WriteErrors != 3 || report.PointsFailed != 4 || report.LinesSkipped != 1 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+
+	// An invalid line fails fast.
+	_, err = Replay(context.Background(), &batchRecorder{}, strings.NewReader(capture), ReplayOptions{
+		Database: "db", Precision: "ms", AsFastAsPossible: true,
+	})
+	var le *LineError
+	if !errors.As(err, &le) || le.Index != 6 {
+		t.Errorf("unexpected error.  expected %T at line %v, actual %v", le, 6, err)
+	}
+
+	if _, err := Replay(context.Background(), &batchRecorder{}, strings.NewReader(capture), ReplayOptions{}); err == nil {
+		t.Error("expected an error without a database")
+	}
+
+	// A point over the limits of the batch is an invalid line.
+	capture = replayCapture + "cpu value=\"" + strings.Repeat("x", 70000) + "\" 1600000003000\ncpu value=5 1600000003000\n"
+	_, err = Replay(context.Background(), &batchRecorder{}, strings.NewReader(capture), ReplayOptions{
+		Database: "db", Precision: "ms", AsFastAsPossible: true,
+	})
+	var lerr *LimitError
+	if !errors.As(err, &le) || le.Index != 6 || !errors.As(err, &lerr) {
+		t.Errorf("unexpected error.  expected %T over a limit at line %v, actual %v", le, 6, err)
+	}
+	rec = &batchRecorder{}
+	report, err = Replay(context.Background(), rec, strings.NewReader(capture), ReplayOptions{
+		Database: "db", Precision: "ms", AsFastAsPossible: true, ErrorMode: ImportContinueOnError,
+	})
+	if err != nil || report.LinesSkipped != 1 || report.PointsSent != 5 {
+		t.Errorf("unexpected result: %v, %+v", err, report)
+	}
+}
@@ -0,0 +1,102 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+// DecodeOptions are the options of DecodeResponse, matching those of the
+// HTTPConfig and Query a response decoded by the HTTP client is for.
+type DecodeOptions struct {
+	// StrictResponses rejects a response without results nor error, and
+	// reports the keys that Response does not hold to Logger, as
+	// HTTPConfig.StrictResponses does.
+	StrictResponses bool
+
+	// Logger, if set, is told about the keys reported by StrictResponses.
+	// Nothing is logged by default.
+	Logger Logger
+
+	// NumberDecoding selects how the numeric values of the results are
+	// decoded. Defaults to JSONNumberDecoding.
+	NumberDecoding NumberDecoding
+
+	// Epoch is the epoch, or precision, of the query the response is for,
+	// used by Scan and TimeSeries to decode its times.
+	Epoch string
+
+	// Command is the command of the query the response is for, used by
+	// ResultFor and Errors to name its statements.
+	Command string
+}
+
+// DecodeResponse decodes a JSON query response read from r, as the HTTP
+// client decodes the response of a query that is not chunked. It lets
+// responses reaching the program by other transports, such as a cache, be
+// used like those of Query. Header is nil for the response returned.
+func DecodeResponse(r io.Reader, opts DecodeOptions) (*Response, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	var strict *strictDecoder
+	if opts.StrictResponses {
+		strict = newStrictDecoder(opts.Logger)
+	}
+	var resp Response
+	decErr, err := decodeJSONResponse(dec, &resp, strict)
+	if err != nil {
+		return nil, err
+	}
+	if decErr != nil {
+		return nil, fmt.Errorf("unable to decode json: %w", decErr)
+	}
+	convertNumbers(resp.Results, opts.NumberDecoding)
+	resp.precision = responsePrecision(opts.Epoch)
+	resp.command = opts.Command
+	return &resp, nil
+}
+
+// decodeJSONResponse decodes the next response of dec, which uses
+// json.Number, into resp, checked by strict if set. The error decoding the
+// JSON is returned as decErr, and that of a response strict rejects as err.
+func decodeJSONResponse(dec *json.Decoder, resp *Response, strict *strictDecoder) (decErr, err error) {
+	if strict == nil {
+		return dec.Decode(resp), nil
+	}
+	var raw json.RawMessage
+	if decErr = dec.Decode(&raw); decErr != nil {
+		return decErr, nil
+	}
+	return nil, strict.decode(raw, resp)
+}
+
+// MarshalJSON encodes the response as the server does, so that a response
+// decoded by DecodeResponse, or by the HTTP client, encodes back to the JSON
+// it was decoded from. The numbers of its values are encoded as they were
+// received if they are left as json.Number.
+func (r *Response) MarshalJSON() ([]byte, error) {
+	type result struct {
+		StatementId int          `json:"statement_id"`
+		Series      []models.Row `json:"series,omitempty"`
+		Messages    []*Message   `json:"messages,omitempty"`
+		Partial     bool         `json:"partial,omitempty"`
+		Err         string       `json:"error,omitempty"`
+	}
+	var jr struct {
+		Results []result `json:"results,omitempty"`
+		Err     string   `json:"error,omitempty"`
+	}
+	for _, res := range r.Results {
+		jr.Results = append(jr.Results, result{
+			StatementId: res.StatementId,
+			Series:      res.Series,
+			Messages:    res.Messages,
+			Partial:     res.Partial,
+			Err:         res.Err,
+		})
+	}
+	jr.Err = r.Err
+	return json.Marshal(jr)
+}
@@ -0,0 +1,107 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serverResponse is a response as the server encodes it, of several results
+// and series.
+const serverResponse = `{"results":[` +
+	`{"statement_id":0,"series":[` +
+	`{"name":"cpu","tags":{"host":"a","region":"us-west"},"columns":["time","value","s","ok"],"values":[["2020-01-01T00:00:00Z",1.5,"x",true],["2020-01-01T00:00:01Z",12345678901234567890,null,false]]},` +
+	`{"name":"cpu","tags":{"host":"b"},"columns":["time","value"],"values":[["2020-01-01T00:00:00Z",1e+21]],"partial":true}],` +
+	`"messages":[{"level":"warning","text":"deprecated \"syntax\""}],"partial":true},` +
+	`{"statement_id":0,"series":[{"name":"cpu","tags":{"host":"b"},"columns":["time","value"],"values":[["2020-01-01T00:00:01Z",-0.25]]}]},` +
+	`{"statement_id":1,"series":[{"name":"databases","columns":["name"],"values":[["_internal"],["db0"]]}]},` +
+	`{"statement_id":2,"error":"database not found: db1"},` +
+	`{"statement_id":3}]}`
+
+func TestDecodeResponse(t *testing.T) {
+	resp, err := DecodeResponse(strings.NewReader(serverResponse), DecodeOptions{Command: "SELECT * FROM cpu; SHOW DATABASES; SELECT * FROM db1..cpu; SELECT 1"})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if len(resp.Results) != 5 || len(resp.Results[0].Series) != 2 || resp.Results[0].Messages[0].Level != "warning" {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	if v := resp.Results[0].Series[0].Values[1][1]; v != json.Number("12345678901234567890") {
+		t.Errorf("unexpected value.  expected %v, actual %#v", "12345678901234567890", v)
+	}
+	var se *StatementError
+	if _, err := resp.ResultFor(2); !errors.As(err, &se) || se.Statement != "SELECT * FROM db1..cpu" {
+		t.Errorf("unexpected error.  expected the error of the statement, actual %v", err)
+	}
+
+	b, err := json.Marshal(resp)
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if string(b) != serverResponse {
+		t.Errorf("unexpected encoding.\nexpected %s\nactual   %s", serverResponse, b)
+	}
+	if b, _ := json.Marshal(&Response{Err: "error parsing query"}); string(b) != `{"error":"error parsing query"}` {
+		t.Errorf("unexpected encoding of an error: %s", b)
+	}
+}
+
+func TestDecodeResponse_Options(t *testing.T) {
+	body := `{"results":[{"statement_id":0,"series":[{"name":"cpu","columns":["time","value"],"values":[[1000,2],[2000,2.5]]}]}],"extra":1}`
+	logger := newBufferLogger()
+	resp, err := DecodeResponse(strings.NewReader(body), DecodeOptions{
+		StrictResponses: true,
+		Logger:          logger,
+		NumberDecoding:  Int64Decoding,
+		Epoch:           "ms",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if !strings.Contains(logger.String(), "unknown keys of query response: extra") {
+		t.Errorf("unexpected logs.  expected a report of the unknown key, actual %q", logger.String())
+	}
+	values := resp.Results[0].Series[0].Values
+	if values[0][1] != int64(2) || values[1][1] != 2.5 {
+		t.Errorf("unexpected values: %#v", values)
+	}
+	var rows []struct {
+		Time  time.Time `influx:"time"`
+		Value float64   `influx:"value"`
+	}
+	if err := resp.Scan(&rows); err != nil || len(rows) != 2 || !rows[1].Time.Equal(time.Unix(2, 0)) || rows[1].Value != 2.5 {
+		t.Errorf("unexpected rows: %+v, %v", rows, err)
+	}
+
+	if _, err := DecodeResponse(strings.NewReader(`{}`), DecodeOptions{StrictResponses: true}); err == nil || !strings.Contains(err.Error(), "expected results or an error") {
+		t.Errorf("unexpected error.  expected the response to be rejected, actual %v", err)
+	}
+	if _, err := DecodeResponse(strings.NewReader(`{"results":`), DecodeOptions{}); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("unexpected error.  expected a decoding error, actual %v", err)
+	}
+}
+
+func TestClient_QueryMarshalJSON(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(serverResponse + "\n"))
+	}))
+	defer ts.Close()
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	for _, chunked := range []bool{false, true} {
+		resp, _ := c.Query(Query{Command: "SELECT * FROM cpu", Chunked: chunked})
+		if resp == nil {
+			t.Fatalf("unexpected response.  expected a response, actual %v", resp)
+		}
+		if b, err := json.Marshal(resp); err != nil || string(b) != serverResponse {
+			t.Errorf("unexpected encoding of a chunked=%v response.\nexpected %s\nactual   %s, %v", chunked, serverResponse, b, err)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+)
+
+// ErrResponseTooLarge is matched by a *ResponseTooLargeError with errors.Is.
+var ErrResponseTooLarge = errors.New("response is too large")
+
+// ResponseTooLargeError is returned when the response of a query is larger
+// than its MaxResponseBytes. Reading the response was aborted and its
+// connection closed once the limit was exceeded.
+type ResponseTooLargeError struct {
+	// Read is the number of bytes read before the response was aborted,
+	// and Limit the MaxResponseBytes of the query.
+	Read  int64
+	Limit int64
+}
+
+func (e *ResponseTooLargeError) Error() string {
+	return fmt.Sprintf("response too large: read %d bytes, more than the maximum of %d", e.Read, e.Limit)
+}
+
+func (e *ResponseTooLargeError) Is(target error) bool { return target == ErrResponseTooLarge }
+
+// sizeReader is the body of a response counting the bytes read from it. If
+// limit is positive, the read going past it closes the body, so that the
+// server stops sending, and fails with a *ResponseTooLargeError, as do the
+// reads that follow. A read is never given more than one byte past the
+// limit, so no more than that is buffered by the decoder reading the body.
+type sizeReader struct {
+	io.ReadCloser
+	limit int64
+
+	// n is the number of bytes read, read by BytesRead while the body is
+	// being read.
+	n   int64
+	err error
+}
+
+// newSizeReader returns body counting its bytes and limited to limit, if
+// positive.
+func newSizeReader(body io.ReadCloser, limit int64) *sizeReader {
+	return &sizeReader{ReadCloser: body, limit: limit}
+}
+
+func (r *sizeReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	if r.limit > 0 {
+		if max := r.limit - r.n + 1; int64(len(p)) > max {
+			p = p[:max]
+		}
+	}
+	n, err := r.ReadCloser.Read(p)
+	read := atomic.AddInt64(&r.n, int64(n))
+	if r.limit > 0 && read > r.limit {
+		r.err = &ResponseTooLargeError{Read: read, Limit: r.limit}
+		r.ReadCloser.Close()
+		return n - int(read-r.limit), r.err
+	}
+	return n, err
+}
+
+// bytesRead returns the number of bytes read so far.
+func (r *sizeReader) bytesRead() int64 {
+	return atomic.LoadInt64(&r.n)
+}
+
+// BytesRead returns the number of bytes of the stream read so far, after
+// the response was decompressed. It may be called while NextResponse reads,
+// as to abort a response growing too fast with Close; Query.MaxResponseBytes
+// bounds it.
+func (r *ChunkedResponse) BytesRead() int64 {
+	if r.size == nil {
+		return 0
+	}
+	return r.size.bytesRead()
+}
@@ -0,0 +1,159 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+// floodSize is the size of the responses of a flood server, much more than
+// the limits of the tests.
+const floodSize = 256 << 20
+
+// newFloodServer returns a server answering with floodSize bytes of JSON, as
+// chunks if chunked or as a single result with ever more rows, and counting
+// the bytes it sends in sent. gone is closed once it stops sending.
+func newFloodServer(chunked bool) (ts *httptest.Server, sent *int64, gone chan struct{}) {
+	sent = new(int64)
+	gone = make(chan struct{})
+	var piece []byte
+	if chunked {
+		chunk, _ := json.Marshal(Response{Results: []Result{{Series: []models.Row{{Name: "cpu", Columns: []string{"time", "value"}, Values: [][]interface{}{{1, 1}}}}, Partial: true}}})
+		piece = bytes.Repeat(append(chunk, '\n'), 64<<10/(len(chunk)+1))
+	} else {
+		piece = []byte(strings.Repeat("[1,1],", 64<<10/6))
+	}
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(gone)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if !chunked {
+			n, _ := w.Write([]byte(`{"results":[{"statement_id":0,"series":[{"name":"cpu","columns":["time","value"],"values":[`))
+			atomic.AddInt64(sent, int64(n))
+		}
+		for atomic.LoadInt64(sent) < floodSize {
+			n, err := w.Write(piece)
+			atomic.AddInt64(sent, int64(n))
+			if err != nil {
+				return
+			}
+		}
+	}))
+	return ts, sent, gone
+}
+
+// checkAborted checks that err is the error of a response aborted past
+// limit, and that the server stopped sending soon after.
+func checkAborted(t *testing.T, err error, limit int64, sent *int64, gone chan struct{}) {
+	t.Helper()
+	var te *ResponseTooLargeError
+	if !errors.As(err, &te) || !errors.Is(err, ErrResponseTooLarge) {
+		t.Fatalf("unexpected error.  expected a *ResponseTooLargeError, actual %v", err)
+	}
+	if te.Limit != limit || te.Read != limit+1 {
+		t.Errorf("unexpected error.  expected %d bytes read of %d, actual %d of %d", limit+1, limit, te.Read, te.Limit)
+	}
+	select {
+	case <-gone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the connection to be closed")
+	}
+	// What the server sent past the limit is what the socket buffers held.
+	if n := atomic.LoadInt64(sent); n >= floodSize/4 {
+		t.Errorf("unexpected bytes sent by the server: %d, expected far less than %d", n, floodSize)
+	}
+}
+
+func TestQuery_MaxResponseBytes(t *testing.T) {
+	for _, chunked := range []bool{false, true} {
+		ts, sent, gone := newFloodServer(chunked)
+		c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+
+		const limit = 1 << 20
+		resp, err := c.Query(Query{Command: "SELECT * FROM cpu", Chunked: chunked, MaxResponseBytes: limit})
+		if resp != nil {
+			t.Errorf("chunked %v: unexpected response.  expected %v, actual %v", chunked, nil, resp)
+		}
+		checkAborted(t, err, limit, sent, gone)
+		c.Close()
+		ts.Close()
+	}
+}
+
+func TestQueryAsChunk_MaxResponseBytes(t *testing.T) {
+	ts, sent, gone := newFloodServer(true)
+	defer ts.Close()
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	const limit = 1 << 20
+	cr, err := c.QueryAsChunk(Query{Command: "SELECT * FROM cpu", MaxResponseBytes: limit})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	defer cr.Close()
+	var chunks int
+	for {
+		if _, err = cr.NextResponse(); err != nil {
+			break
+		}
+		chunks++
+		// The decoder reads ahead of the chunks it returns, up to the
+		// byte past the limit.
+		if n := cr.BytesRead(); n > limit+1 {
+			t.Fatalf("unexpected bytes read after %d chunks: %d, more than %d", chunks, n, limit+1)
+		}
+	}
+	if chunks == 0 {
+		t.Error("expected chunks before the limit")
+	}
+	checkAborted(t, err, limit, sent, gone)
+	if n := cr.BytesRead(); n != limit+1 {
+		t.Errorf("unexpected bytes read.  expected %v, actual %v", limit+1, n)
+	}
+	if _, err := cr.NextResponse(); !errors.Is(err, ErrResponseTooLarge) {
+		t.Errorf("unexpected error of the next read.  expected %v, actual %v", ErrResponseTooLarge, err)
+	}
+}
+
+func TestChunkedResponse_BytesRead(t *testing.T) {
+	const body = `{"results":[{"statement_id":0}]}` + "\n" + `{"results":[{"statement_id":1}]}` + "\n"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	defer ts.Close()
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	// A response of the size of the limit is read in full.
+	cr, err := c.QueryAsChunk(Query{Command: "SELECT * FROM cpu", MaxResponseBytes: int64(len(body))})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	defer cr.Close()
+	for i := 0; i < 2; i++ {
+		if _, err := cr.NextResponse(); err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+	}
+	if _, err := cr.NextResponse(); err != io.EOF {
+		t.Errorf("unexpected error.  expected %v, actual %v", io.EOF, err)
+	}
+	if n := cr.BytesRead(); n != int64(len(body)) {
+		t.Errorf("unexpected bytes read.  expected %v, actual %v", len(body), n)
+	}
+
+	if n := NewChunkedResponse(strings.NewReader(body)).BytesRead(); n != 0 {
+		t.Errorf("unexpected bytes read before reading.  expected %v, actual %v", 0, n)
+	}
+}
@@ -0,0 +1,99 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// chunkStart is how a chunk of a JSON chunked response starts, at the start
+// of a line.
+var chunkStart = []byte(`{"results"`)
+
+// ChunkDecodeError is a part of a chunked response that could not be decoded
+// and was skipped, with Query.ResyncOnDecodeError.
+type ChunkDecodeError struct {
+	// Offset is the position of the part in the body of the response, and
+	// Length its size, in bytes.
+	Offset int64
+	Length int64
+
+	// Err is the error decoding the part failed with.
+	Err error
+}
+
+func (e ChunkDecodeError) Error() string {
+	return fmt.Sprintf("skipped %d bytes of chunked response at offset %d: %v", e.Length, e.Offset, e.Err)
+}
+
+// DecodeErrors returns the parts of the response skipped so far as they could
+// not be decoded, with Query.ResyncOnDecodeError.
+func (r *ChunkedResponse) DecodeErrors() []ChunkDecodeError {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]ChunkDecodeError(nil), r.decodeErrors...)
+}
+
+// resync skips the part of the response the decoder failed on with decErr,
+// up to the next line that starts a chunk, and resumes decoding there. It
+// returns io.EOF if no chunk follows.
+func (r *ChunkedResponse) resync(decErr error) error {
+	start := r.offset + r.dec.InputOffset()
+	src := r.src
+	if src == nil {
+		src = r.duplex
+	}
+	// The decoder did not consume the value it failed on.
+	br := bufio.NewReader(io.MultiReader(r.dec.Buffered(), src))
+	for {
+		b, err := br.Peek(1)
+		if err != nil || !isJSONSpace(b[0]) {
+			break
+		}
+		br.Discard(1)
+		start++
+	}
+
+	var length int64
+	var err error
+	for {
+		var line []byte
+		line, err = br.ReadSlice('\n')
+		length += int64(len(line))
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			break
+		}
+		if next, _ := br.Peek(len(chunkStart)); bytes.Equal(next, chunkStart) {
+			break
+		}
+	}
+	if err != nil && err != io.EOF {
+		// Reading failed, as when the request was canceled.
+		return err
+	}
+
+	e := ChunkDecodeError{Offset: start, Length: length, Err: decErr}
+	logf(r.logger, "influxdb: %v", e)
+	r.mu.Lock()
+	r.decodeErrors = append(r.decodeErrors, e)
+	r.mu.Unlock()
+	if err == io.EOF {
+		return io.EOF
+	}
+
+	r.offset = start + length
+	r.src = br
+	r.dec = json.NewDecoder(br)
+	r.dec.UseNumber()
+	r.buf.Reset()
+	return nil
+}
+
+func isJSONSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r'
+}
@@ -0,0 +1,73 @@
+package client
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestChunkedResponse_ResyncOnDecodeError(t *testing.T) {
+	chunk := func(name string) string {
+		return `{"results":[{"statement_id":0,"series":[{"name":"` + name + `","columns":["time","value"],"values":[[1,1]]}],"partial":true}]}` + "\n"
+	}
+	garbage := []string{
+		": keep-alive\n",
+		"<html><body>502 Bad Gateway</body></html>\n",
+		`{"results":[{"statement_id":0,"ser` + "\n",
+		"oops",
+	}
+	body := chunk("a") + garbage[0] + chunk("b") + garbage[1] + garbage[2] + chunk("c") + garbage[3]
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, body)
+	}))
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	cr, err := c.QueryAsChunk(Query{Command: "SELECT * FROM m", ResyncOnDecodeError: true})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	defer cr.Close()
+	var names []string
+	for {
+		resp, err := cr.NextResponse()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+		names = append(names, resp.Results[0].Series[0].Name)
+	}
+	if exp := "a b c"; strings.Join(names, " ") != exp {
+		t.Errorf("unexpected chunks.  expected %v, actual %v", exp, names)
+	}
+
+	errs := cr.DecodeErrors()
+	var exp []ChunkDecodeError
+	for _, g := range garbage {
+		exp = append(exp, ChunkDecodeError{Offset: int64(strings.LastIndex(body, g)), Length: int64(len(g))})
+	}
+	if len(errs) != len(exp) {
+		t.Fatalf("unexpected decode errors.  expected %d, actual %v", len(exp), errs)
+	}
+	for i := range exp {
+		if errs[i].Offset != exp[i].Offset || errs[i].Length != exp[i].Length || errs[i].Err == nil {
+			t.Errorf("unexpected decode error %d.  expected %d bytes at %d, actual %v", i, exp[i].Length, exp[i].Offset, errs[i])
+		}
+	}
+
+	// By default the response fails at the first garbage.
+	cr, _ = c.QueryAsChunk(Query{Command: "SELECT * FROM m"})
+	defer cr.Close()
+	if _, err := cr.NextResponse(); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if _, err := cr.NextResponse(); err == nil || err == io.EOF || len(cr.DecodeErrors()) != 0 {
+		t.Errorf("unexpected error.  expected a decode error, actual %v", err)
+	}
+}
@@ -0,0 +1,191 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// DefaultRetryInterval is the delay before the first retry of a write.
+	DefaultRetryInterval = 100 * time.Millisecond
+
+	// DefaultMaxRetryInterval is the upper bound of the delay between retries.
+	DefaultMaxRetryInterval = 10 * time.Second
+)
+
+// RetryError is returned when a write still failed after being retried.
+type RetryError struct {
+	// Attempts is the number of requests that were made.
+	Attempts int
+
+	// Err is the error of the last attempt.
+	Err error
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("write failed after %d attempts: %v", e.Attempts, e.Err)
+}
+
+func (e *RetryError) Unwrap() error { return e.Err }
+
+// RetryStatsCollector is implemented by a StatsCollector that is told about
+// the writes the HTTP client retries.
+type RetryStatsCollector interface {
+	// WriteRetried is called before a write of points is sent again, with
+	// the error of the attempt that failed.
+	WriteRetried(points int, err error)
+}
+
+// retryableError marks a failure that may succeed when the request is repeated.
+type retryableError struct {
+	err error
+
+	// retryAfter is the delay requested by the server, if any.
+	retryAfter time.Duration
+}
+
+func (e *retryableError) Error() string { return e.err.Error() }
+
+func (e *retryableError) Unwrap() error { return e.err }
+
+// IsRetryable reports whether the write that failed with err may succeed if
+// it is made again, as when the server is overloaded or unavailable, a
+// cluster did not meet the write consistency or the connection failed. The
+// writes rejected for their points, such as with a *PartialWriteError, or
+// for their credentials are not, and neither are those of a closed client or
+// a done context.
+func IsRetryable(err error) bool {
+	var (
+		re *retryableError
+		pe *PartialWriteError
+		ce *ConsistencyError
+		he *HintedHandoffError
+		te *ThrottledError
+		er *ErrorResponse
+		ne net.Error
+	)
+	switch {
+	case err == nil:
+		return false
+	case errors.As(err, &re):
+		return true
+	case errors.As(err, &pe):
+		// Retrying would write the points that were stored again.
+		return false
+	case errors.As(err, &ce), errors.As(err, &he), errors.As(err, &te):
+		return true
+	case errors.As(err, &er):
+		return er.StatusCode == http.StatusTooManyRequests || er.StatusCode >= 500
+	case errors.Is(err, ErrClientClosed), errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return false
+	case errors.As(err, &ne):
+		return true
+	}
+	return false
+}
+
+// retry calls fn until it succeeds, fails with a permanent error, ctx is done
+// or the client's retries are exhausted.
+func (c *client) retry(ctx context.Context, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		re, ok := err.(*retryableError)
+		if !ok {
+			if err != nil && attempt > 1 {
+				return &RetryError{Attempts: attempt, Err: err}
+			}
+			return err
+		}
+		if attempt > c.maxRetries {
+			if attempt > 1 {
+				return &RetryError{Attempts: attempt, Err: re.err}
+			}
+			return re.err
+		}
+
+		wait := re.retryAfter
+		if wait <= 0 {
+			wait = c.backoff(attempt)
+		} else if wait > c.maxRetryInterval {
+			// A proxy can ask for hours.
+			wait = c.maxRetryInterval
+		}
+		logf(c.logger, "influxdb: write attempt %d failed, retrying in %v: %v", attempt, wait, re.err)
+
+		if err := sleepClock(ctx, c.clock, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// backoff returns the jittered delay before the given retry attempt.
+func (c *client) backoff(attempt int) time.Duration {
+	d := c.retryInterval
+	for i := 1; i < attempt && d < c.maxRetryInterval; i++ {
+		d *= 2
+	}
+	if d > c.maxRetryInterval {
+		d = c.maxRetryInterval
+	}
+	// Pick a delay in [d/2, d) so concurrent writers do not retry in lockstep.
+	half := d / 2
+	if half <= 0 {
+		return d
+	}
+	return half + time.Duration(rand.Int63n(int64(half)))
+}
+
+// parseRetryAfter parses a Retry-After header given either in seconds or as
+// an HTTP date, measured from now. It returns 0 if the header is absent or
+// invalid.
+func parseRetryAfter(s string, now time.Time) time.Duration {
+	if s == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(s); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(s); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// parseRateLimitRemaining parses an X-RateLimit-Remaining header. It returns
+// -1 if the header is absent or invalid.
+func parseRateLimitRemaining(s string) int {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return -1
+	}
+	return n
+}
+
+// unixResetThreshold tells the two forms of X-RateLimit-Reset apart: larger
+// values are Unix times, smaller ones delays in seconds.
+const unixResetThreshold = 1e9
+
+// parseRateLimitReset parses an X-RateLimit-Reset header given either in
+// Unix seconds or in seconds from now. It returns the zero time if the header
+// is absent or invalid.
+func parseRateLimitReset(s string, now time.Time) time.Time {
+	n, err := strconv.ParseInt(s, 10, 64)
+	switch {
+	case err != nil || n < 0:
+		return time.Time{}
+	case n >= unixResetThreshold:
+		return time.Unix(n, 0)
+	}
+	return now.Add(time.Duration(n) * time.Second)
+}
@@ -0,0 +1,332 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newRetryTestServer(t *testing.T, codes ...int) (*httptest.Server, *int32) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(&calls, 1))
+		code := http.StatusNoContent
+		if n <= len(codes) {
+			code = codes[n-1]
+		}
+		w.WriteHeader(code)
+		if code != http.StatusNoContent {
+			w.Write([]byte(http.StatusText(code)))
+		}
+	}))
+	return ts, &calls
+}
+
+func TestClient_WriteRetry(t *testing.T) {
+	ts, calls := newRetryTestServer(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	defer ts.Close()
+
+	c, err := NewHTTPClient(HTTPConfig{Addr: ts.URL, MaxRetries: 3, RetryInterval: time.Millisecond})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer c.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	bp.AddPoints(newTestPoints(1))
+	if err := c.Write(bp); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, exp := atomic.LoadInt32(calls), int32(3); got != exp {
+		t.Errorf("unexpected number of requests: got %d, exp %d", got, exp)
+	}
+}
+
+func TestClient_WriteRetryExhausted(t *testing.T) {
+	ts, calls := newRetryTestServer(t, 500, 500, 500, 500)
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, MaxRetries: 2, RetryInterval: time.Millisecond})
+	defer c.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	bp.AddPoints(newTestPoints(1))
+	err := c.Write(bp)
+	var re *RetryError
+	if !errors.As(err, &re) {
+		t.Fatalf("expected a *RetryError, got %v", err)
+	}
+	if re.Attempts != 3 {
+		t.Errorf("unexpected attempts: got %d, exp %d", re.Attempts, 3)
+	}
+	if exp := "write failed after 3 attempts: Internal Server Error"; err.Error() != exp {
+		t.Errorf("unexpected error: got %q, exp %q", err.Error(), exp)
+	}
+	if got := atomic.LoadInt32(calls); got != 3 {
+		t.Errorf("unexpected number of requests: got %d, exp %d", got, 3)
+	}
+}
+
+func TestClient_WriteNoRetryOnClientError(t *testing.T) {
+	ts, calls := newRetryTestServer(t, http.StatusBadRequest)
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, MaxRetries: 3, RetryInterval: time.Millisecond})
+	defer c.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	bp.AddPoints(newTestPoints(1))
+	err := c.Write(bp)
+	if err == nil || err.Error() != "Bad Request" {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if got := atomic.LoadInt32(calls); got != 1 {
+		t.Errorf("unexpected number of requests: got %d, exp %d", got, 1)
+	}
+}
+
+func TestClient_WriteNoRetryByDefault(t *testing.T) {
+	ts, calls := newRetryTestServer(t, http.StatusServiceUnavailable)
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	bp.AddPoints(newTestPoints(1))
+	if err := c.Write(bp); err == nil || err.Error() != "Service Unavailable" {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if got := atomic.LoadInt32(calls); got != 1 {
+		t.Errorf("unexpected number of requests: got %d, exp %d", got, 1)
+	}
+}
+
+func TestClient_WriteRetryAfter(t *testing.T) {
+	var calls int32
+	var first time.Time
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			first = time.Now()
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if d := time.Since(first); d < 900*time.Millisecond {
+			t.Errorf("retried after %v, expected Retry-After to be respected", d)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, MaxRetries: 1, RetryInterval: time.Millisecond})
+	defer c.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	bp.AddPoints(newTestPoints(1))
+	if err := c.Write(bp); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("unexpected number of requests: got %d, exp %d", n, 2)
+	}
+}
+
+func TestClient_WriteRetryAfterCapped(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, MaxRetries: 1, MaxRetryInterval: 50 * time.Millisecond})
+	defer c.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	bp.AddPoints(newTestPoints(1))
+	start := time.Now()
+	if err := c.Write(bp); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("retried after %v, expected MaxRetryInterval to cap Retry-After", d)
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("unexpected number of requests: got %d, exp %d", n, 2)
+	}
+}
+
+func TestClient_WriteRetryAfterDate(t *testing.T) {
+	// The date is in the past of the system clock, not of the client's.
+	clock := &replayClock{now: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	for _, status := range []int{http.StatusServiceUnavailable, http.StatusTooManyRequests} {
+		var calls int32
+		date := clock.now.Add(5 * time.Second).Format(http.TimeFormat)
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				w.Header().Set("Retry-After", date)
+				w.WriteHeader(status)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, MaxRetries: 1, Clock: clock})
+
+		bp, _ := NewBatchPoints(BatchPointsConfig{})
+		bp.AddPoints(newTestPoints(1))
+		clock.sleeps = nil
+		if err := c.Write(bp); err != nil {
+			t.Fatalf("status %d: unexpected error: %v", status, err)
+		}
+		if exp := []time.Duration{5 * time.Second}; !reflect.DeepEqual(clock.sleeps, exp) {
+			t.Errorf("status %d: unexpected sleeps: got %v, exp %v", status, clock.sleeps, exp)
+		}
+		c.Close()
+		ts.Close()
+	}
+}
+
+func TestClient_WriteRetryThrottled(t *testing.T) {
+	ts, calls := newRetryTestServer(t, http.StatusTooManyRequests, http.StatusTooManyRequests)
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, MaxRetries: 1, RetryInterval: time.Millisecond})
+	defer c.Close()
+
+	// Without hints, the retries back off as configured.
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	bp.AddPoints(newTestPoints(1))
+	start := time.Now()
+	err := c.Write(bp)
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("retried after %v, expected the default backoff", d)
+	}
+	var te *ThrottledError
+	if !errors.As(err, &te) || te.RetryAfter != 0 || te.Remaining != -1 || !te.Reset.IsZero() || te.Delay() != 0 {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if got := atomic.LoadInt32(calls); got != 2 {
+		t.Errorf("unexpected number of requests: got %d, exp %d", got, 2)
+	}
+}
+
+func TestClient_WriteRetryNetworkError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	addr := ts.URL
+	ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: addr, MaxRetries: 2, RetryInterval: time.Millisecond})
+	defer c.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	bp.AddPoints(newTestPoints(1))
+	err := c.Write(bp)
+	var re *RetryError
+	if !errors.As(err, &re) || re.Attempts != 3 {
+		t.Fatalf("expected a *RetryError after 3 attempts, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "connection refused") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestClient_WriteRetryContextCanceled(t *testing.T) {
+	ts, _ := newRetryTestServer(t, 503, 503, 503)
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, MaxRetries: 3, RetryInterval: time.Hour, MaxRetryInterval: time.Hour})
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	bp.AddPoints(newTestPoints(1))
+	if err := c.(ContextClient).WriteContext(ctx, bp); err != context.DeadlineExceeded {
+		t.Errorf("unexpected error: got %v, exp %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestClient_Backoff(t *testing.T) {
+	c := &client{retryInterval: 100 * time.Millisecond, maxRetryInterval: time.Second}
+	for attempt, max := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		max *= time.Millisecond
+		d := c.backoff(attempt + 1)
+		if d < max/2 || d >= max {
+			t.Errorf("attempt %d: backoff %v not in [%v, %v)", attempt+1, d, max/2, max)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	if got := parseRetryAfter("3", now); got != 3*time.Second {
+		t.Errorf("unexpected delay: got %v, exp %v", got, 3*time.Second)
+	}
+	if got := parseRetryAfter(now.Add(time.Minute).Format(http.TimeFormat), now); got != time.Minute {
+		t.Errorf("unexpected delay for HTTP date: got %v, exp %v", got, time.Minute)
+	}
+	for _, s := range []string{"", "-1", "soon", now.Add(-time.Minute).Format(http.TimeFormat)} {
+		if got := parseRetryAfter(s, now); got != 0 {
+			t.Errorf("parseRetryAfter(%q) = %v, exp 0", s, got)
+		}
+	}
+}
+
+func TestParseRateLimitHeaders(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	for s, exp := range map[string]time.Time{
+		"30":         now.Add(30 * time.Second),
+		"1700000060": time.Unix(1700000060, 0),
+		"":           {},
+		"-5":         {},
+		"later":      {},
+	} {
+		if got := parseRateLimitReset(s, now); !got.Equal(exp) {
+			t.Errorf("parseRateLimitReset(%q) = %v, exp %v", s, got, exp)
+		}
+	}
+	for s, exp := range map[string]int{"12": 12, "0": 0, "": -1, "many": -1} {
+		if got := parseRateLimitRemaining(s); got != exp {
+			t.Errorf("parseRateLimitRemaining(%q) = %v, exp %v", s, got, exp)
+		}
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	for _, tt := range []struct {
+		err error
+		exp bool
+	}{
+		{nil, false},
+		{&ErrorResponse{StatusCode: http.StatusBadRequest}, false},
+		{&ErrorResponse{StatusCode: http.StatusInternalServerError}, true},
+		{&ErrorResponse{StatusCode: http.StatusServiceUnavailable}, true},
+		{&ThrottledError{ErrorResponse: ErrorResponse{StatusCode: http.StatusTooManyRequests}}, true},
+		{&AuthorizationError{ErrorResponse: ErrorResponse{StatusCode: http.StatusUnauthorized}}, false},
+		{&PartialWriteError{ErrorResponse: ErrorResponse{StatusCode: http.StatusInternalServerError}}, false},
+		{&ConsistencyError{ErrorResponse: ErrorResponse{StatusCode: http.StatusInternalServerError}}, true},
+		{&HintedHandoffError{}, true},
+		{&RetryError{Attempts: 2, Err: &ErrorResponse{StatusCode: http.StatusBadGateway}}, true},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, true},
+		{context.DeadlineExceeded, false},
+		{ErrClientClosed, false},
+		{errors.New("unknown"), false},
+	} {
+		if got := IsRetryable(tt.err); got != tt.exp {
+			t.Errorf("unexpected IsRetryable(%#v).  expected %v, actual %v", tt.err, tt.exp, got)
+		}
+	}
+}
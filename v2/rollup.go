@@ -0,0 +1,515 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+// RollupAggregate is an aggregate a RollupRule computes for a field.
+type RollupAggregate string
+
+const (
+	RollupMean  RollupAggregate = "mean"
+	RollupSum   RollupAggregate = "sum"
+	RollupMin   RollupAggregate = "min"
+	RollupMax   RollupAggregate = "max"
+	RollupCount RollupAggregate = "count"
+)
+
+// RollupRule selects points a RollupWriter aggregates and sets how.
+type RollupRule struct {
+	// Measurement, or MeasurementRegexp if set, matches the measurements
+	// of the points rolled up. Both empty match every measurement.
+	Measurement       string
+	MeasurementRegexp *regexp.Regexp
+
+	// RetentionPolicy, if set, only rolls up the points of the batches
+	// written to that retention policy.
+	RetentionPolicy string
+
+	// Window is the duration of the windows, aligned on the Unix epoch.
+	Window time.Duration
+
+	// Fields are the aggregates computed for the fields of the points, by
+	// field key. The aggregate of a field is written to the field named
+	// after both as InfluxQL names them, such as "mean_value". mean is a
+	// float, count an integer, and sum, min and max are integers if every
+	// value of the window was one and floats otherwise. Fields that are not
+	// numbers are only counted.
+	Fields map[string][]RollupAggregate
+
+	// TargetMeasurement, TargetDatabase and TargetRetentionPolicy are where
+	// the rollups are written, empty ones keeping the measurement, database
+	// or retention policy of the points rolled up. At least one must be
+	// set, or the rollups would overwrite the points.
+	TargetMeasurement     string
+	TargetDatabase        string
+	TargetRetentionPolicy string
+}
+
+// RollupOptions configure a RollupWriter.
+type RollupOptions struct {
+	// Rules are the rollups computed. A point matching several rules is
+	// rolled up by each of them.
+	Rules []RollupRule
+
+	// AllowedLateness is how long, in the time of the points, a point may
+	// come after the end of its window and still be rolled up. A window is
+	// closed, and its rollup written, once a point at least AllowedLateness
+	// past its end was written.
+	AllowedLateness time.Duration
+
+	// OnLatePoint, if set, is called with the points that came after their
+	// window was closed, which are written but not rolled up.
+	OnLatePoint func(p *Point)
+}
+
+// RollupError is returned by a RollupWriter when the batch given was written
+// but the write of the rollups of the windows it closed failed. The batch
+// must not be written again, as its points were rolled up; the rollups are
+// written again along with the next batch, and by Close.
+type RollupError struct {
+	Err error
+}
+
+func (e *RollupError) Error() string {
+	return fmt.Sprintf("writing rollups: %v", e.Err)
+}
+
+func (e *RollupError) Unwrap() error { return e.Err }
+
+// RollupWriter is a Client that writes pre-aggregated rollups of the points
+// it writes, such as mean values per minute in a retention policy kept
+// longer than the raw points, without running continuous queries on the
+// server. The windows are closed by the time of the points rather than the
+// clock, so that points replayed from a queue are rolled up as if they came
+// live. Points without a time are not rolled up. Queries and pings are sent
+// as they are. RollupWriter is safe for concurrent use if the wrapped client
+// is.
+type RollupWriter struct {
+	c        Client
+	rules    []RollupRule
+	lateness time.Duration
+	onLate   func(p *Point)
+
+	mu sync.Mutex
+
+	// watermark is the latest time of the points rolled up.
+	watermark int64
+	seen      bool
+
+	windows map[rollupKey]*rollupWindow
+
+	// pending are the rollups whose write failed.
+	pending []*rollupWindow
+}
+
+// rollupKey identifies the window of a series for a rule.
+type rollupKey struct {
+	rule   int
+	db, rp string
+	series string
+	start  int64
+}
+
+// rollupWindow accumulates the values of the fields of a series over a
+// window.
+type rollupWindow struct {
+	key                rollupKey
+	measurement        string
+	tags               models.Tags
+	precision          string
+	writeConsistency   string
+	fields             map[string]*rollupField
+	targetDB, targetRP string
+}
+
+// rollupField accumulates the values of a field.
+type rollupField struct {
+	count    int64
+	numbers  int64
+	sum      float64
+	min, max float64
+	intSum   int64
+	ints     bool
+	imin     int64
+	imax     int64
+}
+
+// NewRollupWriter returns a RollupWriter writing with c. A rule without a
+// positive Window, without Fields, with an unknown aggregate or without a
+// target is a *ConfigError. Closing the RollupWriter does not close c.
+func NewRollupWriter(c Client, opts RollupOptions) (*RollupWriter, error) {
+	if opts.AllowedLateness < 0 {
+		return nil, &ConfigError{Field: "AllowedLateness", Reason: fmt.Sprintf("%v is negative", opts.AllowedLateness)}
+	}
+	for i, r := range opts.Rules {
+		field := fmt.Sprintf("Rules[%d]", i)
+		switch {
+		case r.Window <= 0:
+			return nil, &ConfigError{Field: field + ".Window", Reason: "must be positive"}
+		case len(r.Fields) == 0:
+			return nil, &ConfigError{Field: field + ".Fields", Reason: "no field given"}
+		case r.TargetMeasurement == "" && r.TargetDatabase == "" && r.TargetRetentionPolicy == "":
+			return nil, &ConfigError{Field: field, Reason: "no target measurement, database or retention policy given"}
+		}
+		for k, aggs := range r.Fields {
+			for _, agg := range aggs {
+				switch agg {
+				case RollupMean, RollupSum, RollupMin, RollupMax, RollupCount:
+				default:
+					return nil, &ConfigError{Field: field + ".Fields", Reason: fmt.Sprintf("unknown aggregate %q of field %q", agg, k)}
+				}
+			}
+		}
+	}
+	return &RollupWriter{
+		c:        c,
+		rules:    append([]RollupRule(nil), opts.Rules...),
+		lateness: opts.AllowedLateness,
+		onLate:   opts.OnLatePoint,
+		windows:  make(map[rollupKey]*rollupWindow),
+	}, nil
+}
+
+// Ping checks the status of the wrapped client.
+func (rw *RollupWriter) Ping(timeout time.Duration) (time.Duration, string, error) {
+	return rw.c.Ping(timeout)
+}
+
+// Write writes bp, then rolls up its points and writes the rollups of the
+// windows they closed, see WriteContext.
+func (rw *RollupWriter) Write(bp BatchPoints) error {
+	return rw.WriteContext(context.Background(), bp)
+}
+
+// WriteContext writes bp as it is. Once it was written, its points are
+// rolled up, and the rollups of the windows they closed are written, a
+// batch per destination. A failed write of bp is returned as is, and none
+// of its points are rolled up, so that it can be written again; a failed
+// write of the rollups is a *RollupError.
+func (rw *RollupWriter) WriteContext(ctx context.Context, bp BatchPoints) error {
+	if err := bp.Err(); err != nil {
+		return err
+	}
+	if err := writeContext(ctx, rw.c, bp); err != nil {
+		return err
+	}
+	closed, err := rw.add(bp)
+	if err != nil {
+		return err
+	}
+	return rw.writeRollups(ctx, closed)
+}
+
+// Close writes the rollups of the windows still open, partial as they are,
+// along with those whose write failed.
+func (rw *RollupWriter) Close() error {
+	rw.mu.Lock()
+	closed := rw.pending
+	rw.pending = nil
+	for _, w := range rw.windows {
+		closed = append(closed, w)
+	}
+	rw.windows = make(map[rollupKey]*rollupWindow)
+	rw.mu.Unlock()
+	sortWindows(closed)
+	return rw.writeRollups(context.Background(), closed)
+}
+
+// Query sends q with the wrapped client.
+func (rw *RollupWriter) Query(q Query) (*Response, error) {
+	return rw.c.Query(q)
+}
+
+// QueryContext sends q with the wrapped client.
+func (rw *RollupWriter) QueryContext(ctx context.Context, q Query) (*Response, error) {
+	return queryContext(ctx, rw.c, q)
+}
+
+// QueryAsChunk sends q with the wrapped client.
+func (rw *RollupWriter) QueryAsChunk(q Query) (*ChunkedResponse, error) {
+	return rw.c.QueryAsChunk(q)
+}
+
+// QueryAsChunkContext sends q with the wrapped client.
+func (rw *RollupWriter) QueryAsChunkContext(ctx context.Context, q Query) (*ChunkedResponse, error) {
+	if cc, ok := rw.c.(ContextClient); ok {
+		return cc.QueryAsChunkContext(ctx, q)
+	}
+	return rw.c.QueryAsChunk(q)
+}
+
+// add rolls up the points of bp, and returns the windows they closed along
+// with the rollups whose write failed before.
+func (rw *RollupWriter) add(bp BatchPoints) ([]*rollupWindow, error) {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	for _, p := range bp.Points() {
+		if p == nil || p.pt.Time().IsZero() {
+			continue
+		}
+		t := p.pt.UnixNano()
+		var fields models.Fields
+		for i := range rw.rules {
+			r := &rw.rules[i]
+			if !r.matches(p, bp.RetentionPolicy()) {
+				continue
+			}
+			start := windowStart(t, r.Window)
+			if rw.seen && start+int64(r.Window)+int64(rw.lateness) <= rw.watermark {
+				if rw.onLate != nil {
+					rw.onLate(p)
+				}
+				continue
+			}
+			if fields == nil {
+				var err error
+				if fields, err = p.pt.Fields(); err != nil {
+					return nil, err
+				}
+			}
+			key := rollupKey{rule: i, db: bp.Database(), rp: bp.RetentionPolicy(), series: string(p.pt.Key()), start: start}
+			w, ok := rw.windows[key]
+			if !ok {
+				w = newRollupWindow(key, r, p, bp)
+				rw.windows[key] = w
+			}
+			w.add(r, fields)
+		}
+		if fields != nil && (!rw.seen || t > rw.watermark) {
+			rw.watermark, rw.seen = t, true
+		}
+	}
+
+	closed := rw.pending
+	rw.pending = nil
+	for key, w := range rw.windows {
+		if key.start+int64(rw.rules[key.rule].Window)+int64(rw.lateness) <= rw.watermark {
+			closed = append(closed, w)
+			delete(rw.windows, key)
+		}
+	}
+	sortWindows(closed)
+	return closed, nil
+}
+
+// writeRollups writes the rollups of windows, a batch per destination. The
+// windows of a failed write are kept to be written again.
+func (rw *RollupWriter) writeRollups(ctx context.Context, windows []*rollupWindow) error {
+	type destination struct {
+		db, rp, precision, consistency string
+	}
+	var order []destination
+	batches := make(map[destination][]*rollupWindow)
+	for _, w := range windows {
+		d := destination{w.targetDB, w.targetRP, w.precision, w.writeConsistency}
+		if _, ok := batches[d]; !ok {
+			order = append(order, d)
+		}
+		batches[d] = append(batches[d], w)
+	}
+
+	var failed []*rollupWindow
+	var first error
+	for _, d := range order {
+		err := rw.writeWindows(ctx, BatchPointsConfig{
+			Database:         d.db,
+			RetentionPolicy:  d.rp,
+			Precision:        d.precision,
+			WriteConsistency: d.consistency,
+		}, batches[d])
+		if err != nil {
+			failed = append(failed, batches[d]...)
+			if first == nil {
+				first = err
+			}
+		}
+	}
+	if first == nil {
+		return nil
+	}
+	rw.mu.Lock()
+	rw.pending = append(rw.pending, failed...)
+	rw.mu.Unlock()
+	return &RollupError{Err: first}
+}
+
+// writeWindows writes the rollups of windows in a batch of conf.
+func (rw *RollupWriter) writeWindows(ctx context.Context, conf BatchPointsConfig, windows []*rollupWindow) error {
+	bp, err := NewBatchPoints(conf)
+	if err != nil {
+		return err
+	}
+	for _, w := range windows {
+		pt, err := w.point(&rw.rules[w.key.rule])
+		if err != nil {
+			return err
+		}
+		if pt != nil {
+			if err := bp.AddPoint(NewPointFrom(pt)); err != nil {
+				return err
+			}
+		}
+	}
+	if len(bp.Points()) == 0 {
+		return nil
+	}
+	return writeContext(ctx, rw.c, bp)
+}
+
+// matches reports whether r rolls up p, written to the retention policy rp.
+func (r *RollupRule) matches(p *Point, rp string) bool {
+	if r.RetentionPolicy != "" && r.RetentionPolicy != rp {
+		return false
+	}
+	switch {
+	case r.MeasurementRegexp != nil:
+		return r.MeasurementRegexp.Match(p.pt.Name())
+	case r.Measurement != "":
+		return string(p.pt.Name()) == r.Measurement
+	}
+	return true
+}
+
+// windowStart returns the start of the window of duration d that t, in
+// nanoseconds since the Unix epoch, falls in.
+func windowStart(t int64, d time.Duration) int64 {
+	start := t - t%int64(d)
+	if t < 0 && start != t {
+		start -= int64(d)
+	}
+	return start
+}
+
+// sortWindows sorts windows by start, then by series and rule, so that the
+// rollups are written in a stable order.
+func sortWindows(windows []*rollupWindow) {
+	sort.SliceStable(windows, func(i, j int) bool {
+		a, b := windows[i].key, windows[j].key
+		if a.start != b.start {
+			return a.start < b.start
+		}
+		if a.series != b.series {
+			return a.series < b.series
+		}
+		return a.rule < b.rule
+	})
+}
+
+func newRollupWindow(key rollupKey, r *RollupRule, p *Point, bp BatchPoints) *rollupWindow {
+	w := &rollupWindow{
+		key:              key,
+		measurement:      string(p.pt.Name()),
+		tags:             p.pt.Tags().Clone(),
+		precision:        bp.Precision(),
+		writeConsistency: bp.WriteConsistency(),
+		fields:           make(map[string]*rollupField, len(r.Fields)),
+		targetDB:         override(r.TargetDatabase, key.db),
+		targetRP:         override(r.TargetRetentionPolicy, key.rp),
+	}
+	if r.TargetMeasurement != "" {
+		w.measurement = r.TargetMeasurement
+	}
+	return w
+}
+
+// add accumulates the fields of a point the rule r rolls up.
+func (w *rollupWindow) add(r *RollupRule, fields models.Fields) {
+	for k := range r.Fields {
+		v, ok := fields[k]
+		if !ok {
+			continue
+		}
+		f := w.fields[k]
+		if f == nil {
+			f = &rollupField{ints: true}
+			w.fields[k] = f
+		}
+		f.add(v)
+	}
+}
+
+func (f *rollupField) add(v interface{}) {
+	f.count++
+	var x float64
+	var i int64
+	isInt := false
+	switch v := v.(type) {
+	case float64:
+		x = v
+	case int64:
+		x, i, isInt = float64(v), v, true
+	case uint64:
+		x = float64(v)
+		if v <= 1<<63-1 {
+			i, isInt = int64(v), true
+		}
+	default:
+		return
+	}
+	if f.numbers == 0 || x < f.min {
+		f.min = x
+	}
+	if f.numbers == 0 || x > f.max {
+		f.max = x
+	}
+	if isInt && (f.numbers == 0 || i < f.imin) {
+		f.imin = i
+	}
+	if isInt && (f.numbers == 0 || i > f.imax) {
+		f.imax = i
+	}
+	f.numbers++
+	f.sum += x
+	f.intSum += i
+	f.ints = f.ints && isInt
+}
+
+// point returns the rollup of the window, or nil if no field of the rule
+// was found in its points.
+func (w *rollupWindow) point(r *RollupRule) (models.Point, error) {
+	fields := make(models.Fields)
+	for k, aggs := range r.Fields {
+		f := w.fields[k]
+		if f == nil {
+			continue
+		}
+		for _, agg := range aggs {
+			name := string(agg) + "_" + k
+			if agg == RollupCount {
+				fields[name] = f.count
+				continue
+			}
+			if f.numbers == 0 {
+				continue
+			}
+			switch {
+			case agg == RollupMean:
+				fields[name] = f.sum / float64(f.numbers)
+			case agg == RollupSum && f.ints:
+				fields[name] = f.intSum
+			case agg == RollupSum:
+				fields[name] = f.sum
+			case agg == RollupMin && f.ints:
+				fields[name] = f.imin
+			case agg == RollupMin:
+				fields[name] = f.min
+			case agg == RollupMax && f.ints:
+				fields[name] = f.imax
+			case agg == RollupMax:
+				fields[name] = f.max
+			}
+		}
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	return models.NewPoint(w.measurement, w.tags, fields, time.Unix(0, w.key.start))
+}
@@ -0,0 +1,174 @@
+package client
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// rpRecorder records the batches written to it as their retention policy
+// and lines.
+type rpRecorder struct {
+	batchRecorder
+	writes []string
+}
+
+func (r *rpRecorder) Write(bp BatchPoints) error {
+	if r.err != nil {
+		return r.err
+	}
+	for _, p := range bp.Points() {
+		r.writes = append(r.writes, bp.RetentionPolicy()+": "+p.String())
+	}
+	return nil
+}
+
+func rollupBatch(t *testing.T, rp string, points ...*Point) BatchPoints {
+	t.Helper()
+	bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0", RetentionPolicy: rp})
+	bp.AddPoints(points)
+	return bp
+}
+
+func rollupPoint(t *testing.T, name, host string, fields map[string]interface{}, sec int64) *Point {
+	t.Helper()
+	p, err := NewPoint(name, map[string]string{"host": host}, fields, time.Unix(sec, 0))
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	return p
+}
+
+func TestRollupWriter(t *testing.T) {
+	var rec rpRecorder
+	var late []*Point
+	rw, err := NewRollupWriter(&rec, RollupOptions{
+		Rules: []RollupRule{{
+			Measurement:           "cpu",
+			RetentionPolicy:       "raw",
+			Window:                time.Minute,
+			Fields:                map[string][]RollupAggregate{"usage": {RollupMean, RollupMax, RollupCount}, "n": {RollupSum, RollupMin}},
+			TargetRetentionPolicy: "downsampled",
+		}},
+		AllowedLateness: 10 * time.Second,
+		OnLatePoint:     func(p *Point) { late = append(late, p) },
+	})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+
+	err = rw.Write(rollupBatch(t, "raw",
+		rollupPoint(t, "cpu", "a", map[string]interface{}{"usage": 1.0, "n": 2}, 0),
+		rollupPoint(t, "cpu", "a", map[string]interface{}{"usage": 3.0, "n": 5}, 30),
+		rollupPoint(t, "cpu", "b", map[string]interface{}{"usage": 4.0}, 59),
+		rollupPoint(t, "mem", "a", map[string]interface{}{"usage": 9.0}, 61),
+	))
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	// The point at 61s is not rolled up: it does not move the watermark,
+	// nor does one within the allowed lateness.
+	rw.Write(rollupBatch(t, "raw", rollupPoint(t, "cpu", "a", map[string]interface{}{"usage": 5.0, "n": 1}, 65)))
+	if len(rec.writes) != 5 {
+		t.Fatalf("unexpected writes before the window closed: %q", rec.writes)
+	}
+
+	rec.writes = nil
+	rw.Write(rollupBatch(t, "raw", rollupPoint(t, "cpu", "b", map[string]interface{}{"usage": 2.0}, 70)))
+	exp := []string{
+		"raw: cpu,host=b usage=2 70000000000",
+		"downsampled: cpu,host=a count_usage=2i,max_usage=3,mean_usage=2,min_n=2i,sum_n=7i 0",
+		"downsampled: cpu,host=b count_usage=1i,max_usage=4,mean_usage=4 0",
+	}
+	if !reflect.DeepEqual(rec.writes, exp) {
+		t.Errorf("unexpected writes.\nexpected %q\nactual   %q", exp, rec.writes)
+	}
+
+	// A point of a closed window is written but not rolled up; points of
+	// other retention policies are not rolled up either.
+	rec.writes = nil
+	rw.Write(rollupBatch(t, "raw", rollupPoint(t, "cpu", "a", map[string]interface{}{"usage": 7.0}, 20)))
+	rw.Write(rollupBatch(t, "other", rollupPoint(t, "cpu", "c", map[string]interface{}{"usage": 7.0}, 80)))
+	if len(late) != 1 || late[0].Time().Unix() != 20 || len(rec.writes) != 2 {
+		t.Errorf("unexpected late points %v, writes %q", late, rec.writes)
+	}
+
+	// Close rolls up the windows left open.
+	rec.writes = nil
+	if err := rw.Close(); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	exp = []string{
+		"downsampled: cpu,host=a count_usage=1i,max_usage=5,mean_usage=5,min_n=1i,sum_n=1i 60000000000",
+		"downsampled: cpu,host=b count_usage=1i,max_usage=2,mean_usage=2 60000000000",
+	}
+	if !reflect.DeepEqual(rec.writes, exp) {
+		t.Errorf("unexpected writes.\nexpected %q\nactual   %q", exp, rec.writes)
+	}
+}
+
+func TestRollupWriter_Errors(t *testing.T) {
+	var rec rpRecorder
+	rw, _ := NewRollupWriter(&rec, RollupOptions{Rules: []RollupRule{{
+		MeasurementRegexp: regexp.MustCompile("^c"),
+		Window:            time.Minute,
+		Fields:            map[string][]RollupAggregate{"usage": {RollupSum}},
+		TargetMeasurement: "cpu_1m",
+	}}})
+
+	// A failed write of the batch is not rolled up.
+	errDown := errors.New("down")
+	rec.err = errDown
+	if err := rw.Write(rollupBatch(t, "", rollupPoint(t, "cpu", "a", map[string]interface{}{"usage": 1.5}, 0))); err != errDown {
+		t.Errorf("unexpected error.  expected %v, actual %v", errDown, err)
+	}
+	rec.err = nil
+
+	// A failed write of the rollups is retried with the next batch.
+	failing := &failingRollups{rpRecorder: &rec, fail: true}
+	rw.c = failing
+	rw.Write(rollupBatch(t, "", rollupPoint(t, "cpu", "a", map[string]interface{}{"usage": 2.0}, 10)))
+	var re *RollupError
+	if err := rw.Write(rollupBatch(t, "", rollupPoint(t, "cpu", "a", map[string]interface{}{"usage": 1.0}, 60))); !errors.As(err, &re) {
+		t.Fatalf("unexpected error.  expected %T, actual %v", re, err)
+	}
+	failing.fail = false
+	rw.Write(rollupBatch(t, "", rollupPoint(t, "cpu", "a", map[string]interface{}{"usage": 1.0}, 61)))
+	exp := []string{
+		": cpu,host=a usage=2 10000000000",
+		": cpu,host=a usage=1 60000000000",
+		": cpu,host=a usage=1 61000000000",
+		": cpu_1m,host=a sum_usage=2 0",
+	}
+	if !reflect.DeepEqual(rec.writes, exp) {
+		t.Errorf("unexpected writes.\nexpected %q\nactual   %q", exp, rec.writes)
+	}
+
+	for _, opts := range []RollupOptions{
+		{AllowedLateness: -1},
+		{Rules: []RollupRule{{Fields: map[string][]RollupAggregate{"v": {RollupSum}}, TargetRetentionPolicy: "1m"}}},
+		{Rules: []RollupRule{{Window: time.Minute, TargetRetentionPolicy: "1m"}}},
+		{Rules: []RollupRule{{Window: time.Minute, Fields: map[string][]RollupAggregate{"v": {"median"}}, TargetRetentionPolicy: "1m"}}},
+		{Rules: []RollupRule{{Window: time.Minute, Fields: map[string][]RollupAggregate{"v": {RollupSum}}}}},
+	} {
+		var ce *ConfigError
+		if _, err := NewRollupWriter(&rec, opts); !errors.As(err, &ce) {
+			t.Errorf("unexpected error for %+v.  expected %T, actual %v", opts, ce, err)
+		}
+	}
+}
+
+// failingRollups fails the writes to cpu_1m while fail is set.
+type failingRollups struct {
+	*rpRecorder
+	fail bool
+}
+
+func (f *failingRollups) Write(bp BatchPoints) error {
+	if f.fail && bp.Points()[0].Name() == "cpu_1m" {
+		return errors.New("rollups rejected")
+	}
+	return f.rpRecorder.Write(bp)
+}
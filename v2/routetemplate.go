@@ -0,0 +1,221 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+// MissingTagPolicy tells what a RoutingClient with a RouteTemplate does with a
+// point that lacks a tag its templates refer to.
+type MissingTagPolicy int
+
+const (
+	// MissingTagFail fails the write with a *MissingTagError, before any of
+	// the batch is written.
+	MissingTagFail MissingTagPolicy = iota
+
+	// MissingTagDrop leaves the point out of the write.
+	MissingTagDrop
+
+	// MissingTagDefault writes the point as it is, to the database and
+	// retention policy of its batch.
+	MissingTagDefault
+)
+
+// RouteTemplate is the config of a RoutingClient routing points by templates
+// of their retention policy and measurement, as for a database holding the
+// data of many tenants:
+//
+//	RouteTemplate{
+//		RetentionPolicy: "rp_{tag:tenant}",
+//		Measurement:     "metrics_{tag:tenant}",
+//	}
+//
+// A placeholder {tag:key} is replaced with the value of the tag key of the
+// point, made safe for an identifier by Sanitize. Literal braces are written
+// {{ and }}.
+type RouteTemplate struct {
+	// Database is the database of the points, or the one of their batch if
+	// empty.
+	Database string
+
+	// RetentionPolicy is the template of the retention policy of the
+	// points. If empty, they are written to the retention policy of their
+	// batch, that of Database being its default one.
+	RetentionPolicy string
+
+	// Measurement is the template of the measurement of the points. If
+	// empty, the points keep their measurement.
+	Measurement string
+
+	// MissingTag tells what to do with a point that lacks a tag of the
+	// templates, by default fail the write.
+	MissingTag MissingTagPolicy
+
+	// Sanitize, if set, returns the value of a tag as substituted in the
+	// templates. It defaults to SanitizeIdentifier.
+	Sanitize func(value string) string
+}
+
+// MissingTagError is returned by a RoutingClient with a RouteTemplate for a
+// point that lacks a tag its templates refer to, with MissingTagFail.
+type MissingTagError struct {
+	// Measurement is the measurement of the point, Tag the key of the tag
+	// it lacks.
+	Measurement string
+	Tag         string
+}
+
+func (e *MissingTagError) Error() string {
+	return fmt.Sprintf("point of %s has no %s tag to route it by", e.Measurement, e.Tag)
+}
+
+// SanitizeIdentifier returns value with every character other than an ASCII
+// letter or digit, '_' or '-' replaced with '_', so that it can be part of a
+// measurement or retention policy name without being quoted or escaped.
+func SanitizeIdentifier(value string) string {
+	safe := func(r rune) bool {
+		return r < 0x80 && (r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-')
+	}
+	if strings.IndexFunc(value, func(r rune) bool { return !safe(r) }) < 0 {
+		return value
+	}
+	return strings.Map(func(r rune) rune {
+		if safe(r) {
+			return r
+		}
+		return '_'
+	}, value)
+}
+
+// NewTemplateRoutingClient returns a RoutingClient that writes with c the
+// points of a batch to the destinations and measurements t makes of their
+// tags. The points of a batch written to the same database and retention
+// policy share a batch, whatever their measurement. It returns a
+// *ConfigError for a template that does not parse.
+func NewTemplateRoutingClient(c Client, t RouteTemplate) (*RoutingClient, error) {
+	rt := &routeTemplate{db: t.Database, missing: t.MissingTag, sanitize: t.Sanitize}
+	if rt.sanitize == nil {
+		rt.sanitize = SanitizeIdentifier
+	}
+	switch t.MissingTag {
+	case MissingTagFail, MissingTagDrop, MissingTagDefault:
+	default:
+		return nil, &ConfigError{Field: "MissingTag", Reason: fmt.Sprintf("unknown policy %d", t.MissingTag)}
+	}
+	var err error
+	if rt.rp, err = parseTemplate("RetentionPolicy", t.RetentionPolicy); err != nil {
+		return nil, err
+	}
+	if rt.measurement, err = parseTemplate("Measurement", t.Measurement); err != nil {
+		return nil, err
+	}
+	return &RoutingClient{c: c, tmpl: rt}, nil
+}
+
+// routeTemplate is the parsed RouteTemplate of a RoutingClient.
+type routeTemplate struct {
+	db              string
+	rp, measurement template
+	missing         MissingTagPolicy
+	sanitize        func(string) string
+}
+
+// template is a parsed template, nil for an empty one. The parts are
+// literals and, at the odd indexes, the keys of the tags substituted
+// between them.
+type template []string
+
+// parseTemplate parses s, the template of field, returning a *ConfigError if
+// it is malformed.
+func parseTemplate(field, s string) (template, error) {
+	if s == "" {
+		return nil, nil
+	}
+	fail := func(reason string) (template, error) {
+		return nil, &ConfigError{Field: field, Reason: reason}
+	}
+	var t template
+	var lit strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '{' && strings.HasPrefix(s[i:], "{{"), c == '}' && strings.HasPrefix(s[i:], "}}"):
+			lit.WriteByte(c)
+			i++
+		case c == '{':
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				return fail("unterminated placeholder")
+			}
+			ph := s[i+1 : i+end]
+			key := strings.TrimPrefix(ph, "tag:")
+			if key == ph || key == "" {
+				return fail(fmt.Sprintf("unknown placeholder {%s}, must be {tag:key}", ph))
+			}
+			t = append(t, lit.String(), key)
+			lit.Reset()
+			i += end
+		case c == '}':
+			return fail("unmatched }")
+		default:
+			lit.WriteByte(c)
+		}
+	}
+	return append(t, lit.String()), nil
+}
+
+// expand returns t with tags substituted, or the key of the first tag
+// missing.
+func (t template) expand(tags models.Tags, sanitize func(string) string) (s, missing string) {
+	var b strings.Builder
+	for i, part := range t {
+		if i%2 == 0 {
+			b.WriteString(part)
+			continue
+		}
+		v := tags.Get([]byte(part))
+		if len(v) == 0 {
+			return "", part
+		}
+		b.WriteString(sanitize(string(v)))
+	}
+	return b.String(), ""
+}
+
+// resolve returns p of bp as it is written, with its measurement renamed,
+// along with its database and retention policy. It returns a nil point for a
+// point dropped.
+func (rt *routeTemplate) resolve(p *Point, bp BatchPoints) (*Point, string, string, error) {
+	tags := p.pt.Tags()
+	rp, missing := rt.rp.expand(tags, rt.sanitize)
+	var name string
+	if missing == "" && rt.measurement != nil {
+		name, missing = rt.measurement.expand(tags, rt.sanitize)
+	}
+	if missing != "" {
+		switch rt.missing {
+		case MissingTagDrop:
+			return nil, "", "", nil
+		case MissingTagDefault:
+			return p, bp.Database(), bp.RetentionPolicy(), nil
+		}
+		return nil, "", "", &MissingTagError{Measurement: p.Name(), Tag: missing}
+	}
+	if rt.measurement != nil && name != p.Name() {
+		renamed, err := p.WithName(name)
+		if err != nil {
+			return nil, "", "", err
+		}
+		p = renamed
+	}
+	db := rt.db
+	switch {
+	case db == "" && rt.rp == nil:
+		return p, bp.Database(), bp.RetentionPolicy(), nil
+	case db == "":
+		db = bp.Database()
+	}
+	return p, db, rp, nil
+}
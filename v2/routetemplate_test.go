@@ -0,0 +1,129 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var tenantTemplate = RouteTemplate{
+	RetentionPolicy: "rp_{tag:tenant}",
+	Measurement:     "metrics_{tag:tenant}",
+}
+
+func TestTemplateRoutingClient_Write(t *testing.T) {
+	r := &destinationRecorder{}
+	c, err := NewTemplateRoutingClient(r, tenantTemplate)
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+
+	// The points of 100 tenants, interleaved, in a single batch.
+	bp, _ := NewBatchPoints(BatchPointsConfig{Database: "tenants", Precision: "s", WriteConsistency: "any"})
+	for i := 0; i < 300; i++ {
+		tags := map[string]string{"tenant": fmt.Sprintf("t%d", i%100), "host": "a"}
+		bp.AddPoint(mustPoint(t, fmt.Sprintf("m%d", i%2), tags, map[string]interface{}{"v": int64(i)}, time.Unix(int64(i), 0)))
+	}
+	if err := c.Write(bp); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if len(r.writes) != 100 {
+		t.Fatalf("unexpected writes.  expected %d, actual %d", 100, len(r.writes))
+	}
+	for i, w := range r.writes {
+		exp := fmt.Sprintf("tenants.rp_t%d s any:", i)
+		for j := i; j < 300; j += 100 {
+			exp += fmt.Sprintf(" metrics_t%d,host=a,tenant=t%d v=%di %d", i, i, j, j)
+		}
+		if w != exp {
+			t.Errorf("unexpected write %d.\nexpected %s\nactual   %s", i, exp, w)
+		}
+	}
+	// The points of the batch are left as they were.
+	if name := bp.Points()[0].Name(); name != "m0" {
+		t.Errorf("unexpected measurement of the point added.  expected %s, actual %s", "m0", name)
+	}
+}
+
+func TestTemplateRoutingClient_MissingTag(t *testing.T) {
+	newBatch := func() BatchPoints {
+		bp, _ := NewBatchPoints(BatchPointsConfig{Database: "tenants", RetentionPolicy: "rp0", Precision: "s"})
+		bp.AddPoint(mustPoint(t, "cpu", map[string]string{"tenant": "acme"}, map[string]interface{}{"v": int64(0)}, time.Unix(0, 0)))
+		bp.AddPoint(mustPoint(t, "cpu", nil, map[string]interface{}{"v": int64(1)}, time.Unix(1, 0)))
+		return bp
+	}
+	for _, tt := range []struct {
+		policy MissingTagPolicy
+		writes []string
+	}{
+		{MissingTagDrop, []string{"tenants.rp_acme s : metrics_acme,tenant=acme v=0i 0"}},
+		{MissingTagDefault, []string{"tenants.rp_acme s : metrics_acme,tenant=acme v=0i 0", "tenants.rp0 s : cpu v=1i 1"}},
+	} {
+		r := &destinationRecorder{}
+		tmpl := tenantTemplate
+		tmpl.MissingTag = tt.policy
+		c, _ := NewTemplateRoutingClient(r, tmpl)
+		if err := c.Write(newBatch()); err != nil {
+			t.Fatalf("policy %d: unexpected error.  expected %v, actual %v", tt.policy, nil, err)
+		}
+		if !reflect.DeepEqual(r.writes, tt.writes) {
+			t.Errorf("policy %d: unexpected writes.\nexpected %q\nactual   %q", tt.policy, tt.writes, r.writes)
+		}
+	}
+
+	r := &destinationRecorder{}
+	c, _ := NewTemplateRoutingClient(r, tenantTemplate)
+	err := c.Write(newBatch())
+	var me *MissingTagError
+	if !errors.As(err, &me) || me.Tag != "tenant" || me.Measurement != "cpu" {
+		t.Fatalf("unexpected error.  expected a *MissingTagError, actual %v", err)
+	}
+	if len(r.writes) != 0 {
+		t.Errorf("unexpected writes.  expected none, actual %q", r.writes)
+	}
+}
+
+func TestTemplateRoutingClient_Sanitize(t *testing.T) {
+	r := &destinationRecorder{}
+	c, _ := NewTemplateRoutingClient(r, RouteTemplate{Database: "db1", Measurement: "{{{tag:region}}}_{tag:tenant}"})
+	bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0", Precision: "s"})
+	bp.AddPoint(mustPoint(t, "cpu", map[string]string{"tenant": "Acme Corp/EU", "region": "eu-west.1"}, map[string]interface{}{"v": int64(0)}, time.Unix(0, 0)))
+	if err := c.Write(bp); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	exp := []string{`db1. s : {eu-west_1}_Acme_Corp_EU,region=eu-west.1,tenant=Acme\ Corp/EU v=0i 0`}
+	if !reflect.DeepEqual(r.writes, exp) {
+		t.Errorf("unexpected writes.\nexpected %q\nactual   %q", exp, r.writes)
+	}
+
+	r.writes = nil
+	c, _ = NewTemplateRoutingClient(r, RouteTemplate{Measurement: "m_{tag:tenant}", Sanitize: strings.ToLower})
+	if err := c.Write(bp); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if exp := `db0. s : m_acme\ corp/eu,region=eu-west.1,tenant=Acme\ Corp/EU v=0i 0`; len(r.writes) != 1 || r.writes[0] != exp {
+		t.Errorf("unexpected writes.\nexpected %q\nactual   %q", exp, r.writes)
+	}
+}
+
+func TestNewTemplateRoutingClient_Errors(t *testing.T) {
+	for _, tt := range []struct {
+		tmpl  RouteTemplate
+		field string
+	}{
+		{RouteTemplate{Measurement: "metrics_{tenant}"}, "Measurement"},
+		{RouteTemplate{Measurement: "metrics_{tag:}"}, "Measurement"},
+		{RouteTemplate{RetentionPolicy: "rp_{tag:tenant"}, "RetentionPolicy"},
+		{RouteTemplate{RetentionPolicy: "rp_}"}, "RetentionPolicy"},
+		{RouteTemplate{MissingTag: MissingTagPolicy(7)}, "MissingTag"},
+	} {
+		_, err := NewTemplateRoutingClient(&destinationRecorder{}, tt.tmpl)
+		var ce *ConfigError
+		if !errors.As(err, &ce) || ce.Field != tt.field {
+			t.Errorf("%+v: unexpected error.  expected a *ConfigError for %s, actual %v", tt.tmpl, tt.field, err)
+		}
+	}
+}
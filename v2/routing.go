@@ -0,0 +1,211 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// RoutingClient is a Client that writes the points of a batch to databases
+// and retention policies chosen point by point, so that points kept for
+// different durations can be added to a single batch. Queries are sent as
+// they are. RoutingClient is safe for concurrent use if the wrapped client
+// and the route function are.
+type RoutingClient struct {
+	c     Client
+	route func(p *Point) (db, rp string)
+
+	// tmpl, if set, routes the points in place of route.
+	tmpl *routeTemplate
+}
+
+// NewRoutingClient returns a RoutingClient that writes with c the points of a
+// batch to the database and retention policy route returns for them. An empty
+// database routes a point to the database and retention policy of its batch,
+// and an empty retention policy to the default one of the database. Closing
+// the RoutingClient does not close c.
+func NewRoutingClient(c Client, route func(p *Point) (db, rp string)) *RoutingClient {
+	return &RoutingClient{c: c, route: route}
+}
+
+// Route is the destination of the points of a batch written by a
+// RoutingClient, and the outcome of their write.
+type Route struct {
+	Database        string
+	RetentionPolicy string
+
+	// Points is the number of points routed to the destination.
+	Points int
+
+	// Err is the error the write to the destination failed with, or nil.
+	Err error
+}
+
+func (r Route) String() string {
+	if r.RetentionPolicy == "" {
+		return r.Database
+	}
+	return r.Database + "." + r.RetentionPolicy
+}
+
+// RoutingError is returned by a RoutingClient when the write to some of the
+// destinations of a batch failed. The points routed to the others were
+// written.
+type RoutingError struct {
+	// Routes holds every destination of the batch, in the order their
+	// first point was added to it.
+	Routes []Route
+}
+
+// Failed returns the destinations whose write failed.
+func (e *RoutingError) Failed() []Route {
+	var failed []Route
+	for _, r := range e.Routes {
+		if r.Err != nil {
+			failed = append(failed, r)
+		}
+	}
+	return failed
+}
+
+func (e *RoutingError) Error() string {
+	failed := e.Failed()
+	msgs := make([]string, len(failed))
+	for i, r := range failed {
+		msgs[i] = fmt.Sprintf("%s (%d points): %v", r, r.Points, r.Err)
+	}
+	return fmt.Sprintf("write failed for %d of %d destinations: %s", len(failed), len(e.Routes), strings.Join(msgs, "; "))
+}
+
+// Unwrap returns the errors of the destinations whose write failed.
+func (e *RoutingError) Unwrap() []error {
+	var errs []error
+	for _, r := range e.Failed() {
+		errs = append(errs, r.Err)
+	}
+	return errs
+}
+
+// Ping checks the status of the wrapped client.
+func (rc *RoutingClient) Ping(timeout time.Duration) (time.Duration, string, error) {
+	return rc.c.Ping(timeout)
+}
+
+// Write splits bp into a batch per destination and writes them one after the
+// other, each with the precision and write consistency of bp and its points
+// in the order of bp. If some of the writes fail, the error is a
+// *RoutingError.
+func (rc *RoutingClient) Write(bp BatchPoints) error {
+	return rc.WriteContext(context.Background(), bp)
+}
+
+// WriteContext is like Write, but the writes are bound to ctx if the wrapped
+// client supports it.
+func (rc *RoutingClient) WriteContext(ctx context.Context, bp BatchPoints) error {
+	if err := bp.Err(); err != nil {
+		return err
+	}
+	routes, batches, err := rc.split(bp)
+	if err != nil {
+		return err
+	}
+
+	failed := false
+	for i, b := range batches {
+		if routes[i].Err = ctx.Err(); routes[i].Err == nil {
+			if cc, ok := rc.c.(ContextClient); ok {
+				routes[i].Err = cc.WriteContext(ctx, b)
+			} else {
+				routes[i].Err = rc.c.Write(b)
+			}
+		}
+		failed = failed || routes[i].Err != nil
+	}
+	if failed {
+		return &RoutingError{Routes: routes}
+	}
+	return nil
+}
+
+// split returns the destinations of the points of bp along with their
+// batches.
+func (rc *RoutingClient) split(bp BatchPoints) ([]Route, []BatchPoints, error) {
+	conf := batchPointsConfig(bp)
+	// The points were already counted by the limiter of bp, and sorted if
+	// it sorts them.
+	conf.CardinalityLimiter = nil
+	conf.SortOnWrite = false
+
+	var routes []Route
+	var batches []BatchPoints
+	index := make(map[[2]string]int)
+	for _, p := range bp.Points() {
+		if p == nil {
+			continue
+		}
+		var db, rp string
+		if rc.tmpl != nil {
+			var err error
+			if p, db, rp, err = rc.tmpl.resolve(p, bp); err != nil {
+				return nil, nil, err
+			}
+			if p == nil {
+				continue
+			}
+		} else if db, rp = rc.route(p); db == "" {
+			db, rp = bp.Database(), bp.RetentionPolicy()
+		}
+		key := [2]string{db, rp}
+		i, ok := index[key]
+		if !ok {
+			conf.Database, conf.RetentionPolicy = db, rp
+			b, err := NewBatchPoints(conf)
+			if err != nil {
+				return nil, nil, err
+			}
+			i = len(routes)
+			index[key] = i
+			routes = append(routes, Route{Database: db, RetentionPolicy: rp})
+			batches = append(batches, b)
+		}
+		if err := batches[i].AddPoint(p); err != nil {
+			return nil, nil, err
+		}
+		routes[i].Points++
+	}
+	return routes, batches, nil
+}
+
+// Query sends q with the wrapped client.
+func (rc *RoutingClient) Query(q Query) (*Response, error) {
+	return rc.c.Query(q)
+}
+
+// QueryContext sends q with the wrapped client, bound to ctx if it supports
+// it.
+func (rc *RoutingClient) QueryContext(ctx context.Context, q Query) (*Response, error) {
+	if cc, ok := rc.c.(ContextClient); ok {
+		return cc.QueryContext(ctx, q)
+	}
+	return rc.c.Query(q)
+}
+
+// QueryAsChunk sends q with the wrapped client.
+func (rc *RoutingClient) QueryAsChunk(q Query) (*ChunkedResponse, error) {
+	return rc.c.QueryAsChunk(q)
+}
+
+// QueryAsChunkContext sends q with the wrapped client, bound to ctx if it
+// supports it.
+func (rc *RoutingClient) QueryAsChunkContext(ctx context.Context, q Query) (*ChunkedResponse, error) {
+	if cc, ok := rc.c.(ContextClient); ok {
+		return cc.QueryAsChunkContext(ctx, q)
+	}
+	return rc.c.QueryAsChunk(q)
+}
+
+// Close does nothing, the wrapped client is left open.
+func (rc *RoutingClient) Close() error {
+	return nil
+}
@@ -0,0 +1,108 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// destinationRecorder is a Client that records the destination and points of
+// the batches written to it, failing those written to the database fail.
+type destinationRecorder struct {
+	batchRecorder
+	writes []string
+	fail   string
+}
+
+var errDestination = errors.New("destination failed")
+
+func (r *destinationRecorder) Write(bp BatchPoints) error {
+	w := fmt.Sprintf("%s.%s %s %s:", bp.Database(), bp.RetentionPolicy(), bp.Precision(), bp.WriteConsistency())
+	for _, p := range bp.Points() {
+		w += " " + p.PrecisionString(bp.Precision())
+	}
+	r.writes = append(r.writes, w)
+	if bp.Database() == r.fail {
+		return errDestination
+	}
+	return nil
+}
+
+func routeByTier(p *Point) (string, string) {
+	switch p.Tags()["tier"] {
+	case "long":
+		return "metrics", "forever"
+	case "short":
+		return "metrics", "week"
+	case "other":
+		return "other", ""
+	}
+	return "", ""
+}
+
+func newRoutedBatch(t *testing.T) BatchPoints {
+	bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0", RetentionPolicy: "rp0", Precision: "s", WriteConsistency: "any"})
+	for i, tier := range []string{"long", "short", "", "long", "other", "short", "long"} {
+		tags := map[string]string{}
+		if tier != "" {
+			tags["tier"] = tier
+		}
+		bp.AddPoint(mustPoint(t, "cpu", tags, map[string]interface{}{"v": int64(i)}, time.Unix(int64(i), 0)))
+	}
+	return bp
+}
+
+func TestRoutingClient_Write(t *testing.T) {
+	r := &destinationRecorder{}
+	c := NewRoutingClient(r, routeByTier)
+
+	if err := c.Write(newRoutedBatch(t)); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	exp := []string{
+		"metrics.forever s any: cpu,tier=long v=0i 0 cpu,tier=long v=3i 3 cpu,tier=long v=6i 6",
+		"metrics.week s any: cpu,tier=short v=1i 1 cpu,tier=short v=5i 5",
+		"db0.rp0 s any: cpu v=2i 2",
+		"other. s any: cpu,tier=other v=4i 4",
+	}
+	if !reflect.DeepEqual(r.writes, exp) {
+		t.Errorf("unexpected writes.\nexpected %q\nactual   %q", exp, r.writes)
+	}
+
+	empty, _ := NewBatchPoints(BatchPointsConfig{})
+	if err := c.Write(empty); err != nil || len(r.writes) != len(exp) {
+		t.Errorf("unexpected write of an empty batch: %v", err)
+	}
+}
+
+func TestRoutingClient_WriteError(t *testing.T) {
+	r := &destinationRecorder{fail: "metrics"}
+	c := NewRoutingClient(r, routeByTier)
+
+	err := c.Write(newRoutedBatch(t))
+	var re *RoutingError
+	if !errors.As(err, &re) {
+		t.Fatalf("unexpected error.  expected a *RoutingError, actual %v", err)
+	}
+	if !errors.Is(err, errDestination) {
+		t.Errorf("expected the error to match the error of the destination, got %v", err)
+	}
+	if len(r.writes) != 4 {
+		t.Errorf("unexpected writes, every destination should be written: %q", r.writes)
+	}
+	exp := []Route{
+		{Database: "metrics", RetentionPolicy: "forever", Points: 3, Err: errDestination},
+		{Database: "metrics", RetentionPolicy: "week", Points: 2, Err: errDestination},
+	}
+	if failed := re.Failed(); !reflect.DeepEqual(failed, exp) {
+		t.Errorf("unexpected failed routes.  expected %v, actual %v", exp, failed)
+	}
+	if len(re.Routes) != 4 || re.Routes[2].Err != nil || re.Routes[2].Points != 1 {
+		t.Errorf("unexpected routes: %v", re.Routes)
+	}
+	if msg := "write failed for 2 of 4 destinations: metrics.forever (3 points): destination failed; metrics.week (2 points): destination failed"; err.Error() != msg {
+		t.Errorf("unexpected message.\nexpected %s\nactual   %s", msg, err.Error())
+	}
+}
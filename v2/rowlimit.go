@@ -0,0 +1,269 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ErrTooManyRows is matched by the *TooManyRowsError a query fails with when
+// its response has more rows or series than its MaxRows or MaxSeries.
+var ErrTooManyRows = errors.New("too many rows")
+
+// TooManyRowsError is returned when the response of a query has more rows
+// than its MaxRows, or more series than its MaxSeries. Reading the response
+// was aborted once the limit was exceeded.
+type TooManyRowsError struct {
+	MaxRows   int
+	MaxSeries int
+
+	// Rows and Series are the numbers of rows and series read before the
+	// response was aborted.
+	Rows   int
+	Series int
+}
+
+func (e *TooManyRowsError) Error() string {
+	if e.MaxRows > 0 && e.Rows > e.MaxRows {
+		return fmt.Sprintf("too many rows: read %d, more than the maximum of %d", e.Rows, e.MaxRows)
+	}
+	return fmt.Sprintf("too many series: read %d, more than the maximum of %d", e.Series, e.MaxSeries)
+}
+
+func (e *TooManyRowsError) Is(target error) bool { return target == ErrTooManyRows }
+
+// rowGuard counts the rows and series of the responses of a query with
+// MaxRows or MaxSeries.
+type rowGuard struct {
+	maxRows   int
+	maxSeries int
+	rows      int
+	series    int
+
+	// continued is set when the last series read was partial, its rows
+	// going on in the next chunk.
+	continued bool
+}
+
+// newRowGuard returns the guard of q, nil if it has no limit.
+func newRowGuard(q Query) *rowGuard {
+	if q.MaxRows <= 0 && q.MaxSeries <= 0 {
+		return nil
+	}
+	return &rowGuard{maxRows: q.MaxRows, maxSeries: q.MaxSeries}
+}
+
+// add counts the rows and series of results, and returns a
+// *TooManyRowsError once there are too many.
+func (g *rowGuard) add(results []Result) error {
+	for _, result := range results {
+		for _, row := range result.Series {
+			if !g.continued {
+				g.series++
+			}
+			g.continued = row.Partial
+			g.rows += len(row.Values)
+			if g.maxRows > 0 && g.rows > g.maxRows || g.maxSeries > 0 && g.series > g.maxSeries {
+				return &TooManyRowsError{MaxRows: g.maxRows, MaxSeries: g.maxSeries, Rows: g.rows, Series: g.series}
+			}
+		}
+	}
+	return nil
+}
+
+// limitCommand returns the command of q with its LIMIT lowered to MaxRows
+// and its SLIMIT to MaxSeries, or added if it has none. Only a command made
+// of a single SELECT statement without INTO or subqueries is modified,
+// others are returned as they are: the guard of the response still applies.
+func (q Query) limitCommand() string {
+	if q.MaxRows <= 0 && q.MaxSeries <= 0 {
+		return q.Command
+	}
+	tokens, ok := scanSelect(q.Command)
+	if !ok {
+		return q.Command
+	}
+
+	// The clauses that follow LIMIT and SLIMIT, where they are inserted if
+	// the statement has none.
+	end := tokens[len(tokens)-1].end
+	clause := func(words ...string) (int, int) {
+		for i, t := range tokens {
+			for _, w := range words {
+				if t.word == w {
+					return i, t.start
+				}
+			}
+		}
+		return -1, end
+	}
+
+	type edit struct {
+		start, end int
+		text       string
+	}
+	var edits []edit
+	limit := func(word string, max int, before ...string) bool {
+		if max <= 0 {
+			return true
+		}
+		i, pos := clause(append([]string{word}, before...)...)
+		if i < 0 || tokens[i].word != word {
+			edits = append(edits, edit{start: pos, end: pos, text: fmt.Sprintf("%s %d ", word, max)})
+			return true
+		}
+		if i+1 == len(tokens) {
+			return false
+		}
+		n, err := strconv.Atoi(tokens[i+1].word)
+		if err != nil {
+			// A bound parameter, whose value is not known here.
+			return false
+		}
+		if n == 0 || n > max {
+			// LIMIT 0 is no limit.
+			edits = append(edits, edit{start: tokens[i+1].start, end: tokens[i+1].end, text: strconv.Itoa(max)})
+		}
+		return true
+	}
+	if !limit("LIMIT", q.MaxRows, "OFFSET", "SLIMIT", "SOFFSET", "TZ") || !limit("SLIMIT", q.MaxSeries, "SOFFSET", "TZ") {
+		return q.Command
+	}
+
+	// LIMIT goes before SLIMIT when both are inserted at the same place.
+	sort.SliceStable(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+	var b strings.Builder
+	last := 0
+	for _, e := range edits {
+		b.WriteString(q.Command[last:e.start])
+		text := e.text
+		if e.start == end && e.start == e.end {
+			text = " " + strings.TrimSpace(text)
+		}
+		b.WriteString(text)
+		last = e.end
+	}
+	b.WriteString(q.Command[last:])
+	return b.String()
+}
+
+// selectToken is a word, in upper case, a literal, with no word, or a
+// punctuation mark of a SELECT statement outside of parentheses, and its
+// position in the statement.
+type selectToken struct {
+	word       string
+	start, end int
+}
+
+// scanSelect returns the tokens of command if it is a single SELECT statement
+// without INTO or subqueries. Quoted strings, identifiers, regular expressions
+// and comments are skipped.
+func scanSelect(command string) ([]selectToken, bool) {
+	var tokens []selectToken
+	depth := 0
+	// prev is the previous token, at any depth, telling a regular
+	// expression from a division.
+	prev := ""
+	for i := 0; i < len(command); {
+		s := command[i:]
+		switch c := s[0]; {
+		case c == ';':
+			// Only comments and spaces may follow.
+			if len(splitStatements(s[1:])) > 0 {
+				return nil, false
+			}
+			i = len(command)
+		case strings.HasPrefix(s, "--"):
+			if n := strings.IndexByte(s, '\n'); n >= 0 {
+				i += n + 1
+			} else {
+				i = len(command)
+			}
+		case strings.HasPrefix(s, "/*"):
+			if n := strings.Index(s[2:], "*/"); n >= 0 {
+				i += n + 4
+			} else {
+				i = len(command)
+			}
+		case c == '\'' || c == '"':
+			n := quotedLen(s)
+			if len(tokens) == 0 || n < 2 || s[n-1] != c {
+				return nil, false
+			}
+			if depth == 0 {
+				tokens = append(tokens, selectToken{start: i, end: i + n})
+			}
+			prev = "literal"
+			i += n
+		case c == '/' && (prev == "SELECT" || prev == "FROM" || prev == "," || prev == "=~" || prev == "!~"):
+			n := regexLen(s)
+			if n < 0 {
+				return nil, false
+			}
+			if depth == 0 {
+				tokens = append(tokens, selectToken{start: i, end: i + n})
+			}
+			prev = "literal"
+			i += n
+		case isWordByte(c) || c == '$':
+			n := 1
+			for n < len(s) && (isWordByte(s[n]) || s[n] == '.') {
+				n++
+			}
+			word := strings.ToUpper(s[:n])
+			if len(tokens) == 0 && word != "SELECT" || word == "INTO" {
+				return nil, false
+			}
+			if word == "SELECT" && prev == "(" {
+				// A subquery.
+				return nil, false
+			}
+			if depth == 0 {
+				tokens = append(tokens, selectToken{word: word, start: i, end: i + n})
+			}
+			prev = word
+			i += n
+		case unicode.IsSpace(rune(c)):
+			i++
+		default:
+			if len(tokens) == 0 {
+				return nil, false
+			}
+			n := 1
+			if strings.HasPrefix(s, "=~") || strings.HasPrefix(s, "!~") {
+				n = 2
+			}
+			if c == ')' {
+				if depth--; depth < 0 {
+					return nil, false
+				}
+			}
+			if depth == 0 {
+				tokens = append(tokens, selectToken{word: s[:n], start: i, end: i + n})
+			}
+			if c == '(' {
+				depth++
+			}
+			prev = s[:n]
+			i += n
+		}
+	}
+	return tokens, len(tokens) > 0 && depth == 0
+}
+
+// regexLen returns the length of the regular expression s starts with,
+// including its slashes, or -1 if it is not terminated.
+func regexLen(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '/':
+			return i + 1
+		}
+	}
+	return -1
+}
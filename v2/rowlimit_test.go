@@ -0,0 +1,173 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+func TestQuery_LimitCommand(t *testing.T) {
+	tests := []struct {
+		command   string
+		maxRows   int
+		maxSeries int
+		exp       string
+	}{
+		{command: `SELECT * FROM cpu`, maxRows: 10, exp: `SELECT * FROM cpu LIMIT 10`},
+		{command: `SELECT * FROM cpu`, maxSeries: 5, exp: `SELECT * FROM cpu SLIMIT 5`},
+		{command: `SELECT * FROM cpu;`, maxRows: 10, maxSeries: 5, exp: `SELECT * FROM cpu LIMIT 10 SLIMIT 5;`},
+		{command: `select * from cpu limit 100 offset 3`, maxRows: 10, exp: `select * from cpu limit 10 offset 3`},
+		{command: `SELECT * FROM cpu LIMIT 5`, maxRows: 10, exp: `SELECT * FROM cpu LIMIT 5`},
+		{command: `SELECT * FROM cpu LIMIT 0`, maxRows: 10, exp: `SELECT * FROM cpu LIMIT 10`},
+		{
+			command:   `SELECT mean(v) FROM cpu WHERE host = 'a' GROUP BY time(1m), * fill(none) OFFSET 2 SOFFSET 1 tz('Europe/Paris')`,
+			maxRows:   10,
+			maxSeries: 5,
+			exp:       `SELECT mean(v) FROM cpu WHERE host = 'a' GROUP BY time(1m), * fill(none) LIMIT 10 OFFSET 2 SLIMIT 5 SOFFSET 1 tz('Europe/Paris')`,
+		},
+		{command: `SELECT * FROM /cpu/ WHERE host =~ /a limit 1/ -- limit`, maxRows: 10, exp: `SELECT * FROM /cpu/ WHERE host =~ /a limit 1/ LIMIT 10 -- limit`},
+		{command: `SELECT "limit" FROM cpu WHERE t = 'SLIMIT 3'`, maxRows: 10, maxSeries: 5, exp: `SELECT "limit" FROM cpu WHERE t = 'SLIMIT 3' LIMIT 10 SLIMIT 5`},
+		{command: `SELECT v / 2 FROM cpu`, maxRows: 10, exp: `SELECT v / 2 FROM cpu LIMIT 10`},
+
+		// Statements that are not modified.
+		{command: `SELECT * FROM cpu`, exp: `SELECT * FROM cpu`},
+		{command: `SELECT * INTO cpu_copy FROM cpu`, maxRows: 10, exp: `SELECT * INTO cpu_copy FROM cpu`},
+		{command: `SELECT max(v) FROM (SELECT mean(v) AS v FROM cpu GROUP BY host)`, maxRows: 10, exp: `SELECT max(v) FROM (SELECT mean(v) AS v FROM cpu GROUP BY host)`},
+		{command: `SELECT * FROM cpu; SELECT * FROM mem`, maxRows: 10, exp: `SELECT * FROM cpu; SELECT * FROM mem`},
+		{command: `SHOW SERIES`, maxRows: 10, exp: `SHOW SERIES`},
+		{command: `SELECT * FROM cpu LIMIT $n`, maxRows: 10, exp: `SELECT * FROM cpu LIMIT $n`},
+		{command: `SELECT * FROM cpu WHERE host = 'a`, maxRows: 10, exp: `SELECT * FROM cpu WHERE host = 'a`},
+	}
+
+	for _, test := range tests {
+		q := Query{Command: test.command, MaxRows: test.maxRows, MaxSeries: test.maxSeries}
+		if actual := q.limitCommand(); actual != test.exp {
+			t.Errorf("unexpected command for %q.\nexpected %s\nactual   %s", test.command, test.exp, actual)
+		}
+	}
+}
+
+// newRowsServer answers every query with series series of rows rows, in a
+// chunk per series if chunked, and records the commands it got.
+func newRowsServer(series, rows int) (*httptest.Server, *[]string) {
+	var commands []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		commands = append(commands, r.FormValue("q"))
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		var all []models.Row
+		for i := 0; i < series; i++ {
+			row := models.Row{Name: "cpu", Tags: map[string]string{"host": string(rune('a' + i))}, Columns: []string{"time", "v"}}
+			for j := 0; j < rows; j++ {
+				row.Values = append(row.Values, []interface{}{j, j})
+			}
+			if r.FormValue("chunked") == "true" {
+				enc.Encode(Response{Results: []Result{{Series: []models.Row{row}}}})
+				w.(http.Flusher).Flush()
+			}
+			all = append(all, row)
+		}
+		if r.FormValue("chunked") != "true" {
+			enc.Encode(Response{Results: []Result{{Series: all}}})
+		}
+	}))
+	return ts, &commands
+}
+
+func TestQuery_MaxRows(t *testing.T) {
+	ts, commands := newRowsServer(4, 3)
+	defer ts.Close()
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	tests := []struct {
+		name      string
+		q         Query
+		exp       *TooManyRowsError
+		expSeries int
+	}{
+		{name: "under", q: Query{MaxRows: 12, MaxSeries: 4}, expSeries: 4},
+		{name: "rows", q: Query{MaxRows: 10}, exp: &TooManyRowsError{MaxRows: 10, Rows: 12, Series: 4}},
+		{name: "rows chunked", q: Query{MaxRows: 5, Chunked: true}, exp: &TooManyRowsError{MaxRows: 5, Rows: 6, Series: 2}},
+		{name: "series", q: Query{MaxSeries: 2}, exp: &TooManyRowsError{MaxSeries: 2, Rows: 9, Series: 3}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.q.Command = "SELECT * FROM cpu GROUP BY *"
+			resp, err := c.Query(test.q)
+			if test.exp == nil {
+				if err != nil || len(resp.Results[0].Series) != test.expSeries {
+					t.Fatalf("unexpected response.  expected %d series, actual %+v, %v", test.expSeries, resp, err)
+				}
+				return
+			}
+			var tmr *TooManyRowsError
+			if !errors.As(err, &tmr) || !errors.Is(err, ErrTooManyRows) {
+				t.Fatalf("unexpected error.  expected a *TooManyRowsError, actual %v", err)
+			}
+			if *tmr != *test.exp {
+				t.Errorf("unexpected error.  expected %+v, actual %+v", test.exp, tmr)
+			}
+			if resp != nil {
+				t.Errorf("unexpected response.  expected %v, actual %+v", nil, resp)
+			}
+		})
+	}
+	if exp, actual := "SELECT * FROM cpu GROUP BY * LIMIT 12 SLIMIT 4", (*commands)[0]; actual != exp {
+		t.Errorf("unexpected command.\nexpected %s\nactual   %s", exp, actual)
+	}
+}
+
+func TestQueryAsChunk_MaxRows(t *testing.T) {
+	ts, _ := newRowsServer(4, 3)
+	defer ts.Close()
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	cr, err := c.QueryAsChunk(Query{Command: "SELECT * FROM cpu GROUP BY *", MaxSeries: 2})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	defer cr.Close()
+	var read int
+	for {
+		_, err = cr.NextResponse()
+		if err != nil {
+			break
+		}
+		read++
+	}
+	exp := &TooManyRowsError{MaxSeries: 2, Rows: 9, Series: 3}
+	var tmr *TooManyRowsError
+	if !errors.As(err, &tmr) || *tmr != *exp {
+		t.Fatalf("unexpected error.  expected %v, actual %v", exp, err)
+	}
+	if read != 2 {
+		t.Errorf("unexpected responses read.  expected %v, actual %v", 2, read)
+	}
+	if _, err := cr.NextResponse(); err != tmr {
+		t.Errorf("unexpected error.  expected %v, actual %v", tmr, err)
+	}
+}
+
+func TestRowGuard_PartialSeries(t *testing.T) {
+	g := &rowGuard{maxSeries: 1}
+	row := models.Row{Name: "cpu", Values: [][]interface{}{{1}}, Partial: true}
+	for i := 0; i < 3; i++ {
+		// The chunks of one series are counted once.
+		if err := g.add([]Result{{Series: []models.Row{row}}}); err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+	}
+	row.Partial = false
+	if err := g.add([]Result{{Series: []models.Row{row, row}}}); err == nil || g.rows != 5 {
+		t.Errorf("unexpected outcome.  expected an error after %d rows, actual %v after %d", 5, err, g.rows)
+	}
+	if err := (&TooManyRowsError{MaxRows: 2, Rows: 3}).Error(); err != "too many rows: read 3, more than the maximum of 2" {
+		t.Errorf("unexpected message: %s", err)
+	}
+}
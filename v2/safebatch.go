@@ -0,0 +1,129 @@
+package client
+
+import "sync"
+
+// SafeBatchPoints is a BatchPoints that is safe for concurrent use by
+// multiple goroutines.
+type SafeBatchPoints interface {
+	BatchPoints
+
+	// Drain removes the points from the batch and returns them in a new
+	// BatchPoints with the same settings, ready to be written.
+	Drain() BatchPoints
+}
+
+// NewSafeBatchPoints returns a SafeBatchPoints based on the given config.
+// Its Points method returns a copy of the points, so that they can be read
+// while other goroutines keep adding points.
+func NewSafeBatchPoints(conf BatchPointsConfig) (SafeBatchPoints, error) {
+	bp, err := NewBatchPoints(conf)
+	if err != nil {
+		return nil, err
+	}
+	return &safeBatchPoints{bp: bp.(*batchpoints)}, nil
+}
+
+type safeBatchPoints struct {
+	mu sync.Mutex
+	bp *batchpoints
+}
+
+func (s *safeBatchPoints) AddPoint(p *Point) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bp.AddPoint(p)
+}
+
+func (s *safeBatchPoints) AddPoints(ps []*Point) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bp.AddPoints(ps)
+}
+
+func (s *safeBatchPoints) Size() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bp.Size()
+}
+
+func (s *safeBatchPoints) Points() []*Point {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Point(nil), s.bp.Points()...)
+}
+
+func (s *safeBatchPoints) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bp.Reset()
+}
+
+func (s *safeBatchPoints) Validate() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bp.Validate()
+}
+
+func (s *safeBatchPoints) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bp.Err()
+}
+
+func (s *safeBatchPoints) Drain() BatchPoints {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	bp := *s.bp
+	s.bp.points = nil
+	s.bp.nilPoint = false
+	s.bp.size = 0
+	return &bp
+}
+
+func (s *safeBatchPoints) Precision() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bp.Precision()
+}
+
+func (s *safeBatchPoints) Database() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bp.Database()
+}
+
+func (s *safeBatchPoints) WriteConsistency() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bp.WriteConsistency()
+}
+
+func (s *safeBatchPoints) RetentionPolicy() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bp.RetentionPolicy()
+}
+
+func (s *safeBatchPoints) SetPrecision(p string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bp.SetPrecision(p)
+}
+
+func (s *safeBatchPoints) SetDatabase(db string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bp.SetDatabase(db)
+}
+
+func (s *safeBatchPoints) SetWriteConsistency(wc string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bp.SetWriteConsistency(wc)
+}
+
+func (s *safeBatchPoints) SetRetentionPolicy(rp string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bp.SetRetentionPolicy(rp)
+}
@@ -0,0 +1,103 @@
+package client
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSafeBatchPoints_Concurrent(t *testing.T) {
+	var received int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		atomic.AddInt64(&received, int64(bytes.Count(body, []byte{'\n'})))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	bp, err := NewSafeBatchPoints(BatchPointsConfig{Database: "db0"})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+
+	const goroutines, perGoroutine = 16, 500
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perGoroutine; j++ {
+				pt, _ := NewPoint("cpu", nil, map[string]interface{}{"value": j}, time.Unix(int64(j), 0))
+				bp.AddPoint(pt)
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		for {
+			// Every point was added once done is closed, so the next
+			// drain is the last one.
+			var last bool
+			select {
+			case <-done:
+				last = true
+			default:
+			}
+			for _, pt := range bp.Points() {
+				_ = pt.Name()
+			}
+			if batch := bp.Drain(); len(batch.Points()) > 0 {
+				if err := c.Write(batch); err != nil {
+					t.Errorf("unexpected error.  expected %v, actual %v", nil, err)
+				}
+			}
+			if last {
+				return
+			}
+		}
+	}()
+
+	wg.Wait()
+	close(done)
+	<-drained
+
+	if got, exp := atomic.LoadInt64(&received), int64(goroutines*perGoroutine); got != exp {
+		t.Errorf("unexpected number of points written.  expected %v, actual %v", exp, got)
+	}
+}
+
+func TestSafeBatchPoints_Drain(t *testing.T) {
+	bp, _ := NewSafeBatchPoints(BatchPointsConfig{Database: "db0", Precision: "s"})
+	pt, _ := NewPoint("cpu", nil, map[string]interface{}{"value": 1})
+	bp.AddPoints([]*Point{pt, pt})
+
+	points := bp.Points()
+	bp.AddPoint(pt)
+	if len(points) != 2 {
+		t.Errorf("expected Points to return a copy, got %d points", len(points))
+	}
+
+	batch := bp.Drain()
+	if len(batch.Points()) != 3 || batch.Database() != "db0" || batch.Precision() != "s" {
+		t.Errorf("unexpected drained batch: %d points, database %q, precision %q", len(batch.Points()), batch.Database(), batch.Precision())
+	}
+	if n := len(bp.Points()); n != 0 {
+		t.Errorf("unexpected number of points after Drain.  expected %v, actual %v", 0, n)
+	}
+	// The drained batch does not share points with the safe one.
+	bp.AddPoint(pt)
+	if n := len(batch.Points()); n != 3 {
+		t.Errorf("unexpected number of drained points.  expected %v, actual %v", 3, n)
+	}
+}
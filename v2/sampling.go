@@ -0,0 +1,125 @@
+package client
+
+import (
+	"context"
+)
+
+// SamplingStatsCollector is implemented by a StatsCollector that is told
+// about the points a SamplingClient dropped, when it is set on the HTTP, UDP
+// or TCP client the SamplingClient writes with.
+type SamplingStatsCollector interface {
+	// PointsSampledOut is called for every batch some points of were
+	// dropped, with the number of those points, before the others are
+	// written.
+	PointsSampledOut(points int)
+}
+
+// NewSamplingClient returns a Client that writes a sample of the points of
+// every batch with c, such as to shed load rather than drop every point or
+// overload the server. rate is called for every batch and returns the
+// fraction of the series to keep, from 0 for none to 1 for all, so that it
+// can follow the error rate or the state of a circuit breaker.
+//
+// The points are sampled by the key keyFn returns for them, the hash of
+// their series key if keyFn is nil: the points of a key are either all
+// kept or all dropped while the rate does not change, which keeps the
+// series written complete, and the keys kept at a rate are a subset of
+// those kept at any higher rate. A batch whose rate is 1 or more is written
+// as it is, without looking at its points. Otherwise the points kept are
+// written in a new batch with the settings of the batch given, which is left
+// as is, and the batch is not written if every point was dropped.
+//
+// Queries and pings are sent with c as they are, and closing the returned
+// Client closes c.
+func NewSamplingClient(c Client, rate func() float64, keyFn func(*Point) uint64) Client {
+	s := &sampler{rate: rate, key: keyFn}
+	if s.key == nil {
+		s.key = seriesKeyHash
+	}
+	if sc, ok := c.(statsClient); ok {
+		s.stats, _ = sc.statsCollector().(SamplingStatsCollector)
+	}
+	return WrapClient(c, s.intercept)
+}
+
+// seriesKeyHash returns the hash of the series key of p.
+func seriesKeyHash(p *Point) uint64 {
+	return hashTagValue(p.pt.Key())
+}
+
+// sampler implements NewSamplingClient.
+type sampler struct {
+	rate  func() float64
+	key   func(*Point) uint64
+	stats SamplingStatsCollector
+}
+
+func (s *sampler) intercept(next WriteFunc) WriteFunc {
+	return func(ctx context.Context, bp BatchPoints) error {
+		rate := s.rate()
+		if !(rate < 1) {
+			return next(ctx, bp)
+		}
+		if err := bp.Err(); err != nil {
+			return err
+		}
+		threshold := sampleThreshold(rate)
+		points := bp.Points()
+		kept := make([]*Point, 0, len(points))
+		dropped := 0
+		for _, p := range points {
+			if p == nil {
+				continue
+			}
+			if mixHash(s.key(p))>>11 < threshold {
+				kept = append(kept, p)
+			} else {
+				dropped++
+			}
+		}
+		if dropped == 0 {
+			return next(ctx, bp)
+		}
+		if s.stats != nil {
+			s.stats.PointsSampledOut(dropped)
+		}
+		if len(kept) == 0 {
+			return nil
+		}
+
+		conf := batchPointsConfig(bp)
+		// The points were already counted by the limiter of bp, and their
+		// size was checked against its limit.
+		conf.CardinalityLimiter = nil
+		conf.MaxBytes = 0
+		nbp, err := NewBatchPoints(conf)
+		if err != nil {
+			return err
+		}
+		if err := nbp.AddPoints(kept); err != nil {
+			return err
+		}
+		return next(ctx, nbp)
+	}
+}
+
+// sampleThreshold returns the number of the 2^53 values of a key's hash,
+// shifted right by 11 bits, that are kept at rate.
+func sampleThreshold(rate float64) uint64 {
+	if rate <= 0 {
+		return 0
+	}
+	return uint64(rate * (1 << 53))
+}
+
+// mixHash scrambles the bits of h, the finalizer of SplitMix64, so that keys
+// differing in a few bits, or the low bits of a weak hash, are spread over
+// the whole range.
+func mixHash(h uint64) uint64 {
+	h ^= h >> 30
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 27
+	h *= 0x94d049bb133111eb
+	h ^= h >> 31
+	return h
+}
@@ -0,0 +1,157 @@
+package client
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newSeriesPoints returns points points of each of series series.
+func newSeriesPoints(series, points int) []*Point {
+	var out []*Point
+	for i := 0; i < series; i++ {
+		for j := 0; j < points; j++ {
+			p, _ := NewPoint("cpu", map[string]string{"host": fmt.Sprintf("host%d", i)}, map[string]interface{}{"value": j}, time.Unix(int64(j), 0))
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func TestSamplingClient_Rate(t *testing.T) {
+	const series = 20000
+	points := newSeriesPoints(series, 1)
+	for _, rate := range []float64{0, 0.01, 0.1, 0.5, 0.9, 0.99} {
+		r := &batchRecorder{}
+		c := NewSamplingClient(r, func() float64 { return rate }, nil)
+		bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0"})
+		bp.AddPoints(points)
+		if err := c.Write(bp); err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+		var kept int
+		for _, batch := range r.batches {
+			kept += len(batch)
+		}
+		// The fraction kept is within 5 standard deviations of the rate.
+		if sd := math.Sqrt(rate * (1 - rate) / series); math.Abs(float64(kept)/series-rate) > 5*sd {
+			t.Errorf("unexpected sample at rate %v.  expected %v points, actual %v", rate, rate*series, kept)
+		}
+		if len(bp.Points()) != series {
+			t.Errorf("unexpected batch changed.  expected %v points, actual %v", series, len(bp.Points()))
+		}
+	}
+}
+
+func TestSamplingClient_ConsistentSeries(t *testing.T) {
+	var rate = 0.5
+	r := &batchRecorder{}
+	c := NewSamplingClient(r, func() float64 { return rate }, nil)
+
+	keptAt := func(rate float64) map[string]int {
+		r.batches = nil
+		for i := 0; i < 5; i++ {
+			bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0"})
+			bp.AddPoints(newSeriesPoints(500, 4))
+			c.Write(bp)
+		}
+		kept := make(map[string]int)
+		for _, batch := range r.batches {
+			for _, p := range batch {
+				kept[string(p.pt.Key())]++
+			}
+		}
+		return kept
+	}
+	half := keptAt(rate)
+	for key, n := range half {
+		// Every point of a series kept is kept.
+		if n != 20 {
+			t.Fatalf("unexpected points of series %s.  expected %v, actual %v", key, 20, n)
+		}
+	}
+	rate = 0.25
+	for key := range keptAt(rate) {
+		if _, ok := half[key]; !ok {
+			t.Errorf("unexpected series %s kept at a lower rate but not at a higher one", key)
+		}
+	}
+
+	// Points are sampled by the key given.
+	byMeasurement := NewSamplingClient(r, func() float64 { return 0.5 }, func(p *Point) uint64 { return 1 })
+	r.batches = nil
+	bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0"})
+	bp.AddPoints(newSeriesPoints(100, 1))
+	byMeasurement.Write(bp)
+	if len(r.batches) > 1 || len(r.batches) == 1 && len(r.batches[0]) != 100 {
+		t.Errorf("unexpected sample of a single key: %v", r.batches)
+	}
+}
+
+func TestSamplingClient_Passthrough(t *testing.T) {
+	r := &batchRecorder{}
+	var calls int
+	c := NewSamplingClient(r, func() float64 { calls++; return 1 }, func(p *Point) uint64 {
+		t.Fatalf("unexpected key computed at rate 1")
+		return 0
+	})
+	bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0"})
+	bp.AddPoints(newTestPoints(3))
+	if err := c.Write(bp); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if calls != 1 || len(r.batches) != 1 || len(r.batches[0]) != 3 {
+		t.Errorf("unexpected write at rate 1.  expected the batch given, actual %v", r.batches)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() { c.Write(bp) })
+	if allocs > 1 {
+		t.Errorf("unexpected allocations at rate 1.  expected at most %v, actual %v", 1, allocs)
+	}
+}
+
+// samplingStats records the points sampled out along with the writes.
+type samplingStats struct {
+	recordingStats
+	mu         sync.Mutex
+	sampledOut []int
+}
+
+func (s *samplingStats) PointsSampledOut(points int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sampledOut = append(s.sampledOut, points)
+}
+
+func TestSamplingClient_Stats(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+	stats := &samplingStats{}
+	hc, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, Stats: stats})
+	c := NewSamplingClient(hc, func() float64 { return 0.5 }, nil)
+	defer c.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0"})
+	bp.AddPoints(newSeriesPoints(1000, 1))
+	if err := c.Write(bp); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if len(stats.sampledOut) != 1 || len(stats.writes) != 1 || stats.sampledOut[0]+stats.writes[0].points != 1000 {
+		t.Errorf("unexpected stats.  expected the points dropped and written to add up to %v, actual %v and %+v", 1000, stats.sampledOut, stats.writes)
+	}
+
+	// Nothing is written once every point is dropped.
+	c = NewSamplingClient(hc, func() float64 { return 0 }, nil)
+	if err := c.Write(bp); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if len(stats.sampledOut) != 2 || stats.sampledOut[1] != 1000 || len(stats.writes) != 1 {
+		t.Errorf("unexpected stats.  expected %v points dropped, actual %v and %+v", 1000, stats.sampledOut, stats.writes)
+	}
+}
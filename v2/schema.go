@@ -0,0 +1,576 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// SchemaPlan declares the databases, retention policies, continuous queries
+// and users a server should have, such as to set up a new environment. Apply
+// compares it with the server and runs only the statements for what is
+// missing or different, so that applying a plan twice changes nothing the
+// second time. The zero value is an empty plan.
+type SchemaPlan struct {
+	databases []string
+	rps       []schemaRP
+	cqs       []schemaCQ
+	users     []SchemaUser
+}
+
+type schemaRP struct {
+	db   string
+	spec RetentionPolicySpec
+}
+
+type schemaCQ struct {
+	db   string
+	spec CQSpec
+}
+
+// SchemaUser is a user of a SchemaPlan.
+type SchemaUser struct {
+	Name string
+
+	// Password is set when the user is created, and on an existing user
+	// only with the SetPasswords of the ApplyOptions, since the server does
+	// not tell it.
+	Password string
+
+	// Admin makes the user an admin, or revokes the admin rights of an
+	// existing one if not set.
+	Admin bool
+
+	// Grants are the privileges of the user on databases. The privileges
+	// on the databases left out are left as they are.
+	Grants []Grant
+}
+
+// Database adds the database name to p.
+func (p *SchemaPlan) Database(name string) *SchemaPlan {
+	p.databases = append(p.databases, name)
+	return p
+}
+
+// RetentionPolicy adds the retention policy spec of the database db to p. The
+// settings left zero are not compared with those of an existing policy, but
+// Duration, which stands for InfiniteDuration as when creating one. A policy
+// that is not Default is not made to stop being the default of db.
+func (p *SchemaPlan) RetentionPolicy(db string, spec RetentionPolicySpec) *SchemaPlan {
+	p.rps = append(p.rps, schemaRP{db: db, spec: spec})
+	return p
+}
+
+// ContinuousQuery adds the continuous query spec of the database db to p. A
+// continuous query cannot be altered: one that differs is dropped and created
+// again. It differs if its target or RESAMPLE durations do, or its SELECT
+// statement once the case, quoting and spacing of both are ignored. An INTO
+// database or retention policy left empty in spec is not compared.
+func (p *SchemaPlan) ContinuousQuery(db string, spec CQSpec) *SchemaPlan {
+	p.cqs = append(p.cqs, schemaCQ{db: db, spec: spec})
+	return p
+}
+
+// User adds the user u to p.
+func (p *SchemaPlan) User(u SchemaUser) *SchemaPlan {
+	p.users = append(p.users, u)
+	return p
+}
+
+// ApplyOptions are the options of SchemaPlan.Apply.
+type ApplyOptions struct {
+	// DryRun, if set, compares the plan with the server but runs nothing:
+	// the report holds the statements Apply would run.
+	DryRun bool
+
+	// SetPasswords sets the password of the users that exist already.
+	SetPasswords bool
+}
+
+// SchemaAction is what Apply did with an item of a SchemaPlan.
+type SchemaAction int
+
+const (
+	// SchemaSkipped is an item that is on the server as planned.
+	SchemaSkipped SchemaAction = iota
+
+	// SchemaCreated is an item that was missing and was created.
+	SchemaCreated
+
+	// SchemaUpdated is an item that differed and was changed.
+	SchemaUpdated
+
+	// SchemaFailed is the item whose statement failed. Apply stops there.
+	SchemaFailed
+)
+
+func (a SchemaAction) String() string {
+	switch a {
+	case SchemaSkipped:
+		return "skipped"
+	case SchemaCreated:
+		return "created"
+	case SchemaUpdated:
+		return "updated"
+	case SchemaFailed:
+		return "failed"
+	}
+	return fmt.Sprintf("SchemaAction(%d)", int(a))
+}
+
+// SchemaItem is the outcome of an item of a SchemaPlan.
+type SchemaItem struct {
+	// Kind is "database", "retention policy", "continuous query", "user"
+	// or "grant".
+	Kind string
+
+	// Database is the database of the item, empty for a database or a
+	// user, and Name its name, that of the user for a grant.
+	Database string
+	Name     string
+
+	Action SchemaAction
+
+	// Statements are those run for the item, or that would be with
+	// DryRun, with passwords redacted.
+	Statements []string
+
+	// Err is the error of a SchemaFailed item.
+	Err error
+}
+
+// ApplyReport is the outcome of SchemaPlan.Apply, with an item per database,
+// retention policy, continuous query, user and grant in the order they were
+// applied, up to the one that failed.
+type ApplyReport struct {
+	DryRun bool
+	Items  []SchemaItem
+}
+
+// Statements returns the statements of every item of r, in order.
+func (r ApplyReport) Statements() []string {
+	var stmts []string
+	for _, item := range r.Items {
+		stmts = append(stmts, item.Statements...)
+	}
+	return stmts
+}
+
+// Count returns the number of items of r whose action is a.
+func (r ApplyReport) Count(a SchemaAction) int {
+	var n int
+	for _, item := range r.Items {
+		if item.Action == a {
+			n++
+		}
+	}
+	return n
+}
+
+// Plan returns the statements Apply would run on the server of c, without
+// running them. It still queries the server to compare the plan with it.
+func (p *SchemaPlan) Plan(ctx context.Context, c Client) ([]string, error) {
+	r, err := p.Apply(ctx, c, ApplyOptions{DryRun: true})
+	return r.Statements(), err
+}
+
+// Apply runs the statements for the items of p that are missing on the
+// server of c or differ, in dependency order: databases, retention
+// policies, continuous queries, then users and their grants. The state of
+// the server is read with SHOW queries, and every item is reported. Apply
+// stops at the first statement that fails, and returns its error along with
+// the report up to that item, whose Action is SchemaFailed.
+func (p *SchemaPlan) Apply(ctx context.Context, c Client, opts ApplyOptions) (ApplyReport, error) {
+	a := &schemaApply{ctx: ctx, c: c, opts: opts, report: ApplyReport{DryRun: opts.DryRun}}
+	for _, step := range []func(*SchemaPlan) error{a.databases, a.retentionPolicies, a.continuousQueries, a.users} {
+		if err := step(p); err != nil {
+			return a.report, err
+		}
+	}
+	return a.report, nil
+}
+
+// schemaApply is a run of SchemaPlan.Apply.
+type schemaApply struct {
+	ctx    context.Context
+	c      Client
+	opts   ApplyOptions
+	report ApplyReport
+
+	// created are the databases created, or that would be with DryRun.
+	created map[string]bool
+}
+
+// run reports item with action, running the statements of exec with a
+// client that records them, and does not send them with DryRun. password,
+// if not empty, is redacted from the statements.
+func (a *schemaApply) run(item SchemaItem, action SchemaAction, password string, exec func(c Client) error) error {
+	rec := &schemaRecorder{Client: a.c, ctx: a.ctx, dryRun: a.opts.DryRun}
+	if password != "" {
+		rec.secret = QuoteString(password)
+	}
+	err := exec(rec)
+	item.Statements = rec.stmts
+	item.Action = action
+	if err != nil {
+		item.Action = SchemaFailed
+		item.Err = err
+		a.report.Items = append(a.report.Items, item)
+		if item.Database != "" {
+			return fmt.Errorf("%s %s on %s: %w", item.Kind, item.Name, item.Database, err)
+		}
+		return fmt.Errorf("%s %s: %w", item.Kind, item.Name, err)
+	}
+	a.report.Items = append(a.report.Items, item)
+	return nil
+}
+
+func (a *schemaApply) skip(item SchemaItem) {
+	item.Action = SchemaSkipped
+	a.report.Items = append(a.report.Items, item)
+}
+
+func (a *schemaApply) databases(p *SchemaPlan) error {
+	if len(p.databases) == 0 {
+		return nil
+	}
+	live, err := ShowDatabases(a.ctx, a.c)
+	if err != nil {
+		return err
+	}
+	exists := make(map[string]bool, len(live))
+	for _, db := range live {
+		exists[db] = true
+	}
+	a.created = make(map[string]bool)
+	for _, db := range p.databases {
+		item := SchemaItem{Kind: "database", Name: db}
+		if exists[db] || a.created[db] {
+			a.skip(item)
+			continue
+		}
+		if err := a.run(item, SchemaCreated, "", func(c Client) error {
+			return CreateDatabase(a.ctx, c, db, nil)
+		}); err != nil {
+			return err
+		}
+		a.created[db] = true
+	}
+	return nil
+}
+
+// missing reports whether the database db is known not to exist yet, which
+// only happens with DryRun: there is nothing on it to show.
+func (a *schemaApply) missing(db string) bool {
+	return a.opts.DryRun && a.created[db]
+}
+
+func (a *schemaApply) retentionPolicies(p *SchemaPlan) error {
+	live := make(map[string]map[string]RetentionPolicySpec)
+	for _, rp := range p.rps {
+		item := SchemaItem{Kind: "retention policy", Database: rp.db, Name: rp.spec.Name}
+		specs, ok := live[rp.db]
+		if !ok {
+			specs = make(map[string]RetentionPolicySpec)
+			if !a.missing(rp.db) {
+				shown, err := ShowRetentionPolicies(a.ctx, a.c, rp.db)
+				if err != nil {
+					return err
+				}
+				for _, spec := range shown {
+					specs[spec.Name] = spec
+				}
+			}
+			live[rp.db] = specs
+		}
+
+		cur, exists := specs[rp.spec.Name]
+		var err error
+		switch alter, changed := rpChanges(cur, rp.spec); {
+		case !exists:
+			err = a.run(item, SchemaCreated, "", func(c Client) error {
+				return CreateRetentionPolicy(a.ctx, c, rp.db, rp.spec)
+			})
+		case changed:
+			err = a.run(item, SchemaUpdated, "", func(c Client) error {
+				return AlterRetentionPolicy(a.ctx, c, rp.db, alter)
+			})
+		default:
+			a.skip(item)
+		}
+		if err != nil {
+			return err
+		}
+
+		// Keep track of the default for the policies that follow.
+		if rp.spec.Default {
+			for name, spec := range specs {
+				spec.Default = false
+				specs[name] = spec
+			}
+		}
+		specs[rp.spec.Name] = rpApplied(cur, rp.spec)
+	}
+	return nil
+}
+
+// rpChanges returns the settings of spec to alter on the live policy cur,
+// and whether there are any.
+func rpChanges(cur, spec RetentionPolicySpec) (RetentionPolicySpec, bool) {
+	alter := RetentionPolicySpec{Name: spec.Name}
+	d := spec.Duration
+	if d == 0 {
+		d = InfiniteDuration
+	}
+	if d != cur.Duration {
+		alter.Duration = d
+	}
+	if spec.ShardGroupDuration != 0 && spec.ShardGroupDuration != cur.ShardGroupDuration {
+		alter.ShardGroupDuration = spec.ShardGroupDuration
+	}
+	if spec.ReplicaN != 0 && spec.ReplicaN != cur.ReplicaN {
+		alter.ReplicaN = spec.ReplicaN
+	}
+	alter.Default = spec.Default && !cur.Default
+	return alter, alter != RetentionPolicySpec{Name: spec.Name}
+}
+
+// rpApplied returns the live policy cur once spec was applied to it.
+func rpApplied(cur, spec RetentionPolicySpec) RetentionPolicySpec {
+	alter, _ := rpChanges(cur, spec)
+	cur.Name = spec.Name
+	if alter.Duration != 0 {
+		cur.Duration = alter.Duration
+	}
+	if alter.ShardGroupDuration != 0 {
+		cur.ShardGroupDuration = alter.ShardGroupDuration
+	}
+	if alter.ReplicaN != 0 {
+		cur.ReplicaN = alter.ReplicaN
+	}
+	cur.Default = cur.Default || alter.Default
+	return cur
+}
+
+func (a *schemaApply) continuousQueries(p *SchemaPlan) error {
+	live := make(map[string]map[string]CQInfo)
+	for _, cq := range p.cqs {
+		item := SchemaItem{Kind: "continuous query", Database: cq.db, Name: cq.spec.Name}
+		infos, ok := live[cq.db]
+		if !ok {
+			infos = make(map[string]CQInfo)
+			if !a.missing(cq.db) {
+				shown, err := ShowContinuousQueries(a.ctx, a.c, cq.db)
+				if err != nil {
+					return err
+				}
+				for _, info := range shown {
+					infos[info.Name] = info
+				}
+			}
+			live[cq.db] = infos
+		}
+
+		cur, exists := infos[cq.spec.Name]
+		var err error
+		switch {
+		case !exists:
+			err = a.run(item, SchemaCreated, "", func(c Client) error {
+				return CreateContinuousQuery(a.ctx, c, cq.db, cq.spec)
+			})
+		case !sameCQ(cur.CQSpec, cq.spec):
+			err = a.run(item, SchemaUpdated, "", func(c Client) error {
+				if err := DropContinuousQuery(a.ctx, c, cq.db, cq.spec.Name); err != nil {
+					return err
+				}
+				return CreateContinuousQuery(a.ctx, c, cq.db, cq.spec)
+			})
+		default:
+			a.skip(item)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sameCQ reports whether the live continuous query cur is the one of spec,
+// see SchemaPlan.ContinuousQuery.
+func sameCQ(cur, spec CQSpec) bool {
+	if cur.Every != spec.Every || cur.For != spec.For {
+		return false
+	}
+	if findKeyword(spec.Select, "INTO") < 0 {
+		if spec.IntoDatabase == "" {
+			spec.IntoDatabase = cur.IntoDatabase
+		}
+		if spec.IntoRetentionPolicy == "" {
+			spec.IntoRetentionPolicy = cur.IntoRetentionPolicy
+		}
+	}
+	want, err := spec.selectInto()
+	if err != nil {
+		return false
+	}
+	got, err := cur.selectInto()
+	if err != nil {
+		return false
+	}
+	return normalizeStatement(want) == normalizeStatement(got)
+}
+
+// normalizeStatement returns stmt with the double quotes and the spaces that
+// do not separate words removed, and in lower case but for its string
+// literals, so that two statements the server takes the same way compare
+// equal.
+func normalizeStatement(stmt string) string {
+	var b strings.Builder
+	space := false
+	for i := 0; i < len(stmt); i++ {
+		ch := stmt[i]
+		switch {
+		case ch == '\'':
+			j := i + 1
+			for ; j < len(stmt) && stmt[j] != '\''; j++ {
+				if stmt[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(stmt) {
+				j = len(stmt) - 1
+			}
+			b.WriteString(stmt[i : j+1])
+			i = j
+			continue
+		case ch == '"':
+			continue
+		case isSpace(ch):
+			space = true
+			continue
+		}
+		if space && b.Len() > 0 && isWordByte(ch) && isWordByte(b.String()[b.Len()-1]) {
+			b.WriteByte(' ')
+		}
+		space = false
+		if 'A' <= ch && ch <= 'Z' {
+			ch += 'a' - 'A'
+		}
+		b.WriteByte(ch)
+	}
+	return b.String()
+}
+
+func (a *schemaApply) users(p *SchemaPlan) error {
+	if len(p.users) == 0 {
+		return nil
+	}
+	live, err := ShowUsers(a.ctx, a.c)
+	if err != nil {
+		return err
+	}
+	admins := make(map[string]bool, len(live))
+	for _, u := range live {
+		admins[u.Name] = u.Admin
+	}
+
+	for _, u := range p.users {
+		item := SchemaItem{Kind: "user", Name: u.Name}
+		admin, exists := admins[u.Name]
+		var err error
+		switch {
+		case !exists:
+			err = a.run(item, SchemaCreated, u.Password, func(c Client) error {
+				return CreateUser(a.ctx, c, u.Name, u.Password, u.Admin)
+			})
+		case admin != u.Admin || a.opts.SetPasswords:
+			err = a.run(item, SchemaUpdated, u.Password, func(c Client) error {
+				if a.opts.SetPasswords {
+					if err := SetUserPassword(a.ctx, c, u.Name, u.Password); err != nil {
+						return err
+					}
+				}
+				if admin == u.Admin {
+					return nil
+				}
+				if u.Admin {
+					return GrantPrivilege(a.ctx, c, u.Name, "", PrivilegeAll)
+				}
+				return RevokePrivilege(a.ctx, c, u.Name, "", PrivilegeAll)
+			})
+		default:
+			a.skip(item)
+		}
+		if err != nil {
+			return err
+		}
+		admins[u.Name] = u.Admin
+
+		if err := a.grants(u, exists); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// grants applies the grants of u, an existing user if exists is set.
+func (a *schemaApply) grants(u SchemaUser, exists bool) error {
+	if len(u.Grants) == 0 {
+		return nil
+	}
+	live := make(map[string]Privilege)
+	if exists || !a.opts.DryRun {
+		shown, err := ShowGrants(a.ctx, a.c, u.Name)
+		if err != nil {
+			return err
+		}
+		for _, g := range shown {
+			live[g.Database] = g.Privilege
+		}
+	}
+	for _, g := range u.Grants {
+		item := SchemaItem{Kind: "grant", Database: g.Database, Name: u.Name}
+		cur, ok := live[g.Database]
+		if cur == g.Privilege {
+			a.skip(item)
+			continue
+		}
+		action := SchemaCreated
+		if ok && cur != PrivilegeNone {
+			action = SchemaUpdated
+		}
+		if err := a.run(item, action, "", func(c Client) error {
+			if g.Privilege == PrivilegeNone {
+				return RevokePrivilege(a.ctx, c, u.Name, g.Database, cur)
+			}
+			return GrantPrivilege(a.ctx, c, u.Name, g.Database, g.Privilege)
+		}); err != nil {
+			return err
+		}
+		live[g.Database] = g.Privilege
+	}
+	return nil
+}
+
+// schemaRecorder is the Client the statements of an item of a SchemaPlan are
+// run with, bound to ctx. It records them, and answers them itself with
+// DryRun.
+type schemaRecorder struct {
+	Client
+	ctx    context.Context
+	dryRun bool
+	secret string
+	stmts  []string
+}
+
+func (r *schemaRecorder) Query(q Query) (*Response, error) {
+	stmt := q.Command
+	if r.secret != "" {
+		stmt = strings.ReplaceAll(stmt, r.secret, redactedPassword)
+	}
+	r.stmts = append(r.stmts, stmt)
+	if r.dryRun {
+		return &Response{Results: []Result{{}}}, nil
+	}
+	return queryContext(r.ctx, r.Client, q)
+}
@@ -0,0 +1,316 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+// schemaServer is a Client that keeps the schema the statements it is sent
+// set up, and shows it as the server does, with the continuous queries
+// unquoted.
+type schemaServer struct {
+	batchRecorder
+	dbs   map[string]*schemaDB
+	users map[string]*schemaServerUser
+
+	// executed are the statements other than SHOW queries, and fail the
+	// error of the statements starting with one of its keys.
+	executed []string
+	fail     map[string]string
+}
+
+type schemaDB struct {
+	rps map[string]RetentionPolicySpec
+	cqs map[string]string
+}
+
+type schemaServerUser struct {
+	admin  bool
+	grants map[string]Privilege
+}
+
+func newSchemaServer() *schemaServer {
+	return &schemaServer{dbs: make(map[string]*schemaDB), users: make(map[string]*schemaServerUser)}
+}
+
+var schemaStatements = []struct {
+	re   *regexp.Regexp
+	exec func(s *schemaServer, m []string) ([]models.Row, string)
+}{
+	{regexp.MustCompile(`^SHOW DATABASES$`), func(s *schemaServer, m []string) ([]models.Row, string) {
+		row := models.Row{Name: "databases", Columns: []string{"name"}}
+		for _, name := range sortedKeys(s.dbs) {
+			row.Values = append(row.Values, []interface{}{name})
+		}
+		return []models.Row{row}, ""
+	}},
+	{regexp.MustCompile(`^SHOW RETENTION POLICIES ON "(.*)"$`), func(s *schemaServer, m []string) ([]models.Row, string) {
+		db, ok := s.dbs[m[1]]
+		if !ok {
+			return nil, "database not found: " + m[1]
+		}
+		row := models.Row{Columns: []string{"name", "duration", "shardGroupDuration", "replicaN", "default"}}
+		for _, name := range sortedKeys(db.rps) {
+			rp := db.rps[name]
+			d := rp.Duration
+			if d == InfiniteDuration {
+				d = 0
+			}
+			row.Values = append(row.Values, []interface{}{name, d.String(), rp.ShardGroupDuration.String(), float64(rp.ReplicaN), rp.Default})
+		}
+		return []models.Row{row}, ""
+	}},
+	{regexp.MustCompile(`^SHOW CONTINUOUS QUERIES$`), func(s *schemaServer, m []string) ([]models.Row, string) {
+		var rows []models.Row
+		for _, name := range sortedKeys(s.dbs) {
+			row := models.Row{Name: name, Columns: []string{"name", "query"}}
+			for _, cq := range sortedKeys(s.dbs[name].cqs) {
+				row.Values = append(row.Values, []interface{}{cq, s.dbs[name].cqs[cq]})
+			}
+			rows = append(rows, row)
+		}
+		return rows, ""
+	}},
+	{regexp.MustCompile(`^SHOW USERS$`), func(s *schemaServer, m []string) ([]models.Row, string) {
+		row := models.Row{Columns: []string{"user", "admin"}}
+		for _, name := range sortedKeys(s.users) {
+			row.Values = append(row.Values, []interface{}{name, s.users[name].admin})
+		}
+		return []models.Row{row}, ""
+	}},
+	{regexp.MustCompile(`^SHOW GRANTS FOR "(.*)"$`), func(s *schemaServer, m []string) ([]models.Row, string) {
+		u, ok := s.users[m[1]]
+		if !ok {
+			return nil, "user not found"
+		}
+		row := models.Row{Columns: []string{"database", "privilege"}}
+		for _, db := range sortedKeys(u.grants) {
+			row.Values = append(row.Values, []interface{}{db, u.grants[db].String()})
+		}
+		return []models.Row{row}, ""
+	}},
+	{regexp.MustCompile(`^CREATE DATABASE "(.*)"$`), func(s *schemaServer, m []string) ([]models.Row, string) {
+		if _, ok := s.dbs[m[1]]; !ok {
+			s.dbs[m[1]] = &schemaDB{
+				rps: map[string]RetentionPolicySpec{"autogen": {Name: "autogen", Duration: InfiniteDuration, ShardGroupDuration: 7 * 24 * time.Hour, ReplicaN: 1, Default: true}},
+				cqs: make(map[string]string),
+			}
+		}
+		return nil, ""
+	}},
+	{regexp.MustCompile(`^(CREATE|ALTER) RETENTION POLICY "(.*?)" ON "(.*?)"(?: DURATION (\S+))?(?: REPLICATION (\d+))?(?: SHARD DURATION (\S+))?( DEFAULT)?$`), func(s *schemaServer, m []string) ([]models.Row, string) {
+		db := s.dbs[m[3]]
+		rp, ok := db.rps[m[2]]
+		if ok == (m[1] == "CREATE") {
+			return nil, "retention policy already exists"
+		}
+		rp.Name = m[2]
+		if m[4] != "" {
+			rp.Duration, _ = parseDuration(m[4])
+		}
+		if m[5] != "" {
+			rp.ReplicaN, _ = strconv.Atoi(m[5])
+		}
+		if m[6] != "" {
+			rp.ShardGroupDuration, _ = parseDuration(m[6])
+		} else if rp.ShardGroupDuration == 0 {
+			rp.ShardGroupDuration = 24 * time.Hour
+		}
+		if m[7] != "" {
+			for name, other := range db.rps {
+				other.Default = false
+				db.rps[name] = other
+			}
+			rp.Default = true
+		}
+		db.rps[m[2]] = rp
+		return nil, ""
+	}},
+	{regexp.MustCompile(`^CREATE CONTINUOUS QUERY "(.*?)" ON "(.*?)" .*$`), func(s *schemaServer, m []string) ([]models.Row, string) {
+		cqs := s.dbs[m[2]].cqs
+		if _, ok := cqs[m[1]]; ok {
+			return nil, "continuous query already exists"
+		}
+		cqs[m[1]] = strings.ReplaceAll(m[0], `"`, "")
+		return nil, ""
+	}},
+	{regexp.MustCompile(`^DROP CONTINUOUS QUERY "(.*?)" ON "(.*?)"$`), func(s *schemaServer, m []string) ([]models.Row, string) {
+		delete(s.dbs[m[2]].cqs, m[1])
+		return nil, ""
+	}},
+	{regexp.MustCompile(`^CREATE USER "(.*?)" WITH PASSWORD '.*'( WITH ALL PRIVILEGES)?$`), func(s *schemaServer, m []string) ([]models.Row, string) {
+		s.users[m[1]] = &schemaServerUser{admin: m[2] != "", grants: make(map[string]Privilege)}
+		return nil, ""
+	}},
+	{regexp.MustCompile(`^(GRANT|REVOKE) (READ|WRITE|ALL) ON "(.*?)" (?:TO|FROM) "(.*)"$`), func(s *schemaServer, m []string) ([]models.Row, string) {
+		p := map[string]Privilege{"READ": PrivilegeRead, "WRITE": PrivilegeWrite, "ALL": PrivilegeAll}[m[2]]
+		if m[1] == "REVOKE" {
+			p = PrivilegeNone
+		}
+		s.users[m[4]].grants[m[3]] = p
+		return nil, ""
+	}},
+	{regexp.MustCompile(`^(GRANT|REVOKE) ALL PRIVILEGES (?:TO|FROM) "(.*)"$`), func(s *schemaServer, m []string) ([]models.Row, string) {
+		s.users[m[2]].admin = m[1] == "GRANT"
+		return nil, ""
+	}},
+}
+
+func (s *schemaServer) Query(q Query) (*Response, error) {
+	stmt := q.Command
+	if !strings.HasPrefix(stmt, "SHOW") {
+		s.executed = append(s.executed, stmt)
+	}
+	for prefix, msg := range s.fail {
+		if strings.HasPrefix(stmt, prefix) {
+			return &Response{Results: []Result{{Err: msg}}}, nil
+		}
+	}
+	for _, st := range schemaStatements {
+		if m := st.re.FindStringSubmatch(stmt); m != nil {
+			rows, msg := st.exec(s, m)
+			return &Response{Results: []Result{{Series: rows, Err: msg}}}, nil
+		}
+	}
+	return &Response{Results: []Result{{Err: "unexpected statement " + stmt}}}, nil
+}
+
+// sortedKeys returns the keys of the map m, sorted.
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	for _, k := range reflect.ValueOf(m).MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func testSchemaPlan() *SchemaPlan {
+	var p SchemaPlan
+	p.Database("metrics").
+		RetentionPolicy("metrics", RetentionPolicySpec{Name: "raw", Duration: 7 * 24 * time.Hour, Default: true}).
+		RetentionPolicy("metrics", RetentionPolicySpec{Name: "downsampled", Duration: 52 * 7 * 24 * time.Hour, ShardGroupDuration: 7 * 24 * time.Hour})
+	for _, field := range []string{"cpu", "mem", "disk"} {
+		p.ContinuousQuery("metrics", CQSpec{
+			Name:                field + "_1h",
+			Select:              `SELECT mean("value") AS "value" FROM "raw"."` + field + `" GROUP BY time(1h), *`,
+			IntoRetentionPolicy: "downsampled",
+			IntoMeasurement:     field,
+		})
+	}
+	p.User(SchemaUser{Name: "reader", Password: "s3cr'et", Grants: []Grant{{Database: "metrics", Privilege: PrivilegeRead}}})
+	return &p
+}
+
+func TestSchemaPlan_Apply(t *testing.T) {
+	ctx := context.Background()
+	s := newSchemaServer()
+	p := testSchemaPlan()
+
+	// Planning reads the server but changes nothing.
+	stmts, err := p.Plan(ctx, s)
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	exp := []string{
+		`CREATE DATABASE "metrics"`,
+		`CREATE RETENTION POLICY "raw" ON "metrics" DURATION 1w REPLICATION 1 DEFAULT`,
+		`CREATE RETENTION POLICY "downsampled" ON "metrics" DURATION 52w REPLICATION 1 SHARD DURATION 1w`,
+		`CREATE CONTINUOUS QUERY "cpu_1h" ON "metrics" BEGIN SELECT mean("value") AS "value" INTO "downsampled"."cpu" FROM "raw"."cpu" GROUP BY time(1h), * END`,
+		`CREATE CONTINUOUS QUERY "mem_1h" ON "metrics" BEGIN SELECT mean("value") AS "value" INTO "downsampled"."mem" FROM "raw"."mem" GROUP BY time(1h), * END`,
+		`CREATE CONTINUOUS QUERY "disk_1h" ON "metrics" BEGIN SELECT mean("value") AS "value" INTO "downsampled"."disk" FROM "raw"."disk" GROUP BY time(1h), * END`,
+		`CREATE USER "reader" WITH PASSWORD '[REDACTED]'`,
+		`GRANT READ ON "metrics" TO "reader"`,
+	}
+	if !reflect.DeepEqual(stmts, exp) {
+		t.Errorf("unexpected statements.\nexpected %q\nactual   %q", exp, stmts)
+	}
+	if len(s.executed) != 0 {
+		t.Fatalf("unexpected statements executed by Plan: %q", s.executed)
+	}
+
+	report, err := p.Apply(ctx, s, ApplyOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if n := report.Count(SchemaCreated); n != len(report.Items) || n != 8 {
+		t.Errorf("unexpected report.  expected %v items created, actual %+v", 8, report.Items)
+	}
+	if !reflect.DeepEqual(report.Statements(), exp) || len(s.executed) != len(exp) {
+		t.Errorf("unexpected statements.\nexpected %q\nactual   %q", exp, s.executed)
+	}
+
+	// Applying the plan again is a no-op.
+	s.executed = nil
+	report, err = p.Apply(ctx, s, ApplyOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if n := report.Count(SchemaSkipped); n != len(report.Items) || n != 8 || len(s.executed) != 0 {
+		t.Errorf("unexpected changes on the second run %+v, statements %q", report.Items, s.executed)
+	}
+
+	// Only what changed is applied.
+	p.rps[1].spec.Duration = 104 * 7 * 24 * time.Hour
+	p.cqs[2].spec.Select = `SELECT max("value") AS "value" FROM "raw"."disk" GROUP BY time(1h), *`
+	p.users[0].Admin = true
+	report, err = p.Apply(ctx, s, ApplyOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	exp = []string{
+		`ALTER RETENTION POLICY "downsampled" ON "metrics" DURATION 104w`,
+		`DROP CONTINUOUS QUERY "disk_1h" ON "metrics"`,
+		`CREATE CONTINUOUS QUERY "disk_1h" ON "metrics" BEGIN SELECT max("value") AS "value" INTO "downsampled"."disk" FROM "raw"."disk" GROUP BY time(1h), * END`,
+		`GRANT ALL PRIVILEGES TO "reader"`,
+	}
+	if report.Count(SchemaUpdated) != 3 || !reflect.DeepEqual(s.executed, exp) {
+		t.Errorf("unexpected changes %+v.\nexpected %q\nactual   %q", report.Items, exp, s.executed)
+	}
+}
+
+func TestSchemaPlan_ApplyFailure(t *testing.T) {
+	s := newSchemaServer()
+	s.fail = map[string]string{`CREATE CONTINUOUS QUERY "mem_1h"`: "invalid query"}
+
+	report, err := testSchemaPlan().Apply(context.Background(), s, ApplyOptions{})
+	var se *StatementError
+	if !errors.As(err, &se) || se.Message != "invalid query" {
+		t.Fatalf("unexpected error.  expected %T, actual %v", se, err)
+	}
+	if prefix := "continuous query mem_1h on metrics: "; !strings.HasPrefix(err.Error(), prefix) {
+		t.Errorf("unexpected error message.  expected it to start with %q, actual %v", prefix, err)
+	}
+	last := report.Items[len(report.Items)-1]
+	if len(report.Items) != 5 || report.Count(SchemaCreated) != 4 || last.Action != SchemaFailed || last.Name != "mem_1h" || last.Err == nil {
+		t.Errorf("unexpected report %+v", report.Items)
+	}
+
+	// The next run picks up where the failed one stopped.
+	s.fail = nil
+	s.executed = nil
+	report, err = testSchemaPlan().Apply(context.Background(), s, ApplyOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if report.Count(SchemaSkipped) != 4 || report.Count(SchemaCreated) != 4 || len(s.executed) != 4 {
+		t.Errorf("unexpected report %+v, statements %q", report.Items, s.executed)
+	}
+}
+
+func TestNormalizeStatement(t *testing.T) {
+	a := normalizeStatement(`SELECT  mean("value") AS "v" INTO "rp"."m" FROM "cpu" WHERE host = 'A "b"' GROUP BY time(1h)`)
+	b := normalizeStatement(`select mean(value) as v into rp.m from cpu where host = 'A "b"' group by time( 1h )`)
+	if a != b {
+		t.Errorf("unexpected normalized statements.  expected %q, actual %q", a, b)
+	}
+}
@@ -0,0 +1,288 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ScriptOptions sets how ExecuteScript runs a script.
+type ScriptOptions struct {
+	// Database is the database the statements are run against, as the
+	// Database of a Query.
+	Database string
+
+	// ContinueOnError makes ExecuteScript run the statements following one
+	// that failed. It stops at the first failed statement otherwise.
+	ContinueOnError bool
+
+	// DryRun makes ExecuteScript parse the script and return its
+	// statements without running them.
+	DryRun bool
+
+	// Variables are substituted for the ${NAME} placeholders of the
+	// script. In a string literal the value is escaped as a string, in a
+	// double quoted identifier as an identifier and in a regular
+	// expression as one; elsewhere it is inserted as is. A placeholder
+	// whose variable is not set is an error.
+	Variables map[string]string
+}
+
+// ScriptStatement is a statement of a script run by ExecuteScript.
+type ScriptStatement struct {
+	// Line is the line of the script the statement starts on, counted from
+	// 1.
+	Line int
+
+	// Statement is the statement, with its variables substituted and its
+	// comments removed.
+	Statement string
+
+	// Executed is whether the statement was run.
+	Executed bool
+
+	// Err is the error of the statement, nil if it succeeded or was not
+	// run.
+	Err error
+}
+
+// ScriptReport is the outcome of ExecuteScript.
+type ScriptReport struct {
+	// Statements are the statements of the script, in order.
+	Statements []ScriptStatement
+
+	// Failed is the number of statements that failed.
+	Failed int
+
+	// Duration is how long the script took.
+	Duration time.Duration
+}
+
+// ScriptError is returned by ExecuteScript for a script that cannot be
+// parsed, and for the first statement of a script that failed.
+type ScriptError struct {
+	// Line is the line of the statement, or of the syntax error, counted
+	// from 1.
+	Line int
+
+	// Statement is the statement that failed, empty for a syntax error.
+	Statement string
+
+	Err error
+}
+
+func (e *ScriptError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *ScriptError) Unwrap() error { return e.Err }
+
+// ExecuteScript runs the InfluxQL statements of the script read from r one
+// after the other, as a migration tool would. The statements are separated
+// by semicolons outside of quoted strings, identifiers and regular
+// expressions, and the comments starting with --, or with # as in the files
+// of the influx CLI, are removed, along with /* */ blocks.
+//
+// The script is parsed before any statement is run: a syntax error, such as
+// an unterminated string, or an unset variable is returned as a
+// *ScriptError without running anything. The error of a failed statement is
+// in its ScriptStatement, the first one is also returned as a *ScriptError.
+func ExecuteScript(ctx context.Context, c Client, r io.Reader, opts ScriptOptions) (ScriptReport, error) {
+	start := time.Now()
+	script, err := ioutil.ReadAll(r)
+	if err != nil {
+		return ScriptReport{}, err
+	}
+	statements, err := parseScript(string(script), opts.Variables)
+	if err != nil {
+		return ScriptReport{}, err
+	}
+	report := ScriptReport{Statements: statements}
+	if opts.DryRun {
+		report.Duration = time.Since(start)
+		return report, nil
+	}
+
+	var first error
+	for i := range report.Statements {
+		if err := ctx.Err(); err != nil {
+			report.Duration = time.Since(start)
+			return report, err
+		}
+		s := &report.Statements[i]
+		_, s.Err = queryStatement(ctx, c, opts.Database, s.Statement)
+		s.Executed = true
+		if s.Err == nil {
+			continue
+		}
+		report.Failed++
+		if first == nil {
+			first = &ScriptError{Line: s.Line, Statement: s.Statement, Err: s.Err}
+		}
+		if !opts.ContinueOnError {
+			break
+		}
+	}
+	report.Duration = time.Since(start)
+	return report, first
+}
+
+// scriptParser splits a script into statements.
+type scriptParser struct {
+	script string
+	vars   map[string]string
+
+	// i is the offset in the script and line its line.
+	i    int
+	line int
+
+	// stmt is the statement being read, which started on startLine.
+	stmt       strings.Builder
+	startLine  int
+	statements []ScriptStatement
+}
+
+func parseScript(script string, vars map[string]string) ([]ScriptStatement, error) {
+	p := &scriptParser{script: script, vars: vars, line: 1}
+	for p.i < len(p.script) {
+		s := p.script[p.i:]
+		switch c := s[0]; {
+		case c == ';':
+			p.end()
+			p.i++
+		case strings.HasPrefix(s, "--") || c == '#':
+			if n := strings.IndexByte(s, '\n'); n >= 0 {
+				p.i += n
+			} else {
+				p.i = len(p.script)
+			}
+		case strings.HasPrefix(s, "/*"):
+			n := strings.Index(s[2:], "*/")
+			if n < 0 {
+				return nil, &ScriptError{Line: p.line, Err: errors.New("unterminated comment")}
+			}
+			p.line += strings.Count(s[:n+4], "\n")
+			p.stmt.WriteByte(' ')
+			p.i += n + 4
+		case c == '\'' || c == '"' || c == '/' && p.afterRegexOperator():
+			if err := p.quoted(c); err != nil {
+				return nil, err
+			}
+		case strings.HasPrefix(s, "${"):
+			v, err := p.variable()
+			if err != nil {
+				return nil, err
+			}
+			p.start()
+			p.stmt.WriteString(v)
+		default:
+			if c == '\n' {
+				p.line++
+			} else if !unicode.IsSpace(rune(c)) {
+				p.start()
+			}
+			p.stmt.WriteByte(c)
+			p.i++
+		}
+	}
+	p.end()
+	return p.statements, nil
+}
+
+// start records the line of the statement being read, at its first
+// character.
+func (p *scriptParser) start() {
+	if p.startLine == 0 {
+		p.startLine = p.line
+	}
+}
+
+// end ends the statement being read, leaving it out if it is empty.
+func (p *scriptParser) end() {
+	if stmt := strings.TrimSpace(p.stmt.String()); stmt != "" {
+		p.statements = append(p.statements, ScriptStatement{Line: p.startLine, Statement: stmt})
+	}
+	p.stmt.Reset()
+	p.startLine = 0
+}
+
+// afterRegexOperator reports whether the statement being read ends with =~
+// or !~, so that a slash starts a regular expression rather than being a
+// division.
+func (p *scriptParser) afterRegexOperator() bool {
+	s := strings.TrimRightFunc(p.stmt.String(), unicode.IsSpace)
+	return strings.HasSuffix(s, "=~") || strings.HasSuffix(s, "!~")
+}
+
+// quoted reads the string, identifier or regular expression delimited by q
+// at p.i, substituting its variables escaped for it.
+func (p *scriptParser) quoted(q byte) error {
+	line := p.line
+	p.start()
+	p.stmt.WriteByte(q)
+	for p.i++; p.i < len(p.script); {
+		s := p.script[p.i:]
+		switch c := s[0]; {
+		case c == '\\' && len(s) > 1:
+			if s[1] == '\n' {
+				p.line++
+			}
+			p.stmt.WriteString(s[:2])
+			p.i += 2
+		case c == q:
+			p.stmt.WriteByte(q)
+			p.i++
+			return nil
+		case strings.HasPrefix(s, "${"):
+			v, err := p.variable()
+			if err != nil {
+				return err
+			}
+			switch q {
+			case '\'':
+				v = stringReplacer.Replace(v)
+			case '"':
+				v = identReplacer.Replace(v)
+			default:
+				v = strings.ReplaceAll(v, "/", `\/`)
+			}
+			p.stmt.WriteString(v)
+		default:
+			if c == '\n' {
+				p.line++
+			}
+			p.stmt.WriteByte(c)
+			p.i++
+		}
+	}
+	kind := "string"
+	switch q {
+	case '"':
+		kind = "identifier"
+	case '/':
+		kind = "regular expression"
+	}
+	return &ScriptError{Line: line, Err: fmt.Errorf("unterminated %s", kind)}
+}
+
+// variable returns the value of the ${NAME} placeholder at p.i, and moves
+// past it.
+func (p *scriptParser) variable() (string, error) {
+	s := p.script[p.i:]
+	n := strings.IndexByte(s, '}')
+	if n < 0 || strings.Contains(s[2:n], "\n") {
+		return "", &ScriptError{Line: p.line, Err: errors.New("unterminated ${ placeholder")}
+	}
+	name := s[2:n]
+	v, ok := p.vars[name]
+	if !ok {
+		return "", &ScriptError{Line: p.line, Err: fmt.Errorf("variable %q is not set", name)}
+	}
+	p.i += n + 1
+	return v, nil
+}
@@ -0,0 +1,121 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const testScript = `-- Creates the schema.
+CREATE DATABASE "db0";
+# From the influx CLI.
+CREATE RETENTION POLICY "${RP}" ON "db0" DURATION ${DURATION} REPLICATION 1;
+
+/* A statement
+   over lines */ SELECT * FROM "cpu"
+WHERE "host" = '${HOST}' AND "dc" =~ /^${DC}$/ -- inline
+  AND "note" = 'a; b -- # not comments';
+
+DROP SERIES FROM "disk"; DROP SERIES FROM "mem"
+`
+
+func TestExecuteScript_Parse(t *testing.T) {
+	report, err := ExecuteScript(context.Background(), nil, strings.NewReader(testScript), ScriptOptions{
+		DryRun: true,
+		Variables: map[string]string{
+			"RP":       `one "week"`,
+			"DURATION": "7d",
+			"HOST":     `o'brien`,
+			"DC":       "eu/west",
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	exp := []ScriptStatement{
+		{Line: 2, Statement: `CREATE DATABASE "db0"`},
+		{Line: 4, Statement: `CREATE RETENTION POLICY "one \"week\"" ON "db0" DURATION 7d REPLICATION 1`},
+		{Line: 7, Statement: "SELECT * FROM \"cpu\"\nWHERE \"host\" = 'o\\'brien' AND \"dc\" =~ /^eu\\/west$/ \n  AND \"note\" = 'a; b -- # not comments'"},
+		{Line: 11, Statement: `DROP SERIES FROM "disk"`},
+		{Line: 11, Statement: `DROP SERIES FROM "mem"`},
+	}
+	if len(report.Statements) != len(exp) {
+		t.Fatalf("unexpected statements.  expected %d, actual %+v", len(exp), report.Statements)
+	}
+	for i := range exp {
+		if report.Statements[i] != exp[i] {
+			t.Errorf("unexpected statement %d.\nexpected %+v\nactual   %+v", i, exp[i], report.Statements[i])
+		}
+	}
+}
+
+func TestExecuteScript_SyntaxError(t *testing.T) {
+	for _, tt := range []struct {
+		script string
+		line   int
+		exp    string
+	}{
+		{script: "SHOW DATABASES;\nSELECT * FROM cpu WHERE host = 'a;\nSHOW USERS", line: 2, exp: "unterminated string"},
+		{script: "DROP MEASUREMENT \"cpu", line: 1, exp: "unterminated identifier"},
+		{script: "SHOW DATABASES\n/* left open", line: 2, exp: "unterminated comment"},
+		{script: "\n\nSHOW USERS WHERE ${NAME}", line: 3, exp: `variable "NAME" is not set`},
+		{script: "SHOW USERS WHERE ${NAME", line: 1, exp: "unterminated ${ placeholder"},
+	} {
+		ts, statements := newStatementServer(t, nil)
+		c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+		_, err := ExecuteScript(context.Background(), c, strings.NewReader(tt.script), ScriptOptions{})
+		var se *ScriptError
+		if !errors.As(err, &se) || se.Line != tt.line || se.Err.Error() != tt.exp {
+			t.Errorf("unexpected error for %q.  expected line %d: %s, actual %v", tt.script, tt.line, tt.exp, err)
+		}
+		if len(*statements) != 0 {
+			t.Errorf("unexpected statements run: %q", *statements)
+		}
+		c.Close()
+		ts.Close()
+	}
+}
+
+func TestExecuteScript_Errors(t *testing.T) {
+	const script = "CREATE DATABASE a;\nCREATE DATABASE b;\nCREATE DATABASE c;\nCREATE DATABASE d"
+	ts, statements := newStatementServer(t, func(q string) string {
+		if q == "CREATE DATABASE b" || q == "CREATE DATABASE d" {
+			return "database already exists"
+		}
+		return ""
+	})
+	defer ts.Close()
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	// The script stops at the first error by default.
+	report, err := ExecuteScript(context.Background(), c, strings.NewReader(script), ScriptOptions{Database: "db0"})
+	var se *ScriptError
+	if !errors.As(err, &se) || se.Line != 2 || se.Statement != "CREATE DATABASE b" || !errors.Is(err, ErrDatabaseExists) {
+		t.Fatalf("unexpected error.  expected line 2: %v, actual %v", ErrDatabaseExists, err)
+	}
+	if len(*statements) != 2 || report.Failed != 1 || !report.Statements[1].Executed || report.Statements[2].Executed {
+		t.Errorf("unexpected report: %+v, statements run %q", report, *statements)
+	}
+	if report.Statements[0].Err != nil || !errors.Is(report.Statements[1].Err, ErrDatabaseExists) {
+		t.Errorf("unexpected statement errors: %+v", report.Statements)
+	}
+
+	*statements = nil
+	report, err = ExecuteScript(context.Background(), c, strings.NewReader(script), ScriptOptions{ContinueOnError: true})
+	if !errors.As(err, &se) || se.Line != 2 {
+		t.Errorf("unexpected error.  expected the error of line 2, actual %v", err)
+	}
+	if len(*statements) != 4 || report.Failed != 2 || report.Statements[3].Err == nil || report.Statements[2].Err != nil {
+		t.Errorf("unexpected report: %+v, statements run %q", report, *statements)
+	}
+
+	// A canceled context stops the script before the next statement.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	*statements = nil
+	if _, err := ExecuteScript(ctx, c, strings.NewReader(script), ScriptOptions{}); err != context.Canceled || len(*statements) != 0 {
+		t.Errorf("unexpected error.  expected %v, actual %v", context.Canceled, err)
+	}
+}
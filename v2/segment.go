@@ -0,0 +1,239 @@
+package client
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// segmentExt is the extension of the segment files of a diskQueue.
+const segmentExt = ".seg"
+
+// recordHeaderSize is the size of the header of every record of a segment: the
+// length of the payload and its CRC-32, both big-endian uint32.
+const recordHeaderSize = 8
+
+// errQueueClosed is returned when appending to a closed diskQueue.
+var errQueueClosed = errors.New("buffer is closed")
+
+// diskQueue is a queue of records kept in segment files of a directory, named
+// after their sequence number so that they sort in order. Records are only
+// appended to the last segment, the active one, and a segment is removed as a
+// whole once it was consumed. A record that was only partially written, as
+// when the process died while appending it, ends the segment.
+type diskQueue struct {
+	dir         string
+	maxBytes    int64
+	segmentSize int64
+
+	mu       sync.Mutex
+	segments []*segment
+	active   *os.File
+	size     int64
+	nextSeq  uint64
+	closed   bool
+}
+
+type segment struct {
+	seq  uint64
+	path string
+	size int64
+
+	// offset is where the consumer stopped reading, so that it goes on
+	// from there. It is not persisted, the segment is read again from the
+	// start after a restart.
+	offset int
+}
+
+// openDiskQueue opens the queue in dir, creating dir if needed. The segments
+// found there are kept, but never appended to again.
+func openDiskQueue(dir string, maxBytes, segmentSize int64) (*diskQueue, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &diskQueue{dir: dir, maxBytes: maxBytes, segmentSize: segmentSize}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		q.segments = append(q.segments, &segment{seq: seq, path: filepath.Join(dir, name), size: info.Size()})
+		q.size += info.Size()
+		if seq >= q.nextSeq {
+			q.nextSeq = seq + 1
+		}
+	}
+	sort.Slice(q.segments, func(i, j int) bool { return q.segments[i].seq < q.segments[j].seq })
+	return q, nil
+}
+
+// append adds a record with payload to the queue, and removes the oldest
+// segments if the queue grew larger than maxBytes. It returns the number of
+// segments removed.
+func (q *diskQueue) append(payload []byte) (evicted int, err error) {
+	rec := make([]byte, recordHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(rec[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(rec[4:8], crc32.ChecksumIEEE(payload))
+	copy(rec[recordHeaderSize:], payload)
+	if int64(len(rec)) > q.maxBytes {
+		return 0, fmt.Errorf("write of %d bytes exceeds the maximum disk usage of %d bytes", len(rec), q.maxBytes)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return 0, errQueueClosed
+	}
+
+	last := q.last()
+	if q.active == nil || (last.size > 0 && last.size+int64(len(rec)) > q.segmentSize) {
+		if err := q.create(); err != nil {
+			return 0, err
+		}
+		last = q.last()
+	}
+
+	n, err := q.active.Write(rec)
+	if err == nil {
+		err = q.active.Sync()
+	}
+	last.size += int64(n)
+	q.size += int64(n)
+	if err != nil {
+		// Records appended after a partial one could not be read, so
+		// start a new segment for the next one.
+		q.seal()
+		return 0, err
+	}
+
+	for q.size > q.maxBytes && len(q.segments) > 1 {
+		oldest := q.segments[0]
+		if err := os.Remove(oldest.path); err != nil && !os.IsNotExist(err) {
+			return evicted, err
+		}
+		q.segments = q.segments[1:]
+		q.size -= oldest.size
+		evicted++
+	}
+	return evicted, nil
+}
+
+// create seals the active segment and opens a new one. It must be called
+// with mu held.
+func (q *diskQueue) create() error {
+	q.seal()
+	seg := &segment{seq: q.nextSeq, path: filepath.Join(q.dir, fmt.Sprintf("%020d%s", q.nextSeq, segmentExt))}
+	f, err := os.OpenFile(seg.path, os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	q.nextSeq++
+	q.segments = append(q.segments, seg)
+	q.active = f
+	return nil
+}
+
+// seal closes the active segment, so that no record is appended to it
+// anymore. It must be called with mu held.
+func (q *diskQueue) seal() {
+	if q.active != nil {
+		q.active.Close()
+		q.active = nil
+	}
+}
+
+// last returns the newest segment, or nil. It must be called with mu held.
+func (q *diskQueue) last() *segment {
+	if len(q.segments) == 0 {
+		return nil
+	}
+	return q.segments[len(q.segments)-1]
+}
+
+// oldest returns the oldest segment, or nil if the queue is empty. The
+// segment is sealed if it was the active one, so that it is complete.
+func (q *diskQueue) oldest() *segment {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.segments) == 0 {
+		return nil
+	}
+	if len(q.segments) == 1 {
+		q.seal()
+	}
+	return q.segments[0]
+}
+
+// remove deletes the consumed segment seg, unless it was evicted already.
+func (q *diskQueue) remove(seg *segment) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, s := range q.segments {
+		if s == seg {
+			q.segments = append(q.segments[:i], q.segments[i+1:]...)
+			q.size -= seg.size
+			break
+		}
+	}
+	if err := os.Remove(seg.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// bytes returns the size of the segments of the queue.
+func (q *diskQueue) bytes() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.size
+}
+
+func (q *diskQueue) close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.seal()
+	return nil
+}
+
+// readRecord returns the payload of the record at the start of b and the size
+// of the record. It returns io.EOF if b is empty, and io.ErrUnexpectedEOF if
+// the record was only partially written.
+func readRecord(b []byte) (payload []byte, n int, err error) {
+	if len(b) == 0 {
+		return nil, 0, io.EOF
+	}
+	if len(b) < recordHeaderSize {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+	size := int(binary.BigEndian.Uint32(b[0:4]))
+	if len(b)-recordHeaderSize < size {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+	payload = b[recordHeaderSize : recordHeaderSize+size]
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(b[4:8]) {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+	return payload, recordHeaderSize + size, nil
+}
@@ -0,0 +1,507 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SelectBuilder builds a SELECT query with its identifiers and literals
+// quoted, so that values such as tag values are never interpreted as
+// InfluxQL. Errors of the clauses are returned by Build.
+//
+//	q, err := client.Select("usage_idle").
+//		From("telegraf", "", "cpu").
+//		Where(client.Tag("host").Eq(host)).
+//		And(client.Time().Gt(start)).
+//		GroupBy(client.Time(time.Minute), "host").
+//		Limit(100).
+//		Build()
+type SelectBuilder struct {
+	fields  []string
+	from    []string
+	db      string
+	where   string
+	groupBy []string
+	byTime  bool
+	fill    string
+	desc    bool
+	limit   int
+	offset  int
+
+	// err is the first error of the clauses, returned by Build.
+	err error
+}
+
+// Select returns a SelectBuilder for a query of fields. A field is either a
+// string, the name of a field or tag quoted as an identifier, "*" for every
+// field and tag, or an Expr such as Call("mean", "usage_idle").
+func Select(fields ...interface{}) *SelectBuilder {
+	b := &SelectBuilder{}
+	for _, f := range fields {
+		switch f := f.(type) {
+		case string:
+			if f == "*" {
+				b.fields = append(b.fields, f)
+			} else {
+				b.fields = append(b.fields, QuoteIdent(f))
+			}
+		case Expr:
+			b.fields = append(b.fields, f.s)
+			b.fail(f.err)
+		default:
+			b.fail(fmt.Errorf("unsupported field type %T", f))
+		}
+	}
+	return b
+}
+
+// From adds measurement to the measurements the query selects from, in db
+// and rp. Both db and rp may be empty to use those of the query's database.
+// The database of the first call is the Database of the built query.
+func (b *SelectBuilder) From(db, rp, measurement string) *SelectBuilder {
+	if measurement == "" {
+		b.fail(errors.New("empty measurement name"))
+		return b
+	}
+	b.addFrom(db, rp, QuoteIdent(measurement))
+	return b
+}
+
+// FromRegex is like From, for the measurements matching expr in db and rp.
+func (b *SelectBuilder) FromRegex(db, rp, expr string) *SelectBuilder {
+	if _, err := regexp.Compile(expr); err != nil {
+		b.fail(err)
+		return b
+	}
+	b.addFrom(db, rp, quoteRegex(expr))
+	return b
+}
+
+func (b *SelectBuilder) addFrom(db, rp, source string) {
+	if len(b.from) == 0 {
+		b.db = db
+	}
+	b.from = append(b.from, qualifySource(db, rp, source))
+}
+
+// qualifySource prefixes the quoted measurement or regex source with the
+// database and retention policy that are set.
+func qualifySource(db, rp, source string) string {
+	switch {
+	case db != "" && rp != "":
+		return QuoteIdent(db) + "." + QuoteIdent(rp) + "." + source
+	case db != "":
+		return QuoteIdent(db) + ".." + source
+	case rp != "":
+		return QuoteIdent(rp) + "." + source
+	}
+	return source
+}
+
+// Where sets the condition of the query, replacing any set before.
+func (b *SelectBuilder) Where(c Cond) *SelectBuilder {
+	b.fail(c.err)
+	b.where = c.s
+	return b
+}
+
+// And adds c to the condition of the query, which must all hold.
+func (b *SelectBuilder) And(c Cond) *SelectBuilder {
+	if b.where == "" {
+		return b.Where(c)
+	}
+	return b.Where(Cond{s: b.where}.And(c))
+}
+
+// Or makes the query select the points matching either its condition so
+// far or c.
+func (b *SelectBuilder) Or(c Cond) *SelectBuilder {
+	if b.where == "" {
+		return b.Where(c)
+	}
+	return b.Where(Cond{s: b.where}.Or(c))
+}
+
+// GroupBy adds dimensions to group the results by: a string is the key of a
+// tag, "*" stands for every tag, and Time(interval) groups by time.
+func (b *SelectBuilder) GroupBy(dimensions ...interface{}) *SelectBuilder {
+	for _, d := range dimensions {
+		switch d := d.(type) {
+		case string:
+			if d == "*" {
+				b.groupBy = append(b.groupBy, d)
+			} else {
+				b.groupBy = append(b.groupBy, QuoteIdent(d))
+			}
+		case Column:
+			if !d.interval {
+				b.fail(fmt.Errorf("cannot group by %s without an interval", d.s))
+				continue
+			}
+			b.fail(d.err)
+			b.groupBy = append(b.groupBy, d.s)
+			b.byTime = true
+		default:
+			b.fail(fmt.Errorf("unsupported dimension type %T", d))
+		}
+	}
+	return b
+}
+
+// FillMode is how a query grouped by time fills the intervals without data.
+type FillMode int
+
+const (
+	// FillNull returns intervals without data with a null value, as the
+	// server does by default.
+	FillNull FillMode = iota
+
+	// FillNone leaves the intervals without data out.
+	FillNone
+
+	// FillPrevious fills the intervals without data with the value of the
+	// interval before.
+	FillPrevious
+
+	// FillLinear interpolates the intervals without data between those
+	// around them.
+	FillLinear
+
+	// FillValue fills the intervals without data with a given value.
+	FillValue
+)
+
+// Fill sets how the intervals without data of a query grouped by time are
+// filled. The value is only used by FillValue.
+func (b *SelectBuilder) Fill(mode FillMode, value float64) *SelectBuilder {
+	switch mode {
+	case FillNull:
+		b.fill = "fill(null)"
+	case FillNone:
+		b.fill = "fill(none)"
+	case FillPrevious:
+		b.fill = "fill(previous)"
+	case FillLinear:
+		b.fill = "fill(linear)"
+	case FillValue:
+		lit, err := formatFloat(value)
+		b.fail(err)
+		b.fill = "fill(" + lit + ")"
+	default:
+		b.fail(fmt.Errorf("unknown fill mode %d", mode))
+	}
+	return b
+}
+
+// Desc returns the newest points first.
+func (b *SelectBuilder) Desc() *SelectBuilder {
+	b.desc = true
+	return b
+}
+
+// Limit returns at most n points per series.
+func (b *SelectBuilder) Limit(n int) *SelectBuilder {
+	if n < 0 {
+		b.fail(fmt.Errorf("invalid limit %d", n))
+	}
+	b.limit = n
+	return b
+}
+
+// Offset skips the first n points of every series.
+func (b *SelectBuilder) Offset(n int) *SelectBuilder {
+	if n < 0 {
+		b.fail(fmt.Errorf("invalid offset %d", n))
+	}
+	b.offset = n
+	return b
+}
+
+// Build returns the query, or the first error of its clauses. A query
+// without a field or a measurement is an error.
+func (b *SelectBuilder) Build() (Query, error) {
+	switch {
+	case b.err != nil:
+		return Query{}, b.err
+	case len(b.fields) == 0:
+		return Query{}, errors.New("no field selected")
+	case len(b.from) == 0:
+		return Query{}, errors.New("no measurement to select from")
+	case b.fill != "" && !b.byTime:
+		return Query{}, errors.New("cannot fill a query not grouped by time")
+	}
+
+	var s strings.Builder
+	s.WriteString("SELECT " + strings.Join(b.fields, ", "))
+	s.WriteString(" FROM " + strings.Join(b.from, ", "))
+	if b.where != "" {
+		s.WriteString(" WHERE " + b.where)
+	}
+	if len(b.groupBy) > 0 {
+		s.WriteString(" GROUP BY " + strings.Join(b.groupBy, ", "))
+	}
+	if b.fill != "" {
+		s.WriteString(" " + b.fill)
+	}
+	if b.desc {
+		s.WriteString(" ORDER BY time DESC")
+	}
+	if b.limit > 0 {
+		s.WriteString(" LIMIT " + strconv.Itoa(b.limit))
+	}
+	if b.offset > 0 {
+		s.WriteString(" OFFSET " + strconv.Itoa(b.offset))
+	}
+	return NewQuery(s.String(), b.db, ""), nil
+}
+
+func (b *SelectBuilder) fail(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// Expr is an expression selected by a SelectBuilder.
+type Expr struct {
+	s   string
+	err error
+}
+
+// Call returns a call of the function fn, such as mean or percentile. A
+// string argument is the name of a field, quoted as an identifier, and any
+// other argument a literal as in Column.Eq.
+//
+//	client.Call("percentile", "latency", 95)
+func Call(fn string, args ...interface{}) Expr {
+	var e Expr
+	parts := make([]string, 0, len(args))
+	for _, arg := range args {
+		switch arg := arg.(type) {
+		case string:
+			parts = append(parts, QuoteIdent(arg))
+		case Expr:
+			if e.err == nil {
+				e.err = arg.err
+			}
+			parts = append(parts, arg.s)
+		default:
+			lit, err := literal(arg)
+			if err != nil && e.err == nil {
+				e.err = err
+			}
+			parts = append(parts, lit)
+		}
+	}
+	if !isIdent(fn) {
+		e.err = fmt.Errorf("invalid function name %q", fn)
+	}
+	e.s = fn + "(" + strings.Join(parts, ", ") + ")"
+	return e
+}
+
+// As names the column of e in the results.
+func (e Expr) As(alias string) Expr {
+	e.s += " AS " + QuoteIdent(alias)
+	return e
+}
+
+// Column is a tag, field or the time of the points of a query, compared to
+// values in the condition of a SelectBuilder.
+type Column struct {
+	s   string
+	tag bool
+	err error
+
+	// interval is set for Time with an interval, only valid in GROUP BY.
+	interval bool
+}
+
+// Tag returns the column of the tag key. Its values are strings.
+func Tag(key string) Column {
+	return Column{s: QuoteIdent(key) + "::tag", tag: true}
+}
+
+// Field returns the column of the field key.
+func Field(key string) Column {
+	return Column{s: QuoteIdent(key) + "::field"}
+}
+
+// Time returns the time column of the points, compared to time.Time values.
+// Given an interval, and optionally an offset, it returns the dimension that
+// groups points by time for SelectBuilder.GroupBy instead.
+func Time(interval ...time.Duration) Column {
+	if len(interval) == 0 {
+		return Column{s: "time"}
+	}
+	c := Column{interval: true}
+	if len(interval) > 2 {
+		c.err = fmt.Errorf("too many arguments for time: %d", len(interval))
+	}
+	parts := make([]string, len(interval))
+	for i, d := range interval {
+		var err error
+		switch {
+		case i == 0 && d <= 0:
+			err = fmt.Errorf("invalid time interval %v", d)
+		case d == 0:
+			parts[i] = "0s"
+		case d < 0:
+			parts[i], err = formatDuration(-d)
+			parts[i] = "-" + parts[i]
+		default:
+			parts[i], err = formatDuration(d)
+		}
+		if err != nil && c.err == nil {
+			c.err = err
+		}
+	}
+	c.s = "time(" + strings.Join(parts, ", ") + ")"
+	return c
+}
+
+// Eq matches the points whose column equals v.
+func (c Column) Eq(v interface{}) Cond { return c.compare("=", v) }
+
+// Neq matches the points whose column is not v.
+func (c Column) Neq(v interface{}) Cond { return c.compare("!=", v) }
+
+// Lt matches the points whose column is less than v.
+func (c Column) Lt(v interface{}) Cond { return c.compare("<", v) }
+
+// Lte matches the points whose column is at most v.
+func (c Column) Lte(v interface{}) Cond { return c.compare("<=", v) }
+
+// Gt matches the points whose column is greater than v.
+func (c Column) Gt(v interface{}) Cond { return c.compare(">", v) }
+
+// Gte matches the points whose column is at least v.
+func (c Column) Gte(v interface{}) Cond { return c.compare(">=", v) }
+
+// Matches matches the points whose column matches the regular expression
+// expr.
+func (c Column) Matches(expr string) Cond { return c.match("=~", expr) }
+
+// NotMatches matches the points whose column does not match the regular
+// expression expr.
+func (c Column) NotMatches(expr string) Cond { return c.match("!~", expr) }
+
+func (c Column) compare(op string, v interface{}) Cond {
+	if err := c.check(); err != nil {
+		return Cond{err: err}
+	}
+	if _, ok := v.(string); c.tag && !ok {
+		return Cond{err: fmt.Errorf("cannot compare tag %s to %T: tag values are strings", c.s, v)}
+	}
+	lit, err := literal(v)
+	if err != nil {
+		return Cond{err: err}
+	}
+	return Cond{s: c.s + " " + op + " " + lit}
+}
+
+func (c Column) match(op, expr string) Cond {
+	if err := c.check(); err != nil {
+		return Cond{err: err}
+	}
+	if _, err := regexp.Compile(expr); err != nil {
+		return Cond{err: err}
+	}
+	return Cond{s: c.s + " " + op + " " + quoteRegex(expr)}
+}
+
+func (c Column) check() error {
+	if c.err != nil {
+		return c.err
+	}
+	if c.interval {
+		return fmt.Errorf("cannot compare %s to a value", c.s)
+	}
+	return nil
+}
+
+// Cond is a condition of a SelectBuilder, made by the methods of Column.
+type Cond struct {
+	s   string
+	err error
+}
+
+// And returns the condition matching the points c and every one of others
+// match.
+func (c Cond) And(others ...Cond) Cond { return c.join(" AND ", others) }
+
+// Or returns the condition matching the points c or one of others match.
+func (c Cond) Or(others ...Cond) Cond { return c.join(" OR ", others) }
+
+func (c Cond) join(op string, others []Cond) Cond {
+	parts := []string{c.s}
+	for _, o := range others {
+		if c.err == nil {
+			c.err = o.err
+		}
+		parts = append(parts, o.s)
+	}
+	c.s = "(" + strings.Join(parts, op) + ")"
+	return c
+}
+
+// literal formats v as an InfluxQL literal: times as RFC3339 strings with
+// nanoseconds in UTC, durations as duration literals.
+func literal(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return QuoteString(v), nil
+	case time.Time:
+		return FormatTime(v), nil
+	case time.Duration:
+		return formatDuration(v)
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int32:
+		return strconv.FormatInt(int64(v), 10), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint32:
+		return strconv.FormatUint(uint64(v), 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case float32:
+		return formatFloat(float64(v))
+	case float64:
+		return formatFloat(v)
+	}
+	return "", fmt.Errorf("unsupported literal type %T", v)
+}
+
+func formatFloat(f float64) (string, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", fmt.Errorf("invalid number %v", f)
+	}
+	s := strconv.FormatFloat(f, 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		// Keep it a float, as an integer does not match float fields.
+		s += ".0"
+	}
+	return s, nil
+}
+
+// isIdent reports whether s is an unquoted InfluxQL identifier.
+func isIdent(s string) bool {
+	if s == "" || s[0] >= '0' && s[0] <= '9' {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isWordByte(s[i]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,134 @@
+package client
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSelect(t *testing.T) {
+	t1 := time.Date(2024, 1, 2, 3, 4, 5, 6, time.FixedZone("CET", 3600))
+	for _, tt := range []struct {
+		b   *SelectBuilder
+		exp string
+	}{
+		{
+			Select("usage_idle").From("db", "rp", "cpu").Where(Tag("host").Eq("a'b")).And(Time().Gt(t1)).GroupBy(Time(time.Minute), "host").Limit(100),
+			`SELECT "usage_idle" FROM "db"."rp"."cpu" WHERE ("host"::tag = 'a\'b' AND time > '2024-01-02T02:04:05.000000006Z') GROUP BY time(1m), "host" LIMIT 100`,
+		},
+		{
+			Select("*").From("", "", `we"ird`).Where(Field("v").Gte(1.0).Or(Field("v").Lt(int64(-2)))).Desc().Offset(5),
+			`SELECT * FROM "we\"ird" WHERE ("v"::field >= 1.0 OR "v"::field < -2) ORDER BY time DESC OFFSET 5`,
+		},
+		{
+			Select(Call("mean", "value").As("avg"), Call("percentile", "value", 95)).From("db", "", "cpu").FromRegex("", "rp", "^mem/.*").GroupBy(Time(time.Hour, -15*time.Minute), "*"),
+			`SELECT mean("value") AS "avg", percentile("value", 95) FROM "db".."cpu", "rp"./^mem\/.*/ GROUP BY time(1h, -15m), *`,
+		},
+		{
+			Select(Call("max", "v")).From("", "", "cpu").GroupBy(Time(time.Minute)).Fill(FillValue, -1).Desc(),
+			`SELECT max("v") FROM "cpu" GROUP BY time(1m) fill(-1.0) ORDER BY time DESC`,
+		},
+		{
+			Select(Call("sum", "v")).From("", "", "cpu").GroupBy("host", Time(time.Minute)).Fill(FillPrevious, 0),
+			`SELECT sum("v") FROM "cpu" GROUP BY "host", time(1m) fill(previous)`,
+		},
+		{
+			Select("v").From("", "", "cpu").Where(Tag("host").Matches("^web-\\d+$")).Or(Tag("dc").NotMatches("eu")),
+			`SELECT "v" FROM "cpu" WHERE ("host"::tag =~ /^web-\d+$/ OR "dc"::tag !~ /eu/)`,
+		},
+		{
+			Select("line").From("", "", "logs").Where(Field("msg").Eq("back\\slash\nnewline")).And(Field("ok").Eq(true)),
+			`SELECT "line" FROM "logs" WHERE ("msg"::field = 'back\\slash\nnewline' AND "ok"::field = true)`,
+		},
+	} {
+		q, err := tt.b.Build()
+		if err != nil {
+			t.Errorf("unexpected error for %s.  expected %v, actual %v", tt.exp, nil, err)
+			continue
+		}
+		if q.Command != tt.exp {
+			t.Errorf("unexpected command.\nexpected %s\nactual   %s", tt.exp, q.Command)
+		}
+	}
+
+	q, _ := Select("v").From("db0", "", "cpu").From("db1", "", "mem").Build()
+	if q.Database != "db0" {
+		t.Errorf("unexpected database.  expected %v, actual %v", "db0", q.Database)
+	}
+}
+
+func TestSelect_Errors(t *testing.T) {
+	for _, b := range []*SelectBuilder{
+		Select().From("", "", "cpu"),
+		Select("v"),
+		Select("v").From("", "", ""),
+		Select(42).From("", "", "cpu"),
+		Select("v").FromRegex("", "", "("),
+		Select("v").From("", "", "cpu").Where(Tag("host").Eq(1)),
+		Select("v").From("", "", "cpu").Where(Field("v").Eq(math.NaN())),
+		Select("v").From("", "", "cpu").Where(Field("v").Eq([]int{1})),
+		Select("v").From("", "", "cpu").Where(Tag("host").Matches("[")),
+		Select("v").From("", "", "cpu").Where(Time(time.Minute).Gt(time.Now())),
+		Select("v").From("", "", "cpu").And(Field("a").Eq(1)).And(Tag("b").Eq(2)),
+		Select("v").From("", "", "cpu").GroupBy(Time()),
+		Select("v").From("", "", "cpu").GroupBy(Time(0)),
+		Select("v").From("", "", "cpu").GroupBy(Time(time.Nanosecond)),
+		Select("v").From("", "", "cpu").GroupBy(Time(time.Hour, time.Minute, time.Second)),
+		Select("v").From("", "", "cpu").GroupBy(3),
+		Select("v").From("", "", "cpu").Limit(-1),
+		Select(Call("mean(", "v")).From("", "", "cpu"),
+		Select(Call("mean", "v")).From("", "", "cpu").GroupBy("host").Fill(FillNone, 0),
+		Select(Call("mean", "v")).From("", "", "cpu").GroupBy(Time(time.Minute)).Fill(FillValue, math.Inf(1)),
+		Select(Call("mean", "v")).From("", "", "cpu").GroupBy(Time(time.Minute)).Fill(FillMode(9), 0),
+	} {
+		if q, err := b.Build(); err == nil {
+			t.Errorf("expected an error for %q", q.Command)
+		}
+	}
+}
+
+// unquote reverses QuoteString and QuoteIdent, and checks that the quoted
+// string ends where the scanner of readOnly and Query.Statements says.
+func unquote(t *testing.T, quoted string) string {
+	if n := quotedLen(quoted); n != len(quoted) {
+		t.Fatalf("quoted string %q ends at %d", quoted, n)
+	}
+	var b strings.Builder
+	for i := 1; i < len(quoted)-1; i++ {
+		c := quoted[i]
+		if c == quoted[0] {
+			t.Fatalf("unescaped quote in %q", quoted)
+		}
+		if c == '\\' {
+			i++
+			switch quoted[i] {
+			case 'n':
+				c = '\n'
+			default:
+				c = quoted[i]
+			}
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+func FuzzQuoteString(f *testing.F) {
+	for _, s := range []string{"", "a", `'`, `"`, `\`, "\n", `a\'b`, `\\'`, "x;y", "--", "/*"} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		if got := unquote(t, QuoteString(s)); got != s {
+			t.Errorf("unexpected string.  expected %q, actual %q", s, got)
+		}
+		if got := unquote(t, QuoteIdent(s)); got != s {
+			t.Errorf("unexpected identifier.  expected %q, actual %q", s, got)
+		}
+		// A value cannot end the statement or start another one.
+		q, err := Select("v").From("", "", s).Where(Tag(s).Eq(s)).Build()
+		if err == nil && len(q.Statements()) != 1 {
+			t.Errorf("unexpected statements for %q: %q", s, q.Statements())
+		}
+	})
+}
@@ -0,0 +1,352 @@
+package client
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+// The types of the fields of a point in its JSON encoding.
+const (
+	jsonFloat    = "float"
+	jsonInteger  = "integer"
+	jsonUnsigned = "unsigned"
+	jsonBoolean  = "boolean"
+	jsonString   = "string"
+)
+
+// jsonPoint is the JSON encoding of a point. The type of every field is
+// explicit, so that an integer does not decode as a float.
+type jsonPoint struct {
+	Measurement string               `json:"measurement"`
+	Tags        map[string]string    `json:"tags,omitempty"`
+	Fields      map[string]jsonField `json:"fields"`
+	Time        *int64               `json:"time,omitempty"`
+}
+
+type jsonField struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+// MarshalJSON encodes the point as an object with its measurement, tags,
+// fields and time in nanoseconds since the Unix epoch, which is omitted for a
+// point without a timestamp. Every field is encoded with its type, "float",
+// "integer", "unsigned", "boolean" or "string", and its value, so that the
+// point decodes with the types it was encoded with.
+func (p *Point) MarshalJSON() ([]byte, error) {
+	fields, err := p.pt.Fields()
+	if err != nil {
+		return nil, err
+	}
+
+	jp := jsonPoint{
+		Measurement: p.Name(),
+		Tags:        p.Tags(),
+		Fields:      make(map[string]jsonField, len(fields)),
+	}
+	if len(jp.Tags) == 0 {
+		jp.Tags = nil
+	}
+	for k, v := range fields {
+		var f jsonField
+		switch v := v.(type) {
+		case float64:
+			f = jsonField{Type: jsonFloat, Value: strconv.AppendFloat(nil, v, 'g', -1, 64)}
+		case int64:
+			f = jsonField{Type: jsonInteger, Value: strconv.AppendInt(nil, v, 10)}
+		case uint64:
+			f = jsonField{Type: jsonUnsigned, Value: strconv.AppendUint(nil, v, 10)}
+		case bool:
+			f = jsonField{Type: jsonBoolean, Value: strconv.AppendBool(nil, v)}
+		case string:
+			b, err := json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			f = jsonField{Type: jsonString, Value: b}
+		default:
+			return nil, fmt.Errorf("field %s has unsupported type %T", k, v)
+		}
+		jp.Fields[k] = f
+	}
+	if t := p.pt.Time(); !t.IsZero() {
+		ns := t.UnixNano()
+		jp.Time = &ns
+	}
+	return json.Marshal(jp)
+}
+
+// UnmarshalJSON decodes a point encoded by MarshalJSON.
+func (p *Point) UnmarshalJSON(b []byte) error {
+	var jp jsonPoint
+	if err := json.Unmarshal(b, &jp); err != nil {
+		return err
+	}
+
+	fields := make(map[string]interface{}, len(jp.Fields))
+	for k, f := range jp.Fields {
+		v, err := f.decode()
+		if err != nil {
+			return fmt.Errorf("unable to decode field %s: %s", k, err)
+		}
+		fields[k] = v
+	}
+
+	var t time.Time
+	if jp.Time != nil {
+		t = time.Unix(0, *jp.Time).UTC()
+	}
+	pt, err := models.NewPoint(jp.Measurement, models.NewTags(jp.Tags), fields, t)
+	if err != nil {
+		return err
+	}
+	p.pt = pt
+	return nil
+}
+
+// decode returns the value of f as its type.
+func (f jsonField) decode() (interface{}, error) {
+	switch f.Type {
+	case jsonFloat:
+		var n json.Number
+		if err := json.Unmarshal(f.Value, &n); err != nil {
+			return nil, err
+		}
+		return strconv.ParseFloat(string(n), 64)
+	case jsonInteger:
+		var n json.Number
+		if err := json.Unmarshal(f.Value, &n); err != nil {
+			return nil, err
+		}
+		return strconv.ParseInt(string(n), 10, 64)
+	case jsonUnsigned:
+		var n json.Number
+		if err := json.Unmarshal(f.Value, &n); err != nil {
+			return nil, err
+		}
+		return strconv.ParseUint(string(n), 10, 64)
+	case jsonBoolean:
+		var v bool
+		err := json.Unmarshal(f.Value, &v)
+		return v, err
+	case jsonString:
+		var v string
+		err := json.Unmarshal(f.Value, &v)
+		return v, err
+	}
+	return nil, fmt.Errorf("unknown type %q", f.Type)
+}
+
+// BatchPointsDocument is a batch of points along with its configuration, as
+// encoded to and decoded from JSON.
+type BatchPointsDocument struct {
+	Config BatchPointsConfig `json:"config"`
+	Points []*Point          `json:"points"`
+}
+
+// NewBatchPointsDocument returns the document of bp. The points are those of
+// bp, not copies.
+func NewBatchPointsDocument(bp BatchPoints) *BatchPointsDocument {
+	return &BatchPointsDocument{
+		Config: batchPointsConfig(bp),
+		Points: bp.Points(),
+	}
+}
+
+// BatchPoints returns a batch with the configuration and points of d.
+func (d *BatchPointsDocument) BatchPoints() (BatchPoints, error) {
+	bp, err := NewBatchPoints(d.Config)
+	if err != nil {
+		return nil, err
+	}
+	if err := bp.AddPoints(d.Points); err != nil {
+		return nil, err
+	}
+	return bp, nil
+}
+
+// batchPointsConfig returns the configuration that bp was created with.
+func batchPointsConfig(bp BatchPoints) BatchPointsConfig {
+	conf := BatchPointsConfig{
+		Precision:        bp.Precision(),
+		Database:         bp.Database(),
+		RetentionPolicy:  bp.RetentionPolicy(),
+		WriteConsistency: bp.WriteConsistency(),
+	}
+	switch bp := bp.(type) {
+	case *batchpoints:
+		conf.SortOnWrite = bp.sortOnWrite
+		conf.SortFields = bp.sortFields
+		conf.CardinalityLimiter = bp.limiter
+		conf.TagSanitizer = bp.sanitizer
+		conf.AssignTimeOnAdd = bp.clock != nil
+		conf.Clock = bp.clock
+		conf.Strict = bp.strict
+		conf.MaxBytes = bp.maxBytes
+		bp.limits.configure(&conf)
+	case *safeBatchPoints:
+		bp.mu.Lock()
+		conf.SortOnWrite = bp.bp.sortOnWrite
+		conf.SortFields = bp.bp.sortFields
+		conf.CardinalityLimiter = bp.bp.limiter
+		conf.TagSanitizer = bp.bp.sanitizer
+		conf.AssignTimeOnAdd = bp.bp.clock != nil
+		conf.Clock = bp.bp.clock
+		conf.Strict = bp.bp.strict
+		conf.MaxBytes = bp.bp.maxBytes
+		bp.bp.limits.configure(&conf)
+		bp.mu.Unlock()
+	}
+	return conf
+}
+
+// binaryVersion is the version of the binary encoding of a batch, its first
+// byte.
+const binaryVersion = 1
+
+const (
+	binarySortOnWrite = 1 << 0
+	binaryStrict      = 1 << 1
+	binarySortFields  = 1 << 2
+)
+
+// errMalformedBinary is returned by FromBinary for a truncated or otherwise
+// malformed encoding.
+var errMalformedBinary = errors.New("malformed binary batch")
+
+// AppendBinary appends the binary encoding of bp to dst and returns the
+// extended buffer. The encoding is a header with the configuration of bp
+// followed by its points, each with its series key and fields in line
+// protocol and its time in binary. It is much smaller than the JSON of a
+// batch of numeric fields, and keeps the types of the fields as the line
+// protocol does. Nil points are skipped.
+func AppendBinary(dst []byte, bp BatchPoints) ([]byte, error) {
+	conf := batchPointsConfig(bp)
+	points := bp.Points()
+
+	dst = append(dst, binaryVersion)
+	for _, s := range []string{conf.Precision, conf.Database, conf.RetentionPolicy, conf.WriteConsistency} {
+		dst = binary.AppendUvarint(dst, uint64(len(s)))
+		dst = append(dst, s...)
+	}
+	var flags byte
+	if conf.SortOnWrite {
+		flags |= binarySortOnWrite
+	}
+	if conf.Strict {
+		flags |= binaryStrict
+	}
+	if conf.SortFields {
+		flags |= binarySortFields
+	}
+	dst = append(dst, flags)
+
+	n := 0
+	for _, p := range points {
+		if p != nil {
+			n++
+		}
+	}
+	dst = binary.AppendUvarint(dst, uint64(n))
+	for _, p := range points {
+		if p == nil {
+			continue
+		}
+		b, err := p.pt.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		dst = binary.AppendUvarint(dst, uint64(len(b)))
+		dst = append(dst, b...)
+	}
+	return dst, nil
+}
+
+// FromBinary decodes a batch encoded by AppendBinary. The points do not
+// refer to b.
+func FromBinary(b []byte) (BatchPoints, error) {
+	b = append([]byte(nil), b...)
+
+	if len(b) == 0 {
+		return nil, errMalformedBinary
+	}
+	if b[0] != binaryVersion {
+		return nil, fmt.Errorf("unknown binary batch version %d", b[0])
+	}
+	b = b[1:]
+
+	next := func() ([]byte, error) {
+		n, m := binary.Uvarint(b)
+		if m <= 0 || uint64(len(b)-m) < n {
+			return nil, errMalformedBinary
+		}
+		v := b[m : m+int(n)]
+		b = b[m+int(n):]
+		return v, nil
+	}
+
+	var strs [4]string
+	for i := range strs {
+		s, err := next()
+		if err != nil {
+			return nil, err
+		}
+		strs[i] = string(s)
+	}
+	if len(b) == 0 {
+		return nil, errMalformedBinary
+	}
+	flags := b[0]
+	b = b[1:]
+
+	bp, err := NewBatchPoints(BatchPointsConfig{
+		Precision:        strs[0],
+		Database:         strs[1],
+		RetentionPolicy:  strs[2],
+		WriteConsistency: strs[3],
+		SortOnWrite:      flags&binarySortOnWrite != 0,
+		Strict:           flags&binaryStrict != 0,
+		SortFields:       flags&binarySortFields != 0,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	n, m := binary.Uvarint(b)
+	if m <= 0 {
+		return nil, errMalformedBinary
+	}
+	b = b[m:]
+	// Every point takes more than a byte, which bounds the allocation for
+	// a malformed count.
+	if n > uint64(len(b)) {
+		return nil, errMalformedBinary
+	}
+	points := make([]*Point, 0, n)
+	for i := uint64(0); i < n; i++ {
+		pb, err := next()
+		if err != nil {
+			return nil, err
+		}
+		pt, err := models.NewPointFromBytes(pb)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, &Point{pt: pt})
+	}
+	if len(b) != 0 {
+		return nil, errMalformedBinary
+	}
+	// The points were accepted by the batch encoded, whose limits are not
+	// part of the encoding.
+	if err := addAccepted(bp, points); err != nil {
+		return nil, err
+	}
+	return bp, nil
+}
@@ -0,0 +1,187 @@
+package client
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPoint_JSON(t *testing.T) {
+	ts := time.Unix(0, 1500000000000000001)
+	for _, p := range []*Point{
+		mustPoint(t, "cpu", map[string]string{"host": "a b", "region": "x,y"}, map[string]interface{}{
+			"f":  2.0,
+			"fr": 0.1,
+			"i":  int64(-3),
+			"u":  uint64(1<<64 - 1),
+			"b":  true,
+			"s":  `quoted "string"`,
+			"n":  int64(1<<63 - 1),
+		}, ts),
+		mustPoint(t, "untimed", nil, map[string]interface{}{"v": int64(1)}),
+		mustPoint(t, "epoch", nil, map[string]interface{}{"v": 1.5}, time.Unix(0, 0)),
+	} {
+		b, err := json.Marshal(p)
+		if err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+		var got Point
+		if err := json.Unmarshal(b, &got); err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+		if got.String() != p.String() {
+			t.Errorf("unexpected point.  expected %v, actual %v", p, &got)
+		}
+		expFields, _ := p.Fields()
+		gotFields, _ := got.Fields()
+		if !reflect.DeepEqual(gotFields, expFields) {
+			t.Errorf("unexpected fields.  expected %#v, actual %#v", expFields, gotFields)
+		}
+		if !got.Time().Equal(p.Time()) || got.Time().IsZero() != p.Time().IsZero() {
+			t.Errorf("unexpected time.  expected %v, actual %v", p.Time(), got.Time())
+		}
+	}
+}
+
+func TestPoint_JSONFormat(t *testing.T) {
+	p := mustPoint(t, "cpu", map[string]string{"host": "a"}, map[string]interface{}{"i": int64(3), "f": 2.0}, time.Unix(0, 42))
+	b, err := json.Marshal(p)
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	exp := `{"measurement":"cpu","tags":{"host":"a"},"fields":{"f":{"type":"float","value":2},"i":{"type":"integer","value":3}},"time":42}`
+	if string(b) != exp {
+		t.Errorf("unexpected JSON.\nexpected %s\nactual   %s", exp, b)
+	}
+}
+
+func TestPoint_UnmarshalJSONInvalid(t *testing.T) {
+	for _, s := range []string{
+		`{"measurement":"cpu","fields":{"v":{"type":"integer","value":1.5}}}`,
+		`{"measurement":"cpu","fields":{"v":{"type":"unsigned","value":-1}}}`,
+		`{"measurement":"cpu","fields":{"v":{"type":"boolean","value":"true"}}}`,
+		`{"measurement":"cpu","fields":{"v":{"type":"complex","value":1}}}`,
+		`{"measurement":"cpu","fields":{}}`,
+	} {
+		var p Point
+		if err := json.Unmarshal([]byte(s), &p); err == nil {
+			t.Errorf("unexpected success for %s", s)
+		}
+	}
+}
+
+func TestBatchPointsDocument_JSON(t *testing.T) {
+	bp, _ := NewBatchPoints(BatchPointsConfig{
+		Precision:        "ms",
+		Database:         "db0",
+		RetentionPolicy:  "rp0",
+		WriteConsistency: "all",
+		SortOnWrite:      true,
+		Strict:           true,
+	})
+	bp.AddPoint(mustPoint(t, "cpu", map[string]string{"host": "b"}, map[string]interface{}{"v": int64(1)}, time.Unix(2, 0)))
+	bp.AddPoint(mustPoint(t, "cpu", map[string]string{"host": "a"}, map[string]interface{}{"v": uint64(2)}, time.Unix(1, 0)))
+
+	b, err := json.Marshal(NewBatchPointsDocument(bp))
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	var doc BatchPointsDocument
+	if err := json.Unmarshal(b, &doc); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	got, err := doc.BatchPoints()
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	assertSameBatch(t, bp, got)
+}
+
+func TestBatchPoints_Binary(t *testing.T) {
+	bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0", RetentionPolicy: "rp0", Precision: "s", WriteConsistency: "one", Strict: true})
+	bp.AddPoint(mustPoint(t, "cpu", map[string]string{"host": "a b"}, map[string]interface{}{
+		"f": 2.0,
+		"i": int64(-3),
+		"u": uint64(1<<64 - 1),
+		"b": false,
+		"s": "x\ny",
+	}, time.Unix(0, 1500000000000000001)))
+	// Nil points are dropped by AddPoint, but other BatchPoints may have
+	// them.
+	bp.(*batchpoints).points = append(bp.Points(), nil)
+	bp.AddPoint(mustPoint(t, "untimed", nil, map[string]interface{}{"v": int64(1)}))
+
+	b, err := AppendBinary([]byte("prefix"), bp)
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if string(b[:6]) != "prefix" {
+		t.Errorf("unexpected prefix.  expected %q, actual %q", "prefix", b[:6])
+	}
+	got, err := FromBinary(b[6:])
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if len(got.Points()) != 2 {
+		t.Fatalf("unexpected point count.  expected %v, actual %v", 2, len(got.Points()))
+	}
+	if !got.Points()[1].Time().IsZero() {
+		t.Errorf("unexpected time.  expected %v, actual %v", time.Time{}, got.Points()[1].Time())
+	}
+	bp.(*batchpoints).points = append(bp.Points()[:1], bp.Points()[2])
+	assertSameBatch(t, bp, got)
+
+	// The points are not checked against the default limits again.
+	long, _ := NewBatchPoints(BatchPointsConfig{MaxStringFieldLength: -1})
+	long.AddPoint(mustPoint(t, "cpu", nil, map[string]interface{}{"s": strings.Repeat("x", 70000)}, time.Unix(0, 0)))
+	lb, _ := AppendBinary(nil, long)
+	if got, err := FromBinary(lb); err != nil || len(got.Points()) != 1 {
+		t.Errorf("unexpected decoding of a point over the default limits: %v", err)
+	}
+
+	// A truncated encoding is an error at any length.
+	for i := 0; i < len(b)-6; i++ {
+		if _, err := FromBinary(b[6 : 6+i]); err == nil {
+			t.Errorf("unexpected success for %d bytes", i)
+		}
+	}
+}
+
+func TestBatchPoints_BinarySize(t *testing.T) {
+	bp := newTestBatch(t, 1000)
+	b, err := AppendBinary(nil, bp)
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	j, err := json.Marshal(NewBatchPointsDocument(bp))
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if len(b)*2 > len(j) {
+		t.Errorf("unexpected binary size %d for JSON of %d bytes", len(b), len(j))
+	}
+}
+
+// assertSameBatch fails t unless got has the configuration and points of exp.
+func assertSameBatch(t *testing.T, exp, got BatchPoints) {
+	t.Helper()
+	if c, g := batchPointsConfig(exp), batchPointsConfig(got); c != g {
+		t.Errorf("unexpected config.  expected %+v, actual %+v", c, g)
+	}
+	expPoints, gotPoints := exp.Points(), got.Points()
+	if len(gotPoints) != len(expPoints) {
+		t.Fatalf("unexpected point count.  expected %v, actual %v", len(expPoints), len(gotPoints))
+	}
+	for i := range expPoints {
+		if gotPoints[i].String() != expPoints[i].String() {
+			t.Errorf("unexpected point %d.  expected %v, actual %v", i, expPoints[i], gotPoints[i])
+		}
+		expFields, _ := expPoints[i].Fields()
+		gotFields, _ := gotPoints[i].Fields()
+		if !reflect.DeepEqual(gotFields, expFields) {
+			t.Errorf("unexpected fields %d.  expected %#v, actual %#v", i, expFields, gotFields)
+		}
+	}
+}
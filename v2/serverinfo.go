@@ -0,0 +1,261 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+// ServerVersion is the version of a server, as major, minor and patch
+// numbers that compare in release order.
+type ServerVersion struct {
+	Major, Minor, Patch int
+
+	// Suffix is what follows the numbers, such as "c1.8.10" of an InfluxDB
+	// Enterprise build or "rc1" of a release candidate, without the
+	// separator.
+	Suffix string
+}
+
+// ParseServerVersion parses a version string sent by a server, such as
+// "1.8.10", "v2.7.1", "1.8.10-c1.8.10" of InfluxDB Enterprise or "1.9.0-dev".
+// The minor and patch numbers default to 0. It fails for a version that does
+// not start with a number, such as the "unknown" of a server built from
+// source.
+func ParseServerVersion(s string) (ServerVersion, error) {
+	core := strings.TrimPrefix(s, "v")
+	var v ServerVersion
+	if i := strings.IndexAny(core, "-~+ "); i >= 0 {
+		core, v.Suffix = core[:i], core[i+1:]
+	}
+	parts := strings.SplitN(core, ".", 3)
+	nums := []*int{&v.Major, &v.Minor, &v.Patch}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return ServerVersion{}, fmt.Errorf("invalid server version %q", s)
+		}
+		*nums[i] = n
+	}
+	return v, nil
+}
+
+// Compare returns -1, 0 or 1 if v is older than, the same as, or newer than
+// o. The suffixes are ignored.
+func (v ServerVersion) Compare(o ServerVersion) int {
+	for _, d := range [...]int{v.Major - o.Major, v.Minor - o.Minor, v.Patch - o.Patch} {
+		switch {
+		case d < 0:
+			return -1
+		case d > 0:
+			return 1
+		}
+	}
+	return 0
+}
+
+// AtLeast reports whether v is major.minor.patch or newer.
+func (v ServerVersion) AtLeast(major, minor, patch int) bool {
+	return v.Compare(ServerVersion{Major: major, Minor: minor, Patch: patch}) >= 0
+}
+
+func (v ServerVersion) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Suffix != "" {
+		s += "-" + v.Suffix
+	}
+	return s
+}
+
+// ServerInfo is the version of a server and the features of the client it
+// supports, as derived from the version.
+type ServerInfo struct {
+	// Version is the version the server reported, and Build its build type
+	// such as "OSS", "ENT" or "Cloud", if it told.
+	Version string
+	Build   string
+
+	// Parsed is Version parsed, the zero value if Development is set.
+	Parsed ServerVersion
+
+	// Development is set if Version could not be parsed, such as for a
+	// server built from source. No feature is taken as supported then.
+	Development bool
+
+	// SupportsUint64 is set for servers that take unsigned integer
+	// fields: InfluxDB 2.x and Cloud. InfluxDB 1.x only does when built
+	// with them.
+	SupportsUint64 bool
+
+	// SupportsMsgpack is set for servers that answer queries in
+	// MessagePack, see MsgpackFormat: InfluxDB 1.8 and later 1.x versions.
+	SupportsMsgpack bool
+
+	// SupportsHealthEndpoint is set for servers that have the /health
+	// endpoint, InfluxDB 1.8 and later.
+	SupportsHealthEndpoint bool
+
+	// SupportsV2CompatWrite is set for servers that have the /api/v2/write
+	// endpoint, see HTTPConfig.UseV2CompatWrite: InfluxDB 1.8 and later.
+	SupportsV2CompatWrite bool
+}
+
+// newServerInfo returns the ServerInfo of a server of version and build.
+func newServerInfo(version, build string) ServerInfo {
+	info := ServerInfo{Version: version, Build: build}
+	v, err := ParseServerVersion(version)
+	if err != nil && build != "Cloud" {
+		info.Development = true
+		return info
+	}
+	info.Parsed = v
+	v2 := v.Major >= 2 || build == "Cloud"
+	info.SupportsUint64 = v2
+	info.SupportsMsgpack = !v2 && v.AtLeast(1, 8, 0)
+	info.SupportsHealthEndpoint = v2 || v.AtLeast(1, 8, 0)
+	info.SupportsV2CompatWrite = v2 || v.AtLeast(1, 8, 0)
+	return info
+}
+
+// ServerInfoClient is implemented by the HTTP client.
+type ServerInfoClient interface {
+	// ServerInfo returns the version and the features of the server. The
+	// first call pings the server, and the version of its /health endpoint
+	// if the ping has none, the others return what it found until
+	// RefreshServerInfo.
+	ServerInfo(ctx context.Context) (ServerInfo, error)
+
+	// RefreshServerInfo asks the server for its version again, as after it
+	// was upgraded. The last info is kept if it fails.
+	RefreshServerInfo(ctx context.Context) (ServerInfo, error)
+}
+
+// ServerInfo returns the version and the features of the server.
+func (c *client) ServerInfo(ctx context.Context) (ServerInfo, error) {
+	c.infoMu.Lock()
+	defer c.infoMu.Unlock()
+	if c.info != nil {
+		return *c.info, nil
+	}
+	return c.refreshServerInfoLocked(ctx)
+}
+
+// RefreshServerInfo asks the server for its version again.
+func (c *client) RefreshServerInfo(ctx context.Context) (ServerInfo, error) {
+	c.infoMu.Lock()
+	defer c.infoMu.Unlock()
+	return c.refreshServerInfoLocked(ctx)
+}
+
+func (c *client) refreshServerInfoLocked(ctx context.Context) (ServerInfo, error) {
+	res, err := c.PingContext(ctx, 0)
+	if err != nil {
+		return ServerInfo{}, err
+	}
+	version := res.Version
+	if version == "" {
+		health, err := c.health(ctx)
+		if err != nil && !errors.Is(err, ErrNotSupported) {
+			return ServerInfo{}, err
+		}
+		version = health.Version
+	}
+	info := newServerInfo(version, res.Build)
+	c.info = &info
+	return info, nil
+}
+
+// cachedServerInfo returns the ServerInfo found by the last probe, if any.
+func (c *client) cachedServerInfo() (ServerInfo, bool) {
+	c.infoMu.Lock()
+	defer c.infoMu.Unlock()
+	if c.info == nil {
+		return ServerInfo{}, false
+	}
+	return *c.info, true
+}
+
+// supports reports whether the server supports the feature of has, asking
+// it for its version the first time. A server whose version cannot be told
+// is taken as not supporting it.
+func (c *client) supports(ctx context.Context, has func(ServerInfo) bool) bool {
+	info, err := c.ServerInfo(ctx)
+	return err == nil && has(info)
+}
+
+// queryFormat returns the format to ask the server for, deciding on the
+// AutoFormat.
+func (c *client) queryFormat(ctx context.Context) ResponseFormat {
+	if c.format != AutoFormat {
+		return c.format
+	}
+	if c.supports(ctx, func(info ServerInfo) bool { return info.SupportsMsgpack }) {
+		return MsgpackFormat
+	}
+	return JSONFormat
+}
+
+// UintEncoding is how the HTTP client writes unsigned integer fields.
+type UintEncoding int
+
+const (
+	// UintUnsigned writes them as unsigned integers, such as 1u, the
+	// default.
+	UintUnsigned UintEncoding = iota
+
+	// UintSigned writes them as signed integers, such as 1i, for servers
+	// that do not take unsigned ones. A value over math.MaxInt64 fails the
+	// write.
+	UintSigned
+
+	// UintAuto writes them as unsigned integers to servers whose ServerInfo
+	// has SupportsUint64, and as signed integers to the others and to a
+	// server that could not tell its version.
+	UintAuto
+)
+
+// signedUints reports whether the unsigned integer fields of a write are
+// written as signed integers.
+func (c *client) signedUints(ctx context.Context) bool {
+	switch c.uints {
+	case UintSigned:
+		return true
+	case UintAuto:
+		return !c.supports(ctx, func(info ServerInfo) bool { return info.SupportsUint64 })
+	}
+	return false
+}
+
+// signedUintPoint returns pt with its unsigned integer fields as signed
+// integers, or pt itself if it has none.
+func signedUintPoint(pt models.Point) (models.Point, error) {
+	it := pt.FieldIterator()
+	var unsigned bool
+	for it.Next() {
+		if it.Type() == models.Unsigned {
+			unsigned = true
+			break
+		}
+	}
+	if !unsigned {
+		return pt, nil
+	}
+	fields, err := pt.Fields()
+	if err != nil {
+		return nil, err
+	}
+	opts := PointOptions{SignedIntegers: true}
+	for k, v := range fields {
+		if _, ok := v.(uint64); !ok {
+			continue
+		}
+		if fields[k], _, err = opts.convertField(k, v); err != nil {
+			return nil, err
+		}
+	}
+	return models.NewPoint(string(pt.Name()), pt.Tags(), fields, pt.Time())
+}
@@ -0,0 +1,219 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestParseServerVersion(t *testing.T) {
+	for _, tt := range []struct {
+		s   string
+		exp ServerVersion
+		err bool
+	}{
+		{s: "1.8.10", exp: ServerVersion{Major: 1, Minor: 8, Patch: 10}},
+		{s: "v2.7.1", exp: ServerVersion{Major: 2, Minor: 7, Patch: 1}},
+		{s: "1.8.10-c1.8.10", exp: ServerVersion{Major: 1, Minor: 8, Patch: 10, Suffix: "c1.8.10"}},
+		{s: "1.9.0-dev", exp: ServerVersion{Major: 1, Minor: 9, Suffix: "dev"}},
+		{s: "1.8.0~rc1", exp: ServerVersion{Major: 1, Minor: 8, Suffix: "rc1"}},
+		{s: "1.7", exp: ServerVersion{Major: 1, Minor: 7}},
+		{s: "unknown", err: true},
+		{s: "", err: true},
+		{s: "1.x.0", err: true},
+	} {
+		v, err := ParseServerVersion(tt.s)
+		if (err != nil) != tt.err {
+			t.Errorf("unexpected error for %q.  expected %v, actual %v", tt.s, tt.err, err)
+		}
+		if v != tt.exp {
+			t.Errorf("unexpected version for %q.  expected %+v, actual %+v", tt.s, tt.exp, v)
+		}
+	}
+}
+
+func TestServerVersion_Compare(t *testing.T) {
+	v, _ := ParseServerVersion("1.8.10-c1.8.10")
+	if !v.AtLeast(1, 8, 0) || !v.AtLeast(1, 8, 10) || v.AtLeast(1, 8, 11) || v.AtLeast(2, 0, 0) {
+		t.Errorf("unexpected comparisons of %v", v)
+	}
+	if got := v.String(); got != "1.8.10-c1.8.10" {
+		t.Errorf("unexpected string.  expected %v, actual %v", "1.8.10-c1.8.10", got)
+	}
+}
+
+func TestNewServerInfo(t *testing.T) {
+	for _, tt := range []struct {
+		version, build               string
+		dev, uint64, msgpack, health bool
+	}{
+		{version: "1.7.11"},
+		{version: "1.8.10", msgpack: true, health: true},
+		{version: "1.8.10-c1.8.10", build: "ENT", msgpack: true, health: true},
+		{version: "1.9.0-dev", msgpack: true, health: true},
+		{version: "v2.7.1", uint64: true, health: true},
+		{version: "", build: "Cloud", uint64: true, health: true},
+		{version: "unknown", dev: true},
+	} {
+		info := newServerInfo(tt.version, tt.build)
+		if info.Development != tt.dev || info.SupportsUint64 != tt.uint64 || info.SupportsMsgpack != tt.msgpack ||
+			info.SupportsHealthEndpoint != tt.health || info.SupportsV2CompatWrite != tt.health {
+			t.Errorf("unexpected info for %q %q: %+v", tt.version, tt.build, info)
+		}
+	}
+}
+
+// infoServer answers pings with the version it holds, and records the
+// number of pings and the last write and query request.
+type infoServer struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	version string
+	pings   int32
+	accept  string
+	body    string
+}
+
+func newInfoServer(version string) *infoServer {
+	s := &infoServer{version: version}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		w.Header().Set("X-Influxdb-Version", s.version)
+		switch r.URL.Path {
+		case "/ping":
+			atomic.AddInt32(&s.pings, 1)
+			w.WriteHeader(http.StatusNoContent)
+		case "/write":
+			b, _ := ioutil.ReadAll(r.Body)
+			s.body = string(b)
+			w.WriteHeader(http.StatusNoContent)
+		case "/query":
+			s.accept = r.Header.Get("Accept")
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"results":[{"statement_id":0}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	return s
+}
+
+func (s *infoServer) setVersion(version string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.version = version
+}
+
+func (s *infoServer) last() (accept, body string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.accept, s.body
+}
+
+func TestClient_ServerInfo(t *testing.T) {
+	ts := newInfoServer("1.7.11")
+	defer ts.Close()
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+	sc := c.(ServerInfoClient)
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		info, err := sc.ServerInfo(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+		if info.Parsed.Minor != 7 || info.SupportsHealthEndpoint {
+			t.Errorf("unexpected info: %+v", info)
+		}
+	}
+	if n := atomic.LoadInt32(&ts.pings); n != 1 {
+		t.Errorf("unexpected pings.  expected %v, actual %v", 1, n)
+	}
+
+	// The server is known to have no /health endpoint.
+	if _, err := c.(HealthClient).Health(ctx); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("unexpected error.  expected %v, actual %v", ErrNotSupported, err)
+	}
+
+	ts.setVersion("1.8.10-c1.8.10")
+	info, err := sc.RefreshServerInfo(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if !info.SupportsMsgpack || info.Parsed.Suffix != "c1.8.10" {
+		t.Errorf("unexpected info: %+v", info)
+	}
+	if n := atomic.LoadInt32(&ts.pings); n != 2 {
+		t.Errorf("unexpected pings.  expected %v, actual %v", 2, n)
+	}
+}
+
+func TestClient_AutoFormat(t *testing.T) {
+	for _, tt := range []struct {
+		version, accept string
+	}{
+		{"1.7.11", ""},
+		{"1.8.10", msgpackContentType},
+		{"unknown", ""},
+	} {
+		ts := newInfoServer(tt.version)
+		c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, ResponseFormat: AutoFormat})
+		if _, err := c.Query(Query{Command: "SHOW DATABASES"}); err != nil {
+			t.Errorf("unexpected error for %s.  expected %v, actual %v", tt.version, nil, err)
+		}
+		if accept, _ := ts.last(); accept != tt.accept {
+			t.Errorf("unexpected Accept header for %s.  expected %q, actual %q", tt.version, tt.accept, accept)
+		}
+		c.Close()
+		ts.Close()
+	}
+}
+
+func TestClient_UintEncoding(t *testing.T) {
+	for _, tt := range []struct {
+		version  string
+		encoding UintEncoding
+		exp      string
+	}{
+		{"1.8.10", UintUnsigned, "cpu value=1u\n"},
+		{"1.8.10", UintSigned, "cpu value=1i\n"},
+		{"1.8.10", UintAuto, "cpu value=1i\n"},
+		{"v2.7.1", UintAuto, "cpu value=1u\n"},
+		{"unknown", UintAuto, "cpu value=1i\n"},
+	} {
+		ts := newInfoServer(tt.version)
+		c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, UintEncoding: tt.encoding})
+		bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db"})
+		pt, _ := NewPoint("cpu", nil, map[string]interface{}{"value": uint64(1)})
+		bp.AddPoint(pt)
+		if err := c.Write(bp); err != nil {
+			t.Errorf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+		if _, body := ts.last(); body != tt.exp {
+			t.Errorf("unexpected body for %s and %d.  expected %q, actual %q", tt.version, tt.encoding, tt.exp, body)
+		}
+		c.Close()
+		ts.Close()
+	}
+
+	// A value a signed integer cannot hold fails the write.
+	ts := newInfoServer("1.8.10")
+	defer ts.Close()
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, UintEncoding: UintSigned})
+	defer c.Close()
+	bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db"})
+	pt, _ := NewPoint("cpu", nil, map[string]interface{}{"value": uint64(math.MaxUint64)})
+	bp.AddPoint(pt)
+	if err := c.Write(bp); err == nil {
+		t.Error("expected an error for an overflowing value")
+	}
+}
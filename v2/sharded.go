@@ -0,0 +1,852 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+const (
+	// DefaultShardedQueryWindows is the default number of windows, at most,
+	// QuerySharded splits the time range into.
+	DefaultShardedQueryWindows = 8
+
+	// DefaultShardedQueryConcurrency is the default number of windows
+	// QuerySharded queries at once.
+	DefaultShardedQueryConcurrency = 4
+)
+
+// ErrUnshardableQuery is wrapped by the errors of QuerySharded for the
+// queries whose results would differ once split into time windows, or whose
+// time range cannot be told.
+var ErrUnshardableQuery = errors.New("query cannot be split by time")
+
+// ShardedQueryOptions configures QuerySharded.
+type ShardedQueryOptions struct {
+	// Start and End are the time range to split, End excluded. They are
+	// optional if the WHERE clause of the query bounds time on both ends
+	// with literal times, bound parameters or now(), as in
+	// time >= now() - 1d AND time < now(). The conditions of the query are
+	// kept either way, so that the range queried is their intersection.
+	Start, End time.Time
+
+	// Windows is the number of windows, at most, the range is split into.
+	// It defaults to DefaultShardedQueryWindows.
+	Windows int
+
+	// Align is the duration the bounds of the windows are multiples of
+	// since the Unix epoch, such as the shard group duration of the
+	// retention policy, so that every window reads as few shards as
+	// possible. It defaults to the GROUP BY time interval of the query, and
+	// must be a multiple of it.
+	Align time.Duration
+
+	// Concurrency is the number of windows queried at once. It defaults to
+	// DefaultShardedQueryConcurrency.
+	Concurrency int
+}
+
+// ShardedQueryError is returned by QuerySharded when the query of a
+// window failed.
+type ShardedQueryError struct {
+	// Start and End are the window, End excluded.
+	Start, End time.Time
+
+	Err error
+}
+
+func (e *ShardedQueryError) Error() string {
+	return fmt.Sprintf("query of the window from %s to %s: %v",
+		e.Start.UTC().Format(time.RFC3339Nano), e.End.UTC().Format(time.RFC3339Nano), e.Err)
+}
+
+func (e *ShardedQueryError) Unwrap() error { return e.Err }
+
+// QuerySharded runs the SELECT statement of q as one query per time window
+// of opts, each with the WHERE clause of q and the bounds of the window,
+// and merges the results as if q had been run whole: the rows of a series
+// are in time order, and a row repeated at the end of a window and the
+// start of the next one is kept once. The series are sorted by measurement
+// and tags, as the server does. The first window to fail cancels the
+// others, and its error is returned as a *ShardedQueryError.
+//
+// The queries whose results would differ once split are refused with an
+// error wrapping ErrUnshardableQuery: aggregates and selectors without
+// GROUP BY time, GROUP BY time intervals the windows would straddle,
+// transformations across rows such as derivative, fill(previous) and
+// fill(linear), LIMIT, OFFSET, SLIMIT and SOFFSET, INTO and subqueries.
+// The time of now() in the WHERE clause is the time of the client.
+func QuerySharded(ctx context.Context, c Client, q Query, opts ShardedQueryOptions) (*Response, error) {
+	statements := q.Statements()
+	if len(statements) != 1 {
+		return nil, fmt.Errorf("%w: %d statements", ErrUnshardableQuery, len(statements))
+	}
+	stmt, err := parseShardedStatement(statements[0])
+	if err != nil {
+		return nil, err
+	}
+	windows, err := stmt.windows(opts, time.Now(), q.Parameters)
+	if err != nil {
+		return nil, err
+	}
+	concurrency := opts.Concurrency
+	if concurrency == 0 {
+		concurrency = DefaultShardedQueryConcurrency
+	}
+
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	responses := make([]*Response, len(windows))
+	indices := make(chan int)
+	var mu sync.Mutex
+	var failed *ShardedQueryError
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency && i < len(windows); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				w := windows[i]
+				sub := q
+				sub.Command = stmt.window(w[0], w[1])
+				resp, err := queryContext(ctx, c, sub)
+				if err == nil {
+					err = resp.Error()
+				}
+
+				mu.Lock()
+				responses[i] = resp
+				if err != nil && failed == nil {
+					failed = &ShardedQueryError{Start: w[0], End: w[1], Err: err}
+					cancel()
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := range windows {
+		if ctx.Err() != nil {
+			break
+		}
+		select {
+		case indices <- i:
+		case <-ctx.Done():
+		}
+	}
+	close(indices)
+	wg.Wait()
+
+	if err := parent.Err(); err != nil {
+		return nil, err
+	}
+	if failed != nil {
+		return nil, failed
+	}
+	return mergeShards(responses, q.Command, stmt.desc), nil
+}
+
+// shardedStatement is a SELECT statement split around its WHERE clause.
+type shardedStatement struct {
+	// head and tail are the statement before and after the WHERE clause,
+	// and where its condition, empty if there is none.
+	head, where, tail string
+
+	// interval and offset are those of GROUP BY time, 0 if there is none.
+	// The offset is within [0, interval).
+	interval, offset time.Duration
+
+	// desc is whether the rows are in descending time order.
+	desc bool
+}
+
+// rowFunctions are the InfluxQL functions computed row by row, which do
+// not need GROUP BY time to be split.
+var rowFunctions = map[string]bool{
+	"abs": true, "acos": true, "asin": true, "atan": true, "atan2": true,
+	"ceil": true, "cos": true, "exp": true, "floor": true, "ln": true,
+	"log": true, "log2": true, "log10": true, "pow": true, "round": true,
+	"sin": true, "sqrt": true, "tan": true,
+}
+
+// crossRowFunctions are the InfluxQL functions computed from the rows
+// before, whose first rows of a window would miss those of the previous
+// one whatever the windows.
+var crossRowFunctions = map[string]bool{
+	"cumulative_sum": true, "derivative": true, "difference": true,
+	"elapsed": true, "holt_winters": true, "holt_winters_with_fit": true,
+	"moving_average": true, "non_negative_derivative": true,
+	"non_negative_difference": true,
+}
+
+// stmtWord is a word of a statement outside of parentheses, quotes and
+// comments, upper-cased.
+type stmtWord struct {
+	word       string
+	start, end int
+}
+
+// topLevelWords returns the words of stmt outside of parentheses, quotes
+// and comments, and the end of stmt without the comments that end it.
+func topLevelWords(stmt string) ([]stmtWord, int) {
+	var words []stmtWord
+	end, depth := 0, 0
+	for i := 0; i < len(stmt); {
+		s := stmt[i:]
+		switch c := s[0]; {
+		case strings.HasPrefix(s, "--"):
+			if n := strings.IndexByte(s, '\n'); n >= 0 {
+				i += n + 1
+			} else {
+				i = len(stmt)
+			}
+			continue
+		case strings.HasPrefix(s, "/*"):
+			if n := strings.Index(s[2:], "*/"); n >= 0 {
+				i += n + 4
+			} else {
+				i = len(stmt)
+			}
+			continue
+		case unicode.IsSpace(rune(c)):
+			i++
+			continue
+		case c == '\'' || c == '"':
+			i += quotedLen(s)
+		case c == '(':
+			depth++
+			i++
+		case c == ')':
+			depth--
+			i++
+		case isWordByte(c):
+			n := 1
+			for n < len(s) && isWordByte(s[n]) {
+				n++
+			}
+			if depth == 0 {
+				words = append(words, stmtWord{word: strings.ToUpper(s[:n]), start: i, end: i + n})
+			}
+			i += n
+		default:
+			i++
+		}
+		end = i
+	}
+	return words, end
+}
+
+// parseShardedStatement splits stmt around its WHERE clause, and refuses
+// it if its results would differ once split into time windows.
+func parseShardedStatement(stmt string) (*shardedStatement, error) {
+	refuse := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: %q: %s", ErrUnshardableQuery, stmt, fmt.Sprintf(format, args...))
+	}
+	words, end := topLevelWords(stmt)
+	if len(words) == 0 || words[0].word != "SELECT" {
+		return nil, refuse("not a SELECT statement")
+	}
+
+	from, where, group, order, tail := -1, -1, -1, -1, -1
+	for i, w := range words {
+		next := ""
+		if i+1 < len(words) {
+			next = words[i+1].word
+		}
+		switch {
+		case w.word == "INTO":
+			return nil, refuse("SELECT INTO writes the results")
+		case w.word == "LIMIT" || w.word == "OFFSET" || w.word == "SLIMIT" || w.word == "SOFFSET":
+			return nil, refuse("%s applies to the whole results", w.word)
+		case from < 0:
+			if w.word == "FROM" {
+				from = i
+			}
+			continue
+		case w.word == "WHERE" && where < 0:
+			where = i
+		case w.word == "GROUP" && next == "BY":
+			group = i
+		case w.word == "ORDER" && next == "BY":
+			order = i
+		case w.word == "FILL" || w.word == "TZ":
+		default:
+			continue
+		}
+		if w.word != "WHERE" && tail < 0 {
+			tail = i
+		}
+	}
+	if from < 0 {
+		return nil, refuse("no FROM clause")
+	}
+	if strings.HasPrefix(strings.TrimLeftFunc(stmt[words[from].end:], unicode.IsSpace), "(") {
+		return nil, refuse("subqueries are not supported")
+	}
+
+	// clauseEnd returns the end of the clause starting at the word i.
+	clauseEnd := func(i int) int {
+		for _, w := range words[i+1:] {
+			switch w.word {
+			case "WHERE", "GROUP", "ORDER", "FILL", "TZ":
+				return w.start
+			}
+		}
+		return end
+	}
+	s := &shardedStatement{}
+	if group >= 0 {
+		if err := s.parseGroupBy(stmt[words[group].end:clauseEnd(group+1)]); err != nil {
+			return nil, refuse("%v", err)
+		}
+	}
+	if order >= 0 {
+		for _, w := range words[order+1:] {
+			if w.start >= clauseEnd(order+1) {
+				break
+			}
+			s.desc = s.desc || w.word == "DESC"
+		}
+	}
+	for _, w := range words {
+		if w.word == "FILL" {
+			arg := strings.TrimLeftFunc(stmt[w.end:], unicode.IsSpace)
+			arg = strings.ToLower(strings.TrimLeftFunc(strings.TrimPrefix(arg, "("), unicode.IsSpace))
+			if strings.HasPrefix(arg, "previous") || strings.HasPrefix(arg, "linear") {
+				return nil, refuse("fill(previous) and fill(linear) fill the first buckets of a window from the previous one")
+			}
+		}
+	}
+	for _, fn := range selectFunctions(stmt[words[0].end:words[from].start]) {
+		switch {
+		case crossRowFunctions[fn]:
+			return nil, refuse("%s depends on the rows of the previous windows", fn)
+		case !rowFunctions[fn] && s.interval == 0:
+			return nil, refuse("%s without GROUP BY time aggregates the whole range", fn)
+		}
+	}
+
+	switch {
+	case where >= 0:
+		s.head = stmt[:words[where].start]
+		e := clauseEnd(where)
+		s.where = strings.TrimSpace(stmt[words[where].end:e])
+		s.tail = stmt[e:end]
+	case tail >= 0:
+		s.head = stmt[:words[tail].start]
+		s.tail = stmt[words[tail].start:end]
+	default:
+		s.head = stmt[:end]
+	}
+	s.head = strings.TrimRightFunc(s.head, unicode.IsSpace) + " "
+	if s.tail = strings.TrimSpace(s.tail); s.tail != "" {
+		s.tail = " " + s.tail
+	}
+	return s, nil
+}
+
+// parseGroupBy records the interval and offset of the time dimension of
+// the dimensions of a GROUP BY clause.
+func (s *shardedStatement) parseGroupBy(dimensions string) error {
+	i := findKeyword(dimensions, "time")
+	if i < 0 {
+		return nil
+	}
+	rest := strings.TrimLeftFunc(dimensions[i+len("time"):], unicode.IsSpace)
+	n := strings.IndexByte(rest, ')')
+	if !strings.HasPrefix(rest, "(") || n < 0 {
+		return nil
+	}
+	args := strings.Split(rest[1:n], ",")
+	interval, err := parseDuration(strings.TrimSpace(args[0]))
+	if err != nil || interval <= 0 || interval == InfiniteDuration {
+		return fmt.Errorf("invalid GROUP BY time interval %q", strings.TrimSpace(args[0]))
+	}
+	s.interval = interval
+	if len(args) > 1 {
+		arg := strings.TrimSpace(args[1])
+		offset, err := parseDuration(strings.TrimPrefix(arg, "-"))
+		if err != nil || offset == InfiniteDuration {
+			return fmt.Errorf("unsupported GROUP BY time offset %q", arg)
+		}
+		if strings.HasPrefix(arg, "-") {
+			offset = -offset
+		}
+		if offset %= interval; offset < 0 {
+			offset += interval
+		}
+		s.offset = offset
+	}
+	return nil
+}
+
+// selectFunctions returns the names, lower-cased, of the functions called
+// in the fields of a SELECT statement.
+func selectFunctions(fields string) []string {
+	var names []string
+	for i := 0; i < len(fields); {
+		s := fields[i:]
+		switch c := s[0]; {
+		case c == '\'' || c == '"':
+			i += quotedLen(s)
+		case isWordByte(c):
+			n := 1
+			for n < len(s) && isWordByte(s[n]) {
+				n++
+			}
+			if strings.HasPrefix(strings.TrimLeftFunc(s[n:], unicode.IsSpace), "(") {
+				names = append(names, strings.ToLower(s[:n]))
+			}
+			i += n
+		default:
+			i++
+		}
+	}
+	return names
+}
+
+// windows returns the windows, start and end, of the statement for opts.
+func (s *shardedStatement) windows(opts ShardedQueryOptions, now time.Time, params map[string]interface{}) ([][2]time.Time, error) {
+	switch {
+	case opts.Windows < 0:
+		return nil, &ConfigError{Field: "Windows", Reason: "must not be negative"}
+	case opts.Concurrency < 0:
+		return nil, &ConfigError{Field: "Concurrency", Reason: "must not be negative"}
+	case opts.Align < 0:
+		return nil, &ConfigError{Field: "Align", Reason: "must not be negative"}
+	case opts.Start.IsZero() != opts.End.IsZero():
+		return nil, &ConfigError{Field: "End", Reason: "Start and End must be set together"}
+	case !opts.Start.IsZero() && !opts.End.After(opts.Start):
+		return nil, &ConfigError{Field: "End", Reason: "not after Start"}
+	}
+
+	var start, end int64
+	if opts.Start.IsZero() {
+		var b timeBounds
+		if err := b.add(s.where, now, params); err != nil {
+			return nil, fmt.Errorf("%w: %v, set Start and End", ErrUnshardableQuery, err)
+		}
+		if !b.hasLower || !b.hasUpper {
+			return nil, fmt.Errorf("%w: the WHERE clause does not bound time on both ends, set Start and End", ErrUnshardableQuery)
+		}
+		start, end = b.lower, b.upper
+	} else {
+		start, end = opts.Start.UnixNano(), opts.End.UnixNano()
+	}
+
+	align := int64(opts.Align)
+	if s.interval > 0 {
+		switch {
+		case align == 0:
+			align = int64(s.interval)
+		case align%int64(s.interval) != 0 || s.offset != 0:
+			return nil, fmt.Errorf("%w: windows aligned to %s would straddle the buckets of GROUP BY time(%s, %s)",
+				ErrUnshardableQuery, FormatDuration(opts.Align), FormatDuration(s.interval), FormatDuration(s.offset))
+		}
+	}
+	n := int64(opts.Windows)
+	if n == 0 {
+		n = DefaultShardedQueryWindows
+	}
+
+	base := start
+	if align > 0 {
+		base = alignTime(time.Unix(0, start-int64(s.offset)), time.Duration(align), false).UnixNano() + int64(s.offset)
+	}
+	size := (end - base + n - 1) / n
+	if align > 0 {
+		size = (size + align - 1) / align * align
+	}
+	if size <= 0 {
+		return [][2]time.Time{{time.Unix(0, start).UTC(), time.Unix(0, end).UTC()}}, nil
+	}
+	var windows [][2]time.Time
+	for t := base; t < end; t += size {
+		w := [2]int64{t, t + size}
+		if w[0] < start {
+			w[0] = start
+		}
+		if w[1] > end {
+			w[1] = end
+		}
+		windows = append(windows, [2]time.Time{time.Unix(0, w[0]).UTC(), time.Unix(0, w[1]).UTC()})
+	}
+	return windows, nil
+}
+
+// window returns the statement restricted to the times from start to end,
+// end excluded.
+func (s *shardedStatement) window(start, end time.Time) string {
+	cond := Time().Gte(start).And(Time().Lt(end)).s
+	if s.where != "" {
+		cond = "(" + s.where + ") AND " + cond
+	}
+	return s.head + "WHERE " + cond + s.tail
+}
+
+// timeBounds is the range of times, in nanoseconds since the Unix epoch,
+// from lower to upper excluded, that a condition selects.
+type timeBounds struct {
+	lower, upper       int64
+	hasLower, hasUpper bool
+}
+
+// add narrows the bounds to those of the comparisons of time with literal
+// times of cond joined by AND. It fails on the other conditions on time.
+func (b *timeBounds) add(cond string, now time.Time, params map[string]interface{}) error {
+	cond = trimParens(cond)
+	if cond == "" {
+		return nil
+	}
+	if parts := splitCond(cond, "OR"); len(parts) > 1 {
+		if mentionsTime(cond) {
+			return fmt.Errorf("cannot bound time in the OR condition %q", cond)
+		}
+		return nil
+	}
+	if parts := splitCond(cond, "AND"); len(parts) > 1 {
+		for _, part := range parts {
+			if err := b.add(part, now, params); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	left, op, right := splitComparison(cond)
+	if isTimeRef(right) {
+		left, right = right, left
+		switch op {
+		case "<":
+			op = ">"
+		case "<=":
+			op = ">="
+		case ">":
+			op = "<"
+		case ">=":
+			op = "<="
+		}
+	}
+	if !isTimeRef(left) {
+		if mentionsTime(cond) {
+			return fmt.Errorf("cannot bound time in the condition %q", cond)
+		}
+		return nil
+	}
+	t, err := parseTimeLiteral(right, now, params)
+	if err != nil {
+		return fmt.Errorf("cannot bound time in the condition %q: %v", cond, err)
+	}
+	switch op {
+	case ">=":
+		b.setLower(t)
+	case ">":
+		b.setLower(t + 1)
+	case "<":
+		b.setUpper(t)
+	case "<=":
+		b.setUpper(t + 1)
+	case "=":
+		b.setLower(t)
+		b.setUpper(t + 1)
+	default:
+		return fmt.Errorf("cannot bound time in the condition %q", cond)
+	}
+	return nil
+}
+
+func (b *timeBounds) setLower(t int64) {
+	if !b.hasLower || t > b.lower {
+		b.lower, b.hasLower = t, true
+	}
+}
+
+func (b *timeBounds) setUpper(t int64) {
+	if !b.hasUpper || t < b.upper {
+		b.upper, b.hasUpper = t, true
+	}
+}
+
+// trimParens removes the spaces around cond and the parentheses enclosing
+// it whole.
+func trimParens(cond string) string {
+	for {
+		cond = strings.TrimSpace(cond)
+		if !strings.HasPrefix(cond, "(") || condDepthEnd(cond) != len(cond)-1 {
+			return cond
+		}
+		cond = cond[1 : len(cond)-1]
+	}
+}
+
+// condDepthEnd returns the index of the parenthesis closing the one
+// opening cond, or -1.
+func condDepthEnd(cond string) int {
+	depth := 0
+	for i := 0; i < len(cond); i++ {
+		switch c := cond[i]; c {
+		case '\'', '"':
+			i += quotedLen(cond[i:]) - 1
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// splitCond splits cond at the keyword op, such as AND, outside of
+// parentheses and quotes.
+func splitCond(cond, op string) []string {
+	var parts []string
+	start, depth := 0, 0
+	for i := 0; i < len(cond); {
+		switch c := cond[i]; {
+		case c == '\'' || c == '"':
+			i += quotedLen(cond[i:])
+		case c == '(':
+			depth++
+			i++
+		case c == ')':
+			depth--
+			i++
+		case isWordByte(c):
+			n := i + 1
+			for n < len(cond) && isWordByte(cond[n]) {
+				n++
+			}
+			if depth == 0 && strings.EqualFold(cond[i:n], op) {
+				parts = append(parts, cond[start:i])
+				start = n
+			}
+			i = n
+		default:
+			i++
+		}
+	}
+	return append(parts, cond[start:])
+}
+
+// splitComparison splits cond at its comparison operator outside of
+// parentheses and quotes. op is empty if there is none.
+func splitComparison(cond string) (left, op, right string) {
+	depth := 0
+	for i := 0; i < len(cond); i++ {
+		switch c := cond[i]; c {
+		case '\'', '"':
+			i += quotedLen(cond[i:]) - 1
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case '<', '>', '=', '!':
+			if depth != 0 {
+				continue
+			}
+			n := 1
+			if i+1 < len(cond) && strings.IndexByte("=>~", cond[i+1]) >= 0 {
+				n = 2
+			}
+			return strings.TrimSpace(cond[:i]), cond[i : i+n], strings.TrimSpace(cond[i+n:])
+		}
+	}
+	return strings.TrimSpace(cond), "", ""
+}
+
+func isTimeRef(s string) bool {
+	return strings.EqualFold(s, "time") || s == `"time"`
+}
+
+func mentionsTime(cond string) bool {
+	return findKeyword(cond, "time") >= 0 || strings.Contains(cond, `"time"`)
+}
+
+// parseTimeLiteral returns the time, in nanoseconds since the Unix epoch,
+// of an InfluxQL time literal: an RFC3339 or date string, an integer of
+// nanoseconds or a duration since the epoch, now() plus or minus
+// durations, or a bound parameter of one of these.
+func parseTimeLiteral(s string, now time.Time, params map[string]interface{}) (int64, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "$") {
+		switch v := params[s[1:]].(type) {
+		case time.Time:
+			return v.UnixNano(), nil
+		case int:
+			return int64(v), nil
+		case int64:
+			return v, nil
+		case string:
+			return parseTimeLiteral(QuoteString(v), now, nil)
+		case nil:
+			return 0, fmt.Errorf("no parameter %s", s)
+		default:
+			return 0, fmt.Errorf("unsupported time parameter %s of type %T", s, v)
+		}
+	}
+	if strings.HasPrefix(s, "'") {
+		v := strings.TrimSuffix(s[1:], "'")
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999", "2006-01-02"} {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t.UnixNano(), nil
+			}
+		}
+		return 0, fmt.Errorf("invalid time %s", s)
+	}
+	if len(s) >= len("now()") && strings.EqualFold(s[:len("now()")], "now()") {
+		t := now.UnixNano()
+		for rest := strings.TrimSpace(s[len("now()"):]); rest != ""; {
+			sign := rest[0]
+			if sign != '+' && sign != '-' {
+				return 0, fmt.Errorf("invalid time %s", s)
+			}
+			rest = strings.TrimSpace(rest[1:])
+			n := strings.IndexAny(rest, " \t\n\r+-")
+			if n < 0 {
+				n = len(rest)
+			}
+			d, err := parseDuration(rest[:n])
+			if err != nil || d == InfiniteDuration {
+				return 0, fmt.Errorf("invalid time %s", s)
+			}
+			if sign == '-' {
+				d = -d
+			}
+			t += int64(d)
+			rest = strings.TrimSpace(rest[n:])
+		}
+		return t, nil
+	}
+	if ns, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return ns, nil
+	}
+	if d, err := parseDuration(s); err == nil && d != InfiniteDuration {
+		return int64(d), nil
+	}
+	return 0, fmt.Errorf("invalid time %s", s)
+}
+
+// mergeShards merges the responses of the windows, in time order, into the
+// response of command.
+func mergeShards(responses []*Response, command string, desc bool) *Response {
+	if desc {
+		reversed := make([]*Response, len(responses))
+		for i, resp := range responses {
+			reversed[len(responses)-1-i] = resp
+		}
+		responses = reversed
+	}
+	merged := &Response{command: command}
+	result := Result{}
+	series := make(map[string]int)
+	messages := make(map[Message]bool)
+	for i, resp := range responses {
+		if i == 0 {
+			merged.Header, merged.precision = resp.Header, resp.precision
+		}
+		for _, r := range resp.Results {
+			result.Partial = result.Partial || r.Partial
+			for _, m := range r.Messages {
+				if !messages[*m] {
+					messages[*m] = true
+					result.Messages = append(result.Messages, m)
+				}
+			}
+			for _, row := range r.Series {
+				key := rowKey(row)
+				j, ok := series[key]
+				if !ok {
+					j = len(result.Series)
+					series[key] = j
+					result.Series = append(result.Series, models.Row{Name: row.Name, Tags: row.Tags, Columns: append([]string(nil), row.Columns...)})
+				}
+				appendRows(&result.Series[j], row)
+			}
+		}
+	}
+	sort.SliceStable(result.Series, func(i, j int) bool {
+		if a, b := result.Series[i], result.Series[j]; a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return tagsKey(result.Series[i].Tags) < tagsKey(result.Series[j].Tags)
+	})
+	merged.Results = []Result{result}
+	return merged
+}
+
+// appendRows appends the values of src to dst, adding the columns dst
+// misses. The first row of src is dropped if it repeats the last one of
+// dst.
+func appendRows(dst *models.Row, src models.Row) {
+	values := src.Values
+	if !reflect.DeepEqual(dst.Columns, src.Columns) {
+		index := make([]int, len(src.Columns))
+		for i, col := range src.Columns {
+			index[i] = -1
+			for j, have := range dst.Columns {
+				if have == col {
+					index[i] = j
+				}
+			}
+			if index[i] < 0 {
+				index[i] = len(dst.Columns)
+				dst.Columns = append(dst.Columns, col)
+			}
+		}
+		for i, v := range dst.Values {
+			for len(v) < len(dst.Columns) {
+				v = append(v, nil)
+			}
+			dst.Values[i] = v
+		}
+		values = make([][]interface{}, len(src.Values))
+		for i, v := range src.Values {
+			values[i] = make([]interface{}, len(dst.Columns))
+			for j, x := range v {
+				if j < len(index) {
+					values[i][index[j]] = x
+				}
+			}
+		}
+	}
+	if n := len(dst.Values); n > 0 && len(values) > 0 && reflect.DeepEqual(dst.Values[n-1], values[0]) {
+		values = values[1:]
+	}
+	dst.Values = append(dst.Values, values...)
+	dst.Partial = dst.Partial || src.Partial
+}
+
+// rowKey identifies the series of row among those of a result.
+func rowKey(row models.Row) string {
+	return row.Name + "\x00" + tagsKey(row.Tags)
+}
+
+// tagsKey returns the tags sorted by key, as in host=a,region=eu.
+func tagsKey(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(k + "=" + tags[k])
+	}
+	return b.String()
+}
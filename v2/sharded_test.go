@@ -0,0 +1,299 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+// shardClient answers the queries of QuerySharded with answer, given the
+// start of the window of the query, and records the commands. It is a
+// ContextClient, so that the windows answered last see the cancellation.
+type shardClient struct {
+	*batchRecorder
+	mu       sync.Mutex
+	commands []string
+	answer   func(ctx context.Context, start time.Time) (*Response, error)
+}
+
+func (c *shardClient) Query(q Query) (*Response, error) {
+	return c.QueryContext(context.Background(), q)
+}
+
+func (c *shardClient) WriteContext(ctx context.Context, bp BatchPoints) error {
+	return c.Write(bp)
+}
+
+func (c *shardClient) QueryAsChunkContext(ctx context.Context, q Query) (*ChunkedResponse, error) {
+	return nil, nil
+}
+
+func (c *shardClient) QueryContext(ctx context.Context, q Query) (*Response, error) {
+	c.mu.Lock()
+	c.commands = append(c.commands, q.Command)
+	c.mu.Unlock()
+
+	// The window is the last condition: AND (time >= '...' AND time < '...').
+	i := strings.LastIndex(q.Command, "(time >= '")
+	if i < 0 {
+		return nil, errors.New("no window in " + q.Command)
+	}
+	s := q.Command[i+len("(time >= '"):]
+	start, err := time.Parse(time.RFC3339Nano, s[:strings.IndexByte(s, '\'')])
+	if err != nil {
+		return nil, err
+	}
+	return c.answer(ctx, start)
+}
+
+// windows returns the windows of the commands, sorted.
+func (c *shardClient) windows() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var windows []string
+	for _, cmd := range c.commands {
+		w := cmd[strings.LastIndex(cmd, "(time >= "):]
+		windows = append(windows, w[:strings.IndexByte(w, ')')+1])
+	}
+	sort.Strings(windows)
+	return windows
+}
+
+func shardResponse(rows ...models.Row) *Response {
+	return &Response{Results: []Result{{Series: rows}}}
+}
+
+func TestQuerySharded_Merge(t *testing.T) {
+	c := &shardClient{batchRecorder: &batchRecorder{}}
+	c.answer = func(ctx context.Context, start time.Time) (*Response, error) {
+		a := models.Row{Name: "cpu", Tags: map[string]string{"host": "a"}, Columns: []string{"time", "mean"}}
+		b := models.Row{Name: "cpu", Tags: map[string]string{"host": "b"}, Columns: []string{"time", "mean"}}
+		switch start.Unix() {
+		case 0:
+			a.Values = [][]interface{}{{int64(0), 1.0}, {int64(10), 2.0}}
+			return shardResponse(a), nil
+		case 20:
+			a.Values = [][]interface{}{{int64(20), 3.0}, {int64(30), 4.0}}
+			b.Values = [][]interface{}{{int64(30), 9.0}}
+			resp := shardResponse(b, a)
+			resp.Results[0].Messages = []*Message{{Level: "warning", Text: "deprecated"}}
+			return resp, nil
+		case 40:
+			// The last row of the previous window repeated, and a new column.
+			a.Values = [][]interface{}{{int64(30), 4.0}, {int64(40), 5.0}}
+			b.Columns = []string{"time", "max", "mean"}
+			b.Values = [][]interface{}{{int64(50), 7.0, 6.0}}
+			resp := shardResponse(a, b)
+			resp.Results[0].Messages = []*Message{{Level: "warning", Text: "deprecated"}}
+			return resp, nil
+		}
+		return nil, errors.New("unexpected window " + start.String())
+	}
+
+	q := NewQuery("SELECT mean(value) FROM cpu WHERE time >= 0s AND time < 60s GROUP BY time(10s), host -- by host", "db0", "s")
+	resp, err := QuerySharded(context.Background(), c, q, ShardedQueryOptions{Windows: 3, Concurrency: 2})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+
+	exp := []models.Row{
+		{
+			Name: "cpu", Tags: map[string]string{"host": "a"}, Columns: []string{"time", "mean"},
+			Values: [][]interface{}{{int64(0), 1.0}, {int64(10), 2.0}, {int64(20), 3.0}, {int64(30), 4.0}, {int64(40), 5.0}},
+		},
+		{
+			Name: "cpu", Tags: map[string]string{"host": "b"}, Columns: []string{"time", "mean", "max"},
+			Values: [][]interface{}{{int64(30), 9.0, nil}, {int64(50), 6.0, 7.0}},
+		},
+	}
+	if len(resp.Results) != 1 || !reflect.DeepEqual(resp.Results[0].Series, exp) {
+		t.Errorf("unexpected series.\nexpected %v\nactual   %v", exp, resp.Results)
+	}
+	if msgs := resp.Messages(); len(msgs) != 1 || msgs[0].Text != "deprecated" {
+		t.Errorf("unexpected messages.  expected %v, actual %v", "[deprecated]", msgs)
+	}
+
+	exp0 := "SELECT mean(value) FROM cpu WHERE (time >= 0s AND time < 60s) AND (time >= '1970-01-01T00:00:00Z' AND time < '1970-01-01T00:00:20Z') GROUP BY time(10s), host"
+	found := false
+	for _, cmd := range c.commands {
+		found = found || cmd == exp0
+	}
+	if !found {
+		t.Errorf("unexpected commands.  expected %q among %q", exp0, c.commands)
+	}
+}
+
+func TestQuerySharded_Windows(t *testing.T) {
+	hour := func(h float64) time.Time { return time.Unix(0, int64(h*float64(time.Hour))).UTC() }
+	for _, tt := range []struct {
+		command string
+		opts    ShardedQueryOptions
+		exp     []string
+	}{
+		{
+			command: "SELECT value FROM cpu",
+			opts:    ShardedQueryOptions{Start: hour(0.5), End: hour(3.5), Windows: 2, Align: time.Hour},
+			exp: []string{
+				"(time >= '1970-01-01T00:30:00Z' AND time < '1970-01-01T02:00:00Z')",
+				"(time >= '1970-01-01T02:00:00Z' AND time < '1970-01-01T03:30:00Z')",
+			},
+		},
+		{
+			command: "SELECT value FROM cpu WHERE host = 'a' AND (time > '1970-01-01T01:00:00Z' AND time <= 7200000000000) ORDER BY time DESC",
+			opts:    ShardedQueryOptions{Windows: 2},
+			exp: []string{
+				"(time >= '1970-01-01T01:00:00.000000001Z' AND time < '1970-01-01T01:30:00.000000001Z')",
+				"(time >= '1970-01-01T01:30:00.000000001Z' AND time < '1970-01-01T02:00:00.000000001Z')",
+			},
+		},
+		{
+			command: "SELECT max(value) FROM cpu WHERE $end > time AND time >= 1h GROUP BY time(1h, 30m)",
+			opts:    ShardedQueryOptions{Windows: 4},
+			exp: []string{
+				"(time >= '1970-01-01T01:00:00Z' AND time < '1970-01-01T01:30:00Z')",
+				"(time >= '1970-01-01T01:30:00Z' AND time < '1970-01-01T02:30:00Z')",
+				"(time >= '1970-01-01T02:30:00Z' AND time < '1970-01-01T03:00:00Z')",
+			},
+		},
+		{
+			command: "SELECT value FROM cpu WHERE time = '1970-01-01T00:00:05Z'",
+			exp:     []string{"(time >= '1970-01-01T00:00:05Z' AND time < '1970-01-01T00:00:05.000000001Z')"},
+		},
+	} {
+		c := &shardClient{batchRecorder: &batchRecorder{}}
+		c.answer = func(ctx context.Context, start time.Time) (*Response, error) { return shardResponse(), nil }
+		q := NewQuery(tt.command, "db0", "")
+		q.Parameters = map[string]interface{}{"end": int64(3 * time.Hour)}
+		if _, err := QuerySharded(context.Background(), c, q, tt.opts); err != nil {
+			t.Errorf("%s: unexpected error.  expected %v, actual %v", tt.command, nil, err)
+			continue
+		}
+		if windows := c.windows(); !reflect.DeepEqual(windows, tt.exp) {
+			t.Errorf("%s: unexpected windows.\nexpected %q\nactual   %q", tt.command, tt.exp, windows)
+		}
+	}
+}
+
+func TestQuerySharded_Desc(t *testing.T) {
+	c := &shardClient{batchRecorder: &batchRecorder{}}
+	c.answer = func(ctx context.Context, start time.Time) (*Response, error) {
+		s := start.Unix()
+		return shardResponse(models.Row{Name: "cpu", Columns: []string{"time", "value"}, Values: [][]interface{}{{s + 5, 1.0}, {s, 1.0}}}), nil
+	}
+	q := NewQuery("SELECT value FROM cpu WHERE time >= 0s AND time < 20s ORDER BY time DESC", "db0", "s")
+	resp, err := QuerySharded(context.Background(), c, q, ShardedQueryOptions{Windows: 2})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	exp := [][]interface{}{{int64(15), 1.0}, {int64(10), 1.0}, {int64(5), 1.0}, {int64(0), 1.0}}
+	if values := resp.Results[0].Series[0].Values; !reflect.DeepEqual(values, exp) {
+		t.Errorf("unexpected values.  expected %v, actual %v", exp, values)
+	}
+}
+
+func TestQuerySharded_Refused(t *testing.T) {
+	for _, tt := range []struct {
+		command string
+		opts    ShardedQueryOptions
+		err     string
+	}{
+		{command: "SELECT value FROM cpu; SELECT idle FROM cpu", err: "2 statements"},
+		{command: "SHOW DATABASES", err: "not a SELECT statement"},
+		{command: "SELECT count(value) FROM cpu WHERE time >= 0s AND time < 1h", err: "count without GROUP BY time"},
+		{command: "SELECT derivative(mean(value)) FROM cpu WHERE time >= 0s AND time < 1h GROUP BY time(1m)", err: "derivative"},
+		{command: "SELECT mean(value) FROM cpu WHERE time >= 0s AND time < 1h GROUP BY time(7m)", opts: ShardedQueryOptions{Align: time.Hour}, err: "straddle"},
+		{command: "SELECT mean(value) FROM cpu WHERE time >= 0s AND time < 1h GROUP BY time(10m, 5m)", opts: ShardedQueryOptions{Align: time.Hour}, err: "straddle"},
+		{command: "SELECT mean(value) FROM cpu WHERE time >= 0s AND time < 1h GROUP BY time(1m) fill(previous)", err: "fill(previous)"},
+		{command: "SELECT value FROM cpu WHERE time >= 0s AND time < 1h LIMIT 10", err: "LIMIT"},
+		{command: "SELECT value INTO cpu_copy FROM cpu WHERE time >= 0s AND time < 1h", err: "INTO"},
+		{command: "SELECT max FROM (SELECT max(value) FROM cpu) WHERE time >= 0s AND time < 1h", err: "subqueries"},
+		{command: "SELECT value FROM cpu WHERE time >= 0s", err: "both ends"},
+		{command: "SELECT value FROM cpu WHERE time < 1h OR host = 'a'", err: "OR condition"},
+		{command: "SELECT value FROM cpu WHERE time >= 0s AND time != 1h", err: "time != 1h"},
+	} {
+		c := &shardClient{batchRecorder: &batchRecorder{}}
+		_, err := QuerySharded(context.Background(), c, NewQuery(tt.command, "db0", ""), tt.opts)
+		if !errors.Is(err, ErrUnshardableQuery) || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: unexpected error.  expected %q, actual %v", tt.command, tt.err, err)
+		}
+		if len(c.commands) != 0 {
+			t.Errorf("%s: unexpected queries: %q", tt.command, c.commands)
+		}
+	}
+
+	for _, tt := range []struct {
+		opts  ShardedQueryOptions
+		field string
+	}{
+		{ShardedQueryOptions{Start: time.Unix(0, 0)}, "End"},
+		{ShardedQueryOptions{Start: time.Unix(10, 0), End: time.Unix(10, 0)}, "End"},
+		{ShardedQueryOptions{Windows: -1}, "Windows"},
+		{ShardedQueryOptions{Concurrency: -1}, "Concurrency"},
+	} {
+		_, err := QuerySharded(context.Background(), &shardClient{}, NewQuery("SELECT value FROM cpu", "db0", ""), tt.opts)
+		var ce *ConfigError
+		if !errors.As(err, &ce) || ce.Field != tt.field {
+			t.Errorf("%+v: unexpected error.  expected a *ConfigError of %v, actual %v", tt.opts, tt.field, err)
+		}
+	}
+}
+
+func TestQuerySharded_Error(t *testing.T) {
+	boom := errors.New("boom")
+	for _, tt := range []struct {
+		name   string
+		answer func() (*Response, error)
+		check  func(error) bool
+	}{
+		{"request", func() (*Response, error) { return nil, boom }, func(err error) bool { return errors.Is(err, boom) }},
+		{"statement", func() (*Response, error) {
+			return &Response{Results: []Result{{Err: "shard is locked"}}}, nil
+		}, func(err error) bool {
+			var se *StatementError
+			return errors.As(err, &se) && strings.Contains(se.Message, "shard is locked")
+		}},
+	} {
+		c := &shardClient{batchRecorder: &batchRecorder{}}
+		c.answer = func(ctx context.Context, start time.Time) (*Response, error) {
+			switch start.Unix() {
+			case 0:
+				return shardResponse(), nil
+			case 10:
+				return tt.answer()
+			}
+			// The other windows wait for the failure to cancel them.
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		q := NewQuery("SELECT value FROM cpu WHERE time >= 0s AND time < 40s", "db0", "s")
+		resp, err := QuerySharded(context.Background(), c, q, ShardedQueryOptions{Windows: 4, Concurrency: 4})
+		if resp != nil {
+			t.Errorf("%s: unexpected response: %v", tt.name, resp)
+		}
+		var sqe *ShardedQueryError
+		if !errors.As(err, &sqe) || !tt.check(err) {
+			t.Fatalf("%s: unexpected error.  expected a *ShardedQueryError, actual %v", tt.name, err)
+		}
+		if !sqe.Start.Equal(time.Unix(10, 0)) || !sqe.End.Equal(time.Unix(20, 0)) {
+			t.Errorf("%s: unexpected window.  expected %v to %v, actual %v to %v", tt.name, time.Unix(10, 0), time.Unix(20, 0), sqe.Start, sqe.End)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &shardClient{batchRecorder: &batchRecorder{}}
+	c.answer = func(context.Context, time.Time) (*Response, error) {
+		cancel()
+		return nil, context.Canceled
+	}
+	q := NewQuery("SELECT value FROM cpu WHERE time >= 0s AND time < 40s", "db0", "s")
+	if _, err := QuerySharded(ctx, c, q, ShardedQueryOptions{}); err != context.Canceled {
+		t.Errorf("unexpected error.  expected %v, actual %v", context.Canceled, err)
+	}
+}
@@ -0,0 +1,294 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ShowOption narrows down the results of a SHOW query.
+type ShowOption func(*showOptions)
+
+type showOptions struct {
+	measurementRegex string
+	where            string
+	limit            int
+	offset           int
+}
+
+// WithMeasurementRegex restricts a SHOW query to the measurements matching
+// the regular expression expr, without the surrounding slashes.
+func WithMeasurementRegex(expr string) ShowOption {
+	return func(o *showOptions) { o.measurementRegex = expr }
+}
+
+// WithWhere adds a WHERE clause to a SHOW query. cond is InfluxQL and is sent
+// as is, such as `"host" = 'server01'`.
+func WithWhere(cond string) ShowOption {
+	return func(o *showOptions) { o.where = cond }
+}
+
+// WithLimit limits the number of results of a SHOW query.
+func WithLimit(n int) ShowOption {
+	return func(o *showOptions) { o.limit = n }
+}
+
+// WithOffset skips the first n results of a SHOW query.
+func WithOffset(n int) ShowOption {
+	return func(o *showOptions) { o.offset = n }
+}
+
+// ShowDatabases returns the names of the databases.
+func ShowDatabases(ctx context.Context, c Client) ([]string, error) {
+	return showColumn(ctx, c, "", "SHOW DATABASES", "name")
+}
+
+// ShowRetentionPolicies returns the retention policies of the database db.
+func ShowRetentionPolicies(ctx context.Context, c Client, db string) ([]RetentionPolicySpec, error) {
+	stmt := "SHOW RETENTION POLICIES ON " + QuoteIdent(db)
+	resp, err := queryStatement(ctx, c, db, stmt)
+	if err != nil {
+		return nil, err
+	}
+
+	var specs []RetentionPolicySpec
+	err = eachRow(resp, func(get func(column string) interface{}) error {
+		var spec RetentionPolicySpec
+		spec.Name, _ = get("name").(string)
+		var err error
+		if spec.Duration, err = parseShowDuration(get("duration")); err != nil {
+			return err
+		}
+		if spec.ShardGroupDuration, err = parseShowDuration(get("shardGroupDuration")); err != nil {
+			return err
+		}
+		if n, ok := numberString(get("replicaN")); ok {
+			spec.ReplicaN, _ = strconv.Atoi(n)
+		}
+		spec.Default, _ = get("default").(bool)
+		if spec.Duration == 0 {
+			spec.Duration = InfiniteDuration
+		}
+		specs = append(specs, spec)
+		return nil
+	})
+	return specs, err
+}
+
+// ShowMeasurements returns the names of the measurements of the database db.
+func ShowMeasurements(ctx context.Context, c Client, db string, opts ...ShowOption) ([]string, error) {
+	o := newShowOptions(opts)
+	stmt := "SHOW MEASUREMENTS"
+	if o.measurementRegex != "" {
+		stmt += " WITH MEASUREMENT =~ " + quoteRegex(o.measurementRegex)
+	}
+	return showColumn(ctx, c, db, stmt+o.tail(), "name")
+}
+
+// ShowTagKeys returns the tag keys of measurement, or of every measurement
+// of the database db if measurement is empty.
+func ShowTagKeys(ctx context.Context, c Client, db, measurement string, opts ...ShowOption) ([]string, error) {
+	o := newShowOptions(opts)
+	stmt := "SHOW TAG KEYS" + o.from(measurement) + o.tail()
+	return showColumn(ctx, c, db, stmt, "tagKey")
+}
+
+// ShowTagValues returns the values of the tag key of measurement, or of
+// every measurement of the database db if measurement is empty.
+func ShowTagValues(ctx context.Context, c Client, db, measurement, key string, opts ...ShowOption) ([]string, error) {
+	o := newShowOptions(opts)
+	stmt := "SHOW TAG VALUES" + o.from(measurement) + " WITH KEY = " + QuoteIdent(key) + o.tail()
+	return showColumn(ctx, c, db, stmt, "value")
+}
+
+// ShowFieldKeys returns the type of each field of measurement, or of every
+// measurement of the database db if measurement is empty, by field name.
+func ShowFieldKeys(ctx context.Context, c Client, db, measurement string, opts ...ShowOption) (map[string]string, error) {
+	o := newShowOptions(opts)
+	stmt := "SHOW FIELD KEYS" + o.from(measurement) + o.tail()
+	resp, err := queryStatement(ctx, c, db, stmt)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]string)
+	err = eachRow(resp, func(get func(column string) interface{}) error {
+		name, _ := get("fieldKey").(string)
+		typ, _ := get("fieldType").(string)
+		fields[name] = typ
+		return nil
+	})
+	return fields, err
+}
+
+// ShowSeries returns the series keys of measurement, or of every
+// measurement of the database db if measurement is empty.
+func ShowSeries(ctx context.Context, c Client, db, measurement string, opts ...ShowOption) ([]string, error) {
+	o := newShowOptions(opts)
+	stmt := "SHOW SERIES" + o.from(measurement) + o.tail()
+	return showColumn(ctx, c, db, stmt, "key")
+}
+
+// SeriesCardinality returns the number of series of the database db, counted
+// exactly or estimated by the server, which is cheaper on a large database.
+// A database that does not exist fails with an error matching
+// ErrDatabaseNotFound.
+func SeriesCardinality(ctx context.Context, c Client, db string, exact bool) (int64, error) {
+	return showCardinality(ctx, c, db, "SHOW SERIES", exact, "")
+}
+
+// MeasurementCardinality returns the number of measurements of the database
+// db, counted exactly or estimated, see SeriesCardinality.
+func MeasurementCardinality(ctx context.Context, c Client, db string, exact bool) (int64, error) {
+	return showCardinality(ctx, c, db, "SHOW MEASUREMENT", exact, "")
+}
+
+// TagValuesCardinality returns the estimated number of values of the tag key
+// of measurement, or of every measurement of the database db if measurement
+// is empty, see SeriesCardinality.
+func TagValuesCardinality(ctx context.Context, c Client, db, measurement, key string) (int64, error) {
+	return showCardinality(ctx, c, db, "SHOW TAG VALUES", false, newShowOptions(nil).from(measurement)+" WITH KEY = "+QuoteIdent(key))
+}
+
+// cardinalityColumns are the columns a SHOW ... CARDINALITY response holds
+// the number in: count for the exact ones, and for the estimated ones
+// "cardinality estimation", or cardinality for some versions of the server.
+var cardinalityColumns = []string{"count", "cardinality estimation", "cardinality"}
+
+// showCardinality runs the CARDINALITY form of the SHOW statement show, with
+// the clauses rest, and returns the sum of the numbers of its series, which
+// the exact ones return one of per measurement. A database without series
+// has none.
+func showCardinality(ctx context.Context, c Client, db, show string, exact bool, rest string) (int64, error) {
+	stmt := show
+	if exact {
+		stmt += " EXACT"
+	}
+	stmt += " CARDINALITY" + rest
+	resp, err := queryStatement(ctx, c, db, stmt)
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	err = eachRow(resp, func(get func(column string) interface{}) error {
+		for _, column := range cardinalityColumns {
+			v := get(column)
+			if v == nil {
+				continue
+			}
+			s, ok := numberString(v)
+			if !ok {
+				return fmt.Errorf("unexpected value %v in column %q of %s", v, column, stmt)
+			}
+			n, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return fmt.Errorf("unexpected value %v in column %q of %s", v, column, stmt)
+			}
+			total += n
+			return nil
+		}
+		return fmt.Errorf("no cardinality in the response to %s", stmt)
+	})
+	return total, err
+}
+
+func newShowOptions(opts []ShowOption) *showOptions {
+	o := &showOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// from returns the FROM clause for measurement, or for the measurement regex
+// if measurement is empty.
+func (o *showOptions) from(measurement string) string {
+	switch {
+	case measurement != "":
+		return " FROM " + QuoteIdent(measurement)
+	case o.measurementRegex != "":
+		return " FROM " + quoteRegex(o.measurementRegex)
+	}
+	return ""
+}
+
+// tail returns the WHERE, LIMIT and OFFSET clauses.
+func (o *showOptions) tail() string {
+	var b strings.Builder
+	if o.where != "" {
+		b.WriteString(" WHERE " + o.where)
+	}
+	if o.limit > 0 {
+		b.WriteString(" LIMIT " + strconv.Itoa(o.limit))
+	}
+	if o.offset > 0 {
+		b.WriteString(" OFFSET " + strconv.Itoa(o.offset))
+	}
+	return b.String()
+}
+
+// showColumn returns the distinct values of column over every series of the
+// response to stmt, in the order the server sent them.
+func showColumn(ctx context.Context, c Client, db, stmt, column string) ([]string, error) {
+	resp, err := queryStatement(ctx, c, db, stmt)
+	if err != nil {
+		return nil, err
+	}
+
+	var values []string
+	seen := make(map[string]bool)
+	err = eachRow(resp, func(get func(column string) interface{}) error {
+		v, ok := get(column).(string)
+		if !ok {
+			return fmt.Errorf("unexpected value %v in column %q of %s", get(column), column, stmt)
+		}
+		if !seen[v] {
+			seen[v] = true
+			values = append(values, v)
+		}
+		return nil
+	})
+	return values, err
+}
+
+// eachRow calls fn for every row of every series of resp. get returns the
+// value of a column of the row, or nil if the series has no such column.
+func eachRow(resp *Response, fn func(get func(column string) interface{}) error) error {
+	for _, result := range resp.Results {
+		for _, row := range result.Series {
+			index := make(map[string]int, len(row.Columns))
+			for i, c := range row.Columns {
+				index[c] = i
+			}
+			for _, values := range row.Values {
+				get := func(column string) interface{} {
+					if i, ok := index[column]; ok && i < len(values) {
+						return values[i]
+					}
+					return nil
+				}
+				if err := fn(get); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// parseShowDuration parses a duration as returned by SHOW RETENTION POLICIES.
+func parseShowDuration(v interface{}) (time.Duration, error) {
+	s, _ := v.(string)
+	if s == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// quoteRegex formats expr as an InfluxQL regular expression literal.
+func quoteRegex(expr string) string {
+	return "/" + strings.ReplaceAll(expr, "/", `\/`) + "/"
+}
@@ -0,0 +1,219 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+// newShowServer answers each statement with the series registered for it,
+// and with an empty result for any other statement.
+func newShowServer(t *testing.T, series map[string][]models.Row) (*httptest.Server, *[]string) {
+	var statements []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.FormValue("q")
+		statements = append(statements, q)
+		resp := Response{Results: []Result{{Series: series[q]}}}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(resp)
+	}))
+	return ts, &statements
+}
+
+func TestShowMeasurements(t *testing.T) {
+	const stmt = `SHOW MEASUREMENTS WITH MEASUREMENT =~ /^cpu\/.*/ WHERE "host" = 'a' LIMIT 10 OFFSET 5`
+	ts, statements := newShowServer(t, map[string][]models.Row{
+		stmt: {{Name: "measurements", Columns: []string{"name"}, Values: [][]interface{}{{"cpu/0"}, {"cpu/1"}}}},
+	})
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	names, err := ShowMeasurements(context.Background(), c, "db0",
+		WithMeasurementRegex("^cpu/.*"), WithWhere(`"host" = 'a'`), WithLimit(10), WithOffset(5))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := (*statements)[0]; got != stmt {
+		t.Errorf("unexpected statement.\nexpected %s\nactual   %s", stmt, got)
+	}
+	if exp := []string{"cpu/0", "cpu/1"}; !reflect.DeepEqual(names, exp) {
+		t.Errorf("unexpected measurements.  expected %v, actual %v", exp, names)
+	}
+}
+
+func TestShowTagKeysAndValues(t *testing.T) {
+	ts, statements := newShowServer(t, map[string][]models.Row{
+		`SHOW TAG KEYS`: {
+			{Name: "cpu", Columns: []string{"tagKey"}, Values: [][]interface{}{{"host"}, {"region"}}},
+			{Name: "mem", Columns: []string{"tagKey"}, Values: [][]interface{}{{"host"}}},
+		},
+		`SHOW TAG VALUES FROM "c\"pu" WITH KEY = "host"`: {
+			{Name: "c\"pu", Columns: []string{"key", "value"}, Values: [][]interface{}{{"host", "a"}, {"host", "b"}}},
+		},
+	})
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+	ctx := context.Background()
+
+	keys, err := ShowTagKeys(ctx, c, "db0", "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if exp := []string{"host", "region"}; !reflect.DeepEqual(keys, exp) {
+		t.Errorf("unexpected tag keys.  expected %v, actual %v", exp, keys)
+	}
+
+	values, err := ShowTagValues(ctx, c, "db0", `c"pu`, "host")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if exp := []string{"a", "b"}; !reflect.DeepEqual(values, exp) {
+		t.Errorf("unexpected tag values.  expected %v, actual %v (%v)", exp, values, *statements)
+	}
+}
+
+func TestShowFieldKeys(t *testing.T) {
+	ts, _ := newShowServer(t, map[string][]models.Row{
+		`SHOW FIELD KEYS FROM "cpu"`: {
+			{Name: "cpu", Columns: []string{"fieldKey", "fieldType"}, Values: [][]interface{}{{"idle", "float"}, {"count", "integer"}}},
+		},
+	})
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	fields, err := ShowFieldKeys(context.Background(), c, "db0", "cpu")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if exp := map[string]string{"idle": "float", "count": "integer"}; !reflect.DeepEqual(fields, exp) {
+		t.Errorf("unexpected fields.  expected %v, actual %v", exp, fields)
+	}
+}
+
+func TestShowSeriesEmpty(t *testing.T) {
+	ts, statements := newShowServer(t, nil)
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	series, err := ShowSeries(context.Background(), c, "db0", "", WithMeasurementRegex("cpu"))
+	if err != nil || len(series) != 0 {
+		t.Errorf("unexpected result: %v, %v", series, err)
+	}
+	if exp := `SHOW SERIES FROM /cpu/`; (*statements)[0] != exp {
+		t.Errorf("unexpected statement.  expected %s, actual %s", exp, (*statements)[0])
+	}
+	fields, err := ShowFieldKeys(context.Background(), c, "db0", "none")
+	if err != nil || fields == nil || len(fields) != 0 {
+		t.Errorf("unexpected result: %v, %v", fields, err)
+	}
+}
+
+func TestShowRetentionPolicies(t *testing.T) {
+	ts, _ := newShowServer(t, map[string][]models.Row{
+		`SHOW RETENTION POLICIES ON "db0"`: {{
+			Columns: []string{"name", "duration", "shardGroupDuration", "replicaN", "default"},
+			Values: [][]interface{}{
+				{"autogen", "0s", "168h0m0s", 1, true},
+				{"week", "168h0m0s", "24h0m0s", 2, false},
+			},
+		}},
+	})
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	specs, err := ShowRetentionPolicies(context.Background(), c, "db0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	exp := []RetentionPolicySpec{
+		{Name: "autogen", Duration: InfiniteDuration, ShardGroupDuration: 7 * 24 * time.Hour, ReplicaN: 1, Default: true},
+		{Name: "week", Duration: 7 * 24 * time.Hour, ShardGroupDuration: 24 * time.Hour, ReplicaN: 2},
+	}
+	if !reflect.DeepEqual(specs, exp) {
+		t.Errorf("unexpected retention policies.\nexpected %+v\nactual   %+v", exp, specs)
+	}
+}
+
+func TestShowDatabasesNotFound(t *testing.T) {
+	ts, _ := newStatementServer(t, func(string) string { return `database not found: db0` })
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	if _, err := ShowMeasurements(context.Background(), c, "db0"); !errors.Is(err, ErrDatabaseNotFound) {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestCardinality(t *testing.T) {
+	ts, statements := newShowServer(t, map[string][]models.Row{
+		"SHOW SERIES CARDINALITY": {{Columns: []string{"cardinality estimation"}, Values: [][]interface{}{{1234}}}},
+		"SHOW SERIES EXACT CARDINALITY": {
+			{Name: "cpu", Columns: []string{"count"}, Values: [][]interface{}{{1000}}},
+			{Name: "mem", Columns: []string{"count"}, Values: [][]interface{}{{200}}},
+		},
+		"SHOW MEASUREMENT CARDINALITY":       {{Columns: []string{"cardinality"}, Values: [][]interface{}{{3}}}},
+		"SHOW MEASUREMENT EXACT CARDINALITY": {{Columns: []string{"count"}, Values: [][]interface{}{{2}}}},
+		`SHOW TAG VALUES CARDINALITY FROM "cpu" WITH KEY = "host"`: {
+			{Name: "cpu", Columns: []string{"cardinality estimation"}, Values: [][]interface{}{{40}}},
+		},
+	})
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	ctx := context.Background()
+	for _, tt := range []struct {
+		fn  func() (int64, error)
+		exp int64
+	}{
+		{func() (int64, error) { return SeriesCardinality(ctx, c, "db0", false) }, 1234},
+		{func() (int64, error) { return SeriesCardinality(ctx, c, "db0", true) }, 1200},
+		{func() (int64, error) { return MeasurementCardinality(ctx, c, "db0", false) }, 3},
+		{func() (int64, error) { return MeasurementCardinality(ctx, c, "db0", true) }, 2},
+		{func() (int64, error) { return TagValuesCardinality(ctx, c, "db0", "cpu", "host") }, 40},
+		// An empty database has no series.
+		{func() (int64, error) { return TagValuesCardinality(ctx, c, "db0", "", "host") }, 0},
+	} {
+		n, err := tt.fn()
+		if err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+		if n != tt.exp {
+			t.Errorf("unexpected cardinality of %s.  expected %v, actual %v", (*statements)[len(*statements)-1], tt.exp, n)
+		}
+	}
+}
+
+func TestCardinalityNotFound(t *testing.T) {
+	ts, _ := newStatementServer(t, func(string) string { return `database not found: db0` })
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	var se *StatementError
+	if _, err := SeriesCardinality(context.Background(), c, "db0", true); !errors.Is(err, ErrDatabaseNotFound) || !errors.As(err, &se) || se.Statement != "SHOW SERIES EXACT CARDINALITY" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
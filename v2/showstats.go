@@ -0,0 +1,126 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// StatsEntry is a series of SHOW STATS: the statistics of a module, or of
+// one instance of it such as a shard or a service listening on an address.
+type StatsEntry struct {
+	// Module is the name of the module, such as "httpd" or "shard".
+	Module string
+
+	// Tags identify the instance of the module, such as the database and
+	// id of a shard. It is nil for the modules that have one instance.
+	Tags map[string]string
+
+	// Integers and Floats are the statistics of the module by name, the
+	// column of SHOW STATS. JSON does not tell a float with an integral
+	// value from an integer, so such a statistic is in Integers, as with
+	// Int64Decoding. Null values and values that are not numbers are left
+	// out, so that a statistic is in either map or in none.
+	Integers map[string]int64
+	Floats   map[string]float64
+}
+
+// Value returns the statistic name as a float64, whether it is in Integers
+// or Floats, and whether the entry has it.
+func (e StatsEntry) Value(name string) (float64, bool) {
+	if v, ok := e.Integers[name]; ok {
+		return float64(v), true
+	}
+	v, ok := e.Floats[name]
+	return v, ok
+}
+
+// DiagnosticsRow is a row of SHOW DIAGNOSTICS, its values by column. Numbers
+// are int64 if they are integers and float64 otherwise, other values are
+// those of the response, such as strings.
+type DiagnosticsRow map[string]interface{}
+
+// ShowStats returns the statistics of module, or of every module if module
+// is empty, as returned by SHOW STATS. The columns of a module vary between
+// versions of the server: those the caller does not know of are to be
+// ignored rather than rejected.
+func ShowStats(ctx context.Context, c Client, module string) ([]StatsEntry, error) {
+	stmt := "SHOW STATS"
+	if module != "" {
+		stmt += " FOR " + QuoteString(module)
+	}
+	resp, err := queryStatement(ctx, c, "", stmt)
+	if err != nil {
+		return nil, err
+	}
+	var entries []StatsEntry
+	for _, result := range resp.Results {
+		for _, row := range result.Series {
+			entry := StatsEntry{
+				Module:   row.Name,
+				Tags:     row.Tags,
+				Integers: make(map[string]int64),
+				Floats:   make(map[string]float64),
+			}
+			for _, values := range row.Values {
+				for i, v := range values {
+					if i >= len(row.Columns) {
+						break
+					}
+					switch n := statsNumber(v).(type) {
+					case int64:
+						entry.Integers[row.Columns[i]] = n
+					case float64:
+						entry.Floats[row.Columns[i]] = n
+					}
+				}
+			}
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// ShowDiagnostics returns the rows of SHOW DIAGNOSTICS by module, such as
+// "build", "runtime" or "system".
+func ShowDiagnostics(ctx context.Context, c Client) (map[string][]DiagnosticsRow, error) {
+	resp, err := queryStatement(ctx, c, "", "SHOW DIAGNOSTICS")
+	if err != nil {
+		return nil, err
+	}
+	diagnostics := make(map[string][]DiagnosticsRow)
+	for _, result := range resp.Results {
+		for _, row := range result.Series {
+			for _, values := range row.Values {
+				r := make(DiagnosticsRow, len(row.Columns))
+				for i, v := range values {
+					if i >= len(row.Columns) {
+						break
+					}
+					r[row.Columns[i]] = statsNumber(v)
+				}
+				diagnostics[row.Name] = append(diagnostics[row.Name], r)
+			}
+		}
+	}
+	return diagnostics, nil
+}
+
+// statsNumber returns v as an int64 if it is an integer and as a float64 if
+// it is another number, whichever NumberDecoding decoded it. Other values
+// are returned as is.
+func statsNumber(v interface{}) interface{} {
+	switch n := v.(type) {
+	case json.Number:
+		return convertNumber(n, Int64Decoding)
+	case float64:
+		if i := int64(n); float64(i) == n {
+			return i
+		}
+	case uint64:
+		if int64(n) >= 0 {
+			return int64(n)
+		}
+		return float64(n)
+	}
+	return v
+}
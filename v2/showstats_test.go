@@ -0,0 +1,83 @@
+package client
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+func TestShowStats(t *testing.T) {
+	ts, statements := newShowServer(t, map[string][]models.Row{
+		`SHOW STATS`: {
+			{Name: "runtime", Columns: []string{"Alloc", "GCCPUFraction", "NumGoroutine"}, Values: [][]interface{}{{4136056, 0.0002, 24}}},
+			{Name: "shard", Tags: map[string]string{"database": "db0", "id": "1"}, Columns: []string{"diskBytes", "path", "fieldsCreate", "newStat"}, Values: [][]interface{}{{1024, "/var/lib/influxdb", nil, 1e20}}},
+		},
+		`SHOW STATS FOR 'httpd'`: {
+			{Name: "httpd", Tags: map[string]string{"bind": ":8086"}, Columns: []string{"req"}, Values: [][]interface{}{{9007199254740993}}},
+		},
+	})
+	defer ts.Close()
+
+	for _, numbers := range []NumberDecoding{JSONNumberDecoding, Float64Decoding} {
+		c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, NumberDecoding: numbers})
+		entries, err := ShowStats(context.Background(), c, "")
+		if err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+		exp := []StatsEntry{
+			{Module: "runtime", Integers: map[string]int64{"Alloc": 4136056, "NumGoroutine": 24}, Floats: map[string]float64{"GCCPUFraction": 0.0002}},
+			{Module: "shard", Tags: map[string]string{"database": "db0", "id": "1"}, Integers: map[string]int64{"diskBytes": 1024}, Floats: map[string]float64{"newStat": 1e20}},
+		}
+		if !reflect.DeepEqual(entries, exp) {
+			t.Errorf("unexpected entries with %v.\nexpected %+v\nactual   %+v", numbers, exp, entries)
+		}
+		if v, ok := entries[0].Value("NumGoroutine"); !ok || v != 24 {
+			t.Errorf("unexpected value.  expected %v, actual %v, %v", 24, v, ok)
+		}
+		if _, ok := entries[1].Value("path"); ok {
+			t.Errorf("unexpected value of a string column")
+		}
+		c.Close()
+	}
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+	entries, err := ShowStats(context.Background(), c, "httpd")
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if len(entries) != 1 || entries[0].Integers["req"] != 9007199254740993 {
+		t.Errorf("unexpected entries: %+v", entries)
+	}
+	if exp := "SHOW STATS FOR 'httpd'"; (*statements)[len(*statements)-1] != exp {
+		t.Errorf("unexpected statement.  expected %s, actual %s", exp, (*statements)[len(*statements)-1])
+	}
+}
+
+func TestShowDiagnostics(t *testing.T) {
+	ts, _ := newShowServer(t, map[string][]models.Row{
+		`SHOW DIAGNOSTICS`: {
+			{Name: "build", Columns: []string{"Branch", "Version"}, Values: [][]interface{}{{"1.8", "1.8.10"}}},
+			{Name: "runtime", Columns: []string{"GOMAXPROCS", "GOOS", "uptime"}, Values: [][]interface{}{{8, "linux", 1.5}}},
+			{Name: "config-data", Columns: []string{"dir", "cache-max-memory-size"}, Values: [][]interface{}{{"/a", 1073741824}, {"/b", 0}}},
+		},
+	})
+	defer ts.Close()
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	diagnostics, err := ShowDiagnostics(context.Background(), c)
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	exp := map[string][]DiagnosticsRow{
+		"build":       {{"Branch": "1.8", "Version": "1.8.10"}},
+		"runtime":     {{"GOMAXPROCS": int64(8), "GOOS": "linux", "uptime": 1.5}},
+		"config-data": {{"dir": "/a", "cache-max-memory-size": int64(1073741824)}, {"dir": "/b", "cache-max-memory-size": int64(0)}},
+	}
+	if !reflect.DeepEqual(diagnostics, exp) {
+		t.Errorf("unexpected diagnostics.\nexpected %v\nactual   %v", exp, diagnostics)
+	}
+}
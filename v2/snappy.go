@@ -0,0 +1,160 @@
+package client
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// snappyEncoder compresses payloads to the snappy block format, described at
+// https://github.com/google/snappy/blob/main/format_description.txt. Its hash
+// table is kept from one payload to the next instead of being allocated for
+// each. It is not safe for concurrent use.
+type snappyEncoder struct {
+	table [1 << snappyTableBits]uint16
+}
+
+const (
+	snappyTableBits = 14
+
+	// snappyMaxBlockSize is the size of the blocks the input is split into,
+	// and matched within, so that offsets fit 16 bits.
+	snappyMaxBlockSize = 65536
+
+	// snappyInputMargin is the number of bytes left at the end of a block
+	// in which no match is looked for, so that loads do not overrun it.
+	snappyInputMargin = 16 - 1
+
+	// snappyMinBlockSize is the size below which a block is just copied as
+	// a literal.
+	snappyMinBlockSize = 1 + 1 + snappyInputMargin
+)
+
+// CompressBlock appends the snappy encoding of src to dst.
+func (e *snappyEncoder) CompressBlock(dst, src []byte) ([]byte, error) {
+	dst = binary.AppendUvarint(dst, uint64(len(src)))
+	for len(src) > 0 {
+		block := src
+		if len(block) > snappyMaxBlockSize {
+			block = block[:snappyMaxBlockSize]
+		}
+		src = src[len(block):]
+		if len(block) < snappyMinBlockSize {
+			dst = snappyLiteral(dst, block)
+		} else {
+			dst = e.encodeBlock(dst, block)
+		}
+	}
+	return dst, nil
+}
+
+func snappyHash(u uint32) uint32 {
+	return (u * 0x1e35a7bd) >> (32 - snappyTableBits)
+}
+
+func load32(b []byte, i int) uint32 {
+	return binary.LittleEndian.Uint32(b[i:])
+}
+
+// encodeBlock appends the encoding of src, at least snappyMinBlockSize and at
+// most snappyMaxBlockSize bytes, to dst. Matches of 4 bytes are looked up by
+// their hash, skipping ahead faster the longer none is found, so that data that
+// does not compress is not spent much time on.
+func (e *snappyEncoder) encodeBlock(dst, src []byte) []byte {
+	e.table = [len(e.table)]uint16{}
+	sLimit := len(src) - snappyInputMargin
+	nextEmit := 0
+	s := 1
+	nextHash := snappyHash(load32(src, s))
+
+	for {
+		skip := 32
+		nextS := s
+		candidate := 0
+		for {
+			s = nextS
+			step := skip >> 5
+			nextS = s + step
+			skip += step
+			if nextS > sLimit {
+				return snappyLiteral(dst, src[nextEmit:])
+			}
+			candidate = int(e.table[nextHash])
+			e.table[nextHash] = uint16(s)
+			nextHash = snappyHash(load32(src, nextS))
+			if load32(src, s) == load32(src, candidate) {
+				break
+			}
+		}
+
+		dst = snappyLiteral(dst, src[nextEmit:s])
+		// Matches are emitted as long as the bytes following one match
+		// again.
+		for {
+			base := s
+			s += 4
+			for i := candidate + 4; s < len(src) && src[i] == src[s]; i, s = i+1, s+1 {
+			}
+			dst = snappyCopy(dst, base-candidate, s-base)
+			nextEmit = s
+			if s >= sLimit {
+				if nextEmit < len(src) {
+					dst = snappyLiteral(dst, src[nextEmit:])
+				}
+				return dst
+			}
+
+			e.table[snappyHash(load32(src, s-1))] = uint16(s - 1)
+			h := snappyHash(load32(src, s))
+			candidate = int(e.table[h])
+			e.table[h] = uint16(s)
+			if load32(src, s) != load32(src, candidate) {
+				nextHash = snappyHash(load32(src, s+1))
+				s++
+				break
+			}
+		}
+	}
+}
+
+// snappyLiteral appends the literal element of lit to dst.
+func snappyLiteral(dst, lit []byte) []byte {
+	if len(lit) == 0 {
+		return dst
+	}
+	n := uint32(len(lit) - 1)
+	if n < 60 {
+		dst = append(dst, byte(n)<<2)
+	} else {
+		// The length follows the tag in 1 to 4 little-endian bytes, their
+		// number being told by tags 60 to 63.
+		size := (bits.Len32(n) + 7) / 8
+		dst = append(dst, byte(59+size)<<2)
+		for i := 0; i < size; i++ {
+			dst = append(dst, byte(n>>(8*i)))
+		}
+	}
+	return append(dst, lit...)
+}
+
+// snappyCopy appends the copy elements of length bytes at offset to dst.
+func snappyCopy(dst []byte, offset, length int) []byte {
+	for length >= 68 {
+		dst = snappyCopy2(dst, offset, 64)
+		length -= 64
+	}
+	if length > 64 {
+		// Leaves at least 4 bytes, the shortest copy with a 1-byte offset.
+		dst = snappyCopy2(dst, offset, 60)
+		length -= 60
+	}
+	if length >= 12 || offset >= 2048 {
+		return snappyCopy2(dst, offset, length)
+	}
+	return append(dst, byte(offset>>8)<<5|byte(length-4)<<2|1, byte(offset))
+}
+
+// snappyCopy2 appends a copy element with a 2-byte offset, of at most 64
+// bytes.
+func snappyCopy2(dst []byte, offset, length int) []byte {
+	return append(dst, byte(length-1)<<2|2, byte(offset), byte(offset>>8))
+}
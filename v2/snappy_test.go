@@ -0,0 +1,115 @@
+package client
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// snappyDecode decodes src from the snappy block format.
+func snappyDecode(src []byte) ([]byte, error) {
+	n, l := binary.Uvarint(src)
+	if l <= 0 {
+		return nil, errors.New("snappy: invalid length")
+	}
+	src = src[l:]
+	dst := make([]byte, 0, n)
+	for len(src) > 0 {
+		tag := src[0]
+		var length, offset int
+		switch tag & 3 {
+		case 0:
+			length = int(tag >> 2)
+			src = src[1:]
+			if length >= 60 {
+				size := length - 59
+				if len(src) < size {
+					return nil, errors.New("snappy: truncated literal")
+				}
+				length = 0
+				for i := 0; i < size; i++ {
+					length |= int(src[i]) << (8 * i)
+				}
+				src = src[size:]
+			}
+			length++
+			if len(src) < length {
+				return nil, errors.New("snappy: truncated literal")
+			}
+			dst = append(dst, src[:length]...)
+			src = src[length:]
+			continue
+		case 1:
+			if len(src) < 2 {
+				return nil, errors.New("snappy: truncated copy")
+			}
+			length = 4 + int(tag>>2&7)
+			offset = int(tag>>5)<<8 | int(src[1])
+			src = src[2:]
+		case 2:
+			if len(src) < 3 {
+				return nil, errors.New("snappy: truncated copy")
+			}
+			length = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint16(src[1:]))
+			src = src[3:]
+		default:
+			return nil, errors.New("snappy: unexpected copy with a 4-byte offset")
+		}
+		if offset <= 0 || offset > len(dst) {
+			return nil, fmt.Errorf("snappy: invalid offset %d", offset)
+		}
+		for i := 0; i < length; i++ {
+			dst = append(dst, dst[len(dst)-offset])
+		}
+	}
+	if uint64(len(dst)) != n {
+		return nil, fmt.Errorf("snappy: decoded %d bytes, expected %d", len(dst), n)
+	}
+	return dst, nil
+}
+
+func TestSnappyEncoder(t *testing.T) {
+	random := make([]byte, 100000)
+	rand.New(rand.NewSource(1)).Read(random)
+	var lines bytes.Buffer
+	for i := 0; lines.Len() < 3*snappyMaxBlockSize; i++ {
+		fmt.Fprintf(&lines, "cpu,host=server%02d,region=us-west value=%d %d\n", i%10, i%7, 1e9+i)
+	}
+
+	tests := []struct {
+		name string
+		src  []byte
+	}{
+		{name: "empty", src: nil},
+		{name: "short", src: []byte("cpu value=1\n")},
+		{name: "repeated", src: bytes.Repeat([]byte("a"), 5000)},
+		{name: "random", src: random},
+		{name: "lines", src: lines.Bytes()},
+		{name: "long literal", src: append(random[:70000:70000], bytes.Repeat([]byte("cpu "), 100)...)},
+	}
+
+	var e snappyEncoder
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b, err := e.CompressBlock(nil, test.src)
+			if err != nil {
+				t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+			}
+			decoded, err := snappyDecode(b)
+			if err != nil {
+				t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+			}
+			if !bytes.Equal(decoded, test.src) {
+				t.Errorf("unexpected decoded data.  expected %d bytes, actual %d", len(test.src), len(decoded))
+			}
+		})
+	}
+
+	if b, _ := e.CompressBlock(nil, lines.Bytes()); len(b) > lines.Len()/4 {
+		t.Errorf("unexpected compressed size.  expected at most %d, actual %d", lines.Len()/4, len(b))
+	}
+}
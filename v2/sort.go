@@ -0,0 +1,89 @@
+package client
+
+import (
+	"bytes"
+	"sort"
+)
+
+// sortEntry is a point with the keys it is sorted by.
+type sortEntry struct {
+	key    []byte
+	time   int64
+	noTime bool
+	p      *Point
+
+	// index is the position of the point in the batch, which keeps the
+	// order of equal points without a slower stable sort.
+	index int
+}
+
+func newSortEntry(p *Point, index int) sortEntry {
+	if p == nil {
+		return sortEntry{index: index}
+	}
+	e := sortEntry{key: p.pt.Key(), p: p, index: index}
+	if p.pt.Time().IsZero() {
+		e.noTime = true
+	} else {
+		e.time = p.pt.UnixNano()
+	}
+	return e
+}
+
+// less orders entries by series key then time. The points without a time come
+// after the others of their series, as the server stores them at the time it
+// receives them, and nil points come last.
+func (e sortEntry) less(o sortEntry) bool {
+	if e.p == nil || o.p == nil {
+		if e.p == nil && o.p == nil {
+			return e.index < o.index
+		}
+		return o.p == nil
+	}
+	if c := bytes.Compare(e.key, o.key); c != 0 {
+		return c < 0
+	}
+	if e.noTime != o.noTime {
+		return o.noTime
+	}
+	if e.time != o.time {
+		return e.time < o.time
+	}
+	return e.index < o.index
+}
+
+type sortEntries []sortEntry
+
+func (s sortEntries) Len() int           { return len(s) }
+func (s sortEntries) Less(i, j int) bool { return s[i].less(s[j]) }
+func (s sortEntries) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// sortPoints sorts points in place, see BatchPoints.Sort.
+func sortPoints(points []*Point) {
+	entries := make([]sortEntry, len(points))
+	sorted := true
+	for i, p := range points {
+		entries[i] = newSortEntry(p, i)
+		if i > 0 && entries[i].less(entries[i-1]) {
+			sorted = false
+		}
+	}
+	if sorted {
+		return
+	}
+	sort.Sort(sortEntries(entries))
+	for i, e := range entries {
+		points[i] = e.p
+	}
+}
+
+func (bp *batchpoints) Sort() {
+	sortPoints(bp.points)
+	bp.sorted = true
+}
+
+func (s *safeBatchPoints) Sort() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bp.Sort()
+}
@@ -0,0 +1,128 @@
+package client
+
+import (
+	"bufio"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBatchPoints_Sort(t *testing.T) {
+	t0 := time.Unix(100, 0)
+	points := []*Point{
+		mustPoint(t, "mem", nil, map[string]interface{}{"v": 1}, t0),
+		mustPoint(t, "cpu", map[string]string{"host": "b"}, map[string]interface{}{"v": 2}, t0.Add(time.Second)),
+		// Dropped by AddPoints.
+		nil,
+		mustPoint(t, "cpu", map[string]string{"host": "b"}, map[string]interface{}{"v": 3}),
+		mustPoint(t, "cpu", map[string]string{"host": "a"}, map[string]interface{}{"v": 4}, t0.Add(time.Second)),
+		mustPoint(t, "cpu", map[string]string{"host": "b"}, map[string]interface{}{"v": 5}, t0),
+		// Equal keys keep their order.
+		mustPoint(t, "cpu", map[string]string{"host": "a"}, map[string]interface{}{"v": 6}, t0),
+		mustPoint(t, "cpu", map[string]string{"host": "a"}, map[string]interface{}{"v": 7}, t0),
+	}
+	given := append([]*Point(nil), points...)
+
+	bp, _ := NewSafeBatchPoints(BatchPointsConfig{Precision: "s"})
+	bp.AddPoints(points)
+	bp.Sort()
+
+	var got []string
+	for _, p := range bp.Points() {
+		if p == nil {
+			got = append(got, "nil")
+			continue
+		}
+		got = append(got, p.PrecisionString("s"))
+	}
+	exp := []string{
+		"cpu,host=a v=6i 100",
+		"cpu,host=a v=7i 100",
+		"cpu,host=a v=4i 101",
+		"cpu,host=b v=5i 100",
+		"cpu,host=b v=2i 101",
+		"cpu,host=b v=3i",
+		"mem v=1i 100",
+	}
+	if fmt.Sprint(got) != fmt.Sprint(exp) {
+		t.Errorf("unexpected points.\nexpected %q\nactual   %q", exp, got)
+	}
+	for i := range points {
+		if points[i] != given[i] {
+			t.Fatalf("the slice given to AddPoints was reordered at %d", i)
+		}
+	}
+}
+
+func TestClient_WriteSortOnWrite(t *testing.T) {
+	var lines []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s := bufio.NewScanner(r.Body)
+		for s.Scan() {
+			lines = append(lines, s.Text())
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{Precision: "s", SortOnWrite: true})
+	bp.AddPoint(mustPoint(t, "cpu", map[string]string{"host": "b"}, map[string]interface{}{"v": 1}, time.Unix(2, 0)))
+	bp.AddPoint(mustPoint(t, "cpu", map[string]string{"host": "a"}, map[string]interface{}{"v": 2}, time.Unix(3, 0)))
+	if err := c.Write(bp); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	// The points added after a write are sorted as well.
+	bp.AddPoint(mustPoint(t, "cpu", map[string]string{"host": "a"}, map[string]interface{}{"v": 3}, time.Unix(1, 0)))
+	if err := c.Write(bp); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+
+	exp := []string{
+		"cpu,host=a v=2i 3",
+		"cpu,host=b v=1i 2",
+		"cpu,host=a v=3i 1",
+		"cpu,host=a v=2i 3",
+		"cpu,host=b v=1i 2",
+	}
+	if fmt.Sprint(lines) != fmt.Sprint(exp) {
+		t.Errorf("unexpected written points.\nexpected %q\nactual   %q", exp, lines)
+	}
+}
+
+// BenchmarkClient_WriteSortOnWrite writes 50,000 points of 500 series in a
+// random order, sorted or not.
+func BenchmarkClient_WriteSortOnWrite(b *testing.B) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	points := make([]*Point, 0, 50000)
+	for i := 0; i < cap(points); i++ {
+		tags := map[string]string{"host": fmt.Sprintf("server%03d", i%500), "region": "eu-west"}
+		points = append(points, mustPoint(b, "cpu", tags, map[string]interface{}{"value": float64(i)}, time.Unix(int64(i/500), 0)))
+	}
+	rand.New(rand.NewSource(1)).Shuffle(len(points), func(i, j int) { points[i], points[j] = points[j], points[i] })
+
+	for _, sorted := range []bool{false, true} {
+		b.Run(fmt.Sprintf("sorted=%v", sorted), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				bp, _ := NewBatchPoints(BatchPointsConfig{SortOnWrite: sorted})
+				bp.AddPoints(points)
+				if err := c.Write(bp); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
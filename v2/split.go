@@ -0,0 +1,87 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+// OversizedPointsError is returned by BatchPoints.Split, along with the
+// batches, when points are larger than the byte limit on their own. Each of
+// them is in a batch of its own. It matches ErrPointExceedsBatch.
+type OversizedPointsError struct {
+	// Indexes are the indexes of the points in the split batch.
+	Indexes []int
+
+	// MaxBytes is the byte limit of the split.
+	MaxBytes int
+}
+
+func (e *OversizedPointsError) Error() string {
+	return fmt.Sprintf("%d points larger than %d bytes, each written in a batch of its own", len(e.Indexes), e.MaxBytes)
+}
+
+// Is reports whether target is ErrPointExceedsBatch.
+func (e *OversizedPointsError) Is(target error) bool {
+	return target == ErrPointExceedsBatch
+}
+
+func (bp *batchpoints) Split(maxPoints, maxBytes int) ([]BatchPoints, error) {
+	points := bp.Points()
+	var batches []BatchPoints
+	var oversized *OversizedPointsError
+	start, size := 0, 0
+	flush := func(end int) {
+		if end > start {
+			batches = append(batches, bp.child(points[start:end], size))
+		}
+		start, size = end, 0
+	}
+	for i, p := range points {
+		n := models.PrecisionStringSize(p.pt, bp.precision) + 1
+		if maxPoints > 0 && i-start == maxPoints || maxBytes > 0 && i > start && size+n > maxBytes {
+			flush(i)
+		}
+		size += n
+		if maxBytes > 0 && n > maxBytes {
+			if oversized == nil {
+				oversized = &OversizedPointsError{MaxBytes: maxBytes}
+			}
+			oversized.Indexes = append(oversized.Indexes, i)
+			flush(i + 1)
+		}
+	}
+	flush(len(points))
+	if oversized != nil {
+		return batches, oversized
+	}
+	return batches, nil
+}
+
+// child returns a batch with the settings of bp and a copy of points, whose
+// size is given.
+func (bp *batchpoints) child(points []*Point, size int) *batchpoints {
+	return &batchpoints{
+		points:           append([]*Point(nil), points...),
+		database:         bp.database,
+		precision:        bp.precision,
+		retentionPolicy:  bp.retentionPolicy,
+		writeConsistency: bp.writeConsistency,
+		sortOnWrite:      bp.sortOnWrite,
+		sorted:           bp.sorted,
+		sortFields:       bp.sortFields,
+		limiter:          bp.limiter,
+		sanitizer:        bp.sanitizer,
+		clock:            bp.clock,
+		strict:           bp.strict,
+		size:             size,
+		maxBytes:         bp.maxBytes,
+		limits:           bp.limits,
+	}
+}
+
+func (s *safeBatchPoints) Split(maxPoints, maxBytes int) ([]BatchPoints, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bp.Split(maxPoints, maxBytes)
+}
@@ -0,0 +1,106 @@
+package client
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// batchLengths returns the number of points of each of batches.
+func batchLengths(batches []BatchPoints) []int {
+	var n []int
+	for _, b := range batches {
+		n = append(n, len(b.Points()))
+	}
+	return n
+}
+
+func TestBatchPoints_Split(t *testing.T) {
+	p, _ := NewPoint("cpu", map[string]string{"host": "a"}, map[string]interface{}{"value": 1.0}, time.Unix(1, 0))
+	size := len(p.PrecisionString("s")) + 1
+	points := []*Point{p, p, p, p, p, p, p}
+
+	for _, tt := range []struct {
+		name                string
+		maxPoints, maxBytes int
+		exp                 []int
+	}{
+		{"no limit", 0, 0, []int{7}},
+		{"one point", 1, 0, []int{1, 1, 1, 1, 1, 1, 1}},
+		{"points", 3, 0, []int{3, 3, 1}},
+		{"bytes cutting the series", 0, 2*size + size/2, []int{2, 2, 2, 1}},
+		{"exact bytes", 0, 3 * size, []int{3, 3, 1}},
+		{"points first", 2, 3 * size, []int{2, 2, 2, 1}},
+		{"bytes first", 4, 3 * size, []int{3, 3, 1}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0", RetentionPolicy: "rp0", Precision: "s", WriteConsistency: "all"})
+			bp.AddPoints(points)
+			batches, err := bp.Split(tt.maxPoints, tt.maxBytes)
+			if err != nil {
+				t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+			}
+			if got := batchLengths(batches); !reflect.DeepEqual(got, tt.exp) {
+				t.Errorf("unexpected batches.  expected %v, actual %v", tt.exp, got)
+			}
+			var total int
+			for _, b := range batches {
+				if b.Database() != "db0" || b.RetentionPolicy() != "rp0" || b.Precision() != "s" || b.WriteConsistency() != "all" {
+					t.Errorf("unexpected settings: %s, %s, %s, %s", b.Database(), b.RetentionPolicy(), b.Precision(), b.WriteConsistency())
+				}
+				if tt.maxBytes > 0 && b.Size() > tt.maxBytes {
+					t.Errorf("unexpected batch size.  expected at most %v, actual %v", tt.maxBytes, b.Size())
+				}
+				total += b.Size()
+			}
+			if total != bp.Size() || len(bp.Points()) != len(points) {
+				t.Errorf("unexpected split of %v bytes into %v", bp.Size(), total)
+			}
+		})
+	}
+}
+
+func TestBatchPoints_SplitOrder(t *testing.T) {
+	bp, _ := NewSafeBatchPoints(BatchPointsConfig{})
+	points := newTestPoints(10)
+	bp.AddPoints(points)
+	batches, _ := bp.Split(3, 0)
+
+	var got []*Point
+	for _, b := range batches {
+		got = append(got, b.Points()...)
+	}
+	if !reflect.DeepEqual(got, points) {
+		t.Errorf("unexpected points.  expected %v, actual %v", points, got)
+	}
+
+	bp.Reset()
+	if batches, err := bp.Split(3, 0); len(batches) != 0 || err != nil {
+		t.Errorf("unexpected split of an empty batch: %v, %v", batches, err)
+	}
+}
+
+func TestBatchPoints_SplitOversized(t *testing.T) {
+	p, _ := NewPoint("cpu", nil, map[string]interface{}{"value": 1.0}, time.Unix(1, 0))
+	size := len(p.String()) + 1
+	large, _ := NewPoint("cpu", nil, map[string]interface{}{"value": strings.Repeat("x", 3*size)}, time.Unix(1, 0))
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	bp.AddPoints([]*Point{large, p, p, large, p})
+	batches, err := bp.Split(0, 2*size)
+	if got, exp := batchLengths(batches), []int{1, 2, 1, 1}; !reflect.DeepEqual(got, exp) {
+		t.Errorf("unexpected batches.  expected %v, actual %v", exp, got)
+	}
+	if batches[2].Points()[0] != large {
+		t.Errorf("unexpected point in the batch of its own: %v", batches[2].Points())
+	}
+	var oe *OversizedPointsError
+	if !errors.As(err, &oe) || !reflect.DeepEqual(oe.Indexes, []int{0, 3}) || oe.MaxBytes != 2*size {
+		t.Errorf("unexpected error: %#v", err)
+	}
+	if !errors.Is(err, ErrPointExceedsBatch) {
+		t.Errorf("unexpected error.  expected %v, actual %v", ErrPointExceedsBatch, err)
+	}
+}
@@ -0,0 +1,146 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ErrNoResult is returned for a statement of a query the server returned no
+// result for, such as the statements following one that failed.
+var ErrNoResult = errors.New("no result for statement")
+
+// NewQueryStatements returns a query running statements in order, joined
+// with semicolons. The database and precision arguments can be empty strings
+// if they are not needed for the query.
+func NewQueryStatements(statements []string, database, precision string) Query {
+	return NewQuery(strings.Join(statements, ";\n"), database, precision)
+}
+
+// Statements returns the statements of the command of q, split on the
+// semicolons outside of quoted strings, identifiers and comments, without
+// the surrounding spaces. Statements holding nothing but spaces and comments
+// are left out, as the server does.
+func (q Query) Statements() []string {
+	return splitStatements(q.Command)
+}
+
+func splitStatements(command string) []string {
+	var statements []string
+	start, empty := 0, true
+	end := func(i int) {
+		if !empty {
+			statements = append(statements, strings.TrimSpace(command[start:i]))
+		}
+		start, empty = i+1, true
+	}
+	for i := 0; i < len(command); {
+		s := command[i:]
+		switch c := s[0]; {
+		case c == ';':
+			end(i)
+			i++
+		case strings.HasPrefix(s, "--"):
+			if n := strings.IndexByte(s, '\n'); n >= 0 {
+				i += n + 1
+			} else {
+				i = len(command)
+			}
+		case strings.HasPrefix(s, "/*"):
+			if n := strings.Index(s[2:], "*/"); n >= 0 {
+				i += n + 4
+			} else {
+				i = len(command)
+			}
+		case c == '\'' || c == '"':
+			empty = false
+			i += quotedLen(s)
+		case unicode.IsSpace(rune(c)):
+			i++
+		default:
+			empty = false
+			i++
+		}
+	}
+	end(len(command))
+	return statements
+}
+
+// ResultFor returns the result of the statement with index i, counted from
+// 0 in the order of the statements of the query, and the error of that
+// statement. The results of a chunked query spread over several Results are
+// merged. It returns ErrNoResult if the server did not run the statement.
+func (r *Response) ResultFor(i int) (Result, error) {
+	if r.Err != "" {
+		return Result{StatementId: i}, r.Error()
+	}
+	var (
+		result Result
+		found  bool
+	)
+	for _, res := range r.Results {
+		if res.StatementId != i {
+			continue
+		}
+		if !found {
+			result, found = res, true
+			// Appending must not modify the Series of r.
+			result.Series = result.Series[:len(result.Series):len(result.Series)]
+			result.Messages = result.Messages[:len(result.Messages):len(result.Messages)]
+			continue
+		}
+		result.Series = append(result.Series, res.Series...)
+		result.Messages = append(result.Messages, res.Messages...)
+		if res.Err != "" {
+			result.Err = res.Err
+		}
+	}
+	if !found {
+		return Result{StatementId: i}, fmt.Errorf("statement %d: %w", i, ErrNoResult)
+	}
+	if result.Err != "" {
+		return result, &StatementError{Statement: r.statement(i), Message: result.Err}
+	}
+	return result, nil
+}
+
+// Errors returns the error of every statement of the query, nil for those
+// that succeeded. For a response of the HTTP client there is one entry per
+// statement of the query, see Query.Statements, otherwise one per statement
+// up to the last one with a result. An error of the whole query, such as an
+// unexpected status code, is the error of every statement.
+func (r *Response) Errors() []error {
+	n := len(r.statements())
+	for _, res := range r.Results {
+		if res.StatementId >= n {
+			n = res.StatementId + 1
+		}
+	}
+	if n == 0 && r.Err != "" {
+		n = 1
+	}
+	errs := make([]error, n)
+	for i := range errs {
+		_, errs[i] = r.ResultFor(i)
+	}
+	return errs
+}
+
+// statements returns the statements of the command r is the response to, if
+// known.
+func (r *Response) statements() []string {
+	if r.command == "" {
+		return nil
+	}
+	return splitStatements(r.command)
+}
+
+// statement returns the statement with index i of the command r is the
+// response to, or "" if unknown.
+func (r *Response) statement(i int) string {
+	if statements := r.statements(); i < len(statements) {
+		return statements[i]
+	}
+	return ""
+}
@@ -0,0 +1,98 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+func TestQuery_Statements(t *testing.T) {
+	for _, tt := range []struct {
+		command string
+		exp     []string
+	}{
+		{"", nil},
+		{"SHOW DATABASES", []string{"SHOW DATABASES"}},
+		{" CREATE DATABASE foo ;SELECT * FROM cpu; ", []string{"CREATE DATABASE foo", "SELECT * FROM cpu"}},
+		{`SELECT * FROM "a;b" WHERE t = 'x;y'; SHOW TAG KEYS`, []string{`SELECT * FROM "a;b" WHERE t = 'x;y'`, "SHOW TAG KEYS"}},
+		{"SELECT 1 -- a; comment\n; /* another; */ ;; SHOW USERS", []string{"SELECT 1 -- a; comment", "SHOW USERS"}},
+		{`SELECT * FROM "it\"s;"`, []string{`SELECT * FROM "it\"s;"`}},
+	} {
+		got := Query{Command: tt.command}.Statements()
+		if fmt.Sprintf("%q", got) != fmt.Sprintf("%q", tt.exp) {
+			t.Errorf("unexpected statements for %q.  expected %q, actual %q", tt.command, tt.exp, got)
+		}
+	}
+
+	q := NewQueryStatements([]string{"CREATE DATABASE foo", "SHOW DATABASES"}, "", "")
+	if got := q.Statements(); len(got) != 2 || got[1] != "SHOW DATABASES" {
+		t.Errorf("unexpected statements: %q", got)
+	}
+}
+
+func TestResponse_ResultFor(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		// The second statement failed, and the server stopped there.
+		w.Write([]byte(`{"results":[
+			{"statement_id":1,"error":"database not found: nope"},
+			{"statement_id":0,"series":[{"name":"databases","columns":["name"],"values":[["a"]]}]}
+		]}`))
+	}))
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+	q := NewQueryStatements([]string{"SHOW DATABASES", "SHOW MEASUREMENTS ON nope", "SHOW USERS"}, "", "")
+	resp, err := c.Query(q)
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+
+	res, err := resp.ResultFor(0)
+	if err != nil || len(res.Series) != 1 || res.Series[0].Name != "databases" {
+		t.Errorf("unexpected result for statement 0: %+v, %v", res, err)
+	}
+	var se *StatementError
+	if _, err := resp.ResultFor(1); !errors.As(err, &se) || se.Statement != "SHOW MEASUREMENTS ON nope" || !errors.Is(err, ErrDatabaseNotFound) {
+		t.Errorf("unexpected error for statement 1: %v", err)
+	}
+	if _, err := resp.ResultFor(2); !errors.Is(err, ErrNoResult) {
+		t.Errorf("unexpected error for statement 2.  expected %v, actual %v", ErrNoResult, err)
+	}
+
+	errs := resp.Errors()
+	if len(errs) != 3 || errs[0] != nil || !errors.As(errs[1], &se) || !errors.Is(errs[2], ErrNoResult) {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+}
+
+func TestResponse_ResultForChunks(t *testing.T) {
+	first := make([]models.Row, 1, 4)
+	first[0].Name = "cpu"
+	resp := &Response{Results: []Result{
+		{StatementId: 0, Series: first},
+		{StatementId: 1, Series: []models.Row{{Name: "mem"}}},
+		{StatementId: 0, Series: []models.Row{{Name: "cpu"}, {Name: "disk"}}},
+	}}
+	res, err := resp.ResultFor(0)
+	if err != nil || len(res.Series) != 3 || res.Series[2].Name != "disk" {
+		t.Errorf("unexpected merged result: %+v, %v", res.Series, err)
+	}
+	if name := first[:2][1].Name; name != "" {
+		t.Errorf("unexpected change to the series of the response: %q", name)
+	}
+	if errs := resp.Errors(); len(errs) != 2 || errs[0] != nil || errs[1] != nil {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+
+	// An error of the whole query is the error of every statement.
+	resp = &Response{Err: "timeout"}
+	if errs := resp.Errors(); len(errs) != 1 || errs[0] == nil {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+}
@@ -0,0 +1,113 @@
+package client
+
+import (
+	"context"
+	"time"
+)
+
+// StatsCollector receives the outcome of the writes and queries of a client,
+// for example to export them as metrics. Without one, the default, nothing is
+// measured. It is called after the operation
+// completed and never while the client holds a lock, but possibly from
+// several goroutines at once.
+type StatsCollector interface {
+	// WriteDone is called after every attempt to write a batch, so a write
+	// retried by the HTTP client is reported once per request sent. points
+	// is the number of points in the batch and bytes the size of the encoded
+	// payloads, after compression.
+	WriteDone(points, bytes int, dur time.Duration, err error)
+
+	// QueryDone is called once per query. For a chunked query dur includes
+	// reading the response and QueryDone is called when the last chunk was
+	// read, reading failed or the response was closed, whichever comes
+	// first. err includes an error reported by the server in the response.
+	QueryDone(q string, dur time.Duration, err error)
+}
+
+// WriteStats describes a single write, for ad-hoc instrumentation without a
+// StatsCollector.
+type WriteStats struct {
+	// PointCount is the number of points in the batch.
+	PointCount int
+
+	// ByteCount is the size of the encoded payloads, after compression.
+	// A retried HTTP request sends them again, but counts them once.
+	ByteCount int
+
+	// SerializeDuration is the time spent encoding the points.
+	SerializeDuration time.Duration
+
+	// NetworkDuration is the time spent sending the payloads, over every
+	// attempt for the HTTP client. Waits for a retry or for RateLimit are
+	// part of neither duration.
+	NetworkDuration time.Duration
+
+	// Retries is the number of times the HTTP client sent the write again.
+	Retries int
+
+	// StatusCode is the status the server answered the last HTTP request
+	// with, or 0 if it did not answer.
+	StatusCode int
+
+	// Flushes is the number of payloads the TCP and UDP clients sent, one
+	// per datagram for UDP.
+	Flushes int
+}
+
+// StatsWriter is implemented by the HTTP, UDP and TCP clients.
+type StatsWriter interface {
+	// WriteWithStats is like WriteContext, but also returns the statistics
+	// of the write, as far as it went if it failed.
+	WriteWithStats(ctx context.Context, bp BatchPoints) (WriteStats, error)
+}
+
+// writeTimer splits the time of a TCP or UDP write, which encodes a payload
+// and sends it in turn, between the two in the WriteStats of the write.
+type writeTimer struct {
+	ws   *WriteStats
+	last time.Time
+}
+
+func newWriteTimer(ws *WriteStats) writeTimer {
+	return writeTimer{ws: ws, last: time.Now()}
+}
+
+// encoded is called when a payload was encoded.
+func (t *writeTimer) encoded() {
+	now := time.Now()
+	t.ws.SerializeDuration += now.Sub(t.last)
+	t.last = now
+}
+
+// sending is called before a payload of n bytes is sent, once it may be.
+func (t *writeTimer) sending(n int) {
+	t.ws.Flushes++
+	t.ws.ByteCount += n
+	t.last = time.Now()
+}
+
+// sent is called when the payload was sent.
+func (t *writeTimer) sent() {
+	now := time.Now()
+	t.ws.NetworkDuration += now.Sub(t.last)
+	t.last = now
+}
+
+// writeDone reports a write of points that started at start to s, once the
+// write returned. It is meant to be deferred with pointers to the number of
+// bytes sent and the result of the write.
+func writeDone(s StatsCollector, points int, start time.Time, bytes *int, err *error) {
+	s.WriteDone(points, *bytes, time.Since(start), *err)
+}
+
+// statsClient is implemented by the HTTP, UDP and TCP clients, for the
+// clients wrapping them to report to their StatsCollector.
+type statsClient interface {
+	statsCollector() StatsCollector
+}
+
+func (c *client) statsCollector() StatsCollector     { return c.stats }
+func (uc *udpclient) statsCollector() StatsCollector { return uc.stats }
+func (p *udppool) statsCollector() StatsCollector    { return p.stats }
+func (uc *tcpclient) statsCollector() StatsCollector { return uc.stats }
+func (p *tcppool) statsCollector() StatsCollector    { return p.stats }
@@ -0,0 +1,255 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type statsEvent struct {
+	query         string
+	points, bytes int
+	err           error
+}
+
+// recordingStats records every event reported to it.
+type recordingStats struct {
+	mu      sync.Mutex
+	writes  []statsEvent
+	queries []statsEvent
+
+	// check, if set, is called on every event.
+	check func()
+}
+
+func (s *recordingStats) WriteDone(points, bytes int, dur time.Duration, err error) {
+	if s.check != nil {
+		s.check()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writes = append(s.writes, statsEvent{points: points, bytes: bytes, err: err})
+}
+
+func (s *recordingStats) QueryDone(q string, dur time.Duration, err error) {
+	if s.check != nil {
+		s.check()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queries = append(s.queries, statsEvent{query: q, err: err})
+}
+
+func TestClient_StatsWriteRetries(t *testing.T) {
+	ts, _ := newRetryTestServer(t, http.StatusServiceUnavailable)
+	defer ts.Close()
+
+	stats := &recordingStats{}
+	c, err := NewHTTPClient(HTTPConfig{Addr: ts.URL, MaxRetries: 1, RetryInterval: time.Millisecond, Stats: stats})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer c.Close()
+
+	if err := c.Write(newTestBatch(t, 2)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if exp, got := 2, len(stats.writes); got != exp {
+		t.Fatalf("unexpected number of writes reported.  expected %v, actual %v", exp, got)
+	}
+	if w := stats.writes[0]; w.err == nil || w.points != 2 || w.bytes == 0 {
+		t.Errorf("unexpected first attempt: %+v", w)
+	}
+	if w := stats.writes[1]; w.err != nil || w.points != 2 || w.bytes != stats.writes[0].bytes {
+		t.Errorf("unexpected second attempt: %+v", w)
+	}
+}
+
+func TestClient_StatsQuery(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results":[{"error":"measurement not found"}]}`))
+	}))
+	defer ts.Close()
+
+	stats := &recordingStats{}
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, Stats: stats})
+	defer c.Close()
+
+	if _, err := c.Query(Query{Command: "SELECT * FROM cpu"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if exp, got := 1, len(stats.queries); got != exp {
+		t.Fatalf("unexpected number of queries reported.  expected %v, actual %v", exp, got)
+	}
+	if q := stats.queries[0]; q.query != "SELECT * FROM cpu" || q.err == nil {
+		t.Errorf("unexpected query reported: %+v", q)
+	}
+}
+
+func TestClient_StatsChunkedQuery(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"results":[{}]}` + "\n" + `{"results":[{}]}` + "\n"))
+	}))
+	defer ts.Close()
+
+	stats := &recordingStats{}
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, Stats: stats})
+	defer c.Close()
+
+	cr, err := c.QueryAsChunk(Query{Command: "SELECT * FROM cpu"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := cr.NextResponse(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if exp, got := 0, len(stats.queries); got != exp {
+		t.Fatalf("unexpected number of queries reported mid-stream.  expected %v, actual %v", exp, got)
+	}
+	for {
+		if _, err := cr.NextResponse(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	cr.Close()
+
+	if exp, got := 1, len(stats.queries); got != exp {
+		t.Fatalf("unexpected number of queries reported.  expected %v, actual %v", exp, got)
+	}
+	if q := stats.queries[0]; q.err != nil {
+		t.Errorf("unexpected error.  expected %v, actual %v", nil, q.err)
+	}
+}
+
+func TestClient_StatsChunkedQueryFailed(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer ts.Close()
+
+	stats := &recordingStats{}
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, Stats: stats})
+	defer c.Close()
+
+	if _, err := c.QueryAsChunk(Query{Command: "SHOW DATABASES"}); err == nil {
+		t.Fatal("expected an error")
+	}
+	if len(stats.queries) != 1 || stats.queries[0].err == nil {
+		t.Errorf("unexpected queries reported: %+v", stats.queries)
+	}
+}
+
+func TestTCPClient_StatsNotUnderLock(t *testing.T) {
+	w := &bufferConn{}
+	stats := &recordingStats{}
+	cl := &tcpclient{conn: w, payloadSize: TCPPayloadSize, stats: stats}
+	stats.check = func() {
+		if !cl.mu.TryLock() {
+			t.Error("stats called while holding the connection lock")
+			return
+		}
+		cl.mu.Unlock()
+	}
+
+	if err := cl.Write(newTestBatch(t, 3)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if exp, got := []statsEvent{{points: 3, bytes: len(w.String())}}, stats.writes; len(got) != 1 || got[0] != exp[0] {
+		t.Errorf("unexpected writes reported.  expected %+v, actual %+v", exp, got)
+	}
+}
+
+func TestUDPClient_StatsWriteError(t *testing.T) {
+	stats := &recordingStats{}
+	cl := &udpclient{conn: brokenConn{}, payloadSize: UDPPayloadSize, stats: stats}
+
+	err := cl.Write(newTestBatch(t, 1))
+	if len(stats.writes) != 1 || !errors.Is(stats.writes[0].err, err) {
+		t.Errorf("unexpected writes reported: %+v", stats.writes)
+	}
+	var netErr net.Error
+	if !errors.As(stats.writes[0].err, &netErr) {
+		t.Errorf("expected the network error to be reported, got %v", stats.writes[0].err)
+	}
+}
+
+func TestClient_WriteWithStats(t *testing.T) {
+	ts, _ := newRetryTestServer(t, http.StatusServiceUnavailable)
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, MaxRetries: 1, RetryInterval: time.Millisecond, WriteEncoding: GzipEncoding})
+	defer c.Close()
+
+	ws, err := c.(StatsWriter).WriteWithStats(context.Background(), newTestBatch(t, 2))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ws.PointCount != 2 || ws.ByteCount == 0 || ws.Retries != 1 || ws.StatusCode != http.StatusNoContent || ws.Flushes != 0 {
+		t.Errorf("unexpected stats: %+v", ws)
+	}
+	if ws.SerializeDuration <= 0 || ws.NetworkDuration <= 0 {
+		t.Errorf("unexpected durations: %+v", ws)
+	}
+}
+
+func TestClient_WriteWithStatsFailed(t *testing.T) {
+	ts, _ := newRetryTestServer(t, http.StatusBadRequest)
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	ws, err := c.(StatsWriter).WriteWithStats(context.Background(), newTestBatch(t, 1))
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if ws.StatusCode != http.StatusBadRequest || ws.Retries != 0 {
+		t.Errorf("unexpected stats: %+v", ws)
+	}
+
+	// Nothing was sent to a server that could not be reached.
+	ts.Close()
+	ws, _ = c.(StatsWriter).WriteWithStats(context.Background(), newTestBatch(t, 1))
+	if ws.StatusCode != 0 || ws.PointCount != 1 {
+		t.Errorf("unexpected stats: %+v", ws)
+	}
+}
+
+func TestTCPClient_WriteWithStats(t *testing.T) {
+	w := &bufferConn{}
+	cl := &tcpclient{conn: w, payloadSize: 64}
+
+	ws, err := cl.WriteWithStats(context.Background(), newTestBatch(t, 3))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ws.PointCount != 3 || ws.ByteCount != len(w.String()) || ws.Flushes < 2 || ws.StatusCode != 0 {
+		t.Errorf("unexpected stats: %+v", ws)
+	}
+}
+
+func TestUDPClient_WriteWithStats(t *testing.T) {
+	cl := &udpclient{conn: brokenConn{}, payloadSize: UDPPayloadSize}
+
+	ws, err := cl.WriteWithStats(context.Background(), newTestBatch(t, 2))
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if ws.PointCount != 2 || ws.Flushes != 1 || ws.ByteCount == 0 {
+		t.Errorf("unexpected stats: %+v", ws)
+	}
+}
@@ -0,0 +1,389 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+// StreamClient is implemented by the HTTP client. It decodes the response of
+// a query while it is read, so that memory use is bound by the largest series
+// rather than by the whole response.
+type StreamClient interface {
+	// QueryStream sends q and returns the stream of its response. The
+	// context applies to the whole lifetime of the returned stream.
+	QueryStream(ctx context.Context, q Query) (*ResultStream, error)
+}
+
+// StreamDecodeError is returned by a ResultStream for a response body that is
+// not valid JSON, or not shaped like the response of a query.
+type StreamDecodeError struct {
+	// Offset is the offset in bytes within the body at which decoding
+	// failed.
+	Offset int64
+
+	Err error
+}
+
+func (e *StreamDecodeError) Error() string {
+	return fmt.Sprintf("decoding response at offset %d: %v", e.Offset, e.Err)
+}
+
+func (e *StreamDecodeError) Unwrap() error { return e.Err }
+
+type streamState int
+
+const (
+	streamStart    streamState = iota // before a response object
+	streamResponse                    // between the keys of a response object
+	streamResults                     // between the results of a response
+	streamResult                      // between the keys of a result object
+	streamSeries                      // between the series of a result
+)
+
+// ResultStream walks the series of a query response one at a time, decoding
+// each one from the body only when Next is called. A statement error ends the
+// stream, as does the first malformed byte of the body.
+//
+//	s, err := c.(client.StreamClient).QueryStream(ctx, q)
+//	if err != nil {
+//		...
+//	}
+//	defer s.Close()
+//	for s.Next() {
+//		fmt.Println(s.StatementID(), s.Series().Name)
+//	}
+//	if err := s.Err(); err != nil {
+//		...
+//	}
+//
+// The series of a chunked query are yielded as the server split them, see
+// models.Row.Partial.
+type ResultStream struct {
+	// Header is the header of the response.
+	Header http.Header
+
+	dec     *json.Decoder
+	body    io.Closer
+	errResp *http.Response // set if the status of the response is not 200
+	command string
+	numbers NumberDecoding
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	release func(error) error
+	done    func(error)
+
+	state     streamState
+	started   bool
+	statement int
+	messages  []*Message
+	series    models.Row
+
+	err    error
+	closed bool
+}
+
+// QueryStream sends q and returns a stream decoding its response as it is
+// read. The response is always requested as JSON.
+func (c *client) QueryStream(ctx context.Context, q Query) (*ResultStream, error) {
+	start := time.Now()
+	ctx, end := c.startSpan(ctx, SpanQueryStream)
+	ctx, cancel := context.WithCancel(ctx)
+	ctx, release := c.withQueryTimeout(ctx, q)
+	fail := func(err error) error {
+		err = release(err)
+		cancel()
+		if end != nil {
+			end(err, querySpanAttrs(q, spanStatus(err, 0)))
+		}
+		return c.queryDone(q, start, err)
+	}
+	req, err := c.createDefaultRequest(ctx, q)
+	if err != nil {
+		return nil, fail(err)
+	}
+	req.Header.Set("Accept", jsonContentType)
+	if q.Chunked {
+		params := req.URL.Query()
+		params.Set("chunked", "true")
+		if q.ChunkSize > 0 {
+			params.Set("chunk_size", strconv.Itoa(q.ChunkSize))
+		}
+		req.URL.RawQuery = params.Encode()
+	}
+
+	probe, err := c.breaker.allow()
+	if err != nil {
+		return nil, fail(err)
+	}
+	resp, err := c.doQuery(req, q)
+	if err == nil {
+		err = c.checkResponse(resp, JSONFormat)
+		if err != nil {
+			resp.Body.Close()
+		}
+	}
+	c.breaker.done(probe, err)
+	if err != nil {
+		return nil, fail(err)
+	}
+
+	if q.MaxResponseBytes > 0 {
+		resp.Body = newSizeReader(resp.Body, q.MaxResponseBytes)
+	}
+	s := &ResultStream{
+		Header:  resp.Header,
+		dec:     json.NewDecoder(resp.Body),
+		body:    resp.Body,
+		command: q.Command,
+		numbers: c.numbers,
+		ctx:     ctx,
+		cancel:  cancel,
+		release: release,
+	}
+	s.dec.UseNumber()
+	if resp.StatusCode != http.StatusOK {
+		s.errResp = resp
+	}
+	if c.stats != nil || end != nil {
+		status := resp.StatusCode
+		s.done = func(err error) {
+			if c.stats != nil {
+				c.stats.QueryDone(q.Command, time.Since(start), err)
+			}
+			if end != nil {
+				end(err, querySpanAttrs(q, status))
+			}
+		}
+	}
+	return s, nil
+}
+
+// Next decodes the next series and reports whether there is one. It returns
+// false at the end of the response or on error, see Err.
+func (s *ResultStream) Next() bool {
+	if s.closed {
+		return false
+	}
+	s.series = models.Row{}
+	for {
+		var err error
+		switch s.state {
+		case streamStart:
+			var tok json.Token
+			tok, err = s.dec.Token()
+			if err == io.EOF && s.started {
+				s.stop(s.statusError())
+				return false
+			}
+			if err == nil && tok != json.Delim('{') {
+				err = s.malformed("unexpected %v, expected a response object", tok)
+			}
+			s.started = true
+			s.state = streamResponse
+		case streamResponse:
+			var key string
+			if key, err = s.key(); err != nil || key == "" {
+				s.state = streamStart
+				break
+			}
+			switch key {
+			case "results":
+				err = s.expect('[')
+				s.state = streamResults
+			case "error":
+				var msg string
+				if err = s.dec.Decode(&msg); err == nil {
+					s.stop(s.responseError(msg))
+					return false
+				}
+			default:
+				err = s.skip()
+			}
+		case streamResults:
+			if !s.dec.More() {
+				err = s.expect(']')
+				s.state = streamResponse
+				break
+			}
+			err = s.expect('{')
+			s.statement, s.messages = 0, nil
+			s.state = streamResult
+		case streamResult:
+			var key string
+			if key, err = s.key(); err != nil || key == "" {
+				s.state = streamResults
+				break
+			}
+			switch key {
+			case "statement_id":
+				err = s.dec.Decode(&s.statement)
+			case "series":
+				err = s.expect('[')
+				s.state = streamSeries
+			case "messages":
+				var messages []*Message
+				err = s.dec.Decode(&messages)
+				s.messages = append(s.messages, messages...)
+			case "error":
+				var msg string
+				if err = s.dec.Decode(&msg); err == nil {
+					s.stop(&StatementError{Statement: s.command, Message: msg})
+					return false
+				}
+			default:
+				err = s.skip()
+			}
+		case streamSeries:
+			if !s.dec.More() {
+				err = s.expect(']')
+				s.state = streamResult
+				break
+			}
+			var row models.Row
+			if err = s.dec.Decode(&row); err == nil {
+				convertNumbers([]Result{{Series: []models.Row{row}}}, s.numbers)
+				s.series = row
+				return true
+			}
+		}
+		if _, ok := err.(*StreamDecodeError); !ok && err != nil {
+			err = s.decodeError(err)
+		}
+		if err != nil {
+			s.stop(err)
+			return false
+		}
+	}
+}
+
+// StatementID returns the id of the statement of the current series.
+func (s *ResultStream) StatementID() int { return s.statement }
+
+// Series returns the current series.
+func (s *ResultStream) Series() models.Row { return s.series }
+
+// Messages returns the informational messages of the current statement
+// decoded so far. The server sends them after the series of the statement.
+func (s *ResultStream) Messages() []*Message { return s.messages }
+
+// Err returns the error that ended the stream, if any.
+func (s *ResultStream) Err() error { return s.err }
+
+// Close stops decoding and closes the body of the response. It is safe to
+// call at any time, and more than once.
+func (s *ResultStream) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	s.cancel()
+	err := s.body.Close()
+	s.release(nil)
+	if s.done != nil {
+		s.done(s.err)
+		s.done = nil
+	}
+	return err
+}
+
+// stop ends the stream with err.
+func (s *ResultStream) stop(err error) {
+	if err != nil {
+		s.err = s.release(err)
+	}
+	s.Close()
+}
+
+// key returns the next key of the current object, or "" at its end.
+func (s *ResultStream) key() (string, error) {
+	tok, err := s.dec.Token()
+	if err != nil {
+		return "", err
+	}
+	if tok == json.Delim('}') {
+		return "", nil
+	}
+	key, ok := tok.(string)
+	if !ok || key == "" {
+		return "", s.malformed("unexpected %v, expected an object key", tok)
+	}
+	return key, nil
+}
+
+// expect consumes the delimiter d.
+func (s *ResultStream) expect(d json.Delim) error {
+	tok, err := s.dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != d {
+		return s.malformed("unexpected %v, expected %v", tok, d)
+	}
+	return nil
+}
+
+// skip consumes a value of a key that is not known.
+func (s *ResultStream) skip() error {
+	var v json.RawMessage
+	return s.dec.Decode(&v)
+}
+
+// malformed returns a StreamDecodeError at the current offset.
+func (s *ResultStream) malformed(format string, args ...interface{}) error {
+	return &StreamDecodeError{Offset: s.dec.InputOffset(), Err: fmt.Errorf(format, args...)}
+}
+
+// decodeError returns the error reported for err, returned while decoding
+// the body. Errors reading the body are returned as they are, and malformed
+// content is reported at its offset.
+func (s *ResultStream) decodeError(err error) error {
+	if s.ctx.Err() != nil {
+		return s.ctx.Err()
+	}
+	offset := s.dec.InputOffset()
+	var syntax *json.SyntaxError
+	var typ *json.UnmarshalTypeError
+	switch {
+	case err == io.EOF && !s.started && s.errResp != nil:
+		return s.statusError()
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		// The body ended within the value being decoded, which is still
+		// buffered.
+		n, _ := io.Copy(ioutil.Discard, s.dec.Buffered())
+		offset += n
+		err = io.ErrUnexpectedEOF
+	case errors.As(err, &syntax):
+		offset = syntax.Offset
+	case errors.As(err, &typ):
+	default:
+		return err
+	}
+	return &StreamDecodeError{Offset: offset, Err: err}
+}
+
+// responseError returns the error of a response with the error msg.
+func (s *ResultStream) responseError(msg string) error {
+	if s.errResp != nil {
+		return newErrorResponse(s.errResp, msg)
+	}
+	return errors.New(msg)
+}
+
+// statusError returns the error of a response that ended without an error
+// of its own, which is nil unless its status is not 200.
+func (s *ResultStream) statusError() error {
+	if s.errResp != nil {
+		return newErrorResponse(s.errResp, "")
+	}
+	return nil
+}
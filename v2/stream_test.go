@@ -0,0 +1,166 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newStreamServer(t *testing.T, status int, body string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if accept := r.Header.Get("Accept"); accept != "application/json" {
+			t.Errorf("unexpected Accept header.  expected %v, actual %v", "application/json", accept)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Influxdb-Version", "1.8.10")
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}))
+}
+
+func queryStream(t *testing.T, ts *httptest.Server, q Query) *ResultStream {
+	t.Helper()
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, ResponseFormat: MsgpackFormat})
+	t.Cleanup(func() { c.Close() })
+	s, err := c.(StreamClient).QueryStream(context.Background(), q)
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	return s
+}
+
+func TestQueryStream(t *testing.T) {
+	ts := newStreamServer(t, http.StatusOK, `{"results":[`+
+		`{"statement_id":0,"series":[{"name":"cpu","columns":["time","v"],"values":[[1,1.5]]},{"name":"mem","tags":{"host":"a"},"columns":["time","v"],"values":[[2,3]]}],"messages":[{"level":"warning","text":"deprecated"}]},`+
+		`{"statement_id":1,"extra":{"ignored":[1,2]}},`+
+		`{"statement_id":2,"series":[{"name":"disk","columns":["time","v"],"values":[[3,4]]}]}`+
+		`]}`+"\n")
+	defer ts.Close()
+
+	s := queryStream(t, ts, NewQuery("SELECT v FROM cpu, mem; SELECT v FROM none; SELECT v FROM disk", "db0", ""))
+	defer s.Close()
+
+	var got []string
+	for s.Next() {
+		row := s.Series()
+		got = append(got, fmt.Sprintf("%d %s %v %v", s.StatementID(), row.Name, row.Tags, row.Values))
+	}
+	if err := s.Err(); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	exp := []string{"0 cpu map[] [[1 1.5]]", "0 mem map[host:a] [[2 3]]", "2 disk map[] [[3 4]]"}
+	if strings.Join(got, "\n") != strings.Join(exp, "\n") {
+		t.Errorf("unexpected series.\nexpected %q\nactual   %q", exp, got)
+	}
+	if s.Next() {
+		t.Error("unexpected series after the end of the stream")
+	}
+}
+
+func TestQueryStream_Chunked(t *testing.T) {
+	ts := newStreamServer(t, http.StatusOK,
+		`{"results":[{"statement_id":0,"series":[{"name":"cpu","columns":["v"],"values":[[1]],"partial":true}],"partial":true}]}`+"\n"+
+			`{"results":[{"statement_id":0,"series":[{"name":"cpu","columns":["v"],"values":[[2]]}]}]}`+"\n")
+	defer ts.Close()
+
+	s := queryStream(t, ts, Query{Command: "SELECT v FROM cpu", Database: "db0", Chunked: true})
+	defer s.Close()
+
+	var partial []bool
+	for s.Next() {
+		partial = append(partial, s.Series().Partial)
+	}
+	if err := s.Err(); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if len(partial) != 2 || !partial[0] || partial[1] {
+		t.Errorf("unexpected series partial flags: %v", partial)
+	}
+}
+
+func TestQueryStream_Close(t *testing.T) {
+	closed := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"results":[{"statement_id":0,"series":[{"name":"cpu","columns":["v"],"values":[[1]]},`)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+		close(closed)
+	}))
+	defer ts.Close()
+
+	s := queryStream(t, ts, NewQuery("SELECT v FROM cpu", "db0", ""))
+	if !s.Next() || s.Series().Name != "cpu" {
+		t.Fatalf("unexpected series: %v, %v", s.Series(), s.Err())
+	}
+	s.Close()
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the request was not aborted by Close")
+	}
+	if s.Next() || s.Err() != nil {
+		t.Errorf("unexpected stream after Close: %v", s.Err())
+	}
+}
+
+func TestQueryStream_Malformed(t *testing.T) {
+	first := `{"results":[{"statement_id":0,"series":[{"name":"cpu","columns":["v"],"values":[[1]]},`
+	for _, tt := range []struct {
+		body   string
+		offset int
+	}{
+		{body: first + `{"name":"mem","columns":[}`, offset: len(first) + 26},
+		{body: first + `{"name":"mem"`, offset: len(first) + 13},
+		{body: first + `7]}]}`, offset: len(first) + 1},
+	} {
+		body := tt.body
+		ts := newStreamServer(t, http.StatusOK, body)
+		s := queryStream(t, ts, NewQuery("SELECT v FROM cpu, mem", "db0", ""))
+		if !s.Next() {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, s.Err())
+		}
+		if s.Next() {
+			t.Errorf("unexpected series for %q: %v", body, s.Series())
+		}
+		var de *StreamDecodeError
+		if !errors.As(s.Err(), &de) || de.Offset != int64(tt.offset) {
+			t.Errorf("unexpected error for %q: %v", body, s.Err())
+		}
+		s.Close()
+		ts.Close()
+	}
+}
+
+func TestQueryStream_Errors(t *testing.T) {
+	ts := newStreamServer(t, http.StatusOK, `{"results":[`+
+		`{"statement_id":0,"series":[{"name":"cpu","columns":["v"],"values":[[1]]}]},`+
+		`{"statement_id":1,"error":"retention policy not found: rp0"}]}`)
+	defer ts.Close()
+
+	s := queryStream(t, ts, NewQuery("SELECT v FROM cpu; SELECT v FROM rp0.cpu", "db0", ""))
+	defer s.Close()
+	if !s.Next() {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, s.Err())
+	}
+	var se *StatementError
+	if s.Next() || !errors.As(s.Err(), &se) || !errors.Is(s.Err(), ErrRetentionPolicyNotFound) {
+		t.Errorf("unexpected error.  expected a statement error, actual %v", s.Err())
+	}
+
+	ts = newStreamServer(t, http.StatusBadRequest, `{"error":"error parsing query: found EOF"}`)
+	defer ts.Close()
+	s = queryStream(t, ts, NewQuery("SELECT", "db0", ""))
+	defer s.Close()
+	var er *ErrorResponse
+	if s.Next() || !errors.As(s.Err(), &er) || er.StatusCode != http.StatusBadRequest || er.Message != "error parsing query: found EOF" {
+		t.Errorf("unexpected error.  expected an error response, actual %v", s.Err())
+	}
+}
@@ -0,0 +1,107 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// checkStrictResponse checks the response of a query with StrictResponses:
+// its content type must be JSON, or MessagePack if format requested it.
+// Otherwise the error holds the start of the body.
+func checkStrictResponse(resp *http.Response, format ResponseFormat) error {
+	cType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if cType == jsonContentType || (cType == msgpackContentType && format != JSONFormat) {
+		return nil
+	}
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("expected json response, got %q, with status: %v and response body: %q", cType, resp.StatusCode, body)
+}
+
+// The keys of a response and of its results decoded into Response and
+// Result, in lower case as encoding/json matches them regardless of case.
+var (
+	responseKeys = map[string]bool{"results": true, "error": true}
+	resultKeys   = map[string]bool{"statement_id": true, "series": true, "messages": true, "error": true, "partial": true}
+)
+
+// strictDecoder decodes the JSON responses of a query with StrictResponses.
+// The keys of a response, or of its results, that Response does not hold
+// are reported to the logger, once per key for the responses of a chunked
+// query, rather than failing the query.
+type strictDecoder struct {
+	logger Logger
+	warned map[string]bool
+}
+
+func newStrictDecoder(logger Logger) *strictDecoder {
+	return &strictDecoder{logger: logger, warned: make(map[string]bool)}
+}
+
+// decode decodes the response raw into resp. A response without results nor
+// error, such as an empty object, is an error.
+func (d *strictDecoder) decode(raw json.RawMessage, resp *Response) error {
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &keys); err != nil || keys == nil {
+		return fmt.Errorf("expected a json object response, got %q", snippet(raw))
+	}
+	var unknown []string
+	var results json.RawMessage
+	hasErr := false
+	for k, v := range keys {
+		switch lk := strings.ToLower(k); {
+		case !responseKeys[lk]:
+			unknown = append(unknown, k)
+		case lk == "results":
+			results = v
+		case lk == "error":
+			hasErr = true
+		}
+	}
+	if !hasErr && results == nil {
+		return fmt.Errorf("expected results or an error in the response, got %q", snippet(raw))
+	}
+	var list []map[string]json.RawMessage
+	json.Unmarshal(results, &list)
+	for _, r := range list {
+		for k := range r {
+			if !resultKeys[strings.ToLower(k)] {
+				unknown = append(unknown, "results."+k)
+			}
+		}
+	}
+	d.warn(unknown)
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	return dec.Decode(resp)
+}
+
+// warn logs the keys not reported yet.
+func (d *strictDecoder) warn(keys []string) {
+	var fresh []string
+	for _, k := range keys {
+		if !d.warned[k] {
+			d.warned[k] = true
+			fresh = append(fresh, k)
+		}
+	}
+	if len(fresh) > 0 {
+		sort.Strings(fresh)
+		logf(d.logger, "influxdb: ignoring unknown keys of query response: %s", strings.Join(fresh, ", "))
+	}
+}
+
+// snippet returns the start of b, for an error message.
+func snippet(b []byte) []byte {
+	if len(b) > 1024 {
+		return b[:1024]
+	}
+	return b
+}
@@ -0,0 +1,87 @@
+package client
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newBodyServer returns a server answering every request with the content
+// type and body given.
+func newBodyServer(cType, body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", cType)
+		w.Header().Set("X-Influxdb-Version", "1.8.10")
+		io.WriteString(w, body)
+	}))
+}
+
+func TestClient_StrictResponses(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		cType  string
+		body   string
+		query  Query
+		strict string
+		lax    bool
+	}{
+		{"html page", "text/html", "<html>502 Bad Gateway</html>", Query{}, "502 Bad Gateway", false},
+		{"empty object", "application/json", "{}", Query{}, "expected results or an error", true},
+		{"empty chunk", "application/json", "{}\n", Query{Chunked: true}, "expected results or an error", true},
+		{"array", "application/json", "[]", Query{}, "expected a json object", false},
+		{"msgpack", "application/x-msgpack", "\x80", Query{}, `got "application/x-msgpack"`, true},
+	} {
+		ts := newBodyServer(tt.cType, tt.body)
+		tt.query.Command, tt.query.Database = "SELECT * FROM cpu", "db0"
+
+		c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+		if _, err := c.Query(tt.query); (err == nil) != tt.lax {
+			t.Errorf("%s: unexpected error without StrictResponses: %v", tt.name, err)
+		}
+		c.Close()
+
+		c, _ = NewHTTPClient(HTTPConfig{Addr: ts.URL, StrictResponses: true})
+		if _, err := c.Query(tt.query); err == nil || !strings.Contains(err.Error(), tt.strict) {
+			t.Errorf("%s: unexpected error.  expected %q, actual %v", tt.name, tt.strict, err)
+		}
+		c.Close()
+		ts.Close()
+	}
+}
+
+func TestClient_StrictResponsesUnknownKeys(t *testing.T) {
+	const chunk = `{"results":[{"statement_id":0,"series":[{"name":"cpu","columns":["time","value"],"values":[[1,2]]}],"took":"1ms"}],"trace":{}}` + "\n"
+	ts := newBodyServer("application/json", chunk+chunk)
+	defer ts.Close()
+
+	logger := newBufferLogger()
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, StrictResponses: true, Logger: logger})
+	defer c.Close()
+
+	resp, err := c.Query(Query{Command: "SELECT * FROM cpu", Database: "db0"})
+	if err != nil || len(resp.Results) != 1 || len(resp.Results[0].Series) != 1 {
+		t.Fatalf("unexpected query result: %v, %v", resp, err)
+	}
+
+	cr, err := c.QueryAsChunk(Query{Command: "SELECT * FROM cpu", Database: "db0"})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	for i := 0; i < 2; i++ {
+		if resp, err := cr.NextResponse(); err != nil || len(resp.Results) != 1 {
+			t.Fatalf("unexpected chunk %d: %v, %v", i, resp, err)
+		}
+	}
+	if _, err := cr.NextResponse(); err != io.EOF {
+		t.Errorf("unexpected error.  expected %v, actual %v", io.EOF, err)
+	}
+	cr.Close()
+
+	// Every response warns once, the chunks of a response only once for all.
+	const warning = "influxdb: ignoring unknown keys of query response: results.took, trace\n"
+	if exp := warning + warning; logger.String() != exp {
+		t.Errorf("unexpected log.\nexpected %q\nactual   %q", exp, logger.String())
+	}
+}
@@ -0,0 +1,121 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Subscription modes: whether every point is sent to all destinations of a
+// subscription, or each point to any one of them.
+const (
+	SubscriptionModeAll = "ALL"
+	SubscriptionModeAny = "ANY"
+)
+
+// SubscriptionSpec describes a subscription, through which the server
+// forwards the points written to a retention policy, such as to
+// influxdb-relay or Kapacitor.
+type SubscriptionSpec struct {
+	// Name of the subscription.
+	Name string
+
+	// RetentionPolicy is the retention policy whose points are forwarded.
+	RetentionPolicy string
+
+	// Mode is SubscriptionModeAll or SubscriptionModeAny, defaults to
+	// SubscriptionModeAll.
+	Mode string
+
+	// Destinations are the URLs the points are sent to, such as
+	// udp://relay:9096 or http://kapacitor:9092.
+	Destinations []string
+}
+
+// SubscriptionInfo is a subscription returned by ShowSubscriptions.
+type SubscriptionInfo struct {
+	// Database is the database of the subscription.
+	Database string
+
+	SubscriptionSpec
+}
+
+// CreateSubscription creates the subscription spec on the database db. It
+// fails with an error matching ErrSubscriptionExists if a subscription of the
+// same name exists.
+func CreateSubscription(ctx context.Context, c Client, db string, spec SubscriptionSpec) error {
+	if db == "" || spec.RetentionPolicy == "" || spec.Name == "" {
+		return errors.New("database, retention policy and subscription names are required")
+	}
+	mode := strings.ToUpper(spec.Mode)
+	switch mode {
+	case "":
+		mode = SubscriptionModeAll
+	case SubscriptionModeAll, SubscriptionModeAny:
+	default:
+		return fmt.Errorf("invalid subscription mode %q", spec.Mode)
+	}
+	if len(spec.Destinations) == 0 {
+		return errors.New("no subscription destination given")
+	}
+	dests := make([]string, len(spec.Destinations))
+	for i, d := range spec.Destinations {
+		dests[i] = QuoteString(d)
+	}
+	stmt := "CREATE SUBSCRIPTION " + QuoteIdent(spec.Name) + " ON " + QuoteIdent(db) + "." + QuoteIdent(spec.RetentionPolicy) +
+		" DESTINATIONS " + mode + " " + strings.Join(dests, ", ")
+	return execAdmin(ctx, c, stmt, db)
+}
+
+// DropSubscription drops the subscription name of the retention policy rp of
+// the database db.
+func DropSubscription(ctx context.Context, c Client, db, rp, name string) error {
+	if db == "" || rp == "" || name == "" {
+		return errors.New("database, retention policy and subscription names are required")
+	}
+	return execAdmin(ctx, c, "DROP SUBSCRIPTION "+QuoteIdent(name)+" ON "+QuoteIdent(db)+"."+QuoteIdent(rp), db)
+}
+
+// ShowSubscriptions returns the subscriptions of the database db, or of every
+// database if db is empty.
+func ShowSubscriptions(ctx context.Context, c Client, db string) ([]SubscriptionInfo, error) {
+	resp, err := queryStatement(ctx, c, db, "SHOW SUBSCRIPTIONS")
+	if err != nil {
+		return nil, err
+	}
+
+	var infos []SubscriptionInfo
+	for _, result := range resp.Results {
+		for _, row := range result.Series {
+			if db != "" && row.Name != db {
+				continue
+			}
+			for _, values := range row.Values {
+				info := SubscriptionInfo{Database: row.Name}
+				for i, col := range row.Columns {
+					if i >= len(values) {
+						break
+					}
+					switch col {
+					case "retention_policy":
+						info.RetentionPolicy, _ = values[i].(string)
+					case "name":
+						info.Name, _ = values[i].(string)
+					case "mode":
+						info.Mode, _ = values[i].(string)
+					case "destinations":
+						dests, _ := values[i].([]interface{})
+						for _, d := range dests {
+							if s, ok := d.(string); ok {
+								info.Destinations = append(info.Destinations, s)
+							}
+						}
+					}
+				}
+				infos = append(infos, info)
+			}
+		}
+	}
+	return infos, nil
+}
@@ -0,0 +1,134 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+func TestSubscription_Statements(t *testing.T) {
+	ts, statements := newStatementServer(t, nil)
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	ctx := context.Background()
+	steps := []struct {
+		run func() error
+		exp string
+	}{
+		{
+			run: func() error {
+				return CreateSubscription(ctx, c, "telegraf", SubscriptionSpec{
+					Name:            "relay",
+					RetentionPolicy: "autogen",
+					Destinations:    []string{"udp://relay-a:9096", "udp://relay-b:9096"},
+				})
+			},
+			exp: `CREATE SUBSCRIPTION "relay" ON "telegraf"."autogen" DESTINATIONS ALL 'udp://relay-a:9096', 'udp://relay-b:9096'`,
+		},
+		{
+			run: func() error {
+				return CreateSubscription(ctx, c, "telegraf", SubscriptionSpec{
+					Name:            "kapacitor",
+					RetentionPolicy: "1y",
+					Mode:            "any",
+					Destinations:    []string{"http://kapacitor:9092"},
+				})
+			},
+			exp: `CREATE SUBSCRIPTION "kapacitor" ON "telegraf"."1y" DESTINATIONS ANY 'http://kapacitor:9092'`,
+		},
+		{
+			run: func() error { return DropSubscription(ctx, c, "telegraf", "autogen", "relay") },
+			exp: `DROP SUBSCRIPTION "relay" ON "telegraf"."autogen"`,
+		},
+	}
+	for i, step := range steps {
+		if err := step.run(); err != nil {
+			t.Fatalf("%d: unexpected error: %v", i, err)
+		}
+		if got := (*statements)[i]; got != step.exp {
+			t.Errorf("%d: unexpected statement.\nexpected %s\nactual   %s", i, step.exp, got)
+		}
+	}
+}
+
+func TestShowSubscriptions(t *testing.T) {
+	columns := []string{"retention_policy", "name", "mode", "destinations"}
+	ts, _ := newShowServer(t, map[string][]models.Row{
+		"SHOW SUBSCRIPTIONS": {
+			{Name: "telegraf", Columns: columns, Values: [][]interface{}{
+				{"autogen", "relay", "ALL", []interface{}{"udp://relay-a:9096", "udp://relay-b:9096"}},
+			}},
+			{Name: "_internal", Columns: columns, Values: [][]interface{}{
+				{"monitor", "kapacitor", "ANY", []interface{}{"http://kapacitor:9092"}},
+			}},
+		},
+	})
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	infos, err := ShowSubscriptions(context.Background(), c, "telegraf")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	exp := []SubscriptionInfo{{
+		Database: "telegraf",
+		SubscriptionSpec: SubscriptionSpec{
+			Name:            "relay",
+			RetentionPolicy: "autogen",
+			Mode:            SubscriptionModeAll,
+			Destinations:    []string{"udp://relay-a:9096", "udp://relay-b:9096"},
+		},
+	}}
+	if !reflect.DeepEqual(infos, exp) {
+		t.Errorf("unexpected subscriptions.\nexpected %+v\nactual   %+v", exp, infos)
+	}
+
+	infos, err = ShowSubscriptions(context.Background(), c, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(infos) != 2 || infos[1].Database != "_internal" || infos[1].Mode != SubscriptionModeAny {
+		t.Errorf("unexpected subscriptions: %+v", infos)
+	}
+}
+
+func TestSubscription_Invalid(t *testing.T) {
+	ts, _ := newStatementServer(t, func(string) string { return "subscription already exists" })
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	ctx := context.Background()
+	valid := SubscriptionSpec{Name: "s", RetentionPolicy: "autogen", Destinations: []string{"udp://h:1"}}
+	err := CreateSubscription(ctx, c, "db0", valid)
+	if !errors.Is(err, ErrSubscriptionExists) || IgnoreExists(err) != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	for _, tt := range []struct {
+		name   string
+		modify func(*SubscriptionSpec)
+	}{
+		{name: "no retention policy", modify: func(s *SubscriptionSpec) { s.RetentionPolicy = "" }},
+		{name: "no destination", modify: func(s *SubscriptionSpec) { s.Destinations = nil }},
+		{name: "invalid mode", modify: func(s *SubscriptionSpec) { s.Mode = "SOME" }},
+	} {
+		spec := valid
+		tt.modify(&spec)
+		if err := CreateSubscription(ctx, c, "db0", spec); err == nil || errors.Is(err, ErrSubscriptionExists) {
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+		}
+	}
+	if err := DropSubscription(ctx, c, "db0", "", "s"); err == nil {
+		t.Error("expected an error for an empty retention policy")
+	}
+}
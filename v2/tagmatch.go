@@ -0,0 +1,208 @@
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+
+	"github.com/influxdata/influxdb1-client/models"
+	"github.com/influxdata/influxdb1-client/pkg/escape"
+)
+
+// TagOp is the comparison of a TagExpr.
+type TagOp int
+
+const (
+	// TagEquals matches the points whose tag has the value.
+	TagEquals TagOp = iota
+
+	// TagNotEquals matches the points whose tag has another value.
+	TagNotEquals
+
+	// TagRegex matches the points whose tag value matches the regular
+	// expression of the value, unanchored as in InfluxQL.
+	TagRegex
+
+	// TagExists matches the points with the tag, of any value.
+	TagExists
+)
+
+func (op TagOp) String() string {
+	switch op {
+	case TagEquals:
+		return "="
+	case TagNotEquals:
+		return "!="
+	case TagRegex:
+		return "=~"
+	case TagExists:
+		return "exists"
+	}
+	return fmt.Sprintf("TagOp(%d)", int(op))
+}
+
+// TagExpr is a condition on a tag of a point. As in InfluxQL, a point
+// without the tag has it with an empty value, so that TagEquals with an
+// empty value matches it, and TagNotEquals with a non-empty one.
+type TagExpr struct {
+	Key   string
+	Op    TagOp
+	Value string
+}
+
+func (e TagExpr) String() string {
+	if e.Op == TagExists {
+		return e.Key + " exists"
+	}
+	return fmt.Sprintf("%s %s %q", e.Key, e.Op, e.Value)
+}
+
+// TagMatcher matches the points meeting every condition of a list, see
+// CompileTagMatcher. It is safe for concurrent use.
+type TagMatcher struct {
+	exprs []compiledTagExpr
+}
+
+// compiledTagExpr is a TagExpr ready to be matched.
+type compiledTagExpr struct {
+	key   string
+	op    TagOp
+	value string
+	re    *regexp.Regexp
+}
+
+// CompileTagMatcher returns a TagMatcher matching the points meeting every
+// condition of exprs, all of them if there are none. The regular
+// expressions are compiled once here.
+func CompileTagMatcher(exprs ...TagExpr) (*TagMatcher, error) {
+	m := &TagMatcher{exprs: make([]compiledTagExpr, len(exprs))}
+	for i, e := range exprs {
+		if e.Key == "" {
+			return nil, fmt.Errorf("tag expression %d: empty tag key", i)
+		}
+		c := compiledTagExpr{key: e.Key, op: e.Op, value: e.Value}
+		switch e.Op {
+		case TagEquals, TagNotEquals, TagExists:
+		case TagRegex:
+			re, err := regexp.Compile(e.Value)
+			if err != nil {
+				return nil, fmt.Errorf("tag expression %d: %w", i, err)
+			}
+			c.re = re
+		default:
+			return nil, fmt.Errorf("tag expression %d: unknown operator %v", i, e.Op)
+		}
+		m.exprs[i] = c
+	}
+	return m, nil
+}
+
+// Match reports whether p meets every condition of the matcher. The tags
+// are read from the encoded series key of the point without building a
+// map, so that Match does not allocate but for the regular expressions
+// matched against a tag value holding escaped characters. A nil point is
+// never matched.
+func (m *TagMatcher) Match(p *Point) bool {
+	if p == nil {
+		return false
+	}
+	key := p.pt.Key()
+	for i := range m.exprs {
+		if !m.exprs[i].match(key) {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *compiledTagExpr) match(seriesKey []byte) bool {
+	value, ok := lookupTag(seriesKey, e.key)
+	switch e.op {
+	case TagEquals:
+		return unescapedEqual(value, e.value)
+	case TagNotEquals:
+		return !unescapedEqual(value, e.value)
+	case TagRegex:
+		if bytes.IndexByte(value, '\\') >= 0 {
+			value = escape.Unescape(value)
+		}
+		return e.re.Match(value)
+	}
+	return ok
+}
+
+// lookupTag returns the value, still escaped, of the tag key in the series
+// key of a point, and whether it has the tag.
+func lookupTag(seriesKey []byte, key string) ([]byte, bool) {
+	i := scanEscaped(seriesKey, 0, ',')
+	for i < len(seriesKey) {
+		start := i + 1
+		eq := scanEscaped(seriesKey, start, '=')
+		end := scanEscaped(seriesKey, eq+1, ',')
+		if eq < len(seriesKey) && unescapedEqual(seriesKey[start:eq], key) {
+			return seriesKey[eq+1 : end], true
+		}
+		i = end
+	}
+	return nil, false
+}
+
+// scanEscaped returns the index of the first c not escaped by a backslash in
+// b from i, len(b) if there is none.
+func scanEscaped(b []byte, i int, c byte) int {
+	for ; i < len(b); i++ {
+		switch b[i] {
+		case '\\':
+			i++
+		case c:
+			return i
+		}
+	}
+	return len(b)
+}
+
+// unescapedEqual reports whether the escaped tag key or value b is s once
+// unescaped.
+func unescapedEqual(b []byte, s string) bool {
+	if bytes.IndexByte(b, '\\') < 0 {
+		return string(b) == s
+	}
+	j := 0
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		if c == '\\' && i+1 < len(b) {
+			switch b[i+1] {
+			case ',', ' ', '=', '"':
+				i++
+				c = b[i]
+			}
+		}
+		if j >= len(s) || s[j] != c {
+			return false
+		}
+		j++
+	}
+	return j == len(s)
+}
+
+func (bp *batchpoints) Filter(m *TagMatcher) BatchPoints {
+	return bp.filter(m)
+}
+
+func (bp *batchpoints) filter(m *TagMatcher) *batchpoints {
+	var matched []*Point
+	size := 0
+	for _, p := range bp.Points() {
+		if m.Match(p) {
+			matched = append(matched, p)
+			size += models.PrecisionStringSize(p.pt, bp.precision) + 1
+		}
+	}
+	return bp.child(matched, size)
+}
+
+func (s *safeBatchPoints) Filter(m *TagMatcher) BatchPoints {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &safeBatchPoints{bp: s.bp.filter(m)}
+}
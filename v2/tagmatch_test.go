@@ -0,0 +1,143 @@
+package client
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTagMatchPoint(t testing.TB, tags map[string]string) *Point {
+	t.Helper()
+	p, err := NewPoint("cpu", tags, map[string]interface{}{"value": 1.0}, time.Unix(0, 0))
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	return p
+}
+
+func TestTagMatcher_Match(t *testing.T) {
+	p := newTagMatchPoint(t, map[string]string{"host": "web-1", "env": "prod", "dc": "eu west,1", "k=y": "v"})
+	for _, tt := range []struct {
+		exprs []TagExpr
+		exp   bool
+	}{
+		{nil, true},
+		{[]TagExpr{{Key: "host", Op: TagEquals, Value: "web-1"}}, true},
+		{[]TagExpr{{Key: "host", Op: TagEquals, Value: "web"}}, false},
+		{[]TagExpr{{Key: "host", Op: TagRegex, Value: "^web"}, {Key: "env", Op: TagNotEquals, Value: "dev"}}, true},
+		{[]TagExpr{{Key: "host", Op: TagRegex, Value: "^db"}, {Key: "env", Op: TagNotEquals, Value: "dev"}}, false},
+		{[]TagExpr{{Key: "env", Op: TagNotEquals, Value: "prod"}}, false},
+		{[]TagExpr{{Key: "env", Op: TagExists}}, true},
+		{[]TagExpr{{Key: "region", Op: TagExists}}, false},
+		{[]TagExpr{{Key: "region", Op: TagEquals, Value: ""}}, true},
+		{[]TagExpr{{Key: "region", Op: TagNotEquals, Value: "eu"}}, true},
+		{[]TagExpr{{Key: "dc", Op: TagEquals, Value: "eu west,1"}}, true},
+		{[]TagExpr{{Key: "dc", Op: TagRegex, Value: "^eu west,"}}, true},
+		{[]TagExpr{{Key: "k=y", Op: TagEquals, Value: "v"}}, true},
+		{[]TagExpr{{Key: "k", Op: TagExists}}, false},
+	} {
+		m, err := CompileTagMatcher(tt.exprs...)
+		if err != nil {
+			t.Fatalf("%v: unexpected error.  expected %v, actual %v", tt.exprs, nil, err)
+		}
+		if got := m.Match(p); got != tt.exp {
+			t.Errorf("%v: unexpected match.  expected %v, actual %v", tt.exprs, tt.exp, got)
+		}
+	}
+
+	m, _ := CompileTagMatcher()
+	if m.Match(nil) {
+		t.Error("unexpected match of a nil point")
+	}
+}
+
+func TestCompileTagMatcher_Invalid(t *testing.T) {
+	for _, tt := range []struct {
+		expr TagExpr
+		err  string
+	}{
+		{TagExpr{Op: TagExists}, "empty tag key"},
+		{TagExpr{Key: "host", Op: TagRegex, Value: "web("}, "missing closing )"},
+		{TagExpr{Key: "host", Op: TagOp(42)}, "unknown operator"},
+	} {
+		_, err := CompileTagMatcher(TagExpr{Key: "env", Op: TagExists}, tt.expr)
+		if err == nil || !strings.Contains(err.Error(), "tag expression 1") || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%v: unexpected error.  expected %q, actual %v", tt.expr, tt.err, err)
+		}
+	}
+}
+
+func TestBatchPoints_Filter(t *testing.T) {
+	for _, newBatch := range []func(BatchPointsConfig) (BatchPoints, error){NewBatchPoints, func(conf BatchPointsConfig) (BatchPoints, error) {
+		return NewSafeBatchPoints(conf)
+	}} {
+		bp, _ := newBatch(BatchPointsConfig{Database: "db0", RetentionPolicy: "rp0", Precision: "s"})
+		for _, host := range []string{"web-1", "db-1", "web-2"} {
+			bp.AddPoint(newTagMatchPoint(t, map[string]string{"host": host}))
+		}
+		m, _ := CompileTagMatcher(TagExpr{Key: "host", Op: TagRegex, Value: "^web"})
+		filtered := bp.Filter(m)
+
+		if filtered.Database() != "db0" || filtered.RetentionPolicy() != "rp0" || filtered.Precision() != "s" {
+			t.Errorf("unexpected settings: %s %s %s", filtered.Database(), filtered.RetentionPolicy(), filtered.Precision())
+		}
+		points := filtered.Points()
+		if len(points) != 2 || points[0].Tags()["host"] != "web-1" || points[1].Tags()["host"] != "web-2" {
+			t.Errorf("unexpected points: %v", points)
+		}
+		if n := len(bp.Points()); n != 3 {
+			t.Errorf("unexpected points left in the batch.  expected %v, actual %v", 3, n)
+		}
+		exp, _ := NewBatchPoints(BatchPointsConfig{Precision: "s"})
+		exp.AddPoints(points)
+		if filtered.Size() != exp.Size() {
+			t.Errorf("unexpected size.  expected %v, actual %v", exp.Size(), filtered.Size())
+		}
+	}
+}
+
+func TestTagMatcher_MatchAllocs(t *testing.T) {
+	p := newTenTagPoint()
+	m, err := CompileTagMatcher(
+		TagExpr{Key: "tag5", Op: TagEquals, Value: "value5"},
+		TagExpr{Key: "tag9", Op: TagRegex, Value: "^val"},
+		TagExpr{Key: "tag0", Op: TagNotEquals, Value: "dev"},
+		TagExpr{Key: "tag1", Op: TagExists},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if !m.Match(p) {
+		t.Fatalf("expected %v to match", p)
+	}
+	if allocs := testing.AllocsPerRun(100, func() { m.Match(p) }); allocs != 0 {
+		t.Errorf("unexpected allocations.  expected %v, actual %v", 0, allocs)
+	}
+}
+
+func BenchmarkTagMatcher_Match(b *testing.B) {
+	p := newTenTagPoint()
+	m, _ := CompileTagMatcher(
+		TagExpr{Key: "tag5", Op: TagEquals, Value: "value5"},
+		TagExpr{Key: "tag9", Op: TagRegex, Value: "^val"},
+		TagExpr{Key: "tag0", Op: TagNotEquals, Value: "dev"},
+	)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if !m.Match(p) {
+			b.Fatal("no match")
+		}
+	}
+}
+
+func BenchmarkTagMatcher_MatchTags(b *testing.B) {
+	// The map-based check Match replaces, for comparison.
+	p := newTenTagPoint()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		tags := p.Tags()
+		if tags["tag5"] != "value5" || !strings.HasPrefix(tags["tag9"], "val") || tags["tag0"] == "dev" {
+			b.Fatal("no match")
+		}
+	}
+}
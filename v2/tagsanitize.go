@@ -0,0 +1,191 @@
+package client
+
+import (
+	"bytes"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+// TagSanitizerConfig is the config data needed to create a TagSanitizer. The
+// rules that are set apply to the keys and values of the tags, except for
+// LowercaseKeys and MaxValueLength.
+type TagSanitizerConfig struct {
+	// TrimSpace removes the leading and trailing white space.
+	TrimSpace bool
+
+	// ReplaceUnprintable replaces the characters the server rejects in a
+	// tag, the control characters such as newlines and tabs and the white
+	// space other than the ASCII space, with Replacement.
+	ReplaceUnprintable bool
+
+	// ValidUTF8 replaces every invalid UTF-8 sequence with Replacement.
+	ValidUTF8 bool
+
+	// Replacement is the character characters and sequences are replaced
+	// with, defaults to '_'. It must be printable.
+	Replacement rune
+
+	// LowercaseKeys lowercases the keys. The keys that are only told apart
+	// by case become one, with the value of the last of them in the order
+	// of the keys.
+	LowercaseKeys bool
+
+	// MaxValueLength, if set, truncates the values to at most that many
+	// bytes, without splitting a character.
+	MaxValueLength int
+
+	// OnSanitized, if set, is called for every tag of a point that the
+	// rules changed, with the point as it was added, the key and value of
+	// the tag, and the key and value it was changed to. A tag whose key or
+	// value is left empty is removed from the point. It is called from
+	// AddPoint and must not block for long.
+	OnSanitized func(p *Point, key, value, sanitizedKey, sanitizedValue string)
+}
+
+// TagSanitizer cleans the tags of the points added to a batch, see
+// BatchPointsConfig.TagSanitizer, so that a tag coming from user input neither
+// breaks the line protocol nor makes a series that only differs by white
+// space or case from another. A point whose tags change is replaced by a
+// new one, the point added is left as it was. TagSanitizer is safe for
+// concurrent use by multiple goroutines, and can be shared by batches.
+type TagSanitizer struct {
+	trimSpace      bool
+	unprintable    bool
+	validUTF8      bool
+	replacement    []byte
+	lowercaseKeys  bool
+	maxValueLength int
+	onSanitized    func(*Point, string, string, string, string)
+}
+
+// DefaultTagSanitizer trims white space, and replaces the unprintable
+// characters and invalid UTF-8 sequences with '_'.
+var DefaultTagSanitizer, _ = NewTagSanitizer(TagSanitizerConfig{
+	TrimSpace:          true,
+	ReplaceUnprintable: true,
+	ValidUTF8:          true,
+})
+
+// NewTagSanitizer returns a TagSanitizer based on the given config.
+func NewTagSanitizer(conf TagSanitizerConfig) (*TagSanitizer, error) {
+	if conf.Replacement == 0 {
+		conf.Replacement = '_'
+	}
+	if !unicode.IsPrint(conf.Replacement) || conf.Replacement == unicode.ReplacementChar {
+		return nil, &ConfigError{Field: "Replacement", Reason: "must be printable"}
+	}
+	if conf.MaxValueLength < 0 {
+		return nil, &ConfigError{Field: "MaxValueLength", Reason: "must not be negative"}
+	}
+	return &TagSanitizer{
+		trimSpace:      conf.TrimSpace,
+		unprintable:    conf.ReplaceUnprintable,
+		validUTF8:      conf.ValidUTF8,
+		replacement:    []byte(string(conf.Replacement)),
+		lowercaseKeys:  conf.LowercaseKeys,
+		maxValueLength: conf.MaxValueLength,
+		onSanitized:    conf.OnSanitized,
+	}, nil
+}
+
+// Sanitize returns the key and value of a tag as the rules change them.
+func (s *TagSanitizer) Sanitize(key, value string) (string, string) {
+	return string(s.clean([]byte(key), true)), string(s.clean([]byte(value), false))
+}
+
+// clean returns b as the rules change a key, or a value, of a tag. It only
+// allocates if b is changed other than by trimming it.
+func (s *TagSanitizer) clean(b []byte, key bool) []byte {
+	if s.validUTF8 && !utf8.Valid(b) {
+		b = bytes.ToValidUTF8(b, s.replacement)
+	}
+	if s.trimSpace {
+		b = bytes.TrimSpace(b)
+	}
+	if s.unprintable {
+		b = s.replaceUnprintable(b)
+		if s.trimSpace {
+			// The replacement can be a space.
+			b = bytes.TrimSpace(b)
+		}
+	}
+	if key && s.lowercaseKeys && bytes.IndexFunc(b, unicode.IsUpper) >= 0 {
+		b = bytes.ToLower(b)
+	}
+	if !key && s.maxValueLength > 0 && len(b) > s.maxValueLength {
+		n := s.maxValueLength
+		for n > 0 && !utf8.RuneStart(b[n]) {
+			n--
+		}
+		b = b[:n]
+		if s.trimSpace {
+			b = bytes.TrimRightFunc(b, unicode.IsSpace)
+		}
+	}
+	return b
+}
+
+// replaceUnprintable returns b with its unprintable characters replaced.
+// The bytes of invalid UTF-8 sequences are left as they are.
+func (s *TagSanitizer) replaceUnprintable(b []byte) []byte {
+	unprintable := func(r rune) bool {
+		return r != utf8.RuneError && !unicode.IsPrint(r)
+	}
+	i := bytes.IndexFunc(b, unprintable)
+	if i < 0 {
+		return b
+	}
+	out := make([]byte, i, len(b))
+	copy(out, b)
+	for i < len(b) {
+		r, size := utf8.DecodeRune(b[i:])
+		if unprintable(r) {
+			out = append(out, s.replacement...)
+		} else {
+			out = append(out, b[i:i+size]...)
+		}
+		i += size
+	}
+	return out
+}
+
+// apply returns p, or a point with its tags sanitized if the rules change
+// any of them.
+func (s *TagSanitizer) apply(p *Point) *Point {
+	if p == nil {
+		return nil
+	}
+	// The tags are checked in the key of the point, and only parsed if
+	// one of them changes.
+	var changed bool
+	p.pt.ForEachTag(func(k, v []byte) bool {
+		changed = !bytes.Equal(s.clean(k, true), k) || !bytes.Equal(s.clean(v, false), v)
+		return !changed
+	})
+	if !changed {
+		return p
+	}
+
+	tags := p.pt.Tags()
+	sanitized := make(map[string]string, len(tags))
+	for _, t := range tags {
+		k, v := s.clean(t.Key, true), s.clean(t.Value, false)
+		if s.onSanitized != nil && (!bytes.Equal(k, t.Key) || !bytes.Equal(v, t.Value)) {
+			s.onSanitized(p, string(t.Key), string(t.Value), string(k), string(v))
+		}
+		if len(k) > 0 && len(v) > 0 {
+			sanitized[string(k)] = string(v)
+		}
+	}
+	fields, err := p.pt.Fields()
+	if err != nil {
+		return p
+	}
+	pt, err := models.NewPoint(string(p.pt.Name()), models.NewTags(sanitized), fields, p.pt.Time())
+	if err != nil {
+		return p
+	}
+	return &Point{pt: pt}
+}
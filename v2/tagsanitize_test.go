@@ -0,0 +1,141 @@
+package client
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestSanitizer(t *testing.T, conf TagSanitizerConfig) *TagSanitizer {
+	t.Helper()
+	s, err := NewTagSanitizer(conf)
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	return s
+}
+
+func TestTagSanitizer_Rules(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		conf       TagSanitizerConfig
+		key, value string
+		expKey     string
+		expValue   string
+	}{
+		{name: "none", key: " Host ", value: " web1\n", expKey: " Host ", expValue: " web1\n"},
+		{name: "trim", conf: TagSanitizerConfig{TrimSpace: true}, key: " host", value: "\tweb1 \n", expKey: "host", expValue: "web1"},
+		{name: "unprintable", conf: TagSanitizerConfig{ReplaceUnprintable: true}, key: "ho\tst", value: "web\n1 ", expKey: "ho_st", expValue: "web_1_"},
+		{name: "replacement", conf: TagSanitizerConfig{ReplaceUnprintable: true, Replacement: '-'}, key: "host", value: "a\r\nb", expKey: "host", expValue: "a--b"},
+		{name: "utf8", conf: TagSanitizerConfig{ValidUTF8: true}, key: "h\xffost", value: "w\xc3eb1", expKey: "h_ost", expValue: "w_eb1"},
+		{name: "utf8 left", conf: TagSanitizerConfig{ReplaceUnprintable: true}, key: "host", value: "w\xffeb", expKey: "host", expValue: "w\xffeb"},
+		{name: "lowercase", conf: TagSanitizerConfig{LowercaseKeys: true}, key: "HostName", value: "Web1", expKey: "hostname", expValue: "Web1"},
+		{name: "truncate", conf: TagSanitizerConfig{MaxValueLength: 4}, key: "hostname", value: "web1.example.com", expKey: "hostname", expValue: "web1"},
+		{name: "truncate rune", conf: TagSanitizerConfig{MaxValueLength: 4}, key: "city", value: "Zürich", expKey: "city", expValue: "Zür"},
+		{name: "truncate trim", conf: TagSanitizerConfig{TrimSpace: true, MaxValueLength: 5}, key: "host", value: "web1 abc", expKey: "host", expValue: "web1"},
+		{name: "trim replaced space", conf: TagSanitizerConfig{TrimSpace: true, ReplaceUnprintable: true, Replacement: ' '}, key: "host", value: " web1\t", expKey: "host", expValue: "web1"},
+		{name: "default", conf: TagSanitizerConfig{TrimSpace: true, ReplaceUnprintable: true, ValidUTF8: true}, key: " Host\n", value: " we\nb\xff1 ", expKey: "Host", expValue: "we_b_1"},
+	} {
+		s := newTestSanitizer(t, tt.conf)
+		if k, v := s.Sanitize(tt.key, tt.value); k != tt.expKey || v != tt.expValue {
+			t.Errorf("unexpected tag with %s.  expected %q=%q, actual %q=%q", tt.name, tt.expKey, tt.expValue, k, v)
+		}
+		// Sanitizing is idempotent.
+		if k, v := s.Sanitize(tt.expKey, tt.expValue); k != tt.expKey || v != tt.expValue {
+			t.Errorf("unexpected tag sanitized again with %s.  expected %q=%q, actual %q=%q", tt.name, tt.expKey, tt.expValue, k, v)
+		}
+	}
+	if k, v := DefaultTagSanitizer.Sanitize("host ", "web1\r\n"); k != "host" || v != "web1" {
+		t.Errorf("unexpected tag with DefaultTagSanitizer.  expected %q=%q, actual %q=%q", "host", "web1", k, v)
+	}
+}
+
+func TestTagSanitizer_AddPoint(t *testing.T) {
+	type change struct{ key, value, sanitizedKey, sanitizedValue string }
+	var changes []change
+	s := newTestSanitizer(t, TagSanitizerConfig{
+		TrimSpace:          true,
+		ReplaceUnprintable: true,
+		LowercaseKeys:      true,
+		MaxValueLength:     8,
+		OnSanitized: func(p *Point, key, value, sanitizedKey, sanitizedValue string) {
+			changes = append(changes, change{key, value, sanitizedKey, sanitizedValue})
+		},
+	})
+	bp, _ := NewBatchPoints(BatchPointsConfig{TagSanitizer: s})
+
+	clean := mustPoint(t, "cpu", map[string]string{"host": "web1"}, map[string]interface{}{"v": 1.0}, time.Unix(0, 1))
+	dirty := mustPoint(t, "cpu", map[string]string{"Host": "web1 ", "dc": "eu\nwest-1-long", "empty": " ", "region": "x"}, map[string]interface{}{"v": 1.0}, time.Unix(0, 2))
+	bp.AddPoints([]*Point{clean, dirty})
+
+	points := bp.Points()
+	if points[0] != clean {
+		t.Errorf("unexpected point replaced without a change")
+	}
+	if exp, s := `cpu,dc=eu_west-,host=web1,region=x v=1 2`, points[1].String(); s != exp {
+		t.Errorf("unexpected point.  expected %v, actual %v", exp, s)
+	}
+	if exp, s := "cpu,Host=web1\\ ,dc=eu\nwest-1-long,empty=\\ ,region=x v=1 2", dirty.String(); s != exp {
+		t.Errorf("unexpected point added changed.  expected %q, actual %q", exp, s)
+	}
+	exp := []change{
+		{"Host", "web1 ", "host", "web1"},
+		{"dc", "eu\nwest-1-long", "dc", "eu_west-"},
+		{"empty", " ", "empty", ""},
+	}
+	if len(changes) != len(exp) {
+		t.Fatalf("unexpected changes.  expected %q, actual %q", exp, changes)
+	}
+	for i := range exp {
+		if changes[i] != exp[i] {
+			t.Errorf("unexpected change %d.  expected %q, actual %q", i, exp[i], changes[i])
+		}
+	}
+}
+
+func TestTagSanitizer_Combinations(t *testing.T) {
+	// The keys that only differ by case or white space become one, with
+	// the value of the last of them.
+	s := newTestSanitizer(t, TagSanitizerConfig{TrimSpace: true, LowercaseKeys: true})
+	bp, _ := NewBatchPoints(BatchPointsConfig{TagSanitizer: s})
+	bp.AddPoint(mustPoint(t, "cpu", map[string]string{"HOST": "a", "Host": "b", "host ": "c"}, map[string]interface{}{"v": 1.0}, time.Unix(0, 1)))
+	if exp, s := `cpu,host=c v=1 1`, bp.Points()[0].String(); s != exp {
+		t.Errorf("unexpected point.  expected %v, actual %v", exp, s)
+	}
+
+	// The limiter counts the sanitized values.
+	l := newTestLimiter(t, CardinalityLimiterConfig{MaxValues: 1, Action: CardinalityRejectPoint})
+	bp, _ = NewBatchPoints(BatchPointsConfig{TagSanitizer: DefaultTagSanitizer, CardinalityLimiter: l})
+	for _, host := range []string{"web1", "web1 ", " web1\n"} {
+		bp.AddPoint(mustPoint(t, "cpu", map[string]string{"host": host}, map[string]interface{}{"v": 1.0}, time.Unix(0, 1)))
+	}
+	if n := len(bp.Points()); n != 3 {
+		t.Errorf("unexpected points.  expected %v, actual %v", 3, n)
+	}
+
+	// The batches of a BatchingClient, and those split from a batch,
+	// sanitize with the sanitizer of their config.
+	r := &batchRecorder{}
+	bc, _ := NewBatchingClient(r, BatchingOptions{BatchPointsConfig: BatchPointsConfig{TagSanitizer: DefaultTagSanitizer}})
+	bc.AddPoint(mustPoint(t, "cpu", map[string]string{"host": "web1\t"}, map[string]interface{}{"v": 1.0}, time.Unix(0, 1)))
+	bc.Close()
+	if len(r.batches) != 1 || r.batches[0][0].String() != `cpu,host=web1 v=1 1` {
+		t.Errorf("unexpected points written: %v", r.batches)
+	}
+	if conf := batchPointsConfig(bp); conf.TagSanitizer != DefaultTagSanitizer {
+		t.Errorf("unexpected sanitizer in the batch config")
+	}
+}
+
+func TestNewTagSanitizer_Errors(t *testing.T) {
+	for _, conf := range []TagSanitizerConfig{
+		{Replacement: '\n'},
+		{Replacement: '�'},
+		{MaxValueLength: -1},
+	} {
+		var ce *ConfigError
+		if _, err := NewTagSanitizer(conf); !errors.As(err, &ce) {
+			t.Errorf("unexpected error for %+v.  expected a *ConfigError, actual %v", conf, err)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+// DefaultMissingTag is the value SeriesByTags puts in the key of a series
+// for a tag it does not have.
+const DefaultMissingTag = "<missing>"
+
+// SeriesGrouper keys the series of a response, such as of a query with
+// GROUP BY *, by the values of some of their tags.
+type SeriesGrouper struct {
+	// Separator joins the tag values of a key, defaults to ",".
+	Separator string
+
+	// MissingTag stands for a tag a series does not have, or has empty, in
+	// its key, defaults to DefaultMissingTag.
+	MissingTag string
+}
+
+// SeriesCollisionError is returned by SeriesByTags for two distinct series
+// with the same key, which usually means that a tag telling them apart was
+// left out of the keys.
+type SeriesCollisionError struct {
+	// Key is the key of both series.
+	Key string
+
+	// Series are the two series, with their names and tags but no values.
+	Series [2]models.Row
+}
+
+func (e *SeriesCollisionError) Error() string {
+	return fmt.Sprintf("series %s and %s have the same key %q; a tag is missing from the keys",
+		seriesString(e.Series[0]), seriesString(e.Series[1]), e.Key)
+}
+
+// seriesString formats the name and tags of row as a series key, as in
+// "cpu,host=a".
+func seriesString(row models.Row) string {
+	return row.Name + string(models.NewTags(row.Tags).HashKey())
+}
+
+// SeriesByTags returns the series of r keyed by the values of the tags keys,
+// see SeriesGrouper.SeriesByTags.
+func (r *Response) SeriesByTags(keys ...string) (map[string]models.Row, error) {
+	return SeriesGrouper{}.SeriesByTags(r, keys...)
+}
+
+// SeriesByTags reads the rest of the chunks of r, and returns their series
+// keyed by the values of the tags keys, see SeriesGrouper.SeriesByTags. The
+// parts of a series split across chunks are merged.
+func (r *ChunkedResponse) SeriesByTags(keys ...string) (map[string]models.Row, error) {
+	return SeriesGrouper{}.ChunkedSeriesByTags(r, keys...)
+}
+
+// SeriesByTags returns the series of resp keyed by the values of their tags
+// keys, in order, joined by the Separator, such as "server01,eu-west" for
+// the keys "host" and "region". The values of a series found in several
+// results, such as the chunks of a chunked query, are appended in order. It
+// returns a *SeriesCollisionError if two distinct series, by name, tags or
+// statement, have the same key, and the first error of the response, if any.
+// The series of resp are not modified.
+func (g SeriesGrouper) SeriesByTags(resp *Response, keys ...string) (map[string]models.Row, error) {
+	if err := resp.Error(); err != nil {
+		return nil, err
+	}
+	m := make(seriesMap)
+	if err := g.add(m, resp.Results, keys); err != nil {
+		return nil, err
+	}
+	return m.rows(), nil
+}
+
+// ChunkedSeriesByTags is like SeriesByTags for the chunks of cr, which it
+// reads to the end.
+func (g SeriesGrouper) ChunkedSeriesByTags(cr *ChunkedResponse, keys ...string) (map[string]models.Row, error) {
+	m := make(seriesMap)
+	for {
+		resp, err := cr.NextResponse()
+		if err == io.EOF || err == nil && resp == nil {
+			return m.rows(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := resp.Error(); err != nil {
+			return nil, err
+		}
+		if err := g.add(m, resp.Results, keys); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// seriesMap holds the series keyed by SeriesByTags, along with the
+// statements they come from.
+type seriesMap map[string]*keyedSeries
+
+type keyedSeries struct {
+	row       models.Row
+	statement int
+	id        string
+}
+
+func (m seriesMap) rows() map[string]models.Row {
+	rows := make(map[string]models.Row, len(m))
+	for k, s := range m {
+		rows[k] = s.row
+	}
+	return rows
+}
+
+// add adds the series of results to m.
+func (g SeriesGrouper) add(m seriesMap, results []Result, keys []string) error {
+	for _, result := range results {
+		for _, row := range result.Series {
+			key := g.key(row.Tags, keys)
+			id := seriesString(row)
+			s, ok := m[key]
+			if !ok {
+				row.Partial = false
+				m[key] = &keyedSeries{row: row, statement: result.StatementId, id: id}
+				continue
+			}
+			if s.id != id || s.statement != result.StatementId {
+				return &SeriesCollisionError{Key: key, Series: [2]models.Row{
+					{Name: s.row.Name, Tags: s.row.Tags},
+					{Name: row.Name, Tags: row.Tags},
+				}}
+			}
+			// The values are copied rather than appended to those of
+			// the response.
+			values := s.row.Values
+			s.row.Values = append(values[:len(values):len(values)], row.Values...)
+		}
+	}
+	return nil
+}
+
+// key returns the key of a series with tags.
+func (g SeriesGrouper) key(tags map[string]string, keys []string) string {
+	sep, missing := g.Separator, g.MissingTag
+	if sep == "" {
+		sep = ","
+	}
+	if missing == "" {
+		missing = DefaultMissingTag
+	}
+	values := make([]string, len(keys))
+	for i, k := range keys {
+		if v := tags[k]; v != "" {
+			values[i] = v
+		} else {
+			values[i] = missing
+		}
+	}
+	return strings.Join(values, sep)
+}
+
+// TagSets returns the distinct tag sets of the series of r, in the order
+// they first appear, such as to find the tags of a query with GROUP BY *
+// before keying its series with SeriesByTags. Tags with empty values, which
+// the server returns for the series that do not have a tag grouped by, are
+// left out.
+func (r *Response) TagSets() []map[string]string {
+	var sets []map[string]string
+	seen := make(map[string]bool)
+	for _, result := range r.Results {
+		for _, row := range result.Series {
+			tags := make(map[string]string, len(row.Tags))
+			for k, v := range row.Tags {
+				if v != "" {
+					tags[k] = v
+				}
+			}
+			key := string(models.NewTags(tags).HashKey())
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			sets = append(sets, tags)
+		}
+	}
+	return sets
+}
+
+// TagKeys returns the keys of the tags of sets, sorted, such as the tags of
+// the result of TagSets to key series by all of them.
+func TagKeys(sets []map[string]string) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, tags := range sets {
+		for k := range tags {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
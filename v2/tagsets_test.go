@@ -0,0 +1,88 @@
+package client
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+// groupByStarResponse is the response of a query with GROUP BY * whose series
+// have overlapping but different tag keys.
+func groupByStarResponse() *Response {
+	columns := []string{"time", "value"}
+	return &Response{Results: []Result{{Series: []models.Row{
+		{Name: "cpu", Tags: map[string]string{"host": "a", "region": "eu", "rack": ""}, Columns: columns, Values: [][]interface{}{{1, 1}}},
+		{Name: "cpu", Tags: map[string]string{"host": "b", "region": "eu", "rack": "r1"}, Columns: columns, Values: [][]interface{}{{1, 2}}},
+		{Name: "cpu", Tags: map[string]string{"host": "c", "region": ""}, Columns: columns, Values: [][]interface{}{{1, 3}}},
+	}}}}
+}
+
+func TestResponse_SeriesByTags(t *testing.T) {
+	series, err := groupByStarResponse().SeriesByTags("region", "host")
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	var keys []string
+	for k := range series {
+		keys = append(keys, k)
+	}
+	if len(keys) != 3 || series["eu,a"].Values[0][1] != 1 || series["eu,b"].Values[0][1] != 2 || series["<missing>,c"].Values[0][1] != 3 {
+		t.Errorf("unexpected series: %v", series)
+	}
+
+	g := SeriesGrouper{Separator: "/", MissingTag: "none"}
+	series, err = g.SeriesByTags(groupByStarResponse(), "rack", "host")
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	for _, k := range []string{"none/a", "r1/b", "none/c"} {
+		if _, ok := series[k]; !ok {
+			t.Errorf("missing series %q in %v", k, series)
+		}
+	}
+
+	// Without the host, two series of eu have the same key.
+	_, err = groupByStarResponse().SeriesByTags("region")
+	var ce *SeriesCollisionError
+	if !errors.As(err, &ce) || ce.Key != "eu" {
+		t.Fatalf("unexpected error.  expected %T, actual %v", ce, err)
+	}
+	if exp := `series cpu,host=a,region=eu and cpu,host=b,rack=r1,region=eu have the same key "eu"; a tag is missing from the keys`; err.Error() != exp {
+		t.Errorf("unexpected error message.\nexpected %s\nactual   %s", exp, err)
+	}
+}
+
+func TestResponse_TagSets(t *testing.T) {
+	resp := groupByStarResponse()
+	resp.Results = append(resp.Results, Result{Series: []models.Row{{Name: "mem", Tags: map[string]string{"host": "c"}}}})
+	sets := resp.TagSets()
+	exp := []map[string]string{
+		{"host": "a", "region": "eu"},
+		{"host": "b", "region": "eu", "rack": "r1"},
+		{"host": "c"},
+	}
+	if !reflect.DeepEqual(sets, exp) {
+		t.Errorf("unexpected tag sets.  expected %v, actual %v", exp, sets)
+	}
+	if keys := TagKeys(sets); !reflect.DeepEqual(keys, []string{"host", "rack", "region"}) {
+		t.Errorf("unexpected tag keys: %v", keys)
+	}
+}
+
+func TestChunkedResponse_SeriesByTags(t *testing.T) {
+	// The series of host a is split across the first two chunks.
+	cr := NewChunkedResponse(strings.NewReader(
+		`{"results":[{"statement_id":0,"series":[{"name":"cpu","tags":{"host":"a"},"columns":["time","value"],"values":[[1,1],[2,2]],"partial":true}],"partial":true}]}
+{"results":[{"statement_id":0,"series":[{"name":"cpu","tags":{"host":"a"},"columns":["time","value"],"values":[[3,3]]},{"name":"cpu","tags":{"host":"b"},"columns":["time","value"],"values":[[1,4]]}]}]}
+`))
+	series, err := cr.SeriesByTags("host")
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if len(series) != 2 || len(series["a"].Values) != 3 || series["a"].Partial || len(series["b"].Values) != 1 {
+		t.Errorf("unexpected series: %v", series)
+	}
+}
@@ -1,9 +1,13 @@
 package client
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
 	"net"
+	"sync"
 	"time"
 )
 
@@ -11,6 +15,26 @@ const (
 	// TCPPayloadSize is a reasonable default payload size for TCP packets that
 	// could be travelling over the internet.
 	TCPPayloadSize = 512
+
+	// MinPayloadSize is the smallest PayloadSize accepted by NewTCPClient
+	// and NewUDPClient.
+	MinPayloadSize = 64
+
+	// DefaultMaxReconnectAttempts is the number of dials attempted when
+	// reconnecting if TCPConfig.MaxReconnectAttempts is not set.
+	DefaultMaxReconnectAttempts = 3
+
+	// DefaultReconnectInterval is the delay before the second dial attempt when
+	// reconnecting if TCPConfig.ReconnectInterval is not set.
+	DefaultReconnectInterval = 100 * time.Millisecond
+
+	// DefaultPingTimeout is the timeout used by the TCP client's Ping when
+	// called with a zero timeout.
+	DefaultPingTimeout = 5 * time.Second
+
+	// DefaultDialTimeout bounds connecting, including the TLS handshake, if
+	// TCPConfig.DialTimeout is not set.
+	DefaultDialTimeout = 5 * time.Second
 )
 
 // TCPConfig is the config data needed to create a TCP Client.
@@ -20,98 +44,751 @@ type TCPConfig struct {
 	Addr string
 
 	// PayloadSize is the maximum size of a TCP client message, optional
-	// Tune this based on your network. Defaults to TCPPayloadSize.
+	// Tune this based on your network. Defaults to TCPPayloadSize, must be at
+	// least MinPayloadSize.
 	PayloadSize int
+
+	// WriteTimeout bounds the time spent sending each payload, optional.
+	// A payload that is only partially sent leaves the connection unusable,
+	// so it is closed; enable ReconnectOnError to recover from that.
+	WriteTimeout time.Duration
+
+	// TLSConfig enables TLS on the connection when set. The ServerName is
+	// derived from Addr if the config does not specify it. Unless the config
+	// has a ClientSessionCache, the client keeps one of its own so that
+	// reconnects resume the TLS session instead of a full handshake.
+	TLSConfig *tls.Config
+
+	// CACertPEM, CACertFile, PinnedCertSHA256, ClientCertPEM and
+	// ClientKeyPEM build the TLS config in place of TLSConfig, and enable
+	// TLS, see HTTPConfig.CACertPEM.
+	CACertPEM        []byte
+	CACertFile       string
+	PinnedCertSHA256 []string
+	ClientCertPEM    []byte
+	ClientKeyPEM     []byte
+
+	// ReconnectOnError enables re-dialing Addr when writing to the connection
+	// fails with a network error. The payload that failed is retried once on
+	// the new connection.
+	ReconnectOnError bool
+
+	// MaxReconnectAttempts is the number of dials attempted before a reconnect
+	// is given up, optional. Defaults to DefaultMaxReconnectAttempts.
+	MaxReconnectAttempts int
+
+	// ReconnectInterval is the delay between dial attempts, doubled after
+	// every failed attempt, optional. Defaults to DefaultReconnectInterval.
+	ReconnectInterval time.Duration
+
+	// Stats, if set, is told about every write.
+	Stats StatsCollector
+
+	// Logger, if set, is told about connections being established, lost and
+	// closed, and about failed writes. Nothing is logged by default.
+	Logger Logger
+
+	// ValidateRawWrites makes WriteRawBytes parse the line protocol it is
+	// given and reject it if a line is invalid.
+	ValidateRawWrites bool
+
+	// ValidatePoints makes Write validate the points of a batch with
+	// BatchPoints.Validate and reject the batch if one is invalid.
+	ValidatePoints bool
+
+	// RateLimit, if set, limits the points and bytes written per second.
+	// The budget is taken for every payload sent.
+	RateLimit *RateLimit
+
+	// Clock, if set, is the clock of the RateLimit, defaults to the system
+	// clock.
+	Clock Clock
+
+	// DialTimeout bounds each attempt to connect, including the TLS
+	// handshake, optional. Defaults to DefaultDialTimeout.
+	DialTimeout time.Duration
+
+	// KeepAlive is the idle time before TCP keep-alive probes are sent,
+	// optional. Zero enables them with the default of the net package,
+	// negative disables them.
+	KeepAlive time.Duration
+
+	// KeepAliveInterval is the time between keep-alive probes, optional.
+	// It is applied where the Go version and operating system support it.
+	KeepAliveInterval time.Duration
+
+	// DialContext, if set, is used to open connections instead of a
+	// net.Dialer, for example to go through a SOCKS proxy or to bind a
+	// source address. It is used for the first connection and every
+	// reconnect, and given the context of the write that dials, bounded by
+	// DialTimeout. KeepAlive and KeepAliveInterval do not apply. TLS is
+	// established on top of the returned connection.
+	DialContext func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	// ReResolveInterval, if set, makes writes look Addr up again at most
+	// this often, and move to a new connection when it no longer resolves to
+	// the address connected to. Reconnects always look Addr up again.
+	ReResolveInterval time.Duration
+
+	// PoolSize is the number of connections to Addr, optional. When greater
+	// than 1, each payload is written to an idle connection of the pool so
+	// that concurrent writes are not serialized on one socket. A connection
+	// that failed is re-dialed before it is used again.
+	PoolSize int
+
+	// DrainTimeout is how long Close waits for the writes in progress to
+	// finish, optional. Defaults to DefaultDrainTimeout.
+	DrainTimeout time.Duration
+
+	// Compression, if set to TCPCompressionSnappy or TCPCompressionZstd,
+	// compresses every payload, which stock InfluxDB does not accept: it
+	// is meant for a relay that decompresses them. Each payload is sent as
+	// a frame of its own, made of the length of the compressed payload as
+	// a 4-byte big-endian integer followed by the compressed payload, which
+	// decompresses to whole lines of line protocol independently of the
+	// other frames: nothing is left in an encoder for Close to flush, and a
+	// payload retried on a new connection is sent as the same frame.
+	// PayloadSize, the stats and the rate limit count the bytes of the
+	// payloads before compression.
+	Compression string
+
+	// Compressor compresses the payloads with Compression, in place of the
+	// snappy encoder of the client. It is required for TCPCompressionZstd,
+	// such as one calling the EncodeAll method of an encoder of
+	// github.com/klauspost/compress/zstd.
+	Compressor BlockCompressor
+
+	// DumpPayload, if set, is called with every payload before it is
+	// compressed and sent, for debugging. b is only valid until it returns.
+	DumpPayload func(b []byte)
+
+	// ExpectedPrecision, if set, is the precision the receiver parses the
+	// timestamps of the payloads in, such as that of a socket listener
+	// assuming seconds: one of ns, u, ms, s, m or h. The timestamps of the
+	// points written are converted to it, rounded if their precision is
+	// finer, rather than sent in nanoseconds. The points are not modified.
+	// The lines of raw writes are sent as they are.
+	ExpectedPrecision string
 }
 
 // NewTCPClient returns a client interface for writing to an InfluxDB TCP
 // service from the given config.
 func NewTCPClient(conf TCPConfig) (Client, error) {
-	var udpAddr *net.TCPAddr
-	udpAddr, err := net.ResolveTCPAddr("tcp", conf.Addr)
+	if conf.Addr == "" {
+		return nil, &ConfigError{Field: "Addr", Reason: "no address given"}
+	}
+	if err := validatePayloadSize(conf.PayloadSize, 0); err != nil {
+		return nil, err
+	}
+	if conf.PoolSize < 0 {
+		return nil, &ConfigError{Field: "PoolSize", Reason: fmt.Sprintf("%d is negative", conf.PoolSize)}
+	}
+	if conf.DrainTimeout < 0 {
+		return nil, &ConfigError{Field: "DrainTimeout", Reason: fmt.Sprintf("%v is negative", conf.DrainTimeout)}
+	}
+	newCompressor, err := newTCPCompressor(conf)
 	if err != nil {
 		return nil, err
 	}
-
-	conn, err := net.DialTCP("tcp", nil, udpAddr)
+	timePrecision, err := expectedPrecision(conf.ExpectedPrecision)
 	if err != nil {
 		return nil, err
 	}
+	if conf.DialContext == nil {
+		// A custom dialer may resolve the address itself, such as through
+		// a SOCKS proxy.
+		if _, err := net.ResolveTCPAddr("tcp", conf.Addr); err != nil {
+			return nil, err
+		}
+	}
 
 	payloadSize := conf.PayloadSize
 	if payloadSize == 0 {
 		payloadSize = TCPPayloadSize
 	}
 
-	return &tcpclient{
-		conn:        conn,
-		payloadSize: payloadSize,
-	}, nil
+	limiter, err := newRateLimiter(conf.RateLimit, conf.Clock)
+	if err != nil {
+		return nil, err
+	}
+
+	maxReconnectAttempts := conf.MaxReconnectAttempts
+	if maxReconnectAttempts == 0 {
+		maxReconnectAttempts = DefaultMaxReconnectAttempts
+	}
+
+	reconnectInterval := conf.ReconnectInterval
+	if reconnectInterval == 0 {
+		reconnectInterval = DefaultReconnectInterval
+	}
+
+	dialTimeout := conf.DialTimeout
+	if dialTimeout == 0 {
+		dialTimeout = DefaultDialTimeout
+	}
+
+	dialContext := conf.DialContext
+	if dialContext == nil {
+		d := &net.Dialer{}
+		setKeepAlive(d, conf.KeepAlive, conf.KeepAliveInterval)
+		dialContext = d.DialContext
+	}
+
+	tlsConfig, err := conf.tlsHelpers().tlsConfig(conf.TLSConfig)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil && tlsConfig.ClientSessionCache == nil {
+		// The connections of a pool share the cache.
+		tlsConfig = tlsConfig.Clone()
+		tlsConfig.ClientSessionCache = tls.NewLRUClientSessionCache(0)
+	}
+
+	newConn := func() (*tcpclient, error) {
+		uc := &tcpclient{
+			payloadSize:          payloadSize,
+			timePrecision:        timePrecision,
+			writeTimeout:         conf.WriteTimeout,
+			redialBroken:         conf.PoolSize > 1,
+			addr:                 conf.Addr,
+			tlsConfig:            tlsConfig,
+			reconnectOnError:     conf.ReconnectOnError,
+			maxReconnectAttempts: maxReconnectAttempts,
+			reconnectInterval:    reconnectInterval,
+			closing:              make(chan struct{}),
+			logger:               conf.Logger,
+			reResolveInterval:    conf.ReResolveInterval,
+			resolved:             time.Now(),
+			dialTimeout:          dialTimeout,
+			dialContext:          dialContext,
+			dumpPayload:          conf.DumpPayload,
+		}
+		if newCompressor != nil {
+			uc.compressor = newCompressor()
+		}
+
+		// With TLS enabled the handshake completes before dial returns, so
+		// certificate problems are reported here rather than on the first
+		// Write.
+		conn, err := uc.dial(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		logf(uc.logger, "influxdb: connected to %s", uc.addr)
+		uc.conn = conn
+		return uc, nil
+	}
+
+	if conf.PoolSize <= 1 {
+		uc, err := newConn()
+		if err != nil {
+			return nil, err
+		}
+		uc.stats = conf.Stats
+		uc.validateRaw = conf.ValidateRawWrites
+		uc.validatePoints = conf.ValidatePoints
+		uc.limiter = limiter
+		uc.drain.timeout = conf.DrainTimeout
+		return uc, nil
+	}
+
+	p := &tcppool{payloadSize: payloadSize, timePrecision: timePrecision, stats: conf.Stats, validateRaw: conf.ValidateRawWrites, validatePoints: conf.ValidatePoints, limiter: limiter}
+	p.drain.timeout = conf.DrainTimeout
+	for i := 0; i < conf.PoolSize; i++ {
+		uc, err := newConn()
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+		p.conns = append(p.conns, uc)
+	}
+	return p, nil
 }
 
-// Close releases the tcpclient's resources.
+// Close makes the writes and pings that follow fail with ErrClientClosed,
+// waits up to the DrainTimeout for those in progress to finish and closes the
+// connection. A reconnect in progress is aborted. The connection is closed
+// once the last write finished if some are still in progress by then.
 func (uc *tcpclient) Close() error {
-	return uc.conn.Close()
+	uc.abortReconnect()
+	return uc.drain.close(uc.closeConn)
+}
+
+// abortReconnect aborts a reconnect in progress and those that follow.
+func (uc *tcpclient) abortReconnect() {
+	uc.closeOnce.Do(func() {
+		if uc.closing != nil {
+			close(uc.closing)
+		}
+	})
+}
+
+// closeConn closes the connection.
+func (uc *tcpclient) closeConn() error {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	err := uc.conn.Close()
+	logf(uc.logger, "influxdb: closed connection to %s", uc.addr)
+	if uc.broken {
+		// The connection may already have been closed after a failure.
+		return nil
+	}
+	return err
 }
 
+// tcpclient serializes writes with mu, which also guards swapping conn when
+// reconnecting.
 type tcpclient struct {
-	conn        io.WriteCloser
-	payloadSize int
+	mu           sync.Mutex
+	conn         io.WriteCloser
+	payloadSize  int
+	writeTimeout time.Duration
+	bufs         payloadBuffers
+
+	// timePrecision is the precision of the timestamps of the payloads,
+	// nanoseconds if empty.
+	timePrecision string
+
+	// compressor, if set, compresses the payloads, framed in frameBuf.
+	compressor BlockCompressor
+	frameBuf   []byte
+
+	// dumpPayload, if set, is called with every payload.
+	dumpPayload func(b []byte)
+
+	// broken is set once writing to conn failed with a network error.
+	// A connection of a pool is then re-dialed before the next write.
+	broken       bool
+	redialBroken bool
+
+	stats          StatsCollector
+	logger         Logger
+	validateRaw    bool
+	validatePoints bool
+	limiter        *rateLimiter
+
+	addr                 string
+	tlsConfig            *tls.Config
+	reconnectOnError     bool
+	maxReconnectAttempts int
+	reconnectInterval    time.Duration
+
+	// resolve looks up addr, net.ResolveTCPAddr if nil. resolved is when
+	// the connection was last checked against the address addr resolves to.
+	reResolveInterval time.Duration
+	resolve           func(addr string) (*net.TCPAddr, error)
+	resolved          time.Time
+
+	// dialContext opens connections, using a zero net.Dialer if nil.
+	dialTimeout time.Duration
+	dialContext func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	closing   chan struct{}
+	closeOnce sync.Once
+	drain     drainer
+}
+
+// RemoteAddrClient is implemented by the TCP and UDP clients.
+type RemoteAddrClient interface {
+	// RemoteAddr returns the address the client is sending to, such as
+	// the IP address its host name resolved to. It returns nil if the
+	// client is not connected.
+	RemoteAddr() net.Addr
+}
+
+// RemoteAddr returns the address of the connection.
+func (uc *tcpclient) RemoteAddr() net.Addr {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	return remoteAddr(uc.conn)
+}
+
+// remoteAddr returns the remote address of conn, if it is a net.Conn.
+func remoteAddr(conn io.WriteCloser) net.Addr {
+	if c, ok := conn.(net.Conn); ok {
+		return c.RemoteAddr()
+	}
+	return nil
 }
 
 func (uc *tcpclient) Write(bp BatchPoints) error {
-	var 	b = make([]byte, 0, uc.payloadSize) // initial buffer size, it will grow as needed
-	var d, _ = time.ParseDuration("1" + bp.Precision())
+	return uc.WriteContext(context.Background(), bp)
+}
 
-	var delayedError error
+// WriteContext is like Write, but gives up once ctx is done. A deadline on ctx
+// is applied to the connection's write deadline.
+func (uc *tcpclient) WriteContext(ctx context.Context, bp BatchPoints) error {
+	_, err := uc.WriteWithStats(ctx, bp)
+	return err
+}
 
-	var checkBuffer = func(n int) {
-		if len(b) > 0 && len(b)+n > uc.payloadSize {
-			if _, err := uc.conn.Write(b); err != nil {
-				delayedError = err
-			}
-			b = b[:0]
+// WriteWithStats is like WriteContext, returning the statistics of the write.
+func (uc *tcpclient) WriteWithStats(ctx context.Context, bp BatchPoints) (WriteStats, error) {
+	if ok, err := checkBatch(bp); !ok {
+		return WriteStats{}, err
+	}
+	return uc.write(ctx, len(bp.Points()), bp.Validate, func(flush func([]byte) error) error {
+		// A failed payload usually means the connection is gone, so stop
+		// at the first one rather than failing every remaining payload.
+		return writePayloads(ctx, bp, uc.timePrecision, &uc.bufs, uc.payloadSize, true, flush)
+	})
+}
+
+// write sends the payloads of points, which encode hands to flush, once
+// validate accepted them if points are validated.
+func (uc *tcpclient) write(ctx context.Context, points int, validate func() error, encode func(flush func([]byte) error) error) (ws WriteStats, err error) {
+	if err := uc.drain.begin(); err != nil {
+		return ws, err
+	}
+	defer uc.drain.end()
+	if err := ctx.Err(); err != nil {
+		return ws, err
+	}
+	if uc.validatePoints {
+		if err := validate(); err != nil {
+			return ws, err
 		}
 	}
+	ws.PointCount = points
+	if uc.stats != nil {
+		defer writeDone(uc.stats, ws.PointCount, time.Now(), &ws.ByteCount, &err)
+	}
+	defer func() { err = contextError(ctx, err) }()
+
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
 
-	for _, p := range bp.Points() {
-		p.pt.Round(d)
-		pointSize := p.pt.StringSize() + 1 // include newline in size
-		//point := p.pt.RoundedString(d) + "\n"
+	// Only the first payload failing with a network error is retried on a new
+	// connection, so a dead listener cannot stall the whole batch.
+	var reconnected bool
+	t := newWriteTimer(&ws)
+	var flush = func(b []byte) error {
+		t.encoded()
+		if err := uc.limiter.wait(ctx, countLines(b), len(b)); err != nil {
+			return err
+		}
+		t.sending(len(b))
+		defer t.sent()
+		return uc.flush(ctx, b, &reconnected)
+	}
 
-		checkBuffer(pointSize)
+	err = encode(flush)
+	t.encoded()
+	return ws, err
+}
 
-		if p.Time().IsZero() || pointSize <= uc.payloadSize {
-			b = p.pt.AppendString(b)
-			b = append(b, '\n')
-			continue
+// flush sends the payload b, reconnecting if needed and allowed. It must be
+// called with mu held.
+func (uc *tcpclient) flush(ctx context.Context, b []byte, reconnected *bool) error {
+	if uc.dumpPayload != nil {
+		uc.dumpPayload(b)
+	}
+	if uc.compressor != nil {
+		var err error
+		if b, err = uc.frame(b); err != nil {
+			return err
+		}
+	}
+	uc.reResolve(ctx)
+	if uc.broken && uc.redialBroken {
+		conn, err := uc.dial(ctx)
+		if err != nil {
+			logf(uc.logger, "influxdb: reconnecting to %s failed: %v", uc.addr, err)
+			return err
 		}
+		logf(uc.logger, "influxdb: reconnected to %s", uc.addr)
+		uc.conn.Close()
+		uc.conn = conn
+		uc.broken = false
+	}
 
-		points := p.pt.Split(uc.payloadSize - 1) // account for newline character
-		for _, sp := range points {
-			checkBuffer(sp.StringSize() + 1)
-			b = sp.AppendString(b)
-			b = append(b, '\n')
+	release := bindWriteContext(ctx, uc.conn)
+	err := release(uc.send(ctx, b))
+	if err != nil {
+		logf(uc.logger, "influxdb: writing to %s failed: %v", uc.addr, err)
+	}
+	if err != nil && !*reconnected && uc.shouldReconnect(ctx, err) {
+		*reconnected = true
+		if uc.reconnect(ctx) == nil {
+			release := bindWriteContext(ctx, uc.conn)
+			err = release(uc.send(ctx, b))
 		}
 	}
 
-	if len(b) > 0 {
-		if _, err := uc.conn.Write(b); err != nil {
-			return err
+	var netErr net.Error
+	if err != nil && errors.As(err, &netErr) {
+		uc.broken = true
+	}
+	return err
+}
+
+// reResolve moves to a new connection if ReResolveInterval passed and addr
+// now resolves to a different address than the one connected to. The current
+// connection is kept if that fails. It must be called with mu held.
+func (uc *tcpclient) reResolve(ctx context.Context) {
+	if uc.reResolveInterval <= 0 || time.Since(uc.resolved) < uc.reResolveInterval {
+		return
+	}
+	uc.resolved = time.Now()
+
+	resolve := uc.resolve
+	if resolve == nil {
+		resolve = func(addr string) (*net.TCPAddr, error) { return net.ResolveTCPAddr("tcp", addr) }
+	}
+	addr, err := resolve(uc.addr)
+	if err != nil {
+		logf(uc.logger, "influxdb: resolving %s failed: %v", uc.addr, err)
+		return
+	}
+	current := remoteAddr(uc.conn)
+	if current != nil && current.String() == addr.String() {
+		return
+	}
+
+	conn, err := uc.dialAddr(ctx, addr.String())
+	if err != nil {
+		logf(uc.logger, "influxdb: connecting to %s at %s failed: %v", uc.addr, addr, err)
+		return
+	}
+	logf(uc.logger, "influxdb: %s now resolves to %s, moving from %v", uc.addr, addr, current)
+	uc.conn.Close()
+	uc.conn = conn
+	uc.broken = false
+}
+
+// send writes the whole payload b to the connection. It must be called with
+// mu held.
+func (uc *tcpclient) send(ctx context.Context, b []byte) error {
+	setWriteDeadline(ctx, uc.conn, uc.writeTimeout)
+	n, err := writeFull(uc.conn, b)
+	if err == nil {
+		return nil
+	}
+
+	if n > 0 {
+		// The stream now ends in the middle of a line, anything written after
+		// it would be corrupted.
+		uc.conn.Close()
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() && ctxError(ctx) == nil {
+		return &WriteTimeoutError{Written: n, Size: len(b), Err: err}
+	}
+	return err
+}
+
+// shouldReconnect reports whether err, returned from writing to the
+// connection, warrants dialing a new one.
+func (uc *tcpclient) shouldReconnect(ctx context.Context, err error) bool {
+	if !uc.reconnectOnError || ctx.Err() != nil {
+		return false
+	}
+	select {
+	case <-uc.closing:
+		return false
+	default:
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// reconnect replaces the connection with a newly dialed one, backing off
+// between failed attempts. It must be called with mu held.
+func (uc *tcpclient) reconnect(ctx context.Context) error {
+	uc.conn.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-uc.closing:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	var err error
+	interval := uc.reconnectInterval
+	for i := 0; i < uc.maxReconnectAttempts; i++ {
+		if i > 0 {
+			timer := time.NewTimer(interval)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			}
+			interval *= 2
+		}
+
+		var conn net.Conn
+		if conn, err = uc.dial(ctx); err == nil {
+			logf(uc.logger, "influxdb: reconnected to %s", uc.addr)
+			uc.conn = conn
+			uc.broken = false
+			return nil
 		}
+		logf(uc.logger, "influxdb: reconnecting to %s failed (attempt %d of %d): %v", uc.addr, i+1, uc.maxReconnectAttempts, err)
 	}
-	return delayedError
+	return err
 }
 
+// dial connects to the configured address, resolving it again so that DNS
+// changes are picked up. TLS connections are returned after a successful
+// handshake.
+func (uc *tcpclient) dial(ctx context.Context) (net.Conn, error) {
+	return uc.dialAddr(ctx, uc.addr)
+}
+
+// dialAddr is like dial, connecting to addr, which is the configured address
+// or one it resolved to.
+func (uc *tcpclient) dialAddr(ctx context.Context, addr string) (net.Conn, error) {
+	if uc.dialTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, uc.dialTimeout)
+		defer cancel()
+	}
+
+	dial := uc.dialContext
+	if dial == nil {
+		var d net.Dialer
+		dial = d.DialContext
+	}
+	conn, err := dial(ctx, "tcp", addr)
+	if err != nil || uc.tlsConfig == nil {
+		return conn, err
+	}
+
+	config := uc.tlsConfig
+	if config.ServerName == "" {
+		host, _, _ := net.SplitHostPort(uc.addr)
+		config = config.Clone()
+		config.ServerName = host
+	}
+	tc := tls.Client(conn, config)
+	if err := tc.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if config.ClientSessionCache != nil && tc.ConnectionState().Version >= tls.VersionTLS13 {
+		readSessionTickets(tc)
+	}
+	return tc, nil
+}
+
+// readSessionTickets reads the session tickets a TLS 1.3 server sends along
+// with the end of the handshake, putting them in the session cache. The
+// server never sends anything else, so they would otherwise only be read by
+// checkConn.
+func readSessionTickets(conn net.Conn) {
+	if err := conn.SetReadDeadline(time.Now().Add(time.Millisecond)); err != nil {
+		return
+	}
+	defer conn.SetReadDeadline(time.Time{})
+	var b [1]byte
+	conn.Read(b[:])
+}
+
+// WriteTimeoutError is returned by the TCP and UDP clients when a payload could
+// not be sent within the WriteTimeout of their config.
+type WriteTimeoutError struct {
+	// Written is the number of bytes of the payload that were sent.
+	Written int
+
+	// Size is the size of the payload in bytes.
+	Size int
+
+	// Err is the error returned by the connection.
+	Err error
+}
+
+func (e *WriteTimeoutError) Error() string {
+	return fmt.Sprintf("write timeout after sending %d of %d bytes: %v", e.Written, e.Size, e.Err)
+}
+
+// Unwrap returns the error returned by the connection.
+func (e *WriteTimeoutError) Unwrap() error { return e.Err }
+
+// Timeout is always true, satisfying net.Error.
+func (e *WriteTimeoutError) Timeout() bool { return true }
+
+// Temporary is always true, satisfying net.Error.
+func (e *WriteTimeoutError) Temporary() bool { return true }
+
 func (uc *tcpclient) Query(q Query) (*Response, error) {
-	return nil, fmt.Errorf("Querying via TCP is not supported")
+	return nil, queryNotSupportedError("TCP")
+}
+
+func (uc *tcpclient) QueryContext(ctx context.Context, q Query) (*Response, error) {
+	return uc.Query(q)
 }
 
 func (uc *tcpclient) QueryAsChunk(q Query) (*ChunkedResponse, error) {
-	return nil, fmt.Errorf("Querying via TCP is not supported")
+	return nil, queryNotSupportedError("TCP")
+}
+
+func (uc *tcpclient) QueryAsChunkContext(ctx context.Context, q Query) (*ChunkedResponse, error) {
+	return uc.QueryAsChunk(q)
 }
 
+// Ping checks that the connection has not been closed by the server, and
+// reconnects if ReconnectOnError is set. It then dials a short-lived probe
+// connection and returns the time it took. The version is always empty.
+// A zero timeout means DefaultPingTimeout.
 func (uc *tcpclient) Ping(timeout time.Duration) (time.Duration, string, error) {
-	return 0, "", nil
+	if err := uc.drain.begin(); err != nil {
+		return 0, "", err
+	}
+	defer uc.drain.end()
+	if timeout <= 0 {
+		timeout = DefaultPingTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+
+	if err := uc.checkConn(); err != nil {
+		if !uc.shouldReconnect(ctx, err) || uc.reconnect(ctx) != nil {
+			return 0, "", err
+		}
+	}
+
+	now := time.Now()
+	conn, err := uc.dial(ctx)
+	if err != nil {
+		return 0, "", err
+	}
+	rtt := time.Since(now)
+	conn.Close()
+
+	return rtt, "", nil
 }
 
+// checkConn detects a connection closed or reset by the server. The server
+// never sends anything, so a read timing out means the connection is still
+// open. It must be called with mu held.
+func (uc *tcpclient) checkConn() error {
+	conn, ok := uc.conn.(net.Conn)
+	if !ok {
+		return nil
+	}
+
+	if err := conn.SetReadDeadline(time.Now().Add(time.Millisecond)); err != nil {
+		return err
+	}
+	defer conn.SetReadDeadline(time.Time{})
+
+	var b [1]byte
+	_, err := conn.Read(b[:])
+	var netErr net.Error
+	if err == nil || errors.As(err, &netErr) && netErr.Timeout() {
+		return nil
+	}
+	if err == io.EOF {
+		return &net.OpError{Op: "ping", Net: "tcp", Addr: conn.RemoteAddr(), Err: errors.New("connection closed by server")}
+	}
+	return err
+}
@@ -0,0 +1,874 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// brokenConn fails every write with a network error, like a connection that
+// was reset by the peer.
+type brokenConn struct{}
+
+var errBrokenPipe = &net.OpError{Op: "write", Net: "tcp", Err: syscall.EPIPE}
+
+func (brokenConn) Write(b []byte) (int, error) { return 0, errBrokenPipe }
+func (brokenConn) Close() error                { return nil }
+
+// newTCPTestClient returns a tcpclient writing to conn that reconnects to addr.
+func newTCPTestClient(conn io.WriteCloser, addr string) *tcpclient {
+	return &tcpclient{
+		conn:                 conn,
+		payloadSize:          TCPPayloadSize,
+		addr:                 addr,
+		reconnectOnError:     true,
+		maxReconnectAttempts: DefaultMaxReconnectAttempts,
+		reconnectInterval:    10 * time.Millisecond,
+		closing:              make(chan struct{}),
+	}
+}
+
+func TestTCPClient_WriteContextCanceled(t *testing.T) {
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	cl := &tcpclient{conn: local, payloadSize: TCPPayloadSize}
+	defer cl.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	p, _ := NewPoint("cpu", nil, map[string]interface{}{"value": 1.0}, time.Unix(1, 0))
+	bp.AddPoint(p)
+
+	// Nobody reads from remote so the write blocks until ctx is canceled.
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	if err := cl.WriteContext(ctx, bp); err != context.Canceled {
+		t.Fatalf("unexpected error.  expected %v, actual %v", context.Canceled, err)
+	}
+
+	// The client must still be usable afterwards.
+	go io.Copy(ioutil.Discard, remote)
+	if err := cl.WriteContext(context.Background(), bp); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+}
+
+func TestTCPClient_WriteContextDeadline(t *testing.T) {
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	cl := &tcpclient{conn: local, payloadSize: TCPPayloadSize}
+	defer cl.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	p, _ := NewPoint("cpu", nil, map[string]interface{}{"value": 1.0}, time.Unix(1, 0))
+	bp.AddPoint(p)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := cl.WriteContext(ctx, bp); err != context.DeadlineExceeded {
+		t.Fatalf("unexpected error.  expected %v, actual %v", context.DeadlineExceeded, err)
+	}
+}
+
+func TestTCPClient_WriteContextDone(t *testing.T) {
+	var logger writeLogger
+	cl := &tcpclient{conn: &logger, payloadSize: TCPPayloadSize}
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	p, _ := NewPoint("cpu", nil, map[string]interface{}{"value": 1.0})
+	bp.AddPoint(p)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := cl.WriteContext(ctx, bp); err != context.Canceled {
+		t.Fatalf("unexpected error.  expected %v, actual %v", context.Canceled, err)
+	}
+	if len(logger.writes) != 0 {
+		t.Errorf("Mismatched write count: got %v, exp %v", len(logger.writes), 0)
+	}
+}
+
+func TestTCPClient_Reconnect(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	received := make(chan []byte)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		b, _ := ioutil.ReadAll(conn)
+		received <- b
+	}()
+
+	cl := newTCPTestClient(brokenConn{}, l.Addr().String())
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	p, _ := NewPoint("cpu", nil, map[string]interface{}{"value": 1.0}, time.Unix(1, 0))
+	bp.AddPoint(p)
+
+	if err := cl.Write(bp); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	cl.Close()
+
+	exp := []byte("cpu value=1 1000000000\n")
+	if got := <-received; !bytes.Equal(got, exp) {
+		t.Errorf("unexpected payload.  expected %q, actual %q", exp, got)
+	}
+}
+
+func TestTCPClient_ReconnectFailed(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	cl := newTCPTestClient(brokenConn{}, addr)
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	p, _ := NewPoint("cpu", nil, map[string]interface{}{"value": 1.0})
+	bp.AddPoint(p)
+
+	// The original write error is returned when no new connection can be made.
+	if err := cl.Write(bp); !errors.Is(err, syscall.EPIPE) {
+		t.Errorf("unexpected error.  expected %v, actual %v", errBrokenPipe, err)
+	}
+}
+
+func TestTCPClient_CloseAbortsReconnect(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	cl := newTCPTestClient(brokenConn{}, addr)
+	cl.reconnectInterval = time.Hour
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	p, _ := NewPoint("cpu", nil, map[string]interface{}{"value": 1.0})
+	bp.AddPoint(p)
+
+	errC := make(chan error)
+	go func() { errC <- cl.Write(bp) }()
+
+	time.Sleep(50 * time.Millisecond)
+	cl.Close()
+
+	select {
+	case err := <-errC:
+		if !errors.Is(err, syscall.EPIPE) {
+			t.Errorf("unexpected error.  expected %v, actual %v", errBrokenPipe, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Write did not return after Close")
+	}
+}
+
+func TestTCPClient_ConcurrentReconnect(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go io.Copy(ioutil.Discard, conn)
+		}
+	}()
+
+	cl := newTCPTestClient(brokenConn{}, l.Addr().String())
+	defer cl.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	p, _ := NewPoint("cpu", nil, map[string]interface{}{"value": 1.0})
+	bp.AddPoint(p)
+
+	errC := make(chan error, 8)
+	for i := 0; i < cap(errC); i++ {
+		go func() { errC <- cl.Write(bp) }()
+	}
+	for i := 0; i < cap(errC); i++ {
+		if err := <-errC; err != nil {
+			t.Errorf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+	}
+}
+
+// newSelfSignedCert returns a certificate valid for 127.0.0.1 and a pool
+// trusting it.
+func newSelfSignedCert(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "influxdb"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pool
+}
+
+// newTLSListener returns a TLS listener sending everything it reads on the
+// first accepted connection to the returned channel.
+func newTLSListener(t *testing.T, cert tls.Certificate) (net.Listener, <-chan []byte) {
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := make(chan []byte, 1)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				b, err := ioutil.ReadAll(conn)
+				if err == nil {
+					received <- b
+				}
+			}()
+		}
+	}()
+	return l, received
+}
+
+func TestTCPClient_TLS(t *testing.T) {
+	cert, pool := newSelfSignedCert(t)
+
+	tests := []struct {
+		name      string
+		tlsConfig *tls.Config
+	}{
+		{
+			name:      "verify",
+			tlsConfig: &tls.Config{RootCAs: pool},
+		},
+		{
+			name:      "skip verify",
+			tlsConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			l, received := newTLSListener(t, cert)
+			defer l.Close()
+
+			c, err := NewTCPClient(TCPConfig{Addr: l.Addr().String(), TLSConfig: test.tlsConfig})
+			if err != nil {
+				t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+			}
+
+			bp, _ := NewBatchPoints(BatchPointsConfig{})
+			p, _ := NewPoint("cpu", nil, map[string]interface{}{"value": 1.0}, time.Unix(1, 0))
+			bp.AddPoint(p)
+
+			if err := c.Write(bp); err != nil {
+				t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+			}
+			if err := c.Close(); err != nil {
+				t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+			}
+
+			exp := []byte("cpu value=1 1000000000\n")
+			select {
+			case got := <-received:
+				if !bytes.Equal(got, exp) {
+					t.Errorf("unexpected payload.  expected %q, actual %q", exp, got)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("timed out waiting for payload")
+			}
+		})
+	}
+}
+
+func TestTCPClient_TLSSessionResumption(t *testing.T) {
+	cert, pool := newSelfSignedCert(t)
+	for _, version := range []uint16{tls.VersionTLS12, tls.VersionTLS13} {
+		l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}, MaxVersion: version})
+		if err != nil {
+			t.Fatal(err)
+		}
+		resumed := make(chan bool, 4)
+		go func() {
+			for {
+				conn, err := l.Accept()
+				if err != nil {
+					return
+				}
+				go func() {
+					defer conn.Close()
+					tc := conn.(*tls.Conn)
+					if err := tc.Handshake(); err != nil {
+						return
+					}
+					resumed <- tc.ConnectionState().DidResume
+					io.Copy(ioutil.Discard, tc)
+				}()
+			}
+		}()
+
+		// The second connection of the pool resumes the session of the
+		// first, as reconnects and the probe connection of Ping do.
+		c, err := NewTCPClient(TCPConfig{Addr: l.Addr().String(), TLSConfig: &tls.Config{RootCAs: pool}, PoolSize: 2})
+		if err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+		if _, _, err := c.Ping(0); err != nil {
+			t.Fatalf("unexpected ping error.  expected %v, actual %v", nil, err)
+		}
+		for i, exp := range []bool{false, true, true} {
+			if got := <-resumed; got != exp {
+				t.Errorf("unexpected resumption of handshake %d with version %x.  expected %v, actual %v", i, version, exp, got)
+			}
+		}
+		c.Close()
+		l.Close()
+	}
+}
+
+func TestTCPClient_TLSHandshakeError(t *testing.T) {
+	cert, _ := newSelfSignedCert(t)
+	l, _ := newTLSListener(t, cert)
+	defer l.Close()
+
+	// The self-signed certificate is not trusted by an empty pool.
+	c, err := NewTCPClient(TCPConfig{
+		Addr:      l.Addr().String(),
+		TLSConfig: &tls.Config{RootCAs: x509.NewCertPool()},
+	})
+	if err == nil {
+		c.Close()
+		t.Fatal("expected certificate verification error")
+	}
+	var certErr x509.UnknownAuthorityError
+	if !errors.As(err, &certErr) {
+		t.Errorf("unexpected error.  expected %T, actual %v", certErr, err)
+	}
+}
+
+func TestTCPClient_WriteTimeout(t *testing.T) {
+	local, remote := net.Pipe()
+	defer remote.Close()
+
+	cl := &tcpclient{conn: local, payloadSize: TCPPayloadSize, writeTimeout: 50 * time.Millisecond}
+	defer cl.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	p, _ := NewPoint("cpu", nil, map[string]interface{}{"value": 1.0}, time.Unix(1, 0))
+	bp.AddPoint(p)
+
+	// Nobody reads from remote, so the deadline expires.
+	err := cl.Write(bp)
+	var timeoutErr *WriteTimeoutError
+	if !errors.As(err, &timeoutErr) {
+		t.Fatalf("unexpected error.  expected %T, actual %v", timeoutErr, err)
+	}
+	if timeoutErr.Written != 0 || timeoutErr.Size != len("cpu value=1 1000000000\n") {
+		t.Errorf("unexpected progress.  expected 0 of %d bytes, actual %d of %d", len("cpu value=1 1000000000\n"), timeoutErr.Written, timeoutErr.Size)
+	}
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("expected a net.Error reporting a timeout, actual %v", err)
+	}
+}
+
+// shortWriter accepts at most max bytes per Write call.
+type shortWriter struct {
+	writeLogger
+	max int
+}
+
+func (w *shortWriter) Write(b []byte) (int, error) {
+	if len(b) > w.max {
+		w.writeLogger.Write(b[:w.max])
+		return w.max, io.ErrShortWrite
+	}
+	return w.writeLogger.Write(b)
+}
+
+func TestTCPClient_ShortWrites(t *testing.T) {
+	w := &shortWriter{max: 3}
+	cl := &tcpclient{conn: w, payloadSize: TCPPayloadSize}
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	p, _ := NewPoint("cpu", nil, map[string]interface{}{"value": 1.0}, time.Unix(1, 0))
+	bp.AddPoint(p)
+	bp.AddPoint(p)
+
+	if err := cl.Write(bp); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+
+	exp := "cpu value=1 1000000000\ncpu value=1 1000000000\n"
+	if got := string(bytes.Join(w.writes, nil)); got != exp {
+		t.Errorf("unexpected payload.  expected %q, actual %q", exp, got)
+	}
+}
+
+// failingWriter fails the nth call to Write and every call after it.
+type failingWriter struct {
+	writeLogger
+	n int
+}
+
+var errWriteFailed = errors.New("write failed")
+
+func (w *failingWriter) Write(b []byte) (int, error) {
+	if w.n--; w.n <= 0 {
+		return 0, errWriteFailed
+	}
+	return w.writeLogger.Write(b)
+}
+
+func TestTCPClient_WriteError(t *testing.T) {
+	w := &failingWriter{n: 2}
+	cl := &tcpclient{conn: w, payloadSize: 20} // two points per payload
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	p, _ := NewPoint("cpu", nil, map[string]interface{}{"a": 1}, time.Time{})
+	for i := 0; i < 9; i++ {
+		bp.AddPoint(p)
+	}
+
+	err := cl.Write(bp)
+	var writeErr *WriteError
+	if !errors.As(err, &writeErr) {
+		t.Fatalf("unexpected error.  expected %T, actual %v", writeErr, err)
+	}
+
+	// Writing stops after the failed payload, every other point is dropped.
+	if writeErr.PointsWritten != 2 || writeErr.PointsDropped != 7 {
+		t.Errorf("unexpected counts.  expected 2 written and 7 dropped, actual %d and %d", writeErr.PointsWritten, writeErr.PointsDropped)
+	}
+	if len(writeErr.Errs) != 1 || !errors.Is(err, errWriteFailed) {
+		t.Errorf("unexpected errors.  expected [%v], actual %v", errWriteFailed, writeErr.Errs)
+	}
+	if got := len(w.writes); got != 1 {
+		t.Errorf("Mismatched write count: got %v, exp %v", got, 1)
+	}
+}
+
+func TestTCPClient_WriteDoesNotRoundPoints(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		in, _ := ioutil.ReadAll(r.Body)
+		if got, exp := string(in), "cpu value=1 1123456789\n"; got != exp {
+			t.Errorf("unexpected write protocol: %q != %q", got, exp)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	var logger writeLogger
+	cl := &tcpclient{conn: &logger, payloadSize: TCPPayloadSize}
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{Precision: "s"})
+	p, _ := NewPoint("cpu", nil, map[string]interface{}{"value": 1.0}, time.Unix(1, 123456789))
+	bp.AddPoint(p)
+
+	if err := cl.Write(bp); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if got, exp := string(logger.writes[0]), "cpu value=1 1000000000\n"; got != exp {
+		t.Errorf("unexpected payload.  expected %q, actual %q", exp, got)
+	}
+
+	// A dual write of the same batch to an HTTP client sees the original
+	// nanosecond timestamp.
+	bp.SetPrecision("ns")
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+	if err := c.Write(bp); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+}
+
+func TestTCPClient_ExpectedPrecision(t *testing.T) {
+	tests := []struct {
+		precision string
+		expected  string
+		time      time.Time
+		exp       string
+	}{
+		{precision: "ns", expected: "s", time: time.Unix(1, 600000000), exp: "cpu value=1 2\n"},
+		{precision: "ms", expected: "s", time: time.Unix(1, 400000000), exp: "cpu value=1 1\n"},
+		{precision: "s", expected: "ms", time: time.Unix(1, 400000000), exp: "cpu value=1 1000\n"},
+		{precision: "s", expected: "s", time: time.Unix(5, 0), exp: "cpu value=1 5\n"},
+		{precision: "ns", expected: "ns", time: time.Unix(1, 123456789), exp: "cpu value=1 1123456789\n"},
+		{precision: "us", expected: "u", time: time.Unix(1, 123456789), exp: "cpu value=1 1123457\n"},
+	}
+	for _, test := range tests {
+		timePrecision, err := expectedPrecision(test.expected)
+		if err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+		var logger writeLogger
+		cl := &tcpclient{conn: &logger, payloadSize: TCPPayloadSize, timePrecision: timePrecision}
+		bp, _ := NewBatchPoints(BatchPointsConfig{Precision: test.precision})
+		p, _ := NewPoint("cpu", nil, map[string]interface{}{"value": 1.0}, test.time)
+		bp.AddPoint(p)
+		if err := cl.Write(bp); err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+		if got := string(logger.writes[0]); got != test.exp {
+			t.Errorf("unexpected payload for %s to %s.  expected %q, actual %q", test.precision, test.expected, test.exp, got)
+		}
+		if !p.Time().Equal(test.time) {
+			t.Errorf("unexpected point time.  expected %v, actual %v", test.time, p.Time())
+		}
+	}
+
+	_, err := NewTCPClient(TCPConfig{Addr: "127.0.0.1:8089", ExpectedPrecision: "d"})
+	var ce *ConfigError
+	if !errors.As(err, &ce) || ce.Field != "ExpectedPrecision" {
+		t.Errorf("unexpected error.  expected a *ConfigError, actual %v", err)
+	}
+}
+
+// closingListener accepts connections and closes them again once closeConns
+// is called.
+type closingListener struct {
+	net.Listener
+	conns chan net.Conn
+}
+
+func newClosingListener(t *testing.T) *closingListener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cl := &closingListener{Listener: l, conns: make(chan net.Conn, 16)}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			cl.conns <- conn
+		}
+	}()
+	return cl
+}
+
+// closeFirst closes the first connection accepted by the listener.
+func (l *closingListener) closeFirst() {
+	conn := <-l.conns
+	conn.Close()
+}
+
+func TestTCPClient_Ping(t *testing.T) {
+	l := newClosingListener(t)
+	defer l.Close()
+
+	c, err := NewTCPClient(TCPConfig{Addr: l.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	rtt, version, err := c.Ping(0)
+	if err != nil || rtt <= 0 || version != "" {
+		t.Errorf("unexpected result.  expected (>0, '', nil), actual (%v, '%v', %v)", rtt, version, err)
+	}
+}
+
+func TestTCPClient_PingClosedByServer(t *testing.T) {
+	l := newClosingListener(t)
+	defer l.Close()
+
+	c, err := NewTCPClient(TCPConfig{Addr: l.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	l.closeFirst()
+	time.Sleep(10 * time.Millisecond)
+
+	if _, _, err := c.Ping(time.Second); err == nil {
+		t.Error("expected Ping to fail on a connection closed by the server")
+	}
+}
+
+func TestTCPClient_PingReconnects(t *testing.T) {
+	l := newClosingListener(t)
+	defer l.Close()
+
+	c, err := NewTCPClient(TCPConfig{Addr: l.Addr().String(), ReconnectOnError: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	l.closeFirst()
+	time.Sleep(10 * time.Millisecond)
+
+	if _, _, err := c.Ping(time.Second); err != nil {
+		t.Errorf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+}
+
+func TestTCPClient_PingServerDown(t *testing.T) {
+	l := newClosingListener(t)
+
+	c, err := NewTCPClient(TCPConfig{Addr: l.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// The existing connection stays open, but no new ones are accepted.
+	l.Close()
+
+	if _, _, err := c.Ping(time.Second); err == nil {
+		t.Error("expected Ping to fail when the server is not listening")
+	}
+}
+
+// discardConn accepts every write.
+type discardConn struct{}
+
+func (discardConn) Write(b []byte) (int, error) { return len(b), nil }
+func (discardConn) Close() error                { return nil }
+
+func TestTCPClient_WriteConcurrent(t *testing.T) {
+	w := &bufferConn{}
+	cl := &tcpclient{conn: w, payloadSize: 64}
+
+	const writers, batches = 8, 50
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < batches; j++ {
+				if err := cl.Write(newTestBatch(t, 5)); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSuffix(w.String(), "\n"), "\n")
+	if exp, got := writers*batches*5, len(lines); got != exp {
+		t.Fatalf("unexpected number of lines.  expected %v, actual %v", exp, got)
+	}
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "cpu,host=server01 value=") {
+			t.Fatalf("unexpected line %q", line)
+		}
+	}
+}
+
+func BenchmarkTCPClient_Write(b *testing.B) {
+	cl := &tcpclient{conn: discardConn{}, payloadSize: TCPPayloadSize}
+	bp := newTestBatch(b, 100)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := cl.Write(bp); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// newRecordingListener accepts connections and sends everything read from
+// them to the returned channel.
+func newRecordingListener(t *testing.T) (net.Listener, <-chan string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make(chan string, 16)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				buf := make([]byte, 1024)
+				for {
+					n, err := conn.Read(buf)
+					if n > 0 {
+						data <- string(buf[:n])
+					}
+					if err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+	return l, data
+}
+
+func TestTCPClient_ReResolve(t *testing.T) {
+	l1, data1 := newRecordingListener(t)
+	defer l1.Close()
+	l2, data2 := newRecordingListener(t)
+	defer l2.Close()
+
+	conn, err := net.Dial("tcp", l1.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := l1.Addr().(*net.TCPAddr)
+	cl := newTCPTestClient(conn, "influxdb.example:8094")
+	cl.reResolveInterval = time.Millisecond
+	cl.resolve = func(addr string) (*net.TCPAddr, error) { return target, nil }
+	defer cl.Close()
+
+	expectWrite := func(data <-chan string) {
+		t.Helper()
+		if err := cl.Write(newTestBatch(t, 1)); err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+		select {
+		case <-data:
+		case <-time.After(5 * time.Second):
+			t.Fatal("the write did not arrive at the expected listener")
+		}
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	expectWrite(data1)
+
+	// The name now resolves to the other listener.
+	target = l2.Addr().(*net.TCPAddr)
+	time.Sleep(2 * time.Millisecond)
+	expectWrite(data2)
+
+	if got := cl.RemoteAddr().String(); got != l2.Addr().String() {
+		t.Errorf("unexpected remote address.  expected %v, actual %v", l2.Addr(), got)
+	}
+}
+
+func TestTCPClient_RemoteAddr(t *testing.T) {
+	l := newSinkListener(t)
+	defer l.Close()
+
+	c, err := NewTCPClient(TCPConfig{Addr: l.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if got := c.(RemoteAddrClient).RemoteAddr(); got == nil || got.String() != l.Addr().String() {
+		t.Errorf("unexpected remote address.  expected %v, actual %v", l.Addr(), got)
+	}
+}
+
+func TestNewTCPClient_DialContext(t *testing.T) {
+	l := newSinkListener(t)
+	defer l.Close()
+
+	var dialed []string
+	c, err := NewTCPClient(TCPConfig{
+		Addr: "influxdb.example:8094",
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			dialed = append(dialed, addr)
+			var d net.Dialer
+			return d.DialContext(ctx, network, l.Addr().String())
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	defer c.Close()
+
+	if len(dialed) != 1 || dialed[0] != "influxdb.example:8094" {
+		t.Errorf("unexpected dials: %v", dialed)
+	}
+}
+
+func TestNewTCPClient_DialTimeout(t *testing.T) {
+	start := time.Now()
+	_, err := NewTCPClient(TCPConfig{
+		Addr:        "127.0.0.1:8094",
+		DialTimeout: 50 * time.Millisecond,
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("unexpected error.  expected %v, actual %v", context.DeadlineExceeded, err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("dial took %v, expected it to give up after the dial timeout", elapsed)
+	}
+}
+
+func TestSetKeepAlive(t *testing.T) {
+	var d net.Dialer
+	setKeepAlive(&d, 30*time.Second, 0)
+	if d.KeepAlive != 30*time.Second {
+		t.Errorf("unexpected keep-alive.  expected %v, actual %v", 30*time.Second, d.KeepAlive)
+	}
+
+	setKeepAlive(&d, -1, 10*time.Second)
+	if d.KeepAlive >= 0 {
+		t.Errorf("expected keep-alives to be disabled, got %v", d.KeepAlive)
+	}
+}
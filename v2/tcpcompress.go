@@ -0,0 +1,68 @@
+package client
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// The compressions of TCPConfig.Compression.
+const (
+	// TCPCompressionSnappy compresses payloads to the snappy block format,
+	// with the encoder of the client unless a Compressor is set.
+	TCPCompressionSnappy = "snappy"
+
+	// TCPCompressionZstd compresses payloads to zstd frames. The client has
+	// no zstd encoder of its own: it must be given one as the Compressor.
+	TCPCompressionZstd = "zstd"
+)
+
+// BlockCompressor compresses the payloads of a TCP client, see
+// TCPConfig.Compressor.
+type BlockCompressor interface {
+	// CompressBlock appends the compressed form of src to dst and returns
+	// the extended buffer. src must be decompressed as a whole, independently
+	// of the payloads before it. The connections of a pool call it
+	// concurrently.
+	CompressBlock(dst, src []byte) ([]byte, error)
+}
+
+// tcpFrameHeaderSize is the size of the length prefixing a compressed payload.
+const tcpFrameHeaderSize = 4
+
+// newTCPCompressor returns the function compressing the payloads of a
+// connection with the compression of conf, nil without compression.
+func newTCPCompressor(conf TCPConfig) (func() BlockCompressor, error) {
+	switch conf.Compression {
+	case "":
+		if conf.Compressor != nil {
+			return nil, &ConfigError{Field: "Compressor", Reason: "set without a Compression"}
+		}
+		return nil, nil
+	case TCPCompressionSnappy:
+		if conf.Compressor == nil {
+			// Each connection has an encoder of its own as it is not safe
+			// for concurrent use.
+			return func() BlockCompressor { return &snappyEncoder{} }, nil
+		}
+	case TCPCompressionZstd:
+		if conf.Compressor == nil {
+			return nil, &ConfigError{Field: "Compressor", Reason: "zstd needs a Compressor, the client has no zstd encoder"}
+		}
+	default:
+		return nil, &ConfigError{Field: "Compression", Reason: fmt.Sprintf("unknown compression %q", conf.Compression)}
+	}
+	return func() BlockCompressor { return conf.Compressor }, nil
+}
+
+// frame returns the frame of the payload b compressed, in a buffer of uc
+// reused by the next payload. It must be called with mu held.
+func (uc *tcpclient) frame(b []byte) ([]byte, error) {
+	f := append(uc.frameBuf[:0], 0, 0, 0, 0)
+	f, err := uc.compressor.CompressBlock(f, b)
+	if err != nil {
+		return nil, fmt.Errorf("compressing payload: %w", err)
+	}
+	binary.BigEndian.PutUint32(f, uint32(len(f)-tcpFrameHeaderSize))
+	uc.frameBuf = f
+	return f, nil
+}
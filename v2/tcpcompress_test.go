@@ -0,0 +1,283 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flateCompressor compresses blocks with compress/flate, standing in for a
+// zstd encoder.
+type flateCompressor struct{}
+
+func (flateCompressor) CompressBlock(dst, src []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(dst)
+	w, _ := flate.NewWriter(buf, flate.BestSpeed)
+	w.Write(src)
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func flateDecode(b []byte) ([]byte, error) {
+	return io.ReadAll(flate.NewReader(bytes.NewReader(b)))
+}
+
+// frameListener is a relay decompressing the frames of the connections it
+// accepts.
+type frameListener struct {
+	net.Listener
+	decode func([]byte) ([]byte, error)
+
+	mu       sync.Mutex
+	payloads []string
+	err      error
+	// wire is the number of bytes read from the connections.
+	wire int64
+}
+
+func newFrameListener(t *testing.T, decode func([]byte) ([]byte, error)) *frameListener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fl := &frameListener{Listener: l, decode: decode}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go fl.serve(conn)
+		}
+	}()
+	return fl
+}
+
+func (fl *frameListener) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	var header [tcpFrameHeaderSize]byte
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return
+		}
+		block := make([]byte, binary.BigEndian.Uint32(header[:]))
+		if _, err := io.ReadFull(r, block); err != nil {
+			fl.fail(err)
+			return
+		}
+		atomic.AddInt64(&fl.wire, int64(len(header)+len(block)))
+		payload, err := fl.decode(block)
+		if err != nil {
+			fl.fail(err)
+			return
+		}
+		fl.mu.Lock()
+		fl.payloads = append(fl.payloads, string(payload))
+		fl.mu.Unlock()
+	}
+}
+
+func (fl *frameListener) fail(err error) {
+	fl.mu.Lock()
+	fl.err = err
+	fl.mu.Unlock()
+}
+
+// wait waits for the lines decompressed to be n, and returns them.
+func (fl *frameListener) wait(t *testing.T, n int) []string {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		fl.mu.Lock()
+		payloads, err := fl.payloads, fl.err
+		fl.mu.Unlock()
+		if err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+		for _, p := range payloads {
+			if !strings.HasSuffix(p, "\n") {
+				t.Fatalf("unexpected payload.  expected whole lines, actual %q", p)
+			}
+		}
+		lines := strings.SplitAfter(strings.Join(payloads, ""), "\n")
+		lines = lines[:len(lines)-1]
+		if len(lines) >= n || time.Now().After(deadline) {
+			return lines
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// newCompressibleBatch returns a batch of n points of few series.
+func newCompressibleBatch(n int) BatchPoints {
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	for i := 0; i < n; i++ {
+		p, _ := NewPoint("cpu_usage",
+			map[string]string{"host": fmt.Sprintf("server%02d", i%10), "region": "us-west", "datacenter": "dc1"},
+			map[string]interface{}{"usage_idle": float64(i % 100), "usage_user": i % 7},
+			time.Unix(0, int64(i)))
+		bp.AddPoint(p)
+	}
+	return bp
+}
+
+func TestTCPClient_Compression(t *testing.T) {
+	tests := []struct {
+		name   string
+		conf   TCPConfig
+		decode func([]byte) ([]byte, error)
+	}{
+		{
+			name:   "snappy",
+			conf:   TCPConfig{Compression: TCPCompressionSnappy},
+			decode: snappyDecode,
+		},
+		{
+			name:   "snappy pool",
+			conf:   TCPConfig{Compression: TCPCompressionSnappy, PoolSize: 3},
+			decode: snappyDecode,
+		},
+		{
+			name:   "compressor",
+			conf:   TCPConfig{Compression: TCPCompressionZstd, Compressor: flateCompressor{}},
+			decode: flateDecode,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fl := newFrameListener(t, test.decode)
+			defer fl.Close()
+
+			conf := test.conf
+			conf.Addr = fl.Addr().String()
+			c, err := NewTCPClient(conf)
+			if err != nil {
+				t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+			}
+			bp := newCompressibleBatch(500)
+			if err := c.Write(bp); err != nil {
+				t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+			}
+			raw := []byte("mem free=1i 1\nmem free=2i 2\n")
+			if err := c.(RawBytesWriter).WriteRawBytes(raw); err != nil {
+				t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+			}
+			if err := c.Close(); err != nil {
+				t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+			}
+
+			var exp []string
+			for _, p := range bp.Points() {
+				exp = append(exp, p.String()+"\n")
+			}
+			exp = append(exp, "mem free=1i 1\n", "mem free=2i 2\n")
+			lines := fl.wait(t, len(exp))
+			if test.conf.PoolSize > 1 {
+				// The payloads are spread over the connections.
+				sort.Strings(exp)
+				sort.Strings(lines)
+			}
+			if strings.Join(lines, "") != strings.Join(exp, "") {
+				t.Errorf("unexpected lines.  expected %d lines, actual %d:\n%s", len(exp), len(lines), strings.Join(lines, ""))
+			}
+			if wire := atomic.LoadInt64(&fl.wire); wire == 0 || wire > int64(len(strings.Join(exp, "")))/2 {
+				t.Errorf("unexpected bytes on the wire.  expected less than half of %d, actual %d", len(strings.Join(exp, "")), wire)
+			}
+		})
+	}
+}
+
+func TestTCPClient_CompressionReconnect(t *testing.T) {
+	fl := newFrameListener(t, snappyDecode)
+	defer fl.Close()
+
+	uc := newTCPTestClient(brokenConn{}, fl.Addr().String())
+	uc.compressor = &snappyEncoder{}
+	bp := newCompressibleBatch(10)
+	if err := uc.Write(bp); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	uc.Close()
+	// The frame that failed was sent again on the new connection.
+	if lines := fl.wait(t, 10); len(lines) != 10 {
+		t.Errorf("unexpected lines.  expected %v, actual %v", 10, len(lines))
+	}
+}
+
+func TestTCPClient_CompressionConfig(t *testing.T) {
+	fl := newFrameListener(t, snappyDecode)
+	defer fl.Close()
+	addr := fl.Addr().String()
+
+	for _, conf := range []TCPConfig{
+		{Addr: addr, Compression: "lz4"},
+		{Addr: addr, Compression: TCPCompressionZstd},
+		{Addr: addr, Compressor: flateCompressor{}},
+	} {
+		var ce *ConfigError
+		if _, err := NewTCPClient(conf); !errors.As(err, &ce) {
+			t.Errorf("unexpected error for %+v.  expected a *ConfigError, actual %v", conf, err)
+		}
+	}
+}
+
+// newCountingListener returns a listener counting the bytes of the first
+// connection it accepts, sent on the returned channel once it is closed.
+func newCountingListener(b *testing.B) (net.Listener, <-chan int64) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		b.Fatal(err)
+	}
+	counted := make(chan int64, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		n, _ := io.Copy(io.Discard, conn)
+		counted <- n
+	}()
+	return l, counted
+}
+
+func BenchmarkTCPClient_Compression(b *testing.B) {
+	for _, compression := range []string{"", TCPCompressionSnappy} {
+		name := compression
+		if name == "" {
+			name = "none"
+		}
+		b.Run(name, func(b *testing.B) {
+			l, counted := newCountingListener(b)
+			defer l.Close()
+			c, err := NewTCPClient(TCPConfig{Addr: l.Addr().String(), Compression: compression, PayloadSize: 64 * 1024})
+			if err != nil {
+				b.Fatal(err)
+			}
+			bp := newCompressibleBatch(1000)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := c.Write(bp); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.StopTimer()
+			c.Close()
+			b.ReportMetric(float64(<-counted)/float64(b.N), "wire-B/op")
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package client
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// tcppool spreads the payloads of its writes over several connections to the
+// same address.
+type tcppool struct {
+	conns          []*tcpclient
+	payloadSize    int
+	timePrecision  string
+	bufs           payloadBuffers
+	stats          StatsCollector
+	validateRaw    bool
+	validatePoints bool
+	limiter        *rateLimiter
+	next           uint32
+	drain          drainer
+}
+
+func (p *tcppool) Write(bp BatchPoints) error {
+	return p.WriteContext(context.Background(), bp)
+}
+
+// WriteContext is like Write, but gives up once ctx is done. Every payload is
+// sent on the connection checked out for it.
+func (p *tcppool) WriteContext(ctx context.Context, bp BatchPoints) error {
+	_, err := p.WriteWithStats(ctx, bp)
+	return err
+}
+
+// WriteWithStats is like WriteContext, returning the statistics of the write.
+// The time spent waiting for a free connection counts as network time.
+func (p *tcppool) WriteWithStats(ctx context.Context, bp BatchPoints) (WriteStats, error) {
+	return p.write(ctx, len(bp.Points()), bp.Validate, func(flush func([]byte) error) error {
+		return writePayloads(ctx, bp, p.timePrecision, &p.bufs, p.payloadSize, true, flush)
+	})
+}
+
+// write sends the payloads of points, which encode hands to flush, once
+// validate accepted them if points are validated.
+func (p *tcppool) write(ctx context.Context, points int, validate func() error, encode func(flush func([]byte) error) error) (ws WriteStats, err error) {
+	if err := p.drain.begin(); err != nil {
+		return ws, err
+	}
+	defer p.drain.end()
+	if err := ctx.Err(); err != nil {
+		return ws, err
+	}
+	if p.validatePoints {
+		if err := validate(); err != nil {
+			return ws, err
+		}
+	}
+	ws.PointCount = points
+	if p.stats != nil {
+		defer writeDone(p.stats, ws.PointCount, time.Now(), &ws.ByteCount, &err)
+	}
+	defer func() { err = contextError(ctx, err) }()
+
+	t := newWriteTimer(&ws)
+	var flush = func(b []byte) error {
+		t.encoded()
+		if err := p.limiter.wait(ctx, countLines(b), len(b)); err != nil {
+			return err
+		}
+		t.sending(len(b))
+		defer t.sent()
+		uc := p.checkout()
+		defer uc.mu.Unlock()
+
+		var reconnected bool
+		return uc.flush(ctx, b, &reconnected)
+	}
+	err = encode(flush)
+	t.encoded()
+	return ws, err
+}
+
+// checkout returns a connection of the pool with its mu held, preferring one
+// that is not in use. The search starts at the next connection in turn.
+func (p *tcppool) checkout() *tcpclient {
+	start := int(atomic.AddUint32(&p.next, 1) - 1)
+	for i := range p.conns {
+		if uc := p.conns[(start+i)%len(p.conns)]; uc.mu.TryLock() {
+			return uc
+		}
+	}
+	uc := p.conns[start%len(p.conns)]
+	uc.mu.Lock()
+	return uc
+}
+
+// Ping checks a connection of the pool, see the TCP client's Ping.
+func (p *tcppool) Ping(timeout time.Duration) (time.Duration, string, error) {
+	if err := p.drain.begin(); err != nil {
+		return 0, "", err
+	}
+	defer p.drain.end()
+	start := int(atomic.AddUint32(&p.next, 1) - 1)
+	return p.conns[start%len(p.conns)].Ping(timeout)
+}
+
+func (p *tcppool) Query(q Query) (*Response, error) {
+	return nil, queryNotSupportedError("TCP")
+}
+
+func (p *tcppool) QueryContext(ctx context.Context, q Query) (*Response, error) {
+	return p.Query(q)
+}
+
+func (p *tcppool) QueryAsChunk(q Query) (*ChunkedResponse, error) {
+	return nil, queryNotSupportedError("TCP")
+}
+
+func (p *tcppool) QueryAsChunkContext(ctx context.Context, q Query) (*ChunkedResponse, error) {
+	return p.QueryAsChunk(q)
+}
+
+// RemoteAddr returns the address of the first connection of the pool.
+func (p *tcppool) RemoteAddr() net.Addr {
+	return p.conns[0].RemoteAddr()
+}
+
+// Close is like the TCP client's Close, closing every connection of the pool.
+func (p *tcppool) Close() error {
+	for _, uc := range p.conns {
+		uc.abortReconnect()
+	}
+	return p.drain.close(func() error {
+		var err error
+		for _, uc := range p.conns {
+			if cerr := uc.Close(); cerr != nil && err == nil {
+				err = cerr
+			}
+		}
+		return err
+	})
+}
@@ -0,0 +1,178 @@
+package client
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// bufferConn records everything written to it.
+type bufferConn struct {
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	closed bool
+}
+
+func (c *bufferConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.buf.Write(b)
+}
+
+func (c *bufferConn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return nil
+}
+
+func (c *bufferConn) String() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.buf.String()
+}
+
+// newSinkListener accepts connections and discards what is written to them.
+func newSinkListener(tb testing.TB) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(ioutil.Discard, conn)
+				conn.Close()
+			}()
+		}
+	}()
+	return l
+}
+
+func newTestBatch(tb testing.TB, n int) BatchPoints {
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	for i := 0; i < n; i++ {
+		pt, err := NewPoint("cpu", map[string]string{"host": "server01"}, map[string]interface{}{"value": i}, time.Unix(0, int64(i)))
+		if err != nil {
+			tb.Fatal(err)
+		}
+		bp.AddPoint(pt)
+	}
+	return bp
+}
+
+func TestTCPPool_WriteSkipsBusyConnection(t *testing.T) {
+	busy, idle := &bufferConn{}, &bufferConn{}
+	p := &tcppool{
+		conns: []*tcpclient{
+			{conn: busy, payloadSize: TCPPayloadSize},
+			{conn: idle, payloadSize: TCPPayloadSize},
+		},
+		payloadSize: TCPPayloadSize,
+	}
+
+	p.conns[0].mu.Lock()
+	for i := 0; i < 3; i++ {
+		if err := p.Write(newTestBatch(t, 1)); err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+	}
+	p.conns[0].mu.Unlock()
+
+	if busy.String() != "" {
+		t.Errorf("unexpected write to a busy connection: %q", busy.String())
+	}
+	if exp, got := 3, bytes.Count([]byte(idle.String()), []byte("\n")); got != exp {
+		t.Errorf("unexpected number of points.  expected %v, actual %v", exp, got)
+	}
+}
+
+func TestTCPPool_RedialsBrokenConnection(t *testing.T) {
+	l := newSinkListener(t)
+	defer l.Close()
+
+	uc := &tcpclient{
+		conn:         brokenConn{},
+		payloadSize:  TCPPayloadSize,
+		redialBroken: true,
+		addr:         l.Addr().String(),
+		closing:      make(chan struct{}),
+	}
+	p := &tcppool{conns: []*tcpclient{uc}, payloadSize: TCPPayloadSize}
+	defer p.Close()
+
+	if err := p.Write(newTestBatch(t, 1)); err == nil {
+		t.Fatal("expected the write on a broken connection to fail")
+	}
+	if !uc.broken {
+		t.Fatal("expected the connection to be marked broken")
+	}
+	if err := p.Write(newTestBatch(t, 1)); err != nil {
+		t.Errorf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if uc.broken {
+		t.Error("expected the connection to be re-dialed")
+	}
+}
+
+func TestTCPPool_CloseClosesAllConnections(t *testing.T) {
+	l := newSinkListener(t)
+	defer l.Close()
+
+	c, err := NewTCPClient(TCPConfig{Addr: l.Addr().String(), PoolSize: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, ok := c.(*tcppool)
+	if !ok {
+		t.Fatalf("unexpected client type %T", c)
+	}
+	if exp, got := 3, len(p.conns); got != exp {
+		t.Fatalf("unexpected pool size.  expected %v, actual %v", exp, got)
+	}
+	if err := c.Write(newTestBatch(t, 10)); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	for i, uc := range p.conns {
+		if _, err := uc.conn.Write([]byte("x")); err == nil {
+			t.Errorf("expected connection %d to be closed", i)
+		}
+	}
+}
+
+func benchmarkTCPClientWrite(b *testing.B, poolSize int) {
+	l := newSinkListener(b)
+	defer l.Close()
+
+	c, err := NewTCPClient(TCPConfig{Addr: l.Addr().String(), PoolSize: poolSize})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer c.Close()
+
+	bp := newTestBatch(b, 100)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if err := c.Write(bp); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+func BenchmarkTCPClient_WritePoolSize1(b *testing.B) { benchmarkTCPClientWrite(b, 1) }
+func BenchmarkTCPClient_WritePoolSize4(b *testing.B) { benchmarkTCPClientWrite(b, 4) }
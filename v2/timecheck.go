@@ -0,0 +1,166 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+// TimestampViolation is a point whose timestamp is too far from the current
+// time, as returned by BatchPoints.CheckTimestamps.
+type TimestampViolation struct {
+	// Index is the index of the point in its batch.
+	Index       int
+	Measurement string
+
+	// Time is the timestamp of the point in the precision of its batch,
+	// and Delta how far it is from the current time, negative in the past.
+	Time  time.Time
+	Delta time.Duration
+}
+
+func (v TimestampViolation) String() string {
+	if v.Delta > 0 {
+		return fmt.Sprintf("point %d (%s) is %v in the future", v.Index, v.Measurement, v.Delta)
+	}
+	return fmt.Sprintf("point %d (%s) is %v in the past", v.Index, v.Measurement, -v.Delta)
+}
+
+// TimestampError is passed to BatchingOptions.OnError with the points whose
+// timestamp is too far from the current time, see TimestampCheck.
+type TimestampError struct {
+	// Action is what was done with the points.
+	Action     TimestampAction
+	Violations []TimestampViolation
+}
+
+func (e *TimestampError) Error() string {
+	msgs := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		msgs[i] = v.String()
+	}
+	var action string
+	switch e.Action {
+	case TimestampDrop:
+		action = "dropped"
+	case TimestampClamp:
+		action = "written at the current time"
+	case TimestampReject:
+		action = "batch rejected"
+	}
+	return fmt.Sprintf("timestamps out of range, %s: %s", action, strings.Join(msgs, "; "))
+}
+
+// TimestampAction is what a BatchingClient does with the points whose
+// timestamp is too far from the current time.
+type TimestampAction int
+
+const (
+	// TimestampDrop drops the points.
+	TimestampDrop TimestampAction = iota
+
+	// TimestampClamp writes the points with the current time.
+	TimestampClamp
+
+	// TimestampReject drops every point of the batch.
+	TimestampReject
+)
+
+// TimestampCheck makes a BatchingClient check the timestamps of the points of
+// every batch before writing it, see BatchingOptions.TimestampCheck.
+type TimestampCheck struct {
+	// MaxPast and MaxFuture are how far in the past and in the future of
+	// the current time a timestamp may be, a limit being ignored if it is
+	// not positive. One of them must be set.
+	MaxPast, MaxFuture time.Duration
+
+	// Action is what happens to the points out of range, defaults to
+	// TimestampDrop.
+	Action TimestampAction
+
+	// Now returns the current time, defaults to time.Now.
+	Now func() time.Time
+}
+
+// validate checks the config of tc.
+func (tc *TimestampCheck) validate() error {
+	if tc.MaxPast <= 0 && tc.MaxFuture <= 0 {
+		return &ConfigError{Field: "TimestampCheck", Reason: "neither MaxPast nor MaxFuture is set"}
+	}
+	switch tc.Action {
+	case TimestampDrop, TimestampClamp, TimestampReject:
+	default:
+		return &ConfigError{Field: "TimestampCheck.Action", Reason: "unknown timestamp action"}
+	}
+	return nil
+}
+
+// apply checks the timestamps of points, written in precision, and returns
+// the points to write along with the error to report with the points out of
+// range, if any.
+func (tc *TimestampCheck) apply(points []*Point, precision string) ([]*Point, []*Point, error) {
+	now := time.Now
+	if tc.Now != nil {
+		now = tc.Now
+	}
+	t := now()
+	violations := checkTimestamps(points, precision, t, tc.MaxPast, tc.MaxFuture)
+	if len(violations) == 0 {
+		return points, nil, nil
+	}
+	err := &TimestampError{Action: tc.Action, Violations: violations}
+	if tc.Action == TimestampReject {
+		return nil, points, err
+	}
+
+	kept := make([]*Point, 0, len(points))
+	bad := make([]*Point, 0, len(violations))
+	for i, p := range points {
+		if len(violations) == 0 || violations[0].Index != i {
+			kept = append(kept, p)
+			continue
+		}
+		violations = violations[1:]
+		bad = append(bad, p)
+		if tc.Action == TimestampClamp {
+			// A copy, the point added is left as is.
+			kept = append(kept, &Point{pt: models.PointWithTime(p.pt, t)})
+		}
+	}
+	return kept, bad, err
+}
+
+// checkTimestamps returns the points of points whose timestamp in precision
+// is more than maxPast before now or maxFuture after it. Points without a
+// timestamp are left to the server and never reported.
+func checkTimestamps(points []*Point, precision string, now time.Time, maxPast, maxFuture time.Duration) []TimestampViolation {
+	var violations []TimestampViolation
+	for i, p := range points {
+		if p == nil || p.pt.Time().IsZero() {
+			continue
+		}
+		t := truncateTime(p.pt.Time(), precision)
+		delta := t.Sub(now)
+		if (maxFuture > 0 && delta > maxFuture) || (maxPast > 0 && -delta > maxPast) {
+			violations = append(violations, TimestampViolation{
+				Index:       i,
+				Measurement: string(p.pt.Name()),
+				Time:        t,
+				Delta:       delta,
+			})
+		}
+	}
+	return violations
+}
+
+func (bp *batchpoints) CheckTimestamps(now time.Time, maxPast, maxFuture time.Duration) []TimestampViolation {
+	return checkTimestamps(bp.points, bp.precision, now, maxPast, maxFuture)
+}
+
+func (s *safeBatchPoints) CheckTimestamps(now time.Time, maxPast, maxFuture time.Duration) []TimestampViolation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bp.CheckTimestamps(now, maxPast, maxFuture)
+}
@@ -0,0 +1,132 @@
+package client
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBatchPoints_CheckTimestamps(t *testing.T) {
+	now := time.Unix(10000, 0)
+	at := func(name string, t time.Time) *Point {
+		p, _ := NewPoint(name, nil, map[string]interface{}{"value": 1.0}, t)
+		return p
+	}
+	points := []*Point{
+		at("ok", now.Add(-time.Minute)),
+		at("future", now.Add(50*time.Minute)),
+		at("server", time.Time{}),
+		at("past", now.Add(-2*time.Hour)),
+	}
+
+	for _, tt := range []struct {
+		precision string
+		exp       []TimestampViolation
+	}{
+		{"ns", []TimestampViolation{
+			{Index: 1, Measurement: "future", Time: now.Add(50 * time.Minute), Delta: 50 * time.Minute},
+			{Index: 3, Measurement: "past", Time: now.Add(-2 * time.Hour), Delta: -2 * time.Hour},
+		}},
+		// In hours the point in the future is written at 3h, 800s after now.
+		{"h", []TimestampViolation{
+			{Index: 3, Measurement: "past", Time: time.Unix(0, 0), Delta: -10000 * time.Second},
+		}},
+	} {
+		bp, _ := NewBatchPoints(BatchPointsConfig{Precision: tt.precision})
+		sbp, _ := NewSafeBatchPoints(BatchPointsConfig{Precision: tt.precision})
+		for _, b := range []BatchPoints{bp, sbp} {
+			b.AddPoints(points)
+			violations := b.CheckTimestamps(now, time.Hour, 30*time.Minute)
+			for i := range violations {
+				if violations[i].Time.Equal(tt.exp[i].Time) {
+					violations[i].Time = tt.exp[i].Time
+				}
+			}
+			if !reflect.DeepEqual(violations, tt.exp) {
+				t.Errorf("unexpected violations in precision %s.\nexpected %v\nactual   %v", tt.precision, tt.exp, violations)
+			}
+		}
+	}
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	bp.AddPoints(points)
+	if v := bp.CheckTimestamps(now, 0, 0); len(v) != 0 {
+		t.Errorf("unexpected violations without limits: %v", v)
+	}
+	if v := bp.CheckTimestamps(now, 0, time.Hour); len(v) != 0 {
+		t.Errorf("unexpected violations without a past limit: %v", v)
+	}
+}
+
+func TestBatchingClient_TimestampCheck(t *testing.T) {
+	now := time.Unix(10000, 500e6)
+	newPoints := func() []*Point {
+		var points []*Point
+		for _, d := range []time.Duration{-time.Second, 2 * time.Hour, 0, -3 * time.Hour} {
+			p, _ := NewPoint("cpu", nil, map[string]interface{}{"value": 1.0}, now.Add(d))
+			points = append(points, p)
+		}
+		server, _ := NewPoint("cpu", nil, map[string]interface{}{"value": 2.0}, time.Time{})
+		return append(points, server)
+	}
+
+	for _, tt := range []struct {
+		action  TimestampAction
+		times   []string
+		dropped int
+	}{
+		{TimestampDrop, []string{"9999", "10000", ""}, 2},
+		{TimestampClamp, []string{"9999", "10000", "10000", "10000", ""}, 2},
+		{TimestampReject, nil, 5},
+	} {
+		r := &batchRecorder{}
+		var reported error
+		var dropped []*Point
+		bc, err := NewBatchingClient(r, BatchingOptions{
+			BatchPointsConfig: BatchPointsConfig{Database: "db0", Precision: "s"},
+			FlushInterval:     time.Hour,
+			TimestampCheck: &TimestampCheck{
+				MaxPast:   time.Hour,
+				MaxFuture: time.Minute,
+				Action:    tt.action,
+				Now:       func() time.Time { return now },
+			},
+			OnError: func(err error, points []*Point) { reported, dropped = err, points },
+		})
+		if err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+		points := newPoints()
+		bc.AddPoints(points)
+		bc.Close()
+
+		var times []string
+		for _, b := range r.batches {
+			for _, p := range b {
+				s := ""
+				if !p.Time().IsZero() {
+					s = p.PrecisionString("s")[len("cpu value=1 "):]
+				}
+				times = append(times, s)
+			}
+		}
+		if !reflect.DeepEqual(times, tt.times) {
+			t.Errorf("unexpected times written for action %d.  expected %q, actual %q", tt.action, tt.times, times)
+		}
+		var te *TimestampError
+		if !errors.As(reported, &te) || len(te.Violations) != 2 || te.Action != tt.action || len(dropped) != tt.dropped {
+			t.Errorf("unexpected report for action %d: %v, %d points", tt.action, reported, len(dropped))
+		}
+		if !points[1].Time().Equal(now.Add(2 * time.Hour)) {
+			t.Errorf("expected the point added to be left as is, actual %v", points[1])
+		}
+	}
+
+	for _, tc := range []*TimestampCheck{{}, {MaxPast: time.Hour, Action: 3}} {
+		var ce *ConfigError
+		if _, err := NewBatchingClient(&batchRecorder{}, BatchingOptions{TimestampCheck: tc}); !errors.As(err, &ce) {
+			t.Errorf("unexpected error.  expected a *ConfigError, actual %v", err)
+		}
+	}
+}
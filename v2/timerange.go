@@ -0,0 +1,164 @@
+package client
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+const (
+	// MinNanoTime and MaxNanoTime are the earliest and latest timestamps
+	// the server can store, in nanoseconds since the Unix epoch, that is
+	// 1677-09-21 00:12:43.145224194 and 2262-04-11 23:47:16.854775806 UTC.
+	MinNanoTime = models.MinNanoTime
+	MaxNanoTime = models.MaxNanoTime
+
+	// MinPlausibleNanoTime and MaxPlausibleNanoTime bound the timestamps
+	// NewPoint accepts unless PointOptions.AllowAnyTime is set, in
+	// nanoseconds since the Unix epoch: 1900-01-01 and 2200-01-01 UTC.
+	// Timestamps outside of them are most likely of the wrong unit, such as
+	// milliseconds taken for seconds.
+	MinPlausibleNanoTime int64 = -2208988800 * int64(time.Second)
+	MaxPlausibleNanoTime int64 = 7258118400 * int64(time.Second)
+)
+
+// TimeRangeError is returned by NewPoint and NewPointWithPrecision for a
+// timestamp out of the accepted range.
+type TimeRangeError struct {
+	Measurement string
+
+	// Time is the timestamp given to NewPoint. For NewPointWithPrecision
+	// it is zero, and Timestamp is the timestamp given in units of
+	// Precision.
+	Time      time.Time
+	Timestamp int64
+	Precision string
+
+	// Min and Max are the earliest and latest timestamps accepted.
+	Min, Max time.Time
+
+	// Plausible is whether the timestamp could be stored, but is outside
+	// of MinPlausibleNanoTime and MaxPlausibleNanoTime.
+	Plausible bool
+}
+
+func (e *TimeRangeError) Error() string {
+	ts := e.Time.UTC().Format(time.RFC3339Nano)
+	if e.Precision != "" {
+		ts = fmt.Sprintf("%d in precision %s", e.Timestamp, e.Precision)
+	}
+	msg := fmt.Sprintf("point %s: timestamp %s is outside the range %s to %s",
+		e.Measurement, ts, e.Min.Format(time.RFC3339Nano), e.Max.Format(time.RFC3339Nano))
+	if e.Plausible {
+		msg += ", set PointOptions.AllowAnyTime to write it anyway"
+	}
+	return msg
+}
+
+// TimeRange returns the earliest and latest timestamps the server can store
+// in units of precision, such as "s", as written by a batch of that
+// precision.
+func TimeRange(precision string) (min, max int64) {
+	d := int64(precisionDuration(precision))
+	return MinNanoTime / d, MaxNanoTime / d
+}
+
+// checkTime returns a *TimeRangeError if t is set but out of the range
+// accepted by o.
+func (o PointOptions) checkTime(name string, t time.Time) error {
+	if t.IsZero() {
+		return nil
+	}
+	min, max := time.Unix(0, MinNanoTime).UTC(), time.Unix(0, MaxNanoTime).UTC()
+	if t.Before(min) || t.After(max) {
+		return &TimeRangeError{Measurement: name, Time: t, Min: min, Max: max}
+	}
+	if o.AllowAnyTime {
+		return nil
+	}
+	min, max = time.Unix(0, MinPlausibleNanoTime).UTC(), time.Unix(0, MaxPlausibleNanoTime).UTC()
+	if t.Before(min) || !t.Before(max) {
+		return &TimeRangeError{Measurement: name, Time: t, Min: min, Max: max, Plausible: true}
+	}
+	return nil
+}
+
+// truncateTime truncates t to precision toward zero, which is what the
+// timestamps of the HTTP writes in that precision are. The zero time is left
+// as is.
+func truncateTime(t time.Time, precision string) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	d := int64(precisionDuration(precision))
+	ns := t.UnixNano()
+	if ns%d == 0 {
+		return t
+	}
+	return time.Unix(0, ns/d*d).In(t.Location())
+}
+
+func (bp *batchpoints) TruncateTimes() {
+	for i, p := range bp.points {
+		t := p.pt.Time()
+		if tt := truncateTime(t, bp.precision); !tt.Equal(t) {
+			bp.points[i] = &Point{pt: models.PointWithTime(p.pt, tt)}
+		}
+	}
+}
+
+func (s *safeBatchPoints) TruncateTimes() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bp.TruncateTimes()
+}
+
+// NewPointWithPrecision returns a point with the timestamp ts in units of
+// precision, such as "ms", rather than a time.Time. A timestamp that the
+// server could not store in that precision, or outside of
+// MinPlausibleNanoTime and MaxPlausibleNanoTime, is an error, see
+// PointOptions.AllowAnyTime.
+func NewPointWithPrecision(
+	name string,
+	tags map[string]string,
+	fields map[string]interface{},
+	ts int64,
+	precision string,
+) (*Point, error) {
+	return PointOptions{}.NewPointWithPrecision(name, tags, fields, ts, precision)
+}
+
+// NewPointWithPrecision is like the NewPointWithPrecision function, with the
+// field values converted as set by o.
+func (o PointOptions) NewPointWithPrecision(
+	name string,
+	tags map[string]string,
+	fields map[string]interface{},
+	ts int64,
+	precision string,
+) (*Point, error) {
+	if precision == "" {
+		precision = "ns"
+	}
+	if err := checkPrecision(precision); err != nil {
+		return nil, err
+	}
+	d := int64(precisionDuration(precision))
+	if min, max := TimeRange(precision); ts < min || ts > max {
+		return nil, &TimeRangeError{
+			Measurement: name,
+			Timestamp:   ts,
+			Precision:   precision,
+			Min:         time.Unix(0, min*d).UTC(),
+			Max:         time.Unix(0, max*d).UTC(),
+		}
+	}
+	t := time.Unix(0, ts*d).UTC()
+	if err := o.checkTime(name, t); err != nil {
+		err := err.(*TimeRangeError)
+		err.Time, err.Timestamp, err.Precision = time.Time{}, ts, precision
+		return nil, err
+	}
+	return o.NewPoint(name, tags, fields, t)
+}
@@ -0,0 +1,192 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+func TestNewPoint_TimeRange(t *testing.T) {
+	fields := map[string]interface{}{"v": 1}
+	for _, tt := range []struct {
+		name      string
+		opts      PointOptions
+		t         time.Time
+		plausible bool
+		ok        bool
+	}{
+		{name: "now", t: time.Now(), ok: true},
+		{name: "epoch", t: time.Unix(0, 0), ok: true},
+		{name: "historical", t: time.Date(1850, 1, 1, 0, 0, 0, 0, time.UTC), plausible: true},
+		{name: "far future", t: time.Date(2200, 1, 1, 0, 0, 0, 0, time.UTC), plausible: true},
+		{name: "historical allowed", opts: PointOptions{AllowAnyTime: true}, t: time.Date(1850, 1, 1, 0, 0, 0, 0, time.UTC), ok: true},
+		{name: "milliseconds as seconds", t: time.Unix(1500000000000, 0)},
+		{name: "milliseconds as seconds allowed", opts: PointOptions{AllowAnyTime: true}, t: time.Unix(1500000000000, 0)},
+		{name: "before the minimum", opts: PointOptions{AllowAnyTime: true}, t: time.Unix(0, MinNanoTime).Add(-time.Nanosecond)},
+		{name: "the minimum", opts: PointOptions{AllowAnyTime: true}, t: time.Unix(0, MinNanoTime), ok: true},
+	} {
+		_, err := tt.opts.NewPoint("cpu", nil, fields, tt.t)
+		if tt.ok {
+			if err != nil {
+				t.Errorf("%s: unexpected error.  expected %v, actual %v", tt.name, nil, err)
+			}
+			continue
+		}
+		var te *TimeRangeError
+		if !errors.As(err, &te) {
+			t.Errorf("%s: unexpected error.  expected %T, actual %v", tt.name, te, err)
+			continue
+		}
+		if te.Plausible != tt.plausible || te.Measurement != "cpu" || !te.Time.Equal(tt.t) {
+			t.Errorf("%s: unexpected error: %+v", tt.name, te)
+		}
+	}
+}
+
+func TestTimeRangeError_Error(t *testing.T) {
+	_, err := NewPoint("cpu", nil, map[string]interface{}{"v": 1}, time.Unix(1500000000000, 0))
+	exp := "point cpu: timestamp 49503-02-10T02:40:00Z is outside the range 1677-09-21T00:12:43.145224194Z to 2262-04-11T23:47:16.854775806Z"
+	if err == nil || err.Error() != exp {
+		t.Errorf("unexpected error.\nexpected %s\nactual   %v", exp, err)
+	}
+
+	_, err = NewPoint("cpu", nil, map[string]interface{}{"v": 1}, time.Date(1850, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err == nil || !strings.Contains(err.Error(), "1900-01-01T00:00:00Z to 2200-01-01T00:00:00Z, set PointOptions.AllowAnyTime") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestNewPointWithPrecision(t *testing.T) {
+	fields := map[string]interface{}{"v": 1}
+	p, err := NewPointWithPrecision("cpu", nil, fields, 1500000000000, "ms")
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if exp := time.Unix(1500000000, 0); !p.Time().Equal(exp) {
+		t.Errorf("unexpected time.  expected %v, actual %v", exp, p.Time())
+	}
+
+	// Milliseconds taken for seconds overflow.
+	_, err = NewPointWithPrecision("cpu", nil, fields, 1500000000000, "s")
+	var te *TimeRangeError
+	if !errors.As(err, &te) || te.Plausible || te.Timestamp != 1500000000000 || te.Precision != "s" || te.Max.Unix() != 9223372036 {
+		t.Errorf("unexpected error.  expected %T, actual %v", te, err)
+	}
+	exp := "point cpu: timestamp 1500000000000 in precision s is outside the range 1677-09-21T00:12:44Z to 2262-04-11T23:47:16Z"
+	if err == nil || err.Error() != exp {
+		t.Errorf("unexpected error.\nexpected %s\nactual   %v", exp, err)
+	}
+
+	_, err = NewPointWithPrecision("cpu", nil, fields, -3000000000, "s")
+	if !errors.As(err, &te) || !te.Plausible || te.Timestamp != -3000000000 || !te.Time.IsZero() {
+		t.Errorf("unexpected error.  expected %T, actual %v", te, err)
+	}
+
+	if _, err := NewPointWithPrecision("cpu", nil, fields, 1, "fortnights"); err == nil {
+		t.Errorf("unexpected error.  expected an error, actual %v", err)
+	}
+}
+
+func TestTimeRange(t *testing.T) {
+	for _, tt := range []struct {
+		precision string
+		min, max  int64
+	}{
+		{precision: "ns", min: MinNanoTime, max: MaxNanoTime},
+		{precision: "ms", min: -9223372036854, max: 9223372036854},
+		{precision: "s", min: -9223372036, max: 9223372036},
+		{precision: "h", min: -2562047, max: 2562047},
+	} {
+		if min, max := TimeRange(tt.precision); min != tt.min || max != tt.max {
+			t.Errorf("unexpected range for %s.  expected %d to %d, actual %d to %d", tt.precision, tt.min, tt.max, min, max)
+		}
+	}
+}
+
+func TestPointOptions_Precision(t *testing.T) {
+	fields := map[string]interface{}{"v": 1}
+	for _, tt := range []struct {
+		precision string
+		t, exp    time.Time
+	}{
+		{"s", time.Unix(10, 999999999), time.Unix(10, 0)},
+		{"ms", time.Unix(10, 1500000), time.Unix(10, 1000000)},
+		{"m", time.Unix(119, 0), time.Unix(60, 0)},
+		// Toward zero, as the timestamps of HTTP writes.
+		{"s", time.Unix(-10, -500000000), time.Unix(-10, 0)},
+		{"s", time.Time{}, time.Time{}},
+	} {
+		p, err := PointOptions{Precision: tt.precision}.NewPoint("cpu", nil, fields, tt.t)
+		if err != nil {
+			t.Errorf("unexpected error.  expected %v, actual %v", nil, err)
+			continue
+		}
+		if !p.Time().Equal(tt.exp) {
+			t.Errorf("unexpected time of %v in precision %s.  expected %v, actual %v", tt.t, tt.precision, tt.exp, p.Time())
+		}
+	}
+
+	if _, err := (PointOptions{Precision: "fortnights"}).NewPoint("cpu", nil, fields, time.Now()); err == nil {
+		t.Error("expected an error for an invalid precision")
+	}
+}
+
+func TestBatchPoints_TruncateTimes(t *testing.T) {
+	tm := time.Unix(1500000000, 600000000)
+	p, _ := NewPoint("cpu", nil, map[string]interface{}{"v": 1}, tm)
+	noTime, _ := NewPoint("cpu", nil, map[string]interface{}{"v": 2})
+
+	// httpTimes and udpTimes return the timestamps the HTTP and UDP clients
+	// write the points of bp with.
+	httpTimes := func(bp BatchPoints) []int64 {
+		var buf bytes.Buffer
+		bp.WriteTo(&buf)
+		points, _ := models.ParsePointsWithPrecision(buf.Bytes(), time.Unix(0, 0), bp.Precision())
+		var times []int64
+		for _, pt := range points {
+			times = append(times, pt.UnixNano())
+		}
+		return times
+	}
+	udpTimes := func(bp BatchPoints) []int64 {
+		var times []int64
+		writePayloads(context.Background(), bp, "", &payloadBuffers{}, UDPPayloadSize, false, func(b []byte) error {
+			points, _ := models.ParsePointsWithPrecision(b, time.Unix(0, 0), "ns")
+			for _, pt := range points {
+				times = append(times, pt.UnixNano())
+			}
+			return nil
+		})
+		return times
+	}
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{Precision: "s"})
+	bp.AddPoints([]*Point{p, noTime})
+	if h, u := httpTimes(bp), udpTimes(bp); reflect.DeepEqual(h, u) {
+		t.Fatalf("expected the HTTP and UDP times to differ before truncating, got %v", h)
+	}
+	size := bp.Size()
+
+	bp.TruncateTimes()
+	if h, u := httpTimes(bp), udpTimes(bp); !reflect.DeepEqual(h, u) {
+		t.Errorf("unexpected times.  expected the HTTP times %v, actual UDP times %v", h, u)
+	}
+	if got := bp.Points()[0].Time(); !got.Equal(time.Unix(1500000000, 0)) {
+		t.Errorf("unexpected time.  expected %v, actual %v", time.Unix(1500000000, 0), got)
+	}
+	if !bp.Points()[1].Time().IsZero() || bp.Points()[1] != noTime {
+		t.Errorf("unexpected point without time: %v", bp.Points()[1])
+	}
+	if !p.Time().Equal(tm) {
+		t.Errorf("the point added was changed: %v", p.Time())
+	}
+	if bp.Size() != size {
+		t.Errorf("unexpected size.  expected %v, actual %v", size, bp.Size())
+	}
+}
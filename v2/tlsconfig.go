@@ -0,0 +1,157 @@
+package client
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// tlsHelpers are the TLS settings of HTTPConfig and TCPConfig that build a
+// TLS config, see HTTPConfig.CACertPEM.
+type tlsHelpers struct {
+	caPEM    []byte
+	caFile   string
+	pins     []string
+	certPEM  []byte
+	keyPEM   []byte
+	insecure bool
+}
+
+func (h tlsHelpers) set() bool {
+	return len(h.caPEM) > 0 || h.caFile != "" || len(h.pins) > 0 || len(h.certPEM) > 0 || len(h.keyPEM) > 0
+}
+
+// tlsConfig returns explicit if the helpers are not set, and the TLS config
+// they build otherwise, which explicit must then be nil. The errors are
+// *ConfigError.
+func (h tlsHelpers) tlsConfig(explicit *tls.Config) (*tls.Config, error) {
+	if !h.set() {
+		return explicit, nil
+	}
+	if explicit != nil {
+		return nil, &ConfigError{Field: "TLSConfig", Reason: "cannot be used together with CACertPEM, CACertFile, PinnedCertSHA256, ClientCertPEM or ClientKeyPEM"}
+	}
+	config := &tls.Config{InsecureSkipVerify: h.insecure}
+
+	switch {
+	case len(h.caPEM) > 0 && h.caFile != "":
+		return nil, &ConfigError{Field: "CACertFile", Reason: "cannot be used together with CACertPEM"}
+	case h.caFile != "":
+		pem, err := ioutil.ReadFile(h.caFile)
+		if err != nil {
+			return nil, &ConfigError{Field: "CACertFile", Reason: err.Error()}
+		}
+		if config.RootCAs, err = certPool("CACertFile", pem); err != nil {
+			return nil, err
+		}
+	case len(h.caPEM) > 0:
+		var err error
+		if config.RootCAs, err = certPool("CACertPEM", h.caPEM); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(h.certPEM) > 0 || len(h.keyPEM) > 0 {
+		if len(h.certPEM) == 0 || len(h.keyPEM) == 0 {
+			return nil, &ConfigError{Field: "ClientCertPEM", Reason: "ClientCertPEM and ClientKeyPEM must be set together"}
+		}
+		cert, err := tls.X509KeyPair(h.certPEM, h.keyPEM)
+		if err != nil {
+			return nil, &ConfigError{Field: "ClientCertPEM", Reason: err.Error()}
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	if len(h.pins) > 0 {
+		pins := make([][]byte, len(h.pins))
+		for i, pin := range h.pins {
+			var err error
+			if pins[i], err = parsePin(pin); err != nil {
+				return nil, &ConfigError{Field: "PinnedCertSHA256", Reason: fmt.Sprintf("%q: %v", pin, err)}
+			}
+		}
+		config.VerifyPeerCertificate = verifyPins(pins)
+	}
+	return config, nil
+}
+
+// certPool returns the pool of the certificates of pem, the value of field.
+func certPool(field string, pem []byte) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, &ConfigError{Field: field, Reason: "no valid PEM certificate found"}
+	}
+	return pool, nil
+}
+
+// parsePin decodes a SHA-256 hash, in hex, with or without colons, or in
+// base64 as after the "sha256/" of the pins of HPKP, which may prefix it.
+func parsePin(pin string) ([]byte, error) {
+	s := strings.TrimPrefix(strings.TrimSpace(pin), "sha256/")
+	if b, err := hex.DecodeString(strings.Replace(s, ":", "", -1)); err == nil && len(b) == sha256.Size {
+		return b, nil
+	}
+	if b, err := base64.StdEncoding.DecodeString(s); err == nil && len(b) == sha256.Size {
+		return b, nil
+	}
+	return nil, errors.New("not a SHA-256 hash in hex or base64")
+}
+
+// verifyPins returns the VerifyPeerCertificate of a TLS config that accepts
+// the servers with a certificate whose public key has one of the SHA-256
+// hashes pins, among those of the verified chains, or those presented if the
+// chains were not verified.
+func verifyPins(pins [][]byte) func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		var certs []*x509.Certificate
+		for _, chain := range verifiedChains {
+			certs = append(certs, chain...)
+		}
+		if len(verifiedChains) == 0 {
+			for _, raw := range rawCerts {
+				cert, err := x509.ParseCertificate(raw)
+				if err != nil {
+					return err
+				}
+				certs = append(certs, cert)
+			}
+		}
+		for _, cert := range certs {
+			sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+			for _, pin := range pins {
+				if bytes.Equal(sum[:], pin) {
+					return nil
+				}
+			}
+		}
+		return errors.New("tls: no certificate of the server matches PinnedCertSHA256")
+	}
+}
+
+func (conf HTTPConfig) tlsHelpers() tlsHelpers {
+	return tlsHelpers{
+		caPEM:    conf.CACertPEM,
+		caFile:   conf.CACertFile,
+		pins:     conf.PinnedCertSHA256,
+		certPEM:  conf.ClientCertPEM,
+		keyPEM:   conf.ClientKeyPEM,
+		insecure: conf.InsecureSkipVerify,
+	}
+}
+
+func (conf TCPConfig) tlsHelpers() tlsHelpers {
+	return tlsHelpers{
+		caPEM:   conf.CACertPEM,
+		caFile:  conf.CACertFile,
+		pins:    conf.PinnedCertSHA256,
+		certPEM: conf.ClientCertPEM,
+		keyPEM:  conf.ClientKeyPEM,
+	}
+}
@@ -0,0 +1,199 @@
+package client
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCert is a certificate and its key, in PEM and parsed.
+type testCert struct {
+	certPEM, keyPEM []byte
+	cert            *x509.Certificate
+	key             *ecdsa.PrivateKey
+}
+
+// tlsCert returns the certificate for a tls.Config.
+func (c testCert) tlsCert(t *testing.T) tls.Certificate {
+	cert, err := tls.X509KeyPair(c.certPEM, c.keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// spki returns the SHA-256 of the public key of the certificate.
+func (c testCert) spki() []byte {
+	sum := sha256.Sum256(c.cert.RawSubjectPublicKeyInfo)
+	return sum[:]
+}
+
+// newTestCert returns a certificate for 127.0.0.1 valid until notAfter,
+// signed by parent or self-signed as a CA if parent is nil.
+func newTestCert(t *testing.T, parent *testCert, notAfter time.Time) testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "influxdb"},
+		NotBefore:    notAfter.Add(-48 * time.Hour),
+		NotAfter:     notAfter,
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA, tmpl.BasicConstraintsValid = true, true
+		tmpl.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	return testCert{
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+		cert:    cert,
+		key:     key,
+	}
+}
+
+// newCertServer starts a TLS server with cert, requiring a client
+// certificate signed by clientCA if it is set.
+func newCertServer(t *testing.T, cert testCert, clientCA *testCert) *httptest.Server {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	ts.TLS = &tls.Config{Certificates: []tls.Certificate{cert.tlsCert(t)}}
+	if clientCA != nil {
+		pool := x509.NewCertPool()
+		pool.AddCert(clientCA.cert)
+		ts.TLS.ClientAuth, ts.TLS.ClientCAs = tls.RequireAndVerifyClientCert, pool
+	}
+	ts.StartTLS()
+	return ts
+}
+
+func pingTLS(conf HTTPConfig) error {
+	c, err := NewHTTPClient(conf)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	_, _, err = c.Ping(0)
+	return err
+}
+
+func TestHTTPConfig_PinnedCert(t *testing.T) {
+	valid := time.Now().Add(24 * time.Hour)
+	ca := newTestCert(t, nil, valid)
+	leaf := newTestCert(t, &ca, valid)
+	ts := newCertServer(t, leaf, nil)
+	defer ts.Close()
+
+	other := newTestCert(t, nil, valid)
+	for _, tt := range []struct {
+		name string
+		conf HTTPConfig
+		err  string
+	}{
+		{name: "CA", conf: HTTPConfig{CACertPEM: ca.certPEM}},
+		{name: "system roots", conf: HTTPConfig{}, err: "x509"},
+		{name: "pinned leaf", conf: HTTPConfig{CACertPEM: ca.certPEM, PinnedCertSHA256: []string{hex.EncodeToString(leaf.spki())}}},
+		{name: "pinned CA", conf: HTTPConfig{CACertPEM: ca.certPEM, PinnedCertSHA256: []string{"sha256/" + base64.StdEncoding.EncodeToString(ca.spki())}}},
+		{name: "pin mismatch", conf: HTTPConfig{CACertPEM: ca.certPEM, PinnedCertSHA256: []string{hex.EncodeToString(other.spki())}}, err: "PinnedCertSHA256"},
+		{
+			name: "pin without verification",
+			conf: HTTPConfig{InsecureSkipVerify: true, PinnedCertSHA256: []string{hex.EncodeToString(leaf.spki())}},
+		},
+	} {
+		tt.conf.Addr = ts.URL
+		err := pingTLS(tt.conf)
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%s: unexpected error.  expected %v, actual %v", tt.name, nil, err)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("%s: unexpected error.  expected %v, actual %v", tt.name, tt.err, err)
+		}
+	}
+}
+
+func TestHTTPConfig_ExpiredCA(t *testing.T) {
+	expired := time.Now().Add(-time.Hour)
+	ca := newTestCert(t, nil, expired)
+	leaf := newTestCert(t, &ca, time.Now().Add(time.Hour))
+	ts := newCertServer(t, leaf, nil)
+	defer ts.Close()
+
+	err := pingTLS(HTTPConfig{Addr: ts.URL, CACertPEM: ca.certPEM})
+	if err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Errorf("unexpected error.  expected an expired certificate, actual %v", err)
+	}
+}
+
+func TestHTTPConfig_ClientCert(t *testing.T) {
+	valid := time.Now().Add(24 * time.Hour)
+	ca := newTestCert(t, nil, valid)
+	ts := newCertServer(t, newTestCert(t, &ca, valid), &ca)
+	defer ts.Close()
+
+	client := newTestCert(t, &ca, valid)
+	if err := pingTLS(HTTPConfig{Addr: ts.URL, CACertPEM: ca.certPEM, ClientCertPEM: client.certPEM, ClientKeyPEM: client.keyPEM}); err != nil {
+		t.Errorf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if err := pingTLS(HTTPConfig{Addr: ts.URL, CACertPEM: ca.certPEM}); err == nil {
+		t.Error("expected an error without a client certificate")
+	}
+}
+
+func TestTLSHelpers_Invalid(t *testing.T) {
+	cert := newTestCert(t, nil, time.Now().Add(time.Hour))
+	for _, tt := range []struct {
+		conf  HTTPConfig
+		field string
+	}{
+		{HTTPConfig{CACertPEM: []byte("not a certificate")}, "CACertPEM"},
+		{HTTPConfig{CACertFile: "/nonexistent/ca.pem"}, "CACertFile"},
+		{HTTPConfig{CACertPEM: cert.certPEM, CACertFile: "ca.pem"}, "CACertFile"},
+		{HTTPConfig{PinnedCertSHA256: []string{"abcd"}}, "PinnedCertSHA256"},
+		{HTTPConfig{ClientCertPEM: cert.certPEM}, "ClientCertPEM"},
+		{HTTPConfig{ClientCertPEM: cert.certPEM, ClientKeyPEM: []byte("bad key")}, "ClientCertPEM"},
+		{HTTPConfig{CACertPEM: cert.certPEM, TLSConfig: &tls.Config{}}, "TLSConfig"},
+	} {
+		tt.conf.Addr = "https://localhost:8086"
+		_, err := NewHTTPClient(tt.conf)
+		var ce *ConfigError
+		if !errors.As(err, &ce) || ce.Field != tt.field {
+			t.Errorf("unexpected error.  expected a %s *ConfigError, actual %v", tt.field, err)
+		}
+	}
+
+	_, err := NewTCPClient(TCPConfig{Addr: "localhost:8094", CACertPEM: []byte("not a certificate")})
+	var ce *ConfigError
+	if !errors.As(err, &ce) || ce.Field != "CACertPEM" {
+		t.Errorf("unexpected error.  expected a CACertPEM *ConfigError, actual %v", err)
+	}
+}
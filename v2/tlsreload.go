@@ -0,0 +1,124 @@
+package client
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"sync"
+)
+
+// TLSReloader is implemented by the HTTP client.
+type TLSReloader interface {
+	// ReloadTLS makes the connections opened from now on use config, as
+	// when the client certificate or the certificate authorities rotated,
+	// without creating a new client. The requests in progress finish on
+	// their connections, which are closed once they did, and the idle
+	// connections are closed. It fails for a client with its own
+	// HTTPConfig.Transport, whose TLS config is up to its owner. config is
+	// copied, and a config whose GetClientCertificate loads the current
+	// certificate needs no reloading, as it is called on every handshake.
+	ReloadTLS(config *tls.Config) error
+}
+
+// ReloadTLS replaces the TLS config of the connections of the client.
+func (c *client) ReloadTLS(config *tls.Config) error {
+	if c.tlsTransport == nil {
+		return errors.New("the TLS config of a custom Transport cannot be reloaded")
+	}
+	if config == nil {
+		return errors.New("nil TLS config")
+	}
+	c.tlsTransport.reload(config)
+	return nil
+}
+
+// reloadableTransport sends the requests of a client with the
+// *http.Transport of its current TLS config.
+type reloadableTransport struct {
+	mu      sync.Mutex
+	current *countedTransport
+
+	// retired are the transports replaced by reload. A connection can
+	// become idle right after the last request of its transport finished,
+	// so their idle connections are closed again on the next reload and by
+	// CloseIdleConnections.
+	retired []*countedTransport
+}
+
+// countedTransport is an *http.Transport along with its requests in
+// progress, so that its connections can be closed once it was replaced and
+// they finished.
+type countedTransport struct {
+	*http.Transport
+
+	// active is the number of requests in progress, and retired is set
+	// once the transport was replaced. They are guarded by the mu of the
+	// reloadableTransport.
+	active  int
+	retired bool
+}
+
+func newReloadableTransport(t *http.Transport) *reloadableTransport {
+	return &reloadableTransport{current: &countedTransport{Transport: t}}
+}
+
+func (t *reloadableTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	ct := t.current
+	ct.active++
+	t.mu.Unlock()
+
+	resp, err := ct.RoundTrip(req)
+	if err != nil {
+		t.done(ct)
+		return nil, err
+	}
+	resp.Body = &drainBody{ReadCloser: resp.Body, end: func() { t.done(ct) }}
+	return resp, nil
+}
+
+// done is called when a request sent with ct finished.
+func (t *reloadableTransport) done(ct *countedTransport) {
+	t.mu.Lock()
+	ct.active--
+	closeIdle := ct.retired && ct.active == 0
+	t.mu.Unlock()
+	if closeIdle {
+		ct.CloseIdleConnections()
+	}
+}
+
+// reload replaces the transport with one using config.
+func (t *reloadableTransport) reload(config *tls.Config) {
+	t.mu.Lock()
+	old := t.current
+	tr := old.Clone()
+	tr.TLSClientConfig = config.Clone()
+	t.current = &countedTransport{Transport: tr}
+	old.retired = true
+	closing := []*countedTransport{old}
+	retired := t.retired[:0]
+	for _, ct := range t.retired {
+		if ct.active > 0 {
+			retired = append(retired, ct)
+		} else {
+			closing = append(closing, ct)
+		}
+	}
+	t.retired = append(retired, old)
+	t.mu.Unlock()
+	for _, ct := range closing {
+		ct.CloseIdleConnections()
+	}
+}
+
+// CloseIdleConnections closes the idle connections of the current and
+// retired transports.
+func (t *reloadableTransport) CloseIdleConnections() {
+	t.mu.Lock()
+	transports := append([]*countedTransport{t.current}, t.retired...)
+	t.mu.Unlock()
+	for _, ct := range transports {
+		ct.CloseIdleConnections()
+	}
+}
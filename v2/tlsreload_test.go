@@ -0,0 +1,58 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient_ReloadTLS(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/write" {
+			started <- struct{}{}
+			<-release
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+	trusted := x509.NewCertPool()
+	trusted.AddCert(ts.Certificate())
+
+	// The client starts trusting the server, then is reloaded with a config
+	// that does not while a write is in progress.
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, TLSConfig: &tls.Config{RootCAs: trusted}})
+	defer c.Close()
+	done := make(chan error)
+	go func() { done <- c.Write(newTestBatch(t, 1)) }()
+	<-started
+
+	if err := c.(TLSReloader).ReloadTLS(&tls.Config{RootCAs: x509.NewCertPool()}); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if _, _, err := c.Ping(0); err == nil {
+		t.Errorf("expected the new connection to reject the certificate of the server")
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Errorf("unexpected error of the write in progress.  expected %v, actual %v", nil, err)
+	}
+
+	if err := c.(TLSReloader).ReloadTLS(&tls.Config{RootCAs: trusted}); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if _, _, err := c.Ping(0); err != nil {
+		t.Errorf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+}
+
+func TestClient_ReloadTLSCustomTransport(t *testing.T) {
+	c, _ := NewHTTPClient(HTTPConfig{Addr: "https://localhost:8086", Transport: http.DefaultTransport})
+	defer c.Close()
+	if err := c.(TLSReloader).ReloadTLS(&tls.Config{}); err == nil {
+		t.Error("expected an error for a custom transport")
+	}
+}
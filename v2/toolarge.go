@@ -0,0 +1,113 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// ErrPointTooLarge is matched by the error of a point that the server, or a
+// proxy before it, rejected with 413 Payload Too Large on its own, see
+// HTTPConfig.SplitTooLarge, and by a *PointTooLargeError.
+var ErrPointTooLarge = errors.New("point is too large for the server")
+
+// Is makes a *PointTooLargeError match ErrPointTooLarge.
+func (e *PointTooLargeError) Is(target error) bool { return target == ErrPointTooLarge }
+
+// SplitStatsCollector can be implemented by the StatsCollector of an HTTP
+// client with HTTPConfig.SplitTooLarge to learn the size of the writes its
+// server accepts.
+type SplitStatsCollector interface {
+	// WriteSplit is called after a write of points was rejected as too
+	// large and split, with the number of points of the largest fragment
+	// written, 0 if none was. As the fragments are halves, that size is a
+	// batch size the server is known to accept.
+	WriteSplit(points, written int)
+}
+
+// tooLarge reports whether err is a 413 Payload Too Large response.
+func tooLarge(err error) bool {
+	var er *ErrorResponse
+	return errors.As(err, &er) && er.StatusCode == http.StatusRequestEntityTooLarge
+}
+
+// writeSplitting writes bp and, if it is rejected as too large and the
+// client splits such writes, writes it again in fragments.
+func (c *client) writeSplitting(ctx context.Context, bp BatchPoints, ws *WriteStats) error {
+	err := c.writeContext(ctx, bp, nil, ws, nil)
+	if !c.splitTooLarge || !tooLarge(err) {
+		return err
+	}
+	s := &splitWrite{c: c, werr: &WriteError{}}
+	s.write(ctx, bp, err, ws)
+	if sc, ok := c.stats.(SplitStatsCollector); ok {
+		sc.WriteSplit(len(bp.Points()), s.largest)
+	}
+	if len(s.werr.Errs) > 0 {
+		return s.werr
+	}
+	return nil
+}
+
+// splitWrite is the state of a write split after a 413 response.
+type splitWrite struct {
+	c *client
+
+	// largest is the number of points of the largest fragment written,
+	// and werr counts the points and holds the errors of the fragments.
+	largest int
+	werr    *WriteError
+}
+
+// write writes the halves of bp, whose write failed with the 413 error err,
+// splitting again those rejected as too large. ws sums the statistics of
+// the fragments.
+func (s *splitWrite) write(ctx context.Context, bp BatchPoints, err error, ws *WriteStats) {
+	n := len(bp.Points())
+	switch {
+	case n <= 1:
+		s.fail(n, fmt.Errorf("%w: %v", ErrPointTooLarge, err))
+		return
+	case n/2 < s.c.minSplit:
+		s.fail(n, err)
+		return
+	}
+	halves, _ := bp.Split((n+1)/2, 0)
+	for _, half := range halves {
+		points := len(half.Points())
+		if ctx.Err() != nil {
+			s.fail(points, ctx.Err())
+			continue
+		}
+		var hs WriteStats
+		herr := s.c.writeContext(ctx, half, nil, &hs, nil)
+		addWriteStats(ws, hs)
+		switch {
+		case herr == nil:
+			s.werr.PointsWritten += points
+			if points > s.largest {
+				s.largest = points
+			}
+		case tooLarge(herr):
+			s.write(ctx, half, herr, ws)
+		default:
+			s.fail(points, herr)
+		}
+	}
+}
+
+// fail records the failure of a fragment of n points with err.
+func (s *splitWrite) fail(n int, err error) {
+	s.werr.PointsDropped += n
+	s.werr.Errs = append(s.werr.Errs, err)
+}
+
+// addWriteStats adds the statistics of a fragment of a write to ws.
+func addWriteStats(ws *WriteStats, fragment WriteStats) {
+	ws.ByteCount += fragment.ByteCount
+	ws.SerializeDuration += fragment.SerializeDuration
+	ws.NetworkDuration += fragment.NetworkDuration
+	ws.Retries += fragment.Retries
+	ws.StatusCode = fragment.StatusCode
+}
@@ -0,0 +1,122 @@
+package client
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// limitServer rejects the writes whose body is over limit bytes with 413,
+// and records the number of points of the others.
+type limitServer struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	written []int
+}
+
+func newLimitServer(limit int) *limitServer {
+	s := &limitServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		if len(b) > limit {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			return
+		}
+		s.mu.Lock()
+		s.written = append(s.written, strings.Count(string(b), "\n"))
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	return s
+}
+
+// splitStats records the calls to WriteSplit.
+type splitStats struct {
+	recordingStats
+	splits [][2]int
+}
+
+func (s *splitStats) WriteSplit(points, written int) {
+	s.splits = append(s.splits, [2]int{points, written})
+}
+
+// splitBatch returns a batch of n points, the lines of which take 20 bytes
+// but those of the indexes in big, which have a long string field.
+func splitBatch(n int, big ...int) BatchPoints {
+	bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db", Precision: "s"})
+	for i := 0; i < n; i++ {
+		fields := map[string]interface{}{"v": 1}
+		for _, b := range big {
+			if b == i {
+				fields["s"] = strings.Repeat("x", 100)
+			}
+		}
+		pt, _ := NewPoint("cpu", nil, fields, time.Unix(int64(1000000000+i), 0))
+		bp.AddPoint(pt)
+	}
+	return bp
+}
+
+func TestClient_SplitTooLarge(t *testing.T) {
+	// Lines are "cpu v=1i 1000000000\n", 20 bytes: 3 lines fit.
+	ts := newLimitServer(60)
+	defer ts.Close()
+	stats := &splitStats{}
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, SplitTooLarge: true, Stats: stats})
+	defer c.Close()
+
+	if err := c.Write(splitBatch(10)); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	// 10 is cut in 5 and 5, each cut in 3 and 2.
+	if exp := []int{3, 2, 3, 2}; !reflect.DeepEqual(exp, ts.written) {
+		t.Errorf("unexpected writes.  expected %v, actual %v", exp, ts.written)
+	}
+	if exp := [][2]int{{10, 3}}; len(stats.splits) != 1 || stats.splits[0] != exp[0] {
+		t.Errorf("unexpected splits.  expected %v, actual %v", exp, stats.splits)
+	}
+}
+
+func TestClient_SplitTooLarge_PointTooLarge(t *testing.T) {
+	ts := newLimitServer(60)
+	defer ts.Close()
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, SplitTooLarge: true})
+	defer c.Close()
+
+	err := c.Write(splitBatch(4, 1))
+	var werr *WriteError
+	if !errors.As(err, &werr) || !errors.Is(err, ErrPointTooLarge) {
+		t.Fatalf("unexpected error.  expected %v, actual %v", ErrPointTooLarge, err)
+	}
+	if werr.PointsWritten != 3 || werr.PointsDropped != 1 || len(werr.Errs) != 1 {
+		t.Errorf("unexpected error: %+v", werr)
+	}
+}
+
+func TestClient_SplitTooLarge_MinSplitSize(t *testing.T) {
+	ts := newLimitServer(60)
+	defer ts.Close()
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, SplitTooLarge: true, MinSplitSize: 3})
+	defer c.Close()
+
+	// The halves of 5 points are not cut below 3 points.
+	err := c.Write(splitBatch(10))
+	var werr *WriteError
+	if !errors.As(err, &werr) || werr.PointsDropped != 10 || len(werr.Errs) != 2 || !tooLarge(werr.Errs[0]) {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	// Without SplitTooLarge the 413 is returned as is.
+	c, _ = NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+	if err := c.Write(splitBatch(10)); !tooLarge(err) {
+		t.Errorf("unexpected error.  expected a 413, actual %v", err)
+	}
+}
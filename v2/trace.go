@@ -0,0 +1,101 @@
+package client
+
+import (
+	"context"
+	"errors"
+)
+
+// Tracer starts a span around every write, query and ping of the HTTP client,
+// for example to bridge them to OpenTelemetry without the client depending
+// on it. It is called from several goroutines at once.
+type Tracer interface {
+	// StartSpan starts a span for the operation op, one of SpanWrite,
+	// SpanQuery, SpanQueryChunked, SpanQueryStream and SpanPing, as a child
+	// of the span of ctx. The context returned is the one the requests are
+	// made with, so that an instrumented http.RoundTripper sees the span.
+	// end is called once with the outcome of the operation and its
+	// attributes, keyed by the SpanAttr constants.
+	StartSpan(ctx context.Context, op string) (_ context.Context, end func(err error, attrs map[string]interface{}))
+}
+
+// The operations traced by a Tracer.
+const (
+	// SpanWrite is a write of a batch, over all of its retries.
+	SpanWrite = "influxdb.write"
+
+	// SpanQuery is a query whose response is read at once.
+	SpanQuery = "influxdb.query"
+
+	// SpanQueryChunked is a query of QueryAsChunk, which ends when the last
+	// chunk was read, reading failed or the response was closed.
+	SpanQueryChunked = "influxdb.query_chunked"
+
+	// SpanQueryStream is a query of QueryStream, which ends like a
+	// SpanQueryChunked.
+	SpanQueryStream = "influxdb.query_stream"
+
+	// SpanPing is a ping.
+	SpanPing = "influxdb.ping"
+)
+
+// The attributes of the spans of a Tracer, set when known.
+const (
+	SpanAttrDatabase        = "db"          // string, writes and queries
+	SpanAttrRetentionPolicy = "rp"          // string, writes and queries
+	SpanAttrStatement       = "statement"   // string, queries
+	SpanAttrPoints          = "points"      // int, writes
+	SpanAttrBytes           = "bytes"       // int, encoded size of a write
+	SpanAttrStatusCode      = "status_code" // int, of the last response
+	SpanAttrRetries         = "retries"     // int, writes
+)
+
+// startSpan starts a span for op if the client has a Tracer. end is nil
+// otherwise.
+func (c *client) startSpan(ctx context.Context, op string) (context.Context, func(err error, attrs map[string]interface{})) {
+	if c.tracer == nil {
+		return ctx, nil
+	}
+	return c.tracer.StartSpan(ctx, op)
+}
+
+// writeSpanAttrs returns the attributes of the span of a write of bp.
+func writeSpanAttrs(bp BatchPoints, ws *WriteStats) map[string]interface{} {
+	attrs := map[string]interface{}{
+		SpanAttrDatabase:        bp.Database(),
+		SpanAttrRetentionPolicy: bp.RetentionPolicy(),
+		SpanAttrPoints:          ws.PointCount,
+		SpanAttrBytes:           ws.ByteCount,
+		SpanAttrRetries:         ws.Retries,
+	}
+	if ws.StatusCode != 0 {
+		attrs[SpanAttrStatusCode] = ws.StatusCode
+	}
+	return attrs
+}
+
+// querySpanAttrs returns the attributes of the span of q, answered with
+// status, or 0 if the server did not answer.
+func querySpanAttrs(q Query, status int) map[string]interface{} {
+	attrs := map[string]interface{}{
+		SpanAttrDatabase:        q.Database,
+		SpanAttrRetentionPolicy: q.RetentionPolicy,
+		SpanAttrStatement:       q.Command,
+	}
+	if status != 0 {
+		attrs[SpanAttrStatusCode] = status
+	}
+	return attrs
+}
+
+// spanStatus returns the status of the *ErrorResponse err, ok if err is nil,
+// or 0 if the server did not answer.
+func spanStatus(err error, ok int) int {
+	var er *ErrorResponse
+	switch {
+	case errors.As(err, &er):
+		return er.StatusCode
+	case err == nil:
+		return ok
+	}
+	return 0
+}
@@ -0,0 +1,195 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type spanKey struct{}
+
+type recordedSpan struct {
+	op    string
+	err   error
+	attrs map[string]interface{}
+	ended bool
+}
+
+// recordingTracer records the spans started, and marks their contexts so
+// that the requests made with them can be recognized.
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []*recordedSpan
+}
+
+func (tr *recordingTracer) StartSpan(ctx context.Context, op string) (context.Context, func(error, map[string]interface{})) {
+	s := &recordedSpan{op: op}
+	tr.mu.Lock()
+	tr.spans = append(tr.spans, s)
+	tr.mu.Unlock()
+	return context.WithValue(ctx, spanKey{}, op), func(err error, attrs map[string]interface{}) {
+		tr.mu.Lock()
+		defer tr.mu.Unlock()
+		if s.ended {
+			panic("span ended twice")
+		}
+		s.err, s.attrs, s.ended = err, attrs, true
+	}
+}
+
+func (tr *recordingTracer) last(t *testing.T, op string) *recordedSpan {
+	t.Helper()
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if len(tr.spans) == 0 || tr.spans[len(tr.spans)-1].op != op {
+		t.Fatalf("unexpected spans, expected one of %s last: %+v", op, tr.spans)
+	}
+	s := *tr.spans[len(tr.spans)-1]
+	return &s
+}
+
+// spanTransport fails requests whose context has no span.
+type spanTransport struct{}
+
+func (spanTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Context().Value(spanKey{}) == nil {
+		return nil, errors.New("request made without the span context")
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestTracer_Write(t *testing.T) {
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests++; requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	tr := &recordingTracer{}
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, Tracer: tr, Transport: spanTransport{}, MaxRetries: 1, RetryInterval: time.Millisecond})
+	defer c.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0", RetentionPolicy: "rp0"})
+	bp.AddPoints(newTestPoints(3))
+	if err := c.Write(bp); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	s := tr.last(t, SpanWrite)
+	if !s.ended || s.err != nil {
+		t.Errorf("unexpected span: %+v", s)
+	}
+	for k, exp := range map[string]interface{}{
+		SpanAttrDatabase:        "db0",
+		SpanAttrRetentionPolicy: "rp0",
+		SpanAttrPoints:          3,
+		SpanAttrRetries:         1,
+		SpanAttrStatusCode:      http.StatusNoContent,
+	} {
+		if s.attrs[k] != exp {
+			t.Errorf("unexpected %s.  expected %v, actual %v", k, exp, s.attrs[k])
+		}
+	}
+	if n, _ := s.attrs[SpanAttrBytes].(int); n <= 0 {
+		t.Errorf("unexpected bytes: %v", s.attrs[SpanAttrBytes])
+	}
+
+	if err := c.(StreamWriter).WriteStream(context.Background(), "db0", "", "", strings.NewReader("cpu v=1\ncpu v=2\n")); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	s = tr.last(t, SpanWrite)
+	if !s.ended || s.attrs[SpanAttrPoints] != 2 || s.attrs[SpanAttrBytes] != 16 || s.attrs[SpanAttrStatusCode] != http.StatusNoContent {
+		t.Errorf("unexpected span: %+v", s)
+	}
+}
+
+func TestTracer_Query(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Influxdb-Version", "1.8.10")
+		if r.FormValue("q") == "bad" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"error parsing query"}`)
+			return
+		}
+		fmt.Fprint(w, `{"results":[{"statement_id":0,"series":[{"name":"cpu","columns":["time","v"],"values":[[0,1]]}]}]}`+"\n")
+	}))
+	defer ts.Close()
+
+	tr := &recordingTracer{}
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, Tracer: tr, Transport: spanTransport{}})
+	defer c.Close()
+
+	if _, err := c.Query(NewQuery("SELECT v FROM cpu", "db0", "")); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	s := tr.last(t, SpanQuery)
+	if !s.ended || s.err != nil || s.attrs[SpanAttrStatement] != "SELECT v FROM cpu" || s.attrs[SpanAttrDatabase] != "db0" || s.attrs[SpanAttrStatusCode] != http.StatusOK {
+		t.Errorf("unexpected span: %+v", s)
+	}
+
+	resp, err := c.Query(NewQuery("bad", "db0", ""))
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	s = tr.last(t, SpanQuery)
+	if s.err == nil || s.err != resp.Error() || s.attrs[SpanAttrStatusCode] != http.StatusBadRequest {
+		t.Errorf("unexpected span: %+v", s)
+	}
+
+	// The span of a chunked query ends once the response was read.
+	cr, err := c.QueryAsChunk(NewQuery("SELECT v FROM cpu", "db0", ""))
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if s := tr.last(t, SpanQueryChunked); s.ended {
+		t.Errorf("unexpected span ended before the response was read: %+v", s)
+	}
+	for {
+		if _, err := cr.NextResponse(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+	}
+	cr.Close()
+	if s := tr.last(t, SpanQueryChunked); !s.ended || s.err != nil || s.attrs[SpanAttrStatusCode] != http.StatusOK {
+		t.Errorf("unexpected span: %+v", s)
+	}
+}
+
+func TestTracer_Ping(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	tr := &recordingTracer{}
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, Tracer: tr, Transport: spanTransport{}})
+	defer c.Close()
+
+	if _, _, err := c.Ping(0); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if s := tr.last(t, SpanPing); !s.ended || s.err != nil || s.attrs[SpanAttrStatusCode] != http.StatusNoContent {
+		t.Errorf("unexpected span: %+v", s)
+	}
+
+	ts.Close()
+	if _, _, err := c.Ping(0); err == nil {
+		t.Fatalf("unexpected error.  expected an error, actual %v", err)
+	}
+	if s := tr.last(t, SpanPing); s.err == nil || s.attrs[SpanAttrStatusCode] != nil {
+		t.Errorf("unexpected span: %+v", s)
+	}
+}
@@ -0,0 +1,558 @@
+package client
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+// DefaultTypeGuardSize is the default number of measurements whose field
+// types a TypeGuard keeps.
+const DefaultTypeGuardSize = 10000
+
+// TypePolicy is what a TypeGuard does with a field whose type is not the one
+// of the field in its measurement.
+type TypePolicy int
+
+const (
+	// TypeCoerce converts the value to the type of the field, such as an
+	// integer to a float or a string to a number it parses as. The point is
+	// rejected if the value does not convert.
+	TypeCoerce TypePolicy = iota
+
+	// TypeDropField removes the field from the point. The point is
+	// rejected if it has no field left.
+	TypeDropField
+
+	// TypeRejectPoint drops the point.
+	TypeRejectPoint
+)
+
+// TypeGuardConfig is the config data needed to create a TypeGuard.
+type TypeGuardConfig struct {
+	// Policy is what happens to a field with a conflicting type, defaults
+	// to TypeCoerce.
+	Policy TypePolicy
+
+	// MaxMeasurements is the number of measurements whose field types are
+	// kept, the least recently written ones being evicted first. Defaults
+	// to DefaultTypeGuardSize.
+	MaxMeasurements int
+
+	// OnConflict, if set, is called with a point and the conflict of one of
+	// its fields, before Policy is applied. It is called from Write and
+	// must not block for long.
+	OnConflict func(p *Point, conflict FieldTypeError)
+}
+
+// FieldTypeError is a field of a point whose type is not the one of the field
+// in its measurement.
+type FieldTypeError struct {
+	// Index is the index of the point in its batch.
+	Index int
+
+	Measurement string
+	Field       string
+
+	// Type is the type of the value of the point and Expected the type of
+	// the field, named as by SHOW FIELD KEYS, such as "integer".
+	Type     string
+	Expected string
+}
+
+func (e FieldTypeError) Error() string {
+	return fmt.Sprintf("field %s of %s is %s, expected %s", e.Field, e.Measurement, e.Type, e.Expected)
+}
+
+// TypeConflictError is returned by a TypeGuard for a batch of which some
+// points were rejected for the type of their fields. The other points were
+// written, unless Err is set.
+type TypeConflictError struct {
+	// Conflicts are the fields of the rejected points.
+	Conflicts []FieldTypeError
+
+	// Err is the error writing the other points failed with, or nil.
+	Err error
+}
+
+func (e *TypeConflictError) Error() string {
+	msgs := make([]string, len(e.Conflicts))
+	for i, c := range e.Conflicts {
+		msgs[i] = fmt.Sprintf("point %d: %v", c.Index, c)
+	}
+	msg := "field type conflict: " + strings.Join(msgs, "; ")
+	if e.Err != nil {
+		msg += "; write failed: " + e.Err.Error()
+	}
+	return msg
+}
+
+// Is reports whether target is ErrFieldTypeConflict.
+func (e *TypeConflictError) Is(target error) bool { return target == ErrFieldTypeConflict }
+
+// Unwrap returns the error writing the other points failed with.
+func (e *TypeConflictError) Unwrap() error { return e.Err }
+
+// TypeGuard is a Client that checks the type of the fields of the points it
+// writes against the types of the fields of their measurement, so that a
+// field type conflict is handled before the batch is sent rather than
+// failing its write. The types are learned from the batches written
+// successfully, or loaded by Refresh. Queries are sent as they are. To guard
+// the batches of a BatchingClient, make it write with a TypeGuard. TypeGuard
+// is safe for concurrent use if the wrapped client is.
+type TypeGuard struct {
+	c          Client
+	policy     TypePolicy
+	max        int
+	onConflict func(*Point, FieldTypeError)
+
+	mu           sync.Mutex
+	lru          *list.List
+	measurements map[string]*list.Element
+}
+
+// typeGuardEntry holds the field types of a measurement of a database.
+type typeGuardEntry struct {
+	key    string
+	fields map[string]models.FieldType
+}
+
+// NewTypeGuard returns a TypeGuard that writes with c. Closing the TypeGuard
+// does not close c.
+func NewTypeGuard(c Client, conf TypeGuardConfig) (*TypeGuard, error) {
+	switch conf.Policy {
+	case TypeCoerce, TypeDropField, TypeRejectPoint:
+	default:
+		return nil, &ConfigError{Field: "Policy", Reason: "unknown type policy"}
+	}
+	if conf.MaxMeasurements < 0 {
+		return nil, &ConfigError{Field: "MaxMeasurements", Reason: "must not be negative"}
+	}
+	if conf.MaxMeasurements == 0 {
+		conf.MaxMeasurements = DefaultTypeGuardSize
+	}
+	return &TypeGuard{
+		c:            c,
+		policy:       conf.Policy,
+		max:          conf.MaxMeasurements,
+		onConflict:   conf.OnConflict,
+		lru:          list.New(),
+		measurements: make(map[string]*list.Element),
+	}, nil
+}
+
+// typeGuardKey returns the key of the measurement of the database db.
+func typeGuardKey(db, measurement string) string {
+	return db + "\x00" + measurement
+}
+
+// FieldType returns the type of the field of measurement in the database db,
+// named as by SHOW FIELD KEYS, if it is known.
+func (g *TypeGuard) FieldType(db, measurement, field string) (string, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	e, ok := g.measurements[typeGuardKey(db, measurement)]
+	if !ok {
+		return "", false
+	}
+	typ, ok := e.Value.(*typeGuardEntry).fields[field]
+	if !ok {
+		return "", false
+	}
+	return fieldTypeName(typ), true
+}
+
+// Len returns the number of measurements whose field types are known.
+func (g *TypeGuard) Len() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.lru.Len()
+}
+
+// Refresh replaces the field types known for the database db with those
+// returned by SHOW FIELD KEYS. It can be called at startup to check the
+// first batches against the existing fields, and when the fields changed
+// other than through the TypeGuard, such as after a measurement was dropped.
+func (g *TypeGuard) Refresh(ctx context.Context, db string) error {
+	resp, err := queryStatement(ctx, g.c, db, "SHOW FIELD KEYS")
+	if err != nil {
+		return err
+	}
+
+	measurements := make(map[string]map[string]models.FieldType)
+	var names []string
+	for _, result := range resp.Results {
+		for _, row := range result.Series {
+			key, typ := -1, -1
+			for i, c := range row.Columns {
+				switch c {
+				case "fieldKey":
+					key = i
+				case "fieldType":
+					typ = i
+				}
+			}
+			if key < 0 || typ < 0 {
+				continue
+			}
+			fields, ok := measurements[row.Name]
+			if !ok {
+				fields = make(map[string]models.FieldType)
+				measurements[row.Name] = fields
+				names = append(names, row.Name)
+			}
+			for _, values := range row.Values {
+				if len(values) <= key || len(values) <= typ {
+					continue
+				}
+				name, _ := values[key].(string)
+				t, ok := parseFieldType(values[typ])
+				// A field can have a type per shard, the first one
+				// is kept.
+				if _, seen := fields[name]; ok && !seen {
+					fields[name] = t
+				}
+			}
+		}
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	prefix := db + "\x00"
+	for key, e := range g.measurements {
+		if strings.HasPrefix(key, prefix) {
+			g.lru.Remove(e)
+			delete(g.measurements, key)
+		}
+	}
+	for _, name := range names {
+		g.merge(typeGuardKey(db, name), measurements[name])
+	}
+	return nil
+}
+
+// Ping checks the status of the wrapped client.
+func (g *TypeGuard) Ping(timeout time.Duration) (time.Duration, string, error) {
+	return g.c.Ping(timeout)
+}
+
+// Write checks the fields of the points of bp, applies the policy to those
+// with a conflicting type, and writes the points left with the wrapped
+// client. bp is not changed, the points changed or rejected are written in
+// a new batch with the settings of bp. If points were rejected, the error
+// is a *TypeConflictError. The types of the fields of the points not known
+// yet are learned once the write succeeded.
+func (g *TypeGuard) Write(bp BatchPoints) error {
+	return g.WriteContext(context.Background(), bp)
+}
+
+// WriteContext is like Write, but the write is bound to ctx if the wrapped
+// client supports it.
+func (g *TypeGuard) WriteContext(ctx context.Context, bp BatchPoints) error {
+	if err := bp.Err(); err != nil {
+		return err
+	}
+	db := bp.Database()
+	points := bp.Points()
+
+	// Types first seen in the batch are checked against each other and
+	// only kept once the batch was written.
+	pending := make(map[string]map[string]models.FieldType)
+	var kept []*Point
+	var conflicts []FieldTypeError
+	changed := false
+	g.mu.Lock()
+	for i, p := range points {
+		if p == nil {
+			continue
+		}
+		q, rejected := g.check(db, i, p, pending)
+		if rejected != nil {
+			conflicts = append(conflicts, rejected...)
+			changed = true
+			continue
+		}
+		changed = changed || q != p
+		kept = append(kept, q)
+	}
+	g.mu.Unlock()
+
+	if changed {
+		conf := batchPointsConfig(bp)
+		// The points were already counted by the limiter of bp, and their
+		// size was checked against its limit.
+		conf.CardinalityLimiter = nil
+		conf.MaxBytes = 0
+		nbp, err := NewBatchPoints(conf)
+		if err != nil {
+			return err
+		}
+		if err := nbp.AddPoints(kept); err != nil {
+			return err
+		}
+		bp = nbp
+	}
+
+	var err error
+	if len(kept) > 0 || !changed {
+		if cc, ok := g.c.(ContextClient); ok {
+			err = cc.WriteContext(ctx, bp)
+		} else {
+			err = g.c.Write(bp)
+		}
+	}
+	if err == nil && len(pending) > 0 {
+		g.mu.Lock()
+		for key, fields := range pending {
+			g.merge(key, fields)
+		}
+		g.mu.Unlock()
+	}
+	if len(conflicts) > 0 {
+		return &TypeConflictError{Conflicts: conflicts, Err: err}
+	}
+	return err
+}
+
+// check returns p as changed by the policy for the fields with a conflicting
+// type, or the conflicts if the point is rejected. The types of the fields
+// not known are added to pending. g.mu must be held.
+func (g *TypeGuard) check(db string, index int, p *Point, pending map[string]map[string]models.FieldType) (*Point, []FieldTypeError) {
+	measurement := string(p.pt.Name())
+	key := typeGuardKey(db, measurement)
+	var known map[string]models.FieldType
+	if e, ok := g.measurements[key]; ok {
+		g.lru.MoveToFront(e)
+		known = e.Value.(*typeGuardEntry).fields
+	}
+
+	var conflicts []FieldTypeError
+	var learned []string
+	var learnedTypes []models.FieldType
+	nfields := 0
+	it := p.pt.FieldIterator()
+	for it.Next() {
+		nfields++
+		field, typ := string(it.FieldKey()), it.Type()
+		expected, ok := known[field]
+		if !ok {
+			expected, ok = pending[key][field]
+		}
+		if !ok {
+			learned = append(learned, field)
+			learnedTypes = append(learnedTypes, typ)
+			continue
+		}
+		if typ != expected {
+			conflicts = append(conflicts, FieldTypeError{
+				Index:       index,
+				Measurement: measurement,
+				Field:       field,
+				Type:        fieldTypeName(typ),
+				Expected:    fieldTypeName(expected),
+			})
+		}
+	}
+	if len(conflicts) > 0 {
+		if g.onConflict != nil {
+			for _, c := range conflicts {
+				g.onConflict(p, c)
+			}
+		}
+		if g.policy == TypeRejectPoint || (g.policy == TypeDropField && len(conflicts) == nfields) {
+			return nil, conflicts
+		}
+		fields, err := p.pt.Fields()
+		if err != nil {
+			return nil, conflicts
+		}
+		for _, c := range conflicts {
+			if g.policy == TypeDropField {
+				delete(fields, c.Field)
+				continue
+			}
+			expected, _ := parseFieldType(c.Expected)
+			v, ok := coerceField(fields[c.Field], expected)
+			if !ok {
+				return nil, conflicts
+			}
+			fields[c.Field] = v
+		}
+		pt, err := models.NewPoint(measurement, p.pt.Tags(), fields, p.pt.Time())
+		if err != nil {
+			return nil, conflicts
+		}
+		p = &Point{pt: pt}
+	}
+
+	for i, field := range learned {
+		fields, ok := pending[key]
+		if !ok {
+			fields = make(map[string]models.FieldType)
+			pending[key] = fields
+		}
+		fields[field] = learnedTypes[i]
+	}
+	return p, nil
+}
+
+// merge adds fields to the types known for the measurement of key, evicting
+// the least recently used measurement if there are too many. g.mu must be
+// held.
+func (g *TypeGuard) merge(key string, fields map[string]models.FieldType) {
+	if e, ok := g.measurements[key]; ok {
+		g.lru.MoveToFront(e)
+		known := e.Value.(*typeGuardEntry).fields
+		for field, typ := range fields {
+			if _, ok := known[field]; !ok {
+				known[field] = typ
+			}
+		}
+		return
+	}
+	entry := &typeGuardEntry{key: key, fields: make(map[string]models.FieldType, len(fields))}
+	for field, typ := range fields {
+		entry.fields[field] = typ
+	}
+	g.measurements[key] = g.lru.PushFront(entry)
+	for g.lru.Len() > g.max {
+		oldest := g.lru.Back()
+		g.lru.Remove(oldest)
+		delete(g.measurements, oldest.Value.(*typeGuardEntry).key)
+	}
+}
+
+// Query sends q with the wrapped client.
+func (g *TypeGuard) Query(q Query) (*Response, error) {
+	return g.c.Query(q)
+}
+
+// QueryContext sends q with the wrapped client, bound to ctx if it supports
+// it.
+func (g *TypeGuard) QueryContext(ctx context.Context, q Query) (*Response, error) {
+	if cc, ok := g.c.(ContextClient); ok {
+		return cc.QueryContext(ctx, q)
+	}
+	return g.c.Query(q)
+}
+
+// QueryAsChunk sends q with the wrapped client.
+func (g *TypeGuard) QueryAsChunk(q Query) (*ChunkedResponse, error) {
+	return g.c.QueryAsChunk(q)
+}
+
+// QueryAsChunkContext sends q with the wrapped client, bound to ctx if it
+// supports it.
+func (g *TypeGuard) QueryAsChunkContext(ctx context.Context, q Query) (*ChunkedResponse, error) {
+	if cc, ok := g.c.(ContextClient); ok {
+		return cc.QueryAsChunkContext(ctx, q)
+	}
+	return g.c.QueryAsChunk(q)
+}
+
+// Close does nothing, the wrapped client is left open.
+func (g *TypeGuard) Close() error {
+	return nil
+}
+
+// fieldTypeName returns the name of typ as returned by SHOW FIELD KEYS.
+func fieldTypeName(typ models.FieldType) string {
+	switch typ {
+	case models.Float:
+		return "float"
+	case models.Integer:
+		return "integer"
+	case models.Unsigned:
+		return "unsigned"
+	case models.String:
+		return "string"
+	case models.Boolean:
+		return "boolean"
+	}
+	return "unknown"
+}
+
+// parseFieldType returns the type named v by SHOW FIELD KEYS.
+func parseFieldType(v interface{}) (models.FieldType, bool) {
+	s, _ := v.(string)
+	switch s {
+	case "float":
+		return models.Float, true
+	case "integer":
+		return models.Integer, true
+	case "unsigned":
+		return models.Unsigned, true
+	case "string":
+		return models.String, true
+	case "boolean":
+		return models.Boolean, true
+	}
+	return models.Empty, false
+}
+
+// coerceField returns the field value v converted to the type typ, and
+// whether it converts: numbers convert to each other as long as the value is
+// kept, strings parse as numbers and booleans, and every value formats as a
+// string. Booleans and numbers do not convert to each other.
+func coerceField(v interface{}, typ models.FieldType) (interface{}, bool) {
+	switch typ {
+	case models.Float:
+		switch v := v.(type) {
+		case int64:
+			return float64(v), true
+		case uint64:
+			return float64(v), true
+		case string:
+			f, err := strconv.ParseFloat(v, 64)
+			return f, err == nil && !math.IsNaN(f) && !math.IsInf(f, 0)
+		}
+	case models.Integer:
+		switch v := v.(type) {
+		case float64:
+			// The upper bound is excluded, as float64(math.MaxInt64)
+			// is 2^63.
+			ok := v == math.Trunc(v) && v >= math.MinInt64 && v < math.MaxInt64
+			return int64(v), ok
+		case uint64:
+			return int64(v), v <= math.MaxInt64
+		case string:
+			i, err := strconv.ParseInt(v, 10, 64)
+			return i, err == nil
+		}
+	case models.Unsigned:
+		switch v := v.(type) {
+		case float64:
+			ok := v == math.Trunc(v) && v >= 0 && v < math.MaxUint64
+			return uint64(v), ok
+		case int64:
+			return uint64(v), v >= 0
+		case string:
+			u, err := strconv.ParseUint(v, 10, 64)
+			return u, err == nil
+		}
+	case models.Boolean:
+		if s, ok := v.(string); ok {
+			b, err := strconv.ParseBool(s)
+			return b, err == nil
+		}
+	case models.String:
+		switch v := v.(type) {
+		case float64:
+			return strconv.FormatFloat(v, 'g', -1, 64), true
+		case int64:
+			return strconv.FormatInt(v, 10), true
+		case uint64:
+			return strconv.FormatUint(v, 10), true
+		case bool:
+			return strconv.FormatBool(v), true
+		}
+	}
+	return nil, false
+}
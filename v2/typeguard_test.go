@@ -0,0 +1,280 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+// showRecorder records the batches written, and sends the queries with c.
+type showRecorder struct {
+	*batchRecorder
+	c Client
+}
+
+func (r showRecorder) Query(q Query) (*Response, error) { return r.c.Query(q) }
+
+// newTypeGuardBatch returns a batch of db0 holding a point per fields, all of
+// the measurement name.
+func newTypeGuardBatch(t *testing.T, name string, fields ...map[string]interface{}) BatchPoints {
+	t.Helper()
+	bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0"})
+	for i, f := range fields {
+		p, err := NewPoint(name, nil, f, time.Unix(int64(i), 0))
+		if err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+		bp.AddPoint(p)
+	}
+	return bp
+}
+
+func TestCoerceField(t *testing.T) {
+	for _, tt := range []struct {
+		v   interface{}
+		typ models.FieldType
+		exp interface{}
+		ok  bool
+	}{
+		{int64(42), models.Float, float64(42), true},
+		{uint64(42), models.Float, float64(42), true},
+		{"4.5", models.Float, 4.5, true},
+		{"NaN", models.Float, nil, false},
+		{"abc", models.Float, nil, false},
+		{true, models.Float, nil, false},
+
+		{float64(42), models.Integer, int64(42), true},
+		{4.5, models.Integer, nil, false},
+		{math.Pow(2, 63), models.Integer, nil, false},
+		{uint64(42), models.Integer, int64(42), true},
+		{uint64(math.MaxUint64), models.Integer, nil, false},
+		{"42", models.Integer, int64(42), true},
+		{"4.5", models.Integer, nil, false},
+		{false, models.Integer, nil, false},
+
+		{float64(42), models.Unsigned, uint64(42), true},
+		{float64(-1), models.Unsigned, nil, false},
+		{int64(42), models.Unsigned, uint64(42), true},
+		{int64(-1), models.Unsigned, nil, false},
+		{"42", models.Unsigned, uint64(42), true},
+		{"-42", models.Unsigned, nil, false},
+
+		{"true", models.Boolean, true, true},
+		{"F", models.Boolean, false, true},
+		{"yes", models.Boolean, nil, false},
+		{int64(1), models.Boolean, nil, false},
+		{float64(0), models.Boolean, nil, false},
+
+		{4.5, models.String, "4.5", true},
+		{int64(-42), models.String, "-42", true},
+		{uint64(42), models.String, "42", true},
+		{true, models.String, "true", true},
+	} {
+		v, ok := coerceField(tt.v, tt.typ)
+		if ok != tt.ok || (ok && v != tt.exp) {
+			t.Errorf("unexpected coercion of %T %v to %s.  expected %v (%v), actual %v (%v)", tt.v, tt.v, fieldTypeName(tt.typ), tt.exp, tt.ok, v, ok)
+		}
+	}
+}
+
+func TestTypeGuard_Refresh(t *testing.T) {
+	series := map[string][]models.Row{
+		"SHOW FIELD KEYS": {
+			{Name: "cpu", Columns: []string{"fieldKey", "fieldType"}, Values: [][]interface{}{{"idle", "float"}, {"count", "integer"}}},
+			{Name: "mem", Columns: []string{"fieldKey", "fieldType"}, Values: [][]interface{}{{"used", "integer"}, {"used", "float"}}},
+		},
+	}
+	ts, statements := newShowServer(t, series)
+	defer ts.Close()
+
+	hc, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer hc.Close()
+	r := &batchRecorder{}
+	g, err := NewTypeGuard(showRecorder{r, hc}, TypeGuardConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if err := g.Refresh(context.Background(), "db0"); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if exp := []string{"SHOW FIELD KEYS"}; !reflect.DeepEqual(*statements, exp) {
+		t.Errorf("unexpected statements.  expected %q, actual %q", exp, *statements)
+	}
+	for _, tt := range [][3]string{{"cpu", "idle", "float"}, {"cpu", "count", "integer"}, {"mem", "used", "integer"}} {
+		if typ, ok := g.FieldType("db0", tt[0], tt[1]); !ok || typ != tt[2] {
+			t.Errorf("unexpected type of %s %s.  expected %s, actual %q", tt[0], tt[1], tt[2], typ)
+		}
+	}
+
+	// The points of the first batch are checked against the types loaded.
+	bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db0", Precision: "s"})
+	p0, _ := NewPoint("cpu", map[string]string{"host": "a"}, map[string]interface{}{"idle": int64(98), "count": "42"}, time.Unix(1, 0))
+	p1, _ := NewPoint("mem", nil, map[string]interface{}{"used": 2.5}, time.Unix(2, 0))
+	p2, _ := NewPoint("mem", nil, map[string]interface{}{"used": int64(3)}, time.Unix(3, 0))
+	bp.AddPoints([]*Point{p0, p1, p2})
+	err = g.Write(bp)
+	var tce *TypeConflictError
+	if !errors.As(err, &tce) || !errors.Is(err, ErrFieldTypeConflict) {
+		t.Fatalf("unexpected error.  expected a *TypeConflictError, actual %v", err)
+	}
+	exp := []FieldTypeError{{Index: 1, Measurement: "mem", Field: "used", Type: "float", Expected: "integer"}}
+	if !reflect.DeepEqual(tce.Conflicts, exp) || tce.Err != nil {
+		t.Errorf("unexpected conflicts.  expected %v, actual %v (%v)", exp, tce.Conflicts, tce.Err)
+	}
+	if len(r.batches) != 1 || len(r.batches[0]) != 2 {
+		t.Fatalf("unexpected batches: %v", r.batches)
+	}
+	if s, exp := r.batches[0][0].String(), `cpu,host=a count=42i,idle=98 1000000000`; s != exp {
+		t.Errorf("unexpected coerced point.  expected %s, actual %s", exp, s)
+	}
+	if r.batches[0][1] != p2 {
+		t.Errorf("unexpected point.  expected %v, actual %v", p2, r.batches[0][1])
+	}
+	if len(bp.Points()) != 3 || bp.Points()[0] != p0 {
+		t.Error("expected the batch to be left as is")
+	}
+
+	// Refresh forgets the measurements that are gone.
+	series["SHOW FIELD KEYS"] = series["SHOW FIELD KEYS"][:1]
+	if err := g.Refresh(context.Background(), "db0"); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if typ, ok := g.FieldType("db0", "mem", "used"); ok {
+		t.Errorf("unexpected type of mem used.  expected none, actual %s", typ)
+	}
+	if g.Len() != 1 {
+		t.Errorf("unexpected number of measurements.  expected %v, actual %v", 1, g.Len())
+	}
+}
+
+func TestTypeGuard_Learn(t *testing.T) {
+	r := &batchRecorder{}
+	g, _ := NewTypeGuard(r, TypeGuardConfig{})
+
+	// The types first seen in a batch are checked within it.
+	bp := newTypeGuardBatch(t, "cpu", map[string]interface{}{"value": int64(1)}, map[string]interface{}{"value": float64(2)})
+	if err := g.Write(bp); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if s := r.batches[0][1].String(); s != "cpu value=2i 1000000000" {
+		t.Errorf("unexpected coerced point: %s", s)
+	}
+	if typ, ok := g.FieldType("db0", "cpu", "value"); !ok || typ != "integer" {
+		t.Errorf("unexpected type.  expected %s, actual %q", "integer", typ)
+	}
+	if _, ok := g.FieldType("db1", "cpu", "value"); ok {
+		t.Error("expected the types to be kept per database")
+	}
+
+	// The types of a batch whose write failed are not learned.
+	r.err = errors.New("write failed")
+	if err := g.Write(newTypeGuardBatch(t, "mem", map[string]interface{}{"used": 1.5})); err != r.err {
+		t.Errorf("unexpected error.  expected %v, actual %v", r.err, err)
+	}
+	if _, ok := g.FieldType("db0", "mem", "used"); ok {
+		t.Error("expected the types of a failed write not to be learned")
+	}
+
+	// The write error of the points left is kept along with the conflicts.
+	err := g.Write(newTypeGuardBatch(t, "cpu", map[string]interface{}{"value": true}, map[string]interface{}{"value": int64(3)}))
+	var tce *TypeConflictError
+	if !errors.As(err, &tce) || len(tce.Conflicts) != 1 || !errors.Is(err, r.err) {
+		t.Errorf("unexpected error.  expected a *TypeConflictError wrapping %v, actual %v", r.err, err)
+	}
+}
+
+func TestTypeGuard_Policies(t *testing.T) {
+	for _, tt := range []struct {
+		policy   TypePolicy
+		points   []string
+		rejected []int
+	}{
+		{TypeCoerce, []string{"cpu other=1i,value=2i 0", "cpu value=4i 3000000000"}, []int{1, 2}},
+		{TypeDropField, []string{"cpu other=1i 0", "cpu value=4i 3000000000"}, []int{1, 2}},
+		{TypeRejectPoint, []string{"cpu value=4i 3000000000"}, []int{0, 1, 2}},
+	} {
+		r := &batchRecorder{}
+		var conflicts int
+		g, _ := NewTypeGuard(r, TypeGuardConfig{
+			Policy:     tt.policy,
+			OnConflict: func(p *Point, conflict FieldTypeError) { conflicts++ },
+		})
+		if err := g.Write(newTypeGuardBatch(t, "cpu", map[string]interface{}{"value": int64(0)})); err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+		r.batches = nil
+
+		err := g.Write(newTypeGuardBatch(t, "cpu",
+			map[string]interface{}{"value": "2", "other": int64(1)},
+			map[string]interface{}{"value": "two"},
+			map[string]interface{}{"value": true},
+			map[string]interface{}{"value": int64(4)},
+		))
+		var tce *TypeConflictError
+		if !errors.As(err, &tce) {
+			t.Fatalf("unexpected error.  expected a *TypeConflictError, actual %v", err)
+		}
+		var rejected []int
+		for _, c := range tce.Conflicts {
+			rejected = append(rejected, c.Index)
+		}
+		if !equalInts(rejected, tt.rejected) {
+			t.Errorf("unexpected rejected points for policy %d.  expected %v, actual %v", tt.policy, tt.rejected, rejected)
+		}
+		if conflicts != 3 {
+			t.Errorf("unexpected number of conflicts for policy %d.  expected %v, actual %v", tt.policy, 3, conflicts)
+		}
+		var written []string
+		if len(r.batches) == 1 {
+			for _, p := range r.batches[0] {
+				written = append(written, p.String())
+			}
+		}
+		if !reflect.DeepEqual(written, tt.points) {
+			t.Errorf("unexpected points for policy %d.  expected %q, actual %q", tt.policy, tt.points, written)
+		}
+	}
+
+	// A batch of rejected points only is not written.
+	r := &batchRecorder{}
+	g, _ := NewTypeGuard(r, TypeGuardConfig{Policy: TypeRejectPoint})
+	g.Write(newTypeGuardBatch(t, "cpu", map[string]interface{}{"value": int64(0)}))
+	if err := g.Write(newTypeGuardBatch(t, "cpu", map[string]interface{}{"value": 1.5})); !errors.Is(err, ErrFieldTypeConflict) {
+		t.Errorf("unexpected error.  expected %v, actual %v", ErrFieldTypeConflict, err)
+	}
+	if len(r.batches) != 1 {
+		t.Errorf("unexpected number of batches.  expected %v, actual %v", 1, len(r.batches))
+	}
+}
+
+func TestTypeGuard_Evict(t *testing.T) {
+	g, _ := NewTypeGuard(&batchRecorder{}, TypeGuardConfig{MaxMeasurements: 2})
+	for _, name := range []string{"m0", "m1", "m0", "m2"} {
+		if err := g.Write(newTypeGuardBatch(t, name, map[string]interface{}{"value": 1.0})); err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+	}
+	if g.Len() != 2 {
+		t.Errorf("unexpected number of measurements.  expected %v, actual %v", 2, g.Len())
+	}
+	for name, exp := range map[string]bool{"m0": true, "m1": false, "m2": true} {
+		if _, ok := g.FieldType("db0", name, "value"); ok != exp {
+			t.Errorf("unexpected type of %s kept.  expected %v, actual %v", name, exp, ok)
+		}
+	}
+}
+
+func TestNewTypeGuard_Config(t *testing.T) {
+	var ce *ConfigError
+	if _, err := NewTypeGuard(&batchRecorder{}, TypeGuardConfig{Policy: 3}); !errors.As(err, &ce) || ce.Field != "Policy" {
+		t.Errorf("unexpected error.  expected a ConfigError for Policy, actual %v", err)
+	}
+	if _, err := NewTypeGuard(&batchRecorder{}, TypeGuardConfig{MaxMeasurements: -1}); !errors.As(err, &ce) || ce.Field != "MaxMeasurements" {
+		t.Errorf("unexpected error.  expected a ConfigError for MaxMeasurements, actual %v", err)
+	}
+}
@@ -1,16 +1,24 @@
 package client
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net"
+	"syscall"
 	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
 )
 
 const (
 	// UDPPayloadSize is a reasonable default payload size for UDP packets that
 	// could be travelling over the internet.
 	UDPPayloadSize = 512
+
+	// MaxUDPPayloadSize is the largest payload a UDP datagram can carry.
+	MaxUDPPayloadSize = 65507
 )
 
 // UDPConfig is the config data needed to create a UDP Client.
@@ -19,96 +27,404 @@ type UDPConfig struct {
 	// or "[ipv6-host%zone]:port".
 	Addr string
 
+	// Addrs, in place of Addr, are several addresses to spread the
+	// datagrams over, as selected by Routing. An address that cannot be
+	// resolved or fails a send is taken out of rotation, and probed again
+	// every RetryInterval. The client implements UDPEndpointsClient.
+	Addrs []string
+
+	// Routing selects the address of each datagram when Addrs is set,
+	// defaults to UDPRoundRobin.
+	Routing UDPRouting
+
+	// RetryInterval is how often the addresses out of rotation are probed,
+	// defaults to DefaultUDPRetryInterval.
+	RetryInterval time.Duration
+
 	// PayloadSize is the maximum size of a UDP client message, optional
-	// Tune this based on your network. Defaults to UDPPayloadSize.
+	// Tune this based on your network. Defaults to UDPPayloadSize, must be
+	// between MinPayloadSize and MaxUDPPayloadSize.
 	PayloadSize int
+
+	// Stats, if set, is told about every write.
+	Stats StatsCollector
+
+	// Logger, if set, is told about datagrams that could not be sent.
+	// Nothing is logged by default.
+	Logger Logger
+
+	// ValidateRawWrites makes WriteRawBytes parse the line protocol it is
+	// given and reject it if a line is invalid.
+	ValidateRawWrites bool
+
+	// ValidatePoints makes Write validate the points of a batch with
+	// BatchPoints.Validate and reject the batch if one is invalid.
+	ValidatePoints bool
+
+	// RateLimit, if set, limits the points and bytes written per second.
+	// The budget is taken for every payload sent.
+	RateLimit *RateLimit
+
+	// Clock, if set, is the clock of the RateLimit, defaults to the system
+	// clock.
+	Clock Clock
+
+	// WriteTimeout bounds the time spent sending each datagram, as a send
+	// blocks while the socket buffer is full. A datagram not sent in time
+	// fails with a *WriteTimeoutError. Defaults to no timeout.
+	WriteTimeout time.Duration
+
+	// ProbeTimeout, if set, makes NewUDPClient send an empty datagram and
+	// wait that long for the host to reject it, so that an address nothing
+	// listens on fails right away instead of at a later write. Only hosts
+	// answering with ICMP port unreachable messages are detected. With Addrs
+	// the addresses out of rotation are probed the same way.
+	ProbeTimeout time.Duration
+
+	// DatagramInterval, if set, is the pause before every datagram of a
+	// write but the first, so that a burst of datagrams does not overflow
+	// the socket buffer of the server, which drops them silently. Defaults
+	// to no pause.
+	DatagramInterval time.Duration
+
+	// MaxDatagramsPerWrite, if set, makes the writes of points that would
+	// need more datagrams fail with a *BatchTooLargeError before any of
+	// them is sent. It cannot be used with UDPRouteBySeries.
+	MaxDatagramsPerWrite int
+
+	// DrainTimeout is how long Close waits for the writes in progress to
+	// finish. Defaults to DefaultDrainTimeout.
+	DrainTimeout time.Duration
+
+	// DumpPayload, if set, is called with every datagram before it is sent,
+	// for debugging. b is only valid until it returns.
+	DumpPayload func(b []byte)
+
+	// ExpectedPrecision, if set, is the precision the receiver parses the
+	// timestamps of the datagrams in, such as the precision setting of an
+	// InfluxDB UDP listener: one of ns, u, ms, s, m or h. The timestamps of
+	// the points written are converted to it, rounded if their precision is
+	// finer, rather than sent in nanoseconds. The points are not modified.
+	// The lines of raw writes are sent as they are.
+	ExpectedPrecision string
+}
+
+// ErrBatchTooLarge is matched by a *BatchTooLargeError with errors.Is.
+var ErrBatchTooLarge = errors.New("batch needs too many datagrams")
+
+// BatchTooLargeError is returned by the UDP client for a write of points that
+// needs more datagrams than UDPConfig.MaxDatagramsPerWrite. None of the
+// points were sent.
+type BatchTooLargeError struct {
+	// Datagrams is the number of datagrams the points need, and
+	// MaxDatagrams the configured limit.
+	Datagrams    int
+	MaxDatagrams int
+
+	// Points is the number of points at the start of the write that fit in
+	// MaxDatagrams datagrams, for the caller to write them on their own.
+	Points int
+}
+
+func (e *BatchTooLargeError) Error() string {
+	return fmt.Sprintf("write needs %d UDP datagrams, over the limit of %d: only the first %d points fit", e.Datagrams, e.MaxDatagrams, e.Points)
 }
 
+func (e *BatchTooLargeError) Is(target error) bool { return target == ErrBatchTooLarge }
+
+// checkDatagrams returns a *BatchTooLargeError if the n points returned by at
+// need more than max payloads of payloadSize bytes. A max of 0 is no limit.
+func checkDatagrams(max, n int, at func(i int) models.Point, precision, timePrecision string, payloadSize int) error {
+	if max <= 0 {
+		return nil
+	}
+	if count, fit := countPayloads(n, at, precision, timePrecision, payloadSize, max); count > max {
+		return &BatchTooLargeError{Datagrams: count, MaxDatagrams: max, Points: fit}
+	}
+	return nil
+}
+
+// pace waits for interval before every datagram of a write but the first,
+// counting them in sent.
+func pace(ctx context.Context, interval time.Duration, sent *int) error {
+	if interval > 0 && *sent > 0 {
+		if err := sleepContext(ctx, interval); err != nil {
+			return err
+		}
+	}
+	*sent++
+	return nil
+}
+
+// PreviousDatagramError is returned by a write of the UDP client when the
+// host rejected an earlier datagram, of this write or a previous one. Such
+// rejections, ICMP port unreachable messages, only surface as "connection
+// refused" on the next send. The datagram being sent is sent again, so
+// unless the write also fails with another error, the points of the write
+// returning it were sent.
+type PreviousDatagramError struct {
+	Err error
+}
+
+func (e *PreviousDatagramError) Error() string {
+	return fmt.Sprintf("an earlier UDP datagram was rejected: %v", e.Err)
+}
+
+func (e *PreviousDatagramError) Unwrap() error { return e.Err }
+
 // NewUDPClient returns a client interface for writing to an InfluxDB UDP
 // service from the given config.
 func NewUDPClient(conf UDPConfig) (Client, error) {
-	var udpAddr *net.UDPAddr
-	udpAddr, err := net.ResolveUDPAddr("udp", conf.Addr)
+	if conf.Addr == "" && len(conf.Addrs) == 0 {
+		return nil, &ConfigError{Field: "Addr", Reason: "no address given"}
+	}
+	if conf.Addr != "" && len(conf.Addrs) > 0 {
+		return nil, &ConfigError{Field: "Addrs", Reason: "cannot be used together with Addr"}
+	}
+	if err := validatePayloadSize(conf.PayloadSize, MaxUDPPayloadSize); err != nil {
+		return nil, err
+	}
+	if conf.DatagramInterval < 0 {
+		return nil, &ConfigError{Field: "DatagramInterval", Reason: "must not be negative"}
+	}
+	if conf.MaxDatagramsPerWrite < 0 {
+		return nil, &ConfigError{Field: "MaxDatagramsPerWrite", Reason: "must not be negative"}
+	}
+	if conf.MaxDatagramsPerWrite > 0 && len(conf.Addrs) > 0 && conf.Routing == UDPRouteBySeries {
+		return nil, &ConfigError{Field: "MaxDatagramsPerWrite", Reason: "cannot be used with UDPRouteBySeries"}
+	}
+	if conf.DrainTimeout < 0 {
+		return nil, &ConfigError{Field: "DrainTimeout", Reason: "must not be negative"}
+	}
+	timePrecision, err := expectedPrecision(conf.ExpectedPrecision)
+	if err != nil {
+		return nil, err
+	}
+	if len(conf.Addrs) > 0 {
+		limiter, err := newRateLimiter(conf.RateLimit, conf.Clock)
+		if err != nil {
+			return nil, err
+		}
+		payloadSize := conf.PayloadSize
+		if payloadSize == 0 {
+			payloadSize = UDPPayloadSize
+		}
+		return newUDPPool(conf, payloadSize, timePrecision, limiter)
+	}
+
+	addr, err := net.ResolveUDPAddr("udp", conf.Addr)
 	if err != nil {
 		return nil, err
 	}
 
-	conn, err := net.DialUDP("udp", nil, udpAddr)
+	conn, err := net.DialUDP("udp", nil, addr)
 	if err != nil {
 		return nil, err
 	}
+	if conf.ProbeTimeout > 0 {
+		if err := probeUDP(conn, conf.ProbeTimeout); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("probing %s: %w", addr, err)
+		}
+	}
 
 	payloadSize := conf.PayloadSize
 	if payloadSize == 0 {
 		payloadSize = UDPPayloadSize
 	}
 
+	limiter, err := newRateLimiter(conf.RateLimit, conf.Clock)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	logf(conf.Logger, "influxdb: sending UDP datagrams to %s", addr)
 	return &udpclient{
-		conn:        conn,
-		payloadSize: payloadSize,
+		conn:           conn,
+		payloadSize:    payloadSize,
+		timePrecision:  timePrecision,
+		stats:          conf.Stats,
+		logger:         conf.Logger,
+		validateRaw:    conf.ValidateRawWrites,
+		validatePoints: conf.ValidatePoints,
+		limiter:        limiter,
+		writeTimeout:   conf.WriteTimeout,
+		interval:       conf.DatagramInterval,
+		maxDatagrams:   conf.MaxDatagramsPerWrite,
+		drain:          drainer{timeout: conf.DrainTimeout},
+		dumpPayload:    conf.DumpPayload,
 	}, nil
 }
 
-// Close releases the udpclient's resources.
+// probeUDP sends an empty datagram on conn and returns the error of a
+// rejection received within timeout.
+func probeUDP(conn *net.UDPConn, timeout time.Duration) error {
+	if _, err := conn.Write(nil); err != nil {
+		return err
+	}
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	defer conn.SetReadDeadline(time.Time{})
+	_, err := conn.Read(make([]byte, 1))
+	var netErr net.Error
+	if err == nil || errors.As(err, &netErr) && netErr.Timeout() {
+		// Nothing rejected the datagram, or something answered it.
+		return nil
+	}
+	return err
+}
+
+// Close makes the writes that follow fail with ErrClientClosed, waits up to
+// the DrainTimeout for those in progress to finish and closes the connection,
+// or leaves that to the last write if some are still in progress by then.
 func (uc *udpclient) Close() error {
-	return uc.conn.Close()
+	return uc.drain.close(uc.conn.Close)
 }
 
 type udpclient struct {
-	conn        io.WriteCloser
-	payloadSize int
+	conn           io.WriteCloser
+	payloadSize    int
+	timePrecision  string
+	bufs           payloadBuffers
+	stats          StatsCollector
+	logger         Logger
+	validateRaw    bool
+	validatePoints bool
+	limiter        *rateLimiter
+	writeTimeout   time.Duration
+	interval       time.Duration
+	maxDatagrams   int
+	drain          drainer
+	dumpPayload    func(b []byte)
 }
 
 func (uc *udpclient) Write(bp BatchPoints) error {
-	var b = make([]byte, 0, uc.payloadSize) // initial buffer size, it will grow as needed
-	var d, _ = time.ParseDuration("1" + bp.Precision())
+	return uc.WriteContext(context.Background(), bp)
+}
 
-	var delayedError error
+// WriteContext is like Write, but gives up once ctx is done. A deadline on ctx
+// is applied to the connection's write deadline.
+func (uc *udpclient) WriteContext(ctx context.Context, bp BatchPoints) error {
+	_, err := uc.WriteWithStats(ctx, bp)
+	return err
+}
 
-	var checkBuffer = func(n int) {
-		if len(b) > 0 && len(b)+n > uc.payloadSize {
-			if _, err := uc.conn.Write(b); err != nil {
-				delayedError = err
-			}
-			b = b[:0]
-		}
+// WriteWithStats is like WriteContext, returning the statistics of the write.
+func (uc *udpclient) WriteWithStats(ctx context.Context, bp BatchPoints) (WriteStats, error) {
+	if ok, err := checkBatch(bp); !ok {
+		return WriteStats{}, err
 	}
+	if err := checkDatagrams(uc.maxDatagrams, len(bp.Points()), batchPointAt(bp), bp.Precision(), uc.timePrecision, uc.payloadSize); err != nil {
+		return WriteStats{}, err
+	}
+	return uc.write(ctx, len(bp.Points()), bp.Validate, func(flush func([]byte) error) error {
+		return writePayloads(ctx, bp, uc.timePrecision, &uc.bufs, uc.payloadSize, false, flush)
+	})
+}
 
-	for _, p := range bp.Points() {
-		p.pt.Round(d)
-		pointSize := p.pt.StringSize() + 1 // include newline in size
-		//point := p.pt.RoundedString(d) + "\n"
-
-		checkBuffer(pointSize)
-
-		if p.Time().IsZero() || pointSize <= uc.payloadSize {
-			b = p.pt.AppendString(b)
-			b = append(b, '\n')
-			continue
-		}
-
-		points := p.pt.Split(uc.payloadSize - 1) // account for newline character
-		for _, sp := range points {
-			checkBuffer(sp.StringSize() + 1)
-			b = sp.AppendString(b)
-			b = append(b, '\n')
+// write sends the payloads of points, which encode hands to flush, once
+// validate accepted them if points are validated.
+func (uc *udpclient) write(ctx context.Context, points int, validate func() error, encode func(flush func([]byte) error) error) (ws WriteStats, err error) {
+	if err := uc.drain.begin(); err != nil {
+		return ws, err
+	}
+	defer uc.drain.end()
+	if err := ctx.Err(); err != nil {
+		return ws, err
+	}
+	if uc.validatePoints {
+		if err := validate(); err != nil {
+			return ws, err
 		}
 	}
+	ws.PointCount = points
+	if uc.stats != nil {
+		defer writeDone(uc.stats, ws.PointCount, time.Now(), &ws.ByteCount, &err)
+	}
+	release := bindWriteContext(ctx, uc.conn)
+	defer func() { err = release(err) }()
 
-	if len(b) > 0 {
-		if _, err := uc.conn.Write(b); err != nil {
+	// Datagrams are independent of each other, so keep sending after a
+	// failed one.
+	var prev error
+	var sent int
+	t := newWriteTimer(&ws)
+	err = encode(func(b []byte) error {
+		t.encoded()
+		if err := uc.limiter.wait(ctx, countLines(b), len(b)); err != nil {
 			return err
 		}
+		if err := pace(ctx, uc.interval, &sent); err != nil {
+			return err
+		}
+		t.sending(len(b))
+		defer t.sent()
+		return uc.send(ctx, b, &prev)
+	})
+	t.encoded()
+	return ws, joinPrevious(prev, err)
+}
+
+// send sends the datagram b. A send failing with "connection refused" is
+// about an earlier datagram and did not send b, so the first one of a write
+// is stored in prev as a *PreviousDatagramError and b sent again.
+func (uc *udpclient) send(ctx context.Context, b []byte, prev *error) error {
+	if uc.dumpPayload != nil {
+		uc.dumpPayload(b)
+	}
+	setWriteDeadline(ctx, uc.conn, uc.writeTimeout)
+	_, err := uc.conn.Write(b)
+	if err != nil && *prev == nil && errors.Is(err, syscall.ECONNREFUSED) {
+		*prev = &PreviousDatagramError{Err: err}
+		setWriteDeadline(ctx, uc.conn, uc.writeTimeout)
+		_, err = uc.conn.Write(b)
+	}
+	if err == nil {
+		return nil
 	}
-	return delayedError
+
+	var netErr net.Error
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		err = &PreviousDatagramError{Err: err}
+	case uc.writeTimeout > 0 && errors.As(err, &netErr) && netErr.Timeout() && ctxError(ctx) == nil:
+		err = &WriteTimeoutError{Size: len(b), Err: err}
+	}
+	logf(uc.logger, "influxdb: sending UDP datagram failed: %v", err)
+	return err
+}
+
+// joinPrevious returns the error of a write that sent all of its datagrams or
+// failed with err, given the *PreviousDatagramError prev it ran into.
+func joinPrevious(prev, err error) error {
+	switch {
+	case prev == nil:
+		return err
+	case err == nil:
+		return prev
+	}
+	return errors.Join(prev, err)
+}
+
+// RemoteAddr returns the address the datagrams are sent to.
+func (uc *udpclient) RemoteAddr() net.Addr {
+	return remoteAddr(uc.conn)
 }
 
 func (uc *udpclient) Query(q Query) (*Response, error) {
-	return nil, fmt.Errorf("Querying via UDP is not supported")
+	return nil, queryNotSupportedError("UDP")
+}
+
+func (uc *udpclient) QueryContext(ctx context.Context, q Query) (*Response, error) {
+	return uc.Query(q)
 }
 
 func (uc *udpclient) QueryAsChunk(q Query) (*ChunkedResponse, error) {
-	return nil, fmt.Errorf("Querying via UDP is not supported")
+	return nil, queryNotSupportedError("UDP")
+}
+
+func (uc *udpclient) QueryAsChunkContext(ctx context.Context, q Query) (*ChunkedResponse, error) {
+	return uc.QueryAsChunk(q)
 }
 
 func (uc *udpclient) Ping(timeout time.Duration) (time.Duration, string, error) {
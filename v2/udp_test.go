@@ -0,0 +1,264 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+// closedUDPAddr returns a local address nothing listens on.
+func closedUDPAddr(t *testing.T) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	addr := conn.LocalAddr().String()
+	conn.Close()
+	return addr
+}
+
+func TestUDPClient_Probe(t *testing.T) {
+	c, err := NewUDPClient(UDPConfig{Addr: closedUDPAddr(t), ProbeTimeout: time.Second})
+	if err == nil {
+		c.Close()
+		t.Skip("the host does not reject datagrams sent to closed ports")
+	}
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Errorf("unexpected error.  expected %v, actual %v", syscall.ECONNREFUSED, err)
+	}
+
+	server, _ := net.ListenPacket("udp", "127.0.0.1:0")
+	defer server.Close()
+	c, err = NewUDPClient(UDPConfig{Addr: server.LocalAddr().String(), ProbeTimeout: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	c.Close()
+}
+
+func TestUDPClient_PreviousDatagram(t *testing.T) {
+	c, err := NewUDPClient(UDPConfig{Addr: closedUDPAddr(t)})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	defer c.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	bp.AddPoints(newTestPoints(1))
+	if err := c.Write(bp); err != nil {
+		t.Fatalf("unexpected error for the first write.  expected %v, actual %v", nil, err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	err = c.Write(bp)
+	if err == nil {
+		t.Skip("the host does not reject datagrams sent to closed ports")
+	}
+	var pe *PreviousDatagramError
+	if !errors.As(err, &pe) || !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Fatalf("unexpected error.  expected a *PreviousDatagramError, actual %v", err)
+	}
+	// The error was only about the first write, the second one was sent.
+	var joined interface{ Unwrap() []error }
+	if errors.As(err, &joined) {
+		t.Errorf("unexpected error of the write itself: %v", err)
+	}
+}
+
+// stalledConn is a connection whose writes block until their deadline.
+type stalledConn struct {
+	mu       sync.Mutex
+	deadline time.Time
+}
+
+func (c *stalledConn) SetWriteDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deadline = t
+	return nil
+}
+
+func (c *stalledConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	deadline := c.deadline
+	c.mu.Unlock()
+	if deadline.IsZero() {
+		return 0, errors.New("no write deadline set")
+	}
+	time.Sleep(time.Until(deadline))
+	return 0, os.ErrDeadlineExceeded
+}
+
+func (c *stalledConn) Close() error { return nil }
+
+func TestUDPClient_WriteTimeout(t *testing.T) {
+	c := &udpclient{conn: &stalledConn{}, payloadSize: UDPPayloadSize, writeTimeout: 20 * time.Millisecond}
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	bp.AddPoints(newTestPoints(1))
+
+	start := time.Now()
+	err := c.Write(bp)
+	var te *WriteTimeoutError
+	if !errors.As(err, &te) || te.Written != 0 || te.Size == 0 {
+		t.Errorf("unexpected error.  expected a *WriteTimeoutError, actual %v", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("unexpected write duration: %v", d)
+	}
+
+	if err := c.WriteRawBytes([]byte("cpu value=1\n")); !errors.As(err, &te) {
+		t.Errorf("unexpected error.  expected a *WriteTimeoutError, actual %v", err)
+	}
+}
+
+// newDatagramPoints returns n points of the same size, 2 of which fit a
+// payload of MinPayloadSize bytes.
+func newDatagramPoints(n int) []*Point {
+	points := make([]*Point, n)
+	for i := range points {
+		points[i], _ = NewPoint("cpu", nil, map[string]interface{}{"value": 1.0}, time.Unix(1, 0))
+	}
+	return points
+}
+
+// receiveDatagrams returns the number of datagrams received on conn until
+// none came for idle.
+func receiveDatagrams(conn net.PacketConn, idle time.Duration) int {
+	buf := make([]byte, MaxUDPPayloadSize)
+	n := 0
+	for {
+		conn.SetReadDeadline(time.Now().Add(idle))
+		if _, _, err := conn.ReadFrom(buf); err != nil {
+			return n
+		}
+		n++
+	}
+}
+
+func TestUDPClient_MaxDatagrams(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	defer server.Close()
+
+	c, err := NewUDPClient(UDPConfig{Addr: server.LocalAddr().String(), PayloadSize: MinPayloadSize, MaxDatagramsPerWrite: 3})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	defer c.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	bp.AddPoints(newDatagramPoints(7))
+	err = c.Write(bp)
+	var te *BatchTooLargeError
+	if !errors.As(err, &te) || !errors.Is(err, ErrBatchTooLarge) {
+		t.Fatalf("unexpected error.  expected a *BatchTooLargeError, actual %v", err)
+	}
+	if exp := (BatchTooLargeError{Datagrams: 4, MaxDatagrams: 3, Points: 6}); *te != exp {
+		t.Errorf("unexpected error.  expected %+v, actual %+v", exp, *te)
+	}
+	if n := receiveDatagrams(server, 50*time.Millisecond); n != 0 {
+		t.Errorf("unexpected datagrams of a rejected write.  expected %v, actual %v", 0, n)
+	}
+
+	bp.Reset()
+	bp.AddPoints(newDatagramPoints(te.Points))
+	if err := c.Write(bp); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if n := receiveDatagrams(server, 200*time.Millisecond); n != 3 {
+		t.Errorf("unexpected datagrams.  expected %v, actual %v", 3, n)
+	}
+
+	_, err = NewUDPClient(UDPConfig{Addrs: []string{server.LocalAddr().String()}, Routing: UDPRouteBySeries, MaxDatagramsPerWrite: 3})
+	var ce *ConfigError
+	if !errors.As(err, &ce) || ce.Field != "MaxDatagramsPerWrite" {
+		t.Errorf("unexpected error.  expected a *ConfigError, actual %v", err)
+	}
+}
+
+func TestUDPClient_ExpectedPrecision(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	defer server.Close()
+
+	addr := server.LocalAddr().String()
+	for _, conf := range []UDPConfig{{Addr: addr}, {Addrs: []string{addr}}} {
+		var dumped []string
+		conf.ExpectedPrecision = "s"
+		conf.MaxDatagramsPerWrite = 1
+		conf.DumpPayload = func(b []byte) { dumped = append(dumped, string(b)) }
+		c, err := NewUDPClient(conf)
+		if err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+		bp, _ := NewBatchPoints(BatchPointsConfig{Precision: "ns"})
+		bp.AddPoints(newTestPoints(3))
+		if err := c.Write(bp); err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+		points := []models.Point{bp.Points()[2].pt}
+		if err := c.(ModelsWriter).WriteModels(context.Background(), "", "", "ms", points); err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+		c.Close()
+		if exp := "cpu value=0 0\ncpu value=1 1\ncpu value=2 2\n|cpu value=2 2\n"; strings.Join(dumped, "|") != exp {
+			t.Errorf("unexpected datagrams.  expected %q, actual %q", exp, dumped)
+		}
+	}
+
+	_, err = NewUDPClient(UDPConfig{Addr: addr, ExpectedPrecision: "1s"})
+	var ce *ConfigError
+	if !errors.As(err, &ce) || ce.Field != "ExpectedPrecision" {
+		t.Errorf("unexpected error.  expected a *ConfigError, actual %v", err)
+	}
+}
+
+func TestUDPClient_DatagramInterval(t *testing.T) {
+	const datagrams = 50
+	for _, interval := range []time.Duration{0, 2 * time.Millisecond} {
+		server, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+		received := make(chan int)
+		go func() { received <- receiveDatagrams(server, 500*time.Millisecond) }()
+
+		c, _ := NewUDPClient(UDPConfig{Addr: server.LocalAddr().String(), PayloadSize: MinPayloadSize, DatagramInterval: interval})
+		bp, _ := NewBatchPoints(BatchPointsConfig{})
+		bp.AddPoints(newDatagramPoints(2 * datagrams))
+		start := time.Now()
+		ws, err := c.(StatsWriter).WriteWithStats(context.Background(), bp)
+		elapsed := time.Since(start)
+		if err != nil || ws.Flushes != datagrams {
+			t.Fatalf("unexpected write.  expected %d datagrams, actual %d, %v", datagrams, ws.Flushes, err)
+		}
+		n := <-received
+		c.Close()
+		server.Close()
+
+		if interval == 0 {
+			// A burst may overflow the socket buffer, so only report it.
+			t.Logf("received %d of %d datagrams of a burst", n, datagrams)
+			continue
+		}
+		if n != datagrams {
+			t.Errorf("unexpected paced datagrams received.  expected %v, actual %v", datagrams, n)
+		}
+		if min := (datagrams - 1) * interval; elapsed < min {
+			t.Errorf("unexpected duration of a paced write.  expected at least %v, actual %v", min, elapsed)
+		}
+	}
+}
@@ -0,0 +1,482 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+// DefaultUDPRetryInterval is the default interval at which a UDP client with
+// several addresses probes the ones taken out of rotation.
+const DefaultUDPRetryInterval = 5 * time.Second
+
+// ErrNoUDPEndpoint is returned for a datagram of a UDP client with several
+// addresses while all of them are out of rotation.
+var ErrNoUDPEndpoint = errors.New("no UDP endpoint is available")
+
+// UDPRouting decides which address of a UDP client with several addresses a
+// datagram is sent to.
+type UDPRouting int
+
+const (
+	// UDPRoundRobin sends the datagrams to the addresses in turn.
+	UDPRoundRobin UDPRouting = iota
+
+	// UDPRouteBySeries sends the points of a series to the same address,
+	// picked by a hash of the series key, as long as it is in rotation.
+	// Points written with WriteRawBytes are sent round-robin.
+	UDPRouteBySeries
+)
+
+// UDPEndpointStats describes an address of a UDP client with several
+// addresses.
+type UDPEndpointStats struct {
+	// Addr is the address, as given in UDPConfig.Addrs.
+	Addr string
+
+	// Datagrams is the number of datagrams sent to the address, and Errors
+	// the number of those that failed.
+	Datagrams uint64
+	Errors    uint64
+
+	// Up is whether the address is in rotation.
+	Up bool
+}
+
+// UDPEndpointsClient is implemented by the UDP client created with several
+// addresses.
+type UDPEndpointsClient interface {
+	// UDPEndpoints returns the statistics of the addresses, in the order
+	// of UDPConfig.Addrs.
+	UDPEndpoints() []UDPEndpointStats
+}
+
+// udpEndpoint is an address of a udppool.
+type udpEndpoint struct {
+	addr string
+
+	datagrams uint64
+	errors    uint64
+
+	// mu guards uc, which is nil until the address was resolved and dialed,
+	// and up.
+	mu sync.Mutex
+	uc *udpclient
+	up bool
+}
+
+// client returns the client of the endpoint if it is in rotation.
+func (ep *udpEndpoint) client() *udpclient {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+	if !ep.up {
+		return nil
+	}
+	return ep.uc
+}
+
+// setUp puts the endpoint in or out of rotation, and reports whether that
+// changed anything.
+func (ep *udpEndpoint) setUp(up bool) bool {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+	changed := ep.up != up
+	ep.up = up
+	return changed
+}
+
+// udppool spreads the datagrams of its writes over several addresses.
+type udppool struct {
+	endpoints      []*udpEndpoint
+	routing        UDPRouting
+	payloadSize    int
+	timePrecision  string
+	bufs           payloadBuffers
+	stats          StatsCollector
+	logger         Logger
+	validateRaw    bool
+	validatePoints bool
+	limiter        *rateLimiter
+	writeTimeout   time.Duration
+	probeTimeout   time.Duration
+	interval       time.Duration
+	maxDatagrams   int
+	dumpPayload    func(b []byte)
+	next           uint32
+
+	closeOnce sync.Once
+	closing   chan struct{}
+	done      chan struct{}
+	drain     drainer
+}
+
+// newUDPPool returns a udppool for conf.Addrs. An address that cannot be
+// resolved, dialed or probed is left out of rotation until it can, unless
+// that goes for all of them.
+func newUDPPool(conf UDPConfig, payloadSize int, timePrecision string, limiter *rateLimiter) (*udppool, error) {
+	switch conf.Routing {
+	case UDPRoundRobin, UDPRouteBySeries:
+	default:
+		return nil, &ConfigError{Field: "Routing", Reason: "unknown UDP routing"}
+	}
+	retryInterval := conf.RetryInterval
+	if retryInterval <= 0 {
+		retryInterval = DefaultUDPRetryInterval
+	}
+
+	p := &udppool{
+		routing:        conf.Routing,
+		timePrecision:  timePrecision,
+		payloadSize:    payloadSize,
+		stats:          conf.Stats,
+		logger:         conf.Logger,
+		validateRaw:    conf.ValidateRawWrites,
+		validatePoints: conf.ValidatePoints,
+		limiter:        limiter,
+		writeTimeout:   conf.WriteTimeout,
+		probeTimeout:   conf.ProbeTimeout,
+		interval:       conf.DatagramInterval,
+		maxDatagrams:   conf.MaxDatagramsPerWrite,
+		dumpPayload:    conf.DumpPayload,
+		closing:        make(chan struct{}),
+		done:           make(chan struct{}),
+		drain:          drainer{timeout: conf.DrainTimeout},
+	}
+	var firstErr error
+	up := 0
+	for _, addr := range conf.Addrs {
+		if addr == "" {
+			return nil, &ConfigError{Field: "Addrs", Reason: "empty address given"}
+		}
+		ep := &udpEndpoint{addr: addr}
+		p.endpoints = append(p.endpoints, ep)
+		if err := p.connect(ep); err != nil {
+			logf(p.logger, "influxdb: leaving %s out of rotation: %v", addr, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		up++
+	}
+	if up == 0 {
+		p.closeConns()
+		return nil, firstErr
+	}
+
+	logf(p.logger, "influxdb: sending UDP datagrams to %d of %d addresses", up, len(p.endpoints))
+	go p.probeLoop(retryInterval)
+	return p, nil
+}
+
+// connect resolves and dials the address of ep unless it was already, probes
+// it if probeTimeout is set, and puts it in rotation if that went well.
+func (p *udppool) connect(ep *udpEndpoint) error {
+	ep.mu.Lock()
+	uc := ep.uc
+	ep.mu.Unlock()
+
+	if uc == nil {
+		addr, err := net.ResolveUDPAddr("udp", ep.addr)
+		if err != nil {
+			return err
+		}
+		conn, err := net.DialUDP("udp", nil, addr)
+		if err != nil {
+			return err
+		}
+		uc = &udpclient{conn: conn, payloadSize: p.payloadSize, logger: p.logger, writeTimeout: p.writeTimeout}
+		ep.mu.Lock()
+		ep.uc = uc
+		ep.mu.Unlock()
+	}
+	if p.probeTimeout > 0 {
+		if err := probeUDP(uc.conn.(*net.UDPConn), p.probeTimeout); err != nil {
+			return fmt.Errorf("probing %s: %w", ep.addr, err)
+		}
+	}
+	ep.setUp(true)
+	return nil
+}
+
+// probeLoop tries to put the endpoints out of rotation back in every
+// interval, until the pool is closed.
+func (p *udppool) probeLoop(interval time.Duration) {
+	defer close(p.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.closing:
+			return
+		case <-ticker.C:
+		}
+		for _, ep := range p.endpoints {
+			if ep.client() != nil {
+				continue
+			}
+			if err := p.connect(ep); err != nil {
+				logf(p.logger, "influxdb: %s is still out of rotation: %v", ep.addr, err)
+				continue
+			}
+			logf(p.logger, "influxdb: %s is back in rotation", ep.addr)
+		}
+	}
+}
+
+// UDPEndpoints returns the statistics of the addresses of the pool.
+func (p *udppool) UDPEndpoints() []UDPEndpointStats {
+	stats := make([]UDPEndpointStats, len(p.endpoints))
+	for i, ep := range p.endpoints {
+		stats[i] = UDPEndpointStats{
+			Addr:      ep.addr,
+			Datagrams: atomic.LoadUint64(&ep.datagrams),
+			Errors:    atomic.LoadUint64(&ep.errors),
+			Up:        ep.client() != nil,
+		}
+	}
+	return stats
+}
+
+func (p *udppool) Write(bp BatchPoints) error {
+	return p.WriteContext(context.Background(), bp)
+}
+
+// WriteContext is like Write, but gives up once ctx is done. A deadline on ctx
+// is applied to the write deadline of the connections.
+func (p *udppool) WriteContext(ctx context.Context, bp BatchPoints) error {
+	_, err := p.WriteWithStats(ctx, bp)
+	return err
+}
+
+// WriteWithStats is like WriteContext, returning the statistics of the write.
+func (p *udppool) WriteWithStats(ctx context.Context, bp BatchPoints) (WriteStats, error) {
+	n, at := len(bp.Points()), batchPointAt(bp)
+	if err := checkDatagrams(p.maxDatagrams, n, at, bp.Precision(), p.timePrecision, p.payloadSize); err != nil {
+		return WriteStats{}, err
+	}
+	return p.write(ctx, n, bp.Validate, p.encode(ctx, n, at, bp.Precision(), newFieldSorter(bp)))
+}
+
+// encode returns the encode function of a write of the n points returned by
+// at, which routes every payload by series if the pool does, see
+// writePointPayloads.
+func (p *udppool) encode(ctx context.Context, n int, at func(i int) models.Point, precision string, sorter *fieldSorter) func(flush func(route int, b []byte) error) error {
+	return func(flush func(int, []byte) error) error {
+		if p.routing != UDPRouteBySeries {
+			return writePointPayloads(ctx, n, at, precision, p.timePrecision, sorter, &p.bufs, p.payloadSize, false, func(b []byte) error {
+				return flush(-1, b)
+			})
+		}
+
+		routes := make([][]int, len(p.endpoints))
+		for i := 0; i < n; i++ {
+			if pt := at(i); pt != nil {
+				route := int(hashTagValue(pt.Key()) % uint64(len(p.endpoints)))
+				routes[route] = append(routes[route], i)
+			}
+		}
+		var errs []error
+		for route, indices := range routes {
+			if len(indices) == 0 {
+				continue
+			}
+			err := writePointPayloads(ctx, len(indices), func(i int) models.Point { return at(indices[i]) }, precision, p.timePrecision, sorter, &p.bufs, p.payloadSize, false, func(b []byte) error {
+				return flush(route, b)
+			})
+			if err != nil {
+				if _, ok := err.(*WriteError); !ok {
+					return err
+				}
+				errs = append(errs, err)
+			}
+		}
+		return mergeWriteErrors(n, errs)
+	}
+}
+
+// mergeWriteErrors returns the *WriteError of a write of n points made of
+// several, each returning one of errs.
+func mergeWriteErrors(n int, errs []error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	merged := &WriteError{}
+	for _, err := range errs {
+		we := err.(*WriteError)
+		merged.PointsDropped += we.PointsDropped
+		merged.Errs = append(merged.Errs, we.Errs...)
+	}
+	merged.PointsWritten = n - merged.PointsDropped
+	return merged
+}
+
+// write sends the payloads of points, which encode hands to flush along with
+// the endpoint they are routed to, or -1, once validate accepted them if
+// points are validated.
+func (p *udppool) write(ctx context.Context, points int, validate func() error, encode func(flush func(int, []byte) error) error) (ws WriteStats, err error) {
+	if err := p.drain.begin(); err != nil {
+		return ws, err
+	}
+	defer p.drain.end()
+	if err := ctx.Err(); err != nil {
+		return ws, err
+	}
+	if p.validatePoints {
+		if err := validate(); err != nil {
+			return ws, err
+		}
+	}
+	ws.PointCount = points
+	if p.stats != nil {
+		defer writeDone(p.stats, ws.PointCount, time.Now(), &ws.ByteCount, &err)
+	}
+
+	// The connections are bound to ctx as they are first sent to.
+	releases := make([]func(error) error, len(p.endpoints))
+	defer func() {
+		for _, release := range releases {
+			if release != nil {
+				release(nil)
+			}
+		}
+		err = contextError(ctx, err)
+	}()
+
+	var prev error
+	var sent int
+	t := newWriteTimer(&ws)
+	err = encode(func(route int, b []byte) error {
+		t.encoded()
+		if err := p.limiter.wait(ctx, countLines(b), len(b)); err != nil {
+			return err
+		}
+		if err := pace(ctx, p.interval, &sent); err != nil {
+			return err
+		}
+		t.sending(len(b))
+		defer t.sent()
+		return p.send(ctx, route, b, &prev, releases)
+	})
+	t.encoded()
+	return ws, joinPrevious(prev, err)
+}
+
+// send sends the datagram b to the endpoint of route, or to the next one in
+// turn for a route of -1. An endpoint that fails is taken out of rotation
+// and b sent to the next one, so that b is only lost if all of them fail. An
+// endpoint rejecting an earlier datagram is taken out of rotation as well,
+// with the first such error stored in prev. The connections are bound to ctx
+// with releases, for the ones not bound yet.
+func (p *udppool) send(ctx context.Context, route int, b []byte, prev *error, releases []func(error) error) error {
+	if p.dumpPayload != nil {
+		p.dumpPayload(b)
+	}
+	if route < 0 {
+		route = int(atomic.AddUint32(&p.next, 1) - 1)
+	}
+	err := ErrNoUDPEndpoint
+	sent := false
+	for i := range p.endpoints {
+		n := (route + i) % len(p.endpoints)
+		ep := p.endpoints[n]
+		uc := ep.client()
+		if uc == nil {
+			continue
+		}
+		if releases[n] == nil {
+			releases[n] = bindWriteContext(ctx, uc.conn)
+		}
+
+		var rejected error
+		serr := uc.send(ctx, b, &rejected)
+		atomic.AddUint64(&ep.datagrams, 1)
+		if rejected != nil && *prev == nil {
+			*prev = rejected
+		}
+		if serr == nil && rejected == nil {
+			return nil
+		}
+		if serr != nil {
+			atomic.AddUint64(&ep.errors, 1)
+			err = serr
+		} else {
+			// b was sent, but likely lost like the datagram rejected
+			// before it, so send it to the next endpoint too.
+			sent = true
+		}
+		if ep.setUp(false) {
+			logf(p.logger, "influxdb: taking %s out of rotation", ep.addr)
+		}
+		if ctxError(ctx) != nil {
+			return err
+		}
+	}
+	if sent {
+		return nil
+	}
+	return err
+}
+
+// RemoteAddr returns the address of the first endpoint in rotation.
+func (p *udppool) RemoteAddr() net.Addr {
+	for _, ep := range p.endpoints {
+		if uc := ep.client(); uc != nil {
+			return uc.RemoteAddr()
+		}
+	}
+	return nil
+}
+
+func (p *udppool) Query(q Query) (*Response, error) {
+	return nil, queryNotSupportedError("UDP")
+}
+
+func (p *udppool) QueryContext(ctx context.Context, q Query) (*Response, error) {
+	return p.Query(q)
+}
+
+func (p *udppool) QueryAsChunk(q Query) (*ChunkedResponse, error) {
+	return nil, queryNotSupportedError("UDP")
+}
+
+func (p *udppool) QueryAsChunkContext(ctx context.Context, q Query) (*ChunkedResponse, error) {
+	return p.QueryAsChunk(q)
+}
+
+func (p *udppool) Ping(timeout time.Duration) (time.Duration, string, error) {
+	return 0, "", nil
+}
+
+// Close stops probing the endpoints and is otherwise like the UDP client's
+// Close, closing the connections of the endpoints.
+func (p *udppool) Close() error {
+	p.closeOnce.Do(func() {
+		close(p.closing)
+		<-p.done
+	})
+	return p.drain.close(p.closeConns)
+}
+
+func (p *udppool) closeConns() error {
+	var err error
+	for _, ep := range p.endpoints {
+		ep.mu.Lock()
+		uc := ep.uc
+		ep.uc, ep.up = nil, false
+		ep.mu.Unlock()
+		if uc == nil {
+			continue
+		}
+		if cerr := uc.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
@@ -0,0 +1,235 @@
+package client
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// udpListener counts the lines of the datagrams received on a local address.
+type udpListener struct {
+	conn net.PacketConn
+
+	mu    sync.Mutex
+	lines []string
+}
+
+func newUDPListener(t *testing.T, addr string) *udpListener {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	l := &udpListener{conn: conn}
+	go func() {
+		buf := make([]byte, MaxUDPPayloadSize)
+		for {
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			l.mu.Lock()
+			l.lines = append(l.lines, strings.Split(strings.TrimSuffix(string(buf[:n]), "\n"), "\n")...)
+			l.mu.Unlock()
+		}
+	}()
+	t.Cleanup(func() { conn.Close() })
+	return l
+}
+
+func (l *udpListener) addr() string { return l.conn.LocalAddr().String() }
+
+// received waits for n lines and returns the lines received.
+func (l *udpListener) received(t *testing.T, n int) []string {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		l.mu.Lock()
+		lines := append([]string(nil), l.lines...)
+		l.mu.Unlock()
+		if len(lines) >= n || time.Now().After(deadline) {
+			return lines
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// newLongTestPoints returns n points of which only one fits a payload of
+// MinPayloadSize.
+func newLongTestPoints(t *testing.T, n int) []*Point {
+	points := make([]*Point, n)
+	for i := range points {
+		points[i] = mustPoint(t, "measurement_with_a_long_name", nil, map[string]interface{}{"value": int64(i)}, time.Unix(0, int64(i)))
+	}
+	return points
+}
+
+func TestUDPClient_AddrsRoundRobin(t *testing.T) {
+	listeners := []*udpListener{newUDPListener(t, "127.0.0.1:0"), newUDPListener(t, "127.0.0.1:0"), newUDPListener(t, "127.0.0.1:0")}
+	var addrs []string
+	for _, l := range listeners {
+		addrs = append(addrs, l.addr())
+	}
+	c, err := NewUDPClient(UDPConfig{Addrs: addrs, PayloadSize: MinPayloadSize}) // one point per datagram
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	defer c.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	bp.AddPoints(newLongTestPoints(t, 6))
+	if err := c.Write(bp); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	for i, l := range listeners {
+		if lines := l.received(t, 2); len(lines) != 2 {
+			t.Errorf("unexpected lines of listener %d: %q", i, lines)
+		}
+	}
+	for _, ep := range c.(UDPEndpointsClient).UDPEndpoints() {
+		if ep.Datagrams != 2 || ep.Errors != 0 || !ep.Up {
+			t.Errorf("unexpected endpoint stats: %+v", ep)
+		}
+	}
+}
+
+func TestUDPClient_AddrsRouteBySeries(t *testing.T) {
+	listeners := []*udpListener{newUDPListener(t, "127.0.0.1:0"), newUDPListener(t, "127.0.0.1:0")}
+	c, err := NewUDPClient(UDPConfig{Addrs: []string{listeners[0].addr(), listeners[1].addr()}, Routing: UDPRouteBySeries})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	defer c.Close()
+
+	const hosts, writes = 20, 3
+	for w := 0; w < writes; w++ {
+		bp, _ := NewBatchPoints(BatchPointsConfig{})
+		for h := 0; h < hosts; h++ {
+			bp.AddPoint(mustPoint(t, "cpu", map[string]string{"host": string(rune('a' + h))}, map[string]interface{}{"v": int64(w)}, time.Unix(0, int64(w))))
+		}
+		if err := c.Write(bp); err != nil {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(listeners[0].received(t, 0))+len(listeners[1].received(t, 0)) < hosts*writes && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	seen := make(map[string]int)
+	for i, l := range listeners {
+		lines := l.received(t, 0)
+		if len(lines) == 0 || len(lines)%writes != 0 {
+			t.Errorf("unexpected line count of listener %d: %d", i, len(lines))
+		}
+		for _, line := range lines {
+			key := line[:strings.IndexByte(line, ' ')]
+			if j, ok := seen[key]; ok && j != i {
+				t.Errorf("unexpected listener for %s.  expected %v, actual %v", key, j, i)
+			}
+			seen[key] = i
+		}
+	}
+	if len(seen) != hosts {
+		t.Errorf("unexpected series count.  expected %v, actual %v", hosts, len(seen))
+	}
+}
+
+func TestUDPClient_AddrsOutOfRotation(t *testing.T) {
+	live := newUDPListener(t, "127.0.0.1:0")
+	closed := closedUDPAddr(t)
+	c, err := NewUDPClient(UDPConfig{
+		Addrs:         []string{"unresolvable.invalid:8089", closed, live.addr()},
+		PayloadSize:   MinPayloadSize,
+		RetryInterval: 20 * time.Millisecond,
+		ProbeTimeout:  20 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	defer c.Close()
+	ec := c.(UDPEndpointsClient)
+
+	eps := ec.UDPEndpoints()
+	if eps[0].Up || !eps[2].Up {
+		t.Errorf("unexpected endpoints: %+v", eps)
+	}
+	if eps[1].Up {
+		// The host does not reject datagrams sent to closed ports, so the
+		// first writes take the endpoint out of rotation, if at all.
+		t.Log("the closed port passed the probe")
+	}
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	bp.AddPoints(newLongTestPoints(t, 4))
+	for i := 0; i < 3; i++ {
+		if err := c.Write(bp); err != nil && !errors.As(err, new(*PreviousDatagramError)) {
+			t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+		}
+	}
+	if lines := live.received(t, 8); len(lines) < 8 {
+		t.Errorf("unexpected line count.  expected at least %v, actual %v", 8, len(lines))
+	}
+
+	// Once something listens on the closed port, it is back in rotation.
+	revived := newUDPListener(t, closed)
+	deadline := time.Now().Add(2 * time.Second)
+	for !ec.UDPEndpoints()[1].Up && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if eps := ec.UDPEndpoints(); !eps[1].Up || eps[0].Up {
+		t.Fatalf("unexpected endpoints: %+v", eps)
+	}
+	before := len(revived.received(t, 0))
+	if err := c.Write(bp); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if lines := revived.received(t, before+1); len(lines) <= before {
+		t.Errorf("unexpected lines of the revived listener: %q", lines)
+	}
+}
+
+func TestUDPClient_AddrsAllDown(t *testing.T) {
+	_, err := NewUDPClient(UDPConfig{Addrs: []string{"unresolvable.invalid:8089", "unresolvable.invalid:8090"}})
+	if err == nil {
+		t.Fatalf("unexpected error.  expected an error, actual %v", err)
+	}
+}
+
+func TestUDPClient_AddrsClose(t *testing.T) {
+	l := newUDPListener(t, "127.0.0.1:0")
+	c, err := NewUDPClient(UDPConfig{Addrs: []string{l.addr(), l.addr()}})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	p := c.(*udppool)
+	var conns []*net.UDPConn
+	for _, ep := range p.endpoints {
+		conns = append(conns, ep.uc.conn.(*net.UDPConn))
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	for i, conn := range conns {
+		if _, err := conn.Write([]byte("x")); !errors.Is(err, net.ErrClosed) {
+			t.Errorf("unexpected error for connection %d.  expected %v, actual %v", i, net.ErrClosed, err)
+		}
+	}
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	bp.AddPoints(newTestPoints(1))
+	if err := c.Write(bp); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("unexpected error.  expected %v, actual %v", ErrClientClosed, err)
+	}
+}
+
+func TestNewUDPClient_AddrAndAddrs(t *testing.T) {
+	_, err := NewUDPClient(UDPConfig{Addr: "127.0.0.1:8089", Addrs: []string{"127.0.0.1:8090"}})
+	var ce *ConfigError
+	if !errors.As(err, &ce) || ce.Field != "Addrs" {
+		t.Errorf("unexpected error.  expected %T, actual %v", ce, err)
+	}
+}
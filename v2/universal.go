@@ -0,0 +1,328 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// APIVersion is the API a UniversalClient writes and queries with.
+type APIVersion int
+
+const (
+	// APIDetect detects the API of the server, see NewUniversalClient.
+	APIDetect APIVersion = iota
+
+	// APIV1 writes to /write with the database and retention policy of
+	// the batches, as InfluxDB 1.x.
+	APIV1
+
+	// APIV2 writes to /api/v2/write with a bucket and an organization, as
+	// InfluxDB 2.x, and queries its 1.x compatible /query endpoint.
+	APIV2
+)
+
+func (v APIVersion) String() string {
+	switch v {
+	case APIDetect:
+		return "detect"
+	case APIV1:
+		return "v1"
+	case APIV2:
+		return "v2"
+	}
+	return fmt.Sprintf("APIVersion(%d)", int(v))
+}
+
+// Target is where a UniversalClient writes and queries, given for both APIs.
+type Target struct {
+	// Database and RetentionPolicy are those of the batches and queries
+	// that have none. With APIV2 they are mapped to a bucket by the DBRP
+	// mappings of the server.
+	Database        string
+	RetentionPolicy string
+
+	// Bucket is the bucket written to with APIV2, defaults to
+	// "database/retention-policy" of each batch, see HTTPConfig.Bucket.
+	Bucket string
+
+	// Org is the organization written to with APIV2, see HTTPConfig.Org.
+	Org string
+
+	// Token authenticates the requests with APIV2, in place of the
+	// Username and Password of the config, which are used with APIV1.
+	Token string
+}
+
+// UniversalConfig is the config data needed to create a UniversalClient.
+type UniversalConfig struct {
+	// HTTPConfig configures the HTTP clients of both APIs. Its
+	// UseV2CompatWrite, Bucket and Org are set by the UniversalClient.
+	HTTPConfig
+
+	Target Target
+
+	// API is the API to use, defaults to APIDetect.
+	API APIVersion
+
+	// DetectTimeout bounds the requests that detect the API, defaults to
+	// DefaultDetectTimeout.
+	DetectTimeout time.Duration
+}
+
+// DefaultDetectTimeout is how long a UniversalClient waits for the server to
+// tell its API if the DetectTimeout of the config is not set.
+const DefaultDetectTimeout = 5 * time.Second
+
+// UniversalClient is a Client that writes and queries InfluxDB 1.x and the
+// 1.x compatible API of InfluxDB 2.x alike, so that the same points and
+// queries can be sent to either during a migration. The API is detected on
+// the first request and kept until a request fails with an
+// *AuthorizationError, as when the server was replaced by one of the other
+// version, after which it is detected again.
+//
+// UniversalClient is safe for concurrent use by multiple goroutines.
+type UniversalClient struct {
+	conf     UniversalConfig
+	detector Client
+
+	mu   sync.Mutex
+	api  APIVersion
+	c    Client
+	old  []Client
+	done bool
+}
+
+// NewUniversalClient returns a UniversalClient for the server at conf.Addr.
+// Unless conf.API is set, the API is detected from the version the server
+// answers pings with, or the version of its /health endpoint if the pings
+// have none, as InfluxDB Cloud: 2.x is APIV2, anything else APIV1.
+func NewUniversalClient(conf UniversalConfig) (*UniversalClient, error) {
+	switch conf.API {
+	case APIDetect, APIV1, APIV2:
+	default:
+		return nil, &ConfigError{Field: "API", Reason: fmt.Sprintf("unknown API %v", conf.API)}
+	}
+	if conf.DetectTimeout < 0 {
+		return nil, &ConfigError{Field: "DetectTimeout", Reason: fmt.Sprintf("%v is negative", conf.DetectTimeout)}
+	}
+	if conf.DetectTimeout == 0 {
+		conf.DetectTimeout = DefaultDetectTimeout
+	}
+	if conf.Target.Token != "" && conf.AuthToken != "" {
+		return nil, &ConfigError{Field: "Target.Token", Reason: "cannot be used together with AuthToken"}
+	}
+	conf.UseV2CompatWrite, conf.Bucket, conf.Org = false, "", ""
+
+	detector, err := NewHTTPClient(conf.HTTPConfig)
+	if err != nil {
+		return nil, err
+	}
+	uc := &UniversalClient{conf: conf, detector: detector}
+	if conf.API != APIDetect {
+		if _, err := uc.client(context.Background()); err != nil {
+			detector.Close()
+			return nil, err
+		}
+	}
+	return uc, nil
+}
+
+// API returns the API of the server, detecting it if it is not known.
+func (uc *UniversalClient) API(ctx context.Context) (APIVersion, error) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	if _, err := uc.clientLocked(ctx); err != nil {
+		return APIDetect, err
+	}
+	return uc.api, nil
+}
+
+// client returns the client of the API of the server.
+func (uc *UniversalClient) client(ctx context.Context) (Client, error) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	return uc.clientLocked(ctx)
+}
+
+func (uc *UniversalClient) clientLocked(ctx context.Context) (Client, error) {
+	if uc.done {
+		return nil, ErrClientClosed
+	}
+	if uc.c != nil {
+		return uc.c, nil
+	}
+	api := uc.conf.API
+	if api == APIDetect {
+		var err error
+		if api, err = uc.detect(ctx); err != nil {
+			return nil, fmt.Errorf("detecting the API of the server: %w", err)
+		}
+	}
+	if api == uc.api && uc.old != nil {
+		// Detected again as it was, the last client is kept.
+		uc.c, uc.old = uc.old[len(uc.old)-1], uc.old[:len(uc.old)-1]
+		return uc.c, nil
+	}
+
+	conf := uc.conf.HTTPConfig
+	if api == APIV2 {
+		conf.UseV2CompatWrite = true
+		conf.Bucket, conf.Org = uc.conf.Target.Bucket, uc.conf.Target.Org
+		if uc.conf.Target.Token != "" {
+			conf.Username, conf.Password, conf.AuthViaParams = "", "", false
+			conf.AuthToken = uc.conf.Target.Token
+		}
+	}
+	c, err := NewHTTPClient(conf)
+	if err != nil {
+		return nil, err
+	}
+	uc.api, uc.c = api, c
+	return c, nil
+}
+
+// detect asks the server for its API.
+func (uc *UniversalClient) detect(ctx context.Context) (APIVersion, error) {
+	ctx, cancel := context.WithTimeout(ctx, uc.conf.DetectTimeout)
+	defer cancel()
+	hc := uc.detector.(HealthClient)
+	res, err := hc.PingContext(ctx, 0)
+	if err != nil {
+		return APIDetect, err
+	}
+	version := res.Version
+	if version == "" {
+		info, err := hc.Health(ctx)
+		if err != nil && !errors.Is(err, ErrNotSupported) {
+			return APIDetect, err
+		}
+		version = info.Version
+	}
+	if res.Build == "Cloud" || strings.HasPrefix(strings.TrimPrefix(version, "v"), "2.") {
+		return APIV2, nil
+	}
+	return APIV1, nil
+}
+
+// checkAuth forgets the API of the server if err, or the error of resp, is an
+// *AuthorizationError of c, so that it is detected again by the next
+// request.
+func (uc *UniversalClient) checkAuth(c Client, resp *Response, err error) {
+	if err == nil && resp != nil {
+		err = resp.Error()
+	}
+	var ae *AuthorizationError
+	if uc.conf.API != APIDetect || !errors.As(err, &ae) {
+		return
+	}
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	if uc.c == c {
+		// A request may still be using it, it is closed by Close.
+		uc.old = append(uc.old, c)
+		uc.c = nil
+	}
+}
+
+// Ping checks the status of the server.
+func (uc *UniversalClient) Ping(timeout time.Duration) (time.Duration, string, error) {
+	return uc.detector.Ping(timeout)
+}
+
+// Write writes bp with the API of the server.
+func (uc *UniversalClient) Write(bp BatchPoints) error {
+	return uc.WriteContext(context.Background(), bp)
+}
+
+// WriteContext is like Write, but the write is bound to ctx. A batch without
+// a database is written to the Database and RetentionPolicy of the Target.
+func (uc *UniversalClient) WriteContext(ctx context.Context, bp BatchPoints) error {
+	c, err := uc.client(ctx)
+	if err != nil {
+		return err
+	}
+	if bp.Database() == "" && uc.conf.Target.Database != "" {
+		conf := batchPointsConfig(bp)
+		// The points were already counted by the limiter of bp.
+		conf.CardinalityLimiter = nil
+		conf.MaxBytes = 0
+		conf.Database, conf.RetentionPolicy = uc.conf.Target.Database, uc.conf.Target.RetentionPolicy
+		b, err := NewBatchPoints(conf)
+		if err != nil {
+			return err
+		}
+		if err := b.AddPoints(bp.Points()); err != nil {
+			return err
+		}
+		bp = b
+	}
+	err = c.(ContextClient).WriteContext(ctx, bp)
+	uc.checkAuth(c, nil, err)
+	return err
+}
+
+// target sets the Database and RetentionPolicy of the Target on q if it has
+// none.
+func (uc *UniversalClient) target(q Query) Query {
+	if q.Database == "" {
+		q.Database, q.RetentionPolicy = uc.conf.Target.Database, uc.conf.Target.RetentionPolicy
+	}
+	return q
+}
+
+// Query sends q with the API of the server.
+func (uc *UniversalClient) Query(q Query) (*Response, error) {
+	return uc.QueryContext(context.Background(), q)
+}
+
+// QueryContext is like Query, but the query is bound to ctx. A query without
+// a database is sent to the Database and RetentionPolicy of the Target.
+func (uc *UniversalClient) QueryContext(ctx context.Context, q Query) (*Response, error) {
+	c, err := uc.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.(ContextClient).QueryContext(ctx, uc.target(q))
+	uc.checkAuth(c, resp, err)
+	return resp, err
+}
+
+// QueryAsChunk sends q with the API of the server.
+func (uc *UniversalClient) QueryAsChunk(q Query) (*ChunkedResponse, error) {
+	return uc.QueryAsChunkContext(context.Background(), q)
+}
+
+// QueryAsChunkContext is like QueryAsChunk, but the query is bound to ctx,
+// see QueryContext.
+func (uc *UniversalClient) QueryAsChunkContext(ctx context.Context, q Query) (*ChunkedResponse, error) {
+	c, err := uc.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.(ContextClient).QueryAsChunkContext(ctx, uc.target(q))
+	uc.checkAuth(c, nil, err)
+	return resp, err
+}
+
+// Close closes the clients of the APIs.
+func (uc *UniversalClient) Close() error {
+	uc.mu.Lock()
+	clients := append(uc.old, uc.detector)
+	if uc.c != nil {
+		clients = append(clients, uc.c)
+	}
+	uc.old, uc.c, uc.done = nil, nil, true
+	uc.mu.Unlock()
+
+	var errs []error
+	for _, c := range clients {
+		if err := c.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,154 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// newVersionServer returns a server that answers as InfluxDB 1.8 or 2.x, as
+// set with the returned function, and records the requests it got, as the
+// path with the parameters that tell the APIs apart.
+func newVersionServer() (*httptest.Server, func(v2 bool), func() []string) {
+	var mu sync.Mutex
+	var v2 bool
+	var requests []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		req := r.URL.Path
+		switch r.URL.Path {
+		case "/write":
+			req += " db=" + r.FormValue("db") + " rp=" + r.FormValue("rp")
+		case "/api/v2/write":
+			req += " bucket=" + r.FormValue("bucket") + " org=" + r.FormValue("org")
+		case "/query":
+			req += " db=" + r.FormValue("db")
+		}
+		requests = append(requests, req)
+
+		switch {
+		case r.URL.Path == "/ping" && v2:
+			w.Header().Set("X-Influxdb-Version", "v2.7.4")
+			w.WriteHeader(http.StatusNoContent)
+		case r.URL.Path == "/ping":
+			w.Header().Set("X-Influxdb-Version", "1.8.10")
+			w.WriteHeader(http.StatusNoContent)
+		case v2 && r.Header.Get("Authorization") != "Token tok":
+			w.WriteHeader(http.StatusUnauthorized)
+		case !v2 && r.URL.Path == "/api/v2/write":
+			w.WriteHeader(http.StatusNotFound)
+		case r.URL.Path == "/query":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"results":[{"statement_id":0}]}`))
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	setV2 := func(b bool) {
+		mu.Lock()
+		v2 = b
+		mu.Unlock()
+	}
+	take := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		r := requests
+		requests = nil
+		return r
+	}
+	return ts, setV2, take
+}
+
+func TestUniversalClient(t *testing.T) {
+	ts, setV2, requests := newVersionServer()
+	defer ts.Close()
+
+	uc, err := NewUniversalClient(UniversalConfig{
+		HTTPConfig: HTTPConfig{Addr: ts.URL, Username: "user", Password: "pass"},
+		Target:     Target{Database: "db0", RetentionPolicy: "rp0", Org: "org0", Token: "tok"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	defer uc.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	bp.AddPoints(newTestPoints(2))
+	run := func() error {
+		if err := uc.Write(bp); err != nil {
+			return err
+		}
+		_, err := uc.Query(Query{Command: "SELECT * FROM cpu"})
+		return err
+	}
+
+	if err := run(); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if exp, actual := []string{"/ping", "/write db=db0 rp=rp0", "/query db=db0"}, requests(); !reflect.DeepEqual(actual, exp) {
+		t.Errorf("unexpected requests for 1.x.  expected %q, actual %q", exp, actual)
+	}
+	if api, _ := uc.API(context.Background()); api != APIV1 {
+		t.Errorf("unexpected API.  expected %v, actual %v", APIV1, api)
+	}
+
+	// The server is replaced by a 2.x one, rejecting the credentials of
+	// 1.x: the API is detected again by the next request.
+	setV2(true)
+	var ae *AuthorizationError
+	if err := uc.Write(bp); !errors.As(err, &ae) {
+		t.Fatalf("unexpected error.  expected an *AuthorizationError, actual %v", err)
+	}
+	requests()
+	if err := run(); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if exp, actual := []string{"/ping", "/api/v2/write bucket=db0/rp0 org=org0", "/query db=db0"}, requests(); !reflect.DeepEqual(actual, exp) {
+		t.Errorf("unexpected requests for 2.x.  expected %q, actual %q", exp, actual)
+	}
+	if api, _ := uc.API(context.Background()); api != APIV2 {
+		t.Errorf("unexpected API.  expected %v, actual %v", APIV2, api)
+	}
+}
+
+func TestUniversalClient_API(t *testing.T) {
+	ts, _, requests := newVersionServer()
+	defer ts.Close()
+
+	uc, err := NewUniversalClient(UniversalConfig{
+		HTTPConfig: HTTPConfig{Addr: ts.URL},
+		Target:     Target{Bucket: "b0"},
+		API:        APIV2,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	bp, _ := NewBatchPoints(BatchPointsConfig{Database: "db1"})
+	bp.AddPoints(newTestPoints(1))
+	// The server is 1.8, set as 2.x: the API is not detected.
+	if err := uc.Write(bp); err == nil {
+		t.Errorf("unexpected error.  expected the write to fail, actual %v", err)
+	}
+	uc.Close()
+	if exp, actual := []string{"/api/v2/write bucket=b0 org=-"}, requests(); !reflect.DeepEqual(actual, exp) {
+		t.Errorf("unexpected requests.  expected %q, actual %q", exp, actual)
+	}
+	if err := uc.Write(bp); err != ErrClientClosed {
+		t.Errorf("unexpected error.  expected %v, actual %v", ErrClientClosed, err)
+	}
+
+	for _, conf := range []UniversalConfig{
+		{HTTPConfig: HTTPConfig{Addr: ts.URL}, API: 3},
+		{HTTPConfig: HTTPConfig{Addr: ts.URL, AuthToken: "a"}, Target: Target{Token: "b"}},
+	} {
+		var ce *ConfigError
+		if _, err := NewUniversalClient(conf); !errors.As(err, &ce) {
+			t.Errorf("unexpected error.  expected a *ConfigError, actual %v", err)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+)
+
+// DefaultUnixSocketHost is the Host header of requests sent over a unix
+// domain socket.
+const DefaultUnixSocketHost = "localhost"
+
+// dialUnixSocket returns a DialContext function that connects to the socket
+// at path whatever the address of the request.
+func dialUnixSocket(path string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	var d net.Dialer
+	return func(ctx context.Context, _, _ string) (net.Conn, error) {
+		conn, err := d.DialContext(ctx, "unix", path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil, fmt.Errorf("unix socket %s does not exist, is InfluxDB listening on it? %w", path, err)
+			}
+			if errors.Is(err, syscall.ECONNREFUSED) {
+				return nil, fmt.Errorf("nothing is listening on unix socket %s: %w", path, err)
+			}
+			return nil, err
+		}
+		return conn, nil
+	}
+}
@@ -0,0 +1,125 @@
+package client
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newUnixSocketServer(t *testing.T, h http.Handler) (string, func()) {
+	dir, err := os.MkdirTemp("", "influxd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "influxd.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Skipf("unix sockets are not supported: %v", err)
+	}
+	ts := httptest.NewUnstartedServer(h)
+	ts.Listener = l
+	ts.Start()
+	return path, func() {
+		ts.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestClient_UnixSocket(t *testing.T) {
+	var hosts []string
+	path, closeServer := newUnixSocketServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hosts = append(hosts, r.Host)
+		switch r.URL.Path {
+		case "/ping":
+			w.Header().Set("X-Influxdb-Version", "1.8.10")
+			w.WriteHeader(http.StatusNoContent)
+		case "/write":
+			w.WriteHeader(http.StatusNoContent)
+		case "/query":
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"results":[{"statement_id":0}]}`))
+		}
+	}))
+	defer closeServer()
+
+	c, err := NewHTTPClient(HTTPConfig{Addr: "unix://" + path})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	defer c.Close()
+
+	if _, version, err := c.Ping(0); err != nil || version != "1.8.10" {
+		t.Errorf("unexpected ping result: %q, %v", version, err)
+	}
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	bp.AddPoints(newTestPoints(1))
+	if err := c.Write(bp); err != nil {
+		t.Errorf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if _, err := c.Query(Query{}); err != nil {
+		t.Errorf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	resp, err := c.QueryAsChunk(Query{})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if _, err := resp.NextResponse(); err != nil {
+		t.Errorf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	resp.Close()
+
+	for _, h := range hosts {
+		if h != DefaultUnixSocketHost {
+			t.Errorf("unexpected Host header.  expected %v, actual %v", DefaultUnixSocketHost, h)
+		}
+	}
+	if len(hosts) != 4 {
+		t.Errorf("unexpected number of requests.  expected %v, actual %v", 4, len(hosts))
+	}
+}
+
+func TestClient_UnixSocketHost(t *testing.T) {
+	path, closeServer := newUnixSocketServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "influxdb.local" {
+			t.Errorf("unexpected Host header: %q", r.Host)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer closeServer()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: "unix://" + path, UnixSocketHost: "influxdb.local"})
+	defer c.Close()
+
+	if _, _, err := c.Ping(0); err != nil {
+		t.Errorf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+}
+
+func TestClient_UnixSocketMissing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.sock")
+	c, err := NewHTTPClient(HTTPConfig{Addr: "unix://" + path})
+	if err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	defer c.Close()
+
+	_, _, err = c.Ping(0)
+	if err == nil || !strings.Contains(err.Error(), "unix socket "+path+" does not exist") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestNewHTTPClient_UnixSocketInvalid(t *testing.T) {
+	if _, err := NewHTTPClient(HTTPConfig{Addr: "unix://"}); err == nil {
+		t.Error("expected an error for a missing socket path")
+	}
+	if _, err := NewHTTPClient(HTTPConfig{Addr: "unix:///tmp/influxd.sock", Transport: http.DefaultTransport}); err == nil {
+		t.Error("expected an error for a unix socket with a custom transport")
+	}
+}
@@ -0,0 +1,209 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Privilege is a privilege of a user on a database.
+type Privilege int
+
+const (
+	// PrivilegeNone is no privilege, as reported by ShowGrants for a
+	// database whose privileges were revoked.
+	PrivilegeNone Privilege = iota
+
+	// PrivilegeRead allows querying a database.
+	PrivilegeRead
+
+	// PrivilegeWrite allows writing to a database.
+	PrivilegeWrite
+
+	// PrivilegeAll allows both. Granted on no database, it makes the user
+	// an admin.
+	PrivilegeAll
+)
+
+// String returns the InfluxQL name of p.
+func (p Privilege) String() string {
+	switch p {
+	case PrivilegeNone:
+		return "NO PRIVILEGES"
+	case PrivilegeRead:
+		return "READ"
+	case PrivilegeWrite:
+		return "WRITE"
+	case PrivilegeAll:
+		return "ALL PRIVILEGES"
+	}
+	return fmt.Sprintf("Privilege(%d)", int(p))
+}
+
+// keyword returns the InfluxQL keyword of p in GRANT and REVOKE statements.
+func (p Privilege) keyword() (string, error) {
+	switch p {
+	case PrivilegeRead:
+		return "READ", nil
+	case PrivilegeWrite:
+		return "WRITE", nil
+	case PrivilegeAll:
+		return "ALL", nil
+	}
+	return "", fmt.Errorf("invalid privilege %v", p)
+}
+
+// UserInfo is a user returned by ShowUsers.
+type UserInfo struct {
+	Name  string
+	Admin bool
+}
+
+// Grant is a privilege of a user on a database, returned by ShowGrants.
+type Grant struct {
+	Database  string
+	Privilege Privilege
+}
+
+// redactedPassword replaces passwords in the statements of the errors
+// returned.
+const redactedPassword = "'[REDACTED]'"
+
+// CreateUser creates the user name with password, as an admin if admin is
+// set. It fails with an error matching ErrUserExists if the user exists with
+// a different password.
+func CreateUser(ctx context.Context, c Client, name, password string, admin bool) error {
+	if name == "" {
+		return errors.New("user name is required")
+	}
+	stmt := "CREATE USER " + QuoteIdent(name) + " WITH PASSWORD "
+	suffix := ""
+	if admin {
+		suffix = " WITH ALL PRIVILEGES"
+	}
+	return execWithPassword(ctx, c, stmt, password, suffix)
+}
+
+// DropUser drops the user name.
+func DropUser(ctx context.Context, c Client, name string) error {
+	if name == "" {
+		return errors.New("user name is required")
+	}
+	return execAdmin(ctx, c, "DROP USER "+QuoteIdent(name), "")
+}
+
+// SetUserPassword changes the password of the user name.
+func SetUserPassword(ctx context.Context, c Client, name, password string) error {
+	if name == "" {
+		return errors.New("user name is required")
+	}
+	return execWithPassword(ctx, c, "SET PASSWORD FOR "+QuoteIdent(name)+" = ", password, "")
+}
+
+// GrantPrivilege grants privilege on the database db to user. With an empty
+// db, privilege must be PrivilegeAll and makes user an admin.
+func GrantPrivilege(ctx context.Context, c Client, user, db string, privilege Privilege) error {
+	on, err := privilegeClause(user, db, privilege)
+	if err != nil {
+		return err
+	}
+	return execAdmin(ctx, c, "GRANT "+on+" TO "+QuoteIdent(user), "")
+}
+
+// RevokePrivilege revokes privilege on the database db from user. With an
+// empty db, privilege must be PrivilegeAll and revokes the admin rights of
+// user.
+func RevokePrivilege(ctx context.Context, c Client, user, db string, privilege Privilege) error {
+	on, err := privilegeClause(user, db, privilege)
+	if err != nil {
+		return err
+	}
+	return execAdmin(ctx, c, "REVOKE "+on+" FROM "+QuoteIdent(user), "")
+}
+
+// ShowUsers returns the users.
+func ShowUsers(ctx context.Context, c Client) ([]UserInfo, error) {
+	resp, err := queryStatement(ctx, c, "", "SHOW USERS")
+	if err != nil {
+		return nil, err
+	}
+
+	var users []UserInfo
+	err = eachRow(resp, func(get func(column string) interface{}) error {
+		var u UserInfo
+		u.Name, _ = get("user").(string)
+		u.Admin, _ = get("admin").(bool)
+		users = append(users, u)
+		return nil
+	})
+	return users, err
+}
+
+// ShowGrants returns the privileges of user on the databases. The admin
+// rights of user are reported by ShowUsers instead.
+func ShowGrants(ctx context.Context, c Client, user string) ([]Grant, error) {
+	if user == "" {
+		return nil, errors.New("user name is required")
+	}
+	resp, err := queryStatement(ctx, c, "", "SHOW GRANTS FOR "+QuoteIdent(user))
+	if err != nil {
+		return nil, err
+	}
+
+	var grants []Grant
+	err = eachRow(resp, func(get func(column string) interface{}) error {
+		var g Grant
+		g.Database, _ = get("database").(string)
+		name, _ := get("privilege").(string)
+		switch strings.ToUpper(name) {
+		case "NO PRIVILEGES":
+			g.Privilege = PrivilegeNone
+		case "READ":
+			g.Privilege = PrivilegeRead
+		case "WRITE":
+			g.Privilege = PrivilegeWrite
+		case "ALL PRIVILEGES":
+			g.Privilege = PrivilegeAll
+		default:
+			return fmt.Errorf("unexpected privilege %q of %s on %s", name, user, g.Database)
+		}
+		grants = append(grants, g)
+		return nil
+	})
+	return grants, err
+}
+
+// privilegeClause returns the privilege and ON clause of a GRANT or REVOKE
+// statement.
+func privilegeClause(user, db string, privilege Privilege) (string, error) {
+	if user == "" {
+		return "", errors.New("user name is required")
+	}
+	kw, err := privilege.keyword()
+	if err != nil {
+		return "", err
+	}
+	if db == "" {
+		if privilege != PrivilegeAll {
+			return "", fmt.Errorf("privilege %v requires a database", privilege)
+		}
+		return "ALL PRIVILEGES", nil
+	}
+	return kw + " ON " + QuoteIdent(db), nil
+}
+
+// execWithPassword runs the statement made of prefix, the quoted password and
+// suffix, with the password redacted from the statement of the error
+// returned.
+func execWithPassword(ctx context.Context, c Client, prefix, password, suffix string) error {
+	if password == "" {
+		return errors.New("password is required")
+	}
+	err := execAdmin(ctx, c, prefix+QuoteString(password)+suffix, "")
+	var se *StatementError
+	if errors.As(err, &se) {
+		se.Statement = prefix + redactedPassword + suffix
+	}
+	return err
+}
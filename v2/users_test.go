@@ -0,0 +1,140 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+func TestUsers_Statements(t *testing.T) {
+	ts, statements := newStatementServer(t, nil)
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	ctx := context.Background()
+	steps := []struct {
+		run func() error
+		exp string
+	}{
+		{
+			run: func() error { return CreateUser(ctx, c, "admin", "s3cret", true) },
+			exp: `CREATE USER "admin" WITH PASSWORD 's3cret' WITH ALL PRIVILEGES`,
+		},
+		{
+			run: func() error { return CreateUser(ctx, c, `o"brien`, `it's a \ secret`, false) },
+			exp: `CREATE USER "o\"brien" WITH PASSWORD 'it\'s a \\ secret'`,
+		},
+		{
+			run: func() error { return SetUserPassword(ctx, c, "reader", `new'pass`) },
+			exp: `SET PASSWORD FOR "reader" = 'new\'pass'`,
+		},
+		{
+			run: func() error { return GrantPrivilege(ctx, c, "reader", "telegraf", PrivilegeRead) },
+			exp: `GRANT READ ON "telegraf" TO "reader"`,
+		},
+		{
+			run: func() error { return GrantPrivilege(ctx, c, "writer", "telegraf", PrivilegeAll) },
+			exp: `GRANT ALL ON "telegraf" TO "writer"`,
+		},
+		{
+			run: func() error { return GrantPrivilege(ctx, c, "ops", "", PrivilegeAll) },
+			exp: `GRANT ALL PRIVILEGES TO "ops"`,
+		},
+		{
+			run: func() error { return RevokePrivilege(ctx, c, "writer", "telegraf", PrivilegeWrite) },
+			exp: `REVOKE WRITE ON "telegraf" FROM "writer"`,
+		},
+		{
+			run: func() error { return RevokePrivilege(ctx, c, "ops", "", PrivilegeAll) },
+			exp: `REVOKE ALL PRIVILEGES FROM "ops"`,
+		},
+		{
+			run: func() error { return DropUser(ctx, c, "reader") },
+			exp: `DROP USER "reader"`,
+		},
+	}
+	for i, step := range steps {
+		if err := step.run(); err != nil {
+			t.Fatalf("%d: unexpected error: %v", i, err)
+		}
+		if got := (*statements)[i]; got != step.exp {
+			t.Errorf("%d: unexpected statement.\nexpected %s\nactual   %s", i, step.exp, got)
+		}
+	}
+}
+
+func TestCreateUser_Exists(t *testing.T) {
+	ts, _ := newStatementServer(t, func(string) string { return "user already exists" })
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	err := CreateUser(context.Background(), c, "admin", "s3cret", true)
+	if !errors.Is(err, ErrUserExists) || IgnoreExists(err) != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var se *StatementError
+	if !errors.As(err, &se) || strings.Contains(se.Statement, "s3cret") || se.Statement != `CREATE USER "admin" WITH PASSWORD '[REDACTED]' WITH ALL PRIVILEGES` {
+		t.Errorf("unexpected statement error: %+v", se)
+	}
+}
+
+func TestShowUsersAndGrants(t *testing.T) {
+	ts, _ := newShowServer(t, map[string][]models.Row{
+		"SHOW USERS": {{Columns: []string{"user", "admin"}, Values: [][]interface{}{{"admin", true}, {"reader", false}}}},
+		`SHOW GRANTS FOR "reader"`: {{Columns: []string{"database", "privilege"}, Values: [][]interface{}{
+			{"telegraf", "READ"}, {"_internal", "ALL PRIVILEGES"}, {"old", "NO PRIVILEGES"},
+		}}},
+	})
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+	ctx := context.Background()
+
+	users, err := ShowUsers(ctx, c)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if exp := []UserInfo{{Name: "admin", Admin: true}, {Name: "reader"}}; !reflect.DeepEqual(users, exp) {
+		t.Errorf("unexpected users.  expected %v, actual %v", exp, users)
+	}
+
+	grants, err := ShowGrants(ctx, c, "reader")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	exp := []Grant{{"telegraf", PrivilegeRead}, {"_internal", PrivilegeAll}, {"old", PrivilegeNone}}
+	if !reflect.DeepEqual(grants, exp) {
+		t.Errorf("unexpected grants.  expected %v, actual %v", exp, grants)
+	}
+}
+
+func TestUsers_Invalid(t *testing.T) {
+	c, _ := NewHTTPClient(HTTPConfig{Addr: "http://localhost:1"})
+	defer c.Close()
+
+	ctx := context.Background()
+	if err := CreateUser(ctx, c, "", "pw", false); err == nil {
+		t.Error("expected an error for an empty user name")
+	}
+	if err := CreateUser(ctx, c, "u", "", false); err == nil {
+		t.Error("expected an error for an empty password")
+	}
+	if err := GrantPrivilege(ctx, c, "u", "", PrivilegeRead); err == nil {
+		t.Error("expected an error for a read privilege on no database")
+	}
+	if err := GrantPrivilege(ctx, c, "u", "db", PrivilegeNone); err == nil {
+		t.Error("expected an error for no privilege")
+	}
+	if err := RevokePrivilege(ctx, c, "", "db", PrivilegeRead); err == nil {
+		t.Error("expected an error for an empty user name")
+	}
+}
@@ -0,0 +1,133 @@
+package client
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+// MaxStringFieldLength is the length in bytes of the longest string field
+// value Validate accepts.
+const MaxStringFieldLength = 64 * 1024
+
+// ValidationError is returned for a point the server would reject.
+type ValidationError struct {
+	// Index is the index of the point within its batch, or -1 for a point
+	// validated on its own.
+	Index int
+
+	// Key is the measurement, tag key or field key at fault, or "" if the
+	// point as a whole is.
+	Key string
+
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	msg := e.Reason
+	if e.Key != "" {
+		msg = fmt.Sprintf("%q: %s", e.Key, msg)
+	}
+	if e.Index >= 0 {
+		msg = fmt.Sprintf("point %d: %s", e.Index, msg)
+	}
+	return "invalid " + msg
+}
+
+// Validate runs the checks the server applies to a point before storing it,
+// and returns a *ValidationError for the first one that fails: the
+// measurement, tag and field keys and the tag values must be printable, the
+// point needs a field, no tag or field may be named "time", string values must
+// not exceed MaxStringFieldLength, and the timestamp must be representable.
+// Limits that depend on the data already stored, such as max-values-per-tag,
+// are not checked.
+func (p *Point) Validate() error {
+	if err := validatePoint(p.pt); err != nil {
+		err.Index = -1
+		return err
+	}
+	return nil
+}
+
+// validatePoints validates every point of points, see Point.Validate.
+func validatePoints(points []*Point) error {
+	for i, p := range points {
+		if p == nil {
+			continue
+		}
+		if err := validatePoint(p.pt); err != nil {
+			err.Index = i
+			return err
+		}
+	}
+	return nil
+}
+
+// validateModelsPoints is like validatePoints for points of the models
+// package.
+func validateModelsPoints(points []models.Point) error {
+	for i, p := range points {
+		if p == nil {
+			continue
+		}
+		if err := validatePoint(p); err != nil {
+			err.Index = i
+			return err
+		}
+	}
+	return nil
+}
+
+func validatePoint(p models.Point) *ValidationError {
+	name := string(p.Name())
+	if name == "" {
+		return &ValidationError{Reason: "missing measurement"}
+	}
+	if !models.ValidKeyToken(name) {
+		return &ValidationError{Key: name, Reason: "measurement contains an unprintable character"}
+	}
+
+	for _, tag := range p.Tags() {
+		key := string(tag.Key)
+		switch {
+		case key == "time":
+			return &ValidationError{Key: key, Reason: "tag key is reserved"}
+		case !models.ValidKeyToken(key):
+			return &ValidationError{Key: key, Reason: "tag key contains an unprintable character"}
+		case !models.ValidKeyToken(string(tag.Value)):
+			return &ValidationError{Key: key, Reason: "tag value contains an unprintable character"}
+		}
+	}
+
+	fields, err := p.Fields()
+	if err != nil {
+		return &ValidationError{Reason: err.Error()}
+	}
+	if len(fields) == 0 {
+		return &ValidationError{Reason: "missing fields"}
+	}
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		switch {
+		case key == "time":
+			return &ValidationError{Key: key, Reason: "field key is reserved"}
+		case !models.ValidKeyToken(key):
+			return &ValidationError{Key: key, Reason: "field key contains an unprintable character"}
+		}
+		if s, ok := fields[key].(string); ok && len(s) > MaxStringFieldLength {
+			return &ValidationError{Key: key, Reason: fmt.Sprintf("string value of %d bytes exceeds the maximum of %d bytes", len(s), MaxStringFieldLength)}
+		}
+	}
+
+	if t := p.Time(); !t.IsZero() {
+		if err := models.CheckTime(t); err != nil {
+			return &ValidationError{Reason: err.Error()}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPoint_Validate(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		meas   string
+		tags   map[string]string
+		fields map[string]interface{}
+		key    string
+		reason string
+	}{
+		{"valid", "cpu", map[string]string{"host": "a"}, map[string]interface{}{"value": 1.0, "s": "x"}, "", ""},
+		{"empty measurement", "", nil, map[string]interface{}{"value": 1.0}, "", "missing measurement"},
+		{"newline in measurement", "cpu\n", nil, map[string]interface{}{"value": 1.0}, "cpu\n", "measurement contains an unprintable character"},
+		{"newline in tag key", "cpu", map[string]string{"ho\nst": "a"}, map[string]interface{}{"value": 1.0}, "ho\nst", "tag key contains an unprintable character"},
+		{"newline in tag value", "cpu", map[string]string{"host": "a\n"}, map[string]interface{}{"value": 1.0}, "host", "tag value contains an unprintable character"},
+		{"time tag", "cpu", map[string]string{"time": "a"}, map[string]interface{}{"value": 1.0}, "time", "tag key is reserved"},
+		{"time field", "cpu", nil, map[string]interface{}{"time": 1.0}, "time", "field key is reserved"},
+		{"long string", "cpu", nil, map[string]interface{}{"s": strings.Repeat("x", MaxStringFieldLength+1)}, "s", "string value of 65537 bytes exceeds the maximum of 65536 bytes"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			pt, err := NewPoint(tt.meas, tt.tags, tt.fields)
+			if err != nil {
+				t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+			}
+			err = pt.Validate()
+			if tt.reason == "" {
+				if err != nil {
+					t.Errorf("unexpected error.  expected %v, actual %v", nil, err)
+				}
+				return
+			}
+			var ve *ValidationError
+			if !errors.As(err, &ve) {
+				t.Fatalf("unexpected error.  expected %T, actual %v", ve, err)
+			}
+			if ve.Index != -1 || ve.Key != tt.key || ve.Reason != tt.reason {
+				t.Errorf("unexpected validation error: %+v", ve)
+			}
+		})
+	}
+}
+
+func TestBatchPoints_Validate(t *testing.T) {
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	good, _ := NewPoint("cpu", nil, map[string]interface{}{"value": 1.0})
+	bad, _ := NewPoint("cpu", map[string]string{"time": "a"}, map[string]interface{}{"value": 1.0})
+	bp.AddPoints([]*Point{good, good, bad})
+
+	err := bp.Validate()
+	if exp := `invalid point 2: "time": tag key is reserved`; err == nil || err.Error() != exp {
+		t.Errorf("unexpected error.  expected %v, actual %v", exp, err)
+	}
+}
+
+func TestClient_WriteValidatePoints(t *testing.T) {
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	bp, _ := NewBatchPoints(BatchPointsConfig{})
+	bad, _ := NewPoint("cpu", nil, map[string]interface{}{"time": 1.0})
+	bp.AddPoint(bad)
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL, ValidatePoints: true})
+	defer c.Close()
+	var ve *ValidationError
+	if err := c.Write(bp); !errors.As(err, &ve) || ve.Index != 0 {
+		t.Errorf("unexpected error.  expected %T, actual %v", ve, err)
+	}
+	if requests != 0 {
+		t.Errorf("unexpected number of requests.  expected %v, actual %v", 0, requests)
+	}
+
+	// Validation is off by default.
+	c2, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c2.Close()
+	if err := c2.Write(bp); err != nil {
+		t.Errorf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+
+	udp, _ := NewUDPClient(UDPConfig{Addr: "localhost:8089", ValidatePoints: true})
+	defer udp.Close()
+	if err := udp.Write(bp); !errors.As(err, &ve) {
+		t.Errorf("unexpected error.  expected %T, actual %v", ve, err)
+	}
+}
@@ -0,0 +1,117 @@
+package client
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+// ModelsWriter is implemented by the HTTP, UDP and TCP clients. It writes
+// points of the models package, such as those parsed from line protocol,
+// without wrapping each of them in a Point first.
+type ModelsWriter interface {
+	// WriteModels writes points like a Write of a batch with the database
+	// db, retention policy rp and precision would, nil points being
+	// skipped. The UDP and TCP clients ignore db and rp, as they do for a
+	// batch.
+	WriteModels(ctx context.Context, db, rp, precision string, points []models.Point) error
+}
+
+// WriteModels writes points to the database db and retention policy rp.
+func (c *client) WriteModels(ctx context.Context, db, rp, precision string, points []models.Point) error {
+	bp, err := NewBatchPoints(BatchPointsConfig{Database: db, RetentionPolicy: rp, Precision: precision})
+	if err != nil {
+		return err
+	}
+	if c.v2Write {
+		if _, err := v2Precision(bp.Precision()); err != nil {
+			return err
+		}
+	}
+	if c.validatePoints {
+		if err := validateModelsPoints(points); err != nil {
+			return err
+		}
+	}
+
+	var ws WriteStats
+	return c.writeEncoded(ctx, bp, nil, &ws, nil, func(w io.Writer) (int, error) {
+		var n int
+		var line []byte
+		for _, pt := range points {
+			if pt == nil {
+				continue
+			}
+			n++
+			line = models.AppendPrecisionString(line[:0], pt, bp.Precision())
+			line = append(line, '\n')
+			if _, err := w.Write(line); err != nil {
+				return 0, err
+			}
+		}
+		return n, nil
+	})
+}
+
+// WriteModels sends points in datagrams, rounded to precision.
+func (uc *udpclient) WriteModels(ctx context.Context, db, rp, precision string, points []models.Point) error {
+	if err := checkPrecision(precision); err != nil {
+		return err
+	}
+	at := func(i int) models.Point { return points[i] }
+	if err := checkDatagrams(uc.maxDatagrams, len(points), at, precision, uc.timePrecision, uc.payloadSize); err != nil {
+		return err
+	}
+	_, err := uc.write(ctx, len(points), func() error { return validateModelsPoints(points) }, func(flush func([]byte) error) error {
+		return writeModelsPayloads(ctx, points, precision, uc.timePrecision, &uc.bufs, uc.payloadSize, false, flush)
+	})
+	return err
+}
+
+// WriteModels sends points over the connection, rounded to precision.
+func (uc *tcpclient) WriteModels(ctx context.Context, db, rp, precision string, points []models.Point) error {
+	if err := checkPrecision(precision); err != nil {
+		return err
+	}
+	_, err := uc.write(ctx, len(points), func() error { return validateModelsPoints(points) }, func(flush func([]byte) error) error {
+		return writeModelsPayloads(ctx, points, precision, uc.timePrecision, &uc.bufs, uc.payloadSize, true, flush)
+	})
+	return err
+}
+
+// WriteModels sends points over the connections of the pool, rounded to
+// precision.
+func (p *tcppool) WriteModels(ctx context.Context, db, rp, precision string, points []models.Point) error {
+	if err := checkPrecision(precision); err != nil {
+		return err
+	}
+	_, err := p.write(ctx, len(points), func() error { return validateModelsPoints(points) }, func(flush func([]byte) error) error {
+		return writeModelsPayloads(ctx, points, precision, p.timePrecision, &p.bufs, p.payloadSize, true, flush)
+	})
+	return err
+}
+
+// checkPrecision returns an error for a precision a batch would not accept.
+func checkPrecision(precision string) error {
+	if precision == "" {
+		return nil
+	}
+	_, err := time.ParseDuration("1" + precision)
+	return err
+}
+
+// WriteModels sends points in datagrams to the addresses of the pool,
+// rounded to precision.
+func (p *udppool) WriteModels(ctx context.Context, db, rp, precision string, points []models.Point) error {
+	if err := checkPrecision(precision); err != nil {
+		return err
+	}
+	at := func(i int) models.Point { return points[i] }
+	if err := checkDatagrams(p.maxDatagrams, len(points), at, precision, p.timePrecision, p.payloadSize); err != nil {
+		return err
+	}
+	_, err := p.write(ctx, len(points), func() error { return validateModelsPoints(points) }, p.encode(ctx, len(points), at, precision, nil))
+	return err
+}
@@ -0,0 +1,124 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb1-client/models"
+)
+
+func newModelsPoints(tb testing.TB, n int) []models.Point {
+	tb.Helper()
+	var b []byte
+	for i := 0; i < n; i++ {
+		b = append(b, fmt.Sprintf("cpu,host=server%02d value=%di %d\n", i%50, i, int64(i)*int64(time.Millisecond)+123)...)
+	}
+	points, err := models.ParsePoints(b)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return points
+}
+
+func wrapModelsPoints(db, precision string, points []models.Point) BatchPoints {
+	bp, _ := NewBatchPoints(BatchPointsConfig{Database: db, Precision: precision})
+	for _, pt := range points {
+		bp.AddPoint(NewPointFrom(pt))
+	}
+	return bp
+}
+
+func TestClient_WriteModels(t *testing.T) {
+	var bodies []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, r.URL.RawQuery+"\n"+string(b))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+
+	points := newModelsPoints(t, 3)
+	if err := c.(ModelsWriter).WriteModels(context.Background(), "db0", "", "ms", append(points, nil)); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if err := c.Write(wrapModelsPoints("db0", "ms", points)); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if len(bodies) != 2 || bodies[0] != bodies[1] {
+		t.Errorf("unexpected requests, expected the same twice: %q", bodies)
+	}
+
+	if err := c.(ModelsWriter).WriteModels(context.Background(), "db0", "", "bogus", points); err == nil {
+		t.Error("expected an error for an invalid precision")
+	}
+}
+
+func TestTCPClient_WriteModels(t *testing.T) {
+	points := newModelsPoints(t, 20)
+
+	w := &bufferConn{}
+	cl := &tcpclient{conn: w, payloadSize: 128}
+	if err := cl.WriteModels(context.Background(), "", "", "ms", points); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	exp := &bufferConn{}
+	cl = &tcpclient{conn: exp, payloadSize: 128}
+	if err := cl.Write(wrapModelsPoints("", "ms", points)); err != nil {
+		t.Fatalf("unexpected error.  expected %v, actual %v", nil, err)
+	}
+	if w.String() != exp.String() {
+		t.Errorf("unexpected payloads.\nexpected %q\nactual   %q", exp.String(), w.String())
+	}
+}
+
+func TestUDPClient_WriteModelsValidate(t *testing.T) {
+	pt, _ := models.NewPoint("cpu", models.NewTags(map[string]string{"time": "x"}), models.Fields{"value": 1.0}, time.Time{})
+	cl := &udpclient{conn: brokenConn{}, payloadSize: UDPPayloadSize, validatePoints: true}
+
+	err := cl.WriteModels(context.Background(), "", "", "", []models.Point{nil, pt})
+	var ve *ValidationError
+	if !errors.As(err, &ve) || ve.Index != 1 {
+		t.Errorf("unexpected error.  expected %T, actual %v", ve, err)
+	}
+}
+
+func benchmarkWriteModels(b *testing.B, write func(c Client, points []models.Point) error) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	c, _ := NewHTTPClient(HTTPConfig{Addr: ts.URL})
+	defer c.Close()
+	points := newModelsPoints(b, 100000)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := write(c, points); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkClient_WriteModels(b *testing.B) {
+	benchmarkWriteModels(b, func(c Client, points []models.Point) error {
+		return c.(ModelsWriter).WriteModels(context.Background(), "db0", "", "ms", points)
+	})
+}
+
+func BenchmarkClient_WriteModelsWrapped(b *testing.B) {
+	benchmarkWriteModels(b, func(c Client, points []models.Point) error {
+		return c.Write(wrapModelsPoints("db0", "ms", points))
+	})
+}